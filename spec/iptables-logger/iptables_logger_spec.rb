@@ -35,9 +35,168 @@ module Bosh::Template::Test
               'host_ip' => '1.2.3.4',
               'host_guid' => 'some-guid',
               'log_timestamp_format' => 'rfc3339',
+              'debug_server_host' => '127.0.0.1',
+              'debug_server_port' => 8724,
+              'output_buffer_size' => 10000,
+              'max_output_file_size_mib' => 512,
             })
           end
 
+          context 'when output_buffer_size is less than 1' do
+            before do
+              merged_manifest_properties['output_buffer_size'] = 0
+            end
+
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, spec: spec)
+              }.to raise_error("'output_buffer_size' must be at least 1.")
+            end
+          end
+
+          context 'when max_output_file_size_mib is negative' do
+            before do
+              merged_manifest_properties['max_output_file_size_mib'] = -1
+            end
+
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, spec: spec)
+              }.to raise_error("'max_output_file_size_mib' must not be negative.")
+            end
+          end
+
+          context 'when a syslog server is set' do
+            before do
+              merged_manifest_properties['syslog'] = {
+                'address' => 'syslog.example.com:6514',
+                'ca_cert' => 'some-syslog-ca-cert',
+              }
+            end
+
+            it 'renders the syslog config' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec))
+              expect(clientConfig['syslog']).to eq({
+                'address' => 'syslog.example.com:6514',
+                'ca_cert_file' => '/var/vcap/jobs/iptables-logger/config/certs/syslog/ca.crt',
+              })
+            end
+
+            it 'renders the ca cert' do
+              expect(job.template('config/certs/syslog/ca.crt').render(merged_manifest_properties)).to eq("some-syslog-ca-cert\n")
+            end
+          end
+
+          context 'when a webhook is set' do
+            before do
+              merged_manifest_properties['webhook'] = {
+                'url' => 'https://webhook.example.com/events',
+              }
+            end
+
+            it 'renders the webhook config' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec))
+              expect(clientConfig['webhook']).to eq({
+                'url' => 'https://webhook.example.com/events',
+                'batch_size' => 100,
+                'flush_interval_seconds' => 5,
+                'max_retries' => 3,
+              })
+            end
+
+            context 'when the url is not https' do
+              before do
+                merged_manifest_properties['webhook']['url'] = 'http://webhook.example.com/events'
+              end
+
+              it 'throws a helpful error' do
+                expect {
+                  template.render(merged_manifest_properties, spec: spec)
+                }.to raise_error("'webhook.url' must be an https URL.")
+              end
+            end
+          end
+
+          context 'when container_events is enabled' do
+            before do
+              merged_manifest_properties['container_events'] = {'enabled' => true}
+            end
+
+            it 'renders the socket of the logger' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec))
+              expect(clientConfig['container_events_socket']).to eq('/var/vcap/data/container-metadata/events/iptables-logger.sock')
+            end
+          end
+
+          context 'when tenant_logs is enabled' do
+            before do
+              merged_manifest_properties['tenant_logs'] = {
+                'enabled' => true,
+                'file_group' => 'tenant-logs',
+              }
+            end
+
+            it 'renders the tenant logs config' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec))
+              expect(clientConfig['tenant_logs']).to eq({
+                'directory' => '/var/vcap/sys/log/iptables-logger/tenants',
+                'layout' => 'space',
+                'file_group' => 'tenant-logs',
+              })
+            end
+
+            context 'when the layout is invalid' do
+              before do
+                merged_manifest_properties['tenant_logs']['layout'] = 'app'
+              end
+
+              it 'throws a helpful error' do
+                expect {
+                  template.render(merged_manifest_properties, spec: spec)
+                }.to raise_error("'app' is not a valid layout for the property 'tenant_logs.layout'. Valid options are: 'org' and 'space'.")
+              end
+            end
+          end
+
+          context 'when resolve_source_apps is enabled' do
+            let(:vpa_link) do
+              Link.new(name: 'vpa', properties: {
+                'ca_cert' => 'some-ca-cert',
+                'client_cert' => 'some-client-cert',
+                'client_key' => 'some-client-key',
+              })
+            end
+
+            before do
+              merged_manifest_properties['resolve_source_apps'] = true
+            end
+
+            it 'renders the policy server client config' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: [vpa_link]))
+              expect(clientConfig).to include({
+                'policy_server_url' => 'https://policy-server.service.cf.internal:4003',
+                'ca_cert_file' => '/var/vcap/jobs/iptables-logger/config/certs/policy-agent/ca.crt',
+                'client_cert_file' => '/var/vcap/jobs/iptables-logger/config/certs/policy-agent/client.crt',
+                'client_key_file' => '/var/vcap/jobs/iptables-logger/config/certs/policy-agent/client.key',
+                'tag_cache_ttl_seconds' => 60,
+              })
+            end
+
+            it 'renders the client certs from the vpa link' do
+              expect(job.template('config/certs/policy-agent/ca.crt').render({}, consumes: [vpa_link])).to eq("some-ca-cert\n")
+              expect(job.template('config/certs/policy-agent/client.crt').render({}, consumes: [vpa_link])).to eq("some-client-cert\n")
+              expect(job.template('config/certs/policy-agent/client.key').render({}, consumes: [vpa_link])).to eq("some-client-key\n")
+            end
+
+            context 'when the vpa link is missing' do
+              it 'throws a helpful error' do
+                expect {
+                  template.render(merged_manifest_properties, spec: spec)
+                }.to raise_error('resolve_source_apps requires the vxlan-policy-agent job on the same instance group.')
+              end
+            end
+          end
+
           context 'when logging.format.timestamp is set to an invalid value' do
             let(:merged_manifest_properties) do
               {
@@ -45,7 +45,7 @@ module Bosh::Template::Test
     let(:mtu) {0}
     let(:disable) {false}
     let(:networks) {{'fake-network' => {'fake-network-settings' => {}, 'ip' => '192.74.65.4'}}}
-    let(:spec) {InstanceSpec.new(networks: networks, ip: '111.11.11.1')}
+    let(:spec) {InstanceSpec.new(networks: networks, ip: '111.11.11.1', id: 'some-guid')}
 
 
     describe 'cni-wrapper-plugin.conflist' do
@@ -62,6 +62,7 @@ module Bosh::Template::Test
             'datastore' => '/var/vcap/data/container-metadata/store.json',
             'datastore_file_owner' => 'vcap',
             'datastore_file_group' => 'vcap',
+            'container_events_directory' => '/var/vcap/data/container-metadata/events',
             'iptables_lock_file' => '/var/vcap/data/garden-cni/iptables.lock',
             'instance_address' => '111.11.11.1',
             'no_masquerade_cidr_range' => '222.22.0.0/16',
@@ -70,13 +71,22 @@ module Bosh::Template::Test
             'iptables_asg_logging' => true,
             'iptables_c2c_logging' => true,
             'iptables_denied_logs_per_sec' => 2,
+            'iptables_denied_logs_per_destination' => false,
             'iptables_accepted_udp_logs_per_sec' => 3,
+            'iptables_cell_logs_per_sec' => 0,
+            'iptables_cell_logs_burst' => 0,
+            'reject_tcp_with_reset' => false,
+            'related_established' => {
+              'scope' => 'all',
+              'interfaces' => [],
+            },
             'ingress_tag' => 'ffff0000',
             'vtep_name' => 'silk-vtep',
             'dns_servers' => ['8.8.8.8'],
             'policy_agent_force_poll_address' => '127.0.0.1:5555',
             'host_tcp_services' => ['169.254.0.2:9001', '169.254.0.2:9002'],
             'host_udp_services' => ['169.254.0.2:9003', '169.254.0.2:9004'],
+            'host_services_registry_dir' => '/var/vcap/data/host-services',
             'deny_networks' => {
               'always' => ['1.1.1.1/32'],
               'running' => ['2.2.2.2/32'],
@@ -89,14 +99,42 @@ module Bosh::Template::Test
               'daemonPort' => 8080,
               'dataDir' => '/var/vcap/data/host-local',
               'datastore' => '/var/vcap/data/silk/store.json',
-              'mtu' => 0
+              'mtu' => 0,
+              'reversePathFilter' => {
+                'host' => 'strict',
+                'container' => 'strict'
+              },
+              'neighborMode' => 'static'
+            },
+            'egress_proxy' => {
+              'space_guids' => [],
+              'endpoints' => [],
             },
+            'sni_inspection' => {
+              'fail_open' => false,
+              'allowlists' => [],
+            },
+            'uid_exemptions' => [],
             'outbound_connections' => {
               'limit' => true,
               'logging' => true,
               'burst' => 1000,
               'rate_per_sec' => 100,
               'dry_run' => false,
+            },
+            'timeouts' => {
+              'delegate_seconds' => 60,
+              'iptables_seconds' => 60,
+              'datastore_seconds' => 30,
+              'policy_agent_seconds' => 60,
+            },
+            'chain_batch' => {
+              'dir' => '',
+              'window_ms' => 20,
+            },
+            'feature_flags' => {
+              'file' => '/var/vcap/data/network-feature-flags/flags.json',
+              'cell_id' => 'some-guid',
             }
           }, {
             'name' => 'bandwidth-limit',
@@ -109,6 +147,77 @@ module Bosh::Template::Test
         })
       end
 
+      context 'when the vpa link has egress proxy spaces' do
+        let(:links) {[
+          Link.new(
+            name: 'vpa',
+            properties: {
+              'force_policy_poll_cycle_port' => 5555,
+              'egress_proxy' => {
+                'space_guids' => ['some-space-guid'],
+                'endpoints' => [{'destination' => '10.0.5.5', 'protocol' => 'tcp', 'ports' => '3128'}],
+              }
+            }
+          )
+        ]}
+
+        it 'passes them to the wrapper so new containers start proxy-only' do
+          clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['egress_proxy']).to eq({
+            'space_guids' => ['some-space-guid'],
+            'endpoints' => [{'destination' => '10.0.5.5', 'protocol' => 'tcp', 'ports' => '3128'}],
+          })
+        end
+      end
+
+      context 'when the vpa link has sni allowlists' do
+        let(:links) {[
+          Link.new(
+            name: 'vpa',
+            properties: {
+              'force_policy_poll_cycle_port' => 5555,
+              'sni_inspection' => {
+                'fail_open' => true,
+                'allowlists' => [{'queue_num' => 100, 'space_guids' => ['some-space-guid'], 'server_names' => ['api.example.com']}],
+              }
+            }
+          )
+        ]}
+
+        it 'passes them to the wrapper so new containers are inspected' do
+          clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['sni_inspection']).to eq({
+            'fail_open' => true,
+            'allowlists' => [{'queue_num' => 100, 'space_guids' => ['some-space-guid'], 'server_names' => ['api.example.com']}],
+          })
+        end
+      end
+
+      context 'when uid exemptions are set' do
+        it 'passes them to the wrapper' do
+          merged_manifest_properties['uid_exemptions'] = [
+            {'uid' => 2000, 'dscp' => 10, 'bypass' => true},
+            {'uid' => 2001, 'dscp' => 11, 'destinations' => [{'destination' => '10.0.5.5', 'protocol' => 'tcp', 'ports' => '443'}]},
+          ]
+          clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['uid_exemptions']).to eq([
+            {'uid' => 2000, 'dscp' => 10, 'bypass' => true},
+            {'uid' => 2001, 'dscp' => 11, 'destinations' => [{'destination' => '10.0.5.5', 'protocol' => 'tcp', 'ports' => '443'}]},
+          ])
+        end
+      end
+
+      context 'when the chain batch is enabled' do
+        it 'passes its directory and window to the wrapper' do
+          merged_manifest_properties['chain_batch'] = {'enabled' => true, 'window_ms' => 50}
+          clientConfig = JSON.parse(template.render(merged_manifest_properties, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['chain_batch']).to eq({
+            'dir' => '/var/vcap/data/garden-cni/chain-batch',
+            'window_ms' => 50,
+          })
+        end
+      end
+
       context 'when ips have leading 0s' do
         it 'no_masquerade_cidr_range fails with a nice message' do
           merged_manifest_properties['no_masquerade_cidr_range'] = '222.022.0.2/16'
@@ -198,6 +307,179 @@ module Bosh::Template::Test
         end
       end
 
+      context 'when flat mode is enabled' do
+        it 'configures the delegate to run without the overlay' do
+          contents = merged_manifest_properties.merge(
+            'mtu' => 1500,
+            'flat' => {
+              'enabled' => true,
+              'subnet_file' => '/var/vcap/data/flat/subnet.env',
+              'underlay_interface' => 'eth0',
+              'route_hook' => '/var/vcap/jobs/bgp/bin/route-hook'
+            }
+          )
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          delegate = clientConfig['plugins'][0]['delegate']
+          expect(delegate['subnetFile']).to eq('/var/vcap/data/flat/subnet.env')
+          expect(delegate['flat']).to eq({
+            'underlayInterface' => 'eth0',
+            'routeHook' => '/var/vcap/jobs/bgp/bin/route-hook'
+          })
+          expect(delegate['mtu']).to eq(1500)
+        end
+
+        context 'when the subnet file is not set' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge(
+              'flat' => { 'enabled' => true, 'underlay_interface' => 'eth0' }
+            )
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("'flat.underlay_interface' and 'flat.subnet_file' must be set when 'flat.enabled' is true")
+          end
+        end
+      end
+
+      context 'when a delegated ipam plugin is set' do
+        it 'configures the delegate to assign the addresses with it' do
+          contents = merged_manifest_properties.merge(
+            'delegated_ipam' => {
+              'type' => 'some-ipam',
+              'plugin_dir' => '/var/vcap/packages/some-ipam/bin',
+              'config' => { 'pool' => 'apps' }
+            }
+          )
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['delegate']['delegatedIPAM']).to eq({
+            'type' => 'some-ipam',
+            'pluginDir' => '/var/vcap/packages/some-ipam/bin',
+            'config' => { 'pool' => 'apps' }
+          })
+        end
+
+        context 'when it is dhcp without flat mode' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge(
+              'delegated_ipam' => { 'type' => 'dhcp' }
+            )
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("'delegated_ipam.type' dhcp requires 'flat.enabled'")
+          end
+        end
+      end
+
+      context 'when peer scoped addressing is enabled' do
+        let(:contents) { merged_manifest_properties.merge('peer_scoped_addressing' => true) }
+
+        context 'when a cf_network.network link exists' do
+          let(:links) {[
+            Link.new(
+              name: 'cf_network',
+              properties: {
+                'network' => '10.255.0.0/16'
+              }
+            ),
+            Link.new(
+              name: 'vpa',
+              properties: {
+                'force_policy_poll_cycle_port' => 5555
+              }
+            )
+          ]}
+
+          it 'passes the overlay network to the delegate' do
+            clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+            delegate = clientConfig['plugins'][0]['delegate']
+            expect(delegate['peerScopedAddressing']).to eq(true)
+            expect(delegate['overlayNetwork']).to eq('10.255.0.0/16')
+          end
+        end
+
+        context 'when there is no cf_network link' do
+          it 'raises a descriptive error' do
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("'peer_scoped_addressing' requires the cf_network link")
+          end
+        end
+      end
+
+      context 'when the reverse path filter is set' do
+        it 'passes the modes to the delegate' do
+          contents = merged_manifest_properties.merge(
+            'reverse_path_filter' => {
+              'host_interfaces' => 'loose',
+              'container_interfaces' => 'off'
+            }
+          )
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['delegate']['reversePathFilter']).to eq({
+            'host' => 'loose',
+            'container' => 'off'
+          })
+        end
+
+        context 'when a mode is invalid' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge(
+              'reverse_path_filter' => { 'host_interfaces' => '1' }
+            )
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid reverse_path_filter.host_interfaces '1': must be one of strict, loose or off")
+          end
+        end
+      end
+
+      context 'when the neighbor mode is set' do
+        it 'passes the mode to the delegate' do
+          contents = merged_manifest_properties.merge('neighbor_mode' => 'dynamic')
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['delegate']['neighborMode']).to eq('dynamic')
+        end
+
+        context 'when the mode is invalid' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('neighbor_mode' => 'arp')
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid neighbor_mode 'arp': must be one of static or dynamic")
+          end
+        end
+      end
+
+      context 'when related_established is provided' do
+        it 'renders the scope and the interfaces' do
+          contents = merged_manifest_properties.merge(
+            'related_established' => { 'scope' => 'interface', 'interfaces' => ['eth0'] }
+          )
+          clientConfig = JSON.parse(template.render(contents, spec: spec, consumes: links))
+          expect(clientConfig['plugins'][0]['related_established']).to eq(
+            'scope' => 'interface',
+            'interfaces' => ['eth0'],
+          )
+        end
+
+        context 'when the scope is invalid' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('related_established' => { 'scope' => 'none' })
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error("Invalid related_established.scope 'none': must be one of all, connmark or interface")
+          end
+        end
+
+        context 'when the scope is interface without interfaces' do
+          it 'raises a descriptive error' do
+            contents = merged_manifest_properties.merge('related_established' => { 'scope' => 'interface' })
+            expect {
+              template.render(contents, spec: spec, consumes: links)
+            }.to raise_error('Invalid related_established.interfaces: must not be empty when related_established.scope is interface')
+          end
+        end
+      end
+
       context 'when deny_networks are provided' do
         context 'when a destination is IPv6' do
           it 'raises a descriptive error' do
@@ -23,5 +23,22 @@ module Bosh::Template::Test
         ]
       })
     end
+
+    context 'when flat mode is enabled' do
+      let(:merged_manifest_properties) do
+        {
+          'flat' => {
+            'enabled' => true,
+            'underlay_interface' => 'eth1',
+            'subnet_file' => '/var/vcap/data/flat/subnet.env'
+          }
+        }
+      end
+
+      it 'sets the underlay interface to sweep the proxy neighbors of' do
+        clientConfig = JSON.parse(template.render(merged_manifest_properties))
+        expect(clientConfig['flat_underlay_interface']).to eq('eth1')
+      end
+    end
   end
 end
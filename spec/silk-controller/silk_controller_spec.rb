@@ -61,11 +61,13 @@ module Bosh::Template::Test
         expect(config).to eq({
           'debug_server_port' => 1234,
           'health_check_port' => 2345,
+          'admin_port' => 4105,
           'listen_host' => '123.123.2.2',
           'listen_port' => 2222,
           'ca_cert_file' => '/var/vcap/jobs/silk-controller/config/certs/ca.crt',
           'server_cert_file' => '/var/vcap/jobs/silk-controller/config/certs/server.crt',
           'server_key_file' => '/var/vcap/jobs/silk-controller/config/certs/server.key',
+          'client_spiffe_ids' => [],
           'network' => '10.255.0.1/12',
           'subnet_prefix_length' => 30,
           'database' => {
@@ -87,10 +89,47 @@ module Bosh::Template::Test
           'log_prefix' => 'cfnetworking',
           'max_idle_connections' => 10,
           'max_open_connections' => 1,
-          'connections_max_lifetime_seconds' => 31
+          'connections_max_lifetime_seconds' => 31,
+          'egress_gateways' => [],
+          'network_ipv6' => '',
+          'subnet_prefix_length_ipv6' => 64,
+          'lease_reclamation_grace_seconds' => 0,
+          'lease_reclamation_probe_port' => 0,
+          'lease_reclamation_probe_timeout_seconds' => 2,
+          'pinned_underlay_ips' => [],
+          'shard_index' => 0,
+          'shard_count' => 0
         })
       end
 
+      it 'authenticates with the svid when spiffe is enabled' do
+        merged_manifest_properties['spiffe'] = {
+          'enabled' => true,
+          'svid_dir' => '/var/vcap/data/spire',
+          'client_ids' => ['spiffe://example.org'],
+        }
+        config = JSON.parse(template.render(merged_manifest_properties))
+        expect(config['ca_cert_file']).to eq('/var/vcap/data/spire/svid_bundle.pem')
+        expect(config['server_cert_file']).to eq('/var/vcap/data/spire/svid.pem')
+        expect(config['server_key_file']).to eq('/var/vcap/data/spire/svid_key.pem')
+        expect(config['client_spiffe_ids']).to eq(['spiffe://example.org'])
+      end
+
+      it 'shards the subnet pool between the instances of the job' do
+        silk_controller_link = Link.new(
+          name: 'silk_controller',
+          instances: [
+            LinkInstance.new(id: 'ccc'),
+            LinkInstance.new(id: 'aaa'),
+            LinkInstance.new(id: 'bbb'),
+          ],
+          properties: {}
+        )
+        config = JSON.parse(template.render(merged_manifest_properties, consumes: [silk_controller_link], spec: InstanceSpec.new(id: 'bbb')))
+        expect(config['shard_index']).to eq(1)
+        expect(config['shard_count']).to eq(3)
+      end
+
       it 'uses the database link for host when the property is not set' do
         merged_manifest_properties['database'].delete('host')
         config = JSON.parse(template.render(merged_manifest_properties, consumes: [database_link]))
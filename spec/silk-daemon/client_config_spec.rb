@@ -51,6 +51,7 @@ module Bosh::Template::Test
             clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
             expect(clientConfig).to eq({
               'underlay_ip' => '192.168.0.0',
+              'secondary_underlay_ip' => '',
               'subnet_prefix_length' => 24,
               'overlay_network' => '10.255.0.0/16',
               'health_check_port' => 12345,
@@ -59,6 +60,7 @@ module Bosh::Template::Test
               'ca_cert_file' => '/var/vcap/jobs/silk-daemon/config/certs/ca.crt',
               'client_cert_file' => '/var/vcap/jobs/silk-daemon/config/certs/client.crt',
               'client_key_file' => '/var/vcap/jobs/silk-daemon/config/certs/client.key',
+              'controller_spiffe_ids' => [],
               'vni' => 1,
               'poll_interval' => 30,
               'debug_server_port' => 89,
@@ -70,10 +72,69 @@ module Bosh::Template::Test
               'log_prefix' => 'cfnetworking',
               'log_level' => 'error',
               'vxlan_interface_name' => '',
-              'single_ip_only' => true
+              'single_ip_only' => true,
+              'self_test_port' => 0,
+              'self_test_ca_cert_file' => '/var/vcap/jobs/silk-daemon/config/certs/self-test/ca.crt',
+              'self_test_server_cert_file' => '/var/vcap/jobs/silk-daemon/config/certs/self-test/server.crt',
+              'self_test_server_key_file' => '/var/vcap/jobs/silk-daemon/config/certs/self-test/server.key',
+              'enable_egress_gateways' => false,
+              'container_metadata_file' => '/var/vcap/data/container-metadata/store.json',
+              'iptables_lock_file' => '/var/vcap/data/garden-cni/iptables.lock',
+              'encapsulated_ttl' => 0,
+              'container_egress_ttl' => 0,
+              'vtep_reverse_path_filter' => 'loose',
+              'bgp' => {
+                'local_as' => 0,
+                'hold_time_seconds' => 90,
+                'health_timeout_seconds' => 0,
+                'peers' => []
+              },
+              'sysctls' => [],
+              'conntrack' => {
+                'autotune' => false,
+                'entries_per_gib_memory' => 16384,
+                'entries_per_container' => 1024,
+                'expected_containers' => 0,
+                'entries_per_bucket' => 4,
+                'high_utilization_percent' => 90
+              },
+              'underlay_health' => {
+                'check_default_gateway' => false,
+                'check_vtep_carrier' => false,
+                'ping_timeout_ms' => 1000
+              },
+              'overlay_routing' => {
+                'table' => 0,
+                'metric' => 0,
+                'rule_priority' => 900
+              }
             })
           end
 
+          context 'when spiffe is enabled' do
+            before do
+              merged_manifest_properties['spiffe'] = {
+                'enabled' => true,
+                'controller_ids' => ['spiffe://example.org/silk-controller'],
+              }
+            end
+
+            it 'authenticates with the svid' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['ca_cert_file']).to eq('/var/vcap/data/spiffe/svid_bundle.pem')
+              expect(clientConfig['client_cert_file']).to eq('/var/vcap/data/spiffe/svid.pem')
+              expect(clientConfig['client_key_file']).to eq('/var/vcap/data/spiffe/svid_key.pem')
+              expect(clientConfig['controller_spiffe_ids']).to eq(['spiffe://example.org/silk-controller'])
+            end
+
+            it 'requires the ids of the controller' do
+              merged_manifest_properties['spiffe']['controller_ids'] = []
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("'spiffe.controller_ids' must be set when 'spiffe.enabled' is true")
+            end
+          end
+
           context 'when temporary_vxlan_interface and vxlan_network are set' do
             let(:merged_manifest_properties) do
               {
@@ -102,6 +163,19 @@ module Bosh::Template::Test
             end
           end
 
+          context 'when self_test.enabled is set' do
+            let(:merged_manifest_properties) do
+              {
+                'self_test' => { 'enabled' => true }
+              }
+            end
+
+            it 'serves the self-test on the default port' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['self_test_port']).to eq(4104)
+            end
+          end
+
           context 'when vxlan_network is set' do
             let(:merged_manifest_properties) do
               {
@@ -117,6 +191,236 @@ module Bosh::Template::Test
             end
           end
 
+          context 'when secondary_vxlan_network is set' do
+            let(:merged_manifest_properties) do
+              {
+                'vxlan_network' => 'fake-network',
+                'secondary_vxlan_network' => 'fake-secondary-network'
+              }
+            end
+            networks = {
+              'fake-network' => { 'fake-network-settings' => {}, 'ip' => "192.74.65.4" },
+              'fake-secondary-network' => { 'fake-network-settings' => {}, 'ip' => "192.74.66.4" }
+            }
+            spec = InstanceSpec.new(address: 'cloudfoundry.org', bootstrap: true, networks: networks)
+
+            it 'sets the secondary_underlay_ip to the ip associated with secondary_vxlan_network' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links, spec: spec))
+              expect(clientConfig['underlay_ip']).to eq("192.74.65.4")
+              expect(clientConfig['secondary_underlay_ip']).to eq("192.74.66.4")
+            end
+
+            context 'when the network does not exist' do
+              let(:merged_manifest_properties) do
+                {
+                  'vxlan_network' => 'fake-network',
+                  'secondary_vxlan_network' => 'missing-network'
+                }
+              end
+
+              it 'raises an error' do
+                expect {
+                  template.render(merged_manifest_properties, consumes: links, spec: spec)
+                }.to raise_error(/requested secondary_vxlan_network 'missing-network' not found in available networks/)
+              end
+            end
+
+            context 'when it is the vxlan network' do
+              let(:merged_manifest_properties) do
+                {
+                  'vxlan_network' => 'fake-network',
+                  'secondary_vxlan_network' => 'fake-network'
+                }
+              end
+
+              it 'raises an error' do
+                expect {
+                  template.render(merged_manifest_properties, consumes: links, spec: spec)
+                }.to raise_error("'secondary_vxlan_network' must be a different network from the vxlan network")
+              end
+            end
+          end
+
+          context 'when the ttls are set' do
+            let(:merged_manifest_properties) do
+              {
+                'ttl' => { 'encapsulated' => 1, 'container_egress' => 64 }
+              }
+            end
+
+            it 'renders them' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['encapsulated_ttl']).to eq(1)
+              expect(clientConfig['container_egress_ttl']).to eq(64)
+            end
+          end
+
+          context 'when a ttl is out of range' do
+            let(:merged_manifest_properties) do
+              {
+                'ttl' => { 'encapsulated' => 256 }
+              }
+            end
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("'ttl.encapsulated' must be a value between 0-255")
+            end
+          end
+
+          context 'when bgp peers are set' do
+            let(:merged_manifest_properties) do
+              {
+                'bgp' => {
+                  'local_as' => 65001,
+                  'peers' => [
+                    { 'address' => '10.0.16.1', 'as' => 65000 },
+                    { 'address' => '10.0.16.2', 'as' => 65000, 'port' => 1179 }
+                  ]
+                }
+              }
+            end
+
+            it 'renders them' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['bgp']).to eq({
+                'local_as' => 65001,
+                'hold_time_seconds' => 90,
+                'health_timeout_seconds' => 0,
+                'peers' => [
+                  { 'address' => '10.0.16.1', 'port' => 0, 'as' => 65000 },
+                  { 'address' => '10.0.16.2', 'port' => 1179, 'as' => 65000 }
+                ]
+              })
+            end
+
+            it 'requires the local as' do
+              merged_manifest_properties['bgp'].delete('local_as')
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("'bgp.local_as' must be set when 'bgp.peers' are set")
+            end
+
+            it 'requires the as of each peer' do
+              merged_manifest_properties['bgp']['peers'] = [{ 'address' => '10.0.16.1' }]
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("Each of 'bgp.peers' must have an 'address' and an 'as'")
+            end
+          end
+
+          context 'when sysctls are set' do
+            let(:merged_manifest_properties) do
+              {
+                'sysctls' => [
+                  { 'name' => 'net.netfilter.nf_conntrack_max', 'value' => 262144, 'minimum' => true },
+                  { 'name' => 'net.bridge.bridge-nf-call-iptables', 'value' => '1', 'optional' => true }
+                ]
+              }
+            end
+
+            it 'renders them' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['sysctls']).to eq([
+                { 'name' => 'net.netfilter.nf_conntrack_max', 'value' => '262144', 'minimum' => true, 'optional' => false },
+                { 'name' => 'net.bridge.bridge-nf-call-iptables', 'value' => '1', 'minimum' => false, 'optional' => true }
+              ])
+            end
+
+            it 'requires the value of each sysctl' do
+              merged_manifest_properties['sysctls'] = [{ 'name' => 'net.ipv4.ip_forward' }]
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("Each of 'sysctls' must have a 'name' and a 'value'")
+            end
+          end
+
+          context 'when conntrack autotuning is enabled' do
+            let(:merged_manifest_properties) do
+              {
+                'conntrack' => { 'autotune' => true, 'expected_containers' => 100 }
+              }
+            end
+
+            it 'renders it' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['conntrack']['autotune']).to eq(true)
+              expect(clientConfig['conntrack']['expected_containers']).to eq(100)
+            end
+
+            it 'requires entries in every bucket' do
+              merged_manifest_properties['conntrack']['entries_per_bucket'] = 0
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("'conntrack.entries_per_bucket' must be at least 1")
+            end
+          end
+
+          context 'when the lease renewal is gated on the default gateway' do
+            let(:merged_manifest_properties) do
+              {
+                'underlay_health' => { 'check_default_gateway' => true, 'ping_timeout_ms' => 500 }
+              }
+            end
+
+            it 'renders it' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['underlay_health']['check_default_gateway']).to eq(true)
+              expect(clientConfig['underlay_health']['ping_timeout_ms']).to eq(500)
+            end
+
+            it 'requires a ping timeout' do
+              merged_manifest_properties['underlay_health']['ping_timeout_ms'] = 0
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("'underlay_health.ping_timeout_ms' must be at least 1")
+            end
+          end
+
+          context 'when the overlay routes are installed in a table' do
+            let(:merged_manifest_properties) do
+              {
+                'overlay_routing' => { 'table' => 300, 'metric' => 50, 'rule_priority' => 100 }
+              }
+            end
+
+            it 'renders it' do
+              clientConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links))
+              expect(clientConfig['overlay_routing']).to eq({
+                'table' => 300,
+                'metric' => 50,
+                'rule_priority' => 100
+              })
+            end
+
+            it 'refuses the tables of the kernel' do
+              merged_manifest_properties['overlay_routing']['table'] = 254
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("'overlay_routing.table' must be between 1 and 4294967295 except for 253 to 255, or 0 for the main table")
+            end
+
+            it 'requires a rule priority before the main table' do
+              merged_manifest_properties['overlay_routing']['rule_priority'] = 32766
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("'overlay_routing.rule_priority' must be between 1 and 32765")
+            end
+          end
+
+          context 'when reverse_path_filter.vtep is set to an invalid value' do
+            let(:merged_manifest_properties) do
+              {
+                'reverse_path_filter' => { 'vtep' => 'sometimes' }
+              }
+            end
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, consumes: links)
+              }.to raise_error("Invalid reverse_path_filter.vtep 'sometimes': must be one of loose, strict or off")
+            end
+          end
+
           context 'when logging.format.timestamp is set to an invalid value' do
             let(:merged_manifest_properties) do
               {
@@ -0,0 +1,52 @@
+require 'rspec'
+require 'bosh/template/test'
+require 'json'
+
+module Bosh::Template::Test
+  describe 'errand-config.json.erb' do
+    let(:release_path) {File.join(File.dirname(__FILE__), '../..')}
+    let(:release) {ReleaseDir.new(release_path)}
+    let(:job) {release.job('silk-network-verification')}
+    let(:template) {job.template('config/errand-config.json')}
+    let(:merged_manifest_properties) do
+      {
+        'silk_controller' => {
+          'hostname' => 'some-host',
+          'listen_port' => 12345,
+        },
+        'self_test' => {
+          'port' => 4321,
+          'server_name' => 'some-server-name',
+        },
+        'concurrency' => 5,
+      }
+    end
+
+    it 'renders the template with the provided manifest properties' do
+      errandConfig = JSON.parse(template.render(merged_manifest_properties))
+      expect(errandConfig).to eq({
+        'connectivity_server_url' => 'https://some-host:12345',
+        'ca_cert_file' => '/var/vcap/jobs/silk-network-verification/config/certs/ca.crt',
+        'client_cert_file' => '/var/vcap/jobs/silk-network-verification/config/certs/client.crt',
+        'client_key_file' => '/var/vcap/jobs/silk-network-verification/config/certs/client.key',
+        'controller_spiffe_ids' => [],
+        'self_test_port' => 4321,
+        'self_test_ca_cert_file' => '/var/vcap/jobs/silk-network-verification/config/certs/self-test/ca.crt',
+        'self_test_server_name' => 'some-server-name',
+        'client_timeout_seconds' => 60,
+        'peer_count' => -1,
+        'concurrency' => 5,
+        'log_prefix' => 'cfnetworking'
+      })
+    end
+
+    context 'when concurrency is less than 1' do
+      it 'raises a helpful error' do
+        merged_manifest_properties['concurrency'] = 0
+        expect {
+          template.render(merged_manifest_properties)
+        }.to raise_error("'concurrency' must be at least 1")
+      end
+    end
+  end
+end
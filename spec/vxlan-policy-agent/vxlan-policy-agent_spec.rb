@@ -41,6 +41,14 @@ module Bosh::Template::Test
             properties: {
               'iptables_logging' => true,
               'iptables_denied_logs_per_sec' => 2,
+              'iptables_denied_logs_per_destination' => true,
+              'iptables_cell_logs_per_sec' => 100,
+              'iptables_cell_logs_burst' => 200,
+              'reject_tcp_with_reset' => true,
+              'related_established' => {
+                'scope' => 'connmark',
+                'interfaces' => [],
+              },
               'deny_networks' => {
                 'always' => ['1.1.1.1/32'],
                 'running' => ['2.2.2.2/32'],
@@ -50,7 +58,11 @@ module Bosh::Template::Test
                 'limit' => true,
                 'burst' => 1000,
                 'rate_per_sec' => 100,
-              }
+              },
+              'silk_daemon' => {
+                'listen_port' => 23954,
+              },
+              'feature_flags_file' => '/var/vcap/data/network-feature-flags/flags.json',
             }
           )
         ]
@@ -71,11 +83,17 @@ module Bosh::Template::Test
               'ca_cert_file' => '/var/vcap/jobs/vxlan-policy-agent/config/certs/ca.crt',
               'client_cert_file' => '/var/vcap/jobs/vxlan-policy-agent/config/certs/client.crt',
               'client_key_file' => '/var/vcap/jobs/vxlan-policy-agent/config/certs/client.key',
+              'policy_server_spiffe_ids' => [],
               'client_timeout_seconds' => 5,
               'cni_datastore_path' => '/var/vcap/data/container-metadata/store.json',
               'debug_server_host' => '127.0.0.1',
               'debug_server_port' => 8721,
+              'enable_self_metrics' => false,
+              'iptables_record_file' => '',
+              'container_events_socket' => '',
+              'managed_chain_name_version' => 1,
               'iptables_accepted_udp_logs_per_sec' => 33,
+              'iptables_sub_chain_min_rules' => 0,
               'iptables_c2c_logging' => true,
               'iptables_lock_file' => '/var/vcap/data/garden-cni/iptables.lock',
               'log_level' => 'error',
@@ -83,17 +101,65 @@ module Bosh::Template::Test
               'underlay_ips' => ['192.168.0.0'],
               'metron_address' => '127.0.0.1:55',
               'enable_overlay_ingress_rules' => true,
+              'enable_destinations' => false,
               'policy_server_url' => 'https://policy-server-hostname:4003',
               'poll_interval' => 22,
               'enable_asg_syncing' => false,
               'asg_poll_interval' => 66,
+              'asg_sync_batch_size' => 0,
+              'plan_dumps_to_keep' => 0,
+              'enforcement_timeout' => 300,
+              'iptables_backend_change' => 'alarm',
+              'container_icmp_echo' => 'deny',
+              'health_check_sources' => [],
+              'asg_cleanup_retry_interval' => 10,
+              'runtime_reconcile_interval' => 60,
+              'consistency_check_interval' => 0,
+              'repair_inconsistencies' => false,
+              'garden_network' => 'unix',
+              'garden_address' => '/var/vcap/data/garden/garden.sock',
+              'asg_syncing_pause_file' => '/var/vcap/data/vxlan-policy-agent/asg-syncing-paused',
+              'intent_log_file' => '/var/vcap/data/vxlan-policy-agent/iptables-intents.json',
+              'plan_dump_dir' => '/var/vcap/data/vxlan-policy-agent/plans',
               'vni' => 1,
               'force_policy_poll_cycle_host' => '127.0.0.1',
               'force_policy_poll_cycle_port' => 8722,
+              'sharding' => {
+                'workers' => 1,
+                'port_base' => 8730,
+              },
+              'chaos' => {
+                'faults_file' => '',
+                'vtep_port' => 4789,
+              },
               'disable_container_network_policy' => false,
               'overlay_network' => '10.255.0.0/16',
+              'egress_proxy' => {
+                'space_guids' => [],
+                'endpoints' => [],
+              },
+              'sni_inspection' => {
+                'fail_open' => false,
+                'allowlists' => [],
+              },
+              'global_chains' => [],
+              'policy_sources' => [],
+              'qos_classes' => [],
+              'silk_daemon_port' => 23954,
+              'feature_flags' => {
+                'file' => '/var/vcap/data/network-feature-flags/flags.json',
+                'cell_id' => 'some-guid',
+              },
               'iptables_asg_logging' => true,
               'iptables_denied_logs_per_sec' => 2,
+              'iptables_denied_logs_per_destination' => true,
+              'iptables_cell_logs_per_sec' => 100,
+              'iptables_cell_logs_burst' => 200,
+              'reject_tcp_with_reset' => true,
+              'related_established' => {
+                'scope' => 'connmark',
+                'interfaces' => [],
+              },
               'deny_networks' => {
                 'always' => ['1.1.1.1/32'],
                 'running' => ['2.2.2.2/32'],
@@ -111,6 +177,121 @@ module Bosh::Template::Test
             })
           end
 
+          context 'when egress proxy spaces are configured without asg syncing' do
+            before do
+              merged_manifest_properties['enable_asg_syncing'] = false
+              merged_manifest_properties['egress_proxy'] = {
+                'space_guids' => ['some-space-guid'],
+                'endpoints' => [{'destination' => '10.0.5.5', 'protocol' => 'tcp', 'ports' => '3128'}],
+              }
+            end
+
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, consumes: links, spec: spec)
+              }.to raise_error("'egress_proxy.space_guids' requires 'enable_asg_syncing' to be true.")
+            end
+          end
+
+          context 'when sni allowlists are configured without asg syncing' do
+            before do
+              merged_manifest_properties['enable_asg_syncing'] = false
+              merged_manifest_properties['sni_inspection'] = {
+                'allowlists' => [{'queue_num' => 100, 'space_guids' => ['some-space-guid'], 'server_names' => ['api.example.com']}],
+              }
+            end
+
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, consumes: links, spec: spec)
+              }.to raise_error("'sni_inspection.allowlists' requires 'enable_asg_syncing' to be true.")
+            end
+          end
+
+          context 'when iptables_backend_change is invalid' do
+            before do
+              merged_manifest_properties['iptables_backend_change'] = 'ignore'
+            end
+
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, consumes: links, spec: spec)
+              }.to raise_error("Invalid iptables_backend_change 'ignore': must be one of alarm or adapt")
+            end
+          end
+
+          context 'when container_icmp_echo is invalid' do
+            before do
+              merged_manifest_properties['container_icmp_echo'] = 'drop'
+            end
+
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, consumes: links, spec: spec)
+              }.to raise_error("Invalid container_icmp_echo 'drop': must be one of deny or accept")
+            end
+          end
+
+          context 'when record_iptables_calls is true' do
+            before do
+              merged_manifest_properties['record_iptables_calls'] = true
+            end
+
+            it 'renders the record file' do
+              renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links, spec: spec))
+              expect(renderedConfig['iptables_record_file']).to eq('/var/vcap/data/vxlan-policy-agent/iptables-calls.jsonl')
+            end
+          end
+
+          context 'when container_events is enabled' do
+            before do
+              merged_manifest_properties['container_events'] = {'enabled' => true}
+            end
+
+            it 'renders the socket of the agent' do
+              renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links, spec: spec))
+              expect(renderedConfig['container_events_socket']).to eq('/var/vcap/data/container-metadata/events/vxlan-policy-agent.sock')
+            end
+          end
+
+          context 'when sharding.workers is less than 1' do
+            before do
+              merged_manifest_properties['sharding'] = {'workers' => 0}
+            end
+
+            it 'throws a helpful error' do
+              expect {
+                template.render(merged_manifest_properties, consumes: links, spec: spec)
+              }.to raise_error("'sharding.workers' must be at least 1")
+            end
+          end
+
+          context 'when chaos is enabled' do
+            before do
+              merged_manifest_properties['chaos'] = {'enabled' => true, 'non_production_acknowledged' => true}
+            end
+
+            it 'renders the faults file' do
+              renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links, spec: spec))
+              expect(renderedConfig['chaos']).to eq({
+                'faults_file' => '/var/vcap/data/vxlan-policy-agent/chaos-faults.json',
+                'vtep_port' => 4789,
+              })
+            end
+
+            context 'without the non-production acknowledgement' do
+              before do
+                merged_manifest_properties['chaos'] = {'enabled' => true}
+              end
+
+              it 'throws a helpful error' do
+                expect {
+                  template.render(merged_manifest_properties, consumes: links, spec: spec)
+                }.to raise_error("'chaos.enabled' requires 'chaos.non_production_acknowledged' to be true. Chaos mode is not meant for production environments.")
+              end
+            end
+          end
+
           context 'when loggregator.use_v2_api is true' do
             let(:ca_cert_template) {job.template('config/certs/loggregator/ca.crt')}
             let(:client_cert_template) {job.template('config/certs/loggregator/client.crt')}
@@ -159,6 +340,27 @@ module Bosh::Template::Test
             end
           end
         end
+
+        describe 'config/sni-verdict.json' do
+          let(:template) {job.template('config/sni-verdict.json')}
+
+          it 'renders the allowlists' do
+            merged_manifest_properties['sni_inspection'] = {
+              'fail_open' => true,
+              'allowlists' => [{'queue_num' => 100, 'space_guids' => ['some-space-guid'], 'server_names' => ['api.example.com']}],
+            }
+            renderedConfig = JSON.parse(template.render(merged_manifest_properties, consumes: links, spec: spec))
+            expect(renderedConfig).to eq({
+              'log_level' => 'error',
+              'log_prefix' => 'cfnetworking',
+              'flow_timeout_seconds' => 300,
+              'sni_inspection' => {
+                'fail_open' => true,
+                'allowlists' => [{'queue_num' => 100, 'space_guids' => ['some-space-guid'], 'server_names' => ['api.example.com']}],
+              },
+            })
+          end
+        end
       end
     end
   end
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/policy_client"
+)
+
+// ValidateASG answers whether the cells can enforce the rules of an ASG in
+// the request body, a JSON array of rules in the format of the cloud
+// controller. It responds 200 for valid rules and 422 with the errors for
+// invalid ones, with the number of iptables rules they convert to in both
+// cases.
+type ValidateASG struct {
+	Logger           lager.Logger
+	MaxIPTablesRules int
+}
+
+const maxRequestBytes = 10 * 1024 * 1024
+
+func (h *ValidateASG) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.Session("validate-asg")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+	if err != nil {
+		logger.Error("read-body", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var sgRules []policy_client.SecurityGroupRule
+	if err := json.Unmarshal(body, &sgRules); err != nil {
+		logger.Info("invalid-body", lager.Data{"error": err.Error()})
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid rules: " + err.Error()))
+		return
+	}
+
+	validation := netrules.ValidateSecurityGroupRules(sgRules, h.MaxIPTablesRules)
+	response, err := json.Marshal(validation)
+	if err != nil {
+		logger.Error("marshal-response", err) // not tested, the validation always marshals
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !validation.Valid() {
+		logger.Info("rejected", lager.Data{"errors": validation.Errors})
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	w.Write(response)
+}
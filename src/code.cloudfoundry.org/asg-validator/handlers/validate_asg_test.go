@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/asg-validator/handlers"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateASG", func() {
+	var (
+		handler  *handlers.ValidateASG
+		response *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		handler = &handlers.ValidateASG{
+			Logger:           lagertest.NewTestLogger("test"),
+			MaxIPTablesRules: 10,
+		}
+		response = httptest.NewRecorder()
+	})
+
+	validate := func(body string) {
+		handler.ServeHTTP(response, httptest.NewRequest("POST", "/validate-asg", strings.NewReader(body)))
+	}
+
+	It("accepts rules the cells can enforce", func() {
+		validate(`[{"protocol": "tcp", "destination": "10.0.0.0/8", "ports": "443"}, {"protocol": "icmp", "destination": "10.0.0.1", "type": -1, "code": -1}]`)
+
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(response.Body.String()).To(MatchJSON(`{"iptables_rules": 2}`))
+	})
+
+	It("rejects rules the cells cannot enforce", func() {
+		validate(`[{"protocol": "tcp", "destination": "10.0.0.0/8", "ports": "443"}, {"protocol": "udp", "destination": "10.0.0.1", "ports": "dns"}]`)
+
+		Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response.Body.String()).To(MatchJSON(`{
+			"iptables_rules": 1,
+			"errors": ["rule 1: invalid ports \"dns\": ports must be between 1 and 65535"]
+		}`))
+	})
+
+	It("rejects rules beyond the limit of iptables rules", func() {
+		validate(`[{"protocol": "tcp", "destination": "10.0.0.1", "ports": "1,2,3,4,5,6,7,8,9,10,11"}]`)
+
+		Expect(response.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(response.Body.String()).To(ContainSubstring("the rules convert to 11 iptables rules, more than the limit of 10"))
+	})
+
+	Context("when the body is not a list of rules", func() {
+		It("responds with bad request", func() {
+			validate(`{"protocol": "tcp"}`)
+
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(HavePrefix("invalid rules: "))
+		})
+	})
+
+	Context("when the method is not POST", func() {
+		It("responds with method not allowed", func() {
+			handler.ServeHTTP(response, httptest.NewRequest("GET", "/validate-asg", nil))
+
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
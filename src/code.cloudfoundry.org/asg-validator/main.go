@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"code.cloudfoundry.org/asg-validator/handlers"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerflags"
+	"code.cloudfoundry.org/lib/common"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/http_server"
+	"github.com/tedsuo/ifrit/sigmon"
+)
+
+func main() {
+	listenAddress := flag.String("listen-address", "127.0.0.1:8730", "address to serve the validation on")
+	maxIPTablesRules := flag.Int("max-iptables-rules", 0, "most iptables rules the ASGs of a container may convert to, 0 for no limit")
+	flag.Parse()
+
+	logger, _ := lagerflags.NewFromConfig("cfnetworking.asg-validator", common.GetLagerConfig())
+
+	mux := http.NewServeMux()
+	mux.Handle("/validate-asg", &handlers.ValidateASG{
+		Logger:           logger,
+		MaxIPTablesRules: *maxIPTablesRules,
+	})
+
+	monitor := ifrit.Invoke(sigmon.New(http_server.New(*listenAddress, mux)))
+	logger.Info("running", lager.Data{"address": *listenAddress})
+	if err := <-monitor.Wait(); err != nil {
+		log.Fatalf("asg-validator: %s", err)
+	}
+	os.Exit(0)
+}
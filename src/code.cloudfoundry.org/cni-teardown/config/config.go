@@ -8,6 +8,9 @@ import (
 
 type Config struct {
 	PathsToDelete []string `json:"paths_to_delete" `
+	// FlatUnderlayInterface is the underlay interface of flat mode, if it is
+	// on.
+	FlatUnderlayInterface string `json:"flat_underlay_interface"`
 }
 
 func LoadConfig(pathToConfig string) (*Config, error) {
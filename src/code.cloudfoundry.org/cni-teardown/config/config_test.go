@@ -32,7 +32,8 @@ var _ = Describe("Config", func() {
 			"paths_to_delete": [
 				%q,
 				%q
-			]
+			],
+			"flat_underlay_interface": "eth1"
 		}`, datastorePath, dataDirPath)), os.ModePerm)
 	})
 
@@ -44,6 +45,7 @@ var _ = Describe("Config", func() {
 				datastorePath,
 				dataDirPath,
 			},
+			FlatUnderlayInterface: "eth1",
 		}))
 	})
 
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 
 	"code.cloudfoundry.org/cni-teardown/config"
+	"code.cloudfoundry.org/cni-teardown/teardown"
 
 	"strings"
 	"time"
@@ -206,6 +207,94 @@ var _ = Describe("Teardown", func() {
 		Expect(session.Out.Contents()).To(ContainSubstring("cni-teardown.complete"))
 	})
 
+	Context("when it is a dry run", func() {
+		It("lists the directories without removing them", func() {
+			session := runTeardown(configFilePath, "--dry-run")
+			Expect(session).To(gexec.Exit(0))
+
+			Expect(string(session.Out.Contents())).To(ContainSubstring("cni-teardown.would-remove"))
+			Expect(string(session.Out.Contents())).To(ContainSubstring(datastorePath))
+			Expect(fileExists(datastorePath)).To(BeTrue())
+			Expect(fileExists(delegateDataDirPath)).To(BeTrue())
+			Expect(fileExists(delegateDatastorePath)).To(BeTrue())
+		})
+	})
+
+	Context("when the output is json", func() {
+		It("writes a report of what it removed to stdout", func() {
+			session := runTeardown(configFilePath, "--output", "json")
+			Expect(session).To(gexec.Exit(0))
+
+			var report teardown.Report
+			Expect(json.Unmarshal(session.Out.Contents(), &report)).To(Succeed())
+			Expect(report.DryRun).To(BeFalse())
+			Expect(report.Artifacts).To(ContainElement(teardown.Artifact{
+				Kind:    "path",
+				Name:    datastorePath,
+				Reason:  "state of the plugins, listed in paths_to_delete",
+				Removed: true,
+			}))
+			Expect(string(session.Err.Contents())).To(ContainSubstring("cni-teardown.complete"))
+		})
+	})
+
+	Context("when flat mode left proxy neighbors on the underlay interface", func() {
+		var underlayName string
+
+		BeforeEach(func() {
+			underlayName = fmt.Sprintf("flat-%d", GinkgoParallelProcess())
+			mustSucceed("ip", "link", "add", underlayName, "type", "dummy")
+			mustSucceed("ip", "neigh", "add", "proxy", "10.0.16.10", "dev", underlayName)
+			mustSucceed("ip", "-6", "neigh", "add", "proxy", "fd00::10", "dev", underlayName)
+			mustSucceed("sysctl", "-w", fmt.Sprintf("net.ipv6.conf.%s.proxy_ndp=1", underlayName))
+
+			teardownConfig.FlatUnderlayInterface = underlayName
+			configFilePath = writeConfigFile(*teardownConfig)
+		})
+
+		AfterEach(func() {
+			exec.Command("ip", "link", "del", underlayName).Run()
+		})
+
+		It("removes them and turns off proxy NDP", func() {
+			session := runTeardown(configFilePath)
+			Expect(session).To(gexec.Exit(0))
+
+			Expect(mustSucceed("ip", "neigh", "show", "proxy", "dev", underlayName)).To(BeEmpty())
+			Expect(mustSucceed("ip", "-6", "neigh", "show", "proxy", "dev", underlayName)).To(BeEmpty())
+			proxyNDP, err := os.ReadFile(fmt.Sprintf("/proc/sys/net/ipv6/conf/%s/proxy_ndp", underlayName))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(string(proxyNDP))).To(Equal("0"))
+		})
+
+		Context("when it is a dry run with json output", func() {
+			It("reports them without removing them", func() {
+				session := runTeardown(configFilePath, "--dry-run", "--output", "json")
+				Expect(session).To(gexec.Exit(0))
+
+				var report teardown.Report
+				Expect(json.Unmarshal(session.Out.Contents(), &report)).To(Succeed())
+				Expect(report.DryRun).To(BeTrue())
+				Expect(report.Artifacts).To(ContainElements(
+					teardown.Artifact{Kind: "proxy-neighbor", Name: "10.0.16.10 dev " + underlayName, Reason: "proxy neighbor of a flat mode container"},
+					teardown.Artifact{Kind: "proxy-neighbor", Name: "fd00::10 dev " + underlayName, Reason: "proxy neighbor of a flat mode container"},
+					teardown.Artifact{Kind: "sysctl", Name: fmt.Sprintf("net.ipv6.conf.%s.proxy_ndp", underlayName), Reason: "proxy NDP of flat mode on the underlay interface"},
+				))
+
+				Expect(mustSucceed("ip", "neigh", "show", "proxy", "dev", underlayName)).To(ContainSubstring("10.0.16.10"))
+			})
+		})
+	})
+
+	Context("when the output is invalid", func() {
+		It("exits without removing anything", func() {
+			session := runTeardown(configFilePath, "--output", "yaml")
+			Expect(session).To(gexec.Exit(1))
+			Expect(string(session.Err.Contents())).To(ContainSubstring(`invalid output "yaml"`))
+			Expect(fileExists(datastorePath)).To(BeTrue())
+		})
+	})
+
 	Context("when the config file exists but cannot be read", func() {
 		BeforeEach(func() {
 			err := ioutil.WriteFile(configFilePath, []byte("some-bad-data"), os.ModePerm)
@@ -306,8 +395,8 @@ func mustSucceed(binary string, args ...string) string {
 	return string(sess.Out.Contents())
 }
 
-func runTeardown(configFilePath string) *gexec.Session {
-	startCmd := exec.Command(paths.TeardownBin, "--config", configFilePath)
+func runTeardown(configFilePath string, args ...string) *gexec.Session {
+	startCmd := exec.Command(paths.TeardownBin, append([]string{"--config", configFilePath}, args...)...)
 	session, err := gexec.Start(startCmd, GinkgoWriter, GinkgoWriter)
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(session, DEFAULT_TIMEOUT).Should(gexec.Exit())
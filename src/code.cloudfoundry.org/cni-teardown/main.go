@@ -1,16 +1,26 @@
+// cni-teardown runs in the pre-start of the silk-cni job, when the cell has no
+// containers left. It removes the ifb devices of the bandwidth limits, the
+// IPv4 and IPv6 proxy neighbors and the proxy NDP of flat mode on the underlay
+// interface, and the state directories of the plugins. The iptables chains,
+// routes and neighbor entries of the containers are not swept here: the netns
+// and veth of a container take its routes and addresses with them, and the
+// chains are removed by the DEL of the wrapper plugin and by the orphan cleanup
+// of the vxlan policy agent. No ip6tables chains are written.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"strings"
 
 	"code.cloudfoundry.org/cni-teardown/config"
+	"code.cloudfoundry.org/cni-teardown/teardown"
 	"code.cloudfoundry.org/lib/common"
 
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
+	cniadapter "code.cloudfoundry.org/silk/cni/adapter"
 	"code.cloudfoundry.org/silk/lib/adapter"
 )
 
@@ -20,27 +30,33 @@ const (
 )
 
 func main() {
-	logger, _ := lagerflags.NewFromConfig(fmt.Sprintf("%s.%s", logPrefix, jobPrefix), common.GetLagerConfig())
+	configFilePath := flag.String("config", "", "path to config file")
+	dryRun := flag.Bool("dry-run", false, "list what would be removed without removing it")
+	output := flag.String("output", "text", "output format: text, or json for a report of what is removed on stdout")
+	flag.Parse()
 
-	logger.Info("starting")
-	netlinkAdapter := &adapter.NetlinkAdapter{}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "invalid output %q: must be text or json\n", *output)
+		os.Exit(1)
+	}
 
-	links, err := netlinkAdapter.LinkList()
-	if err != nil {
-		logger.Error("failed-to-list-network-devices", err) // not tested
+	component := fmt.Sprintf("%s.%s", logPrefix, jobPrefix)
+	logger, _ := lagerflags.NewFromConfig(component, common.GetLagerConfig())
+	if *output == "json" {
+		// the report is written to stdout
+		logger = lager.NewLogger(component)
+		logger.RegisterSink(lager.NewPrettySink(os.Stderr, lager.INFO))
 	}
 
-	for _, link := range links {
-		if link.Type() == "ifb" && strings.HasPrefix(link.Attrs().Name, "i") {
-			err = netlinkAdapter.LinkDel(link)
-			if err != nil {
-				logger.Error("failed-to-remove-ifb", err)
-			}
-		}
+	logger.Info("starting", lager.Data{"dry-run": *dryRun})
+	td := &teardown.Teardown{
+		NetlinkAdapter: &adapter.NetlinkAdapter{},
+		SysctlAdapter:  &cniadapter.SysctlAdapter{},
+		Logger:         logger,
+		DryRun:         *dryRun,
 	}
 
-	configFilePath := flag.String("config", "", "path to config file")
-	flag.Parse()
+	report := td.Remove(td.Devices())
 
 	cfg, err := config.LoadConfig(*configFilePath)
 	if err != nil {
@@ -48,10 +64,14 @@ func main() {
 		os.Exit(1)
 	}
 
-	for _, path := range cfg.PathsToDelete {
-		err := os.RemoveAll(path)
+	report.Artifacts = append(report.Artifacts, td.Remove(td.FlatMode(cfg.FlatUnderlayInterface)).Artifacts...)
+	report.Artifacts = append(report.Artifacts, td.Remove(td.Paths(cfg.PathsToDelete)).Artifacts...)
+
+	if *output == "json" {
+		err = json.NewEncoder(os.Stdout).Encode(report)
 		if err != nil {
-			logger.Info("failed-to-remove-path", lager.Data{"path": path, "err": err})
+			logger.Error("write-report", err) // not tested
+			os.Exit(1)
 		}
 	}
 
@@ -0,0 +1,205 @@
+package teardown
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	KindDevice        = "device"
+	KindPath          = "path"
+	KindProxyNeighbor = "proxy-neighbor"
+	KindSysctl        = "sysctl"
+)
+
+//go:generate counterfeiter -o fakes/netlinkAdapter.go --fake-name NetlinkAdapter . netlinkAdapter
+type netlinkAdapter interface {
+	LinkList() ([]netlink.Link, error)
+	LinkDel(netlink.Link) error
+	LinkByName(string) (netlink.Link, error)
+	NeighProxyList(linkIndex, family int) ([]netlink.Neigh, error)
+	NeighDel(*netlink.Neigh) error
+}
+
+//go:generate counterfeiter -o fakes/sysctlAdapter.go --fake-name SysctlAdapter . sysctlAdapter
+type sysctlAdapter interface {
+	Sysctl(name string, params ...string) (string, error)
+}
+
+// Artifact is something the teardown removes, with the reason it is removed.
+// Contents lists what a directory holds, e.g. the datastores of the plugins.
+type Artifact struct {
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	Reason   string   `json:"reason"`
+	Contents []string `json:"contents,omitempty"`
+	Removed  bool     `json:"removed"`
+	Error    string   `json:"error,omitempty"`
+
+	link  netlink.Link
+	neigh *netlink.Neigh
+}
+
+// Report lists the artifacts of a teardown. In a dry run, none is removed.
+type Report struct {
+	DryRun    bool       `json:"dry_run"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+type Teardown struct {
+	NetlinkAdapter netlinkAdapter
+	SysctlAdapter  sysctlAdapter
+	Logger         lager.Logger
+	DryRun         bool
+}
+
+// Devices finds the ifb devices that the bandwidth limits of the containers
+// left behind.
+func (t *Teardown) Devices() []Artifact {
+	links, err := t.NetlinkAdapter.LinkList()
+	if err != nil {
+		t.Logger.Error("failed-to-list-network-devices", err)
+	}
+
+	var artifacts []Artifact
+	for _, link := range links {
+		if link.Type() == "ifb" && strings.HasPrefix(link.Attrs().Name, "i") {
+			artifacts = append(artifacts, Artifact{
+				Kind:   KindDevice,
+				Name:   link.Attrs().Name,
+				Reason: "ifb device of a container bandwidth limit",
+				link:   link,
+			})
+		}
+	}
+	return artifacts
+}
+
+// FlatMode finds the IPv4 and IPv6 proxy neighbors with which flat mode
+// answers ARP and NDP for the containers on the underlay interface, and the
+// proxy NDP it turns on for them. They are left behind by the containers whose
+// DEL never ran.
+func (t *Teardown) FlatMode(underlayInterface string) []Artifact {
+	if underlayInterface == "" {
+		return nil
+	}
+
+	link, err := t.NetlinkAdapter.LinkByName(underlayInterface)
+	if err != nil {
+		t.Logger.Error("failed-to-find-underlay-interface", err, lager.Data{"interface": underlayInterface})
+		return nil
+	}
+
+	var artifacts []Artifact
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		neighs, err := t.NetlinkAdapter.NeighProxyList(link.Attrs().Index, family)
+		if err != nil {
+			t.Logger.Error("failed-to-list-proxy-neighbors", err, lager.Data{"interface": underlayInterface, "family": family})
+			continue
+		}
+		for _, neigh := range neighs {
+			artifacts = append(artifacts, Artifact{
+				Kind:   KindProxyNeighbor,
+				Name:   fmt.Sprintf("%s dev %s", neigh.IP, underlayInterface),
+				Reason: "proxy neighbor of a flat mode container",
+				neigh: &netlink.Neigh{
+					LinkIndex: link.Attrs().Index,
+					Family:    family,
+					Flags:     netlink.NTF_PROXY,
+					IP:        neigh.IP,
+				},
+			})
+		}
+	}
+
+	proxyNDP := fmt.Sprintf("net.ipv6.conf.%s.proxy_ndp", underlayInterface)
+	value, err := t.SysctlAdapter.Sysctl(proxyNDP)
+	if err != nil {
+		t.Logger.Info("failed-to-read-sysctl", lager.Data{"name": proxyNDP, "err": err})
+		return artifacts
+	}
+	if strings.TrimSpace(value) == "1" {
+		artifacts = append(artifacts, Artifact{
+			Kind:   KindSysctl,
+			Name:   proxyNDP,
+			Reason: "proxy NDP of flat mode on the underlay interface",
+		})
+	}
+	return artifacts
+}
+
+// Paths finds the paths to delete that exist.
+func (t *Teardown) Paths(paths []string) []Artifact {
+	var artifacts []Artifact
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		artifact := Artifact{
+			Kind:   KindPath,
+			Name:   path,
+			Reason: "state of the plugins, listed in paths_to_delete",
+		}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err == nil {
+				for _, entry := range entries {
+					artifact.Contents = append(artifact.Contents, entry.Name())
+				}
+				sort.Strings(artifact.Contents)
+			}
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts
+}
+
+// Remove removes the artifacts, or only logs them in a dry run. Removing goes
+// on past an artifact that cannot be removed.
+func (t *Teardown) Remove(artifacts []Artifact) Report {
+	report := Report{DryRun: t.DryRun, Artifacts: []Artifact{}}
+	for _, artifact := range artifacts {
+		if t.DryRun {
+			t.Logger.Info("would-remove", lager.Data{"kind": artifact.Kind, "name": artifact.Name, "reason": artifact.Reason})
+			report.Artifacts = append(report.Artifacts, artifact)
+			continue
+		}
+
+		var err error
+		switch artifact.Kind {
+		case KindDevice:
+			err = t.NetlinkAdapter.LinkDel(artifact.link)
+			if err != nil {
+				t.Logger.Error("failed-to-remove-ifb", err)
+			}
+		case KindPath:
+			err = os.RemoveAll(artifact.Name)
+			if err != nil {
+				t.Logger.Info("failed-to-remove-path", lager.Data{"path": artifact.Name, "err": err})
+			}
+		case KindProxyNeighbor:
+			err = t.NetlinkAdapter.NeighDel(artifact.neigh)
+			if err != nil {
+				t.Logger.Error("failed-to-remove-proxy-neighbor", err, lager.Data{"neighbor": artifact.Name})
+			}
+		case KindSysctl:
+			_, err = t.SysctlAdapter.Sysctl(artifact.Name, "0")
+			if err != nil {
+				t.Logger.Error("failed-to-reset-sysctl", err, lager.Data{"name": artifact.Name})
+			}
+		}
+		if err != nil {
+			artifact.Error = err.Error()
+		} else {
+			artifact.Removed = true
+		}
+		report.Artifacts = append(report.Artifacts, artifact)
+	}
+	return report
+}
@@ -0,0 +1,13 @@
+package teardown_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTeardown(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Teardown Suite")
+}
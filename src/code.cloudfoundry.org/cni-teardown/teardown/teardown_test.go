@@ -0,0 +1,311 @@
+package teardown_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/cni-teardown/teardown"
+	"code.cloudfoundry.org/cni-teardown/teardown/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Teardown", func() {
+	var (
+		fakeNetlinkAdapter *fakes.NetlinkAdapter
+		fakeSysctlAdapter  *fakes.SysctlAdapter
+		logger             *lagertest.TestLogger
+		td                 *teardown.Teardown
+		ifb                netlink.Link
+		dataDir            string
+	)
+
+	BeforeEach(func() {
+		fakeNetlinkAdapter = &fakes.NetlinkAdapter{}
+		fakeSysctlAdapter = &fakes.SysctlAdapter{}
+		logger = lagertest.NewTestLogger("test")
+		td = &teardown.Teardown{
+			NetlinkAdapter: fakeNetlinkAdapter,
+			SysctlAdapter:  fakeSysctlAdapter,
+			Logger:         logger,
+		}
+
+		ifb = &netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: "i-some-ifb"}}
+		fakeNetlinkAdapter.LinkListReturns([]netlink.Link{
+			ifb,
+			&netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: "other-ifb"}},
+			&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: "i-some-dummy"}},
+		}, nil)
+
+		var err error
+		dataDir, err = os.MkdirTemp("", "teardown-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dataDir, "store.json"), []byte("{}"), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dataDir)).To(Succeed())
+	})
+
+	Describe("Devices", func() {
+		It("finds only the ifb devices of the containers", func() {
+			devices := td.Devices()
+			Expect(devices).To(HaveLen(1))
+			Expect(devices[0].Kind).To(Equal("device"))
+			Expect(devices[0].Name).To(Equal("i-some-ifb"))
+			Expect(devices[0].Reason).To(Equal("ifb device of a container bandwidth limit"))
+		})
+
+		Context("when listing the devices fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkListReturns(nil, errors.New("banana"))
+			})
+
+			It("logs the error and finds none", func() {
+				Expect(td.Devices()).To(BeEmpty())
+				Expect(logger).To(gbytes.Say("failed-to-list-network-devices"))
+			})
+		})
+	})
+
+	Describe("FlatMode", func() {
+		BeforeEach(func() {
+			fakeNetlinkAdapter.LinkByNameReturns(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth1", Index: 3}}, nil)
+			fakeNetlinkAdapter.NeighProxyListStub = func(linkIndex, family int) ([]netlink.Neigh, error) {
+				if family == netlink.FAMILY_V4 {
+					return []netlink.Neigh{{LinkIndex: linkIndex, IP: net.ParseIP("10.0.16.10")}}, nil
+				}
+				return []netlink.Neigh{{LinkIndex: linkIndex, IP: net.ParseIP("fd00::10")}}, nil
+			}
+			fakeSysctlAdapter.SysctlReturns("1", nil)
+		})
+
+		It("finds the proxy neighbors and the proxy NDP on the underlay interface", func() {
+			artifacts := td.FlatMode("eth1")
+
+			Expect(fakeNetlinkAdapter.LinkByNameArgsForCall(0)).To(Equal("eth1"))
+			Expect(fakeNetlinkAdapter.NeighProxyListCallCount()).To(Equal(2))
+			linkIndex, family := fakeNetlinkAdapter.NeighProxyListArgsForCall(0)
+			Expect(linkIndex).To(Equal(3))
+			Expect(family).To(Equal(netlink.FAMILY_V4))
+			_, family = fakeNetlinkAdapter.NeighProxyListArgsForCall(1)
+			Expect(family).To(Equal(netlink.FAMILY_V6))
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.ipv6.conf.eth1.proxy_ndp"))
+			Expect(params).To(BeEmpty())
+
+			Expect(artifacts).To(HaveLen(3))
+			Expect(artifacts[0].Kind).To(Equal("proxy-neighbor"))
+			Expect(artifacts[0].Name).To(Equal("10.0.16.10 dev eth1"))
+			Expect(artifacts[0].Reason).To(Equal("proxy neighbor of a flat mode container"))
+			Expect(artifacts[1].Kind).To(Equal("proxy-neighbor"))
+			Expect(artifacts[1].Name).To(Equal("fd00::10 dev eth1"))
+			Expect(artifacts[2].Kind).To(Equal("sysctl"))
+			Expect(artifacts[2].Name).To(Equal("net.ipv6.conf.eth1.proxy_ndp"))
+			Expect(artifacts[2].Reason).To(Equal("proxy NDP of flat mode on the underlay interface"))
+		})
+
+		Context("when flat mode is off", func() {
+			It("finds nothing", func() {
+				Expect(td.FlatMode("")).To(BeEmpty())
+				Expect(fakeNetlinkAdapter.LinkByNameCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when proxy NDP is off", func() {
+			BeforeEach(func() {
+				fakeSysctlAdapter.SysctlReturns("0\n", nil)
+			})
+
+			It("finds only the proxy neighbors", func() {
+				artifacts := td.FlatMode("eth1")
+				Expect(artifacts).To(HaveLen(2))
+				Expect(artifacts[1].Kind).To(Equal("proxy-neighbor"))
+			})
+		})
+
+		Context("when the underlay interface does not exist", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("banana"))
+			})
+
+			It("logs the error and finds nothing", func() {
+				Expect(td.FlatMode("eth1")).To(BeEmpty())
+				Expect(logger).To(gbytes.Say("failed-to-find-underlay-interface"))
+			})
+		})
+
+		Context("when listing the proxy neighbors of a family fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.NeighProxyListReturnsOnCall(0, nil, errors.New("banana"))
+				fakeNetlinkAdapter.NeighProxyListReturnsOnCall(1, []netlink.Neigh{{IP: net.ParseIP("fd00::10")}}, nil)
+				fakeNetlinkAdapter.NeighProxyListStub = nil
+			})
+
+			It("logs the error and finds the others", func() {
+				artifacts := td.FlatMode("eth1")
+				Expect(artifacts).To(HaveLen(2))
+				Expect(artifacts[0].Name).To(Equal("fd00::10 dev eth1"))
+				Expect(logger).To(gbytes.Say("failed-to-list-proxy-neighbors"))
+			})
+		})
+
+		Context("when IPv6 is disabled", func() {
+			BeforeEach(func() {
+				fakeSysctlAdapter.SysctlReturns("", errors.New("no such file or directory"))
+			})
+
+			It("logs the error and finds the proxy neighbors", func() {
+				Expect(td.FlatMode("eth1")).To(HaveLen(2))
+				Expect(logger).To(gbytes.Say("failed-to-read-sysctl"))
+			})
+		})
+	})
+
+	Describe("Paths", func() {
+		It("finds the paths that exist with their contents", func() {
+			paths := td.Paths([]string{dataDir, filepath.Join(dataDir, "not-there")})
+			Expect(paths).To(Equal([]teardown.Artifact{{
+				Kind:     "path",
+				Name:     dataDir,
+				Reason:   "state of the plugins, listed in paths_to_delete",
+				Contents: []string{"store.json"},
+			}}))
+		})
+	})
+
+	Describe("Remove", func() {
+		It("removes the artifacts and reports them", func() {
+			report := td.Remove(append(td.Devices(), td.Paths([]string{dataDir})...))
+
+			Expect(fakeNetlinkAdapter.LinkDelCallCount()).To(Equal(1))
+			Expect(fakeNetlinkAdapter.LinkDelArgsForCall(0)).To(Equal(ifb))
+			_, err := os.Stat(dataDir)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			Expect(report.DryRun).To(BeFalse())
+			Expect(report.Artifacts).To(HaveLen(2))
+			Expect(report.Artifacts[0].Removed).To(BeTrue())
+			Expect(report.Artifacts[1].Removed).To(BeTrue())
+		})
+
+		Context("with the artifacts of flat mode", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth1", Index: 3}}, nil)
+				fakeNetlinkAdapter.NeighProxyListReturnsOnCall(0, []netlink.Neigh{{LinkIndex: 3, IP: net.ParseIP("10.0.16.10")}}, nil)
+				fakeNetlinkAdapter.NeighProxyListReturnsOnCall(1, []netlink.Neigh{{LinkIndex: 3, IP: net.ParseIP("fd00::10")}}, nil)
+				fakeSysctlAdapter.SysctlReturns("1", nil)
+			})
+
+			It("removes the proxy neighbors and turns off proxy NDP", func() {
+				report := td.Remove(td.FlatMode("eth1"))
+
+				Expect(fakeNetlinkAdapter.NeighDelCallCount()).To(Equal(2))
+				Expect(fakeNetlinkAdapter.NeighDelArgsForCall(0)).To(Equal(&netlink.Neigh{
+					LinkIndex: 3,
+					Family:    netlink.FAMILY_V4,
+					Flags:     netlink.NTF_PROXY,
+					IP:        net.ParseIP("10.0.16.10"),
+				}))
+				Expect(fakeNetlinkAdapter.NeighDelArgsForCall(1)).To(Equal(&netlink.Neigh{
+					LinkIndex: 3,
+					Family:    netlink.FAMILY_V6,
+					Flags:     netlink.NTF_PROXY,
+					IP:        net.ParseIP("fd00::10"),
+				}))
+				Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(2))
+				name, params := fakeSysctlAdapter.SysctlArgsForCall(1)
+				Expect(name).To(Equal("net.ipv6.conf.eth1.proxy_ndp"))
+				Expect(params).To(Equal([]string{"0"}))
+
+				Expect(report.Artifacts).To(HaveLen(3))
+				for _, artifact := range report.Artifacts {
+					Expect(artifact.Removed).To(BeTrue())
+				}
+			})
+
+			Context("when removing a proxy neighbor fails", func() {
+				BeforeEach(func() {
+					fakeNetlinkAdapter.NeighDelReturnsOnCall(0, errors.New("banana"))
+				})
+
+				It("logs and reports the error and removes the others", func() {
+					report := td.Remove(td.FlatMode("eth1"))
+
+					Expect(report.Artifacts[0].Error).To(Equal("banana"))
+					Expect(report.Artifacts[1].Removed).To(BeTrue())
+					Expect(report.Artifacts[2].Removed).To(BeTrue())
+					Expect(logger).To(gbytes.Say("failed-to-remove-proxy-neighbor"))
+				})
+			})
+
+			Context("when turning off proxy NDP fails", func() {
+				BeforeEach(func() {
+					fakeSysctlAdapter.SysctlReturnsOnCall(1, "", errors.New("banana"))
+				})
+
+				It("logs and reports the error", func() {
+					report := td.Remove(td.FlatMode("eth1"))
+
+					Expect(report.Artifacts[2].Error).To(Equal("banana"))
+					Expect(logger).To(gbytes.Say("failed-to-reset-sysctl"))
+				})
+			})
+
+			Context("in a dry run", func() {
+				BeforeEach(func() {
+					td.DryRun = true
+				})
+
+				It("only logs and reports them", func() {
+					report := td.Remove(td.FlatMode("eth1"))
+
+					Expect(fakeNetlinkAdapter.NeighDelCallCount()).To(Equal(0))
+					Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
+					Expect(report.Artifacts).To(HaveLen(3))
+					Expect(logger).To(gbytes.Say(`would-remove.*"kind":"proxy-neighbor".*"10.0.16.10 dev eth1"`))
+				})
+			})
+		})
+
+		Context("when removing an artifact fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkDelReturns(errors.New("banana"))
+			})
+
+			It("logs and reports the error and removes the others", func() {
+				report := td.Remove(append(td.Devices(), td.Paths([]string{dataDir})...))
+
+				Expect(report.Artifacts[0].Removed).To(BeFalse())
+				Expect(report.Artifacts[0].Error).To(Equal("banana"))
+				Expect(report.Artifacts[1].Removed).To(BeTrue())
+				Expect(logger).To(gbytes.Say("failed-to-remove-ifb"))
+			})
+		})
+
+		Context("in a dry run", func() {
+			BeforeEach(func() {
+				td.DryRun = true
+			})
+
+			It("only logs and reports the artifacts", func() {
+				report := td.Remove(append(td.Devices(), td.Paths([]string{dataDir})...))
+
+				Expect(fakeNetlinkAdapter.LinkDelCallCount()).To(Equal(0))
+				_, err := os.Stat(dataDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(report.DryRun).To(BeTrue())
+				Expect(report.Artifacts).To(HaveLen(2))
+				Expect(report.Artifacts[0].Removed).To(BeFalse())
+				Expect(report.Artifacts[1].Removed).To(BeFalse())
+				Expect(logger).To(gbytes.Say(`would-remove.*"i-some-ifb"`))
+			})
+		})
+	})
+})
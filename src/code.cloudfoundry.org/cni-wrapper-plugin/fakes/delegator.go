@@ -2,6 +2,7 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
@@ -9,11 +10,12 @@ import (
 )
 
 type Delegator struct {
-	DelegateAddStub        func(string, []byte) (types.Result, error)
+	DelegateAddStub        func(context.Context, string, []byte) (types.Result, error)
 	delegateAddMutex       sync.RWMutex
 	delegateAddArgsForCall []struct {
-		arg1 string
-		arg2 []byte
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
 	}
 	delegateAddReturns struct {
 		result1 types.Result
@@ -23,11 +25,12 @@ type Delegator struct {
 		result1 types.Result
 		result2 error
 	}
-	DelegateDelStub        func(string, []byte) error
+	DelegateDelStub        func(context.Context, string, []byte) error
 	delegateDelMutex       sync.RWMutex
 	delegateDelArgsForCall []struct {
-		arg1 string
-		arg2 []byte
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
 	}
 	delegateDelReturns struct {
 		result1 error
@@ -39,24 +42,25 @@ type Delegator struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *Delegator) DelegateAdd(arg1 string, arg2 []byte) (types.Result, error) {
-	var arg2Copy []byte
-	if arg2 != nil {
-		arg2Copy = make([]byte, len(arg2))
-		copy(arg2Copy, arg2)
+func (fake *Delegator) DelegateAdd(arg1 context.Context, arg2 string, arg3 []byte) (types.Result, error) {
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
 	}
 	fake.delegateAddMutex.Lock()
 	ret, specificReturn := fake.delegateAddReturnsOnCall[len(fake.delegateAddArgsForCall)]
 	fake.delegateAddArgsForCall = append(fake.delegateAddArgsForCall, struct {
-		arg1 string
-		arg2 []byte
-	}{arg1, arg2Copy})
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
+	}{arg1, arg2, arg3Copy})
 	stub := fake.DelegateAddStub
 	fakeReturns := fake.delegateAddReturns
-	fake.recordInvocation("DelegateAdd", []interface{}{arg1, arg2Copy})
+	fake.recordInvocation("DelegateAdd", []interface{}{arg1, arg2, arg3Copy})
 	fake.delegateAddMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -70,17 +74,17 @@ func (fake *Delegator) DelegateAddCallCount() int {
 	return len(fake.delegateAddArgsForCall)
 }
 
-func (fake *Delegator) DelegateAddCalls(stub func(string, []byte) (types.Result, error)) {
+func (fake *Delegator) DelegateAddCalls(stub func(context.Context, string, []byte) (types.Result, error)) {
 	fake.delegateAddMutex.Lock()
 	defer fake.delegateAddMutex.Unlock()
 	fake.DelegateAddStub = stub
 }
 
-func (fake *Delegator) DelegateAddArgsForCall(i int) (string, []byte) {
+func (fake *Delegator) DelegateAddArgsForCall(i int) (context.Context, string, []byte) {
 	fake.delegateAddMutex.RLock()
 	defer fake.delegateAddMutex.RUnlock()
 	argsForCall := fake.delegateAddArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *Delegator) DelegateAddReturns(result1 types.Result, result2 error) {
@@ -109,24 +113,25 @@ func (fake *Delegator) DelegateAddReturnsOnCall(i int, result1 types.Result, res
 	}{result1, result2}
 }
 
-func (fake *Delegator) DelegateDel(arg1 string, arg2 []byte) error {
-	var arg2Copy []byte
-	if arg2 != nil {
-		arg2Copy = make([]byte, len(arg2))
-		copy(arg2Copy, arg2)
+func (fake *Delegator) DelegateDel(arg1 context.Context, arg2 string, arg3 []byte) error {
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
 	}
 	fake.delegateDelMutex.Lock()
 	ret, specificReturn := fake.delegateDelReturnsOnCall[len(fake.delegateDelArgsForCall)]
 	fake.delegateDelArgsForCall = append(fake.delegateDelArgsForCall, struct {
-		arg1 string
-		arg2 []byte
-	}{arg1, arg2Copy})
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
+	}{arg1, arg2, arg3Copy})
 	stub := fake.DelegateDelStub
 	fakeReturns := fake.delegateDelReturns
-	fake.recordInvocation("DelegateDel", []interface{}{arg1, arg2Copy})
+	fake.recordInvocation("DelegateDel", []interface{}{arg1, arg2, arg3Copy})
 	fake.delegateDelMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
@@ -140,17 +145,17 @@ func (fake *Delegator) DelegateDelCallCount() int {
 	return len(fake.delegateDelArgsForCall)
 }
 
-func (fake *Delegator) DelegateDelCalls(stub func(string, []byte) error) {
+func (fake *Delegator) DelegateDelCalls(stub func(context.Context, string, []byte) error) {
 	fake.delegateDelMutex.Lock()
 	defer fake.delegateDelMutex.Unlock()
 	fake.DelegateDelStub = stub
 }
 
-func (fake *Delegator) DelegateDelArgsForCall(i int) (string, []byte) {
+func (fake *Delegator) DelegateDelArgsForCall(i int) (context.Context, string, []byte) {
 	fake.delegateDelMutex.RLock()
 	defer fake.delegateDelMutex.RUnlock()
 	argsForCall := fake.delegateDelArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *Delegator) DelegateDelReturns(result1 error) {
@@ -9,10 +9,13 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/containerevents"
 
 	"code.cloudfoundry.org/garden"
 
@@ -26,6 +29,7 @@ import (
 	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
 	"github.com/pivotal-cf-experimental/gomegamatchers"
+	"github.com/tedsuo/ifrit"
 	"github.com/vishvananda/netlink"
 )
 
@@ -181,7 +185,7 @@ var _ = Describe("CniWrapperPlugin", func() {
 				IPTablesAcceptedUDPLogsPerSec: 7,
 				PolicyAgentForcePollAddress:   policyAgentAddress,
 				RuntimeConfig: lib.RuntimeConfig{
-					PortMappings: []garden.NetIn{
+					PortMappings: []lib.PortMapping{
 						{
 							HostPort:      1000,
 							ContainerPort: 1001,
@@ -333,6 +337,71 @@ var _ = Describe("CniWrapperPlugin", func() {
 			Expect(string(stateFileBytes)).NotTo(ContainSubstring("1.2.3.4"))
 			Expect(string(stateFileBytes)).NotTo(ContainSubstring("value1"))
 		})
+
+		Context("when a container events directory is configured", func() {
+			var (
+				eventsDir  string
+				subscriber ifrit.Process
+				received   chan containerevents.Event
+			)
+
+			BeforeEach(func() {
+				var err error
+				eventsDir, err = ioutil.TempDir("", "container-events")
+				Expect(err).NotTo(HaveOccurred())
+				inputStruct.WrapperConfig.ContainerEventsDirectory = eventsDir
+				input = GetInput(inputStruct)
+				cmd = cniCommand("ADD", input)
+
+				received = make(chan containerevents.Event, 10)
+				subscriber = ifrit.Invoke(&containerevents.Subscriber{
+					Path:    filepath.Join(eventsDir, "subscriber"+containerevents.SocketSuffix),
+					Handler: func(event containerevents.Event) { received <- event },
+					Logger:  lagertest.NewTestLogger("subscriber"),
+				})
+			})
+
+			AfterEach(func() {
+				subscriber.Signal(os.Interrupt)
+				Eventually(subscriber.Wait()).Should(Receive())
+				os.RemoveAll(eventsDir)
+			})
+
+			It("publishes the addition and deletion of the container", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+				Eventually(received).Should(Receive(Equal(containerevents.Event{Type: containerevents.TypeAdd, Handle: containerID, IP: "1.2.3.4"})))
+
+				session, err = gexec.Start(cniCommand("DEL", input), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+				Eventually(received).Should(Receive(Equal(containerevents.Event{Type: containerevents.TypeDel, Handle: containerID, IP: "1.2.3.4"})))
+			})
+		})
+
+		Context("when the metadata is malformed", func() {
+			BeforeEach(func() {
+				inputStruct.Metadata["ports"] = "8080,http"
+				input = GetInput(inputStruct)
+
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("rejects the container before setting up its network", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+
+				var errData map[string]interface{}
+				Expect(json.Unmarshal(session.Out.Contents(), &errData)).To(Succeed())
+				Expect(errData["msg"]).To(ContainSubstring(`container metadata: metadata ports: invalid port "http"`))
+
+				debug, err := noop_debug.ReadDebug(debugFileName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debug.Command).To(BeEmpty(), "the delegate plugin was not called")
+			})
+		})
 	})
 
 	Describe("iptables lifecycle", func() {
@@ -499,6 +568,31 @@ var _ = Describe("CniWrapperPlugin", func() {
 			})
 		})
 
+		Context("when the container is in an egress proxy space", func() {
+			BeforeEach(func() {
+				inputStruct.Metadata["space_id"] = "some-proxy-space-guid"
+				inputStruct.WrapperConfig.EgressProxy = lib.EgressProxyConfig{
+					SpaceGUIDs: []string{"some-proxy-space-guid"},
+					Endpoints: []lib.EgressProxyEndpoint{
+						{Destination: "10.0.5.5", Protocol: "tcp", Ports: "3128"},
+					},
+				}
+				input = GetInput(inputStruct)
+				cmd = cniCommand("ADD", input)
+			})
+
+			It("only allows egress to the proxies when the container is created", func() {
+				policyAgentServer.ASGReturnCode = 405
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).ToNot(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				rules := AllIPTablesRules("filter")
+				Expect(rules).To(ContainElement(`-A ` + netoutChainName + ` -p tcp -m iprange --dst-range 10.0.5.5-10.0.5.5 -m tcp --dport 3128 -j ACCEPT`))
+				Expect(strings.Join(rules, "\n")).ToNot(ContainSubstring("11.11.11.11-22.22.22.22"))
+			})
+		})
+
 		Context("when the policy agent asg updater returns an error", func() {
 			It("returns an error", func() {
 				policyAgentServer.ASGReturnCode = 500
@@ -728,9 +822,85 @@ var _ = Describe("CniWrapperPlugin", func() {
 				})
 			})
 
+			Context("when a port mapping forwards a range of UDP ports", func() {
+				BeforeEach(func() {
+					inputStruct.WrapperConfig.RuntimeConfig.PortMappings = []lib.PortMapping{
+						{
+							HostPort:      3000,
+							HostPortEnd:   3010,
+							ContainerPort: 5000,
+							Protocol:      "udp",
+						},
+					}
+					input = GetInput(inputStruct)
+					cmd = cniCommand("ADD", input)
+				})
+
+				It("forwards each port of the range and marks its packets", func() {
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(0))
+
+					Expect(AllIPTablesRules("nat")).To(ContainElement("-A " + netinChainName + " -d 10.244.2.3/32 -p udp -m udp --dport 3000:3010 -j DNAT --to-destination 1.2.3.4:5000-5010/3000"))
+					Expect(AllIPTablesRules("mangle")).To(ContainElement("-A " + netinChainName + " -d 10.244.2.3/32 -i " + underlayName1 + " -p udp -m udp --dport 3000:3010 -j MARK --set-xmark 0xffff0000/0xffffffff"))
+				})
+			})
+
+			Context("when a port mapping preserves the client ip", func() {
+				BeforeEach(func() {
+					inputStruct.WrapperConfig.RuntimeConfig.PortMappings = []lib.PortMapping{
+						{
+							HostPort:         3000,
+							ContainerPort:    5000,
+							PreserveClientIP: true,
+						},
+					}
+					input = GetInput(inputStruct)
+					cmd = cniCommand("ADD", input)
+				})
+
+				It("marks the connections to the mapping and clears the mark of their replies", func() {
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(0))
+
+					Expect(AllIPTablesRules("mangle")).To(ContainElement("-A " + netinChainName + " -d 10.244.2.3/32 -p tcp -m tcp --dport 3000 -j CONNMARK --set-xmark 0x1/0x1"))
+					Expect(AllIPTablesRules("mangle")).To(ContainElement("-A " + netinChainName + " -s 1.2.3.4/32 -m connmark --mark 0x1/0x1 -j MARK --set-xmark 0x0/0xffffffff"))
+				})
+			})
+
+			Context("when two port mappings forward the same host port", func() {
+				BeforeEach(func() {
+					inputStruct.WrapperConfig.RuntimeConfig.PortMappings = []lib.PortMapping{
+						{
+							HostPort:      3000,
+							HostPortEnd:   3010,
+							ContainerPort: 5000,
+						},
+						{
+							HostPort:      3005,
+							ContainerPort: 8080,
+						},
+					}
+					input = GetInput(inputStruct)
+					cmd = cniCommand("ADD", input)
+				})
+
+				It("rejects the container before setting up its network", func() {
+					session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session).Should(gexec.Exit(1))
+					Expect(session.Out.Contents()).To(MatchJSON(`{ "code": 999, "msg": "port mappings tcp 3000-3010:5000 and tcp 3005:8080 conflict" }`))
+
+					debug, err := noop_debug.ReadDebug(debugFileName)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(debug.Command).To(BeEmpty(), "the delegate plugin was not called")
+				})
+			})
+
 			Context("when a port mapping with hostport 0 is given", func() {
 				BeforeEach(func() {
-					inputStruct.WrapperConfig.RuntimeConfig.PortMappings = []garden.NetIn{
+					inputStruct.WrapperConfig.RuntimeConfig.PortMappings = []lib.PortMapping{
 						{
 							HostPort:      0,
 							ContainerPort: 1001,
@@ -1166,6 +1336,35 @@ var _ = Describe("CniWrapperPlugin", func() {
 			Expect(policyAgentServer.CleanupOrphanedASGsEndpointContainerRequested).To(Equal(containerID))
 		})
 
+		Context("when the container was never added", func() {
+			It("treats the missing rules and metadata as removed", func() {
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				Expect(string(session.Err.Contents())).NotTo(ContainSubstring("removing IP masq"))
+				Expect(string(session.Err.Contents())).NotTo(ContainSubstring("cleanup"))
+			})
+		})
+
+		Context("when DEL is called again after the container was deleted", func() {
+			It("returns the success status code", func() {
+				addCmd := cniCommand("ADD", input)
+				session, err := gexec.Start(addCmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+
+				session, err = gexec.Start(cniCommand("DEL", input), GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(string(session.Err.Contents())).NotTo(ContainSubstring("cleanup"))
+			})
+		})
+
 		Context("when the policy agent asg cleanup returns a 405", func() {
 			It("ignores and moves on, since dynamic asgs have been disabled", func() {
 				policyAgentServer.CleanupOrphanedASGsReturnCode = 405
@@ -9,18 +9,19 @@ import (
 
 //go:generate counterfeiter -o ../fakes/delegator.go --fake-name Delegator . Delegator
 type Delegator interface {
-	DelegateAdd(delegatePlugin string, netconf []byte) (types.Result, error)
-	DelegateDel(delegatePlugin string, netconf []byte) error
+	DelegateAdd(ctx context.Context, delegatePlugin string, netconf []byte) (types.Result, error)
+	DelegateDel(ctx context.Context, delegatePlugin string, netconf []byte) error
 }
 
 type delegator struct{}
 
-func (*delegator) DelegateAdd(delegatePlugin string, netconf []byte) (types.Result, error) {
-	return invoke.DelegateAdd(context.Background(), delegatePlugin, netconf, nil)
+// The delegate plugin is killed when the context is cancelled.
+func (*delegator) DelegateAdd(ctx context.Context, delegatePlugin string, netconf []byte) (types.Result, error) {
+	return invoke.DelegateAdd(ctx, delegatePlugin, netconf, nil)
 }
 
-func (*delegator) DelegateDel(delegatePlugin string, netconf []byte) error {
-	return invoke.DelegateDel(context.Background(), delegatePlugin, netconf, nil)
+func (*delegator) DelegateDel(ctx context.Context, delegatePlugin string, netconf []byte) error {
+	return invoke.DelegateDel(ctx, delegatePlugin, netconf, nil)
 }
 
 func NewDelegator() Delegator { return &delegator{} }
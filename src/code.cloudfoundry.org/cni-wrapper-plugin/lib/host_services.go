@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// HostServiceRegistration is the file a host service, e.g. a DNS forwarder
+// or a metrics agent, writes to the host services registry to be reachable
+// from the containers of the cell:
+//
+//	{"tcp": ["169.254.0.2:9001"], "udp": ["169.254.0.2:53"]}
+type HostServiceRegistration struct {
+	TCP []string `json:"tcp"`
+	UDP []string `json:"udp"`
+}
+
+// HostServices are the TCP and UDP addresses on the cell that containers can
+// connect to.
+type HostServices struct {
+	TCP []string
+	UDP []string
+}
+
+// ReadHostServiceRegistry adds the addresses that the services registered in
+// the *.json files of dir to the configured ones. A registration that cannot
+// be read or has an invalid address is skipped with a warning, so that one
+// broken service does not fail every container of the cell. A registry that
+// does not exist has no services.
+func ReadHostServiceRegistry(dir string, configured HostServices, warnings io.Writer) (HostServices, error) {
+	services := HostServices{
+		TCP: append([]string{}, configured.TCP...),
+		UDP: append([]string{}, configured.UDP...),
+	}
+	if dir == "" {
+		return services, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return services, nil
+	}
+	if err != nil {
+		return services, fmt.Errorf("host services registry: %s", err)
+	}
+
+	paths := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	for _, path := range paths {
+		registration, err := readHostServiceRegistration(path)
+		if err != nil {
+			fmt.Fprintf(warnings, "skipping host service %s: %s\n", path, err)
+			continue
+		}
+		services.TCP = appendNew(services.TCP, registration.TCP)
+		services.UDP = appendNew(services.UDP, registration.UDP)
+	}
+	return services, nil
+}
+
+func readHostServiceRegistration(path string) (HostServiceRegistration, error) {
+	var registration HostServiceRegistration
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return registration, err
+	}
+	if err := json.Unmarshal(contents, &registration); err != nil {
+		return registration, err
+	}
+	for _, address := range append(append([]string{}, registration.TCP...), registration.UDP...) {
+		if err := validateHostServiceAddress(address); err != nil {
+			return registration, err
+		}
+	}
+	return registration, nil
+}
+
+func validateHostServiceAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid address %q: host must be an IPv4 address", address)
+	}
+	if ip.IsLoopback() {
+		return fmt.Errorf("invalid address %q: host must not be in 127.0.0.0/8", address)
+	}
+	portInt, err := strconv.Atoi(port)
+	if err != nil || portInt < 1 || portInt > maxPort {
+		return fmt.Errorf("invalid address %q: port must be between 1 and %d", address, maxPort)
+	}
+	return nil
+}
+
+func appendNew(addresses, more []string) []string {
+	for _, address := range more {
+		known := false
+		for _, existing := range addresses {
+			if existing == address {
+				known = true
+				break
+			}
+		}
+		if !known {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
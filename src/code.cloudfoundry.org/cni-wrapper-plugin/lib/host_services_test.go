@@ -0,0 +1,105 @@
+package lib_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ReadHostServiceRegistry", func() {
+	var (
+		dir        string
+		configured lib.HostServices
+		warnings   *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "host-services")
+		Expect(err).NotTo(HaveOccurred())
+		configured = lib.HostServices{
+			TCP: []string{"169.254.0.2:9001"},
+			UDP: []string{"169.254.0.2:8125"},
+		}
+		warnings = gbytes.NewBuffer()
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	register := func(name, contents string) {
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)).To(Succeed())
+	}
+
+	It("adds the registered addresses to the configured ones", func() {
+		register("dns.json", `{"tcp": ["169.254.0.2:53"], "udp": ["169.254.0.2:53"]}`)
+		register("metrics.json", `{"udp": ["169.254.0.2:8125", "169.254.0.3:8125"]}`)
+		register("README", `not a registration`)
+
+		services, err := lib.ReadHostServiceRegistry(dir, configured, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(services).To(Equal(lib.HostServices{
+			TCP: []string{"169.254.0.2:9001", "169.254.0.2:53"},
+			UDP: []string{"169.254.0.2:8125", "169.254.0.2:53", "169.254.0.3:8125"},
+		}))
+		Expect(warnings.Contents()).To(BeEmpty())
+	})
+
+	It("does not change the configured addresses", func() {
+		register("dns.json", `{"tcp": ["169.254.0.2:53"]}`)
+
+		_, err := lib.ReadHostServiceRegistry(dir, configured, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(configured.TCP).To(Equal([]string{"169.254.0.2:9001"}))
+	})
+
+	DescribeTable("skipping invalid registrations",
+		func(contents, warning string) {
+			register("broken.json", contents)
+			register("dns.json", `{"udp": ["169.254.0.2:53"]}`)
+
+			services, err := lib.ReadHostServiceRegistry(dir, configured, warnings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services.UDP).To(Equal([]string{"169.254.0.2:8125", "169.254.0.2:53"}))
+			Expect(services.TCP).To(Equal([]string{"169.254.0.2:9001"}))
+			Expect(warnings).To(gbytes.Say("skipping host service .*broken.json: " + warning))
+		},
+		Entry("invalid json", `{"tcp": `, "unexpected end of JSON input"),
+		Entry("missing port", `{"tcp": ["169.254.0.2"]}`, "address 169.254.0.2: missing port in address"),
+		Entry("hostname", `{"tcp": ["localhost:53"]}`, `invalid address "localhost:53": host must be an IPv4 address`),
+		Entry("loopback", `{"udp": ["127.0.0.1:53"]}`, `invalid address "127.0.0.1:53": host must not be in 127.0.0.0/8`),
+		Entry("port out of range", `{"tcp": ["169.254.0.2:70000"]}`, `invalid address "169.254.0.2:70000": port must be between 1 and 65535`),
+	)
+
+	Context("when the registry does not exist", func() {
+		It("returns the configured addresses", func() {
+			services, err := lib.ReadHostServiceRegistry(filepath.Join(dir, "missing"), configured, warnings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services).To(Equal(configured))
+		})
+	})
+
+	Context("when there is no registry", func() {
+		It("returns the configured addresses", func() {
+			register("dns.json", `{"tcp": ["169.254.0.2:53"]}`)
+
+			services, err := lib.ReadHostServiceRegistry("", configured, warnings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(services).To(Equal(configured))
+		})
+	})
+
+	Context("when the registry is not a directory", func() {
+		It("returns an error", func() {
+			register("file", "")
+
+			_, err := lib.ReadHostServiceRegistry(filepath.Join(dir, "file"), configured, warnings)
+			Expect(err).To(MatchError(ContainSubstring("host services registry:")))
+		})
+	})
+})
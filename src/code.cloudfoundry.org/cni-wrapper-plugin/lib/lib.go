@@ -1,19 +1,26 @@
 package lib
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lib/config"
 	"code.cloudfoundry.org/lib/rules"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/policy_client"
 
 	"github.com/containernetworking/cni/pkg/types"
-	"gopkg.in/validator.v2"
 )
 
 type RuntimeConfig struct {
-	PortMappings []garden.NetIn      `json:"portMappings"`
+	PortMappings []PortMapping       `json:"portMappings"`
 	NetOutRules  []garden.NetOutRule `json:"netOutRules"`
 }
 
@@ -31,85 +38,519 @@ type OutConnConfig struct {
 	DryRun     bool `json:"dry_run"`
 }
 
+// EgressProxyConfig lists the spaces whose containers may only reach the
+// egress proxies. Both the wrapper, when a container is created, and the
+// vxlan policy agent, on every ASG sync, apply it.
+type EgressProxyConfig struct {
+	SpaceGUIDs []string              `json:"space_guids"`
+	Endpoints  []EgressProxyEndpoint `json:"endpoints"`
+}
+
+type EgressProxyEndpoint struct {
+	Destination string `json:"destination"`
+	Protocol    string `json:"protocol"`
+	Ports       string `json:"ports"`
+}
+
+func (e EgressProxyConfig) IncludesSpace(spaceGUID string) bool {
+	if spaceGUID == "" {
+		return false
+	}
+	for _, s := range e.SpaceGUIDs {
+		if s == spaceGUID {
+			return true
+		}
+	}
+	return false
+}
+
+func (e EgressProxyConfig) SecurityGroupRules() []policy_client.SecurityGroupRule {
+	return endpointRules(e.Endpoints)
+}
+
+// UIDExemptionConfig gives the packets a user inside the containers sends,
+// e.g. the user of a sidecar the platform injects, their own egress rules.
+// The packets of the user are marked with the DSCP value inside the
+// container, and the cell accepts the marked packets to the destinations, or
+// to any destination with bypass, before the egress rules of the container.
+type UIDExemptionConfig struct {
+	UID          int                   `json:"uid"`
+	DSCP         int                   `json:"dscp"`
+	Bypass       bool                  `json:"bypass"`
+	Destinations []EgressProxyEndpoint `json:"destinations"`
+}
+
+func (u UIDExemptionConfig) SecurityGroupRules() []policy_client.SecurityGroupRule {
+	return endpointRules(u.Destinations)
+}
+
+func endpointRules(endpoints []EgressProxyEndpoint) []policy_client.SecurityGroupRule {
+	sgRules := []policy_client.SecurityGroupRule{}
+	for _, endpoint := range endpoints {
+		sgRules = append(sgRules, policy_client.SecurityGroupRule{
+			Protocol:    endpoint.Protocol,
+			Destination: endpoint.Destination,
+			Ports:       endpoint.Ports,
+		})
+	}
+	return sgRules
+}
+
+// TimeoutsConfig bounds the phases of the ADD and DEL calls, in seconds, so
+// that a hung delegate plugin, iptables call, datastore lock or policy agent
+// fails the call with the phase it hung in before the runtime gives up on
+// it. A timeout of 0 leaves the phase unbounded.
+type TimeoutsConfig struct {
+	DelegateSeconds    int `json:"delegate_seconds"`
+	IPTablesSeconds    int `json:"iptables_seconds"`
+	DatastoreSeconds   int `json:"datastore_seconds"`
+	PolicyAgentSeconds int `json:"policy_agent_seconds"`
+}
+
+func (t TimeoutsConfig) Delegate() time.Duration {
+	return time.Duration(t.DelegateSeconds) * time.Second
+}
+
+func (t TimeoutsConfig) IPTables() time.Duration {
+	return time.Duration(t.IPTablesSeconds) * time.Second
+}
+
+func (t TimeoutsConfig) Datastore() time.Duration {
+	return time.Duration(t.DatastoreSeconds) * time.Second
+}
+
+func (t TimeoutsConfig) PolicyAgent() time.Duration {
+	return time.Duration(t.PolicyAgentSeconds) * time.Second
+}
+
+func (t TimeoutsConfig) validate() error {
+	if t.DelegateSeconds < 0 || t.IPTablesSeconds < 0 || t.DatastoreSeconds < 0 || t.PolicyAgentSeconds < 0 {
+		return fmt.Errorf("invalid timeouts: must not be negative")
+	}
+	return nil
+}
+
+// ChainBatchConfig lets the ADDs of containers that start together create
+// their chains in one iptables-restore, see netrules.ChainBatch. The ADDs
+// queue their chains in Dir, and the first of them waits WindowMilliseconds
+// for the others. An empty Dir creates the chains of every ADD on its own.
+type ChainBatchConfig struct {
+	Dir                string `json:"dir"`
+	WindowMilliseconds int    `json:"window_ms"`
+}
+
+func (c ChainBatchConfig) Window() time.Duration {
+	return time.Duration(c.WindowMilliseconds) * time.Millisecond
+}
+
+func (c ChainBatchConfig) validate() error {
+	if c.WindowMilliseconds < 0 {
+		return fmt.Errorf("invalid chain batch window: must not be negative")
+	}
+	return nil
+}
+
+// FeatureFlagsConfig is the file of the feature flags of the cell, whose
+// flags override the logging, deny networks and connection limit settings.
+type FeatureFlagsConfig struct {
+	File   string `json:"file"`
+	CellID string `json:"cell_id"`
+}
+
 type WrapperConfig struct {
-	CNIVersion                      string                 `json:"cniVersion"`
-	Datastore                       string                 `json:"datastore"`
-	DatastoreFileOwner              string                 `json:"datastore_file_owner"`
-	DatastoreFileGroup              string                 `json:"datastore_file_group"`
-	IPTablesLockFile                string                 `json:"iptables_lock_file"`
-	Delegate                        map[string]interface{} `json:"delegate"`
-	InstanceAddress                 string                 `json:"instance_address"`
-	NoMasqueradeCIDRRange           string                 `json:"no_masquerade_cidr_range"`
-	DNSServers                      []string               `json:"dns_servers"`
-	HostTCPServices                 []string               `json:"host_tcp_services"`
-	HostUDPServices                 []string               `json:"host_udp_services"`
-	DenyNetworks                    DenyNetworksConfig     `json:"deny_networks"`
-	UnderlayIPs                     []string               `json:"underlay_ips"`
-	TemporaryUnderlayInterfaceNames []string               `json:"temporary_underlay_interface_names"`
-	IPTablesASGLogging              bool                   `json:"iptables_asg_logging"`
-	IPTablesC2CLogging              bool                   `json:"iptables_c2c_logging"`
-	IPTablesDeniedLogsPerSec        int                    `json:"iptables_denied_logs_per_sec" validate:"min=1"`
-	IPTablesAcceptedUDPLogsPerSec   int                    `json:"iptables_accepted_udp_logs_per_sec" validate:"min=1"`
-	IngressTag                      string                 `json:"ingress_tag"`
-	VTEPName                        string                 `json:"vtep_name"`
-	RuntimeConfig                   RuntimeConfig          `json:"runtimeConfig,omitempty"`
-	PolicyAgentForcePollAddress     string                 `json:"policy_agent_force_poll_address" validate:"nonzero"`
-	OutConn                         OutConnConfig          `json:"outbound_connections"`
+	CNIVersion                      string                   `json:"cniVersion"`
+	Datastore                       string                   `json:"datastore"`
+	DatastoreFileOwner              string                   `json:"datastore_file_owner"`
+	DatastoreFileGroup              string                   `json:"datastore_file_group"`
+	ContainerEventsDirectory        string                   `json:"container_events_directory"`
+	IPTablesLockFile                string                   `json:"iptables_lock_file"`
+	Delegate                        map[string]interface{}   `json:"delegate"`
+	InstanceAddress                 string                   `json:"instance_address"`
+	NoMasqueradeCIDRRange           string                   `json:"no_masquerade_cidr_range"`
+	DNSServers                      []string                 `json:"dns_servers"`
+	HostTCPServices                 []string                 `json:"host_tcp_services"`
+	HostUDPServices                 []string                 `json:"host_udp_services"`
+	HostServicesRegistryDir         string                   `json:"host_services_registry_dir"`
+	DenyNetworks                    DenyNetworksConfig       `json:"deny_networks"`
+	UnderlayIPs                     []string                 `json:"underlay_ips"`
+	TemporaryUnderlayInterfaceNames []string                 `json:"temporary_underlay_interface_names"`
+	IPTablesASGLogging              bool                     `json:"iptables_asg_logging"`
+	IPTablesC2CLogging              bool                     `json:"iptables_c2c_logging"`
+	IPTablesDeniedLogsPerSec        int                      `json:"iptables_denied_logs_per_sec" validate:"min=1"`
+	IPTablesDeniedLogsPerDest       bool                     `json:"iptables_denied_logs_per_destination"`
+	IPTablesAcceptedUDPLogsPerSec   int                      `json:"iptables_accepted_udp_logs_per_sec" validate:"min=1"`
+	IPTablesCellLogsPerSec          int                      `json:"iptables_cell_logs_per_sec"`
+	IPTablesCellLogsBurst           int                      `json:"iptables_cell_logs_burst"`
+	RejectTCPWithReset              bool                     `json:"reject_tcp_with_reset"`
+	IngressTag                      string                   `json:"ingress_tag"`
+	VTEPName                        string                   `json:"vtep_name"`
+	RuntimeConfig                   RuntimeConfig            `json:"runtimeConfig,omitempty"`
+	PolicyAgentForcePollAddress     string                   `json:"policy_agent_force_poll_address" validate:"nonzero"`
+	OutConn                         OutConnConfig            `json:"outbound_connections"`
+	EgressProxy                     EgressProxyConfig        `json:"egress_proxy"`
+	UIDExemptions                   []UIDExemptionConfig     `json:"uid_exemptions"`
+	Timeouts                        TimeoutsConfig           `json:"timeouts"`
+	FeatureFlags                    FeatureFlagsConfig       `json:"feature_flags"`
+	RelatedEstablished              RelatedEstablishedConfig `json:"related_established"`
+	SNIInspection                   SNIInspectionConfig      `json:"sni_inspection"`
+	ChainBatch                      ChainBatchConfig         `json:"chain_batch"`
+}
+
+// RelatedEstablishedConfig scopes the rules that accept the packets of the
+// established connections of the containers, see
+// netrules.RelatedEstablishedScope.
+type RelatedEstablishedConfig struct {
+	Scope      string   `json:"scope"`
+	Interfaces []string `json:"interfaces"`
+}
+
+func (r RelatedEstablishedConfig) NetRules() netrules.RelatedEstablishedScope {
+	return netrules.RelatedEstablishedScope{Mode: r.Scope, Interfaces: r.Interfaces}
+}
+
+func (r RelatedEstablishedConfig) Validate() error {
+	switch r.Scope {
+	case "", netrules.RelatedEstablishedAll, netrules.RelatedEstablishedConnMark:
+	case netrules.RelatedEstablishedInterface:
+		if len(r.Interfaces) == 0 {
+			return errors.New("related established: missing interfaces")
+		}
+	default:
+		return fmt.Errorf("related established: invalid scope %q", r.Scope)
+	}
+	return nil
+}
+
+// SNIInspectionConfig only lets the containers of the spaces of an allowlist
+// open TLS connections to its server names. The first packets of their TLS
+// connections are queued to the SNI verdict daemon of the vxlan policy agent,
+// which reads the server name from the ClientHello. With FailOpen, the
+// connections it cannot inspect are allowed, also while it is not running;
+// otherwise they are denied.
+type SNIInspectionConfig struct {
+	FailOpen   bool                 `json:"fail_open"`
+	Allowlists []SNIAllowlistConfig `json:"allowlists"`
+}
+
+// SNIAllowlistConfig is the allowlist of server names of the spaces. The
+// connections of their containers to the ports, 443 if none, are queued to
+// the NFQUEUE QueueNum. Rules are ASG-like entries of the allowlist.
+type SNIAllowlistConfig struct {
+	QueueNum    int             `json:"queue_num"`
+	SpaceGUIDs  []string        `json:"space_guids"`
+	Ports       string          `json:"ports"`
+	ServerNames []string        `json:"server_names"`
+	Rules       []SNIRuleConfig `json:"rules"`
+}
+
+// SNIRuleConfig lets the containers of the spaces of its allowlist open TLS
+// connections to the destination and ports, 443 if none, like an ASG, but
+// only to its server names and the ones of its allowlist.
+type SNIRuleConfig struct {
+	Destination string   `json:"destination"`
+	Ports       string   `json:"ports"`
+	ServerNames []string `json:"server_names"`
+}
+
+// maxSNIPorts is the most ports the multiport match of the queue rule takes,
+// a range counting as two.
+const maxSNIPorts = 15
+
+func (s SNIInspectionConfig) NetRules() netrules.SNIInspection {
+	queues := []netrules.SNIQueue{}
+	for _, allowlist := range s.Allowlists {
+		queue := netrules.SNIQueue{
+			QueueNum:   allowlist.QueueNum,
+			SpaceGUIDs: allowlist.SpaceGUIDs,
+		}
+		// an allowlist of rules only inspects the connections to their
+		// destinations
+		if len(allowlist.ServerNames) > 0 {
+			queue.Ports = multiportPorts(allowlist.PortsOrDefault())
+		}
+		for _, rule := range allowlist.Rules {
+			sgRule, err := netrules.NewRuleFromSecurityGroupRule(rule.SecurityGroupRule())
+			if err != nil {
+				continue // not reached once validated
+			}
+			for _, network := range sgRule.Networks() {
+				queue.Destinations = append(queue.Destinations, netrules.SNIDestination{
+					Network: network,
+					Ports:   multiportPorts(rule.PortsOrDefault()),
+				})
+			}
+		}
+		queues = append(queues, queue)
+	}
+	return netrules.SNIInspection{FailOpen: s.FailOpen, Queues: queues}
+}
+
+// SecurityGroupRules returns the rules of the allowlists by the spaces they
+// apply to. The vxlan policy agent adds them to the ASGs of the containers of
+// the spaces.
+func (s SNIInspectionConfig) SecurityGroupRules() map[string][]policy_client.SecurityGroupRule {
+	sgRules := map[string][]policy_client.SecurityGroupRule{}
+	for _, allowlist := range s.Allowlists {
+		if len(allowlist.Rules) == 0 {
+			continue
+		}
+		allowlistRules := []policy_client.SecurityGroupRule{}
+		for _, rule := range allowlist.Rules {
+			allowlistRules = append(allowlistRules, rule.SecurityGroupRule())
+		}
+		for _, spaceGUID := range allowlist.SpaceGUIDs {
+			sgRules[spaceGUID] = allowlistRules
+		}
+	}
+	return sgRules
+}
+
+func (a SNIAllowlistConfig) PortsOrDefault() string {
+	if a.Ports == "" {
+		return "443"
+	}
+	return a.Ports
+}
+
+func (r SNIRuleConfig) PortsOrDefault() string {
+	if r.Ports == "" {
+		return "443"
+	}
+	return r.Ports
+}
+
+func (r SNIRuleConfig) SecurityGroupRule() policy_client.SecurityGroupRule {
+	return policy_client.SecurityGroupRule{
+		Protocol:    "tcp",
+		Destination: r.Destination,
+		Ports:       r.PortsOrDefault(),
+	}
+}
+
+// multiportPorts converts the port ranges of ports to the syntax of the
+// multiport match.
+func multiportPorts(ports string) string {
+	return strings.ReplaceAll(ports, "-", ":")
+}
+
+func (s SNIInspectionConfig) Validate() error {
+	queues := map[int]bool{}
+	spaces := map[string]bool{}
+	for _, allowlist := range s.Allowlists {
+		if allowlist.QueueNum < 0 || allowlist.QueueNum > 65535 {
+			return fmt.Errorf("sni inspection: invalid queue num %d", allowlist.QueueNum)
+		}
+		if queues[allowlist.QueueNum] {
+			return fmt.Errorf("sni inspection: duplicate queue num %d", allowlist.QueueNum)
+		}
+		queues[allowlist.QueueNum] = true
+		if len(allowlist.SpaceGUIDs) == 0 {
+			return fmt.Errorf("sni inspection: queue %d: missing space guids", allowlist.QueueNum)
+		}
+		for _, spaceGUID := range allowlist.SpaceGUIDs {
+			if spaces[spaceGUID] {
+				return fmt.Errorf("sni inspection: space %s is in more than one allowlist", spaceGUID)
+			}
+			spaces[spaceGUID] = true
+		}
+		if err := validateSNIPorts(allowlist.PortsOrDefault()); err != nil {
+			return fmt.Errorf("sni inspection: queue %d: %s", allowlist.QueueNum, err)
+		}
+		if len(allowlist.ServerNames) == 0 && len(allowlist.Rules) == 0 {
+			return fmt.Errorf("sni inspection: queue %d: missing server names", allowlist.QueueNum)
+		}
+		if err := validateServerNames(allowlist.ServerNames); err != nil {
+			return fmt.Errorf("sni inspection: queue %d: %s", allowlist.QueueNum, err)
+		}
+		for _, rule := range allowlist.Rules {
+			if err := rule.validate(); err != nil {
+				return fmt.Errorf("sni inspection: queue %d: rule %s: %s", allowlist.QueueNum, rule.Destination, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r SNIRuleConfig) validate() error {
+	if _, err := netrules.NewRuleFromSecurityGroupRule(r.SecurityGroupRule()); err != nil {
+		return fmt.Errorf("invalid destination %q", r.Destination)
+	}
+	if err := validateSNIPorts(r.PortsOrDefault()); err != nil {
+		return err
+	}
+	if len(r.ServerNames) == 0 {
+		return errors.New("missing server names")
+	}
+	return validateServerNames(r.ServerNames)
+}
+
+func validateServerNames(serverNames []string) error {
+	for _, serverName := range serverNames {
+		if !validServerName(serverName) {
+			return fmt.Errorf("invalid server name %q", serverName)
+		}
+	}
+	return nil
+}
+
+// validateSNIPorts checks that ports is a comma separated list of ports and
+// port ranges, e.g. 443,8000-8443, that fits in a multiport match.
+func validateSNIPorts(ports string) error {
+	count := 0
+	for _, portRange := range strings.Split(ports, ",") {
+		bounds := strings.Split(portRange, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid ports %q", ports)
+		}
+		previous := 0
+		for _, bound := range bounds {
+			port, err := strconv.Atoi(bound)
+			if err != nil || port < 1 || port > 65535 || port < previous {
+				return fmt.Errorf("invalid ports %q", ports)
+			}
+			previous = port
+		}
+		count += len(bounds)
+	}
+	if count > maxSNIPorts {
+		return fmt.Errorf("more than %d ports %q", maxSNIPorts, ports)
+	}
+	return nil
+}
+
+// validServerName checks that name is a DNS name, optionally with a leading
+// *. label that matches any subdomain.
+func validServerName(name string) bool {
+	name = strings.TrimPrefix(name, "*.")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
 }
 
+// LoadWrapperConfig loads the config of the plugin from the network
+// configuration the runtime passes on stdin. Unlike the configs of the jobs,
+// it is decoded leniently, since runtimes add keys of their own to it.
 func LoadWrapperConfig(bytes []byte) (*WrapperConfig, error) {
 	n := &WrapperConfig{}
 	if err := json.Unmarshal(bytes, n); err != nil {
 		return nil, fmt.Errorf("loading wrapper config: %v", err)
 	}
 
+	if err := config.Check(n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *WrapperConfig) SetDefaults() {
+	if n.Delegate == nil {
+		n.Delegate = map[string]interface{}{}
+	}
+	if _, ok := n.Delegate["cniVersion"]; !ok {
+		n.Delegate["cniVersion"] = "1.0.0"
+	}
+}
+
+func (n *WrapperConfig) Validate() error {
 	if n.Datastore == "" {
-		return nil, fmt.Errorf("missing datastore path")
+		return fmt.Errorf("missing datastore path")
 	}
 
 	if n.IPTablesLockFile == "" {
-		return nil, fmt.Errorf("missing iptables lock file path")
+		return fmt.Errorf("missing iptables lock file path")
 	}
 
 	if n.InstanceAddress == "" {
-		return nil, fmt.Errorf("missing instance address")
+		return fmt.Errorf("missing instance address")
 	}
 
 	if len(n.UnderlayIPs) < 1 {
-		return nil, fmt.Errorf("missing underlay ips")
+		return fmt.Errorf("missing underlay ips")
 	}
 
 	if n.IngressTag == "" {
-		return nil, fmt.Errorf("missing ingress tag")
+		return fmt.Errorf("missing ingress tag")
 	}
 
 	if n.VTEPName == "" {
-		return nil, fmt.Errorf("missing vtep device name")
+		return fmt.Errorf("missing vtep device name")
 	}
 
 	if n.IPTablesDeniedLogsPerSec <= 0 {
-		return nil, fmt.Errorf("invalid denied logs per sec")
+		return fmt.Errorf("invalid denied logs per sec")
 	}
 
 	if n.IPTablesAcceptedUDPLogsPerSec <= 0 {
-		return nil, fmt.Errorf("invalid accepted udp logs per sec")
+		return fmt.Errorf("invalid accepted udp logs per sec")
 	}
 
-	if _, ok := n.Delegate["cniVersion"]; !ok {
-		n.Delegate["cniVersion"] = "1.0.0"
+	if n.IPTablesCellLogsPerSec < 0 || n.IPTablesCellLogsPerSec > rules.MaxLogLimit {
+		return fmt.Errorf("invalid cell logs per sec: must be between 0 and %d", rules.MaxLogLimit)
+	}
+
+	if n.IPTablesCellLogsBurst < 0 || n.IPTablesCellLogsBurst > rules.MaxLogLimit {
+		return fmt.Errorf("invalid cell logs burst: must be between 0 and %d", rules.MaxLogLimit)
 	}
 
 	if n.OutConn.Burst <= 0 {
-		return nil, fmt.Errorf("invalid outbound connection burst")
+		return fmt.Errorf("invalid outbound connection burst")
 	}
 
 	if n.OutConn.RatePerSec <= 0 {
-		return nil, fmt.Errorf("invalid outbound connection rate")
+		return fmt.Errorf("invalid outbound connection rate")
 	}
 
-	validator.Validate(n)
+	if err := validateUIDExemptions(n.UIDExemptions); err != nil {
+		return err
+	}
 
-	return n, nil
+	if err := n.RelatedEstablished.Validate(); err != nil {
+		return err
+	}
+
+	if err := n.SNIInspection.Validate(); err != nil {
+		return err
+	}
+
+	if err := n.ChainBatch.validate(); err != nil {
+		return err
+	}
+
+	return n.Timeouts.validate()
+}
+
+// validateUIDExemptions checks that every exemption marks its packets with a
+// DSCP value of its own, and either bypasses the egress rules or has
+// destinations.
+func validateUIDExemptions(exemptions []UIDExemptionConfig) error {
+	dscpValues := map[int]bool{}
+	for _, exemption := range exemptions {
+		if exemption.UID < 0 {
+			return fmt.Errorf("invalid uid exemption uid %d", exemption.UID)
+		}
+		if exemption.DSCP < 1 || exemption.DSCP > 63 {
+			return fmt.Errorf("invalid uid exemption dscp %d", exemption.DSCP)
+		}
+		if dscpValues[exemption.DSCP] {
+			return fmt.Errorf("duplicate uid exemption dscp %d", exemption.DSCP)
+		}
+		dscpValues[exemption.DSCP] = true
+		if exemption.Bypass == (len(exemption.Destinations) > 0) {
+			return fmt.Errorf("uid exemption for uid %d needs either bypass or destinations", exemption.UID)
+		}
+	}
+	return nil
 }
 
 type PluginController struct {
@@ -131,38 +572,44 @@ func getDelegateParams(netconf map[string]interface{}) (string, []byte, error) {
 	return delegateType, netconfBytes, nil
 }
 
-func (c *PluginController) DelegateAdd(netconf map[string]interface{}) (types.Result, error) {
+func (c *PluginController) DelegateAdd(ctx context.Context, netconf map[string]interface{}) (types.Result, error) {
 	delegateType, netconfBytes, err := getDelegateParams(netconf)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.Delegator.DelegateAdd(delegateType, netconfBytes)
+	return c.Delegator.DelegateAdd(ctx, delegateType, netconfBytes)
 }
 
-func (c *PluginController) DelegateDel(netconf map[string]interface{}) error {
+func (c *PluginController) DelegateDel(ctx context.Context, netconf map[string]interface{}) error {
 	delegateType, netconfBytes, err := getDelegateParams(netconf)
 	if err != nil {
 		return err
 	}
 
-	return c.Delegator.DelegateDel(delegateType, netconfBytes)
+	return c.Delegator.DelegateDel(ctx, delegateType, netconfBytes)
 }
 
-func (c *PluginController) AddIPMasq(ip, noMasqueradeCIDRRange, deviceName string) error {
+func (c *PluginController) AddIPMasq(ctx context.Context, ip, noMasqueradeCIDRRange, deviceName string) error {
 	rule := rules.NewDefaultEgressRule(ip, noMasqueradeCIDRRange, deviceName)
 
-	if err := c.IPTables.BulkAppend("nat", "POSTROUTING", rule); err != nil {
+	if err := c.IPTables.WithContext(ctx).BulkAppend("nat", "POSTROUTING", rule); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (c *PluginController) DelIPMasq(ip, noMasqueradeCIDRRange, deviceName string) error {
+// DelIPMasq succeeds when the rule is already gone, so that a retried DEL
+// does not fail on it.
+func (c *PluginController) DelIPMasq(ctx context.Context, ip, noMasqueradeCIDRRange, deviceName string) error {
 	rule := rules.NewDefaultEgressRule(ip, noMasqueradeCIDRRange, deviceName)
 
-	if err := c.IPTables.Delete("nat", "POSTROUTING", rule); err != nil {
+	ipt := c.IPTables.WithContext(ctx)
+	if err := ipt.Delete("nat", "POSTROUTING", rule); err != nil {
+		if exists, existsErr := ipt.Exists("nat", "POSTROUTING", rule); existsErr == nil && !exists {
+			return nil
+		}
 		return err
 	}
 
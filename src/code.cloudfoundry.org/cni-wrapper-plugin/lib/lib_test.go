@@ -1,14 +1,18 @@
 package lib_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
+	"time"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
 	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	lib_fakes "code.cloudfoundry.org/lib/fakes"
 	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/policy_client"
 
 	"github.com/containernetworking/cni/pkg/types"
 	types020 "github.com/containernetworking/cni/pkg/types/020"
@@ -36,7 +40,9 @@ var _ = Describe("LoadWrapperConfig", func() {
 				"some": "info"
 			},
 			"iptables_denied_logs_per_sec": 2,
+			"iptables_denied_logs_per_destination": true,
 			"iptables_accepted_udp_logs_per_sec": 4,
+			"reject_tcp_with_reset": true,
 			"outbound_connections": {
 				"limit": true,
 				"logging": true,
@@ -67,7 +73,9 @@ var _ = Describe("LoadWrapperConfig", func() {
 			IngressTag:                    "ffaa0000",
 			VTEPName:                      "some-device",
 			IPTablesDeniedLogsPerSec:      2,
+			IPTablesDeniedLogsPerDest:     true,
 			IPTablesAcceptedUDPLogsPerSec: 4,
+			RejectTCPWithReset:            true,
 			OutConn: lib.OutConnConfig{
 				Limit:      true,
 				Logging:    true,
@@ -89,6 +97,22 @@ var _ = Describe("LoadWrapperConfig", func() {
 		})
 	})
 
+	Context("when the runtime adds keys of its own", func() {
+		BeforeEach(func() {
+			var inputData map[string]interface{}
+			Expect(json.Unmarshal(input, &inputData)).To(Succeed())
+			inputData["name"] = "cni-wrapper"
+			inputData["cniVersion"] = "1.0.0"
+			inputData["runtimeConfig"] = map[string]interface{}{"some": "config"}
+			input, _ = json.Marshal(inputData)
+		})
+
+		It("ignores them", func() {
+			_, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
 	Describe("delegate cniVersion", func() {
 		Context("when the input JSON doesn't have an explicit version on the delgate", func() {
 			BeforeEach(func() {
@@ -156,9 +180,200 @@ var _ = Describe("LoadWrapperConfig", func() {
 	},
 		Entry("denied logs per sec", "iptables_denied_logs_per_sec", -1, "invalid denied logs per sec"),
 		Entry("accepted udp logs per sec", "iptables_accepted_udp_logs_per_sec", -1, "invalid accepted udp logs per sec"),
+		Entry("negative cell logs per sec", "iptables_cell_logs_per_sec", -1, "invalid cell logs per sec: must be between 0 and 5000"),
+		Entry("too many cell logs per sec", "iptables_cell_logs_per_sec", 5001, "invalid cell logs per sec: must be between 0 and 5000"),
+		Entry("cell logs burst", "iptables_cell_logs_burst", 5001, "invalid cell logs burst: must be between 0 and 5000"),
 		Entry("out conn burst", "outbound_connections", map[string]interface{}{"burst": -1}, "invalid outbound connection burst"),
 		Entry("out conn rate", "outbound_connections", map[string]interface{}{"burst": 1, "rate_per_sec": -1}, "invalid outbound connection rate"),
+		Entry("uid exemption uid", "uid_exemptions", []map[string]interface{}{{"uid": -1, "dscp": 10, "bypass": true}}, "invalid uid exemption uid -1"),
+		Entry("uid exemption dscp", "uid_exemptions", []map[string]interface{}{{"uid": 2000, "dscp": 64, "bypass": true}}, "invalid uid exemption dscp 64"),
+		Entry("uid exemption duplicate dscp", "uid_exemptions", []map[string]interface{}{
+			{"uid": 2000, "dscp": 10, "bypass": true},
+			{"uid": 2001, "dscp": 10, "bypass": true},
+		}, "duplicate uid exemption dscp 10"),
+		Entry("uid exemption without treatment", "uid_exemptions", []map[string]interface{}{{"uid": 2000, "dscp": 10}}, "uid exemption for uid 2000 needs either bypass or destinations"),
+		Entry("negative timeout", "timeouts", map[string]interface{}{"delegate_seconds": -1}, "invalid timeouts: must not be negative"),
+		Entry("negative chain batch window", "chain_batch", map[string]interface{}{"dir": "/some/dir", "window_ms": -1}, "invalid chain batch window: must not be negative"),
+		Entry("related established scope", "related_established", map[string]interface{}{"scope": "some"}, `related established: invalid scope "some"`),
+		Entry("related established interfaces", "related_established", map[string]interface{}{"scope": "interface"}, "related established: missing interfaces"),
+		Entry("sni queue num", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 65536, "space_guids": []string{"s1"}, "server_names": []string{"example.com"}},
+		}}, "sni inspection: invalid queue num 65536"),
+		Entry("sni duplicate queue num", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}, "server_names": []string{"example.com"}},
+			{"queue_num": 100, "space_guids": []string{"s2"}, "server_names": []string{"example.com"}},
+		}}, "sni inspection: duplicate queue num 100"),
+		Entry("sni space in two allowlists", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}, "server_names": []string{"example.com"}},
+			{"queue_num": 101, "space_guids": []string{"s1"}, "server_names": []string{"example.com"}},
+		}}, "sni inspection: space s1 is in more than one allowlist"),
+		Entry("sni ports", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}, "ports": "443,9000-8000", "server_names": []string{"example.com"}},
+		}}, `sni inspection: queue 100: invalid ports "443,9000-8000"`),
+		Entry("sni server names", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}},
+		}}, "sni inspection: queue 100: missing server names"),
+		Entry("sni server name", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}, "server_names": []string{"api.*.example.com"}},
+		}}, `sni inspection: queue 100: invalid server name "api.*.example.com"`),
+		Entry("sni rule destination", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}, "rules": []map[string]interface{}{
+				{"destination": "10.0.0.0/33", "server_names": []string{"example.com"}},
+			}},
+		}}, `sni inspection: queue 100: rule 10.0.0.0/33: invalid destination "10.0.0.0/33"`),
+		Entry("sni rule ports", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}, "rules": []map[string]interface{}{
+				{"destination": "10.0.0.0/24", "ports": "https", "server_names": []string{"example.com"}},
+			}},
+		}}, `sni inspection: queue 100: rule 10.0.0.0/24: invalid ports "https"`),
+		Entry("sni rule server names", "sni_inspection", map[string]interface{}{"allowlists": []map[string]interface{}{
+			{"queue_num": 100, "space_guids": []string{"s1"}, "rules": []map[string]interface{}{
+				{"destination": "10.0.0.0/24"},
+			}},
+		}}, "sni inspection: queue 100: rule 10.0.0.0/24: missing server names"),
 	)
+
+	Context("when sni inspection is configured", func() {
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["sni_inspection"] = map[string]interface{}{
+				"fail_open": true,
+				"allowlists": []map[string]interface{}{
+					{"queue_num": 100, "space_guids": []string{"s1", "s2"}, "server_names": []string{"api.example.com"}},
+					{"queue_num": 101, "space_guids": []string{"s3"}, "ports": "443,8000-8443", "server_names": []string{"*.example.org"}},
+				},
+			}
+			input, _ = json.Marshal(config)
+		})
+
+		It("converts them to the queues of the netout chains", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.SNIInspection.NetRules()).To(Equal(netrules.SNIInspection{
+				FailOpen: true,
+				Queues: []netrules.SNIQueue{
+					{QueueNum: 100, SpaceGUIDs: []string{"s1", "s2"}, Ports: "443"},
+					{QueueNum: 101, SpaceGUIDs: []string{"s3"}, Ports: "443,8000:8443"},
+				},
+			}))
+		})
+
+		Context("when an allowlist has rules", func() {
+			BeforeEach(func() {
+				var config map[string]interface{}
+				Expect(json.Unmarshal(input, &config)).To(Succeed())
+				config["sni_inspection"] = map[string]interface{}{
+					"allowlists": []map[string]interface{}{
+						{"queue_num": 100, "space_guids": []string{"s1", "s2"}, "rules": []map[string]interface{}{
+							{"destination": "10.0.0.0/24,10.1.0.5", "server_names": []string{"api.example.com"}},
+							{"destination": "10.2.0.1-10.2.0.9", "ports": "8000-8443", "server_names": []string{"*.example.org"}},
+						}},
+					},
+				}
+				input, _ = json.Marshal(config)
+			})
+
+			It("only queues the connections to their destinations", func() {
+				conf, err := lib.LoadWrapperConfig(input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conf.SNIInspection.NetRules().Queues).To(Equal([]netrules.SNIQueue{{
+					QueueNum:   100,
+					SpaceGUIDs: []string{"s1", "s2"},
+					Destinations: []netrules.SNIDestination{
+						{Network: netrules.IPRange{Start: net.ParseIP("10.0.0.0").To4(), End: net.ParseIP("10.0.0.255").To4()}, Ports: "443"},
+						{Network: netrules.IPRange{Start: net.ParseIP("10.1.0.5"), End: net.ParseIP("10.1.0.5")}, Ports: "443"},
+						{Network: netrules.IPRange{Start: net.ParseIP("10.2.0.1"), End: net.ParseIP("10.2.0.9")}, Ports: "8000:8443"},
+					},
+				}}))
+			})
+
+			It("returns them as security group rules of the spaces", func() {
+				conf, err := lib.LoadWrapperConfig(input)
+				Expect(err).NotTo(HaveOccurred())
+				sgRules := []policy_client.SecurityGroupRule{
+					{Protocol: "tcp", Destination: "10.0.0.0/24,10.1.0.5", Ports: "443"},
+					{Protocol: "tcp", Destination: "10.2.0.1-10.2.0.9", Ports: "8000-8443"},
+				}
+				Expect(conf.SNIInspection.SecurityGroupRules()).To(Equal(map[string][]policy_client.SecurityGroupRule{
+					"s1": sgRules,
+					"s2": sgRules,
+				}))
+			})
+		})
+	})
+
+	Context("when timeouts are configured", func() {
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["timeouts"] = map[string]interface{}{
+				"delegate_seconds":     30,
+				"iptables_seconds":     20,
+				"datastore_seconds":    10,
+				"policy_agent_seconds": 15,
+			}
+			input, _ = json.Marshal(config)
+		})
+
+		It("parses them", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.Timeouts.Delegate()).To(Equal(30 * time.Second))
+			Expect(conf.Timeouts.IPTables()).To(Equal(20 * time.Second))
+			Expect(conf.Timeouts.Datastore()).To(Equal(10 * time.Second))
+			Expect(conf.Timeouts.PolicyAgent()).To(Equal(15 * time.Second))
+		})
+	})
+
+	Context("when uid exemptions are configured", func() {
+		BeforeEach(func() {
+			var config map[string]interface{}
+			Expect(json.Unmarshal(input, &config)).To(Succeed())
+			config["uid_exemptions"] = []map[string]interface{}{
+				{"uid": 2000, "dscp": 10, "bypass": true},
+				{"uid": 2001, "dscp": 11, "destinations": []map[string]string{{"destination": "10.0.5.5", "protocol": "tcp", "ports": "443"}}},
+			}
+			input, _ = json.Marshal(config)
+		})
+
+		It("parses them", func() {
+			conf, err := lib.LoadWrapperConfig(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.UIDExemptions).To(Equal([]lib.UIDExemptionConfig{
+				{UID: 2000, DSCP: 10, Bypass: true},
+				{UID: 2001, DSCP: 11, Destinations: []lib.EgressProxyEndpoint{{Destination: "10.0.5.5", Protocol: "tcp", Ports: "443"}}},
+			}))
+			Expect(conf.UIDExemptions[1].SecurityGroupRules()).To(Equal([]policy_client.SecurityGroupRule{
+				{Destination: "10.0.5.5", Protocol: "tcp", Ports: "443"},
+			}))
+		})
+	})
+})
+
+var _ = Describe("EgressProxyConfig", func() {
+	var egressProxy lib.EgressProxyConfig
+
+	BeforeEach(func() {
+		egressProxy = lib.EgressProxyConfig{
+			SpaceGUIDs: []string{"some-space-guid"},
+			Endpoints: []lib.EgressProxyEndpoint{
+				{Destination: "10.0.5.5", Protocol: "tcp", Ports: "3128"},
+			},
+		}
+	})
+
+	It("includes only the configured spaces", func() {
+		Expect(egressProxy.IncludesSpace("some-space-guid")).To(BeTrue())
+		Expect(egressProxy.IncludesSpace("some-other-space-guid")).To(BeFalse())
+		Expect(egressProxy.IncludesSpace("")).To(BeFalse())
+	})
+
+	It("converts the endpoints to security group rules", func() {
+		Expect(egressProxy.SecurityGroupRules()).To(Equal([]policy_client.SecurityGroupRule{
+			{Destination: "10.0.5.5", Protocol: "tcp", Ports: "3128"},
+		}))
+	})
 })
 
 var _ = Describe("DelegateAdd", func() {
@@ -188,7 +403,7 @@ var _ = Describe("DelegateAdd", func() {
 	})
 
 	It("should call the plugin specified by the type", func() {
-		result, err := pluginController.DelegateAdd(input)
+		result, err := pluginController.DelegateAdd(context.Background(), input)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(result).To(Equal(expectedResult))
 	})
@@ -201,7 +416,7 @@ var _ = Describe("DelegateAdd", func() {
 		})
 
 		It("should return a useful error", func() {
-			_, err := pluginController.DelegateAdd(input)
+			_, err := pluginController.DelegateAdd(context.Background(), input)
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError(HavePrefix("serializing delegate netconf:")))
 		})
@@ -213,7 +428,7 @@ var _ = Describe("DelegateAdd", func() {
 		})
 
 		It("should return a useful error", func() {
-			_, err := pluginController.DelegateAdd(input)
+			_, err := pluginController.DelegateAdd(context.Background(), input)
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError("patato"))
 		})
@@ -228,7 +443,7 @@ var _ = Describe("DelegateAdd", func() {
 		})
 
 		It("should return a useful error", func() {
-			_, err := pluginController.DelegateAdd(input)
+			_, err := pluginController.DelegateAdd(context.Background(), input)
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError("delegate config is missing type"))
 		})
@@ -255,7 +470,7 @@ var _ = Describe("DelegateDel", func() {
 	})
 
 	It("should call the plugin specified by the type", func() {
-		err := pluginController.DelegateDel(input)
+		err := pluginController.DelegateDel(context.Background(), input)
 		Expect(err).NotTo(HaveOccurred())
 	})
 
@@ -267,7 +482,7 @@ var _ = Describe("DelegateDel", func() {
 		})
 
 		It("should return a useful error", func() {
-			err := pluginController.DelegateDel(input)
+			err := pluginController.DelegateDel(context.Background(), input)
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError(HavePrefix("serializing delegate netconf:")))
 		})
@@ -279,7 +494,7 @@ var _ = Describe("DelegateDel", func() {
 		})
 
 		It("should return a useful error", func() {
-			err := pluginController.DelegateDel(input)
+			err := pluginController.DelegateDel(context.Background(), input)
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError("patato"))
 		})
@@ -293,7 +508,7 @@ var _ = Describe("DelegateDel", func() {
 		})
 
 		It("should return a useful error", func() {
-			err := pluginController.DelegateDel(input)
+			err := pluginController.DelegateDel(context.Background(), input)
 			Expect(err).To(HaveOccurred())
 			Expect(err).To(MatchError("delegate config is missing type"))
 		})
@@ -309,15 +524,19 @@ var _ = Describe("AddIPMasq", func() {
 
 	BeforeEach(func() {
 		fakeIPTablesAdapter = &lib_fakes.IPTablesAdapter{}
+		fakeIPTablesAdapter.WithContextReturns(fakeIPTablesAdapter)
 		pluginController = &lib.PluginController{
 			IPTables: fakeIPTablesAdapter,
 		}
 	})
 
 	It("should add the ip masquerade rules for egress traffic", func() {
-		err := pluginController.AddIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
+		ctx := rules.WithOperation(context.Background(), "iptables ip masq")
+		err := pluginController.AddIPMasq(ctx, "10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
 		Expect(err).NotTo(HaveOccurred())
 
+		Expect(fakeIPTablesAdapter.WithContextArgsForCall(0)).To(Equal(ctx))
+
 		tableName, chainName, iptablesRule := fakeIPTablesAdapter.BulkAppendArgsForCall(0)
 		Expect(tableName).To(Equal("nat"))
 		Expect(chainName).To(Equal("POSTROUTING"))
@@ -334,13 +553,14 @@ var _ = Describe("DelIPMasq", func() {
 
 	BeforeEach(func() {
 		fakeIPTablesAdapter = &lib_fakes.IPTablesAdapter{}
+		fakeIPTablesAdapter.WithContextReturns(fakeIPTablesAdapter)
 		pluginController = &lib.PluginController{
 			IPTables: fakeIPTablesAdapter,
 		}
 	})
 
 	It("should delete the ip masquerade rules for egress traffic", func() {
-		err := pluginController.DelIPMasq("10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
+		err := pluginController.DelIPMasq(context.Background(), "10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
 		Expect(err).NotTo(HaveOccurred())
 
 		tableName, chainName, iptablesRule := fakeIPTablesAdapter.DeleteArgsForCall(0)
@@ -348,4 +568,28 @@ var _ = Describe("DelIPMasq", func() {
 		Expect(chainName).To(Equal("POSTROUTING"))
 		Expect(iptablesRule).To(Equal(rules.NewDefaultEgressRule("10.255.5.5/32", "10.255.0.0/16", "silk-vtep")))
 	})
+
+	Context("when the rule is already gone", func() {
+		BeforeEach(func() {
+			fakeIPTablesAdapter.DeleteReturns(fmt.Errorf("Bad rule (does a matching rule exist in that chain?)"))
+			fakeIPTablesAdapter.ExistsReturns(false, nil)
+		})
+
+		It("succeeds", func() {
+			err := pluginController.DelIPMasq(context.Background(), "10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when deleting the rule fails", func() {
+		BeforeEach(func() {
+			fakeIPTablesAdapter.DeleteReturns(fmt.Errorf("patato"))
+			fakeIPTablesAdapter.ExistsReturns(true, nil)
+		})
+
+		It("returns the error", func() {
+			err := pluginController.DelIPMasq(context.Background(), "10.255.5.5/32", "10.255.0.0/16", "silk-vtep")
+			Expect(err).To(MatchError("patato"))
+		})
+	})
 })
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// PhaseTimeoutError is returned for a phase of a CNI call that did not
+// finish within its timeout, e.g. because an external binary hung.
+type PhaseTimeoutError struct {
+	Phase   string
+	Timeout time.Duration
+}
+
+func (e *PhaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Phase, e.Timeout)
+}
+
+// RunPhase runs the phase with a context that is cancelled after the
+// timeout, and returns a PhaseTimeoutError when the phase has not returned
+// by then. The iptables calls made with the context stop with it, and the
+// context names the phase as their operation. A phase that does not honor
+// the context is abandoned; it ends when the plugin exits with the error. A
+// timeout of 0 leaves the phase unbounded.
+func RunPhase(phase string, timeout time.Duration, f func(ctx context.Context) error) error {
+	phaseCtx := rules.WithOperation(context.Background(), phase)
+	if timeout <= 0 {
+		return f(phaseCtx)
+	}
+
+	ctx, cancel := context.WithTimeout(phaseCtx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return &PhaseTimeoutError{Phase: phase, Timeout: timeout}
+		}
+		return err
+	case <-ctx.Done():
+		return &PhaseTimeoutError{Phase: phase, Timeout: timeout}
+	}
+}
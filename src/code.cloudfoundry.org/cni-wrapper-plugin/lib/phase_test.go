@@ -0,0 +1,72 @@
+package lib_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	"code.cloudfoundry.org/lib/rules"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RunPhase", func() {
+	It("returns the result of the phase", func() {
+		Expect(lib.RunPhase("some phase", time.Second, func(context.Context) error {
+			return nil
+		})).To(Succeed())
+
+		err := lib.RunPhase("some phase", time.Second, func(context.Context) error {
+			return errors.New("banana")
+		})
+		Expect(err).To(MatchError("banana"))
+	})
+
+	Context("when the phase does not return within the timeout", func() {
+		It("returns a timeout error for the phase", func() {
+			hung := make(chan struct{})
+			defer close(hung)
+
+			err := lib.RunPhase("delegate add", 10*time.Millisecond, func(context.Context) error {
+				<-hung
+				return nil
+			})
+			Expect(err).To(MatchError("delegate add timed out after 10ms"))
+
+			var timeoutErr *lib.PhaseTimeoutError
+			Expect(errors.As(err, &timeoutErr)).To(BeTrue())
+			Expect(timeoutErr.Phase).To(Equal("delegate add"))
+		})
+	})
+
+	Context("when the phase fails because its context expired", func() {
+		It("returns a timeout error for the phase", func() {
+			err := lib.RunPhase("delegate add", 10*time.Millisecond, func(ctx context.Context) error {
+				<-ctx.Done()
+				return errors.New("signal: killed")
+			})
+			Expect(err).To(MatchError("delegate add timed out after 10ms"))
+		})
+	})
+
+	It("names the phase as the operation of its iptables calls", func() {
+		err := lib.RunPhase("iptables net out", time.Second, func(ctx context.Context) error {
+			Expect(rules.Operation(ctx)).To(Equal("iptables net out"))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("when the timeout is 0", func() {
+		It("does not bound the phase", func() {
+			err := lib.RunPhase("delegate add", 0, func(ctx context.Context) error {
+				_, hasDeadline := ctx.Deadline()
+				Expect(hasDeadline).To(BeFalse())
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package lib
+
+import "fmt"
+
+const maxPort = 65535
+
+// PortMapping forwards a host port to a container port. It extends the
+// garden.NetIn mappings, which are TCP, with a protocol and a range: with
+// HostPortEnd, the host ports from HostPort to HostPortEnd are forwarded to
+// as many container ports from ContainerPort on, e.g. for apps that
+// negotiate media ports at runtime.
+//
+// With PreserveClientIP, the replies of the container on the connections to
+// the mapping are kept on the return path of the cell, where they are not
+// SNATed, so that apps see the real client IPs without gorouter headers.
+type PortMapping struct {
+	HostPort         uint32 `json:"host_port"`
+	ContainerPort    uint32 `json:"container_port"`
+	HostPortEnd      uint32 `json:"host_port_end,omitempty"`
+	Protocol         string `json:"protocol,omitempty"`
+	PreserveClientIP bool   `json:"preserve_client_ip,omitempty"`
+}
+
+// ProtocolOrDefault is the protocol of the mapping, TCP when it has none.
+func (p PortMapping) ProtocolOrDefault() string {
+	if p.Protocol == "" {
+		return "tcp"
+	}
+	return p.Protocol
+}
+
+// LastHostPort is the end of the host port range, the host port itself when
+// the mapping is not a range.
+func (p PortMapping) LastHostPort() uint32 {
+	if p.HostPortEnd == 0 {
+		return p.HostPort
+	}
+	return p.HostPortEnd
+}
+
+func (p PortMapping) String() string {
+	if p.LastHostPort() == p.HostPort {
+		return fmt.Sprintf("%s %d:%d", p.ProtocolOrDefault(), p.HostPort, p.ContainerPort)
+	}
+	return fmt.Sprintf("%s %d-%d:%d", p.ProtocolOrDefault(), p.HostPort, p.HostPortEnd, p.ContainerPort)
+}
+
+func (p PortMapping) validate() error {
+	if p.HostPort == 0 {
+		return fmt.Errorf("cannot allocate port %d", p.HostPort)
+	}
+	switch p.ProtocolOrDefault() {
+	case "tcp", "udp":
+	default:
+		return fmt.Errorf("port mapping %s: invalid protocol %q", p, p.Protocol)
+	}
+	if p.LastHostPort() < p.HostPort || p.LastHostPort() > maxPort {
+		return fmt.Errorf("port mapping %s: invalid host port range", p)
+	}
+	if uint64(p.ContainerPort)+uint64(p.LastHostPort()-p.HostPort) > maxPort {
+		return fmt.Errorf("port mapping %s: container ports out of range", p)
+	}
+	return nil
+}
+
+func (p PortMapping) overlaps(other PortMapping) bool {
+	return p.ProtocolOrDefault() == other.ProtocolOrDefault() &&
+		p.HostPort <= other.LastHostPort() && other.HostPort <= p.LastHostPort()
+}
+
+// ValidatePortMappings checks each of the mappings, and that no host port of
+// a protocol is forwarded by more than one of them.
+func ValidatePortMappings(mappings []PortMapping) error {
+	for i, mapping := range mappings {
+		if err := mapping.validate(); err != nil {
+			return err
+		}
+		for _, previous := range mappings[:i] {
+			if mapping.overlaps(previous) {
+				return fmt.Errorf("port mappings %s and %s conflict", previous, mapping)
+			}
+		}
+	}
+	return nil
+}
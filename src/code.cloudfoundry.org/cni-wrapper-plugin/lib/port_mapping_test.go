@@ -0,0 +1,66 @@
+package lib_test
+
+import (
+	"encoding/json"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PortMapping", func() {
+	It("unmarshals the garden net in mappings as TCP ports", func() {
+		var mapping lib.PortMapping
+		Expect(json.Unmarshal([]byte(`{"host_port": 1000, "container_port": 8080}`), &mapping)).To(Succeed())
+		Expect(mapping).To(Equal(lib.PortMapping{HostPort: 1000, ContainerPort: 8080}))
+		Expect(mapping.ProtocolOrDefault()).To(Equal("tcp"))
+		Expect(mapping.LastHostPort()).To(Equal(uint32(1000)))
+	})
+
+	It("unmarshals ranges of UDP ports", func() {
+		var mapping lib.PortMapping
+		Expect(json.Unmarshal([]byte(`{"host_port": 1000, "host_port_end": 1010, "container_port": 5000, "protocol": "udp"}`), &mapping)).To(Succeed())
+		Expect(mapping.ProtocolOrDefault()).To(Equal("udp"))
+		Expect(mapping.LastHostPort()).To(Equal(uint32(1010)))
+		Expect(mapping.String()).To(Equal("udp 1000-1010:5000"))
+	})
+
+	It("unmarshals whether the mapping preserves the client ip", func() {
+		var mapping lib.PortMapping
+		Expect(json.Unmarshal([]byte(`{"host_port": 1000, "container_port": 8080, "preserve_client_ip": true}`), &mapping)).To(Succeed())
+		Expect(mapping.PreserveClientIP).To(BeTrue())
+	})
+})
+
+var _ = Describe("ValidatePortMappings", func() {
+	It("accepts mappings that do not share host ports", func() {
+		Expect(lib.ValidatePortMappings([]lib.PortMapping{
+			{HostPort: 1000, ContainerPort: 8080},
+			{HostPort: 1001, HostPortEnd: 1010, ContainerPort: 5000, Protocol: "udp"},
+			{HostPort: 1001, ContainerPort: 2222, Protocol: "tcp"},
+			{HostPort: 1011, HostPortEnd: 1020, ContainerPort: 6000, Protocol: "udp"},
+		})).To(Succeed())
+	})
+
+	DescribeTable("rejects invalid mappings",
+		func(mapping lib.PortMapping, expectedErr string) {
+			Expect(lib.ValidatePortMappings([]lib.PortMapping{mapping})).To(MatchError(expectedErr))
+		},
+		Entry("host port 0", lib.PortMapping{ContainerPort: 8080}, "cannot allocate port 0"),
+		Entry("unknown protocol", lib.PortMapping{HostPort: 1000, ContainerPort: 8080, Protocol: "sctp"}, `port mapping sctp 1000:8080: invalid protocol "sctp"`),
+		Entry("range that ends before it starts", lib.PortMapping{HostPort: 1000, HostPortEnd: 999, ContainerPort: 8080}, "port mapping tcp 1000-999:8080: invalid host port range"),
+		Entry("range past the last port", lib.PortMapping{HostPort: 65530, HostPortEnd: 65536, ContainerPort: 8080}, "port mapping tcp 65530-65536:8080: invalid host port range"),
+		Entry("container ports past the last port", lib.PortMapping{HostPort: 1000, HostPortEnd: 1010, ContainerPort: 65530}, "port mapping tcp 1000-1010:65530: container ports out of range"),
+	)
+
+	Context("when two mappings forward the same host port of a protocol", func() {
+		It("returns an error naming both", func() {
+			err := lib.ValidatePortMappings([]lib.PortMapping{
+				{HostPort: 1000, HostPortEnd: 1010, ContainerPort: 5000, Protocol: "udp"},
+				{HostPort: 1000, ContainerPort: 8080},
+				{HostPort: 1010, HostPortEnd: 1020, ContainerPort: 6000, Protocol: "udp"},
+			})
+			Expect(err).To(MatchError("port mappings udp 1000-1010:5000 and udp 1010-1020:6000 conflict"))
+		})
+	})
+})
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/adapter"
 	"code.cloudfoundry.org/cni-wrapper-plugin/lib"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lib/containerevents"
 	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/featureflags"
 	"code.cloudfoundry.org/lib/interfacelookup"
 	"code.cloudfoundry.org/lib/rules"
 	"code.cloudfoundry.org/lib/serial"
@@ -22,9 +26,12 @@ import (
 	"strconv"
 
 	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager/v3"
 	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/cni/pkg/version"
+	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/coreos/go-iptables/iptables"
 )
 
@@ -34,12 +41,38 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
+	var cniAddData struct {
+		Metadata map[string]interface{}
+	}
+	if err := json.Unmarshal(args.StdinData, &cniAddData); err != nil {
+		return err // not tested, this should be impossible
+	}
+
+	// the metadata and port mappings are checked before the container network
+	// is set up, so a rejected container leaves nothing behind
+	containerMetadata, err := datastore.NormalizeContainerMetadata(cniAddData.Metadata)
+	if err != nil {
+		return fmt.Errorf("container metadata: %s", err)
+	}
+	metadata, err := datastore.ParseContainerMetadata(containerMetadata)
+	if err != nil {
+		return fmt.Errorf("container metadata: %s", err) // not tested, normalized metadata parses
+	}
+	if err := lib.ValidatePortMappings(cfg.RuntimeConfig.PortMappings); err != nil {
+		return err
+	}
+
 	pluginController, err := newPluginController(cfg)
 	if err != nil {
 		return err
 	}
 
-	result, err := pluginController.DelegateAdd(cfg.Delegate)
+	var result types.Result
+	err = lib.RunPhase("delegate add", cfg.Timeouts.Delegate(), func(ctx context.Context) error {
+		var err error
+		result, err = pluginController.DelegateAdd(ctx, cfg.Delegate)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("delegate call: %s", err)
 	}
@@ -50,7 +83,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 	}
 
 	containerIP := resultActual.IPs[0].Address.IP
-	var containerWorkload string
+	containerWorkload := metadata.Workload
 
 	// Add container metadata info
 	store := &datastore.Store{
@@ -67,21 +100,16 @@ func cmdAdd(args *skel.CmdArgs) error {
 		CacheMutex:      new(sync.RWMutex),
 	}
 
-	var cniAddData struct {
-		Metadata map[string]interface{}
-	}
-	if err := json.Unmarshal(args.StdinData, &cniAddData); err != nil {
-		return err // not tested, this should be impossible
-	}
-	if workload, present := cniAddData.Metadata["container_workload"]; present {
-		containerWorkload, _ = workload.(string)
-	}
-
-	if err := store.Add(args.ContainerID, containerIP.String(), cniAddData.Metadata); err != nil {
+	err = lib.RunPhase("datastore add", cfg.Timeouts.Datastore(), func(context.Context) error {
+		return store.Add(args.ContainerID, containerIP.String(), containerMetadata)
+	})
+	if err != nil {
 		storeErr := fmt.Errorf("store add: %s", err)
 		fmt.Fprintf(os.Stderr, "%s", storeErr)
 		fmt.Fprint(os.Stderr, "cleaning up from error")
-		err = pluginController.DelIPMasq(containerIP.String(), cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
+		err = lib.RunPhase("iptables ip masq", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+			return pluginController.DelIPMasq(ctx, containerIP.String(), cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "during cleanup: removing IP masq: %s", err)
 		}
@@ -89,15 +117,21 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return storeErr
 	}
 
-	resp, err := http.DefaultClient.Get(fmt.Sprintf("http://%s/force-policy-poll-cycle", cfg.PolicyAgentForcePollAddress))
+	publishContainerEvent(cfg, containerevents.Event{Type: containerevents.TypeAdd, Handle: args.ContainerID, IP: containerIP.String()})
+
+	err = lib.RunPhase("policy agent poll", cfg.Timeouts.PolicyAgent(), func(ctx context.Context) error {
+		statusCode, body, err := getPolicyAgent(ctx, fmt.Sprintf("http://%s/force-policy-poll-cycle", cfg.PolicyAgentForcePollAddress))
+		if err != nil {
+			return err
+		}
+		if statusCode != http.StatusOK {
+			return fmt.Errorf("vpa response code: %v with message: %s", statusCode, body)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		return fmt.Errorf("vpa response code: %v with message: %s", resp.StatusCode, body)
-	}
 
 	localDNSServers, err := getLocalDNSServers(cfg.DNSServers)
 	if err != nil {
@@ -133,6 +167,13 @@ func cmdAdd(args *skel.CmdArgs) error {
 		DryRun:     cfg.OutConn.DryRun,
 	}
 
+	// the rules are validated on every ADD, whether garden or the policy
+	// agent writes them, so that both paths reject the same rules
+	netOutRules, err := netrules.ValidateGardenNetOutRules(cfg.RuntimeConfig.NetOutRules, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("validate net out rules: %s", err)
+	}
+
 	netOutChain := &netrules.NetOutChain{
 		ChainNamer:       chainNamer,
 		Converter:        &netrules.RuleConverter{LogWriter: os.Stderr},
@@ -143,31 +184,95 @@ func cmdAdd(args *skel.CmdArgs) error {
 			Running: cfg.DenyNetworks.Running,
 			Staging: cfg.DenyNetworks.Staging,
 		},
-		Conn: outConn,
+		Conn:                     outConn,
+		RejectTCPWithReset:       cfg.RejectTCPWithReset,
+		DeniedLogsPerDestination: cfg.IPTablesDeniedLogsPerDest,
+		RelatedEstablished:       cfg.RelatedEstablished.NetRules(),
+		SNIInspection:            cfg.SNIInspection.NetRules(),
+	}
+
+	c2cLogging := cfg.IPTablesC2CLogging
+	if cfg.FeatureFlags.File != "" {
+		// a broken flags file leaves the features at their configured
+		// values instead of failing the container
+		flags, err := featureflags.New(lager.NewLogger("cni-wrapper-plugin"), cfg.FeatureFlags.File, cfg.FeatureFlags.CellID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "feature flags: %s", err)
+		}
+		netOutChain.FeatureFlags = flags
+		c2cLogging = flags.Enabled(featureflags.IPTablesC2CLogging, c2cLogging)
+	}
+
+	uidExemptions, err := newUIDExemptions(cfg)
+	if err != nil {
+		return err
 	}
 
+	hostServices, err := lib.ReadHostServiceRegistry(
+		cfg.HostServicesRegistryDir,
+		lib.HostServices{TCP: cfg.HostTCPServices, UDP: cfg.HostUDPServices},
+		os.Stderr,
+	)
+	if err != nil {
+		return err
+	}
+
+	chainOwners := newChainOwners(cfg)
+	chainBatch := newChainBatch(cfg)
 	netOutProvider := netrules.NetOut{
-		ChainNamer:            chainNamer,
-		IPTables:              pluginController.IPTables,
-		NetOutChain:           netOutChain,
-		C2CLogging:            cfg.IPTablesC2CLogging,
-		DeniedLogsPerSec:      cfg.IPTablesDeniedLogsPerSec,
-		AcceptedUDPLogsPerSec: cfg.IPTablesAcceptedUDPLogsPerSec,
-		IngressTag:            cfg.IngressTag,
-		VTEPName:              cfg.VTEPName,
-		HostInterfaceNames:    interfaceNames,
-		ContainerHandle:       args.ContainerID,
-		ContainerWorkload:     containerWorkload,
-		ContainerIP:           containerIP.String(),
-		HostTCPServices:       cfg.HostTCPServices,
-		HostUDPServices:       cfg.HostUDPServices,
-		DNSServers:            localDNSServers,
-		Conn:                  outConn,
-	}
-	if err := netOutProvider.Initialize(); err != nil {
+		ChainNamer:             chainNamer,
+		IPTables:               pluginController.IPTables,
+		NetOutChain:            netOutChain,
+		C2CLogging:             c2cLogging,
+		DeniedLogsPerSec:       cfg.IPTablesDeniedLogsPerSec,
+		AcceptedUDPLogsPerSec:  cfg.IPTablesAcceptedUDPLogsPerSec,
+		IngressTag:             cfg.IngressTag,
+		VTEPName:               cfg.VTEPName,
+		HostInterfaceNames:     interfaceNames,
+		ContainerHandle:        args.ContainerID,
+		ContainerInstanceIndex: datastore.InstanceIndex(cniAddData.Metadata),
+		ContainerWorkload:      containerWorkload,
+		ContainerIP:            containerIP.String(),
+		HostTCPServices:        hostServices.TCP,
+		HostUDPServices:        hostServices.UDP,
+		DNSServers:             localDNSServers,
+		Conn:                   outConn,
+		ChainOwners:            chainOwners,
+		UIDExemptions:          uidExemptions,
+		ChainBatch:             chainBatch,
+	}
+	err = lib.RunPhase("iptables net out", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+		return netOutProvider.WithContext(ctx).Initialize()
+	})
+	if err != nil {
 		return fmt.Errorf("initialize net out: %s", err)
 	}
 
+	if len(uidExemptions) > 0 {
+		err = lib.RunPhase("iptables uid exemptions", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+			return markUIDExemptions(ctx, args.Netns, cfg, uidExemptions)
+		})
+		if err != nil {
+			return fmt.Errorf("mark uid exemptions: %s", err)
+		}
+	}
+
+	// containers of egress proxy spaces start with only the proxy rules, so
+	// they never have the egress of their ASGs
+	egressProxyOnly := cfg.EgressProxy.IncludesSpace(metadata.SpaceID)
+	if egressProxyOnly {
+		proxyRules, err := netrules.NewRulesFromSecurityGroupRules(cfg.EgressProxy.SecurityGroupRules())
+		if err != nil {
+			return fmt.Errorf("egress proxy rules: %s", err)
+		}
+		err = lib.RunPhase("iptables egress proxy", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+			return netOutProvider.WithContext(ctx).BulkInsertRules(proxyRules)
+		})
+		if err != nil {
+			return fmt.Errorf("bulk insert egress proxy rules: %s", err)
+		}
+	}
+
 	netinProvider := netrules.NetIn{
 		ChainNamer: &netrules.ChainNamer{
 			MaxLength: 28,
@@ -175,41 +280,71 @@ func cmdAdd(args *skel.CmdArgs) error {
 		IPTables:           pluginController.IPTables,
 		IngressTag:         cfg.IngressTag,
 		HostInterfaceNames: interfaceNames,
+		ChainOwners:        chainOwners,
+		ChainBatch:         chainBatch,
 	}
-	err = netinProvider.Initialize(args.ContainerID)
+	err = lib.RunPhase("iptables net in", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+		netinProvider := netinProvider.WithContext(ctx)
+		err := netinProvider.Initialize(args.ContainerID)
+		if err != nil {
+			return fmt.Errorf("initializing net in: %s", err)
+		}
+
+		portMappings := cfg.RuntimeConfig.PortMappings
+		for _, netIn := range portMappings {
+			if err := netinProvider.AddPortRangeRule(args.ContainerID, netIn.ProtocolOrDefault(), int(netIn.HostPort), int(netIn.LastHostPort()), int(netIn.ContainerPort), cfg.InstanceAddress, containerIP.String()); err != nil {
+				return fmt.Errorf("adding netin rule: %s", err)
+			}
+			if netIn.PreserveClientIP {
+				if err := netinProvider.PreserveClientIP(args.ContainerID, netIn.ProtocolOrDefault(), int(netIn.HostPort), int(netIn.LastHostPort()), cfg.InstanceAddress, containerIP.String()); err != nil {
+					return fmt.Errorf("preserving client ip: %s", err)
+				}
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("initializing net in: %s", err)
+		return err
 	}
 
-	portMappings := cfg.RuntimeConfig.PortMappings
-	for _, netIn := range portMappings {
-		if netIn.HostPort <= 0 {
-			return fmt.Errorf("cannot allocate port %d", netIn.HostPort)
+	var asgStatusCode int
+	err = lib.RunPhase("policy agent asg sync", cfg.Timeouts.PolicyAgent(), func(ctx context.Context) error {
+		var body []byte
+		var err error
+		asgStatusCode, body, err = getPolicyAgent(ctx, fmt.Sprintf("http://%s/force-asgs-for-container?container=%s", cfg.PolicyAgentForcePollAddress, args.ContainerID))
+		if err != nil {
+			return err
 		}
-		if err := netinProvider.AddRule(args.ContainerID, int(netIn.HostPort), int(netIn.ContainerPort), cfg.InstanceAddress, containerIP.String()); err != nil {
-			return fmt.Errorf("adding netin rule: %s", err)
+		if asgStatusCode != http.StatusOK && asgStatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("asg sync returned %v with message: %s", asgStatusCode, body)
 		}
-	}
-
-	resp, err = http.DefaultClient.Get(fmt.Sprintf("http://%s/force-asgs-for-container?container=%s", cfg.PolicyAgentForcePollAddress, args.ContainerID))
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode == http.StatusMethodNotAllowed {
-		netOutRules := cfg.RuntimeConfig.NetOutRules
-		if err := netOutProvider.BulkInsertRules(netrules.NewRulesFromGardenNetOutRules(netOutRules)); err != nil {
+	if asgStatusCode == http.StatusMethodNotAllowed && !egressProxyOnly {
+		err = lib.RunPhase("iptables net out rules", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+			return netOutProvider.WithContext(ctx).BulkInsertRules(netrules.NewRulesFromGardenNetOutRules(netOutRules))
+		})
+		if err != nil {
 			return fmt.Errorf("bulk insert: %s", err) // not tested
 		}
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMethodNotAllowed {
-		body, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		return fmt.Errorf("asg sync returned %v with message: %s", resp.StatusCode, body)
+	// the sni inspection rules go on top of all others, since the ClientHello
+	// is sent on an established connection
+	err = lib.RunPhase("iptables sni inspection", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+		return netOutProvider.WithContext(ctx).InsertSNIRules(metadata.SpaceID)
+	})
+	if err != nil {
+		return fmt.Errorf("insert sni inspection rules: %s", err)
 	}
 
-	err = pluginController.AddIPMasq(containerIP.String(), cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
+	err = lib.RunPhase("iptables ip masq", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+		return pluginController.AddIPMasq(ctx, containerIP.String(), cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
+	})
 	if err != nil {
 		return fmt.Errorf("error setting up default ip masq rule: %s", err)
 	}
@@ -257,9 +392,16 @@ func cmdDel(args *skel.CmdArgs) error {
 		CacheMutex:      new(sync.RWMutex),
 	}
 
-	container, err := store.Delete(args.ContainerID)
+	// the entry is deleted last, so that a DEL that is retried after it was
+	// interrupted still knows the IP of the rules it has to remove
+	var container datastore.Container
+	err = lib.RunPhase("datastore read", cfg.Timeouts.Datastore(), func(context.Context) error {
+		containers, err := store.ReadAll()
+		container = containers[args.ContainerID]
+		return err
+	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "store delete: %s", err)
+		fmt.Fprintf(os.Stderr, "store read: %s", err)
 	}
 
 	pluginController, err := newPluginController(cfg)
@@ -267,19 +409,27 @@ func cmdDel(args *skel.CmdArgs) error {
 		return err
 	}
 
-	if err := pluginController.DelegateDel(cfg.Delegate); err != nil {
+	err = lib.RunPhase("delegate delete", cfg.Timeouts.Delegate(), func(ctx context.Context) error {
+		return pluginController.DelegateDel(ctx, cfg.Delegate)
+	})
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "delegate delete: %s", err)
 	}
 
+	chainOwners := newChainOwners(cfg)
 	netInProvider := netrules.NetIn{
 		ChainNamer: &netrules.ChainNamer{
 			MaxLength: 28,
 		},
-		IPTables:   pluginController.IPTables,
-		IngressTag: cfg.IngressTag,
+		IPTables:    pluginController.IPTables,
+		IngressTag:  cfg.IngressTag,
+		ChainOwners: chainOwners,
 	}
 
-	if err = netInProvider.Cleanup(args.ContainerID); err != nil {
+	err = lib.RunPhase("iptables net in cleanup", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+		return netInProvider.WithContext(ctx).Cleanup(args.ContainerID)
+	})
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "net in cleanup: %s", err)
 	}
 
@@ -312,6 +462,13 @@ func cmdDel(args *skel.CmdArgs) error {
 		Conn:       outConn,
 	}
 
+	// the exemptions only decide whether their chain is cleaned up, so a
+	// config that fails to convert still cleans up the other chains
+	uidExemptions, err := newUIDExemptions(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "uid exemptions: %s", err)
+	}
+
 	netOutProvider := netrules.NetOut{
 		ChainNamer:         chainNamer,
 		NetOutChain:        netOutChain,
@@ -320,28 +477,78 @@ func cmdDel(args *skel.CmdArgs) error {
 		ContainerIP:        container.IP,
 		HostInterfaceNames: interfaceNames,
 		Conn:               outConn,
+		ChainOwners:        chainOwners,
+		UIDExemptions:      uidExemptions,
 	}
 
-	if err = netOutProvider.Cleanup(); err != nil {
+	err = lib.RunPhase("iptables net out cleanup", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+		return netOutProvider.WithContext(ctx).Cleanup()
+	})
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "net out cleanup: %s", err)
 	}
 
-	err = pluginController.DelIPMasq(container.IP, cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "removing IP masq: %s", err)
+	// without an entry, an earlier DEL already removed the rule of the IP
+	if container.IP != "" {
+		err = lib.RunPhase("iptables ip masq", cfg.Timeouts.IPTables(), func(ctx context.Context) error {
+			return pluginController.DelIPMasq(ctx, container.IP, cfg.NoMasqueradeCIDRRange, cfg.VTEPName)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "removing IP masq: %s", err)
+		}
 	}
 
-	resp, err := http.DefaultClient.Get(fmt.Sprintf("http://%s/force-orphaned-asgs-cleanup?container=%s", cfg.PolicyAgentForcePollAddress, args.ContainerID))
-	if err != nil {
+	err = lib.RunPhase("datastore delete", cfg.Timeouts.Datastore(), func(context.Context) error {
+		_, err := store.Delete(args.ContainerID)
 		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "store delete: %s", err)
 	}
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMethodNotAllowed {
-		body, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		return fmt.Errorf("asg cleanup returned %v with message: %s", resp.StatusCode, body)
+	publishContainerEvent(cfg, containerevents.Event{Type: containerevents.TypeDel, Handle: args.ContainerID, IP: container.IP})
+
+	return lib.RunPhase("policy agent asg cleanup", cfg.Timeouts.PolicyAgent(), func(ctx context.Context) error {
+		statusCode, body, err := getPolicyAgent(ctx, fmt.Sprintf("http://%s/force-orphaned-asgs-cleanup?container=%s", cfg.PolicyAgentForcePollAddress, args.ContainerID))
+		if err != nil {
+			return err
+		}
+		if statusCode != http.StatusOK && statusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("asg cleanup returned %v with message: %s", statusCode, body)
+		}
+		return nil
+	})
+}
+
+// publishContainerEvent tells the components of the cell about the change
+// of the datastore. They read the datastore again on their own if they miss
+// it, so a failure does not fail the call.
+func publishContainerEvent(cfg *lib.WrapperConfig, event containerevents.Event) {
+	if cfg.ContainerEventsDirectory == "" {
+		return
+	}
+	publisher := &containerevents.Publisher{Directory: cfg.ContainerEventsDirectory}
+	if err := publisher.Publish(event); err != nil {
+		fmt.Fprintf(os.Stderr, "container events: %s", err)
 	}
+}
 
-	return nil
+// getPolicyAgent calls the policy agent and reads the response within the
+// context of the phase, which aborts a request to a hung policy agent.
+func getPolicyAgent(ctx context.Context, url string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, err // not tested
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
 }
 
 func ensureIptablesFileOwnership(filePath, fileOwner, fileGroup string) error {
@@ -390,6 +597,78 @@ func lookupFileOwnerUIDandGID(fileOwner, fileGroup string) (int, int, error) {
 	return uid, gid, nil
 }
 
+// newChainOwners opens the registry the policy agent and the silk daemon use
+// to record the chains they own. The chains of a container are held until
+// the container is deleted, so the claims do not expire.
+func newChainOwners(cfg *lib.WrapperConfig) *datastore.ChainOwners {
+	chainOwnersFile := datastore.ChainOwnersFilePath(cfg.Datastore)
+	return &datastore.ChainOwners{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(chainOwnersFile + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath: chainOwnersFile,
+	}
+}
+
+// newChainBatch returns the batch the ADD creates its chains through, or nil
+// if the chains of every ADD are created on their own.
+func newChainBatch(cfg *lib.WrapperConfig) *netrules.ChainBatch {
+	if cfg.ChainBatch.Dir == "" {
+		return nil
+	}
+	return &netrules.ChainBatch{
+		Dir:    cfg.ChainBatch.Dir,
+		Window: cfg.ChainBatch.Window(),
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(cfg.ChainBatch.Dir + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		Now: time.Now,
+	}
+}
+
+func newUIDExemptions(cfg *lib.WrapperConfig) ([]netrules.UIDExemption, error) {
+	exemptions := []netrules.UIDExemption{}
+	for _, exemption := range cfg.UIDExemptions {
+		ruleSpec, err := netrules.NewRulesFromSecurityGroupRules(exemption.SecurityGroupRules())
+		if err != nil {
+			return nil, fmt.Errorf("uid exemption for uid %d: %s", exemption.UID, err)
+		}
+		exemptions = append(exemptions, netrules.UIDExemption{
+			UID:    exemption.UID,
+			DSCP:   exemption.DSCP,
+			Bypass: exemption.Bypass,
+			Rules:  ruleSpec,
+		})
+	}
+	return exemptions, nil
+}
+
+// markUIDExemptions writes the rules that mark the packets of the exempted
+// users into the network namespace of the container, where the owner of a
+// packet is known. The namespace is deleted with the container, so they are
+// never cleaned up.
+func markUIDExemptions(ctx context.Context, netnsPath string, cfg *lib.WrapperConfig, exemptions []netrules.UIDExemption) error {
+	return ns.WithNetNSPath(netnsPath, func(ns.NetNS) error {
+		ipt, err := iptables.New()
+		if err != nil {
+			return err
+		}
+
+		containerIPTables := &rules.LockedIPTables{
+			IPTables: ipt,
+			Locker: &filelock.Locker{
+				FileLocker: filelock.NewLocker(cfg.IPTablesLockFile),
+				Mutex:      &sync.Mutex{},
+			},
+			Restorer: &rules.Restorer{},
+		}
+		return netrules.MarkUIDExemptions(containerIPTables.WithContext(ctx), exemptions)
+	})
+}
+
 func newPluginController(config *lib.WrapperConfig) (*lib.PluginController, error) {
 	ipt, err := iptables.New()
 	if err != nil {
@@ -412,9 +691,15 @@ func newPluginController(config *lib.WrapperConfig) (*lib.PluginController, erro
 		Restorer: restorer,
 	}
 
+	var pluginIPTables rules.IPTablesAdapter = lockedIPTables
+	logLimit := rules.LogLimit{PerSecond: config.IPTablesCellLogsPerSec, Burst: config.IPTablesCellLogsBurst}
+	if logLimit.Enabled() {
+		pluginIPTables = &rules.LogLimitedIPTables{IPTablesAdapter: lockedIPTables, Limit: logLimit}
+	}
+
 	pluginController := &lib.PluginController{
 		Delegator: lib.NewDelegator(),
-		IPTables:  lockedIPTables,
+		IPTables:  pluginIPTables,
 	}
 	return pluginController, nil
 }
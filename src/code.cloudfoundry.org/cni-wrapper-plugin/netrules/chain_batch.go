@@ -0,0 +1,224 @@
+package netrules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// batchStaleAfter is the age after which a queued request or a result is
+// taken to belong to an ADD that gave up, e.g. because its phase timed out.
+const batchStaleAfter = time.Minute
+
+//go:generate counterfeiter -o ../fakes/chain_batch.go --fake-name ChainBatch . chainBatch
+type chainBatch interface {
+	InitChains(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error
+}
+
+type locker interface {
+	Lock() error
+	Unlock() error
+}
+
+// ChainBatch lets the ADDs of containers that start together create their
+// chains in one iptables-restore. Every ADD queues its chains in Dir and then
+// takes the lock. The first to take it leads: it waits Window for the others
+// to queue theirs, creates the chains of all of them and leaves each of them
+// its result. The others find their result when they get the lock. If the
+// leader dies the lock is released and the next ADD leads.
+type ChainBatch struct {
+	Dir    string
+	Window time.Duration
+	Locker locker
+	Now    func() time.Time
+}
+
+type batchRequest struct {
+	QueuedAt time.Time           `json:"queued_at"`
+	Chains   []IpTablesFullChain `json:"chains"`
+}
+
+type batchResult struct {
+	Error string `json:"error,omitempty"`
+}
+
+// InitChains creates the chains of a container, with the chains of the
+// containers whose ADDs are queued with it, using the iptables of the ADD
+// that leads. A nil batch creates the chains on their own.
+func (b *ChainBatch) InitChains(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
+	if b == nil {
+		return initChains(iptables, fullRules)
+	}
+
+	id, err := b.queue(fullRules)
+	if err != nil {
+		return fmt.Errorf("queue chains: %s", err)
+	}
+
+	if err := b.Locker.Lock(); err != nil {
+		b.remove(requestName(id))
+		return fmt.Errorf("lock chain batch: %s", err)
+	}
+
+	result, found, err := b.result(id)
+	if err == nil && !found {
+		time.Sleep(b.Window)
+		err = b.lead(iptables)
+		if err == nil {
+			result, found, err = b.result(id)
+		}
+		if err == nil && !found {
+			err = errors.New("chains were not created")
+		}
+	}
+
+	unlockErr := b.Locker.Unlock()
+	if err != nil {
+		b.remove(requestName(id))
+		return fmt.Errorf("chain batch: %s", err)
+	}
+	if unlockErr != nil {
+		return fmt.Errorf("unlock chain batch: %s", unlockErr)
+	}
+	if result.Error != "" {
+		return errors.New(result.Error)
+	}
+	return nil
+}
+
+func (b *ChainBatch) queue(fullRules []IpTablesFullChain) (string, error) {
+	contents, err := json.Marshal(batchRequest{QueuedAt: b.Now(), Chains: fullRules})
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(b.Dir, 0700); err != nil {
+		return "", err
+	}
+
+	id := fmt.Sprintf("%d-%d", os.Getpid(), b.Now().UnixNano())
+	tmp := filepath.Join(b.Dir, "."+requestName(id))
+	if err := os.WriteFile(tmp, contents, 0600); err != nil {
+		return "", err
+	}
+	return id, os.Rename(tmp, filepath.Join(b.Dir, requestName(id)))
+}
+
+// result reads and removes the result of a request, if the request was
+// taken by a leader.
+func (b *ChainBatch) result(id string) (batchResult, bool, error) {
+	path := filepath.Join(b.Dir, resultName(id))
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return batchResult{}, false, nil
+	}
+	if err != nil {
+		return batchResult{}, false, fmt.Errorf("reading result: %s", err)
+	}
+	b.remove(resultName(id))
+
+	var result batchResult
+	if err := json.Unmarshal(contents, &result); err != nil {
+		return batchResult{}, false, fmt.Errorf("reading result: %s", err)
+	}
+	return result, true, nil
+}
+
+// lead creates the chains of every queued request and leaves each of them
+// the result. A chain queued by more than one request, e.g. by a retried ADD,
+// is created once with the chain of the last of them.
+func (b *ChainBatch) lead(iptables rules.IPTablesAdapter) error {
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return fmt.Errorf("listing requests: %s", err)
+	}
+
+	ids := []string{}
+	specs := []rules.ChainSpec{}
+	index := map[string]int{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "result-") {
+			if info, err := entry.Info(); err == nil && b.Now().Sub(info.ModTime()) > batchStaleAfter {
+				b.remove(name)
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, "request-") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		var request batchRequest
+		contents, err := os.ReadFile(filepath.Join(b.Dir, name))
+		if err == nil {
+			err = json.Unmarshal(contents, &request)
+		}
+		if err != nil || b.Now().Sub(request.QueuedAt) > batchStaleAfter {
+			b.remove(name)
+			continue
+		}
+
+		ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(name, "request-"), ".json"))
+		for _, chain := range request.Chains {
+			spec := rules.ChainSpec{
+				Table:       chain.Table,
+				ParentChain: chain.ParentChain,
+				Chain:       chain.ChainName,
+				Rules:       chain.Rules,
+			}
+			if chain.ParentChain != "" {
+				spec.Jumps = chain.JumpConditions
+			}
+
+			key := chain.Table + "/" + chain.ChainName
+			if i, ok := index[key]; ok {
+				specs[i] = spec
+				continue
+			}
+			index[key] = len(specs)
+			specs = append(specs, spec)
+		}
+	}
+
+	result := batchResult{}
+	if err := iptables.ReplaceChains(specs...); err != nil {
+		result.Error = fmt.Sprintf("creating chains: %s", err)
+	}
+	contents, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := os.WriteFile(filepath.Join(b.Dir, resultName(id)), contents, 0600); err != nil {
+			return fmt.Errorf("writing result: %s", err)
+		}
+		b.remove(requestName(id))
+	}
+	return nil
+}
+
+func (b *ChainBatch) remove(name string) {
+	_ = os.Remove(filepath.Join(b.Dir, name))
+}
+
+func requestName(id string) string {
+	return "request-" + id + ".json"
+}
+
+func resultName(id string) string {
+	return "result-" + id + ".json"
+}
+
+// initChainsWith creates the chains through the batch, if there is one.
+func initChainsWith(batch chainBatch, iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
+	if batch == nil {
+		return initChains(iptables, fullRules)
+	}
+	return batch.InitChains(iptables, fullRules)
+}
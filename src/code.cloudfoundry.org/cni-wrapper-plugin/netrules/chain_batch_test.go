@@ -0,0 +1,182 @@
+package netrules_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/filelock"
+	lib_fakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChainBatch", func() {
+	var (
+		dir      string
+		lockPath string
+		ipTables *lib_fakes.IPTablesAdapter
+		newBatch func() *netrules.ChainBatch
+	)
+
+	fullRules := func(chain string) []netrules.IpTablesFullChain {
+		return []netrules.IpTablesFullChain{{
+			Table:          "filter",
+			ParentChain:    "FORWARD",
+			ChainName:      chain,
+			JumpConditions: []rules.IPTablesRule{{"--jump", chain}},
+			Rules:          []rules.IPTablesRule{{"-j", "ACCEPT"}},
+		}}
+	}
+
+	requests := func() []string {
+		matches, err := filepath.Glob(filepath.Join(dir, "request-*.json"))
+		Expect(err).NotTo(HaveOccurred())
+		return matches
+	}
+
+	BeforeEach(func() {
+		tempDir := GinkgoT().TempDir()
+		dir = filepath.Join(tempDir, "chain-batch")
+		lockPath = filepath.Join(tempDir, "chain-batch_lock")
+		ipTables = &lib_fakes.IPTablesAdapter{}
+		newBatch = func() *netrules.ChainBatch {
+			return &netrules.ChainBatch{
+				Dir:    dir,
+				Window: 10 * time.Millisecond,
+				Locker: &filelock.Locker{
+					FileLocker: filelock.NewLocker(lockPath),
+					Mutex:      new(sync.Mutex),
+				},
+				Now: time.Now,
+			}
+		}
+	})
+
+	It("creates the chains of a lone ADD and leaves nothing behind", func() {
+		Expect(newBatch().InitChains(ipTables, fullRules("netout-1"))).To(Succeed())
+
+		Expect(ipTables.ReplaceChainsCallCount()).To(Equal(1))
+		Expect(ipTables.ReplaceChainsArgsForCall(0)).To(Equal([]rules.ChainSpec{{
+			Table:       "filter",
+			ParentChain: "FORWARD",
+			Chain:       "netout-1",
+			Jumps:       []rules.IPTablesRule{{"--jump", "netout-1"}},
+			Rules:       []rules.IPTablesRule{{"-j", "ACCEPT"}},
+		}}))
+
+		entries, err := os.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("creates the chains of the ADDs that queue together in one call", func() {
+		holder := newBatch().Locker
+		Expect(holder.Lock()).To(Succeed())
+
+		errs := make(chan error, 3)
+		for _, chain := range []string{"netout-1", "netout-2", "netout-3"} {
+			go func(chain string) {
+				defer GinkgoRecover()
+				errs <- newBatch().InitChains(ipTables, fullRules(chain))
+			}(chain)
+		}
+		Eventually(requests).Should(HaveLen(3))
+		Expect(holder.Unlock()).To(Succeed())
+
+		for i := 0; i < 3; i++ {
+			Eventually(errs).Should(Receive(BeNil()))
+		}
+		Expect(ipTables.ReplaceChainsCallCount()).To(Equal(1))
+		chains := []string{}
+		for _, spec := range ipTables.ReplaceChainsArgsForCall(0) {
+			chains = append(chains, spec.Chain)
+		}
+		Expect(chains).To(ConsistOf("netout-1", "netout-2", "netout-3"))
+		Expect(requests()).To(BeEmpty())
+	})
+
+	It("creates a chain queued by a retried ADD once", func() {
+		holder := newBatch().Locker
+		Expect(holder.Lock()).To(Succeed())
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				defer GinkgoRecover()
+				errs <- newBatch().InitChains(ipTables, fullRules("netout-1"))
+			}()
+		}
+		Eventually(requests).Should(HaveLen(2))
+		Expect(holder.Unlock()).To(Succeed())
+
+		Eventually(errs).Should(Receive(BeNil()))
+		Eventually(errs).Should(Receive(BeNil()))
+		Expect(ipTables.ReplaceChainsCallCount()).To(Equal(1))
+		Expect(ipTables.ReplaceChainsArgsForCall(0)).To(HaveLen(1))
+	})
+
+	It("drops the requests of ADDs that gave up", func() {
+		Expect(os.MkdirAll(dir, 0700)).To(Succeed())
+		stale := []byte(`{"queued_at":"2020-01-01T00:00:00Z","chains":[{"Table":"filter","ChainName":"netout-stale"}]}`)
+		Expect(os.WriteFile(filepath.Join(dir, "request-1-1.json"), stale, 0600)).To(Succeed())
+
+		Expect(newBatch().InitChains(ipTables, fullRules("netout-1"))).To(Succeed())
+
+		Expect(ipTables.ReplaceChainsArgsForCall(0)).To(HaveLen(1))
+		Expect(ipTables.ReplaceChainsArgsForCall(0)[0].Chain).To(Equal("netout-1"))
+		Expect(requests()).To(BeEmpty())
+	})
+
+	Context("when creating the chains fails", func() {
+		BeforeEach(func() {
+			ipTables.ReplaceChainsReturns(errors.New("potato"))
+		})
+
+		It("returns the error to every ADD of the batch", func() {
+			holder := newBatch().Locker
+			Expect(holder.Lock()).To(Succeed())
+
+			errs := make(chan error, 2)
+			for _, chain := range []string{"netout-1", "netout-2"} {
+				go func(chain string) {
+					defer GinkgoRecover()
+					errs <- newBatch().InitChains(ipTables, fullRules(chain))
+				}(chain)
+			}
+			Eventually(requests).Should(HaveLen(2))
+			Expect(holder.Unlock()).To(Succeed())
+
+			Eventually(errs).Should(Receive(MatchError("creating chains: potato")))
+			Eventually(errs).Should(Receive(MatchError("creating chains: potato")))
+		})
+	})
+
+	Context("when the batch is nil", func() {
+		It("creates the chains on their own", func() {
+			var batch *netrules.ChainBatch
+			Expect(batch.InitChains(ipTables, fullRules("netout-1"))).To(Succeed())
+			Expect(ipTables.ReplaceChainCallCount()).To(Equal(1))
+			Expect(ipTables.ReplaceChainsCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the lock cannot be taken", func() {
+		It("returns an error and withdraws the request", func() {
+			locker := &lib_fakes.Locker{}
+			locker.LockReturns(errors.New("potato"))
+			batch := newBatch()
+			batch.Locker = locker
+
+			err := batch.InitChains(ipTables, fullRules("netout-1"))
+			Expect(err).To(MatchError("lock chain batch: potato"))
+			Expect(requests()).To(BeEmpty())
+			Expect(ipTables.ReplaceChainsCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -3,6 +3,8 @@ package netrules
 import (
 	"errors"
 	"fmt"
+
+	"code.cloudfoundry.org/lib/datastore"
 )
 
 //go:generate counterfeiter -o ../fakes/chain_namer.go --fake-name ChainNamer . chainNamer
@@ -33,3 +35,57 @@ func (n *ChainNamer) Postfix(body, suffix string) (string, error) {
 	}
 	return fmt.Sprintf("%s--%s", n.truncate(body, newBodyLen), suffix), nil
 }
+
+// ContainerChains are the names of the per-container chains that the wrapper
+// plugin creates.
+type ContainerChains struct {
+	Input               string
+	NetOut              string
+	NetOutLog           string
+	NetOutRateLimitLog  string
+	NetOutUIDExemptions string
+	Overlay             string
+	NetIn               string
+}
+
+func ContainerChainNames(namer chainNamer, containerHandle string) (ContainerChains, error) {
+	netOut := namer.Prefix(prefixNetOut, containerHandle)
+	netOutLog, err := namer.Postfix(netOut, suffixNetOutLog)
+	if err != nil {
+		return ContainerChains{}, fmt.Errorf("getting chain name: %s", err)
+	}
+	netOutRateLimitLog, err := namer.Postfix(netOut, suffixNetOutRateLimitLog)
+	if err != nil {
+		return ContainerChains{}, fmt.Errorf("getting chain name: %s", err)
+	}
+
+	netOutUIDExemptions, err := namer.Postfix(netOut, suffixNetOutUIDExemptions)
+	if err != nil {
+		return ContainerChains{}, fmt.Errorf("getting chain name: %s", err)
+	}
+
+	return ContainerChains{
+		Input:               namer.Prefix(prefixInput, containerHandle),
+		NetOut:              netOut,
+		NetOutLog:           netOutLog,
+		NetOutRateLimitLog:  netOutRateLimitLog,
+		NetOutUIDExemptions: netOutUIDExemptions,
+		Overlay:             namer.Prefix(prefixOverlay, containerHandle),
+		NetIn:               namer.Prefix(prefixNetIn, containerHandle),
+	}, nil
+}
+
+// Tables lists the chains with the tables the wrapper plugin creates them in.
+// The net-in chain exists in both the nat and the mangle table.
+func (c ContainerChains) Tables() []datastore.OwnedChain {
+	return []datastore.OwnedChain{
+		{Table: "filter", Chain: c.Input},
+		{Table: "filter", Chain: c.NetOut},
+		{Table: "filter", Chain: c.NetOutLog},
+		{Table: "filter", Chain: c.NetOutRateLimitLog},
+		{Table: "filter", Chain: c.NetOutUIDExemptions},
+		{Table: "filter", Chain: c.Overlay},
+		{Table: "nat", Chain: c.NetIn},
+		{Table: "mangle", Chain: c.NetIn},
+	}
+}
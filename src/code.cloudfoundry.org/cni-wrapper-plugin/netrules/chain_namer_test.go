@@ -2,6 +2,7 @@ package netrules_test
 
 import (
 	. "code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lib/datastore"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -47,4 +48,35 @@ var _ = Describe("ChainNamer", func() {
 			Expect(err).To(MatchError("suffix too long, string could not be truncated to max length"))
 		})
 	})
+
+	Describe("ContainerChainNames", func() {
+		It("names the chains of a container", func() {
+			chains, err := ContainerChainNames(namer, "a-very-long-container-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chains).To(Equal(ContainerChains{
+				Input:               "input--a-very-long-container",
+				NetOut:              "netout--a-very-long-containe",
+				NetOutLog:           "netout--a-very-long-con--log",
+				NetOutRateLimitLog:  "netout--a-very-long---rl-log",
+				NetOutUIDExemptions: "netout--a-very-long-con--uid",
+				Overlay:             "overlay--a-very-long-contain",
+				NetIn:               "netin--a-very-long-container",
+			}))
+		})
+
+		It("lists the chains with their tables", func() {
+			chains, err := ContainerChainNames(namer, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chains.Tables()).To(Equal([]datastore.OwnedChain{
+				{Table: "filter", Chain: "input--some-handle"},
+				{Table: "filter", Chain: "netout--some-handle"},
+				{Table: "filter", Chain: "netout--some-handle--log"},
+				{Table: "filter", Chain: "netout--some-handle--rl-log"},
+				{Table: "filter", Chain: "netout--some-handle--uid"},
+				{Table: "filter", Chain: "overlay--some-handle"},
+				{Table: "nat", Chain: "netin--some-handle"},
+				{Table: "mangle", Chain: "netin--some-handle"},
+			}))
+		})
+	})
 })
@@ -3,20 +3,70 @@ package netrules
 import (
 	"fmt"
 
+	"code.cloudfoundry.org/lib/datastore"
 	"code.cloudfoundry.org/lib/rules"
 
 	multierror "github.com/hashicorp/go-multierror"
 )
 
+// ChainOwner is recorded in the chain ownership registry for the chains the
+// wrapper creates for a container.
+const ChainOwner = "cni-wrapper-plugin"
+
+//go:generate counterfeiter -o ../fakes/chain_owners.go --fake-name ChainOwners . chainOwners
+type chainOwners interface {
+	Claim(owner string, chains ...datastore.OwnedChain) error
+	Release(owner string, chains ...datastore.OwnedChain) error
+}
+
+func ownedChains(fullRules []IpTablesFullChain) []datastore.OwnedChain {
+	seen := map[datastore.OwnedChain]bool{}
+	owned := []datastore.OwnedChain{}
+	for _, rule := range fullRules {
+		chain := datastore.OwnedChain{Table: rule.Table, Chain: rule.ChainName}
+		if !seen[chain] {
+			seen[chain] = true
+			owned = append(owned, chain)
+		}
+	}
+	return owned
+}
+
+// claimChains records the wrapper as the owner of the chains of a container
+// before they are created, so that a chain claimed by another component is
+// never written to. A nil registry claims nothing.
+func claimChains(owners chainOwners, fullRules []IpTablesFullChain) error {
+	if owners == nil {
+		return nil
+	}
+	if err := owners.Claim(ChainOwner, ownedChains(fullRules)...); err != nil {
+		return fmt.Errorf("claim chains: %s", err)
+	}
+	return nil
+}
+
+func releaseChains(owners chainOwners, fullRules []IpTablesFullChain) error {
+	if owners == nil {
+		return nil
+	}
+	if err := owners.Release(ChainOwner, ownedChains(fullRules)...); err != nil {
+		return fmt.Errorf("release chains: %s", err)
+	}
+	return nil
+}
+
+// initChains writes every chain with its rules in one restore, so a retried
+// ADD leaves the same chain behind, and then appends the jumps to the parent
+// chains, so they stay below a head claimed by another component.
 func initChains(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
 	for _, rule := range fullRules {
-		err := iptables.NewChain(rule.Table, rule.ChainName)
+		err := iptables.ReplaceChain(rule.Table, rule.ChainName, rule.Rules...)
 		if err != nil {
 			return fmt.Errorf("creating chain: %s", err)
 		}
 
-		if rule.ParentChain != "" {
-			err = iptables.BulkAppend(rule.Table, rule.ParentChain, rule.JumpConditions...)
+		if rule.ParentChain != "" && len(rule.JumpConditions) > 0 {
+			err = iptables.EnsureRules(rule.Table, rule.ParentChain, rule.JumpConditions...)
 			if err != nil {
 				return fmt.Errorf("appending rule to chain: %s", err)
 			}
@@ -26,9 +76,13 @@ func initChains(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) e
 	return nil
 }
 
+// applyRules appends the rules missing from chains that already exist.
 func applyRules(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) error {
 	for _, rule := range fullRules {
-		err := iptables.BulkAppend(rule.Table, rule.ChainName, rule.Rules...)
+		if len(rule.Rules) == 0 {
+			continue
+		}
+		err := iptables.EnsureRules(rule.Table, rule.ChainName, rule.Rules...)
 		if err != nil {
 			return fmt.Errorf("appending rule: %s", err)
 		}
@@ -37,11 +91,17 @@ func applyRules(iptables rules.IPTablesAdapter, fullRules []IpTablesFullChain) e
 	return nil
 }
 
+// cleanupChain removes what is left of a chain and its jumps, so that a DEL
+// that is retried after it removed some of them succeeds. A chain that is
+// already gone is recreated by ClearChain and deleted again.
 func cleanupChain(table, parentChain, chain string, jumpConditions []rules.IPTablesRule, iptables rules.IPTablesAdapter) error {
 	var result error
 	if parentChain != "" {
 		for _, condition := range jumpConditions {
 			if err := iptables.Delete(table, parentChain, condition); err != nil {
+				if exists, existsErr := iptables.Exists(table, parentChain, condition); existsErr == nil && !exists {
+					continue
+				}
 				result = multierror.Append(result, fmt.Errorf("delete rule: %s", err))
 			}
 		}
@@ -66,3 +126,12 @@ func cleanupChains(args []IpTablesFullChain, iptables rules.IPTablesAdapter) err
 	}
 	return result
 }
+
+// logID identifies a container in iptables log prefixes. The instance index
+// goes first so that it survives truncation of the prefix.
+func logID(containerHandle, instanceIndex string) string {
+	if instanceIndex == "" {
+		return containerHandle
+	}
+	return fmt.Sprintf("%s_%s", instanceIndex, containerHandle)
+}
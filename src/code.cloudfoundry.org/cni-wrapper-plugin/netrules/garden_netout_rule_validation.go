@@ -0,0 +1,161 @@
+package netrules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+
+	"code.cloudfoundry.org/garden"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// ValidateGardenNetOutRules checks garden net out rules before they are
+// converted to iptables rules, so that a malformed rule fails the container
+// with a useful error instead of failing inside iptables. Rules that garden
+// would accept are normalized:
+//   - inverted ip and port ranges are put the right way round
+//   - a port range without an end is a single port
+//   - ports of icmp rules and icmp types of other rules are dropped, since
+//     garden ignores them
+//   - ports of rules for all protocols are dropped with a warning written to
+//     warnings, since garden allows every port of such rules
+//   - overlapping and adjacent networks of a rule are merged
+//
+// Rules that cannot be normalized are reported together, and the normalized
+// rules are only returned when there are none.
+func ValidateGardenNetOutRules(gardenRules []garden.NetOutRule, warnings io.Writer) ([]garden.NetOutRule, error) {
+	var result error
+	normalized := []garden.NetOutRule{}
+	for i, gardenRule := range gardenRules {
+		rule, warning, err := normalizeGardenNetOutRule(gardenRule)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("net out rule %d: %s", i, err))
+			continue
+		}
+		if warning != "" && warnings != nil {
+			fmt.Fprintf(warnings, "net out rule %d: %s\n", i, warning)
+		}
+		normalized = append(normalized, rule)
+	}
+	if result != nil {
+		return nil, result
+	}
+	return normalized, nil
+}
+
+func normalizeGardenNetOutRule(rule garden.NetOutRule) (garden.NetOutRule, string, error) {
+	switch rule.Protocol {
+	case garden.ProtocolAll, garden.ProtocolTCP, garden.ProtocolUDP, garden.ProtocolICMP:
+	default:
+		return rule, "", fmt.Errorf("unknown protocol %d", rule.Protocol)
+	}
+
+	networks, err := normalizeNetworks(rule.Networks)
+	if err != nil {
+		return rule, "", err
+	}
+	rule.Networks = networks
+
+	var warning string
+	switch rule.Protocol {
+	case garden.ProtocolICMP:
+		rule.Ports = nil
+	case garden.ProtocolAll:
+		if len(rule.Ports) > 0 {
+			warning = "ignoring the ports of a rule for all protocols"
+			rule.Ports = nil
+		}
+		rule.ICMPs = nil
+	default:
+		ports, err := normalizePorts(rule.Ports)
+		if err != nil {
+			return rule, "", err
+		}
+		rule.Ports = ports
+		rule.ICMPs = nil
+	}
+
+	return rule, warning, nil
+}
+
+func normalizeNetworks(networks []garden.IPRange) ([]garden.IPRange, error) {
+	if len(networks) == 0 {
+		return networks, nil
+	}
+
+	normalized := []garden.IPRange{}
+	for _, network := range networks {
+		if network.Start.To16() == nil || network.End.To16() == nil {
+			return nil, fmt.Errorf("invalid network %s-%s", network.Start, network.End)
+		}
+		start, end := network.Start.To4(), network.End.To4()
+		if (start == nil) != (end == nil) {
+			return nil, fmt.Errorf("network %s-%s mixes ipv4 and ipv6", network.Start, network.End)
+		}
+		if start == nil {
+			start, end = network.Start.To16(), network.End.To16()
+		}
+		if bytes.Compare(start, end) > 0 {
+			start, end = end, start
+		}
+		normalized = append(normalized, garden.IPRange{Start: start, End: end})
+	}
+
+	sorted := append([]garden.IPRange{}, normalized...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if len(sorted[i].Start) != len(sorted[j].Start) {
+			return len(sorted[i].Start) < len(sorted[j].Start)
+		}
+		return bytes.Compare(sorted[i].Start, sorted[j].Start) < 0
+	})
+
+	merged := []garden.IPRange{sorted[0]}
+	for _, network := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if len(last.End) == len(network.Start) && bytes.Compare(network.Start, nextIP(last.End)) <= 0 {
+			if bytes.Compare(network.End, last.End) > 0 {
+				last.End = network.End
+			}
+			continue
+		}
+		merged = append(merged, network)
+	}
+
+	// keep the order of the rule when nothing was merged
+	if len(merged) == len(normalized) {
+		return normalized, nil
+	}
+	return merged, nil
+}
+
+func normalizePorts(ports []garden.PortRange) ([]garden.PortRange, error) {
+	var normalized []garden.PortRange
+	for _, port := range ports {
+		if port.Start == 0 && port.End == 0 {
+			return nil, fmt.Errorf("invalid port range 0-0")
+		}
+		if port.End == 0 {
+			port.End = port.Start
+		}
+		if port.Start > port.End {
+			port.Start, port.End = port.End, port.Start
+		}
+		normalized = append(normalized, port)
+	}
+	return normalized, nil
+}
+
+// nextIP returns the address after ip, or ip itself for the last address.
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return ip
+}
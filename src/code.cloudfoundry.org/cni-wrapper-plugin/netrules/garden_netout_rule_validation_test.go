@@ -0,0 +1,170 @@
+package netrules_test
+
+import (
+	"bytes"
+	"net"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/garden"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateGardenNetOutRules", func() {
+	var warnings *bytes.Buffer
+
+	BeforeEach(func() {
+		warnings = &bytes.Buffer{}
+	})
+
+	ipRange := func(start, end string) garden.IPRange {
+		return garden.IPRange{Start: net.ParseIP(start), End: net.ParseIP(end)}
+	}
+
+	It("returns valid rules unchanged", func() {
+		gardenRules := []garden.NetOutRule{
+			{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{ipRange("8.8.8.8", "9.9.9.9"), ipRange("1.1.1.1", "1.1.1.1")},
+				Ports:    []garden.PortRange{{Start: 53, End: 54}},
+				Log:      true,
+			},
+			{
+				Protocol: garden.ProtocolICMP,
+				Networks: []garden.IPRange{ipRange("5.5.5.5", "6.6.6.6")},
+				ICMPs:    &garden.ICMPControl{Type: 8, Code: garden.ICMPControlCode(0)},
+			},
+		}
+
+		normalized, err := netrules.ValidateGardenNetOutRules(gardenRules, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(normalized).To(HaveLen(2))
+		Expect(normalized[0].Networks).To(HaveLen(2))
+		Expect(normalized[0].Networks[0].Start.String()).To(Equal("8.8.8.8"))
+		Expect(normalized[0].Networks[1].Start.String()).To(Equal("1.1.1.1"))
+		Expect(normalized[0].Ports).To(Equal([]garden.PortRange{{Start: 53, End: 54}}))
+		Expect(normalized[0].Log).To(BeTrue())
+		Expect(normalized[1].ICMPs).To(Equal(&garden.ICMPControl{Type: 8, Code: garden.ICMPControlCode(0)}))
+	})
+
+	It("puts inverted ranges the right way round", func() {
+		normalized, err := netrules.ValidateGardenNetOutRules([]garden.NetOutRule{{
+			Protocol: garden.ProtocolUDP,
+			Networks: []garden.IPRange{ipRange("10.0.0.9", "10.0.0.1")},
+			Ports:    []garden.PortRange{{Start: 9000, End: 80}},
+		}}, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(normalized[0].Networks[0].Start.String()).To(Equal("10.0.0.1"))
+		Expect(normalized[0].Networks[0].End.String()).To(Equal("10.0.0.9"))
+		Expect(normalized[0].Ports).To(Equal([]garden.PortRange{{Start: 80, End: 9000}}))
+	})
+
+	It("treats a port range without an end as a single port", func() {
+		normalized, err := netrules.ValidateGardenNetOutRules([]garden.NetOutRule{{
+			Protocol: garden.ProtocolTCP,
+			Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+			Ports:    []garden.PortRange{{Start: 443}},
+		}}, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(normalized[0].Ports).To(Equal([]garden.PortRange{{Start: 443, End: 443}}))
+	})
+
+	It("drops the fields garden ignores for the protocol", func() {
+		normalized, err := netrules.ValidateGardenNetOutRules([]garden.NetOutRule{
+			{
+				Protocol: garden.ProtocolICMP,
+				Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+				Ports:    []garden.PortRange{{Start: 80, End: 80}},
+				ICMPs:    &garden.ICMPControl{Type: 8},
+			},
+			{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+				Ports:    []garden.PortRange{{Start: 80, End: 80}},
+				ICMPs:    &garden.ICMPControl{Type: 8},
+			},
+		}, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(normalized[0].Ports).To(BeNil())
+		Expect(normalized[0].ICMPs).To(Equal(&garden.ICMPControl{Type: 8}))
+		Expect(normalized[1].ICMPs).To(BeNil())
+	})
+
+	It("drops the ports of rules for all protocols with a warning", func() {
+		normalized, err := netrules.ValidateGardenNetOutRules([]garden.NetOutRule{
+			{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+				Ports:    []garden.PortRange{{Start: 80, End: 80}},
+			},
+			{
+				Protocol: garden.ProtocolAll,
+				Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+				Ports:    []garden.PortRange{{Start: 80, End: 80}},
+			},
+		}, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(normalized).To(HaveLen(2))
+		Expect(normalized[1].Ports).To(BeNil())
+		Expect(warnings.String()).To(Equal("net out rule 1: ignoring the ports of a rule for all protocols\n"))
+	})
+
+	It("returns valid rules without warnings", func() {
+		_, err := netrules.ValidateGardenNetOutRules([]garden.NetOutRule{{
+			Protocol: garden.ProtocolAll,
+			Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+		}}, nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("merges overlapping and adjacent networks", func() {
+		normalized, err := netrules.ValidateGardenNetOutRules([]garden.NetOutRule{{
+			Protocol: garden.ProtocolAll,
+			Networks: []garden.IPRange{
+				ipRange("10.0.1.0", "10.0.1.255"),
+				ipRange("10.0.0.0", "10.0.0.255"),
+				ipRange("10.0.0.128", "10.0.0.200"),
+				ipRange("192.168.0.1", "192.168.0.1"),
+			},
+		}}, warnings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(normalized[0].Networks).To(HaveLen(2))
+		Expect(normalized[0].Networks[0].Start.String()).To(Equal("10.0.0.0"))
+		Expect(normalized[0].Networks[0].End.String()).To(Equal("10.0.1.255"))
+		Expect(normalized[0].Networks[1].Start.String()).To(Equal("192.168.0.1"))
+	})
+
+	Context("when rules cannot be normalized", func() {
+		It("reports every invalid rule", func() {
+			_, err := netrules.ValidateGardenNetOutRules([]garden.NetOutRule{
+				{
+					Protocol: garden.ProtocolAll,
+					Networks: []garden.IPRange{{Start: net.ParseIP("10.0.0.1")}},
+				},
+				{
+					Protocol: garden.ProtocolTCP,
+					Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+					Ports:    []garden.PortRange{{Start: 80, End: 80}},
+				},
+				{
+					Protocol: garden.ProtocolAll,
+					Networks: []garden.IPRange{ipRange("10.0.0.1", "::1")},
+				},
+				{
+					Protocol: garden.ProtocolUDP,
+					Networks: []garden.IPRange{ipRange("10.0.0.1", "10.0.0.1")},
+					Ports:    []garden.PortRange{{}},
+				},
+				{
+					Protocol: garden.Protocol(42),
+				},
+			}, warnings)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("net out rule 0: invalid network 10.0.0.1-<nil>"))
+			Expect(err.Error()).NotTo(ContainSubstring("net out rule 1"))
+			Expect(err.Error()).To(ContainSubstring("net out rule 2: network 10.0.0.1-::1 mixes ipv4 and ipv6"))
+			Expect(err.Error()).To(ContainSubstring("net out rule 3: invalid port range 0-0"))
+			Expect(err.Error()).To(ContainSubstring("net out rule 4: unknown protocol 42"))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package netrules
 
 import (
+	"context"
 	"fmt"
 	"net"
 
@@ -16,10 +17,25 @@ type NetIn struct {
 	IPTables           rules.IPTablesAdapter
 	IngressTag         string
 	HostInterfaceNames []string
+	ChainOwners        chainOwners
+	// ChainBatch, if set, creates the chains with those of the containers
+	// that start together.
+	ChainBatch chainBatch
+}
+
+// WithContext returns a copy of the provider whose iptables calls stop when
+// the context is done.
+func (m NetIn) WithContext(ctx context.Context) *NetIn {
+	m.IPTables = m.IPTables.WithContext(ctx)
+	return &m
 }
 
 func (m *NetIn) Initialize(containerHandle string) error {
-	return initChains(m.IPTables, m.defaultNetInRules(containerHandle))
+	args := m.defaultNetInRules(containerHandle)
+	if err := claimChains(m.ChainOwners, args); err != nil {
+		return err
+	}
+	return initChainsWith(m.ChainBatch, m.IPTables, args)
 }
 
 func (m *NetIn) defaultNetInRules(containerHandle string) []IpTablesFullChain {
@@ -48,17 +64,28 @@ func (m *NetIn) defaultNetInRules(containerHandle string) []IpTablesFullChain {
 func (m *NetIn) Cleanup(containerHandle string) error {
 	var result error
 
-	for _, rule := range m.defaultNetInRules(containerHandle) {
+	args := m.defaultNetInRules(containerHandle)
+	for _, rule := range args {
 		err := cleanupChain(rule.Table, rule.ParentChain, rule.ChainName, rule.JumpConditions, m.IPTables)
 		if err != nil {
 			result = multierror.Append(result, err)
 		}
 	}
 
+	if err := releaseChains(m.ChainOwners, args); err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	return result
 }
 
 func (m *NetIn) AddRule(containerHandle string, hostPort, containerPort int, hostIP, containerIP string) error {
+	return m.AddPortRangeRule(containerHandle, "tcp", hostPort, hostPort, containerPort, hostIP, containerIP)
+}
+
+// AddPortRangeRule forwards the host ports of the protocol from hostPort to
+// hostPortEnd to as many container ports from containerPort on.
+func (m *NetIn) AddPortRangeRule(containerHandle, protocol string, hostPort, hostPortEnd, containerPort int, hostIP, containerIP string) error {
 	chain := m.ChainNamer.Prefix(prefixNetIn, containerHandle)
 
 	parsedIP := net.ParseIP(hostIP)
@@ -77,16 +104,44 @@ func (m *NetIn) AddRule(containerHandle string, hostPort, containerPort int, hos
 			ParentChain: "PREROUTING",
 			ChainName:   chain,
 			Rules: []rules.IPTablesRule{
-				rules.NewPortForwardingRule(hostPort, containerPort, hostIP, containerIP),
+				rules.NewPortRangeForwardingRule(protocol, hostPort, hostPortEnd, containerPort, hostIP, containerIP),
 			},
 		},
 		{
 			Table:       "mangle",
 			ParentChain: "PREROUTING",
 			ChainName:   chain,
-			Rules:       rules.NewIngressMarkRules(m.HostInterfaceNames, hostPort, hostIP, m.IngressTag),
+			Rules:       rules.NewPortRangeIngressMarkRules(m.HostInterfaceNames, protocol, hostPort, hostPortEnd, hostIP, m.IngressTag),
 		},
 	}
 
 	return applyRules(m.IPTables, containerIngressRules)
 }
+
+// PreserveClientIP keeps the replies on the connections to the host ports of
+// the protocol from hostPort to hostPortEnd on the return path of the cell,
+// which does not SNAT them, so that the container sees and answers the
+// client IP. It is added for mappings that AddPortRangeRule forwards.
+func (m *NetIn) PreserveClientIP(containerHandle, protocol string, hostPort, hostPortEnd int, hostIP, containerIP string) error {
+	chain := m.ChainNamer.Prefix(prefixNetIn, containerHandle)
+
+	if net.ParseIP(hostIP) == nil {
+		return fmt.Errorf("invalid ip: %s", hostIP)
+	}
+
+	if net.ParseIP(containerIP) == nil {
+		return fmt.Errorf("invalid ip: %s", containerIP)
+	}
+
+	return applyRules(m.IPTables, []IpTablesFullChain{
+		{
+			Table:       "mangle",
+			ParentChain: "PREROUTING",
+			ChainName:   chain,
+			Rules: []rules.IPTablesRule{
+				rules.NewClientIPConnMarkRule(protocol, hostPort, hostPortEnd, hostIP),
+				rules.NewClientIPReplyRule(containerIP),
+			},
+		},
+	})
+}
@@ -1,6 +1,7 @@
 package netrules_test
 
 import (
+	"context"
 	"errors"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
@@ -43,12 +44,12 @@ var _ = Describe("Netin", func() {
 			Expect(prefix).To(Equal("netin"))
 			Expect(handle).To(Equal("some-container-handle"))
 
-			Expect(ipTables.NewChainCallCount()).To(Equal(2))
-			table, chain := ipTables.NewChainArgsForCall(0)
+			Expect(ipTables.ReplaceChainCallCount()).To(Equal(2))
+			table, chain, _ := ipTables.ReplaceChainArgsForCall(0)
 			Expect(table).To(Equal("nat"))
 			Expect(chain).To(Equal("some-chain-name"))
 
-			table, chain = ipTables.NewChainArgsForCall(1)
+			table, chain, _ = ipTables.ReplaceChainArgsForCall(1)
 			Expect(table).To(Equal("mangle"))
 			Expect(chain).To(Equal("some-chain-name"))
 		})
@@ -57,21 +58,14 @@ var _ = Describe("Netin", func() {
 			err := netIn.Initialize("some-container-handle")
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(ipTables.BulkAppendCallCount()).To(Equal(2))
-			table, chain, rulespec := ipTables.BulkAppendArgsForCall(0)
-			Expect(table).To(Equal("nat"))
-			Expect(chain).To(Equal("PREROUTING"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"--jump", "some-chain-name"}}))
+			Expect(ensuredRules(ipTables, "nat", "PREROUTING")).To(Equal([]rules.IPTablesRule{{"--jump", "some-chain-name"}}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(1)
-			Expect(table).To(Equal("mangle"))
-			Expect(chain).To(Equal("PREROUTING"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"--jump", "some-chain-name"}}))
+			Expect(ensuredRules(ipTables, "mangle", "PREROUTING")).To(Equal([]rules.IPTablesRule{{"--jump", "some-chain-name"}}))
 		})
 
 		Context("when creating a new chain fails", func() {
 			BeforeEach(func() {
-				ipTables.NewChainReturns(errors.New("potato"))
+				ipTables.ReplaceChainReturns(errors.New("potato"))
 			})
 			It("returns an error", func() {
 				err := netIn.Initialize("some-container-handle")
@@ -81,13 +75,56 @@ var _ = Describe("Netin", func() {
 
 		Context("when adding the jump rule fails", func() {
 			BeforeEach(func() {
-				ipTables.BulkAppendReturns(errors.New("sweet potato"))
+				ipTables.EnsureRulesReturns(errors.New("sweet potato"))
 			})
 			It("returns an error", func() {
 				err := netIn.Initialize("some-container-handle")
 				Expect(err).To(MatchError("appending rule to chain: sweet potato"))
 			})
 		})
+
+		Context("when there is a chain batch", func() {
+			var chainBatch *fakes.ChainBatch
+
+			BeforeEach(func() {
+				chainBatch = &fakes.ChainBatch{}
+				netIn.ChainBatch = chainBatch
+			})
+
+			It("creates the chains through the batch", func() {
+				err := netIn.Initialize("some-container-handle")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(chainBatch.InitChainsCallCount()).To(Equal(1))
+				batchIPTables, fullRules := chainBatch.InitChainsArgsForCall(0)
+				Expect(batchIPTables).To(BeIdenticalTo(ipTables))
+				Expect(fullRules).To(HaveLen(2))
+				Expect(fullRules[0].Table).To(Equal("nat"))
+				Expect(fullRules[1].Table).To(Equal("mangle"))
+				Expect(ipTables.ReplaceChainCallCount()).To(Equal(0))
+			})
+
+			It("returns the error of the batch", func() {
+				chainBatch.InitChainsReturns(errors.New("potato"))
+				err := netIn.Initialize("some-container-handle")
+				Expect(err).To(MatchError("potato"))
+			})
+		})
+	})
+
+	Describe("WithContext", func() {
+		It("makes the iptables calls of the copy with the context", func() {
+			boundIPTables := &lib_fakes.IPTablesAdapter{}
+			ipTables.WithContextReturns(boundIPTables)
+			ctx := rules.WithOperation(context.Background(), "iptables net in cleanup")
+
+			Expect(netIn.WithContext(ctx).Cleanup("some-container-handle")).To(Succeed())
+
+			Expect(ipTables.WithContextArgsForCall(0)).To(Equal(ctx))
+			Expect(boundIPTables.DeleteCallCount()).To(Equal(2))
+			Expect(ipTables.DeleteCallCount()).To(Equal(0))
+			Expect(netIn.IPTables).To(BeIdenticalTo(ipTables))
+		})
 	})
 
 	Describe("Cleanup", func() {
@@ -140,9 +177,22 @@ var _ = Describe("Netin", func() {
 			Expect(chain).To(Equal("some-chain-name"))
 		})
 
+		Context("when the jump rule is already gone", func() {
+			BeforeEach(func() {
+				ipTables.DeleteReturns(errors.New("Bad rule (does a matching rule exist in that chain?)"))
+				ipTables.ExistsReturns(false, nil)
+			})
+			It("succeeds and still deletes the chain", func() {
+				err := netIn.Cleanup("some-container-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ipTables.DeleteChainCallCount()).NotTo(BeZero())
+			})
+		})
+
 		Context("when deleting the jump rule fails", func() {
 			BeforeEach(func() {
 				ipTables.DeleteReturns(errors.New("yukon potato"))
+				ipTables.ExistsReturns(true, nil)
 			})
 			It("returns an error", func() {
 				err := netIn.Cleanup("some-container-handle")
@@ -183,6 +233,7 @@ var _ = Describe("Netin", func() {
 		Context("when all the steps fail", func() {
 			BeforeEach(func() {
 				ipTables.DeleteReturns(errors.New("yukon potato"))
+				ipTables.ExistsReturns(true, nil)
 				ipTables.ClearChainReturns(errors.New("idaho potato"))
 				ipTables.DeleteChainReturns(errors.New("purple potato"))
 			})
@@ -205,21 +256,14 @@ var _ = Describe("Netin", func() {
 			Expect(prefix).To(Equal("netin"))
 			Expect(handle).To(Equal("some-container-handle"))
 
-			Expect(ipTables.BulkAppendCallCount()).To(Equal(2))
-			table, chain, rulespec := ipTables.BulkAppendArgsForCall(0)
-			Expect(table).To(Equal("nat"))
-			Expect(chain).To(Equal("some-chain-name"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{
+			Expect(ensuredRules(ipTables, "nat", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
 				"-d", "1.2.3.4", "-p", "tcp",
 				"-m", "tcp", "--dport", "1111",
 				"--jump", "DNAT",
 				"--to-destination", "5.6.7.8:2222",
 			}}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(1)
-			Expect(table).To(Equal("mangle"))
-			Expect(chain).To(Equal("some-chain-name"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{
+			Expect(ensuredRules(ipTables, "mangle", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
 				"-i", "underlay1", "-d", "1.2.3.4", "-p", "tcp",
 				"-m", "tcp", "--dport", "1111",
 				"--jump", "MARK",
@@ -235,7 +279,7 @@ var _ = Describe("Netin", func() {
 
 		Context("when writing the netin rule fails", func() {
 			BeforeEach(func() {
-				ipTables.BulkAppendReturns(errors.New("blue potato"))
+				ipTables.EnsureRulesReturns(errors.New("blue potato"))
 			})
 			It("returns an error", func() {
 				err := netIn.AddRule("some-container-handle", 1111, 2222, "1.2.3.4", "5.6.7.8")
@@ -257,4 +301,62 @@ var _ = Describe("Netin", func() {
 			})
 		})
 	})
+
+	Describe("PreserveClientIP", func() {
+		It("marks the connections to the range and clears the mark of their replies", func() {
+			err := netIn.PreserveClientIP("some-container-handle", "tcp", 1111, 1121, "1.2.3.4", "5.6.7.8")
+			Expect(err).NotTo(HaveOccurred())
+
+			prefix, handle := chainNamer.PrefixArgsForCall(0)
+			Expect(prefix).To(Equal("netin"))
+			Expect(handle).To(Equal("some-container-handle"))
+
+			Expect(ensuredRules(ipTables, "mangle", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
+				"-d", "1.2.3.4", "-p", "tcp",
+				"-m", "tcp", "--dport", "1111:1121",
+				"--jump", "CONNMARK",
+				"--set-xmark", "0x1/0x1",
+			}, {
+				"-s", "5.6.7.8",
+				"-m", "connmark", "--mark", "0x1/0x1",
+				"--jump", "MARK",
+				"--set-xmark", "0x0/0xffffffff",
+			}}))
+			Expect(ensuredRules(ipTables, "nat", "some-chain-name")).To(BeEmpty())
+		})
+
+		Context("when the container ip is invalid", func() {
+			It("returns an error", func() {
+				err := netIn.PreserveClientIP("some-container-handle", "tcp", 1111, 1111, "1.2.3.4", "banana")
+				Expect(err).To(MatchError("invalid ip: banana"))
+			})
+		})
+	})
+
+	Describe("AddPortRangeRule", func() {
+		It("forwards the range of the protocol and marks its packets", func() {
+			err := netIn.AddPortRangeRule("some-container-handle", "udp", 1111, 1121, 2222, "1.2.3.4", "5.6.7.8")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ensuredRules(ipTables, "nat", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
+				"-d", "1.2.3.4", "-p", "udp",
+				"-m", "udp", "--dport", "1111:1121",
+				"--jump", "DNAT",
+				"--to-destination", "5.6.7.8:2222-2232/1111",
+			}}))
+
+			Expect(ensuredRules(ipTables, "mangle", "some-chain-name")).To(Equal([]rules.IPTablesRule{{
+				"-i", "underlay1", "-d", "1.2.3.4", "-p", "udp",
+				"-m", "udp", "--dport", "1111:1121",
+				"--jump", "MARK",
+				"--set-mark", "0xFEEDBEEF",
+			}, {
+				"-i", "underlay2", "-d", "1.2.3.4", "-p", "udp",
+				"-m", "udp", "--dport", "1111:1121",
+				"--jump", "MARK",
+				"--set-mark", "0xFEEDBEEF",
+			},
+			}))
+		})
+	})
 })
@@ -1,11 +1,14 @@
 package netrules
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
 
 	"code.cloudfoundry.org/lib/rules"
+
+	multierror "github.com/hashicorp/go-multierror"
 )
 
 const prefixInput = "input"
@@ -31,22 +34,35 @@ type OutConn struct {
 }
 
 type NetOut struct {
-	ChainNamer            chainNamer
-	IPTables              rules.IPTablesAdapter
-	C2CLogging            bool
-	IngressTag            string
-	VTEPName              string
-	HostInterfaceNames    []string
-	DeniedLogsPerSec      int
-	AcceptedUDPLogsPerSec int
-	ContainerHandle       string
-	ContainerWorkload     string
-	ContainerIP           string
-	HostTCPServices       []string
-	HostUDPServices       []string
-	DNSServers            []string
-	Conn                  OutConn
-	NetOutChain           *NetOutChain
+	ChainNamer             chainNamer
+	IPTables               rules.IPTablesAdapter
+	C2CLogging             bool
+	IngressTag             string
+	VTEPName               string
+	HostInterfaceNames     []string
+	DeniedLogsPerSec       int
+	AcceptedUDPLogsPerSec  int
+	ContainerHandle        string
+	ContainerInstanceIndex string
+	ContainerWorkload      string
+	ContainerIP            string
+	HostTCPServices        []string
+	HostUDPServices        []string
+	DNSServers             []string
+	Conn                   OutConn
+	NetOutChain            *NetOutChain
+	ChainOwners            chainOwners
+	UIDExemptions          []UIDExemption
+	// ChainBatch, if set, creates the chains with those of the containers
+	// that start together.
+	ChainBatch chainBatch
+}
+
+// WithContext returns a copy of the provider whose iptables calls stop when
+// the context is done.
+func (m NetOut) WithContext(ctx context.Context) *NetOut {
+	m.IPTables = m.IPTables.WithContext(ctx)
+	return &m
 }
 
 func (m *NetOut) Initialize() error {
@@ -70,12 +86,12 @@ func (m *NetOut) Initialize() error {
 		return fmt.Errorf("input rules: %s", err)
 	}
 
-	err = initChains(m.IPTables, args)
+	err = claimChains(m.ChainOwners, args)
 	if err != nil {
 		return err
 	}
 
-	return applyRules(m.IPTables, args)
+	return initChainsWith(m.ChainBatch, m.IPTables, args)
 }
 
 func (m *NetOut) BulkInsertRules(ruleSpec []Rule) error {
@@ -91,6 +107,21 @@ func (m *NetOut) BulkInsertRules(ruleSpec []Rule) error {
 	return nil
 }
 
+// InsertSNIRules inserts the SNI inspection rules of the space, if any, at
+// the top of the netout chain of the container.
+func (m *NetOut) InsertSNIRules(spaceGUID string) error {
+	sniRules := m.NetOutChain.SNIRules(spaceGUID)
+	if len(sniRules) == 0 {
+		return nil
+	}
+	chain := m.NetOutChain.Name(m.ContainerHandle)
+	err := m.IPTables.BulkInsert("filter", chain, 1, sniRules...)
+	if err != nil {
+		return fmt.Errorf("inserting sni inspection rules: %s", err)
+	}
+	return nil
+}
+
 func (m *NetOut) Cleanup() error {
 	args, err := m.defaultNetOutRules()
 
@@ -98,7 +129,14 @@ func (m *NetOut) Cleanup() error {
 		return err
 	}
 
-	return cleanupChains(args, m.IPTables)
+	var result error
+	if err := cleanupChains(args, m.IPTables); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := releaseChains(m.ChainOwners, args); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result
 }
 
 func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
@@ -125,7 +163,7 @@ func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
 			"FORWARD",
 			forwardChainName,
 			rules.NewNetOutJumpConditions(m.HostInterfaceNames, m.ContainerIP, forwardChainName),
-			m.NetOutChain.DefaultRules(m.ContainerHandle),
+			m.NetOutChain.DefaultRules(m.ContainerHandle, m.ContainerInstanceIndex),
 		},
 		m.addC2CLogging(IpTablesFullChain{
 			"filter",
@@ -136,7 +174,7 @@ func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
 			}},
 			[]rules.IPTablesRule{
 				rules.NewOverlayAllowEgress(m.VTEPName, m.ContainerIP),
-				rules.NewOverlayRelatedEstablishedRule(m.ContainerIP),
+				m.NetOutChain.OverlayRelatedEstablishedRule(m.ContainerIP),
 				rules.NewOverlayTagAcceptRule(m.ContainerIP, m.IngressTag),
 				rules.NewOverlayDefaultRejectRule(m.ContainerIP),
 			},
@@ -145,8 +183,8 @@ func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
 
 	// This log chain is not connected to parent chains, it only gets used when asg logging is set
 	logChainRules := []rules.IPTablesRule{
-		rules.NewNetOutDefaultNonUDPLogRule(m.ContainerHandle),
-		rules.NewNetOutDefaultUDPLogRule(m.ContainerHandle, m.AcceptedUDPLogsPerSec),
+		rules.NewNetOutDefaultNonUDPLogRule(logID(m.ContainerHandle, m.ContainerInstanceIndex)),
+		rules.NewNetOutDefaultUDPLogRule(logID(m.ContainerHandle, m.ContainerInstanceIndex), m.AcceptedUDPLogsPerSec),
 		rules.NewAcceptRule(),
 	}
 	logChain, err := m.netOutLogChain(forwardChainName, suffixNetOutLog, logChainRules)
@@ -156,7 +194,21 @@ func (m *NetOut) defaultNetOutRules() ([]IpTablesFullChain, error) {
 
 	args = append(args, logChain)
 
-	if (m.Conn.Limit && m.Conn.Logging) || m.Conn.DryRun {
+	if len(m.UIDExemptions) > 0 {
+		uidExemptionsChain, err := m.uidExemptionsChain(forwardChainName, logChain.ChainName)
+		if err != nil {
+			return []IpTablesFullChain{}, fmt.Errorf("getting chain name: %s", err)
+		}
+
+		// the jump to the exemptions is appended to FORWARD before the jump
+		// to the netout chain, which rejects everything it does not accept
+		args = append(args[:1], append([]IpTablesFullChain{uidExemptionsChain}, args[1:]...)...)
+	}
+
+	// a flag can turn the limit on after the container is created, so the
+	// log chain the limit jumps to is created whenever there is a flag
+	connLimit := m.Conn.Limit || m.NetOutChain.FeatureFlags != nil
+	if (connLimit && m.Conn.Logging) || m.Conn.DryRun {
 		rateLimitLogChain, err := m.connRateLimitLogChain(forwardChainName)
 		if err != nil {
 			return []IpTablesFullChain{}, fmt.Errorf("getting chain name: %s", err)
@@ -173,7 +225,7 @@ func (m *NetOut) addC2CLogging(c IpTablesFullChain) IpTablesFullChain {
 		lastIndex := len(c.Rules) - 1
 		c.Rules = append(
 			c.Rules[:lastIndex],
-			rules.NewOverlayDefaultRejectLogRule(m.ContainerHandle, m.ContainerIP, m.DeniedLogsPerSec),
+			rules.NewOverlayDefaultRejectLogRule(logID(m.ContainerHandle, m.ContainerInstanceIndex), m.ContainerIP, m.DeniedLogsPerSec),
 			c.Rules[lastIndex],
 		)
 	}
@@ -233,11 +285,19 @@ func (m *NetOut) connRateLimitLogChain(forwardChainName string) (IpTablesFullCha
 	logRules := []rules.IPTablesRule{}
 
 	if m.Conn.Logging || m.Conn.DryRun {
-		logRules = append(logRules, rules.NewNetOutConnRateLimitRejectLogRule(m.ContainerHandle, m.DeniedLogsPerSec))
+		if m.NetOutChain.DeniedLogsPerDestination {
+			logRules = append(logRules, rules.NewNetOutConnRateLimitRejectLogPerDestinationRule(logID(m.ContainerHandle, m.ContainerInstanceIndex), m.DeniedLogsPerSec))
+		} else {
+			logRules = append(logRules, rules.NewNetOutConnRateLimitRejectLogRule(logID(m.ContainerHandle, m.ContainerInstanceIndex), m.DeniedLogsPerSec))
+		}
 	}
 
 	if !m.Conn.DryRun {
-		logRules = append(logRules, rules.NewNetOutDefaultRejectRule())
+		if m.NetOutChain.RejectTCPWithReset {
+			logRules = append(logRules, rules.NewNetOutDefaultTCPResetRule())
+		} else {
+			logRules = append(logRules, rules.NewNetOutDefaultRejectRule())
+		}
 	}
 
 	return m.netOutLogChain(forwardChainName, suffixNetOutRateLimitLog, logRules)
@@ -2,13 +2,20 @@ package netrules
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
 	"net"
 	"strconv"
 
+	"code.cloudfoundry.org/lib/featureflags"
 	"code.cloudfoundry.org/lib/rules"
 )
 
+//go:generate counterfeiter -o ../fakes/feature_flags.go --fake-name FeatureFlags . featureFlags
+type featureFlags interface {
+	Enabled(name string, defaultValue bool) bool
+}
+
 type NetOutChain struct {
 	ChainNamer       chainNamer
 	Converter        ruleConverter
@@ -16,6 +23,87 @@ type NetOutChain struct {
 	ASGLogging       bool
 	DeniedLogsPerSec int
 	Conn             OutConn
+
+	// RejectTCPWithReset rejects denied TCP with a RST; other protocols
+	// still get icmp-port-unreachable.
+	RejectTCPWithReset bool
+
+	// DeniedLogsPerDestination applies DeniedLogsPerSec to each destination
+	// IP of a container instead of to the container as a whole.
+	DeniedLogsPerDestination bool
+
+	// FeatureFlags turn ASGLogging, DenyNetworks and Conn.Limit on and off
+	// at runtime; a feature without a flag keeps its configured value.
+	FeatureFlags featureFlags
+
+	// RelatedEstablished scopes the rules that accept the packets of
+	// established connections without checking them against the ASGs and
+	// policies again.
+	RelatedEstablished RelatedEstablishedScope
+
+	// SNIInspection queues the TLS connections of the containers of some
+	// spaces to the SNI verdict daemon.
+	SNIInspection SNIInspection
+}
+
+// SNIInspection lists the NFQUEUEs of the SNI verdict daemon. With FailOpen,
+// the packets are accepted while the daemon does not listen on their queue.
+type SNIInspection struct {
+	FailOpen bool
+	Queues   []SNIQueue
+}
+
+// SNIQueue queues the connections of the containers of the spaces to the
+// ports, in multiport syntax, to the NFQUEUE QueueNum, and the connections
+// to the ports of the Destinations. Without Ports, only the connections to
+// the Destinations are queued.
+type SNIQueue struct {
+	QueueNum     int
+	SpaceGUIDs   []string
+	Ports        string
+	Destinations []SNIDestination
+}
+
+// SNIDestination is the network and ports, in multiport syntax, of an
+// ASG-like rule of an allowlist.
+type SNIDestination struct {
+	Network IPRange
+	Ports   string
+}
+
+// The scopes of the rules that accept the packets of established
+// connections.
+const (
+	// RelatedEstablishedAll accepts the packets of all established
+	// connections, also of the ones an ASG or policy no longer allows.
+	RelatedEstablishedAll = "all"
+	// RelatedEstablishedConnMark marks the connections of a container with
+	// its ASG rules, and only accepts the connections it made under its
+	// current rules, and the replies of the connections made to it.
+	RelatedEstablishedConnMark = "connmark"
+	// RelatedEstablishedInterface only accepts the connections of a
+	// container that leave through the Interfaces, and the replies of the
+	// connections made to it.
+	RelatedEstablishedInterface = "interface"
+)
+
+// relatedEstablishedMarkMask is the part of the conntrack mark that holds the
+// mark of the ASG rules of a connection. The lowest bit is ClientIPConnMark.
+const relatedEstablishedMarkMask = 0xffff0000
+
+// RelatedEstablishedScope is the scope of the rules that accept the packets
+// of established connections. Outside of the scope, the packets of the
+// connections a container made are checked against its current ASGs, and the
+// packets of the connections made to a container against its current
+// policies, so that the connections an ASG or policy no longer allows are
+// rejected. An empty Mode is RelatedEstablishedAll.
+type RelatedEstablishedScope struct {
+	Mode       string
+	Interfaces []string
+}
+
+func (r RelatedEstablishedScope) scoped() bool {
+	return r.Mode == RelatedEstablishedConnMark || r.Mode == RelatedEstablishedInterface
 }
 
 func (c *NetOutChain) Validate() error {
@@ -40,12 +128,19 @@ func (c *NetOutChain) Validate() error {
 	return nil
 }
 
-func (c *NetOutChain) DefaultRules(containerHandle string) []rules.IPTablesRule {
+func (c *NetOutChain) DefaultRules(containerHandle, instanceIndex string) []rules.IPTablesRule {
 	ruleSpec := []rules.IPTablesRule{}
-	if c.ASGLogging {
-		ruleSpec = append(ruleSpec, rules.NewNetOutDefaultRejectLogRule(containerHandle, c.DeniedLogsPerSec))
+	if c.asgLogging() {
+		if c.DeniedLogsPerDestination {
+			ruleSpec = append(ruleSpec, rules.NewNetOutDefaultRejectLogPerDestinationRule(logID(containerHandle, instanceIndex), c.DeniedLogsPerSec))
+		} else {
+			ruleSpec = append(ruleSpec, rules.NewNetOutDefaultRejectLogRule(logID(containerHandle, instanceIndex), c.DeniedLogsPerSec))
+		}
 	}
 
+	if c.RejectTCPWithReset {
+		ruleSpec = append(ruleSpec, rules.NewNetOutDefaultTCPResetRule())
+	}
 	ruleSpec = append(ruleSpec, rules.NewNetOutDefaultRejectRule())
 	return ruleSpec
 }
@@ -61,12 +156,12 @@ func (c *NetOutChain) IPTablesRules(containerHandle string, containerWorkload st
 		return nil, fmt.Errorf("getting chain name: %s", err)
 	}
 
-	iptablesRules := c.Converter.BulkConvert(ruleSpec, logChain, c.ASGLogging)
+	iptablesRules := c.Converter.BulkConvert(ruleSpec, logChain, c.asgLogging())
 	iptablesRules = c.Converter.DeduplicateRules(iptablesRules)
 
 	iptablesRules = append(iptablesRules, c.denyNetworksRules(containerWorkload)...)
 
-	if c.Conn.Limit || c.Conn.DryRun {
+	if c.ConnLimit() || c.Conn.DryRun {
 		rateLimitRule, err := c.rateLimitRule(forwardChainName, containerHandle)
 		if err != nil {
 			return nil, fmt.Errorf("getting chain name: %s", err)
@@ -75,36 +170,137 @@ func (c *NetOutChain) IPTablesRules(containerHandle string, containerWorkload st
 		iptablesRules = append(iptablesRules, rateLimitRule)
 	}
 
-	iptablesRules = append(iptablesRules, []rules.IPTablesRule{
-		{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
-		{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
-	}...)
+	if c.RelatedEstablished.Mode == RelatedEstablishedConnMark {
+		mark := relatedEstablishedMark(iptablesRules)
+		iptablesRules = append(iptablesRules, []rules.IPTablesRule{
+			rules.NewNetOutConnMarkRule(mark),
+			{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
+			rules.NewNetOutRelatedEstablishedMarkRule(mark),
+		}...)
+		return append(iptablesRules, rules.NewRelatedEstablishedReplyRule()), nil
+	}
+
+	iptablesRules = append(iptablesRules, rules.IPTablesRule{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"})
+	if c.RelatedEstablished.Mode == RelatedEstablishedInterface {
+		for i := len(c.RelatedEstablished.Interfaces) - 1; i >= 0; i-- {
+			iptablesRules = append(iptablesRules, rules.NewNetOutRelatedEstablishedInterfaceRule(c.RelatedEstablished.Interfaces[i]))
+		}
+		return append(iptablesRules, rules.NewRelatedEstablishedReplyRule()), nil
+	}
 
-	return iptablesRules, nil
+	return append(iptablesRules, rules.IPTablesRule{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"}), nil
+}
+
+// SNIRules are the rules, in the order of IPTablesRules, that reset the
+// connections the SNI verdict daemon denied and queue the undecided ones to
+// it, for the containers of the space. They go before all other rules, since
+// the ClientHello is sent on an established connection.
+func (c *NetOutChain) SNIRules(spaceGUID string) []rules.IPTablesRule {
+	if spaceGUID == "" {
+		return nil
+	}
+	for _, queue := range c.SNIInspection.Queues {
+		for _, queueSpaceGUID := range queue.SpaceGUIDs {
+			if queueSpaceGUID == spaceGUID {
+				return c.sniQueueRules(queue)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *NetOutChain) sniQueueRules(queue SNIQueue) []rules.IPTablesRule {
+	iptablesRules := []rules.IPTablesRule{}
+	if queue.Ports != "" {
+		iptablesRules = append(iptablesRules, rules.NewNetOutSNIQueueRule(queue.Ports, queue.QueueNum, c.SNIInspection.FailOpen))
+	}
+	for _, destination := range queue.Destinations {
+		iptablesRules = append(iptablesRules, rules.NewNetOutSNIDestinationQueueRule(
+			destination.Network.Start.String(), destination.Network.End.String(),
+			destination.Ports, queue.QueueNum, c.SNIInspection.FailOpen,
+		))
+	}
+	return append(iptablesRules, rules.NewNetOutSNIDeniedRule())
+}
+
+// OverlayRelatedEstablishedRule accepts the packets to a container of its
+// established connections on the overlay. Scoped, it only accepts the ones of
+// the connections the container made, so that the packets of the connections
+// made to it are checked against its current policies.
+func (c *NetOutChain) OverlayRelatedEstablishedRule(containerIP string) rules.IPTablesRule {
+	if c.RelatedEstablished.scoped() {
+		return rules.NewOverlayRelatedEstablishedReplyRule(containerIP)
+	}
+	return rules.NewOverlayRelatedEstablishedRule(containerIP)
+}
+
+// relatedEstablishedMark is the conntrack mark of the connections a container
+// makes under the rules. It changes with the rules, so that the connections
+// made under the rules before are checked against the current ones.
+func relatedEstablishedMark(iptablesRules []rules.IPTablesRule) string {
+	hash := fnv.New32a()
+	for _, rule := range iptablesRules {
+		fmt.Fprintf(hash, "%q\n", []string(rule))
+	}
+	mark := hash.Sum32() & relatedEstablishedMarkMask
+	if mark == 0 {
+		mark = 1 << 16
+	}
+	return fmt.Sprintf("0x%08x/0x%08x", mark, relatedEstablishedMarkMask)
 }
 
 func (c *NetOutChain) denyNetworksRules(containerWorkload string) []rules.IPTablesRule {
 	denyRules := []rules.IPTablesRule{}
+	if !c.enabled(featureflags.DenyNetworks, true) {
+		return denyRules
+	}
 
 	for _, denyNetwork := range c.DenyNetworks.Always {
-		denyRules = append(denyRules, rules.NewInputRejectRule(denyNetwork))
+		denyRules = append(denyRules, c.denyNetworkRules(denyNetwork)...)
 	}
 
 	if containerWorkload == "app" || containerWorkload == "task" {
 		for _, denyNetwork := range c.DenyNetworks.Running {
-			denyRules = append(denyRules, rules.NewInputRejectRule(denyNetwork))
+			denyRules = append(denyRules, c.denyNetworkRules(denyNetwork)...)
 		}
 	}
 
 	if containerWorkload == "staging" {
 		for _, denyNetwork := range c.DenyNetworks.Staging {
-			denyRules = append(denyRules, rules.NewInputRejectRule(denyNetwork))
+			denyRules = append(denyRules, c.denyNetworkRules(denyNetwork)...)
 		}
 	}
 
 	return denyRules
 }
 
+func (c *NetOutChain) enabled(name string, defaultValue bool) bool {
+	if c.FeatureFlags == nil {
+		return defaultValue
+	}
+	return c.FeatureFlags.Enabled(name, defaultValue)
+}
+
+func (c *NetOutChain) asgLogging() bool {
+	return c.enabled(featureflags.IPTablesASGLogging, c.ASGLogging)
+}
+
+// ConnLimit reports whether the outbound connections of the containers are
+// rate limited.
+func (c *NetOutChain) ConnLimit() bool {
+	return c.enabled(featureflags.OutboundConnectionLimit, c.Conn.Limit)
+}
+
+func (c *NetOutChain) denyNetworkRules(denyNetwork string) []rules.IPTablesRule {
+	if c.RejectTCPWithReset {
+		return []rules.IPTablesRule{
+			rules.NewInputTCPResetRule(denyNetwork),
+			rules.NewInputRejectRule(denyNetwork),
+		}
+	}
+	return []rules.IPTablesRule{rules.NewInputRejectRule(denyNetwork)}
+}
+
 func (c *NetOutChain) rateLimitRule(forwardChainName string, containerHandle string) (rule rules.IPTablesRule, err error) {
 	jumpTarget := "REJECT"
 
@@ -119,7 +315,11 @@ func (c *NetOutChain) rateLimitRule(forwardChainName string, containerHandle str
 	rate := fmt.Sprintf("%d/sec", c.Conn.RatePerSec)
 	expiryPeriod := c.rateLimitExpiryPeriod()
 
-	return rules.NewNetOutConnRateLimitRule(rate, burst, containerHandle, expiryPeriod, jumpTarget), nil
+	rule = rules.NewNetOutConnRateLimitRule(rate, burst, containerHandle, expiryPeriod, jumpTarget)
+	if jumpTarget == "REJECT" && c.RejectTCPWithReset {
+		rule = append(rule, "--reject-with", "tcp-reset")
+	}
+	return rule, nil
 }
 
 func (c *NetOutChain) rateLimitExpiryPeriod() string {
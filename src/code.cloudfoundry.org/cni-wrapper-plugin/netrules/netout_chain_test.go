@@ -2,12 +2,14 @@ package netrules_test
 
 import (
 	"errors"
+	"net"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 
 	"code.cloudfoundry.org/garden"
 
+	"code.cloudfoundry.org/lib/featureflags"
 	"code.cloudfoundry.org/lib/rules"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -40,7 +42,7 @@ var _ = Describe("NetOutChain", func() {
 
 	Describe("DefaultRules", func() {
 		It("writes the default netout and logging rules", func() {
-			ruleSpec := netOutChain.DefaultRules("some-container-handle")
+			ruleSpec := netOutChain.DefaultRules("some-container-handle", "")
 
 			Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
 				{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
@@ -52,7 +54,7 @@ var _ = Describe("NetOutChain", func() {
 				netOutChain.ASGLogging = true
 			})
 			It("writes a log rule for denies", func() {
-				ruleSpec := netOutChain.DefaultRules("some-container-handle")
+				ruleSpec := netOutChain.DefaultRules("some-container-handle", "")
 
 				Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
 					{"-m", "limit", "--limit", "3/s", "--limit-burst", "3",
@@ -61,6 +63,68 @@ var _ = Describe("NetOutChain", func() {
 						"--reject-with", "icmp-port-unreachable"},
 				}))
 			})
+
+			Context("when denied logs are limited per destination", func() {
+				BeforeEach(func() {
+					netOutChain.DeniedLogsPerDestination = true
+				})
+				It("limits the log rule per destination ip", func() {
+					ruleSpec := netOutChain.DefaultRules("some-container-handle", "")
+
+					Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
+						{"-m", "hashlimit", "--hashlimit-upto", "3/sec", "--hashlimit-burst", "3",
+							"--hashlimit-mode", "dstip", "--hashlimit-name", "deny-some-container-handle",
+							"--jump", "LOG", "--log-prefix", `"DENY_some-container-handle "`},
+						{"--jump", "REJECT",
+							"--reject-with", "icmp-port-unreachable"},
+					}))
+				})
+			})
+		})
+
+		Context("when the instance index is known", func() {
+			BeforeEach(func() {
+				netOutChain.ASGLogging = true
+			})
+			It("includes the instance index in the log prefix", func() {
+				ruleSpec := netOutChain.DefaultRules("some-container-handle", "3")
+
+				Expect(ruleSpec[0]).To(ContainElement(`"DENY_3_some-container-handle "`))
+			})
+		})
+
+		Context("when TCP rejects use a reset", func() {
+			BeforeEach(func() {
+				netOutChain.RejectTCPWithReset = true
+			})
+			It("rejects tcp with a reset before the icmp reject", func() {
+				ruleSpec := netOutChain.DefaultRules("some-container-handle", "")
+
+				Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
+					{"-p", "tcp", "--jump", "REJECT", "--reject-with", "tcp-reset"},
+					{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+				}))
+			})
+		})
+
+		Context("when the ASG logging feature flag is on", func() {
+			BeforeEach(func() {
+				featureFlags := &fakes.FeatureFlags{}
+				featureFlags.EnabledStub = func(name string, defaultValue bool) bool {
+					return name == featureflags.IPTablesASGLogging || defaultValue
+				}
+				netOutChain.FeatureFlags = featureFlags
+			})
+
+			It("writes a log rule for denies", func() {
+				ruleSpec := netOutChain.DefaultRules("some-container-handle", "")
+
+				Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
+					{"-m", "limit", "--limit", "3/s", "--limit-burst", "3",
+						"--jump", "LOG", "--log-prefix", `"DENY_some-container-handle "`},
+					{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+				}))
+			})
 		})
 	})
 
@@ -193,6 +257,28 @@ var _ = Describe("NetOutChain", func() {
 				Entry("when the workload is a task", "task", "2.2.2.2/32"),
 				Entry("when the workload is staging", "staging", "3.3.3.3/32"),
 			)
+
+			Context("when TCP rejects use a reset", func() {
+				BeforeEach(func() {
+					netOutChain.RejectTCPWithReset = true
+					netOutChain.DenyNetworks.Always = []string{"172.16.0.0/12"}
+				})
+
+				It("rejects tcp to the deny networks with a reset", func() {
+					iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", netrules.NewRulesFromGardenNetOutRules(netOutRules))
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(iptablesRules).To(Equal(append(
+						genericRules,
+						[]rules.IPTablesRule{
+							{"-d", "172.16.0.0/12", "-p", "tcp", "--jump", "REJECT", "--reject-with", "tcp-reset"},
+							{"-d", "172.16.0.0/12", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+							{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
+							{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+						}...,
+					)))
+				})
+			})
 		})
 
 		Context("when outbound container connection limiting is enabled", func() {
@@ -306,6 +392,22 @@ var _ = Describe("NetOutChain", func() {
 					Expect(iptablesRules).To(Equal(expectedRules))
 				})
 
+				Context("when TCP rejects use a reset", func() {
+					BeforeEach(func() {
+						netOutChain.RejectTCPWithReset = true
+					})
+
+					It("rejects rate limited connections with a reset", func() {
+						iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", netrules.NewRulesFromGardenNetOutRules(netOutRules))
+						Expect(err).NotTo(HaveOccurred())
+
+						rateLimitRule := iptablesRules[len(iptablesRules)-3]
+						Expect(rateLimitRule[len(rateLimitRule)-4:]).To(Equal(rules.IPTablesRule{
+							"-j", "REJECT", "--reject-with", "tcp-reset",
+						}))
+					})
+				})
+
 				Context("when denied outbound container connections dry_run is enabled", func() {
 					BeforeEach(func() {
 						netOutChain.Conn.DryRun = true
@@ -377,5 +479,176 @@ var _ = Describe("NetOutChain", func() {
 				})
 			})
 		})
+
+		Context("when feature flags are set", func() {
+			var featureFlags *fakes.FeatureFlags
+
+			BeforeEach(func() {
+				netOutChain.ASGLogging = true
+				netOutChain.DenyNetworks = netrules.DenyNetworks{Always: []string{"172.16.0.0/12"}}
+				netOutChain.Conn.RatePerSec = 99
+				netOutChain.Conn.Burst = 400
+
+				featureFlags = &fakes.FeatureFlags{}
+				featureFlags.EnabledStub = func(name string, defaultValue bool) bool {
+					switch name {
+					case featureflags.IPTablesASGLogging, featureflags.DenyNetworks:
+						return false
+					case featureflags.OutboundConnectionLimit:
+						return true
+					}
+					return defaultValue
+				}
+				netOutChain.FeatureFlags = featureFlags
+			})
+
+			It("applies the flags instead of the configured features", func() {
+				iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", netrules.NewRulesFromGardenNetOutRules(netOutRules))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, logging := converter.BulkConvertArgsForCall(0)
+				Expect(logging).To(BeFalse())
+
+				expectedRules := append(genericRules, []rules.IPTablesRule{
+					{
+						"-p", "tcp",
+						"-m", "conntrack", "--ctstate", "NEW",
+						"-m", "hashlimit", "--hashlimit-above", "99/sec", "--hashlimit-burst", "400",
+						"--hashlimit-mode", "dstip,dstport", "--hashlimit-name", "some-container-handle",
+						"--hashlimit-htable-expire", "5000", "-j", "REJECT",
+					},
+					{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+				}...)
+				Expect(iptablesRules).To(Equal(expectedRules))
+			})
+
+			It("keeps the configured features that have no flag", func() {
+				featureFlags.EnabledStub = func(name string, defaultValue bool) bool {
+					return defaultValue
+				}
+
+				iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", netrules.NewRulesFromGardenNetOutRules(netOutRules))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, logging := converter.BulkConvertArgsForCall(0)
+				Expect(logging).To(BeTrue())
+				Expect(iptablesRules).To(ContainElement(rules.IPTablesRule{"-d", "172.16.0.0/12", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"}))
+			})
+		})
+
+		Context("when established connections are scoped by their conntrack mark", func() {
+			BeforeEach(func() {
+				netOutChain.RelatedEstablished = netrules.RelatedEstablishedScope{Mode: netrules.RelatedEstablishedConnMark}
+			})
+
+			It("marks new connections with the rules and only accepts the current ones and replies", func() {
+				iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", netrules.NewRulesFromGardenNetOutRules(netOutRules))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(iptablesRules).To(HaveLen(6))
+				Expect(iptablesRules[:2]).To(Equal(genericRules))
+
+				markRule := iptablesRules[2]
+				Expect(markRule[:len(markRule)-1]).To(Equal(rules.IPTablesRule{"-m", "conntrack", "--ctstate", "NEW", "--jump", "CONNMARK", "--set-xmark"}))
+				mark := markRule[len(markRule)-1]
+				Expect(mark).To(MatchRegexp(`^0x[0-9a-f]{4}0000/0xffff0000$`))
+				Expect(mark).NotTo(HavePrefix("0x0000"))
+
+				Expect(iptablesRules[3:]).To(Equal([]rules.IPTablesRule{
+					{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
+					rules.NewNetOutRelatedEstablishedMarkRule(mark),
+					rules.NewRelatedEstablishedReplyRule(),
+				}))
+			})
+
+			It("changes the mark with the rules", func() {
+				iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				converter.DeduplicateRulesReturns([]rules.IPTablesRule{{"rule1"}})
+				otherRules, err := netOutChain.IPTablesRules("some-container-handle", "app", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(otherRules[1]).NotTo(Equal(iptablesRules[2]))
+			})
+
+			It("only accepts the replies of the connections made to the container on the overlay", func() {
+				Expect(netOutChain.OverlayRelatedEstablishedRule("10.255.0.2")).To(Equal(rules.NewOverlayRelatedEstablishedReplyRule("10.255.0.2")))
+			})
+		})
+
+		Context("when established connections are scoped by interface", func() {
+			BeforeEach(func() {
+				netOutChain.RelatedEstablished = netrules.RelatedEstablishedScope{
+					Mode:       netrules.RelatedEstablishedInterface,
+					Interfaces: []string{"eth1", "eth2"},
+				}
+			})
+
+			It("only accepts the connections through the interfaces and replies", func() {
+				iptablesRules, err := netOutChain.IPTablesRules("some-container-handle", "app", netrules.NewRulesFromGardenNetOutRules(netOutRules))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(iptablesRules).To(Equal(append(genericRules, []rules.IPTablesRule{
+					{"-p", "tcp", "-m", "state", "--state", "INVALID", "-j", "DROP"},
+					rules.NewNetOutRelatedEstablishedInterfaceRule("eth2"),
+					rules.NewNetOutRelatedEstablishedInterfaceRule("eth1"),
+					rules.NewRelatedEstablishedReplyRule(),
+				}...)))
+			})
+
+			It("only accepts the replies of the connections made to the container on the overlay", func() {
+				Expect(netOutChain.OverlayRelatedEstablishedRule("10.255.0.2")).To(Equal(rules.NewOverlayRelatedEstablishedReplyRule("10.255.0.2")))
+			})
+		})
+
+		It("accepts all established connections to the container on the overlay by default", func() {
+			Expect(netOutChain.OverlayRelatedEstablishedRule("10.255.0.2")).To(Equal(rules.NewOverlayRelatedEstablishedRule("10.255.0.2")))
+		})
+	})
+
+	Describe("SNIRules", func() {
+		BeforeEach(func() {
+			netOutChain.SNIInspection = netrules.SNIInspection{
+				FailOpen: true,
+				Queues: []netrules.SNIQueue{
+					{QueueNum: 100, SpaceGUIDs: []string{"space-a"}, Ports: "443"},
+					{QueueNum: 101, SpaceGUIDs: []string{"space-b", "space-c"}, Ports: "443,8443"},
+				},
+			}
+		})
+
+		It("resets the denied connections and queues the others of the space of the container", func() {
+			Expect(netOutChain.SNIRules("space-c")).To(Equal([]rules.IPTablesRule{
+				rules.NewNetOutSNIQueueRule("443,8443", 101, true),
+				rules.NewNetOutSNIDeniedRule(),
+			}))
+		})
+
+		Context("when the allowlist has destinations", func() {
+			BeforeEach(func() {
+				netOutChain.SNIInspection.Queues = append(netOutChain.SNIInspection.Queues, netrules.SNIQueue{
+					QueueNum:   102,
+					SpaceGUIDs: []string{"space-d"},
+					Destinations: []netrules.SNIDestination{
+						{Network: netrules.IPRange{Start: net.ParseIP("10.0.0.0"), End: net.ParseIP("10.0.0.255")}, Ports: "443"},
+						{Network: netrules.IPRange{Start: net.ParseIP("10.1.0.5"), End: net.ParseIP("10.1.0.5")}, Ports: "8000:8443"},
+					},
+				})
+			})
+
+			It("only queues the connections to the destinations", func() {
+				Expect(netOutChain.SNIRules("space-d")).To(Equal([]rules.IPTablesRule{
+					rules.NewNetOutSNIDestinationQueueRule("10.0.0.0", "10.0.0.255", "443", 102, true),
+					rules.NewNetOutSNIDestinationQueueRule("10.1.0.5", "10.1.0.5", "8000:8443", 102, true),
+					rules.NewNetOutSNIDeniedRule(),
+				}))
+			})
+		})
+
+		It("returns no rules for the containers of other spaces", func() {
+			Expect(netOutChain.SNIRules("space-e")).To(BeEmpty())
+			Expect(netOutChain.SNIRules("")).To(BeEmpty())
+		})
 	})
 })
@@ -1,14 +1,19 @@
 package netrules_test
 
 import (
+	"context"
 	"errors"
+	"path/filepath"
+	"time"
 
 	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	"code.cloudfoundry.org/garden"
 
+	"code.cloudfoundry.org/lib/datastore"
 	lib_fakes "code.cloudfoundry.org/lib/fakes"
 	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/lib/serial"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -53,6 +58,21 @@ var _ = Describe("Netout", func() {
 		chainNamer.PostfixReturns("some-other-chain-name", nil)
 	})
 
+	Describe("WithContext", func() {
+		It("makes the iptables calls of the copy with the context", func() {
+			boundIPTables := &lib_fakes.IPTablesAdapter{}
+			ipTables.WithContextReturns(boundIPTables)
+			ctx := rules.WithOperation(context.Background(), "iptables net out")
+
+			Expect(netOut.WithContext(ctx).Initialize()).To(Succeed())
+
+			Expect(ipTables.WithContextArgsForCall(0)).To(Equal(ctx))
+			Expect(boundIPTables.ReplaceChainCallCount()).NotTo(BeZero())
+			Expect(ipTables.ReplaceChainCallCount()).To(BeZero())
+			Expect(netOut.IPTables).To(BeIdenticalTo(ipTables))
+		})
+	})
+
 	Describe("Initialize", func() {
 		It("creates the input chain, netout forwarding chain, and the logging chain", func() {
 			err := netOut.Initialize()
@@ -76,17 +96,17 @@ var _ = Describe("Netout", func() {
 			Expect(body).To(Equal("netout-some-container-handle"))
 			Expect(suffix).To(Equal("log"))
 
-			Expect(ipTables.NewChainCallCount()).To(Equal(4))
-			table, chain := ipTables.NewChainArgsForCall(0)
+			Expect(ipTables.ReplaceChainCallCount()).To(Equal(4))
+			table, chain, _ := ipTables.ReplaceChainArgsForCall(0)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("input-some-container-handle"))
-			table, chain = ipTables.NewChainArgsForCall(1)
+			table, chain, _ = ipTables.ReplaceChainArgsForCall(1)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("netout-some-container-handle"))
-			table, chain = ipTables.NewChainArgsForCall(2)
+			table, chain, _ = ipTables.ReplaceChainArgsForCall(2)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("overlay-some-container-handle"))
-			table, chain = ipTables.NewChainArgsForCall(3)
+			table, chain, _ = ipTables.ReplaceChainArgsForCall(3)
 			Expect(table).To(Equal("filter"))
 			Expect(chain).To(Equal("some-other-chain-name"))
 		})
@@ -95,46 +115,26 @@ var _ = Describe("Netout", func() {
 			err := netOut.Initialize()
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
-			table, chain, rulespec := ipTables.BulkAppendArgsForCall(0)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("INPUT"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"-s", "5.6.7.8", "--jump", "input-some-container-handle"}}))
+			Expect(ensuredRules(ipTables, "filter", "INPUT")).To(Equal([]rules.IPTablesRule{{"-s", "5.6.7.8", "--jump", "input-some-container-handle"}}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(1)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("FORWARD"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
+			Expect(ensuredRules(ipTables, "filter", "FORWARD")).To(Equal([]rules.IPTablesRule{
 				{"-s", "5.6.7.8", "-o", "some-device", "--jump", "netout-some-container-handle"},
 				{"-s", "5.6.7.8", "-o", "eth0", "--jump", "netout-some-container-handle"},
+				{"--jump", "overlay-some-container-handle"},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(2)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("FORWARD"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"--jump", "overlay-some-container-handle"}}))
-
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(3)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("input-some-container-handle"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
+			Expect(ensuredRules(ipTables, "filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
 				{"-m", "state", "--state", "RELATED,ESTABLISHED",
 					"--jump", "ACCEPT"},
 				{"--jump", "REJECT",
 					"--reject-with", "icmp-port-unreachable"},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(4)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("netout-some-container-handle"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
+			Expect(ensuredRules(ipTables, "filter", "netout-some-container-handle")).To(Equal([]rules.IPTablesRule{
 				{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(5)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("overlay-some-container-handle"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
+			Expect(ensuredRules(ipTables, "filter", "overlay-some-container-handle")).To(Equal([]rules.IPTablesRule{
 				{"-s", "5.6.7.8",
 					"-o", "vtep-name",
 					"-m", "mark", "!", "--mark", "0x0",
@@ -150,10 +150,7 @@ var _ = Describe("Netout", func() {
 					"--reject-with", "icmp-port-unreachable"},
 			}))
 
-			table, chain, rulespec = ipTables.BulkAppendArgsForCall(6)
-			Expect(table).To(Equal("filter"))
-			Expect(chain).To(Equal("some-other-chain-name"))
-			Expect(rulespec).To(Equal([]rules.IPTablesRule{
+			Expect(ensuredRules(ipTables, "filter", "some-other-chain-name")).To(Equal([]rules.IPTablesRule{
 				{"!", "-p", "udp",
 					"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
 					"-j", "LOG", "--log-prefix", `"OK_some-container-handle "`},
@@ -164,9 +161,79 @@ var _ = Describe("Netout", func() {
 			}))
 		})
 
+		Context("when the instance index is known", func() {
+			BeforeEach(func() {
+				netOut.ContainerInstanceIndex = "3"
+			})
+
+			It("includes the instance index in the log prefixes", func() {
+				err := netOut.Initialize()
+				Expect(err).NotTo(HaveOccurred())
+
+				rulespec := ensuredRules(ipTables, "filter", "some-other-chain-name")
+				Expect(rulespec[0]).To(ContainElement(`"OK_3_some-container-handle "`))
+				Expect(rulespec[1]).To(ContainElement(`"OK_3_some-container-handle "`))
+			})
+		})
+
+		Context("when rate limited connections are logged and TCP rejects use a reset", func() {
+			BeforeEach(func() {
+				netOut.Conn.Limit = true
+				netOut.Conn.Logging = true
+				netOut.NetOutChain.RejectTCPWithReset = true
+				chainNamer.PostfixReturnsOnCall(1, "netout-some-container-handle-rl-log", nil)
+			})
+
+			It("rejects with a reset from the rate limit logging chain", func() {
+				err := netOut.Initialize()
+				Expect(err).NotTo(HaveOccurred())
+
+				rulespec := ensuredRules(ipTables, "filter", "netout-some-container-handle-rl-log")
+				Expect(rulespec[len(rulespec)-1]).To(Equal(rules.IPTablesRule{
+					"-p", "tcp", "--jump", "REJECT", "--reject-with", "tcp-reset",
+				}))
+			})
+		})
+
+		Context("when feature flags can turn the connection limit on", func() {
+			BeforeEach(func() {
+				netOut.Conn.Logging = true
+				netOut.NetOutChain.FeatureFlags = &fakes.FeatureFlags{}
+				chainNamer.PostfixReturnsOnCall(1, "netout-some-container-handle-rl-log", nil)
+			})
+
+			It("creates the rate limit logging chain", func() {
+				err := netOut.Initialize()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ensuredRules(ipTables, "filter", "netout-some-container-handle-rl-log")).NotTo(BeEmpty())
+			})
+		})
+
+		Context("when rate limited connections are logged per destination", func() {
+			BeforeEach(func() {
+				netOut.Conn.Limit = true
+				netOut.Conn.Logging = true
+				netOut.NetOutChain.DeniedLogsPerDestination = true
+				chainNamer.PostfixReturnsOnCall(1, "netout-some-container-handle-rl-log", nil)
+			})
+
+			It("limits the log rule per destination ip", func() {
+				err := netOut.Initialize()
+				Expect(err).NotTo(HaveOccurred())
+
+				rulespec := ensuredRules(ipTables, "filter", "netout-some-container-handle-rl-log")
+				Expect(rulespec[0]).To(Equal(rules.IPTablesRule{
+					"-m", "hashlimit", "--hashlimit-upto", "3/sec", "--hashlimit-burst", "3",
+					"--hashlimit-mode", "dstip", "--hashlimit-name", "deny_orl-some-container-handle",
+					"--jump", "LOG", "--log-prefix", `"DENY_ORL_some-container-hand "`,
+				}))
+			})
+		})
+
 		Context("when creating a new chain fails", func() {
 			BeforeEach(func() {
-				ipTables.NewChainReturns(errors.New("potata"))
+				ipTables.ReplaceChainReturns(errors.New("potata"))
 			})
 			It("returns the error", func() {
 				err := netOut.Initialize()
@@ -186,7 +253,7 @@ var _ = Describe("Netout", func() {
 
 		Context("when appending a new rule fails", func() {
 			BeforeEach(func() {
-				ipTables.BulkAppendReturns(errors.New("potato"))
+				ipTables.EnsureRulesReturns(errors.New("potato"))
 			})
 			It("returns the error", func() {
 				err := netOut.Initialize()
@@ -194,18 +261,93 @@ var _ = Describe("Netout", func() {
 			})
 		})
 
-		Context("when writing the netout rule fails", func() {
+		Context("when the initialization is retried", func() {
+			It("rewrites each chain in one call instead of appending its rules again", func() {
+				Expect(netOut.Initialize()).To(Succeed())
+				Expect(netOut.Initialize()).To(Succeed())
+
+				Expect(ipTables.ReplaceChainCallCount()).To(Equal(8))
+				for i := 0; i < 4; i++ {
+					table, chain, rulespec := ipTables.ReplaceChainArgsForCall(i)
+					retriedTable, retriedChain, retriedRulespec := ipTables.ReplaceChainArgsForCall(i + 4)
+					Expect(retriedTable).To(Equal(table))
+					Expect(retriedChain).To(Equal(chain))
+					Expect(retriedRulespec).To(Equal(rulespec))
+				}
+			})
+		})
+
+		Context("when there is a chain batch", func() {
+			var chainBatch *fakes.ChainBatch
+
 			BeforeEach(func() {
-				ipTables.BulkAppendStub = func(table, chain string, rulespec ...rules.IPTablesRule) error {
-					if chain == "INPUT" || chain == "FORWARD" {
-						return nil
-					}
-					return errors.New("potato")
+				chainBatch = &fakes.ChainBatch{}
+				netOut.ChainBatch = chainBatch
+			})
+
+			It("creates the chains through the batch", func() {
+				Expect(netOut.Initialize()).To(Succeed())
+
+				Expect(chainBatch.InitChainsCallCount()).To(Equal(1))
+				batchIPTables, fullRules := chainBatch.InitChainsArgsForCall(0)
+				Expect(batchIPTables).To(BeIdenticalTo(ipTables))
+				Expect(fullRules).To(HaveLen(4))
+				Expect(ipTables.ReplaceChainCallCount()).To(Equal(0))
+				Expect(ipTables.EnsureRulesCallCount()).To(Equal(0))
+			})
+
+			It("returns the error of the batch", func() {
+				chainBatch.InitChainsReturns(errors.New("potato"))
+				Expect(netOut.Initialize()).To(MatchError("potato"))
+			})
+		})
+
+		Context("when uid exemptions are configured", func() {
+			BeforeEach(func() {
+				chainNamer.PostfixStub = func(body, suffix string) (string, error) {
+					return body + "--" + suffix, nil
+				}
+				converter.BulkConvertReturns([]rules.IPTablesRule{{"-d", "10.0.5.5", "--jump", "ACCEPT"}})
+				netOut.UIDExemptions = []netrules.UIDExemption{
+					{UID: 2000, DSCP: 10, Bypass: true},
+					{UID: 2001, DSCP: 11, Rules: netrules.NewRulesFromGardenNetOutRules([]garden.NetOutRule{{Protocol: garden.ProtocolTCP}})},
 				}
 			})
-			It("returns the error", func() {
+
+			It("accepts the marked packets of the exempted users before the netout chain", func() {
 				err := netOut.Initialize()
-				Expect(err).To(MatchError("appending rule: potato"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ensuredRules(ipTables, "filter", "FORWARD")).To(Equal([]rules.IPTablesRule{
+					{"-s", "5.6.7.8", "-o", "some-device", "--jump", "netout-some-container-handle--uid"},
+					{"-s", "5.6.7.8", "-o", "eth0", "--jump", "netout-some-container-handle--uid"},
+					{"-s", "5.6.7.8", "-o", "some-device", "--jump", "netout-some-container-handle"},
+					{"-s", "5.6.7.8", "-o", "eth0", "--jump", "netout-some-container-handle"},
+					{"--jump", "overlay-some-container-handle"},
+				}))
+				Expect(ensuredRules(ipTables, "filter", "netout-some-container-handle--uid")).To(Equal([]rules.IPTablesRule{
+					{"-m", "dscp", "--dscp", "10", "--jump", "ACCEPT"},
+					{"-m", "dscp", "--dscp", "11", "-d", "10.0.5.5", "--jump", "ACCEPT"},
+				}))
+
+				Expect(converter.BulkConvertCallCount()).To(Equal(1))
+				ruleSpec, logChain, logging := converter.BulkConvertArgsForCall(0)
+				Expect(ruleSpec).To(Equal(netOut.UIDExemptions[1].Rules))
+				Expect(logChain).To(Equal("netout-some-container-handle--log"))
+				Expect(logging).To(BeFalse())
+			})
+
+			It("deletes the exemptions chain on cleanup", func() {
+				err := netOut.Cleanup()
+				Expect(err).NotTo(HaveOccurred())
+
+				table, chain, rule := ipTables.DeleteArgsForCall(1)
+				Expect(table).To(Equal("filter"))
+				Expect(chain).To(Equal("FORWARD"))
+				Expect(rule).To(Equal(rules.IPTablesRule{"-s", "5.6.7.8", "-o", "some-device", "--jump", "netout-some-container-handle--uid"}))
+				Expect(ipTables.DeleteChainCallCount()).To(Equal(5))
+				_, chain = ipTables.DeleteChainArgsForCall(1)
+				Expect(chain).To(Equal("netout-some-container-handle--uid"))
 			})
 		})
 
@@ -217,12 +359,7 @@ var _ = Describe("Netout", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
-
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(5)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("overlay-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
+				Expect(ensuredRules(ipTables, "filter", "overlay-some-container-handle")).To(Equal([]rules.IPTablesRule{
 					{"-s", "5.6.7.8",
 						"-o", "vtep-name",
 						"-m", "mark", "!", "--mark", "0x0",
@@ -251,12 +388,8 @@ var _ = Describe("Netout", func() {
 			It("creates rules for the dns servers", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
 
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("input-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
+				Expect(ensuredRules(ipTables, "filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
 					{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
 
 					{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
@@ -275,12 +408,8 @@ var _ = Describe("Netout", func() {
 				It("creates rules for both dns servers and the host TCP services", func() {
 					err := netOut.Initialize()
 					Expect(err).NotTo(HaveOccurred())
-					Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
 
-					table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-					Expect(table).To(Equal("filter"))
-					Expect(chain).To(Equal("input-some-container-handle"))
-					Expect(rulespec).To(Equal([]rules.IPTablesRule{
+					Expect(ensuredRules(ipTables, "filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
 						{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
 
 						{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
@@ -303,12 +432,8 @@ var _ = Describe("Netout", func() {
 				It("creates rules for both dns servers and the host UDP services", func() {
 					err := netOut.Initialize()
 					Expect(err).NotTo(HaveOccurred())
-					Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
 
-					table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-					Expect(table).To(Equal("filter"))
-					Expect(chain).To(Equal("input-some-container-handle"))
-					Expect(rulespec).To(Equal([]rules.IPTablesRule{
+					Expect(ensuredRules(ipTables, "filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
 						{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
 
 						{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
@@ -332,12 +457,8 @@ var _ = Describe("Netout", func() {
 				It("creates rules for dns servers, the host TCP services, and the host UDP services", func() {
 					err := netOut.Initialize()
 					Expect(err).NotTo(HaveOccurred())
-					Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
 
-					table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-					Expect(table).To(Equal("filter"))
-					Expect(chain).To(Equal("input-some-container-handle"))
-					Expect(rulespec).To(Equal([]rules.IPTablesRule{
+					Expect(ensuredRules(ipTables, "filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
 						{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
 
 						{"-p", "tcp", "-d", "8.8.4.4", "--destination-port", "53", "--jump", "ACCEPT"},
@@ -365,12 +486,8 @@ var _ = Describe("Netout", func() {
 			It("creates rules for the host TCP services", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
 
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("input-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
+				Expect(ensuredRules(ipTables, "filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
 					{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
 
 					{"-p", "tcp", "-d", "169.125.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
@@ -399,12 +516,8 @@ var _ = Describe("Netout", func() {
 			It("creates rules for the host UDP services", func() {
 				err := netOut.Initialize()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(ipTables.BulkAppendCallCount()).To(Equal(7))
 
-				table, chain, rulespec := ipTables.BulkAppendArgsForCall(3)
-				Expect(table).To(Equal("filter"))
-				Expect(chain).To(Equal("input-some-container-handle"))
-				Expect(rulespec).To(Equal([]rules.IPTablesRule{
+				Expect(ensuredRules(ipTables, "filter", "input-some-container-handle")).To(Equal([]rules.IPTablesRule{
 					{"-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
 
 					{"-p", "udp", "-d", "169.125.0.4", "--destination-port", "9001", "--jump", "ACCEPT"},
@@ -441,6 +554,34 @@ var _ = Describe("Netout", func() {
 		})
 	})
 
+	Describe("InsertSNIRules", func() {
+		BeforeEach(func() {
+			netOut.NetOutChain.SNIInspection = netrules.SNIInspection{
+				Queues: []netrules.SNIQueue{{QueueNum: 100, SpaceGUIDs: []string{"some-space"}, Ports: "443"}},
+			}
+		})
+
+		It("inserts the rules of the space at the top of the netout chain", func() {
+			Expect(netOut.InsertSNIRules("some-space")).To(Succeed())
+			Expect(ipTables.BulkInsertCallCount()).To(Equal(1))
+			table, chain, index, iptablesRules := ipTables.BulkInsertArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("netout-some-container-handle"))
+			Expect(index).To(Equal(1))
+			Expect(iptablesRules).To(Equal(netOut.NetOutChain.SNIRules("some-space")))
+		})
+
+		It("inserts nothing for the containers of other spaces", func() {
+			Expect(netOut.InsertSNIRules("other-space")).To(Succeed())
+			Expect(ipTables.BulkInsertCallCount()).To(Equal(0))
+		})
+
+		It("returns the error of the insert", func() {
+			ipTables.BulkInsertReturns(errors.New("potato"))
+			Expect(netOut.InsertSNIRules("some-space")).To(MatchError("inserting sni inspection rules: potato"))
+		})
+	})
+
 	Describe("Cleanup", func() {
 		It("deletes the correct jump rules from the forward chain", func() {
 			err := netOut.Cleanup()
@@ -542,9 +683,22 @@ var _ = Describe("Netout", func() {
 			})
 		})
 
+		Context("when the jump rule is already gone", func() {
+			BeforeEach(func() {
+				ipTables.DeleteReturns(errors.New("Bad rule (does a matching rule exist in that chain?)"))
+				ipTables.ExistsReturns(false, nil)
+			})
+			It("succeeds and still deletes the chain", func() {
+				err := netOut.Cleanup()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ipTables.DeleteChainCallCount()).NotTo(BeZero())
+			})
+		})
+
 		Context("when deleting the jump rule fails", func() {
 			BeforeEach(func() {
 				ipTables.DeleteReturns(errors.New("yukon potato"))
+				ipTables.ExistsReturns(true, nil)
 			})
 			It("returns an error", func() {
 				err := netOut.Cleanup()
@@ -575,6 +729,7 @@ var _ = Describe("Netout", func() {
 		Context("when all the steps fail", func() {
 			BeforeEach(func() {
 				ipTables.DeleteReturns(errors.New("yukon potato"))
+				ipTables.ExistsReturns(true, nil)
 				ipTables.ClearChainReturns(errors.New("idaho potato"))
 				ipTables.DeleteChainReturns(errors.New("purple potato"))
 			})
@@ -634,4 +789,54 @@ var _ = Describe("Netout", func() {
 			})
 		})
 	})
+
+	Describe("chain ownership", func() {
+		var chainOwners *datastore.ChainOwners
+
+		BeforeEach(func() {
+			dataFile := filepath.Join(GinkgoT().TempDir(), "chain-owners.json")
+			chainOwners = &datastore.ChainOwners{
+				Serializer:   &serial.Serial{},
+				Locker:       &lib_fakes.Locker{},
+				DataFilePath: dataFile,
+			}
+			netOut.ChainOwners = chainOwners
+		})
+
+		It("claims the chains of the container until they are cleaned up", func() {
+			Expect(netOut.Initialize()).To(Succeed())
+
+			owner, ok, err := chainOwners.Owner("filter", "netout-some-container-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(owner.Owner).To(Equal("cni-wrapper-plugin"))
+
+			By("refusing the chain to the silk daemon while the container exists")
+			err = chainOwners.Claim("silk-daemon", datastore.OwnedChain{Table: "filter", Chain: "netout-some-container-handle"})
+			Expect(err).To(MatchError("chain filter/netout-some-container-handle is owned by cni-wrapper-plugin"))
+
+			Expect(netOut.Cleanup()).To(Succeed())
+
+			_, ok, err = chainOwners.Owner("filter", "netout-some-container-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(chainOwners.Claim("silk-daemon", datastore.OwnedChain{Table: "filter", Chain: "netout-some-container-handle"})).To(Succeed())
+		})
+
+		Context("when another component owns one of the chains", func() {
+			BeforeEach(func() {
+				chainOwners.TTL = time.Minute
+				Expect(chainOwners.Claim("silk-daemon", datastore.OwnedChain{Table: "filter", Chain: "overlay-some-container-handle"})).To(Succeed())
+				chainOwners.TTL = 0
+			})
+
+			It("does not write to any of the chains", func() {
+				err := netOut.Initialize()
+				Expect(err).To(MatchError("claim chains: chain filter/overlay-some-container-handle is owned by silk-daemon"))
+
+				Expect(ipTables.ReplaceChainCallCount()).To(Equal(0))
+				Expect(ipTables.EnsureRulesCallCount()).To(Equal(0))
+			})
+		})
+	})
 })
@@ -1,6 +1,9 @@
 package netrules_test
 
 import (
+	lib_fakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -11,3 +14,22 @@ func TestNetrules(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Netrules Suite")
 }
+
+// ensuredRules returns the rules written to a chain through ReplaceChain and
+// EnsureRules, in the order they were written.
+func ensuredRules(ipTables *lib_fakes.IPTablesAdapter, table, chain string) []rules.IPTablesRule {
+	ensured := []rules.IPTablesRule{}
+	for i := 0; i < ipTables.ReplaceChainCallCount(); i++ {
+		t, c, rulespec := ipTables.ReplaceChainArgsForCall(i)
+		if t == table && c == chain {
+			ensured = append(ensured, rulespec...)
+		}
+	}
+	for i := 0; i < ipTables.EnsureRulesCallCount(); i++ {
+		t, c, rulespec := ipTables.EnsureRulesArgsForCall(i)
+		if t == table && c == chain {
+			ensured = append(ensured, rulespec...)
+		}
+	}
+	return ensured
+}
@@ -44,9 +44,19 @@ type Rule interface {
 	ICMPInfo() *ICMPInfo
 }
 
+//go:generate counterfeiter -o ../fakes/denied_rule_counter.go --fake-name DeniedRuleCounter . deniedRuleCounter
+type deniedRuleCounter interface {
+	IncrementCounter(name string)
+}
+
+// MetricDeniedRules counts the rules the converter could not turn into
+// iptables rules. Their traffic hits the default reject of the chain.
+const MetricDeniedRules = "netOutRulesDenied"
+
 type RuleConverter struct {
-	Logger    lager.Logger // used by vxlan-policy-agent
-	LogWriter io.Writer    // used by cni-wrapper-plugin
+	Logger        lager.Logger      // used by vxlan-policy-agent
+	LogWriter     io.Writer         // used by cni-wrapper-plugin
+	MetricsSender deniedRuleCounter // optional
 }
 
 func (c *RuleConverter) BulkConvert(ruleSpec []Rule, logChainName string, globalLogging bool) []rules.IPTablesRule {
@@ -78,19 +88,16 @@ func (c *RuleConverter) DeduplicateRules(iptablesRules []rules.IPTablesRule) []r
 func (c *RuleConverter) Convert(rule Rule, logChainName string, globalLogging bool) []rules.IPTablesRule {
 	ruleSpec := []rules.IPTablesRule{}
 	for _, network := range rule.Networks() {
+		if reason := invalidRule(rule, network); reason != "" {
+			c.deny("%s: %+v\n", reason, rule)
+			continue
+		}
 		startIP, endIP := network.Start.String(), network.End.String()
 		protocol := rule.Protocol()
 		log := rule.Log() || globalLogging
-		ports := rule.Ports()
 		switch protocol {
-		case ProtocolTCP:
-			fallthrough
-		case ProtocolUDP:
-			if len(ports) == 0 {
-				c.log("invalid-rule", "UDP/TCP rule must specify ports: %+v\n", rule)
-				continue
-			}
-			for _, portRange := range ports {
+		case ProtocolTCP, ProtocolUDP:
+			for _, portRange := range rule.Ports() {
 				startPort := int(portRange.Start)
 				endPort := int(portRange.End)
 				if log {
@@ -101,24 +108,12 @@ func (c *RuleConverter) Convert(rule Rule, logChainName string, globalLogging bo
 			}
 		case ProtocolICMP:
 			icmpInfo := rule.ICMPInfo()
-			if icmpInfo == nil {
-				c.log("invalid-rule", "ICMP rule must specify ICMP type/code: %+v\n", rule)
-				continue
-			}
-			if len(ports) > 0 {
-				c.log("invalid-rule", "ICMP rule must not specify ports: %+v\n", rule)
-				continue
-			}
 			if log {
 				ruleSpec = append(ruleSpec, rules.NewNetOutICMPLogRule(startIP, endIP, icmpInfo.Type, icmpInfo.Code, logChainName))
 			} else {
 				ruleSpec = append(ruleSpec, rules.NewNetOutICMPRule(startIP, endIP, icmpInfo.Type, icmpInfo.Code))
 			}
 		case ProtocolAll:
-			if len(ports) > 0 {
-				c.log("invalid-rule", "Rule for all protocols (TCP/UDP/ICMP) must not specify ports: %+v\n", rule)
-				continue
-			}
 			if log {
 				ruleSpec = append(ruleSpec, rules.NewNetOutLogRule(startIP, endIP, logChainName))
 			} else {
@@ -129,6 +124,42 @@ func (c *RuleConverter) Convert(rule Rule, logChainName string, globalLogging bo
 	return ruleSpec
 }
 
+// invalidRule is why the rule cannot be converted for the network, or empty
+// when it can.
+func invalidRule(rule Rule, network IPRange) string {
+	if network.Start.To4() == nil || network.End.To4() == nil {
+		return "IPv6 destinations are not supported by the netout chain"
+	}
+	ports := rule.Ports()
+	switch rule.Protocol() {
+	case ProtocolTCP, ProtocolUDP:
+		if len(ports) == 0 {
+			return "UDP/TCP rule must specify ports"
+		}
+	case ProtocolICMP:
+		if rule.ICMPInfo() == nil {
+			return "ICMP rule must specify ICMP type/code"
+		}
+		if len(ports) > 0 {
+			return "ICMP rule must not specify ports"
+		}
+	case ProtocolAll:
+		if len(ports) > 0 {
+			return "Rule for all protocols (TCP/UDP/ICMP) must not specify ports"
+		}
+	default:
+		return fmt.Sprintf("unsupported protocol %q", rule.Protocol())
+	}
+	return ""
+}
+
+func (c *RuleConverter) deny(message string, args ...interface{}) {
+	c.log("invalid-rule", message, args...)
+	if c.MetricsSender != nil {
+		c.MetricsSender.IncrementCounter(MetricDeniedRules)
+	}
+}
+
 func (c *RuleConverter) log(component, message string, args ...interface{}) {
 	if c.Logger != nil {
 		c.Logger.Error(component, fmt.Errorf(message, args...))
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"net"
 
+	"code.cloudfoundry.org/cni-wrapper-plugin/fakes"
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/policy_client"
 
 	"code.cloudfoundry.org/garden"
 
@@ -321,6 +323,20 @@ var _ = Describe("RuleConverter", func() {
 			})
 		})
 
+		Context("when the protocol is not supported", func() {
+			It("adds no iptables rules and logs the warning", func() {
+				rule, err := netrules.NewRuleFromSecurityGroupRule(policy_client.SecurityGroupRule{
+					Protocol:    "sctp",
+					Destination: "1.1.1.1",
+					Ports:       "80",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(converter.Convert(rule, logChainName, false)).To(BeEmpty())
+				Expect(logger.String()).To(ContainSubstring(`unsupported protocol "sctp"`))
+			})
+		})
+
 	})
 
 	Describe("BulkConvert", func() {
@@ -423,6 +439,43 @@ var _ = Describe("RuleConverter", func() {
 						"--jump", "ACCEPT"},
 				))
 			})
+
+			Context("when a metrics sender is set", func() {
+				var metricsSender *fakes.DeniedRuleCounter
+
+				BeforeEach(func() {
+					metricsSender = &fakes.DeniedRuleCounter{}
+					converter.MetricsSender = metricsSender
+				})
+
+				It("counts each denied destination", func() {
+					converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
+
+					Expect(metricsSender.IncrementCounterCallCount()).To(Equal(2))
+					Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("netOutRulesDenied"))
+				})
+			})
+		})
+
+		Context("when a net out rule has an IPv6 destination", func() {
+			BeforeEach(func() {
+				netOutRules = []garden.NetOutRule{{
+					Protocol: garden.ProtocolAll,
+					Networks: []garden.IPRange{
+						{Start: net.ParseIP("1.1.1.1"), End: net.ParseIP("2.2.2.2")},
+						{Start: net.ParseIP("2001:db8::1"), End: net.ParseIP("2001:db8::ff")},
+					},
+				}}
+			})
+
+			It("denies the IPv6 destination and keeps the others", func() {
+				ruleSpec := converter.BulkConvert(netrules.NewRulesFromGardenNetOutRules(netOutRules), logChainName, false)
+
+				Expect(ruleSpec).To(Equal([]rules.IPTablesRule{
+					{"-m", "iprange", "--dst-range", "1.1.1.1-2.2.2.2", "--jump", "ACCEPT"},
+				}))
+				Expect(logger.String()).To(ContainSubstring("IPv6 destinations are not supported by the netout chain"))
+			})
 		})
 
 	})
@@ -0,0 +1,104 @@
+package netrules
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/policy_client"
+)
+
+// SecurityGroupValidation is what the cells would make of the rules of ASGs:
+// the number of iptables rules they convert to, and the rules that they would
+// drop or could not enforce.
+type SecurityGroupValidation struct {
+	IPTablesRules int      `json:"iptables_rules"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// Valid reports whether the cells would enforce every rule.
+func (v SecurityGroupValidation) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// ValidateSecurityGroupRules converts the rules of ASGs with the converter of
+// the cells, so that ASGs that would be dropped or fail enforcement can be
+// rejected before they are bound. Besides the rules the converter denies, it
+// rejects ports and ICMP types and codes that the conversion would silently
+// drop or wrap around, and more than maxIPTablesRules iptables rules for the
+// ASGs of a container when it is not 0.
+func ValidateSecurityGroupRules(sgRules []policy_client.SecurityGroupRule, maxIPTablesRules int) SecurityGroupValidation {
+	validation := SecurityGroupValidation{}
+	converter := &RuleConverter{LogWriter: io.Discard}
+
+	iptablesRules := []rules.IPTablesRule{}
+	for i, sgRule := range sgRules {
+		if err := validateSecurityGroupRule(sgRule); err != nil {
+			validation.Errors = append(validation.Errors, fmt.Sprintf("rule %d: %s", i, err))
+			continue
+		}
+		rule, err := NewRuleFromSecurityGroupRule(sgRule)
+		if err != nil {
+			validation.Errors = append(validation.Errors, fmt.Sprintf("rule %d: invalid destination %q", i, sgRule.Destination))
+			continue
+		}
+		for _, network := range rule.Networks() {
+			if reason := invalidRule(rule, network); reason != "" {
+				validation.Errors = append(validation.Errors, fmt.Sprintf("rule %d: %s", i, reason))
+				break
+			}
+		}
+		iptablesRules = append(iptablesRules, converter.Convert(rule, "", false)...)
+	}
+
+	validation.IPTablesRules = len(converter.DeduplicateRules(iptablesRules))
+	if maxIPTablesRules > 0 && validation.IPTablesRules > maxIPTablesRules {
+		validation.Errors = append(validation.Errors, fmt.Sprintf("the rules convert to %d iptables rules, more than the limit of %d", validation.IPTablesRules, maxIPTablesRules))
+	}
+	return validation
+}
+
+func validateSecurityGroupRule(sgRule policy_client.SecurityGroupRule) error {
+	switch Protocol(sgRule.Protocol) {
+	case ProtocolTCP, ProtocolUDP:
+		return validateSecurityGroupPorts(sgRule.Ports)
+	case ProtocolICMP:
+		// -1 is any type or code; a code only applies to a type
+		if sgRule.Type < -1 || sgRule.Type > 255 {
+			return fmt.Errorf("invalid icmp type %d: must be between -1 and 255", sgRule.Type)
+		}
+		if sgRule.Code < -1 || sgRule.Code > 255 {
+			return fmt.Errorf("invalid icmp code %d: must be between -1 and 255", sgRule.Code)
+		}
+		if sgRule.Type == -1 && sgRule.Code != -1 {
+			return fmt.Errorf("invalid icmp code %d: any icmp type needs any code", sgRule.Code)
+		}
+	}
+	return nil
+}
+
+func validateSecurityGroupPorts(ports string) error {
+	if strings.TrimSpace(ports) == "" {
+		return nil
+	}
+	for _, portRange := range strings.Split(ports, ",") {
+		bounds := strings.Split(portRange, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid ports %q", portRange)
+		}
+		parsed := []int{}
+		for _, bound := range bounds {
+			port, err := strconv.Atoi(strings.TrimSpace(bound))
+			if err != nil || port < 1 || port > 65535 {
+				return fmt.Errorf("invalid ports %q: ports must be between 1 and 65535", portRange)
+			}
+			parsed = append(parsed, port)
+		}
+		if len(parsed) == 2 && parsed[0] > parsed[1] {
+			return fmt.Errorf("invalid ports %q: start is after end", portRange)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package netrules_test
+
+import (
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/policy_client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateSecurityGroupRules", func() {
+	It("counts the iptables rules of valid rules", func() {
+		validation := netrules.ValidateSecurityGroupRules([]policy_client.SecurityGroupRule{
+			{Protocol: "tcp", Destination: "10.0.0.0/8,192.168.0.1", Ports: "80,443,8000-8999"},
+			{Protocol: "udp", Destination: "10.0.0.0-10.0.0.255", Ports: "53"},
+			{Protocol: "icmp", Destination: "0.0.0.0/0", Type: -1, Code: -1},
+			{Protocol: "all", Destination: "172.16.0.0/12", Log: true},
+			{Protocol: "tcp", Destination: "10.0.0.0/8", Ports: "443"},
+		}, 0)
+
+		Expect(validation.Valid()).To(BeTrue())
+		Expect(validation.Errors).To(BeEmpty())
+		Expect(validation.IPTablesRules).To(Equal(9))
+	})
+
+	DescribeTable("rejecting rules the cells cannot enforce",
+		func(rule policy_client.SecurityGroupRule, expectedError string) {
+			validation := netrules.ValidateSecurityGroupRules([]policy_client.SecurityGroupRule{
+				{Protocol: "tcp", Destination: "10.0.0.1", Ports: "443"},
+				rule,
+			}, 0)
+
+			Expect(validation.Valid()).To(BeFalse())
+			Expect(validation.Errors).To(Equal([]string{expectedError}))
+			Expect(validation.IPTablesRules).To(Equal(1))
+		},
+		Entry("unsupported protocol",
+			policy_client.SecurityGroupRule{Protocol: "sctp", Destination: "10.0.0.1", Ports: "80"},
+			`rule 1: unsupported protocol "sctp"`),
+		Entry("unparseable destination",
+			policy_client.SecurityGroupRule{Protocol: "all", Destination: "10.0.0.0/33"},
+			`rule 1: invalid destination "10.0.0.0/33"`),
+		Entry("ipv6 destination",
+			policy_client.SecurityGroupRule{Protocol: "all", Destination: "::1"},
+			"rule 1: IPv6 destinations are not supported by the netout chain"),
+		Entry("tcp without ports",
+			policy_client.SecurityGroupRule{Protocol: "tcp", Destination: "10.0.0.1"},
+			"rule 1: UDP/TCP rule must specify ports"),
+		Entry("unparseable ports",
+			policy_client.SecurityGroupRule{Protocol: "tcp", Destination: "10.0.0.1", Ports: "80,http"},
+			`rule 1: invalid ports "http": ports must be between 1 and 65535`),
+		Entry("ports out of range",
+			policy_client.SecurityGroupRule{Protocol: "udp", Destination: "10.0.0.1", Ports: "1-70000"},
+			`rule 1: invalid ports "1-70000": ports must be between 1 and 65535`),
+		Entry("inverted port range",
+			policy_client.SecurityGroupRule{Protocol: "udp", Destination: "10.0.0.1", Ports: "90-80"},
+			`rule 1: invalid ports "90-80": start is after end`),
+		Entry("ports of a rule for all protocols",
+			policy_client.SecurityGroupRule{Protocol: "all", Destination: "10.0.0.1", Ports: "80"},
+			"rule 1: Rule for all protocols (TCP/UDP/ICMP) must not specify ports"),
+		Entry("icmp type out of range",
+			policy_client.SecurityGroupRule{Protocol: "icmp", Destination: "10.0.0.1", Type: 256},
+			"rule 1: invalid icmp type 256: must be between -1 and 255"),
+		Entry("icmp code out of range",
+			policy_client.SecurityGroupRule{Protocol: "icmp", Destination: "10.0.0.1", Type: 3, Code: -2},
+			"rule 1: invalid icmp code -2: must be between -1 and 255"),
+		Entry("icmp code of any type",
+			policy_client.SecurityGroupRule{Protocol: "icmp", Destination: "10.0.0.1", Type: -1, Code: 1},
+			"rule 1: invalid icmp code 1: any icmp type needs any code"),
+	)
+
+	Context("when the rules convert to more iptables rules than the limit", func() {
+		It("rejects them", func() {
+			validation := netrules.ValidateSecurityGroupRules([]policy_client.SecurityGroupRule{
+				{Protocol: "tcp", Destination: "10.0.0.1,10.0.0.2", Ports: "80,443"},
+			}, 3)
+
+			Expect(validation.IPTablesRules).To(Equal(4))
+			Expect(validation.Errors).To(Equal([]string{"the rules convert to 4 iptables rules, more than the limit of 3"}))
+		})
+	})
+})
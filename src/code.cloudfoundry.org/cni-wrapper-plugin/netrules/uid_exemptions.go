@@ -0,0 +1,73 @@
+package netrules
+
+import (
+	"code.cloudfoundry.org/lib/rules"
+)
+
+const suffixNetOutUIDExemptions = "uid"
+
+// ContainerUIDExemptionsChain is the chain in the mangle table of the network
+// namespace of a container that marks the packets of the exempted users.
+const ContainerUIDExemptionsChain = "uid-exemptions"
+
+// UIDExemption gives the packets a user inside the container sends their own
+// egress rules. The owner of a packet is only known in the network namespace
+// of the container, so the packets of the user are marked there with the DSCP
+// value, and the cell accepts the marked packets to the destinations of the
+// rules, or to any destination with Bypass, before the netout chain.
+type UIDExemption struct {
+	UID    int
+	DSCP   int
+	Bypass bool
+	Rules  []Rule
+}
+
+// MarkUIDExemptions writes the rules that mark the packets of the exempted
+// users. The iptables adapter must run in the network namespace of the
+// container. The DSCP values of the exemptions are reset on the packets of
+// all users first, so that an app cannot mark its own packets.
+func MarkUIDExemptions(iptables rules.IPTablesAdapter, exemptions []UIDExemption) error {
+	markRules := []rules.IPTablesRule{}
+	for _, exemption := range exemptions {
+		markRules = append(markRules, rules.NewDSCPClearRule(exemption.DSCP))
+	}
+	for _, exemption := range exemptions {
+		markRules = append(markRules, rules.NewUIDDSCPMarkRule(exemption.UID, exemption.DSCP))
+	}
+
+	return initChains(iptables, []IpTablesFullChain{{
+		"mangle",
+		"OUTPUT",
+		ContainerUIDExemptionsChain,
+		[]rules.IPTablesRule{{"--jump", ContainerUIDExemptionsChain}},
+		markRules,
+	}})
+}
+
+// uidExemptionsChain accepts the marked packets of the exempted users. The
+// other packets return to the FORWARD chain and go on to the netout chain.
+func (m *NetOut) uidExemptionsChain(forwardChainName, logChainName string) (IpTablesFullChain, error) {
+	chainName, err := m.ChainNamer.Postfix(forwardChainName, suffixNetOutUIDExemptions)
+	if err != nil {
+		return IpTablesFullChain{}, err
+	}
+
+	exemptionRules := []rules.IPTablesRule{}
+	for _, exemption := range m.UIDExemptions {
+		if exemption.Bypass {
+			exemptionRules = append(exemptionRules, rules.NewDSCPMatchRule(exemption.DSCP, rules.NewAcceptRule()))
+			continue
+		}
+		for _, rule := range m.NetOutChain.Converter.BulkConvert(exemption.Rules, logChainName, m.NetOutChain.ASGLogging) {
+			exemptionRules = append(exemptionRules, rules.NewDSCPMatchRule(exemption.DSCP, rule))
+		}
+	}
+
+	return IpTablesFullChain{
+		"filter",
+		"FORWARD",
+		chainName,
+		rules.NewNetOutJumpConditions(m.HostInterfaceNames, m.ContainerIP, chainName),
+		exemptionRules,
+	}, nil
+}
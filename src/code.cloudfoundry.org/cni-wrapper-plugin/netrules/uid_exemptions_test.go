@@ -0,0 +1,52 @@
+package netrules_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	lib_fakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MarkUIDExemptions", func() {
+	var (
+		ipTables   *lib_fakes.IPTablesAdapter
+		exemptions []netrules.UIDExemption
+	)
+
+	BeforeEach(func() {
+		ipTables = &lib_fakes.IPTablesAdapter{}
+		exemptions = []netrules.UIDExemption{
+			{UID: 2000, DSCP: 10, Bypass: true},
+			{UID: 2001, DSCP: 11},
+		}
+	})
+
+	It("marks the packets of the exempted users after resetting the marks of all users", func() {
+		Expect(netrules.MarkUIDExemptions(ipTables, exemptions)).To(Succeed())
+
+		Expect(ensuredRules(ipTables, "mangle", "OUTPUT")).To(Equal([]rules.IPTablesRule{
+			{"--jump", "uid-exemptions"},
+		}))
+		Expect(ensuredRules(ipTables, "mangle", "uid-exemptions")).To(Equal([]rules.IPTablesRule{
+			{"-m", "dscp", "--dscp", "10", "-j", "DSCP", "--set-dscp", "0"},
+			{"-m", "dscp", "--dscp", "11", "-j", "DSCP", "--set-dscp", "0"},
+			{"-m", "owner", "--uid-owner", "2000", "-j", "DSCP", "--set-dscp", "10"},
+			{"-m", "owner", "--uid-owner", "2001", "-j", "DSCP", "--set-dscp", "11"},
+		}))
+	})
+
+	Context("when writing the chain fails", func() {
+		BeforeEach(func() {
+			ipTables.ReplaceChainReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			err := netrules.MarkUIDExemptions(ipTables, exemptions)
+			Expect(err).To(MatchError("creating chain: banana"))
+		})
+	})
+})
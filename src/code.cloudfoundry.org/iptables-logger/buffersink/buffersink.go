@@ -0,0 +1,84 @@
+package buffersink
+
+import (
+	"os"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/cf-networking-helpers/metrics"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// BufferedSink queues the log records for a sink that writes them in its own
+// goroutine, so that a slow disk does not block the reader of the kernel
+// logs. When the queue is full, the records are dropped and counted.
+type BufferedSink struct {
+	sink    lager.Sink
+	records chan lager.LogFormat
+	dropped uint64
+}
+
+func NewBufferedSink(sink lager.Sink, size int) *BufferedSink {
+	return &BufferedSink{
+		sink:    sink,
+		records: make(chan lager.LogFormat, size),
+	}
+}
+
+func (b *BufferedSink) Log(logFmt lager.LogFormat) {
+	select {
+	case b.records <- logFmt:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
+
+// Dropped is the number of records dropped since the sink was created.
+func (b *BufferedSink) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Run writes the queued records until it is signaled, then writes the records
+// left in the queue.
+func (b *BufferedSink) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	for {
+		select {
+		case <-signals:
+			b.flush()
+			return nil
+		case record := <-b.records:
+			b.sink.Log(record)
+		}
+	}
+}
+
+func (b *BufferedSink) flush() {
+	for {
+		select {
+		case record := <-b.records:
+			b.sink.Log(record)
+		default:
+			return
+		}
+	}
+}
+
+// DropCounter is a sink that counts the records it dropped.
+type DropCounter interface {
+	Dropped() uint64
+}
+
+// NewDroppedRecordsSource reports the records that the sinks dropped.
+func NewDroppedRecordsSource(counters ...DropCounter) metrics.MetricSource {
+	return metrics.MetricSource{
+		Name: "iptablesLoggerDroppedRecords",
+		Unit: "",
+		Getter: func() (float64, error) {
+			var dropped uint64
+			for _, counter := range counters {
+				dropped += counter.Dropped()
+			}
+			return float64(dropped), nil
+		},
+	}
+}
@@ -0,0 +1,13 @@
+package buffersink_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBuffersink(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Buffersink Suite")
+}
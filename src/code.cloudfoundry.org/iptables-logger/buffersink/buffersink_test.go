@@ -0,0 +1,76 @@
+package buffersink_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/iptables-logger/buffersink"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("BufferedSink", func() {
+	var (
+		sink         *lagertest.TestSink
+		bufferedSink *buffersink.BufferedSink
+	)
+
+	BeforeEach(func() {
+		sink = lagertest.NewTestSink()
+		bufferedSink = buffersink.NewBufferedSink(sink, 2)
+	})
+
+	It("writes the queued records to the sink while running", func() {
+		process := ifrit.Invoke(bufferedSink)
+		defer func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		}()
+
+		bufferedSink.Log(lager.LogFormat{Message: "first"})
+		bufferedSink.Log(lager.LogFormat{Message: "second"})
+
+		Eventually(sink.LogMessages).Should(Equal([]string{"first", "second"}))
+		Expect(bufferedSink.Dropped()).To(BeZero())
+	})
+
+	Context("when the queue is full", func() {
+		It("drops and counts the records that do not fit", func() {
+			bufferedSink.Log(lager.LogFormat{Message: "first"})
+			bufferedSink.Log(lager.LogFormat{Message: "second"})
+			bufferedSink.Log(lager.LogFormat{Message: "third"})
+
+			Expect(bufferedSink.Dropped()).To(Equal(uint64(1)))
+			Expect(sink.LogMessages()).To(BeEmpty())
+		})
+	})
+
+	Context("when it is signaled", func() {
+		It("writes the records left in the queue", func() {
+			bufferedSink.Log(lager.LogFormat{Message: "first"})
+			bufferedSink.Log(lager.LogFormat{Message: "second"})
+
+			signals := make(chan os.Signal, 1)
+			signals <- os.Interrupt
+			Expect(bufferedSink.Run(signals, make(chan struct{}))).To(Succeed())
+
+			Expect(sink.LogMessages()).To(Equal([]string{"first", "second"}))
+		})
+	})
+})
+
+var _ = Describe("NewDroppedRecordsSource", func() {
+	It("sums the records dropped by the sinks", func() {
+		first := buffersink.NewBufferedSink(lagertest.NewTestSink(), 0)
+		second := buffersink.NewBufferedSink(lagertest.NewTestSink(), 0)
+		first.Log(lager.LogFormat{})
+		second.Log(lager.LogFormat{})
+		second.Log(lager.LogFormat{})
+
+		source := buffersink.NewDroppedRecordsSource(first, second)
+		Expect(source.Name).To(Equal("iptablesLoggerDroppedRecords"))
+		Expect(source.Getter()).To(Equal(float64(3)))
+	})
+})
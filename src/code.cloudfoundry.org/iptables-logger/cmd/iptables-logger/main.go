@@ -4,17 +4,25 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/user"
+	"strconv"
 	"sync"
 	"time"
 
+	"code.cloudfoundry.org/iptables-logger/buffersink"
 	"code.cloudfoundry.org/iptables-logger/config"
 	"code.cloudfoundry.org/iptables-logger/merger"
 	"code.cloudfoundry.org/iptables-logger/parser"
+	"code.cloudfoundry.org/iptables-logger/remotesink"
 	"code.cloudfoundry.org/iptables-logger/repository"
 	"code.cloudfoundry.org/iptables-logger/runner"
+	"code.cloudfoundry.org/iptables-logger/tags"
 	"code.cloudfoundry.org/iptables-logger/taillogger"
+	"code.cloudfoundry.org/iptables-logger/tenantsink"
 	"code.cloudfoundry.org/lib/common"
+	"code.cloudfoundry.org/lib/containerevents"
 	"code.cloudfoundry.org/lib/datastore"
 	"code.cloudfoundry.org/lib/serial"
 
@@ -29,9 +37,12 @@ import (
 	"io"
 
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
+	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/filelock"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
+	"code.cloudfoundry.org/policy_client"
 )
 
 const (
@@ -39,6 +50,10 @@ const (
 	emitInterval    = 30 * time.Second
 	jobPrefix       = "iptables-logger"
 	logPrefix       = "cfnetworking"
+	clientTimeout   = 5 * time.Second
+	// containerCacheTTL is how long the containers are cached when events of
+	// the containers are received, in case an event is missed
+	containerCacheTTL = 30 * time.Second
 )
 
 func main() {
@@ -92,11 +107,39 @@ func main() {
 	containerRepo := &repository.ContainerRepo{
 		Store: store,
 	}
+	if conf.ContainerEventsSocket != "" {
+		containerRepo.CacheTTL = containerCacheTTL
+	}
 	logMerger := &merger.Merger{
 		ContainerRepo: containerRepo,
 		HostIp:        conf.HostIp,
 		HostGuid:      conf.HostGuid,
 	}
+	var tagResolver *tags.Resolver
+	if conf.PolicyServerURL != "" {
+		clientTLSConfig, err := mutualtls.NewClientTLSConfig(conf.ClientCertFile, conf.ClientKeyFile, conf.CACertFile)
+		if err != nil {
+			logger.Fatal("mutual-tls-config", err)
+		}
+		httpClient := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: clientTLSConfig,
+			},
+			Timeout: clientTimeout,
+		}
+		tagResolver = &tags.Resolver{
+			PolicyClient: policy_client.NewInternal(
+				logger.Session("policy-client"),
+				httpClient,
+				conf.PolicyServerURL,
+				policy_client.DefaultConfig,
+			),
+			Apps:            containerRepo,
+			Logger:          logger.Session("tag-resolver"),
+			RefreshInterval: time.Duration(conf.TagCacheTTLSeconds) * time.Second,
+		}
+		logMerger.TagResolver = tagResolver
+	}
 	iptablesLogger := lager.NewLogger(fmt.Sprintf("%s.iptables", logPrefix))
 	outputLogFile, err := os.OpenFile(conf.OutputLogFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
@@ -115,7 +158,81 @@ func main() {
 	if err != nil {
 		logger.Fatal("rotatable-sink", err)
 	}
-	iptablesLogger.RegisterSink(iptablesSink)
+	iptablesSink.MaxFileSize = int64(conf.MaxOutputFileSizeMiB) * 1024 * 1024
+	bufferedSink := buffersink.NewBufferedSink(iptablesSink, conf.OutputBufferSize)
+	iptablesLogger.RegisterSink(bufferedSink)
+
+	dropCounters := []buffersink.DropCounter{bufferedSink, iptablesSink}
+	sinkMembers := grouper.Members{
+		{Name: "buffered_sink", Runner: bufferedSink},
+	}
+
+	if conf.Syslog.Address != "" {
+		syslogTLSConfig, err := remotesink.NewTLSConfig(conf.Syslog.CACertFile)
+		if err != nil {
+			logger.Fatal("syslog-tls-config", err)
+		}
+		syslogSink := &remotesink.SyslogSink{
+			Address:   conf.Syslog.Address,
+			TLSConfig: syslogTLSConfig,
+			Hostname:  conf.HostIp,
+			Logger:    logger.Session("syslog-sink"),
+		}
+		bufferedSyslogSink := buffersink.NewBufferedSink(syslogSink, conf.OutputBufferSize)
+		iptablesLogger.RegisterSink(bufferedSyslogSink)
+		dropCounters = append(dropCounters, bufferedSyslogSink, syslogSink)
+		sinkMembers = append(sinkMembers, grouper.Member{Name: "syslog_sink", Runner: bufferedSyslogSink})
+	}
+
+	if conf.Webhook.URL != "" {
+		webhookTLSConfig, err := remotesink.NewTLSConfig(conf.Webhook.CACertFile)
+		if err != nil {
+			logger.Fatal("webhook-tls-config", err)
+		}
+		webhookClient := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: webhookTLSConfig,
+			},
+			Timeout: clientTimeout,
+		}
+		webhookSink := remotesink.NewWebhookSink(
+			conf.Webhook.URL,
+			webhookClient,
+			conf.Webhook.BatchSize,
+			time.Duration(conf.Webhook.FlushIntervalSeconds)*time.Second,
+			conf.Webhook.MaxRetries,
+			conf.OutputBufferSize,
+			logger.Session("webhook-sink"),
+		)
+		iptablesLogger.RegisterSink(webhookSink)
+		dropCounters = append(dropCounters, webhookSink)
+		sinkMembers = append(sinkMembers, grouper.Member{Name: "webhook_sink", Runner: webhookSink})
+	}
+
+	if conf.TenantLogs.Directory != "" {
+		gid := -1
+		if conf.TenantLogs.FileGroup != "" {
+			group, err := user.LookupGroup(conf.TenantLogs.FileGroup)
+			if err != nil {
+				logger.Fatal("lookup-tenant-logs-file-group", err)
+			}
+			gid, err = strconv.Atoi(group.Gid)
+			if err != nil {
+				logger.Fatal("lookup-tenant-logs-file-group", err)
+			}
+		}
+		tenantSink := &tenantsink.Sink{
+			Directory:        conf.TenantLogs.Directory,
+			Layout:           conf.TenantLogs.Layout,
+			GID:              gid,
+			PrettyTimestamps: conf.LogTimestampFormat == "rfc3339",
+			Logger:           logger.Session("tenant-sink"),
+		}
+		bufferedTenantSink := buffersink.NewBufferedSink(tenantSink, conf.OutputBufferSize)
+		iptablesLogger.RegisterSink(bufferedTenantSink)
+		dropCounters = append(dropCounters, bufferedTenantSink, tenantSink)
+		sinkMembers = append(sinkMembers, grouper.Member{Name: "tenant_sink", Runner: bufferedTenantSink})
+	}
 
 	err = dropsonde.Initialize(conf.MetronAddress, dropsondeOrigin)
 	if err != nil {
@@ -123,7 +240,8 @@ func main() {
 	}
 
 	uptimeSource := metrics.NewUptimeSource()
-	metricsEmitter := metrics.NewMetricsEmitter(logger, emitInterval, uptimeSource)
+	droppedRecordsSource := buffersink.NewDroppedRecordsSource(dropCounters...)
+	metricsEmitter := metrics.NewMetricsEmitter(logger, emitInterval, uptimeSource, droppedRecordsSource)
 
 	runner := &runner.Runner{
 		Lines:          t.Lines,
@@ -135,7 +253,25 @@ func main() {
 
 	members := grouper.Members{
 		{Name: "metrics_emitter", Runner: metricsEmitter},
-		{Name: "iptables_runner", Runner: runner},
+	}
+	members = append(members, sinkMembers...)
+	members = append(members, grouper.Member{Name: "iptables_runner", Runner: runner})
+
+	if tagResolver != nil {
+		members = append(members, grouper.Member{Name: "tag-resolver", Runner: tagResolver})
+	}
+
+	if conf.ContainerEventsSocket != "" {
+		members = append(members, grouper.Member{Name: "container-events", Runner: &containerevents.Subscriber{
+			Path:    conf.ContainerEventsSocket,
+			Handler: func(containerevents.Event) { containerRepo.Invalidate() },
+			Logger:  logger.Session("container-events"),
+		}})
+	}
+
+	if conf.DebugServerPort != 0 {
+		debugServerAddress := fmt.Sprintf("%s:%d", conf.DebugServerHost, conf.DebugServerPort)
+		members = append(members, grouper.Member{Name: "debug-server", Runner: debugserver.Runner(debugServerAddress, sink)})
 	}
 
 	monitor := ifrit.Invoke(sigmon.New(grouper.NewOrdered(os.Interrupt, members)))
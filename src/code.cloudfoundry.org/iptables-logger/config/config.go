@@ -1,43 +1,105 @@
 package config
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"os"
+	"errors"
 
+	libconfig "code.cloudfoundry.org/lib/config"
 	"gopkg.in/validator.v2"
 )
 
+// DefaultOutputBufferSize is the number of log records queued for the output
+// log file when the config does not set it.
+const DefaultOutputBufferSize = 10000
+
 type Config struct {
 	KernelLogFile         string `json:"kernel_log_file" validate:"nonzero"`
 	ContainerMetadataFile string `json:"container_metadata_file" validate:"nonzero"`
+	// ContainerEventsSocket is where the logger listens for the containers
+	// the cni-wrapper-plugin adds and deletes, so that it can cache the
+	// containers of the datastore between them.
+	ContainerEventsSocket string `json:"container_events_socket"`
 	OutputLogFile         string `json:"output_log_file" validate:"nonzero"`
 	MetronAddress         string `json:"metron_address" validate:"nonzero"`
 	HostIp                string `json:"host_ip" validate:"nonzero"`
 	HostGuid              string `json:"host_guid" validate:"nonzero"`
 
 	LogTimestampFormat string `json:"log_timestamp_format"`
+	DebugServerHost    string `json:"debug_server_host"`
+	DebugServerPort    int    `json:"debug_server_port"`
+
+	PolicyServerURL    string `json:"policy_server_url"`
+	CACertFile         string `json:"ca_cert_file"`
+	ClientCertFile     string `json:"client_cert_file"`
+	ClientKeyFile      string `json:"client_key_file"`
+	TagCacheTTLSeconds int    `json:"tag_cache_ttl_seconds"`
+
+	OutputBufferSize     int `json:"output_buffer_size"`
+	MaxOutputFileSizeMiB int `json:"max_output_file_size_mib"`
+
+	Syslog     SyslogConfig     `json:"syslog"`
+	Webhook    WebhookConfig    `json:"webhook"`
+	TenantLogs TenantLogsConfig `json:"tenant_logs"`
 }
 
-func New(path string) (*Config, error) {
-	if _, err := os.Stat(path); err != nil {
-		return nil, fmt.Errorf("file does not exist: %s", err)
+// SyslogConfig sends the logs to a syslog server over TLS when Address is
+// set.
+type SyslogConfig struct {
+	Address    string `json:"address"`
+	CACertFile string `json:"ca_cert_file"`
+}
+
+// WebhookConfig posts the logs in batches to an HTTPS endpoint when URL is
+// set.
+type WebhookConfig struct {
+	URL                  string `json:"url"`
+	CACertFile           string `json:"ca_cert_file"`
+	BatchSize            int    `json:"batch_size"`
+	FlushIntervalSeconds int    `json:"flush_interval_seconds"`
+	MaxRetries           int    `json:"max_retries"`
+}
+
+// TenantLogsConfig writes the deny logs of each org or space, by Layout, to a
+// file of its own in Directory when it is set. The files are readable by
+// FileGroup, when it is set, so that the logs of a tenant can be handed out
+// without the ones of the others.
+type TenantLogsConfig struct {
+	Directory string `json:"directory"`
+	Layout    string `json:"layout"`
+	FileGroup string `json:"file_group"`
+}
+
+func (c *Config) SetDefaults() {
+	if c.OutputBufferSize == 0 {
+		c.OutputBufferSize = DefaultOutputBufferSize
 	}
-	jsonBytes, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading config: %s", err)
+}
+
+func (c *Config) Validate() error {
+	if err := validator.Validate(c); err != nil {
+		return err
 	}
 
-	cfg := Config{}
-	err = json.Unmarshal(jsonBytes, &cfg)
-	if err != nil {
-		return nil, fmt.Errorf("parsing config: %s", err)
+	if c.Webhook.URL != "" {
+		if c.Webhook.BatchSize < 1 {
+			return errors.New("webhook batch_size must be at least 1")
+		}
+		if c.Webhook.FlushIntervalSeconds < 1 {
+			return errors.New("webhook flush_interval_seconds must be at least 1")
+		}
+		if c.Webhook.MaxRetries < 0 {
+			return errors.New("webhook max_retries must not be negative")
+		}
 	}
 
-	if err := validator.Validate(cfg); err != nil {
-		return &cfg, fmt.Errorf("invalid config: %s", err)
+	if c.TenantLogs.Directory != "" {
+		if c.TenantLogs.Layout != "org" && c.TenantLogs.Layout != "space" {
+			return errors.New("tenant_logs layout must be org or space")
+		}
 	}
+	return nil
+}
 
-	return &cfg, nil
+func New(path string) (*Config, error) {
+	cfg := &Config{}
+	return cfg, libconfig.Load(path, cfg)
 }
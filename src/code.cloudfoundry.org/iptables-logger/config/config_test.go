@@ -29,11 +29,32 @@ var _ = Describe("Config", func() {
 				file.WriteString(`{
 					"kernel_log_file": "/var/log/kern.log",
 					"container_metadata_file": "/var/vcap/data/container-metadata/store.json",
+					"container_events_socket": "/var/vcap/data/container-metadata/events/iptables-logger.sock",
 					"output_log_file": "/var/vcap/sys/log/iptables-logger",
 					"metron_address": "http://1.2.3.4:1234",
 					"host_ip": "1.2.3.4",
 					"host_guid": "some-guid",
-					"log_timestamp_format": "rfc3339"
+					"log_timestamp_format": "rfc3339",
+					"debug_server_host": "127.0.0.1",
+					"debug_server_port": 8724,
+					"output_buffer_size": 500,
+					"max_output_file_size_mib": 100,
+					"syslog": {
+						"address": "syslog.example.com:6514",
+						"ca_cert_file": "/some/syslog/ca.crt"
+					},
+					"webhook": {
+						"url": "https://webhook.example.com/events",
+						"ca_cert_file": "/some/webhook/ca.crt",
+						"batch_size": 100,
+						"flush_interval_seconds": 5,
+						"max_retries": 3
+					},
+					"tenant_logs": {
+						"directory": "/var/vcap/sys/log/iptables-logger/tenants",
+						"layout": "space",
+						"file_group": "tenant-logs"
+					}
 				}`)
 			})
 			It("returns the config", func() {
@@ -41,11 +62,52 @@ var _ = Describe("Config", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(c.KernelLogFile).To(Equal("/var/log/kern.log"))
 				Expect(c.ContainerMetadataFile).To(Equal("/var/vcap/data/container-metadata/store.json"))
+				Expect(c.ContainerEventsSocket).To(Equal("/var/vcap/data/container-metadata/events/iptables-logger.sock"))
 				Expect(c.OutputLogFile).To(Equal("/var/vcap/sys/log/iptables-logger"))
 				Expect(c.MetronAddress).To(Equal("http://1.2.3.4:1234"))
 				Expect(c.HostIp).To(Equal("1.2.3.4"))
 				Expect(c.HostGuid).To(Equal("some-guid"))
 				Expect(c.LogTimestampFormat).To(Equal("rfc3339"))
+				Expect(c.DebugServerHost).To(Equal("127.0.0.1"))
+				Expect(c.DebugServerPort).To(Equal(8724))
+				Expect(c.OutputBufferSize).To(Equal(500))
+				Expect(c.MaxOutputFileSizeMiB).To(Equal(100))
+				Expect(c.Syslog).To(Equal(config.SyslogConfig{
+					Address:    "syslog.example.com:6514",
+					CACertFile: "/some/syslog/ca.crt",
+				}))
+				Expect(c.Webhook).To(Equal(config.WebhookConfig{
+					URL:                  "https://webhook.example.com/events",
+					CACertFile:           "/some/webhook/ca.crt",
+					BatchSize:            100,
+					FlushIntervalSeconds: 5,
+					MaxRetries:           3,
+				}))
+				Expect(c.TenantLogs).To(Equal(config.TenantLogsConfig{
+					Directory: "/var/vcap/sys/log/iptables-logger/tenants",
+					Layout:    "space",
+					FileGroup: "tenant-logs",
+				}))
+			})
+		})
+
+		Context("when the config does not set the output buffer size", func() {
+			BeforeEach(func() {
+				file.WriteString(`{
+					"kernel_log_file": "/var/log/kern.log",
+					"container_metadata_file": "/var/vcap/data/container-metadata/store.json",
+					"output_log_file": "/var/vcap/sys/log/iptables-logger",
+					"metron_address": "http://1.2.3.4:1234",
+					"host_ip": "1.2.3.4",
+					"host_guid": "some-guid"
+				}`)
+			})
+
+			It("defaults it and does not bound the output log file", func() {
+				c, err := config.New(file.Name())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.OutputBufferSize).To(Equal(config.DefaultOutputBufferSize))
+				Expect(c.MaxOutputFileSizeMiB).To(BeZero())
 			})
 		})
 
@@ -71,6 +133,51 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when config file has a key the config does not have", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"kernel_log_fiel": 5}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(ContainSubstring(`parsing config (%s): json: unknown field "kernel_log_fiel"`, file.Name())))
+			})
+		})
+
+		DescribeTable("when the webhook config is invalid",
+			func(webhook map[string]interface{}, errorMsg string) {
+				Expect(json.NewEncoder(file).Encode(map[string]interface{}{
+					"kernel_log_file":         "/var/log/kern.log",
+					"container_metadata_file": "/var/vcap/data/container-metadata/store.json",
+					"output_log_file":         "/var/vcap/sys/log/iptables-logger",
+					"metron_address":          "http://1.2.3.4:1234",
+					"host_ip":                 "1.2.3.4",
+					"host_guid":               "some-guid",
+					"webhook":                 webhook,
+				})).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(errorMsg))
+			},
+			Entry("no batch size", map[string]interface{}{"url": "https://example.com", "flush_interval_seconds": 5}, "invalid config: webhook batch_size must be at least 1"),
+			Entry("no flush interval", map[string]interface{}{"url": "https://example.com", "batch_size": 100}, "invalid config: webhook flush_interval_seconds must be at least 1"),
+			Entry("negative retries", map[string]interface{}{"url": "https://example.com", "batch_size": 100, "flush_interval_seconds": 5, "max_retries": -1}, "invalid config: webhook max_retries must not be negative"),
+		)
+
+		Context("when the tenant logs layout is invalid", func() {
+			It("returns the error", func() {
+				Expect(json.NewEncoder(file).Encode(map[string]interface{}{
+					"kernel_log_file":         "/var/log/kern.log",
+					"container_metadata_file": "/var/vcap/data/container-metadata/store.json",
+					"output_log_file":         "/var/vcap/sys/log/iptables-logger",
+					"metron_address":          "http://1.2.3.4:1234",
+					"host_ip":                 "1.2.3.4",
+					"host_guid":               "some-guid",
+					"tenant_logs":             map[string]interface{}{"directory": "/some/dir", "layout": "app"},
+				})).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: tenant_logs layout must be org or space"))
+			})
+		})
+
 		DescribeTable("when config file is missing a member",
 			func(missingFlag, errorMsg string) {
 				allData := map[string]interface{}{
@@ -2,9 +2,11 @@ package merger
 
 import (
 	"fmt"
+	"strings"
 
 	"code.cloudfoundry.org/iptables-logger/parser"
 	"code.cloudfoundry.org/iptables-logger/repository"
+	"code.cloudfoundry.org/iptables-logger/tags"
 
 	"code.cloudfoundry.org/lager/v3"
 )
@@ -14,6 +16,11 @@ type containerRepo interface {
 	GetByIP(string) (repository.Container, error)
 }
 
+//go:generate counterfeiter -o fakes/tag_resolver.go --fake-name TagResolver . tagResolver
+type tagResolver interface {
+	Resolve(mark string) (tags.App, bool)
+}
+
 type IPTablesLogData struct {
 	Message string
 	Data    lager.Data
@@ -23,6 +30,7 @@ type Merger struct {
 	ContainerRepo containerRepo
 	HostIp        string
 	HostGuid      string
+	TagResolver   tagResolver
 }
 
 func (m *Merger) Merge(parsedData parser.ParsedData) (IPTablesLogData, error) {
@@ -50,11 +58,40 @@ func (m *Merger) Merge(parsedData parser.ParsedData) (IPTablesLogData, error) {
 	containerData.HostIp = m.HostIp
 	containerData.HostGuid = m.HostGuid
 
+	// the prefix of an accepted c2c log line only carries the first group of
+	// each app guid, so the full guids are taken from the resolved apps
+	var sourceApp tags.App
+	var sourceResolved bool
+	if parsedData.Direction == "ingress" && parsedData.Mark != "" && m.TagResolver != nil {
+		sourceApp, sourceResolved = m.TagResolver.Resolve(parsedData.Mark)
+		if sourceResolved && !matchesGUIDPrefix(sourceApp.AppID, parsedData.SourceAppGUIDPrefix) {
+			sourceResolved = false
+		}
+	}
+	if sourceResolved && parsedData.SourceAppGUIDPrefix != "" {
+		parsedData.SourceAppGUID = sourceApp.AppID
+	}
+	if key == "destination" && parsedData.DestinationAppGUIDPrefix != "" &&
+		matchesGUIDPrefix(containerData.AppID, parsedData.DestinationAppGUIDPrefix) {
+		parsedData.DestinationAppGUID = containerData.AppID
+	}
+
+	data := lager.Data{
+		key:      containerData,
+		"packet": parsedData,
+	}
+	if sourceResolved {
+		data["source"] = sourceApp
+	}
+
 	return IPTablesLogData{
 		Message: message,
-		Data: lager.Data{
-			key:      containerData,
-			"packet": parsedData,
-		},
+		Data:    data,
 	}, nil
 }
+
+// matchesGUIDPrefix reports whether guid starts with the prefix of the log
+// line. Lines without a prefix match any guid.
+func matchesGUIDPrefix(guid, prefix string) bool {
+	return guid != "" && strings.HasPrefix(guid, prefix)
+}
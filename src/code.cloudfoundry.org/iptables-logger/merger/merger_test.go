@@ -7,6 +7,7 @@ import (
 	"code.cloudfoundry.org/iptables-logger/merger/fakes"
 	"code.cloudfoundry.org/iptables-logger/parser"
 	"code.cloudfoundry.org/iptables-logger/repository"
+	"code.cloudfoundry.org/iptables-logger/tags"
 
 	"code.cloudfoundry.org/lager/v3"
 
@@ -113,6 +114,123 @@ var _ = Describe("Merger", func() {
 		})
 	})
 
+	Context("when a tag resolver is configured", func() {
+		var fakeTagResolver *fakes.TagResolver
+
+		BeforeEach(func() {
+			fakeTagResolver = &fakes.TagResolver{}
+			fakeTagResolver.ResolveReturns(tags.App{AppID: "some-source-app-id", Tag: "some-mark"}, true)
+			logMerger.TagResolver = fakeTagResolver
+		})
+
+		It("adds the source app of ingress packets", func() {
+			merged, err := logMerger.Merge(parsedData)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeTagResolver.ResolveCallCount()).To(Equal(1))
+			Expect(fakeTagResolver.ResolveArgsForCall(0)).To(Equal("some-mark"))
+
+			Expect(merged).To(Equal(merger.IPTablesLogData{
+				Message: "ingress-allowed",
+				Data: lager.Data{
+					"source":      tags.App{AppID: "some-source-app-id", Tag: "some-mark"},
+					"destination": expectedContainer,
+					"packet":      parsedData,
+				},
+			}))
+		})
+
+		Context("when the log line carries app guid prefixes", func() {
+			BeforeEach(func() {
+				parsedData.SourceAppGUIDPrefix = "some-sou"
+				parsedData.DestinationAppGUIDPrefix = "some-app"
+			})
+
+			It("adds the full app guids to the packet", func() {
+				merged, err := logMerger.Merge(parsedData)
+				Expect(err).NotTo(HaveOccurred())
+
+				packet := merged.Data["packet"].(parser.ParsedData)
+				Expect(packet.SourceAppGUIDPrefix).To(Equal("some-sou"))
+				Expect(packet.SourceAppGUID).To(Equal("some-source-app-id"))
+				Expect(packet.DestinationAppGUIDPrefix).To(Equal("some-app"))
+				Expect(packet.DestinationAppGUID).To(Equal("some-app-id"))
+				Expect(merged.Data["source"]).To(Equal(tags.App{AppID: "some-source-app-id", Tag: "some-mark"}))
+			})
+
+			Context("when the resolved source app does not match the prefix", func() {
+				BeforeEach(func() {
+					parsedData.SourceAppGUIDPrefix = "other-ap"
+				})
+
+				It("leaves out the source app and its guid", func() {
+					merged, err := logMerger.Merge(parsedData)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(merged.Data).NotTo(HaveKey("source"))
+					packet := merged.Data["packet"].(parser.ParsedData)
+					Expect(packet.SourceAppGUID).To(BeEmpty())
+					Expect(packet.DestinationAppGUID).To(Equal("some-app-id"))
+				})
+			})
+
+			Context("when the destination container does not match the prefix", func() {
+				BeforeEach(func() {
+					parsedData.DestinationAppGUIDPrefix = "other-ap"
+				})
+
+				It("leaves out the destination app guid", func() {
+					merged, err := logMerger.Merge(parsedData)
+					Expect(err).NotTo(HaveOccurred())
+
+					packet := merged.Data["packet"].(parser.ParsedData)
+					Expect(packet.DestinationAppGUID).To(BeEmpty())
+					Expect(packet.SourceAppGUID).To(Equal("some-source-app-id"))
+				})
+			})
+		})
+
+		Context("when the tag cannot be resolved", func() {
+			BeforeEach(func() {
+				fakeTagResolver.ResolveReturns(tags.App{}, false)
+			})
+
+			It("leaves out the source app", func() {
+				merged, err := logMerger.Merge(parsedData)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(merged.Data).NotTo(HaveKey("source"))
+			})
+		})
+
+		Context("when the packet has no mark", func() {
+			BeforeEach(func() {
+				parsedData.Mark = ""
+			})
+
+			It("does not resolve it", func() {
+				_, err := logMerger.Merge(parsedData)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeTagResolver.ResolveCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the data is for an egress packet", func() {
+			BeforeEach(func() {
+				parsedData.Direction = "egress"
+			})
+
+			It("does not resolve the mark", func() {
+				merged, err := logMerger.Merge(parsedData)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeTagResolver.ResolveCallCount()).To(Equal(0))
+				Expect(merged.Data).To(Equal(lager.Data{"source": expectedContainer, "packet": parsedData}))
+			})
+		})
+	})
+
 	Context("when the container repo returns an error", func() {
 		BeforeEach(func() {
 			fakeContainerRepo.GetByIPReturns(repository.Container{}, errors.New("banana"))
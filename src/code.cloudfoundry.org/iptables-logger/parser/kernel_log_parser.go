@@ -1,10 +1,15 @@
 package parser
 
 import (
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// c2cAllowedPrefix matches the log prefix of accepted c2c connections, which
+// names the source and destination apps by the first group of their GUIDs.
+var c2cAllowedPrefix = regexp.MustCompile(`\sOK_[0-9A-Fa-f]+_([^_\s]+)_([^_\s]+)\s`)
+
 type ParsedData struct {
 	Direction       string `json:"direction"`
 	Allowed         bool   `json:"allowed"`
@@ -16,6 +21,14 @@ type ParsedData struct {
 	Mark            string `json:"mark"`
 	ICMPType        int    `json:"icmp_type"`
 	ICMPCode        int    `json:"icmp_code"`
+
+	SourceAppGUIDPrefix      string `json:"src_app_guid_prefix,omitempty"`
+	DestinationAppGUIDPrefix string `json:"dst_app_guid_prefix,omitempty"`
+
+	// SourceAppGUID and DestinationAppGUID are set by the merger when the apps
+	// named by the prefixes are resolved.
+	SourceAppGUID      string `json:"src_app_guid,omitempty"`
+	DestinationAppGUID string `json:"dst_app_guid,omitempty"`
 }
 
 type KernelLogParser struct {
@@ -79,5 +92,12 @@ func (k *KernelLogParser) Parse(line string) ParsedData {
 		ICMPType:        icmpType,
 		ICMPCode:        icmpCode,
 	}
+
+	if allowed && direction == "ingress" {
+		if match := c2cAllowedPrefix.FindStringSubmatch(line); match != nil {
+			parsed.SourceAppGUIDPrefix = match[1]
+			parsed.DestinationAppGUIDPrefix = match[2]
+		}
+	}
 	return parsed
 }
@@ -0,0 +1,13 @@
+package remotesink_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRemotesink(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Remotesink Suite")
+}
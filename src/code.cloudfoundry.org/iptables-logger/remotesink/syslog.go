@@ -0,0 +1,104 @@
+package remotesink
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	appName = "iptables-logger"
+
+	// facilityLocal0 is the syslog facility of the records.
+	facilityLocal0 = 16
+
+	dialTimeout = 5 * time.Second
+)
+
+// SyslogSink sends the records as RFC 5424 syslog messages over TLS, framed
+// with octet counting as in RFC 5425. The message is the JSON of the record.
+// A record that cannot be sent, after reconnecting once, is dropped and
+// counted. It writes in the goroutine of its caller, so it is meant to be
+// wrapped in a buffersink.BufferedSink.
+type SyslogSink struct {
+	Address   string
+	TLSConfig *tls.Config
+	Hostname  string
+	Logger    lager.Logger
+
+	conn    io.WriteCloser
+	dropped uint64
+}
+
+func (s *SyslogSink) Log(logFmt lager.LogFormat) {
+	message := s.format(logFmt)
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", s.Address, s.TLSConfig)
+			if err != nil {
+				s.Logger.Error("syslog-dial-failed", err, lager.Data{"address": s.Address})
+				break
+			}
+			s.conn = conn
+		}
+
+		_, err := s.conn.Write(message)
+		if err == nil {
+			return
+		}
+		s.Logger.Error("syslog-write-failed", err, lager.Data{"address": s.Address})
+		s.conn.Close()
+		s.conn = nil
+	}
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+// Dropped is the number of records that could not be sent.
+func (s *SyslogSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *SyslogSink) format(logFmt lager.LogFormat) []byte {
+	hostname := s.Hostname
+	if hostname == "" {
+		hostname = "-"
+	}
+	message := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		facilityLocal0*8+severity(logFmt.LogLevel),
+		timestamp(logFmt).UTC().Format(time.RFC3339Nano),
+		hostname,
+		appName,
+		os.Getpid(),
+		logFmt.ToJSON(),
+	)
+	return []byte(fmt.Sprintf("%d %s", len(message), message))
+}
+
+func severity(level lager.LogLevel) int {
+	switch level {
+	case lager.DEBUG:
+		return 7
+	case lager.ERROR:
+		return 3
+	case lager.FATAL:
+		return 2
+	default:
+		return 6
+	}
+}
+
+// timestamp parses the unix epoch timestamp of lager, falling back to now.
+func timestamp(logFmt lager.LogFormat) time.Time {
+	seconds, err := strconv.ParseFloat(logFmt.Timestamp, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
@@ -0,0 +1,112 @@
+package remotesink_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/iptables-logger/remotesink"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyslogSink", func() {
+	var (
+		listener   net.Listener
+		messages   chan string
+		logger     *lagertest.TestLogger
+		syslogSink *remotesink.SyslogSink
+	)
+
+	BeforeEach(func() {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		serverTLSConfig := server.TLS
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+		server.Close()
+
+		var err error
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", serverTLSConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		messages = make(chan string, 10)
+		go func() {
+			defer GinkgoRecover()
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			for {
+				length, err := reader.ReadString(' ')
+				if err != nil {
+					return
+				}
+				n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+				Expect(err).NotTo(HaveOccurred())
+				message := make([]byte, n)
+				_, err = reader.Read(message)
+				if err != nil {
+					return
+				}
+				messages <- string(message)
+			}
+		}()
+
+		logger = lagertest.NewTestLogger("test")
+		syslogSink = &remotesink.SyslogSink{
+			Address:   listener.Addr().String(),
+			TLSConfig: &tls.Config{RootCAs: pool, ServerName: "example.com"},
+			Hostname:  "10.0.0.1",
+			Logger:    logger,
+		}
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("sends the records as RFC 5424 messages over TLS", func() {
+		syslogSink.Log(lager.LogFormat{
+			Timestamp: "1700000000.500000000",
+			Message:   "cfnetworking.iptables.ingress-denied",
+			LogLevel:  lager.INFO,
+			Data:      lager.Data{"source": lager.Data{"ip": "10.255.0.1"}},
+		})
+
+		var message string
+		Eventually(messages).Should(Receive(&message))
+		Expect(message).To(MatchRegexp(`^<134>1 2023-11-14T22:13:20\.5Z 10\.0\.0\.1 iptables-logger \d+ - - \{`))
+		Expect(message).To(ContainSubstring(`"message":"cfnetworking.iptables.ingress-denied"`))
+		Expect(message).To(ContainSubstring(`"ip":"10.255.0.1"`))
+		Expect(syslogSink.Dropped()).To(BeZero())
+	})
+
+	It("keeps the connection for the next records", func() {
+		syslogSink.Log(lager.LogFormat{Message: "first"})
+		syslogSink.Log(lager.LogFormat{Message: "second"})
+
+		Eventually(messages).Should(Receive(ContainSubstring(`"message":"first"`)))
+		Eventually(messages).Should(Receive(ContainSubstring(`"message":"second"`)))
+	})
+
+	Context("when the syslog server cannot be reached", func() {
+		BeforeEach(func() {
+			listener.Close()
+		})
+
+		It("drops and counts the records", func() {
+			syslogSink.Log(lager.LogFormat{Message: "first"})
+
+			Expect(syslogSink.Dropped()).To(Equal(uint64(1)))
+			Expect(logger.LogMessages()).To(ContainElement("test.syslog-dial-failed"))
+		})
+	})
+})
@@ -0,0 +1,28 @@
+package remotesink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// NewTLSConfig trusts the CA of the remote sink. Without a CA file, the
+// system roots are trusted.
+func NewTLSConfig(caCertFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caCertFile == "" {
+		return tlsConfig, nil
+	}
+
+	caCert, err := os.ReadFile(caCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading ca cert file: %s", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("parsing ca cert file %s: no certificates found", caCertFile)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
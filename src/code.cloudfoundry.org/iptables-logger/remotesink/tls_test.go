@@ -0,0 +1,55 @@
+package remotesink_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/iptables-logger/remotesink"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewTLSConfig", func() {
+	var caCertFile string
+
+	BeforeEach(func() {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		defer server.Close()
+
+		caCertFile = filepath.Join(GinkgoT().TempDir(), "ca.crt")
+		caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		Expect(os.WriteFile(caCertFile, caCert, 0600)).To(Succeed())
+	})
+
+	It("trusts the ca", func() {
+		tlsConfig, err := remotesink.NewTLSConfig(caCertFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tlsConfig.RootCAs).NotTo(BeNil())
+	})
+
+	Context("when there is no ca file", func() {
+		It("trusts the system roots", func() {
+			tlsConfig, err := remotesink.NewTLSConfig("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tlsConfig.RootCAs).To(BeNil())
+		})
+	})
+
+	Context("when the ca file holds no certificate", func() {
+		It("returns an error", func() {
+			Expect(os.WriteFile(caCertFile, []byte("banana"), 0600)).To(Succeed())
+			_, err := remotesink.NewTLSConfig(caCertFile)
+			Expect(err).To(MatchError(ContainSubstring("no certificates found")))
+		})
+	})
+
+	Context("when the ca file does not exist", func() {
+		It("returns an error", func() {
+			_, err := remotesink.NewTLSConfig("/does/not/exist")
+			Expect(err).To(MatchError(ContainSubstring("reading ca cert file")))
+		})
+	})
+})
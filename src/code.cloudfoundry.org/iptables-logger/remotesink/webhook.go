@@ -0,0 +1,144 @@
+package remotesink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// WebhookSink posts the records in batches, as a JSON array, to an HTTPS
+// endpoint. A batch is posted when it is full or FlushInterval after its
+// first record. Failed posts are retried with a doubling interval; a batch
+// that still fails, or that is rejected with a client error, is dropped and
+// its records are counted. Records that arrive while the queue is full are
+// dropped and counted too.
+type WebhookSink struct {
+	URL           string
+	Client        *http.Client
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	RetryInterval time.Duration
+	Logger        lager.Logger
+
+	records chan lager.LogFormat
+	dropped uint64
+}
+
+func NewWebhookSink(url string, client *http.Client, batchSize int, flushInterval time.Duration, maxRetries int, bufferSize int, logger lager.Logger) *WebhookSink {
+	return &WebhookSink{
+		URL:           url,
+		Client:        client,
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+		MaxRetries:    maxRetries,
+		RetryInterval: time.Second,
+		Logger:        logger,
+		records:       make(chan lager.LogFormat, bufferSize),
+	}
+}
+
+func (w *WebhookSink) Log(logFmt lager.LogFormat) {
+	select {
+	case w.records <- logFmt:
+	default:
+		atomic.AddUint64(&w.dropped, 1)
+	}
+}
+
+// Dropped is the number of records that were not delivered.
+func (w *WebhookSink) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Run posts the batches until it is signaled, then posts the records left in
+// the queue.
+func (w *WebhookSink) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	var batch []json.RawMessage
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-signals:
+			w.post(w.drain(batch))
+			return nil
+		case record := <-w.records:
+			batch = append(batch, record.ToJSON())
+			if len(batch) == 1 {
+				flush = time.After(w.FlushInterval)
+			}
+			if len(batch) >= w.BatchSize {
+				w.post(batch)
+				batch, flush = nil, nil
+			}
+		case <-flush:
+			w.post(batch)
+			batch, flush = nil, nil
+		}
+	}
+}
+
+func (w *WebhookSink) drain(batch []json.RawMessage) []json.RawMessage {
+	for {
+		select {
+		case record := <-w.records:
+			batch = append(batch, record.ToJSON())
+		default:
+			return batch
+		}
+	}
+}
+
+func (w *WebhookSink) post(batch []json.RawMessage) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		w.drop(batch, err)
+		return
+	}
+
+	retryInterval := w.RetryInterval
+	for attempt := 0; ; attempt++ {
+		retry, err := w.send(body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= w.MaxRetries {
+			w.drop(batch, err)
+			return
+		}
+		w.Logger.Info("webhook-post-retrying", lager.Data{"url": w.URL, "attempt": attempt + 1, "err": err.Error()})
+		time.Sleep(retryInterval)
+		retryInterval *= 2
+	}
+}
+
+// send posts the body. A failed post is retried, unless the endpoint rejected
+// the body with a client error other than 429.
+func (w *WebhookSink) send(body []byte) (bool, error) {
+	resp, err := w.Client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+func (w *WebhookSink) drop(batch []json.RawMessage, err error) {
+	w.Logger.Error("webhook-post-failed", err, lager.Data{"url": w.URL, "records": len(batch)})
+	atomic.AddUint64(&w.dropped, uint64(len(batch)))
+}
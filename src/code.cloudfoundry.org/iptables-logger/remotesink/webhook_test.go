@@ -0,0 +1,164 @@
+package remotesink_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/iptables-logger/remotesink"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("WebhookSink", func() {
+	var (
+		server      *httptest.Server
+		mutex       sync.Mutex
+		batches     [][]lager.LogFormat
+		statusCodes []int
+		logger      *lagertest.TestLogger
+		webhookSink *remotesink.WebhookSink
+		process     ifrit.Process
+	)
+
+	receivedBatches := func() [][]lager.LogFormat {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return batches
+	}
+
+	BeforeEach(func() {
+		batches = nil
+		statusCodes = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			Expect(r.Method).To(Equal("POST"))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+			var batch []lager.LogFormat
+			Expect(json.NewDecoder(r.Body).Decode(&batch)).To(Succeed())
+
+			statusCode := http.StatusOK
+			if len(statusCodes) > 0 {
+				statusCode, statusCodes = statusCodes[0], statusCodes[1:]
+			}
+			if statusCode == http.StatusOK {
+				batches = append(batches, batch)
+			}
+			w.WriteHeader(statusCode)
+		}))
+
+		logger = lagertest.NewTestLogger("test")
+		webhookSink = remotesink.NewWebhookSink(server.URL, server.Client(), 2, time.Hour, 2, 10, logger)
+		webhookSink.RetryInterval = time.Millisecond
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(webhookSink)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		server.Close()
+	})
+
+	It("posts the records when a batch is full", func() {
+		webhookSink.Log(lager.LogFormat{Message: "first"})
+		webhookSink.Log(lager.LogFormat{Message: "second"})
+
+		Eventually(receivedBatches).Should(HaveLen(1))
+		Expect(receivedBatches()[0]).To(HaveLen(2))
+		Expect(receivedBatches()[0][0].Message).To(Equal("first"))
+		Expect(receivedBatches()[0][1].Message).To(Equal("second"))
+	})
+
+	Context("when the batch is not full after the flush interval", func() {
+		BeforeEach(func() {
+			webhookSink.FlushInterval = 10 * time.Millisecond
+		})
+
+		It("posts the records it has", func() {
+			webhookSink.Log(lager.LogFormat{Message: "first"})
+
+			Eventually(receivedBatches).Should(HaveLen(1))
+			Expect(receivedBatches()[0]).To(HaveLen(1))
+		})
+	})
+
+	Context("when it is signaled", func() {
+		It("posts the records left", func() {
+			webhookSink.Log(lager.LogFormat{Message: "first"})
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+
+			Expect(receivedBatches()).To(HaveLen(1))
+			Expect(receivedBatches()[0][0].Message).To(Equal("first"))
+		})
+	})
+
+	Context("when the endpoint fails", func() {
+		BeforeEach(func() {
+			statusCodes = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+		})
+
+		It("retries the post", func() {
+			webhookSink.Log(lager.LogFormat{Message: "first"})
+			webhookSink.Log(lager.LogFormat{Message: "second"})
+
+			Eventually(receivedBatches).Should(HaveLen(1))
+			Expect(webhookSink.Dropped()).To(BeZero())
+			Expect(logger.LogMessages()).To(ConsistOf(
+				"test.webhook-post-retrying",
+				"test.webhook-post-retrying",
+			))
+		})
+	})
+
+	Context("when the endpoint keeps failing", func() {
+		BeforeEach(func() {
+			statusCodes = []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}
+		})
+
+		It("drops and counts the batch after the retries", func() {
+			webhookSink.Log(lager.LogFormat{Message: "first"})
+			webhookSink.Log(lager.LogFormat{Message: "second"})
+
+			Eventually(webhookSink.Dropped).Should(Equal(uint64(2)))
+			Expect(logger.LogMessages()).To(ContainElement("test.webhook-post-failed"))
+		})
+	})
+
+	Context("when the endpoint rejects the batch", func() {
+		BeforeEach(func() {
+			statusCodes = []int{http.StatusBadRequest}
+		})
+
+		It("drops the batch without retrying", func() {
+			webhookSink.Log(lager.LogFormat{Message: "first"})
+			webhookSink.Log(lager.LogFormat{Message: "second"})
+
+			Eventually(webhookSink.Dropped).Should(Equal(uint64(2)))
+			Expect(logger.LogMessages()).NotTo(ContainElement("test.webhook-post-retrying"))
+		})
+	})
+})
+
+var _ = Describe("WebhookSink queue", func() {
+	It("drops and counts the records when the queue is full", func() {
+		webhookSink := remotesink.NewWebhookSink("https://example.com", http.DefaultClient, 10, time.Hour, 0, 1, lagertest.NewTestLogger("test"))
+		webhookSink.Log(lager.LogFormat{Message: "first"})
+		webhookSink.Log(lager.LogFormat{Message: "second"})
+
+		Expect(webhookSink.Dropped()).To(Equal(uint64(1)))
+	})
+})
@@ -2,51 +2,103 @@ package repository
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"code.cloudfoundry.org/lib/datastore"
 )
 
 type Container struct {
-	Handle   string `json:"container_id"`
-	AppID    string `json:"app_guid"`
-	SpaceID  string `json:"space_guid"`
-	OrgID    string `json:"organization_guid"`
-	HostIp   string `json:"host_ip"`
-	HostGuid string `json:"host_guid"`
+	Handle        string `json:"container_id"`
+	AppID         string `json:"app_guid"`
+	InstanceIndex string `json:"instance_index,omitempty"`
+	SpaceID       string `json:"space_guid"`
+	OrgID         string `json:"organization_guid"`
+	HostIp        string `json:"host_ip"`
+	HostGuid      string `json:"host_guid"`
 }
 
+// ContainerRepo looks up the containers of the datastore. With a CacheTTL,
+// the containers are kept for that long, or until Invalidate is called when
+// a container was added or deleted, instead of checking the datastore for
+// every log.
 type ContainerRepo struct {
-	Store datastore.Datastore
+	Store    datastore.Datastore
+	CacheTTL time.Duration
+
+	mutex    sync.Mutex
+	cached   map[string]datastore.Container
+	cachedAt time.Time
 }
 
-func (c *ContainerRepo) GetByIP(ip string) (Container, error) {
+// Invalidate reads the datastore again on the next lookup.
+func (c *ContainerRepo) Invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cached = nil
+}
+
+func (c *ContainerRepo) readAll() (map[string]datastore.Container, error) {
+	if c.CacheTTL == 0 {
+		return c.Store.ReadAll()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cached != nil && time.Since(c.cachedAt) < c.CacheTTL {
+		return c.cached, nil
+	}
 	containers, err := c.Store.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	c.cached = containers
+	c.cachedAt = time.Now()
+	return containers, nil
+}
+
+func (c *ContainerRepo) GetByIP(ip string) (Container, error) {
+	containers, err := c.readAll()
 	if err != nil {
 		return Container{}, fmt.Errorf("read all: %s", err)
 	}
 
 	for _, container := range containers {
 		if container.IP == ip {
-			appID, ok := container.Metadata["app_id"].(string)
-			if !ok {
-				appID = ""
-			}
-			spaceID, ok := container.Metadata["space_id"].(string)
-			if !ok {
-				spaceID = ""
-			}
-			orgID, ok := container.Metadata["org_id"].(string)
-			if !ok {
-				orgID = ""
-			}
+			metadata, _ := datastore.ParseContainerMetadata(container.Metadata)
 			return Container{
-				Handle:  container.Handle,
-				AppID:   appID,
-				SpaceID: spaceID,
-				OrgID:   orgID,
+				Handle:        container.Handle,
+				AppID:         metadata.AppID,
+				InstanceIndex: metadata.InstanceIndex(),
+				SpaceID:       metadata.SpaceID,
+				OrgID:         metadata.OrgID,
 			}, nil
 		}
 	}
 
 	return Container{}, nil
 }
+
+// AppIDs returns the guids of the apps with containers on this cell, sorted
+// and without duplicates.
+func (c *ContainerRepo) AppIDs() ([]string, error) {
+	containers, err := c.readAll()
+	if err != nil {
+		return nil, fmt.Errorf("read all: %s", err)
+	}
+
+	seen := map[string]bool{}
+	appIDs := []string{}
+	for _, container := range containers {
+		metadata, _ := datastore.ParseContainerMetadata(container.Metadata)
+		if metadata.AppID == "" || seen[metadata.AppID] {
+			continue
+		}
+		seen[metadata.AppID] = true
+		appIDs = append(appIDs, metadata.AppID)
+	}
+	sort.Strings(appIDs)
+
+	return appIDs, nil
+}
@@ -2,6 +2,7 @@ package repository_test
 
 import (
 	"errors"
+	"time"
 
 	"code.cloudfoundry.org/iptables-logger/repository"
 	"code.cloudfoundry.org/lib/datastore"
@@ -38,6 +39,14 @@ var _ = Describe("Container", func() {
 				IP:       "ip-2",
 				Metadata: map[string]interface{}{},
 			},
+			"handle-3": {
+				Handle: "handle-3",
+				IP:     "ip-3",
+				Metadata: map[string]interface{}{
+					"app_id":     "app-3",
+					"log_config": `{"guid":"app-3","index":2}`,
+				},
+			},
 		}
 
 		fakeStore.ReadAllReturns(containers, nil)
@@ -68,6 +77,17 @@ var _ = Describe("Container", func() {
 			}))
 		})
 
+		It("includes the instance index from the log config", func() {
+			container, err := repo.GetByIP("ip-3")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(container).To(Equal(repository.Container{
+				Handle:        "handle-3",
+				AppID:         "app-3",
+				InstanceIndex: "2",
+			}))
+		})
+
 		Context("when unable to read from datastore", func() {
 			BeforeEach(func() {
 				fakeStore.ReadAllReturns(nil, errors.New("apple"))
@@ -89,4 +109,80 @@ var _ = Describe("Container", func() {
 			})
 		})
 	})
+
+	Context("when the containers are cached", func() {
+		BeforeEach(func() {
+			repo.CacheTTL = time.Hour
+		})
+
+		It("reads the store once for several lookups", func() {
+			_, err := repo.GetByIP("ip-1")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = repo.GetByIP("ip-4")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = repo.AppIDs()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeStore.ReadAllCallCount()).To(Equal(1))
+		})
+
+		It("reads the store again after it is invalidated", func() {
+			_, err := repo.GetByIP("ip-4")
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeStore.ReadAllReturns(map[string]datastore.Container{
+				"handle-4": {Handle: "handle-4", IP: "ip-4", Metadata: map[string]interface{}{"app_id": "app-4"}},
+			}, nil)
+			repo.Invalidate()
+
+			container, err := repo.GetByIP("ip-4")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container).To(Equal(repository.Container{Handle: "handle-4", AppID: "app-4"}))
+			Expect(fakeStore.ReadAllCallCount()).To(Equal(2))
+		})
+
+		It("reads the store again once the containers are older than the ttl", func() {
+			repo.CacheTTL = 10 * time.Millisecond
+			_, err := repo.GetByIP("ip-1")
+			Expect(err).NotTo(HaveOccurred())
+
+			time.Sleep(20 * time.Millisecond)
+			_, err = repo.GetByIP("ip-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeStore.ReadAllCallCount()).To(Equal(2))
+		})
+
+		Context("when unable to read from datastore", func() {
+			BeforeEach(func() {
+				fakeStore.ReadAllReturns(nil, errors.New("apple"))
+			})
+
+			It("does not cache the failure", func() {
+				_, err := repo.GetByIP("ip-1")
+				Expect(err).To(MatchError("read all: apple"))
+				_, err = repo.GetByIP("ip-1")
+				Expect(err).To(MatchError("read all: apple"))
+				Expect(fakeStore.ReadAllCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("AppIDs", func() {
+		It("returns the app guids of the containers in the store", func() {
+			appIDs, err := repo.AppIDs()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(appIDs).To(Equal([]string{"app-1", "app-3"}))
+		})
+
+		Context("when unable to read from datastore", func() {
+			BeforeEach(func() {
+				fakeStore.ReadAllReturns(nil, errors.New("apple"))
+			})
+
+			It("returns an error", func() {
+				_, err := repo.AppIDs()
+				Expect(err).To(MatchError("read all: apple"))
+			})
+		})
+	})
 })
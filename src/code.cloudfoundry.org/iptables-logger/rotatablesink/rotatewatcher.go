@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -20,14 +21,38 @@ type RotatableSink struct {
 	writeL                      *sync.Mutex
 	DestinationFileInfo         DestinationFileInfo
 	EnableRFC339TimestampFormat bool
+
+	// MaxFileSize bounds the size of the file. When it is reached, the file is
+	// moved to the same name with a .1 suffix, replacing the one moved before,
+	// so that a burst of logs takes at most twice MaxFileSize on disk. Zero
+	// does not bound the file.
+	MaxFileSize int64
+
+	componentLogger lager.Logger
+	fileSize        int64
+	dropped         uint64
 }
 
 func (rs *RotatableSink) Log(logFmt lager.LogFormat) {
 	rs.writeL.Lock()
 	defer rs.writeL.Unlock()
+	if rs.MaxFileSize > 0 && rs.fileSize >= rs.MaxFileSize {
+		err := rs.rotateFull()
+		if err != nil {
+			rs.componentLogger.Error("rotate-full-file", err)
+			atomic.AddUint64(&rs.dropped, 1)
+			return
+		}
+	}
 	rs.writerSink.Log(logFmt)
 }
 
+// Dropped is the number of records dropped because the file was full and
+// could not be rotated.
+func (rs *RotatableSink) Dropped() uint64 {
+	return atomic.LoadUint64(&rs.dropped)
+}
+
 func NewRotatableSink(fileToWatch string, logLevel lager.LogLevel, fileWriterFactory FileWriterFactory, destinationFileInfo DestinationFileInfo, componentLogger lager.Logger, enableRFC339TimestampFormat bool) (*RotatableSink, error) {
 	var err error
 	rotatableSink := &RotatableSink{
@@ -37,6 +62,7 @@ func NewRotatableSink(fileToWatch string, logLevel lager.LogLevel, fileWriterFac
 		DestinationFileInfo:         destinationFileInfo,
 		writeL:                      new(sync.Mutex),
 		EnableRFC339TimestampFormat: enableRFC339TimestampFormat,
+		componentLogger:             componentLogger,
 	}
 
 	err = rotatableSink.registerFileSink(fileToWatch)
@@ -96,10 +122,38 @@ func (rs *RotatableSink) registerFileSink(fileToWatch string) error {
 func (rs *RotatableSink) rotateFileSink() error {
 	rs.writeL.Lock()
 	defer rs.writeL.Unlock()
-	outputLogFile, err := rs.WriterFactory.NewWriter(rs.fileToWatch)
+	return rs.openFileSink()
+}
+
+// rotateFull moves the full file aside and opens a new one. The caller holds
+// writeL.
+func (rs *RotatableSink) rotateFull() error {
+	err := os.Rename(rs.fileToWatch, rs.fileToWatch+".1")
+	if err != nil {
+		return fmt.Errorf("move full file: %s", err)
+	}
+	err = rs.openFileSink()
+	if err != nil {
+		return err
+	}
+	rs.fileToWatchInode, err = rs.DestinationFileInfo.FileInode(rs.fileToWatch)
+	if err != nil {
+		return fmt.Errorf("get file inode: %s", err)
+	}
+	rs.componentLogger.Info("rotated-full-file", lager.Data{"file": rs.fileToWatch, "max-file-size": rs.MaxFileSize})
+	return nil
+}
+
+func (rs *RotatableSink) openFileSink() error {
+	writer, err := rs.WriterFactory.NewWriter(rs.fileToWatch)
 	if err != nil {
 		return fmt.Errorf("create file writer: %s", err)
 	}
+	rs.fileSize = 0
+	if info, err := os.Stat(rs.fileToWatch); err == nil {
+		rs.fileSize = info.Size()
+	}
+	outputLogFile := &countingWriter{writer: writer, count: &rs.fileSize}
 	if rs.EnableRFC339TimestampFormat {
 		rs.writerSink = lager.NewPrettySink(outputLogFile, rs.minLogLevel)
 	} else {
@@ -108,6 +162,17 @@ func (rs *RotatableSink) rotateFileSink() error {
 	return nil
 }
 
+type countingWriter struct {
+	writer io.Writer
+	count  *int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	*w.count += int64(n)
+	return n, err
+}
+
 type FileWriterFactory interface {
 	NewWriter(fileName string) (io.Writer, error)
 }
@@ -228,6 +228,78 @@ var _ = Describe("Rotatewatcher", func() {
 
 	})
 
+	Describe("MaxFileSize", func() {
+		BeforeEach(func() {
+			var err error
+			rotatableSink, err = rotatablesink.NewRotatableSink(
+				fileToWatchName,
+				lager.DEBUG,
+				rotatablesink.DefaultFileWriterFunc(rotatablesink.DefaultFileWriter),
+				rotatablesink.DefaultDestinationFileInfo{},
+				fakeLogger,
+				false,
+			)
+			Expect(err).NotTo(HaveOccurred())
+			rotatableSink.MaxFileSize = 100
+		})
+
+		AfterEach(func() {
+			os.Remove(fileToWatchName + ".1")
+		})
+
+		It("moves the full file aside and writes to a new one", func() {
+			rotatableSink.Log(lager.LogFormat{Timestamp: "some-timestamp", Message: "first", Data: lager.Data{"padding": strings.Repeat("a", 100)}})
+			rotatableSink.Log(lager.LogFormat{Timestamp: "some-timestamp", Message: "second"})
+
+			Expect(ReadLines(fileToWatchName + ".1")).To(ConsistOf(ContainSubstring(`"message":"first"`)))
+			Expect(ReadLines(fileToWatchName)).To(ConsistOf(ContainSubstring(`"message":"second"`)))
+			Expect(fakeLogger.LogMessages()).To(ContainElement("test.rotated-full-file"))
+		})
+
+		It("replaces the file moved aside before", func() {
+			for _, message := range []string{"first", "second", "third"} {
+				rotatableSink.Log(lager.LogFormat{Timestamp: "some-timestamp", Message: message, Data: lager.Data{"padding": strings.Repeat("a", 100)}})
+			}
+
+			Expect(ReadLines(fileToWatchName + ".1")).To(ConsistOf(ContainSubstring(`"message":"second"`)))
+			Expect(ReadLines(fileToWatchName)).To(ConsistOf(ContainSubstring(`"message":"third"`)))
+		})
+
+		It("counts the size of the file it was started with", func() {
+			Expect(os.WriteFile(fileToWatchName, []byte(strings.Repeat("a", 100)+"\n"), 0600)).To(Succeed())
+			var err error
+			rotatableSink, err = rotatablesink.NewRotatableSink(
+				fileToWatchName,
+				lager.DEBUG,
+				rotatablesink.DefaultFileWriterFunc(rotatablesink.DefaultFileWriter),
+				rotatablesink.DefaultDestinationFileInfo{},
+				fakeLogger,
+				false,
+			)
+			Expect(err).NotTo(HaveOccurred())
+			rotatableSink.MaxFileSize = 100
+
+			rotatableSink.Log(lager.LogFormat{Timestamp: "some-timestamp", Message: "first"})
+
+			Expect(ReadLines(fileToWatchName)).To(ConsistOf(ContainSubstring(`"message":"first"`)))
+		})
+
+		Context("when the full file cannot be moved aside", func() {
+			It("drops and counts the records", func() {
+				rotatableSink.Log(lager.LogFormat{Timestamp: "some-timestamp", Message: "first", Data: lager.Data{"padding": strings.Repeat("a", 100)}})
+				Expect(os.Mkdir(fileToWatchName+".1", 0700)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(fileToWatchName+".1", "file"), nil, 0600)).To(Succeed())
+				defer os.RemoveAll(fileToWatchName + ".1")
+
+				rotatableSink.Log(lager.LogFormat{Timestamp: "some-timestamp", Message: "second"})
+
+				Expect(rotatableSink.Dropped()).To(Equal(uint64(1)))
+				Expect(ReadLines(fileToWatchName)).To(ConsistOf(ContainSubstring(`"message":"first"`)))
+				Expect(fakeLogger.LogMessages()).To(ContainElement("test.rotate-full-file"))
+			})
+		})
+	})
+
 	Describe("FileWriterFactory", func() {
 		It("should return a writer that can write to a file", func() {
 			writer, err := rotatablesink.DefaultFileWriter(fileToWatch.Name())
@@ -0,0 +1,121 @@
+package tags
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/policy_client"
+)
+
+// App is the app that a tag was allocated to by the policy server.
+type App struct {
+	AppID string `json:"app_guid"`
+	Tag   string `json:"tag"`
+}
+
+//go:generate counterfeiter -o fakes/policy_client.go --fake-name PolicyClient . policyClient
+type policyClient interface {
+	GetPoliciesByID(ids ...string) ([]policy_client.Policy, error)
+}
+
+//go:generate counterfeiter -o fakes/app_lister.go --fake-name AppLister . appLister
+type appLister interface {
+	AppIDs() ([]string, error)
+}
+
+// Resolver maps the mark of a logged packet back to the app that sent it. The
+// mark of a c2c packet is the tag of its source app. Only packets to the apps
+// on this cell are logged, so the resolver fetches the policies of those apps
+// from the policy server, every RefreshInterval in the background, and
+// resolves marks from a snapshot of their tags. The last known tags are kept
+// when refreshing them fails.
+type Resolver struct {
+	PolicyClient    policyClient
+	Apps            appLister
+	Logger          lager.Logger
+	RefreshInterval time.Duration
+
+	mutex sync.RWMutex
+	apps  map[uint64]string
+}
+
+func (r *Resolver) Resolve(mark string) (App, bool) {
+	tag, err := parseTag(mark)
+	if err != nil {
+		return App{}, false
+	}
+
+	r.mutex.RLock()
+	appID, ok := r.apps[tag]
+	r.mutex.RUnlock()
+
+	if !ok {
+		return App{}, false
+	}
+	return App{AppID: appID, Tag: mark}, true
+}
+
+// Run refreshes the tags once before it is ready, and then every
+// RefreshInterval until it is signalled.
+func (r *Resolver) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	r.Refresh()
+	close(ready)
+
+	ticker := time.NewTicker(r.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			r.Refresh()
+		}
+	}
+}
+
+// Refresh fetches the tags of the policies of the apps on this cell and
+// replaces the snapshot that marks are resolved from.
+func (r *Resolver) Refresh() {
+	appIDs, err := r.Apps.AppIDs()
+	if err != nil {
+		r.Logger.Error("list-apps", err)
+		return
+	}
+
+	apps := map[uint64]string{}
+	if len(appIDs) > 0 {
+		policies, err := r.PolicyClient.GetPoliciesByID(appIDs...)
+		if err != nil {
+			r.Logger.Error("get-policies", err)
+			return
+		}
+
+		for _, policy := range policies {
+			addTag(apps, policy.Source.Tag, policy.Source.ID)
+			addTag(apps, policy.Destination.Tag, policy.Destination.ID)
+		}
+	}
+
+	r.mutex.Lock()
+	r.apps = apps
+	r.mutex.Unlock()
+}
+
+func addTag(apps map[uint64]string, tag, appID string) {
+	parsed, err := parseTag(tag)
+	if err != nil {
+		return
+	}
+	apps[parsed] = appID
+}
+
+// parseTag reads both the hex tags of the policy server, e.g. "0002", and the
+// marks of the kernel log, e.g. "0x2".
+func parseTag(tag string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(tag, "0x"), 16, 32)
+}
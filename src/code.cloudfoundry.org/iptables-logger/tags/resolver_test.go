@@ -0,0 +1,190 @@
+package tags_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/iptables-logger/tags"
+	"code.cloudfoundry.org/iptables-logger/tags/fakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/policy_client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Resolver", func() {
+	var (
+		resolver         *tags.Resolver
+		fakePolicyClient *fakes.PolicyClient
+		fakeAppLister    *fakes.AppLister
+		logger           *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		fakePolicyClient = &fakes.PolicyClient{}
+		fakePolicyClient.GetPoliciesByIDReturns([]policy_client.Policy{
+			{
+				Source:      policy_client.Source{ID: "some-app-guid", Tag: "0001"},
+				Destination: policy_client.Destination{ID: "some-other-app-guid", Tag: "000A"},
+			},
+			{
+				Source:      policy_client.Source{ID: "some-app-guid", Tag: "0001"},
+				Destination: policy_client.Destination{ID: "yet-another-app-guid", Tag: "0002"},
+			},
+		}, nil)
+		fakeAppLister = &fakes.AppLister{}
+		fakeAppLister.AppIDsReturns([]string{"some-other-app-guid", "yet-another-app-guid"}, nil)
+		logger = lagertest.NewTestLogger("test")
+
+		resolver = &tags.Resolver{
+			PolicyClient:    fakePolicyClient,
+			Apps:            fakeAppLister,
+			Logger:          logger,
+			RefreshInterval: time.Minute,
+		}
+	})
+
+	Describe("Refresh", func() {
+		It("gets the policies of the apps on the cell", func() {
+			resolver.Refresh()
+
+			Expect(fakePolicyClient.GetPoliciesByIDCallCount()).To(Equal(1))
+			Expect(fakePolicyClient.GetPoliciesByIDArgsForCall(0)).To(Equal([]string{"some-other-app-guid", "yet-another-app-guid"}))
+		})
+
+		Context("when there are no apps on the cell", func() {
+			BeforeEach(func() {
+				fakeAppLister.AppIDsReturns([]string{}, nil)
+			})
+
+			It("forgets the tags without getting any policies", func() {
+				resolver.Refresh()
+
+				Expect(fakePolicyClient.GetPoliciesByIDCallCount()).To(Equal(0))
+				_, ok := resolver.Resolve("0x1")
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when listing the apps fails", func() {
+			It("keeps the last known tags and logs the error", func() {
+				resolver.Refresh()
+
+				fakeAppLister.AppIDsReturns(nil, errors.New("apple"))
+				resolver.Refresh()
+
+				Expect(fakePolicyClient.GetPoliciesByIDCallCount()).To(Equal(1))
+				_, ok := resolver.Resolve("0x1")
+				Expect(ok).To(BeTrue())
+				Expect(logger).To(gbytes.Say("list-apps.*apple"))
+			})
+		})
+
+		Context("when getting the policies fails", func() {
+			It("keeps the last known tags and logs the error", func() {
+				resolver.Refresh()
+
+				fakePolicyClient.GetPoliciesByIDReturns(nil, errors.New("banana"))
+				resolver.Refresh()
+
+				app, ok := resolver.Resolve("0x2")
+				Expect(ok).To(BeTrue())
+				Expect(app.AppID).To(Equal("yet-another-app-guid"))
+				Expect(logger).To(gbytes.Say("get-policies.*banana"))
+			})
+		})
+
+		It("replaces the tags", func() {
+			resolver.Refresh()
+
+			fakePolicyClient.GetPoliciesByIDReturns([]policy_client.Policy{
+				{
+					Source:      policy_client.Source{ID: "some-new-app-guid", Tag: "0003"},
+					Destination: policy_client.Destination{ID: "some-other-app-guid", Tag: "000A"},
+				},
+			}, nil)
+			resolver.Refresh()
+
+			app, ok := resolver.Resolve("0x3")
+			Expect(ok).To(BeTrue())
+			Expect(app.AppID).To(Equal("some-new-app-guid"))
+
+			_, ok = resolver.Resolve("0x2")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Resolve", func() {
+		BeforeEach(func() {
+			resolver.Refresh()
+		})
+
+		It("resolves the mark of a packet to the app with that tag", func() {
+			app, ok := resolver.Resolve("0x1")
+			Expect(ok).To(BeTrue())
+			Expect(app).To(Equal(tags.App{AppID: "some-app-guid", Tag: "0x1"}))
+
+			app, ok = resolver.Resolve("0xa")
+			Expect(ok).To(BeTrue())
+			Expect(app).To(Equal(tags.App{AppID: "some-other-app-guid", Tag: "0xa"}))
+
+			app, ok = resolver.Resolve("0x2")
+			Expect(ok).To(BeTrue())
+			Expect(app).To(Equal(tags.App{AppID: "yet-another-app-guid", Tag: "0x2"}))
+		})
+
+		It("does not call the policy server", func() {
+			resolver.Resolve("0x1")
+			resolver.Resolve("0x3")
+
+			Expect(fakePolicyClient.GetPoliciesByIDCallCount()).To(Equal(1))
+		})
+
+		Context("when the tag is not known", func() {
+			It("does not resolve it", func() {
+				_, ok := resolver.Resolve("0x3")
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when the mark is not a tag", func() {
+			It("does not resolve it", func() {
+				_, ok := resolver.Resolve("some-mark")
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	Context("before the tags have been refreshed", func() {
+		It("does not resolve the mark", func() {
+			_, ok := resolver.Resolve("0x1")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Run", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			resolver.RefreshInterval = 10 * time.Millisecond
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		})
+
+		It("refreshes the tags before it is ready and then periodically", func() {
+			process = ifrit.Invoke(resolver)
+
+			_, ok := resolver.Resolve("0x1")
+			Expect(ok).To(BeTrue())
+
+			Eventually(fakePolicyClient.GetPoliciesByIDCallCount).Should(BeNumerically(">", 2))
+		})
+	})
+})
@@ -0,0 +1,159 @@
+package tenantsink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/iptables-logger/repository"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	LayoutOrg   = "org"
+	LayoutSpace = "space"
+)
+
+// FileMode lets the owner write and the group of the files read the logs of a
+// tenant, and nobody else.
+const FileMode os.FileMode = 0640
+
+var guidPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// Sink writes the deny logs of the containers of each org or space, by
+// Layout, to a file of its own in Directory, org-<guid>.log or
+// space-<guid>.log, in the format of the main log file. The files are created
+// with FileMode and owned by GID unless it is -1, so that a tenant can be
+// given access to its own deny logs without the traffic metadata of the
+// other tenants. Logs of containers without the guid of their tenant are
+// only written to the main log file. A file that is moved or removed, e.g. by
+// logrotate, is created again.
+type Sink struct {
+	Directory        string
+	Layout           string
+	GID              int
+	PrettyTimestamps bool
+	Logger           lager.Logger
+
+	mutex   sync.Mutex
+	files   map[string]*tenantFile
+	dropped uint64
+}
+
+type tenantFile struct {
+	file *os.File
+	info os.FileInfo
+	sink lager.Sink
+}
+
+func (s *Sink) Log(logFmt lager.LogFormat) {
+	name, ok := s.fileName(logFmt)
+	if !ok {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	path := filepath.Join(s.Directory, name)
+	file, err := s.open(path)
+	if err != nil {
+		s.Logger.Error("open-tenant-log-file", err, lager.Data{"file": path})
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+	file.sink.Log(logFmt)
+}
+
+// Dropped is the number of deny logs that could not be written to the file of
+// their tenant.
+func (s *Sink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// fileName is the file of the tenant of the container whose traffic was
+// denied: the source of denied egress, and the destination of denied
+// ingress.
+func (s *Sink) fileName(logFmt lager.LogFormat) (string, bool) {
+	var key string
+	switch {
+	case strings.HasSuffix(logFmt.Message, "egress-denied"):
+		key = "source"
+	case strings.HasSuffix(logFmt.Message, "ingress-denied"):
+		key = "destination"
+	default:
+		return "", false
+	}
+
+	container, ok := logFmt.Data[key].(repository.Container)
+	if !ok {
+		return "", false
+	}
+
+	guid := container.OrgID
+	if s.Layout == LayoutSpace {
+		guid = container.SpaceID
+	}
+	if !guidPattern.MatchString(guid) {
+		return "", false
+	}
+	return fmt.Sprintf("%s-%s.log", s.Layout, guid), true
+}
+
+func (s *Sink) open(path string) (*tenantFile, error) {
+	if s.files == nil {
+		s.files = map[string]*tenantFile{}
+	}
+
+	current, ok := s.files[path]
+	if ok {
+		info, err := os.Stat(path)
+		if err == nil && os.SameFile(info, current.info) {
+			return current, nil
+		}
+		current.file.Close()
+		delete(s.files, path)
+	}
+
+	if err := os.MkdirAll(s.Directory, 0750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, FileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.restrict(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	opened := &tenantFile{file: file, info: info}
+	if s.PrettyTimestamps {
+		opened.sink = lager.NewPrettySink(file, lager.DEBUG)
+	} else {
+		opened.sink = lager.NewWriterSink(file, lager.DEBUG)
+	}
+	s.files[path] = opened
+	return opened, nil
+}
+
+// restrict sets the mode and group of the file regardless of the umask and
+// of how the file was created before.
+func (s *Sink) restrict(file *os.File) error {
+	if err := file.Chmod(FileMode); err != nil {
+		return err
+	}
+	if s.GID != -1 {
+		return file.Chown(-1, s.GID)
+	}
+	return nil
+}
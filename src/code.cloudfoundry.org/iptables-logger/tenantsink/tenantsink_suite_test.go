@@ -0,0 +1,13 @@
+package tenantsink_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTenantsink(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tenantsink Suite")
+}
@@ -0,0 +1,139 @@
+package tenantsink_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"code.cloudfoundry.org/iptables-logger/repository"
+	"code.cloudfoundry.org/iptables-logger/tenantsink"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sink", func() {
+	var (
+		dir  string
+		sink *tenantsink.Sink
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "tenant-logs")
+		Expect(err).NotTo(HaveOccurred())
+		sink = &tenantsink.Sink{
+			Directory: filepath.Join(dir, "tenants"),
+			Layout:    tenantsink.LayoutSpace,
+			GID:       os.Getgid(),
+			Logger:    lagertest.NewTestLogger("test"),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	container := func(org, space string) repository.Container {
+		return repository.Container{
+			Handle:  "some-handle",
+			AppID:   "some-app",
+			OrgID:   org,
+			SpaceID: space,
+		}
+	}
+
+	denied := func(direction string, key string, c repository.Container) lager.LogFormat {
+		return lager.LogFormat{
+			Message:  "cfnetworking.iptables." + direction,
+			LogLevel: lager.INFO,
+			Data:     lager.Data{key: c, "packet": map[string]string{"protocol": "TCP"}},
+		}
+	}
+
+	readLines := func(name string) []string {
+		contents, err := os.ReadFile(filepath.Join(dir, "tenants", name))
+		Expect(err).NotTo(HaveOccurred())
+		return strings.Split(strings.TrimSpace(string(contents)), "\n")
+	}
+
+	It("writes the deny logs of each space to its own file", func() {
+		sink.Log(denied("egress-denied", "source", container("org-1", "space-1")))
+		sink.Log(denied("ingress-denied", "destination", container("org-1", "space-2")))
+		sink.Log(denied("egress-denied", "source", container("org-1", "space-1")))
+
+		lines := readLines("space-space-1.log")
+		Expect(lines).To(HaveLen(2))
+		var record map[string]interface{}
+		Expect(json.Unmarshal([]byte(lines[0]), &record)).To(Succeed())
+		Expect(record["message"]).To(Equal("cfnetworking.iptables.egress-denied"))
+		Expect(record["data"]).To(HaveKeyWithValue("source", HaveKeyWithValue("space_guid", "space-1")))
+
+		Expect(readLines("space-space-2.log")).To(HaveLen(1))
+		Expect(sink.Dropped()).To(BeZero())
+	})
+
+	It("restricts the files to the owner and their group", func() {
+		sink.Log(denied("egress-denied", "source", container("org-1", "space-1")))
+
+		info, err := os.Stat(filepath.Join(dir, "tenants", "space-space-1.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+		Expect(int(info.Sys().(*syscall.Stat_t).Gid)).To(Equal(os.Getgid()))
+	})
+
+	It("does not write the allowed logs", func() {
+		sink.Log(denied("egress-allowed", "source", container("org-1", "space-1")))
+		sink.Log(denied("ingress-allowed", "destination", container("org-1", "space-1")))
+
+		Expect(filepath.Join(dir, "tenants", "space-space-1.log")).NotTo(BeAnExistingFile())
+	})
+
+	It("does not write the logs of containers without a valid guid of their tenant", func() {
+		sink.Log(denied("egress-denied", "source", container("org-1", "")))
+		sink.Log(denied("egress-denied", "source", container("org-1", "../../etc/passwd")))
+
+		entries, err := os.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("creates a file that was rotated away again", func() {
+		sink.Log(denied("egress-denied", "source", container("org-1", "space-1")))
+		path := filepath.Join(dir, "tenants", "space-space-1.log")
+		Expect(os.Rename(path, path+".1")).To(Succeed())
+
+		sink.Log(denied("egress-denied", "source", container("org-1", "space-1")))
+
+		Expect(readLines("space-space-1.log")).To(HaveLen(1))
+		Expect(readLines("space-space-1.log.1")).To(HaveLen(1))
+	})
+
+	Context("when the layout is org", func() {
+		BeforeEach(func() {
+			sink.Layout = tenantsink.LayoutOrg
+		})
+
+		It("writes the deny logs of each org to its own file", func() {
+			sink.Log(denied("egress-denied", "source", container("org-1", "space-1")))
+			sink.Log(denied("egress-denied", "source", container("org-1", "space-2")))
+
+			Expect(readLines("org-org-1.log")).To(HaveLen(2))
+		})
+	})
+
+	Context("when the file cannot be created", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(filepath.Join(dir, "tenants"), nil, 0600)).To(Succeed())
+		})
+
+		It("counts the dropped log", func() {
+			sink.Log(denied("egress-denied", "source", container("org-1", "space-1")))
+
+			Expect(sink.Dropped()).To(Equal(uint64(1)))
+		})
+	})
+})
@@ -0,0 +1,98 @@
+// Package config loads the config files that the jobs of silk-release render
+// from their BOSH properties. A config is decoded strictly: a key that its
+// struct does not have, e.g. a misspelled or removed property, fails the
+// load instead of being ignored. The config is then defaulted and validated,
+// so that an invalid combination of properties fails the job at startup.
+//
+// Files ending in .yml or .yaml are read as YAML, all others as JSON.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/validator.v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaulter is a config that fills in the values its properties left unset.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// Validator is a config that validates itself, e.g. the properties that only
+// make sense together. A config that is not a Validator is validated by the
+// validate tags of its fields.
+type Validator interface {
+	Validate() error
+}
+
+// Load reads the config file at path into cfg and checks it.
+func Load(path string, cfg interface{}) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("file does not exist: %s", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %s", err)
+	}
+
+	switch filepath.Ext(path) {
+	case ".yml", ".yaml":
+		data, err = yamlToJSON(data)
+		if err != nil {
+			return fmt.Errorf("parsing config (%s): %s", path, err)
+		}
+	}
+
+	if err := Decode(data, cfg); err != nil {
+		return fmt.Errorf("parsing config (%s): %s", path, err)
+	}
+
+	if err := Check(cfg); err != nil {
+		return fmt.Errorf("invalid config: %s", err)
+	}
+	return nil
+}
+
+// Decode decodes the JSON document in data into cfg. It fails on keys cfg
+// does not have and on anything after the document.
+func Decode(data []byte, cfg interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after the config")
+	}
+	return nil
+}
+
+// Check defaults cfg, if it is a Defaulter, and validates it.
+func Check(cfg interface{}) error {
+	if d, ok := cfg.(Defaulter); ok {
+		d.SetDefaults()
+	}
+	if v, ok := cfg.(Validator); ok {
+		return v.Validate()
+	}
+	return validator.Validate(cfg)
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New("empty config")
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,116 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lib/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type tagged struct {
+	Name  string `json:"name" validate:"nonzero"`
+	Ports []int  `json:"ports"`
+}
+
+type checked struct {
+	Name    string `json:"name"`
+	Retries int    `json:"retries"`
+}
+
+func (c *checked) SetDefaults() {
+	if c.Retries == 0 {
+		c.Retries = 3
+	}
+}
+
+func (c *checked) Validate() error {
+	if c.Name == "" {
+		return errors.New("missing name")
+	}
+	return nil
+}
+
+var _ = Describe("Config", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "config-")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	Describe("Load", func() {
+		It("loads a JSON config", func() {
+			var cfg tagged
+			Expect(config.Load(write("config.json", `{"name": "some-name", "ports": [1, 2]}`), &cfg)).To(Succeed())
+			Expect(cfg).To(Equal(tagged{Name: "some-name", Ports: []int{1, 2}}))
+		})
+
+		It("loads a YAML config", func() {
+			var cfg tagged
+			Expect(config.Load(write("config.yml", "name: some-name\nports:\n- 1\n- 2\n"), &cfg)).To(Succeed())
+			Expect(cfg).To(Equal(tagged{Name: "some-name", Ports: []int{1, 2}}))
+		})
+
+		It("defaults and validates the config", func() {
+			var cfg checked
+			Expect(config.Load(write("config.json", `{"name": "some-name"}`), &cfg)).To(Succeed())
+			Expect(cfg.Retries).To(Equal(3))
+
+			err := config.Load(write("config.json", `{"retries": 1}`), &checked{})
+			Expect(err).To(MatchError("invalid config: missing name"))
+		})
+
+		It("validates the tags of a config without a Validate method", func() {
+			err := config.Load(write("config.json", `{"ports": [1]}`), &tagged{})
+			Expect(err).To(MatchError("invalid config: Name: zero value"))
+		})
+
+		It("fails on keys the config does not have", func() {
+			path := write("config.json", `{"name": "some-name", "prots": [1]}`)
+			err := config.Load(path, &tagged{})
+			Expect(err).To(MatchError(`parsing config (` + path + `): json: unknown field "prots"`))
+		})
+
+		It("fails on unknown keys of a YAML config", func() {
+			err := config.Load(write("config.yaml", "name: some-name\nprots: [1]\n"), &tagged{})
+			Expect(err).To(MatchError(ContainSubstring(`json: unknown field "prots"`)))
+		})
+
+		It("fails on data after the config", func() {
+			err := config.Load(write("config.json", `{"name": "some-name"} {"name": "other-name"}`), &tagged{})
+			Expect(err).To(MatchError(ContainSubstring("unexpected data after the config")))
+		})
+
+		It("fails on an empty YAML config", func() {
+			err := config.Load(write("config.yml", ""), &tagged{})
+			Expect(err).To(MatchError(ContainSubstring("empty config")))
+		})
+
+		It("fails when the file does not exist", func() {
+			err := config.Load(filepath.Join(dir, "missing.json"), &tagged{})
+			Expect(err).To(MatchError(ContainSubstring("file does not exist:")))
+		})
+	})
+
+	Describe("Decode", func() {
+		It("fails on values of the wrong type", func() {
+			err := config.Decode([]byte(`{"ports": "1"}`), &tagged{})
+			Expect(err).To(MatchError(ContainSubstring("cannot unmarshal string")))
+		})
+	})
+})
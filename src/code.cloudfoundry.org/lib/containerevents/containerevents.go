@@ -0,0 +1,164 @@
+// Package containerevents tells the components of a cell when the
+// cni-wrapper-plugin adds or deletes a container, so that they can react to
+// the churn of containers right away instead of polling the datastore for it.
+//
+// Every subscriber listens on a unix datagram socket of its own in a shared
+// directory, e.g. /var/vcap/data/container-events/vxlan-policy-agent.sock,
+// and the plugin sends each event to every socket in the directory. There is
+// no broker, so that a plugin call never depends on another process, and a
+// subscriber that is not running just misses the events. Events are a hint:
+// subscribers keep reading the datastore, only less often or on demand.
+package containerevents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	TypeAdd = "add"
+	TypeDel = "del"
+)
+
+// SocketSuffix ends the names of the sockets of the subscribers.
+const SocketSuffix = ".sock"
+
+// DefaultTimeout is how long the plugin waits for a subscriber that is too
+// busy to take an event.
+const DefaultTimeout = 100 * time.Millisecond
+
+// maxEventSize is more than the largest event, whose fields are limited by
+// the datastore.
+const maxEventSize = 64 * 1024
+
+// Event is a container that was added to or deleted from the datastore.
+type Event struct {
+	Type   string `json:"type"`
+	Handle string `json:"container_id"`
+	IP     string `json:"ip,omitempty"`
+}
+
+// Publisher sends events to the sockets of the subscribers in Directory. A
+// zero Timeout is DefaultTimeout.
+type Publisher struct {
+	Directory string
+	Timeout   time.Duration
+}
+
+// Publish sends the event to every subscriber. Sockets that nobody listens
+// on anymore, e.g. of a subscriber that was stopped, are skipped.
+func (p *Publisher) Publish(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %s", err) // not tested
+	}
+
+	paths, err := filepath.Glob(filepath.Join(p.Directory, "*"+SocketSuffix))
+	if err != nil {
+		return fmt.Errorf("list subscribers: %s", err) // not tested
+	}
+
+	failed := []string{}
+	for _, path := range paths {
+		if err := p.send(path, payload); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", filepath.Base(path), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("publish %s event: %s", event.Type, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (p *Publisher) send(path string, payload []byte) error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	conn, err := net.DialTimeout("unixgram", path, timeout)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err // not tested
+	}
+	_, err = conn.Write(payload)
+	return err
+}
+
+// Subscriber listens for events on the socket at Path and passes them to
+// Handler one at a time. The socket is only writable by its owner, which the
+// plugin runs as.
+type Subscriber struct {
+	Path    string
+	Handler func(Event)
+	Logger  lager.Logger
+}
+
+func (s *Subscriber) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return fmt.Errorf("create socket directory: %s", err)
+	}
+	// the socket of the last run is left behind when it was killed
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove stale socket: %s", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: s.Path, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("listen on %s: %s", s.Path, err)
+	}
+	if err := os.Chmod(s.Path, 0600); err != nil {
+		conn.Close()
+		return fmt.Errorf("restrict socket: %s", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.receive(conn)
+	}()
+	close(ready)
+
+	<-signals
+	conn.Close()
+	<-done
+	os.Remove(s.Path)
+	return nil
+}
+
+func (s *Subscriber) receive(conn *net.UnixConn) {
+	buf := make([]byte, maxEventSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.Logger.Error("read-container-event", err)
+			}
+			return
+		}
+
+		var event Event
+		if err := json.Unmarshal(buf[:n], &event); err != nil {
+			s.Logger.Error("parse-container-event", err)
+			continue
+		}
+		s.Logger.Debug("container-event", lager.Data{"type": event.Type, "container_id": event.Handle})
+		s.Handler(event)
+	}
+}
@@ -0,0 +1,126 @@
+package containerevents_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/containerevents"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Container events", func() {
+	var (
+		dir       string
+		logger    *lagertest.TestLogger
+		publisher *containerevents.Publisher
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "container-events")
+		Expect(err).NotTo(HaveOccurred())
+		logger = lagertest.NewTestLogger("test")
+		publisher = &containerevents.Publisher{Directory: filepath.Join(dir, "events")}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	subscribe := func(name string) (ifrit.Process, chan containerevents.Event) {
+		received := make(chan containerevents.Event, 10)
+		subscriber := &containerevents.Subscriber{
+			Path:    filepath.Join(dir, "events", name+containerevents.SocketSuffix),
+			Handler: func(event containerevents.Event) { received <- event },
+			Logger:  logger,
+		}
+		process := ifrit.Invoke(subscriber)
+		return process, received
+	}
+
+	stop := func(process ifrit.Process) {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	}
+
+	It("sends the events to every subscriber", func() {
+		agent, agentEvents := subscribe("agent")
+		defer stop(agent)
+		logger2, loggerEvents := subscribe("logger")
+		defer stop(logger2)
+
+		event := containerevents.Event{Type: containerevents.TypeAdd, Handle: "some-handle", IP: "10.255.0.1"}
+		Expect(publisher.Publish(event)).To(Succeed())
+		Expect(publisher.Publish(containerevents.Event{Type: containerevents.TypeDel, Handle: "some-handle"})).To(Succeed())
+
+		for _, received := range []chan containerevents.Event{agentEvents, loggerEvents} {
+			Eventually(received).Should(Receive(Equal(event)))
+			Eventually(received).Should(Receive(Equal(containerevents.Event{Type: containerevents.TypeDel, Handle: "some-handle"})))
+		}
+	})
+
+	It("only lets the owner write to the socket", func() {
+		agent, _ := subscribe("agent")
+		defer stop(agent)
+
+		info, err := os.Stat(filepath.Join(dir, "events", "agent.sock"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("removes the socket when it is stopped", func() {
+		agent, _ := subscribe("agent")
+		stop(agent)
+
+		Expect(filepath.Join(dir, "events", "agent.sock")).NotTo(BeAnExistingFile())
+	})
+
+	Context("when the socket of an earlier run was left behind", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(dir, "events"), 0755)).To(Succeed())
+			listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(dir, "events", "agent.sock"), Net: "unixgram"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listener.Close()).To(Succeed())
+		})
+
+		It("skips it when publishing", func() {
+			Expect(publisher.Publish(containerevents.Event{Type: containerevents.TypeAdd, Handle: "some-handle"})).To(Succeed())
+		})
+
+		It("listens on it again", func() {
+			agent, received := subscribe("agent")
+			defer stop(agent)
+
+			Expect(publisher.Publish(containerevents.Event{Type: containerevents.TypeAdd, Handle: "some-handle"})).To(Succeed())
+			Eventually(received).Should(Receive(Equal(containerevents.Event{Type: containerevents.TypeAdd, Handle: "some-handle"})))
+		})
+	})
+
+	Context("when there are no subscribers", func() {
+		It("publishes nothing", func() {
+			Expect(publisher.Publish(containerevents.Event{Type: containerevents.TypeAdd, Handle: "some-handle"})).To(Succeed())
+		})
+	})
+
+	Context("when an event cannot be parsed", func() {
+		It("logs it and keeps receiving", func() {
+			agent, received := subscribe("agent")
+			defer stop(agent)
+
+			conn, err := net.Dial("unixgram", filepath.Join(dir, "events", "agent.sock"))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = conn.Write([]byte("not json"))
+			Expect(err).NotTo(HaveOccurred())
+			conn.Close()
+
+			Eventually(logger).Should(gbytes.Say("parse-container-event"))
+			Expect(publisher.Publish(containerevents.Event{Type: containerevents.TypeDel, Handle: "some-handle"})).To(Succeed())
+			Eventually(received).Should(Receive(Equal(containerevents.Event{Type: containerevents.TypeDel, Handle: "some-handle"})))
+		})
+	})
+})
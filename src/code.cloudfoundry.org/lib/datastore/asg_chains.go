@@ -0,0 +1,91 @@
+package datastore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lib/serial"
+)
+
+// ASGChain is the timestamped chain that holds the ASG rules of a container,
+// along with the per-container parent chain that jumps to it.
+type ASGChain struct {
+	Table       string
+	ParentChain string
+	Chain       string
+}
+
+// ASGChains records the ASG chain that the policy agent last applied for
+// every container, so that the chain can be found without the agent's
+// in-memory state.
+type ASGChains struct {
+	Serializer   serial.Serializer
+	Locker       locker
+	DataFilePath string
+	// OwnsChain tells the recorded chains that Save replaces, when several
+	// agents record their chains in the same file. Nil owns all.
+	OwnsChain func(chain string) bool
+}
+
+func ASGChainsFilePath(datastorePath string) string {
+	return filepath.Join(filepath.Dir(datastorePath), "asg-chains.json")
+}
+
+// Save replaces the recorded chains with the given ones.
+func (c *ASGChains) Save(chains []ASGChain) error {
+	return c.withChains(func(recorded map[string]string) bool {
+		for key, chain := range recorded {
+			if c.OwnsChain == nil || c.OwnsChain(chain) {
+				delete(recorded, key)
+			}
+		}
+		for _, chain := range chains {
+			recorded[chainKey(chain.Table, chain.ParentChain)] = chain.Chain
+		}
+		return true
+	})
+}
+
+func (c *ASGChains) Lookup(table, parentChain string) (string, bool, error) {
+	var chain string
+	var ok bool
+	err := c.withChains(func(recorded map[string]string) bool {
+		chain, ok = recorded[chainKey(table, parentChain)]
+		return false
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return chain, ok, nil
+}
+
+func (c *ASGChains) withChains(f func(map[string]string) bool) error {
+	err := c.Locker.Lock()
+	if err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+	defer c.Locker.Unlock()
+
+	dataFile, err := os.OpenFile(c.DataFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open data file: %s", err)
+	}
+	defer dataFile.Close()
+
+	recorded := make(map[string]string)
+	err = c.Serializer.DecodeAll(dataFile, &recorded)
+	if err != nil {
+		return fmt.Errorf("decoding file: %s", err)
+	}
+
+	if !f(recorded) {
+		return nil
+	}
+
+	err = c.Serializer.EncodeAndOverwrite(dataFile, recorded)
+	if err != nil {
+		return fmt.Errorf("encode and overwrite: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package datastore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/serial"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ASGChains", func() {
+	var (
+		asgChains *datastore.ASGChains
+		locker    *libfakes.Locker
+		dataFile  string
+	)
+
+	BeforeEach(func() {
+		locker = &libfakes.Locker{}
+		dataFile = filepath.Join(GinkgoT().TempDir(), "asg-chains.json")
+		asgChains = &datastore.ASGChains{
+			Serializer:   &serial.Serial{},
+			Locker:       locker,
+			DataFilePath: dataFile,
+		}
+	})
+
+	It("looks up saved chains by parent chain", func() {
+		Expect(asgChains.Save([]datastore.ASGChain{
+			{Table: "filter", ParentChain: "netout--handle-1", Chain: "asg-a1b2c3v1-g7cs183k3a"},
+			{Table: "filter", ParentChain: "netout--handle-2", Chain: "asg-d4e5f6v1-g7cs183k3b"},
+		})).To(Succeed())
+
+		chain, ok, err := asgChains.Lookup("filter", "netout--handle-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(chain).To(Equal("asg-d4e5f6v1-g7cs183k3b"))
+
+		Expect(locker.LockCallCount()).To(Equal(2))
+		Expect(locker.UnlockCallCount()).To(Equal(2))
+	})
+
+	It("replaces previously saved chains", func() {
+		Expect(asgChains.Save([]datastore.ASGChain{
+			{Table: "filter", ParentChain: "netout--handle-1", Chain: "asg-a1b2c3v1-g7cs183k3a"},
+		})).To(Succeed())
+		Expect(asgChains.Save([]datastore.ASGChain{
+			{Table: "filter", ParentChain: "netout--handle-2", Chain: "asg-d4e5f6v1-g7cs183k3b"},
+		})).To(Succeed())
+
+		_, ok, err := asgChains.Lookup("filter", "netout--handle-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	Context("when other agents record chains in the same file", func() {
+		It("only replaces the chains it owns", func() {
+			Expect(asgChains.Save([]datastore.ASGChain{
+				{Table: "filter", ParentChain: "netout--handle-1", Chain: "asg-a1b2c3v1-g7cs183k3a"},
+				{Table: "filter", ParentChain: "netout--handle-2", Chain: "asg-d4e5f6v1-g7cs183k3b"},
+			})).To(Succeed())
+
+			asgChains.OwnsChain = func(chain string) bool {
+				return chain[:10] == "asg-a1b2c3"
+			}
+			Expect(asgChains.Save([]datastore.ASGChain{})).To(Succeed())
+
+			_, ok, err := asgChains.Lookup("filter", "netout--handle-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			chain, ok, err := asgChains.Lookup("filter", "netout--handle-2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(chain).To(Equal("asg-d4e5f6v1-g7cs183k3b"))
+		})
+	})
+
+	It("reports unknown parent chains as missing", func() {
+		_, ok, err := asgChains.Lookup("filter", "netout--handle-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	Context("when locking fails", func() {
+		BeforeEach(func() {
+			locker.LockReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(asgChains.Save(nil)).To(MatchError("lock: banana"))
+		})
+	})
+
+	Context("when the data file is corrupt", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(dataFile, []byte("{"), 0600)).To(Succeed())
+		})
+
+		It("returns the error", func() {
+			_, _, err := asgChains.Lookup("filter", "netout--handle-1")
+			Expect(err).To(MatchError(ContainSubstring("decoding file")))
+		})
+	})
+
+	It("places the file next to the datastore", func() {
+		Expect(datastore.ASGChainsFilePath("/var/vcap/data/container-metadata/store.json")).To(Equal("/var/vcap/data/container-metadata/asg-chains.json"))
+	})
+})
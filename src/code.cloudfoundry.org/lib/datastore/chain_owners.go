@@ -0,0 +1,151 @@
+package datastore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lib/serial"
+)
+
+// OwnedChain identifies an iptables chain. When Jump is set, the owner also
+// claims the head of the chain: the jump to Jump is kept as its first rule
+// and other writers must insert their rules below it.
+type OwnedChain struct {
+	Table string
+	Chain string
+	Jump  string
+}
+
+type ChainOwner struct {
+	Owner   string    `json:"owner"`
+	Jump    string    `json:"jump,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+func (o ChainOwner) expired(now time.Time) bool {
+	return !o.Expires.IsZero() && !now.Before(o.Expires)
+}
+
+type ChainOwnedError struct {
+	Table string
+	Chain string
+	Owner string
+}
+
+func (e *ChainOwnedError) Error() string {
+	return fmt.Sprintf("chain %s/%s is owned by %s", e.Table, e.Chain, e.Owner)
+}
+
+// ChainOwners records which component writes each cell-global chain, so that
+// the policy agent, the wrapper plugin and the silk daemon do not fight over
+// the same chains. Claims expire after TTL unless renewed, so a component
+// that stops running does not hold its chains forever. With a TTL of zero
+// claims are held until they are released, which suits the per-container
+// chains the wrapper plugin removes when the container is deleted.
+type ChainOwners struct {
+	Serializer   serial.Serializer
+	Locker       locker
+	DataFilePath string
+	TTL          time.Duration
+}
+
+// ChainOwnersFilePath places the registry next to the container datastore so
+// that every component with access to the datastore can find it.
+func ChainOwnersFilePath(datastorePath string) string {
+	return filepath.Join(filepath.Dir(datastorePath), "chain-owners.json")
+}
+
+// Claim takes or renews ownership of the given chains. It fails without
+// claiming any of them if one is held by a different owner.
+func (c *ChainOwners) Claim(owner string, chains ...OwnedChain) error {
+	return c.update(func(owners map[string]ChainOwner, now time.Time) error {
+		for _, chain := range chains {
+			current, ok := owners[chainKey(chain.Table, chain.Chain)]
+			if ok && current.Owner != owner && !current.expired(now) {
+				return &ChainOwnedError{Table: chain.Table, Chain: chain.Chain, Owner: current.Owner}
+			}
+		}
+		var expires time.Time
+		if c.TTL != 0 {
+			expires = now.Add(c.TTL)
+		}
+		for _, chain := range chains {
+			owners[chainKey(chain.Table, chain.Chain)] = ChainOwner{
+				Owner:   owner,
+				Jump:    chain.Jump,
+				Expires: expires,
+			}
+		}
+		return nil
+	})
+}
+
+func (c *ChainOwners) Release(owner string, chains ...OwnedChain) error {
+	return c.update(func(owners map[string]ChainOwner, _ time.Time) error {
+		for _, chain := range chains {
+			key := chainKey(chain.Table, chain.Chain)
+			if owners[key].Owner == owner {
+				delete(owners, key)
+			}
+		}
+		return nil
+	})
+}
+
+// Owner returns the current owner of a chain. Expired claims are ignored.
+func (c *ChainOwners) Owner(table, chain string) (ChainOwner, bool, error) {
+	var current ChainOwner
+	var ok bool
+	err := c.withOwners(func(owners map[string]ChainOwner, now time.Time) (bool, error) {
+		current, ok = owners[chainKey(table, chain)]
+		ok = ok && !current.expired(now)
+		return false, nil
+	})
+	if err != nil || !ok {
+		return ChainOwner{}, false, err
+	}
+	return current, true, nil
+}
+
+func (c *ChainOwners) update(f func(map[string]ChainOwner, time.Time) error) error {
+	return c.withOwners(func(owners map[string]ChainOwner, now time.Time) (bool, error) {
+		return true, f(owners, now)
+	})
+}
+
+func (c *ChainOwners) withOwners(f func(map[string]ChainOwner, time.Time) (bool, error)) error {
+	err := c.Locker.Lock()
+	if err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+	defer c.Locker.Unlock()
+
+	dataFile, err := os.OpenFile(c.DataFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open data file: %s", err)
+	}
+	defer dataFile.Close()
+
+	owners := make(map[string]ChainOwner)
+	err = c.Serializer.DecodeAll(dataFile, &owners)
+	if err != nil {
+		return fmt.Errorf("decoding file: %s", err)
+	}
+
+	write, err := f(owners, time.Now())
+	if err != nil || !write {
+		return err
+	}
+
+	err = c.Serializer.EncodeAndOverwrite(dataFile, owners)
+	if err != nil {
+		return fmt.Errorf("encode and overwrite: %s", err)
+	}
+	return nil
+}
+
+func chainKey(table, chain string) string {
+	return table + "/" + chain
+}
@@ -0,0 +1,149 @@
+package datastore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/serial"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChainOwners", func() {
+	var (
+		owners   *datastore.ChainOwners
+		locker   *libfakes.Locker
+		dataFile string
+		egress   datastore.OwnedChain
+		forward  datastore.OwnedChain
+	)
+
+	BeforeEach(func() {
+		locker = &libfakes.Locker{}
+		dataFile = filepath.Join(GinkgoT().TempDir(), "chain-owners.json")
+		owners = &datastore.ChainOwners{
+			Serializer:   &serial.Serial{},
+			Locker:       locker,
+			DataFilePath: dataFile,
+			TTL:          time.Minute,
+		}
+		egress = datastore.OwnedChain{Table: "filter", Chain: "silk-egress"}
+		forward = datastore.OwnedChain{Table: "filter", Chain: "FORWARD", Jump: "silk-egress"}
+	})
+
+	It("records the owner of claimed chains", func() {
+		Expect(owners.Claim("silk-daemon", egress, forward)).To(Succeed())
+
+		owner, ok, err := owners.Owner("filter", "FORWARD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(owner.Owner).To(Equal("silk-daemon"))
+		Expect(owner.Jump).To(Equal("silk-egress"))
+		Expect(owner.Expires).To(BeTemporally("~", time.Now().Add(time.Minute), 5*time.Second))
+
+		Expect(locker.LockCallCount()).To(Equal(2))
+		Expect(locker.UnlockCallCount()).To(Equal(2))
+	})
+
+	It("reports unclaimed chains as unowned", func() {
+		_, ok, err := owners.Owner("filter", "FORWARD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("lets the owner renew its claim", func() {
+		Expect(owners.Claim("silk-daemon", forward)).To(Succeed())
+		Expect(owners.Claim("silk-daemon", forward)).To(Succeed())
+	})
+
+	Context("when a chain is owned by someone else", func() {
+		BeforeEach(func() {
+			Expect(owners.Claim("silk-daemon", forward)).To(Succeed())
+		})
+
+		It("refuses the claim without claiming any of the chains", func() {
+			err := owners.Claim("vxlan-policy-agent", egress, forward)
+			Expect(err).To(MatchError("chain filter/FORWARD is owned by silk-daemon"))
+
+			var ownedErr *datastore.ChainOwnedError
+			Expect(errors.As(err, &ownedErr)).To(BeTrue())
+
+			_, ok, err := owners.Owner("filter", "silk-egress")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("allows the claim once the chain is released", func() {
+			Expect(owners.Release("vxlan-policy-agent", forward)).To(Succeed())
+			Expect(owners.Claim("vxlan-policy-agent", forward)).To(MatchError(ContainSubstring("owned by silk-daemon")))
+
+			Expect(owners.Release("silk-daemon", forward)).To(Succeed())
+			Expect(owners.Claim("vxlan-policy-agent", forward)).To(Succeed())
+		})
+	})
+
+	Context("when the previous claim has expired", func() {
+		BeforeEach(func() {
+			owners.TTL = -time.Second
+			Expect(owners.Claim("silk-daemon", forward)).To(Succeed())
+			owners.TTL = time.Minute
+		})
+
+		It("ignores it", func() {
+			_, ok, err := owners.Owner("filter", "FORWARD")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+
+			Expect(owners.Claim("vxlan-policy-agent", forward)).To(Succeed())
+		})
+	})
+
+	Context("when the TTL is zero", func() {
+		BeforeEach(func() {
+			owners.TTL = 0
+			Expect(owners.Claim("cni-wrapper-plugin", egress)).To(Succeed())
+		})
+
+		It("holds the claim until it is released", func() {
+			owner, ok, err := owners.Owner("filter", "silk-egress")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(owner.Expires.IsZero()).To(BeTrue())
+
+			Expect(owners.Release("cni-wrapper-plugin", egress)).To(Succeed())
+			_, ok, err = owners.Owner("filter", "silk-egress")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("when locking fails", func() {
+		BeforeEach(func() {
+			locker.LockReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(owners.Claim("silk-daemon", forward)).To(MatchError("lock: banana"))
+		})
+	})
+
+	Context("when the data file is corrupt", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(dataFile, []byte("{"), 0600)).To(Succeed())
+		})
+
+		It("returns the error", func() {
+			_, _, err := owners.Owner("filter", "FORWARD")
+			Expect(err).To(MatchError(ContainSubstring("decoding file")))
+		})
+	})
+
+	It("places the registry next to the datastore", func() {
+		Expect(datastore.ChainOwnersFilePath("/var/vcap/data/container-metadata/store.json")).To(Equal("/var/vcap/data/container-metadata/chain-owners.json"))
+	})
+})
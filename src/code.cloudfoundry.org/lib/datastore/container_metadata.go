@@ -0,0 +1,155 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Keys of the garden network properties that the wrapper plugin records as
+// container metadata.
+const (
+	MetadataKeyAppID         = "app_id"
+	MetadataKeyPolicyGroupID = "policy_group_id"
+	MetadataKeySpaceID       = "space_id"
+	MetadataKeyOrgID         = "org_id"
+	MetadataKeyPorts         = "ports"
+	MetadataKeyWorkload      = "container_workload"
+	MetadataKeyLogConfig     = "log_config"
+)
+
+// LogConfig is the log_config property that diego sets on app containers. It
+// has the same fields as executor.LogConfig.
+type LogConfig struct {
+	Guid       string            `json:"guid"`
+	Index      int               `json:"index"`
+	SourceName string            `json:"source_name"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// ContainerMetadata is the schema of the metadata recorded for a container.
+// Components read the metadata of a container through it instead of looking
+// up keys of the property map themselves.
+type ContainerMetadata struct {
+	AppID         string
+	PolicyGroupID string
+	SpaceID       string
+	OrgID         string
+	Ports         string
+	Workload      string
+	LogConfig     LogConfig
+}
+
+// ParseContainerMetadata reads the metadata of a container. Missing keys are
+// left empty. When the log config cannot be parsed, the other fields are
+// still returned along with the error.
+func ParseContainerMetadata(metadata map[string]interface{}) (ContainerMetadata, error) {
+	parsed := ContainerMetadata{
+		AppID:         stringValue(metadata, MetadataKeyAppID),
+		PolicyGroupID: stringValue(metadata, MetadataKeyPolicyGroupID),
+		SpaceID:       stringValue(metadata, MetadataKeySpaceID),
+		OrgID:         stringValue(metadata, MetadataKeyOrgID),
+		Ports:         stringValue(metadata, MetadataKeyPorts),
+		Workload:      stringValue(metadata, MetadataKeyWorkload),
+	}
+
+	logConfig := stringValue(metadata, MetadataKeyLogConfig)
+	if logConfig != "" {
+		err := json.Unmarshal([]byte(logConfig), &parsed.LogConfig)
+		if err != nil {
+			return parsed, fmt.Errorf("unmarshal %s: %s", MetadataKeyLogConfig, err)
+		}
+	}
+
+	return parsed, nil
+}
+
+// NormalizeContainerMetadata validates the metadata of a container before it
+// is recorded and returns a copy in canonical form, so that readers of the
+// datastore can rely on the schema. The known keys must hold strings, the
+// ports must be a comma separated list of port numbers and the log config
+// must be valid JSON. Ports are written without spaces and the log config is
+// re-encoded. Other keys are kept as they are.
+func NormalizeContainerMetadata(metadata map[string]interface{}) (map[string]interface{}, error) {
+	normalized := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		normalized[key] = value
+	}
+
+	for _, key := range []string{
+		MetadataKeyAppID,
+		MetadataKeyPolicyGroupID,
+		MetadataKeySpaceID,
+		MetadataKeyOrgID,
+		MetadataKeyPorts,
+		MetadataKeyWorkload,
+		MetadataKeyLogConfig,
+	} {
+		value, ok := metadata[key]
+		if !ok {
+			continue
+		}
+		if _, isString := value.(string); !isString {
+			return nil, fmt.Errorf("metadata %s: expected a string, got %T", key, value)
+		}
+	}
+
+	if ports := stringValue(metadata, MetadataKeyPorts); ports != "" {
+		normalizedPorts, err := normalizePorts(ports)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %s", MetadataKeyPorts, err)
+		}
+		normalized[MetadataKeyPorts] = normalizedPorts
+	}
+
+	parsed, err := ParseContainerMetadata(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if stringValue(metadata, MetadataKeyLogConfig) != "" {
+		err = SetLogConfig(normalized, parsed.LogConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return normalized, nil
+}
+
+func normalizePorts(ports string) (string, error) {
+	normalized := []string{}
+	for _, port := range strings.Split(ports, ",") {
+		port = strings.TrimSpace(port)
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return "", fmt.Errorf("invalid port %q", port)
+		}
+		normalized = append(normalized, strconv.Itoa(number))
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+// SetLogConfig records the log config in the metadata of a container.
+func SetLogConfig(metadata map[string]interface{}, logConfig LogConfig) error {
+	b, err := json.Marshal(logConfig)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %s", MetadataKeyLogConfig, err)
+	}
+	metadata[MetadataKeyLogConfig] = string(b)
+	return nil
+}
+
+// InstanceIndex returns the app instance index, or "" when the container has
+// no log config.
+func (m ContainerMetadata) InstanceIndex() string {
+	if m.LogConfig.Guid == "" {
+		return ""
+	}
+	return strconv.Itoa(m.LogConfig.Index)
+}
+
+func stringValue(metadata map[string]interface{}, key string) string {
+	value, _ := metadata[key].(string)
+	return value
+}
@@ -0,0 +1,112 @@
+package datastore_test
+
+import (
+	"code.cloudfoundry.org/lib/datastore"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ContainerMetadata", func() {
+	It("parses the metadata recorded by the wrapper plugin", func() {
+		parsed, err := datastore.ParseContainerMetadata(map[string]interface{}{
+			"app_id":             "some-app-guid",
+			"policy_group_id":    "some-policy-group-id",
+			"space_id":           "some-space-guid",
+			"org_id":             "some-org-guid",
+			"ports":              "8080, 9090",
+			"container_workload": "app",
+			"log_config":         `{"guid":"some-app-guid","index":2,"source_name":"APP/PROC/WEB","tags":{"app_name":"some-app"}}`,
+			"other":              "ignored",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(datastore.ContainerMetadata{
+			AppID:         "some-app-guid",
+			PolicyGroupID: "some-policy-group-id",
+			SpaceID:       "some-space-guid",
+			OrgID:         "some-org-guid",
+			Ports:         "8080, 9090",
+			Workload:      "app",
+			LogConfig: datastore.LogConfig{
+				Guid:       "some-app-guid",
+				Index:      2,
+				SourceName: "APP/PROC/WEB",
+				Tags:       map[string]string{"app_name": "some-app"},
+			},
+		}))
+		Expect(parsed.InstanceIndex()).To(Equal("2"))
+	})
+
+	It("leaves missing and non-string values empty", func() {
+		parsed, err := datastore.ParseContainerMetadata(map[string]interface{}{
+			"app_id": 42,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(datastore.ContainerMetadata{}))
+		Expect(parsed.InstanceIndex()).To(Equal(""))
+	})
+
+	Context("when the log config is malformed", func() {
+		It("returns the other fields and an error", func() {
+			parsed, err := datastore.ParseContainerMetadata(map[string]interface{}{
+				"policy_group_id": "some-policy-group-id",
+				"log_config":      "{",
+			})
+			Expect(err).To(MatchError(ContainSubstring("unmarshal log_config")))
+			Expect(parsed.PolicyGroupID).To(Equal("some-policy-group-id"))
+		})
+	})
+
+	It("records a log config", func() {
+		metadata := map[string]interface{}{}
+		Expect(datastore.SetLogConfig(metadata, datastore.LogConfig{Guid: "some-app-guid", Index: 1})).To(Succeed())
+
+		parsed, err := datastore.ParseContainerMetadata(metadata)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.LogConfig.Guid).To(Equal("some-app-guid"))
+		Expect(parsed.InstanceIndex()).To(Equal("1"))
+	})
+
+	Describe("NormalizeContainerMetadata", func() {
+		It("returns the metadata in canonical form", func() {
+			metadata := map[string]interface{}{
+				"app_id":     "some-app-guid",
+				"ports":      " 8080, 9090 ",
+				"log_config": `{ "index": 2, "guid": "some-app-guid" }`,
+				"other":      []string{"kept"},
+			}
+
+			normalized, err := datastore.NormalizeContainerMetadata(metadata)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(normalized).To(Equal(map[string]interface{}{
+				"app_id":     "some-app-guid",
+				"ports":      "8080,9090",
+				"log_config": `{"guid":"some-app-guid","index":2,"source_name":"","tags":null}`,
+				"other":      []string{"kept"},
+			}))
+			Expect(metadata["ports"]).To(Equal(" 8080, 9090 "), "the metadata passed in is not changed")
+		})
+
+		It("accepts empty and missing metadata", func() {
+			normalized, err := datastore.NormalizeContainerMetadata(map[string]interface{}{"ports": ""})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(normalized).To(Equal(map[string]interface{}{"ports": ""}))
+
+			normalized, err = datastore.NormalizeContainerMetadata(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(normalized).To(BeEmpty())
+		})
+
+		DescribeTable("rejects malformed metadata",
+			func(metadata map[string]interface{}, expectedErr string) {
+				_, err := datastore.NormalizeContainerMetadata(metadata)
+				Expect(err).To(MatchError(expectedErr))
+			},
+			Entry("a known key that is not a string", map[string]interface{}{"space_id": 42}, "metadata space_id: expected a string, got int"),
+			Entry("a port that is not a number", map[string]interface{}{"ports": "8080,http"}, `metadata ports: invalid port "http"`),
+			Entry("a port out of range", map[string]interface{}{"ports": "70000"}, `metadata ports: invalid port "70000"`),
+			Entry("an empty port", map[string]interface{}{"ports": "8080,"}, `metadata ports: invalid port ""`),
+			Entry("a log config that is not JSON", map[string]interface{}{"log_config": "{"}, "unmarshal log_config: unexpected end of JSON input"),
+		)
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"os/user"
 	"strconv"
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/lib/serial"
 )
@@ -29,6 +30,20 @@ type Container struct {
 	Handle   string                 `json:"handle"`
 	IP       string                 `json:"ip"`
 	Metadata map[string]interface{} `json:"metadata"`
+	// Enforcement is the result of the last enforcement of the ASG rules of
+	// the container by the policy agent, if they were enforced.
+	Enforcement *EnforcementStatus `json:"enforcement,omitempty"`
+}
+
+// EnforcementStatus is the result of an enforcement of the ASG rules of a
+// container: the chain they were enforced in, when, the hash of the rules,
+// and the error, if the enforcement failed. A container whose status has no
+// error has the rules with the hash enforced.
+type EnforcementStatus struct {
+	Chain      string    `json:"chain"`
+	EnforcedAt time.Time `json:"enforced_at"`
+	RulesHash  string    `json:"rules_hash"`
+	Error      string    `json:"error,omitempty"`
 }
 
 type Store struct {
@@ -86,15 +101,69 @@ func (c *Store) AddOrUpdate(handle, ip string, metadata map[string]interface{},
 		return fmt.Errorf("decoding file: %s", err)
 	}
 
-	_, ok := pool[handle]
+	existing, ok := pool[handle]
 	if !ok && update {
 		return fmt.Errorf("entry does not exist")
 	}
-	pool[handle] = Container{
+	container := Container{
 		Handle:   handle,
 		IP:       ip,
 		Metadata: metadata,
 	}
+	if update {
+		container.Enforcement = existing.Enforcement
+	}
+	pool[handle] = container
+
+	err = c.Serializer.EncodeAndOverwrite(dataFile, pool)
+	if err != nil {
+		return fmt.Errorf("encode and overwrite: %s", err)
+	}
+
+	err = c.updateVersion()
+	if err != nil {
+		return err
+	}
+
+	return c.ensureFileOwnership()
+}
+
+// SaveEnforcementStatuses records the enforcement statuses of the containers
+// by their handles. The statuses of containers that are no longer in the
+// store are dropped.
+func (c *Store) SaveEnforcementStatuses(statuses map[string]EnforcementStatus) error {
+	err := c.Locker.Lock()
+	if err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+	defer c.Locker.Unlock()
+
+	dataFile, err := os.OpenFile(c.DataFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open data file: %s", err)
+	}
+	defer dataFile.Close()
+
+	pool := make(map[string]Container)
+	err = c.Serializer.DecodeAll(dataFile, &pool)
+	if err != nil {
+		return fmt.Errorf("decoding file: %s", err)
+	}
+
+	changed := false
+	for handle, status := range statuses {
+		container, ok := pool[handle]
+		if !ok {
+			continue
+		}
+		status := status
+		container.Enforcement = &status
+		pool[handle] = container
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
 
 	err = c.Serializer.EncodeAndOverwrite(dataFile, pool)
 	if err != nil {
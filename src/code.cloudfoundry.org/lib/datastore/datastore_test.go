@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/user"
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/lib/datastore"
 	libfakes "code.cloudfoundry.org/lib/fakes"
@@ -218,6 +219,27 @@ var _ = Describe("Datastore", func() {
 			Expect(actual).To(Equal(expected))
 		})
 
+		It("keeps the enforcement status of the entry", func() {
+			status := &datastore.EnforcementStatus{Chain: "asg-abc123", RulesHash: "some-hash"}
+			serializer.DecodeAllStub = func(_ io.ReadSeeker, a interface{}) error {
+				b := a.(*map[string]datastore.Container)
+				*b = map[string]datastore.Container{
+					handle: datastore.Container{
+						Handle:      handle,
+						IP:          ip,
+						Metadata:    metadata,
+						Enforcement: status,
+					},
+				}
+				return nil
+			}
+			err := store.Update(handle, ip, metadata)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, actual := serializer.EncodeAndOverwriteArgsForCall(0)
+			Expect(actual.(map[string]datastore.Container)[handle].Enforcement).To(Equal(status))
+		})
+
 		It("doesn't re-add things that don't exist", func() {
 			err := store.Update(handle, ip, metadata)
 			Expect(err).To(HaveOccurred())
@@ -225,6 +247,84 @@ var _ = Describe("Datastore", func() {
 		})
 	})
 
+	Context("when saving enforcement statuses", func() {
+		var enforcedAt time.Time
+
+		BeforeEach(func() {
+			enforcedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			serializer.DecodeAllStub = func(_ io.ReadSeeker, a interface{}) error {
+				b := a.(*map[string]datastore.Container)
+				*b = map[string]datastore.Container{
+					handle: datastore.Container{
+						Handle:   handle,
+						IP:       ip,
+						Metadata: metadata,
+					},
+				}
+				return nil
+			}
+		})
+
+		It("records the statuses of the containers in the store", func() {
+			err := store.SaveEnforcementStatuses(map[string]datastore.EnforcementStatus{
+				handle:         {Chain: "asg-abc123", EnforcedAt: enforcedAt, RulesHash: "some-hash", Error: "some-error"},
+				"other-handle": {Chain: "asg-def456", EnforcedAt: enforcedAt, RulesHash: "other-hash"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locker.LockCallCount()).To(Equal(1))
+			Expect(locker.UnlockCallCount()).To(Equal(1))
+
+			_, actual := serializer.EncodeAndOverwriteArgsForCall(0)
+			Expect(actual).To(Equal(map[string]datastore.Container{
+				handle: datastore.Container{
+					Handle:   handle,
+					IP:       ip,
+					Metadata: metadata,
+					Enforcement: &datastore.EnforcementStatus{
+						Chain:      "asg-abc123",
+						EnforcedAt: enforcedAt,
+						RulesHash:  "some-hash",
+						Error:      "some-error",
+					},
+				},
+			}))
+
+			versionContents, err := ioutil.ReadFile(versionFile.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(versionContents)).To(Equal("2"))
+		})
+
+		Context("when none of the containers are in the store", func() {
+			It("does not write the store", func() {
+				err := store.SaveEnforcementStatuses(map[string]datastore.EnforcementStatus{
+					"other-handle": {Chain: "asg-def456", EnforcedAt: enforcedAt, RulesHash: "other-hash"},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(serializer.EncodeAndOverwriteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the locker fails to lock", func() {
+			BeforeEach(func() {
+				locker.LockReturns(errors.New("potato"))
+			})
+			It("wraps and returns the error", func() {
+				err := store.SaveEnforcementStatuses(map[string]datastore.EnforcementStatus{})
+				Expect(err).To(MatchError("lock: potato"))
+			})
+		})
+
+		Context("when serializer fails to encode", func() {
+			BeforeEach(func() {
+				serializer.EncodeAndOverwriteReturns(errors.New("potato"))
+			})
+			It("wraps and returns the error", func() {
+				err := store.SaveEnforcementStatuses(map[string]datastore.EnforcementStatus{handle: {Chain: "asg-abc123"}})
+				Expect(err).To(MatchError("encode and overwrite: potato"))
+			})
+		})
+	})
+
 	Context("when deleting an entry from store", func() {
 		It("deserializes the data from the file", func() {
 			_, err := store.Delete(handle)
@@ -0,0 +1,11 @@
+package datastore
+
+// InstanceIndex returns the app instance index from the log_config garden
+// property of a container, or "" when the container has no log config.
+func InstanceIndex(metadata map[string]interface{}) string {
+	parsed, err := ParseContainerMetadata(metadata)
+	if err != nil {
+		return ""
+	}
+	return parsed.InstanceIndex()
+}
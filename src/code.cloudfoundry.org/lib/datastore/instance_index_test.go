@@ -0,0 +1,35 @@
+package datastore_test
+
+import (
+	"code.cloudfoundry.org/lib/datastore"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstanceIndex", func() {
+	It("returns the index from the log config", func() {
+		metadata := map[string]interface{}{
+			"log_config": `{"guid":"some-app-guid","index":3,"source_name":"CELL"}`,
+		}
+		Expect(datastore.InstanceIndex(metadata)).To(Equal("3"))
+	})
+
+	It("returns the first index", func() {
+		metadata := map[string]interface{}{
+			"log_config": `{"guid":"some-app-guid","index":0}`,
+		}
+		Expect(datastore.InstanceIndex(metadata)).To(Equal("0"))
+	})
+
+	DescribeTable("when the index is unknown",
+		func(metadata map[string]interface{}) {
+			Expect(datastore.InstanceIndex(metadata)).To(Equal(""))
+		},
+		Entry("no metadata", nil),
+		Entry("no log config", map[string]interface{}{"app_id": "some-app-guid"}),
+		Entry("log config without a guid", map[string]interface{}{"log_config": `{"index":3}`}),
+		Entry("log config that is not a string", map[string]interface{}{"log_config": 3}),
+		Entry("invalid log config", map[string]interface{}{"log_config": "{"}),
+	)
+})
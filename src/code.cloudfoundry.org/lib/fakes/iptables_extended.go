@@ -2,6 +2,7 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 
 	"code.cloudfoundry.org/lib/rules"
@@ -109,6 +110,19 @@ type IPTablesAdapter struct {
 	deleteChainReturnsOnCall map[int]struct {
 		result1 error
 	}
+	EnsureRulesStub        func(string, string, ...rules.IPTablesRule) error
+	ensureRulesMutex       sync.RWMutex
+	ensureRulesArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 []rules.IPTablesRule
+	}
+	ensureRulesReturns struct {
+		result1 error
+	}
+	ensureRulesReturnsOnCall map[int]struct {
+		result1 error
+	}
 	ExistsStub        func(string, string, rules.IPTablesRule) (bool, error)
 	existsMutex       sync.RWMutex
 	existsArgsForCall []struct {
@@ -162,6 +176,20 @@ type IPTablesAdapter struct {
 		result1 []string
 		result2 error
 	}
+	ListParsedStub        func(string, string) ([]rules.ParsedRule, error)
+	listParsedMutex       sync.RWMutex
+	listParsedArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	listParsedReturns struct {
+		result1 []rules.ParsedRule
+		result2 error
+	}
+	listParsedReturnsOnCall map[int]struct {
+		result1 []rules.ParsedRule
+		result2 error
+	}
 	NewChainStub        func(string, string) error
 	newChainMutex       sync.RWMutex
 	newChainArgsForCall []struct {
@@ -174,6 +202,30 @@ type IPTablesAdapter struct {
 	newChainReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ReplaceChainStub        func(string, string, ...rules.IPTablesRule) error
+	replaceChainMutex       sync.RWMutex
+	replaceChainArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 []rules.IPTablesRule
+	}
+	replaceChainReturns struct {
+		result1 error
+	}
+	replaceChainReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ReplaceChainsStub        func(...rules.ChainSpec) error
+	replaceChainsMutex       sync.RWMutex
+	replaceChainsArgsForCall []struct {
+		arg1 []rules.ChainSpec
+	}
+	replaceChainsReturns struct {
+		result1 error
+	}
+	replaceChainsReturnsOnCall map[int]struct {
+		result1 error
+	}
 	RuleCountStub        func(string) (int, error)
 	ruleCountMutex       sync.RWMutex
 	ruleCountArgsForCall []struct {
@@ -187,6 +239,17 @@ type IPTablesAdapter struct {
 		result1 int
 		result2 error
 	}
+	WithContextStub        func(context.Context) rules.IPTablesAdapter
+	withContextMutex       sync.RWMutex
+	withContextArgsForCall []struct {
+		arg1 context.Context
+	}
+	withContextReturns struct {
+		result1 rules.IPTablesAdapter
+	}
+	withContextReturnsOnCall map[int]struct {
+		result1 rules.IPTablesAdapter
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -692,6 +755,69 @@ func (fake *IPTablesAdapter) DeleteChainReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *IPTablesAdapter) EnsureRules(arg1 string, arg2 string, arg3 ...rules.IPTablesRule) error {
+	fake.ensureRulesMutex.Lock()
+	ret, specificReturn := fake.ensureRulesReturnsOnCall[len(fake.ensureRulesArgsForCall)]
+	fake.ensureRulesArgsForCall = append(fake.ensureRulesArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 []rules.IPTablesRule
+	}{arg1, arg2, arg3})
+	stub := fake.EnsureRulesStub
+	fakeReturns := fake.ensureRulesReturns
+	fake.recordInvocation("EnsureRules", []interface{}{arg1, arg2, arg3})
+	fake.ensureRulesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPTablesAdapter) EnsureRulesCallCount() int {
+	fake.ensureRulesMutex.RLock()
+	defer fake.ensureRulesMutex.RUnlock()
+	return len(fake.ensureRulesArgsForCall)
+}
+
+func (fake *IPTablesAdapter) EnsureRulesCalls(stub func(string, string, ...rules.IPTablesRule) error) {
+	fake.ensureRulesMutex.Lock()
+	defer fake.ensureRulesMutex.Unlock()
+	fake.EnsureRulesStub = stub
+}
+
+func (fake *IPTablesAdapter) EnsureRulesArgsForCall(i int) (string, string, []rules.IPTablesRule) {
+	fake.ensureRulesMutex.RLock()
+	defer fake.ensureRulesMutex.RUnlock()
+	argsForCall := fake.ensureRulesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *IPTablesAdapter) EnsureRulesReturns(result1 error) {
+	fake.ensureRulesMutex.Lock()
+	defer fake.ensureRulesMutex.Unlock()
+	fake.EnsureRulesStub = nil
+	fake.ensureRulesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPTablesAdapter) EnsureRulesReturnsOnCall(i int, result1 error) {
+	fake.ensureRulesMutex.Lock()
+	defer fake.ensureRulesMutex.Unlock()
+	fake.EnsureRulesStub = nil
+	if fake.ensureRulesReturnsOnCall == nil {
+		fake.ensureRulesReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.ensureRulesReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *IPTablesAdapter) Exists(arg1 string, arg2 string, arg3 rules.IPTablesRule) (bool, error) {
 	fake.existsMutex.Lock()
 	ret, specificReturn := fake.existsReturnsOnCall[len(fake.existsArgsForCall)]
@@ -948,6 +1074,71 @@ func (fake *IPTablesAdapter) ListChainsReturnsOnCall(i int, result1 []string, re
 	}{result1, result2}
 }
 
+func (fake *IPTablesAdapter) ListParsed(arg1 string, arg2 string) ([]rules.ParsedRule, error) {
+	fake.listParsedMutex.Lock()
+	ret, specificReturn := fake.listParsedReturnsOnCall[len(fake.listParsedArgsForCall)]
+	fake.listParsedArgsForCall = append(fake.listParsedArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.ListParsedStub
+	fakeReturns := fake.listParsedReturns
+	fake.recordInvocation("ListParsed", []interface{}{arg1, arg2})
+	fake.listParsedMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *IPTablesAdapter) ListParsedCallCount() int {
+	fake.listParsedMutex.RLock()
+	defer fake.listParsedMutex.RUnlock()
+	return len(fake.listParsedArgsForCall)
+}
+
+func (fake *IPTablesAdapter) ListParsedCalls(stub func(string, string) ([]rules.ParsedRule, error)) {
+	fake.listParsedMutex.Lock()
+	defer fake.listParsedMutex.Unlock()
+	fake.ListParsedStub = stub
+}
+
+func (fake *IPTablesAdapter) ListParsedArgsForCall(i int) (string, string) {
+	fake.listParsedMutex.RLock()
+	defer fake.listParsedMutex.RUnlock()
+	argsForCall := fake.listParsedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *IPTablesAdapter) ListParsedReturns(result1 []rules.ParsedRule, result2 error) {
+	fake.listParsedMutex.Lock()
+	defer fake.listParsedMutex.Unlock()
+	fake.ListParsedStub = nil
+	fake.listParsedReturns = struct {
+		result1 []rules.ParsedRule
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *IPTablesAdapter) ListParsedReturnsOnCall(i int, result1 []rules.ParsedRule, result2 error) {
+	fake.listParsedMutex.Lock()
+	defer fake.listParsedMutex.Unlock()
+	fake.ListParsedStub = nil
+	if fake.listParsedReturnsOnCall == nil {
+		fake.listParsedReturnsOnCall = make(map[int]struct {
+			result1 []rules.ParsedRule
+			result2 error
+		})
+	}
+	fake.listParsedReturnsOnCall[i] = struct {
+		result1 []rules.ParsedRule
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *IPTablesAdapter) NewChain(arg1 string, arg2 string) error {
 	fake.newChainMutex.Lock()
 	ret, specificReturn := fake.newChainReturnsOnCall[len(fake.newChainArgsForCall)]
@@ -1010,6 +1201,130 @@ func (fake *IPTablesAdapter) NewChainReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *IPTablesAdapter) ReplaceChain(arg1 string, arg2 string, arg3 ...rules.IPTablesRule) error {
+	fake.replaceChainMutex.Lock()
+	ret, specificReturn := fake.replaceChainReturnsOnCall[len(fake.replaceChainArgsForCall)]
+	fake.replaceChainArgsForCall = append(fake.replaceChainArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 []rules.IPTablesRule
+	}{arg1, arg2, arg3})
+	stub := fake.ReplaceChainStub
+	fakeReturns := fake.replaceChainReturns
+	fake.recordInvocation("ReplaceChain", []interface{}{arg1, arg2, arg3})
+	fake.replaceChainMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3...)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPTablesAdapter) ReplaceChainCallCount() int {
+	fake.replaceChainMutex.RLock()
+	defer fake.replaceChainMutex.RUnlock()
+	return len(fake.replaceChainArgsForCall)
+}
+
+func (fake *IPTablesAdapter) ReplaceChainCalls(stub func(string, string, ...rules.IPTablesRule) error) {
+	fake.replaceChainMutex.Lock()
+	defer fake.replaceChainMutex.Unlock()
+	fake.ReplaceChainStub = stub
+}
+
+func (fake *IPTablesAdapter) ReplaceChainArgsForCall(i int) (string, string, []rules.IPTablesRule) {
+	fake.replaceChainMutex.RLock()
+	defer fake.replaceChainMutex.RUnlock()
+	argsForCall := fake.replaceChainArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *IPTablesAdapter) ReplaceChainReturns(result1 error) {
+	fake.replaceChainMutex.Lock()
+	defer fake.replaceChainMutex.Unlock()
+	fake.ReplaceChainStub = nil
+	fake.replaceChainReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPTablesAdapter) ReplaceChainReturnsOnCall(i int, result1 error) {
+	fake.replaceChainMutex.Lock()
+	defer fake.replaceChainMutex.Unlock()
+	fake.ReplaceChainStub = nil
+	if fake.replaceChainReturnsOnCall == nil {
+		fake.replaceChainReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.replaceChainReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPTablesAdapter) ReplaceChains(arg1 ...rules.ChainSpec) error {
+	fake.replaceChainsMutex.Lock()
+	ret, specificReturn := fake.replaceChainsReturnsOnCall[len(fake.replaceChainsArgsForCall)]
+	fake.replaceChainsArgsForCall = append(fake.replaceChainsArgsForCall, struct {
+		arg1 []rules.ChainSpec
+	}{arg1})
+	stub := fake.ReplaceChainsStub
+	fakeReturns := fake.replaceChainsReturns
+	fake.recordInvocation("ReplaceChains", []interface{}{arg1})
+	fake.replaceChainsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1...)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPTablesAdapter) ReplaceChainsCallCount() int {
+	fake.replaceChainsMutex.RLock()
+	defer fake.replaceChainsMutex.RUnlock()
+	return len(fake.replaceChainsArgsForCall)
+}
+
+func (fake *IPTablesAdapter) ReplaceChainsCalls(stub func(...rules.ChainSpec) error) {
+	fake.replaceChainsMutex.Lock()
+	defer fake.replaceChainsMutex.Unlock()
+	fake.ReplaceChainsStub = stub
+}
+
+func (fake *IPTablesAdapter) ReplaceChainsArgsForCall(i int) []rules.ChainSpec {
+	fake.replaceChainsMutex.RLock()
+	defer fake.replaceChainsMutex.RUnlock()
+	argsForCall := fake.replaceChainsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *IPTablesAdapter) ReplaceChainsReturns(result1 error) {
+	fake.replaceChainsMutex.Lock()
+	defer fake.replaceChainsMutex.Unlock()
+	fake.ReplaceChainsStub = nil
+	fake.replaceChainsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IPTablesAdapter) ReplaceChainsReturnsOnCall(i int, result1 error) {
+	fake.replaceChainsMutex.Lock()
+	defer fake.replaceChainsMutex.Unlock()
+	fake.ReplaceChainsStub = nil
+	if fake.replaceChainsReturnsOnCall == nil {
+		fake.replaceChainsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.replaceChainsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *IPTablesAdapter) RuleCount(arg1 string) (int, error) {
 	fake.ruleCountMutex.Lock()
 	ret, specificReturn := fake.ruleCountReturnsOnCall[len(fake.ruleCountArgsForCall)]
@@ -1074,6 +1389,67 @@ func (fake *IPTablesAdapter) RuleCountReturnsOnCall(i int, result1 int, result2
 	}{result1, result2}
 }
 
+func (fake *IPTablesAdapter) WithContext(arg1 context.Context) rules.IPTablesAdapter {
+	fake.withContextMutex.Lock()
+	ret, specificReturn := fake.withContextReturnsOnCall[len(fake.withContextArgsForCall)]
+	fake.withContextArgsForCall = append(fake.withContextArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	stub := fake.WithContextStub
+	fakeReturns := fake.withContextReturns
+	fake.recordInvocation("WithContext", []interface{}{arg1})
+	fake.withContextMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *IPTablesAdapter) WithContextCallCount() int {
+	fake.withContextMutex.RLock()
+	defer fake.withContextMutex.RUnlock()
+	return len(fake.withContextArgsForCall)
+}
+
+func (fake *IPTablesAdapter) WithContextCalls(stub func(context.Context) rules.IPTablesAdapter) {
+	fake.withContextMutex.Lock()
+	defer fake.withContextMutex.Unlock()
+	fake.WithContextStub = stub
+}
+
+func (fake *IPTablesAdapter) WithContextArgsForCall(i int) context.Context {
+	fake.withContextMutex.RLock()
+	defer fake.withContextMutex.RUnlock()
+	argsForCall := fake.withContextArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *IPTablesAdapter) WithContextReturns(result1 rules.IPTablesAdapter) {
+	fake.withContextMutex.Lock()
+	defer fake.withContextMutex.Unlock()
+	fake.WithContextStub = nil
+	fake.withContextReturns = struct {
+		result1 rules.IPTablesAdapter
+	}{result1}
+}
+
+func (fake *IPTablesAdapter) WithContextReturnsOnCall(i int, result1 rules.IPTablesAdapter) {
+	fake.withContextMutex.Lock()
+	defer fake.withContextMutex.Unlock()
+	fake.WithContextStub = nil
+	if fake.withContextReturnsOnCall == nil {
+		fake.withContextReturnsOnCall = make(map[int]struct {
+			result1 rules.IPTablesAdapter
+		})
+	}
+	fake.withContextReturnsOnCall[i] = struct {
+		result1 rules.IPTablesAdapter
+	}{result1}
+}
+
 func (fake *IPTablesAdapter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -1093,6 +1469,8 @@ func (fake *IPTablesAdapter) Invocations() map[string][][]interface{} {
 	defer fake.deleteAfterRuleNumKeepRejectMutex.RUnlock()
 	fake.deleteChainMutex.RLock()
 	defer fake.deleteChainMutex.RUnlock()
+	fake.ensureRulesMutex.RLock()
+	defer fake.ensureRulesMutex.RUnlock()
 	fake.existsMutex.RLock()
 	defer fake.existsMutex.RUnlock()
 	fake.flushAndRestoreMutex.RLock()
@@ -1101,10 +1479,18 @@ func (fake *IPTablesAdapter) Invocations() map[string][][]interface{} {
 	defer fake.listMutex.RUnlock()
 	fake.listChainsMutex.RLock()
 	defer fake.listChainsMutex.RUnlock()
+	fake.listParsedMutex.RLock()
+	defer fake.listParsedMutex.RUnlock()
 	fake.newChainMutex.RLock()
 	defer fake.newChainMutex.RUnlock()
+	fake.replaceChainMutex.RLock()
+	defer fake.replaceChainMutex.RUnlock()
+	fake.replaceChainsMutex.RLock()
+	defer fake.replaceChainsMutex.RUnlock()
 	fake.ruleCountMutex.RLock()
 	defer fake.ruleCountMutex.RUnlock()
+	fake.withContextMutex.RLock()
+	defer fake.withContextMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -2,14 +2,16 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 )
 
 type Restorer struct {
-	RestoreStub        func(string) error
+	RestoreStub        func(context.Context, string) error
 	restoreMutex       sync.RWMutex
 	restoreArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
+		arg2 string
 	}
 	restoreReturns struct {
 		result1 error
@@ -17,11 +19,12 @@ type Restorer struct {
 	restoreReturnsOnCall map[int]struct {
 		result1 error
 	}
-	RestoreWithFlagsStub        func(string, ...string) error
+	RestoreWithFlagsStub        func(context.Context, string, ...string) error
 	restoreWithFlagsMutex       sync.RWMutex
 	restoreWithFlagsArgsForCall []struct {
-		arg1 string
-		arg2 []string
+		arg1 context.Context
+		arg2 string
+		arg3 []string
 	}
 	restoreWithFlagsReturns struct {
 		result1 error
@@ -33,18 +36,19 @@ type Restorer struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *Restorer) Restore(arg1 string) error {
+func (fake *Restorer) Restore(arg1 context.Context, arg2 string) error {
 	fake.restoreMutex.Lock()
 	ret, specificReturn := fake.restoreReturnsOnCall[len(fake.restoreArgsForCall)]
 	fake.restoreArgsForCall = append(fake.restoreArgsForCall, struct {
-		arg1 string
-	}{arg1})
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
 	stub := fake.RestoreStub
 	fakeReturns := fake.restoreReturns
-	fake.recordInvocation("Restore", []interface{}{arg1})
+	fake.recordInvocation("Restore", []interface{}{arg1, arg2})
 	fake.restoreMutex.Unlock()
 	if stub != nil {
-		return stub(arg1)
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
@@ -58,17 +62,17 @@ func (fake *Restorer) RestoreCallCount() int {
 	return len(fake.restoreArgsForCall)
 }
 
-func (fake *Restorer) RestoreCalls(stub func(string) error) {
+func (fake *Restorer) RestoreCalls(stub func(context.Context, string) error) {
 	fake.restoreMutex.Lock()
 	defer fake.restoreMutex.Unlock()
 	fake.RestoreStub = stub
 }
 
-func (fake *Restorer) RestoreArgsForCall(i int) string {
+func (fake *Restorer) RestoreArgsForCall(i int) (context.Context, string) {
 	fake.restoreMutex.RLock()
 	defer fake.restoreMutex.RUnlock()
 	argsForCall := fake.restoreArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *Restorer) RestoreReturns(result1 error) {
@@ -94,19 +98,20 @@ func (fake *Restorer) RestoreReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *Restorer) RestoreWithFlags(arg1 string, arg2 ...string) error {
+func (fake *Restorer) RestoreWithFlags(arg1 context.Context, arg2 string, arg3 ...string) error {
 	fake.restoreWithFlagsMutex.Lock()
 	ret, specificReturn := fake.restoreWithFlagsReturnsOnCall[len(fake.restoreWithFlagsArgsForCall)]
 	fake.restoreWithFlagsArgsForCall = append(fake.restoreWithFlagsArgsForCall, struct {
-		arg1 string
-		arg2 []string
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 string
+		arg3 []string
+	}{arg1, arg2, arg3})
 	stub := fake.RestoreWithFlagsStub
 	fakeReturns := fake.restoreWithFlagsReturns
-	fake.recordInvocation("RestoreWithFlags", []interface{}{arg1, arg2})
+	fake.recordInvocation("RestoreWithFlags", []interface{}{arg1, arg2, arg3})
 	fake.restoreWithFlagsMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2...)
+		return stub(arg1, arg2, arg3...)
 	}
 	if specificReturn {
 		return ret.result1
@@ -120,17 +125,17 @@ func (fake *Restorer) RestoreWithFlagsCallCount() int {
 	return len(fake.restoreWithFlagsArgsForCall)
 }
 
-func (fake *Restorer) RestoreWithFlagsCalls(stub func(string, ...string) error) {
+func (fake *Restorer) RestoreWithFlagsCalls(stub func(context.Context, string, ...string) error) {
 	fake.restoreWithFlagsMutex.Lock()
 	defer fake.restoreWithFlagsMutex.Unlock()
 	fake.RestoreWithFlagsStub = stub
 }
 
-func (fake *Restorer) RestoreWithFlagsArgsForCall(i int) (string, []string) {
+func (fake *Restorer) RestoreWithFlagsArgsForCall(i int) (context.Context, string, []string) {
 	fake.restoreWithFlagsMutex.RLock()
 	defer fake.restoreWithFlagsMutex.RUnlock()
 	argsForCall := fake.restoreWithFlagsArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *Restorer) RestoreWithFlagsReturns(result1 error) {
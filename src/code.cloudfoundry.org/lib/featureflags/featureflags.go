@@ -0,0 +1,245 @@
+// Package featureflags turns network features of a cell on and off at
+// runtime. The flags are read from a JSON file that operators can change on
+// the cells without a BOSH deploy:
+//
+//	{"flags": {"deny_networks": {"enabled": false, "percentage": 25, "cells": ["<bosh instance id>"]}}}
+//
+// A flag is on for a cell when it is enabled, when the cell is listed, or
+// when the cell falls into the percentage of cells it is rolled out to. The
+// share of a cell is a hash of the flag and the cell, so that a flag rolled
+// out to more cells stays on for the cells it was on for, and the wrapper
+// plugin and the policy agent of a cell agree on every flag. A feature whose
+// flag is not in the file keeps the value of its BOSH property.
+package featureflags
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// The flags of the features that can be toggled.
+const (
+	OutboundConnectionLimit = "outbound_connection_limit"
+	DenyNetworks            = "deny_networks"
+	IPTablesASGLogging      = "iptables_asg_logging"
+	IPTablesC2CLogging      = "iptables_c2c_logging"
+)
+
+var known = map[string]bool{
+	OutboundConnectionLimit: true,
+	DenyNetworks:            true,
+	IPTablesASGLogging:      true,
+	IPTablesC2CLogging:      true,
+}
+
+// DefaultReloadInterval is how often the policy agent checks the file for
+// changes.
+const DefaultReloadInterval = 10 * time.Second
+
+const metricPrefix = "featureFlag."
+
+// Flag is the rollout of a feature.
+type Flag struct {
+	Enabled    bool     `json:"enabled"`
+	Percentage int      `json:"percentage"`
+	Cells      []string `json:"cells"`
+}
+
+type file struct {
+	Flags map[string]Flag `json:"flags"`
+}
+
+//go:generate counterfeiter -o ../fakes/metrics_sender.go --fake-name MetricsSender . metricsSender
+type metricsSender interface {
+	SendValue(string, float64, string)
+}
+
+// Set is the flags of the file as they apply to the cell with CellID. A Set
+// without a file, or whose file does not exist, has no flags. While Run is
+// running the file is checked every ReloadInterval; when it cannot be loaded,
+// the last good flags are kept.
+type Set struct {
+	File           string
+	CellID         string
+	Logger         lager.Logger
+	MetricsSender  metricsSender
+	ReloadInterval time.Duration
+
+	mutex    sync.RWMutex
+	loaded   bool
+	checksum [sha256.Size]byte
+	flags    map[string]bool
+}
+
+// New loads the flags of the cell from the file. When the file cannot be
+// loaded, the returned Set has no flags until a reload succeeds, so that a
+// broken file leaves every feature at its configured value.
+func New(logger lager.Logger, path, cellID string) (*Set, error) {
+	s := &Set{
+		File:           path,
+		CellID:         cellID,
+		Logger:         logger,
+		ReloadInterval: DefaultReloadInterval,
+		flags:          map[string]bool{},
+	}
+	_, err := s.Reload()
+	return s, err
+}
+
+// Reload loads the file when its contents changed since it was last loaded,
+// and reports whether it did.
+func (s *Set) Reload() (bool, error) {
+	contents := []byte{}
+	if s.File != "" {
+		var err error
+		contents, err = os.ReadFile(s.File)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("read feature flags file: %s", err)
+		}
+	}
+
+	checksum := sha256.Sum256(contents)
+	s.mutex.RLock()
+	unchanged := s.loaded && checksum == s.checksum
+	s.mutex.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	flags, err := s.parse(contents)
+	if err != nil {
+		return false, err
+	}
+
+	s.mutex.Lock()
+	s.loaded = true
+	s.checksum = checksum
+	s.flags = flags
+	s.mutex.Unlock()
+	return true, nil
+}
+
+func (s *Set) parse(contents []byte) (map[string]bool, error) {
+	flags := map[string]bool{}
+	if len(bytes.TrimSpace(contents)) == 0 {
+		return flags, nil
+	}
+
+	var f file
+	if err := json.Unmarshal(contents, &f); err != nil {
+		return nil, fmt.Errorf("parse feature flags file: %s", err)
+	}
+	for name, flag := range f.Flags {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown feature flag %q", name)
+		}
+		if flag.Percentage < 0 || flag.Percentage > 100 {
+			return nil, fmt.Errorf("feature flag %q: percentage must be between 0 and 100", name)
+		}
+		flags[name] = flag.enabledFor(name, s.CellID)
+	}
+	return flags, nil
+}
+
+func (f Flag) enabledFor(name, cellID string) bool {
+	if f.Enabled {
+		return true
+	}
+	for _, cell := range f.Cells {
+		if cell == cellID {
+			return true
+		}
+	}
+	return f.Percentage > 0 && share(name, cellID) < f.Percentage
+}
+
+// share places the cell in one of 100 buckets of the flag.
+func share(name, cellID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(cellID))
+	return int(h.Sum32() % 100)
+}
+
+// Enabled reports whether the feature is on for the cell, or returns
+// defaultValue when its flag is not in the file.
+func (s *Set) Enabled(name string, defaultValue bool) bool {
+	if s == nil {
+		return defaultValue
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	enabled, ok := s.flags[name]
+	if !ok {
+		return defaultValue
+	}
+	return enabled
+}
+
+// Flags are the flags in the file and whether they are on for the cell.
+func (s *Set) Flags() map[string]bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	flags := make(map[string]bool, len(s.flags))
+	for name, enabled := range s.flags {
+		flags[name] = enabled
+	}
+	return flags
+}
+
+// sendMetrics sends a featureFlag.<name> metric of 1 or 0 for every flag in
+// the file.
+func (s *Set) sendMetrics() {
+	if s.MetricsSender == nil {
+		return
+	}
+	flags := s.Flags()
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := 0.0
+		if flags[name] {
+			value = 1
+		}
+		s.MetricsSender.SendValue(metricPrefix+name, value, "flag")
+	}
+}
+
+// Run reloads the flags every ReloadInterval until it is signalled, and
+// sends their metrics after every check.
+func (s *Set) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	s.sendMetrics()
+
+	ticker := time.NewTicker(s.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			reloaded, err := s.Reload()
+			if err != nil {
+				s.Logger.Error("reload-feature-flags", err, lager.Data{"file": s.File})
+			} else if reloaded {
+				s.Logger.Info("reloaded-feature-flags", lager.Data{"file": s.File, "flags": s.Flags()})
+			}
+			s.sendMetrics()
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package featureflags_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/featureflags"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Set", func() {
+	var (
+		dir    string
+		path   string
+		logger *lagertest.TestLogger
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "feature-flags")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "feature-flags.json")
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeFlags := func(contents string) {
+		Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	It("applies the flags in the file", func() {
+		writeFlags(`{"flags": {
+			"deny_networks": {"enabled": false},
+			"iptables_asg_logging": {"enabled": true}
+		}}`)
+		set, err := featureflags.New(logger, path, "some-cell")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(set.Enabled(featureflags.DenyNetworks, true)).To(BeFalse())
+		Expect(set.Enabled(featureflags.IPTablesASGLogging, false)).To(BeTrue())
+		Expect(set.Flags()).To(Equal(map[string]bool{
+			featureflags.DenyNetworks:       false,
+			featureflags.IPTablesASGLogging: true,
+		}))
+	})
+
+	It("keeps the default of the flags that are not in the file", func() {
+		writeFlags(`{"flags": {}}`)
+		set, err := featureflags.New(logger, path, "some-cell")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(set.Enabled(featureflags.OutboundConnectionLimit, true)).To(BeTrue())
+		Expect(set.Enabled(featureflags.OutboundConnectionLimit, false)).To(BeFalse())
+	})
+
+	It("enables a flag for the listed cells", func() {
+		writeFlags(`{"flags": {"deny_networks": {"cells": ["some-cell"]}}}`)
+		set, err := featureflags.New(logger, path, "some-cell")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set.Enabled(featureflags.DenyNetworks, false)).To(BeTrue())
+
+		set, err = featureflags.New(logger, path, "other-cell")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set.Enabled(featureflags.DenyNetworks, true)).To(BeFalse())
+	})
+
+	It("rolls a flag out to a percentage of the cells", func() {
+		enabledCells := func(percentage int) map[string]bool {
+			writeFlags(fmt.Sprintf(`{"flags": {"outbound_connection_limit": {"percentage": %d}}}`, percentage))
+			cells := map[string]bool{}
+			for i := 0; i < 1000; i++ {
+				cell := fmt.Sprintf("cell-%d", i)
+				set, err := featureflags.New(logger, path, cell)
+				Expect(err).NotTo(HaveOccurred())
+				if set.Enabled(featureflags.OutboundConnectionLimit, false) {
+					cells[cell] = true
+				}
+			}
+			return cells
+		}
+
+		quarter := enabledCells(25)
+		Expect(len(quarter)).To(BeNumerically("~", 250, 60))
+		half := enabledCells(50)
+		Expect(len(half)).To(BeNumerically("~", 500, 60))
+		for cell := range quarter {
+			Expect(half).To(HaveKey(cell))
+		}
+		Expect(enabledCells(0)).To(BeEmpty())
+		Expect(enabledCells(100)).To(HaveLen(1000))
+	})
+
+	Context("when the file does not exist", func() {
+		It("has no flags", func() {
+			set, err := featureflags.New(logger, path, "some-cell")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set.Flags()).To(BeEmpty())
+			Expect(set.Enabled(featureflags.DenyNetworks, true)).To(BeTrue())
+		})
+	})
+
+	Context("when there is no file", func() {
+		It("has no flags", func() {
+			set, err := featureflags.New(logger, "", "some-cell")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set.Flags()).To(BeEmpty())
+		})
+	})
+
+	Context("when there is no set", func() {
+		It("returns the defaults", func() {
+			var set *featureflags.Set
+			Expect(set.Enabled(featureflags.DenyNetworks, true)).To(BeTrue())
+		})
+	})
+
+	Context("when the file is invalid", func() {
+		It("returns an error", func() {
+			writeFlags(`{"flags": `)
+			set, err := featureflags.New(logger, path, "some-cell")
+			Expect(err).To(MatchError(ContainSubstring("parse feature flags file:")))
+			Expect(set.Flags()).To(BeEmpty())
+			Expect(set.Enabled(featureflags.DenyNetworks, true)).To(BeTrue())
+		})
+	})
+
+	Context("when a flag is unknown", func() {
+		It("returns an error", func() {
+			writeFlags(`{"flags": {"warp_drive": {"enabled": true}}}`)
+			_, err := featureflags.New(logger, path, "some-cell")
+			Expect(err).To(MatchError(`unknown feature flag "warp_drive"`))
+		})
+	})
+
+	Context("when a percentage is out of range", func() {
+		It("returns an error", func() {
+			writeFlags(`{"flags": {"deny_networks": {"percentage": 101}}}`)
+			_, err := featureflags.New(logger, path, "some-cell")
+			Expect(err).To(MatchError(`feature flag "deny_networks": percentage must be between 0 and 100`))
+		})
+	})
+
+	Describe("Reload", func() {
+		var set *featureflags.Set
+
+		BeforeEach(func() {
+			writeFlags(`{"flags": {"deny_networks": {"enabled": true}}}`)
+			var err error
+			set, err = featureflags.New(logger, path, "some-cell")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("loads the file when it changed", func() {
+			reloaded, err := set.Reload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded).To(BeFalse())
+
+			writeFlags(`{"flags": {"deny_networks": {"enabled": false}}}`)
+			reloaded, err = set.Reload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded).To(BeTrue())
+			Expect(set.Enabled(featureflags.DenyNetworks, true)).To(BeFalse())
+		})
+
+		It("drops the flags when the file is removed", func() {
+			Expect(os.Remove(path)).To(Succeed())
+			reloaded, err := set.Reload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded).To(BeTrue())
+			Expect(set.Flags()).To(BeEmpty())
+		})
+
+		Context("when the changed file is invalid", func() {
+			It("keeps the last good flags", func() {
+				writeFlags(`{"flags": {"deny_networks": {"enabled": "yes"}}}`)
+				_, err := set.Reload()
+				Expect(err).To(HaveOccurred())
+				Expect(set.Enabled(featureflags.DenyNetworks, false)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Run", func() {
+		var (
+			set           *featureflags.Set
+			metricsSender *fakes.MetricsSender
+			process       ifrit.Process
+		)
+
+		BeforeEach(func() {
+			writeFlags(`{"flags": {"deny_networks": {"enabled": true}, "iptables_asg_logging": {}}}`)
+			var err error
+			set, err = featureflags.New(logger, path, "some-cell")
+			Expect(err).NotTo(HaveOccurred())
+			metricsSender = &fakes.MetricsSender{}
+			set.MetricsSender = metricsSender
+			set.ReloadInterval = 10 * time.Millisecond
+			process = ifrit.Invoke(set)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("sends a metric for every flag", func() {
+			Eventually(metricsSender.SendValueCallCount).Should(BeNumerically(">=", 2))
+			name, value, unit := metricsSender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("featureFlag.deny_networks"))
+			Expect(value).To(Equal(1.0))
+			Expect(unit).To(Equal("flag"))
+			name, value, _ = metricsSender.SendValueArgsForCall(1)
+			Expect(name).To(Equal("featureFlag.iptables_asg_logging"))
+			Expect(value).To(Equal(0.0))
+		})
+
+		It("reloads the file", func() {
+			writeFlags(`{"flags": {"deny_networks": {"enabled": false}}}`)
+			Eventually(func() bool {
+				return set.Enabled(featureflags.DenyNetworks, true)
+			}).Should(BeFalse())
+			Eventually(logger).Should(gbytes.Say("reloaded-feature-flags"))
+		})
+	})
+})
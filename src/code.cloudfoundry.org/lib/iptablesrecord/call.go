@@ -0,0 +1,37 @@
+// Package iptablesrecord records the calls of an agent to iptables on a cell
+// and replays them in tests, so that a bug seen on a cell can be turned into
+// a regression test that runs against the exact outputs iptables gave there.
+//
+// A recording is a file of JSON lines, one Call per line, in the order the
+// calls returned.
+package iptablesrecord
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Call is one call to an IPTablesAdapter: its arguments, what it returned
+// and how long it took, and the operation it was made for, if its context
+// named one.
+type Call struct {
+	Time      time.Time         `json:"time"`
+	Method    string            `json:"method"`
+	Operation string            `json:"operation,omitempty"`
+	Args      []json.RawMessage `json:"args"`
+	Output    json.RawMessage   `json:"output,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Duration  time.Duration     `json:"duration_ns"`
+}
+
+func marshalArgs(args []interface{}) ([]json.RawMessage, error) {
+	raw := make([]json.RawMessage, 0, len(args))
+	for _, arg := range args {
+		bytes, err := json.Marshal(arg)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, bytes)
+	}
+	return raw, nil
+}
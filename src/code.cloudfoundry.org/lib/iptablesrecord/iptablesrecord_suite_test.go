@@ -0,0 +1,13 @@
+package iptablesrecord_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestIPTablesRecord(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "IPTablesRecord Suite")
+}
@@ -0,0 +1,183 @@
+package iptablesrecord_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/iptablesrecord"
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+var _ = Describe("Recorder", func() {
+	var (
+		iptables  *fakes.IPTablesAdapter
+		recording *bytes.Buffer
+		logger    *lagertest.TestLogger
+		recorder  *iptablesrecord.Recorder
+	)
+
+	BeforeEach(func() {
+		iptables = &fakes.IPTablesAdapter{}
+		recording = &bytes.Buffer{}
+		logger = lagertest.NewTestLogger("test")
+		recorder = &iptablesrecord.Recorder{
+			IPTables: iptables,
+			Writer:   recording,
+			Logger:   logger,
+		}
+	})
+
+	It("records the arguments, output and error of every call", func() {
+		iptables.ListReturns([]string{"-N some-chain", "-A some-chain -j ACCEPT"}, nil)
+		iptables.BulkInsertReturns(errors.New("potato"))
+
+		list, err := recorder.List("filter", "some-chain")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list).To(Equal([]string{"-N some-chain", "-A some-chain -j ACCEPT"}))
+
+		err = recorder.BulkInsert("filter", "FORWARD", 1, rules.IPTablesRule{"-j", "some-chain"})
+		Expect(err).To(MatchError("potato"))
+
+		lines := strings.Split(strings.TrimSpace(recording.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+
+		var call iptablesrecord.Call
+		Expect(json.Unmarshal([]byte(lines[0]), &call)).To(Succeed())
+		Expect(call.Method).To(Equal("List"))
+		Expect(call.Args).To(HaveLen(2))
+		Expect(string(call.Args[0])).To(Equal(`"filter"`))
+		Expect(string(call.Output)).To(Equal(`["-N some-chain","-A some-chain -j ACCEPT"]`))
+		Expect(call.Error).To(BeEmpty())
+		Expect(call.Time).NotTo(BeZero())
+
+		Expect(json.Unmarshal([]byte(lines[1]), &call)).To(Succeed())
+		Expect(call.Method).To(Equal("BulkInsert"))
+		Expect(string(call.Args[2])).To(Equal("1"))
+		Expect(string(call.Args[3])).To(Equal(`[["-j","some-chain"]]`))
+		Expect(call.Error).To(Equal("potato"))
+	})
+
+	It("records the operation of the context of the calls", func() {
+		boundIPTables := &fakes.IPTablesAdapter{}
+		iptables.WithContextReturns(boundIPTables)
+		ctx := rules.WithOperation(context.Background(), "enforce-rules-and-chain")
+
+		Expect(recorder.WithContext(ctx).NewChain("filter", "some-chain")).To(Succeed())
+		Expect(recorder.NewChain("filter", "other-chain")).To(Succeed())
+
+		Expect(iptables.WithContextArgsForCall(0)).To(Equal(ctx))
+		Expect(boundIPTables.NewChainCallCount()).To(Equal(1))
+		Expect(iptables.NewChainCallCount()).To(Equal(1))
+
+		lines := strings.Split(strings.TrimSpace(recording.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		var call iptablesrecord.Call
+		Expect(json.Unmarshal([]byte(lines[0]), &call)).To(Succeed())
+		Expect(call.Operation).To(Equal("enforce-rules-and-chain"))
+		var callWithoutContext iptablesrecord.Call
+		Expect(json.Unmarshal([]byte(lines[1]), &callWithoutContext)).To(Succeed())
+		Expect(callWithoutContext.Operation).To(BeEmpty())
+	})
+
+	Context("when the call cannot be recorded", func() {
+		BeforeEach(func() {
+			recorder.Writer = failingWriter{}
+		})
+
+		It("still makes the call and logs the error", func() {
+			Expect(recorder.NewChain("filter", "some-chain")).To(Succeed())
+			Expect(iptables.NewChainCallCount()).To(Equal(1))
+			Expect(logger.LogMessages()).To(ContainElement("test.record-iptables-call"))
+		})
+	})
+})
+
+var _ = Describe("Replayer", func() {
+	var recording *bytes.Buffer
+
+	BeforeEach(func() {
+		iptables := &fakes.IPTablesAdapter{}
+		iptables.ListChainsReturns([]string{"FORWARD", "vpa--v1-abc"}, nil)
+		iptables.ExistsReturns(true, nil)
+		iptables.RuleCountReturns(42, nil)
+		iptables.DeleteChainReturns(errors.New("chain in use"))
+
+		recording = &bytes.Buffer{}
+		recorder := &iptablesrecord.Recorder{
+			IPTables: iptables,
+			Writer:   recording,
+			Logger:   lagertest.NewTestLogger("test"),
+		}
+		_, _ = recorder.ListChains("filter")
+		_, _ = recorder.Exists("filter", "FORWARD", rules.IPTablesRule{"-j", "vpa--v1-abc"})
+		_, _ = recorder.RuleCount("filter")
+		_ = recorder.DeleteChain("filter", "vpa--v1-abc")
+	})
+
+	It("answers the recorded calls with the recorded outputs", func() {
+		replayer, err := iptablesrecord.NewReplayer(recording)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(replayer.Remaining()).To(Equal(4))
+
+		chains, err := replayer.ListChains("filter")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chains).To(Equal([]string{"FORWARD", "vpa--v1-abc"}))
+
+		exists, err := replayer.Exists("filter", "FORWARD", rules.IPTablesRule{"-j", "vpa--v1-abc"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+
+		count, err := replayer.RuleCount("filter")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(42))
+
+		Expect(replayer.DeleteChain("filter", "vpa--v1-abc")).To(MatchError("chain in use"))
+
+		Expect(replayer.Remaining()).To(Equal(0))
+		Expect(replayer.Err()).NotTo(HaveOccurred())
+	})
+
+	Context("when a call does not match the recording", func() {
+		It("fails the call and keeps the first mismatch", func() {
+			replayer, err := iptablesrecord.NewReplayer(recording)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = replayer.ListChains("nat")
+			Expect(err).To(MatchError(`unexpected call ListChains("nat"), recorded call 1 is ListChains("filter")`))
+			_, _ = replayer.List("filter", "FORWARD")
+
+			Expect(replayer.Err()).To(MatchError(ContainSubstring(`ListChains("nat")`)))
+			Expect(replayer.Remaining()).To(Equal(4))
+		})
+	})
+
+	Context("when there are more calls than recorded", func() {
+		It("fails the call", func() {
+			replayer, err := iptablesrecord.NewReplayer(strings.NewReader(""))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(replayer.NewChain("filter", "some-chain")).To(MatchError(`unexpected call NewChain("filter", "some-chain") after the end of the recording`))
+		})
+	})
+
+	Context("when the recording is not valid", func() {
+		It("returns an error", func() {
+			_, err := iptablesrecord.NewReplayer(strings.NewReader("{\"method\":\"List\"}\nbanana\n"))
+			Expect(err).To(MatchError(ContainSubstring("line 2:")))
+		})
+	})
+})
@@ -0,0 +1,206 @@
+package iptablesrecord
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// Recorder is an IPTablesAdapter that writes every call to the adapter it
+// wraps to Writer. A call that cannot be recorded is still made, so that
+// recording never changes what the agent enforces. The calls made through
+// WithContext are recorded with the operation of the context.
+type Recorder struct {
+	IPTables rules.IPTablesAdapter
+	Writer   io.Writer
+	Logger   lager.Logger
+
+	mutex sync.Mutex
+	// the recorder of the calls without a context, whose mutex guards Writer
+	root      *Recorder
+	operation string
+}
+
+var _ rules.IPTablesAdapter = &Recorder{}
+
+func (r *Recorder) record(method string, start time.Time, output interface{}, err error, args ...interface{}) {
+	call := Call{
+		Time:      start,
+		Method:    method,
+		Operation: r.operation,
+		Duration:  time.Since(start),
+	}
+	var recordErr error
+	call.Args, recordErr = marshalArgs(args)
+	if recordErr == nil && output != nil {
+		call.Output, recordErr = json.Marshal(output)
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	var line []byte
+	if recordErr == nil {
+		line, recordErr = json.Marshal(call)
+	}
+
+	if recordErr == nil {
+		root := r
+		if r.root != nil {
+			root = r.root
+		}
+		root.mutex.Lock()
+		_, recordErr = r.Writer.Write(append(line, '\n'))
+		root.mutex.Unlock()
+	}
+	if recordErr != nil {
+		r.Logger.Error("record-iptables-call", recordErr, lager.Data{"method": method})
+	}
+}
+
+func (r *Recorder) WithContext(ctx context.Context) rules.IPTablesAdapter {
+	root := r
+	if r.root != nil {
+		root = r.root
+	}
+	return &Recorder{
+		IPTables:  r.IPTables.WithContext(ctx),
+		Writer:    r.Writer,
+		Logger:    r.Logger,
+		root:      root,
+		operation: rules.Operation(ctx),
+	}
+}
+
+func (r *Recorder) FlushAndRestore(rawInput string) error {
+	start := time.Now()
+	err := r.IPTables.FlushAndRestore(rawInput)
+	r.record("FlushAndRestore", start, nil, err, rawInput)
+	return err
+}
+
+func (r *Recorder) Exists(table, chain string, rulespec rules.IPTablesRule) (bool, error) {
+	start := time.Now()
+	exists, err := r.IPTables.Exists(table, chain, rulespec)
+	r.record("Exists", start, exists, err, table, chain, rulespec)
+	return exists, err
+}
+
+func (r *Recorder) Delete(table, chain string, rulespec rules.IPTablesRule) error {
+	start := time.Now()
+	err := r.IPTables.Delete(table, chain, rulespec)
+	r.record("Delete", start, nil, err, table, chain, rulespec)
+	return err
+}
+
+func (r *Recorder) DeleteAfterRuleNum(table, chain string, ruleNum int) error {
+	start := time.Now()
+	err := r.IPTables.DeleteAfterRuleNum(table, chain, ruleNum)
+	r.record("DeleteAfterRuleNum", start, nil, err, table, chain, ruleNum)
+	return err
+}
+
+func (r *Recorder) DeleteAfterRuleNumKeepReject(table, chain string, ruleNum int) error {
+	start := time.Now()
+	err := r.IPTables.DeleteAfterRuleNumKeepReject(table, chain, ruleNum)
+	r.record("DeleteAfterRuleNumKeepReject", start, nil, err, table, chain, ruleNum)
+	return err
+}
+
+func (r *Recorder) List(table, chain string) ([]string, error) {
+	start := time.Now()
+	list, err := r.IPTables.List(table, chain)
+	r.record("List", start, list, err, table, chain)
+	return list, err
+}
+
+// ListParsed is recorded as the List call it parses, so that it replays
+// with the same listing.
+func (r *Recorder) ListParsed(table, chain string) ([]rules.ParsedRule, error) {
+	list, err := r.List(table, chain)
+	if err != nil {
+		return nil, err
+	}
+	return rules.ParseRules(list)
+}
+
+func (r *Recorder) ListChains(table string) ([]string, error) {
+	start := time.Now()
+	chains, err := r.IPTables.ListChains(table)
+	r.record("ListChains", start, chains, err, table)
+	return chains, err
+}
+
+func (r *Recorder) NewChain(table, chain string) error {
+	start := time.Now()
+	err := r.IPTables.NewChain(table, chain)
+	r.record("NewChain", start, nil, err, table, chain)
+	return err
+}
+
+func (r *Recorder) ClearChain(table, chain string) error {
+	start := time.Now()
+	err := r.IPTables.ClearChain(table, chain)
+	r.record("ClearChain", start, nil, err, table, chain)
+	return err
+}
+
+func (r *Recorder) DeleteChain(table, chain string) error {
+	start := time.Now()
+	err := r.IPTables.DeleteChain(table, chain)
+	r.record("DeleteChain", start, nil, err, table, chain)
+	return err
+}
+
+func (r *Recorder) BulkInsert(table, chain string, pos int, rulespec ...rules.IPTablesRule) error {
+	start := time.Now()
+	err := r.IPTables.BulkInsert(table, chain, pos, rulespec...)
+	r.record("BulkInsert", start, nil, err, table, chain, pos, rulespec)
+	return err
+}
+
+func (r *Recorder) BulkAppend(table, chain string, rulespec ...rules.IPTablesRule) error {
+	start := time.Now()
+	err := r.IPTables.BulkAppend(table, chain, rulespec...)
+	r.record("BulkAppend", start, nil, err, table, chain, rulespec)
+	return err
+}
+
+func (r *Recorder) EnsureRules(table, chain string, rulespec ...rules.IPTablesRule) error {
+	start := time.Now()
+	err := r.IPTables.EnsureRules(table, chain, rulespec...)
+	r.record("EnsureRules", start, nil, err, table, chain, rulespec)
+	return err
+}
+
+func (r *Recorder) ReplaceChain(table, chain string, rulespec ...rules.IPTablesRule) error {
+	start := time.Now()
+	err := r.IPTables.ReplaceChain(table, chain, rulespec...)
+	r.record("ReplaceChain", start, nil, err, table, chain, rulespec)
+	return err
+}
+
+func (r *Recorder) ReplaceChains(chains ...rules.ChainSpec) error {
+	start := time.Now()
+	err := r.IPTables.ReplaceChains(chains...)
+	r.record("ReplaceChains", start, nil, err, chains)
+	return err
+}
+
+func (r *Recorder) RuleCount(table string) (int, error) {
+	start := time.Now()
+	count, err := r.IPTables.RuleCount(table)
+	r.record("RuleCount", start, count, err, table)
+	return count, err
+}
+
+func (r *Recorder) AllowTrafficForRange(rulespec ...rules.IPTablesRule) error {
+	start := time.Now()
+	err := r.IPTables.AllowTrafficForRange(rulespec...)
+	r.record("AllowTrafficForRange", start, nil, err, rulespec)
+	return err
+}
@@ -0,0 +1,211 @@
+package iptablesrecord
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// Replayer is an IPTablesAdapter that answers the calls of a test with the
+// outputs of a recording. The calls have to come in the recorded order with
+// the recorded arguments; a call that does not match the next recorded call
+// fails, and the first mismatch is kept for Err. Chain names that contain a
+// timestamp only match when the test fixes the time of the enforcer to the
+// one of the recording.
+type Replayer struct {
+	calls []Call
+	next  int
+	err   error
+	mutex sync.Mutex
+}
+
+var _ rules.IPTablesAdapter = &Replayer{}
+
+// NewReplayer reads a recording of a Recorder.
+func NewReplayer(recording io.Reader) (*Replayer, error) {
+	replayer := &Replayer{}
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var call Call
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		replayer.calls = append(replayer.calls, call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return replayer, nil
+}
+
+// Err is the first call that did not match the recording.
+func (r *Replayer) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// Remaining is the number of recorded calls that were not replayed yet.
+func (r *Replayer) Remaining() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.calls) - r.next
+}
+
+// replay matches a call with the next recorded call and decodes the recorded
+// output into output.
+func (r *Replayer) replay(method string, output interface{}, args ...interface{}) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	err := r.match(method, args)
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return err
+	}
+
+	call := r.calls[r.next]
+	r.next++
+	if output != nil && len(call.Output) > 0 {
+		if err := json.Unmarshal(call.Output, output); err != nil {
+			return fmt.Errorf("replaying %s: %s", method, err)
+		}
+	}
+	if call.Error != "" {
+		return errors.New(call.Error)
+	}
+	return nil
+}
+
+func (r *Replayer) match(method string, args []interface{}) error {
+	raw, err := marshalArgs(args)
+	if err != nil {
+		return err
+	}
+	called := describe(method, raw)
+	if r.next >= len(r.calls) {
+		return fmt.Errorf("unexpected call %s after the end of the recording", called)
+	}
+	recorded := describe(r.calls[r.next].Method, r.calls[r.next].Args)
+	if called != recorded {
+		return fmt.Errorf("unexpected call %s, recorded call %d is %s", called, r.next+1, recorded)
+	}
+	return nil
+}
+
+func describe(method string, args []json.RawMessage) string {
+	compacted := make([]string, 0, len(args))
+	for _, arg := range args {
+		var buffer bytes.Buffer
+		if err := json.Compact(&buffer, arg); err != nil {
+			compacted = append(compacted, string(arg))
+			continue
+		}
+		compacted = append(compacted, buffer.String())
+	}
+	return fmt.Sprintf("%s(%s)", method, strings.Join(compacted, ", "))
+}
+
+// WithContext returns the Replayer itself, as the calls of every operation
+// are replayed in the order of the one recording.
+func (r *Replayer) WithContext(context.Context) rules.IPTablesAdapter {
+	return r
+}
+
+func (r *Replayer) FlushAndRestore(rawInput string) error {
+	return r.replay("FlushAndRestore", nil, rawInput)
+}
+
+func (r *Replayer) Exists(table, chain string, rulespec rules.IPTablesRule) (bool, error) {
+	var exists bool
+	err := r.replay("Exists", &exists, table, chain, rulespec)
+	return exists, err
+}
+
+func (r *Replayer) Delete(table, chain string, rulespec rules.IPTablesRule) error {
+	return r.replay("Delete", nil, table, chain, rulespec)
+}
+
+func (r *Replayer) DeleteAfterRuleNum(table, chain string, ruleNum int) error {
+	return r.replay("DeleteAfterRuleNum", nil, table, chain, ruleNum)
+}
+
+func (r *Replayer) DeleteAfterRuleNumKeepReject(table, chain string, ruleNum int) error {
+	return r.replay("DeleteAfterRuleNumKeepReject", nil, table, chain, ruleNum)
+}
+
+func (r *Replayer) List(table, chain string) ([]string, error) {
+	var list []string
+	err := r.replay("List", &list, table, chain)
+	return list, err
+}
+
+func (r *Replayer) ListParsed(table, chain string) ([]rules.ParsedRule, error) {
+	list, err := r.List(table, chain)
+	if err != nil {
+		return nil, err
+	}
+	return rules.ParseRules(list)
+}
+
+func (r *Replayer) ListChains(table string) ([]string, error) {
+	var chains []string
+	err := r.replay("ListChains", &chains, table)
+	return chains, err
+}
+
+func (r *Replayer) NewChain(table, chain string) error {
+	return r.replay("NewChain", nil, table, chain)
+}
+
+func (r *Replayer) ClearChain(table, chain string) error {
+	return r.replay("ClearChain", nil, table, chain)
+}
+
+func (r *Replayer) DeleteChain(table, chain string) error {
+	return r.replay("DeleteChain", nil, table, chain)
+}
+
+func (r *Replayer) BulkInsert(table, chain string, pos int, rulespec ...rules.IPTablesRule) error {
+	return r.replay("BulkInsert", nil, table, chain, pos, rulespec)
+}
+
+func (r *Replayer) BulkAppend(table, chain string, rulespec ...rules.IPTablesRule) error {
+	return r.replay("BulkAppend", nil, table, chain, rulespec)
+}
+
+func (r *Replayer) EnsureRules(table, chain string, rulespec ...rules.IPTablesRule) error {
+	return r.replay("EnsureRules", nil, table, chain, rulespec)
+}
+
+func (r *Replayer) ReplaceChain(table, chain string, rulespec ...rules.IPTablesRule) error {
+	return r.replay("ReplaceChain", nil, table, chain, rulespec)
+}
+
+func (r *Replayer) ReplaceChains(chains ...rules.ChainSpec) error {
+	return r.replay("ReplaceChains", nil, chains)
+}
+
+func (r *Replayer) RuleCount(table string) (int, error) {
+	var count int
+	err := r.replay("RuleCount", &count, table)
+	return count, err
+}
+
+func (r *Replayer) AllowTrafficForRange(rulespec ...rules.IPTablesRule) error {
+	return r.replay("AllowTrafficForRange", nil, rulespec)
+}
@@ -7,13 +7,28 @@ import (
 	"code.cloudfoundry.org/lager/v3"
 )
 
+// Poller runs SingleCycleFunc every PollInterval, and whenever Trigger
+// receives.
 type Poller struct {
 	Logger       lager.Logger
 	PollInterval time.Duration
+	Trigger      <-chan struct{}
 
 	SingleCycleFunc func() error
 }
 
+// NewTrigger makes a channel for a Poller and a function that triggers a cycle
+// without blocking. Triggers while a cycle is pending add no other cycle.
+func NewTrigger() (<-chan struct{}, func()) {
+	trigger := make(chan struct{}, 1)
+	return trigger, func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
 func (m *Poller) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	close(ready)
 
@@ -26,6 +41,10 @@ func (m *Poller) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 				m.Logger.Error("poll-cycle", err)
 				continue
 			}
+		case <-m.Trigger:
+			if err := m.SingleCycleFunc(); err != nil {
+				m.Logger.Error("poll-cycle", err)
+			}
 		}
 	}
 }
@@ -63,6 +63,36 @@ var _ = Describe("Poller", func() {
 			Eventually(retChan).Should(Receive(nil))
 		})
 
+		Context("when it is triggered", func() {
+			It("calls the single cycle func right away, once for triggers while a cycle is pending", func() {
+				p.PollInterval = time.Hour
+				trigger, triggerCycle := poller.NewTrigger()
+				p.Trigger = trigger
+
+				triggerCycle()
+				triggerCycle()
+				go func() {
+					retChan <- p.Run(signals, ready)
+				}()
+				Eventually(ready).Should(BeClosed())
+
+				Eventually(func() uint64 {
+					return atomic.LoadUint64(&cycleCount)
+				}).Should(Equal(uint64(1)))
+				Consistently(func() uint64 {
+					return atomic.LoadUint64(&cycleCount)
+				}).Should(Equal(uint64(1)))
+
+				triggerCycle()
+				Eventually(func() uint64 {
+					return atomic.LoadUint64(&cycleCount)
+				}).Should(Equal(uint64(2)))
+
+				signals <- os.Interrupt
+				Eventually(retChan).Should(Receive(nil))
+			})
+		})
+
 		Context("when the cycle func errors", func() {
 			BeforeEach(func() {
 				p.SingleCycleFunc = func() error { return errors.New("banana") }
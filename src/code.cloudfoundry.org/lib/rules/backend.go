@@ -0,0 +1,44 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
+)
+
+// Backend is the kernel interface an iptables binary writes its rules to.
+// The legacy and nf_tables backends keep tables of their own, so the rules
+// written with one cannot be seen with the other.
+type Backend string
+
+const (
+	BackendLegacy   Backend = "legacy"
+	BackendNFTables Backend = "nf_tables"
+)
+
+var backendVersion = regexp.MustCompile(`v[0-9]+\.[0-9]+\.[0-9]+(?:\s+\((\w+)\))?`)
+
+// BackendDetector reads the backend from the version of the iptables binary,
+// e.g. "iptables v1.8.7 (nf_tables)". It runs the binary every time, since
+// the alternative it links to can be switched while the process runs, e.g.
+// by patching the OS. Versions without a backend are legacy.
+type BackendDetector struct {
+	IPTablesRunner commandRunner
+}
+
+func (d *BackendDetector) Detect() (Backend, error) {
+	output, err := d.IPTablesRunner.CombinedOutput(runner.Command{Args: []string{"--version"}})
+	if err != nil {
+		return "", fmt.Errorf("iptables --version: %s: %s", err, output)
+	}
+
+	match := backendVersion.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", fmt.Errorf("no iptables version in %q", output)
+	}
+	if match[1] == "" {
+		return BackendLegacy, nil
+	}
+	return Backend(match[1]), nil
+}
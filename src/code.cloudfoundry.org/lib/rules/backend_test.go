@@ -0,0 +1,71 @@
+package rules_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
+	"code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BackendDetector", func() {
+	var (
+		iptablesRunner *fakes.CommandRunner
+		detector       *rules.BackendDetector
+	)
+
+	BeforeEach(func() {
+		iptablesRunner = &fakes.CommandRunner{}
+		detector = &rules.BackendDetector{IPTablesRunner: iptablesRunner}
+	})
+
+	It("reads the backend from the version of iptables", func() {
+		iptablesRunner.CombinedOutputReturns([]byte("iptables v1.8.7 (nf_tables)\n"), nil)
+
+		backend, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend).To(Equal(rules.BackendNFTables))
+		Expect(iptablesRunner.CombinedOutputArgsForCall(0)).To(Equal(runner.Command{Args: []string{"--version"}}))
+	})
+
+	It("detects the legacy backend", func() {
+		iptablesRunner.CombinedOutputReturns([]byte("iptables v1.8.7 (legacy)\n"), nil)
+
+		backend, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend).To(Equal(rules.BackendLegacy))
+	})
+
+	It("treats versions without a backend as legacy", func() {
+		iptablesRunner.CombinedOutputReturns([]byte("iptables v1.6.1\n"), nil)
+
+		backend, err := detector.Detect()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(backend).To(Equal(rules.BackendLegacy))
+	})
+
+	Context("when iptables fails", func() {
+		BeforeEach(func() {
+			iptablesRunner.CombinedOutputReturns([]byte("not found"), errors.New("exit status 127"))
+		})
+
+		It("returns the error with the output", func() {
+			_, err := detector.Detect()
+			Expect(err).To(MatchError("iptables --version: exit status 127: not found"))
+		})
+	})
+
+	Context("when the output has no version", func() {
+		BeforeEach(func() {
+			iptablesRunner.CombinedOutputReturns([]byte("banana"), nil)
+		})
+
+		It("returns an error", func() {
+			_, err := detector.Detect()
+			Expect(err).To(MatchError(`no iptables version in "banana"`))
+		})
+	})
+})
@@ -0,0 +1,68 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The chains the vxlan-policy-agent manages are named <prefix><suffix>, where the suffix encodes the time
+// the chain was created. Version 0 suffixes are the 10 to 16 decimal digits
+// written before chain names were versioned. Later versions are written as
+// v<version>-<time>, so that a name never parses under the wrong version.
+const (
+	ChainNameVersionLegacy = 0
+	ChainNameVersion1      = 1
+)
+
+// MaxChainNameLength is the longest chain name iptables accepts.
+const MaxChainNameLength = 28
+
+// ChainNameSuffixPattern matches the suffix of every known chain name version.
+const ChainNameSuffixPattern = `([0-9]{10,16}|v1-[0-9a-z]{1,13})`
+
+// ChainName returns the name of the managed chain created at t. Version 1
+// encodes the time in base 36 to leave room in the 28 character limit on
+// iptables chain names.
+func ChainName(prefix string, version int, t int64) string {
+	switch version {
+	case ChainNameVersionLegacy:
+		return fmt.Sprintf("%s%d", prefix, t)
+	default:
+		return fmt.Sprintf("%sv%d-%s", prefix, ChainNameVersion1, strconv.FormatInt(t, 36))
+	}
+}
+
+// ParseChainNameSuffix returns the version and time encoded in the suffix of a
+// managed chain name.
+func ParseChainNameSuffix(suffix string) (int, int64, error) {
+	if !strings.HasPrefix(suffix, "v") {
+		t, err := strconv.ParseInt(suffix, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse chain time %q: %s", suffix, err)
+		}
+		return ChainNameVersionLegacy, t, nil
+	}
+
+	version, encoded, ok := strings.Cut(suffix[1:], "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("missing chain time in %q", suffix)
+	}
+	switch version {
+	case "1":
+		t, err := strconv.ParseInt(encoded, 36, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse chain time %q: %s", suffix, err)
+		}
+		return ChainNameVersion1, t, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown chain name version %q", version)
+	}
+}
+
+// ManagedChainRegexp matches the full name of a managed chain whose prefix
+// matches managedChainsRegex. The suffix is captured by the last group.
+func ManagedChainRegexp(managedChainsRegex string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`^(?:%s)%s$`, managedChainsRegex, ChainNameSuffixPattern))
+}
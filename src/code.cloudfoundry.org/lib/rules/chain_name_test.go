@@ -0,0 +1,59 @@
+package rules_test
+
+import (
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChainName", func() {
+	It("names legacy chains with the decimal time", func() {
+		Expect(rules.ChainName("vpa--", rules.ChainNameVersionLegacy, 1645708469990518)).To(Equal("vpa--1645708469990518"))
+	})
+
+	It("names version 1 chains with the version and the base 36 time", func() {
+		Expect(rules.ChainName("vpa--", rules.ChainNameVersion1, 1645708469990518)).To(Equal("vpa--v1-g7cs183k3a"))
+	})
+
+	It("round trips through ParseChainNameSuffix", func() {
+		for _, version := range []int{rules.ChainNameVersionLegacy, rules.ChainNameVersion1} {
+			name := rules.ChainName("vpa--", version, 1645708469990518)
+			matches := rules.ManagedChainRegexp("vpa--").FindStringSubmatch(name)
+			Expect(matches).To(HaveLen(2))
+
+			parsedVersion, t, err := rules.ParseChainNameSuffix(matches[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsedVersion).To(Equal(version))
+			Expect(t).To(Equal(int64(1645708469990518)))
+		}
+	})
+})
+
+var _ = Describe("ParseChainNameSuffix", func() {
+	It("returns an error for unknown versions", func() {
+		_, _, err := rules.ParseChainNameSuffix("v9-abc")
+		Expect(err).To(MatchError(`unknown chain name version "9"`))
+	})
+
+	It("returns an error when the time is missing", func() {
+		_, _, err := rules.ParseChainNameSuffix("v1")
+		Expect(err).To(MatchError(`missing chain time in "v1"`))
+	})
+
+	It("returns an error when the time is invalid", func() {
+		_, _, err := rules.ParseChainNameSuffix("banana")
+		Expect(err).To(MatchError(ContainSubstring(`parse chain time "banana"`)))
+	})
+})
+
+var _ = Describe("ManagedChainRegexp", func() {
+	It("only matches complete managed chain names", func() {
+		re := rules.ManagedChainRegexp("vpa--")
+		Expect(re.MatchString("vpa--1645708469990518")).To(BeTrue())
+		Expect(re.MatchString("vpa--v1-g7cs183k3a")).To(BeTrue())
+		Expect(re.MatchString("vpa--")).To(BeFalse())
+		Expect(re.MatchString("vpa--v2-g7cs183k3a")).To(BeFalse())
+		Expect(re.MatchString("my-vpa--v1-g7cs183k3a")).To(BeFalse())
+	})
+})
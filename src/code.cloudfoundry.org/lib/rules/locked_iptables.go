@@ -1,11 +1,13 @@
 package rules
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
 
 	"code.cloudfoundry.org/cf-networking-helpers/runner"
+	"github.com/google/shlex"
 )
 
 //go:generate counterfeiter -o ../fakes/iptables.go --fake-name IPTables . iptables
@@ -29,14 +31,36 @@ type IPTablesAdapter interface {
 	DeleteAfterRuleNum(table, chain string, ruleNum int) error
 	DeleteAfterRuleNumKeepReject(table, chain string, ruleNum int) error
 	List(table, chain string) ([]string, error)
+	ListParsed(table, chain string) ([]ParsedRule, error)
 	ListChains(table string) ([]string, error)
 	NewChain(table, chain string) error
 	ClearChain(table, chain string) error
 	DeleteChain(table, chain string) error
 	BulkInsert(table, chain string, pos int, rulespec ...IPTablesRule) error
 	BulkAppend(table, chain string, rulespec ...IPTablesRule) error
+	EnsureRules(table, chain string, rulespec ...IPTablesRule) error
+	ReplaceChain(table, chain string, rulespec ...IPTablesRule) error
+	ReplaceChains(chains ...ChainSpec) error
 	RuleCount(table string) (int, error)
 	AllowTrafficForRange(rulespec ...IPTablesRule) error
+	// WithContext returns an adapter whose calls stop when the context is
+	// done, so that callers can bound them with a deadline.
+	WithContext(ctx context.Context) IPTablesAdapter
+}
+
+type operationKey struct{}
+
+// WithOperation names the operation that the iptables calls made with the
+// context are made for, so that their recordings and errors can be told
+// apart.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// Operation is the operation named by WithOperation, or "".
+func Operation(ctx context.Context) string {
+	operation, _ := ctx.Value(operationKey{}).(string)
+	return operation
 }
 
 //go:generate counterfeiter -o ../fakes/command_runner.go --fake-name CommandRunner . commandRunner
@@ -52,18 +76,21 @@ type locker interface {
 
 //go:generate counterfeiter -o ../fakes/restorer.go --fake-name Restorer . restorer
 type restorer interface {
-	Restore(ruleState string) error
-	RestoreWithFlags(ruleState string, iptablesFlags ...string) error
+	Restore(ctx context.Context, ruleState string) error
+	RestoreWithFlags(ctx context.Context, ruleState string, iptablesFlags ...string) error
 }
 
+// Restorer runs iptables-restore, which is killed when the context is done.
+// The rules of a table are committed at once, so a killed restore leaves the
+// table as it was.
 type Restorer struct{}
 
-func (r *Restorer) Restore(input string) error {
-	return r.RestoreWithFlags(input, "--noflush")
+func (r *Restorer) Restore(ctx context.Context, input string) error {
+	return r.RestoreWithFlags(ctx, input, "--noflush")
 }
 
-func (r *Restorer) RestoreWithFlags(input string, iptablesFlags ...string) error {
-	cmd := exec.Command("iptables-restore", iptablesFlags...)
+func (r *Restorer) RestoreWithFlags(ctx context.Context, input string, iptablesFlags ...string) error {
+	cmd := exec.CommandContext(ctx, "iptables-restore", iptablesFlags...)
 	cmd.Stdin = strings.NewReader(input)
 
 	bytes, err := cmd.CombinedOutput()
@@ -73,11 +100,57 @@ func (r *Restorer) RestoreWithFlags(input string, iptablesFlags ...string) error
 	return nil
 }
 
+// LockedIPTables makes the iptables calls of the components of a cell under
+// one lock. With a context, a call stops before it takes the lock or runs an
+// iptables execution once the context is done, and a running iptables-restore
+// is killed. A running iptables execution is waited for, as it holds the lock.
 type LockedIPTables struct {
 	IPTables       iptables
 	Locker         locker
 	Restorer       restorer
 	IPTablesRunner commandRunner
+
+	ctx context.Context
+}
+
+func (l *LockedIPTables) WithContext(ctx context.Context) IPTablesAdapter {
+	withContext := *l
+	withContext.ctx = ctx
+	return &withContext
+}
+
+func (l *LockedIPTables) context() context.Context {
+	if l.ctx == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
+
+// stopped is the error of a call whose context is done, if it is.
+func (l *LockedIPTables) stopped() error {
+	ctx := l.context()
+	if ctx.Err() == nil {
+		return nil
+	}
+	if operation := Operation(ctx); operation != "" {
+		return fmt.Errorf("iptables call for %s stopped: %s", operation, ctx.Err())
+	}
+	return fmt.Errorf("iptables call stopped: %s", ctx.Err())
+}
+
+// lock takes the iptables lock, unless the context is done before or while
+// waiting for it.
+func (l *LockedIPTables) lock() error {
+	if err := l.stopped(); err != nil {
+		return err
+	}
+	if err := l.Locker.Lock(); err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+	if err := l.stopped(); err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+	return nil
 }
 
 func handleIPTablesError(err1, err2 error) error {
@@ -85,11 +158,11 @@ func handleIPTablesError(err1, err2 error) error {
 }
 
 func (l *LockedIPTables) FlushAndRestore(rawInput string) error {
-	if err := l.Locker.Lock(); err != nil {
-		return fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return err
 	}
 
-	err := l.Restorer.RestoreWithFlags(rawInput)
+	err := l.Restorer.RestoreWithFlags(l.context(), rawInput)
 	if err != nil {
 		return handleIPTablesError(err, l.Locker.Unlock())
 	}
@@ -98,8 +171,8 @@ func (l *LockedIPTables) FlushAndRestore(rawInput string) error {
 }
 
 func (l *LockedIPTables) Exists(table, chain string, rulespec IPTablesRule) (bool, error) {
-	if err := l.Locker.Lock(); err != nil {
-		return false, fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return false, err
 	}
 
 	b, err := l.IPTables.Exists(table, chain, rulespec...)
@@ -110,19 +183,22 @@ func (l *LockedIPTables) Exists(table, chain string, rulespec IPTablesRule) (boo
 	return b, l.Locker.Unlock()
 }
 
-func (l *LockedIPTables) bulkAction(table, prefix string, rulespec ...IPTablesRule) error {
-	if err := l.Locker.Lock(); err != nil {
-		return fmt.Errorf("lock: %s", err)
-	}
-
+func restoreInput(table, prefix string, rulespec ...IPTablesRule) string {
 	input := []string{fmt.Sprintf("*%s\n", table)}
 	for _, r := range rulespec {
 		tmp := fmt.Sprintf("%s %s\n", prefix, strings.Join(r, " "))
 		input = append(input, tmp)
 	}
 	input = append(input, "COMMIT\n")
+	return strings.Join(input, "")
+}
+
+func (l *LockedIPTables) bulkAction(table, prefix string, rulespec ...IPTablesRule) error {
+	if err := l.lock(); err != nil {
+		return err
+	}
 
-	err := l.Restorer.Restore(strings.Join(input, ""))
+	err := l.Restorer.Restore(l.context(), restoreInput(table, prefix, rulespec...))
 	if err != nil {
 		return handleIPTablesError(err, l.Locker.Unlock())
 	}
@@ -142,9 +218,154 @@ func (l *LockedIPTables) BulkAppend(table, chain string, rulespec ...IPTablesRul
 	return l.bulkAction(table, fmt.Sprintf("-A %s", chain), rulespec...)
 }
 
+// EnsureRules appends the rules the chain does not contain yet. The checks
+// and a single restore of the missing rules happen under one lock, so
+// retried callers never add a rule twice.
+func (l *LockedIPTables) EnsureRules(table, chain string, rulespec ...IPTablesRule) error {
+	// the rules are written for iptables-restore, so quoted args such as log
+	// prefixes have to be unquoted before checking through the iptables binary
+	parsed := make([][]string, len(rulespec))
+	for i, r := range rulespec {
+		args, err := shlex.Split(strings.Join(r, " "))
+		if err != nil {
+			return fmt.Errorf("parsing rule: %s", err)
+		}
+		parsed[i] = args
+	}
+
+	if err := l.lock(); err != nil {
+		return err
+	}
+
+	missing := []IPTablesRule{}
+	for i, args := range parsed {
+		if err := l.stopped(); err != nil {
+			return handleIPTablesError(err, l.Locker.Unlock())
+		}
+		exists, err := l.IPTables.Exists(table, chain, args...)
+		if err != nil {
+			return handleIPTablesError(err, l.Locker.Unlock())
+		}
+		if !exists {
+			missing = append(missing, rulespec[i])
+		}
+	}
+	if len(missing) == 0 {
+		return l.Locker.Unlock()
+	}
+	if err := l.stopped(); err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+
+	err := l.Restorer.Restore(l.context(), restoreInput(table, fmt.Sprintf("-A %s", chain), missing...))
+	if err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+
+	return l.Locker.Unlock()
+}
+
+// ReplaceChain creates the chain, or flushes it if it exists, and writes the
+// rules to it in a single restore. Calling it again leaves the same chain.
+func (l *LockedIPTables) ReplaceChain(table, chain string, rulespec ...IPTablesRule) error {
+	if err := l.lock(); err != nil {
+		return err
+	}
+
+	input := restoreInput(table, fmt.Sprintf("-A %s", chain), rulespec...)
+	input = strings.Replace(input, "\n", fmt.Sprintf("\n:%s - [0:0]\n", chain), 1)
+	err := l.Restorer.Restore(l.context(), input)
+	if err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+
+	return l.Locker.Unlock()
+}
+
+// ChainSpec is a chain with its rules and the jumps to it from its parent
+// chain, if it has one.
+type ChainSpec struct {
+	Table       string
+	ParentChain string
+	Chain       string
+	Jumps       []IPTablesRule
+	Rules       []IPTablesRule
+}
+
+// ReplaceChains replaces the chains as ReplaceChain does and appends the
+// jumps to them that their parent chains lack, with a single iptables-restore
+// for all of their tables under one lock, so that the chains of several
+// containers are written at once.
+func (l *LockedIPTables) ReplaceChains(chains ...ChainSpec) error {
+	parsedJumps := make([][][]string, len(chains))
+	for i, chain := range chains {
+		for _, jump := range chain.Jumps {
+			args, err := shlex.Split(strings.Join(jump, " "))
+			if err != nil {
+				return fmt.Errorf("parsing rule: %s", err)
+			}
+			parsedJumps[i] = append(parsedJumps[i], args)
+		}
+	}
+
+	if err := l.lock(); err != nil {
+		return err
+	}
+
+	tables := []string{}
+	declarations := map[string][]string{}
+	lines := map[string][]string{}
+	jumps := map[string][]string{}
+	for i, chain := range chains {
+		if _, ok := declarations[chain.Table]; !ok {
+			tables = append(tables, chain.Table)
+		}
+		declarations[chain.Table] = append(declarations[chain.Table], fmt.Sprintf(":%s - [0:0]\n", chain.Chain))
+		for _, rule := range chain.Rules {
+			lines[chain.Table] = append(lines[chain.Table], fmt.Sprintf("-A %s %s\n", chain.Chain, strings.Join(rule, " ")))
+		}
+
+		for j, args := range parsedJumps[i] {
+			if err := l.stopped(); err != nil {
+				return handleIPTablesError(err, l.Locker.Unlock())
+			}
+			exists, err := l.IPTables.Exists(chain.Table, chain.ParentChain, args...)
+			if err != nil {
+				return handleIPTablesError(err, l.Locker.Unlock())
+			}
+			if !exists {
+				jumps[chain.Table] = append(jumps[chain.Table], fmt.Sprintf("-A %s %s\n", chain.ParentChain, strings.Join(chain.Jumps[j], " ")))
+			}
+		}
+	}
+	if len(tables) == 0 {
+		return l.Locker.Unlock()
+	}
+	if err := l.stopped(); err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+
+	// the jumps go after the rules, so that no packet jumps to a chain that
+	// is not complete
+	input := []string{}
+	for _, table := range tables {
+		input = append(input, fmt.Sprintf("*%s\n", table))
+		input = append(input, declarations[table]...)
+		input = append(input, lines[table]...)
+		input = append(input, jumps[table]...)
+		input = append(input, "COMMIT\n")
+	}
+	err := l.Restorer.Restore(l.context(), strings.Join(input, ""))
+	if err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
+
+	return l.Locker.Unlock()
+}
+
 func (l *LockedIPTables) Delete(table, chain string, rulespec IPTablesRule) error {
-	if err := l.Locker.Lock(); err != nil {
-		return fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return err
 	}
 
 	err := l.IPTables.Delete(table, chain, rulespec...)
@@ -156,8 +377,8 @@ func (l *LockedIPTables) Delete(table, chain string, rulespec IPTablesRule) erro
 }
 
 func (l *LockedIPTables) DeleteAfterRuleNum(table, chain string, ruleNum int) error {
-	if err := l.Locker.Lock(); err != nil {
-		return fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return err
 	}
 
 	rules, err := l.IPTables.List(table, chain)
@@ -169,6 +390,9 @@ func (l *LockedIPTables) DeleteAfterRuleNum(table, chain string, ruleNum int) er
 	//so this takes the place of the '0' index of rules, and we don't need to offset anything
 	for range rules[ruleNum:] {
 		// rule numbers adjust after each deletion, so always delete the same number each time
+		if err := l.stopped(); err != nil {
+			return handleIPTablesError(err, l.Locker.Unlock())
+		}
 		err := l.IPTables.Delete(table, chain, fmt.Sprintf("%d", ruleNum), "--wait")
 		if err != nil {
 			return handleIPTablesError(err, l.Locker.Unlock())
@@ -179,8 +403,8 @@ func (l *LockedIPTables) DeleteAfterRuleNum(table, chain string, ruleNum int) er
 }
 
 func (l *LockedIPTables) DeleteAfterRuleNumKeepReject(table, chain string, ruleNum int) error {
-	if err := l.Locker.Lock(); err != nil {
-		return fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return err
 	}
 
 	rules, err := l.IPTables.List(table, chain)
@@ -192,11 +416,17 @@ func (l *LockedIPTables) DeleteAfterRuleNumKeepReject(table, chain string, ruleN
 	//so this takes the place of the '0' index of rules, and we don't need to offset anything
 	for range rules[ruleNum:] {
 		// rule numbers adjust after each deletion, so always delete the same number each time
+		if err := l.stopped(); err != nil {
+			return handleIPTablesError(err, l.Locker.Unlock())
+		}
 		err := l.IPTables.Delete(table, chain, fmt.Sprintf("%d", ruleNum), "--wait")
 		if err != nil {
 			return handleIPTablesError(err, l.Locker.Unlock())
 		}
 	}
+	if err := l.stopped(); err != nil {
+		return handleIPTablesError(err, l.Locker.Unlock())
+	}
 	err = l.IPTables.AppendUnique(table, chain, NewInputDefaultRejectRule()...)
 	if err != nil {
 		return handleIPTablesError(err, l.Locker.Unlock())
@@ -206,8 +436,8 @@ func (l *LockedIPTables) DeleteAfterRuleNumKeepReject(table, chain string, ruleN
 }
 
 func (l *LockedIPTables) List(table, chain string) ([]string, error) {
-	if err := l.Locker.Lock(); err != nil {
-		return nil, fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return nil, err
 	}
 
 	ret, err := l.IPTables.List(table, chain)
@@ -218,9 +448,19 @@ func (l *LockedIPTables) List(table, chain string) ([]string, error) {
 	return ret, l.Locker.Unlock()
 }
 
+// ListParsed lists the rules of the chain with their matches, target and
+// comment.
+func (l *LockedIPTables) ListParsed(table, chain string) ([]ParsedRule, error) {
+	listing, err := l.List(table, chain)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(listing)
+}
+
 func (l *LockedIPTables) ListChains(table string) ([]string, error) {
-	if err := l.Locker.Lock(); err != nil {
-		return nil, fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return nil, err
 	}
 
 	ret, err := l.IPTables.ListChains(table)
@@ -232,8 +472,8 @@ func (l *LockedIPTables) ListChains(table string) ([]string, error) {
 }
 
 func (l *LockedIPTables) RuleCount(table string) (int, error) {
-	if err := l.Locker.Lock(); err != nil {
-		return -1, fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return -1, err
 	}
 
 	command := runner.Command{
@@ -262,8 +502,8 @@ func (l *LockedIPTables) DeleteChain(table, chain string) error {
 }
 
 func (l *LockedIPTables) chainExec(table, chain string, action func(string, string) error) error {
-	if err := l.Locker.Lock(); err != nil {
-		return fmt.Errorf("lock: %s", err)
+	if err := l.lock(); err != nil {
+		return err
 	}
 	if err := action(table, chain); err != nil {
 		return handleIPTablesError(err, l.Locker.Unlock())
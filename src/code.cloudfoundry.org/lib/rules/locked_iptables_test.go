@@ -1,6 +1,7 @@
 package rules_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -35,6 +36,98 @@ var _ = Describe("LockedIptables", func() {
 		rulespec = []string{"some", "args"}
 		rule = rules.IPTablesRule{"some", "args"}
 	})
+	Describe("WithContext", func() {
+		var (
+			ctx    context.Context
+			cancel context.CancelFunc
+		)
+
+		BeforeEach(func() {
+			ctx, cancel = context.WithCancel(rules.WithOperation(context.Background(), "some-operation"))
+		})
+
+		AfterEach(func() {
+			cancel()
+		})
+
+		It("passes the context to the restorer", func() {
+			err := lockedIPT.WithContext(ctx).BulkAppend("some-table", "some-chain", rule)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(restorer.RestoreCallCount()).To(Equal(1))
+			restoreCtx, _ := restorer.RestoreArgsForCall(0)
+			Expect(restoreCtx).To(Equal(ctx))
+		})
+
+		It("leaves the adapter it was called on without the context", func() {
+			lockedIPT.WithContext(ctx)
+			cancel()
+
+			Expect(lockedIPT.BulkAppend("some-table", "some-chain", rule)).To(Succeed())
+		})
+
+		Context("when the context is done", func() {
+			BeforeEach(func() {
+				cancel()
+			})
+
+			It("does not take the lock", func() {
+				err := lockedIPT.WithContext(ctx).BulkAppend("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("iptables call for some-operation stopped: context canceled"))
+
+				Expect(lock.LockCallCount()).To(Equal(0))
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+			})
+
+			Context("without an operation", func() {
+				BeforeEach(func() {
+					ctx, cancel = context.WithCancel(context.Background())
+					cancel()
+				})
+
+				It("returns the error of the context", func() {
+					_, err := lockedIPT.WithContext(ctx).List("some-table", "some-chain")
+					Expect(err).To(MatchError("iptables call stopped: context canceled"))
+				})
+			})
+		})
+
+		Context("when the context is done while waiting for the lock", func() {
+			BeforeEach(func() {
+				lock.LockStub = func() error {
+					cancel()
+					return nil
+				}
+			})
+
+			It("releases the lock without calling iptables", func() {
+				_, err := lockedIPT.WithContext(ctx).Exists("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("iptables call: iptables call for some-operation stopped: context canceled and unlock: <nil>"))
+
+				Expect(lock.UnlockCallCount()).To(Equal(1))
+				Expect(ipt.ExistsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the context is done between the iptables executions of a call", func() {
+			BeforeEach(func() {
+				ipt.ListReturns([]string{"-N some-chain", "rule-1", "rule-2", "rule-3"}, nil)
+				ipt.DeleteStub = func(string, string, ...string) error {
+					cancel()
+					return nil
+				}
+			})
+
+			It("stops before the next execution", func() {
+				err := lockedIPT.WithContext(ctx).DeleteAfterRuleNum("some-table", "some-chain", 1)
+				Expect(err).To(MatchError(ContainSubstring("context canceled")))
+
+				Expect(ipt.DeleteCallCount()).To(Equal(1))
+				Expect(lock.UnlockCallCount()).To(Equal(1))
+			})
+		})
+	})
+
 	Describe("BulkInsert", func() {
 		var ruleSet []rules.IPTablesRule
 		BeforeEach(func() {
@@ -51,7 +144,7 @@ var _ = Describe("LockedIptables", func() {
 			Expect(lock.LockCallCount()).To(Equal(1))
 			Expect(lock.UnlockCallCount()).To(Equal(1))
 			Expect(restorer.RestoreCallCount()).To(Equal(1))
-			restoreInput := restorer.RestoreArgsForCall(0)
+			_, restoreInput := restorer.RestoreArgsForCall(0)
 			Expect(restoreInput).To(ContainSubstring("*some-table\n"))
 			Expect(restoreInput).To(ContainSubstring("-I some-chain 1 --source 1.2.3.4 --jump MARK --set-xmark 0xA -m comment --comment src:a-guid\n"))
 			Expect(restoreInput).To(ContainSubstring("-I some-chain 1 --source 2.2.2.2 --jump MARK --set-xmark 0xB -m comment --comment src:b-guid\n"))
@@ -114,7 +207,7 @@ var _ = Describe("LockedIptables", func() {
 			Expect(lock.LockCallCount()).To(Equal(1))
 			Expect(lock.UnlockCallCount()).To(Equal(1))
 			Expect(restorer.RestoreCallCount()).To(Equal(1))
-			restoreInput := restorer.RestoreArgsForCall(0)
+			_, restoreInput := restorer.RestoreArgsForCall(0)
 			Expect(restoreInput).To(ContainSubstring("*some-table\n"))
 			Expect(restoreInput).To(ContainSubstring("-A some-chain --source 1.2.3.4 --jump MARK --set-xmark 0xA -m comment --comment src:a-guid\n"))
 			Expect(restoreInput).To(ContainSubstring("-A some-chain --source 2.2.2.2 --jump MARK --set-xmark 0xB -m comment --comment src:b-guid\n"))
@@ -161,6 +254,212 @@ var _ = Describe("LockedIptables", func() {
 		})
 	})
 
+	Describe("EnsureRules", func() {
+		var logRule rules.IPTablesRule
+		BeforeEach(func() {
+			logRule = rules.IPTablesRule{"--jump", "LOG", "--log-prefix", `"OK_some-handle "`}
+		})
+
+		It("appends the missing rules in a single restore", func() {
+			ipt.ExistsReturnsOnCall(0, true, nil)
+			err := lockedIPT.EnsureRules("some-table", "some-chain", rule, logRule)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+
+			Expect(ipt.ExistsCallCount()).To(Equal(2))
+			table, chain, spec := ipt.ExistsArgsForCall(1)
+			Expect(table).To(Equal("some-table"))
+			Expect(chain).To(Equal("some-chain"))
+			Expect(spec).To(Equal([]string{"--jump", "LOG", "--log-prefix", "OK_some-handle "}))
+
+			Expect(restorer.RestoreCallCount()).To(Equal(1))
+			_, restoreInput := restorer.RestoreArgsForCall(0)
+			Expect(restoreInput).To(Equal("*some-table\n-A some-chain --jump LOG --log-prefix \"OK_some-handle \"\nCOMMIT\n"))
+		})
+
+		Context("when all rules already exist", func() {
+			BeforeEach(func() {
+				ipt.ExistsReturns(true, nil)
+			})
+			It("does not add them again", func() {
+				err := lockedIPT.EnsureRules("some-table", "some-chain", rule, logRule)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+				Expect(lock.UnlockCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when a rule cannot be parsed", func() {
+			It("returns an error without locking", func() {
+				err := lockedIPT.EnsureRules("some-table", "some-chain", rule, rules.IPTablesRule{`"unterminated`})
+				Expect(err).To(MatchError(ContainSubstring("parsing rule:")))
+				Expect(lock.LockCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the lock fails", func() {
+			BeforeEach(func() {
+				lock.LockReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.EnsureRules("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("lock: banana"))
+			})
+		})
+
+		Context("when checking for a rule fails", func() {
+			BeforeEach(func() {
+				ipt.ExistsReturns(false, errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.EnsureRules("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the restorer fails", func() {
+			BeforeEach(func() {
+				restorer.RestoreReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.EnsureRules("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+			})
+		})
+
+		Context("when the unlock fails", func() {
+			BeforeEach(func() {
+				lock.UnlockReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.EnsureRules("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
+	Describe("ReplaceChain", func() {
+		It("declares the chain and writes its rules in a single restore", func() {
+			err := lockedIPT.ReplaceChain("some-table", "some-chain", rule, rule)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			Expect(restorer.RestoreCallCount()).To(Equal(1))
+			_, restoreInput := restorer.RestoreArgsForCall(0)
+			Expect(restoreInput).To(Equal("*some-table\n:some-chain - [0:0]\n-A some-chain some args\n-A some-chain some args\nCOMMIT\n"))
+		})
+
+		It("only flushes the chain when there are no rules", func() {
+			err := lockedIPT.ReplaceChain("some-table", "some-chain")
+			Expect(err).NotTo(HaveOccurred())
+			_, restoreInput := restorer.RestoreArgsForCall(0)
+			Expect(restoreInput).To(Equal("*some-table\n:some-chain - [0:0]\nCOMMIT\n"))
+		})
+
+		Context("when the lock fails", func() {
+			BeforeEach(func() {
+				lock.LockReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.ReplaceChain("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("lock: banana"))
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the restorer fails", func() {
+			BeforeEach(func() {
+				restorer.RestoreReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.ReplaceChain("some-table", "some-chain", rule)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+			})
+		})
+	})
+
+	Describe("ReplaceChains", func() {
+		var chains []rules.ChainSpec
+
+		BeforeEach(func() {
+			chains = []rules.ChainSpec{
+				{Table: "filter", ParentChain: "FORWARD", Chain: "netout-1", Jumps: []rules.IPTablesRule{{"-s", "10.255.0.1", "--jump", "netout-1"}}, Rules: []rules.IPTablesRule{rule}},
+				{Table: "nat", ParentChain: "PREROUTING", Chain: "netin-1", Jumps: []rules.IPTablesRule{{"--jump", "netin-1"}}},
+				{Table: "filter", ParentChain: "FORWARD", Chain: "netout-2", Jumps: []rules.IPTablesRule{{"-s", "10.255.0.2", "--jump", "netout-2"}}, Rules: []rules.IPTablesRule{rule}},
+			}
+		})
+
+		It("writes the chains of all tables and the jumps to them in a single restore", func() {
+			err := lockedIPT.ReplaceChains(chains...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			Expect(restorer.RestoreCallCount()).To(Equal(1))
+			_, restoreInput := restorer.RestoreArgsForCall(0)
+			Expect(restoreInput).To(Equal("*filter\n" +
+				":netout-1 - [0:0]\n" +
+				":netout-2 - [0:0]\n" +
+				"-A netout-1 some args\n" +
+				"-A netout-2 some args\n" +
+				"-A FORWARD -s 10.255.0.1 --jump netout-1\n" +
+				"-A FORWARD -s 10.255.0.2 --jump netout-2\n" +
+				"COMMIT\n" +
+				"*nat\n" +
+				":netin-1 - [0:0]\n" +
+				"-A PREROUTING --jump netin-1\n" +
+				"COMMIT\n"))
+		})
+
+		It("does not append the jumps that exist", func() {
+			ipt.ExistsStub = func(table, chain string, spec ...string) (bool, error) {
+				return table == "nat", nil
+			}
+			err := lockedIPT.ReplaceChains(chains...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipt.ExistsCallCount()).To(Equal(3))
+			table, chain, spec := ipt.ExistsArgsForCall(1)
+			Expect(table).To(Equal("nat"))
+			Expect(chain).To(Equal("PREROUTING"))
+			Expect(spec).To(Equal([]string{"--jump", "netin-1"}))
+			_, restoreInput := restorer.RestoreArgsForCall(0)
+			Expect(restoreInput).To(HaveSuffix("*nat\n:netin-1 - [0:0]\nCOMMIT\n"))
+		})
+
+		It("does nothing without chains", func() {
+			Expect(lockedIPT.ReplaceChains()).To(Succeed())
+			Expect(restorer.RestoreCallCount()).To(Equal(0))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+		})
+
+		Context("when checking a jump fails", func() {
+			BeforeEach(func() {
+				ipt.ExistsReturns(false, errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.ReplaceChains(chains...)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the restorer fails", func() {
+			BeforeEach(func() {
+				restorer.RestoreReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := lockedIPT.ReplaceChains(chains...)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+			})
+		})
+	})
+
 	Describe("Exists", func() {
 		BeforeEach(func() {
 			ipt.ExistsReturns(true, nil)
@@ -432,6 +731,49 @@ var _ = Describe("LockedIptables", func() {
 		})
 	})
 
+	Describe("ListParsed", func() {
+		BeforeEach(func() {
+			ipt.ListReturns([]string{
+				"-N some-chain",
+				"-A some-chain -s 10.0.0.0/8 -g some-chain--log",
+			}, nil)
+		})
+		It("lists the rules of the chain parsed", func() {
+			parsed, err := lockedIPT.ListParsed("some-table", "some-chain")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(HaveLen(1))
+			Expect(parsed[0].Chain).To(Equal("some-chain"))
+			Expect(parsed[0].Target).To(Equal("some-chain--log"))
+			Expect(parsed[0].Goto).To(BeTrue())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			table, chain := ipt.ListArgsForCall(0)
+			Expect(table).To(Equal("some-table"))
+			Expect(chain).To(Equal("some-chain"))
+		})
+
+		Context("when iptables call fails", func() {
+			BeforeEach(func() {
+				ipt.ListReturns(nil, errors.New("banana"))
+			})
+			It("returns an error", func() {
+				_, err := lockedIPT.ListParsed("some-table", "some-chain")
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+			})
+		})
+
+		Context("when a rule cannot be parsed", func() {
+			BeforeEach(func() {
+				ipt.ListReturns([]string{`-A some-chain -m comment --comment "unterminated`}, nil)
+			})
+			It("returns an error", func() {
+				_, err := lockedIPT.ListParsed("some-table", "some-chain")
+				Expect(err).To(MatchError(ContainSubstring("parse rule")))
+			})
+		})
+	})
+
 	Describe("ListChains", func() {
 		BeforeEach(func() {
 			ipt.ListChainsReturns([]string{"some", "list"}, nil)
@@ -720,7 +1062,9 @@ var _ = Describe("LockedIptables", func() {
 			Expect(lock.LockCallCount()).To(Equal(1))
 			Expect(lock.UnlockCallCount()).To(Equal(1))
 			Expect(lockedIPT.Restorer.(*fakes.Restorer).RestoreWithFlagsCallCount()).To(Equal(1))
-			Expect(lockedIPT.Restorer.(*fakes.Restorer).RestoreWithFlagsArgsForCall(0)).To(Equal("rule1\nrule2\n"))
+			_, restoreInput, flags := lockedIPT.Restorer.(*fakes.Restorer).RestoreWithFlagsArgsForCall(0)
+			Expect(restoreInput).To(Equal("rule1\nrule2\n"))
+			Expect(flags).To(BeEmpty())
 		})
 		Context("when locking fails", func() {
 			BeforeEach(func() {
@@ -0,0 +1,190 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
+)
+
+const (
+	// LogEventsComment marks the rules that count the packets the LOG rule
+	// after them would log without rate limits.
+	LogEventsComment = "cf-log-events"
+
+	logLimitNamePrefix = "cflog-"
+	// MaxLogLimit keeps twice the rate and burst within the limits of the
+	// hashlimit match.
+	MaxLogLimit = 5000
+)
+
+// LogLimit caps the messages that the LOG rules of the cell write to the
+// kernel log together, on top of the limits of each rule, so that a deny
+// storm of many containers cannot flood the kernel ring buffer. The rules
+// share a hashlimit without a mode, which is a single bucket, named after
+// the limit so that rules written with an earlier limit keep a bucket of
+// their own. A zero PerSecond does not cap the messages, and a zero Burst
+// is PerSecond.
+type LogLimit struct {
+	PerSecond int
+	Burst     int
+}
+
+func (l LogLimit) Enabled() bool {
+	return l.PerSecond > 0
+}
+
+func (l LogLimit) burst() int {
+	if l.Burst == 0 {
+		return l.PerSecond
+	}
+	return l.Burst
+}
+
+func (l LogLimit) name() string {
+	return fmt.Sprintf("%s%d-%d", logLimitNamePrefix, l.PerSecond, l.burst())
+}
+
+// Apply caps the LOG rules of ruleSpec and puts a rule before each that
+// counts the packets it would log without rate limits. The counting rule
+// takes a credit of the bucket of a packet under the limit as well, so the
+// bucket fills at twice the rate.
+func (l LogLimit) Apply(ruleSpec []IPTablesRule) []IPTablesRule {
+	if !l.Enabled() {
+		return ruleSpec
+	}
+	limited := []IPTablesRule{}
+	for _, rule := range ruleSpec {
+		target := logTargetIndex(rule)
+		if target == -1 {
+			limited = append(limited, rule)
+			continue
+		}
+		conditions := rule[:target]
+
+		events := AppendComment(withoutRateLimits(conditions), LogEventsComment)
+		limitedRule := append(IPTablesRule{}, conditions...)
+		limitedRule = append(limitedRule,
+			"-m", "hashlimit", "--hashlimit-upto", fmt.Sprintf("%d/sec", 2*l.PerSecond),
+			"--hashlimit-burst", strconv.Itoa(2*l.burst()),
+			"--hashlimit-name", l.name(),
+		)
+		limitedRule = append(limitedRule, rule[target:]...)
+		limited = append(limited, events, limitedRule)
+	}
+	return limited
+}
+
+func logTargetIndex(rule IPTablesRule) int {
+	for i := 0; i+1 < len(rule); i++ {
+		if (rule[i] == "-j" || rule[i] == "--jump") && rule[i+1] == "LOG" {
+			return i
+		}
+	}
+	return -1
+}
+
+// withoutRateLimits drops the limit and hashlimit matches of the conditions
+// of a LOG rule, so that counting its packets does not take their credits.
+func withoutRateLimits(conditions IPTablesRule) IPTablesRule {
+	stripped := IPTablesRule{}
+	skipping := false
+	for i := 0; i < len(conditions); i++ {
+		arg := conditions[i]
+		if (arg == "-m" || arg == "--match") && i+1 < len(conditions) {
+			module := conditions[i+1]
+			skipping = module == "limit" || module == "hashlimit"
+			if skipping {
+				i++
+				continue
+			}
+		}
+		if skipping {
+			if arg == "!" || (isOptionName(arg) && !strings.HasPrefix(arg, "--limit") && !strings.HasPrefix(arg, "--hashlimit")) {
+				skipping = false
+			} else {
+				continue
+			}
+		}
+		stripped = append(stripped, arg)
+	}
+	return stripped
+}
+
+// SuppressedLogs is the number of packets that the capped LOG rules did not
+// log because of their rate limits, from the counters of the rules.
+func SuppressedLogs(parsed []ParsedRule) uint64 {
+	var events, logged uint64
+	for _, rule := range parsed {
+		if rule.Comment == LogEventsComment {
+			events += rule.Packets
+			continue
+		}
+		name, ok := rule.Option("--hashlimit-name")
+		if rule.Target == "LOG" && ok && strings.HasPrefix(name.Value(), logLimitNamePrefix) {
+			logged += rule.Packets
+		}
+	}
+	// the rules of a chain are counted from when they were written, so a
+	// chain can be rewritten between the listing of its two rules
+	if logged > events {
+		return 0
+	}
+	return events - logged
+}
+
+// LogLimitedIPTables caps the LOG rules that are written through it with
+// Limit.
+type LogLimitedIPTables struct {
+	IPTablesAdapter
+	Limit LogLimit
+}
+
+func (l *LogLimitedIPTables) WithContext(ctx context.Context) IPTablesAdapter {
+	return &LogLimitedIPTables{IPTablesAdapter: l.IPTablesAdapter.WithContext(ctx), Limit: l.Limit}
+}
+
+func (l *LogLimitedIPTables) BulkInsert(table, chain string, pos int, rulespec ...IPTablesRule) error {
+	return l.IPTablesAdapter.BulkInsert(table, chain, pos, l.Limit.Apply(rulespec)...)
+}
+
+func (l *LogLimitedIPTables) BulkAppend(table, chain string, rulespec ...IPTablesRule) error {
+	return l.IPTablesAdapter.BulkAppend(table, chain, l.Limit.Apply(rulespec)...)
+}
+
+func (l *LogLimitedIPTables) EnsureRules(table, chain string, rulespec ...IPTablesRule) error {
+	return l.IPTablesAdapter.EnsureRules(table, chain, l.Limit.Apply(rulespec)...)
+}
+
+func (l *LogLimitedIPTables) ReplaceChain(table, chain string, rulespec ...IPTablesRule) error {
+	return l.IPTablesAdapter.ReplaceChain(table, chain, l.Limit.Apply(rulespec)...)
+}
+
+func (l *LogLimitedIPTables) ReplaceChains(chains ...ChainSpec) error {
+	limited := make([]ChainSpec, len(chains))
+	for i, chain := range chains {
+		chain.Rules = l.Limit.Apply(chain.Rules)
+		limited[i] = chain
+	}
+	return l.IPTablesAdapter.ReplaceChains(limited...)
+}
+
+// SuppressedLogsCounter reads the suppressed logs from the counters of the
+// rules of the filter table.
+type SuppressedLogsCounter struct {
+	IPTablesRunner commandRunner
+}
+
+func (c *SuppressedLogsCounter) SuppressedLogs() (float64, error) {
+	output, err := c.IPTablesRunner.CombinedOutput(runner.Command{Args: []string{"-t", "filter", "-v", "-S"}})
+	if err != nil {
+		return 0, fmt.Errorf("iptables -v -S: %s: %s", err, output)
+	}
+	parsed, err := ParseRules(strings.Split(string(output), "\n"))
+	if err != nil {
+		return 0, err
+	}
+	return float64(SuppressedLogs(parsed)), nil
+}
@@ -0,0 +1,167 @@
+package rules_test
+
+import (
+	"context"
+	"errors"
+
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
+	"code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LogLimit", func() {
+	var limit rules.LogLimit
+
+	BeforeEach(func() {
+		limit = rules.LogLimit{PerSecond: 100, Burst: 150}
+	})
+
+	Describe("Apply", func() {
+		It("caps the log rules and counts their packets before them", func() {
+			limited := limit.Apply([]rules.IPTablesRule{
+				rules.NewNetOutRelatedEstablishedRule(),
+				rules.NewNetOutDefaultRejectLogRule("some-handle", 3),
+				rules.NewNetOutDefaultRejectRule(),
+			})
+
+			Expect(limited).To(Equal([]rules.IPTablesRule{
+				rules.NewNetOutRelatedEstablishedRule(),
+				{"-m", "comment", "--comment", "cf-log-events"},
+				{
+					"-m", "limit", "--limit", "3/s", "--limit-burst", "3",
+					"-m", "hashlimit", "--hashlimit-upto", "200/sec", "--hashlimit-burst", "300", "--hashlimit-name", "cflog-100-150",
+					"--jump", "LOG", "--log-prefix", `"DENY_some-handle "`,
+				},
+				rules.NewNetOutDefaultRejectRule(),
+			}))
+		})
+
+		It("keeps the conditions of the log rules other than their rate limits", func() {
+			limited := limit.Apply([]rules.IPTablesRule{
+				rules.NewNetOutDefaultUDPLogRule("some-handle", 5),
+				rules.NewNetOutDefaultNonUDPLogRule("some-handle"),
+			})
+
+			Expect(limited[0]).To(Equal(rules.IPTablesRule{"-p", "udp", "-m", "comment", "--comment", "cf-log-events"}))
+			Expect(limited[2]).To(Equal(rules.IPTablesRule{
+				"!", "-p", "udp", "-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
+				"-m", "comment", "--comment", "cf-log-events",
+			}))
+		})
+
+		It("uses the rate as the burst by default", func() {
+			limit.Burst = 0
+			limited := limit.Apply([]rules.IPTablesRule{rules.NewNetOutDefaultNonUDPLogRule("some-handle")})
+
+			Expect(limited[1]).To(ContainElements("200", "cflog-100-100"))
+		})
+
+		It("does not change the rules without a limit", func() {
+			ruleSpec := []rules.IPTablesRule{rules.NewNetOutDefaultRejectLogRule("some-handle", 3)}
+			Expect(rules.LogLimit{}.Apply(ruleSpec)).To(Equal(ruleSpec))
+		})
+	})
+
+	Describe("LogLimitedIPTables", func() {
+		var (
+			adapter *fakes.IPTablesAdapter
+			limited *rules.LogLimitedIPTables
+			logRule rules.IPTablesRule
+		)
+
+		BeforeEach(func() {
+			adapter = &fakes.IPTablesAdapter{}
+			limited = &rules.LogLimitedIPTables{IPTablesAdapter: adapter, Limit: limit}
+			logRule = rules.NewNetOutDefaultNonUDPLogRule("some-handle")
+		})
+
+		It("caps the log rules it writes", func() {
+			Expect(limited.BulkAppend("filter", "some-chain", logRule)).To(Succeed())
+			_, _, appended := adapter.BulkAppendArgsForCall(0)
+			Expect(appended).To(Equal(limit.Apply([]rules.IPTablesRule{logRule})))
+
+			Expect(limited.BulkInsert("filter", "some-chain", 1, logRule)).To(Succeed())
+			_, _, _, inserted := adapter.BulkInsertArgsForCall(0)
+			Expect(inserted).To(HaveLen(2))
+
+			Expect(limited.EnsureRules("filter", "some-chain", logRule)).To(Succeed())
+			_, _, ensured := adapter.EnsureRulesArgsForCall(0)
+			Expect(ensured).To(HaveLen(2))
+
+			Expect(limited.ReplaceChain("filter", "some-chain", logRule)).To(Succeed())
+			_, _, replaced := adapter.ReplaceChainArgsForCall(0)
+			Expect(replaced).To(HaveLen(2))
+		})
+
+		It("keeps capping the log rules written with a context", func() {
+			boundAdapter := &fakes.IPTablesAdapter{}
+			adapter.WithContextReturns(boundAdapter)
+
+			Expect(limited.WithContext(context.Background()).BulkAppend("filter", "some-chain", logRule)).To(Succeed())
+			Expect(adapter.BulkAppendCallCount()).To(Equal(0))
+			_, _, appended := boundAdapter.BulkAppendArgsForCall(0)
+			Expect(appended).To(HaveLen(2))
+		})
+
+		It("passes the other calls through", func() {
+			adapter.ListReturns([]string{"-N some-chain"}, nil)
+
+			Expect(limited.List("filter", "some-chain")).To(Equal([]string{"-N some-chain"}))
+		})
+	})
+})
+
+var _ = Describe("SuppressedLogs", func() {
+	listing := []string{
+		"-N netout--some-handle",
+		`-A netout--some-handle -m comment --comment cf-log-events -c 40 2400`,
+		`-A netout--some-handle -m limit --limit 3/sec --limit-burst 3 -m hashlimit --hashlimit-upto 200/sec --hashlimit-burst 300 --hashlimit-name cflog-100-150 -c 10 600 -j LOG --log-prefix "DENY_some-handle "`,
+		`-A netout--some-handle -c 40 2400 -j REJECT --reject-with icmp-port-unreachable`,
+		`-A netout--other-handle -m limit --limit 3/sec --limit-burst 3 -c 5 300 -j LOG --log-prefix "DENY_other-handle "`,
+	}
+
+	It("is the packets counted for the capped log rules that they did not log", func() {
+		parsed, err := rules.ParseRules(listing)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.SuppressedLogs(parsed)).To(Equal(uint64(30)))
+	})
+
+	It("is 0 when the log rules were counted for longer", func() {
+		parsed, err := rules.ParseRules([]string{listing[2]})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules.SuppressedLogs(parsed)).To(BeZero())
+	})
+
+	Describe("SuppressedLogsCounter", func() {
+		var (
+			iptablesRunner *fakes.CommandRunner
+			counter        *rules.SuppressedLogsCounter
+		)
+
+		BeforeEach(func() {
+			iptablesRunner = &fakes.CommandRunner{}
+			counter = &rules.SuppressedLogsCounter{IPTablesRunner: iptablesRunner}
+		})
+
+		It("reads the counters of the filter table", func() {
+			output := ""
+			for _, line := range listing {
+				output += line + "\n"
+			}
+			iptablesRunner.CombinedOutputReturns([]byte(output), nil)
+
+			Expect(counter.SuppressedLogs()).To(Equal(float64(30)))
+			Expect(iptablesRunner.CombinedOutputArgsForCall(0)).To(Equal(runner.Command{Args: []string{"-t", "filter", "-v", "-S"}}))
+		})
+
+		It("returns the error of iptables", func() {
+			iptablesRunner.CombinedOutputReturns([]byte("some output"), errors.New("banana"))
+
+			_, err := counter.SuppressedLogs()
+			Expect(err).To(MatchError("iptables -v -S: banana: some output"))
+		})
+	})
+})
@@ -0,0 +1,238 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/shlex"
+)
+
+// ParsedRule is a rule as iptables -S or iptables-save lists it, e.g.
+// -A netout--handle -p tcp -m iprange --dst-range 10.0.0.1-10.0.0.9 -m tcp --dport 443 -m comment --comment "some comment" -g netout--handle--log
+type ParsedRule struct {
+	// Table is only set for rules read from iptables-save output.
+	Table string
+	Chain string
+	// Matches are the matches of the rule in order. The options of iptables
+	// itself, e.g. -s or -p, are in a match without a module.
+	Matches []RuleMatch
+	// Target is the chain or target the rule jumps to, or goes to when Goto
+	// is set. It is empty for rules without a target.
+	Target        string
+	Goto          bool
+	TargetOptions []RuleOption
+	// Comment is the comment of the rule, without quotes.
+	Comment string
+	// Spec is the rule without the chain it is in.
+	Spec IPTablesRule
+	// Packets and Bytes are only set for rules listed with their counters,
+	// with iptables -v -S or iptables-save -c.
+	Packets uint64
+	Bytes   uint64
+}
+
+// RuleMatch is a match module, e.g. -m tcp, and its options.
+type RuleMatch struct {
+	Module  string
+	Options []RuleOption
+}
+
+// RuleOption is an option of a match or target, e.g. --dport 443 or
+// ! -s 10.0.0.0/8. Options can have several values, e.g.
+// --tcp-flags SYN,RST SYN, or none, e.g. --log-uid.
+type RuleOption struct {
+	Name    string
+	Values  []string
+	Negated bool
+}
+
+// Value is the first value of the option, or "" for options without one.
+func (o RuleOption) Value() string {
+	if len(o.Values) == 0 {
+		return ""
+	}
+	return o.Values[0]
+}
+
+// Option finds the option by name, in any match, e.g. "--dport" or "-s".
+// Options of iptables itself are looked up by the name iptables lists
+// them with, e.g. -s rather than --source.
+func (r ParsedRule) Option(name string) (RuleOption, bool) {
+	for _, match := range r.Matches {
+		for _, option := range match.Options {
+			if option.Name == name {
+				return option, true
+			}
+		}
+	}
+	return RuleOption{}, false
+}
+
+// HasMatch reports whether the rule uses the match module, e.g. "conntrack".
+func (r ParsedRule) HasMatch(module string) bool {
+	for _, match := range r.Matches {
+		if match.Module == module {
+			return true
+		}
+	}
+	return false
+}
+
+var builtinOptions = map[string]bool{
+	"-s": true, "--source": true,
+	"-d": true, "--destination": true,
+	"-p": true, "--protocol": true,
+	"-i": true, "--in-interface": true,
+	"-o": true, "--out-interface": true,
+	"-f": true, "--fragment": true,
+}
+
+// ParseRules parses the rules of iptables -S or iptables-save output and
+// skips the other lines, e.g. the policies, chains, tables and COMMIT. Rules
+// after a table line of iptables-save output get that table.
+func ParseRules(listing []string) ([]ParsedRule, error) {
+	table := ""
+	parsed := []ParsedRule{}
+	for _, line := range listing {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			table = strings.TrimPrefix(line, "*")
+			continue
+		}
+		if !strings.HasPrefix(line, "-A ") && !strings.HasPrefix(line, "[") {
+			continue
+		}
+		rule, err := ParseRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rule.Table = table
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// ParseRule parses a single -A line of iptables -S or iptables-save output.
+func ParseRule(line string) (ParsedRule, error) {
+	args, err := shlex.Split(line)
+	if err != nil {
+		return ParsedRule{}, fmt.Errorf("parse rule %q: %s", line, err)
+	}
+
+	rule := ParsedRule{}
+	// iptables-save -c puts the counters before the rule, as [packets:bytes]
+	if len(args) > 0 && strings.HasPrefix(args[0], "[") && strings.HasSuffix(args[0], "]") {
+		counters := strings.Split(strings.Trim(args[0], "[]"), ":")
+		if len(counters) != 2 {
+			return ParsedRule{}, fmt.Errorf("parse rule %q: invalid counters %q", line, args[0])
+		}
+		if rule.Packets, rule.Bytes, err = parseCounters(counters[0], counters[1]); err != nil {
+			return ParsedRule{}, fmt.Errorf("parse rule %q: %s", line, err)
+		}
+		args = args[1:]
+	}
+	if len(args) < 2 || (args[0] != "-A" && args[0] != "--append") {
+		return ParsedRule{}, fmt.Errorf("parse rule %q: not an appended rule", line)
+	}
+
+	rule.Chain = args[1]
+	rule.Spec = IPTablesRule(args[2:])
+	builtin := -1
+	current := -1
+	inTarget := false
+	negated := false
+	for i := 2; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "!":
+			negated = true
+			continue
+		case arg == "-m" || arg == "--match":
+			if i+1 == len(args) {
+				return ParsedRule{}, fmt.Errorf("parse rule %q: %s without a module", line, arg)
+			}
+			i++
+			rule.Matches = append(rule.Matches, RuleMatch{Module: args[i]})
+			current = len(rule.Matches) - 1
+			inTarget = false
+			continue
+		case arg == "-c" || arg == "--set-counters":
+			// iptables -v -S lists the counters of a rule before its target
+			if i+2 >= len(args) {
+				return ParsedRule{}, fmt.Errorf("parse rule %q: %s without packets and bytes", line, arg)
+			}
+			if rule.Packets, rule.Bytes, err = parseCounters(args[i+1], args[i+2]); err != nil {
+				return ParsedRule{}, fmt.Errorf("parse rule %q: %s", line, err)
+			}
+			i += 2
+			continue
+		case arg == "-j" || arg == "--jump" || arg == "-g" || arg == "--goto":
+			if i+1 == len(args) {
+				return ParsedRule{}, fmt.Errorf("parse rule %q: %s without a target", line, arg)
+			}
+			i++
+			rule.Target = args[i]
+			rule.Goto = arg == "-g" || arg == "--goto"
+			inTarget = true
+			continue
+		case !isOptionName(arg):
+			return ParsedRule{}, fmt.Errorf("parse rule %q: unexpected value %q", line, arg)
+		}
+
+		option := RuleOption{Name: arg, Values: []string{}, Negated: negated}
+		negated = false
+		for i+1 < len(args) && !isOptionName(args[i+1]) && args[i+1] != "!" {
+			i++
+			option.Values = append(option.Values, args[i])
+		}
+
+		switch {
+		case builtinOptions[arg] && !inTarget:
+			if builtin == -1 {
+				rule.Matches = append(rule.Matches, RuleMatch{})
+				builtin = len(rule.Matches) - 1
+			}
+			rule.Matches[builtin].Options = append(rule.Matches[builtin].Options, option)
+		case inTarget:
+			rule.TargetOptions = append(rule.TargetOptions, option)
+		case current == -1:
+			// iptables loads the match of the protocol for its options, e.g.
+			// -p tcp --dport 443, so rules written that way list as
+			// -p tcp -m tcp --dport 443
+			protocol, ok := rule.Option("-p")
+			if !ok {
+				protocol, ok = rule.Option("--protocol")
+			}
+			if !ok {
+				return ParsedRule{}, fmt.Errorf("parse rule %q: option %s outside of a match", line, arg)
+			}
+			rule.Matches = append(rule.Matches, RuleMatch{Module: protocol.Value(), Options: []RuleOption{option}})
+			current = len(rule.Matches) - 1
+		default:
+			rule.Matches[current].Options = append(rule.Matches[current].Options, option)
+			if arg == "--comment" && rule.Matches[current].Module == "comment" {
+				rule.Comment = option.Value()
+			}
+		}
+	}
+	return rule, nil
+}
+
+func parseCounters(packets, bytes string) (uint64, uint64, error) {
+	p, err := strconv.ParseUint(packets, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid packet counter %q", packets)
+	}
+	b, err := strconv.ParseUint(bytes, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid byte counter %q", bytes)
+	}
+	return p, b, nil
+}
+
+// isOptionName tells options from their values, which can start with a dash
+// too when they are negative numbers, e.g. --icmp-type -1.
+func isOptionName(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && (arg[1] < '0' || arg[1] > '9')
+}
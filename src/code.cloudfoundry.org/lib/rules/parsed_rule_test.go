@@ -0,0 +1,156 @@
+package rules_test
+
+import (
+	"code.cloudfoundry.org/lib/rules"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseRule", func() {
+	It("parses the matches, target and comment of a rule", func() {
+		rule, err := rules.ParseRule(`-A netout--handle -s 10.255.0.2/32 ! -d 10.0.0.0/8 -p tcp -m iprange --dst-range 10.0.0.1-10.0.0.9 -m tcp --dport 443 -m comment --comment "some comment" -g netout--handle--log`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rule.Chain).To(Equal("netout--handle"))
+		Expect(rule.Matches).To(Equal([]rules.RuleMatch{
+			{Options: []rules.RuleOption{
+				{Name: "-s", Values: []string{"10.255.0.2/32"}},
+				{Name: "-d", Values: []string{"10.0.0.0/8"}, Negated: true},
+				{Name: "-p", Values: []string{"tcp"}},
+			}},
+			{Module: "iprange", Options: []rules.RuleOption{{Name: "--dst-range", Values: []string{"10.0.0.1-10.0.0.9"}}}},
+			{Module: "tcp", Options: []rules.RuleOption{{Name: "--dport", Values: []string{"443"}}}},
+			{Module: "comment", Options: []rules.RuleOption{{Name: "--comment", Values: []string{"some comment"}}}},
+		}))
+		Expect(rule.Target).To(Equal("netout--handle--log"))
+		Expect(rule.Goto).To(BeTrue())
+		Expect(rule.Comment).To(Equal("some comment"))
+		Expect(rule.Spec[0]).To(Equal("-s"))
+	})
+
+	It("parses the options of the target", func() {
+		rule, err := rules.ParseRule(`-A some-chain -p tcp -m conntrack --ctstate INVALID,NEW -j LOG --log-prefix "DENY_some-handle " --log-uid`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rule.Target).To(Equal("LOG"))
+		Expect(rule.Goto).To(BeFalse())
+		Expect(rule.TargetOptions).To(Equal([]rules.RuleOption{
+			{Name: "--log-prefix", Values: []string{"DENY_some-handle "}},
+			{Name: "--log-uid", Values: []string{}},
+		}))
+		Expect(rule.HasMatch("conntrack")).To(BeTrue())
+		ctstate, ok := rule.Option("--ctstate")
+		Expect(ok).To(BeTrue())
+		Expect(ctstate.Value()).To(Equal("INVALID,NEW"))
+	})
+
+	It("parses negated match options and options with several values", func() {
+		rule, err := rules.ParseRule(`-A some-chain -p tcp -m set ! --match-set some-set dst -m tcp --tcp-flags SYN,RST SYN -j REJECT --reject-with icmp-port-unreachable`)
+		Expect(err).NotTo(HaveOccurred())
+
+		matchSet, ok := rule.Option("--match-set")
+		Expect(ok).To(BeTrue())
+		Expect(matchSet).To(Equal(rules.RuleOption{Name: "--match-set", Values: []string{"some-set", "dst"}, Negated: true}))
+		flags, ok := rule.Option("--tcp-flags")
+		Expect(ok).To(BeTrue())
+		Expect(flags.Values).To(Equal([]string{"SYN,RST", "SYN"}))
+	})
+
+	It("keeps negative numbers as values", func() {
+		rule, err := rules.ParseRule(`-A some-chain -p icmp -m icmp --icmp-type -1 -j ACCEPT`)
+		Expect(err).NotTo(HaveOccurred())
+
+		icmpType, ok := rule.Option("--icmp-type")
+		Expect(ok).To(BeTrue())
+		Expect(icmpType.Value()).To(Equal("-1"))
+	})
+
+	It("puts the options of the protocol in its match", func() {
+		rule, err := rules.ParseRule(`-A some-chain -d 10.255.0.2 -p tcp --dport 8080:8080 -m mark --mark 0x0002 --jump ACCEPT -m comment --comment src:some-app`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rule.Matches[1]).To(Equal(rules.RuleMatch{Module: "tcp", Options: []rules.RuleOption{{Name: "--dport", Values: []string{"8080:8080"}}}}))
+		Expect(rule.Matches[2].Module).To(Equal("mark"))
+		Expect(rule.Target).To(Equal("ACCEPT"))
+		Expect(rule.TargetOptions).To(BeEmpty())
+		Expect(rule.Comment).To(Equal("src:some-app"))
+	})
+
+	It("parses rules without a target", func() {
+		rule, err := rules.ParseRule(`-A some-chain -s 10.0.0.0/8`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rule.Target).To(BeEmpty())
+
+		_, ok := rule.Option("--dport")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("parses the counters of iptables -v -S output", func() {
+		rule, err := rules.ParseRule(`-A some-chain -s 10.0.0.0/8 -c 12 720 -j ACCEPT`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rule.Packets).To(Equal(uint64(12)))
+		Expect(rule.Bytes).To(Equal(uint64(720)))
+		Expect(rule.Target).To(Equal("ACCEPT"))
+	})
+
+	It("parses the counters of iptables-save -c output", func() {
+		rule, err := rules.ParseRule(`[12:720] -A some-chain -s 10.0.0.0/8 -j ACCEPT`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rule.Chain).To(Equal("some-chain"))
+		Expect(rule.Packets).To(Equal(uint64(12)))
+		Expect(rule.Bytes).To(Equal(uint64(720)))
+	})
+
+	DescribeTable("invalid rules",
+		func(line, message string) {
+			_, err := rules.ParseRule(line)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("not appended", `-N some-chain`, "not an appended rule"),
+		Entry("unterminated quote", `-A some-chain -m comment --comment "some`, "parse rule"),
+		Entry("match without a module", `-A some-chain -m`, "-m without a module"),
+		Entry("jump without a target", `-A some-chain -j`, "-j without a target"),
+		Entry("value without an option", `-A some-chain somefilter -j ACCEPT`, `unexpected value "somefilter"`),
+		Entry("option outside of a match", `-A some-chain --dport 443 -j ACCEPT`, "option --dport outside of a match"),
+		Entry("invalid counters", `-A some-chain -c 12 -j ACCEPT`, `invalid byte counter "-j"`),
+		Entry("invalid saved counters", `[12] -A some-chain -j ACCEPT`, `invalid counters "[12]"`),
+	)
+})
+
+var _ = Describe("ParseRules", func() {
+	It("parses the rules of iptables -S output", func() {
+		parsed, err := rules.ParseRules([]string{
+			"-P FORWARD ACCEPT",
+			"-N some-chain",
+			"-A some-chain -j ACCEPT",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(HaveLen(1))
+		Expect(parsed[0].Table).To(BeEmpty())
+		Expect(parsed[0].Chain).To(Equal("some-chain"))
+	})
+
+	It("parses the rules of the tables of iptables-save output", func() {
+		parsed, err := rules.ParseRules([]string{
+			"# Generated by iptables-save",
+			"*filter",
+			":some-chain - [0:0]",
+			"-A some-chain -j ACCEPT",
+			"COMMIT",
+			"*nat",
+			"-A POSTROUTING -s 10.255.0.0/16 -j MASQUERADE",
+			"COMMIT",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(HaveLen(2))
+		Expect(parsed[0].Table).To(Equal("filter"))
+		Expect(parsed[1].Table).To(Equal("nat"))
+		Expect(parsed[1].Target).To(Equal("MASQUERADE"))
+	})
+
+	It("returns the error of a rule that cannot be parsed", func() {
+		_, err := rules.ParseRules([]string{"-A some-chain somefilter"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -24,21 +24,36 @@ func AppendComment(rule IPTablesRule, comment string) IPTablesRule {
 }
 
 func NewPortForwardingRule(hostPort, containerPort int, hostIP, containerIP string) IPTablesRule {
+	return NewPortRangeForwardingRule("tcp", hostPort, hostPort, containerPort, hostIP, containerIP)
+}
+
+// NewPortRangeForwardingRule forwards the host ports from hostPort to
+// hostPortEnd to as many container ports from containerPort on. Each host
+// port keeps its offset in the range, with the shifted port mapping of DNAT.
+func NewPortRangeForwardingRule(protocol string, hostPort, hostPortEnd, containerPort int, hostIP, containerIP string) IPTablesRule {
+	destination := fmt.Sprintf("%s:%d", containerIP, containerPort)
+	if hostPortEnd > hostPort {
+		destination = fmt.Sprintf("%s:%d-%d/%d", containerIP, containerPort, containerPort+hostPortEnd-hostPort, hostPort)
+	}
 	return IPTablesRule{
-		"-d", hostIP, "-p", "tcp",
-		"-m", "tcp", "--dport", fmt.Sprintf("%d", hostPort),
+		"-d", hostIP, "-p", protocol,
+		"-m", protocol, "--dport", portRange(hostPort, hostPortEnd),
 		"--jump", "DNAT",
-		"--to-destination", fmt.Sprintf("%s:%d", containerIP, containerPort),
+		"--to-destination", destination,
 	}
 }
 
 func NewIngressMarkRules(hostInterfaceNames []string, hostPort int, hostIP, tag string) []IPTablesRule {
+	return NewPortRangeIngressMarkRules(hostInterfaceNames, "tcp", hostPort, hostPort, hostIP, tag)
+}
+
+func NewPortRangeIngressMarkRules(hostInterfaceNames []string, protocol string, hostPort, hostPortEnd int, hostIP, tag string) []IPTablesRule {
 	jumpConditions := make([]IPTablesRule, len(hostInterfaceNames))
 
 	for i, hostInterfaceName := range hostInterfaceNames {
 		jumpConditions[i] = IPTablesRule{
-			"-i", hostInterfaceName, "-d", hostIP, "-p", "tcp",
-			"-m", "tcp", "--dport", fmt.Sprintf("%d", hostPort),
+			"-i", hostInterfaceName, "-d", hostIP, "-p", protocol,
+			"-m", protocol, "--dport", portRange(hostPort, hostPortEnd),
 			"--jump", "MARK",
 			"--set-mark", fmt.Sprintf("0x%s", tag),
 		}
@@ -47,6 +62,41 @@ func NewIngressMarkRules(hostInterfaceNames []string, hostPort int, hostIP, tag
 	return jumpConditions
 }
 
+// ClientIPConnMark marks the connections to port mappings that preserve the
+// client IP, in the conntrack mark, which no other rule uses.
+const ClientIPConnMark = "0x1/0x1"
+
+// NewClientIPConnMarkRule marks the connections to the host ports from
+// hostPort to hostPortEnd with ClientIPConnMark.
+func NewClientIPConnMarkRule(protocol string, hostPort, hostPortEnd int, hostIP string) IPTablesRule {
+	return IPTablesRule{
+		"-d", hostIP, "-p", protocol,
+		"-m", protocol, "--dport", portRange(hostPort, hostPortEnd),
+		"--jump", "CONNMARK",
+		"--set-xmark", ClientIPConnMark,
+	}
+}
+
+// NewClientIPReplyRule clears the mark of the replies the container sends on
+// connections marked with ClientIPConnMark, so that they are routed back to
+// the client by the cell, e.g. instead of to an egress gateway that would
+// SNAT them.
+func NewClientIPReplyRule(containerIP string) IPTablesRule {
+	return IPTablesRule{
+		"-s", containerIP,
+		"-m", "connmark", "--mark", ClientIPConnMark,
+		"--jump", "MARK",
+		"--set-xmark", "0x0/0xffffffff",
+	}
+}
+
+func portRange(start, end int) string {
+	if end > start {
+		return fmt.Sprintf("%d:%d", start, end)
+	}
+	return fmt.Sprintf("%d", start)
+}
+
 func NewNetOutJumpConditions(hostInterfaceNames []string, hostIP, forwardChainName string) []IPTablesRule {
 	jumpConditions := make([]IPTablesRule, len(hostInterfaceNames))
 
@@ -81,7 +131,13 @@ func NewMarkAllowRule(destinationIP, protocol string, startPort, endPort int, ta
 	}, fmt.Sprintf("src:%s_dst:%s", sourceAppGUID, destinationAppGUID))
 }
 
-func NewMarkAllowLogRule(destinationIP, protocol string, startPort, endPort int, tag string, destinationAppGUID string, acceptedUDPLogsPerSec int) IPTablesRule {
+// appGUIDPrefixLength is the length of the first group of a GUID. Log
+// prefixes are limited to 29 characters, so c2c log rules name the source and
+// destination apps by the first group of their GUIDs.
+const appGUIDPrefixLength = 8
+
+func NewMarkAllowLogRule(destinationIP, protocol string, startPort, endPort int, tag string, sourceAppGUID, destinationAppGUID string, acceptedUDPLogsPerSec int) IPTablesRule {
+	logPrefix := trimAndPad(fmt.Sprintf("OK_%s_%s_%s", tag, shortenAppGUID(sourceAppGUID), shortenAppGUID(destinationAppGUID)))
 	if protocol != "udp" {
 		return IPTablesRule{
 			"-d", destinationIP,
@@ -90,7 +146,7 @@ func NewMarkAllowLogRule(destinationIP, protocol string, startPort, endPort int,
 			"-m", "mark", "--mark", fmt.Sprintf("0x%s", tag),
 			"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
 			"--jump", "LOG", "--log-prefix",
-			trimAndPad(fmt.Sprintf("OK_%s_%s", tag, destinationAppGUID))}
+			logPrefix}
 	} else {
 		return IPTablesRule{
 			"-d", destinationIP,
@@ -101,7 +157,7 @@ func NewMarkAllowLogRule(destinationIP, protocol string, startPort, endPort int,
 			"--limit", fmt.Sprintf("%d/s", acceptedUDPLogsPerSec),
 			"--limit-burst", strconv.Itoa(acceptedUDPLogsPerSec),
 			"--jump", "LOG", "--log-prefix",
-			trimAndPad(fmt.Sprintf("OK_%s_%s", tag, destinationAppGUID))}
+			logPrefix}
 	}
 }
 
@@ -240,6 +296,13 @@ func NewAcceptRule() IPTablesRule {
 	}
 }
 
+// NewGotoRule hands packets matching the conditions to the chain with -g, so
+// that they do not return to the chain the rule is in.
+func NewGotoRule(conditions IPTablesRule, chain string) IPTablesRule {
+	rule := append(IPTablesRule{}, conditions...)
+	return append(rule, "-g", chain)
+}
+
 func NewAcceptEverythingRule(ipRange string) IPTablesRule {
 	return IPTablesRule{
 		"-s", ipRange, "-d", ipRange, "-j", "ACCEPT",
@@ -269,6 +332,15 @@ func NewInputRejectRule(destinationIP string) IPTablesRule {
 	}
 }
 
+func NewInputTCPResetRule(destinationIP string) IPTablesRule {
+	return IPTablesRule{
+		"-d", destinationIP,
+		"-p", "tcp",
+		"--jump", "REJECT",
+		"--reject-with", "tcp-reset",
+	}
+}
+
 func NewInputDefaultRejectRule() IPTablesRule {
 	return IPTablesRule{
 		"--jump", "REJECT",
@@ -290,6 +362,98 @@ func NewNetOutRelatedEstablishedRule() IPTablesRule {
 	}
 }
 
+// NewRelatedEstablishedReplyRule accepts the packets of established
+// connections that travel in the reply direction, i.e. the replies to
+// connections made from the other side.
+func NewRelatedEstablishedReplyRule() IPTablesRule {
+	return IPTablesRule{
+		"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "--ctdir", "REPLY",
+		"--jump", "ACCEPT",
+	}
+}
+
+// NewNetOutRelatedEstablishedMarkRule accepts the packets of established
+// connections whose conntrack mark is mark.
+func NewNetOutRelatedEstablishedMarkRule(mark string) IPTablesRule {
+	return IPTablesRule{
+		"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED",
+		"-m", "connmark", "--mark", mark,
+		"--jump", "ACCEPT",
+	}
+}
+
+// NewNetOutRelatedEstablishedInterfaceRule accepts the packets of
+// established connections that leave through the interface.
+func NewNetOutRelatedEstablishedInterfaceRule(interfaceName string) IPTablesRule {
+	return IPTablesRule{
+		"-o", interfaceName,
+		"-m", "state", "--state", "RELATED,ESTABLISHED",
+		"--jump", "ACCEPT",
+	}
+}
+
+// NewNetOutConnMarkRule marks new connections with mark in their conntrack
+// mark.
+func NewNetOutConnMarkRule(mark string) IPTablesRule {
+	return IPTablesRule{
+		"-m", "conntrack", "--ctstate", "NEW",
+		"--jump", "CONNMARK",
+		"--set-xmark", mark,
+	}
+}
+
+// The conntrack marks the SNI verdict daemon gives the TLS connections of the
+// containers it inspects: SNIAllowedConnMark when their server name is
+// allowed, SNIDeniedConnMark otherwise. Connections without either are still
+// queued to the daemon.
+const (
+	SNIConnMarkMask    = 0x6
+	SNIAllowedConnMark = 0x2
+	SNIDeniedConnMark  = 0x6
+)
+
+// NewNetOutSNIQueueRule queues the packets of the established TCP connections
+// to ports that the SNI verdict daemon has not decided yet to queueNum. With
+// bypass, the packets are accepted while no daemon listens on the queue.
+func NewNetOutSNIQueueRule(ports string, queueNum int, bypass bool) IPTablesRule {
+	return sniQueueRule(IPTablesRule{"-p", "tcp", "-m", "multiport", "--dports", ports}, queueNum, bypass)
+}
+
+// NewNetOutSNIDestinationQueueRule is NewNetOutSNIQueueRule for the
+// connections to the ports of the addresses from startIP to endIP only.
+func NewNetOutSNIDestinationQueueRule(startIP, endIP, ports string, queueNum int, bypass bool) IPTablesRule {
+	return sniQueueRule(IPTablesRule{
+		"-m", "iprange",
+		"--dst-range", fmt.Sprintf("%s-%s", startIP, endIP),
+		"-p", "tcp",
+		"-m", "multiport", "--dports", ports,
+	}, queueNum, bypass)
+}
+
+func sniQueueRule(match IPTablesRule, queueNum int, bypass bool) IPTablesRule {
+	rule := append(match, IPTablesRule{
+		"-m", "conntrack", "--ctstate", "ESTABLISHED", "--ctdir", "ORIGINAL",
+		"-m", "connmark", "--mark", fmt.Sprintf("0x0/0x%x", SNIConnMarkMask),
+		"--jump", "NFQUEUE",
+		"--queue-num", strconv.Itoa(queueNum),
+	}...)
+	if bypass {
+		rule = append(rule, "--queue-bypass")
+	}
+	return rule
+}
+
+// NewNetOutSNIDeniedRule resets the TCP connections that the SNI verdict
+// daemon denied.
+func NewNetOutSNIDeniedRule() IPTablesRule {
+	return IPTablesRule{
+		"-p", "tcp",
+		"-m", "connmark", "--mark", fmt.Sprintf("0x%x/0x%x", SNIDeniedConnMark, SNIConnMarkMask),
+		"--jump", "REJECT",
+		"--reject-with", "tcp-reset",
+	}
+}
+
 func NewNetOutConnRateLimitRule(rate, burst, containerHandle, expiryPeriod, rateLimitLogChainName string) IPTablesRule {
 	return IPTablesRule{
 		"-p", "tcp",
@@ -308,6 +472,29 @@ func NewOverlayTagAcceptRule(containerIP, tag string) IPTablesRule {
 	}
 }
 
+// NewHealthCheckAcceptRule accepts the probes of a health check source to an
+// app port of a container.
+func NewHealthCheckAcceptRule(source, containerIP string, port int) IPTablesRule {
+	return AppendComment(IPTablesRule{
+		"-s", source,
+		"-d", containerIP,
+		"-p", "tcp",
+		"-m", "tcp", "--dport", strconv.Itoa(port),
+		"--jump", "ACCEPT",
+	}, "health-check")
+}
+
+// NewICMPEchoAcceptRule accepts pings to a container, whose replies are
+// accepted as related by its overlay chain.
+func NewICMPEchoAcceptRule(containerIP string) IPTablesRule {
+	return IPTablesRule{
+		"-d", containerIP,
+		"-p", "icmp",
+		"-m", "icmp", "--icmp-type", "echo-request",
+		"--jump", "ACCEPT",
+	}
+}
+
 func NewOverlayDefaultRejectRule(containerIP string) IPTablesRule {
 	return IPTablesRule{
 		"-d", containerIP,
@@ -343,6 +530,16 @@ func NewOverlayRelatedEstablishedRule(containerIP string) IPTablesRule {
 	}
 }
 
+// NewOverlayRelatedEstablishedReplyRule accepts the packets to a container
+// of the connections it made.
+func NewOverlayRelatedEstablishedReplyRule(containerIP string) IPTablesRule {
+	return IPTablesRule{
+		"-d", containerIP,
+		"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "--ctdir", "REPLY",
+		"--jump", "ACCEPT",
+	}
+}
+
 func NewNetOutDefaultRejectLogRule(containerHandle string, deniedLogsPerSec int) IPTablesRule {
 	return newNetOutRejectLogRule(containerHandle, "DENY", deniedLogsPerSec)
 }
@@ -351,6 +548,14 @@ func NewNetOutConnRateLimitRejectLogRule(containerHandle string, deniedLogsPerSe
 	return newNetOutRejectLogRule(containerHandle, "DENY_ORL", deniedLogsPerSec)
 }
 
+func NewNetOutDefaultRejectLogPerDestinationRule(containerHandle string, deniedLogsPerSec int) IPTablesRule {
+	return newNetOutRejectLogPerDestinationRule(containerHandle, "DENY", deniedLogsPerSec)
+}
+
+func NewNetOutConnRateLimitRejectLogPerDestinationRule(containerHandle string, deniedLogsPerSec int) IPTablesRule {
+	return newNetOutRejectLogPerDestinationRule(containerHandle, "DENY_ORL", deniedLogsPerSec)
+}
+
 func NewNetOutDefaultRejectRule() IPTablesRule {
 	return IPTablesRule{
 		"--jump", "REJECT",
@@ -358,6 +563,16 @@ func NewNetOutDefaultRejectRule() IPTablesRule {
 	}
 }
 
+// NewNetOutDefaultTCPResetRule rejects TCP with a RST so that clients fail
+// fast instead of waiting for the handshake to time out.
+func NewNetOutDefaultTCPResetRule() IPTablesRule {
+	return IPTablesRule{
+		"-p", "tcp",
+		"--jump", "REJECT",
+		"--reject-with", "tcp-reset",
+	}
+}
+
 func NewOverlayAccessMarkRule(tag string) IPTablesRule {
 	return IPTablesRule{
 		"-o", "silk-vtep",
@@ -366,6 +581,39 @@ func NewOverlayAccessMarkRule(tag string) IPTablesRule {
 	}
 }
 
+// NewDSCPClearRule resets the DSCP value of the packets that carry it, so
+// that only the packets marked after the rule carry it.
+func NewDSCPClearRule(dscp int) IPTablesRule {
+	return IPTablesRule{
+		"-m", "dscp", "--dscp", fmt.Sprintf("%d", dscp),
+		"-j", "DSCP",
+		"--set-dscp", "0",
+	}
+}
+
+// NewUIDDSCPMarkRule marks the packets a user sends with a DSCP value. The
+// owner match only works for packets sent from the network namespace the
+// rule is in.
+func NewUIDDSCPMarkRule(uid, dscp int) IPTablesRule {
+	return IPTablesRule{
+		"-m", "owner", "--uid-owner", fmt.Sprintf("%d", uid),
+		"-j", "DSCP",
+		"--set-dscp", fmt.Sprintf("%d", dscp),
+	}
+}
+
+// NewDSCPMatchRule limits a rule to the packets marked with a DSCP value.
+func NewDSCPMatchRule(dscp int, rule IPTablesRule) IPTablesRule {
+	return append(IPTablesRule{"-m", "dscp", "--dscp", fmt.Sprintf("%d", dscp)}, rule...)
+}
+
+func shortenAppGUID(appGUID string) string {
+	if len(appGUID) > appGUIDPrefixLength {
+		return appGUID[:appGUIDPrefixLength]
+	}
+	return appGUID
+}
+
 func trimAndPad(name string) string {
 	if len(name) > 28 {
 		name = name[:28]
@@ -381,3 +629,16 @@ func newNetOutRejectLogRule(containerHandle, prefix string, deniedLogsPerSec int
 		"--log-prefix", trimAndPad(fmt.Sprintf("%s_%s", prefix, containerHandle)),
 	}
 }
+
+// newNetOutRejectLogPerDestinationRule limits logging per destination IP, so
+// that a single noisy destination does not suppress logs for the others.
+func newNetOutRejectLogPerDestinationRule(containerHandle, prefix string, deniedLogsPerSec int) IPTablesRule {
+	return IPTablesRule{
+		"-m", "hashlimit", "--hashlimit-upto", fmt.Sprintf("%d/sec", deniedLogsPerSec),
+		"--hashlimit-burst", strconv.Itoa(deniedLogsPerSec),
+		"--hashlimit-mode", "dstip",
+		"--hashlimit-name", fmt.Sprintf("%s-%s", strings.ToLower(prefix), containerHandle),
+		"--jump", "LOG",
+		"--log-prefix", trimAndPad(fmt.Sprintf("%s_%s", prefix, containerHandle)),
+	}
+}
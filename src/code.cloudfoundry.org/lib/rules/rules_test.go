@@ -73,34 +73,39 @@ var _ = Describe("Rules", func() {
 	})
 
 	Describe("NewMarkAllowLogRule", func() {
+		It("names the source and destination apps by the first group of their GUIDs", func() {
+			rule := rules.NewMarkAllowLogRule("10.255.0.1", "tcp", 80, 80, "0001", "e9e8959f-3828-4136-8a1b-3f8c", "bc6f229d-5e4a-4c41-a63f-e879", -1)
+			Expect(rule).To(ContainElement(`"OK_0001_e9e8959f_bc6f229d "`))
+		})
+
 		Context("when the log prefix is greater than 28 characters", func() {
 			Context("when the protocol is not udp", func() {
 				It("shortens the log-prefix to 28 characters and adds a space", func() {
-					rule := rules.NewMarkAllowLogRule("10.255.0.1", "tcp", 80, 80, "0", "some-very-very-very-long-app-guid", -1)
+					rule := rules.NewMarkAllowLogRule("10.255.0.1", "tcp", 80, 80, "000000000000", "some-very-long-source-app-guid", "some-very-very-very-long-app-guid", -1)
 					Expect(rule).To(Equal(rules.IPTablesRule{
 						"-d", "10.255.0.1",
 						"-p", "tcp",
 						"--dport", "80:80",
-						"-m", "mark", "--mark", "0x0",
+						"-m", "mark", "--mark", "0x000000000000",
 						"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
 						"--jump", "LOG", "--log-prefix",
-						`"OK_0_some-very-very-very-lon "`,
+						`"OK_000000000000_some-ver_som "`,
 					}))
 				})
 			})
 			Context("when the protocol is udp", func() {
 				It("does not use conntrack", func() {
-					rule := rules.NewMarkAllowLogRule("10.255.0.1", "udp", 80, 80, "0", "some-very-very-very-long-app-guid", 4)
+					rule := rules.NewMarkAllowLogRule("10.255.0.1", "udp", 80, 80, "000000000000", "some-very-long-source-app-guid", "some-very-very-very-long-app-guid", 4)
 					Expect(rule).To(Equal(rules.IPTablesRule{
 						"-d", "10.255.0.1",
 						"-p", "udp",
 						"--dport", "80:80",
-						"-m", "mark", "--mark", "0x0",
+						"-m", "mark", "--mark", "0x000000000000",
 						"-m", "limit",
 						"--limit", "4/s",
 						"--limit-burst", "4",
 						"--jump", "LOG", "--log-prefix",
-						`"OK_0_some-very-very-very-lon "`,
+						`"OK_000000000000_some-ver_som "`,
 					}))
 				})
 
@@ -173,6 +178,54 @@ var _ = Describe("Rules", func() {
 		})
 	})
 
+	Describe("NewNetOutDefaultRejectLogPerDestinationRule", func() {
+		It("limits the logs per destination ip", func() {
+			rule := rules.NewNetOutDefaultRejectLogPerDestinationRule("some-very-very-very-long-app-guid", 3)
+			Expect(rule).To(Equal(rules.IPTablesRule{
+				"-m", "hashlimit", "--hashlimit-upto", "3/sec", "--hashlimit-burst", "3",
+				"--hashlimit-mode", "dstip", "--hashlimit-name", "deny-some-very-very-very-long-app-guid",
+				"--jump", "LOG", "--log-prefix", `"DENY_some-very-very-very-lon "`,
+			}))
+		})
+	})
+
+	Describe("NewNetOutConnRateLimitRejectLogPerDestinationRule", func() {
+		It("limits the logs per destination ip", func() {
+			rule := rules.NewNetOutConnRateLimitRejectLogPerDestinationRule("some-very-very-very-long-app-guid", 5)
+			Expect(rule).To(Equal(rules.IPTablesRule{
+				"-m", "hashlimit", "--hashlimit-upto", "5/sec", "--hashlimit-burst", "5",
+				"--hashlimit-mode", "dstip", "--hashlimit-name", "deny_orl-some-very-very-very-long-app-guid",
+				"--jump", "LOG", "--log-prefix", `"DENY_ORL_some-very-very-very "`,
+			}))
+		})
+	})
+
+	Describe("NewNetOutDefaultTCPResetRule", func() {
+		It("rejects tcp with a reset", func() {
+			Expect(rules.NewNetOutDefaultTCPResetRule()).To(Equal(rules.IPTablesRule{
+				"-p", "tcp", "--jump", "REJECT", "--reject-with", "tcp-reset",
+			}))
+		})
+	})
+
+	Describe("NewInputTCPResetRule", func() {
+		It("rejects tcp to the destination with a reset", func() {
+			Expect(rules.NewInputTCPResetRule("10.0.0.0/8")).To(Equal(rules.IPTablesRule{
+				"-d", "10.0.0.0/8", "-p", "tcp", "--jump", "REJECT", "--reject-with", "tcp-reset",
+			}))
+		})
+	})
+
+	Describe("NewGotoRule", func() {
+		It("goes to the chain when the conditions match", func() {
+			conditions := rules.IPTablesRule{"-d", "10.0.0.0/8"}
+			Expect(rules.NewGotoRule(conditions, "some-chain")).To(Equal(rules.IPTablesRule{
+				"-d", "10.0.0.0/8", "-g", "some-chain",
+			}))
+			Expect(conditions).To(Equal(rules.IPTablesRule{"-d", "10.0.0.0/8"}))
+		})
+	})
+
 	Describe("NewIngressMarkRules", func() {
 		It("creates a jump rule when given one interface", func() {
 			jumpRule := rules.NewIngressMarkRules([]string{"eth0"}, 2000, "2.3.4.5", "1")
@@ -200,6 +253,140 @@ var _ = Describe("Rules", func() {
 		})
 	})
 
+	Describe("NewPortRangeForwardingRule", func() {
+		It("forwards a single port", func() {
+			Expect(rules.NewPortRangeForwardingRule("udp", 2000, 2000, 3000, "2.3.4.5", "10.255.0.2")).To(Equal(rules.IPTablesRule{
+				"-d", "2.3.4.5", "-p", "udp",
+				"-m", "udp", "--dport", "2000",
+				"--jump", "DNAT",
+				"--to-destination", "10.255.0.2:3000",
+			}))
+		})
+
+		It("forwards each port of a range to the container port with the same offset", func() {
+			Expect(rules.NewPortRangeForwardingRule("udp", 2000, 2010, 3000, "2.3.4.5", "10.255.0.2")).To(Equal(rules.IPTablesRule{
+				"-d", "2.3.4.5", "-p", "udp",
+				"-m", "udp", "--dport", "2000:2010",
+				"--jump", "DNAT",
+				"--to-destination", "10.255.0.2:3000-3010/2000",
+			}))
+		})
+	})
+
+	Describe("NewPortRangeIngressMarkRules", func() {
+		It("marks the packets to the range for each interface", func() {
+			Expect(rules.NewPortRangeIngressMarkRules([]string{"eth0", "gandalf"}, "udp", 2000, 2010, "2.3.4.5", "1")).To(Equal([]rules.IPTablesRule{{
+				"-i", "eth0", "-d", "2.3.4.5", "-p", "udp",
+				"-m", "udp", "--dport", "2000:2010",
+				"--jump", "MARK",
+				"--set-mark", "0x1",
+			}, {
+				"-i", "gandalf", "-d", "2.3.4.5", "-p", "udp",
+				"-m", "udp", "--dport", "2000:2010",
+				"--jump", "MARK",
+				"--set-mark", "0x1",
+			}}))
+		})
+	})
+
+	Describe("NewClientIPConnMarkRule", func() {
+		It("marks the connections to the range", func() {
+			Expect(rules.NewClientIPConnMarkRule("tcp", 2000, 2010, "2.3.4.5")).To(Equal(rules.IPTablesRule{
+				"-d", "2.3.4.5", "-p", "tcp",
+				"-m", "tcp", "--dport", "2000:2010",
+				"--jump", "CONNMARK",
+				"--set-xmark", "0x1/0x1",
+			}))
+		})
+	})
+
+	Describe("NewClientIPReplyRule", func() {
+		It("clears the mark of the replies of the container on marked connections", func() {
+			Expect(rules.NewClientIPReplyRule("10.255.0.2")).To(Equal(rules.IPTablesRule{
+				"-s", "10.255.0.2",
+				"-m", "connmark", "--mark", "0x1/0x1",
+				"--jump", "MARK",
+				"--set-xmark", "0x0/0xffffffff",
+			}))
+		})
+	})
+
+	Describe("scoped related and established rules", func() {
+		It("accepts the replies of connections made from the other side", func() {
+			Expect(rules.NewRelatedEstablishedReplyRule()).To(Equal(rules.IPTablesRule{
+				"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "--ctdir", "REPLY",
+				"--jump", "ACCEPT",
+			}))
+			Expect(rules.NewOverlayRelatedEstablishedReplyRule("10.255.0.2")).To(Equal(rules.IPTablesRule{
+				"-d", "10.255.0.2",
+				"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "--ctdir", "REPLY",
+				"--jump", "ACCEPT",
+			}))
+		})
+
+		It("marks new connections and accepts the marked ones", func() {
+			Expect(rules.NewNetOutConnMarkRule("0xab120000/0xffff0000")).To(Equal(rules.IPTablesRule{
+				"-m", "conntrack", "--ctstate", "NEW",
+				"--jump", "CONNMARK",
+				"--set-xmark", "0xab120000/0xffff0000",
+			}))
+			Expect(rules.NewNetOutRelatedEstablishedMarkRule("0xab120000/0xffff0000")).To(Equal(rules.IPTablesRule{
+				"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED",
+				"-m", "connmark", "--mark", "0xab120000/0xffff0000",
+				"--jump", "ACCEPT",
+			}))
+		})
+
+		It("accepts the connections that leave through an interface", func() {
+			Expect(rules.NewNetOutRelatedEstablishedInterfaceRule("eth1")).To(Equal(rules.IPTablesRule{
+				"-o", "eth1",
+				"-m", "state", "--state", "RELATED,ESTABLISHED",
+				"--jump", "ACCEPT",
+			}))
+		})
+	})
+
+	Describe("sni inspection rules", func() {
+		It("queues the undecided established connections to the ports", func() {
+			Expect(rules.NewNetOutSNIQueueRule("443,8443", 100, false)).To(Equal(rules.IPTablesRule{
+				"-p", "tcp",
+				"-m", "multiport", "--dports", "443,8443",
+				"-m", "conntrack", "--ctstate", "ESTABLISHED", "--ctdir", "ORIGINAL",
+				"-m", "connmark", "--mark", "0x0/0x6",
+				"--jump", "NFQUEUE",
+				"--queue-num", "100",
+			}))
+		})
+
+		It("queues the undecided established connections to the ports of a destination", func() {
+			Expect(rules.NewNetOutSNIDestinationQueueRule("10.0.0.0", "10.0.0.255", "443:445", 101, true)).To(Equal(rules.IPTablesRule{
+				"-m", "iprange",
+				"--dst-range", "10.0.0.0-10.0.0.255",
+				"-p", "tcp",
+				"-m", "multiport", "--dports", "443:445",
+				"-m", "conntrack", "--ctstate", "ESTABLISHED", "--ctdir", "ORIGINAL",
+				"-m", "connmark", "--mark", "0x0/0x6",
+				"--jump", "NFQUEUE",
+				"--queue-num", "101",
+				"--queue-bypass",
+			}))
+		})
+
+		It("bypasses the queue without a listener when asked to", func() {
+			rule := rules.NewNetOutSNIQueueRule("443", 100, true)
+			Expect(rule[len(rule)-1]).To(Equal("--queue-bypass"))
+		})
+
+		It("resets the denied connections", func() {
+			Expect(rules.NewNetOutSNIDeniedRule()).To(Equal(rules.IPTablesRule{
+				"-p", "tcp",
+				"-m", "connmark", "--mark", "0x6/0x6",
+				"--jump", "REJECT",
+				"--reject-with", "tcp-reset",
+			}))
+		})
+	})
+
 	Describe("NewNetOutJumpConditions", func() {
 		It("creates a jump rule when given one interface", func() {
 			jumpRule := rules.NewNetOutJumpConditions([]string{"eth0"}, "1.2.3.4", "a-chain")
@@ -235,4 +422,32 @@ var _ = Describe("Rules", func() {
 			}))
 		})
 	})
+	Describe("NewDSCPClearRule", func() {
+		It("resets the DSCP value of the packets that carry it", func() {
+			Expect(rules.NewDSCPClearRule(10)).To(Equal(rules.IPTablesRule{
+				"-m", "dscp", "--dscp", "10",
+				"-j", "DSCP",
+				"--set-dscp", "0",
+			}))
+		})
+	})
+
+	Describe("NewUIDDSCPMarkRule", func() {
+		It("marks the packets of the user with the DSCP value", func() {
+			Expect(rules.NewUIDDSCPMarkRule(2000, 10)).To(Equal(rules.IPTablesRule{
+				"-m", "owner", "--uid-owner", "2000",
+				"-j", "DSCP",
+				"--set-dscp", "10",
+			}))
+		})
+	})
+
+	Describe("NewDSCPMatchRule", func() {
+		It("limits the rule to the packets marked with the DSCP value", func() {
+			Expect(rules.NewDSCPMatchRule(10, rules.NewAcceptRule())).To(Equal(rules.IPTablesRule{
+				"-m", "dscp", "--dscp", "10",
+				"--jump", "ACCEPT",
+			}))
+		})
+	})
 })
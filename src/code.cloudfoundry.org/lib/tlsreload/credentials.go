@@ -0,0 +1,270 @@
+package tlsreload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// DefaultReloadInterval is how often the credential files are checked for
+// changes.
+const DefaultReloadInterval = 10 * time.Second
+
+// Credentials are the certificate, key and CA certificate of one end of a
+// mutual TLS connection. While Run is running the files are checked every
+// ReloadInterval and loaded again when their contents change, so that rotated
+// certificates are used for new connections without a restart. Connections
+// that are already open keep the certificates they were made with. When the
+// changed files cannot be loaded, e.g. because the certificate has been
+// written but the key not yet, the last good credentials are kept and the
+// files are tried again on the next check.
+type Credentials struct {
+	CertFile       string
+	KeyFile        string
+	CACertFile     string
+	Logger         lager.Logger
+	ReloadInterval time.Duration
+
+	// AuthorizedSPIFFEIDs switches the credentials to SPIFFE authentication
+	// when set: the certificate is an X.509 SVID, the CA certificate file is
+	// the trust bundle, and peers are authenticated by the SPIFFE ID of their
+	// SVID instead of by host name. Use AuthorizeSPIFFEIDs to set it.
+	AuthorizedSPIFFEIDs []*url.URL
+
+	mutex       sync.RWMutex
+	checksum    [sha256.Size]byte
+	certificate *tls.Certificate
+	caCertPool  *x509.CertPool
+}
+
+// New loads the credentials from their files.
+func New(logger lager.Logger, certFile, keyFile, caCertFile string) (*Credentials, error) {
+	c := &Credentials{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		CACertFile:     caCertFile,
+		Logger:         logger,
+		ReloadInterval: DefaultReloadInterval,
+	}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads the files when their contents changed since they were last
+// loaded, and reports whether they did.
+func (c *Credentials) Reload() (bool, error) {
+	certPEM, err := os.ReadFile(c.CertFile)
+	if err != nil {
+		return false, fmt.Errorf("read cert file: %s", err)
+	}
+	keyPEM, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("read key file: %s", err)
+	}
+	caCertPEM, err := os.ReadFile(c.CACertFile)
+	if err != nil {
+		return false, fmt.Errorf("read ca cert file: %s", err)
+	}
+
+	checksum := sha256.Sum256(bytes.Join([][]byte{certPEM, keyPEM, caCertPEM}, []byte{0}))
+	c.mutex.RLock()
+	unchanged := c.certificate != nil && checksum == c.checksum
+	c.mutex.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("unable to load cert or key: %s", err)
+	}
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCertPEM) {
+		return false, errors.New("unable to load ca cert")
+	}
+
+	c.mutex.Lock()
+	c.checksum = checksum
+	c.certificate = &certificate
+	c.caCertPool = caCertPool
+	c.mutex.Unlock()
+	return true, nil
+}
+
+// Run reloads the credentials every ReloadInterval until it is signalled.
+func (c *Credentials) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	ticker := time.NewTicker(c.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			reloaded, err := c.Reload()
+			if err != nil {
+				c.Logger.Error("reload-tls-credentials", err, lager.Data{"cert_file": c.CertFile})
+				continue
+			}
+			if reloaded {
+				c.Logger.Info("reloaded-tls-credentials", lager.Data{"cert_file": c.CertFile})
+			}
+		}
+	}
+}
+
+// AuthorizeSPIFFEIDs only accepts peers with one of the SPIFFE IDs, e.g.
+// "spiffe://example.org/silk-controller". An ID without a path, e.g.
+// "spiffe://example.org", accepts every workload of its trust domain.
+func (c *Credentials) AuthorizeSPIFFEIDs(ids []string) error {
+	authorized := []*url.URL{}
+	for _, id := range ids {
+		parsed, err := url.Parse(id)
+		if err != nil || parsed.Scheme != "spiffe" || parsed.Host == "" || parsed.RawQuery != "" || parsed.Fragment != "" {
+			return fmt.Errorf("invalid spiffe id '%s'", id)
+		}
+		authorized = append(authorized, parsed)
+	}
+	c.AuthorizedSPIFFEIDs = authorized
+	return nil
+}
+
+// verifySPIFFEID checks that the SVID of a peer has an authorized SPIFFE ID.
+func (c *Credentials) verifySPIFFEID(svid *x509.Certificate) error {
+	var id *url.URL
+	for _, uri := range svid.URIs {
+		if uri.Scheme == "spiffe" {
+			id = uri
+			break
+		}
+	}
+	if id == nil {
+		return errors.New("peer certificate has no spiffe id")
+	}
+	for _, authorized := range c.AuthorizedSPIFFEIDs {
+		if authorized.Host != id.Host {
+			continue
+		}
+		if authorized.Path == "" || authorized.Path == "/" || authorized.Path == id.Path {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer spiffe id %s is not authorized", id)
+}
+
+// Certificate returns the current certificate and its key.
+func (c *Credentials) Certificate() tls.Certificate {
+	certificate, _ := c.current()
+	return certificate
+}
+
+func (c *Credentials) current() (tls.Certificate, *x509.CertPool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return *c.certificate, c.caCertPool
+}
+
+// ServerTLSConfig returns the config of a server that requires its clients to
+// present a certificate signed by the CA. Every handshake uses the current
+// credentials.
+func (c *Credentials) ServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		MaxVersion: tls.VersionTLS13,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, caCertPool := c.current()
+			config := &tls.Config{
+				Certificates: []tls.Certificate{certificate},
+				ClientCAs:    caCertPool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+				CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+				MinVersion:   tls.VersionTLS12,
+				MaxVersion:   tls.VersionTLS13,
+			}
+			if len(c.AuthorizedSPIFFEIDs) > 0 {
+				config.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+					return c.verifySPIFFEID(verifiedChains[0][0])
+				}
+			}
+			return config, nil
+		},
+	}
+}
+
+// ClientTLSConfig returns the config of a client with the current
+// credentials.
+func (c *Credentials) ClientTLSConfig() *tls.Config {
+	certificate, caCertPool := c.current()
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		RootCAs:      caCertPool,
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS13,
+	}
+	if len(c.AuthorizedSPIFFEIDs) > 0 {
+		// SVIDs do not name the host of the server, so the chain is verified
+		// here without the host name, followed by the SPIFFE ID
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return c.verifyServerSVID(rawCerts, caCertPool)
+		}
+	}
+	return config
+}
+
+func (c *Credentials) verifyServerSVID(rawCerts [][]byte, bundle *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return fmt.Errorf("parse server certificate: %s", err)
+		}
+		certs[i] = cert
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         bundle,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return err
+	}
+	return c.verifySPIFFEID(certs[0])
+}
+
+// DialTLSContext connects to addr with the current credentials. It is meant
+// for the DialTLSContext of an http.Transport, whose TLSClientConfig would
+// keep the credentials it was created with.
+func (c *Credentials) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	config := c.ClientTLSConfig()
+	config.ServerName = host
+	dialer := &tls.Dialer{Config: config}
+	return dialer.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,387 @@
+package tlsreload_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/tlsreload"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCA() testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	return testCA{cert: cert, key: key, der: der}
+}
+
+// writeLeaf writes the CA and a certificate signed by it to dir. The
+// certificate is an SVID without a host name when a SPIFFE ID is given, and
+// a certificate for 127.0.0.1 otherwise.
+func (ca testCA) writeLeaf(dir, spiffeID string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if spiffeID == "" {
+		template.Subject = pkix.Name{CommonName: "127.0.0.1"}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	} else {
+		id, err := url.Parse(spiffeID)
+		Expect(err).NotTo(HaveOccurred())
+		template.URIs = []*url.URL{id}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	writePEM(filepath.Join(dir, "ca.crt"), "CERTIFICATE", ca.der)
+	writePEM(filepath.Join(dir, "cert.crt"), "CERTIFICATE", certDER)
+	writePEM(filepath.Join(dir, "cert.key"), "EC PRIVATE KEY", keyDER)
+}
+
+// writeCredentials writes a new CA and a certificate for 127.0.0.1 signed by
+// it to dir.
+func writeCredentials(dir string) {
+	newTestCA().writeLeaf(dir, "")
+}
+
+func loadCredentials(logger lager.Logger, dir string) *tlsreload.Credentials {
+	creds, err := tlsreload.New(logger, filepath.Join(dir, "cert.crt"), filepath.Join(dir, "cert.key"), filepath.Join(dir, "ca.crt"))
+	Expect(err).NotTo(HaveOccurred())
+	return creds
+}
+
+func serve(creds *tlsreload.Credentials) net.Listener {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", creds.ServerTLSConfig())
+	Expect(err).NotTo(HaveOccurred())
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	return listener
+}
+
+func get(creds *tlsreload.Credentials, listener net.Listener) (string, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialTLSContext:    creds.DialTLSContext,
+			DisableKeepAlives: true,
+		},
+	}
+	resp, err := client.Get("https://" + listener.Addr().String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func writePEM(path, blockType string, der []byte) {
+	Expect(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)).To(Succeed())
+}
+
+var _ = Describe("Credentials", func() {
+	var (
+		logger  *lagertest.TestLogger
+		dir     string
+		creds   *tlsreload.Credentials
+		credErr error
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		dir = GinkgoT().TempDir()
+		writeCredentials(dir)
+	})
+
+	JustBeforeEach(func() {
+		creds, credErr = tlsreload.New(logger, filepath.Join(dir, "cert.crt"), filepath.Join(dir, "cert.key"), filepath.Join(dir, "ca.crt"))
+	})
+
+	Describe("New", func() {
+		It("loads the credentials", func() {
+			Expect(credErr).NotTo(HaveOccurred())
+			Expect(creds.ClientTLSConfig().Certificates).To(HaveLen(1))
+			Expect(creds.ReloadInterval).To(Equal(tlsreload.DefaultReloadInterval))
+		})
+
+		Context("when a file is missing", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(dir, "cert.key"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(credErr).To(MatchError(ContainSubstring("read key file:")))
+			})
+		})
+
+		Context("when the ca cert is not a certificate", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("banana"), 0600)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(credErr).To(MatchError("unable to load ca cert"))
+			})
+		})
+	})
+
+	Describe("Reload", func() {
+		It("does not reload files that did not change", func() {
+			reloaded, err := creds.Reload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded).To(BeFalse())
+		})
+
+		It("reloads files that changed", func() {
+			before := creds.ClientTLSConfig().Certificates[0].Certificate[0]
+			writeCredentials(dir)
+
+			reloaded, err := creds.Reload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded).To(BeTrue())
+			Expect(creds.ClientTLSConfig().Certificates[0].Certificate[0]).NotTo(Equal(before))
+		})
+
+		It("returns the reloaded certificate", func() {
+			before := creds.Certificate().Certificate[0]
+			writeCredentials(dir)
+
+			_, err := creds.Reload()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(creds.Certificate().Certificate[0]).NotTo(Equal(before))
+			Expect(creds.Certificate().PrivateKey).NotTo(BeNil())
+		})
+
+		Context("when the changed files cannot be loaded", func() {
+			It("keeps the last good credentials and returns an error", func() {
+				before := creds.ClientTLSConfig().Certificates[0].Certificate[0]
+				Expect(os.WriteFile(filepath.Join(dir, "cert.key"), []byte("banana"), 0600)).To(Succeed())
+
+				_, err := creds.Reload()
+				Expect(err).To(MatchError(ContainSubstring("unable to load cert or key:")))
+				Expect(creds.ClientTLSConfig().Certificates[0].Certificate[0]).To(Equal(before))
+			})
+		})
+	})
+
+	Context("when a client and a server use the credentials", func() {
+		var (
+			serverDir   string
+			serverCreds *tlsreload.Credentials
+			listener    net.Listener
+		)
+
+		BeforeEach(func() {
+			serverDir = GinkgoT().TempDir()
+			for _, name := range []string{"ca.crt", "cert.crt", "cert.key"} {
+				contents, err := os.ReadFile(filepath.Join(dir, name))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(serverDir, name), contents, 0600)).To(Succeed())
+			}
+		})
+
+		JustBeforeEach(func() {
+			serverCreds = loadCredentials(logger, serverDir)
+			listener = serve(serverCreds)
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("connects with mutual tls", func() {
+			Expect(get(creds, listener)).To(Equal("hello"))
+		})
+
+		It("uses rotated credentials for new connections", func() {
+			writeCredentials(serverDir)
+			_, err := serverCreds.Reload()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = get(creds, listener)
+			Expect(err).To(MatchError(ContainSubstring("certificate signed by unknown authority")))
+
+			for _, name := range []string{"ca.crt", "cert.crt", "cert.key"} {
+				contents, err := os.ReadFile(filepath.Join(serverDir, name))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(dir, name), contents, 0600)).To(Succeed())
+			}
+			_, err = creds.Reload()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(get(creds, listener)).To(Equal("hello"))
+		})
+	})
+
+	Describe("AuthorizeSPIFFEIDs", func() {
+		DescribeTable("rejects ids that are not spiffe ids",
+			func(id string) {
+				err := creds.AuthorizeSPIFFEIDs([]string{"spiffe://example.org", id})
+				Expect(err).To(MatchError(fmt.Sprintf("invalid spiffe id '%s'", id)))
+			},
+			Entry("another scheme", "https://example.org/silk"),
+			Entry("no trust domain", "spiffe:///silk"),
+			Entry("a query", "spiffe://example.org/silk?banana"),
+			Entry("not a url", "%zz"),
+		)
+	})
+
+	Context("when the client and the server authenticate each other by spiffe id", func() {
+		var (
+			clientCreds *tlsreload.Credentials
+			serverCreds *tlsreload.Credentials
+			listener    net.Listener
+		)
+
+		BeforeEach(func() {
+			bundle := newTestCA()
+			clientDir := GinkgoT().TempDir()
+			bundle.writeLeaf(clientDir, "spiffe://example.org/silk-daemon")
+			serverDir := GinkgoT().TempDir()
+			bundle.writeLeaf(serverDir, "spiffe://example.org/silk-controller")
+
+			clientCreds = loadCredentials(logger, clientDir)
+			Expect(clientCreds.AuthorizeSPIFFEIDs([]string{"spiffe://example.org/silk-controller"})).To(Succeed())
+			serverCreds = loadCredentials(logger, serverDir)
+			Expect(serverCreds.AuthorizeSPIFFEIDs([]string{"spiffe://example.org"})).To(Succeed())
+		})
+
+		JustBeforeEach(func() {
+			listener = serve(serverCreds)
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("connects without checking the host name", func() {
+			Expect(get(clientCreds, listener)).To(Equal("hello"))
+		})
+
+		Context("when the server does not have an authorized id", func() {
+			BeforeEach(func() {
+				Expect(clientCreds.AuthorizeSPIFFEIDs([]string{"spiffe://example.org/policy-server"})).To(Succeed())
+			})
+
+			It("does not connect", func() {
+				_, err := get(clientCreds, listener)
+				Expect(err).To(MatchError(ContainSubstring("peer spiffe id spiffe://example.org/silk-controller is not authorized")))
+			})
+		})
+
+		Context("when the client does not have an authorized id", func() {
+			BeforeEach(func() {
+				Expect(serverCreds.AuthorizeSPIFFEIDs([]string{"spiffe://other.org"})).To(Succeed())
+			})
+
+			It("is not served", func() {
+				_, err := get(clientCreds, listener)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when the server is not signed by the trust bundle", func() {
+			BeforeEach(func() {
+				serverDir := GinkgoT().TempDir()
+				newTestCA().writeLeaf(serverDir, "spiffe://example.org/silk-controller")
+				serverCreds = loadCredentials(logger, serverDir)
+			})
+
+			It("does not connect", func() {
+				_, err := get(clientCreds, listener)
+				Expect(err).To(MatchError(ContainSubstring("certificate signed by unknown authority")))
+			})
+		})
+
+		Context("when the server certificate has no spiffe id", func() {
+			BeforeEach(func() {
+				serverDir := GinkgoT().TempDir()
+				writeCredentials(serverDir)
+				clientDir := GinkgoT().TempDir()
+				for _, name := range []string{"ca.crt", "cert.crt", "cert.key"} {
+					contents, err := os.ReadFile(filepath.Join(serverDir, name))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(os.WriteFile(filepath.Join(clientDir, name), contents, 0600)).To(Succeed())
+				}
+				serverCreds = loadCredentials(logger, serverDir)
+				clientCreds = loadCredentials(logger, clientDir)
+				Expect(clientCreds.AuthorizeSPIFFEIDs([]string{"spiffe://example.org"})).To(Succeed())
+			})
+
+			It("does not connect", func() {
+				_, err := get(clientCreds, listener)
+				Expect(err).To(MatchError(ContainSubstring("peer certificate has no spiffe id")))
+			})
+		})
+	})
+
+	Describe("Run", func() {
+		var process ifrit.Process
+
+		JustBeforeEach(func() {
+			creds.ReloadInterval = 10 * time.Millisecond
+			process = ifrit.Invoke(creds)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		})
+
+		It("reloads the credentials when their files change", func() {
+			writeCredentials(dir)
+			Eventually(logger).Should(gbytes.Say("reloaded-tls-credentials"))
+		})
+
+		It("logs the errors of reloading", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "ca.crt"), []byte("banana"), 0600)).To(Succeed())
+			Eventually(logger).Should(gbytes.Say("reload-tls-credentials.*unable to load ca cert"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package tlsreload_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTLSReload(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TLSReload Suite")
+}
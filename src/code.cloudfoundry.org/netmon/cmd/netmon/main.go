@@ -15,9 +15,12 @@ import (
 	"sync"
 
 	"code.cloudfoundry.org/lib/common"
+	"code.cloudfoundry.org/lib/containerevents"
+	"code.cloudfoundry.org/lib/poller"
 	"code.cloudfoundry.org/lib/rules"
 
 	"code.cloudfoundry.org/cf-networking-helpers/runner"
+	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/filelock"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
@@ -93,6 +96,7 @@ func main() {
 
 	networkStatsFetcher := network_stats.NewFetcher(lockedIPTables, logger)
 	ruleCountAggregator := network_stats.NewIntAggregator()
+	lastRuleCount := &network_stats.LastCount{}
 
 	systemMetrics := &pollers.SystemMetrics{
 		Logger:              logger,
@@ -100,10 +104,44 @@ func main() {
 		InterfaceName:       conf.InterfaceName,
 		NetworkStatsFetcher: networkStatsFetcher,
 		RuleCountAggregator: ruleCountAggregator,
+		LastRuleCount:       lastRuleCount,
+	}
+
+	interfaceStates := &pollers.InterfaceStates{
+		Logger:          logger,
+		PollInterval:    pollInterval,
+		InterfaceName:   conf.InterfaceName,
+		InterfaceLister: network_stats.NewInterfaceLister(),
 	}
 
 	members := grouper.Members{
 		{Name: "metric_poller", Runner: systemMetrics},
+		{Name: "interface_state_poller", Runner: interfaceStates},
+	}
+
+	if conf.ContainerEventsSocket != "" {
+		trigger, measure := poller.NewTrigger()
+		interfaceStates.Trigger = trigger
+		members = append(members, grouper.Member{Name: "container_events", Runner: &containerevents.Subscriber{
+			Path: conf.ContainerEventsSocket,
+			Handler: func(event containerevents.Event) {
+				if event.Type == containerevents.TypeAdd {
+					measure()
+				}
+			},
+			Logger: logger.Session("container-events"),
+		}})
+	}
+
+	if conf.IPTablesLatencyEnabled {
+		iptablesLatency := &pollers.IPTablesLatency{
+			Logger:        logger,
+			PollInterval:  pollInterval,
+			LastRuleCount: lastRuleCount,
+			LatencyProber: network_stats.NewLatencyProber(ipt, time.Second),
+		}
+
+		members = append(members, grouper.Member{Name: "iptables_latency_poller", Runner: iptablesLatency})
 	}
 
 	if conf.TelemetryEnabled {
@@ -129,6 +167,11 @@ func main() {
 		members = append(members, grouper.Member{Name: "telemetry_poller", Runner: telemetryPoller})
 	}
 
+	if conf.DebugServerPort != 0 {
+		debugServerAddress := fmt.Sprintf("%s:%d", conf.DebugServerHost, conf.DebugServerPort)
+		members = append(members, grouper.Member{Name: "debug-server", Runner: debugserver.Runner(debugServerAddress, sink)})
+	}
+
 	monitor := ifrit.Invoke(sigmon.New(grouper.NewOrdered(os.Interrupt, members)))
 	logger.Info("starting")
 	err = <-monitor.Wait()
@@ -1,16 +1,14 @@
 package config
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"strings"
 
 	"gopkg.in/validator.v2"
 
 	"code.cloudfoundry.org/lager/v3"
+	libconfig "code.cloudfoundry.org/lib/config"
 )
 
 type Netmon struct {
@@ -22,6 +20,14 @@ type Netmon struct {
 	IPTablesLockFile  string `json:"iptables_lock_file" validate:"nonzero"`
 	TelemetryEnabled  bool   `json:"telemetry_enabled"`
 	TelemetryInterval int    `json:"telemetry_interval"`
+	DebugServerHost   string `json:"debug_server_host"`
+	DebugServerPort   int    `json:"debug_server_port"`
+
+	IPTablesLatencyEnabled bool `json:"iptables_latency_enabled"`
+	// ContainerEventsSocket is where netmon listens for the containers the
+	// cni-wrapper-plugin adds and deletes, to measure their interfaces right
+	// away.
+	ContainerEventsSocket string `json:"container_events_socket"`
 }
 
 func (n Netmon) ParseLogLevel() (lager.LogLevel, error) {
@@ -46,23 +52,6 @@ func (c *Netmon) Validate() error {
 }
 
 func New(path string) (*Netmon, error) {
-	if _, err := os.Stat(path); err != nil {
-		return nil, fmt.Errorf("file does not exist: %s", err)
-	}
-	jsonBytes, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("reading config: %s", err) // not tested
-	}
-
-	cfg := Netmon{}
-	err = json.Unmarshal(jsonBytes, &cfg)
-	if err != nil {
-		return nil, fmt.Errorf("parsing config: %s", err)
-	}
-
-	if err := cfg.Validate(); err != nil {
-		return &cfg, fmt.Errorf("invalid config: %s", err)
-	}
-
-	return &cfg, nil
+	cfg := &Netmon{}
+	return cfg, libconfig.Load(path, cfg)
 }
@@ -63,7 +63,11 @@ var _ = Describe("Config", func() {
 					"log_prefix": "cfnetworking",
 					"iptables_lock_file": "iptables-lock-file",
 					"telemetry_enabled": true,
-					"telemetry_interval": 2345
+					"telemetry_interval": 2345,
+					"debug_server_host": "127.0.0.1",
+					"debug_server_port": 8723,
+					"iptables_latency_enabled": true,
+					"container_events_socket": "/var/vcap/data/container-metadata/events/netmon.sock"
 				}`)
 				c, err := config.New(file.Name())
 				Expect(err).NotTo(HaveOccurred())
@@ -75,6 +79,10 @@ var _ = Describe("Config", func() {
 				Expect(c.IPTablesLockFile).To(Equal("iptables-lock-file"))
 				Expect(c.TelemetryEnabled).To(BeTrue())
 				Expect(c.TelemetryInterval).To(Equal(2345))
+				Expect(c.DebugServerHost).To(Equal("127.0.0.1"))
+				Expect(c.DebugServerPort).To(Equal(8723))
+				Expect(c.IPTablesLatencyEnabled).To(BeTrue())
+				Expect(c.ContainerEventsSocket).To(Equal("/var/vcap/data/container-metadata/events/netmon.sock"))
 			})
 		})
 
@@ -100,6 +108,14 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when config file has a key the config does not have", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"poll_intreval": 5}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(ContainSubstring(`parsing config (%s): json: unknown field "poll_intreval"`, file.Name())))
+			})
+		})
+
 		Context("when `telemetry_enabled` is not set", func() {
 			It("defaults to false", func() {
 				file.WriteString(`{
@@ -113,6 +129,7 @@ var _ = Describe("Config", func() {
 				c, err := config.New(file.Name())
 				Expect(err).NotTo(HaveOccurred())
 				Expect(c.TelemetryEnabled).To(BeFalse())
+				Expect(c.IPTablesLatencyEnabled).To(BeFalse())
 			})
 		})
 
@@ -1,5 +1,7 @@
 package network_stats
 
+import "sync"
+
 type IntAggregator struct {
 	Average     int
 	AverageRaw  float64
@@ -47,3 +49,25 @@ func (agg *IntAggregator) Flush() {
 	agg.Total = 0
 	agg.UpdateCount = 0
 }
+
+// LastCount holds the latest rule count of one poller for the others, so
+// that the rules are only counted once per poll.
+type LastCount struct {
+	mutex sync.Mutex
+	count int
+	set   bool
+}
+
+func (c *LastCount) Set(count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.count = count
+	c.set = true
+}
+
+// Get returns false until the first count is set.
+func (c *LastCount) Get() (int, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.count, c.set
+}
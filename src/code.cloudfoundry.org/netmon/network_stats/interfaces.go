@@ -0,0 +1,18 @@
+package network_stats
+
+import "net"
+
+//go:generate counterfeiter -o ../fakes/interface_lister.go --fake-name InterfaceLister . InterfaceLister
+type InterfaceLister interface {
+	Interfaces() ([]net.Interface, error)
+}
+
+type interfaceLister struct{}
+
+func NewInterfaceLister() InterfaceLister {
+	return interfaceLister{}
+}
+
+func (interfaceLister) Interfaces() ([]net.Interface, error) {
+	return net.Interfaces()
+}
@@ -0,0 +1,134 @@
+package network_stats
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//go:generate counterfeiter -o ../fakes/latency_prober.go --fake-name LatencyProber . LatencyProber
+type LatencyProber interface {
+	CommandLatency() (time.Duration, error)
+	RoundTripLatency() (time.Duration, error)
+}
+
+type iptablesLister interface {
+	List(table, chain string) ([]string, error)
+}
+
+// ErrNoProbeTarget is returned by RoundTripLatency when the cell runs no
+// containers to send the probe to.
+var ErrNoProbeTarget = errors.New("no container to probe")
+
+// containerProbePort is a port containers are not expected to listen on, so
+// that probing them only makes their kernel answer with a reset.
+const containerProbePort = 1
+
+type latencyProber struct {
+	IPTables       iptablesLister
+	InterfaceNames func() ([]string, error)
+	ProbePort      int
+	Timeout        time.Duration
+}
+
+// NewLatencyProber takes the iptables library itself rather than the locked
+// adapter, so that waiting for the lock shared with the other jobs is not
+// counted as iptables latency.
+func NewLatencyProber(ipt iptablesLister, timeout time.Duration) latencyProber {
+	return latencyProber{
+		IPTables:       ipt,
+		InterfaceNames: interfaceNames,
+		ProbePort:      containerProbePort,
+		Timeout:        timeout,
+	}
+}
+
+// CommandLatency times listing the filter INPUT chain. iptables loads the
+// whole table for every command, so this grows with the number of rules.
+func (p latencyProber) CommandLatency() (time.Duration, error) {
+	start := time.Now()
+	_, err := p.IPTables.List("filter", "INPUT")
+	if err != nil {
+		return 0, fmt.Errorf("list filter INPUT: %s", err)
+	}
+	return time.Since(start), nil
+}
+
+// RoundTripLatency times a TCP connection attempt to a container of the cell.
+// The container answers from behind its silk host interface, so the reply
+// passes the PREROUTING chains of the container and its input chain like the
+// traffic of the container does. A refused connection is a reply as well.
+func (p latencyProber) RoundTripLatency() (time.Duration, error) {
+	target, err := p.probeTarget()
+	if err != nil {
+		return 0, err
+	}
+
+	address := net.JoinHostPort(target.String(), strconv.Itoa(p.ProbePort))
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, p.Timeout)
+	latency := time.Since(start)
+	if err == nil {
+		conn.Close()
+		return latency, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return latency, nil
+	}
+	return 0, fmt.Errorf("probe %s: %s", address, err)
+}
+
+// probeTarget picks the container with the lowest IP, found through the host
+// interfaces silk names s-<ip> with every octet padded to three digits.
+func (p latencyProber) probeTarget() (net.IP, error) {
+	names, err := p.InterfaceNames()
+	if err != nil {
+		return nil, fmt.Errorf("list interfaces: %s", err)
+	}
+
+	var targets []net.IP
+	for _, name := range names {
+		if ip := containerIPFromHostInterface(name); ip != nil {
+			targets = append(targets, ip)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, ErrNoProbeTarget
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return string(targets[i]) < string(targets[j])
+	})
+	return targets[0], nil
+}
+
+func containerIPFromHostInterface(name string) net.IP {
+	if len(name) != len("s-000000000000") || name[:2] != "s-" {
+		return nil
+	}
+	ip := make(net.IP, 4)
+	for i := 0; i < 4; i++ {
+		octet, err := strconv.Atoi(name[2+3*i : 5+3*i])
+		if err != nil || octet > 255 {
+			return nil
+		}
+		ip[i] = byte(octet)
+	}
+	return ip
+}
+
+func interfaceNames() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		names = append(names, iface.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,133 @@
+package network_stats_test
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	network_stats "code.cloudfoundry.org/netmon/network_stats"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LatencyProber", func() {
+	var (
+		iptables   *libfakes.IPTablesAdapter
+		interfaces []string
+		prober     network_stats.LatencyProber
+	)
+
+	BeforeEach(func() {
+		iptables = &libfakes.IPTablesAdapter{}
+		interfaces = []string{"lo", "eth0", "silk-vtep"}
+		prober = nil
+	})
+
+	JustBeforeEach(func() {
+		if prober != nil {
+			return
+		}
+		latencyProber := network_stats.NewLatencyProber(iptables, time.Second)
+		latencyProber.InterfaceNames = func() ([]string, error) {
+			return interfaces, nil
+		}
+		prober = latencyProber
+	})
+
+	Describe("CommandLatency", func() {
+		It("times listing the filter INPUT chain", func() {
+			iptables.ListStub = func(string, string) ([]string, error) {
+				time.Sleep(10 * time.Millisecond)
+				return []string{"-P INPUT ACCEPT"}, nil
+			}
+
+			latency, err := prober.CommandLatency()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latency).To(BeNumerically(">=", 10*time.Millisecond))
+
+			Expect(iptables.ListCallCount()).To(Equal(1))
+			table, chain := iptables.ListArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("INPUT"))
+		})
+
+		Context("when listing the chain fails", func() {
+			BeforeEach(func() {
+				iptables.ListReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := prober.CommandLatency()
+				Expect(err).To(MatchError("list filter INPUT: banana"))
+			})
+		})
+	})
+
+	Describe("RoundTripLatency", func() {
+		var listener net.Listener
+
+		BeforeEach(func() {
+			var err error
+			listener, err = net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			_, port, err := net.SplitHostPort(listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+
+			interfaces = append(interfaces, "s-127000000009", "s-127000000001")
+
+			latencyProber := network_stats.NewLatencyProber(iptables, time.Second)
+			latencyProber.InterfaceNames = func() ([]string, error) {
+				return interfaces, nil
+			}
+			latencyProber.ProbePort, err = strconv.Atoi(port)
+			Expect(err).NotTo(HaveOccurred())
+			prober = latencyProber
+		})
+
+		AfterEach(func() {
+			listener.Close()
+		})
+
+		It("times a connection to the container with the lowest IP", func() {
+			accepted := make(chan string, 1)
+			go func() {
+				defer GinkgoRecover()
+				conn, err := listener.Accept()
+				Expect(err).NotTo(HaveOccurred())
+				accepted <- conn.LocalAddr().String()
+				conn.Close()
+			}()
+
+			latency, err := prober.RoundTripLatency()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latency).To(BeNumerically(">", 0))
+			Expect(latency).To(BeNumerically("<", time.Second))
+			Eventually(accepted).Should(Receive(HavePrefix("127.0.0.1:")))
+		})
+
+		Context("when the container refuses the connection", func() {
+			BeforeEach(func() {
+				listener.Close()
+			})
+
+			It("counts the reset as the reply", func() {
+				latency, err := prober.RoundTripLatency()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(latency).To(BeNumerically(">", 0))
+			})
+		})
+
+		Context("when the cell runs no containers", func() {
+			BeforeEach(func() {
+				interfaces = []string{"lo", "eth0", "s-not-a-veth", "s-999000000001"}
+			})
+
+			It("returns ErrNoProbeTarget", func() {
+				_, err := prober.RoundTripLatency()
+				Expect(err).To(MatchError(network_stats.ErrNoProbeTarget))
+			})
+		})
+	})
+})
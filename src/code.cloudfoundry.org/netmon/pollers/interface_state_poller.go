@@ -0,0 +1,103 @@
+package pollers
+
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/netmon/network_stats"
+	"code.cloudfoundry.org/runtimeschema/metric"
+)
+
+const interfaceStateChanges = metric.Counter("InterfaceStateChanges")
+const interfaceMTUChanges = metric.Counter("InterfaceMTUChanges")
+
+// VethPrefix starts the names of the host side veths of the containers.
+const VethPrefix = "s-"
+
+// InterfaceStates tracks the VTEP and the veths of the containers between
+// polls, and logs and counts the interfaces that went down or up or changed
+// their MTU, so that the connection resets of apps can be correlated with
+// flaps of the underlay. Interfaces that appear or go away with their
+// containers are not counted. The interfaces are also measured whenever
+// Trigger receives, e.g. when a container was added, so that its veth is
+// tracked from the start.
+type InterfaceStates struct {
+	Logger          lager.Logger
+	PollInterval    time.Duration
+	InterfaceName   string
+	InterfaceLister network_stats.InterfaceLister
+	Trigger         <-chan struct{}
+
+	last map[string]net.Interface
+}
+
+func (m *InterfaceStates) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-time.After(m.PollInterval):
+			m.measure(m.Logger.Session("measure-interface-states"))
+		case <-m.Trigger:
+			m.measure(m.Logger.Session("measure-interface-states"))
+		}
+	}
+}
+
+func (m *InterfaceStates) measure(logger lager.Logger) {
+	logger.Debug("measure-start")
+	defer logger.Debug("measure-complete")
+
+	ifaces, err := m.InterfaceLister.Interfaces()
+	if err != nil {
+		logger.Error("list-interfaces", err)
+		return
+	}
+
+	current := map[string]net.Interface{}
+	for _, iface := range ifaces {
+		if iface.Name != m.InterfaceName && !strings.HasPrefix(iface.Name, VethPrefix) {
+			continue
+		}
+		current[iface.Name] = iface
+
+		previous, ok := m.last[iface.Name]
+		if !ok {
+			continue
+		}
+
+		if state(previous) != state(iface) {
+			logger.Info("interface-state-changed", lager.Data{
+				"interface": iface.Name,
+				"from":      state(previous),
+				"to":        state(iface),
+			})
+			interfaceStateChanges.Increment()
+		}
+
+		if previous.MTU != iface.MTU {
+			logger.Info("interface-mtu-changed", lager.Data{
+				"interface": iface.Name,
+				"from":      previous.MTU,
+				"to":        iface.MTU,
+			})
+			interfaceMTUChanges.Increment()
+		}
+	}
+	m.last = current
+}
+
+// state is up when the interface is administratively up and has a carrier.
+func state(iface net.Interface) string {
+	if iface.Flags&net.FlagUp == 0 {
+		return "down"
+	}
+	if iface.Flags&net.FlagRunning == 0 {
+		return "no-carrier"
+	}
+	return "up"
+}
@@ -0,0 +1,189 @@
+package pollers_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/netmon/fakes"
+	"code.cloudfoundry.org/netmon/pollers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InterfaceStates Run", func() {
+	var (
+		interfaceLister *fakes.InterfaceLister
+		logger          *lagertest.TestLogger
+
+		interfaceStates *pollers.InterfaceStates
+		pollInterval    time.Duration
+
+		before []net.Interface
+		after  []net.Interface
+	)
+
+	up := net.FlagUp | net.FlagRunning
+
+	BeforeEach(func() {
+		interfaceLister = &fakes.InterfaceLister{}
+		logger = lagertest.NewTestLogger("test")
+		pollInterval = 50 * time.Millisecond
+
+		before = []net.Interface{
+			{Name: "silk-vtep", MTU: 1450, Flags: up},
+			{Name: "s-010255000001", MTU: 1410, Flags: up},
+			{Name: "s-010255000002", MTU: 1410, Flags: up},
+			{Name: "eth0", MTU: 1500, Flags: up},
+		}
+		after = before
+
+		interfaceStates = &pollers.InterfaceStates{
+			Logger:          logger,
+			PollInterval:    pollInterval,
+			InterfaceName:   "silk-vtep",
+			InterfaceLister: interfaceLister,
+		}
+	})
+
+	runTwoPolls := func() {
+		interfaceLister.InterfacesReturnsOnCall(0, before, nil)
+		interfaceLister.InterfacesReturns(after, nil)
+
+		doneCh := make(chan os.Signal)
+		readyCh := make(chan struct{})
+		go interfaceStates.Run(doneCh, readyCh)
+
+		<-readyCh
+		Eventually(interfaceLister.InterfacesCallCount).Should(BeNumerically(">=", 2))
+		doneCh <- os.Interrupt
+	}
+
+	Context("when it is triggered", func() {
+		BeforeEach(func() {
+			interfaceStates.PollInterval = time.Hour
+		})
+
+		It("measures the interfaces right away", func() {
+			trigger := make(chan struct{})
+			interfaceStates.Trigger = trigger
+			interfaceLister.InterfacesReturns(before, nil)
+
+			doneCh := make(chan os.Signal)
+			readyCh := make(chan struct{})
+			go interfaceStates.Run(doneCh, readyCh)
+			<-readyCh
+
+			trigger <- struct{}{}
+			Eventually(interfaceLister.InterfacesCallCount).Should(Equal(1))
+			doneCh <- os.Interrupt
+		})
+	})
+
+	It("logs nothing when no interface changed", func() {
+		runTwoPolls()
+
+		Expect(logger.LogMessages()).NotTo(ContainElement("test.measure-interface-states.interface-state-changed"))
+		Expect(logger.LogMessages()).NotTo(ContainElement("test.measure-interface-states.interface-mtu-changed"))
+	})
+
+	Context("when the vtep loses its carrier", func() {
+		BeforeEach(func() {
+			after = []net.Interface{
+				{Name: "silk-vtep", MTU: 1450, Flags: net.FlagUp},
+				before[1], before[2], before[3],
+			}
+		})
+
+		It("logs the transition", func() {
+			runTwoPolls()
+
+			logs := logger.Logs()
+			Expect(logs).To(ContainElement(SatisfyAll(
+				HaveField("Message", "test.measure-interface-states.interface-state-changed"),
+				HaveField("Data", HaveKeyWithValue("interface", "silk-vtep")),
+				HaveField("Data", HaveKeyWithValue("from", "up")),
+				HaveField("Data", HaveKeyWithValue("to", "no-carrier")),
+			)))
+		})
+	})
+
+	Context("when a veth goes down and changes its mtu", func() {
+		BeforeEach(func() {
+			after = []net.Interface{
+				before[0],
+				{Name: "s-010255000001", MTU: 1400, Flags: 0},
+				before[2], before[3],
+			}
+		})
+
+		It("logs both changes", func() {
+			runTwoPolls()
+
+			logs := logger.Logs()
+			Expect(logs).To(ContainElement(SatisfyAll(
+				HaveField("Message", "test.measure-interface-states.interface-state-changed"),
+				HaveField("Data", HaveKeyWithValue("interface", "s-010255000001")),
+				HaveField("Data", HaveKeyWithValue("from", "up")),
+				HaveField("Data", HaveKeyWithValue("to", "down")),
+			)))
+			Expect(logs).To(ContainElement(SatisfyAll(
+				HaveField("Message", "test.measure-interface-states.interface-mtu-changed"),
+				HaveField("Data", HaveKeyWithValue("interface", "s-010255000001")),
+				HaveField("Data", HaveKeyWithValue("from", float64(1410))),
+				HaveField("Data", HaveKeyWithValue("to", float64(1400))),
+			)))
+		})
+	})
+
+	Context("when an interface that is not tracked changes", func() {
+		BeforeEach(func() {
+			after = []net.Interface{
+				before[0], before[1], before[2],
+				{Name: "eth0", MTU: 9000, Flags: 0},
+			}
+		})
+
+		It("logs nothing", func() {
+			runTwoPolls()
+
+			Expect(logger.LogMessages()).NotTo(ContainElement("test.measure-interface-states.interface-state-changed"))
+			Expect(logger.LogMessages()).NotTo(ContainElement("test.measure-interface-states.interface-mtu-changed"))
+		})
+	})
+
+	Context("when containers come and go", func() {
+		BeforeEach(func() {
+			after = []net.Interface{
+				before[0], before[1],
+				{Name: "s-010255000003", MTU: 1410, Flags: net.FlagUp},
+			}
+		})
+
+		It("does not count them as changes", func() {
+			runTwoPolls()
+
+			Expect(logger.LogMessages()).NotTo(ContainElement("test.measure-interface-states.interface-state-changed"))
+		})
+	})
+
+	Context("when listing the interfaces fails", func() {
+		BeforeEach(func() {
+			interfaceLister.InterfacesReturns(nil, errors.New("potato"))
+		})
+
+		It("logs the error", func() {
+			doneCh := make(chan os.Signal)
+			readyCh := make(chan struct{})
+			go interfaceStates.Run(doneCh, readyCh)
+
+			<-readyCh
+			Eventually(interfaceLister.InterfacesCallCount).Should(BeNumerically(">=", 1))
+			doneCh <- os.Interrupt
+
+			Expect(logger.LogMessages()).To(ContainElement("test.measure-interface-states.list-interfaces"))
+		})
+	})
+})
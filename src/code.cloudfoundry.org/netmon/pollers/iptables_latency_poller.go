@@ -0,0 +1,85 @@
+package pollers
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/netmon/network_stats"
+	"code.cloudfoundry.org/runtimeschema/metric"
+)
+
+const iptablesCommandLatency = metric.Duration("IPTablesCommandLatency")
+const iptablesRoundTripLatency = metric.Duration("IPTablesRoundTripLatency")
+const iptablesCommandLatencyPerThousandRules = metric.Duration("IPTablesCommandLatencyPerThousandRules")
+
+type IPTablesLatency struct {
+	Logger        lager.Logger
+	PollInterval  time.Duration
+	LastRuleCount *network_stats.LastCount
+	LatencyProber network_stats.LatencyProber
+}
+
+func (m *IPTablesLatency) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-time.After(m.PollInterval):
+			m.measure(m.Logger.Session("measure-iptables-latency"))
+		}
+	}
+}
+
+func (m *IPTablesLatency) measure(logger lager.Logger) {
+	logger.Debug("measure-start")
+	defer logger.Debug("measure-complete")
+
+	commandLatency, err := m.LatencyProber.CommandLatency()
+	if err != nil {
+		logger.Error("command-latency", err)
+		return
+	}
+
+	if err := iptablesCommandLatency.Send(commandLatency); err != nil {
+		logger.Error("failed-to-send-metric", err, lager.Data{
+			"metric": iptablesCommandLatency})
+		return
+	}
+	logger.Debug("metric-sent", lager.Data{"IPTablesCommandLatency": commandLatency.String()})
+
+	// The rule count comes from the system metrics poller; until it has
+	// counted once there is nothing to relate the latency to.
+	nIpTablesRule, counted := m.LastRuleCount.Get()
+	if counted && nIpTablesRule > 0 {
+		perThousandRules := commandLatency * 1000 / time.Duration(nIpTablesRule)
+		if err := iptablesCommandLatencyPerThousandRules.Send(perThousandRules); err != nil {
+			logger.Error("failed-to-send-metric", err, lager.Data{
+				"metric": iptablesCommandLatencyPerThousandRules})
+			return
+		}
+		logger.Debug("metric-sent", lager.Data{
+			"IPTablesRuleCount":                      nIpTablesRule,
+			"IPTablesCommandLatencyPerThousandRules": perThousandRules.String(),
+		})
+	}
+
+	roundTripLatency, err := m.LatencyProber.RoundTripLatency()
+	if errors.Is(err, network_stats.ErrNoProbeTarget) {
+		logger.Debug("round-trip-latency-skipped", lager.Data{"reason": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Error("round-trip-latency", err)
+		return
+	}
+
+	if err := iptablesRoundTripLatency.Send(roundTripLatency); err != nil {
+		logger.Error("failed-to-send-metric", err, lager.Data{
+			"metric": iptablesRoundTripLatency})
+		return
+	}
+	logger.Debug("metric-sent", lager.Data{"IPTablesRoundTripLatency": roundTripLatency.String()})
+}
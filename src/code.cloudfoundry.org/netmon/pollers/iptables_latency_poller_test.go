@@ -0,0 +1,118 @@
+package pollers_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/netmon/fakes"
+	"code.cloudfoundry.org/netmon/network_stats"
+	"code.cloudfoundry.org/netmon/pollers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IPTablesLatency Run", func() {
+	var (
+		lastRuleCount *network_stats.LastCount
+		latencyProber *fakes.LatencyProber
+		logger        *lagertest.TestLogger
+
+		iptablesLatency *pollers.IPTablesLatency
+		pollInterval    time.Duration
+	)
+
+	BeforeEach(func() {
+		lastRuleCount = &network_stats.LastCount{}
+		latencyProber = &fakes.LatencyProber{}
+		logger = lagertest.NewTestLogger("test")
+		pollInterval = 1 * time.Second
+
+		lastRuleCount.Set(2000)
+		latencyProber.CommandLatencyReturns(30*time.Millisecond, nil)
+		latencyProber.RoundTripLatencyReturns(200*time.Microsecond, nil)
+
+		iptablesLatency = &pollers.IPTablesLatency{
+			Logger:        logger,
+			PollInterval:  pollInterval,
+			LastRuleCount: lastRuleCount,
+			LatencyProber: latencyProber,
+		}
+	})
+
+	It("measures the latencies once within a single interval, using the last rule count", func() {
+		runTest(iptablesLatency, pollInterval)
+
+		Expect(latencyProber.CommandLatencyCallCount()).To(Equal(1))
+		Expect(latencyProber.RoundTripLatencyCallCount()).To(Equal(1))
+
+		Expect(logger.LogMessages()).To(Equal([]string{
+			"test.measure-iptables-latency.measure-start",
+			"test.measure-iptables-latency.metric-sent",
+			"test.measure-iptables-latency.metric-sent",
+			"test.measure-iptables-latency.metric-sent",
+			"test.measure-iptables-latency.measure-complete",
+		}))
+		Expect(logger.Logs()[1].Data["IPTablesCommandLatency"]).To(Equal("30ms"))
+		Expect(logger.Logs()[2].Data["IPTablesRuleCount"]).To(Equal(float64(2000)))
+		Expect(logger.Logs()[2].Data["IPTablesCommandLatencyPerThousandRules"]).To(Equal("15ms"))
+		Expect(logger.Logs()[3].Data["IPTablesRoundTripLatency"]).To(Equal("200µs"))
+	})
+
+	Context("when the rules have not been counted yet", func() {
+		BeforeEach(func() {
+			iptablesLatency.LastRuleCount = &network_stats.LastCount{}
+		})
+
+		It("skips the latency per thousand rules", func() {
+			runTest(iptablesLatency, pollInterval)
+
+			for _, log := range logger.Logs() {
+				Expect(log.Data).NotTo(HaveKey("IPTablesCommandLatencyPerThousandRules"))
+			}
+			Expect(latencyProber.RoundTripLatencyCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when timing the iptables command fails", func() {
+		BeforeEach(func() {
+			latencyProber.CommandLatencyReturns(0, errors.New("banana"))
+		})
+
+		It("logs the error", func() {
+			runTest(iptablesLatency, pollInterval)
+
+			Expect(logger.LogMessages()).To(ContainElement("test.measure-iptables-latency.command-latency"))
+			Expect(latencyProber.RoundTripLatencyCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when there is no container to probe", func() {
+		BeforeEach(func() {
+			latencyProber.RoundTripLatencyReturns(0, network_stats.ErrNoProbeTarget)
+		})
+
+		It("still sends the command latency and skips the round trip", func() {
+			runTest(iptablesLatency, pollInterval)
+
+			Expect(logger.LogMessages()).To(ContainElement("test.measure-iptables-latency.round-trip-latency-skipped"))
+			Expect(logger.LogMessages()).NotTo(ContainElement("test.measure-iptables-latency.round-trip-latency"))
+			Expect(logger.Logs()[1].Data["IPTablesCommandLatency"]).To(Equal("30ms"))
+		})
+	})
+
+	Context("when timing the round trip fails", func() {
+		BeforeEach(func() {
+			latencyProber.RoundTripLatencyReturns(0, errors.New("banana"))
+		})
+
+		It("logs the error", func() {
+			runTest(iptablesLatency, pollInterval)
+
+			Expect(logger.LogMessages()).To(ContainElement("test.measure-iptables-latency.round-trip-latency"))
+			for _, log := range logger.Logs() {
+				Expect(log.Data).NotTo(HaveKey("IPTablesRoundTripLatency"))
+			}
+		})
+	})
+})
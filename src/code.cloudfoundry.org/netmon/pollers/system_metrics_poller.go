@@ -28,6 +28,7 @@ type SystemMetrics struct {
 	InterfaceName       string
 	NetworkStatsFetcher network_stats.Fetcher
 	RuleCountAggregator *network_stats.IntAggregator
+	LastRuleCount       *network_stats.LastCount
 }
 
 func (m *SystemMetrics) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
@@ -89,6 +90,9 @@ func (m *SystemMetrics) measure(logger lager.Logger) {
 	}
 
 	m.RuleCountAggregator.UpdateStats(nIpTablesRule)
+	if m.LastRuleCount != nil {
+		m.LastRuleCount.Set(nIpTablesRule)
+	}
 
 	if err := iptablesRuleCount.Send(nIpTablesRule); err != nil {
 		logger.Error("failed-to-send-metric", err, lager.Data{
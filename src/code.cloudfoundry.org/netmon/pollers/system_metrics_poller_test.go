@@ -73,6 +73,19 @@ var _ = Describe("Poller Run", func() {
 		Expect(metrics.RuleCountAggregator.Average).To(Equal(4))
 		Expect(metrics.RuleCountAggregator.Minimum).To(Equal(2))
 	})
+
+	It("hands the last rule count to the other pollers", func() {
+		metrics.LastRuleCount = &network_stats.LastCount{}
+		_, counted := metrics.LastRuleCount.Get()
+		Expect(counted).To(BeFalse())
+
+		runTest(metrics, pollInterval)
+		runTest(metrics, pollInterval)
+
+		count, counted := metrics.LastRuleCount.Get()
+		Expect(counted).To(BeTrue())
+		Expect(count).To(Equal(2))
+	})
 })
 
 type poller interface {
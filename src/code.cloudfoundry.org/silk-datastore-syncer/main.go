@@ -103,15 +103,14 @@ func main() {
 			}
 
 			logger.Debug("Datastore container reconciling with Garden container", lager.Data{"handle": sc.Handle})
-			b, err := json.Marshal(desiredLogConfig)
+			if sc.Metadata == nil {
+				sc.Metadata = make(map[string]interface{})
+			}
+			err = datastore.SetLogConfig(sc.Metadata, datastore.LogConfig(desiredLogConfig))
 			if err != nil {
 				logger.Error("Garden container error marshalling container log config", err, lager.Data{"handle": sc.Handle})
 				continue
 			}
-			if sc.Metadata == nil {
-				sc.Metadata = make(map[string]interface{})
-			}
-			sc.Metadata["log_config"] = string(b)
 			err = store.Update(sc.Handle, sc.IP, sc.Metadata)
 			if err != nil {
 				logger.Error("Error updating log config", err)
@@ -162,7 +161,7 @@ func getGardenLogConfig(c garden.Container) (executor.LogConfig, error) {
 		err = fmt.Errorf("Garden container: %s: error retrieving properties: %w", c.Handle(), err)
 		return executor.LogConfig{}, err
 	}
-	logConfigStr, ok := props["log_config"]
+	logConfigStr, ok := props[datastore.MetadataKeyLogConfig]
 	var desiredLogConfig executor.LogConfig
 	if ok {
 		err := json.Unmarshal([]byte(logConfigStr), &desiredLogConfig)
@@ -175,14 +174,10 @@ func getGardenLogConfig(c garden.Container) (executor.LogConfig, error) {
 }
 
 func getSilkLogConfig(sc datastore.Container) (executor.LogConfig, error) {
-	var actualLogConfig executor.LogConfig
-	logConfigStr, ok := sc.Metadata["log_config"].(string)
-	if ok {
-		err := json.Unmarshal([]byte(logConfigStr), &actualLogConfig)
-		if err != nil {
-			err = fmt.Errorf("Datastore container: %s: error unmarshalling container log config from datastore: %w", sc.Handle, err)
-			return executor.LogConfig{}, err
-		}
+	metadata, err := datastore.ParseContainerMetadata(sc.Metadata)
+	if err != nil {
+		err = fmt.Errorf("Datastore container: %s: error unmarshalling container log config from datastore: %w", sc.Handle, err)
+		return executor.LogConfig{}, err
 	}
-	return actualLogConfig, nil
+	return executor.LogConfig(metadata.LogConfig), nil
 }
@@ -1,50 +1,234 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"math"
+	"net"
+	"os"
 
+	libconfig "code.cloudfoundry.org/lib/config"
+	"code.cloudfoundry.org/silk/daemon/sysctls"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	"gopkg.in/validator.v2"
 )
 
 type Config struct {
-	UnderlayIP                string `json:"underlay_ip" validate:"nonzero"`
-	VxlanInterfaceName        string `json:"vxlan_interface_name"`
-	SubnetPrefixLength        int    `json:"subnet_prefix_length" validate:"nonzero"`
-	OverlayNetwork            string `json:"overlay_network" validate:"nonzero"`
-	HealthCheckPort           uint16 `json:"health_check_port" validate:"nonzero"`
-	VTEPName                  string `json:"vtep_name" validate:"nonzero"`
-	ConnectivityServerURL     string `json:"connectivity_server_url" validate:"nonzero"`
-	ServerCACertFile          string `json:"ca_cert_file" validate:"nonzero"`
-	ClientCertFile            string `json:"client_cert_file" validate:"nonzero"`
-	ClientKeyFile             string `json:"client_key_file" validate:"nonzero"`
-	VNI                       int    `json:"vni" validate:"nonzero"`
-	VTEPPort                  int    `json:"vtep_port" validate:"min=1"`
-	PollInterval              int    `json:"poll_interval" validate:"nonzero"`
-	DebugServerPort           int    `json:"debug_server_port" validate:"nonzero"`
-	Datastore                 string `json:"datastore" validate:"nonzero"`
-	PartitionToleranceSeconds int    `json:"partition_tolerance_seconds" validate:"nonzero"`
-	ClientTimeoutSeconds      int    `json:"client_timeout_seconds" validate:"nonzero"`
-	MetronPort                int    `json:"metron_port" validate:"min=1"`
-	LogPrefix                 string `json:"log_prefix" validate:"nonzero"`
-	LogLevel                  string `json:"log_level"`
-	SingleIPOnly              bool   `json:"single_ip_only"`
+	UnderlayIP                string   `json:"underlay_ip" validate:"nonzero"`
+	SecondaryUnderlayIP       string   `json:"secondary_underlay_ip"`
+	VxlanInterfaceName        string   `json:"vxlan_interface_name"`
+	SubnetPrefixLength        int      `json:"subnet_prefix_length" validate:"nonzero"`
+	OverlayNetwork            string   `json:"overlay_network" validate:"nonzero"`
+	HealthCheckPort           uint16   `json:"health_check_port" validate:"nonzero"`
+	VTEPName                  string   `json:"vtep_name" validate:"nonzero"`
+	ConnectivityServerURL     string   `json:"connectivity_server_url" validate:"nonzero"`
+	ServerCACertFile          string   `json:"ca_cert_file" validate:"nonzero"`
+	ClientCertFile            string   `json:"client_cert_file" validate:"nonzero"`
+	ClientKeyFile             string   `json:"client_key_file" validate:"nonzero"`
+	ControllerSPIFFEIDs       []string `json:"controller_spiffe_ids"`
+	VNI                       int      `json:"vni" validate:"nonzero"`
+	VTEPPort                  int      `json:"vtep_port" validate:"min=1"`
+	PollInterval              int      `json:"poll_interval" validate:"nonzero"`
+	DebugServerPort           int      `json:"debug_server_port" validate:"nonzero"`
+	Datastore                 string   `json:"datastore" validate:"nonzero"`
+	PartitionToleranceSeconds int      `json:"partition_tolerance_seconds" validate:"nonzero"`
+	ClientTimeoutSeconds      int      `json:"client_timeout_seconds" validate:"nonzero"`
+	MetronPort                int      `json:"metron_port" validate:"min=1"`
+	LogPrefix                 string   `json:"log_prefix" validate:"nonzero"`
+	LogLevel                  string   `json:"log_level"`
+	SingleIPOnly              bool     `json:"single_ip_only"`
+	SelfTestPort              int      `json:"self_test_port"`
+	SelfTestCACertFile        string   `json:"self_test_ca_cert_file"`
+	SelfTestServerCertFile    string   `json:"self_test_server_cert_file"`
+	SelfTestServerKeyFile     string   `json:"self_test_server_key_file"`
+	EnableEgressGateways      bool     `json:"enable_egress_gateways"`
+	ContainerMetadataFile     string   `json:"container_metadata_file"`
+	IPTablesLockFile          string   `json:"iptables_lock_file"`
+	EncapsulatedTTL           int      `json:"encapsulated_ttl" validate:"min=0,max=255"`
+	ContainerEgressTTL        int      `json:"container_egress_ttl" validate:"min=0,max=255"`
+
+	VTEPReversePathFilter rpfilter.Mode `json:"vtep_reverse_path_filter"`
+	BGP                   BGP           `json:"bgp"`
+
+	// Sysctls are enforced in addition to the ones silk always requires.
+	Sysctls   []sysctls.Setting `json:"sysctls"`
+	Conntrack Conntrack         `json:"conntrack"`
+
+	UnderlayHealth UnderlayHealth `json:"underlay_health"`
+	OverlayRouting OverlayRouting `json:"overlay_routing"`
+}
+
+// OverlayRouting places the routes to the subnets of the other cells. With a
+// Table, they are installed in that routing table and an ip rule with
+// RulePriority looks the overlay network up in it; without one they are
+// installed in the main table. Metric is the metric of the routes.
+type OverlayRouting struct {
+	Table        int `json:"table"`
+	Metric       int `json:"metric"`
+	RulePriority int `json:"rule_priority"`
+}
+
+func (o OverlayRouting) Validate() error {
+	if o.Table < 0 || o.Metric < 0 {
+		return errors.New("overlay_routing table and metric must not be negative")
+	}
+	if o.Table > math.MaxUint32 {
+		return fmt.Errorf("overlay_routing table %d is not a routing table", o.Table)
+	}
+	if o.Table >= 253 && o.Table <= 255 {
+		return fmt.Errorf("overlay_routing table %d is reserved by the kernel", o.Table)
+	}
+	if o.Table != 0 && (o.RulePriority < 1 || o.RulePriority > 32765) {
+		return errors.New("overlay_routing rule_priority must be between 1 and 32765 to use a table")
+	}
+	return nil
+}
+
+// UnderlayHealth gates the renewal of the lease, and with it the announcement
+// of the subnet over BGP, on probes of the underlay of the cell. It is enabled
+// when it checks the default gateway or the carrier of the VTEP.
+type UnderlayHealth struct {
+	CheckDefaultGateway     bool `json:"check_default_gateway"`
+	CheckVTEPCarrier        bool `json:"check_vtep_carrier"`
+	PingTimeoutMilliseconds int  `json:"ping_timeout_ms"`
+}
+
+func (u UnderlayHealth) Enabled() bool {
+	return u.CheckDefaultGateway || u.CheckVTEPCarrier
+}
+
+func (u UnderlayHealth) Validate() error {
+	if u.CheckDefaultGateway && u.PingTimeoutMilliseconds < 1 {
+		return errors.New("underlay_health ping_timeout_ms must be at least 1 to check the default gateway")
+	}
+	return nil
+}
+
+// Conntrack configures the sizing of the connection tracking table. When
+// Autotune is set, the table is sized from the memory of the cell and the
+// containers it is expected to run; ExpectedContainers of 0 stands for the
+// number of addresses in the subnet of the cell.
+type Conntrack struct {
+	Autotune               bool    `json:"autotune"`
+	EntriesPerGiB          int     `json:"entries_per_gib_memory"`
+	EntriesPerContainer    int     `json:"entries_per_container"`
+	ExpectedContainers     int     `json:"expected_containers"`
+	EntriesPerBucket       int     `json:"entries_per_bucket"`
+	HighUtilizationPercent float64 `json:"high_utilization_percent"`
+}
+
+func (c Conntrack) Validate() error {
+	if c.HighUtilizationPercent < 0 || c.HighUtilizationPercent > 100 {
+		return errors.New("conntrack high_utilization_percent must be between 0 and 100")
+	}
+	if !c.Autotune {
+		return nil
+	}
+	if c.EntriesPerGiB < 0 || c.EntriesPerContainer < 0 || c.ExpectedContainers < 0 {
+		return errors.New("conntrack entries and containers must not be negative")
+	}
+	if c.EntriesPerGiB == 0 && c.EntriesPerContainer == 0 {
+		return errors.New("conntrack entries_per_gib_memory or entries_per_container must be set to autotune")
+	}
+	if c.EntriesPerBucket < 1 {
+		return errors.New("conntrack entries_per_bucket must be at least 1")
+	}
+	return nil
+}
+
+// BGP configures the announcement of the subnet of the cell to the routers
+// of the underlay in no-overlay mode. It is enabled when it has peers.
+type BGP struct {
+	LocalAS              uint32    `json:"local_as"`
+	HoldTimeSeconds      int       `json:"hold_time_seconds"`
+	HealthTimeoutSeconds int       `json:"health_timeout_seconds"`
+	Peers                []BGPPeer `json:"peers"`
+}
+
+type BGPPeer struct {
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	AS      uint32 `json:"as"`
+}
+
+func (b BGP) Enabled() bool {
+	return len(b.Peers) > 0
+}
+
+func (b BGP) Validate() error {
+	if !b.Enabled() {
+		return nil
+	}
+	if b.LocalAS == 0 {
+		return errors.New("bgp local_as must be set")
+	}
+	if b.HoldTimeSeconds < 3 || b.HoldTimeSeconds > 65535 {
+		return errors.New("bgp hold_time_seconds must be between 3 and 65535")
+	}
+	if b.HealthTimeoutSeconds < 0 {
+		return errors.New("bgp health_timeout_seconds must not be negative")
+	}
+	for _, peer := range b.Peers {
+		if net.ParseIP(peer.Address).To4() == nil {
+			return fmt.Errorf("bgp peer address %q is not an IPv4 address", peer.Address)
+		}
+		if peer.AS == 0 {
+			return fmt.Errorf("bgp peer %s must have an as", peer.Address)
+		}
+		if peer.Port < 0 || peer.Port > 65535 {
+			return fmt.Errorf("bgp peer %s has invalid port %d", peer.Address, peer.Port)
+		}
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	if err := validator.Validate(c); err != nil {
+		return err
+	}
+	if err := c.VTEPReversePathFilter.Validate(); err != nil {
+		return err
+	}
+	if err := c.BGP.Validate(); err != nil {
+		return err
+	}
+	if err := c.Conntrack.Validate(); err != nil {
+		return err
+	}
+	if err := c.UnderlayHealth.Validate(); err != nil {
+		return err
+	}
+	if err := c.OverlayRouting.Validate(); err != nil {
+		return err
+	}
+	if c.SecondaryUnderlayIP != "" {
+		if net.ParseIP(c.SecondaryUnderlayIP).To4() == nil {
+			return fmt.Errorf("secondary_underlay_ip %q is not an IPv4 address", c.SecondaryUnderlayIP)
+		}
+		if c.SecondaryUnderlayIP == c.UnderlayIP {
+			return errors.New("secondary_underlay_ip must differ from underlay_ip")
+		}
+	}
+	for _, setting := range c.Sysctls {
+		if err := setting.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func LoadConfig(filePath string) (Config, error) {
 	var cfg Config
-	contents, err := ioutil.ReadFile(filePath)
+	contents, err := os.ReadFile(filePath)
 	if err != nil {
 		return cfg, fmt.Errorf("reading file %s: %s", filePath, err)
 	}
 
-	err = json.Unmarshal(contents, &cfg)
-	if err != nil {
+	if err := libconfig.Decode(contents, &cfg); err != nil {
 		return cfg, fmt.Errorf("unmarshaling contents: %s", err)
 	}
 
-	if err := validator.Validate(cfg); err != nil {
+	if err := libconfig.Check(&cfg); err != nil {
 		return cfg, fmt.Errorf("invalid config: %s", err)
 	}
 	return cfg, nil
@@ -7,6 +7,8 @@ import (
 	"os"
 
 	"code.cloudfoundry.org/silk/client/config"
+	"code.cloudfoundry.org/silk/daemon/sysctls"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -76,6 +78,32 @@ var _ = Describe("Config.LoadConfig", func() {
 		}
 	})
 
+	It("errors on a field it does not know", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["vtep_nmae"] = "silk-vtep"
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		_, err = config.LoadConfig(file.Name())
+		Expect(err).To(MatchError(`unmarshaling contents: json: unknown field "vtep_nmae"`))
+	})
+
+	It("reads the spiffe ids of the controller", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["controller_spiffe_ids"] = []string{"spiffe://example.org/silk-controller"}
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		loaded, err := config.LoadConfig(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.ControllerSPIFFEIDs).To(Equal([]string{"spiffe://example.org/silk-controller"}))
+	})
+
 	Context("when single ip only is specified", func() {
 		It("sets SingleIPOnly", func() {
 			cfg := cloneMap(requiredFields)
@@ -107,4 +135,384 @@ var _ = Describe("Config.LoadConfig", func() {
 			Expect(loadedConfig.VxlanInterfaceName).To(Equal("something"))
 		})
 	})
+
+	Context("when the self test server is specified", func() {
+		It("sets the self test fields", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["self_test_port"] = 4104
+			cfg["self_test_ca_cert_file"] = "/some/self-test/ca.pem"
+			cfg["self_test_server_cert_file"] = "/some/self-test/server.pem"
+			cfg["self_test_server_key_file"] = "/some/self-test/server.key"
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.SelfTestPort).To(Equal(4104))
+			Expect(loadedConfig.SelfTestCACertFile).To(Equal("/some/self-test/ca.pem"))
+			Expect(loadedConfig.SelfTestServerCertFile).To(Equal("/some/self-test/server.pem"))
+			Expect(loadedConfig.SelfTestServerKeyFile).To(Equal("/some/self-test/server.key"))
+		})
+	})
+
+	Context("when egress gateways are enabled", func() {
+		It("sets the egress gateway fields", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["enable_egress_gateways"] = true
+			cfg["container_metadata_file"] = "/some/container-metadata/store.json"
+			cfg["iptables_lock_file"] = "/some/iptables.lock"
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.EnableEgressGateways).To(BeTrue())
+			Expect(loadedConfig.ContainerMetadataFile).To(Equal("/some/container-metadata/store.json"))
+			Expect(loadedConfig.IPTablesLockFile).To(Equal("/some/iptables.lock"))
+		})
+	})
+
+	Context("when the reverse path filter of the VTEP is set", func() {
+		It("sets the mode", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["vtep_reverse_path_filter"] = "strict"
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.VTEPReversePathFilter).To(Equal(rpfilter.Strict))
+		})
+
+		It("errors if the mode is invalid", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["vtep_reverse_path_filter"] = "2"
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError(`invalid config: invalid reverse path filter "2": must be one of off, strict or loose`))
+		})
+	})
+
+	Context("when the TTLs are set", func() {
+		It("sets the TTL fields", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["encapsulated_ttl"] = 1
+			cfg["container_egress_ttl"] = 64
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.EncapsulatedTTL).To(Equal(1))
+			Expect(loadedConfig.ContainerEgressTTL).To(Equal(64))
+		})
+
+		It("errors if a TTL is out of range", func() {
+			for _, fieldName := range []string{"encapsulated_ttl", "container_egress_ttl"} {
+				cfg := cloneMap(requiredFields)
+				cfg[fieldName] = 256
+
+				file, err := ioutil.TempFile(os.TempDir(), "config-")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+				By(fmt.Sprintf("checking that %s is limited", fieldName))
+				_, err = config.LoadConfig(file.Name())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("invalid config:"))
+			}
+		})
+	})
+
+	Context("when BGP peers are set", func() {
+		var cfg map[string]interface{}
+
+		BeforeEach(func() {
+			cfg = cloneMap(requiredFields)
+			cfg["bgp"] = map[string]interface{}{
+				"local_as":          65001,
+				"hold_time_seconds": 90,
+				"peers": []map[string]interface{}{
+					{"address": "10.0.0.254", "as": 65000},
+				},
+			}
+		})
+
+		It("enables BGP", func() {
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.BGP.Enabled()).To(BeTrue())
+			Expect(loadedConfig.BGP.LocalAS).To(Equal(uint32(65001)))
+			Expect(loadedConfig.BGP.Peers).To(Equal([]config.BGPPeer{{Address: "10.0.0.254", AS: 65000}}))
+		})
+
+		It("errors if a peer address is not an IPv4 address", func() {
+			cfg["bgp"].(map[string]interface{})["peers"] = []map[string]interface{}{
+				{"address": "fd00::1", "as": 65000},
+			}
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError(`invalid config: bgp peer address "fd00::1" is not an IPv4 address`))
+		})
+
+		It("errors if the local AS is missing", func() {
+			delete(cfg["bgp"].(map[string]interface{}), "local_as")
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError("invalid config: bgp local_as must be set"))
+		})
+	})
+
+	Context("when conntrack autotuning is enabled", func() {
+		var cfg map[string]interface{}
+
+		BeforeEach(func() {
+			cfg = cloneMap(requiredFields)
+			cfg["conntrack"] = map[string]interface{}{
+				"autotune":                 true,
+				"entries_per_gib_memory":   16384,
+				"entries_per_container":    1024,
+				"entries_per_bucket":       4,
+				"high_utilization_percent": 90,
+			}
+		})
+
+		It("sets the conntrack fields", func() {
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.Conntrack).To(Equal(config.Conntrack{
+				Autotune:               true,
+				EntriesPerGiB:          16384,
+				EntriesPerContainer:    1024,
+				EntriesPerBucket:       4,
+				HighUtilizationPercent: 90,
+			}))
+		})
+
+		It("errors if there are no entries per bucket", func() {
+			cfg["conntrack"].(map[string]interface{})["entries_per_bucket"] = 0
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError("invalid config: conntrack entries_per_bucket must be at least 1"))
+		})
+
+		It("errors if nothing sizes the table", func() {
+			cfg["conntrack"].(map[string]interface{})["entries_per_gib_memory"] = 0
+			cfg["conntrack"].(map[string]interface{})["entries_per_container"] = 0
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError("invalid config: conntrack entries_per_gib_memory or entries_per_container must be set to autotune"))
+		})
+	})
+
+	Context("when the lease renewal is gated on the underlay health", func() {
+		var cfg map[string]interface{}
+
+		BeforeEach(func() {
+			cfg = cloneMap(requiredFields)
+			cfg["underlay_health"] = map[string]interface{}{
+				"check_default_gateway": true,
+				"check_vtep_carrier":    true,
+				"ping_timeout_ms":       500,
+			}
+		})
+
+		It("sets the underlay health fields", func() {
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.UnderlayHealth).To(Equal(config.UnderlayHealth{
+				CheckDefaultGateway:     true,
+				CheckVTEPCarrier:        true,
+				PingTimeoutMilliseconds: 500,
+			}))
+			Expect(loadedConfig.UnderlayHealth.Enabled()).To(BeTrue())
+		})
+
+		It("errors if the default gateway is checked without a ping timeout", func() {
+			cfg["underlay_health"].(map[string]interface{})["ping_timeout_ms"] = 0
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError("invalid config: underlay_health ping_timeout_ms must be at least 1 to check the default gateway"))
+		})
+	})
+
+	Context("when a secondary underlay ip is set", func() {
+		var cfg map[string]interface{}
+
+		BeforeEach(func() {
+			cfg = cloneMap(requiredFields)
+			cfg["secondary_underlay_ip"] = "10.0.1.4"
+		})
+
+		It("sets it", func() {
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.SecondaryUnderlayIP).To(Equal("10.0.1.4"))
+		})
+
+		DescribeTable("errors on invalid addresses",
+			func(secondaryUnderlayIP string, expectedErr string) {
+				cfg["secondary_underlay_ip"] = secondaryUnderlayIP
+
+				file, err := ioutil.TempFile(os.TempDir(), "config-")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+				_, err = config.LoadConfig(file.Name())
+				Expect(err).To(MatchError(expectedErr))
+			},
+			Entry("not an ip", "banana", `invalid config: secondary_underlay_ip "banana" is not an IPv4 address`),
+			Entry("the underlay ip", "1.2.3.4", "invalid config: secondary_underlay_ip must differ from underlay_ip"),
+		)
+	})
+
+	Context("when the overlay routes are installed in a table", func() {
+		var cfg map[string]interface{}
+
+		BeforeEach(func() {
+			cfg = cloneMap(requiredFields)
+			cfg["overlay_routing"] = map[string]interface{}{
+				"table":         300,
+				"metric":        50,
+				"rule_priority": 900,
+			}
+		})
+
+		It("sets the overlay routing fields", func() {
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.OverlayRouting).To(Equal(config.OverlayRouting{
+				Table:        300,
+				Metric:       50,
+				RulePriority: 900,
+			}))
+		})
+
+		DescribeTable("errors on invalid settings",
+			func(field string, value int, expectedErr string) {
+				cfg["overlay_routing"].(map[string]interface{})[field] = value
+
+				file, err := ioutil.TempFile(os.TempDir(), "config-")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+				_, err = config.LoadConfig(file.Name())
+				Expect(err).To(MatchError(expectedErr))
+			},
+			Entry("negative metric", "metric", -1, "invalid config: overlay_routing table and metric must not be negative"),
+			Entry("main table", "table", 254, "invalid config: overlay_routing table 254 is reserved by the kernel"),
+			Entry("no rule priority", "rule_priority", 0, "invalid config: overlay_routing rule_priority must be between 1 and 32765 to use a table"),
+			Entry("rule priority after the main table", "rule_priority", 32766, "invalid config: overlay_routing rule_priority must be between 1 and 32765 to use a table"),
+		)
+	})
+
+	Context("when sysctls are set", func() {
+		var cfg map[string]interface{}
+
+		BeforeEach(func() {
+			cfg = cloneMap(requiredFields)
+			cfg["sysctls"] = []map[string]interface{}{
+				{"name": "net.netfilter.nf_conntrack_max", "value": "262144", "minimum": true},
+				{"name": "net.bridge.bridge-nf-call-iptables", "value": "1", "optional": true},
+			}
+		})
+
+		It("sets the sysctls", func() {
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			loadedConfig, err := config.LoadConfig(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedConfig.Sysctls).To(Equal([]sysctls.Setting{
+				{Name: "net.netfilter.nf_conntrack_max", Value: "262144", Minimum: true},
+				{Name: "net.bridge.bridge-nf-call-iptables", Value: "1", Optional: true},
+			}))
+		})
+
+		It("errors if a sysctl is invalid", func() {
+			cfg["sysctls"] = []map[string]interface{}{
+				{"name": "../../etc/passwd", "value": "1"},
+			}
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			_, err = config.LoadConfig(file.Name())
+			Expect(err).To(MatchError(`invalid config: invalid sysctl name "../../etc/passwd"`))
+		})
+	})
 })
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/db"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller/config"
+	"code.cloudfoundry.org/silk/controller/database"
+	"code.cloudfoundry.org/silk/controller/leaseadmin"
+)
+
+const jobPrefix = "silk-admin"
+
+const usage = "usage: silk-admin [-config <path>] [-audit-log <path>] [-force] leases (list | inspect <underlay-ip> | revoke <underlay-ip> | extend <underlay-ip>)"
+
+func main() {
+	if err := mainWithError(); err != nil {
+		log.Fatalf("%s error: %s", jobPrefix, err)
+	}
+}
+
+func mainWithError() error {
+	configFilePath := flag.String("config", "/var/vcap/jobs/silk-controller/config/silk-controller.json", "path to silk controller config file")
+	auditLogPath := flag.String("audit-log", "/var/vcap/sys/log/silk-controller/silk-admin-audit.log", "path to audit log")
+	force := flag.Bool("force", false, "do not ask for confirmation")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 || args[0] != "leases" {
+		return errors.New(usage)
+	}
+
+	conf, err := config.ReadFromFile(*configFilePath)
+	if err != nil {
+		return fmt.Errorf("load config: %s", err)
+	}
+
+	auditLog, err := os.OpenFile(*auditLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %s", err)
+	}
+	defer auditLog.Close()
+	auditLogger := lager.NewLogger(fmt.Sprintf("%s.%s", conf.LogPrefix, jobPrefix))
+	auditLogger.RegisterSink(lager.NewWriterSink(auditLog, lager.INFO))
+
+	connectionPool, err := db.NewConnectionPool(
+		conf.Database,
+		1,
+		1,
+		time.Duration(conf.MaxConnectionsLifetimeSeconds)*time.Second,
+		conf.LogPrefix,
+		jobPrefix,
+		lager.NewLogger(jobPrefix),
+	)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %s", err)
+	}
+	defer connectionPool.Close()
+
+	operator := os.Getenv("SUDO_USER")
+	if operator == "" {
+		operator = os.Getenv("USER")
+	}
+
+	admin := &leaseadmin.LeaseAdmin{
+		Store:                  database.NewDatabaseHandler(&database.MigrateAdapter{}, connectionPool),
+		LeaseExpirationSeconds: conf.LeaseExpirationSeconds,
+		In:                     os.Stdin,
+		Out:                    os.Stdout,
+		AuditLogger:            auditLogger,
+		Operator:               operator,
+		Force:                  *force,
+	}
+
+	return admin.Run(args[1:])
+}
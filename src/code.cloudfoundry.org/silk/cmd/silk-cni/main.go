@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -21,7 +22,9 @@ import (
 	"code.cloudfoundry.org/silk/cni/netinfo"
 	"code.cloudfoundry.org/silk/daemon"
 	libAdapter "code.cloudfoundry.org/silk/lib/adapter"
+	"code.cloudfoundry.org/silk/lib/announce"
 	"code.cloudfoundry.org/silk/lib/datastore"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	"code.cloudfoundry.org/silk/lib/serial"
 	"github.com/containernetworking/cni/pkg/invoke"
 	"github.com/containernetworking/cni/pkg/skel"
@@ -37,8 +40,12 @@ type CNIPlugin struct {
 	HostNS          ns.NetNS
 	ConfigCreator   *config.ConfigCreator
 	VethPairCreator *lib.VethPairCreator
+	UserNamespaces  *lib.UserNamespaces
+	LinkOperations  *lib.LinkOperations
 	Host            *lib.Host
 	Container       *lib.Container
+	FlatRoutes      *lib.FlatRoutes
+	DelegatedIPAM   *lib.DelegatedIPAM
 	Store           *datastore.Store
 	Logger          lager.Logger
 }
@@ -46,6 +53,10 @@ type CNIPlugin struct {
 const (
 	jobPrefix = "silk-cni"
 	logPrefix = "cfnetworking"
+
+	// ipv6MetadataKey keeps the IPv6 address of a container in flat mode,
+	// whose proxy neighbor and route are removed on delete.
+	ipv6MetadataKey = "ipv6"
 )
 
 // used as a compile-time flag to disable logging during integration tests
@@ -79,9 +90,10 @@ func main() {
 
 	netlinkAdapter := &libAdapter.NetlinkAdapter{}
 	linkOperations := &lib.LinkOperations{
-		SysctlAdapter:  &adapter.SysctlAdapter{},
-		NetlinkAdapter: netlinkAdapter,
-		Logger:         logger,
+		SysctlAdapter:     &adapter.SysctlAdapter{},
+		NetlinkAdapter:    netlinkAdapter,
+		NeighborAnnouncer: &announce.Announcer{},
+		Logger:            logger,
 	}
 	commonSetup := &lib.Common{
 		NetlinkAdapter: netlinkAdapter,
@@ -106,6 +118,11 @@ func main() {
 			NetlinkAdapter: netlinkAdapter,
 			Logger:         logger.Session("veth-pair-creator"),
 		},
+		UserNamespaces: &lib.UserNamespaces{
+			Adapter: &adapter.UserNamespaceAdapter{},
+			Logger:  logger.Session("user-namespaces"),
+		},
+		LinkOperations: linkOperations,
 		Host: &lib.Host{
 			Common:         commonSetup,
 			LinkOperations: linkOperations,
@@ -116,6 +133,16 @@ func main() {
 			LinkOperations: linkOperations,
 			Logger:         logger.Session("container-setup"),
 		},
+		FlatRoutes: &lib.FlatRoutes{
+			NeighborAdapter: netlinkAdapter,
+			SysctlAdapter:   &adapter.SysctlAdapter{},
+			CommandRunner:   &adapter.CommandRunner{},
+			Logger:          logger.Session("flat-routes"),
+		},
+		DelegatedIPAM: &lib.DelegatedIPAM{
+			Invoker: &adapter.IPAMInvoker{},
+			Logger:  logger.Session("delegated-ipam"),
+		},
 		Logger: logger,
 		Store:  store,
 	}
@@ -130,6 +157,28 @@ type NetConf struct {
 	MTU        int    `json:"mtu" validate:"min=0"`
 	Datastore  string `json:"datastore"`
 	DaemonPort int    `json:"daemonPort"`
+
+	ReversePathFilter struct {
+		Host      rpfilter.Mode `json:"host"`
+		Container rpfilter.Mode `json:"container"`
+	} `json:"reversePathFilter"`
+
+	// NeighborMode is how the ends of the veth pairs resolve each other, see
+	// config.NeighborMode.
+	NeighborMode config.NeighborMode `json:"neighborMode"`
+
+	// Flat is set to run without the VXLAN overlay, see lib.FlatConfig.
+	Flat *lib.FlatConfig `json:"flat"`
+
+	// DelegatedIPAM is set to assign the addresses with another IPAM plugin
+	// than host-local, see lib.DelegatedIPAM.
+	DelegatedIPAM *lib.DelegatedIPAMConfig `json:"delegatedIPAM"`
+
+	// PeerScopedAddressing hands out the addresses of the subnet that are
+	// otherwise kept for a gateway and a broadcast, see
+	// config.IPAMConfigGenerator.
+	PeerScopedAddressing bool   `json:"peerScopedAddressing"`
+	OverlayNetwork       string `json:"overlayNetwork"`
 }
 
 type HostLocalIPAM struct {
@@ -159,6 +208,19 @@ func getNetworkInfo(netConf NetConf) (daemon.NetworkInfo, error) {
 	if err != nil {
 		return daemon.NetworkInfo{}, fmt.Errorf("invalid config: %s", err)
 	}
+	for _, mode := range []rpfilter.Mode{netConf.ReversePathFilter.Host, netConf.ReversePathFilter.Container} {
+		if err := mode.Validate(); err != nil {
+			return daemon.NetworkInfo{}, fmt.Errorf("invalid config: %s", err)
+		}
+	}
+	if err := netConf.NeighborMode.Validate(); err != nil {
+		return daemon.NetworkInfo{}, fmt.Errorf("invalid config: %s", err)
+	}
+	if netConf.DelegatedIPAM != nil {
+		if err := netConf.DelegatedIPAM.Validate(netConf.Flat); err != nil {
+			return daemon.NetworkInfo{}, fmt.Errorf("invalid config: %s", err)
+		}
+	}
 
 	discoverer := netinfo.Discoverer{}
 	if netConf.SubnetFile != "" {
@@ -192,27 +254,9 @@ func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
 		return typedError("discover network info", err)
 	}
 
-	p.Logger.Debug("generate-ipam-config", lager.Data{"overlaySubnet": networkInfo.OverlaySubnet, "name": netConf.Name, "dataDir": netConf.DataDir})
-	generator := config.IPAMConfigGenerator{}
-	ipamConfig, err := generator.GenerateConfig(networkInfo.OverlaySubnet, netConf.Name, netConf.DataDir)
-	if err != nil {
-		p.Logger.Error("generate-ipam-config-failed", err)
-		return typedError("generate ipam config", err)
-	}
-	ipamConfigBytes, _ := json.Marshal(ipamConfig) // untestable
-
-	p.Logger.Debug("host-local-ipam", lager.Data{"action": "add", "ipamConfig": string(ipamConfigBytes)})
-	result, err := invoke.DelegateAdd(context.Background(), "host-local", ipamConfigBytes, nil)
-	if err != nil {
-		p.Logger.Error("host-local-ipam-failed", err)
-		return typedError("run ipam plugin", err)
-	}
-
-	p.Logger.Debug("convert-ipam-result", lager.Data{"result": result})
-	cniResult, err := current.NewResultFromResult(result)
+	cniResult, err := p.assignAddresses(args, netConf, networkInfo)
 	if err != nil {
-		p.Logger.Error("convert-ipam-result-failed", err)
-		return fmt.Errorf("convert result to current CNI version: %s", err) // not tested
+		return err
 	}
 
 	p.Logger.Debug("create-config", lager.Data{"hostNamespace": p.HostNS, "args": args, "result": cniResult, "mtu": networkInfo.MTU})
@@ -221,6 +265,20 @@ func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
 		p.Logger.Error("create-config-failed", err)
 		return typedError("create config", err)
 	}
+	cfg.Host.ReversePathFilter = netConf.ReversePathFilter.Host.Or(rpfilter.DefaultVeth)
+	cfg.Container.ReversePathFilter = netConf.ReversePathFilter.Container.Or(rpfilter.DefaultVeth)
+	cfg.Host.NeighborMode = netConf.NeighborMode
+	cfg.Container.NeighborMode = netConf.NeighborMode
+
+	userNamespaced, err := p.UserNamespaces.Detect(args.Netns)
+	if err != nil {
+		p.Logger.Error("detect-user-namespaces-failed", err)
+		return typedError("detect user namespaces", err)
+	}
+	if userNamespaced {
+		p.Logger.Info("user-namespaced", lager.Data{"netns": args.Netns})
+	}
+	p.LinkOperations.UserNamespaced = userNamespaced
 
 	p.Logger.Debug("create-veth-pair", lager.Data{"cfg": cfg})
 	err = p.VethPairCreator.Create(cfg)
@@ -243,9 +301,24 @@ func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
 		return typedError("set up container", err)
 	}
 
+	var metadata map[string]interface{}
+	if netConf.Flat != nil {
+		ips := []net.IP{cfg.Container.Address.IP}
+		if cfg.Container.IPv6Address != nil {
+			ips = append(ips, cfg.Container.IPv6Address)
+			metadata = map[string]interface{}{ipv6MetadataKey: cfg.Container.IPv6Address.String()}
+		}
+		p.Logger.Debug("add-flat-routes", lager.Data{"flat": netConf.Flat, "ips": ips})
+		err = p.FlatRoutes.Add(*netConf.Flat, ips...)
+		if err != nil {
+			p.Logger.Error("add-flat-routes-failed", err)
+			return typedError("add flat routes", err)
+		}
+	}
+
 	// use args.Netns as the 'handle' for now
 	p.Logger.Debug("write-container-metadata", lager.Data{"datastore": netConf.Datastore, "path": filepath.Base(args.Netns), "ip": cfg.Container.Address.IP.String()})
-	err = p.Store.Add(netConf.Datastore, filepath.Base(args.Netns), cfg.Container.Address.IP.String(), nil)
+	err = p.Store.Add(netConf.Datastore, filepath.Base(args.Netns), cfg.Container.Address.IP.String(), metadata)
 	if err != nil {
 		p.Logger.Error("write-container-metadata-failed", err)
 		return typedError("write container metadata", err)
@@ -259,6 +332,48 @@ func (p *CNIPlugin) cmdAdd(args *skel.CmdArgs) error {
 	return err
 }
 
+// assignAddresses gets the addresses of the container from host-local, in
+// the subnet of the cell, or from the delegated IPAM plugin.
+func (p *CNIPlugin) assignAddresses(args *skel.CmdArgs, netConf NetConf, networkInfo daemon.NetworkInfo) (*current.Result, error) {
+	if netConf.DelegatedIPAM != nil {
+		p.Logger.Debug("delegated-ipam", lager.Data{"action": "add", "type": netConf.DelegatedIPAM.Type})
+		cniResult, err := p.DelegatedIPAM.Add(*netConf.DelegatedIPAM, netConf.Flat, args, netConf.Name, netConf.CNIVersion)
+		if err != nil {
+			p.Logger.Error("delegated-ipam-failed", err)
+			return nil, typedError("run ipam plugin", err)
+		}
+		return cniResult, nil
+	}
+
+	p.Logger.Debug("generate-ipam-config", lager.Data{"overlaySubnet": networkInfo.OverlaySubnet, "overlayIPv6Subnet": networkInfo.OverlayIPv6Subnet, "name": netConf.Name, "dataDir": netConf.DataDir})
+	generator := config.IPAMConfigGenerator{
+		IPv6Subnet:     networkInfo.OverlayIPv6Subnet,
+		PeerScoped:     netConf.PeerScopedAddressing,
+		OverlayNetwork: netConf.OverlayNetwork,
+	}
+	ipamConfig, err := generator.GenerateConfig(networkInfo.OverlaySubnet, netConf.Name, netConf.DataDir)
+	if err != nil {
+		p.Logger.Error("generate-ipam-config-failed", err)
+		return nil, typedError("generate ipam config", err)
+	}
+	ipamConfigBytes, _ := json.Marshal(ipamConfig) // untestable
+
+	p.Logger.Debug("host-local-ipam", lager.Data{"action": "add", "ipamConfig": string(ipamConfigBytes)})
+	result, err := invoke.DelegateAdd(context.Background(), "host-local", ipamConfigBytes, nil)
+	if err != nil {
+		p.Logger.Error("host-local-ipam-failed", err)
+		return nil, typedError("run ipam plugin", err)
+	}
+
+	p.Logger.Debug("convert-ipam-result", lager.Data{"result": result})
+	cniResult, err := current.NewResultFromResult(result)
+	if err != nil {
+		p.Logger.Error("convert-ipam-result-failed", err)
+		return nil, fmt.Errorf("convert result to current CNI version: %s", err) // not tested
+	}
+	return cniResult, nil
+}
+
 func (p *CNIPlugin) cmdDel(args *skel.CmdArgs) error {
 	p.Logger = p.Logger.Session("plugin-del")
 
@@ -270,6 +385,69 @@ func (p *CNIPlugin) cmdDel(args *skel.CmdArgs) error {
 		return err // impossible, skel package asserts JSON is valid
 	}
 
+	p.releaseAddresses(args, netConf)
+
+	// the device is gone with the namespace, but what else the container
+	// left behind is still removed
+	var teardownErr error
+	p.Logger.Debug("open-netns", lager.Data{"namespace": args.Netns})
+	containerNS, err := ns.GetNS(args.Netns)
+	if err != nil {
+		p.Logger.Error("open-netns-failed", err)
+	} else {
+		p.Logger.Debug("teardown-container", lager.Data{"namespace": containerNS, "interface": args.IfName})
+		teardownErr = p.Container.Teardown(containerNS, args.IfName)
+		if teardownErr != nil {
+			p.Logger.Error("teardown-failed", teardownErr)
+		}
+	}
+
+	// the entry is deleted after the flat routes, so that a DEL that is
+	// retried after it was interrupted still knows their IPs
+	handle := filepath.Base(args.Netns)
+	if netConf.Flat != nil {
+		p.Logger.Debug("read-container-metadata", lager.Data{"datastore": netConf.Datastore, "path": handle})
+		containers, err := p.Store.ReadAll(netConf.Datastore)
+		if err != nil {
+			p.Logger.Error("read-container-metadata-failed", err)
+		}
+		if container, ok := containers[handle]; ok && container.IP != "" {
+			ips := []net.IP{net.ParseIP(container.IP)}
+			if ipv6, ok := container.Metadata[ipv6MetadataKey].(string); ok {
+				ips = append(ips, net.ParseIP(ipv6))
+			}
+			p.Logger.Debug("delete-flat-routes", lager.Data{"flat": netConf.Flat, "ips": ips})
+			err = p.FlatRoutes.Del(*netConf.Flat, ips...)
+			if err != nil {
+				p.Logger.Error("delete-flat-routes-failed", err)
+			}
+		}
+	}
+
+	p.Logger.Debug("delete-from-container-metadata", lager.Data{"datastore": netConf.Datastore, "path": handle})
+	_, err = p.Store.Delete(netConf.Datastore, handle)
+	if err != nil {
+		p.Logger.Error("delete-from-container-metadata-failed", err)
+	}
+
+	if teardownErr != nil {
+		return typedError("teardown failed", teardownErr)
+	}
+	return nil
+}
+
+// releaseAddresses releases the addresses of the container. A failure is
+// logged, and the rest of the container is still cleaned up.
+func (p *CNIPlugin) releaseAddresses(args *skel.CmdArgs, netConf NetConf) {
+	if netConf.DelegatedIPAM != nil {
+		p.Logger.Debug("delegated-ipam", lager.Data{"action": "delete", "type": netConf.DelegatedIPAM.Type})
+		err := p.DelegatedIPAM.Del(*netConf.DelegatedIPAM, netConf.Flat, args, netConf.Name, netConf.CNIVersion)
+		if err != nil {
+			p.Logger.Error("delegated-ipam-failed", err)
+		}
+		return
+	}
+
 	p.Logger.Debug("generate-ipam-config", lager.Data{"name": netConf.Name, "dataDir": netConf.DataDir})
 	generator := config.IPAMConfigGenerator{}
 	// use 0.0.0.0/0 for the IPAM subnet during delete so we don't need to discover the subnet.
@@ -288,28 +466,6 @@ func (p *CNIPlugin) cmdDel(args *skel.CmdArgs) error {
 		p.Logger.Error("host-local-ipam-failed", err)
 		// continue, keep trying to cleanup
 	}
-
-	p.Logger.Debug("open-netns", lager.Data{"namespace": args.Netns})
-	containerNS, err := ns.GetNS(args.Netns)
-	if err != nil {
-		p.Logger.Error("open-netns-failed", err)
-		return nil // can't do teardown if no netns
-	}
-
-	p.Logger.Debug("teardown-container", lager.Data{"namespace": containerNS, "interface": args.IfName})
-	err = p.Container.Teardown(containerNS, args.IfName)
-	if err != nil {
-		p.Logger.Error("teardown-failed", err)
-		return typedError("teardown failed", err)
-	}
-
-	p.Logger.Debug("delete-from-container-metadata", lager.Data{"datastore": netConf.Datastore, "path": filepath.Base(args.Netns)})
-	_, err = p.Store.Delete(netConf.Datastore, filepath.Base(args.Netns))
-	if err != nil {
-		p.Logger.Error("delete-from-container-metadata-failed", err)
-	}
-
-	return nil
 }
 
 func (p *CNIPlugin) cmdCheck(args *skel.CmdArgs) error {
@@ -14,10 +14,11 @@ import (
 	"code.cloudfoundry.org/cf-networking-helpers/marshal"
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
 	"code.cloudfoundry.org/cf-networking-helpers/middleware"
-	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
 	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
+	"code.cloudfoundry.org/lib/tlsreload"
+	"code.cloudfoundry.org/silk/controller"
 	"code.cloudfoundry.org/silk/controller/config"
 	"code.cloudfoundry.org/silk/controller/database"
 	"code.cloudfoundry.org/silk/controller/handlers"
@@ -64,10 +65,14 @@ func mainWithError() error {
 	debugServerAddress := fmt.Sprintf("127.0.0.1:%d", conf.DebugServerPort)
 	mainServerAddress := fmt.Sprintf("%s:%d", conf.ListenHost, conf.ListenPort)
 	healthServerAddress := fmt.Sprintf("127.0.0.1:%d", conf.HealthCheckPort)
-	tlsConfig, err := mutualtls.NewServerTLSConfig(conf.ServerCertFile, conf.ServerKeyFile, conf.CACertFile)
+	adminServerAddress := fmt.Sprintf("127.0.0.1:%d", conf.AdminPort)
+	serverCredentials, err := tlsreload.New(logger.Session("server-credentials"), conf.ServerCertFile, conf.ServerKeyFile, conf.CACertFile)
 	if err != nil {
 		return fmt.Errorf("mutual tls config: %s", err)
 	}
+	if err := serverCredentials.AuthorizeSPIFFEIDs(conf.ClientSPIFFEIDs); err != nil {
+		return fmt.Errorf("mutual tls config: %s", err)
+	}
 
 	connectionPool, err := db.NewConnectionPool(
 		conf.Database,
@@ -84,6 +89,7 @@ func mainWithError() error {
 
 	databaseHandler := database.NewDatabaseHandler(&database.MigrateAdapter{}, connectionPool)
 	cidrPool := leaser.NewCIDRPool(conf.Network, conf.SubnetPrefixLength)
+	cidrPool.Shard = leaser.Shard{Index: conf.ShardIndex, Count: conf.ShardCount}
 	leaseController := &leaser.LeaseController{
 		DatabaseHandler:            databaseHandler,
 		HardwareAddressGenerator:   &leaser.HardwareAddressGenerator{},
@@ -92,6 +98,23 @@ func mainWithError() error {
 		CIDRPool:                   cidrPool,
 		LeaseExpirationSeconds:     conf.LeaseExpirationSeconds,
 		Logger:                     logger,
+		ReclamationPolicy: leaser.ReclamationPolicy{
+			GraceSeconds:      conf.LeaseReclamationGraceSeconds,
+			PinnedUnderlayIPs: conf.PinnedUnderlayIPs,
+		},
+	}
+	if conf.LeaseReclamationProbePort != 0 {
+		leaseController.ReclamationPolicy.Prober = &leaser.TCPProber{
+			Port:    conf.LeaseReclamationProbePort,
+			Timeout: time.Duration(conf.LeaseReclamationProbeTimeoutSeconds) * time.Second,
+		}
+	}
+	if conf.NetworkIPv6 != "" {
+		ipv6Prefixes, err := leaser.NewIPv6Prefixes(conf.Network, conf.NetworkIPv6, conf.SubnetPrefixLengthIPv6)
+		if err != nil {
+			return fmt.Errorf("ipv6 prefixes: %s", err)
+		}
+		leaseController.IPv6Prefixes = ipv6Prefixes
 	}
 	migrator := &database.Migrator{
 		DatabaseMigrator:              databaseHandler,
@@ -137,6 +160,38 @@ func mainWithError() error {
 		ErrorResponse: errorResponse,
 	}
 
+	leaseConflictsIndex := &handlers.LeaseConflictsIndex{
+		Marshaler:               marshal.MarshalFunc(json.Marshal),
+		LeaseConflictRepository: leaseController,
+		ErrorResponse:           errorResponse,
+	}
+
+	leaseConflictsResolve := &handlers.ResolveLeaseConflict{
+		Unmarshaler:           marshal.UnmarshalFunc(json.Unmarshal),
+		LeaseConflictResolver: leaseController,
+		ErrorResponse:         errorResponse,
+	}
+
+	handshake := &handlers.Handshake{
+		Marshaler:     marshal.MarshalFunc(json.Marshal),
+		Unmarshaler:   marshal.UnmarshalFunc(json.Unmarshal),
+		APIInfo:       controller.CurrentAPIInfo(),
+		ErrorResponse: errorResponse,
+	}
+
+	topology := &handlers.Topology{
+		Marshaler:          marshal.MarshalFunc(json.Marshal),
+		TopologyRepository: leaseController,
+		Signer:             &controller.TopologySigner{Credentials: serverCredentials},
+		ErrorResponse:      errorResponse,
+	}
+
+	egressGatewaysIndex := &handlers.EgressGatewaysIndex{
+		Marshaler:      marshal.MarshalFunc(json.Marshal),
+		EgressGateways: conf.EgressGateways,
+		ErrorResponse:  errorResponse,
+	}
+
 	metricsWrap := func(name string, handle http.Handler) http.Handler {
 		metricsWrapper := middleware.MetricWrapper{
 			Name:          name,
@@ -158,12 +213,20 @@ func mainWithError() error {
 			{Name: "leases-acquire", Method: "PUT", Path: "/leases/acquire"},
 			{Name: "leases-release", Method: "PUT", Path: "/leases/release"},
 			{Name: "leases-renew", Method: "PUT", Path: "/leases/renew"},
+			{Name: "lease-conflicts-index", Method: "GET", Path: "/leases/conflicts"},
+			{Name: "egress-gateways-index", Method: "GET", Path: "/egress_gateways"},
+			{Name: "topology", Method: "GET", Path: "/topology"},
+			{Name: "handshake", Method: "PUT", Path: "/handshake"},
 		},
 		rata.Handlers{
-			"leases-index":   metricsWrap("LeasesIndex", logWrap(leasesIndex)),
-			"leases-acquire": metricsWrap("LeasesAcquire", logWrap(leasesAcquire)),
-			"leases-release": metricsWrap("LeasesRelease", logWrap(leasesRelease)),
-			"leases-renew":   metricsWrap("LeasesRenew", logWrap(leasesRenew)),
+			"leases-index":          metricsWrap("LeasesIndex", logWrap(leasesIndex)),
+			"leases-acquire":        metricsWrap("LeasesAcquire", logWrap(leasesAcquire)),
+			"leases-release":        metricsWrap("LeasesRelease", logWrap(leasesRelease)),
+			"leases-renew":          metricsWrap("LeasesRenew", logWrap(leasesRenew)),
+			"lease-conflicts-index": metricsWrap("LeaseConflictsIndex", logWrap(leaseConflictsIndex)),
+			"egress-gateways-index": metricsWrap("EgressGatewaysIndex", logWrap(egressGatewaysIndex)),
+			"topology":              metricsWrap("Topology", logWrap(topology)),
+			"handshake":             metricsWrap("Handshake", logWrap(handshake)),
 		},
 	)
 	if err != nil {
@@ -187,6 +250,20 @@ func mainWithError() error {
 		return fmt.Errorf("creating health router: %s", err)
 	}
 
+	// resolving a conflict deletes a lease, which the cells must not be able
+	// to do with their client certificates, so it is only served on localhost
+	adminRouter, err := rata.NewRouter(
+		rata.Routes{
+			{Name: "lease-conflicts-resolve", Method: "PUT", Path: "/leases/conflicts/resolve"},
+		},
+		rata.Handlers{
+			"lease-conflicts-resolve": metricsWrap("LeaseConflictsResolve", logWrap(leaseConflictsResolve)),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("creating admin router: %s", err)
+	}
+
 	metronAddress := fmt.Sprintf("127.0.0.1:%d", conf.MetronPort)
 	err = dropsonde.Initialize(metronAddress, "silk-controller")
 	if err != nil {
@@ -194,7 +271,7 @@ func mainWithError() error {
 	}
 
 	logger.Info("starting-servers")
-	httpServer := http_server.NewTLSServer(mainServerAddress, router, tlsConfig)
+	httpServer := http_server.NewTLSServer(mainServerAddress, router, serverCredentials.ServerTLSConfig())
 	healthServer := http_server.New(healthServerAddress, healthRouter)
 
 	// Metrics sources
@@ -203,16 +280,22 @@ func mainWithError() error {
 		server_metrics.NewTotalLeasesSource(databaseHandler),
 		server_metrics.NewFreeLeasesSource(databaseHandler, cidrPool),
 		server_metrics.NewStaleLeasesSource(databaseHandler, conf.StalenessThresholdSeconds),
+		server_metrics.NewConflictingLeasesSource(databaseHandler, conf.LeaseExpirationSeconds),
 	}
 	metricSources = append(metricSources, metrics.NewDBMonitorSource(connectionPool, connectionPool.Monitor)...)
 	metricsEmitter := metrics.NewMetricsEmitter(logger, time.Duration(conf.MetricsEmitSeconds)*time.Second, metricSources...)
 	members := grouper.Members{
+		{Name: "server-credentials", Runner: serverCredentials},
 		{Name: "http_server", Runner: httpServer},
 		{Name: "health-server", Runner: healthServer},
 		{Name: "debug-server", Runner: debugserver.Runner(debugServerAddress, reconfigurableSink)},
 		{Name: "metrics-emitter", Runner: metricsEmitter},
 	}
 
+	if conf.AdminPort != 0 {
+		members = append(members, grouper.Member{Name: "admin-server", Runner: http_server.New(adminServerAddress, adminRouter)})
+	}
+
 	group := grouper.NewOrdered(os.Interrupt, members)
 	monitor := ifrit.Invoke(sigmon.New(group))
 
@@ -8,25 +8,39 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
-	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/filelock"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
+	libdatastore "code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+	libserial "code.cloudfoundry.org/lib/serial"
+	"code.cloudfoundry.org/lib/tlsreload"
 	"code.cloudfoundry.org/silk/client/config"
 	"code.cloudfoundry.org/silk/controller"
 	"code.cloudfoundry.org/silk/daemon"
+	"code.cloudfoundry.org/silk/daemon/bgp"
+	"code.cloudfoundry.org/silk/daemon/conntrack"
+	"code.cloudfoundry.org/silk/daemon/egress"
 	"code.cloudfoundry.org/silk/daemon/planner"
 	"code.cloudfoundry.org/silk/daemon/poller"
+	"code.cloudfoundry.org/silk/daemon/sysctls"
+	"code.cloudfoundry.org/silk/daemon/ttl"
+	"code.cloudfoundry.org/silk/daemon/underlay"
 	"code.cloudfoundry.org/silk/daemon/vtep"
+	"code.cloudfoundry.org/silk/healthcheck"
 	"code.cloudfoundry.org/silk/lib/adapter"
 	"code.cloudfoundry.org/silk/lib/datastore"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	"code.cloudfoundry.org/silk/lib/serial"
 
 	"github.com/cloudfoundry/dropsonde"
+	"github.com/coreos/go-iptables/iptables"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -40,6 +54,12 @@ var logPrefix = "cfnetworking"
 
 const (
 	jobPrefix = "silk-daemon"
+
+	egressMarkBase     = 0x10000
+	egressTableBase    = 200
+	egressRulePriority = 1000
+
+	bgpConnectRetry = 5 * time.Second
 )
 
 func main() {
@@ -68,14 +88,17 @@ func mainWithError() error {
 	logger, reconfigurableSink := lagerflags.NewFromConfig(fmt.Sprintf("%s.%s", logPrefix, jobPrefix), getLagerConfig(logLevel))
 	logger.Info("starting")
 
-	tlsConfig, err := mutualtls.NewClientTLSConfig(cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
+	clientCredentials, err := tlsreload.New(logger.Session("client-credentials"), cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
 	if err != nil {
 		return fmt.Errorf("create tls config: %s", err)
 	}
+	if err := clientCredentials.AuthorizeSPIFFEIDs(cfg.ControllerSPIFFEIDs); err != nil {
+		return fmt.Errorf("create tls config: %s", err)
+	}
 
 	httpClient := &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
+			DialTLSContext: clientCredentials.DialTLSContext,
 		},
 		Timeout: time.Duration(cfg.ClientTimeoutSeconds) * time.Second,
 	}
@@ -99,6 +122,14 @@ func mainWithError() error {
 
 	client := controller.NewClient(logger, httpClient, cfg.ConnectivityServerURL)
 
+	handshake, err := negotiateAPI(logger, client)
+	if err != nil {
+		return err
+	}
+	if cfg.SingleIPOnly && !handshake.Supports(controller.FeatureSingleOverlayIP) {
+		return fmt.Errorf("negotiate api: controller does not support single overlay ip leases")
+	}
+
 	store := &datastore.Store{
 		Serializer: &serial.Serial{},
 		LockerNew:  filelock.NewLocker,
@@ -161,6 +192,15 @@ func mainWithError() error {
 			}
 		}
 		logger.Info("renewed-lease", lager.Data{"lease": lease})
+
+		if lease.OverlayIPv6Subnet == "" {
+			lease = withIPv6Subnet(logger, client, lease)
+		}
+	}
+
+	err = ensureVTEPUnderlays(logger, cfg, lease, vtepConfigCreator, vtepFactory)
+	if err != nil {
+		return err
 	}
 
 	debugServerAddress := fmt.Sprintf("127.0.0.1:%d", cfg.DebugServerPort)
@@ -184,25 +224,109 @@ func mainWithError() error {
 		return fmt.Errorf("find local VTEP: %s", err) //TODO add test coverage
 	}
 
-	vxlanPoller := &poller.Poller{
-		Logger:       logger,
-		PollInterval: time.Duration(cfg.PollInterval) * time.Second,
-		SingleCycleFunc: (&planner.VXLANPlanner{
-			Logger:           logger,
-			ControllerClient: client,
-			Lease:            lease,
-			Converger: &vtep.Converger{
-				OverlayNetwork: overlayNetwork,
-				LocalSubnet:    localSubnet,
-				LocalVTEP:      *vxlanIface,
+	sysctlEnforcer, err := buildSysctlEnforcer(logger, cfg, localSubnet, metricSender)
+	if err != nil {
+		return fmt.Errorf("create sysctl enforcer: %s", err)
+	}
+	err = sysctlEnforcer.Enforce()
+	if err != nil {
+		return fmt.Errorf("enforce sysctls: %s", err)
+	}
+
+	// without TTLs, the rules of an earlier configuration are removed
+	if cfg.EncapsulatedTTL != 0 || cfg.ContainerEgressTTL != 0 || cfg.IPTablesLockFile != "" {
+		err = setTTL(cfg, overlayNetwork)
+		if err != nil {
+			return fmt.Errorf("set ttl: %s", err)
+		}
+	}
+
+	vtepConverger := &vtep.Converger{
+		OverlayNetwork: overlayNetwork,
+		LocalSubnet:    localSubnet,
+		LocalVTEP:      *vxlanIface,
+		NetlinkAdapter: &adapter.NetlinkAdapter{},
+		MetricSender:   metricSender,
+		Logger:         logger,
+		RouteTable:     cfg.OverlayRouting.Table,
+		RouteMetric:    cfg.OverlayRouting.Metric,
+		RulePriority:   cfg.OverlayRouting.RulePriority,
+	}
+	vxlanPlanner := &planner.VXLANPlanner{
+		Logger:           logger,
+		ControllerClient: client,
+		Lease:            lease,
+		Converger:        vtepConverger,
+		ErrorDetector: planner.NewGracefulDetector(
+			time.Duration(cfg.PartitionToleranceSeconds) * time.Second,
+		),
+		MetricSender: metricSender,
+	}
+	if cfg.SecondaryUnderlayIP != "" {
+		vtepConf, err := vtepConfigCreator.Create(cfg, lease)
+		if err != nil {
+			return fmt.Errorf("create vtep config: %s", err) // not tested
+		}
+		vxlanPlanner.UnderlayFailover = &underlay.Failover{
+			SecondaryUnderlayIP: cfg.SecondaryUnderlayIP,
+			PrimaryProbe: &underlay.Probe{
+				VTEPName:       cfg.VTEPName,
+				Device:         vtepConf.UnderlayInterface.Name,
+				CheckCarrier:   true,
+				CheckGateway:   cfg.UnderlayHealth.CheckDefaultGateway,
+				PingTimeout:    time.Duration(cfg.UnderlayHealth.PingTimeoutMilliseconds) * time.Millisecond,
 				NetlinkAdapter: &adapter.NetlinkAdapter{},
-				Logger:         logger,
+				Pinger:         healthcheck.ICMPPinger{},
 			},
-			ErrorDetector: planner.NewGracefulDetector(
-				time.Duration(cfg.PartitionToleranceSeconds) * time.Second,
-			),
-			MetricSender: metricSender,
-		}).DoCycle,
+			SecondaryProbe: &underlay.Probe{
+				VTEPName:       cfg.VTEPName,
+				Device:         vtepConf.SecondaryUnderlayInterface.Name,
+				CheckCarrier:   true,
+				NetlinkAdapter: &adapter.NetlinkAdapter{},
+			},
+			Logger: logger.Session("underlay-failover"),
+		}
+	} else if cfg.UnderlayHealth.Enabled() {
+		vxlanPlanner.UnderlayProbe = &underlay.Probe{
+			VTEPName:       cfg.VTEPName,
+			CheckCarrier:   cfg.UnderlayHealth.CheckVTEPCarrier,
+			CheckGateway:   cfg.UnderlayHealth.CheckDefaultGateway,
+			PingTimeout:    time.Duration(cfg.UnderlayHealth.PingTimeoutMilliseconds) * time.Millisecond,
+			NetlinkAdapter: &adapter.NetlinkAdapter{},
+			Pinger:         healthcheck.ICMPPinger{},
+		}
+	}
+
+	var bgpSpeaker ifrit.Runner
+	if cfg.BGP.Enabled() {
+		leaseHealth := &bgp.LeaseHealth{Timeout: bgpHealthTimeout(cfg)}
+		leaseHealth.Renewed()
+		vtepConverger.NoOverlay = true
+		vxlanPlanner.ErrorDetector = renewalRecorder{vxlanPlanner.ErrorDetector, leaseHealth}
+		bgpSpeaker = buildBGPSpeaker(logger, cfg, localSubnet, leaseHealth)
+	}
+
+	vxlanPoller := &poller.Poller{
+		Logger:          logger,
+		PollInterval:    time.Duration(cfg.PollInterval) * time.Second,
+		SingleCycleFunc: vxlanPlanner.DoCycle,
+	}
+
+	sysctlPoller := &poller.Poller{
+		Logger:          logger.Session("sysctls"),
+		PollInterval:    time.Duration(cfg.PollInterval) * time.Second,
+		SingleCycleFunc: sysctlEnforcer.Enforce,
+	}
+
+	conntrackPoller := &poller.Poller{
+		Logger:       logger.Session("conntrack"),
+		PollInterval: time.Duration(cfg.PollInterval) * time.Second,
+		SingleCycleFunc: (&conntrack.Monitor{
+			SysctlAdapter:          &adapter.SysctlAdapter{},
+			MetricSender:           metricSender,
+			Logger:                 logger.Session("conntrack"),
+			HighUtilizationPercent: cfg.Conntrack.HighUtilizationPercent,
+		}).Check,
 	}
 
 	uptimeSource := metrics.NewUptimeSource()
@@ -210,8 +334,37 @@ func mainWithError() error {
 	members := grouper.Members{
 		{Name: "server", Runner: healthCheckServer},
 		{Name: "vxlan-poller", Runner: vxlanPoller},
+		{Name: "sysctl-poller", Runner: sysctlPoller},
+		{Name: "conntrack-poller", Runner: conntrackPoller},
 		{Name: "debug-server", Runner: debugserver.Runner(debugServerAddress, reconfigurableSink)},
 		{Name: "metrics-emitter", Runner: metricsEmitter},
+		{Name: "client-credentials", Runner: clientCredentials},
+	}
+
+	if cfg.SelfTestPort != 0 {
+		selfTestCredentials, err := tlsreload.New(logger.Session("self-test-credentials"), cfg.SelfTestServerCertFile, cfg.SelfTestServerKeyFile, cfg.SelfTestCACertFile)
+		if err != nil {
+			return fmt.Errorf("create self test server: create tls config: %s", err)
+		}
+		selfTestServer := buildSelfTestServer(logger, cfg, lease, overlayNetwork, vtepFactory, client, selfTestCredentials)
+		members = append(members,
+			grouper.Member{Name: "self-test-credentials", Runner: selfTestCredentials},
+			grouper.Member{Name: "self-test-server", Runner: selfTestServer},
+		)
+	}
+
+	if cfg.EnableEgressGateways && !handshake.Supports(controller.FeatureEgressGateways) {
+		logger.Info("egress-gateways-unsupported", lager.Data{"api_version": handshake.Version})
+	} else if cfg.EnableEgressGateways {
+		egressPoller, err := buildEgressPoller(logger, cfg, client, overlayNetwork, *vxlanIface)
+		if err != nil {
+			return fmt.Errorf("create egress gateway poller: %s", err)
+		}
+		members = append(members, grouper.Member{Name: "egress-poller", Runner: egressPoller})
+	}
+
+	if bgpSpeaker != nil {
+		members = append(members, grouper.Member{Name: "bgp-speaker", Runner: bgpSpeaker})
 	}
 	group := grouper.NewOrdered(os.Interrupt, members)
 	monitor := ifrit.Invoke(sigmon.New(group))
@@ -220,6 +373,88 @@ func mainWithError() error {
 	return err
 }
 
+// buildSysctlEnforcer keeps the sysctls that silk requires and the autotuned
+// conntrack table sizes, followed by the ones of the operator, which may
+// override them.
+func buildSysctlEnforcer(logger lager.Logger, cfg config.Config, localSubnet *net.IPNet, metricSender *metrics.MetricsSender) (*sysctls.Enforcer, error) {
+	vtepReversePathFilter := cfg.VTEPReversePathFilter.Or(rpfilter.DefaultVTEP)
+	settings := []sysctls.Setting{
+		sysctls.IPForward,
+		{Name: rpfilter.SysctlName(cfg.VTEPName), Value: vtepReversePathFilter.SysctlValue()},
+	}
+
+	if cfg.Conntrack.Autotune {
+		conntrackSettings, err := autotuneConntrack(logger, cfg.Conntrack, localSubnet)
+		if err != nil {
+			return nil, fmt.Errorf("autotune conntrack: %s", err)
+		}
+		settings = append(settings, conntrackSettings...)
+	}
+
+	return &sysctls.Enforcer{
+		Settings:      append(settings, cfg.Sysctls...),
+		SysctlAdapter: &adapter.SysctlAdapter{},
+		MetricSender:  metricSender,
+		Logger:        logger.Session("sysctls"),
+	}, nil
+}
+
+func autotuneConntrack(logger lager.Logger, cfg config.Conntrack, localSubnet *net.IPNet) ([]sysctls.Setting, error) {
+	meminfo, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer meminfo.Close()
+	memTotal, err := conntrack.MemTotal(meminfo)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := cfg.ExpectedContainers
+	if containers == 0 {
+		ones, bits := localSubnet.Mask.Size()
+		containers = 1<<uint(bits-ones) - 2
+		if containers < 1 {
+			containers = 1
+		}
+	}
+
+	sizing := conntrack.Sizing{
+		EntriesPerGiB:       cfg.EntriesPerGiB,
+		EntriesPerContainer: cfg.EntriesPerContainer,
+		Containers:          containers,
+		EntriesPerBucket:    cfg.EntriesPerBucket,
+	}
+	conntrackSettings := sizing.Settings(memTotal)
+	logger.Info("conntrack-autotuned", lager.Data{
+		"memory-bytes": memTotal,
+		"containers":   containers,
+		"max":          conntrackSettings[0].Value,
+		"buckets":      conntrackSettings[1].Value,
+	})
+	return conntrackSettings, nil
+}
+
+// negotiateAPI agrees on the version and the features of the API with the
+// controller. If the controller cannot be reached, e.g. while it is upgraded,
+// the daemon keeps renewing its lease with the features of the legacy API
+// until it restarts. Only versions that are too far apart are fatal.
+func negotiateAPI(logger lager.Logger, client *controller.Client) (controller.Handshake, error) {
+	handshake, err := client.Handshake(controller.CurrentAPIInfo())
+	if _, ok := err.(controller.NonRetriableError); ok {
+		return controller.Handshake{}, fmt.Errorf("negotiate api: %s", err)
+	}
+	if err != nil {
+		logger.Error("negotiate-api", err)
+		handshake, err = controller.Negotiate(controller.CurrentAPIInfo(), controller.LegacyAPIInfo())
+		if err != nil {
+			return controller.Handshake{}, fmt.Errorf("negotiate api: %s", err)
+		}
+	}
+	logger.Info("negotiated-api", lager.Data{"version": handshake.Version, "features": handshake.Features})
+	return handshake, nil
+}
+
 func acquireLease(logger lager.Logger, client *controller.Client, vtepConfigCreator *vtep.ConfigCreator, vtepFactory *vtep.Factory, cfg config.Config) (controller.Lease, error) {
 	var lease controller.Lease
 	if cfg.SingleIPOnly {
@@ -269,6 +504,162 @@ func buildHealthCheckServer(healthCheckPort uint16, networkInfo daemon.NetworkIn
 	), nil
 }
 
+func buildSelfTestServer(logger lager.Logger, cfg config.Config, lease controller.Lease, overlayNetwork *net.IPNet, vtepFactory *vtep.Factory, client *controller.Client, credentials *tlsreload.Credentials) ifrit.Runner {
+	mux := http.NewServeMux()
+	mux.Handle("/self-test", &healthcheck.SelfTestHandler{
+		Logger:     logger,
+		UnderlayIP: cfg.UnderlayIP,
+		CheckerBuilder: &healthcheck.DaemonCheckerBuilder{
+			VTEPName:        cfg.VTEPName,
+			OverlayNetwork:  overlayNetwork,
+			Lease:           lease,
+			VTEPStateGetter: vtepFactory,
+			LeaseLister:     client,
+			Pinger:          healthcheck.ICMPPinger{},
+			ProbeTimeout:    2 * time.Second,
+			// stay well below the errand's default client timeout of 60s
+			ProbeConcurrency: 16,
+			ProbeDeadline:    30 * time.Second,
+		},
+	})
+
+	return http_server.NewTLSServer(fmt.Sprintf("%s:%d", cfg.UnderlayIP, cfg.SelfTestPort), mux, credentials.ServerTLSConfig())
+}
+
+func setTTL(cfg config.Config, overlayNetwork *net.IPNet) error {
+	lockedIPTables, err := newLockedIPTables(cfg)
+	if err != nil {
+		return err
+	}
+	return (&ttl.Setter{
+		IPTables:            lockedIPTables,
+		UnderlayIP:          cfg.UnderlayIP,
+		SecondaryUnderlayIP: cfg.SecondaryUnderlayIP,
+		VTEPName:            cfg.VTEPName,
+		VTEPPort:            cfg.VTEPPort,
+		OverlayNetwork:      overlayNetwork,
+		EncapsulatedTTL:     cfg.EncapsulatedTTL,
+		ContainerEgressTTL:  cfg.ContainerEgressTTL,
+	}).Apply()
+}
+
+// renewalRecorder tells the BGP speaker when the lease was renewed.
+type renewalRecorder struct {
+	planner.FatalErrorDetector
+	health *bgp.LeaseHealth
+}
+
+func (r renewalRecorder) GotSuccess() {
+	r.health.Renewed()
+	r.FatalErrorDetector.GotSuccess()
+}
+
+// bgpHealthTimeout defaults to the partition tolerance, after which the
+// daemon gives up on the lease.
+func bgpHealthTimeout(cfg config.Config) time.Duration {
+	if cfg.BGP.HealthTimeoutSeconds != 0 {
+		return time.Duration(cfg.BGP.HealthTimeoutSeconds) * time.Second
+	}
+	return time.Duration(cfg.PartitionToleranceSeconds) * time.Second
+}
+
+func buildBGPSpeaker(logger lager.Logger, cfg config.Config, localSubnet *net.IPNet, health *bgp.LeaseHealth) ifrit.Runner {
+	underlayIP := net.ParseIP(cfg.UnderlayIP)
+	peers := []bgp.Peer{}
+	for _, peer := range cfg.BGP.Peers {
+		port := peer.Port
+		if port == 0 {
+			port = bgp.DefaultPort
+		}
+		peers = append(peers, bgp.Peer{Address: peer.Address, Port: port, AS: peer.AS})
+	}
+
+	return &bgp.Speaker{
+		Logger:       logger.Session("bgp"),
+		LocalAS:      cfg.BGP.LocalAS,
+		RouterID:     underlayIP,
+		NextHop:      underlayIP,
+		Prefixes:     []*net.IPNet{localSubnet},
+		Peers:        peers,
+		HoldTime:     time.Duration(cfg.BGP.HoldTimeSeconds) * time.Second,
+		ConnectRetry: bgpConnectRetry,
+		Health:       health,
+	}
+}
+
+func newLockedIPTables(cfg config.Config) (*rules.LockedIPTables, error) {
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil, fmt.Errorf("iptables new: %s", err)
+	}
+	return &rules.LockedIPTables{
+		IPTables: ipt,
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(cfg.IPTablesLockFile),
+			Mutex:      &sync.Mutex{},
+		},
+		Restorer: &rules.Restorer{},
+	}, nil
+}
+
+func buildEgressPoller(logger lager.Logger, cfg config.Config, client *controller.Client, overlayNetwork *net.IPNet, vxlanIface net.Interface) (ifrit.Runner, error) {
+	lockedIPTables, err := newLockedIPTables(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	containerStore := &libdatastore.Store{
+		Serializer: &libserial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(cfg.ContainerMetadataFile + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath:    cfg.ContainerMetadataFile,
+		VersionFilePath: cfg.ContainerMetadataFile + "_version",
+		LockedFilePath:  cfg.ContainerMetadataFile + "_lock",
+		CacheMutex:      new(sync.RWMutex),
+	}
+
+	pollInterval := time.Duration(cfg.PollInterval) * time.Second
+	chainOwnersFile := libdatastore.ChainOwnersFilePath(cfg.ContainerMetadataFile)
+	chainOwners := &libdatastore.ChainOwners{
+		Serializer: &libserial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(chainOwnersFile + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath: chainOwnersFile,
+		TTL:          3 * pollInterval,
+	}
+
+	return &poller.Poller{
+		Logger:       logger.Session("egress-gateways"),
+		PollInterval: pollInterval,
+		SingleCycleFunc: (&egress.Planner{
+			Logger:           logger.Session("egress-gateways"),
+			ControllerClient: client,
+			Datastore:        containerStore,
+			LocalUnderlayIP:  cfg.UnderlayIP,
+			Converger: &egress.Converger{
+				Logger:         logger.Session("egress-gateways"),
+				IPTables:       lockedIPTables,
+				NetlinkAdapter: &adapter.NetlinkAdapter{},
+				NetOutChains: &netrules.NetOutChain{
+					ChainNamer: &netrules.ChainNamer{
+						MaxLength: 28,
+					},
+				},
+				ChainOwners:    chainOwners,
+				OverlayNetwork: overlayNetwork,
+				VTEP:           vxlanIface,
+				MarkBase:       egressMarkBase,
+				TableBase:      egressTableBase,
+				RulePriority:   egressRulePriority,
+			},
+		}).DoCycle,
+	}, nil
+}
+
 func discoverLocalLease(clientConfig config.Config, vtepFactory *vtep.Factory) (controller.Lease, error) {
 	overlayHwAddr, overlayIP, _, err := vtepFactory.GetVTEPState(clientConfig.VTEPName)
 	if err != nil {
@@ -289,6 +680,23 @@ func leaseFromVTEPState(clientConfig config.Config, overlayHwAddr net.HardwareAd
 	}
 }
 
+// withIPv6Subnet looks up the ipv6 prefix of a renewed lease, which the
+// controller only hands out with acquired and routable leases.
+func withIPv6Subnet(logger lager.Logger, client *controller.Client, lease controller.Lease) controller.Lease {
+	leases, err := client.GetActiveLeases()
+	if err != nil {
+		logger.Error("get-ipv6-subnet", err, lager.Data{"lease": lease})
+		return lease
+	}
+	for _, activeLease := range leases {
+		if activeLease.UnderlayIP == lease.UnderlayIP && activeLease.OverlaySubnet == lease.OverlaySubnet {
+			lease.OverlayIPv6Subnet = activeLease.OverlayIPv6Subnet
+			break
+		}
+	}
+	return lease
+}
+
 func getNetworkInfo(vtepFactory *vtep.Factory, clientConfig config.Config, lease controller.Lease) (daemon.NetworkInfo, error) {
 	_, _, mtu, err := vtepFactory.GetVTEPState(clientConfig.VTEPName)
 	if err != nil {
@@ -296,8 +704,9 @@ func getNetworkInfo(vtepFactory *vtep.Factory, clientConfig config.Config, lease
 	}
 
 	return daemon.NetworkInfo{
-		OverlaySubnet: lease.OverlaySubnet,
-		MTU:           mtu,
+		OverlaySubnet:     lease.OverlaySubnet,
+		OverlayIPv6Subnet: lease.OverlayIPv6Subnet,
+		MTU:               mtu,
 	}, nil
 }
 
@@ -309,6 +718,34 @@ func deleteAndAcquire(cfg config.Config, logger lager.Logger, client *controller
 	return acquireLease(logger, client, vtepConfigCreator, vtepFactory, cfg)
 }
 
+// ensureVTEPUnderlays recreates a VTEP that an earlier configuration bound to
+// the underlay device while the cell now has a secondary underlay, or the
+// other way around, since the kernel cannot rebind an existing VTEP.
+func ensureVTEPUnderlays(logger lager.Logger, cfg config.Config, lease controller.Lease, vtepConfigCreator *vtep.ConfigCreator, vtepFactory *vtep.Factory) error {
+	bound, err := vtepFactory.IsBoundToUnderlay(cfg.VTEPName)
+	if err != nil {
+		return fmt.Errorf("inspect vtep: %s", err) // not tested
+	}
+	if bound == (cfg.SecondaryUnderlayIP == "") {
+		return nil
+	}
+
+	logger.Info("recreate-vtep", lager.Data{"bound_to_underlay": bound})
+	err = vtepFactory.DeleteVTEP(cfg.VTEPName)
+	if err != nil {
+		return fmt.Errorf("delete vtep: %s", err) // not tested
+	}
+	vtepConf, err := vtepConfigCreator.Create(cfg, lease)
+	if err != nil {
+		return fmt.Errorf("create vtep config: %s", err) // not tested
+	}
+	err = vtepFactory.CreateVTEP(vtepConf)
+	if err != nil {
+		return fmt.Errorf("create vtep: %s", err) // not tested
+	}
+	return nil
+}
+
 func getLagerConfig(level string) lagerflags.LagerConfig {
 	lagerConfig := lagerflags.DefaultLagerConfig()
 	lagerConfig.TimeFormat = lagerflags.FormatRFC3339
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/lib/serial"
+	"code.cloudfoundry.org/lib/tlsreload"
+	"code.cloudfoundry.org/silk/client/config"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/vtep"
+	"code.cloudfoundry.org/silk/healthcheck"
+	errandconfig "code.cloudfoundry.org/silk/healthcheck/config"
+	"code.cloudfoundry.org/silk/lib/adapter"
+
+	"github.com/coreos/go-iptables/iptables"
+)
+
+const jobPrefix = "silk-healthcheck"
+
+func main() {
+	healthy, err := mainWithError()
+	if err != nil {
+		log.Fatalf("%s error: %s", jobPrefix, err)
+	}
+	if !healthy {
+		os.Exit(1)
+	}
+}
+
+func mainWithError() (bool, error) {
+	configFilePath := flag.String("config", "", "path to silk-daemon client config file")
+	datastorePath := flag.String("datastore", "/var/vcap/data/container-metadata/store.json", "path to container metadata datastore")
+	iptablesLockFile := flag.String("iptables-lock-file", "/var/vcap/data/garden-cni/iptables.lock", "path to iptables lock file")
+	peerCount := flag.Int("peer-count", 3, "number of random peer cells to probe")
+	probeTimeout := flag.Duration("probe-timeout", 2*time.Second, "timeout for each peer probe")
+	probeConcurrency := flag.Int("probe-concurrency", 16, "number of peer cells probed in parallel")
+	probeDeadline := flag.Duration("probe-deadline", 30*time.Second, "overall deadline for probing the peer cells")
+	errandConfigFilePath := flag.String("errand-config", "", "path to errand config file; when set, fans out the self-test over all cells")
+	flag.Parse()
+
+	if *errandConfigFilePath != "" {
+		return runErrand(*errandConfigFilePath)
+	}
+
+	cfg, err := config.LoadConfig(*configFilePath)
+	if err != nil {
+		return false, fmt.Errorf("load config file: %s", err)
+	}
+
+	logger := lager.NewLogger(fmt.Sprintf("%s.%s", cfg.LogPrefix, jobPrefix))
+	logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.INFO))
+
+	credentials, err := tlsreload.New(logger, cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
+	if err != nil {
+		return false, fmt.Errorf("create tls config: %s", err)
+	}
+	if err := credentials.AuthorizeSPIFFEIDs(cfg.ControllerSPIFFEIDs); err != nil {
+		return false, fmt.Errorf("create tls config: %s", err)
+	}
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: credentials.ClientTLSConfig(),
+		},
+		Timeout: time.Duration(cfg.ClientTimeoutSeconds) * time.Second,
+	}
+	client := controller.NewClient(logger, httpClient, cfg.ConnectivityServerURL)
+
+	_, overlayNetwork, err := net.ParseCIDR(cfg.OverlayNetwork)
+	if err != nil {
+		return false, fmt.Errorf("parse overlay network CIDR: %s", err)
+	}
+
+	leases, err := client.GetActiveLeases()
+	if err != nil {
+		return false, fmt.Errorf("get active leases: %s", err)
+	}
+
+	var localLease *controller.Lease
+	for i := range leases {
+		if leases[i].UnderlayIP == cfg.UnderlayIP {
+			localLease = &leases[i]
+			break
+		}
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		return false, fmt.Errorf("iptables new: %s", err)
+	}
+	lockedIPTables := &rules.LockedIPTables{
+		IPTables: ipt,
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(*iptablesLockFile),
+			Mutex:      &sync.Mutex{},
+		},
+		Restorer: &rules.Restorer{},
+	}
+
+	store := &datastore.Store{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(*datastorePath + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath:    *datastorePath,
+		VersionFilePath: *datastorePath + "_version",
+		LockedFilePath:  *datastorePath + "_lock",
+		CacheMutex:      new(sync.RWMutex),
+	}
+
+	report := healthcheck.BuildReport(cfg.UnderlayIP,
+		&healthcheck.VTEPCheck{
+			VTEPName:       cfg.VTEPName,
+			OverlayNetwork: overlayNetwork,
+			Lease:          localLease,
+			VTEPStateGetter: &vtep.Factory{
+				NetlinkAdapter: &adapter.NetlinkAdapter{},
+				Logger:         logger,
+			},
+		},
+		&healthcheck.PeerProbe{
+			LocalUnderlayIP: cfg.UnderlayIP,
+			Leases:          leases,
+			Count:           *peerCount,
+			Timeout:         *probeTimeout,
+			Concurrency:     *probeConcurrency,
+			Deadline:        *probeDeadline,
+			Pinger:          healthcheck.ICMPPinger{},
+			Rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		},
+		&healthcheck.PolicyMarkCheck{
+			IPTables:  lockedIPTables,
+			Datastore: store,
+		},
+		&healthcheck.ASGDefaultDenyCheck{
+			IPTables:  lockedIPTables,
+			Datastore: store,
+			NetOutChains: &netrules.NetOutChain{
+				ChainNamer: &netrules.ChainNamer{
+					MaxLength: 28,
+				},
+			},
+		},
+	)
+
+	if err := writeJSON(report); err != nil {
+		return false, err
+	}
+
+	return report.Healthy, nil
+}
+
+func runErrand(configFilePath string) (bool, error) {
+	cfg, err := errandconfig.LoadConfig(configFilePath)
+	if err != nil {
+		return false, fmt.Errorf("load errand config file: %s", err)
+	}
+
+	logger := lager.NewLogger(fmt.Sprintf("%s.%s", cfg.LogPrefix, jobPrefix))
+	logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.INFO))
+
+	controllerCredentials, err := tlsreload.New(logger, cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
+	if err != nil {
+		return false, fmt.Errorf("create controller tls config: %s", err)
+	}
+	if err := controllerCredentials.AuthorizeSPIFFEIDs(cfg.ControllerSPIFFEIDs); err != nil {
+		return false, fmt.Errorf("create controller tls config: %s", err)
+	}
+	client := controller.NewClient(logger, &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: controllerCredentials.ClientTLSConfig(),
+		},
+		Timeout: time.Duration(cfg.ClientTimeoutSeconds) * time.Second,
+	}, cfg.ConnectivityServerURL)
+
+	selfTestTLSConfig, err := mutualtls.NewClientTLSConfig(cfg.ClientCertFile, cfg.ClientKeyFile, cfg.SelfTestCACertFile)
+	if err != nil {
+		return false, fmt.Errorf("create self-test tls config: %s", err)
+	}
+	selfTestTLSConfig.ServerName = cfg.SelfTestServerName
+
+	caCert, err := os.ReadFile(cfg.ServerCACertFile)
+	if err != nil {
+		return false, fmt.Errorf("read controller ca cert file: %s", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caCert) {
+		return false, errors.New("read controller ca cert file: no certificates")
+	}
+
+	signedTopology, err := client.GetTopology()
+	if err != nil {
+		return false, fmt.Errorf("get topology: %s", err)
+	}
+	topology, err := signedTopology.Verify(roots)
+	if err != nil {
+		return false, err
+	}
+	// only the routable cells are reachable over the overlay
+	leases := []controller.Lease{}
+	for _, cell := range topology.Cells {
+		if cell.Routable {
+			leases = append(leases, cell.Lease)
+		}
+	}
+	logger.Info("fanning-out", lager.Data{"cells": len(leases), "topology_version": topology.Version})
+
+	fanout := &healthcheck.Fanout{
+		Fetcher: &healthcheck.HTTPReportFetcher{
+			Client: &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: selfTestTLSConfig,
+				},
+				Timeout: time.Duration(cfg.ClientTimeoutSeconds) * time.Second,
+			},
+			Port:      cfg.SelfTestPort,
+			PeerCount: cfg.PeerCount,
+		},
+		Concurrency: cfg.Concurrency,
+	}
+	report := fanout.Run(leases)
+
+	for _, failure := range report.Failures {
+		logger.Error("connectivity-failure", errors.New(failure))
+	}
+
+	if err := writeJSON(report); err != nil {
+		return false, err
+	}
+
+	return report.Healthy, nil
+}
+
+func writeJSON(report interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("encode report: %s", err)
+	}
+	return nil
+}
@@ -8,9 +8,9 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 
-	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
+	"code.cloudfoundry.org/lib/tlsreload"
 	"code.cloudfoundry.org/silk/client/config"
 	"code.cloudfoundry.org/silk/controller"
 	"code.cloudfoundry.org/silk/daemon/vtep"
@@ -44,13 +44,16 @@ func mainWithError() error {
 	logger, _ := lagerflags.NewFromConfig(fmt.Sprintf("%s.%s", logPrefix, jobPrefix), getLagerConfig())
 	logger.Info("starting")
 
-	tlsConfig, err := mutualtls.NewClientTLSConfig(cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
+	credentials, err := tlsreload.New(logger, cfg.ClientCertFile, cfg.ClientKeyFile, cfg.ServerCACertFile)
 	if err != nil {
 		return fmt.Errorf("create tls config: %s", err)
 	}
+	if err := credentials.AuthorizeSPIFFEIDs(cfg.ControllerSPIFFEIDs); err != nil {
+		return fmt.Errorf("create tls config: %s", err)
+	}
 	httpClient := &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
+			TLSClientConfig: credentials.ClientTLSConfig(),
 		},
 	}
 	client := controller.NewClient(logger, httpClient, cfg.ConnectivityServerURL)
@@ -0,0 +1,9 @@
+package adapter
+
+import "os/exec"
+
+type CommandRunner struct{}
+
+func (*CommandRunner) CombinedOutput(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
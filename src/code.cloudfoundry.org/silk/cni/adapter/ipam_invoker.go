@@ -0,0 +1,29 @@
+package adapter
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// IPAMInvoker runs IPAM plugins, which it looks up in the directories of the
+// path of their args.
+type IPAMInvoker struct{}
+
+func (*IPAMInvoker) ExecPluginWithResult(ctx context.Context, plugin string, netconf []byte, args *invoke.Args) (types.Result, error) {
+	pluginPath, err := invoke.FindInPath(plugin, filepath.SplitList(args.Path))
+	if err != nil {
+		return nil, err
+	}
+	return invoke.ExecPluginWithResult(ctx, pluginPath, netconf, args, nil)
+}
+
+func (*IPAMInvoker) ExecPluginWithoutResult(ctx context.Context, plugin string, netconf []byte, args *invoke.Args) error {
+	pluginPath, err := invoke.FindInPath(plugin, filepath.SplitList(args.Path))
+	if err != nil {
+		return err
+	}
+	return invoke.ExecPluginWithoutResult(ctx, pluginPath, netconf, args, nil)
+}
@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+type UserNamespaceAdapter struct{}
+
+// RunningInUserNamespace tells whether the process runs in a user namespace
+// other than the initial one, whose uid map covers every uid.
+func (*UserNamespaceAdapter) RunningInUserNamespace() (bool, error) {
+	uidMap, err := os.ReadFile("/proc/self/uid_map")
+	if err != nil {
+		return false, fmt.Errorf("reading uid map: %s", err)
+	}
+	return strings.Join(strings.Fields(string(uidMap)), " ") != "0 0 4294967295", nil
+}
+
+// CurrentUserNamespace returns the inode of the user namespace of the
+// process.
+func (*UserNamespaceAdapter) CurrentUserNamespace() (uint64, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat("/proc/self/ns/user", &stat); err != nil {
+		return 0, fmt.Errorf("stat user namespace: %s", err)
+	}
+	return stat.Ino, nil
+}
+
+// NetNSUserNamespaces returns the inodes of the user namespace that owns the
+// network namespace at path and of its ancestors, up to the initial user
+// namespace or to the first ancestor that is outside the user namespace of the
+// process.
+func (*UserNamespaceAdapter) NetNSUserNamespaces(path string) ([]uint64, error) {
+	netNS, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening network namespace: %s", err)
+	}
+	defer unix.Close(netNS)
+
+	userNS, err := unix.IoctlRetInt(netNS, unix.NS_GET_USERNS)
+	if err != nil {
+		return nil, fmt.Errorf("getting owning user namespace: %s", err)
+	}
+
+	inodes := []uint64{}
+	for {
+		var stat unix.Stat_t
+		err := unix.Fstat(userNS, &stat)
+		if err != nil {
+			unix.Close(userNS)
+			return nil, fmt.Errorf("stat user namespace: %s", err)
+		}
+		inodes = append(inodes, stat.Ino)
+
+		parent, err := unix.IoctlRetInt(userNS, unix.NS_GET_PARENT)
+		unix.Close(userNS)
+		if errors.Is(err, unix.EPERM) {
+			return inodes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("getting parent user namespace: %s", err)
+		}
+		userNS = parent
+	}
+}
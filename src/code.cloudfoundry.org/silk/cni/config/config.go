@@ -3,6 +3,8 @@ package config
 import (
 	"net"
 
+	"code.cloudfoundry.org/silk/lib/rpfilter"
+
 	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/plugins/pkg/ns"
@@ -24,19 +26,24 @@ type Config struct {
 		TemporaryDeviceName string
 		Namespace           netNS
 		Address             DualAddress
+		IPv6Address         net.IP
 		MTU                 int
 		Routes              []*types.Route
+		ReversePathFilter   rpfilter.Mode
+		NeighborMode        NeighborMode
 	}
 	Host struct {
-		DeviceName string
-		Namespace  netNS
-		Address    DualAddress
+		DeviceName        string
+		Namespace         netNS
+		Address           DualAddress
+		ReversePathFilter rpfilter.Mode
+		NeighborMode      NeighborMode
 	}
 }
 
 func (c *Config) AsCNIResult() *current.Result {
 	ipInterface := 1
-	return &current.Result{
+	result := &current.Result{
 		Interfaces: []*current.Interface{
 			&current.Interface{
 				Name:    c.Host.DeviceName,
@@ -62,4 +69,15 @@ func (c *Config) AsCNIResult() *current.Result {
 		Routes: c.Container.Routes,
 		DNS:    types.DNS{},
 	}
+
+	if c.Container.IPv6Address != nil {
+		result.IPs = append(result.IPs, &current.IPConfig{
+			Interface: &ipInterface,
+			Address: net.IPNet{
+				IP:   c.Container.IPv6Address,
+				Mask: net.CIDRMask(128, 128),
+			},
+		})
+	}
+	return result
 }
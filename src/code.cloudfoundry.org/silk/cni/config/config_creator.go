@@ -61,6 +61,12 @@ func (c *ConfigCreator) Create(hostNS netNS, addCmdArgs *skel.CmdArgs, ipamResul
 		return nil, errors.New("no IP address in IPAM result")
 	}
 	conf.Container.Address.IP = ipamResult.IPs[0].Address.IP
+	for _, ipConfig := range ipamResult.IPs[1:] {
+		if ipConfig.Address.IP.To4() == nil {
+			conf.Container.IPv6Address = ipConfig.Address.IP
+			break
+		}
+	}
 
 	conf.Container.TemporaryDeviceName, err = c.DeviceNameGenerator.GenerateTemporaryForContainer(conf.Container.Address.IP)
 	if err != nil {
@@ -78,6 +78,7 @@ var _ = Describe("ConfigCreator", func() {
 			Expect(conf.Container.Namespace).To(Equal(containerNS))
 			Expect(conf.Container.Address.IP).To(Equal(ipamResult.IPs[0].Address.IP))
 			Expect(conf.Container.Address.Hardware).To(Equal(containerMAC))
+			Expect(conf.Container.IPv6Address).To(BeNil())
 			By("Adding a route with 169.254.0.1 as the gateway", func() {
 				Expect(conf.Container.Routes).To(ConsistOf([]*types.Route{
 					&types.Route{
@@ -102,6 +103,24 @@ var _ = Describe("ConfigCreator", func() {
 			Expect(conf.Host.Address.Hardware).To(Equal(hostMAC))
 		})
 
+		Context("when the IPAM result has an ipv6 address", func() {
+			BeforeEach(func() {
+				ipamResult.IPs = append(ipamResult.IPs, &current.IPConfig{
+					Address: net.IPNet{
+						IP:   net.ParseIP("fd00:abcd:0:1e00::2"),
+						Mask: net.CIDRMask(64, 128),
+					},
+				})
+			})
+			It("uses the ipv4 address as the container address and records the ipv6 address", func() {
+				conf, err := configCreator.Create(hostNS, addCmdArgs, ipamResult, 1450)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(conf.Container.Address.IP).To(Equal(ipamResult.IPs[0].Address.IP))
+				Expect(conf.Container.IPv6Address).To(Equal(net.ParseIP("fd00:abcd:0:1e00::2")))
+			})
+		})
+
 		Context("when the args interface name is blank", func() {
 			BeforeEach(func() {
 				addCmdArgs.IfName = ""
@@ -65,5 +65,22 @@ var _ = Describe("Config", func() {
 
 			Expect(result.Routes).To(ConsistOf(cfg.Container.Routes))
 		})
+
+		Context("when the container has an ipv6 address", func() {
+			BeforeEach(func() {
+				cfg.Container.IPv6Address = net.ParseIP("fd00:abcd:0:1e00::5")
+			})
+
+			It("includes the ipv6 address in the result", func() {
+				result := cfg.AsCNIResult()
+				Expect(result.IPs).To(HaveLen(2))
+				Expect(result.IPs[0].Address.String()).To(Equal("10.255.30.5/32"))
+
+				index := result.IPs[1].Interface
+				Expect(result.Interfaces[*index].Name).To(Equal("container-device-name"))
+				Expect(result.IPs[1].Address.String()).To(Equal("fd00:abcd:0:1e00::5/128"))
+				Expect(result.IPs[1].Gateway).To(BeNil())
+			})
+		})
 	})
 })
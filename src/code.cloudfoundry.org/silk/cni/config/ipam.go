@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"path/filepath"
@@ -34,26 +35,94 @@ type HostLocalIPAM struct {
 	IPAM       IPAMConfig `json:"ipam"`
 }
 
-type IPAMConfigGenerator struct{}
+// IPAMConfigGenerator adds a second range for IPv6Subnet when it is set, so
+// that host-local hands out an IPv6 address alongside the IPv4 one.
+//
+// The containers are addressed point to point, with a /32 and the host side
+// of their veth as the peer, so they need neither a gateway nor a broadcast
+// address in the subnet of the cell. With PeerScoped, host-local hands out
+// the second and the last address of the subnet too, and only keeps the first
+// one, which the VTEP holds. The broadcast address of OverlayNetwork is still
+// kept, since the VTEPs of the cells treat it as a broadcast.
+type IPAMConfigGenerator struct {
+	IPv6Subnet     string
+	PeerScoped     bool
+	OverlayNetwork string
+}
 
-func (IPAMConfigGenerator) GenerateConfig(subnet, network, dataDirPath string) (*HostLocalIPAM, error) {
+func (g IPAMConfigGenerator) GenerateConfig(subnet, network, dataDirPath string) (*HostLocalIPAM, error) {
 	subnetAsIPNet, err := types.ParseCIDR(subnet)
 	if err != nil {
 		return nil, fmt.Errorf("invalid subnet: %s", err)
 	}
 
+	ipv4Range := Range{
+		Subnet: types.IPNet(*subnetAsIPNet),
+	}
+	if g.PeerScoped {
+		ipv4Range, err = g.peerScopedRange(subnetAsIPNet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ranges := []RangeSet{
+		[]Range{ipv4Range},
+	}
+
+	if g.IPv6Subnet != "" {
+		ipv6SubnetAsIPNet, err := types.ParseCIDR(g.IPv6Subnet)
+		if err != nil || ipv6SubnetAsIPNet.IP.To4() != nil {
+			return nil, fmt.Errorf("invalid ipv6 subnet: %s", g.IPv6Subnet)
+		}
+		ranges = append(ranges, []Range{{
+			Subnet: types.IPNet(*ipv6SubnetAsIPNet),
+		}})
+	}
+
 	return &HostLocalIPAM{
 		CNIVersion: "1.0.0",
 		Name:       network,
 		IPAM: IPAMConfig{
-			Type: "host-local",
-			Ranges: []RangeSet{
-				[]Range{{
-					Subnet: types.IPNet(*subnetAsIPNet),
-				}},
-			},
+			Type:    "host-local",
+			Ranges:  ranges,
 			Routes:  []*types.Route{},
 			DataDir: filepath.Join(dataDirPath, "ipam"),
 		},
 	}, nil
 }
+
+func (g IPAMConfigGenerator) peerScopedRange(subnet *net.IPNet) (Range, error) {
+	_, overlayNetwork, err := net.ParseCIDR(g.OverlayNetwork)
+	if err != nil || overlayNetwork.IP.To4() == nil {
+		return Range{}, fmt.Errorf("invalid overlay network: %q", g.OverlayNetwork)
+	}
+
+	ones, bits := subnet.Mask.Size()
+	if subnet.IP.To4() == nil || ones > bits-2 {
+		return Range{}, fmt.Errorf("subnet %s too small for peer scoped addressing", subnet)
+	}
+
+	first := binary.BigEndian.Uint32(subnet.IP.To4())
+	last := lastIPv4(subnet)
+	if last == lastIPv4(overlayNetwork) {
+		last--
+	}
+
+	return Range{
+		Subnet:     types.IPNet(*subnet),
+		Gateway:    uint32ToIP(first),
+		RangeStart: uint32ToIP(first + 1),
+		RangeEnd:   uint32ToIP(last),
+	}, nil
+}
+
+func lastIPv4(network *net.IPNet) uint32 {
+	return binary.BigEndian.Uint32(network.IP.To4()) | ^binary.BigEndian.Uint32(net.IP(network.Mask).To4())
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
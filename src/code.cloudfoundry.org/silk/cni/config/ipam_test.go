@@ -1,6 +1,8 @@
 package config_test
 
 import (
+	"net"
+
 	"code.cloudfoundry.org/silk/cni/config"
 	"github.com/containernetworking/cni/pkg/types"
 	. "github.com/onsi/ginkgo/v2"
@@ -34,6 +36,97 @@ var _ = Describe("Ipam config generation", func() {
 				},
 			}))
 	})
+	Context("when an ipv6 subnet is set", func() {
+		It("adds a range for the ipv6 subnet", func() {
+			generator := config.IPAMConfigGenerator{IPv6Subnet: "fd00:abcd:0:1e00::/64"}
+			ipamConfig, err := generator.GenerateConfig("10.255.30.0/24", "some-network-name", "/some/data/dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			subnetAsIPNet, err := types.ParseCIDR("10.255.30.0/24")
+			Expect(err).NotTo(HaveOccurred())
+			ipv6SubnetAsIPNet, err := types.ParseCIDR("fd00:abcd:0:1e00::/64")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipamConfig.IPAM.Ranges).To(Equal([]config.RangeSet{
+				[]config.Range{{Subnet: types.IPNet(*subnetAsIPNet)}},
+				[]config.Range{{Subnet: types.IPNet(*ipv6SubnetAsIPNet)}},
+			}))
+		})
+
+		Context("when the ipv6 subnet is not an ipv6 subnet", func() {
+			It("returns an error", func() {
+				generator := config.IPAMConfigGenerator{IPv6Subnet: "10.255.30.0/24"}
+				_, err := generator.GenerateConfig("10.255.30.0/24", "some-network-name", "/some/data/dir")
+				Expect(err).To(MatchError("invalid ipv6 subnet: 10.255.30.0/24"))
+			})
+		})
+	})
+
+	Context("when peer scoped addressing is enabled", func() {
+		var generator config.IPAMConfigGenerator
+
+		BeforeEach(func() {
+			generator = config.IPAMConfigGenerator{
+				PeerScoped:     true,
+				OverlayNetwork: "10.255.0.0/16",
+			}
+		})
+
+		It("hands out every address of the subnet but the first one", func() {
+			ipamConfig, err := generator.GenerateConfig("10.255.30.0/24", "some-network-name", "/some/data/dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			subnetAsIPNet, err := types.ParseCIDR("10.255.30.0/24")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipamConfig.IPAM.Ranges).To(Equal([]config.RangeSet{
+				[]config.Range{{
+					Subnet:     types.IPNet(*subnetAsIPNet),
+					Gateway:    net.ParseIP("10.255.30.0").To4(),
+					RangeStart: net.ParseIP("10.255.30.1").To4(),
+					RangeEnd:   net.ParseIP("10.255.30.255").To4(),
+				}},
+			}))
+		})
+
+		Context("when the subnet ends with the overlay network", func() {
+			It("keeps the broadcast address of the overlay network", func() {
+				ipamConfig, err := generator.GenerateConfig("10.255.255.0/24", "some-network-name", "/some/data/dir")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ipamConfig.IPAM.Ranges[0][0].RangeStart).To(Equal(net.ParseIP("10.255.255.1").To4()))
+				Expect(ipamConfig.IPAM.Ranges[0][0].RangeEnd).To(Equal(net.ParseIP("10.255.255.254").To4()))
+			})
+		})
+
+		Context("when an ipv6 subnet is set", func() {
+			It("leaves the ipv6 range alone", func() {
+				generator.IPv6Subnet = "fd00:abcd:0:1e00::/64"
+				ipamConfig, err := generator.GenerateConfig("10.255.30.0/24", "some-network-name", "/some/data/dir")
+				Expect(err).NotTo(HaveOccurred())
+
+				ipv6SubnetAsIPNet, err := types.ParseCIDR("fd00:abcd:0:1e00::/64")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ipamConfig.IPAM.Ranges[1]).To(Equal(config.RangeSet{{Subnet: types.IPNet(*ipv6SubnetAsIPNet)}}))
+			})
+		})
+
+		Context("when the overlay network is invalid", func() {
+			It("returns an error", func() {
+				generator.OverlayNetwork = "fd00::/64"
+				_, err := generator.GenerateConfig("10.255.30.0/24", "some-network-name", "/some/data/dir")
+				Expect(err).To(MatchError(`invalid overlay network: "fd00::/64"`))
+			})
+		})
+
+		Context("when the subnet is too small", func() {
+			It("returns an error", func() {
+				_, err := generator.GenerateConfig("10.255.30.0/31", "some-network-name", "/some/data/dir")
+				Expect(err).To(MatchError("subnet 10.255.30.0/31 too small for peer scoped addressing"))
+			})
+		})
+	})
+
 	Context("when the subnet is invalid", func() {
 		It("returns an error", func() {
 			generator := config.IPAMConfigGenerator{}
@@ -0,0 +1,25 @@
+package config
+
+import "fmt"
+
+// NeighborMode is how the ends of the veth pair of a container resolve each
+// other. Static disables ARP and only relies on a permanent neighbor entry
+// for the hardware address the other end was created with. Dynamic keeps ARP
+// enabled with a longer cache, and only seeds the entry for the other end, for
+// environments where the hardware addresses of the veth pairs are not the
+// ones they were created with, such as nested virtualization.
+type NeighborMode string
+
+const (
+	NeighborStatic  NeighborMode = "static"
+	NeighborDynamic NeighborMode = "dynamic"
+)
+
+// Validate accepts the modes and the empty mode, which stands for static.
+func (m NeighborMode) Validate() error {
+	switch m {
+	case "", NeighborStatic, NeighborDynamic:
+		return nil
+	}
+	return fmt.Errorf("invalid neighbor mode %q: must be one of static or dynamic", m)
+}
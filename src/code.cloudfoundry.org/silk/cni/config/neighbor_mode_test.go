@@ -0,0 +1,22 @@
+package config_test
+
+import (
+	"code.cloudfoundry.org/silk/cni/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NeighborMode", func() {
+	DescribeTable("accepts the modes",
+		func(mode config.NeighborMode) {
+			Expect(mode.Validate()).To(Succeed())
+		},
+		Entry("static", config.NeighborStatic),
+		Entry("dynamic", config.NeighborDynamic),
+		Entry("empty", config.NeighborMode("")),
+	)
+
+	It("rejects other modes", func() {
+		Expect(config.NeighborMode("sometimes").Validate()).To(MatchError(`invalid neighbor mode "sometimes": must be one of static or dynamic`))
+	})
+})
@@ -3,6 +3,7 @@ package integration_test
 import (
 	"fmt"
 	"net/http"
+	"os"
 
 	"encoding/json"
 
@@ -79,6 +80,78 @@ var _ = Describe("errors", func() {
 			})
 		})
 
+		Context("when the reverse path filter is invalid", func() {
+			BeforeEach(func() {
+				fakeServer = startFakeDaemonInHost(daemonPort, http.StatusOK, `{"overlay_subnet": "10.255.30.0/24", "mtu": 1472}`)
+				cniStdin = fmt.Sprintf(`{
+				"cniVersion": "1.0.0",
+				"name": "my-silk-network",
+				"type": "silk",
+				"reversePathFilter": {"host": "sometimes"},
+				"dataDir": "%s",
+				"daemonPort": %d,
+				"datastore": "%s"}`, dataDir, daemonPort, datastorePath)
+			})
+			It("exits with nonzero status and prints a CNI error result as JSON to stdout", func() {
+				session := startCommandInHost("ADD", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(1))
+
+				Expect(session.Out.Contents()).To(MatchJSON(`{
+				"code": 100,
+				"msg": "discover network info",
+				"details": "invalid config: invalid reverse path filter \"sometimes\": must be one of off, strict or loose"
+				}`))
+			})
+		})
+
+		Context("when the neighbor mode is invalid", func() {
+			BeforeEach(func() {
+				fakeServer = startFakeDaemonInHost(daemonPort, http.StatusOK, `{"overlay_subnet": "10.255.30.0/24", "mtu": 1472}`)
+				cniStdin = fmt.Sprintf(`{
+				"cniVersion": "1.0.0",
+				"name": "my-silk-network",
+				"type": "silk",
+				"neighborMode": "sometimes",
+				"dataDir": "%s",
+				"daemonPort": %d,
+				"datastore": "%s"}`, dataDir, daemonPort, datastorePath)
+			})
+			It("exits with nonzero status and prints a CNI error result as JSON to stdout", func() {
+				session := startCommandInHost("ADD", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(1))
+
+				Expect(session.Out.Contents()).To(MatchJSON(`{
+				"code": 100,
+				"msg": "discover network info",
+				"details": "invalid config: invalid neighbor mode \"sometimes\": must be one of static or dynamic"
+				}`))
+			})
+		})
+
+		Context("when the delegated ipam is dhcp without flat mode", func() {
+			BeforeEach(func() {
+				fakeServer = startFakeDaemonInHost(daemonPort, http.StatusOK, `{"overlay_subnet": "10.255.30.0/24", "mtu": 1472}`)
+				cniStdin = fmt.Sprintf(`{
+				"cniVersion": "1.0.0",
+				"name": "my-silk-network",
+				"type": "silk",
+				"delegatedIPAM": {"type": "dhcp"},
+				"dataDir": "%s",
+				"daemonPort": %d,
+				"datastore": "%s"}`, dataDir, daemonPort, datastorePath)
+			})
+			It("exits with nonzero status and prints a CNI error result as JSON to stdout", func() {
+				session := startCommandInHost("ADD", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(1))
+
+				Expect(session.Out.Contents()).To(MatchJSON(`{
+				"code": 100,
+				"msg": "discover network info",
+				"details": "invalid config: delegated ipam: dhcp needs flat mode"
+				}`))
+			})
+		})
+
 		Context("when the daemon url fails to return a response", func() {
 			BeforeEach(func() {
 				if fakeServer != nil {
@@ -186,6 +259,36 @@ var _ = Describe("errors", func() {
 
 				Expect(session.Err).To(gbytes.Say(`open-netns.*/tmp/not/there.*no such file or directory`))
 			})
+
+			It("still deletes the container metadata", func() {
+				Expect(os.WriteFile(datastorePath, []byte(`{
+					"there": {"handle":"there","ip":"10.255.30.2","metadata":null},
+					"other": {"handle":"other","ip":"10.255.30.3","metadata":null}
+				}`), 0600)).To(Succeed())
+
+				session := startCommandInHost("DEL", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(0))
+
+				containerMetadata, err := os.ReadFile(datastorePath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(containerMetadata)).To(MatchJSON(`{
+					"other": {"handle":"other","ip":"10.255.30.3","metadata":null}
+				}`))
+			})
+		})
+
+		Context("when DEL is called twice", func() {
+			It("exits with zero status both times", func() {
+				session := startCommandInHost("ADD", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(0))
+
+				session = startCommandInHost("DEL", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(0))
+
+				session = startCommandInHost("DEL", cniStdin)
+				Eventually(session, cmdTimeout).Should(gexec.Exit(0))
+				Expect(session.Out.Contents()).To(BeEmpty())
+			})
 		})
 
 		Context("when the interface isn't present inside the container", func() {
@@ -595,6 +595,48 @@ var _ = Describe("Silk CNI Integration", func() {
 				"details": "failed to allocate for range 0: no IP addresses available in range set: 10.255.30.1-10.255.30.6"
 				}`))
 		})
+
+		Context("when peer scoped addressing is enabled", func() {
+			BeforeEach(func() {
+				cniStdin = cniConfigWithExtras(dataDir, datastorePath, daemonPort, map[string]interface{}{
+					"peerScopedAddressing": true,
+					"overlayNetwork":       "10.255.0.0/16",
+				})
+				for i := numIPAllocations; i < 8; i++ {
+					containerNS, err := testutils.NewNS()
+					Expect(err).NotTo(HaveOccurred())
+					containerNSList = append(containerNSList, containerNS)
+				}
+				numIPAllocations = 8
+				containerNSList = containerNSList[len(containerNSList)-numIPAllocations:]
+			})
+
+			It("allocates every ip but the first one", func() {
+				By("exhausting all ips")
+				for i := 0; i < numIPAllocations-1; i++ {
+					cniEnv["CNI_NETNS"] = containerNSList[i].Path()
+					cniEnv["CNI_CONTAINERID"] = fmt.Sprintf("test-%03d-%x", GinkgoParallelProcess(), rand.Int31())
+					sess := startCommandInHost("ADD", cniStdin)
+					Eventually(sess, cmdTimeout).Should(gexec.Exit(0))
+
+					result := cniResultForCurrentVersion(sess.Out.Contents())
+
+					Expect(result.IPs).To(HaveLen(1))
+					Expect(result.IPs[0].Address.String()).To(Equal(fmt.Sprintf("10.255.30.%d/32", i+1)))
+					Expect(result.IPs[0].Gateway.String()).To(Equal("169.254.0.1"))
+				}
+
+				cniEnv["CNI_NETNS"] = containerNSList[numIPAllocations-1].Path()
+				cniEnv["CNI_CONTAINERID"] = fmt.Sprintf("test-%03d-%x", GinkgoParallelProcess(), rand.Int31())
+				sess := startCommandInHost("ADD", cniStdin)
+				Eventually(sess, cmdTimeout).Should(gexec.Exit(1))
+				Expect(sess.Out.Contents()).To(MatchJSON(`{
+					"code": 100,
+					"msg": "run ipam plugin",
+					"details": "failed to allocate for range 0: no IP addresses available in range set: 10.255.30.1-10.255.30.7"
+					}`))
+			})
+		})
 	})
 
 	Describe("when configured to use the subnet.env file", func() {
@@ -5,6 +5,7 @@ import (
 
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/silk/cni/config"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 )
 
 // Common bevavior used by both the host-side and container-side Setup functions
@@ -18,7 +19,7 @@ const MAX_ATTEMPTS = 10
 
 // BasicSetup configures a veth device for point-to-point communication with its peer.
 // It is meant to be called by either Host.Setup or Container.Setup
-func (s *Common) BasicSetup(deviceName string, local, peer config.DualAddress) error {
+func (s *Common) BasicSetup(deviceName string, local, peer config.DualAddress, rpFilter rpfilter.Mode, neighborMode config.NeighborMode) error {
 	s.Logger.Debug("basic-device-setup", lager.Data{"deviceName": deviceName, "local": local.Hardware.String(), "peer": peer.Hardware.String()})
 	defer s.Logger.Debug("done")
 	link, err := s.NetlinkAdapter.LinkByName(deviceName)
@@ -54,21 +55,36 @@ func (s *Common) BasicSetup(deviceName string, local, peer config.DualAddress) e
 
 	s.LinkOperations.DisableIPv6(deviceName)
 
-	if err := s.LinkOperations.StaticNeighborNoARP(link, peer.IP, peer.Hardware); err != nil {
-		return fmt.Errorf("replace ARP with permanent neighbor rule: %s", err)
+	if neighborMode == config.NeighborDynamic {
+		if err := s.LinkOperations.SeedNeighborWithARP(link, peer.IP, peer.Hardware); err != nil {
+			return fmt.Errorf("seed neighbor for ARP: %s", err)
+		}
+	} else {
+		if err := s.LinkOperations.StaticNeighborNoARP(link, peer.IP, peer.Hardware); err != nil {
+			return fmt.Errorf("replace ARP with permanent neighbor rule: %s", err)
+		}
 	}
 
 	if err := s.LinkOperations.SetPointToPointAddress(link, local.IP, peer.IP); err != nil {
 		return fmt.Errorf("setting point to point address: %s", err)
 	}
 
-	if err := s.LinkOperations.EnableReversePathFiltering(deviceName); err != nil {
-		return fmt.Errorf("enable reverse path filtering: %s", err)
+	if err := s.LinkOperations.SetReversePathFilter(deviceName, rpFilter); err != nil {
+		return fmt.Errorf("set reverse path filter: %s", err)
 	}
 
 	if err := s.NetlinkAdapter.LinkSetUp(link); err != nil {
 		return fmt.Errorf("setting link %s up: %s", deviceName, err)
 	}
 
+	// with ARP on, the other end may already have resolved this end, e.g.
+	// to a hardware address that changed since, so it is told the current
+	// one. Its first packets are only slower without it.
+	if neighborMode == config.NeighborDynamic {
+		if err := s.LinkOperations.AnnounceAddress(deviceName, local.IP); err != nil {
+			s.Logger.Error("failed-to-announce-address", err)
+		}
+	}
+
 	return nil
 }
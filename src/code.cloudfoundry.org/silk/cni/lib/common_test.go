@@ -8,8 +8,10 @@ import (
 	"code.cloudfoundry.org/silk/cni/config"
 	"code.cloudfoundry.org/silk/cni/lib"
 	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/vishvananda/netlink"
 )
 
@@ -59,7 +61,7 @@ var _ = Describe("Common", func() {
 		})
 
 		It("sets up a veth device", func() {
-			err := common.BasicSetup(deviceName, local, peer)
+			err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(fakeNetlinkAdapter.LinkByNameCallCount()).To(Equal(2))
 			Expect(fakeNetlinkAdapter.LinkByNameArgsForCall(0)).To(Equal("myDeviceName"))
@@ -81,11 +83,65 @@ var _ = Describe("Common", func() {
 			Expect(localIP).To(Equal(local.IP))
 			Expect(peerIP).To(Equal(peer.IP))
 
-			Expect(fakeLinkOperations.EnableReversePathFilteringCallCount()).To(Equal(1))
-			Expect(fakeLinkOperations.EnableReversePathFilteringArgsForCall(0)).To(Equal("myDeviceName"))
+			Expect(fakeLinkOperations.SetReversePathFilterCallCount()).To(Equal(1))
+			device, mode := fakeLinkOperations.SetReversePathFilterArgsForCall(0)
+			Expect(device).To(Equal("myDeviceName"))
+			Expect(mode).To(Equal(rpfilter.Strict))
 
 			Expect(fakeNetlinkAdapter.LinkSetUpCallCount()).To(Equal(1))
 			Expect(fakeNetlinkAdapter.LinkSetUpArgsForCall(0)).To(Equal(fakeLink))
+
+			Expect(fakeLinkOperations.AnnounceAddressCallCount()).To(Equal(0))
+		})
+
+		Context("when the neighbor mode is dynamic", func() {
+			It("seeds the neighbor of the peer and keeps ARP", func() {
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborDynamic)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLinkOperations.StaticNeighborNoARPCallCount()).To(Equal(0))
+				Expect(fakeLinkOperations.SeedNeighborWithARPCallCount()).To(Equal(1))
+				link, peerIP, peerHardwareAddr := fakeLinkOperations.SeedNeighborWithARPArgsForCall(0)
+				Expect(link).To(Equal(fakeLink))
+				Expect(peerIP).To(Equal(peer.IP))
+				Expect(peerHardwareAddr).To(Equal(peer.Hardware))
+			})
+
+			It("announces the local address once the link is up", func() {
+				fakeLinkOperations.AnnounceAddressStub = func(string, net.IP) error {
+					Expect(fakeNetlinkAdapter.LinkSetUpCallCount()).To(Equal(1))
+					return nil
+				}
+
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborDynamic)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLinkOperations.AnnounceAddressCallCount()).To(Equal(1))
+				device, ip := fakeLinkOperations.AnnounceAddressArgsForCall(0)
+				Expect(device).To(Equal("myDeviceName"))
+				Expect(ip).To(Equal(local.IP))
+			})
+
+			Context("when announcing the local address fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.AnnounceAddressReturns(errors.New("cranberry"))
+				})
+				It("logs the error and sets up the device", func() {
+					err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborDynamic)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakelogger).To(gbytes.Say("failed-to-announce-address.*cranberry"))
+				})
+			})
+
+			Context("when seeding the neighbor fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.SeedNeighborWithARPReturns(errors.New("blackberry"))
+				})
+				It("wraps and returns the error", func() {
+					err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborDynamic)
+					Expect(err).To(Equal(errors.New("seed neighbor for ARP: blackberry")))
+				})
+			})
 		})
 
 		Context("when the link cannot be found", func() {
@@ -93,7 +149,7 @@ var _ = Describe("Common", func() {
 				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("strawberry"))
 			})
 			It("wraps and returns the error", func() {
-				err := common.BasicSetup(deviceName, local, peer)
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 				Expect(err).To(Equal(errors.New("failed to find link \"myDeviceName\": strawberry")))
 
 			})
@@ -130,7 +186,7 @@ var _ = Describe("Common", func() {
 					fakeNetlinkAdapter.LinkSetHardwareAddrReturns(errors.New("apple"))
 				})
 				It("wraps and returns the error", func() {
-					err := common.BasicSetup(deviceName, local, peer)
+					err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 					Expect(err).To(Equal(errors.New("setting hardware address: apple")))
 				})
 			})
@@ -146,7 +202,7 @@ var _ = Describe("Common", func() {
 				})
 
 				It("retries and eventually sets the right address", func() {
-					err := common.BasicSetup(deviceName, local, peer)
+					err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(fakeNetlinkAdapter.LinkSetHardwareAddrCallCount()).To(Equal(2))
 					link, hwAddr := fakeNetlinkAdapter.LinkSetHardwareAddrArgsForCall(1)
@@ -161,7 +217,7 @@ var _ = Describe("Common", func() {
 				})
 
 				It("runs out of retries and wraps and returns an error", func() {
-					err := common.BasicSetup(deviceName, local, peer)
+					err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("failed to set hardware addr"))
 				})
@@ -173,7 +229,7 @@ var _ = Describe("Common", func() {
 				fakeLinkOperations.DisableIPv6Returns(errors.New("kiwi"))
 			})
 			It("ignores the error", func() {
-				err := common.BasicSetup(deviceName, local, peer)
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 				Expect(err).NotTo(HaveOccurred())
 			})
 		})
@@ -183,7 +239,7 @@ var _ = Describe("Common", func() {
 				fakeLinkOperations.StaticNeighborNoARPReturns(errors.New("raspberry"))
 			})
 			It("wraps and returns the error", func() {
-				err := common.BasicSetup(deviceName, local, peer)
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 				Expect(err).To(Equal(errors.New("replace ARP with permanent neighbor rule: raspberry")))
 			})
 		})
@@ -193,18 +249,18 @@ var _ = Describe("Common", func() {
 				fakeLinkOperations.SetPointToPointAddressReturns(errors.New("dragonfruit"))
 			})
 			It("wraps and returns the error", func() {
-				err := common.BasicSetup(deviceName, local, peer)
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 				Expect(err).To(Equal(errors.New("setting point to point address: dragonfruit")))
 			})
 		})
 
-		Context("when enabling reverse path filtering fails", func() {
+		Context("when setting the reverse path filter fails", func() {
 			BeforeEach(func() {
-				fakeLinkOperations.EnableReversePathFilteringReturns(errors.New("pomegranate"))
+				fakeLinkOperations.SetReversePathFilterReturns(errors.New("pomegranate"))
 			})
 			It("wraps and returns the error", func() {
-				err := common.BasicSetup(deviceName, local, peer)
-				Expect(err).To(Equal(errors.New("enable reverse path filtering: pomegranate")))
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
+				Expect(err).To(Equal(errors.New("set reverse path filter: pomegranate")))
 			})
 		})
 
@@ -213,7 +269,7 @@ var _ = Describe("Common", func() {
 				fakeNetlinkAdapter.LinkSetUpReturns(errors.New("cantaloupe"))
 			})
 			It("wraps and returns the error", func() {
-				err := common.BasicSetup(deviceName, local, peer)
+				err := common.BasicSetup(deviceName, local, peer, rpfilter.Strict, config.NeighborStatic)
 				Expect(err).To(Equal(errors.New("setting link myDeviceName up: cantaloupe")))
 			})
 		})
@@ -44,10 +44,21 @@ func (c *Container) Setup(cfg *config.Config) error {
 			return fmt.Errorf("renaming link in container: %s", err)
 		}
 
-		if err := c.Common.BasicSetup(deviceName, local, peer); err != nil {
+		if err := c.Common.BasicSetup(deviceName, local, peer, cfg.Container.ReversePathFilter, cfg.Container.NeighborMode); err != nil {
 			return fmt.Errorf("setting up device in container: %s", err)
 		}
 
+		if cfg.Container.IPv6Address != nil {
+			if err := c.LinkOperations.AddIPv6Address(deviceName, cfg.Container.IPv6Address); err != nil {
+				return fmt.Errorf("adding ipv6 address in container: %s", err)
+			}
+			if cfg.Container.NeighborMode == config.NeighborDynamic {
+				if err := c.LinkOperations.AnnounceAddress(deviceName, cfg.Container.IPv6Address); err != nil {
+					c.Logger.Error("failed-to-announce-address", err)
+				}
+			}
+		}
+
 		if err := c.LinkOperations.RouteAddAll(cfg.Container.Routes, cfg.Container.Address.IP); err != nil {
 			return fmt.Errorf("adding route in container: %s", err)
 		}
@@ -8,9 +8,11 @@ import (
 	"code.cloudfoundry.org/silk/cni/config"
 	"code.cloudfoundry.org/silk/cni/lib"
 	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	"github.com/containernetworking/cni/pkg/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("Container Setup", func() {
@@ -42,6 +44,8 @@ var _ = Describe("Container Setup", func() {
 		cfg.Container.TemporaryDeviceName = "someTemporaryDeviceName"
 		cfg.Container.Address = containerAddr
 		cfg.Host.Address = hostAddr
+		cfg.Container.ReversePathFilter = rpfilter.Loose
+		cfg.Container.NeighborMode = config.NeighborDynamic
 		cfg.Container.Routes = []*types.Route{
 			&types.Route{
 				Dst: net.IPNet{
@@ -83,16 +87,76 @@ var _ = Describe("Container Setup", func() {
 			Expect(newName).To(Equal("eth0"))
 
 			Expect(fakeCommon.BasicSetupCallCount()).To(Equal(1))
-			device, local, peer := fakeCommon.BasicSetupArgsForCall(0)
+			device, local, peer, rpFilter, neighborMode := fakeCommon.BasicSetupArgsForCall(0)
 			Expect(device).To(Equal("eth0"))
 			Expect(local).To(Equal(containerAddr))
 			Expect(peer).To(Equal(hostAddr))
+			Expect(rpFilter).To(Equal(rpfilter.Loose))
+			Expect(neighborMode).To(Equal(config.NeighborDynamic))
 
 			By("Adding all the routes")
 			Expect(fakeLinkOperations.RouteAddAllCallCount()).To(Equal(1))
 			routes, srcIP := fakeLinkOperations.RouteAddAllArgsForCall(0)
 			Expect(routes).To(Equal(cfg.Container.Routes))
 			Expect(srcIP).To(Equal(cfg.Container.Address.IP))
+
+			Expect(fakeLinkOperations.AddIPv6AddressCallCount()).To(Equal(0))
+		})
+
+		Context("when the container has an ipv6 address", func() {
+			BeforeEach(func() {
+				cfg.Container.IPv6Address = net.ParseIP("fd00:abcd:0:1e00::4")
+			})
+			It("adds the ipv6 address to the device", func() {
+				err := containerSetup.Setup(cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLinkOperations.AddIPv6AddressCallCount()).To(Equal(1))
+				device, ip := fakeLinkOperations.AddIPv6AddressArgsForCall(0)
+				Expect(device).To(Equal("eth0"))
+				Expect(ip).To(Equal(net.ParseIP("fd00:abcd:0:1e00::4")))
+			})
+
+			It("announces the ipv6 address", func() {
+				err := containerSetup.Setup(cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLinkOperations.AnnounceAddressCallCount()).To(Equal(1))
+				device, ip := fakeLinkOperations.AnnounceAddressArgsForCall(0)
+				Expect(device).To(Equal("eth0"))
+				Expect(ip).To(Equal(net.ParseIP("fd00:abcd:0:1e00::4")))
+			})
+
+			Context("when the neighbor mode is static", func() {
+				BeforeEach(func() {
+					cfg.Container.NeighborMode = config.NeighborStatic
+				})
+				It("does not announce the ipv6 address", func() {
+					Expect(containerSetup.Setup(cfg)).To(Succeed())
+					Expect(fakeLinkOperations.AnnounceAddressCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when announcing the ipv6 address fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.AnnounceAddressReturns(errors.New("turnip"))
+				})
+				It("logs the error and sets up the container", func() {
+					Expect(containerSetup.Setup(cfg)).To(Succeed())
+					Expect(fakelogger).To(gbytes.Say("failed-to-announce-address.*turnip"))
+					Expect(fakeLinkOperations.RouteAddAllCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when adding the ipv6 address fails", func() {
+				BeforeEach(func() {
+					fakeLinkOperations.AddIPv6AddressReturns(errors.New("radish"))
+				})
+				It("returns a meaningful error", func() {
+					err := containerSetup.Setup(cfg)
+					Expect(err).To(MatchError("adding ipv6 address in container: radish"))
+				})
+			})
 		})
 
 		Context("when renaming the link fails", func() {
@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+)
+
+// IPAMTypeDHCP is the dhcp plugin of the CNI plugins, which gets the
+// addresses from a DHCP server through its daemon.
+const IPAMTypeDHCP = "dhcp"
+
+const defaultHostNetNS = "/proc/1/ns/net"
+
+//go:generate counterfeiter -o fakes/ipamInvoker.go --fake-name IPAMInvoker . ipamInvoker
+type ipamInvoker interface {
+	ExecPluginWithResult(ctx context.Context, plugin string, netconf []byte, args *invoke.Args) (types.Result, error)
+	ExecPluginWithoutResult(ctx context.Context, plugin string, netconf []byte, args *invoke.Args) error
+}
+
+// DelegatedIPAMConfig is the IPAM plugin that assigns the addresses of the
+// containers instead of host-local.
+type DelegatedIPAMConfig struct {
+	// Type is the plugin, which is looked up in PluginDir and then in the
+	// directories of CNI_PATH.
+	Type      string `json:"type"`
+	PluginDir string `json:"pluginDir"`
+	// Config is the ipam section of the netconf the plugin is called with,
+	// without its type.
+	Config map[string]interface{} `json:"config"`
+	// HostNetNS is the network namespace the dhcp plugin takes the leases
+	// in, /proc/1/ns/net if empty.
+	HostNetNS string `json:"hostNetns"`
+}
+
+func (c DelegatedIPAMConfig) Validate(flat *FlatConfig) error {
+	if c.Type == "" {
+		return errors.New("delegated ipam: missing type")
+	}
+	if c.Type == IPAMTypeDHCP && flat == nil {
+		return errors.New("delegated ipam: dhcp needs flat mode")
+	}
+	return nil
+}
+
+// DelegatedIPAM gets the addresses of the containers from an IPAM plugin of
+// the operator. The plugin only assigns the addresses: the veth pairs, the
+// routes and the container metadata are set up by the silk plugin as for the
+// addresses of host-local, so the netout, netin and policy chains apply to
+// them as well.
+//
+// The dhcp plugin is not called for the interface of the container, which
+// does not exist yet and is not on a segment with a DHCP server. It takes the
+// lease on the underlay interface of flat mode, in the network namespace of
+// the host, with a client id of its own per container, so that the containers
+// get addresses of the routed segment, which flat mode answers ARP and NDP
+// for.
+type DelegatedIPAM struct {
+	Invoker ipamInvoker
+	Logger  lager.Logger
+}
+
+func (d *DelegatedIPAM) Add(cfg DelegatedIPAMConfig, flat *FlatConfig, args *skel.CmdArgs, network, cniVersion string) (*current.Result, error) {
+	netconf, err := cfg.netconf(network, cniVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Logger.Debug("add", lager.Data{"type": cfg.Type, "netconf": string(netconf)})
+	result, err := d.Invoker.ExecPluginWithResult(context.Background(), cfg.Type, netconf, cfg.args("ADD", flat, args))
+	if err != nil {
+		return nil, err
+	}
+
+	cniResult, err := current.NewResultFromResult(result)
+	if err != nil {
+		return nil, fmt.Errorf("convert result to current CNI version: %s", err)
+	}
+
+	// the IPv4 address goes first, since the containers are addressed by it
+	sort.SliceStable(cniResult.IPs, func(i, j int) bool {
+		return cniResult.IPs[i].Address.IP.To4() != nil && cniResult.IPs[j].Address.IP.To4() == nil
+	})
+	if len(cniResult.IPs) == 0 || cniResult.IPs[0].Address.IP.To4() == nil {
+		return nil, fmt.Errorf("no IPv4 address in the result of %s", cfg.Type)
+	}
+	return cniResult, nil
+}
+
+func (d *DelegatedIPAM) Del(cfg DelegatedIPAMConfig, flat *FlatConfig, args *skel.CmdArgs, network, cniVersion string) error {
+	netconf, err := cfg.netconf(network, cniVersion)
+	if err != nil {
+		return err
+	}
+
+	d.Logger.Debug("del", lager.Data{"type": cfg.Type})
+	return d.Invoker.ExecPluginWithoutResult(context.Background(), cfg.Type, netconf, cfg.args("DEL", flat, args))
+}
+
+func (c DelegatedIPAMConfig) netconf(network, cniVersion string) ([]byte, error) {
+	ipam := map[string]interface{}{}
+	for key, value := range c.Config {
+		ipam[key] = value
+	}
+	ipam["type"] = c.Type
+
+	netconf, err := json.Marshal(map[string]interface{}{
+		"cniVersion": cniVersion,
+		"name":       network,
+		"ipam":       ipam,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal ipam config: %s", err)
+	}
+	return netconf, nil
+}
+
+func (c DelegatedIPAMConfig) args(command string, flat *FlatConfig, args *skel.CmdArgs) *invoke.Args {
+	path := args.Path
+	if c.PluginDir != "" {
+		path = c.PluginDir + string(filepath.ListSeparator) + path
+	}
+	pluginArgs := &invoke.Args{
+		Command:       command,
+		ContainerID:   args.ContainerID,
+		NetNS:         args.Netns,
+		IfName:        args.IfName,
+		PluginArgsStr: args.Args,
+		Path:          path,
+	}
+
+	if c.Type == IPAMTypeDHCP && flat != nil {
+		pluginArgs.NetNS = c.HostNetNS
+		if pluginArgs.NetNS == "" {
+			pluginArgs.NetNS = defaultHostNetNS
+		}
+		pluginArgs.IfName = flat.UnderlayInterface
+	}
+	return pluginArgs
+}
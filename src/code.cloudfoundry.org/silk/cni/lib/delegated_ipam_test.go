@@ -0,0 +1,179 @@
+package lib_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/lib"
+	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"github.com/containernetworking/cni/pkg/skel"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DelegatedIPAM", func() {
+	var (
+		fakeInvoker   *fakes.IPAMInvoker
+		delegatedIPAM *lib.DelegatedIPAM
+		cfg           lib.DelegatedIPAMConfig
+		args          *skel.CmdArgs
+		result        *current.Result
+	)
+
+	ipConfig := func(cidr string) *current.IPConfig {
+		ip, network, err := net.ParseCIDR(cidr)
+		Expect(err).NotTo(HaveOccurred())
+		network.IP = ip
+		return &current.IPConfig{Address: *network}
+	}
+
+	BeforeEach(func() {
+		fakeInvoker = &fakes.IPAMInvoker{}
+		delegatedIPAM = &lib.DelegatedIPAM{
+			Invoker: fakeInvoker,
+			Logger:  lagertest.NewTestLogger("test"),
+		}
+		cfg = lib.DelegatedIPAMConfig{
+			Type:      "some-ipam",
+			PluginDir: "/var/vcap/packages/some-ipam/bin",
+			Config:    map[string]interface{}{"subnet": "10.0.16.0/24"},
+		}
+		args = &skel.CmdArgs{
+			ContainerID: "some-container-id",
+			Netns:       "/some/netns",
+			IfName:      "eth0",
+			Path:        "/var/vcap/packages/silk-cni/bin",
+		}
+		result = &current.Result{
+			CNIVersion: "1.0.0",
+			IPs:        []*current.IPConfig{ipConfig("10.0.16.5/24")},
+		}
+		fakeInvoker.ExecPluginWithResultReturns(result, nil)
+	})
+
+	Describe("Add", func() {
+		It("calls the plugin with its config as the ipam section", func() {
+			cniResult, err := delegatedIPAM.Add(cfg, nil, args, "some-network", "1.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cniResult.IPs[0].Address.IP.String()).To(Equal("10.0.16.5"))
+
+			Expect(fakeInvoker.ExecPluginWithResultCallCount()).To(Equal(1))
+			_, plugin, netconf, pluginArgs := fakeInvoker.ExecPluginWithResultArgsForCall(0)
+			Expect(plugin).To(Equal("some-ipam"))
+			Expect(netconf).To(MatchJSON(`{
+				"cniVersion": "1.0.0",
+				"name": "some-network",
+				"ipam": {"type": "some-ipam", "subnet": "10.0.16.0/24"}
+			}`))
+			Expect(pluginArgs.Command).To(Equal("ADD"))
+			Expect(pluginArgs.ContainerID).To(Equal("some-container-id"))
+			Expect(pluginArgs.NetNS).To(Equal("/some/netns"))
+			Expect(pluginArgs.IfName).To(Equal("eth0"))
+			Expect(pluginArgs.Path).To(Equal("/var/vcap/packages/some-ipam/bin:/var/vcap/packages/silk-cni/bin"))
+		})
+
+		It("puts the IPv4 address first", func() {
+			result.IPs = []*current.IPConfig{ipConfig("fd00::5/64"), ipConfig("10.0.16.5/24")}
+
+			cniResult, err := delegatedIPAM.Add(cfg, nil, args, "some-network", "1.0.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cniResult.IPs[0].Address.IP.String()).To(Equal("10.0.16.5"))
+			Expect(cniResult.IPs[1].Address.IP.String()).To(Equal("fd00::5"))
+		})
+
+		Context("when the plugin is dhcp", func() {
+			BeforeEach(func() {
+				cfg.Type = lib.IPAMTypeDHCP
+				cfg.Config = nil
+			})
+
+			It("takes the lease on the underlay interface in the network namespace of the host", func() {
+				_, err := delegatedIPAM.Add(cfg, &lib.FlatConfig{UnderlayInterface: "bond0"}, args, "some-network", "1.0.0")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, plugin, netconf, pluginArgs := fakeInvoker.ExecPluginWithResultArgsForCall(0)
+				Expect(plugin).To(Equal("dhcp"))
+				var parsed map[string]interface{}
+				Expect(json.Unmarshal(netconf, &parsed)).To(Succeed())
+				Expect(parsed["ipam"]).To(Equal(map[string]interface{}{"type": "dhcp"}))
+				Expect(pluginArgs.ContainerID).To(Equal("some-container-id"))
+				Expect(pluginArgs.NetNS).To(Equal("/proc/1/ns/net"))
+				Expect(pluginArgs.IfName).To(Equal("bond0"))
+			})
+
+			It("uses the configured network namespace of the host", func() {
+				cfg.HostNetNS = "/var/run/netns/host"
+				_, err := delegatedIPAM.Add(cfg, &lib.FlatConfig{UnderlayInterface: "bond0"}, args, "some-network", "1.0.0")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, _, pluginArgs := fakeInvoker.ExecPluginWithResultArgsForCall(0)
+				Expect(pluginArgs.NetNS).To(Equal("/var/run/netns/host"))
+			})
+		})
+
+		Context("when the plugin fails", func() {
+			BeforeEach(func() {
+				fakeInvoker.ExecPluginWithResultReturns(nil, errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				_, err := delegatedIPAM.Add(cfg, nil, args, "some-network", "1.0.0")
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+
+		Context("when the result has no IPv4 address", func() {
+			BeforeEach(func() {
+				result.IPs = []*current.IPConfig{ipConfig("fd00::5/64")}
+			})
+
+			It("returns an error", func() {
+				_, err := delegatedIPAM.Add(cfg, nil, args, "some-network", "1.0.0")
+				Expect(err).To(MatchError("no IPv4 address in the result of some-ipam"))
+			})
+		})
+	})
+
+	Describe("Del", func() {
+		It("releases the addresses with the same args", func() {
+			Expect(delegatedIPAM.Del(cfg, nil, args, "some-network", "1.0.0")).To(Succeed())
+
+			Expect(fakeInvoker.ExecPluginWithoutResultCallCount()).To(Equal(1))
+			_, plugin, netconf, pluginArgs := fakeInvoker.ExecPluginWithoutResultArgsForCall(0)
+			Expect(plugin).To(Equal("some-ipam"))
+			Expect(netconf).To(MatchJSON(`{
+				"cniVersion": "1.0.0",
+				"name": "some-network",
+				"ipam": {"type": "some-ipam", "subnet": "10.0.16.0/24"}
+			}`))
+			Expect(pluginArgs.Command).To(Equal("DEL"))
+			Expect(pluginArgs.NetNS).To(Equal("/some/netns"))
+		})
+
+		Context("when the plugin fails", func() {
+			BeforeEach(func() {
+				fakeInvoker.ExecPluginWithoutResultReturns(errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				err := delegatedIPAM.Del(cfg, nil, args, "some-network", "1.0.0")
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
+	Describe("DelegatedIPAMConfig", func() {
+		It("needs a type", func() {
+			Expect(lib.DelegatedIPAMConfig{}.Validate(nil)).To(MatchError("delegated ipam: missing type"))
+		})
+
+		It("needs flat mode for dhcp", func() {
+			cfg.Type = lib.IPAMTypeDHCP
+			Expect(cfg.Validate(nil)).To(MatchError("delegated ipam: dhcp needs flat mode"))
+			Expect(cfg.Validate(&lib.FlatConfig{UnderlayInterface: "bond0"})).To(Succeed())
+		})
+	})
+})
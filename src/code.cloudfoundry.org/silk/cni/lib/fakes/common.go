@@ -5,15 +5,18 @@ import (
 	"sync"
 
 	"code.cloudfoundry.org/silk/cni/config"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 )
 
 type Common struct {
-	BasicSetupStub        func(deviceName string, local, peer config.DualAddress) error
+	BasicSetupStub        func(deviceName string, local, peer config.DualAddress, rpFilter rpfilter.Mode, neighborMode config.NeighborMode) error
 	basicSetupMutex       sync.RWMutex
 	basicSetupArgsForCall []struct {
-		deviceName string
-		local      config.DualAddress
-		peer       config.DualAddress
+		deviceName   string
+		local        config.DualAddress
+		peer         config.DualAddress
+		rpFilter     rpfilter.Mode
+		neighborMode config.NeighborMode
 	}
 	basicSetupReturns struct {
 		result1 error
@@ -25,18 +28,20 @@ type Common struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *Common) BasicSetup(deviceName string, local config.DualAddress, peer config.DualAddress) error {
+func (fake *Common) BasicSetup(deviceName string, local config.DualAddress, peer config.DualAddress, rpFilter rpfilter.Mode, neighborMode config.NeighborMode) error {
 	fake.basicSetupMutex.Lock()
 	ret, specificReturn := fake.basicSetupReturnsOnCall[len(fake.basicSetupArgsForCall)]
 	fake.basicSetupArgsForCall = append(fake.basicSetupArgsForCall, struct {
-		deviceName string
-		local      config.DualAddress
-		peer       config.DualAddress
-	}{deviceName, local, peer})
-	fake.recordInvocation("BasicSetup", []interface{}{deviceName, local, peer})
+		deviceName   string
+		local        config.DualAddress
+		peer         config.DualAddress
+		rpFilter     rpfilter.Mode
+		neighborMode config.NeighborMode
+	}{deviceName, local, peer, rpFilter, neighborMode})
+	fake.recordInvocation("BasicSetup", []interface{}{deviceName, local, peer, rpFilter, neighborMode})
 	fake.basicSetupMutex.Unlock()
 	if fake.BasicSetupStub != nil {
-		return fake.BasicSetupStub(deviceName, local, peer)
+		return fake.BasicSetupStub(deviceName, local, peer, rpFilter, neighborMode)
 	}
 	if specificReturn {
 		return ret.result1
@@ -50,10 +55,10 @@ func (fake *Common) BasicSetupCallCount() int {
 	return len(fake.basicSetupArgsForCall)
 }
 
-func (fake *Common) BasicSetupArgsForCall(i int) (string, config.DualAddress, config.DualAddress) {
+func (fake *Common) BasicSetupArgsForCall(i int) (string, config.DualAddress, config.DualAddress, rpfilter.Mode, config.NeighborMode) {
 	fake.basicSetupMutex.RLock()
 	defer fake.basicSetupMutex.RUnlock()
-	return fake.basicSetupArgsForCall[i].deviceName, fake.basicSetupArgsForCall[i].local, fake.basicSetupArgsForCall[i].peer
+	return fake.basicSetupArgsForCall[i].deviceName, fake.basicSetupArgsForCall[i].local, fake.basicSetupArgsForCall[i].peer, fake.basicSetupArgsForCall[i].rpFilter, fake.basicSetupArgsForCall[i].neighborMode
 }
 
 func (fake *Common) BasicSetupReturns(result1 error) {
@@ -5,76 +5,85 @@ import (
 	"net"
 	"sync"
 
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/vishvananda/netlink"
 )
 
 type LinkOperations struct {
-	DisableIPv6Stub        func(deviceName string) error
-	disableIPv6Mutex       sync.RWMutex
-	disableIPv6ArgsForCall []struct {
-		deviceName string
+	AddIPv6AddressStub        func(string, net.IP) error
+	addIPv6AddressMutex       sync.RWMutex
+	addIPv6AddressArgsForCall []struct {
+		arg1 string
+		arg2 net.IP
 	}
-	disableIPv6Returns struct {
+	addIPv6AddressReturns struct {
 		result1 error
 	}
-	disableIPv6ReturnsOnCall map[int]struct {
+	addIPv6AddressReturnsOnCall map[int]struct {
 		result1 error
 	}
-	StaticNeighborNoARPStub        func(link netlink.Link, dstIP net.IP, mac net.HardwareAddr) error
-	staticNeighborNoARPMutex       sync.RWMutex
-	staticNeighborNoARPArgsForCall []struct {
-		link  netlink.Link
-		dstIP net.IP
-		mac   net.HardwareAddr
+	AnnounceAddressStub        func(string, net.IP) error
+	announceAddressMutex       sync.RWMutex
+	announceAddressArgsForCall []struct {
+		arg1 string
+		arg2 net.IP
 	}
-	staticNeighborNoARPReturns struct {
+	announceAddressReturns struct {
 		result1 error
 	}
-	staticNeighborNoARPReturnsOnCall map[int]struct {
+	announceAddressReturnsOnCall map[int]struct {
 		result1 error
 	}
-	SetPointToPointAddressStub        func(link netlink.Link, localIPAddr, peerIPAddr net.IP) error
-	setPointToPointAddressMutex       sync.RWMutex
-	setPointToPointAddressArgsForCall []struct {
-		link        netlink.Link
-		localIPAddr net.IP
-		peerIPAddr  net.IP
+	DeleteLinkByNameStub        func(string) error
+	deleteLinkByNameMutex       sync.RWMutex
+	deleteLinkByNameArgsForCall []struct {
+		arg1 string
 	}
-	setPointToPointAddressReturns struct {
+	deleteLinkByNameReturns struct {
 		result1 error
 	}
-	setPointToPointAddressReturnsOnCall map[int]struct {
+	deleteLinkByNameReturnsOnCall map[int]struct {
 		result1 error
 	}
-	RenameLinkStub        func(oldName, newName string) error
-	renameLinkMutex       sync.RWMutex
-	renameLinkArgsForCall []struct {
-		oldName string
-		newName string
+	DisableIPv6Stub        func(string) error
+	disableIPv6Mutex       sync.RWMutex
+	disableIPv6ArgsForCall []struct {
+		arg1 string
 	}
-	renameLinkReturns struct {
+	disableIPv6Returns struct {
 		result1 error
 	}
-	renameLinkReturnsOnCall map[int]struct {
+	disableIPv6ReturnsOnCall map[int]struct {
 		result1 error
 	}
-	DeleteLinkByNameStub        func(deviceName string) error
-	deleteLinkByNameMutex       sync.RWMutex
-	deleteLinkByNameArgsForCall []struct {
-		deviceName string
+	EnableIPv4ForwardingStub        func() error
+	enableIPv4ForwardingMutex       sync.RWMutex
+	enableIPv4ForwardingArgsForCall []struct {
 	}
-	deleteLinkByNameReturns struct {
+	enableIPv4ForwardingReturns struct {
 		result1 error
 	}
-	deleteLinkByNameReturnsOnCall map[int]struct {
+	enableIPv4ForwardingReturnsOnCall map[int]struct {
 		result1 error
 	}
-	RouteAddAllStub        func(route []*types.Route, sourceIP net.IP) error
+	RenameLinkStub        func(string, string) error
+	renameLinkMutex       sync.RWMutex
+	renameLinkArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	renameLinkReturns struct {
+		result1 error
+	}
+	renameLinkReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RouteAddAllStub        func([]*types.Route, net.IP) error
 	routeAddAllMutex       sync.RWMutex
 	routeAddAllArgsForCall []struct {
-		route    []*types.Route
-		sourceIP net.IP
+		arg1 []*types.Route
+		arg2 net.IP
 	}
 	routeAddAllReturns struct {
 		result1 error
@@ -82,296 +91,445 @@ type LinkOperations struct {
 	routeAddAllReturnsOnCall map[int]struct {
 		result1 error
 	}
-	EnableIPv4ForwardingStub        func() error
-	enableIPv4ForwardingMutex       sync.RWMutex
-	enableIPv4ForwardingArgsForCall []struct{}
-	enableIPv4ForwardingReturns     struct {
+	SeedNeighborWithARPStub        func(netlink.Link, net.IP, net.HardwareAddr) error
+	seedNeighborWithARPMutex       sync.RWMutex
+	seedNeighborWithARPArgsForCall []struct {
+		arg1 netlink.Link
+		arg2 net.IP
+		arg3 net.HardwareAddr
+	}
+	seedNeighborWithARPReturns struct {
 		result1 error
 	}
-	enableIPv4ForwardingReturnsOnCall map[int]struct {
+	seedNeighborWithARPReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SetPointToPointAddressStub        func(netlink.Link, net.IP, net.IP) error
+	setPointToPointAddressMutex       sync.RWMutex
+	setPointToPointAddressArgsForCall []struct {
+		arg1 netlink.Link
+		arg2 net.IP
+		arg3 net.IP
+	}
+	setPointToPointAddressReturns struct {
+		result1 error
+	}
+	setPointToPointAddressReturnsOnCall map[int]struct {
 		result1 error
 	}
-	EnableReversePathFilteringStub        func(deviceName string) error
-	enableReversePathFilteringMutex       sync.RWMutex
-	enableReversePathFilteringArgsForCall []struct {
-		deviceName string
+	SetReversePathFilterStub        func(string, rpfilter.Mode) error
+	setReversePathFilterMutex       sync.RWMutex
+	setReversePathFilterArgsForCall []struct {
+		arg1 string
+		arg2 rpfilter.Mode
 	}
-	enableReversePathFilteringReturns struct {
+	setReversePathFilterReturns struct {
 		result1 error
 	}
-	enableReversePathFilteringReturnsOnCall map[int]struct {
+	setReversePathFilterReturnsOnCall map[int]struct {
+		result1 error
+	}
+	StaticNeighborNoARPStub        func(netlink.Link, net.IP, net.HardwareAddr) error
+	staticNeighborNoARPMutex       sync.RWMutex
+	staticNeighborNoARPArgsForCall []struct {
+		arg1 netlink.Link
+		arg2 net.IP
+		arg3 net.HardwareAddr
+	}
+	staticNeighborNoARPReturns struct {
+		result1 error
+	}
+	staticNeighborNoARPReturnsOnCall map[int]struct {
 		result1 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *LinkOperations) DisableIPv6(deviceName string) error {
-	fake.disableIPv6Mutex.Lock()
-	ret, specificReturn := fake.disableIPv6ReturnsOnCall[len(fake.disableIPv6ArgsForCall)]
-	fake.disableIPv6ArgsForCall = append(fake.disableIPv6ArgsForCall, struct {
-		deviceName string
-	}{deviceName})
-	fake.recordInvocation("DisableIPv6", []interface{}{deviceName})
-	fake.disableIPv6Mutex.Unlock()
-	if fake.DisableIPv6Stub != nil {
-		return fake.DisableIPv6Stub(deviceName)
+func (fake *LinkOperations) AddIPv6Address(arg1 string, arg2 net.IP) error {
+	fake.addIPv6AddressMutex.Lock()
+	ret, specificReturn := fake.addIPv6AddressReturnsOnCall[len(fake.addIPv6AddressArgsForCall)]
+	fake.addIPv6AddressArgsForCall = append(fake.addIPv6AddressArgsForCall, struct {
+		arg1 string
+		arg2 net.IP
+	}{arg1, arg2})
+	stub := fake.AddIPv6AddressStub
+	fakeReturns := fake.addIPv6AddressReturns
+	fake.recordInvocation("AddIPv6Address", []interface{}{arg1, arg2})
+	fake.addIPv6AddressMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.disableIPv6Returns.result1
+	return fakeReturns.result1
 }
 
-func (fake *LinkOperations) DisableIPv6CallCount() int {
-	fake.disableIPv6Mutex.RLock()
-	defer fake.disableIPv6Mutex.RUnlock()
-	return len(fake.disableIPv6ArgsForCall)
+func (fake *LinkOperations) AddIPv6AddressCallCount() int {
+	fake.addIPv6AddressMutex.RLock()
+	defer fake.addIPv6AddressMutex.RUnlock()
+	return len(fake.addIPv6AddressArgsForCall)
 }
 
-func (fake *LinkOperations) DisableIPv6ArgsForCall(i int) string {
-	fake.disableIPv6Mutex.RLock()
-	defer fake.disableIPv6Mutex.RUnlock()
-	return fake.disableIPv6ArgsForCall[i].deviceName
+func (fake *LinkOperations) AddIPv6AddressCalls(stub func(string, net.IP) error) {
+	fake.addIPv6AddressMutex.Lock()
+	defer fake.addIPv6AddressMutex.Unlock()
+	fake.AddIPv6AddressStub = stub
 }
 
-func (fake *LinkOperations) DisableIPv6Returns(result1 error) {
-	fake.DisableIPv6Stub = nil
-	fake.disableIPv6Returns = struct {
+func (fake *LinkOperations) AddIPv6AddressArgsForCall(i int) (string, net.IP) {
+	fake.addIPv6AddressMutex.RLock()
+	defer fake.addIPv6AddressMutex.RUnlock()
+	argsForCall := fake.addIPv6AddressArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *LinkOperations) AddIPv6AddressReturns(result1 error) {
+	fake.addIPv6AddressMutex.Lock()
+	defer fake.addIPv6AddressMutex.Unlock()
+	fake.AddIPv6AddressStub = nil
+	fake.addIPv6AddressReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) DisableIPv6ReturnsOnCall(i int, result1 error) {
-	fake.DisableIPv6Stub = nil
-	if fake.disableIPv6ReturnsOnCall == nil {
-		fake.disableIPv6ReturnsOnCall = make(map[int]struct {
+func (fake *LinkOperations) AddIPv6AddressReturnsOnCall(i int, result1 error) {
+	fake.addIPv6AddressMutex.Lock()
+	defer fake.addIPv6AddressMutex.Unlock()
+	fake.AddIPv6AddressStub = nil
+	if fake.addIPv6AddressReturnsOnCall == nil {
+		fake.addIPv6AddressReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.disableIPv6ReturnsOnCall[i] = struct {
+	fake.addIPv6AddressReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) StaticNeighborNoARP(link netlink.Link, dstIP net.IP, mac net.HardwareAddr) error {
-	fake.staticNeighborNoARPMutex.Lock()
-	ret, specificReturn := fake.staticNeighborNoARPReturnsOnCall[len(fake.staticNeighborNoARPArgsForCall)]
-	fake.staticNeighborNoARPArgsForCall = append(fake.staticNeighborNoARPArgsForCall, struct {
-		link  netlink.Link
-		dstIP net.IP
-		mac   net.HardwareAddr
-	}{link, dstIP, mac})
-	fake.recordInvocation("StaticNeighborNoARP", []interface{}{link, dstIP, mac})
-	fake.staticNeighborNoARPMutex.Unlock()
-	if fake.StaticNeighborNoARPStub != nil {
-		return fake.StaticNeighborNoARPStub(link, dstIP, mac)
+func (fake *LinkOperations) AnnounceAddress(arg1 string, arg2 net.IP) error {
+	fake.announceAddressMutex.Lock()
+	ret, specificReturn := fake.announceAddressReturnsOnCall[len(fake.announceAddressArgsForCall)]
+	fake.announceAddressArgsForCall = append(fake.announceAddressArgsForCall, struct {
+		arg1 string
+		arg2 net.IP
+	}{arg1, arg2})
+	stub := fake.AnnounceAddressStub
+	fakeReturns := fake.announceAddressReturns
+	fake.recordInvocation("AnnounceAddress", []interface{}{arg1, arg2})
+	fake.announceAddressMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.staticNeighborNoARPReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *LinkOperations) StaticNeighborNoARPCallCount() int {
-	fake.staticNeighborNoARPMutex.RLock()
-	defer fake.staticNeighborNoARPMutex.RUnlock()
-	return len(fake.staticNeighborNoARPArgsForCall)
+func (fake *LinkOperations) AnnounceAddressCallCount() int {
+	fake.announceAddressMutex.RLock()
+	defer fake.announceAddressMutex.RUnlock()
+	return len(fake.announceAddressArgsForCall)
 }
 
-func (fake *LinkOperations) StaticNeighborNoARPArgsForCall(i int) (netlink.Link, net.IP, net.HardwareAddr) {
-	fake.staticNeighborNoARPMutex.RLock()
-	defer fake.staticNeighborNoARPMutex.RUnlock()
-	return fake.staticNeighborNoARPArgsForCall[i].link, fake.staticNeighborNoARPArgsForCall[i].dstIP, fake.staticNeighborNoARPArgsForCall[i].mac
+func (fake *LinkOperations) AnnounceAddressCalls(stub func(string, net.IP) error) {
+	fake.announceAddressMutex.Lock()
+	defer fake.announceAddressMutex.Unlock()
+	fake.AnnounceAddressStub = stub
 }
 
-func (fake *LinkOperations) StaticNeighborNoARPReturns(result1 error) {
-	fake.StaticNeighborNoARPStub = nil
-	fake.staticNeighborNoARPReturns = struct {
+func (fake *LinkOperations) AnnounceAddressArgsForCall(i int) (string, net.IP) {
+	fake.announceAddressMutex.RLock()
+	defer fake.announceAddressMutex.RUnlock()
+	argsForCall := fake.announceAddressArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *LinkOperations) AnnounceAddressReturns(result1 error) {
+	fake.announceAddressMutex.Lock()
+	defer fake.announceAddressMutex.Unlock()
+	fake.AnnounceAddressStub = nil
+	fake.announceAddressReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) StaticNeighborNoARPReturnsOnCall(i int, result1 error) {
-	fake.StaticNeighborNoARPStub = nil
-	if fake.staticNeighborNoARPReturnsOnCall == nil {
-		fake.staticNeighborNoARPReturnsOnCall = make(map[int]struct {
+func (fake *LinkOperations) AnnounceAddressReturnsOnCall(i int, result1 error) {
+	fake.announceAddressMutex.Lock()
+	defer fake.announceAddressMutex.Unlock()
+	fake.AnnounceAddressStub = nil
+	if fake.announceAddressReturnsOnCall == nil {
+		fake.announceAddressReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.staticNeighborNoARPReturnsOnCall[i] = struct {
+	fake.announceAddressReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) SetPointToPointAddress(link netlink.Link, localIPAddr net.IP, peerIPAddr net.IP) error {
-	fake.setPointToPointAddressMutex.Lock()
-	ret, specificReturn := fake.setPointToPointAddressReturnsOnCall[len(fake.setPointToPointAddressArgsForCall)]
-	fake.setPointToPointAddressArgsForCall = append(fake.setPointToPointAddressArgsForCall, struct {
-		link        netlink.Link
-		localIPAddr net.IP
-		peerIPAddr  net.IP
-	}{link, localIPAddr, peerIPAddr})
-	fake.recordInvocation("SetPointToPointAddress", []interface{}{link, localIPAddr, peerIPAddr})
-	fake.setPointToPointAddressMutex.Unlock()
-	if fake.SetPointToPointAddressStub != nil {
-		return fake.SetPointToPointAddressStub(link, localIPAddr, peerIPAddr)
+func (fake *LinkOperations) DeleteLinkByName(arg1 string) error {
+	fake.deleteLinkByNameMutex.Lock()
+	ret, specificReturn := fake.deleteLinkByNameReturnsOnCall[len(fake.deleteLinkByNameArgsForCall)]
+	fake.deleteLinkByNameArgsForCall = append(fake.deleteLinkByNameArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.DeleteLinkByNameStub
+	fakeReturns := fake.deleteLinkByNameReturns
+	fake.recordInvocation("DeleteLinkByName", []interface{}{arg1})
+	fake.deleteLinkByNameMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.setPointToPointAddressReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *LinkOperations) SetPointToPointAddressCallCount() int {
-	fake.setPointToPointAddressMutex.RLock()
-	defer fake.setPointToPointAddressMutex.RUnlock()
-	return len(fake.setPointToPointAddressArgsForCall)
+func (fake *LinkOperations) DeleteLinkByNameCallCount() int {
+	fake.deleteLinkByNameMutex.RLock()
+	defer fake.deleteLinkByNameMutex.RUnlock()
+	return len(fake.deleteLinkByNameArgsForCall)
 }
 
-func (fake *LinkOperations) SetPointToPointAddressArgsForCall(i int) (netlink.Link, net.IP, net.IP) {
-	fake.setPointToPointAddressMutex.RLock()
-	defer fake.setPointToPointAddressMutex.RUnlock()
-	return fake.setPointToPointAddressArgsForCall[i].link, fake.setPointToPointAddressArgsForCall[i].localIPAddr, fake.setPointToPointAddressArgsForCall[i].peerIPAddr
+func (fake *LinkOperations) DeleteLinkByNameCalls(stub func(string) error) {
+	fake.deleteLinkByNameMutex.Lock()
+	defer fake.deleteLinkByNameMutex.Unlock()
+	fake.DeleteLinkByNameStub = stub
 }
 
-func (fake *LinkOperations) SetPointToPointAddressReturns(result1 error) {
-	fake.SetPointToPointAddressStub = nil
-	fake.setPointToPointAddressReturns = struct {
+func (fake *LinkOperations) DeleteLinkByNameArgsForCall(i int) string {
+	fake.deleteLinkByNameMutex.RLock()
+	defer fake.deleteLinkByNameMutex.RUnlock()
+	argsForCall := fake.deleteLinkByNameArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LinkOperations) DeleteLinkByNameReturns(result1 error) {
+	fake.deleteLinkByNameMutex.Lock()
+	defer fake.deleteLinkByNameMutex.Unlock()
+	fake.DeleteLinkByNameStub = nil
+	fake.deleteLinkByNameReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) SetPointToPointAddressReturnsOnCall(i int, result1 error) {
-	fake.SetPointToPointAddressStub = nil
-	if fake.setPointToPointAddressReturnsOnCall == nil {
-		fake.setPointToPointAddressReturnsOnCall = make(map[int]struct {
+func (fake *LinkOperations) DeleteLinkByNameReturnsOnCall(i int, result1 error) {
+	fake.deleteLinkByNameMutex.Lock()
+	defer fake.deleteLinkByNameMutex.Unlock()
+	fake.DeleteLinkByNameStub = nil
+	if fake.deleteLinkByNameReturnsOnCall == nil {
+		fake.deleteLinkByNameReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.setPointToPointAddressReturnsOnCall[i] = struct {
+	fake.deleteLinkByNameReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) RenameLink(oldName string, newName string) error {
-	fake.renameLinkMutex.Lock()
-	ret, specificReturn := fake.renameLinkReturnsOnCall[len(fake.renameLinkArgsForCall)]
-	fake.renameLinkArgsForCall = append(fake.renameLinkArgsForCall, struct {
-		oldName string
-		newName string
-	}{oldName, newName})
-	fake.recordInvocation("RenameLink", []interface{}{oldName, newName})
-	fake.renameLinkMutex.Unlock()
-	if fake.RenameLinkStub != nil {
-		return fake.RenameLinkStub(oldName, newName)
+func (fake *LinkOperations) DisableIPv6(arg1 string) error {
+	fake.disableIPv6Mutex.Lock()
+	ret, specificReturn := fake.disableIPv6ReturnsOnCall[len(fake.disableIPv6ArgsForCall)]
+	fake.disableIPv6ArgsForCall = append(fake.disableIPv6ArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.DisableIPv6Stub
+	fakeReturns := fake.disableIPv6Returns
+	fake.recordInvocation("DisableIPv6", []interface{}{arg1})
+	fake.disableIPv6Mutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.renameLinkReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *LinkOperations) RenameLinkCallCount() int {
-	fake.renameLinkMutex.RLock()
-	defer fake.renameLinkMutex.RUnlock()
-	return len(fake.renameLinkArgsForCall)
+func (fake *LinkOperations) DisableIPv6CallCount() int {
+	fake.disableIPv6Mutex.RLock()
+	defer fake.disableIPv6Mutex.RUnlock()
+	return len(fake.disableIPv6ArgsForCall)
 }
 
-func (fake *LinkOperations) RenameLinkArgsForCall(i int) (string, string) {
-	fake.renameLinkMutex.RLock()
-	defer fake.renameLinkMutex.RUnlock()
-	return fake.renameLinkArgsForCall[i].oldName, fake.renameLinkArgsForCall[i].newName
+func (fake *LinkOperations) DisableIPv6Calls(stub func(string) error) {
+	fake.disableIPv6Mutex.Lock()
+	defer fake.disableIPv6Mutex.Unlock()
+	fake.DisableIPv6Stub = stub
 }
 
-func (fake *LinkOperations) RenameLinkReturns(result1 error) {
-	fake.RenameLinkStub = nil
-	fake.renameLinkReturns = struct {
+func (fake *LinkOperations) DisableIPv6ArgsForCall(i int) string {
+	fake.disableIPv6Mutex.RLock()
+	defer fake.disableIPv6Mutex.RUnlock()
+	argsForCall := fake.disableIPv6ArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LinkOperations) DisableIPv6Returns(result1 error) {
+	fake.disableIPv6Mutex.Lock()
+	defer fake.disableIPv6Mutex.Unlock()
+	fake.DisableIPv6Stub = nil
+	fake.disableIPv6Returns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) RenameLinkReturnsOnCall(i int, result1 error) {
-	fake.RenameLinkStub = nil
-	if fake.renameLinkReturnsOnCall == nil {
-		fake.renameLinkReturnsOnCall = make(map[int]struct {
+func (fake *LinkOperations) DisableIPv6ReturnsOnCall(i int, result1 error) {
+	fake.disableIPv6Mutex.Lock()
+	defer fake.disableIPv6Mutex.Unlock()
+	fake.DisableIPv6Stub = nil
+	if fake.disableIPv6ReturnsOnCall == nil {
+		fake.disableIPv6ReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.renameLinkReturnsOnCall[i] = struct {
+	fake.disableIPv6ReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) DeleteLinkByName(deviceName string) error {
-	fake.deleteLinkByNameMutex.Lock()
-	ret, specificReturn := fake.deleteLinkByNameReturnsOnCall[len(fake.deleteLinkByNameArgsForCall)]
-	fake.deleteLinkByNameArgsForCall = append(fake.deleteLinkByNameArgsForCall, struct {
-		deviceName string
-	}{deviceName})
-	fake.recordInvocation("DeleteLinkByName", []interface{}{deviceName})
-	fake.deleteLinkByNameMutex.Unlock()
-	if fake.DeleteLinkByNameStub != nil {
-		return fake.DeleteLinkByNameStub(deviceName)
+func (fake *LinkOperations) EnableIPv4Forwarding() error {
+	fake.enableIPv4ForwardingMutex.Lock()
+	ret, specificReturn := fake.enableIPv4ForwardingReturnsOnCall[len(fake.enableIPv4ForwardingArgsForCall)]
+	fake.enableIPv4ForwardingArgsForCall = append(fake.enableIPv4ForwardingArgsForCall, struct {
+	}{})
+	stub := fake.EnableIPv4ForwardingStub
+	fakeReturns := fake.enableIPv4ForwardingReturns
+	fake.recordInvocation("EnableIPv4Forwarding", []interface{}{})
+	fake.enableIPv4ForwardingMutex.Unlock()
+	if stub != nil {
+		return stub()
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.deleteLinkByNameReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *LinkOperations) DeleteLinkByNameCallCount() int {
-	fake.deleteLinkByNameMutex.RLock()
-	defer fake.deleteLinkByNameMutex.RUnlock()
-	return len(fake.deleteLinkByNameArgsForCall)
+func (fake *LinkOperations) EnableIPv4ForwardingCallCount() int {
+	fake.enableIPv4ForwardingMutex.RLock()
+	defer fake.enableIPv4ForwardingMutex.RUnlock()
+	return len(fake.enableIPv4ForwardingArgsForCall)
 }
 
-func (fake *LinkOperations) DeleteLinkByNameArgsForCall(i int) string {
-	fake.deleteLinkByNameMutex.RLock()
-	defer fake.deleteLinkByNameMutex.RUnlock()
-	return fake.deleteLinkByNameArgsForCall[i].deviceName
+func (fake *LinkOperations) EnableIPv4ForwardingCalls(stub func() error) {
+	fake.enableIPv4ForwardingMutex.Lock()
+	defer fake.enableIPv4ForwardingMutex.Unlock()
+	fake.EnableIPv4ForwardingStub = stub
 }
 
-func (fake *LinkOperations) DeleteLinkByNameReturns(result1 error) {
-	fake.DeleteLinkByNameStub = nil
-	fake.deleteLinkByNameReturns = struct {
+func (fake *LinkOperations) EnableIPv4ForwardingReturns(result1 error) {
+	fake.enableIPv4ForwardingMutex.Lock()
+	defer fake.enableIPv4ForwardingMutex.Unlock()
+	fake.EnableIPv4ForwardingStub = nil
+	fake.enableIPv4ForwardingReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) DeleteLinkByNameReturnsOnCall(i int, result1 error) {
-	fake.DeleteLinkByNameStub = nil
-	if fake.deleteLinkByNameReturnsOnCall == nil {
-		fake.deleteLinkByNameReturnsOnCall = make(map[int]struct {
+func (fake *LinkOperations) EnableIPv4ForwardingReturnsOnCall(i int, result1 error) {
+	fake.enableIPv4ForwardingMutex.Lock()
+	defer fake.enableIPv4ForwardingMutex.Unlock()
+	fake.EnableIPv4ForwardingStub = nil
+	if fake.enableIPv4ForwardingReturnsOnCall == nil {
+		fake.enableIPv4ForwardingReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.deleteLinkByNameReturnsOnCall[i] = struct {
+	fake.enableIPv4ForwardingReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) RenameLink(arg1 string, arg2 string) error {
+	fake.renameLinkMutex.Lock()
+	ret, specificReturn := fake.renameLinkReturnsOnCall[len(fake.renameLinkArgsForCall)]
+	fake.renameLinkArgsForCall = append(fake.renameLinkArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.RenameLinkStub
+	fakeReturns := fake.renameLinkReturns
+	fake.recordInvocation("RenameLink", []interface{}{arg1, arg2})
+	fake.renameLinkMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LinkOperations) RenameLinkCallCount() int {
+	fake.renameLinkMutex.RLock()
+	defer fake.renameLinkMutex.RUnlock()
+	return len(fake.renameLinkArgsForCall)
+}
+
+func (fake *LinkOperations) RenameLinkCalls(stub func(string, string) error) {
+	fake.renameLinkMutex.Lock()
+	defer fake.renameLinkMutex.Unlock()
+	fake.RenameLinkStub = stub
+}
+
+func (fake *LinkOperations) RenameLinkArgsForCall(i int) (string, string) {
+	fake.renameLinkMutex.RLock()
+	defer fake.renameLinkMutex.RUnlock()
+	argsForCall := fake.renameLinkArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *LinkOperations) RenameLinkReturns(result1 error) {
+	fake.renameLinkMutex.Lock()
+	defer fake.renameLinkMutex.Unlock()
+	fake.RenameLinkStub = nil
+	fake.renameLinkReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) RenameLinkReturnsOnCall(i int, result1 error) {
+	fake.renameLinkMutex.Lock()
+	defer fake.renameLinkMutex.Unlock()
+	fake.RenameLinkStub = nil
+	if fake.renameLinkReturnsOnCall == nil {
+		fake.renameLinkReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.renameLinkReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) RouteAddAll(route []*types.Route, sourceIP net.IP) error {
-	var routeCopy []*types.Route
-	if route != nil {
-		routeCopy = make([]*types.Route, len(route))
-		copy(routeCopy, route)
+func (fake *LinkOperations) RouteAddAll(arg1 []*types.Route, arg2 net.IP) error {
+	var arg1Copy []*types.Route
+	if arg1 != nil {
+		arg1Copy = make([]*types.Route, len(arg1))
+		copy(arg1Copy, arg1)
 	}
 	fake.routeAddAllMutex.Lock()
 	ret, specificReturn := fake.routeAddAllReturnsOnCall[len(fake.routeAddAllArgsForCall)]
 	fake.routeAddAllArgsForCall = append(fake.routeAddAllArgsForCall, struct {
-		route    []*types.Route
-		sourceIP net.IP
-	}{routeCopy, sourceIP})
-	fake.recordInvocation("RouteAddAll", []interface{}{routeCopy, sourceIP})
+		arg1 []*types.Route
+		arg2 net.IP
+	}{arg1Copy, arg2})
+	stub := fake.RouteAddAllStub
+	fakeReturns := fake.routeAddAllReturns
+	fake.recordInvocation("RouteAddAll", []interface{}{arg1Copy, arg2})
 	fake.routeAddAllMutex.Unlock()
-	if fake.RouteAddAllStub != nil {
-		return fake.RouteAddAllStub(route, sourceIP)
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.routeAddAllReturns.result1
+	return fakeReturns.result1
 }
 
 func (fake *LinkOperations) RouteAddAllCallCount() int {
@@ -380,13 +538,22 @@ func (fake *LinkOperations) RouteAddAllCallCount() int {
 	return len(fake.routeAddAllArgsForCall)
 }
 
+func (fake *LinkOperations) RouteAddAllCalls(stub func([]*types.Route, net.IP) error) {
+	fake.routeAddAllMutex.Lock()
+	defer fake.routeAddAllMutex.Unlock()
+	fake.RouteAddAllStub = stub
+}
+
 func (fake *LinkOperations) RouteAddAllArgsForCall(i int) ([]*types.Route, net.IP) {
 	fake.routeAddAllMutex.RLock()
 	defer fake.routeAddAllMutex.RUnlock()
-	return fake.routeAddAllArgsForCall[i].route, fake.routeAddAllArgsForCall[i].sourceIP
+	argsForCall := fake.routeAddAllArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *LinkOperations) RouteAddAllReturns(result1 error) {
+	fake.routeAddAllMutex.Lock()
+	defer fake.routeAddAllMutex.Unlock()
 	fake.RouteAddAllStub = nil
 	fake.routeAddAllReturns = struct {
 		result1 error
@@ -394,6 +561,8 @@ func (fake *LinkOperations) RouteAddAllReturns(result1 error) {
 }
 
 func (fake *LinkOperations) RouteAddAllReturnsOnCall(i int, result1 error) {
+	fake.routeAddAllMutex.Lock()
+	defer fake.routeAddAllMutex.Unlock()
 	fake.RouteAddAllStub = nil
 	if fake.routeAddAllReturnsOnCall == nil {
 		fake.routeAddAllReturnsOnCall = make(map[int]struct {
@@ -405,90 +574,253 @@ func (fake *LinkOperations) RouteAddAllReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *LinkOperations) EnableIPv4Forwarding() error {
-	fake.enableIPv4ForwardingMutex.Lock()
-	ret, specificReturn := fake.enableIPv4ForwardingReturnsOnCall[len(fake.enableIPv4ForwardingArgsForCall)]
-	fake.enableIPv4ForwardingArgsForCall = append(fake.enableIPv4ForwardingArgsForCall, struct{}{})
-	fake.recordInvocation("EnableIPv4Forwarding", []interface{}{})
-	fake.enableIPv4ForwardingMutex.Unlock()
-	if fake.EnableIPv4ForwardingStub != nil {
-		return fake.EnableIPv4ForwardingStub()
+func (fake *LinkOperations) SeedNeighborWithARP(arg1 netlink.Link, arg2 net.IP, arg3 net.HardwareAddr) error {
+	fake.seedNeighborWithARPMutex.Lock()
+	ret, specificReturn := fake.seedNeighborWithARPReturnsOnCall[len(fake.seedNeighborWithARPArgsForCall)]
+	fake.seedNeighborWithARPArgsForCall = append(fake.seedNeighborWithARPArgsForCall, struct {
+		arg1 netlink.Link
+		arg2 net.IP
+		arg3 net.HardwareAddr
+	}{arg1, arg2, arg3})
+	stub := fake.SeedNeighborWithARPStub
+	fakeReturns := fake.seedNeighborWithARPReturns
+	fake.recordInvocation("SeedNeighborWithARP", []interface{}{arg1, arg2, arg3})
+	fake.seedNeighborWithARPMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.enableIPv4ForwardingReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *LinkOperations) EnableIPv4ForwardingCallCount() int {
-	fake.enableIPv4ForwardingMutex.RLock()
-	defer fake.enableIPv4ForwardingMutex.RUnlock()
-	return len(fake.enableIPv4ForwardingArgsForCall)
+func (fake *LinkOperations) SeedNeighborWithARPCallCount() int {
+	fake.seedNeighborWithARPMutex.RLock()
+	defer fake.seedNeighborWithARPMutex.RUnlock()
+	return len(fake.seedNeighborWithARPArgsForCall)
 }
 
-func (fake *LinkOperations) EnableIPv4ForwardingReturns(result1 error) {
-	fake.EnableIPv4ForwardingStub = nil
-	fake.enableIPv4ForwardingReturns = struct {
+func (fake *LinkOperations) SeedNeighborWithARPCalls(stub func(netlink.Link, net.IP, net.HardwareAddr) error) {
+	fake.seedNeighborWithARPMutex.Lock()
+	defer fake.seedNeighborWithARPMutex.Unlock()
+	fake.SeedNeighborWithARPStub = stub
+}
+
+func (fake *LinkOperations) SeedNeighborWithARPArgsForCall(i int) (netlink.Link, net.IP, net.HardwareAddr) {
+	fake.seedNeighborWithARPMutex.RLock()
+	defer fake.seedNeighborWithARPMutex.RUnlock()
+	argsForCall := fake.seedNeighborWithARPArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *LinkOperations) SeedNeighborWithARPReturns(result1 error) {
+	fake.seedNeighborWithARPMutex.Lock()
+	defer fake.seedNeighborWithARPMutex.Unlock()
+	fake.SeedNeighborWithARPStub = nil
+	fake.seedNeighborWithARPReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) EnableIPv4ForwardingReturnsOnCall(i int, result1 error) {
-	fake.EnableIPv4ForwardingStub = nil
-	if fake.enableIPv4ForwardingReturnsOnCall == nil {
-		fake.enableIPv4ForwardingReturnsOnCall = make(map[int]struct {
+func (fake *LinkOperations) SeedNeighborWithARPReturnsOnCall(i int, result1 error) {
+	fake.seedNeighborWithARPMutex.Lock()
+	defer fake.seedNeighborWithARPMutex.Unlock()
+	fake.SeedNeighborWithARPStub = nil
+	if fake.seedNeighborWithARPReturnsOnCall == nil {
+		fake.seedNeighborWithARPReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.enableIPv4ForwardingReturnsOnCall[i] = struct {
+	fake.seedNeighborWithARPReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) SetPointToPointAddress(arg1 netlink.Link, arg2 net.IP, arg3 net.IP) error {
+	fake.setPointToPointAddressMutex.Lock()
+	ret, specificReturn := fake.setPointToPointAddressReturnsOnCall[len(fake.setPointToPointAddressArgsForCall)]
+	fake.setPointToPointAddressArgsForCall = append(fake.setPointToPointAddressArgsForCall, struct {
+		arg1 netlink.Link
+		arg2 net.IP
+		arg3 net.IP
+	}{arg1, arg2, arg3})
+	stub := fake.SetPointToPointAddressStub
+	fakeReturns := fake.setPointToPointAddressReturns
+	fake.recordInvocation("SetPointToPointAddress", []interface{}{arg1, arg2, arg3})
+	fake.setPointToPointAddressMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LinkOperations) SetPointToPointAddressCallCount() int {
+	fake.setPointToPointAddressMutex.RLock()
+	defer fake.setPointToPointAddressMutex.RUnlock()
+	return len(fake.setPointToPointAddressArgsForCall)
+}
+
+func (fake *LinkOperations) SetPointToPointAddressCalls(stub func(netlink.Link, net.IP, net.IP) error) {
+	fake.setPointToPointAddressMutex.Lock()
+	defer fake.setPointToPointAddressMutex.Unlock()
+	fake.SetPointToPointAddressStub = stub
+}
+
+func (fake *LinkOperations) SetPointToPointAddressArgsForCall(i int) (netlink.Link, net.IP, net.IP) {
+	fake.setPointToPointAddressMutex.RLock()
+	defer fake.setPointToPointAddressMutex.RUnlock()
+	argsForCall := fake.setPointToPointAddressArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *LinkOperations) SetPointToPointAddressReturns(result1 error) {
+	fake.setPointToPointAddressMutex.Lock()
+	defer fake.setPointToPointAddressMutex.Unlock()
+	fake.SetPointToPointAddressStub = nil
+	fake.setPointToPointAddressReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) SetPointToPointAddressReturnsOnCall(i int, result1 error) {
+	fake.setPointToPointAddressMutex.Lock()
+	defer fake.setPointToPointAddressMutex.Unlock()
+	fake.SetPointToPointAddressStub = nil
+	if fake.setPointToPointAddressReturnsOnCall == nil {
+		fake.setPointToPointAddressReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setPointToPointAddressReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) SetReversePathFilter(arg1 string, arg2 rpfilter.Mode) error {
+	fake.setReversePathFilterMutex.Lock()
+	ret, specificReturn := fake.setReversePathFilterReturnsOnCall[len(fake.setReversePathFilterArgsForCall)]
+	fake.setReversePathFilterArgsForCall = append(fake.setReversePathFilterArgsForCall, struct {
+		arg1 string
+		arg2 rpfilter.Mode
+	}{arg1, arg2})
+	stub := fake.SetReversePathFilterStub
+	fakeReturns := fake.setReversePathFilterReturns
+	fake.recordInvocation("SetReversePathFilter", []interface{}{arg1, arg2})
+	fake.setReversePathFilterMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LinkOperations) SetReversePathFilterCallCount() int {
+	fake.setReversePathFilterMutex.RLock()
+	defer fake.setReversePathFilterMutex.RUnlock()
+	return len(fake.setReversePathFilterArgsForCall)
+}
+
+func (fake *LinkOperations) SetReversePathFilterCalls(stub func(string, rpfilter.Mode) error) {
+	fake.setReversePathFilterMutex.Lock()
+	defer fake.setReversePathFilterMutex.Unlock()
+	fake.SetReversePathFilterStub = stub
+}
+
+func (fake *LinkOperations) SetReversePathFilterArgsForCall(i int) (string, rpfilter.Mode) {
+	fake.setReversePathFilterMutex.RLock()
+	defer fake.setReversePathFilterMutex.RUnlock()
+	argsForCall := fake.setReversePathFilterArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *LinkOperations) SetReversePathFilterReturns(result1 error) {
+	fake.setReversePathFilterMutex.Lock()
+	defer fake.setReversePathFilterMutex.Unlock()
+	fake.SetReversePathFilterStub = nil
+	fake.setReversePathFilterReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) EnableReversePathFiltering(deviceName string) error {
-	fake.enableReversePathFilteringMutex.Lock()
-	ret, specificReturn := fake.enableReversePathFilteringReturnsOnCall[len(fake.enableReversePathFilteringArgsForCall)]
-	fake.enableReversePathFilteringArgsForCall = append(fake.enableReversePathFilteringArgsForCall, struct {
-		deviceName string
-	}{deviceName})
-	fake.recordInvocation("EnableReversePathFiltering", []interface{}{deviceName})
-	fake.enableReversePathFilteringMutex.Unlock()
-	if fake.EnableReversePathFilteringStub != nil {
-		return fake.EnableReversePathFilteringStub(deviceName)
+func (fake *LinkOperations) SetReversePathFilterReturnsOnCall(i int, result1 error) {
+	fake.setReversePathFilterMutex.Lock()
+	defer fake.setReversePathFilterMutex.Unlock()
+	fake.SetReversePathFilterStub = nil
+	if fake.setReversePathFilterReturnsOnCall == nil {
+		fake.setReversePathFilterReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setReversePathFilterReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *LinkOperations) StaticNeighborNoARP(arg1 netlink.Link, arg2 net.IP, arg3 net.HardwareAddr) error {
+	fake.staticNeighborNoARPMutex.Lock()
+	ret, specificReturn := fake.staticNeighborNoARPReturnsOnCall[len(fake.staticNeighborNoARPArgsForCall)]
+	fake.staticNeighborNoARPArgsForCall = append(fake.staticNeighborNoARPArgsForCall, struct {
+		arg1 netlink.Link
+		arg2 net.IP
+		arg3 net.HardwareAddr
+	}{arg1, arg2, arg3})
+	stub := fake.StaticNeighborNoARPStub
+	fakeReturns := fake.staticNeighborNoARPReturns
+	fake.recordInvocation("StaticNeighborNoARP", []interface{}{arg1, arg2, arg3})
+	fake.staticNeighborNoARPMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.enableReversePathFilteringReturns.result1
+	return fakeReturns.result1
 }
 
-func (fake *LinkOperations) EnableReversePathFilteringCallCount() int {
-	fake.enableReversePathFilteringMutex.RLock()
-	defer fake.enableReversePathFilteringMutex.RUnlock()
-	return len(fake.enableReversePathFilteringArgsForCall)
+func (fake *LinkOperations) StaticNeighborNoARPCallCount() int {
+	fake.staticNeighborNoARPMutex.RLock()
+	defer fake.staticNeighborNoARPMutex.RUnlock()
+	return len(fake.staticNeighborNoARPArgsForCall)
 }
 
-func (fake *LinkOperations) EnableReversePathFilteringArgsForCall(i int) string {
-	fake.enableReversePathFilteringMutex.RLock()
-	defer fake.enableReversePathFilteringMutex.RUnlock()
-	return fake.enableReversePathFilteringArgsForCall[i].deviceName
+func (fake *LinkOperations) StaticNeighborNoARPCalls(stub func(netlink.Link, net.IP, net.HardwareAddr) error) {
+	fake.staticNeighborNoARPMutex.Lock()
+	defer fake.staticNeighborNoARPMutex.Unlock()
+	fake.StaticNeighborNoARPStub = stub
 }
 
-func (fake *LinkOperations) EnableReversePathFilteringReturns(result1 error) {
-	fake.EnableReversePathFilteringStub = nil
-	fake.enableReversePathFilteringReturns = struct {
+func (fake *LinkOperations) StaticNeighborNoARPArgsForCall(i int) (netlink.Link, net.IP, net.HardwareAddr) {
+	fake.staticNeighborNoARPMutex.RLock()
+	defer fake.staticNeighborNoARPMutex.RUnlock()
+	argsForCall := fake.staticNeighborNoARPArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *LinkOperations) StaticNeighborNoARPReturns(result1 error) {
+	fake.staticNeighborNoARPMutex.Lock()
+	defer fake.staticNeighborNoARPMutex.Unlock()
+	fake.StaticNeighborNoARPStub = nil
+	fake.staticNeighborNoARPReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *LinkOperations) EnableReversePathFilteringReturnsOnCall(i int, result1 error) {
-	fake.EnableReversePathFilteringStub = nil
-	if fake.enableReversePathFilteringReturnsOnCall == nil {
-		fake.enableReversePathFilteringReturnsOnCall = make(map[int]struct {
+func (fake *LinkOperations) StaticNeighborNoARPReturnsOnCall(i int, result1 error) {
+	fake.staticNeighborNoARPMutex.Lock()
+	defer fake.staticNeighborNoARPMutex.Unlock()
+	fake.StaticNeighborNoARPStub = nil
+	if fake.staticNeighborNoARPReturnsOnCall == nil {
+		fake.staticNeighborNoARPReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.enableReversePathFilteringReturnsOnCall[i] = struct {
+	fake.staticNeighborNoARPReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
@@ -496,22 +828,28 @@ func (fake *LinkOperations) EnableReversePathFilteringReturnsOnCall(i int, resul
 func (fake *LinkOperations) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.addIPv6AddressMutex.RLock()
+	defer fake.addIPv6AddressMutex.RUnlock()
+	fake.announceAddressMutex.RLock()
+	defer fake.announceAddressMutex.RUnlock()
+	fake.deleteLinkByNameMutex.RLock()
+	defer fake.deleteLinkByNameMutex.RUnlock()
 	fake.disableIPv6Mutex.RLock()
 	defer fake.disableIPv6Mutex.RUnlock()
-	fake.staticNeighborNoARPMutex.RLock()
-	defer fake.staticNeighborNoARPMutex.RUnlock()
-	fake.setPointToPointAddressMutex.RLock()
-	defer fake.setPointToPointAddressMutex.RUnlock()
+	fake.enableIPv4ForwardingMutex.RLock()
+	defer fake.enableIPv4ForwardingMutex.RUnlock()
 	fake.renameLinkMutex.RLock()
 	defer fake.renameLinkMutex.RUnlock()
-	fake.deleteLinkByNameMutex.RLock()
-	defer fake.deleteLinkByNameMutex.RUnlock()
 	fake.routeAddAllMutex.RLock()
 	defer fake.routeAddAllMutex.RUnlock()
-	fake.enableIPv4ForwardingMutex.RLock()
-	defer fake.enableIPv4ForwardingMutex.RUnlock()
-	fake.enableReversePathFilteringMutex.RLock()
-	defer fake.enableReversePathFilteringMutex.RUnlock()
+	fake.seedNeighborWithARPMutex.RLock()
+	defer fake.seedNeighborWithARPMutex.RUnlock()
+	fake.setPointToPointAddressMutex.RLock()
+	defer fake.setPointToPointAddressMutex.RUnlock()
+	fake.setReversePathFilterMutex.RLock()
+	defer fake.setReversePathFilterMutex.RUnlock()
+	fake.staticNeighborNoARPMutex.RLock()
+	defer fake.staticNeighborNoARPMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -47,6 +47,18 @@ type NetlinkAdapter struct {
 	addrAddScopeLinkReturnsOnCall map[int]struct {
 		result1 error
 	}
+	AddrAddStub        func(netlink.Link, *netlink.Addr) error
+	addrAddMutex       sync.RWMutex
+	addrAddArgsForCall []struct {
+		arg1 netlink.Link
+		arg2 *netlink.Addr
+	}
+	addrAddReturns struct {
+		result1 error
+	}
+	addrAddReturnsOnCall map[int]struct {
+		result1 error
+	}
 	LinkSetHardwareAddrStub        func(netlink.Link, net.HardwareAddr) error
 	linkSetHardwareAddrMutex       sync.RWMutex
 	linkSetHardwareAddrArgsForCall []struct {
@@ -151,6 +163,17 @@ type NetlinkAdapter struct {
 	routeAddReturnsOnCall map[int]struct {
 		result1 error
 	}
+	NeighSetStub        func(neigh *netlink.Neigh) error
+	neighSetMutex       sync.RWMutex
+	neighSetArgsForCall []struct {
+		neigh *netlink.Neigh
+	}
+	neighSetReturns struct {
+		result1 error
+	}
+	neighSetReturnsOnCall map[int]struct {
+		result1 error
+	}
 	QdiscAddStub        func(qdisc netlink.Qdisc) error
 	qdiscAddMutex       sync.RWMutex
 	qdiscAddArgsForCall []struct {
@@ -351,6 +374,55 @@ func (fake *NetlinkAdapter) AddrAddScopeLinkReturnsOnCall(i int, result1 error)
 	}{result1}
 }
 
+func (fake *NetlinkAdapter) AddrAdd(arg1 netlink.Link, arg2 *netlink.Addr) error {
+	fake.addrAddMutex.Lock()
+	ret, specificReturn := fake.addrAddReturnsOnCall[len(fake.addrAddArgsForCall)]
+	fake.addrAddArgsForCall = append(fake.addrAddArgsForCall, struct {
+		arg1 netlink.Link
+		arg2 *netlink.Addr
+	}{arg1, arg2})
+	fake.recordInvocation("AddrAdd", []interface{}{arg1, arg2})
+	fake.addrAddMutex.Unlock()
+	if fake.AddrAddStub != nil {
+		return fake.AddrAddStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.addrAddReturns.result1
+}
+
+func (fake *NetlinkAdapter) AddrAddCallCount() int {
+	fake.addrAddMutex.RLock()
+	defer fake.addrAddMutex.RUnlock()
+	return len(fake.addrAddArgsForCall)
+}
+
+func (fake *NetlinkAdapter) AddrAddArgsForCall(i int) (netlink.Link, *netlink.Addr) {
+	fake.addrAddMutex.RLock()
+	defer fake.addrAddMutex.RUnlock()
+	return fake.addrAddArgsForCall[i].arg1, fake.addrAddArgsForCall[i].arg2
+}
+
+func (fake *NetlinkAdapter) AddrAddReturns(result1 error) {
+	fake.AddrAddStub = nil
+	fake.addrAddReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkAdapter) AddrAddReturnsOnCall(i int, result1 error) {
+	fake.AddrAddStub = nil
+	if fake.addrAddReturnsOnCall == nil {
+		fake.addrAddReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.addrAddReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *NetlinkAdapter) LinkSetHardwareAddr(arg1 netlink.Link, arg2 net.HardwareAddr) error {
 	fake.linkSetHardwareAddrMutex.Lock()
 	ret, specificReturn := fake.linkSetHardwareAddrReturnsOnCall[len(fake.linkSetHardwareAddrArgsForCall)]
@@ -788,6 +860,54 @@ func (fake *NetlinkAdapter) RouteAddReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *NetlinkAdapter) NeighSet(neigh *netlink.Neigh) error {
+	fake.neighSetMutex.Lock()
+	ret, specificReturn := fake.neighSetReturnsOnCall[len(fake.neighSetArgsForCall)]
+	fake.neighSetArgsForCall = append(fake.neighSetArgsForCall, struct {
+		neigh *netlink.Neigh
+	}{neigh})
+	fake.recordInvocation("NeighSet", []interface{}{neigh})
+	fake.neighSetMutex.Unlock()
+	if fake.NeighSetStub != nil {
+		return fake.NeighSetStub(neigh)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.neighSetReturns.result1
+}
+
+func (fake *NetlinkAdapter) NeighSetCallCount() int {
+	fake.neighSetMutex.RLock()
+	defer fake.neighSetMutex.RUnlock()
+	return len(fake.neighSetArgsForCall)
+}
+
+func (fake *NetlinkAdapter) NeighSetArgsForCall(i int) *netlink.Neigh {
+	fake.neighSetMutex.RLock()
+	defer fake.neighSetMutex.RUnlock()
+	return fake.neighSetArgsForCall[i].neigh
+}
+
+func (fake *NetlinkAdapter) NeighSetReturns(result1 error) {
+	fake.NeighSetStub = nil
+	fake.neighSetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkAdapter) NeighSetReturnsOnCall(i int, result1 error) {
+	fake.NeighSetStub = nil
+	if fake.neighSetReturnsOnCall == nil {
+		fake.neighSetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.neighSetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *NetlinkAdapter) QdiscAdd(qdisc netlink.Qdisc) error {
 	fake.qdiscAddMutex.Lock()
 	ret, specificReturn := fake.qdiscAddReturnsOnCall[len(fake.qdiscAddArgsForCall)]
@@ -985,6 +1105,8 @@ func (fake *NetlinkAdapter) Invocations() map[string][][]interface{} {
 	defer fake.parseAddrMutex.RUnlock()
 	fake.addrAddScopeLinkMutex.RLock()
 	defer fake.addrAddScopeLinkMutex.RUnlock()
+	fake.addrAddMutex.RLock()
+	defer fake.addrAddMutex.RUnlock()
 	fake.linkSetHardwareAddrMutex.RLock()
 	defer fake.linkSetHardwareAddrMutex.RUnlock()
 	fake.neighAddPermanentIPv4Mutex.RLock()
@@ -1003,6 +1125,8 @@ func (fake *NetlinkAdapter) Invocations() map[string][][]interface{} {
 	defer fake.linkSetNsFdMutex.RUnlock()
 	fake.routeAddMutex.RLock()
 	defer fake.routeAddMutex.RUnlock()
+	fake.neighSetMutex.RLock()
+	defer fake.neighSetMutex.RUnlock()
 	fake.qdiscAddMutex.RLock()
 	defer fake.qdiscAddMutex.RUnlock()
 	fake.filterAddMutex.RLock()
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/vishvananda/netlink"
+)
+
+// FlatConfig turns on flat mode, in which the containers of a cell get their
+// addresses from a subnet that the underlay routes to the cell, instead of
+// reaching each other over the VXLAN overlay.
+type FlatConfig struct {
+	// UnderlayInterface is the interface of the cell on the routed segment.
+	UnderlayInterface string `json:"underlayInterface" validate:"nonzero"`
+	// RouteHook is run with add or del and the address of a container when
+	// the container is created or deleted, e.g. to advertise a route to it.
+	RouteHook string `json:"routeHook"`
+}
+
+// FlatRoutes answers ARP and NDP on the underlay for the containers behind
+// the host veths, so that the other hosts of the segment reach them through
+// the cell, and runs the route hook for them.
+type FlatRoutes struct {
+	NeighborAdapter neighborAdapter
+	SysctlAdapter   sysctlAdapter
+	CommandRunner   commandRunner
+	Logger          lager.Logger
+}
+
+func (f *FlatRoutes) Add(cfg FlatConfig, ips ...net.IP) error {
+	link, err := f.NeighborAdapter.LinkByName(cfg.UnderlayInterface)
+	if err != nil {
+		return fmt.Errorf("failed to find link %q: %s", cfg.UnderlayInterface, err)
+	}
+
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			_, err := f.SysctlAdapter.Sysctl(fmt.Sprintf("net.ipv6.conf.%s.proxy_ndp", cfg.UnderlayInterface), "1")
+			if err != nil {
+				return fmt.Errorf("sysctl for %s: %s", cfg.UnderlayInterface, err)
+			}
+		}
+
+		err = f.NeighborAdapter.NeighSet(proxyNeigh(link, ip))
+		if err != nil {
+			return fmt.Errorf("add proxy neighbor %s: %s", ip, err)
+		}
+	}
+
+	for _, ip := range ips {
+		err = f.runRouteHook(cfg.RouteHook, "add", ip)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Del removes what Add set up for the addresses. It goes on past the
+// failures, so that as much as possible is cleaned up, and returns the
+// first one.
+func (f *FlatRoutes) Del(cfg FlatConfig, ips ...net.IP) error {
+	var firstErr error
+	for _, ip := range ips {
+		err := f.runRouteHook(cfg.RouteHook, "del", ip)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	link, err := f.NeighborAdapter.LinkByName(cfg.UnderlayInterface)
+	if err != nil {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to find link %q: %s", cfg.UnderlayInterface, err)
+		}
+		return firstErr
+	}
+
+	for _, ip := range ips {
+		err = f.NeighborAdapter.NeighDel(proxyNeigh(link, ip))
+		if errors.Is(err, syscall.ENOENT) {
+			continue
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("delete proxy neighbor %s: %s", ip, err)
+		}
+	}
+	return firstErr
+}
+
+func (f *FlatRoutes) runRouteHook(routeHook, action string, ip net.IP) error {
+	if routeHook == "" {
+		return nil
+	}
+
+	output, err := f.CommandRunner.CombinedOutput(routeHook, action, ip.String())
+	if err != nil {
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			return fmt.Errorf("route hook %s %s: %s: %s", action, ip, err, trimmed)
+		}
+		return fmt.Errorf("route hook %s %s: %s", action, ip, err)
+	}
+	f.Logger.Debug("route-hook", lager.Data{"action": action, "ip": ip.String(), "output": string(output)})
+	return nil
+}
+
+func proxyNeigh(link netlink.Link, ip net.IP) *netlink.Neigh {
+	family := netlink.FAMILY_V4
+	if ip.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+	return &netlink.Neigh{
+		LinkIndex: link.Attrs().Index,
+		Family:    family,
+		Flags:     netlink.NTF_PROXY,
+		IP:        ip,
+	}
+}
@@ -0,0 +1,176 @@
+package lib_test
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/lib"
+	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("FlatRoutes", func() {
+	var (
+		fakeNeighborAdapter *fakes.NeighborAdapter
+		fakeSysctlAdapter   *fakes.SysctlAdapter
+		fakeCommandRunner   *fakes.CommandRunner
+		flatRoutes          *lib.FlatRoutes
+		cfg                 lib.FlatConfig
+		ipv4                net.IP
+		ipv6                net.IP
+	)
+
+	BeforeEach(func() {
+		fakeNeighborAdapter = &fakes.NeighborAdapter{}
+		fakeSysctlAdapter = &fakes.SysctlAdapter{}
+		fakeCommandRunner = &fakes.CommandRunner{}
+		fakeNeighborAdapter.LinkByNameReturns(&netlink.Device{
+			LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2},
+		}, nil)
+
+		flatRoutes = &lib.FlatRoutes{
+			NeighborAdapter: fakeNeighborAdapter,
+			SysctlAdapter:   fakeSysctlAdapter,
+			CommandRunner:   fakeCommandRunner,
+			Logger:          lagertest.NewTestLogger("test"),
+		}
+		cfg = lib.FlatConfig{
+			UnderlayInterface: "eth0",
+			RouteHook:         "/var/vcap/jobs/bgp/bin/announce",
+		}
+		ipv4 = net.ParseIP("10.0.16.130").To4()
+		ipv6 = net.ParseIP("fd00::130")
+	})
+
+	Describe("Add", func() {
+		It("answers ARP and NDP for the addresses on the underlay interface", func() {
+			Expect(flatRoutes.Add(cfg, ipv4, ipv6)).To(Succeed())
+
+			Expect(fakeNeighborAdapter.LinkByNameArgsForCall(0)).To(Equal("eth0"))
+			Expect(fakeNeighborAdapter.NeighSetCallCount()).To(Equal(2))
+			Expect(fakeNeighborAdapter.NeighSetArgsForCall(0)).To(Equal(&netlink.Neigh{
+				LinkIndex: 2,
+				Family:    netlink.FAMILY_V4,
+				Flags:     netlink.NTF_PROXY,
+				IP:        ipv4,
+			}))
+			Expect(fakeNeighborAdapter.NeighSetArgsForCall(1)).To(Equal(&netlink.Neigh{
+				LinkIndex: 2,
+				Family:    netlink.FAMILY_V6,
+				Flags:     netlink.NTF_PROXY,
+				IP:        ipv6,
+			}))
+
+			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.ipv6.conf.eth0.proxy_ndp"))
+			Expect(params).To(Equal([]string{"1"}))
+		})
+
+		It("runs the route hook for each address", func() {
+			Expect(flatRoutes.Add(cfg, ipv4, ipv6)).To(Succeed())
+
+			Expect(fakeCommandRunner.CombinedOutputCallCount()).To(Equal(2))
+			name, args := fakeCommandRunner.CombinedOutputArgsForCall(0)
+			Expect(name).To(Equal("/var/vcap/jobs/bgp/bin/announce"))
+			Expect(args).To(Equal([]string{"add", "10.0.16.130"}))
+			_, args = fakeCommandRunner.CombinedOutputArgsForCall(1)
+			Expect(args).To(Equal([]string{"add", "fd00::130"}))
+		})
+
+		Context("when there is no route hook", func() {
+			BeforeEach(func() {
+				cfg.RouteHook = ""
+			})
+
+			It("does not run one", func() {
+				Expect(flatRoutes.Add(cfg, ipv4)).To(Succeed())
+				Expect(fakeCommandRunner.CombinedOutputCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the underlay interface cannot be found", func() {
+			BeforeEach(func() {
+				fakeNeighborAdapter.LinkByNameReturns(nil, errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				Expect(flatRoutes.Add(cfg, ipv4)).To(MatchError(`failed to find link "eth0": banana`))
+			})
+		})
+
+		Context("when adding the proxy neighbor fails", func() {
+			BeforeEach(func() {
+				fakeNeighborAdapter.NeighSetReturns(errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				Expect(flatRoutes.Add(cfg, ipv4)).To(MatchError("add proxy neighbor 10.0.16.130: banana"))
+			})
+		})
+
+		Context("when the route hook fails", func() {
+			BeforeEach(func() {
+				fakeCommandRunner.CombinedOutputReturns([]byte("no peers\n"), errors.New("exit status 1"))
+			})
+
+			It("returns the error with its output", func() {
+				Expect(flatRoutes.Add(cfg, ipv4)).To(MatchError("route hook add 10.0.16.130: exit status 1: no peers"))
+			})
+		})
+	})
+
+	Describe("Del", func() {
+		It("runs the route hook and removes the proxy neighbors", func() {
+			Expect(flatRoutes.Del(cfg, ipv4)).To(Succeed())
+
+			_, args := fakeCommandRunner.CombinedOutputArgsForCall(0)
+			Expect(args).To(Equal([]string{"del", "10.0.16.130"}))
+
+			Expect(fakeNeighborAdapter.NeighDelCallCount()).To(Equal(1))
+			Expect(fakeNeighborAdapter.NeighDelArgsForCall(0)).To(Equal(&netlink.Neigh{
+				LinkIndex: 2,
+				Family:    netlink.FAMILY_V4,
+				Flags:     netlink.NTF_PROXY,
+				IP:        ipv4,
+			}))
+		})
+
+		Context("when the route hook fails", func() {
+			BeforeEach(func() {
+				fakeCommandRunner.CombinedOutputReturns(nil, errors.New("exit status 1"))
+			})
+
+			It("still removes the proxy neighbors and returns the error", func() {
+				Expect(flatRoutes.Del(cfg, ipv4)).To(MatchError("route hook del 10.0.16.130: exit status 1"))
+				Expect(fakeNeighborAdapter.NeighDelCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when removing a proxy neighbor fails", func() {
+			BeforeEach(func() {
+				fakeNeighborAdapter.NeighDelReturnsOnCall(0, errors.New("banana"))
+			})
+
+			It("removes the others and returns the error", func() {
+				Expect(flatRoutes.Del(cfg, ipv4, ipv6)).To(MatchError("delete proxy neighbor 10.0.16.130: banana"))
+				Expect(fakeNeighborAdapter.NeighDelCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when a proxy neighbor is already gone", func() {
+			BeforeEach(func() {
+				fakeNeighborAdapter.NeighDelReturnsOnCall(0, syscall.ENOENT)
+			})
+
+			It("removes the others and returns success", func() {
+				Expect(flatRoutes.Del(cfg, ipv4, ipv6)).To(Succeed())
+				Expect(fakeNeighborAdapter.NeighDelCallCount()).To(Equal(2))
+			})
+		})
+	})
+})
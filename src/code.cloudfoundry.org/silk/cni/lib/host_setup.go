@@ -24,7 +24,7 @@ func (h *Host) Setup(cfg *config.Config) error {
 	peer := cfg.Container.Address
 
 	return cfg.Host.Namespace.Do(func(_ ns.NetNS) error {
-		if err := h.Common.BasicSetup(deviceName, local, peer); err != nil {
+		if err := h.Common.BasicSetup(deviceName, local, peer, cfg.Host.ReversePathFilter, cfg.Host.NeighborMode); err != nil {
 			return fmt.Errorf("setting up device in host: %s", err)
 		}
 
@@ -8,6 +8,7 @@ import (
 	"code.cloudfoundry.org/silk/cni/config"
 	"code.cloudfoundry.org/silk/cni/lib"
 	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -41,6 +42,8 @@ var _ = Describe("Host Setup", func() {
 		cfg.Host.Namespace = hostNS
 		cfg.Container.Address = containerAddr
 		cfg.Host.Address = hostAddr
+		cfg.Host.ReversePathFilter = rpfilter.Strict
+		cfg.Host.NeighborMode = config.NeighborDynamic
 
 		hostSetup = &lib.Host{
 			Common:         fakeCommon,
@@ -55,10 +58,12 @@ var _ = Describe("Host Setup", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeCommon.BasicSetupCallCount()).To(Equal(1))
-			device, local, peer := fakeCommon.BasicSetupArgsForCall(0)
+			device, local, peer, rpFilter, neighborMode := fakeCommon.BasicSetupArgsForCall(0)
 			Expect(device).To(Equal("someHostDeviceName"))
 			Expect(local).To(Equal(hostAddr))
 			Expect(peer).To(Equal(containerAddr))
+			Expect(rpFilter).To(Equal(rpfilter.Strict))
+			Expect(neighborMode).To(Equal(config.NeighborDynamic))
 		})
 
 		It("enables IPv4 forwarding on the host", func() {
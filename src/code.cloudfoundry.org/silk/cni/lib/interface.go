@@ -4,6 +4,7 @@ import (
 	"net"
 
 	"code.cloudfoundry.org/silk/cni/config"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
@@ -13,17 +14,20 @@ import (
 type linkOperations interface {
 	DisableIPv6(deviceName string) error
 	StaticNeighborNoARP(link netlink.Link, dstIP net.IP, mac net.HardwareAddr) error
+	SeedNeighborWithARP(link netlink.Link, dstIP net.IP, mac net.HardwareAddr) error
 	SetPointToPointAddress(link netlink.Link, localIPAddr, peerIPAddr net.IP) error
+	AddIPv6Address(deviceName string, ip net.IP) error
 	RenameLink(oldName, newName string) error
 	DeleteLinkByName(deviceName string) error
 	RouteAddAll(route []*types.Route, sourceIP net.IP) error
 	EnableIPv4Forwarding() error
-	EnableReversePathFiltering(deviceName string) error
+	SetReversePathFilter(deviceName string, mode rpfilter.Mode) error
+	AnnounceAddress(deviceName string, ip net.IP) error
 }
 
 //go:generate counterfeiter -o fakes/common.go --fake-name Common . common
 type common interface {
-	BasicSetup(deviceName string, local, peer config.DualAddress) error
+	BasicSetup(deviceName string, local, peer config.DualAddress, rpFilter rpfilter.Mode, neighborMode config.NeighborMode) error
 }
 
 //go:generate counterfeiter -o fakes/namespaceAdapter.go --fake-name NamespaceAdapter . namespaceAdapter
@@ -36,9 +40,11 @@ type namespaceAdapter interface {
 type netlinkAdapter interface {
 	LinkByName(string) (netlink.Link, error)
 	ParseAddr(string) (*netlink.Addr, error)
+	AddrAdd(netlink.Link, *netlink.Addr) error
 	AddrAddScopeLink(netlink.Link, *netlink.Addr) error
 	LinkSetHardwareAddr(netlink.Link, net.HardwareAddr) error
 	NeighAddPermanentIPv4(index int, destIP net.IP, hwAddr net.HardwareAddr) error
+	NeighSet(*netlink.Neigh) error
 	LinkSetARPOff(netlink.Link) error
 	LinkSetName(netlink.Link, string) error
 	LinkSetUp(netlink.Link) error
@@ -70,3 +76,21 @@ type sysctlAdapter interface {
 type deviceNameGenerator interface {
 	GenerateForHostIFB(containerIP net.IP) (string, error)
 }
+
+//go:generate counterfeiter -o fakes/neighborAdapter.go --fake-name NeighborAdapter . neighborAdapter
+type neighborAdapter interface {
+	LinkByName(string) (netlink.Link, error)
+	NeighSet(*netlink.Neigh) error
+	NeighDel(*netlink.Neigh) error
+}
+
+//go:generate counterfeiter -o fakes/neighborAnnouncer.go --fake-name NeighborAnnouncer . neighborAnnouncer
+type neighborAnnouncer interface {
+	GratuitousARP(ifindex int, ip net.IP, mac net.HardwareAddr) error
+	UnsolicitedNA(ifindex int, ip net.IP, mac net.HardwareAddr) error
+}
+
+//go:generate counterfeiter -o fakes/commandRunner.go --fake-name CommandRunner . commandRunner
+type commandRunner interface {
+	CombinedOutput(name string, args ...string) ([]byte, error)
+}
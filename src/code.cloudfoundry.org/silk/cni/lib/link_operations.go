@@ -1,33 +1,70 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"strings"
+	"syscall"
 
 	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/vishvananda/netlink"
 )
 
+// dynamicNeighborReachableTimeMs keeps the neighbor entries learned by ARP
+// reachable for 5 minutes instead of the 30 seconds of the kernel.
+const dynamicNeighborReachableTimeMs = "300000"
+
 // LinkOperations exposes mid-level link setup operations.
 // They encapsulate low-level netlink and sysctl commands.
 type LinkOperations struct {
-	SysctlAdapter  sysctlAdapter
-	NetlinkAdapter netlinkAdapter
-	Logger         lager.Logger
+	SysctlAdapter     sysctlAdapter
+	NetlinkAdapter    netlinkAdapter
+	NeighborAnnouncer neighborAnnouncer
+	Logger            lager.Logger
+	// UserNamespaced is set when the plugin or the container is in a user
+	// namespace, see UserNamespaces.
+	UserNamespaced bool
+}
+
+// sysctl sets a sysctl. In user namespaces, which may be denied sysctls that
+// the kernel keeps for the initial user namespace, a denied sysctl that
+// already has the value is left alone, and a denied optional one is skipped.
+func (s *LinkOperations) sysctl(name, value string, optional bool) error {
+	_, err := s.SysctlAdapter.Sysctl(name, value)
+	if err == nil || !s.UserNamespaced || !sysctlDenied(err) {
+		return err
+	}
+
+	current, readErr := s.SysctlAdapter.Sysctl(name)
+	if readErr == nil && strings.TrimSpace(current) == value {
+		return nil
+	}
+	if !optional {
+		return err
+	}
+	s.Logger.Info("skipped-denied-sysctl", lager.Data{"name": name, "value": value, "error": err.Error()})
+	return nil
+}
+
+func sysctlDenied(err error) bool {
+	return errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EROFS)
 }
 
 func (s *LinkOperations) DisableIPv6(deviceName string) error {
-	_, err := s.SysctlAdapter.Sysctl(fmt.Sprintf("net.ipv6.conf.%s.disable_ipv6", deviceName), "1")
+	err := s.sysctl(fmt.Sprintf("net.ipv6.conf.%s.disable_ipv6", deviceName), "1", true)
 	if err != nil {
 		return fmt.Errorf("sysctl for %s: %s", deviceName, err)
 	}
 	return nil
 }
 
-func (s *LinkOperations) EnableReversePathFiltering(deviceName string) error {
-	_, err := s.SysctlAdapter.Sysctl(fmt.Sprintf("net.ipv4.conf.%s.rp_filter", deviceName), "1")
+func (s *LinkOperations) SetReversePathFilter(deviceName string, mode rpfilter.Mode) error {
+	err := s.sysctl(rpfilter.SysctlName(deviceName), mode.SysctlValue(), true)
 	if err != nil {
 		return fmt.Errorf("sysctl for %s: %s", deviceName, err)
 	}
@@ -35,7 +72,7 @@ func (s *LinkOperations) EnableReversePathFiltering(deviceName string) error {
 }
 
 func (s *LinkOperations) EnableIPv4Forwarding() error {
-	_, err := s.SysctlAdapter.Sysctl("net.ipv4.ip_forward", "1")
+	err := s.sysctl("net.ipv4.ip_forward", "1", false)
 	if err != nil {
 		return fmt.Errorf("enabling IPv4 forwarding: %s", err)
 	}
@@ -58,6 +95,36 @@ func (s *LinkOperations) StaticNeighborNoARP(link netlink.Link, destIP net.IP, h
 	return nil
 }
 
+// SeedNeighborWithARP leaves ARP enabled on the link, with the entries it
+// learns kept reachable for longer than the kernel default, and seeds the
+// neighbor entry of destIP with the given hardware address. The entry is
+// stale rather than permanent: it is used right away, and ARP confirms it or
+// replaces it when the peer answers with another hardware address.
+func (s *LinkOperations) SeedNeighborWithARP(link netlink.Link, destIP net.IP, hwAddr net.HardwareAddr) error {
+	deviceName := link.Attrs().Name
+	err := s.sysctl(fmt.Sprintf("net.ipv4.neigh.%s.base_reachable_time_ms", deviceName), dynamicNeighborReachableTimeMs, true)
+	if err != nil {
+		return fmt.Errorf("sysctl for %s: %s", deviceName, err)
+	}
+
+	err = s.NetlinkAdapter.NeighSet(&netlink.Neigh{
+		LinkIndex:    link.Attrs().Index,
+		Family:       netlink.FAMILY_V4,
+		State:        netlink.NUD_STALE,
+		IP:           destIP,
+		HardwareAddr: hwAddr,
+	})
+	if err != nil {
+		return fmt.Errorf("neigh set: %s", err)
+	}
+
+	return nil
+}
+
+// SetPointToPointAddress adds localIPAddr to the device as a /32 with
+// peerIPAddr as its peer, so that the kernel routes to the peer through the
+// device without a subnet on the link. No address around localIPAddr is used
+// up, which lets IPAM hand out every address of the subnet of the cell.
 func (s *LinkOperations) SetPointToPointAddress(link netlink.Link, localIPAddr, peerIPAddr net.IP) error {
 	localAddr := &net.IPNet{
 		IP:   localIPAddr,
@@ -82,6 +149,56 @@ func (s *LinkOperations) SetPointToPointAddress(link netlink.Link, localIPAddr,
 	return nil
 }
 
+// AddIPv6Address re-enables IPv6 on the device and adds the given address
+// to it as a /128, skipping duplicate address detection.
+func (s *LinkOperations) AddIPv6Address(deviceName string, ip net.IP) error {
+	err := s.sysctl(fmt.Sprintf("net.ipv6.conf.%s.disable_ipv6", deviceName), "0", false)
+	if err != nil {
+		return fmt.Errorf("sysctl for %s: %s", deviceName, err)
+	}
+
+	link, err := s.NetlinkAdapter.LinkByName(deviceName)
+	if err != nil {
+		return fmt.Errorf("failed to find link %q: %s", deviceName, err)
+	}
+
+	addr := &netlink.Addr{
+		IPNet: &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(128, 128),
+		},
+		Flags: syscall.IFA_F_NODAD,
+	}
+	err = s.NetlinkAdapter.AddrAdd(link, addr)
+	if err != nil {
+		return fmt.Errorf("adding IP address %s: %s", addr.IPNet, err)
+	}
+
+	return nil
+}
+
+// AnnounceAddress sends a gratuitous ARP for an IPv4 address of the device,
+// or an unsolicited neighbor advertisement for an IPv6 one, with the hardware
+// address the device has, so that the other end updates its neighbor entry
+// for the address right away.
+func (s *LinkOperations) AnnounceAddress(deviceName string, ip net.IP) error {
+	link, err := s.NetlinkAdapter.LinkByName(deviceName)
+	if err != nil {
+		return fmt.Errorf("failed to find link %q: %s", deviceName, err)
+	}
+
+	attrs := link.Attrs()
+	if ip.To4() != nil {
+		err = s.NeighborAnnouncer.GratuitousARP(attrs.Index, ip, attrs.HardwareAddr)
+	} else {
+		err = s.NeighborAnnouncer.UnsolicitedNA(attrs.Index, ip, attrs.HardwareAddr)
+	}
+	if err != nil {
+		return fmt.Errorf("announce %s on %s: %s", ip, deviceName, err)
+	}
+	return nil
+}
+
 func (s *LinkOperations) RenameLink(oldName, newName string) error {
 	link, err := s.NetlinkAdapter.LinkByName(oldName)
 	if err != nil {
@@ -107,7 +224,12 @@ func (s *LinkOperations) DeleteLinkByName(deviceName string) error {
 		return nil
 	}
 
-	return s.NetlinkAdapter.LinkDel(link)
+	// the link can go away with its namespace while it is being deleted
+	err = s.NetlinkAdapter.LinkDel(link)
+	if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENODEV) {
+		return nil
+	}
+	return err
 }
 
 func (s *LinkOperations) RouteAddAll(routes []*types.Route, sourceIP net.IP) error {
@@ -3,14 +3,18 @@ package lib_test
 import (
 	"errors"
 	"net"
+	"os"
+	"syscall"
 
 	"code.cloudfoundry.org/lager/v3/lagertest"
 
 	"code.cloudfoundry.org/silk/cni/lib"
 	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	"code.cloudfoundry.org/silk/lib/rpfilter"
 	"github.com/containernetworking/cni/pkg/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/vishvananda/netlink"
 )
 
@@ -98,9 +102,9 @@ var _ = Describe("Link Operations", func() {
 		})
 	})
 
-	Describe("EnableReversePathFiltering", func() {
-		It("calls the sysctl adapter to set rp_filtering to strict mode", func() {
-			err := linkOperations.EnableReversePathFiltering("someDevice")
+	Describe("SetReversePathFilter", func() {
+		It("calls the sysctl adapter to set rp_filter to the mode", func() {
+			err := linkOperations.SetReversePathFilter("someDevice", rpfilter.Strict)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
@@ -110,12 +114,20 @@ var _ = Describe("Link Operations", func() {
 			Expect(params[0]).To(Equal("1"))
 		})
 
+		It("sets loose mode", func() {
+			err := linkOperations.SetReversePathFilter("someDevice", rpfilter.Loose)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(params).To(Equal([]string{"2"}))
+		})
+
 		Context("when the sysctl command fails", func() {
 			BeforeEach(func() {
 				fakeSysctlAdapter.SysctlReturns("", errors.New("cuttlefish"))
 			})
 			It("returns a meaningful error", func() {
-				err := linkOperations.EnableReversePathFiltering("someDevice")
+				err := linkOperations.SetReversePathFilter("someDevice", rpfilter.Strict)
 				Expect(err).To(MatchError("sysctl for someDevice: cuttlefish"))
 			})
 		})
@@ -144,6 +156,62 @@ var _ = Describe("Link Operations", func() {
 		})
 	})
 
+	Context("when the plugin or the container is in a user namespace", func() {
+		var denied error
+
+		BeforeEach(func() {
+			linkOperations.UserNamespaced = true
+			denied = &os.PathError{Op: "open", Path: "/proc/sys/net/ipv4/ip_forward", Err: syscall.EROFS}
+			fakeSysctlAdapter.SysctlStub = func(name string, params ...string) (string, error) {
+				if len(params) > 0 {
+					return "", denied
+				}
+				return "0\n", nil
+			}
+		})
+
+		It("skips the optional sysctls that are denied", func() {
+			Expect(linkOperations.SetReversePathFilter("someDevice", rpfilter.Strict)).To(Succeed())
+			Expect(linkOperations.DisableIPv6("someDevice")).To(Succeed())
+			Expect(logger).To(gbytes.Say("skipped-denied-sysctl.*net.ipv4.conf.someDevice.rp_filter"))
+		})
+
+		It("fails when a required sysctl is denied", func() {
+			err := linkOperations.EnableIPv4Forwarding()
+			Expect(err).To(MatchError(ContainSubstring("enabling IPv4 forwarding: open /proc/sys/net/ipv4/ip_forward: read-only file system")))
+		})
+
+		It("succeeds when a denied sysctl already has the value", func() {
+			fakeSysctlAdapter.SysctlStub = func(name string, params ...string) (string, error) {
+				if len(params) > 0 {
+					return "", denied
+				}
+				return "1\n", nil
+			}
+			Expect(linkOperations.EnableIPv4Forwarding()).To(Succeed())
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(1)
+			Expect(name).To(Equal("net.ipv4.ip_forward"))
+			Expect(params).To(BeEmpty())
+		})
+
+		It("fails on errors other than denied ones", func() {
+			denied = errors.New("cuttlefish")
+			err := linkOperations.SetReversePathFilter("someDevice", rpfilter.Strict)
+			Expect(err).To(MatchError("sysctl for someDevice: cuttlefish"))
+		})
+	})
+
+	Context("when a sysctl is denied outside of user namespaces", func() {
+		BeforeEach(func() {
+			fakeSysctlAdapter.SysctlReturns("", syscall.EACCES)
+		})
+
+		It("fails", func() {
+			err := linkOperations.SetReversePathFilter("someDevice", rpfilter.Strict)
+			Expect(err).To(MatchError("sysctl for someDevice: permission denied"))
+		})
+	})
+
 	Describe("StaticNeighborNoARP", func() {
 		It("calls the netlink adapter to disable ARP", func() {
 			err := linkOperations.StaticNeighborNoARP(fakeLink, ipAddr, hwAddr)
@@ -185,6 +253,110 @@ var _ = Describe("Link Operations", func() {
 		})
 	})
 
+	Describe("SeedNeighborWithARP", func() {
+		It("keeps the neighbor entries learned by ARP reachable for longer", func() {
+			err := linkOperations.SeedNeighborWithARP(fakeLink, ipAddr, hwAddr)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeNetlinkAdapter.LinkSetARPOffCallCount()).To(Equal(0))
+			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.ipv4.neigh.my-fake-bridge.base_reachable_time_ms"))
+			Expect(params).To(Equal([]string{"300000"}))
+		})
+
+		It("seeds a stale neighbor entry", func() {
+			err := linkOperations.SeedNeighborWithARP(fakeLink, ipAddr, hwAddr)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeNetlinkAdapter.NeighAddPermanentIPv4CallCount()).To(Equal(0))
+			Expect(fakeNetlinkAdapter.NeighSetCallCount()).To(Equal(1))
+			Expect(fakeNetlinkAdapter.NeighSetArgsForCall(0)).To(Equal(&netlink.Neigh{
+				LinkIndex:    42,
+				Family:       netlink.FAMILY_V4,
+				State:        netlink.NUD_STALE,
+				IP:           ipAddr,
+				HardwareAddr: hwAddr,
+			}))
+		})
+
+		Context("when the sysctl command fails", func() {
+			BeforeEach(func() {
+				fakeSysctlAdapter.SysctlReturns("", errors.New("lobster"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.SeedNeighborWithARP(fakeLink, ipAddr, hwAddr)
+				Expect(err).To(MatchError("sysctl for my-fake-bridge: lobster"))
+			})
+		})
+
+		Context("when seeding the neighbor entry fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.NeighSetReturns(errors.New("prawn"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.SeedNeighborWithARP(fakeLink, ipAddr, hwAddr)
+				Expect(err).To(MatchError("neigh set: prawn"))
+			})
+		})
+	})
+
+	Describe("AnnounceAddress", func() {
+		var fakeNeighborAnnouncer *fakes.NeighborAnnouncer
+
+		BeforeEach(func() {
+			fakeNeighborAnnouncer = &fakes.NeighborAnnouncer{}
+			linkOperations.NeighborAnnouncer = fakeNeighborAnnouncer
+			fakeLink.Attrs().HardwareAddr = hwAddr
+			fakeNetlinkAdapter.LinkByNameReturns(fakeLink, nil)
+		})
+
+		It("sends a gratuitous ARP for an IPv4 address with the hardware address of the device", func() {
+			err := linkOperations.AnnounceAddress("my-fake-bridge", ipAddr)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeNetlinkAdapter.LinkByNameArgsForCall(0)).To(Equal("my-fake-bridge"))
+			Expect(fakeNeighborAnnouncer.GratuitousARPCallCount()).To(Equal(1))
+			index, ip, mac := fakeNeighborAnnouncer.GratuitousARPArgsForCall(0)
+			Expect(index).To(Equal(42))
+			Expect(ip).To(Equal(ipAddr))
+			Expect(mac).To(Equal(hwAddr))
+			Expect(fakeNeighborAnnouncer.UnsolicitedNACallCount()).To(Equal(0))
+		})
+
+		It("sends an unsolicited neighbor advertisement for an IPv6 address", func() {
+			err := linkOperations.AnnounceAddress("my-fake-bridge", net.ParseIP("fd00::4"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeNeighborAnnouncer.UnsolicitedNACallCount()).To(Equal(1))
+			index, ip, mac := fakeNeighborAnnouncer.UnsolicitedNAArgsForCall(0)
+			Expect(index).To(Equal(42))
+			Expect(ip).To(Equal(net.ParseIP("fd00::4")))
+			Expect(mac).To(Equal(hwAddr))
+			Expect(fakeNeighborAnnouncer.GratuitousARPCallCount()).To(Equal(0))
+		})
+
+		Context("when the link cannot be found", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("kiwi"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.AnnounceAddress("my-fake-bridge", ipAddr)
+				Expect(err).To(MatchError(`failed to find link "my-fake-bridge": kiwi`))
+			})
+		})
+
+		Context("when sending the announcement fails", func() {
+			BeforeEach(func() {
+				fakeNeighborAnnouncer.GratuitousARPReturns(errors.New("mango"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.AnnounceAddress("my-fake-bridge", ipAddr)
+				Expect(err).To(MatchError("announce 10.255.30.4 on my-fake-bridge: mango"))
+			})
+		})
+	})
+
 	Describe("SetPointToPointAddress", func() {
 		var (
 			parsedAddr *netlink.Addr
@@ -232,6 +404,60 @@ var _ = Describe("Link Operations", func() {
 		})
 	})
 
+	Describe("AddIPv6Address", func() {
+		var ipv6Addr net.IP
+		BeforeEach(func() {
+			ipv6Addr = net.ParseIP("fd00:abcd:0:1e00::4")
+			fakeNetlinkAdapter.LinkByNameReturns(fakeLink, nil)
+		})
+		It("enables IPv6 and adds the address to the link", func() {
+			err := linkOperations.AddIPv6Address("someDevice", ipv6Addr)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(1))
+			name, params := fakeSysctlAdapter.SysctlArgsForCall(0)
+			Expect(name).To(Equal("net.ipv6.conf.someDevice.disable_ipv6"))
+			Expect(params).To(Equal([]string{"0"}))
+
+			Expect(fakeNetlinkAdapter.LinkByNameArgsForCall(0)).To(Equal("someDevice"))
+			Expect(fakeNetlinkAdapter.AddrAddCallCount()).To(Equal(1))
+			link, addr := fakeNetlinkAdapter.AddrAddArgsForCall(0)
+			Expect(link).To(Equal(fakeLink))
+			Expect(addr.IPNet.String()).To(Equal("fd00:abcd:0:1e00::4/128"))
+			Expect(addr.Flags).To(Equal(syscall.IFA_F_NODAD))
+		})
+
+		Context("when enabling IPv6 fails", func() {
+			BeforeEach(func() {
+				fakeSysctlAdapter.SysctlReturns("", errors.New("cuttlefish"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.AddIPv6Address("someDevice", ipv6Addr)
+				Expect(err).To(MatchError("sysctl for someDevice: cuttlefish"))
+			})
+		})
+
+		Context("when the link cannot be found", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("squid"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.AddIPv6Address("someDevice", ipv6Addr)
+				Expect(err).To(MatchError(`failed to find link "someDevice": squid`))
+			})
+		})
+
+		Context("when adding the address fails", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.AddrAddReturns(errors.New("oyster"))
+			})
+			It("returns a meaningful error", func() {
+				err := linkOperations.AddIPv6Address("someDevice", ipv6Addr)
+				Expect(err).To(MatchError("adding IP address fd00:abcd:0:1e00::4/128: oyster"))
+			})
+		})
+	})
+
 	Describe("RenameLink", func() {
 		BeforeEach(func() {
 			fakeNetlinkAdapter.LinkByNameReturns(fakeLink, nil)
@@ -312,6 +538,16 @@ var _ = Describe("Link Operations", func() {
 				Expect(err).To(MatchError("starfish"))
 			})
 		})
+
+		Context("when the link is already gone", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkDelReturns(syscall.ENODEV)
+			})
+			It("returns success", func() {
+				err := linkOperations.DeleteLinkByName("someName")
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
 	})
 
 	Describe("RouteAddAll", func() {
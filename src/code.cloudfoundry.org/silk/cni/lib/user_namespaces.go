@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+//go:generate counterfeiter -o fakes/userNamespaceAdapter.go --fake-name UserNamespaceAdapter . userNamespaceAdapter
+type userNamespaceAdapter interface {
+	RunningInUserNamespace() (bool, error)
+	CurrentUserNamespace() (uint64, error)
+	NetNSUserNamespaces(path string) ([]uint64, error)
+}
+
+// UserNamespaces detects whether the plugin sets up a container in user
+// namespaces, e.g. when garden runs rootless and the plugin runs in its user
+// namespace, or when the network namespace of the container is owned by the
+// user namespace of the container.
+type UserNamespaces struct {
+	Adapter userNamespaceAdapter
+	Logger  lager.Logger
+}
+
+// Detect returns whether the plugin or the network namespace at
+// containerNSPath is in a user namespace other than the initial one. When the
+// kernel cannot tell, e.g. before it had the ioctls for namespaces, the
+// namespaces are taken to be the initial ones. It fails when the user
+// namespace of the plugin is neither the owner of the network namespace nor
+// an ancestor of the owner, since the plugin could not move the veth pair
+// into it.
+func (u *UserNamespaces) Detect(containerNSPath string) (bool, error) {
+	inUserNS, err := u.Adapter.RunningInUserNamespace()
+	if err != nil {
+		u.Logger.Error("detect-user-namespaces", err)
+		return false, nil
+	}
+	current, err := u.Adapter.CurrentUserNamespace()
+	if err != nil {
+		u.Logger.Error("detect-user-namespaces", err)
+		return inUserNS, nil
+	}
+	owners, err := u.Adapter.NetNSUserNamespaces(containerNSPath)
+	if err != nil {
+		u.Logger.Error("detect-user-namespaces", err)
+		return inUserNS, nil
+	}
+
+	for _, owner := range owners {
+		if owner == current {
+			return inUserNS || owners[0] != current, nil
+		}
+	}
+	return false, fmt.Errorf("network namespace %s is owned by a user namespace outside of the user namespace of the plugin", containerNSPath)
+}
@@ -0,0 +1,80 @@
+package lib_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/cni/lib"
+	"code.cloudfoundry.org/silk/cni/lib/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("UserNamespaces", func() {
+	var (
+		adapter        *fakes.UserNamespaceAdapter
+		logger         *lagertest.TestLogger
+		userNamespaces *lib.UserNamespaces
+	)
+
+	BeforeEach(func() {
+		adapter = &fakes.UserNamespaceAdapter{}
+		logger = lagertest.NewTestLogger("test")
+		userNamespaces = &lib.UserNamespaces{Adapter: adapter, Logger: logger}
+
+		adapter.CurrentUserNamespaceReturns(1, nil)
+		adapter.NetNSUserNamespacesReturns([]uint64{1}, nil)
+	})
+
+	It("detects no user namespaces when the plugin and the container are in the initial one", func() {
+		userNamespaced, err := userNamespaces.Detect("/some/netns")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userNamespaced).To(BeFalse())
+		Expect(adapter.NetNSUserNamespacesArgsForCall(0)).To(Equal("/some/netns"))
+	})
+
+	It("detects the network namespace of a container that is owned by a nested user namespace", func() {
+		adapter.NetNSUserNamespacesReturns([]uint64{2, 1}, nil)
+		userNamespaced, err := userNamespaces.Detect("/some/netns")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userNamespaced).To(BeTrue())
+	})
+
+	It("detects a plugin that runs in a user namespace", func() {
+		adapter.RunningInUserNamespaceReturns(true, nil)
+		userNamespaced, err := userNamespaces.Detect("/some/netns")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(userNamespaced).To(BeTrue())
+	})
+
+	Context("when the network namespace is owned by a user namespace outside of the one of the plugin", func() {
+		BeforeEach(func() {
+			adapter.RunningInUserNamespaceReturns(true, nil)
+			adapter.NetNSUserNamespacesReturns([]uint64{3}, nil)
+		})
+
+		It("returns an error", func() {
+			_, err := userNamespaces.Detect("/some/netns")
+			Expect(err).To(MatchError("network namespace /some/netns is owned by a user namespace outside of the user namespace of the plugin"))
+		})
+	})
+
+	Context("when the user namespaces cannot be detected", func() {
+		BeforeEach(func() {
+			adapter.NetNSUserNamespacesReturns(nil, errors.New("inappropriate ioctl for device"))
+		})
+
+		It("logs the error and takes the namespace of the plugin", func() {
+			userNamespaced, err := userNamespaces.Detect("/some/netns")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(userNamespaced).To(BeFalse())
+			Expect(logger).To(gbytes.Say("detect-user-namespaces.*inappropriate ioctl"))
+
+			adapter.RunningInUserNamespaceReturns(true, nil)
+			userNamespaced, err = userNamespaces.Detect("/some/netns")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(userNamespaced).To(BeTrue())
+		})
+	})
+})
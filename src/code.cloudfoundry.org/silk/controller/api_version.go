@@ -0,0 +1,87 @@
+package controller
+
+import "fmt"
+
+const (
+	// APIVersion is the version of the API between the silk daemons and the
+	// controller. Version 1 is the API of the controllers that predate the
+	// handshake.
+	APIVersion = 2
+	// MinAPIVersion is the oldest version that is still supported. It trails
+	// APIVersion by at most two, so that the daemons and controllers of three
+	// consecutive versions work together while a foundation is upgraded.
+	MinAPIVersion = 1
+)
+
+// The features that the daemons and the controller agree on in the handshake,
+// besides acquiring, renewing and releasing leases, which all versions support.
+const (
+	FeatureSingleOverlayIP = "single_overlay_ip"
+	FeatureEgressGateways  = "egress_gateways"
+	FeatureTopology        = "topology"
+)
+
+// APIInfo is the range of versions and the features of one side of the API.
+type APIInfo struct {
+	Version    int      `json:"version"`
+	MinVersion int      `json:"min_version"`
+	Features   []string `json:"features"`
+}
+
+// CurrentAPIInfo is the API of this release.
+func CurrentAPIInfo() APIInfo {
+	return APIInfo{
+		Version:    APIVersion,
+		MinVersion: MinAPIVersion,
+		Features:   []string{FeatureSingleOverlayIP, FeatureEgressGateways, FeatureTopology},
+	}
+}
+
+// LegacyAPIInfo is the API of the controllers that predate the handshake.
+func LegacyAPIInfo() APIInfo {
+	return APIInfo{
+		Version:    1,
+		MinVersion: 1,
+		Features:   []string{FeatureSingleOverlayIP},
+	}
+}
+
+// Handshake is the version and the features that both sides of the API use.
+type Handshake struct {
+	Version  int      `json:"version"`
+	Features []string `json:"features"`
+}
+
+func (h Handshake) Supports(feature string) bool {
+	for _, f := range h.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate agrees on the newest version that both sides support, and on the
+// features of local that remote supports as well. It fails with a
+// NonRetriableError if the versions of the sides are too far apart.
+func Negotiate(local, remote APIInfo) (Handshake, error) {
+	version := local.Version
+	if remote.Version < version {
+		version = remote.Version
+	}
+	if version < local.MinVersion || version < remote.MinVersion {
+		return Handshake{}, NonRetriableError(fmt.Sprintf(
+			"incompatible api versions: %d to %d and %d to %d",
+			local.MinVersion, local.Version, remote.MinVersion, remote.Version,
+		))
+	}
+
+	features := []string{}
+	remoteHandshake := Handshake{Features: remote.Features}
+	for _, feature := range local.Features {
+		if remoteHandshake.Supports(feature) {
+			features = append(features, feature)
+		}
+	}
+	return Handshake{Version: version, Features: features}, nil
+}
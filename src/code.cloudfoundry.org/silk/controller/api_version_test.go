@@ -0,0 +1,87 @@
+package controller_test
+
+import (
+	"code.cloudfoundry.org/silk/controller"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Negotiate", func() {
+	var local, remote controller.APIInfo
+
+	BeforeEach(func() {
+		local = controller.APIInfo{
+			Version:    4,
+			MinVersion: 2,
+			Features:   []string{"egress_gateways", "topology", "single_overlay_ip"},
+		}
+		remote = controller.APIInfo{
+			Version:    3,
+			MinVersion: 1,
+			Features:   []string{"single_overlay_ip", "egress_gateways"},
+		}
+	})
+
+	It("agrees on the newest common version and the common features", func() {
+		handshake, err := controller.Negotiate(local, remote)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handshake).To(Equal(controller.Handshake{
+			Version:  3,
+			Features: []string{"egress_gateways", "single_overlay_ip"},
+		}))
+		Expect(handshake.Supports("egress_gateways")).To(BeTrue())
+		Expect(handshake.Supports("topology")).To(BeFalse())
+	})
+
+	It("is symmetric in the version", func() {
+		handshake, err := controller.Negotiate(remote, local)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handshake.Version).To(Equal(3))
+	})
+
+	Context("when there are no common features", func() {
+		BeforeEach(func() {
+			remote.Features = nil
+		})
+
+		It("agrees on none", func() {
+			handshake, err := controller.Negotiate(local, remote)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handshake.Features).To(BeEmpty())
+		})
+	})
+
+	Context("when the remote side is older than the local side supports", func() {
+		BeforeEach(func() {
+			remote.Version = 1
+		})
+
+		It("returns a non-retriable error", func() {
+			_, err := controller.Negotiate(local, remote)
+			Expect(err).To(Equal(controller.NonRetriableError("incompatible api versions: 2 to 4 and 1 to 1")))
+		})
+	})
+
+	Context("when the local side is older than the remote side supports", func() {
+		BeforeEach(func() {
+			remote = controller.APIInfo{Version: 6, MinVersion: 5}
+		})
+
+		It("returns a non-retriable error", func() {
+			_, err := controller.Negotiate(local, remote)
+			Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
+		})
+	})
+
+	It("supports the legacy api in this release", func() {
+		handshake, err := controller.Negotiate(controller.CurrentAPIInfo(), controller.LegacyAPIInfo())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handshake.Version).To(Equal(1))
+		Expect(handshake.Supports(controller.FeatureSingleOverlayIP)).To(BeTrue())
+	})
+
+	It("tolerates a skew of two versions", func() {
+		current := controller.CurrentAPIInfo()
+		Expect(current.Version - current.MinVersion).To(BeNumerically("<=", 2))
+	})
+})
@@ -22,6 +22,38 @@ type Lease struct {
 	UnderlayIP          string `json:"underlay_ip"`
 	OverlaySubnet       string `json:"overlay_subnet"`
 	OverlayHardwareAddr string `json:"overlay_hardware_addr"`
+	OverlayIPv6Subnet   string `json:"overlay_ipv6_subnet,omitempty"`
+	// FailoverUnderlayIP is the underlay IP that the cell receives the
+	// overlay traffic on while its primary underlay is down.
+	FailoverUnderlayIP string `json:"failover_underlay_ip,omitempty"`
+}
+
+// VTEPUnderlayIP is the underlay IP that the other cells send the overlay
+// traffic of the lease to.
+func (l Lease) VTEPUnderlayIP() string {
+	if l.FailoverUnderlayIP != "" {
+		return l.FailoverUnderlayIP
+	}
+	return l.UnderlayIP
+}
+
+// EgressGateway designates a cell that SNATs egress traffic from the given
+// spaces to EgressIP. Cells hosting apps in those spaces route their egress
+// over the overlay to the gateway instead of out their own NAT. ID selects
+// the fwmark and routing table of the gateway on every cell.
+type EgressGateway struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	UnderlayIP string   `json:"underlay_ip"`
+	EgressIP   string   `json:"egress_ip"`
+	SpaceGUIDs []string `json:"space_guids"`
+}
+
+// LeaseConflict is a group of leases whose overlay subnets overlap, e.g.
+// after a database restore. Cells holding them would route each other's
+// container traffic, so none of them is handed out until it is resolved.
+type LeaseConflict struct {
+	Leases []Lease `json:"leases"`
 }
 
 type ReleaseLeaseRequest struct {
@@ -39,6 +71,26 @@ func NewClient(logger lager.Logger, httpClient json_client.HttpClient, baseURL s
 	}
 }
 
+// Handshake negotiates the version and the features of the API with the
+// controller. A controller that predates the handshake is negotiated with as
+// LegacyAPIInfo. It fails with a NonRetriableError if the versions are too far
+// apart.
+func (c *Client) Handshake(info APIInfo) (Handshake, error) {
+	var response Handshake
+	err := c.JsonClient.Do("PUT", "/handshake", info, &response, "")
+	if err != nil {
+		httpResponseErr, ok := err.(*json_client.HttpResponseCodeError)
+		if ok && httpResponseErr.StatusCode == http.StatusNotFound {
+			return Negotiate(info, LegacyAPIInfo())
+		}
+		if ok && httpResponseErr.StatusCode == http.StatusConflict {
+			return Handshake{}, NonRetriableError(fmt.Sprintf("non-retriable: %s", httpResponseErr.Message))
+		}
+		return Handshake{}, err
+	}
+	return response, nil
+}
+
 func (c *Client) GetActiveLeases() ([]Lease, error) {
 	var response struct {
 		Leases []Lease
@@ -50,6 +102,28 @@ func (c *Client) GetActiveLeases() ([]Lease, error) {
 	return response.Leases, nil
 }
 
+// GetTopology returns the signed topology of the overlay. The caller verifies
+// it with SignedTopology.Verify.
+func (c *Client) GetTopology() (SignedTopology, error) {
+	var response SignedTopology
+	err := c.JsonClient.Do("GET", "/topology", nil, &response, "")
+	if err != nil {
+		return SignedTopology{}, err
+	}
+	return response, nil
+}
+
+func (c *Client) GetEgressGateways() ([]EgressGateway, error) {
+	var response struct {
+		EgressGateways []EgressGateway `json:"egress_gateways"`
+	}
+	err := c.JsonClient.Do("GET", "/egress_gateways", nil, &response, "")
+	if err != nil {
+		return nil, err
+	}
+	return response.EgressGateways, nil
+}
+
 func (c *Client) AcquireSubnetLease(underlayIP string) (Lease, error) {
 	return c.acquireLease(underlayIP, false)
 }
@@ -25,6 +25,135 @@ var _ = Describe("Client", func() {
 		}
 	})
 
+	Describe("GetTopology", func() {
+		BeforeEach(func() {
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				return json.Unmarshal([]byte(`{
+					"topology": {"version": "some-version", "cells": []},
+					"signature": {"algorithm": "ECDSA-SHA256", "certificates": "some-pem", "value": "AQID"}
+				}`), respData)
+			}
+		})
+
+		It("gets the signed topology", func() {
+			signedTopology, err := client.GetTopology()
+			Expect(err).NotTo(HaveOccurred())
+
+			method, route, reqData, _, _ := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("GET"))
+			Expect(route).To(Equal("/topology"))
+			Expect(reqData).To(BeNil())
+
+			Expect(signedTopology.Topology).To(MatchJSON(`{"version": "some-version", "cells": []}`))
+			Expect(signedTopology.Signature).To(Equal(controller.TopologySignature{
+				Algorithm:    "ECDSA-SHA256",
+				Certificates: "some-pem",
+				Value:        []byte{1, 2, 3},
+			}))
+		})
+
+		Context("when the request fails", func() {
+			BeforeEach(func() {
+				jsonClient.DoReturns(errors.New("banana"))
+				jsonClient.DoStub = nil
+			})
+
+			It("returns the error", func() {
+				_, err := client.GetTopology()
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
+	Describe("Handshake", func() {
+		var info controller.APIInfo
+
+		BeforeEach(func() {
+			info = controller.APIInfo{
+				Version:    3,
+				MinVersion: 1,
+				Features:   []string{"single_overlay_ip", "egress_gateways"},
+			}
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				return json.Unmarshal([]byte(`{"version": 2, "features": ["egress_gateways"]}`), respData)
+			}
+		})
+
+		It("negotiates with the controller", func() {
+			handshake, err := client.Handshake(info)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(jsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("PUT"))
+			Expect(route).To(Equal("/handshake"))
+			Expect(reqData).To(Equal(info))
+			Expect(token).To(BeEmpty())
+
+			Expect(handshake).To(Equal(controller.Handshake{
+				Version:  2,
+				Features: []string{"egress_gateways"},
+			}))
+		})
+
+		Context("when the controller predates the handshake", func() {
+			BeforeEach(func() {
+				jsonClient.DoStub = nil
+				jsonClient.DoReturns(&json_client.HttpResponseCodeError{
+					StatusCode: http.StatusNotFound,
+					Message:    "not found",
+				})
+			})
+
+			It("negotiates with the legacy api", func() {
+				handshake, err := client.Handshake(info)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(handshake).To(Equal(controller.Handshake{
+					Version:  1,
+					Features: []string{"single_overlay_ip"},
+				}))
+			})
+
+			Context("when the legacy api is no longer supported", func() {
+				BeforeEach(func() {
+					info.MinVersion = 2
+				})
+
+				It("returns a non-retriable error", func() {
+					_, err := client.Handshake(info)
+					Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
+				})
+			})
+		})
+
+		Context("when the controller rejects the versions with a HTTP 409 Conflict", func() {
+			BeforeEach(func() {
+				jsonClient.DoStub = nil
+				jsonClient.DoReturns(&json_client.HttpResponseCodeError{
+					StatusCode: http.StatusConflict,
+					Message:    "banana",
+				})
+			})
+
+			It("returns a non-retriable error", func() {
+				_, err := client.Handshake(info)
+				Expect(err).To(Equal(controller.NonRetriableError("non-retriable: banana")))
+			})
+		})
+
+		Context("when the json client returns any other error", func() {
+			BeforeEach(func() {
+				jsonClient.DoStub = nil
+				jsonClient.DoReturns(errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				_, err := client.Handshake(info)
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
 	Describe("GetActiveLeases", func() {
 		BeforeEach(func() {
 			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
@@ -80,6 +209,53 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("GetEgressGateways", func() {
+		BeforeEach(func() {
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				respBytes := []byte(`
+				{
+					"egress_gateways": [
+						{ "id": 4, "name": "gw-0", "underlay_ip": "10.0.3.1", "egress_ip": "10.0.100.5", "space_guids": ["space-a", "space-b"] }
+					]
+				}`)
+				json.Unmarshal(respBytes, respData)
+				return nil
+			}
+		})
+
+		It("gets the egress gateways from the controller", func() {
+			gateways, err := client.GetEgressGateways()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(jsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("GET"))
+			Expect(route).To(Equal("/egress_gateways"))
+			Expect(reqData).To(BeNil())
+			Expect(token).To(BeEmpty())
+
+			Expect(gateways).To(Equal([]controller.EgressGateway{
+				{
+					ID:         4,
+					Name:       "gw-0",
+					UnderlayIP: "10.0.3.1",
+					EgressIP:   "10.0.100.5",
+					SpaceGUIDs: []string{"space-a", "space-b"},
+				},
+			}))
+		})
+
+		Context("when the json client fails", func() {
+			BeforeEach(func() {
+				jsonClient.DoReturns(errors.New("banana"))
+			})
+			It("returns the error", func() {
+				_, err := client.GetEgressGateways()
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
 	Describe("AcquireSubnetLease", func() {
 		Context("when acquring a single overlay IP", func() {
 			BeforeEach(func() {
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 
 	"code.cloudfoundry.org/cf-networking-helpers/db"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/leaser"
 	"gopkg.in/validator.v2"
 )
 
@@ -17,18 +20,32 @@ type Config struct {
 	CACertFile                    string    `json:"ca_cert_file" validate:"nonzero"`
 	ServerCertFile                string    `json:"server_cert_file" validate:"nonzero"`
 	ServerKeyFile                 string    `json:"server_key_file" validate:"nonzero"`
+	ClientSPIFFEIDs               []string  `json:"client_spiffe_ids"`
 	Network                       string    `json:"network" validate:"nonzero"`
 	SubnetPrefixLength            int       `json:"subnet_prefix_length" validate:"nonzero"`
 	Database                      db.Config `json:"database" validate:"nonzero"`
 	LeaseExpirationSeconds        int       `json:"lease_expiration_seconds" validate:"min=1"`
 	MetronPort                    int       `json:"metron_port" validate:"min=1"`
 	HealthCheckPort               int       `json:"health_check_port" validate:"min=1"`
+	AdminPort                     int       `json:"admin_port" validate:"min=0"`
 	MetricsEmitSeconds            int       `json:"metrics_emit_seconds" validate:"min=1"`
 	StalenessThresholdSeconds     int       `json:"staleness_threshold_seconds" validate:"min=1"`
 	LogPrefix                     string    `json:"log_prefix" validate:"nonzero"`
 	MaxIdleConnections            int       `json:"max_idle_connections" validate:"min=0"`
 	MaxOpenConnections            int       `json:"max_open_connections" validate:"min=0"`
 	MaxConnectionsLifetimeSeconds int       `json:"connections_max_lifetime_seconds" validate:"min=0"`
+	NetworkIPv6                   string    `json:"network_ipv6"`
+	SubnetPrefixLengthIPv6        int       `json:"subnet_prefix_length_ipv6"`
+
+	LeaseReclamationGraceSeconds        int      `json:"lease_reclamation_grace_seconds" validate:"min=0"`
+	LeaseReclamationProbePort           int      `json:"lease_reclamation_probe_port" validate:"min=0"`
+	LeaseReclamationProbeTimeoutSeconds int      `json:"lease_reclamation_probe_timeout_seconds" validate:"min=0"`
+	PinnedUnderlayIPs                   []string `json:"pinned_underlay_ips"`
+
+	ShardIndex int `json:"shard_index" validate:"min=0"`
+	ShardCount int `json:"shard_count" validate:"min=0"`
+
+	EgressGateways []controller.EgressGateway `json:"egress_gateways"`
 }
 
 func (c *Config) WriteToFile(configFilePath string) error {
@@ -56,5 +73,53 @@ func ReadFromFile(configFilePath string) (*Config, error) {
 	if err := validator.Validate(conf); err != nil {
 		return nil, fmt.Errorf("invalid config: %s", err)
 	}
+	if err := validateEgressGateways(conf.EgressGateways); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	for _, underlayIP := range conf.PinnedUnderlayIPs {
+		if net.ParseIP(underlayIP).To4() == nil {
+			return nil, fmt.Errorf("invalid config: invalid pinned underlay ip '%s'", underlayIP)
+		}
+	}
+	if conf.ShardCount > 0 && conf.ShardIndex >= conf.ShardCount {
+		return nil, fmt.Errorf("invalid config: shard index %d is not below shard count %d", conf.ShardIndex, conf.ShardCount)
+	}
+	if conf.NetworkIPv6 != "" {
+		if _, err := leaser.NewIPv6Prefixes(conf.Network, conf.NetworkIPv6, conf.SubnetPrefixLengthIPv6); err != nil {
+			return nil, fmt.Errorf("invalid config: %s", err)
+		}
+	}
 	return &conf, nil
 }
+
+// MaxEgressGatewayID keeps the routing tables of the gateways, which start
+// after table 200 on the cells, clear of the reserved tables 253 to 255.
+const MaxEgressGatewayID = 50
+
+func validateEgressGateways(gateways []controller.EgressGateway) error {
+	underlayIPs := map[string]bool{}
+	ids := map[int]bool{}
+	for i, gateway := range gateways {
+		if gateway.Name == "" {
+			return fmt.Errorf("egress_gateways[%d]: missing name", i)
+		}
+		if gateway.ID < 1 || gateway.ID > MaxEgressGatewayID {
+			return fmt.Errorf("egress gateway %s: id must be between 1 and %d", gateway.Name, MaxEgressGatewayID)
+		}
+		if ids[gateway.ID] {
+			return fmt.Errorf("egress gateway %s: duplicate id %d", gateway.Name, gateway.ID)
+		}
+		ids[gateway.ID] = true
+		if net.ParseIP(gateway.UnderlayIP).To4() == nil {
+			return fmt.Errorf("egress gateway %s: invalid underlay_ip '%s'", gateway.Name, gateway.UnderlayIP)
+		}
+		if net.ParseIP(gateway.EgressIP).To4() == nil {
+			return fmt.Errorf("egress gateway %s: invalid egress_ip '%s'", gateway.Name, gateway.EgressIP)
+		}
+		if underlayIPs[gateway.UnderlayIP] {
+			return fmt.Errorf("egress gateway %s: duplicate underlay_ip '%s'", gateway.Name, gateway.UnderlayIP)
+		}
+		underlayIPs[gateway.UnderlayIP] = true
+	}
+	return nil
+}
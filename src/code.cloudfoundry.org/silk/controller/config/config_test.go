@@ -65,6 +65,155 @@ var _ = Describe("Config.ReadFromFile", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("reads the admin port", func() {
+		cfg := cloneMap(requiredFields)
+		cfg["admin_port"] = 4104
+
+		file, err := ioutil.TempFile(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+		conf, err := config.ReadFromFile(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conf.AdminPort).To(Equal(4104))
+	})
+
+	Context("when egress gateways are configured", func() {
+		var gateways []map[string]interface{}
+
+		BeforeEach(func() {
+			gateways = []map[string]interface{}{
+				{"id": 1, "name": "gw-0", "underlay_ip": "10.0.0.5", "egress_ip": "10.0.100.5", "space_guids": []string{"space-a"}},
+				{"id": 2, "name": "gw-1", "underlay_ip": "10.0.0.6", "egress_ip": "10.0.100.6", "space_guids": []string{"space-b"}},
+			}
+		})
+
+		readConfig := func() (*config.Config, error) {
+			cfg := cloneMap(requiredFields)
+			cfg["egress_gateways"] = gateways
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			return config.ReadFromFile(file.Name())
+		}
+
+		It("reads the gateways", func() {
+			conf, err := readConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.EgressGateways).To(HaveLen(2))
+			Expect(conf.EgressGateways[1].ID).To(Equal(2))
+			Expect(conf.EgressGateways[1].Name).To(Equal("gw-1"))
+			Expect(conf.EgressGateways[1].UnderlayIP).To(Equal("10.0.0.6"))
+			Expect(conf.EgressGateways[1].EgressIP).To(Equal("10.0.100.6"))
+			Expect(conf.EgressGateways[1].SpaceGUIDs).To(Equal([]string{"space-b"}))
+		})
+
+		DescribeTable("rejects invalid gateways",
+			func(field string, value interface{}, errorString string) {
+				gateways[1][field] = value
+				_, err := readConfig()
+				Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorString)))
+			},
+			Entry("missing name", "name", "", "egress_gateways[1]: missing name"),
+			Entry("missing id", "id", 0, "egress gateway gw-1: id must be between 1 and 50"),
+			Entry("id out of range", "id", 51, "egress gateway gw-1: id must be between 1 and 50"),
+			Entry("duplicate id", "id", 1, "egress gateway gw-1: duplicate id 1"),
+			Entry("invalid underlay_ip", "underlay_ip", "banana", "egress gateway gw-1: invalid underlay_ip 'banana'"),
+			Entry("invalid egress_ip", "egress_ip", "banana", "egress gateway gw-1: invalid egress_ip 'banana'"),
+			Entry("duplicate underlay_ip", "underlay_ip", "10.0.0.5", "egress gateway gw-1: duplicate underlay_ip '10.0.0.5'"),
+		)
+	})
+
+	Context("when an ipv6 network is configured", func() {
+		readConfig := func(ipv6Network string, prefixLength int) (*config.Config, error) {
+			cfg := cloneMap(requiredFields)
+			cfg["network_ipv6"] = ipv6Network
+			cfg["subnet_prefix_length_ipv6"] = prefixLength
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			return config.ReadFromFile(file.Name())
+		}
+
+		It("reads the ipv6 network", func() {
+			conf, err := readConfig("fd00:abcd::/48", 64)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.NetworkIPv6).To(Equal("fd00:abcd::/48"))
+			Expect(conf.SubnetPrefixLengthIPv6).To(Equal(64))
+		})
+
+		It("rejects an invalid ipv6 network", func() {
+			_, err := readConfig("10.0.0.0/8", 64)
+			Expect(err).To(MatchError("invalid config: invalid ipv6 network: 10.0.0.0/8"))
+		})
+
+		It("rejects an ipv6 network that is too small for the network", func() {
+			_, err := readConfig("fd00:abcd::/56", 64)
+			Expect(err).To(MatchError("invalid config: ipv6 network fd00:abcd::/56 has too few /64 prefixes for network 10.255.0.0/16"))
+		})
+	})
+
+	Context("when lease reclamation is configured", func() {
+		readConfig := func(pinnedUnderlayIPs []string) (*config.Config, error) {
+			cfg := cloneMap(requiredFields)
+			cfg["lease_reclamation_grace_seconds"] = 600
+			cfg["lease_reclamation_probe_port"] = 22
+			cfg["lease_reclamation_probe_timeout_seconds"] = 2
+			cfg["pinned_underlay_ips"] = pinnedUnderlayIPs
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			return config.ReadFromFile(file.Name())
+		}
+
+		It("reads the reclamation settings", func() {
+			conf, err := readConfig([]string{"10.0.0.5"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.LeaseReclamationGraceSeconds).To(Equal(600))
+			Expect(conf.LeaseReclamationProbePort).To(Equal(22))
+			Expect(conf.LeaseReclamationProbeTimeoutSeconds).To(Equal(2))
+			Expect(conf.PinnedUnderlayIPs).To(Equal([]string{"10.0.0.5"}))
+		})
+
+		It("rejects an invalid pinned underlay ip", func() {
+			_, err := readConfig([]string{"10.0.0.5", "banana"})
+			Expect(err).To(MatchError("invalid config: invalid pinned underlay ip 'banana'"))
+		})
+	})
+
+	Context("when the pool is sharded between controller instances", func() {
+		readConfig := func(shardIndex, shardCount int) (*config.Config, error) {
+			cfg := cloneMap(requiredFields)
+			cfg["shard_index"] = shardIndex
+			cfg["shard_count"] = shardCount
+
+			file, err := ioutil.TempFile(os.TempDir(), "config-")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+
+			return config.ReadFromFile(file.Name())
+		}
+
+		It("reads the shard", func() {
+			conf, err := readConfig(2, 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conf.ShardIndex).To(Equal(2))
+			Expect(conf.ShardCount).To(Equal(3))
+		})
+
+		It("rejects a shard index outside the shards", func() {
+			_, err := readConfig(3, 3)
+			Expect(err).To(MatchError("invalid config: shard index 3 is not below shard count 3"))
+		})
+	})
+
 	DescribeTable("when config file is missing a member",
 		func(missingFlag, errorString string) {
 			cfg := cloneMap(requiredFields)
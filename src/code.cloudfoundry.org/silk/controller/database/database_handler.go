@@ -6,7 +6,9 @@ import (
 	"fmt"
 
 	"code.cloudfoundry.org/silk/controller"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	migrate "github.com/rubenv/sql-migrate"
 )
 
@@ -17,6 +19,14 @@ const Postgres = "postgres"
 
 var RecordNotAffectedError = errors.New("record not affected")
 
+// DuplicateEntryError is returned by AddEntry when another lease already has
+// the underlay ip, overlay subnet or hardware address of the lease, e.g.
+// because another controller instance added it at the same time.
+var DuplicateEntryError = errors.New("duplicate entry")
+
+const mysqlDuplicateEntry = 1062
+const postgresUniqueViolation = "23505"
+
 //go:generate counterfeiter -o fakes/db.go --fake-name Db . Db
 type Db interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
@@ -48,6 +58,11 @@ func NewDatabaseHandler(migrator migrateAdapter, db Db) *DatabaseHandler {
 					Up:   []string{createSubnetTable(db.DriverName())},
 					Down: []string{"DROP TABLE subnets"},
 				},
+				{
+					Id:   "2",
+					Up:   []string{addFailoverUnderlayIPColumn},
+					Down: []string{"ALTER TABLE subnets DROP COLUMN failover_underlay_ip"},
+				},
 			},
 		},
 		db: db,
@@ -60,7 +75,7 @@ func (d *DatabaseHandler) CheckDatabase() error {
 }
 
 func (d *DatabaseHandler) All() ([]controller.Lease, error) {
-	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets")
+	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip FROM subnets")
 	if err != nil {
 		return nil, fmt.Errorf("selecting all subnets: %s", err)
 	}
@@ -73,8 +88,32 @@ func (d *DatabaseHandler) All() ([]controller.Lease, error) {
 	return leases, nil
 }
 
+// AllWithLastRenewedAt returns all the saved subnets with the time their
+// leases were last renewed, ordered by underlay ip.
+func (d *DatabaseHandler) AllWithLastRenewedAt() ([]controller.TopologyCell, error) {
+	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at FROM subnets ORDER BY underlay_ip")
+	if err != nil {
+		return nil, fmt.Errorf("selecting all subnets: %s", err)
+	}
+	defer rows.Close() // untested
+
+	cells := []controller.TopologyCell{}
+	for rows.Next() {
+		var cell controller.TopologyCell
+		err := rows.Scan(&cell.UnderlayIP, &cell.OverlaySubnet, &cell.OverlayHardwareAddr, &cell.FailoverUnderlayIP, &cell.LastRenewedAt)
+		if err != nil {
+			return nil, fmt.Errorf("selecting all subnets: parsing result: %s", err)
+		}
+		cells = append(cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("selecting all subnets: getting next row: %s", err) // untested
+	}
+	return cells, nil
+}
+
 func (d *DatabaseHandler) AllSingleIPSubnets() ([]controller.Lease, error) {
-	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet LIKE '%/32'")
+	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip FROM subnets WHERE overlay_subnet LIKE '%/32'")
 	if err != nil {
 		return nil, fmt.Errorf("selecting all single ip subnets: %s", err)
 	}
@@ -88,7 +127,7 @@ func (d *DatabaseHandler) AllSingleIPSubnets() ([]controller.Lease, error) {
 }
 
 func (d *DatabaseHandler) AllBlockSubnets() ([]controller.Lease, error) {
-	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet NOT LIKE '%/32'")
+	rows, err := d.db.Query("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip FROM subnets WHERE overlay_subnet NOT LIKE '%/32'")
 	if err != nil {
 		return nil, fmt.Errorf("selecting all block subnets: %s", err)
 	}
@@ -106,7 +145,7 @@ func (d *DatabaseHandler) AllActive(duration int) ([]controller.Lease, error) {
 	if err != nil {
 		return nil, err
 	}
-	rows, err := d.db.Query(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE last_renewed_at + %d > %s", duration, timestamp))
+	rows, err := d.db.Query(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip FROM subnets WHERE last_renewed_at + %d > %s", duration, timestamp))
 	if err != nil {
 		return nil, fmt.Errorf("selecting all active subnets: %s", err)
 	}
@@ -119,48 +158,35 @@ func (d *DatabaseHandler) AllActive(duration int) ([]controller.Lease, error) {
 	return leases, nil
 }
 
-func (d *DatabaseHandler) OldestExpiredBlockSubnet(expirationTime int) (*controller.Lease, error) {
-	timestamp, err := timestampForDriver(d.db.DriverName())
-	if err != nil {
-		return nil, err
-	}
+// ExpiredBlockSubnets returns the block subnet leases that have not been
+// renewed for expirationTime seconds, the longest expired first.
+func (d *DatabaseHandler) ExpiredBlockSubnets(expirationTime int) ([]controller.Lease, error) {
+	return d.expiredLeases(expirationTime, "NOT LIKE")
+}
 
-	var underlayIP, overlaySubnet, overlayHWAddr string
-	result := d.db.QueryRow(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet NOT LIKE '%%/32' AND last_renewed_at + %d <= %s ORDER BY last_renewed_at ASC LIMIT 1", expirationTime, timestamp))
-	err = result.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("scan result: %s", err)
-	}
-	return &controller.Lease{
-		UnderlayIP:          underlayIP,
-		OverlaySubnet:       overlaySubnet,
-		OverlayHardwareAddr: overlayHWAddr,
-	}, nil
+// ExpiredSingleIPs returns the single ip leases that have not been renewed
+// for expirationTime seconds, the longest expired first.
+func (d *DatabaseHandler) ExpiredSingleIPs(expirationTime int) ([]controller.Lease, error) {
+	return d.expiredLeases(expirationTime, "LIKE")
 }
 
-func (d *DatabaseHandler) OldestExpiredSingleIP(expirationTime int) (*controller.Lease, error) {
+func (d *DatabaseHandler) expiredLeases(expirationTime int, singleIPMatch string) ([]controller.Lease, error) {
 	timestamp, err := timestampForDriver(d.db.DriverName())
 	if err != nil {
 		return nil, err
 	}
 
-	var underlayIP, overlaySubnet, overlayHWAddr string
-	result := d.db.QueryRow(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr FROM subnets WHERE overlay_subnet LIKE '%%/32' AND last_renewed_at + %d <= %s ORDER BY last_renewed_at ASC LIMIT 1", expirationTime, timestamp))
-	err = result.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr)
+	rows, err := d.db.Query(fmt.Sprintf("SELECT underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip FROM subnets WHERE overlay_subnet %s '%%/32' AND last_renewed_at + %d <= %s ORDER BY last_renewed_at ASC", singleIPMatch, expirationTime, timestamp))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("scan result: %s", err)
+		return nil, fmt.Errorf("selecting expired subnets: %s", err)
 	}
-	return &controller.Lease{
-		UnderlayIP:          underlayIP,
-		OverlaySubnet:       overlaySubnet,
-		OverlayHardwareAddr: overlayHWAddr,
-	}, nil
+	defer rows.Close() // untested
+	leases, err := rowsToLeases(rows)
+	if err != nil {
+		return nil, fmt.Errorf("selecting expired subnets: %s", err)
+	}
+
+	return leases, nil
 }
 
 func (d *DatabaseHandler) Migrate() (int, error) {
@@ -178,13 +204,28 @@ func (d *DatabaseHandler) AddEntry(lease controller.Lease) error {
 		return err
 	}
 
-	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, %s)", timestamp)), lease.UnderlayIP, lease.OverlaySubnet, lease.OverlayHardwareAddr)
+	_, err = d.db.Exec(d.db.Rebind(fmt.Sprintf("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at) VALUES (?, ?, ?, ?, %s)", timestamp)), lease.UnderlayIP, lease.OverlaySubnet, lease.OverlayHardwareAddr, lease.FailoverUnderlayIP)
+	if isDuplicateEntry(err) {
+		return DuplicateEntryError
+	}
 	if err != nil {
 		return fmt.Errorf("adding entry: %s", err)
 	}
 	return nil
 }
 
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == postgresUniqueViolation
+	}
+	return false
+}
+
 func (d *DatabaseHandler) DeleteEntry(underlayIP string) error {
 	deleteRows, err := d.db.Exec(d.db.Rebind("DELETE FROM subnets WHERE underlay_ip = ?"), underlayIP)
 
@@ -205,9 +246,9 @@ func (d *DatabaseHandler) DeleteEntry(underlayIP string) error {
 }
 
 func (d *DatabaseHandler) LeaseForUnderlayIP(underlayIP string) (*controller.Lease, error) {
-	var overlaySubnet, overlayHWAddr string
-	result := d.db.QueryRow(d.db.Rebind("SELECT overlay_subnet, overlay_hwaddr FROM subnets WHERE underlay_ip = ?"), underlayIP)
-	err := result.Scan(&overlaySubnet, &overlayHWAddr)
+	var overlaySubnet, overlayHWAddr, failoverUnderlayIP string
+	result := d.db.QueryRow(d.db.Rebind("SELECT overlay_subnet, overlay_hwaddr, failover_underlay_ip FROM subnets WHERE underlay_ip = ?"), underlayIP)
+	err := result.Scan(&overlaySubnet, &overlayHWAddr, &failoverUnderlayIP)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -218,9 +259,21 @@ func (d *DatabaseHandler) LeaseForUnderlayIP(underlayIP string) (*controller.Lea
 		UnderlayIP:          underlayIP,
 		OverlaySubnet:       overlaySubnet,
 		OverlayHardwareAddr: overlayHWAddr,
+		FailoverUnderlayIP:  failoverUnderlayIP,
 	}, nil
 }
 
+// SetFailoverUnderlayIP records the underlay ip that the cell with the lease
+// of underlayIP receives the overlay traffic on, or clears it when it is
+// empty.
+func (d *DatabaseHandler) SetFailoverUnderlayIP(underlayIP, failoverUnderlayIP string) error {
+	_, err := d.db.Exec(d.db.Rebind("UPDATE subnets SET failover_underlay_ip = ? WHERE underlay_ip = ?"), failoverUnderlayIP, underlayIP)
+	if err != nil {
+		return fmt.Errorf("setting failover underlay ip: %s", err)
+	}
+	return nil
+}
+
 func (d *DatabaseHandler) RenewLeaseForUnderlayIP(underlayIP string) error {
 	timestamp, err := timestampForDriver(d.db.DriverName())
 	if err != nil {
@@ -247,8 +300,8 @@ func (d *DatabaseHandler) LastRenewedAtForUnderlayIP(underlayIP string) (int64,
 func rowsToLeases(rows *sql.Rows) ([]controller.Lease, error) {
 	leases := []controller.Lease{}
 	for rows.Next() {
-		var underlayIP, overlaySubnet, overlayHWAddr string
-		err := rows.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr)
+		var underlayIP, overlaySubnet, overlayHWAddr, failoverUnderlayIP string
+		err := rows.Scan(&underlayIP, &overlaySubnet, &overlayHWAddr, &failoverUnderlayIP)
 		if err != nil {
 			return nil, fmt.Errorf("parsing result: %s", err)
 		}
@@ -256,6 +309,7 @@ func rowsToLeases(rows *sql.Rows) ([]controller.Lease, error) {
 			UnderlayIP:          underlayIP,
 			OverlaySubnet:       overlaySubnet,
 			OverlayHardwareAddr: overlayHWAddr,
+			FailoverUnderlayIP:  failoverUnderlayIP,
 		})
 	}
 	err := rows.Err()
@@ -266,6 +320,8 @@ func rowsToLeases(rows *sql.Rows) ([]controller.Lease, error) {
 	return leases, nil
 }
 
+const addFailoverUnderlayIPColumn = "ALTER TABLE subnets ADD COLUMN failover_underlay_ip varchar(15) NOT NULL DEFAULT ''"
+
 func createSubnetTable(dbType string) string {
 	baseCreateTable := "CREATE TABLE IF NOT EXISTS subnets (" +
 		"%s" +
@@ -15,6 +15,8 @@ import (
 	"code.cloudfoundry.org/silk/controller"
 	"code.cloudfoundry.org/silk/controller/database"
 	"code.cloudfoundry.org/silk/controller/database/fakes"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	migrate "github.com/rubenv/sql-migrate"
@@ -110,6 +112,11 @@ var _ = Describe("DatabaseHandler", func() {
 							Up:   []string{"CREATE TABLE IF NOT EXISTS subnets (id SERIAL PRIMARY KEY, underlay_ip varchar(15) NOT NULL, overlay_subnet varchar(18) NOT NULL, overlay_hwaddr varchar(17) NOT NULL, last_renewed_at bigint NOT NULL, UNIQUE (underlay_ip), UNIQUE (overlay_subnet), UNIQUE (overlay_hwaddr));"},
 							Down: []string{"DROP TABLE subnets"},
 						},
+						{
+							Id:   "2",
+							Up:   []string{"ALTER TABLE subnets ADD COLUMN failover_underlay_ip varchar(15) NOT NULL DEFAULT ''"},
+							Down: []string{"ALTER TABLE subnets DROP COLUMN failover_underlay_ip"},
+						},
 					},
 				}))
 			} else {
@@ -120,6 +127,11 @@ var _ = Describe("DatabaseHandler", func() {
 							Up:   []string{"CREATE TABLE IF NOT EXISTS subnets (id int NOT NULL AUTO_INCREMENT, PRIMARY KEY (id), underlay_ip varchar(15) NOT NULL, overlay_subnet varchar(18) NOT NULL, overlay_hwaddr varchar(17) NOT NULL, last_renewed_at bigint NOT NULL, UNIQUE (underlay_ip), UNIQUE (overlay_subnet), UNIQUE (overlay_hwaddr));"},
 							Down: []string{"DROP TABLE subnets"},
 						},
+						{
+							Id:   "2",
+							Up:   []string{"ALTER TABLE subnets ADD COLUMN failover_underlay_ip varchar(15) NOT NULL DEFAULT ''"},
+							Down: []string{"ALTER TABLE subnets DROP COLUMN failover_underlay_ip"},
+						},
 					},
 				}))
 			}
@@ -156,7 +168,7 @@ var _ = Describe("DatabaseHandler", func() {
 		Context("when the database type is postgres", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
-				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES ($1, $2, $3, EXTRACT(EPOCH FROM now())::numeric::integer)")
+				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at) VALUES ($1, $2, $3, $4, EXTRACT(EPOCH FROM now())::numeric::integer)")
 				mockDb.DriverNameReturns("postgres")
 			})
 			It("adds an entry to the DB", func() {
@@ -165,9 +177,9 @@ var _ = Describe("DatabaseHandler", func() {
 
 				Expect(mockDb.ExecCallCount()).To(Equal(1))
 				query, args := mockDb.ExecArgsForCall(0)
-				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, EXTRACT(EPOCH FROM now())::numeric::integer)"))
-				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES ($1, $2, $3, EXTRACT(EPOCH FROM now())::numeric::integer)"))
-				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00"}))
+				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at) VALUES (?, ?, ?, ?, EXTRACT(EPOCH FROM now())::numeric::integer)"))
+				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at) VALUES ($1, $2, $3, $4, EXTRACT(EPOCH FROM now())::numeric::integer)"))
+				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00", ""}))
 			})
 		})
 
@@ -175,7 +187,7 @@ var _ = Describe("DatabaseHandler", func() {
 			BeforeEach(func() {
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
 				mockDb.DriverNameReturns("mysql")
-				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, UNIX_TIMESTAMP())")
+				mockDb.RebindReturns("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at) VALUES (?, ?, ?, ?, UNIX_TIMESTAMP())")
 			})
 			It("adds an entry to the DB", func() {
 				err := databaseHandler.AddEntry(lease)
@@ -183,9 +195,46 @@ var _ = Describe("DatabaseHandler", func() {
 
 				Expect(mockDb.ExecCallCount()).To(Equal(1))
 				query, args := mockDb.ExecArgsForCall(0)
-				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, UNIX_TIMESTAMP())"))
-				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, last_renewed_at) VALUES (?, ?, ?, UNIX_TIMESTAMP())"))
-				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00"}))
+				Expect(mockDb.RebindArgsForCall(0)).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at) VALUES (?, ?, ?, ?, UNIX_TIMESTAMP())"))
+				Expect(query).To(Equal("INSERT INTO subnets (underlay_ip, overlay_subnet, overlay_hwaddr, failover_underlay_ip, last_renewed_at) VALUES (?, ?, ?, ?, UNIX_TIMESTAMP())"))
+				Expect(args).To(Equal([]interface{}{"10.244.11.22", "10.255.17.0/24", "ee:ee:0a:ff:11:00", ""}))
+			})
+		})
+
+		Context("when another lease has the same subnet", func() {
+			It("returns a duplicate entry error", func() {
+				err := databaseHandler.AddEntry(lease)
+				Expect(err).NotTo(HaveOccurred())
+
+				otherLease := lease
+				otherLease.UnderlayIP = "10.244.11.23"
+				otherLease.OverlayHardwareAddr = "ee:ee:0a:ff:11:01"
+				err = databaseHandler.AddEntry(otherLease)
+				Expect(err).To(Equal(database.DuplicateEntryError))
+			})
+		})
+
+		DescribeTable("when the driver reports a unique violation",
+			func(driverName string, driverErr error) {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.DriverNameReturns(driverName)
+				mockDb.ExecReturns(nil, driverErr)
+
+				err := databaseHandler.AddEntry(lease)
+				Expect(err).To(Equal(database.DuplicateEntryError))
+			},
+			Entry("mysql", "mysql", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}),
+			Entry("postgres", "postgres", &pq.Error{Code: "23505", Message: "duplicate key value"}),
+		)
+
+		Context("when the insert fails otherwise", func() {
+			It("wraps the error", func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.DriverNameReturns("mysql")
+				mockDb.ExecReturns(nil, &mysql.MySQLError{Number: 1045, Message: "Access denied"})
+
+				err := databaseHandler.AddEntry(lease)
+				Expect(err).To(MatchError("adding entry: Error 1045: Access denied"))
 			})
 		})
 
@@ -304,6 +353,44 @@ var _ = Describe("DatabaseHandler", func() {
 		})
 	})
 
+	Describe("SetFailoverUnderlayIP", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("sets and clears the failover underlay ip of the lease", func() {
+			err := databaseHandler.SetFailoverUnderlayIP("10.244.11.22", "10.245.11.22")
+			Expect(err).NotTo(HaveOccurred())
+
+			found, err := databaseHandler.LeaseForUnderlayIP("10.244.11.22")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found.FailoverUnderlayIP).To(Equal("10.245.11.22"))
+
+			err = databaseHandler.SetFailoverUnderlayIP("10.244.11.22", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			found, err = databaseHandler.LeaseForUnderlayIP("10.244.11.22")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*found).To(Equal(lease))
+		})
+
+		Context("when the update fails", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.ExecReturns(nil, errors.New("kiwi"))
+			})
+
+			It("returns an error", func() {
+				err := databaseHandler.SetFailoverUnderlayIP("10.244.11.22", "10.245.11.22")
+				Expect(err).To(MatchError("setting failover underlay ip: kiwi"))
+			})
+		})
+	})
+
 	Describe("RenewLeaseForUnderlayIP", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
@@ -364,6 +451,47 @@ var _ = Describe("DatabaseHandler", func() {
 		})
 	})
 
+	Describe("AllWithLastRenewedAt", func() {
+		BeforeEach(func() {
+			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
+			_, err := databaseHandler.Migrate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AddEntry(lease2)).To(Succeed())
+			Expect(databaseHandler.AddEntry(lease)).To(Succeed())
+		})
+
+		It("returns the saved subnets with their last renewal, by underlay ip", func() {
+			cells, err := databaseHandler.AllWithLastRenewedAt()
+			Expect(err).NotTo(HaveOccurred())
+
+			lastRenewedAt, err := databaseHandler.LastRenewedAtForUnderlayIP(lease.UnderlayIP)
+			Expect(err).NotTo(HaveOccurred())
+			lastRenewedAt2, err := databaseHandler.LastRenewedAtForUnderlayIP(lease2.UnderlayIP)
+			Expect(err).NotTo(HaveOccurred())
+
+			expected := []controller.TopologyCell{
+				{Lease: lease, LastRenewedAt: lastRenewedAt},
+				{Lease: lease2, LastRenewedAt: lastRenewedAt2},
+			}
+			if lease2.UnderlayIP < lease.UnderlayIP {
+				expected[0], expected[1] = expected[1], expected[0]
+			}
+			Expect(cells).To(Equal(expected))
+		})
+
+		Context("when the query fails", func() {
+			BeforeEach(func() {
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
+			})
+
+			It("returns an error", func() {
+				_, err := databaseHandler.AllWithLastRenewedAt()
+				Expect(err).To(MatchError("selecting all subnets: strawberry"))
+			})
+		})
+	})
+
 	Describe("LastRenewedAtForUnderlayIP", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
@@ -671,7 +799,7 @@ var _ = Describe("DatabaseHandler", func() {
 		})
 	})
 
-	Describe("OldestExpiredBlockSubnet", func() {
+	Describe("ExpiredBlockSubnets", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
@@ -680,13 +808,23 @@ var _ = Describe("DatabaseHandler", func() {
 			Expect(err).NotTo(HaveOccurred())
 			err = databaseHandler.AddEntry(lease)
 			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(lease2)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("gets the oldest lease that is expired", func() {
-			expiredLease, err := databaseHandler.OldestExpiredBlockSubnet(0)
+		It("gets the block subnet leases that are expired", func() {
+			expiredLeases, err := databaseHandler.ExpiredBlockSubnets(0)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(expiredLease).To(Equal(&lease))
+			Expect(expiredLeases).To(ConsistOf(lease, lease2))
+		})
+
+		Context("when no lease is expired", func() {
+			It("returns no leases and does not error", func() {
+				expiredLeases, err := databaseHandler.ExpiredBlockSubnets(23)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(expiredLeases).To(BeEmpty())
+			})
 		})
 
 		Context("when the database type is not supported", func() {
@@ -695,40 +833,44 @@ var _ = Describe("DatabaseHandler", func() {
 				mockDb.DriverNameReturns("foo")
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredBlockSubnet(23)
+				_, err := databaseHandler.ExpiredBlockSubnets(23)
 				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 
-		Context("when no lease is expired", func() {
+		Context("when the query fails", func() {
 			BeforeEach(func() {
-				databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
-				_, err := databaseHandler.Migrate()
-				Expect(err).NotTo(HaveOccurred())
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
-			It("returns nil and does not error", func() {
-				lease, err := databaseHandler.OldestExpiredBlockSubnet(23)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(lease).To(BeNil())
+			It("returns an error", func() {
+				_, err := databaseHandler.ExpiredBlockSubnets(23)
+				Expect(err).To(MatchError("selecting expired subnets: strawberry"))
 			})
 		})
 
 		Context("when parsing the result fails", func() {
-			var result *sql.Row
+			var rows *sql.Rows
 			BeforeEach(func() {
-				result = realDb.QueryRow("SELECT 1")
+				var err error
+				rows, err = realDb.Query("SELECT 1")
+				Expect(err).NotTo(HaveOccurred())
 
-				mockDb.QueryRowReturns(result)
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(rows, nil)
+			})
+
+			AfterEach(func() {
+				Expect(rows.Close()).To(Succeed())
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredBlockSubnet(23)
-				Expect(err).To(MatchError(ContainSubstring("scan result:")))
+				_, err := databaseHandler.ExpiredBlockSubnets(23)
+				Expect(err.Error()).To(ContainSubstring("selecting expired subnets: parsing result"))
 			})
 		})
 	})
 
-	Describe("OldestExipredSingleIP", func() {
+	Describe("ExpiredSingleIPs", func() {
 		BeforeEach(func() {
 			databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
 			_, err := databaseHandler.Migrate()
@@ -737,13 +879,23 @@ var _ = Describe("DatabaseHandler", func() {
 			Expect(err).NotTo(HaveOccurred())
 			err = databaseHandler.AddEntry(singleIPLease)
 			Expect(err).NotTo(HaveOccurred())
+			err = databaseHandler.AddEntry(singleIPLease2)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("gets the oldest lease that is expired", func() {
-			expiredLease, err := databaseHandler.OldestExpiredSingleIP(0)
+		It("gets the single ip leases that are expired", func() {
+			expiredLeases, err := databaseHandler.ExpiredSingleIPs(0)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(expiredLease).To(Equal(&singleIPLease))
+			Expect(expiredLeases).To(ConsistOf(singleIPLease, singleIPLease2))
+		})
+
+		Context("when no lease is expired", func() {
+			It("returns no leases and does not error", func() {
+				expiredLeases, err := databaseHandler.ExpiredSingleIPs(23)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(expiredLeases).To(BeEmpty())
+			})
 		})
 
 		Context("when the database type is not supported", func() {
@@ -752,35 +904,39 @@ var _ = Describe("DatabaseHandler", func() {
 				mockDb.DriverNameReturns("foo")
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredSingleIP(23)
+				_, err := databaseHandler.ExpiredSingleIPs(23)
 				Expect(err).To(MatchError("database type foo is not supported"))
 			})
 		})
 
-		Context("when no lease is expired", func() {
+		Context("when the query fails", func() {
 			BeforeEach(func() {
-				databaseHandler = database.NewDatabaseHandler(realMigrateAdapter, realDb)
-				_, err := databaseHandler.Migrate()
-				Expect(err).NotTo(HaveOccurred())
+				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(nil, errors.New("strawberry"))
 			})
-			It("returns nil and does not error", func() {
-				lease, err := databaseHandler.OldestExpiredSingleIP(23)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(lease).To(BeNil())
+			It("returns an error", func() {
+				_, err := databaseHandler.ExpiredSingleIPs(23)
+				Expect(err).To(MatchError("selecting expired subnets: strawberry"))
 			})
 		})
 
 		Context("when parsing the result fails", func() {
-			var result *sql.Row
+			var rows *sql.Rows
 			BeforeEach(func() {
-				result = realDb.QueryRow("SELECT 1")
+				var err error
+				rows, err = realDb.Query("SELECT 1")
+				Expect(err).NotTo(HaveOccurred())
 
-				mockDb.QueryRowReturns(result)
 				databaseHandler = database.NewDatabaseHandler(mockMigrateAdapter, mockDb)
+				mockDb.QueryReturns(rows, nil)
+			})
+
+			AfterEach(func() {
+				Expect(rows.Close()).To(Succeed())
 			})
 			It("returns an error", func() {
-				_, err := databaseHandler.OldestExpiredSingleIP(23)
-				Expect(err).To(MatchError(ContainSubstring("scan result:")))
+				_, err := databaseHandler.ExpiredSingleIPs(23)
+				Expect(err.Error()).To(ContainSubstring("selecting expired subnets: parsing result"))
 			})
 		})
 	})
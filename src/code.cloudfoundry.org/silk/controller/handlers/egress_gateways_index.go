@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type EgressGatewaysIndex struct {
+	Marshaler      marshal.Marshaler
+	EgressGateways []controller.EgressGateway
+	ErrorResponse  errorResponse
+}
+
+func (e *EgressGatewaysIndex) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("egress-gateways-index")
+
+	gateways := e.EgressGateways
+	if gateways == nil {
+		gateways = []controller.EgressGateway{}
+	}
+
+	response := struct {
+		EgressGateways []controller.EgressGateway `json:"egress_gateways"`
+	}{gateways}
+	bytes, err := e.Marshaler.Marshal(response)
+	if err != nil {
+		e.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
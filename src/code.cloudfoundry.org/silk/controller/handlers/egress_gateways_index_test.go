@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EgressGatewaysIndex", func() {
+	var (
+		logger            *lagertest.TestLogger
+		expectedLogger    lager.Logger
+		handler           *handlers.EgressGatewaysIndex
+		resp              *httptest.ResponseRecorder
+		marshaler         *hfakes.Marshaler
+		fakeErrorResponse *fakes.ErrorResponse
+		request           *http.Request
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("egress-gateways-index")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		fakeErrorResponse = &fakes.ErrorResponse{}
+		handler = &handlers.EgressGatewaysIndex{
+			Marshaler: marshaler,
+			EgressGateways: []controller.EgressGateway{
+				{
+					ID:         1,
+					Name:       "gw-0",
+					UnderlayIP: "10.244.5.9",
+					EgressIP:   "10.244.100.1",
+					SpaceGUIDs: []string{"space-a"},
+				},
+			},
+			ErrorResponse: fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+
+		var err error
+		request, err = http.NewRequest("GET", "/egress_gateways", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the configured egress gateways", func() {
+		handler.ServeHTTP(logger, resp, request)
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(`{ "egress_gateways": [
+			{ "id": 1, "name": "gw-0", "underlay_ip": "10.244.5.9", "egress_ip": "10.244.100.1", "space_guids": ["space-a"] }
+		] }`))
+	})
+
+	Context("when no egress gateways are configured", func() {
+		BeforeEach(func() {
+			handler.EgressGateways = nil
+		})
+
+		It("returns an empty list", func() {
+			handler.ServeHTTP(logger, resp, request)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body).To(MatchJSON(`{ "egress_gateways": [] }`))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
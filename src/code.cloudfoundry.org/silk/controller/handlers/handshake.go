@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+// Handshake negotiates the version and the features of the API with a silk
+// daemon, and answers with a conflict if their versions are too far apart.
+type Handshake struct {
+	Marshaler     marshal.Marshaler
+	Unmarshaler   marshal.Unmarshaler
+	APIInfo       controller.APIInfo
+	ErrorResponse errorResponse
+}
+
+func (h *Handshake) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("handshake")
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		h.ErrorResponse.BadRequest(logger, w, err, fmt.Sprintf("read-body: %s", err.Error()))
+		return
+	}
+
+	var info controller.APIInfo
+	err = h.Unmarshaler.Unmarshal(bodyBytes, &info)
+	if err != nil {
+		h.ErrorResponse.BadRequest(logger, w, err, fmt.Sprintf("unmarshal-request: %s", err.Error()))
+		return
+	}
+
+	handshake, err := controller.Negotiate(h.APIInfo, info)
+	if err != nil {
+		h.ErrorResponse.Conflict(logger, w, err, fmt.Sprintf("negotiate: %s", err.Error()))
+		return
+	}
+
+	bytes, err := h.Marshaler.Marshal(handshake)
+	if err != nil {
+		h.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
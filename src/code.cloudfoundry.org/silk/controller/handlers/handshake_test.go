@@ -0,0 +1,143 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/cf-networking-helpers/testsupport"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handshake", func() {
+	var (
+		logger            *lagertest.TestLogger
+		expectedLogger    lager.Logger
+		handler           *handlers.Handshake
+		resp              *httptest.ResponseRecorder
+		marshaler         *hfakes.Marshaler
+		unmarshaler       *hfakes.Unmarshaler
+		fakeErrorResponse *fakes.ErrorResponse
+		request           *http.Request
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("handshake")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		unmarshaler = &hfakes.Unmarshaler{}
+		unmarshaler.UnmarshalStub = json.Unmarshal
+		fakeErrorResponse = &fakes.ErrorResponse{}
+
+		handler = &handlers.Handshake{
+			Marshaler:   marshaler,
+			Unmarshaler: unmarshaler,
+			APIInfo: controller.APIInfo{
+				Version:    3,
+				MinVersion: 2,
+				Features:   []string{"egress_gateways", "topology"},
+			},
+			ErrorResponse: fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+
+		requestBody := bytes.NewBuffer([]byte(`{ "version": 4, "min_version": 2, "features": ["topology", "something_new"] }`))
+		var err error
+		request, err = http.NewRequest("PUT", "/handshake", requestBody)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the negotiated version and features", func() {
+		handler.ServeHTTP(logger, resp, request)
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(`{ "version": 3, "features": ["topology"] }`))
+	})
+
+	Context("when the versions are too far apart", func() {
+		BeforeEach(func() {
+			request.Body = ioutil.NopCloser(bytes.NewBufferString(`{ "version": 1, "min_version": 1 }`))
+		})
+
+		It("returns a Conflict error", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.ConflictCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.ConflictArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("incompatible api versions: 2 to 3 and 1 to 1"))
+			Expect(description).To(Equal("negotiate: incompatible api versions: 2 to 3 and 1 to 1"))
+		})
+	})
+
+	Context("when there are errors reading the body bytes", func() {
+		BeforeEach(func() {
+			request.Body = ioutil.NopCloser(&testsupport.BadReader{})
+		})
+
+		It("returns a BadRequest error", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("banana"))
+			Expect(description).To(Equal("read-body: banana"))
+		})
+	})
+
+	Context("when the request cannot be unmarshaled", func() {
+		BeforeEach(func() {
+			unmarshaler.UnmarshalReturns(errors.New("fig"))
+		})
+
+		It("returns a BadRequest error", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.BadRequestCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.BadRequestArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("fig"))
+			Expect(description).To(Equal("unmarshal-request: fig"))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/lease_conflict_repository.go --fake-name LeaseConflictRepository . leaseConflictRepository
+type leaseConflictRepository interface {
+	LeaseConflicts() ([]controller.LeaseConflict, error)
+}
+
+type LeaseConflictsIndex struct {
+	Marshaler               marshal.Marshaler
+	LeaseConflictRepository leaseConflictRepository
+	ErrorResponse           errorResponse
+}
+
+func (l *LeaseConflictsIndex) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("lease-conflicts-index")
+
+	conflicts, err := l.LeaseConflictRepository.LeaseConflicts()
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("lease-conflicts: %s", err.Error()))
+		return
+	}
+	if conflicts == nil {
+		conflicts = []controller.LeaseConflict{}
+	}
+
+	response := struct {
+		Conflicts []controller.LeaseConflict `json:"conflicts"`
+	}{conflicts}
+	bytes, err := l.Marshaler.Marshal(response)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
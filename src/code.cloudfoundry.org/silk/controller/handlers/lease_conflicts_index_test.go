@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeaseConflictsIndex", func() {
+	var (
+		logger                  *lagertest.TestLogger
+		expectedLogger          lager.Logger
+		handler                 *handlers.LeaseConflictsIndex
+		leaseConflictRepository *fakes.LeaseConflictRepository
+		resp                    *httptest.ResponseRecorder
+		marshaler               *hfakes.Marshaler
+		fakeErrorResponse       *fakes.ErrorResponse
+		request                 *http.Request
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("lease-conflicts-index")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		leaseConflictRepository = &fakes.LeaseConflictRepository{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+		handler = &handlers.LeaseConflictsIndex{
+			Marshaler:               marshaler,
+			LeaseConflictRepository: leaseConflictRepository,
+			ErrorResponse:           fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+		leaseConflictRepository.LeaseConflictsReturns([]controller.LeaseConflict{
+			{
+				Leases: []controller.Lease{
+					{
+						UnderlayIP:          "10.244.5.9",
+						OverlaySubnet:       "10.255.16.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+					},
+					{
+						UnderlayIP:          "10.244.22.33",
+						OverlaySubnet:       "10.255.16.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+					},
+				},
+			},
+		}, nil)
+
+		var err error
+		request, err = http.NewRequest("GET", "/leases/conflicts", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the lease conflicts", func() {
+		expectedResponseJSON := `{ "conflicts": [ { "leases": [
+			{ "underlay_ip": "10.244.5.9", "overlay_subnet": "10.255.16.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:10:00" },
+			{ "underlay_ip": "10.244.22.33", "overlay_subnet": "10.255.16.0/24", "overlay_hardware_addr": "ee:ee:0a:ff:10:00" }
+		] } ] }`
+
+		handler.ServeHTTP(logger, resp, request)
+		Expect(leaseConflictRepository.LeaseConflictsCallCount()).To(Equal(1))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(expectedResponseJSON))
+	})
+
+	Context("when there are no conflicts", func() {
+		BeforeEach(func() {
+			leaseConflictRepository.LeaseConflictsReturns(nil, nil)
+		})
+
+		It("returns an empty list", func() {
+			handler.ServeHTTP(logger, resp, request)
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body).To(MatchJSON(`{ "conflicts": [] }`))
+		})
+	})
+
+	Context("when getting the lease conflicts fails", func() {
+		BeforeEach(func() {
+			leaseConflictRepository.LeaseConflictsReturns(nil, errors.New("butter"))
+		})
+
+		It("calls the internal server error handler", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("butter"))
+			Expect(description).To(Equal("lease-conflicts: butter"))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+//go:generate counterfeiter -o fakes/lease_conflict_resolver.go --fake-name LeaseConflictResolver . leaseConflictResolver
+type leaseConflictResolver interface {
+	ResolveLeaseConflict(underlayIP string) error
+}
+
+type ResolveLeaseConflict struct {
+	Unmarshaler           marshal.Unmarshaler
+	LeaseConflictResolver leaseConflictResolver
+	ErrorResponse         errorResponse
+}
+
+func (l *ResolveLeaseConflict) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("lease-conflicts-resolve")
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		l.ErrorResponse.BadRequest(logger, w, err, fmt.Sprintf("read-body: %s", err.Error()))
+		return
+	}
+
+	var payload struct {
+		UnderlayIP string `json:"underlay_ip"`
+	}
+	err = l.Unmarshaler.Unmarshal(bodyBytes, &payload)
+	if err != nil {
+		l.ErrorResponse.BadRequest(logger, w, err, fmt.Sprintf("unmarshal-request: %s", err.Error()))
+		return
+	}
+
+	err = l.LeaseConflictResolver.ResolveLeaseConflict(payload.UnderlayIP)
+	if err != nil {
+		l.ErrorResponse.InternalServerError(logger, w, err, err.Error())
+		return
+	}
+
+	w.Write([]byte(`{}`))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/topology_repository.go --fake-name TopologyRepository . topologyRepository
+type topologyRepository interface {
+	Topology() (controller.Topology, error)
+}
+
+//go:generate counterfeiter -o fakes/topology_signer.go --fake-name TopologySigner . topologySigner
+type topologySigner interface {
+	Sign(controller.Topology) (controller.SignedTopology, error)
+}
+
+type Topology struct {
+	Marshaler          marshal.Marshaler
+	TopologyRepository topologyRepository
+	Signer             topologySigner
+	ErrorResponse      errorResponse
+}
+
+func (t *Topology) ServeHTTP(logger lager.Logger, w http.ResponseWriter, req *http.Request) {
+	logger = logger.Session("topology")
+
+	topology, err := t.TopologyRepository.Topology()
+	if err != nil {
+		t.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("topology: %s", err.Error()))
+		return
+	}
+
+	signed, err := t.Signer.Sign(topology)
+	if err != nil {
+		t.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("sign-topology: %s", err.Error()))
+		return
+	}
+
+	bytes, err := t.Marshaler.Marshal(signed)
+	if err != nil {
+		t.ErrorResponse.InternalServerError(logger, w, err, fmt.Sprintf("marshal-response: %s", err.Error()))
+		return
+	}
+
+	w.Write(bytes)
+}
@@ -0,0 +1,154 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/handlers"
+	"code.cloudfoundry.org/silk/controller/handlers/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Topology", func() {
+	var (
+		logger             *lagertest.TestLogger
+		expectedLogger     lager.Logger
+		handler            *handlers.Topology
+		topologyRepository *fakes.TopologyRepository
+		signer             *fakes.TopologySigner
+		resp               *httptest.ResponseRecorder
+		marshaler          *hfakes.Marshaler
+		fakeErrorResponse  *fakes.ErrorResponse
+		request            *http.Request
+		topology           controller.Topology
+	)
+
+	BeforeEach(func() {
+		expectedLogger = lager.NewLogger("test").Session("topology")
+
+		testSink := lagertest.NewTestSink()
+		expectedLogger.RegisterSink(testSink)
+		expectedLogger.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+		logger = lagertest.NewTestLogger("test")
+		marshaler = &hfakes.Marshaler{}
+		marshaler.MarshalStub = json.Marshal
+		topologyRepository = &fakes.TopologyRepository{}
+		signer = &fakes.TopologySigner{}
+		fakeErrorResponse = &fakes.ErrorResponse{}
+		handler = &handlers.Topology{
+			Marshaler:          marshaler,
+			TopologyRepository: topologyRepository,
+			Signer:             signer,
+			ErrorResponse:      fakeErrorResponse,
+		}
+		resp = httptest.NewRecorder()
+
+		topology = controller.Topology{
+			Version:                "some-version",
+			GeneratedAt:            1700000000,
+			LeaseExpirationSeconds: 60,
+			Cells: []controller.TopologyCell{{
+				Lease: controller.Lease{
+					UnderlayIP:          "10.244.5.9",
+					OverlaySubnet:       "10.255.16.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+				},
+				LastRenewedAt: 1699999990,
+				Routable:      true,
+			}},
+		}
+		topologyRepository.TopologyReturns(topology, nil)
+		signer.SignReturns(controller.SignedTopology{
+			Topology: json.RawMessage(`{"version":"some-version"}`),
+			Signature: controller.TopologySignature{
+				Algorithm:    "ECDSA-SHA256",
+				Certificates: "some-certificates",
+				Value:        []byte("some-signature"),
+			},
+		}, nil)
+
+		var err error
+		request, err = http.NewRequest("GET", "/topology", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the signed topology", func() {
+		handler.ServeHTTP(logger, resp, request)
+
+		Expect(topologyRepository.TopologyCallCount()).To(Equal(1))
+		Expect(signer.SignCallCount()).To(Equal(1))
+		Expect(signer.SignArgsForCall(0)).To(Equal(topology))
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Body).To(MatchJSON(`{
+			"topology": { "version": "some-version" },
+			"signature": {
+				"algorithm": "ECDSA-SHA256",
+				"certificates": "some-certificates",
+				"value": "c29tZS1zaWduYXR1cmU="
+			}
+		}`))
+	})
+
+	Context("when getting the topology fails", func() {
+		BeforeEach(func() {
+			topologyRepository.TopologyReturns(controller.Topology{}, errors.New("butter"))
+		})
+
+		It("calls the internal server error handler", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(signer.SignCallCount()).To(Equal(0))
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("butter"))
+			Expect(description).To(Equal("topology: butter"))
+		})
+	})
+
+	Context("when signing the topology fails", func() {
+		BeforeEach(func() {
+			signer.SignReturns(controller.SignedTopology{}, errors.New("peanut"))
+		})
+
+		It("calls the internal server error handler", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("peanut"))
+			Expect(description).To(Equal("sign-topology: peanut"))
+		})
+	})
+
+	Context("when the response cannot be marshaled", func() {
+		BeforeEach(func() {
+			marshaler.MarshalStub = func(interface{}) ([]byte, error) {
+				return nil, errors.New("grapes")
+			}
+		})
+
+		It("calls the internal server error handler", func() {
+			handler.ServeHTTP(logger, resp, request)
+
+			Expect(fakeErrorResponse.InternalServerErrorCallCount()).To(Equal(1))
+			l, w, err, description := fakeErrorResponse.InternalServerErrorArgsForCall(0)
+			Expect(l).To(Equal(expectedLogger))
+			Expect(w).To(Equal(resp))
+			Expect(err).To(MatchError("grapes"))
+			Expect(description).To(Equal("marshal-response: grapes"))
+		})
+	})
+})
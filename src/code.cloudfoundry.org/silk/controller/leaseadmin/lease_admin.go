@@ -0,0 +1,169 @@
+package leaseadmin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/lease_store.go --fake-name LeaseStore . leaseStore
+type leaseStore interface {
+	All() ([]controller.Lease, error)
+	LeaseForUnderlayIP(string) (*controller.Lease, error)
+	LastRenewedAtForUnderlayIP(string) (int64, error)
+	RenewLeaseForUnderlayIP(string) error
+	DeleteEntry(string) error
+}
+
+// LeaseAdmin implements the lease operations of the silk-admin CLI. Revoking
+// and extending a lease asks for confirmation unless Force is set, and is
+// recorded in the audit log together with the operator who ran it.
+type LeaseAdmin struct {
+	Store                  leaseStore
+	LeaseExpirationSeconds int
+	In                     io.Reader
+	Out                    io.Writer
+	AuditLogger            lager.Logger
+	Operator               string
+	Force                  bool
+}
+
+// Run runs a lease operation given as command line arguments, e.g.
+// "revoke 10.0.16.5".
+func (a *LeaseAdmin) Run(args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		return a.List()
+	case len(args) == 2 && args[0] == "inspect":
+		return a.Inspect(args[1])
+	case len(args) == 2 && args[0] == "revoke":
+		return a.Revoke(args[1])
+	case len(args) == 2 && args[0] == "extend":
+		return a.Extend(args[1])
+	}
+	return fmt.Errorf("unknown lease operation: %s", strings.Join(args, " "))
+}
+
+func (a *LeaseAdmin) List() error {
+	leases, err := a.Store.All()
+	if err != nil {
+		return fmt.Errorf("getting all leases: %s", err)
+	}
+
+	w := tabwriter.NewWriter(a.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UNDERLAY IP\tOVERLAY SUBNET\tHARDWARE ADDRESS\tLAST RENEWED AT\tEXPIRES AT")
+	for _, lease := range leases {
+		lastRenewedAt, err := a.Store.LastRenewedAtForUnderlayIP(lease.UnderlayIP)
+		if err != nil {
+			return fmt.Errorf("getting last renewed at for %s: %s", lease.UnderlayIP, err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", lease.UnderlayIP, lease.OverlaySubnet, lease.OverlayHardwareAddr, a.formatTime(lastRenewedAt), a.formatTime(a.expiresAt(lastRenewedAt)))
+	}
+	return w.Flush()
+}
+
+func (a *LeaseAdmin) Inspect(underlayIP string) error {
+	lease, lastRenewedAt, err := a.lease(underlayIP)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Out, "underlay ip:      %s\n", lease.UnderlayIP)
+	fmt.Fprintf(a.Out, "overlay subnet:   %s\n", lease.OverlaySubnet)
+	fmt.Fprintf(a.Out, "hardware address: %s\n", lease.OverlayHardwareAddr)
+	fmt.Fprintf(a.Out, "last renewed at:  %s\n", a.formatTime(lastRenewedAt))
+	fmt.Fprintf(a.Out, "expires at:       %s\n", a.formatTime(a.expiresAt(lastRenewedAt)))
+	return nil
+}
+
+// Revoke deletes the lease of a cell. The cell acquires a new lease the next
+// time its silk-daemon starts.
+func (a *LeaseAdmin) Revoke(underlayIP string) error {
+	lease, _, err := a.lease(underlayIP)
+	if err != nil {
+		return err
+	}
+	if !a.confirm(fmt.Sprintf("Revoke lease %s of %s?", lease.OverlaySubnet, lease.UnderlayIP)) {
+		return fmt.Errorf("revoking lease of %s: not confirmed", underlayIP)
+	}
+
+	err = a.Store.DeleteEntry(underlayIP)
+	if err != nil {
+		a.AuditLogger.Error("lease-revoke-failed", err, lager.Data{"operator": a.Operator, "lease": lease})
+		return fmt.Errorf("revoking lease of %s: %s", underlayIP, err)
+	}
+
+	a.AuditLogger.Info("lease-revoked", lager.Data{"operator": a.Operator, "lease": lease})
+	fmt.Fprintf(a.Out, "revoked lease %s of %s\n", lease.OverlaySubnet, lease.UnderlayIP)
+	return nil
+}
+
+// Extend renews the lease of a cell as if its silk-daemon had just renewed it,
+// e.g. to keep it from being reclaimed while the cell is being recovered.
+func (a *LeaseAdmin) Extend(underlayIP string) error {
+	lease, lastRenewedAt, err := a.lease(underlayIP)
+	if err != nil {
+		return err
+	}
+	if !a.confirm(fmt.Sprintf("Extend lease %s of %s?", lease.OverlaySubnet, lease.UnderlayIP)) {
+		return fmt.Errorf("extending lease of %s: not confirmed", underlayIP)
+	}
+
+	err = a.Store.RenewLeaseForUnderlayIP(underlayIP)
+	if err != nil {
+		a.AuditLogger.Error("lease-extend-failed", err, lager.Data{"operator": a.Operator, "lease": lease})
+		return fmt.Errorf("extending lease of %s: %s", underlayIP, err)
+	}
+	renewedAt, err := a.Store.LastRenewedAtForUnderlayIP(underlayIP)
+	if err != nil {
+		return fmt.Errorf("getting last renewed at for %s: %s", underlayIP, err)
+	}
+
+	a.AuditLogger.Info("lease-extended", lager.Data{
+		"operator":                 a.Operator,
+		"lease":                    lease,
+		"previous_last_renewed_at": lastRenewedAt,
+		"last_renewed_at":          renewedAt,
+	})
+	fmt.Fprintf(a.Out, "extended lease %s of %s until %s\n", lease.OverlaySubnet, lease.UnderlayIP, a.formatTime(a.expiresAt(renewedAt)))
+	return nil
+}
+
+func (a *LeaseAdmin) lease(underlayIP string) (*controller.Lease, int64, error) {
+	lease, err := a.Store.LeaseForUnderlayIP(underlayIP)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting lease for %s: %s", underlayIP, err)
+	}
+	if lease == nil {
+		return nil, 0, fmt.Errorf("no lease for %s", underlayIP)
+	}
+	lastRenewedAt, err := a.Store.LastRenewedAtForUnderlayIP(underlayIP)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting last renewed at for %s: %s", underlayIP, err)
+	}
+	return lease, lastRenewedAt, nil
+}
+
+func (a *LeaseAdmin) confirm(question string) bool {
+	if a.Force {
+		return true
+	}
+	fmt.Fprintf(a.Out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(a.In).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func (a *LeaseAdmin) expiresAt(lastRenewedAt int64) int64 {
+	return lastRenewedAt + int64(a.LeaseExpirationSeconds)
+}
+
+func (a *LeaseAdmin) formatTime(unixSeconds int64) string {
+	return time.Unix(unixSeconds, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,170 @@
+package leaseadmin_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/leaseadmin"
+	"code.cloudfoundry.org/silk/controller/leaseadmin/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeaseAdmin", func() {
+	var (
+		store       *fakes.LeaseStore
+		auditLogger *lagertest.TestLogger
+		out         *bytes.Buffer
+		admin       *leaseadmin.LeaseAdmin
+		lease       controller.Lease
+	)
+
+	BeforeEach(func() {
+		store = &fakes.LeaseStore{}
+		auditLogger = lagertest.NewTestLogger("audit")
+		out = &bytes.Buffer{}
+		admin = &leaseadmin.LeaseAdmin{
+			Store:                  store,
+			LeaseExpirationSeconds: 3600,
+			In:                     strings.NewReader(""),
+			Out:                    out,
+			AuditLogger:            auditLogger,
+			Operator:               "some-operator",
+		}
+
+		lease = controller.Lease{
+			UnderlayIP:          "10.0.16.5",
+			OverlaySubnet:       "10.255.16.0/24",
+			OverlayHardwareAddr: "ee:ee:0a:ff:10:00",
+		}
+		store.AllReturns([]controller.Lease{lease}, nil)
+		store.LeaseForUnderlayIPReturns(&lease, nil)
+		store.LastRenewedAtForUnderlayIPReturns(0, nil)
+	})
+
+	Describe("list", func() {
+		It("prints every lease with its expiry", func() {
+			Expect(admin.Run([]string{"list"})).To(Succeed())
+			Expect(out.String()).To(Equal(
+				"UNDERLAY IP  OVERLAY SUBNET  HARDWARE ADDRESS   LAST RENEWED AT       EXPIRES AT\n" +
+					"10.0.16.5    10.255.16.0/24  ee:ee:0a:ff:10:00  1970-01-01T00:00:00Z  1970-01-01T01:00:00Z\n"))
+		})
+
+		Context("when getting the leases fails", func() {
+			BeforeEach(func() {
+				store.AllReturns(nil, errors.New("banana"))
+			})
+			It("returns an error", func() {
+				Expect(admin.Run([]string{"list"})).To(MatchError("getting all leases: banana"))
+			})
+		})
+	})
+
+	Describe("inspect", func() {
+		It("prints the lease", func() {
+			Expect(admin.Run([]string{"inspect", "10.0.16.5"})).To(Succeed())
+			Expect(store.LeaseForUnderlayIPArgsForCall(0)).To(Equal("10.0.16.5"))
+			Expect(out.String()).To(ContainSubstring("overlay subnet:   10.255.16.0/24\n"))
+			Expect(out.String()).To(ContainSubstring("expires at:       1970-01-01T01:00:00Z\n"))
+		})
+
+		Context("when the cell has no lease", func() {
+			BeforeEach(func() {
+				store.LeaseForUnderlayIPReturns(nil, nil)
+			})
+			It("returns an error", func() {
+				Expect(admin.Run([]string{"inspect", "10.0.16.5"})).To(MatchError("no lease for 10.0.16.5"))
+			})
+		})
+	})
+
+	Describe("revoke", func() {
+		Context("when the operator confirms", func() {
+			BeforeEach(func() {
+				admin.In = strings.NewReader("yes\n")
+			})
+
+			It("deletes the lease and audits it", func() {
+				Expect(admin.Run([]string{"revoke", "10.0.16.5"})).To(Succeed())
+				Expect(store.DeleteEntryCallCount()).To(Equal(1))
+				Expect(store.DeleteEntryArgsForCall(0)).To(Equal("10.0.16.5"))
+
+				Expect(auditLogger.Logs()).To(HaveLen(1))
+				Expect(auditLogger.Logs()[0].Message).To(Equal("audit.lease-revoked"))
+				Expect(auditLogger.Logs()[0].Data).To(HaveKeyWithValue("operator", "some-operator"))
+			})
+
+			Context("when deleting the lease fails", func() {
+				BeforeEach(func() {
+					store.DeleteEntryReturns(errors.New("banana"))
+				})
+				It("audits the failure and returns an error", func() {
+					Expect(admin.Run([]string{"revoke", "10.0.16.5"})).To(MatchError("revoking lease of 10.0.16.5: banana"))
+					Expect(auditLogger.Logs()[0].Message).To(Equal("audit.lease-revoke-failed"))
+					Expect(auditLogger.Logs()[0].LogLevel).To(Equal(lager.ERROR))
+				})
+			})
+		})
+
+		Context("when the operator does not confirm", func() {
+			BeforeEach(func() {
+				admin.In = strings.NewReader("n\n")
+			})
+			It("does not delete the lease", func() {
+				Expect(admin.Run([]string{"revoke", "10.0.16.5"})).To(MatchError("revoking lease of 10.0.16.5: not confirmed"))
+				Expect(store.DeleteEntryCallCount()).To(Equal(0))
+				Expect(auditLogger.Logs()).To(BeEmpty())
+			})
+		})
+
+		Context("when forced", func() {
+			BeforeEach(func() {
+				admin.Force = true
+			})
+			It("does not ask for confirmation", func() {
+				Expect(admin.Run([]string{"revoke", "10.0.16.5"})).To(Succeed())
+				Expect(out.String()).NotTo(ContainSubstring("[y/N]"))
+				Expect(store.DeleteEntryCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("extend", func() {
+		BeforeEach(func() {
+			admin.In = strings.NewReader("y\n")
+			store.LastRenewedAtForUnderlayIPReturnsOnCall(1, 7200, nil)
+		})
+
+		It("renews the lease and audits it", func() {
+			Expect(admin.Run([]string{"extend", "10.0.16.5"})).To(Succeed())
+			Expect(store.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
+			Expect(store.RenewLeaseForUnderlayIPArgsForCall(0)).To(Equal("10.0.16.5"))
+			Expect(out.String()).To(ContainSubstring("until 1970-01-01T03:00:00Z"))
+
+			Expect(auditLogger.Logs()).To(HaveLen(1))
+			Expect(auditLogger.Logs()[0].Message).To(Equal("audit.lease-extended"))
+			Expect(auditLogger.Logs()[0].Data).To(HaveKeyWithValue("previous_last_renewed_at", float64(0)))
+			Expect(auditLogger.Logs()[0].Data).To(HaveKeyWithValue("last_renewed_at", float64(7200)))
+		})
+
+		Context("when renewing the lease fails", func() {
+			BeforeEach(func() {
+				store.RenewLeaseForUnderlayIPReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				Expect(admin.Run([]string{"extend", "10.0.16.5"})).To(MatchError("extending lease of 10.0.16.5: banana"))
+			})
+		})
+	})
+
+	Context("when the operation is unknown", func() {
+		It("returns an error", func() {
+			Expect(admin.Run([]string{"shred", "10.0.16.5"})).To(MatchError("unknown lease operation: shred 10.0.16.5"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package leaseadmin_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLeaseAdmin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lease Admin Suite")
+}
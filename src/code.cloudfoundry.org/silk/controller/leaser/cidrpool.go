@@ -2,6 +2,7 @@ package leaser
 
 import (
 	cryptoRand "crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"math"
 	"math/big"
@@ -14,6 +15,32 @@ import (
 type CIDRPool struct {
 	blockPool  map[string]struct{}
 	singlePool map[string]struct{}
+
+	// Shard is the part of the pool that is handed out first.
+	Shard Shard
+}
+
+// Shard splits the pool between the controller instances, so that instances
+// acquiring leases at the same time rarely pick the same subnet. A subnet
+// belongs to the shard of its address divided by its size, modulo Count. An
+// instance hands out subnets of other shards once its own is used up. A zero
+// Count leaves the pool whole.
+type Shard struct {
+	Index int
+	Count int
+}
+
+func (s Shard) owns(subnet string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	ip, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil || ip.To4() == nil {
+		return true
+	}
+	ones, _ := ipNet.Mask.Size()
+	n := binary.BigEndian.Uint32(ip.To4()) >> (32 - ones)
+	return int(n%uint32(s.Count)) == s.Index
 }
 
 func NewCIDRPool(subnetRange string, subnetMask int) *CIDRPool {
@@ -48,11 +75,11 @@ func (c *CIDRPool) SingleIPPoolSize() int {
 }
 
 func (c *CIDRPool) GetAvailableBlock(taken []string) string {
-	return getAvailable(taken, c.blockPool)
+	return getAvailable(taken, c.blockPool, c.Shard)
 }
 
 func (c *CIDRPool) GetAvailableSingleIP(taken []string) string {
-	return getAvailable(taken, c.singlePool)
+	return getAvailable(taken, c.singlePool, c.Shard)
 }
 
 func (c *CIDRPool) IsMember(subnet string) bool {
@@ -61,7 +88,7 @@ func (c *CIDRPool) IsMember(subnet string) bool {
 	return blockOk || singleOk
 }
 
-func getAvailable(taken []string, pool map[string]struct{}) string {
+func getAvailable(taken []string, pool map[string]struct{}, shard Shard) string {
 	available := make(map[string]struct{})
 	for k, v := range pool {
 		available[k] = v
@@ -69,12 +96,26 @@ func getAvailable(taken []string, pool map[string]struct{}) string {
 	for _, subnet := range taken {
 		delete(available, subnet)
 	}
-	if len(available) == 0 {
+
+	inShard := make(map[string]struct{})
+	for subnet := range available {
+		if shard.owns(subnet) {
+			inShard[subnet] = struct{}{}
+		}
+	}
+	if len(inShard) > 0 {
+		return pickRandom(inShard)
+	}
+	return pickRandom(available)
+}
+
+func pickRandom(subnets map[string]struct{}) string {
+	if len(subnets) == 0 {
 		return ""
 	}
-	i := mathRand.Intn(len(available))
+	i := mathRand.Intn(len(subnets))
 	n := 0
-	for subnet := range available {
+	for subnet := range subnets {
 		if i == n {
 			return subnet
 		}
@@ -1,6 +1,8 @@
 package leaser_test
 
 import (
+	"encoding/binary"
+
 	"code.cloudfoundry.org/silk/controller/leaser"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -101,6 +103,47 @@ var _ = Describe("CIDRPool", func() {
 		})
 	})
 
+	Context("when the pool is sharded", func() {
+		It("hands out the subnets of its shard first and then the others", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24)
+			cidrPool.Shard = leaser.Shard{Index: 1, Count: 3}
+
+			var taken []string
+			for i := 0; i < 85; i++ {
+				s := cidrPool.GetAvailableBlock(taken)
+				ip, _, err := net.ParseCIDR(s)
+				Expect(err).NotTo(HaveOccurred())
+				Expect((binary.BigEndian.Uint32(ip.To4()) >> 8) % 3).To(Equal(uint32(1)))
+				taken = append(taken, s)
+			}
+
+			for i := 85; i < 255; i++ {
+				s := cidrPool.GetAvailableBlock(taken)
+				Expect(s).NotTo(BeEmpty())
+				taken = append(taken, s)
+			}
+			Expect(cidrPool.GetAvailableBlock(taken)).To(BeEmpty())
+		})
+
+		It("shards single ips as well", func() {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 29)
+			cidrPool.Shard = leaser.Shard{Index: 0, Count: 2}
+
+			results := map[string]bool{}
+			var taken []string
+			for i := 0; i < 3; i++ {
+				s := cidrPool.GetAvailableSingleIP(taken)
+				results[s] = true
+				taken = append(taken, s)
+			}
+			Expect(results).To(Equal(map[string]bool{
+				"10.255.0.2/32": true,
+				"10.255.0.4/32": true,
+				"10.255.0.6/32": true,
+			}))
+		})
+	})
+
 	Describe("GetAvailableSingleIP", func() {
 		It("returns a single ip that is not taken", func() {
 			subnetRange := "10.255.0.0/16"
@@ -67,6 +67,18 @@ type DatabaseHandler struct {
 	renewLeaseForUnderlayIPReturnsOnCall map[int]struct {
 		result1 error
 	}
+	SetFailoverUnderlayIPStub        func(string, string) error
+	setFailoverUnderlayIPMutex       sync.RWMutex
+	setFailoverUnderlayIPArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	setFailoverUnderlayIPReturns struct {
+		result1 error
+	}
+	setFailoverUnderlayIPReturnsOnCall map[int]struct {
+		result1 error
+	}
 	AllStub        func() ([]controller.Lease, error)
 	allMutex       sync.RWMutex
 	allArgsForCall []struct{}
@@ -78,6 +90,18 @@ type DatabaseHandler struct {
 		result1 []controller.Lease
 		result2 error
 	}
+	AllWithLastRenewedAtStub        func() ([]controller.TopologyCell, error)
+	allWithLastRenewedAtMutex       sync.RWMutex
+	allWithLastRenewedAtArgsForCall []struct {
+	}
+	allWithLastRenewedAtReturns struct {
+		result1 []controller.TopologyCell
+		result2 error
+	}
+	allWithLastRenewedAtReturnsOnCall map[int]struct {
+		result1 []controller.TopologyCell
+		result2 error
+	}
 	AllBlockSubnetsStub        func() ([]controller.Lease, error)
 	allBlockSubnetsMutex       sync.RWMutex
 	allBlockSubnetsArgsForCall []struct{}
@@ -113,30 +137,30 @@ type DatabaseHandler struct {
 		result1 []controller.Lease
 		result2 error
 	}
-	OldestExpiredBlockSubnetStub        func(int) (*controller.Lease, error)
-	oldestExpiredBlockSubnetMutex       sync.RWMutex
-	oldestExpiredBlockSubnetArgsForCall []struct {
+	ExpiredBlockSubnetsStub        func(int) ([]controller.Lease, error)
+	expiredBlockSubnetsMutex       sync.RWMutex
+	expiredBlockSubnetsArgsForCall []struct {
 		arg1 int
 	}
-	oldestExpiredBlockSubnetReturns struct {
-		result1 *controller.Lease
+	expiredBlockSubnetsReturns struct {
+		result1 []controller.Lease
 		result2 error
 	}
-	oldestExpiredBlockSubnetReturnsOnCall map[int]struct {
-		result1 *controller.Lease
+	expiredBlockSubnetsReturnsOnCall map[int]struct {
+		result1 []controller.Lease
 		result2 error
 	}
-	OldestExpiredSingleIPStub        func(int) (*controller.Lease, error)
-	oldestExpiredSingleIPMutex       sync.RWMutex
-	oldestExpiredSingleIPArgsForCall []struct {
+	ExpiredSingleIPsStub        func(int) ([]controller.Lease, error)
+	expiredSingleIPsMutex       sync.RWMutex
+	expiredSingleIPsArgsForCall []struct {
 		arg1 int
 	}
-	oldestExpiredSingleIPReturns struct {
-		result1 *controller.Lease
+	expiredSingleIPsReturns struct {
+		result1 []controller.Lease
 		result2 error
 	}
-	oldestExpiredSingleIPReturnsOnCall map[int]struct {
-		result1 *controller.Lease
+	expiredSingleIPsReturnsOnCall map[int]struct {
+		result1 []controller.Lease
 		result2 error
 	}
 	invocations      map[string][][]interface{}
@@ -389,6 +413,55 @@ func (fake *DatabaseHandler) RenewLeaseForUnderlayIPReturnsOnCall(i int, result1
 	}{result1}
 }
 
+func (fake *DatabaseHandler) SetFailoverUnderlayIP(arg1 string, arg2 string) error {
+	fake.setFailoverUnderlayIPMutex.Lock()
+	ret, specificReturn := fake.setFailoverUnderlayIPReturnsOnCall[len(fake.setFailoverUnderlayIPArgsForCall)]
+	fake.setFailoverUnderlayIPArgsForCall = append(fake.setFailoverUnderlayIPArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("SetFailoverUnderlayIP", []interface{}{arg1, arg2})
+	fake.setFailoverUnderlayIPMutex.Unlock()
+	if fake.SetFailoverUnderlayIPStub != nil {
+		return fake.SetFailoverUnderlayIPStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.setFailoverUnderlayIPReturns.result1
+}
+
+func (fake *DatabaseHandler) SetFailoverUnderlayIPCallCount() int {
+	fake.setFailoverUnderlayIPMutex.RLock()
+	defer fake.setFailoverUnderlayIPMutex.RUnlock()
+	return len(fake.setFailoverUnderlayIPArgsForCall)
+}
+
+func (fake *DatabaseHandler) SetFailoverUnderlayIPArgsForCall(i int) (string, string) {
+	fake.setFailoverUnderlayIPMutex.RLock()
+	defer fake.setFailoverUnderlayIPMutex.RUnlock()
+	return fake.setFailoverUnderlayIPArgsForCall[i].arg1, fake.setFailoverUnderlayIPArgsForCall[i].arg2
+}
+
+func (fake *DatabaseHandler) SetFailoverUnderlayIPReturns(result1 error) {
+	fake.SetFailoverUnderlayIPStub = nil
+	fake.setFailoverUnderlayIPReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DatabaseHandler) SetFailoverUnderlayIPReturnsOnCall(i int, result1 error) {
+	fake.SetFailoverUnderlayIPStub = nil
+	if fake.setFailoverUnderlayIPReturnsOnCall == nil {
+		fake.setFailoverUnderlayIPReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setFailoverUnderlayIPReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *DatabaseHandler) All() ([]controller.Lease, error) {
 	fake.allMutex.Lock()
 	ret, specificReturn := fake.allReturnsOnCall[len(fake.allArgsForCall)]
@@ -432,6 +505,62 @@ func (fake *DatabaseHandler) AllReturnsOnCall(i int, result1 []controller.Lease,
 	}{result1, result2}
 }
 
+func (fake *DatabaseHandler) AllWithLastRenewedAt() ([]controller.TopologyCell, error) {
+	fake.allWithLastRenewedAtMutex.Lock()
+	ret, specificReturn := fake.allWithLastRenewedAtReturnsOnCall[len(fake.allWithLastRenewedAtArgsForCall)]
+	fake.allWithLastRenewedAtArgsForCall = append(fake.allWithLastRenewedAtArgsForCall, struct {
+	}{})
+	stub := fake.AllWithLastRenewedAtStub
+	fakeReturns := fake.allWithLastRenewedAtReturns
+	fake.recordInvocation("AllWithLastRenewedAt", []interface{}{})
+	fake.allWithLastRenewedAtMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *DatabaseHandler) AllWithLastRenewedAtCallCount() int {
+	fake.allWithLastRenewedAtMutex.RLock()
+	defer fake.allWithLastRenewedAtMutex.RUnlock()
+	return len(fake.allWithLastRenewedAtArgsForCall)
+}
+
+func (fake *DatabaseHandler) AllWithLastRenewedAtCalls(stub func() ([]controller.TopologyCell, error)) {
+	fake.allWithLastRenewedAtMutex.Lock()
+	defer fake.allWithLastRenewedAtMutex.Unlock()
+	fake.AllWithLastRenewedAtStub = stub
+}
+
+func (fake *DatabaseHandler) AllWithLastRenewedAtReturns(result1 []controller.TopologyCell, result2 error) {
+	fake.allWithLastRenewedAtMutex.Lock()
+	defer fake.allWithLastRenewedAtMutex.Unlock()
+	fake.AllWithLastRenewedAtStub = nil
+	fake.allWithLastRenewedAtReturns = struct {
+		result1 []controller.TopologyCell
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DatabaseHandler) AllWithLastRenewedAtReturnsOnCall(i int, result1 []controller.TopologyCell, result2 error) {
+	fake.allWithLastRenewedAtMutex.Lock()
+	defer fake.allWithLastRenewedAtMutex.Unlock()
+	fake.AllWithLastRenewedAtStub = nil
+	if fake.allWithLastRenewedAtReturnsOnCall == nil {
+		fake.allWithLastRenewedAtReturnsOnCall = make(map[int]struct {
+			result1 []controller.TopologyCell
+			result2 error
+		})
+	}
+	fake.allWithLastRenewedAtReturnsOnCall[i] = struct {
+		result1 []controller.TopologyCell
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *DatabaseHandler) AllBlockSubnets() ([]controller.Lease, error) {
 	fake.allBlockSubnetsMutex.Lock()
 	ret, specificReturn := fake.allBlockSubnetsReturnsOnCall[len(fake.allBlockSubnetsArgsForCall)]
@@ -569,104 +698,104 @@ func (fake *DatabaseHandler) AllActiveReturnsOnCall(i int, result1 []controller.
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnet(arg1 int) (*controller.Lease, error) {
-	fake.oldestExpiredBlockSubnetMutex.Lock()
-	ret, specificReturn := fake.oldestExpiredBlockSubnetReturnsOnCall[len(fake.oldestExpiredBlockSubnetArgsForCall)]
-	fake.oldestExpiredBlockSubnetArgsForCall = append(fake.oldestExpiredBlockSubnetArgsForCall, struct {
+func (fake *DatabaseHandler) ExpiredBlockSubnets(arg1 int) ([]controller.Lease, error) {
+	fake.expiredBlockSubnetsMutex.Lock()
+	ret, specificReturn := fake.expiredBlockSubnetsReturnsOnCall[len(fake.expiredBlockSubnetsArgsForCall)]
+	fake.expiredBlockSubnetsArgsForCall = append(fake.expiredBlockSubnetsArgsForCall, struct {
 		arg1 int
 	}{arg1})
-	fake.recordInvocation("OldestExpiredBlockSubnet", []interface{}{arg1})
-	fake.oldestExpiredBlockSubnetMutex.Unlock()
-	if fake.OldestExpiredBlockSubnetStub != nil {
-		return fake.OldestExpiredBlockSubnetStub(arg1)
+	fake.recordInvocation("ExpiredBlockSubnets", []interface{}{arg1})
+	fake.expiredBlockSubnetsMutex.Unlock()
+	if fake.ExpiredBlockSubnetsStub != nil {
+		return fake.ExpiredBlockSubnetsStub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.oldestExpiredBlockSubnetReturns.result1, fake.oldestExpiredBlockSubnetReturns.result2
+	return fake.expiredBlockSubnetsReturns.result1, fake.expiredBlockSubnetsReturns.result2
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetCallCount() int {
-	fake.oldestExpiredBlockSubnetMutex.RLock()
-	defer fake.oldestExpiredBlockSubnetMutex.RUnlock()
-	return len(fake.oldestExpiredBlockSubnetArgsForCall)
+func (fake *DatabaseHandler) ExpiredBlockSubnetsCallCount() int {
+	fake.expiredBlockSubnetsMutex.RLock()
+	defer fake.expiredBlockSubnetsMutex.RUnlock()
+	return len(fake.expiredBlockSubnetsArgsForCall)
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetArgsForCall(i int) int {
-	fake.oldestExpiredBlockSubnetMutex.RLock()
-	defer fake.oldestExpiredBlockSubnetMutex.RUnlock()
-	return fake.oldestExpiredBlockSubnetArgsForCall[i].arg1
+func (fake *DatabaseHandler) ExpiredBlockSubnetsArgsForCall(i int) int {
+	fake.expiredBlockSubnetsMutex.RLock()
+	defer fake.expiredBlockSubnetsMutex.RUnlock()
+	return fake.expiredBlockSubnetsArgsForCall[i].arg1
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetReturns(result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredBlockSubnetStub = nil
-	fake.oldestExpiredBlockSubnetReturns = struct {
-		result1 *controller.Lease
+func (fake *DatabaseHandler) ExpiredBlockSubnetsReturns(result1 []controller.Lease, result2 error) {
+	fake.ExpiredBlockSubnetsStub = nil
+	fake.expiredBlockSubnetsReturns = struct {
+		result1 []controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredBlockSubnetReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredBlockSubnetStub = nil
-	if fake.oldestExpiredBlockSubnetReturnsOnCall == nil {
-		fake.oldestExpiredBlockSubnetReturnsOnCall = make(map[int]struct {
-			result1 *controller.Lease
+func (fake *DatabaseHandler) ExpiredBlockSubnetsReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
+	fake.ExpiredBlockSubnetsStub = nil
+	if fake.expiredBlockSubnetsReturnsOnCall == nil {
+		fake.expiredBlockSubnetsReturnsOnCall = make(map[int]struct {
+			result1 []controller.Lease
 			result2 error
 		})
 	}
-	fake.oldestExpiredBlockSubnetReturnsOnCall[i] = struct {
-		result1 *controller.Lease
+	fake.expiredBlockSubnetsReturnsOnCall[i] = struct {
+		result1 []controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIP(arg1 int) (*controller.Lease, error) {
-	fake.oldestExpiredSingleIPMutex.Lock()
-	ret, specificReturn := fake.oldestExpiredSingleIPReturnsOnCall[len(fake.oldestExpiredSingleIPArgsForCall)]
-	fake.oldestExpiredSingleIPArgsForCall = append(fake.oldestExpiredSingleIPArgsForCall, struct {
+func (fake *DatabaseHandler) ExpiredSingleIPs(arg1 int) ([]controller.Lease, error) {
+	fake.expiredSingleIPsMutex.Lock()
+	ret, specificReturn := fake.expiredSingleIPsReturnsOnCall[len(fake.expiredSingleIPsArgsForCall)]
+	fake.expiredSingleIPsArgsForCall = append(fake.expiredSingleIPsArgsForCall, struct {
 		arg1 int
 	}{arg1})
-	fake.recordInvocation("OldestExpiredSingleIP", []interface{}{arg1})
-	fake.oldestExpiredSingleIPMutex.Unlock()
-	if fake.OldestExpiredSingleIPStub != nil {
-		return fake.OldestExpiredSingleIPStub(arg1)
+	fake.recordInvocation("ExpiredSingleIPs", []interface{}{arg1})
+	fake.expiredSingleIPsMutex.Unlock()
+	if fake.ExpiredSingleIPsStub != nil {
+		return fake.ExpiredSingleIPsStub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	return fake.oldestExpiredSingleIPReturns.result1, fake.oldestExpiredSingleIPReturns.result2
+	return fake.expiredSingleIPsReturns.result1, fake.expiredSingleIPsReturns.result2
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPCallCount() int {
-	fake.oldestExpiredSingleIPMutex.RLock()
-	defer fake.oldestExpiredSingleIPMutex.RUnlock()
-	return len(fake.oldestExpiredSingleIPArgsForCall)
+func (fake *DatabaseHandler) ExpiredSingleIPsCallCount() int {
+	fake.expiredSingleIPsMutex.RLock()
+	defer fake.expiredSingleIPsMutex.RUnlock()
+	return len(fake.expiredSingleIPsArgsForCall)
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPArgsForCall(i int) int {
-	fake.oldestExpiredSingleIPMutex.RLock()
-	defer fake.oldestExpiredSingleIPMutex.RUnlock()
-	return fake.oldestExpiredSingleIPArgsForCall[i].arg1
+func (fake *DatabaseHandler) ExpiredSingleIPsArgsForCall(i int) int {
+	fake.expiredSingleIPsMutex.RLock()
+	defer fake.expiredSingleIPsMutex.RUnlock()
+	return fake.expiredSingleIPsArgsForCall[i].arg1
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPReturns(result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredSingleIPStub = nil
-	fake.oldestExpiredSingleIPReturns = struct {
-		result1 *controller.Lease
+func (fake *DatabaseHandler) ExpiredSingleIPsReturns(result1 []controller.Lease, result2 error) {
+	fake.ExpiredSingleIPsStub = nil
+	fake.expiredSingleIPsReturns = struct {
+		result1 []controller.Lease
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *DatabaseHandler) OldestExpiredSingleIPReturnsOnCall(i int, result1 *controller.Lease, result2 error) {
-	fake.OldestExpiredSingleIPStub = nil
-	if fake.oldestExpiredSingleIPReturnsOnCall == nil {
-		fake.oldestExpiredSingleIPReturnsOnCall = make(map[int]struct {
-			result1 *controller.Lease
+func (fake *DatabaseHandler) ExpiredSingleIPsReturnsOnCall(i int, result1 []controller.Lease, result2 error) {
+	fake.ExpiredSingleIPsStub = nil
+	if fake.expiredSingleIPsReturnsOnCall == nil {
+		fake.expiredSingleIPsReturnsOnCall = make(map[int]struct {
+			result1 []controller.Lease
 			result2 error
 		})
 	}
-	fake.oldestExpiredSingleIPReturnsOnCall[i] = struct {
-		result1 *controller.Lease
+	fake.expiredSingleIPsReturnsOnCall[i] = struct {
+		result1 []controller.Lease
 		result2 error
 	}{result1, result2}
 }
@@ -684,18 +813,22 @@ func (fake *DatabaseHandler) Invocations() map[string][][]interface{} {
 	defer fake.lastRenewedAtForUnderlayIPMutex.RUnlock()
 	fake.renewLeaseForUnderlayIPMutex.RLock()
 	defer fake.renewLeaseForUnderlayIPMutex.RUnlock()
+	fake.setFailoverUnderlayIPMutex.RLock()
+	defer fake.setFailoverUnderlayIPMutex.RUnlock()
 	fake.allMutex.RLock()
 	defer fake.allMutex.RUnlock()
+	fake.allWithLastRenewedAtMutex.RLock()
+	defer fake.allWithLastRenewedAtMutex.RUnlock()
 	fake.allBlockSubnetsMutex.RLock()
 	defer fake.allBlockSubnetsMutex.RUnlock()
 	fake.allSingleIPSubnetsMutex.RLock()
 	defer fake.allSingleIPSubnetsMutex.RUnlock()
 	fake.allActiveMutex.RLock()
 	defer fake.allActiveMutex.RUnlock()
-	fake.oldestExpiredBlockSubnetMutex.RLock()
-	defer fake.oldestExpiredBlockSubnetMutex.RUnlock()
-	fake.oldestExpiredSingleIPMutex.RLock()
-	defer fake.oldestExpiredSingleIPMutex.RUnlock()
+	fake.expiredBlockSubnetsMutex.RLock()
+	defer fake.expiredBlockSubnetsMutex.RUnlock()
+	fake.expiredSingleIPsMutex.RLock()
+	defer fake.expiredSingleIPsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,60 @@
+package leaser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// IPv6Prefixes maps every IPv4 lease to its own prefix of the IPv6 overlay
+// network. The prefix is numbered by the offset of the lease in the IPv4
+// overlay network, so it follows the IPv4 lease without being stored.
+type IPv6Prefixes struct {
+	network      *net.IPNet
+	ipv6Network  *net.IPNet
+	prefixLength int
+}
+
+func NewIPv6Prefixes(network, ipv6Network string, prefixLength int) (*IPv6Prefixes, error) {
+	_, ipv4Net, err := net.ParseCIDR(network)
+	if err != nil || ipv4Net.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid network: %s", network)
+	}
+	_, ipv6Net, err := net.ParseCIDR(ipv6Network)
+	if err != nil || ipv6Net.IP.To4() != nil {
+		return nil, fmt.Errorf("invalid ipv6 network: %s", ipv6Network)
+	}
+
+	ipv4Ones, _ := ipv4Net.Mask.Size()
+	ipv6Ones, _ := ipv6Net.Mask.Size()
+	if prefixLength > 128 || prefixLength-ipv6Ones < 32-ipv4Ones {
+		return nil, fmt.Errorf("ipv6 network %s has too few /%d prefixes for network %s", ipv6Network, prefixLength, network)
+	}
+
+	return &IPv6Prefixes{
+		network:      ipv4Net,
+		ipv6Network:  ipv6Net,
+		prefixLength: prefixLength,
+	}, nil
+}
+
+func (p *IPv6Prefixes) PrefixFor(overlaySubnet string) (string, error) {
+	ip, _, err := net.ParseCIDR(overlaySubnet)
+	if err != nil {
+		return "", fmt.Errorf("parse overlay subnet: %s", err)
+	}
+	if ip.To4() == nil || !p.network.Contains(ip) {
+		return "", fmt.Errorf("overlay subnet %s is not in network %s", overlaySubnet, p.network)
+	}
+
+	offset := binary.BigEndian.Uint32(ip.To4()) - binary.BigEndian.Uint32(p.network.IP.To4())
+	prefix := new(big.Int).SetBytes(p.ipv6Network.IP.To16())
+	prefix.Or(prefix, new(big.Int).Lsh(big.NewInt(int64(offset)), uint(128-p.prefixLength)))
+
+	ipv6Subnet := net.IPNet{
+		IP:   prefix.FillBytes(make([]byte, net.IPv6len)),
+		Mask: net.CIDRMask(p.prefixLength, 128),
+	}
+	return ipv6Subnet.String(), nil
+}
@@ -0,0 +1,50 @@
+package leaser_test
+
+import (
+	"code.cloudfoundry.org/silk/controller/leaser"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IPv6Prefixes", func() {
+	var prefixes *leaser.IPv6Prefixes
+
+	BeforeEach(func() {
+		var err error
+		prefixes, err = leaser.NewIPv6Prefixes("10.255.0.0/16", "fd00:abcd::/48", 64)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	DescribeTable("maps each lease to its own prefix",
+		func(overlaySubnet, expectedPrefix string) {
+			prefix, err := prefixes.PrefixFor(overlaySubnet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prefix).To(Equal(expectedPrefix))
+		},
+		Entry("a block lease", "10.255.1.0/24", "fd00:abcd:0:100::/64"),
+		Entry("another block lease", "10.255.255.0/24", "fd00:abcd:0:ff00::/64"),
+		Entry("a single ip lease", "10.255.0.1/32", "fd00:abcd:0:1::/64"),
+	)
+
+	It("returns an error for subnets outside the network", func() {
+		_, err := prefixes.PrefixFor("10.254.1.0/24")
+		Expect(err).To(MatchError("overlay subnet 10.254.1.0/24 is not in network 10.255.0.0/16"))
+	})
+
+	It("returns an error for invalid subnets", func() {
+		_, err := prefixes.PrefixFor("banana")
+		Expect(err).To(MatchError(ContainSubstring("parse overlay subnet")))
+	})
+
+	DescribeTable("validates the networks",
+		func(network, ipv6Network string, prefixLength int, expectedErr string) {
+			_, err := leaser.NewIPv6Prefixes(network, ipv6Network, prefixLength)
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("invalid network", "banana", "fd00::/48", 64, "invalid network: banana"),
+		Entry("ipv6 network", "fd00::/48", "fd00::/48", 64, "invalid network: fd00::/48"),
+		Entry("ipv4 ipv6 network", "10.255.0.0/16", "10.0.0.0/8", 64, "invalid ipv6 network: 10.0.0.0/8"),
+		Entry("too few prefixes", "10.255.0.0/16", "fd00::/56", 64, "ipv6 network fd00::/56 has too few /64 prefixes for network 10.255.0.0/16"),
+		Entry("prefix longer than an address", "10.255.0.0/16", "fd00::/112", 129, "ipv6 network fd00::/112 has too few /129 prefixes for network 10.255.0.0/16"),
+	)
+})
@@ -0,0 +1,119 @@
+package leaser
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+type subnetRange struct {
+	lease controller.Lease
+	first net.IP
+	last  net.IP
+}
+
+// FindLeaseConflicts groups the leases whose overlay subnets overlap. Leases
+// with an overlay subnet that cannot be parsed are ignored.
+func FindLeaseConflicts(leases []controller.Lease) []controller.LeaseConflict {
+	ranges := []subnetRange{}
+	for _, lease := range leases {
+		r, ok := leaseRange(lease)
+		if ok {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].first, ranges[j].first) < 0
+	})
+
+	conflicts := []controller.LeaseConflict{}
+	var group []controller.Lease
+	var groupLast net.IP
+	for _, r := range ranges {
+		if group != nil && bytes.Compare(r.first, groupLast) <= 0 {
+			group = append(group, r.lease)
+			if bytes.Compare(r.last, groupLast) > 0 {
+				groupLast = r.last
+			}
+			continue
+		}
+		if len(group) > 1 {
+			conflicts = append(conflicts, controller.LeaseConflict{Leases: group})
+		}
+		group = []controller.Lease{r.lease}
+		groupLast = r.last
+	}
+	if len(group) > 1 {
+		conflicts = append(conflicts, controller.LeaseConflict{Leases: group})
+	}
+
+	return conflicts
+}
+
+// conflictingLeases returns the leases other than lease whose overlay subnets
+// overlap with the one of lease.
+func conflictingLeases(lease controller.Lease, leases []controller.Lease) []controller.Lease {
+	r, ok := leaseRange(lease)
+	if !ok {
+		return nil
+	}
+
+	var conflicting []controller.Lease
+	for _, other := range leases {
+		if other.UnderlayIP == lease.UnderlayIP {
+			continue
+		}
+		o, ok := leaseRange(other)
+		if !ok {
+			continue
+		}
+		if bytes.Compare(o.first, r.last) <= 0 && bytes.Compare(r.first, o.last) <= 0 {
+			conflicting = append(conflicting, other)
+		}
+	}
+	return conflicting
+}
+
+func leaseRange(lease controller.Lease) (subnetRange, bool) {
+	_, subnet, err := net.ParseCIDR(lease.OverlaySubnet)
+	if err != nil || subnet.IP.To4() == nil {
+		return subnetRange{}, false
+	}
+
+	first := subnet.IP.To4()
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^subnet.Mask[i]
+	}
+	return subnetRange{lease: lease, first: first, last: last}, true
+}
+
+// quarantinedLeases returns the underlay ips of the leases in conflicts.
+// Cells do not get routes to them, since the traffic for their containers
+// would reach the wrong cell.
+func quarantinedLeases(leases []controller.Lease) map[string]bool {
+	quarantined := map[string]bool{}
+	for _, conflict := range FindLeaseConflicts(leases) {
+		for _, lease := range conflict.Leases {
+			quarantined[lease.UnderlayIP] = true
+		}
+	}
+	return quarantined
+}
+
+// CountConflictingLeases returns the number of leases whose overlay subnets
+// overlap with the one of another lease.
+func CountConflictingLeases(leases []controller.Lease) int {
+	return len(quarantinedLeases(leases))
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,51 @@
+package leaser_test
+
+import (
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/leaser"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FindLeaseConflicts", func() {
+	It("groups leases with duplicate or nested overlay subnets", func() {
+		conflicts := leaser.FindLeaseConflicts([]controller.Lease{
+			{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+			{UnderlayIP: "10.0.0.2", OverlaySubnet: "10.255.2.0/24"},
+			{UnderlayIP: "10.0.0.3", OverlaySubnet: "10.255.1.7/32"},
+			{UnderlayIP: "10.0.0.4", OverlaySubnet: "10.255.3.0/24"},
+			{UnderlayIP: "10.0.0.5", OverlaySubnet: "10.255.3.0/24"},
+		})
+		Expect(conflicts).To(Equal([]controller.LeaseConflict{
+			{
+				Leases: []controller.Lease{
+					{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+					{UnderlayIP: "10.0.0.3", OverlaySubnet: "10.255.1.7/32"},
+				},
+			},
+			{
+				Leases: []controller.Lease{
+					{UnderlayIP: "10.0.0.4", OverlaySubnet: "10.255.3.0/24"},
+					{UnderlayIP: "10.0.0.5", OverlaySubnet: "10.255.3.0/24"},
+				},
+			},
+		}))
+	})
+
+	It("returns no conflicts when the overlay subnets are disjoint", func() {
+		conflicts := leaser.FindLeaseConflicts([]controller.Lease{
+			{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+			{UnderlayIP: "10.0.0.2", OverlaySubnet: "10.255.2.0/24"},
+		})
+		Expect(conflicts).To(BeEmpty())
+	})
+
+	It("ignores leases with an invalid overlay subnet", func() {
+		conflicts := leaser.FindLeaseConflicts([]controller.Lease{
+			{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+			{UnderlayIP: "10.0.0.2", OverlaySubnet: "banana"},
+		})
+		Expect(conflicts).To(BeEmpty())
+	})
+})
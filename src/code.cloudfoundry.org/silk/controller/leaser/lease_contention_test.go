@@ -0,0 +1,218 @@
+package leaser_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/database"
+	"code.cloudfoundry.org/silk/controller/leaser"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// memoryDatabase keeps leases like the subnets table does, with unique
+// underlay ips, overlay subnets and hardware addresses.
+type memoryDatabase struct {
+	mutex     sync.Mutex
+	leases    map[string]controller.Lease
+	renewedAt map[string]int64
+}
+
+func newMemoryDatabase() *memoryDatabase {
+	return &memoryDatabase{
+		leases:    map[string]controller.Lease{},
+		renewedAt: map[string]int64{},
+	}
+}
+
+func (d *memoryDatabase) AddEntry(lease controller.Lease) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, existing := range d.leases {
+		if existing.UnderlayIP == lease.UnderlayIP ||
+			existing.OverlaySubnet == lease.OverlaySubnet ||
+			existing.OverlayHardwareAddr == lease.OverlayHardwareAddr {
+			return database.DuplicateEntryError
+		}
+	}
+	d.leases[lease.UnderlayIP] = lease
+	d.renewedAt[lease.UnderlayIP] = time.Now().Unix()
+	return nil
+}
+
+func (d *memoryDatabase) DeleteEntry(underlayIP string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.leases[underlayIP]; !ok {
+		return database.RecordNotAffectedError
+	}
+	delete(d.leases, underlayIP)
+	delete(d.renewedAt, underlayIP)
+	return nil
+}
+
+func (d *memoryDatabase) LeaseForUnderlayIP(underlayIP string) (*controller.Lease, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	lease, ok := d.leases[underlayIP]
+	if !ok {
+		return nil, nil
+	}
+	return &lease, nil
+}
+
+func (d *memoryDatabase) LastRenewedAtForUnderlayIP(underlayIP string) (int64, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.renewedAt[underlayIP], nil
+}
+
+func (d *memoryDatabase) RenewLeaseForUnderlayIP(underlayIP string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.renewedAt[underlayIP] = time.Now().Unix()
+	return nil
+}
+
+func (d *memoryDatabase) SetFailoverUnderlayIP(underlayIP, failoverUnderlayIP string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	lease := d.leases[underlayIP]
+	lease.FailoverUnderlayIP = failoverUnderlayIP
+	d.leases[underlayIP] = lease
+	return nil
+}
+
+func (d *memoryDatabase) All() ([]controller.Lease, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	leases := []controller.Lease{}
+	for _, lease := range d.leases {
+		leases = append(leases, lease)
+	}
+	// widen the window between reading the leases and adding one
+	runtime.Gosched()
+	return leases, nil
+}
+
+func (d *memoryDatabase) AllWithLastRenewedAt() ([]controller.TopologyCell, error) {
+	return nil, nil
+}
+
+func (d *memoryDatabase) AllBlockSubnets() ([]controller.Lease, error) { return d.All() }
+
+func (d *memoryDatabase) AllSingleIPSubnets() ([]controller.Lease, error) { return nil, nil }
+
+func (d *memoryDatabase) AllActive(int) ([]controller.Lease, error) { return d.All() }
+
+func (d *memoryDatabase) ExpiredBlockSubnets(int) ([]controller.Lease, error) { return nil, nil }
+
+func (d *memoryDatabase) ExpiredSingleIPs(int) ([]controller.Lease, error) { return nil, nil }
+
+var _ = Describe("acquiring leases from several controller instances", func() {
+	const (
+		instances = 3
+		cells     = 120
+	)
+
+	var (
+		db          *memoryDatabase
+		controllers []*leaser.LeaseController
+	)
+
+	newControllers := func(shardCount int) {
+		controllers = nil
+		for i := 0; i < instances; i++ {
+			cidrPool := leaser.NewCIDRPool("10.255.0.0/16", 24)
+			cidrPool.Shard = leaser.Shard{Index: i, Count: shardCount}
+			controllers = append(controllers, &leaser.LeaseController{
+				DatabaseHandler:            db,
+				HardwareAddressGenerator:   &leaser.HardwareAddressGenerator{},
+				AcquireSubnetLeaseAttempts: 10,
+				CIDRPool:                   cidrPool,
+				LeaseValidator:             &leaser.LeaseValidator{},
+				LeaseExpirationSeconds:     60,
+				Logger:                     lagertest.NewTestLogger(fmt.Sprintf("controller-%d", i)),
+			})
+		}
+	}
+
+	// acquire has every cell acquire its lease from every instance at once,
+	// as cells retrying through a load balancer would.
+	acquire := func() map[string][]*controller.Lease {
+		var (
+			wg       sync.WaitGroup
+			mutex    sync.Mutex
+			acquired = map[string][]*controller.Lease{}
+		)
+		for c := 0; c < cells; c++ {
+			underlayIP := fmt.Sprintf("10.244.%d.%d", c/250, c%250+1)
+			for _, leaseController := range controllers {
+				wg.Add(1)
+				go func(leaseController *leaser.LeaseController) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					lease, err := leaseController.AcquireSubnetLease(underlayIP, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease).NotTo(BeNil())
+
+					mutex.Lock()
+					acquired[underlayIP] = append(acquired[underlayIP], lease)
+					mutex.Unlock()
+				}(leaseController)
+			}
+		}
+		wg.Wait()
+		return acquired
+	}
+
+	expectOneLeasePerCell := func(acquired map[string][]*controller.Lease) {
+		Expect(acquired).To(HaveLen(cells))
+		for underlayIP, leases := range acquired {
+			stored, err := db.LeaseForUnderlayIP(underlayIP)
+			Expect(err).NotTo(HaveOccurred())
+			for _, lease := range leases {
+				Expect(lease.OverlaySubnet).To(Equal(stored.OverlaySubnet))
+			}
+		}
+
+		subnets := map[string]string{}
+		all, err := db.All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all).To(HaveLen(cells))
+		for _, lease := range all {
+			Expect(subnets).NotTo(HaveKey(lease.OverlaySubnet))
+			subnets[lease.OverlaySubnet] = lease.UnderlayIP
+		}
+	}
+
+	BeforeEach(func() {
+		db = newMemoryDatabase()
+	})
+
+	Context("when the instances share the whole pool", func() {
+		BeforeEach(func() {
+			newControllers(0)
+		})
+
+		It("gives every cell one lease with a subnet of its own", func() {
+			expectOneLeasePerCell(acquire())
+		})
+	})
+
+	Context("when the pool is sharded between the instances", func() {
+		BeforeEach(func() {
+			newControllers(instances)
+		})
+
+		It("gives every cell one lease with a subnet of its own", func() {
+			expectOneLeasePerCell(acquire())
+		})
+	})
+})
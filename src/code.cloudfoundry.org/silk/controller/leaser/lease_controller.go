@@ -1,8 +1,11 @@
 package leaser
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/silk/controller"
@@ -16,12 +19,14 @@ type databaseHandler interface {
 	LeaseForUnderlayIP(string) (*controller.Lease, error)
 	LastRenewedAtForUnderlayIP(string) (int64, error)
 	RenewLeaseForUnderlayIP(string) error
+	SetFailoverUnderlayIP(string, string) error
 	All() ([]controller.Lease, error)
+	AllWithLastRenewedAt() ([]controller.TopologyCell, error)
 	AllBlockSubnets() ([]controller.Lease, error)
 	AllSingleIPSubnets() ([]controller.Lease, error)
 	AllActive(int) ([]controller.Lease, error)
-	OldestExpiredBlockSubnet(int) (*controller.Lease, error)
-	OldestExpiredSingleIP(int) (*controller.Lease, error)
+	ExpiredBlockSubnets(int) ([]controller.Lease, error)
+	ExpiredSingleIPs(int) ([]controller.Lease, error)
 }
 
 //go:generate counterfeiter -o fakes/lease_validator.go --fake-name LeaseValidator . leaseValidator
@@ -36,6 +41,11 @@ type cidrPool interface {
 	IsMember(string) bool
 }
 
+//go:generate counterfeiter -o fakes/ipv6_prefixes.go --fake-name IPv6Prefixes . ipv6Prefixes
+type ipv6Prefixes interface {
+	PrefixFor(overlaySubnet string) (string, error)
+}
+
 //go:generate counterfeiter -o fakes/hardwareAddressGenerator.go --fake-name HardwareAddressGenerator . hardwareAddressGenerator
 type hardwareAddressGenerator interface {
 	GenerateForVTEP(containerIP net.IP) (net.HardwareAddr, error)
@@ -49,6 +59,8 @@ type LeaseController struct {
 	LeaseValidator             leaseValidator
 	LeaseExpirationSeconds     int
 	Logger                     lager.Logger
+	IPv6Prefixes               ipv6Prefixes
+	ReclamationPolicy          ReclamationPolicy
 }
 
 func (c *LeaseController) ReleaseSubnetLease(underlayIP string) error {
@@ -80,8 +92,13 @@ func (c *LeaseController) AcquireSubnetLease(underlayIP string, singleOverlayIP
 
 	if lease != nil {
 		if c.CIDRPool.IsMember(lease.OverlaySubnet) {
-			c.Logger.Info("lease-renewed", lager.Data{"lease": lease})
-			return lease, nil
+			err = c.checkConflicts(*lease)
+			if err != nil {
+				return nil, err
+			}
+			renewed := c.withIPv6Prefix(*lease)
+			c.Logger.Info("lease-renewed", lager.Data{"lease": renewed})
+			return &renewed, nil
 		}
 		err := c.DatabaseHandler.DeleteEntry(underlayIP)
 		if err != nil {
@@ -90,12 +107,31 @@ func (c *LeaseController) AcquireSubnetLease(underlayIP string, singleOverlayIP
 		c.Logger.Info("lease-deleted", lager.Data{"lease": lease})
 	}
 
+	// other controller instances acquire leases at the same time, so a subnet
+	// that was free when it was picked may be taken when it is added, in
+	// which case another one is picked
 	for numErrs := 0; numErrs < c.AcquireSubnetLeaseAttempts; numErrs++ {
 		lease, err = c.tryAcquireLease(underlayIP, singleOverlayIP)
 		if lease != nil {
 			c.Logger.Info("lease-acquired", lager.Data{"lease": lease})
 			return lease, nil
 		}
+		if err != database.DuplicateEntryError {
+			continue
+		}
+
+		c.Logger.Debug("lease-acquire-contended", lager.Data{"underlay_ip": underlayIP, "attempt": numErrs + 1})
+		// the cell may have acquired its lease through another instance
+		lease, err = c.DatabaseHandler.LeaseForUnderlayIP(underlayIP)
+		if err != nil {
+			return nil, fmt.Errorf("getting lease for underlay ip: %s", err)
+		}
+		if lease != nil {
+			acquired := c.withIPv6Prefix(*lease)
+			c.Logger.Info("lease-acquired", lager.Data{"lease": acquired})
+			return &acquired, nil
+		}
+		err = fmt.Errorf("adding lease entry: %s", database.DuplicateEntryError)
 	}
 
 	return nil, err
@@ -107,17 +143,38 @@ func (c *LeaseController) RenewSubnetLease(lease controller.Lease) error {
 		return controller.NonRetriableError(err.Error())
 	}
 
+	// the ipv6 prefix follows from the overlay subnet and is not stored
+	lease.OverlayIPv6Subnet = ""
+
 	existingLease, err := c.DatabaseHandler.LeaseForUnderlayIP(lease.UnderlayIP)
 	if err != nil {
 		return fmt.Errorf("getting lease for underlay ip: %s", err)
 	}
+	// the failover underlay ip follows the underlay that the cell is on
+	if existingLease != nil && !sameLease(lease, *existingLease) {
+		return controller.NonRetriableError("lease mismatch")
+	}
+
+	// stored leases are checked as well, since a database restore can bring
+	// back the lease of another cell with an overlapping subnet
+	err = c.checkConflicts(lease)
+	if err != nil {
+		return controller.NonRetriableError(err.Error())
+	}
+
 	if existingLease == nil {
 		err := c.DatabaseHandler.AddEntry(lease)
 		if err != nil {
 			return controller.NonRetriableError(err.Error())
 		}
-	} else if lease != *existingLease {
-		return controller.NonRetriableError("lease mismatch")
+	}
+
+	if existingLease != nil && existingLease.FailoverUnderlayIP != lease.FailoverUnderlayIP {
+		err = c.DatabaseHandler.SetFailoverUnderlayIP(lease.UnderlayIP, lease.FailoverUnderlayIP)
+		if err != nil {
+			return fmt.Errorf("setting failover underlay ip: %s", err)
+		}
+		c.Logger.Info("lease-failover-changed", lager.Data{"lease": lease, "previous_failover_underlay_ip": existingLease.FailoverUnderlayIP})
 	}
 
 	err = c.DatabaseHandler.RenewLeaseForUnderlayIP(lease.UnderlayIP)
@@ -140,7 +197,130 @@ func (c *LeaseController) RoutableLeases() ([]controller.Lease, error) {
 		return nil, fmt.Errorf("getting all leases: %s", err)
 	}
 
-	return leases, nil
+	routable := []controller.Lease{}
+	quarantined := quarantinedLeases(leases)
+	for _, lease := range leases {
+		if quarantined[lease.UnderlayIP] {
+			continue
+		}
+		routable = append(routable, c.withIPv6Prefix(lease))
+	}
+	if len(quarantined) > 0 {
+		c.Logger.Error("lease-conflicts", errors.New("overlay subnets of leases overlap"), lager.Data{"quarantined_underlay_ips": sortedKeys(quarantined)})
+	}
+	return routable, nil
+}
+
+// Topology returns the lease of every cell, with whether the other cells route
+// to it.
+func (c *LeaseController) Topology() (controller.Topology, error) {
+	cells, err := c.DatabaseHandler.AllWithLastRenewedAt()
+	if err != nil {
+		return controller.Topology{}, fmt.Errorf("getting all leases: %s", err)
+	}
+	active, err := c.DatabaseHandler.AllActive(c.LeaseExpirationSeconds)
+	if err != nil {
+		return controller.Topology{}, fmt.Errorf("getting active leases: %s", err)
+	}
+
+	activeUnderlayIPs := map[string]bool{}
+	for _, lease := range active {
+		activeUnderlayIPs[lease.UnderlayIP] = true
+	}
+	quarantined := quarantinedLeases(active)
+	for i := range cells {
+		cells[i].Lease = c.withIPv6Prefix(cells[i].Lease)
+		cells[i].Routable = activeUnderlayIPs[cells[i].UnderlayIP] && !quarantined[cells[i].UnderlayIP]
+	}
+
+	return controller.Topology{
+		Version:                controller.TopologyVersion(cells),
+		GeneratedAt:            time.Now().Unix(),
+		LeaseExpirationSeconds: c.LeaseExpirationSeconds,
+		Cells:                  cells,
+	}, nil
+}
+
+// LeaseConflicts returns the groups of leases whose overlay subnets overlap.
+func (c *LeaseController) LeaseConflicts() ([]controller.LeaseConflict, error) {
+	leases, err := c.DatabaseHandler.All()
+	if err != nil {
+		return nil, fmt.Errorf("getting all leases: %s", err)
+	}
+	return FindLeaseConflicts(leases), nil
+}
+
+// ResolveLeaseConflict deletes the lease of a cell whose overlay subnet
+// overlaps with the one of another lease, so that the cell acquires a new
+// lease the next time it starts.
+func (c *LeaseController) ResolveLeaseConflict(underlayIP string) error {
+	leases, err := c.DatabaseHandler.All()
+	if err != nil {
+		return fmt.Errorf("getting all leases: %s", err)
+	}
+
+	var lease *controller.Lease
+	for i := range leases {
+		if leases[i].UnderlayIP == underlayIP {
+			lease = &leases[i]
+			break
+		}
+	}
+	if lease == nil {
+		return fmt.Errorf("no lease for underlay ip %s", underlayIP)
+	}
+	if len(conflictingLeases(*lease, leases)) == 0 {
+		return fmt.Errorf("lease for underlay ip %s does not conflict with other leases", underlayIP)
+	}
+
+	err = c.DatabaseHandler.DeleteEntry(underlayIP)
+	if err != nil {
+		return fmt.Errorf("deleting lease for underlay ip %s: %s", underlayIP, err)
+	}
+
+	c.Logger.Info("lease-conflict-resolved", lager.Data{"lease": lease})
+	return nil
+}
+
+func sameLease(lease, other controller.Lease) bool {
+	lease.FailoverUnderlayIP = ""
+	other.FailoverUnderlayIP = ""
+	return lease == other
+}
+
+// checkConflicts refuses a lease whose overlay subnet overlaps with the one of
+// a lease of another cell.
+func (c *LeaseController) checkConflicts(lease controller.Lease) error {
+	leases, err := c.DatabaseHandler.All()
+	if err != nil {
+		return fmt.Errorf("getting all leases: %s", err)
+	}
+
+	conflicting := conflictingLeases(lease, leases)
+	if len(conflicting) == 0 {
+		return nil
+	}
+
+	underlayIPs := []string{}
+	for _, other := range conflicting {
+		underlayIPs = append(underlayIPs, other.UnderlayIP)
+	}
+	c.Logger.Error("lease-conflict", errors.New("overlay subnet overlaps with other leases"), lager.Data{"lease": lease, "conflicting_leases": conflicting})
+	return fmt.Errorf("overlay subnet %s of underlay ip %s overlaps with leases of %s", lease.OverlaySubnet, lease.UnderlayIP, strings.Join(underlayIPs, ", "))
+}
+
+func (c *LeaseController) withIPv6Prefix(lease controller.Lease) controller.Lease {
+	if c.IPv6Prefixes == nil {
+		return lease
+	}
+
+	prefix, err := c.IPv6Prefixes.PrefixFor(lease.OverlaySubnet)
+	if err != nil {
+		c.Logger.Error("ipv6-prefix", err, lager.Data{"lease": lease})
+		return lease
+	}
+	lease.OverlayIPv6Subnet = prefix
+	return lease
 }
 
 func (c *LeaseController) tryAcquireLease(underlayIP string, singleOverlayIP bool) (*controller.Lease, error) {
@@ -177,10 +357,20 @@ func (c *LeaseController) tryAcquireLease(underlayIP string, singleOverlayIP boo
 		OverlayHardwareAddr: hwAddr.String(),
 	}
 
+	err = c.checkConflicts(lease)
+	if err != nil {
+		return nil, err
+	}
+
 	err = c.DatabaseHandler.AddEntry(lease)
+	if err == database.DuplicateEntryError {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("adding lease entry: %s", err)
 	}
+
+	lease = c.withIPv6Prefix(lease)
 	return &lease, nil
 }
 
@@ -197,18 +387,17 @@ func (c *LeaseController) tryAcquireAvailableSingleIPSubnet(underlayIP string) (
 
 	subnet = c.CIDRPool.GetAvailableSingleIP(taken)
 	if subnet == "" {
-		lease, err := c.DatabaseHandler.OldestExpiredSingleIP(c.LeaseExpirationSeconds)
+		expired, err := c.DatabaseHandler.ExpiredSingleIPs(c.reclaimAfterSeconds())
 		if err != nil {
-			return "", fmt.Errorf("get oldest expired single ip: %s", err)
+			return "", fmt.Errorf("get expired single ips: %s", err)
+		}
+		lease, err := c.reclaimExpiredLease(expired)
+		if err != nil {
+			return "", err
 		} else if lease == nil {
 			return "", nil
-		} else {
-			err := c.DatabaseHandler.DeleteEntry(lease.UnderlayIP)
-			if err != nil {
-				return "", fmt.Errorf("delete expired subnet: %s", err)
-			}
-			subnet = lease.OverlaySubnet
 		}
+		subnet = lease.OverlaySubnet
 	}
 
 	return subnet, nil
@@ -227,18 +416,17 @@ func (c *LeaseController) tryAcquireAvailableBlockSubnet(underlayIP string) (str
 
 	subnet = c.CIDRPool.GetAvailableBlock(taken)
 	if subnet == "" {
-		lease, err := c.DatabaseHandler.OldestExpiredBlockSubnet(c.LeaseExpirationSeconds)
+		expired, err := c.DatabaseHandler.ExpiredBlockSubnets(c.reclaimAfterSeconds())
+		if err != nil {
+			return "", fmt.Errorf("get expired subnets: %s", err)
+		}
+		lease, err := c.reclaimExpiredLease(expired)
 		if err != nil {
-			return "", fmt.Errorf("get oldest expired: %s", err)
+			return "", err
 		} else if lease == nil {
 			return "", nil
-		} else {
-			err := c.DatabaseHandler.DeleteEntry(lease.UnderlayIP)
-			if err != nil {
-				return "", fmt.Errorf("delete expired subnet: %s", err) // test
-			}
-			subnet = lease.OverlaySubnet
 		}
+		subnet = lease.OverlaySubnet
 	}
 
 	return subnet, nil
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagertest"
@@ -16,6 +17,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("LeaseController", func() {
@@ -94,8 +96,8 @@ var _ = Describe("LeaseController", func() {
 						Expect(databaseHandler.AllSingleIPSubnetsCallCount()).To(Equal(10))
 						Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
 
-						Expect(databaseHandler.OldestExpiredSingleIPCallCount()).To(Equal(10))
-						Expect(databaseHandler.OldestExpiredSingleIPArgsForCall(0)).To(Equal(42))
+						Expect(databaseHandler.ExpiredSingleIPsCallCount()).To(Equal(10))
+						Expect(databaseHandler.ExpiredSingleIPsArgsForCall(0)).To(Equal(42))
 					})
 				})
 
@@ -108,7 +110,7 @@ var _ = Describe("LeaseController", func() {
 							OverlaySubnet:       "10.255.0.6/32",
 							OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
 						}
-						databaseHandler.OldestExpiredSingleIPReturns(expiredLease, nil)
+						databaseHandler.ExpiredSingleIPsReturns([]controller.Lease{*expiredLease}, nil)
 					})
 
 					It("deletes the expired lease and assigns that lease's subnet", func() {
@@ -125,18 +127,18 @@ var _ = Describe("LeaseController", func() {
 						Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
 						Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal(expiredLease.UnderlayIP))
 
-						Expect(databaseHandler.OldestExpiredSingleIPCallCount()).To(Equal(1))
-						Expect(databaseHandler.OldestExpiredSingleIPArgsForCall(0)).To(Equal(42))
+						Expect(databaseHandler.ExpiredSingleIPsCallCount()).To(Equal(1))
+						Expect(databaseHandler.ExpiredSingleIPsArgsForCall(0)).To(Equal(42))
 					})
 
-					Context("when getting the oldest expired lease returns an error", func() {
+					Context("when getting the expired leases returns an error", func() {
 						BeforeEach(func() {
-							databaseHandler.OldestExpiredSingleIPReturns(nil, errors.New("guava"))
+							databaseHandler.ExpiredSingleIPsReturns(nil, errors.New("guava"))
 						})
 
 						It("returns an error", func() {
 							_, err := leaseController.AcquireSubnetLease("10.244.5.6", true)
-							Expect(err).To(MatchError("get oldest expired single ip: guava"))
+							Expect(err).To(MatchError("get expired single ips: guava"))
 						})
 					})
 
@@ -177,6 +179,64 @@ var _ = Describe("LeaseController", func() {
 			Expect(savedLease.OverlayHardwareAddr).To(Equal("ee:ee:0a:ff:4c:00"))
 		})
 
+		Context("when another controller instance adds the picked subnet first", func() {
+			BeforeEach(func() {
+				databaseHandler.AddEntryReturnsOnCall(0, database.DuplicateEntryError)
+				cidrPool.GetAvailableBlockReturnsOnCall(0, "10.255.76.0/24")
+				cidrPool.GetAvailableBlockReturnsOnCall(1, "10.255.77.0/24")
+			})
+
+			It("picks another subnet", func() {
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.OverlaySubnet).To(Equal("10.255.77.0/24"))
+
+				Expect(databaseHandler.AddEntryCallCount()).To(Equal(2))
+				Expect(databaseHandler.LeaseForUnderlayIPCallCount()).To(Equal(2))
+				Expect(logger).To(gbytes.Say("lease-acquire-contended.*10.244.5.6"))
+			})
+
+			Context("when the cell acquired its lease through the other instance", func() {
+				BeforeEach(func() {
+					databaseHandler.LeaseForUnderlayIPReturnsOnCall(1, &controller.Lease{
+						UnderlayIP:          "10.244.5.6",
+						OverlaySubnet:       "10.255.76.0/24",
+						OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
+					}, nil)
+				})
+
+				It("returns that lease", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
+					Expect(databaseHandler.AddEntryCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when looking up the lease of the cell fails", func() {
+				BeforeEach(func() {
+					databaseHandler.LeaseForUnderlayIPReturnsOnCall(1, nil, errors.New("guava"))
+				})
+
+				It("returns an error", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+					Expect(err).To(MatchError("getting lease for underlay ip: guava"))
+				})
+			})
+
+			Context("when every attempt is contended", func() {
+				BeforeEach(func() {
+					databaseHandler.AddEntryReturns(database.DuplicateEntryError)
+				})
+
+				It("returns an error", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+					Expect(err).To(MatchError("adding lease entry: duplicate entry"))
+					Expect(databaseHandler.AddEntryCallCount()).To(Equal(10))
+				})
+			})
+		})
+
 		Context("when getting all taken subnets returns an error", func() {
 			It("returns an error", func() {
 				databaseHandler.AllBlockSubnetsReturns(nil, errors.New("guava"))
@@ -203,8 +263,8 @@ var _ = Describe("LeaseController", func() {
 					Expect(databaseHandler.AllBlockSubnetsCallCount()).To(Equal(10))
 					Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
 
-					Expect(databaseHandler.OldestExpiredBlockSubnetCallCount()).To(Equal(10))
-					Expect(databaseHandler.OldestExpiredBlockSubnetArgsForCall(0)).To(Equal(42))
+					Expect(databaseHandler.ExpiredBlockSubnetsCallCount()).To(Equal(10))
+					Expect(databaseHandler.ExpiredBlockSubnetsArgsForCall(0)).To(Equal(42))
 				})
 			})
 
@@ -217,7 +277,7 @@ var _ = Describe("LeaseController", func() {
 						OverlaySubnet:       "10.255.76.0/24",
 						OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
 					}
-					databaseHandler.OldestExpiredBlockSubnetReturns(expiredLease, nil)
+					databaseHandler.ExpiredBlockSubnetsReturns([]controller.Lease{*expiredLease}, nil)
 				})
 
 				It("Deletes the expired lease and assigns that lease's subnet", func() {
@@ -234,17 +294,17 @@ var _ = Describe("LeaseController", func() {
 					Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
 					Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal(expiredLease.UnderlayIP))
 
-					Expect(databaseHandler.OldestExpiredBlockSubnetCallCount()).To(Equal(1))
-					Expect(databaseHandler.OldestExpiredBlockSubnetArgsForCall(0)).To(Equal(42))
+					Expect(databaseHandler.ExpiredBlockSubnetsCallCount()).To(Equal(1))
+					Expect(databaseHandler.ExpiredBlockSubnetsArgsForCall(0)).To(Equal(42))
 				})
 
-				Context("when getting the oldest expired lease returns an error", func() {
+				Context("when getting the expired leases returns an error", func() {
 					BeforeEach(func() {
-						databaseHandler.OldestExpiredBlockSubnetReturns(nil, errors.New("guava"))
+						databaseHandler.ExpiredBlockSubnetsReturns(nil, errors.New("guava"))
 					})
 					It("returns an error", func() {
 						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
-						Expect(err).To(MatchError("get oldest expired: guava"))
+						Expect(err).To(MatchError("get expired subnets: guava"))
 					})
 				})
 
@@ -257,6 +317,96 @@ var _ = Describe("LeaseController", func() {
 						Expect(err).To(MatchError("delete expired subnet: guava"))
 					})
 				})
+
+				Context("when a reclamation grace period is configured", func() {
+					BeforeEach(func() {
+						leaseController.ReclamationPolicy.GraceSeconds = 600
+					})
+					It("only considers leases expired for longer than the grace period", func() {
+						_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(databaseHandler.ExpiredBlockSubnetsArgsForCall(0)).To(Equal(642))
+					})
+				})
+
+				Context("when the cell of the longest expired lease is pinned", func() {
+					BeforeEach(func() {
+						databaseHandler.ExpiredBlockSubnetsReturns([]controller.Lease{
+							{UnderlayIP: "10.244.5.61", OverlaySubnet: "10.255.77.0/24"},
+							*expiredLease,
+						}, nil)
+						leaseController.ReclamationPolicy.PinnedUnderlayIPs = []string{"10.244.5.61"}
+					})
+					It("reclaims the next expired lease instead", func() {
+						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
+
+						Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
+						Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal("10.244.5.60"))
+					})
+
+					Context("when another controller instance reclaimed the lease first", func() {
+						BeforeEach(func() {
+							leaseController.ReclamationPolicy.PinnedUnderlayIPs = nil
+							databaseHandler.DeleteEntryReturnsOnCall(0, database.RecordNotAffectedError)
+						})
+						It("reclaims the next expired lease", func() {
+							lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
+
+							Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(2))
+							Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal("10.244.5.61"))
+							Expect(databaseHandler.DeleteEntryArgsForCall(1)).To(Equal("10.244.5.60"))
+						})
+					})
+
+					Context("when all expired leases are pinned", func() {
+						BeforeEach(func() {
+							leaseController.ReclamationPolicy.PinnedUnderlayIPs = []string{"10.244.5.60", "10.244.5.61"}
+						})
+						It("does not reclaim any lease", func() {
+							lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(lease).To(BeNil())
+							Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(0))
+						})
+					})
+				})
+
+				Context("when a prober is configured", func() {
+					var prober *fakes.CellProber
+
+					BeforeEach(func() {
+						prober = &fakes.CellProber{}
+						leaseController.ReclamationPolicy.Prober = prober
+					})
+
+					It("reclaims the lease of a cell that does not answer the probe", func() {
+						lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
+
+						Expect(prober.ReachableCallCount()).To(Equal(1))
+						Expect(prober.ReachableArgsForCall(0)).To(Equal("10.244.5.60"))
+						Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
+					})
+
+					Context("when the cell answers the probe", func() {
+						BeforeEach(func() {
+							prober.ReachableReturns(true)
+						})
+						It("does not reclaim its lease and logs it", func() {
+							lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(lease).To(BeNil())
+							Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(0))
+
+							Expect(logger.Logs()[0].Message).To(Equal("test.expired-lease-cell-reachable"))
+						})
+					})
+				})
 			})
 		})
 
@@ -328,6 +478,37 @@ var _ = Describe("LeaseController", func() {
 
 				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
 			})
+
+			Context("when the previously assigned lease overlaps with the lease of another cell", func() {
+				BeforeEach(func() {
+					databaseHandler.AllReturns([]controller.Lease{
+						*existingLease,
+						{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.76.0/24"},
+					}, nil)
+				})
+
+				It("refuses to hand it out and logs the conflict", func() {
+					_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+					Expect(err).To(MatchError("overlay subnet 10.255.76.0/24 of underlay ip 10.244.5.6 overlaps with leases of 10.244.7.8"))
+
+					Expect(logger.Logs()[0].Message).To(Equal("test.lease-conflict"))
+					Expect(logger.Logs()[0].LogLevel).To(Equal(lager.ERROR))
+				})
+			})
+		})
+
+		Context("when the new lease overlaps with the lease of another cell", func() {
+			BeforeEach(func() {
+				databaseHandler.AllReturns([]controller.Lease{
+					{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.76.5/32"},
+				}, nil)
+			})
+
+			It("does not store it", func() {
+				_, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				Expect(err).To(MatchError("overlay subnet 10.255.76.0/24 of underlay ip 10.244.5.6 overlaps with leases of 10.244.7.8"))
+				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+			})
 		})
 
 		Context("when a lease has already been assigned in a different network", func() {
@@ -378,6 +559,52 @@ var _ = Describe("LeaseController", func() {
 				Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
 			})
 		})
+
+		Context("when ipv6 prefixes are configured", func() {
+			var ipv6Prefixes *fakes.IPv6Prefixes
+			BeforeEach(func() {
+				ipv6Prefixes = &fakes.IPv6Prefixes{}
+				ipv6Prefixes.PrefixForReturns("fd00:abcd:0:4c00::/56", nil)
+				leaseController.IPv6Prefixes = ipv6Prefixes
+			})
+
+			It("adds the ipv6 prefix to the new lease without storing it", func() {
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.OverlayIPv6Subnet).To(Equal("fd00:abcd:0:4c00::/56"))
+
+				Expect(ipv6Prefixes.PrefixForArgsForCall(0)).To(Equal("10.255.76.0/24"))
+				Expect(databaseHandler.AddEntryArgsForCall(0).OverlayIPv6Subnet).To(BeEmpty())
+			})
+
+			It("adds the ipv6 prefix to a previously assigned lease", func() {
+				databaseHandler.LeaseForUnderlayIPReturns(&controller.Lease{
+					UnderlayIP:          "10.244.5.6",
+					OverlaySubnet:       "10.255.76.0/24",
+					OverlayHardwareAddr: "ee:ee:0a:ff:4c:00",
+				}, nil)
+				cidrPool.IsMemberReturns(true)
+
+				lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lease.OverlayIPv6Subnet).To(Equal("fd00:abcd:0:4c00::/56"))
+			})
+
+			Context("when the ipv6 prefix cannot be determined", func() {
+				BeforeEach(func() {
+					ipv6Prefixes.PrefixForReturns("", errors.New("kiwi"))
+				})
+
+				It("logs the error and returns the lease without an ipv6 prefix", func() {
+					lease, err := leaseController.AcquireSubnetLease("10.244.5.6", false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(lease.OverlaySubnet).To(Equal("10.255.76.0/24"))
+					Expect(lease.OverlayIPv6Subnet).To(BeEmpty())
+
+					Expect(logger.LogMessages()).To(ContainElement("test.ipv6-prefix"))
+				})
+			})
+		})
 	})
 
 	Describe("RenewSubnetLease", func() {
@@ -413,6 +640,71 @@ var _ = Describe("LeaseController", func() {
 			Expect(int64(logger.Logs()[0].Data["last_renewed_at"].(float64))).To(Equal(lastRenewedAt))
 		})
 
+		It("ignores the ipv6 prefix when comparing with the existing lease", func() {
+			leaseWithPrefix := leaseToRenew
+			leaseWithPrefix.OverlayIPv6Subnet = "fd00:abcd:0:2100::/56"
+
+			err := leaseController.RenewSubnetLease(leaseWithPrefix)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
+		})
+
+		Context("when the cell fails over to its secondary underlay", func() {
+			var failedOver controller.Lease
+
+			BeforeEach(func() {
+				failedOver = leaseToRenew
+				failedOver.FailoverUnderlayIP = "10.245.11.22"
+			})
+
+			It("records the failover underlay ip and renews the lease", func() {
+				err := leaseController.RenewSubnetLease(failedOver)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(databaseHandler.SetFailoverUnderlayIPCallCount()).To(Equal(1))
+				underlayIP, failoverUnderlayIP := databaseHandler.SetFailoverUnderlayIPArgsForCall(0)
+				Expect(underlayIP).To(Equal("10.244.11.22"))
+				Expect(failoverUnderlayIP).To(Equal("10.245.11.22"))
+				Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
+				Expect(logger).To(gbytes.Say("lease-failover-changed"))
+			})
+
+			Context("when it already failed over", func() {
+				BeforeEach(func() {
+					databaseHandler.LeaseForUnderlayIPReturns(&failedOver, nil)
+				})
+
+				It("renews the lease without recording it again", func() {
+					err := leaseController.RenewSubnetLease(failedOver)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(databaseHandler.SetFailoverUnderlayIPCallCount()).To(Equal(0))
+					Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(1))
+				})
+
+				It("clears it when the cell is back on its primary underlay", func() {
+					err := leaseController.RenewSubnetLease(leaseToRenew)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(databaseHandler.SetFailoverUnderlayIPCallCount()).To(Equal(1))
+					_, failoverUnderlayIP := databaseHandler.SetFailoverUnderlayIPArgsForCall(0)
+					Expect(failoverUnderlayIP).To(BeEmpty())
+				})
+			})
+
+			Context("when recording the failover underlay ip fails", func() {
+				BeforeEach(func() {
+					databaseHandler.SetFailoverUnderlayIPReturns(errors.New("lychee"))
+				})
+
+				It("does not renew the lease", func() {
+					err := leaseController.RenewSubnetLease(failedOver)
+					Expect(err).To(MatchError("setting failover underlay ip: lychee"))
+					Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(0))
+				})
+			})
+		})
+
 		Context("when the existing lease does not equal the one we are renewing", func() {
 			BeforeEach(func() {
 				existingLease := &controller.Lease{
@@ -430,6 +722,21 @@ var _ = Describe("LeaseController", func() {
 			})
 		})
 
+		Context("when the existing lease overlaps with the lease of another cell", func() {
+			BeforeEach(func() {
+				databaseHandler.AllReturns([]controller.Lease{
+					leaseToRenew,
+					{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.33.128/25"},
+				}, nil)
+			})
+			It("returns a non-retriable error without renewing the lease", func() {
+				err := leaseController.RenewSubnetLease(leaseToRenew)
+				Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
+				Expect(err).To(MatchError("overlay subnet 10.255.33.0/24 of underlay ip 10.244.11.22 overlaps with leases of 10.244.7.8"))
+				Expect(databaseHandler.RenewLeaseForUnderlayIPCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the existing lease does not exist", func() {
 			BeforeEach(func() {
 				databaseHandler.LeaseForUnderlayIPReturns(nil, nil)
@@ -457,6 +764,41 @@ var _ = Describe("LeaseController", func() {
 				Expect(int64(logger.Logs()[0].Data["last_renewed_at"].(float64))).To(Equal(lastRenewedAt))
 			})
 
+			It("does not store an ipv6 prefix sent by the daemon", func() {
+				leaseToRenew.OverlayIPv6Subnet = "fd00:abcd:0:2100::/56"
+
+				err := leaseController.RenewSubnetLease(leaseToRenew)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(databaseHandler.AddEntryArgsForCall(0).OverlayIPv6Subnet).To(BeEmpty())
+			})
+
+			Context("when the lease overlaps with the lease of another cell", func() {
+				BeforeEach(func() {
+					databaseHandler.AllReturns([]controller.Lease{
+						{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.33.0/24"},
+						{UnderlayIP: "10.244.9.10", OverlaySubnet: "10.255.34.0/24"},
+					}, nil)
+				})
+				It("returns a non-retriable error without adding the entry", func() {
+					err := leaseController.RenewSubnetLease(leaseToRenew)
+					Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
+					Expect(err).To(MatchError("overlay subnet 10.255.33.0/24 of underlay ip 10.244.11.22 overlaps with leases of 10.244.7.8"))
+					Expect(databaseHandler.AddEntryCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when getting all leases fails", func() {
+				BeforeEach(func() {
+					databaseHandler.AllReturns(nil, errors.New("mango"))
+				})
+				It("returns a non-retriable error", func() {
+					err := leaseController.RenewSubnetLease(leaseToRenew)
+					Expect(err).To(BeAssignableToTypeOf(controller.NonRetriableError("")))
+					Expect(err).To(MatchError("getting all leases: mango"))
+				})
+			})
+
 			Context("when adding the entry fails", func() {
 				BeforeEach(func() {
 					databaseHandler.AddEntryReturns(errors.New("pineapple"))
@@ -558,6 +900,84 @@ var _ = Describe("LeaseController", func() {
 		})
 	})
 
+	Describe("LeaseConflicts", func() {
+		BeforeEach(func() {
+			databaseHandler.AllReturns([]controller.Lease{
+				{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"},
+				{UnderlayIP: "10.244.22.33", OverlaySubnet: "10.255.75.0/32"},
+				{UnderlayIP: "10.244.22.34", OverlaySubnet: "10.255.16.0/24"},
+			}, nil)
+		})
+		It("returns the groups of overlapping leases", func() {
+			conflicts, err := leaseController.LeaseConflicts()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conflicts).To(Equal([]controller.LeaseConflict{
+				{
+					Leases: []controller.Lease{
+						{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"},
+						{UnderlayIP: "10.244.22.34", OverlaySubnet: "10.255.16.0/24"},
+					},
+				},
+			}))
+		})
+
+		Context("when getting the leases fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AllReturns(nil, errors.New("cupcake"))
+			})
+			It("wraps the error from the database handler", func() {
+				_, err := leaseController.LeaseConflicts()
+				Expect(err).To(MatchError("getting all leases: cupcake"))
+			})
+		})
+	})
+
+	Describe("ResolveLeaseConflict", func() {
+		BeforeEach(func() {
+			databaseHandler.AllReturns([]controller.Lease{
+				{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"},
+				{UnderlayIP: "10.244.22.33", OverlaySubnet: "10.255.75.0/32"},
+				{UnderlayIP: "10.244.22.34", OverlaySubnet: "10.255.16.0/24"},
+			}, nil)
+		})
+		It("deletes the conflicting lease and logs it", func() {
+			err := leaseController.ResolveLeaseConflict("10.244.22.34")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(1))
+			Expect(databaseHandler.DeleteEntryArgsForCall(0)).To(Equal("10.244.22.34"))
+
+			Expect(logger.Logs()).To(HaveLen(1))
+			Expect(logger.Logs()[0].Message).To(Equal("test.lease-conflict-resolved"))
+		})
+
+		Context("when the cell has no lease", func() {
+			It("returns an error", func() {
+				err := leaseController.ResolveLeaseConflict("10.244.1.1")
+				Expect(err).To(MatchError("no lease for underlay ip 10.244.1.1"))
+				Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the lease of the cell does not conflict", func() {
+			It("returns an error", func() {
+				err := leaseController.ResolveLeaseConflict("10.244.22.33")
+				Expect(err).To(MatchError("lease for underlay ip 10.244.22.33 does not conflict with other leases"))
+				Expect(databaseHandler.DeleteEntryCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when deleting the lease fails", func() {
+			BeforeEach(func() {
+				databaseHandler.DeleteEntryReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := leaseController.ResolveLeaseConflict("10.244.22.34")
+				Expect(err).To(MatchError("deleting lease for underlay ip 10.244.22.34: banana"))
+			})
+		})
+	})
+
 	Describe("RoutableLeases", func() {
 		activeLeases := []controller.Lease{
 			{
@@ -580,6 +1000,51 @@ var _ = Describe("LeaseController", func() {
 			Expect(leases).To(Equal(activeLeases))
 		})
 
+		Context("when ipv6 prefixes are configured", func() {
+			BeforeEach(func() {
+				ipv6Prefixes := &fakes.IPv6Prefixes{}
+				ipv6Prefixes.PrefixForStub = func(overlaySubnet string) (string, error) {
+					if overlaySubnet == "10.255.16.0/24" {
+						return "fd00:abcd:0:1000::/56", nil
+					}
+					return "", errors.New("no prefix")
+				}
+				leaseController.IPv6Prefixes = ipv6Prefixes
+			})
+			It("adds the ipv6 prefix to each lease that has one", func() {
+				leases, err := leaseController.RoutableLeases()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leases).To(Equal([]controller.Lease{
+					{
+						UnderlayIP:        "10.244.5.9",
+						OverlaySubnet:     "10.255.16.0/24",
+						OverlayIPv6Subnet: "fd00:abcd:0:1000::/56",
+					},
+					{
+						UnderlayIP:    "10.244.22.33",
+						OverlaySubnet: "10.255.75.0/32",
+					},
+				}))
+			})
+		})
+
+		Context("when the overlay subnets of leases overlap", func() {
+			BeforeEach(func() {
+				databaseHandler.AllActiveReturns(append([]controller.Lease{
+					{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.16.0/23"},
+				}, activeLeases...), nil)
+			})
+			It("quarantines them and logs their underlay ips", func() {
+				leases, err := leaseController.RoutableLeases()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(leases).To(Equal([]controller.Lease{activeLeases[1]}))
+
+				Expect(logger.Logs()).To(HaveLen(1))
+				Expect(logger.Logs()[0].Message).To(Equal("test.lease-conflicts"))
+				Expect(logger.Logs()[0].Data["quarantined_underlay_ips"]).To(Equal([]interface{}{"10.244.5.9", "10.244.7.8"}))
+			})
+		})
+
 		Context("when getting the leases fails", func() {
 			BeforeEach(func() {
 				databaseHandler.AllActiveReturns(nil, errors.New("cupcake"))
@@ -590,4 +1055,58 @@ var _ = Describe("LeaseController", func() {
 			})
 		})
 	})
+
+	Describe("Topology", func() {
+		BeforeEach(func() {
+			databaseHandler.AllWithLastRenewedAtReturns([]controller.TopologyCell{
+				{Lease: controller.Lease{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"}, LastRenewedAt: 100},
+				{Lease: controller.Lease{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.16.0/23"}, LastRenewedAt: 100},
+				{Lease: controller.Lease{UnderlayIP: "10.244.22.33", OverlaySubnet: "10.255.75.0/32"}, LastRenewedAt: 100},
+				{Lease: controller.Lease{UnderlayIP: "10.244.30.1", OverlaySubnet: "10.255.80.0/24"}, LastRenewedAt: 10},
+			}, nil)
+			databaseHandler.AllActiveReturns([]controller.Lease{
+				{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"},
+				{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.16.0/23"},
+				{UnderlayIP: "10.244.22.33", OverlaySubnet: "10.255.75.0/32"},
+			}, nil)
+		})
+
+		It("returns every lease with whether it is routable", func() {
+			topology, err := leaseController.Topology()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseHandler.AllActiveArgsForCall(0)).To(Equal(42))
+
+			Expect(topology.LeaseExpirationSeconds).To(Equal(42))
+			Expect(topology.GeneratedAt).To(BeNumerically("~", time.Now().Unix(), 5))
+			Expect(topology.Cells).To(Equal([]controller.TopologyCell{
+				{Lease: controller.Lease{UnderlayIP: "10.244.5.9", OverlaySubnet: "10.255.16.0/24"}, LastRenewedAt: 100},
+				{Lease: controller.Lease{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.16.0/23"}, LastRenewedAt: 100},
+				{Lease: controller.Lease{UnderlayIP: "10.244.22.33", OverlaySubnet: "10.255.75.0/32"}, LastRenewedAt: 100, Routable: true},
+				{Lease: controller.Lease{UnderlayIP: "10.244.30.1", OverlaySubnet: "10.255.80.0/24"}, LastRenewedAt: 10},
+			}))
+			Expect(topology.Version).To(Equal(controller.TopologyVersion(topology.Cells)))
+		})
+
+		Context("when getting the leases fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AllWithLastRenewedAtReturns(nil, errors.New("cupcake"))
+			})
+
+			It("wraps the error from the database handler", func() {
+				_, err := leaseController.Topology()
+				Expect(err).To(MatchError("getting all leases: cupcake"))
+			})
+		})
+
+		Context("when getting the active leases fails", func() {
+			BeforeEach(func() {
+				databaseHandler.AllActiveReturns(nil, errors.New("cupcake"))
+			})
+
+			It("wraps the error from the database handler", func() {
+				_, err := leaseController.Topology()
+				Expect(err).To(MatchError("getting active leases: cupcake"))
+			})
+		})
+	})
 })
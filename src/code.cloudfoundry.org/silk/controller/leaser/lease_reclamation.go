@@ -0,0 +1,86 @@
+package leaser
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/database"
+)
+
+//go:generate counterfeiter -o fakes/cell_prober.go --fake-name CellProber . cellProber
+type cellProber interface {
+	Reachable(underlayIP string) bool
+}
+
+// ReclamationPolicy decides which expired leases may be handed to another
+// cell. A lease is only reclaimed once its cell has not renewed it for the
+// lease expiration plus GraceSeconds, the cell is not pinned and, when a
+// Prober is set, the cell does not answer the probe.
+type ReclamationPolicy struct {
+	GraceSeconds      int
+	PinnedUnderlayIPs []string
+	Prober            cellProber
+}
+
+func (p ReclamationPolicy) isPinned(underlayIP string) bool {
+	for _, pinned := range p.PinnedUnderlayIPs {
+		if pinned == underlayIP {
+			return true
+		}
+	}
+	return false
+}
+
+// TCPProber considers a cell reachable when it accepts TCP connections on
+// Port of its underlay ip.
+type TCPProber struct {
+	Port    int
+	Timeout time.Duration
+}
+
+func (p *TCPProber) Reachable(underlayIP string) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(underlayIP, strconv.Itoa(p.Port)), p.Timeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// reclaimExpiredLease deletes the first of the expired leases that the
+// reclamation policy allows to be reclaimed and returns it, or nil if none
+// may be reclaimed.
+func (c *LeaseController) reclaimExpiredLease(expired []controller.Lease) (*controller.Lease, error) {
+	for i := range expired {
+		lease := expired[i]
+		if c.ReclamationPolicy.isPinned(lease.UnderlayIP) {
+			c.Logger.Debug("expired-lease-pinned", lager.Data{"lease": lease})
+			continue
+		}
+		if c.ReclamationPolicy.Prober != nil && c.ReclamationPolicy.Prober.Reachable(lease.UnderlayIP) {
+			c.Logger.Info("expired-lease-cell-reachable", lager.Data{"lease": lease})
+			continue
+		}
+
+		err := c.DatabaseHandler.DeleteEntry(lease.UnderlayIP)
+		if err == database.RecordNotAffectedError {
+			// another controller instance reclaimed it first
+			c.Logger.Debug("expired-lease-already-reclaimed", lager.Data{"lease": lease})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("delete expired subnet: %s", err)
+		}
+		c.Logger.Info("expired-lease-reclaimed", lager.Data{"lease": lease})
+		return &lease, nil
+	}
+	return nil, nil
+}
+
+func (c *LeaseController) reclaimAfterSeconds() int {
+	return c.LeaseExpirationSeconds + c.ReclamationPolicy.GraceSeconds
+}
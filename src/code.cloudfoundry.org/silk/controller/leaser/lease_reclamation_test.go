@@ -0,0 +1,52 @@
+package leaser_test
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller/leaser"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TCPProber", func() {
+	var (
+		listener net.Listener
+		prober   *leaser.TCPProber
+	)
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		_, port, err := net.SplitHostPort(listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		portNumber, err := strconv.Atoi(port)
+		Expect(err).NotTo(HaveOccurred())
+
+		prober = &leaser.TCPProber{
+			Port:    portNumber,
+			Timeout: time.Second,
+		}
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	It("reports a cell accepting connections as reachable", func() {
+		Expect(prober.Reachable("127.0.0.1")).To(BeTrue())
+	})
+
+	Context("when the cell does not accept connections", func() {
+		BeforeEach(func() {
+			Expect(listener.Close()).To(Succeed())
+		})
+
+		It("reports the cell as unreachable", func() {
+			Expect(prober.Reachable("127.0.0.1")).To(BeFalse())
+		})
+	})
+})
@@ -14,6 +14,15 @@ func (v *LeaseValidator) Validate(lease controller.Lease) error {
 		return fmt.Errorf("invalid underlay ip: %s", lease.UnderlayIP)
 	}
 
+	if lease.FailoverUnderlayIP != "" {
+		if net.ParseIP(lease.FailoverUnderlayIP) == nil {
+			return fmt.Errorf("invalid failover underlay ip: %s", lease.FailoverUnderlayIP)
+		}
+		if lease.FailoverUnderlayIP == lease.UnderlayIP {
+			return fmt.Errorf("failover underlay ip is the underlay ip: %s", lease.UnderlayIP)
+		}
+	}
+
 	_, _, err := net.ParseCIDR(lease.OverlaySubnet)
 	if err != nil {
 		return err
@@ -24,5 +33,15 @@ func (v *LeaseValidator) Validate(lease controller.Lease) error {
 		return err
 	}
 
+	if lease.OverlayIPv6Subnet != "" {
+		ip, _, err := net.ParseCIDR(lease.OverlayIPv6Subnet)
+		if err != nil {
+			return err
+		}
+		if ip.To4() != nil {
+			return fmt.Errorf("invalid overlay ipv6 subnet: %s", lease.OverlayIPv6Subnet)
+		}
+	}
+
 	return nil
 }
@@ -38,6 +38,36 @@ var _ = Describe("Validator", func() {
 		})
 	})
 
+	Context("when the failover underlay ip is set", func() {
+		BeforeEach(func() {
+			lease.FailoverUnderlayIP = "1.2.4.4"
+		})
+		It("checks that the lease is valid", func() {
+			err := validator.Validate(lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when it is not a valid ip", func() {
+			BeforeEach(func() {
+				lease.FailoverUnderlayIP = "not-an-ip"
+			})
+			It("returns an error", func() {
+				err := validator.Validate(lease)
+				Expect(err).To(MatchError("invalid failover underlay ip: not-an-ip"))
+			})
+		})
+
+		Context("when it is the underlay ip", func() {
+			BeforeEach(func() {
+				lease.FailoverUnderlayIP = "1.2.3.4"
+			})
+			It("returns an error", func() {
+				err := validator.Validate(lease)
+				Expect(err).To(MatchError("failover underlay ip is the underlay ip: 1.2.3.4"))
+			})
+		})
+	})
+
 	Context("when the overlay subnet is invalid", func() {
 		BeforeEach(func() {
 			lease.OverlaySubnet = "not-a-subnet"
@@ -57,4 +87,24 @@ var _ = Describe("Validator", func() {
 			Expect(err).To(MatchError(ContainSubstring("invalid MAC address")))
 		})
 	})
+
+	Context("when the overlay ipv6 subnet is set", func() {
+		BeforeEach(func() {
+			lease.OverlayIPv6Subnet = "fd00:abcd:0:100::/64"
+		})
+		It("checks that the lease is valid", func() {
+			err := validator.Validate(lease)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when it is not an ipv6 subnet", func() {
+			BeforeEach(func() {
+				lease.OverlayIPv6Subnet = "10.255.0.0/24"
+			})
+			It("returns an error", func() {
+				err := validator.Validate(lease)
+				Expect(err).To(MatchError("invalid overlay ipv6 subnet: 10.255.0.0/24"))
+			})
+		})
+	})
 })
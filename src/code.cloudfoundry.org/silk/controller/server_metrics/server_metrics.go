@@ -3,6 +3,7 @@ package server_metrics
 import (
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
 	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/controller/leaser"
 )
 
 //go:generate counterfeiter -o fakes/databaseHandler.go --fake-name DatabaseHandler . databaseHandler
@@ -51,3 +52,19 @@ func NewStaleLeasesSource(lister databaseHandler, seconds int) metrics.MetricSou
 		},
 	}
 }
+
+// NewConflictingLeasesSource counts the active leases that are left out of
+// the routable leases, because their overlay subnets overlap.
+func NewConflictingLeasesSource(lister databaseHandler, leaseExpirationSeconds int) metrics.MetricSource {
+	return metrics.MetricSource{
+		Name: "conflictingLeases",
+		Unit: "",
+		Getter: func() (float64, error) {
+			activeLeases, err := lister.AllActive(leaseExpirationSeconds)
+			if err != nil {
+				return 0, err
+			}
+			return float64(leaser.CountConflictingLeases(activeLeases)), nil
+		},
+	}
+}
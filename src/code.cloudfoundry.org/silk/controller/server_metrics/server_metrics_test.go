@@ -1,6 +1,8 @@
 package server_metrics_test
 
 import (
+	"errors"
+
 	"code.cloudfoundry.org/silk/controller"
 	"code.cloudfoundry.org/silk/controller/server_metrics"
 	"code.cloudfoundry.org/silk/controller/server_metrics/fakes"
@@ -83,4 +85,33 @@ var _ = Describe("ServerMetrics", func() {
 		})
 	})
 
+	Describe("conflictingLeases", func() {
+		It("returns the number of active leases whose overlay subnets overlap", func() {
+			fakeDatabaseHandler.AllActiveReturns([]controller.Lease{
+				allLeases[0],
+				allLeases[1],
+				{UnderlayIP: "10.244.7.8", OverlaySubnet: "10.255.16.0/24"},
+			}, nil)
+			source := server_metrics.NewConflictingLeasesSource(fakeDatabaseHandler, 5)
+
+			Expect(source.Name).To(Equal("conflictingLeases"))
+			Expect(source.Unit).To(Equal(""))
+
+			value, err := source.Getter()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeDatabaseHandler.AllActiveArgsForCall(0)).To(Equal(5))
+			Expect(value).To(Equal(2.0))
+		})
+
+		Context("when getting the leases fails", func() {
+			It("returns the error", func() {
+				fakeDatabaseHandler.AllActiveReturns(nil, errors.New("banana"))
+				source := server_metrics.NewConflictingLeasesSource(fakeDatabaseHandler, 5)
+
+				_, err := source.Getter()
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
 })
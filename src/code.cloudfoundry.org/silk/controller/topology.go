@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Topology is a snapshot of the overlay network: the lease of every cell and
+// when it was last renewed. Its Version changes whenever a lease or the
+// routability of a cell changes, but not when a lease is only renewed, so
+// that tooling can tell whether the overlay changed between two snapshots.
+type Topology struct {
+	Version                string         `json:"version"`
+	GeneratedAt            int64          `json:"generated_at"`
+	LeaseExpirationSeconds int            `json:"lease_expiration_seconds"`
+	Cells                  []TopologyCell `json:"cells"`
+}
+
+// TopologyCell is the lease of a cell. A cell is routable while its lease is
+// active and does not conflict with the one of another cell, i.e. while the
+// other cells route its overlay subnet to it.
+type TopologyCell struct {
+	Lease
+	LastRenewedAt int64 `json:"last_renewed_at"`
+	Routable      bool  `json:"routable"`
+}
+
+// TopologyVersion is the version of a topology with the cells.
+func TopologyVersion(cells []TopologyCell) string {
+	hash := sha256.New()
+	for _, cell := range cells {
+		cell.LastRenewedAt = 0
+		// marshaling a struct of strings and bools cannot fail
+		line, _ := json.Marshal(cell)
+		hash.Write(append(line, '\n'))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// SignedTopology is a topology signed by the key of the certificate of the
+// controller, so that tooling can verify a snapshot it did not fetch itself.
+// Topology holds the signed bytes as they were marshaled.
+type SignedTopology struct {
+	Topology  json.RawMessage   `json:"topology"`
+	Signature TopologySignature `json:"signature"`
+}
+
+// TopologySignature is the signature of a topology. Certificates is the PEM
+// encoded certificate of the signing key, followed by its intermediates.
+// Algorithm is the name of the x509 signature algorithm, e.g. ECDSA-SHA256.
+type TopologySignature struct {
+	Algorithm    string `json:"algorithm"`
+	Certificates string `json:"certificates"`
+	Value        []byte `json:"value"`
+}
+
+// TopologySigner signs the topologies with the current certificate of the
+// credentials of the controller.
+type TopologySigner struct {
+	Credentials interface {
+		Certificate() tls.Certificate
+	}
+}
+
+func (s *TopologySigner) Sign(topology Topology) (SignedTopology, error) {
+	return SignTopology(topology, s.Credentials.Certificate())
+}
+
+// SignTopology signs the topology with the key of the certificate.
+func SignTopology(topology Topology, certificate tls.Certificate) (SignedTopology, error) {
+	if len(certificate.Certificate) == 0 {
+		return SignedTopology{}, errors.New("signing topology: missing certificate")
+	}
+	signer, ok := certificate.PrivateKey.(crypto.Signer)
+	if !ok {
+		return SignedTopology{}, errors.New("signing topology: key cannot sign")
+	}
+
+	data, err := json.Marshal(topology)
+	if err != nil {
+		return SignedTopology{}, fmt.Errorf("signing topology: %s", err)
+	}
+
+	var algorithm x509.SignatureAlgorithm
+	var value []byte
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		algorithm = x509.SHA256WithRSA
+		digest := sha256.Sum256(data)
+		value, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case *ecdsa.PrivateKey:
+		algorithm = x509.ECDSAWithSHA256
+		digest := sha256.Sum256(data)
+		value, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case ed25519.PrivateKey:
+		algorithm = x509.PureEd25519
+		value, err = signer.Sign(rand.Reader, data, crypto.Hash(0))
+	default:
+		return SignedTopology{}, fmt.Errorf("signing topology: unsupported key type %T", signer)
+	}
+	if err != nil {
+		return SignedTopology{}, fmt.Errorf("signing topology: %s", err)
+	}
+
+	certificates := []byte{}
+	for _, der := range certificate.Certificate {
+		certificates = append(certificates, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	return SignedTopology{
+		Topology: data,
+		Signature: TopologySignature{
+			Algorithm:    algorithm.String(),
+			Certificates: string(certificates),
+			Value:        value,
+		},
+	}, nil
+}
+
+// Verify checks that the topology was signed by the key of a certificate
+// that the roots trusted when the topology was generated, and returns it.
+func (s SignedTopology) Verify(roots *x509.CertPool) (Topology, error) {
+	var topology Topology
+	if err := json.Unmarshal(s.Topology, &topology); err != nil {
+		return Topology{}, fmt.Errorf("verifying topology: %s", err)
+	}
+
+	var algorithm x509.SignatureAlgorithm
+	for _, supported := range []x509.SignatureAlgorithm{x509.SHA256WithRSA, x509.ECDSAWithSHA256, x509.PureEd25519} {
+		if s.Signature.Algorithm == supported.String() {
+			algorithm = supported
+		}
+	}
+	if algorithm == x509.UnknownSignatureAlgorithm {
+		return Topology{}, fmt.Errorf("verifying topology: unsupported algorithm %q", s.Signature.Algorithm)
+	}
+
+	certificates := []*x509.Certificate{}
+	for rest := []byte(s.Signature.Certificates); ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return Topology{}, fmt.Errorf("verifying topology: parsing certificate: %s", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return Topology{}, errors.New("verifying topology: missing certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err := certificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Unix(topology.GeneratedAt, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return Topology{}, fmt.Errorf("verifying topology: %s", err)
+	}
+
+	if err := certificates[0].CheckSignature(algorithm, s.Topology, s.Signature.Value); err != nil {
+		return Topology{}, fmt.Errorf("verifying topology: %s", err)
+	}
+	return topology, nil
+}
@@ -0,0 +1,171 @@
+package controller_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Topology", func() {
+	var (
+		caCert   *x509.Certificate
+		caKey    *ecdsa.PrivateKey
+		roots    *x509.CertPool
+		topology controller.Topology
+	)
+
+	newCertificate := func(key crypto.Signer, notAfter time.Time) tls.Certificate {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "silk-controller.service.cf.internal"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+		Expect(err).NotTo(HaveOccurred())
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+
+	newECDSAKey := func() crypto.Signer {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		return key
+	}
+
+	BeforeEach(func() {
+		var err error
+		caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "test-ca"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+		Expect(err).NotTo(HaveOccurred())
+		caCert, err = x509.ParseCertificate(der)
+		Expect(err).NotTo(HaveOccurred())
+		roots = x509.NewCertPool()
+		roots.AddCert(caCert)
+
+		cells := []controller.TopologyCell{{
+			Lease:         controller.Lease{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24", OverlayHardwareAddr: "ee:ee:0a:ff:01:00"},
+			LastRenewedAt: time.Now().Unix(),
+			Routable:      true,
+		}}
+		topology = controller.Topology{
+			Version:                controller.TopologyVersion(cells),
+			GeneratedAt:            time.Now().Unix(),
+			LeaseExpirationSeconds: 60,
+			Cells:                  cells,
+		}
+	})
+
+	Describe("TopologyVersion", func() {
+		It("does not change when a lease is only renewed", func() {
+			cells := append([]controller.TopologyCell{}, topology.Cells...)
+			cells[0].LastRenewedAt++
+			Expect(controller.TopologyVersion(cells)).To(Equal(topology.Version))
+		})
+
+		It("changes when a cell is no longer routable", func() {
+			cells := append([]controller.TopologyCell{}, topology.Cells...)
+			cells[0].Routable = false
+			Expect(controller.TopologyVersion(cells)).NotTo(Equal(topology.Version))
+		})
+	})
+
+	Describe("SignTopology and Verify", func() {
+		It("verifies a topology signed with an ecdsa key", func() {
+			signed, err := controller.SignTopology(topology, newCertificate(newECDSAKey(), time.Now().Add(time.Hour)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signed.Signature.Algorithm).To(Equal("ECDSA-SHA256"))
+
+			verified, err := signed.Verify(roots)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verified).To(Equal(topology))
+		})
+
+		It("verifies a topology signed with an rsa key", func() {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			signed, err := controller.SignTopology(topology, newCertificate(key, time.Now().Add(time.Hour)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signed.Signature.Algorithm).To(Equal("SHA256-RSA"))
+
+			verified, err := signed.Verify(roots)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verified).To(Equal(topology))
+		})
+
+		It("verifies a topology that went through json", func() {
+			signed, err := controller.SignTopology(topology, newCertificate(newECDSAKey(), time.Now().Add(time.Hour)))
+			Expect(err).NotTo(HaveOccurred())
+			data, err := json.Marshal(signed)
+			Expect(err).NotTo(HaveOccurred())
+
+			var decoded controller.SignedTopology
+			Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+			_, err = decoded.Verify(roots)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("verifies a topology whose certificate expired after it was generated", func() {
+			topology.GeneratedAt = time.Now().Add(-30 * time.Minute).Unix()
+			signed, err := controller.SignTopology(topology, newCertificate(newECDSAKey(), time.Now().Add(-time.Minute)))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = signed.Verify(roots)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects a changed topology", func() {
+			signed, err := controller.SignTopology(topology, newCertificate(newECDSAKey(), time.Now().Add(time.Hour)))
+			Expect(err).NotTo(HaveOccurred())
+			topology.Cells[0].UnderlayIP = "10.0.0.2"
+			signed.Topology, err = json.Marshal(topology)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = signed.Verify(roots)
+			Expect(err).To(MatchError(ContainSubstring("verifying topology:")))
+		})
+
+		It("rejects a topology signed by a certificate of another ca", func() {
+			signed, err := controller.SignTopology(topology, newCertificate(newECDSAKey(), time.Now().Add(time.Hour)))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = signed.Verify(x509.NewCertPool())
+			Expect(err).To(MatchError(ContainSubstring("certificate signed by unknown authority")))
+		})
+
+		It("rejects an unsupported algorithm", func() {
+			signed, err := controller.SignTopology(topology, newCertificate(newECDSAKey(), time.Now().Add(time.Hour)))
+			Expect(err).NotTo(HaveOccurred())
+			signed.Signature.Algorithm = "MD5-RSA"
+
+			_, err = signed.Verify(roots)
+			Expect(err).To(MatchError(`verifying topology: unsupported algorithm "MD5-RSA"`))
+		})
+
+		It("fails to sign without a certificate", func() {
+			_, err := controller.SignTopology(topology, tls.Certificate{})
+			Expect(err).To(MatchError("signing topology: missing certificate"))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package bgp_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBGP(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "BGP Suite")
+}
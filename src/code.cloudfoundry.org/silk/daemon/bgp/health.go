@@ -0,0 +1,28 @@
+package bgp
+
+import (
+	"sync"
+	"time"
+)
+
+// LeaseHealth is healthy while the cell renewed its lease within the Timeout.
+// The lease of a cell that cannot reach the controller may be given to
+// another cell, so its subnet is withdrawn before that can happen.
+type LeaseHealth struct {
+	Timeout time.Duration
+
+	mu          sync.Mutex
+	lastRenewal time.Time
+}
+
+func (h *LeaseHealth) Renewed() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastRenewal = time.Now()
+}
+
+func (h *LeaseHealth) Healthy() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.lastRenewal.IsZero() && time.Since(h.lastRenewal) <= h.Timeout
+}
@@ -0,0 +1,20 @@
+package bgp_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/silk/daemon/bgp"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LeaseHealth", func() {
+	It("is healthy until the timeout passes after a renewal", func() {
+		health := &bgp.LeaseHealth{Timeout: 100 * time.Millisecond}
+		Expect(health.Healthy()).To(BeFalse())
+
+		health.Renewed()
+		Expect(health.Healthy()).To(BeTrue())
+		Eventually(health.Healthy).Should(BeFalse())
+	})
+})
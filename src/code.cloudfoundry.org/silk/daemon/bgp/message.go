@@ -0,0 +1,325 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// MessageType is the type of a BGP-4 message, as in RFC 4271.
+type MessageType uint8
+
+const (
+	MsgOpen         MessageType = 1
+	MsgUpdate       MessageType = 2
+	MsgNotification MessageType = 3
+	MsgKeepalive    MessageType = 4
+)
+
+const (
+	headerLength  = 19
+	maxLength     = 4096
+	version       = 4
+	asTrans       = 23456
+	capabilities  = 2
+	capFourOctet  = 65
+	capMultiProto = 1
+
+	attrOrigin    = 1
+	attrASPath    = 2
+	attrNextHop   = 3
+	attrLocalPref = 5
+	asSequence    = 2
+	originIGP     = 0
+	flagOptional  = 0x80
+	flagTransit   = 0x40
+	flagExtLength = 0x10
+)
+
+// Message is a BGP message as read from a connection.
+type Message struct {
+	Type MessageType
+	Body []byte
+}
+
+func ReadMessage(r io.Reader) (Message, error) {
+	header := make([]byte, headerLength)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return Message{}, err
+	}
+	for _, b := range header[:16] {
+		if b != 0xff {
+			return Message{}, errors.New("invalid marker")
+		}
+	}
+	length := int(binary.BigEndian.Uint16(header[16:18]))
+	if length < headerLength || length > maxLength {
+		return Message{}, fmt.Errorf("invalid length %d", length)
+	}
+	body := make([]byte, length-headerLength)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Type: MessageType(header[18]), Body: body}, nil
+}
+
+func WriteMessage(w io.Writer, msgType MessageType, body []byte) error {
+	message := make([]byte, headerLength, headerLength+len(body))
+	for i := 0; i < 16; i++ {
+		message[i] = 0xff
+	}
+	binary.BigEndian.PutUint16(message[16:18], uint16(headerLength+len(body)))
+	message[18] = byte(msgType)
+	_, err := w.Write(append(message, body...))
+	return err
+}
+
+// OpenMessage opens a session. The speaker always offers four-octet AS
+// numbers and IPv4 unicast routes.
+type OpenMessage struct {
+	AS          uint32
+	HoldTime    uint16
+	RouterID    net.IP
+	FourOctetAS bool
+}
+
+func (o OpenMessage) Marshal() []byte {
+	myAS := uint16(asTrans)
+	if o.AS <= 0xffff {
+		myAS = uint16(o.AS)
+	}
+	caps := []byte{capMultiProto, 4, 0, 1, 0, 1}
+	if o.FourOctetAS {
+		caps = append(caps, capFourOctet, 4)
+		caps = binary.BigEndian.AppendUint32(caps, o.AS)
+	}
+
+	body := []byte{version}
+	body = binary.BigEndian.AppendUint16(body, myAS)
+	body = binary.BigEndian.AppendUint16(body, o.HoldTime)
+	body = append(body, o.RouterID.To4()...)
+	body = append(body, byte(len(caps)+2), capabilities, byte(len(caps)))
+	return append(body, caps...)
+}
+
+func ParseOpen(body []byte) (OpenMessage, error) {
+	if len(body) < 10 {
+		return OpenMessage{}, errors.New("open message too short")
+	}
+	if body[0] != version {
+		return OpenMessage{}, fmt.Errorf("unsupported version %d", body[0])
+	}
+	open := OpenMessage{
+		AS:       uint32(binary.BigEndian.Uint16(body[1:3])),
+		HoldTime: binary.BigEndian.Uint16(body[3:5]),
+		RouterID: net.IP(append([]byte{}, body[5:9]...)),
+	}
+	params := body[10:]
+	if len(params) != int(body[9]) {
+		return OpenMessage{}, errors.New("invalid optional parameters length")
+	}
+	for len(params) >= 2 {
+		paramType, paramLength := params[0], int(params[1])
+		if len(params) < 2+paramLength {
+			return OpenMessage{}, errors.New("invalid optional parameter")
+		}
+		if paramType == capabilities {
+			caps := params[2 : 2+paramLength]
+			for len(caps) >= 2 {
+				code, capLength := caps[0], int(caps[1])
+				if len(caps) < 2+capLength {
+					return OpenMessage{}, errors.New("invalid capability")
+				}
+				if code == capFourOctet && capLength == 4 {
+					open.FourOctetAS = true
+					open.AS = binary.BigEndian.Uint32(caps[2:6])
+				}
+				caps = caps[2+capLength:]
+			}
+		}
+		params = params[2+paramLength:]
+	}
+	return open, nil
+}
+
+// UpdateMessage announces the NLRI with the path attributes, and withdraws
+// the Withdrawn routes. LocalPref is only sent to internal peers.
+type UpdateMessage struct {
+	Withdrawn []*net.IPNet
+	ASPath    []uint32
+	NextHop   net.IP
+	LocalPref uint32
+	NLRI      []*net.IPNet
+}
+
+func (u UpdateMessage) Marshal(fourOctetAS bool) []byte {
+	withdrawn := marshalPrefixes(u.Withdrawn)
+	attrs := []byte{}
+	if len(u.NLRI) > 0 {
+		attrs = appendAttr(attrs, flagTransit, attrOrigin, []byte{originIGP})
+		path := []byte{}
+		if len(u.ASPath) > 0 {
+			path = append(path, asSequence, byte(len(u.ASPath)))
+			for _, as := range u.ASPath {
+				if fourOctetAS {
+					path = binary.BigEndian.AppendUint32(path, as)
+				} else {
+					path = binary.BigEndian.AppendUint16(path, uint16(as))
+				}
+			}
+		}
+		attrs = appendAttr(attrs, flagTransit, attrASPath, path)
+		attrs = appendAttr(attrs, flagTransit, attrNextHop, u.NextHop.To4())
+		if u.LocalPref != 0 {
+			attrs = appendAttr(attrs, flagTransit, attrLocalPref, binary.BigEndian.AppendUint32(nil, u.LocalPref))
+		}
+	}
+
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(withdrawn)))
+	body = append(body, withdrawn...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(attrs)))
+	body = append(body, attrs...)
+	return append(body, marshalPrefixes(u.NLRI)...)
+}
+
+func ParseUpdate(body []byte, fourOctetAS bool) (UpdateMessage, error) {
+	update := UpdateMessage{}
+	if len(body) < 4 {
+		return update, errors.New("update message too short")
+	}
+	withdrawnLength := int(binary.BigEndian.Uint16(body[0:2]))
+	if len(body) < 4+withdrawnLength {
+		return update, errors.New("invalid withdrawn routes length")
+	}
+	var err error
+	update.Withdrawn, err = parsePrefixes(body[2 : 2+withdrawnLength])
+	if err != nil {
+		return update, err
+	}
+	body = body[2+withdrawnLength:]
+	attrsLength := int(binary.BigEndian.Uint16(body[0:2]))
+	if len(body) < 2+attrsLength {
+		return update, errors.New("invalid path attributes length")
+	}
+	attrs := body[2 : 2+attrsLength]
+	for len(attrs) >= 3 {
+		flags, code := attrs[0], attrs[1]
+		offset, length := 3, int(attrs[2])
+		if flags&flagExtLength != 0 {
+			if len(attrs) < 4 {
+				return update, errors.New("invalid path attribute")
+			}
+			offset, length = 4, int(binary.BigEndian.Uint16(attrs[2:4]))
+		}
+		if len(attrs) < offset+length {
+			return update, errors.New("invalid path attribute")
+		}
+		value := attrs[offset : offset+length]
+		switch code {
+		case attrASPath:
+			update.ASPath = parseASPath(value, fourOctetAS)
+		case attrNextHop:
+			update.NextHop = net.IP(append([]byte{}, value...))
+		case attrLocalPref:
+			if length == 4 {
+				update.LocalPref = binary.BigEndian.Uint32(value)
+			}
+		}
+		attrs = attrs[offset+length:]
+	}
+	update.NLRI, err = parsePrefixes(body[2+attrsLength:])
+	return update, err
+}
+
+// NotificationMessage closes a session because of an error.
+type NotificationMessage struct {
+	Code    uint8
+	Subcode uint8
+	Data    []byte
+}
+
+const (
+	notificationOpenError       = 2
+	notificationHoldTimer       = 4
+	notificationCease           = 6
+	subcodeBadPeerAS            = 2
+	subcodeAdminShutdown        = 2
+	subcodeUnsupportedOption    = 4
+	subcodeUnacceptableHoldTime = 6
+)
+
+func (n NotificationMessage) Marshal() []byte {
+	return append([]byte{n.Code, n.Subcode}, n.Data...)
+}
+
+func ParseNotification(body []byte) (NotificationMessage, error) {
+	if len(body) < 2 {
+		return NotificationMessage{}, errors.New("notification message too short")
+	}
+	return NotificationMessage{Code: body[0], Subcode: body[1], Data: body[2:]}, nil
+}
+
+func (n NotificationMessage) Error() string {
+	return fmt.Sprintf("notification code %d subcode %d", n.Code, n.Subcode)
+}
+
+func appendAttr(attrs []byte, flags, code byte, value []byte) []byte {
+	if len(value) > 0xff {
+		attrs = append(attrs, flags|flagExtLength, code)
+		attrs = binary.BigEndian.AppendUint16(attrs, uint16(len(value)))
+		return append(attrs, value...)
+	}
+	attrs = append(attrs, flags, code, byte(len(value)))
+	return append(attrs, value...)
+}
+
+func parseASPath(value []byte, fourOctetAS bool) []uint32 {
+	asLength := 2
+	if fourOctetAS {
+		asLength = 4
+	}
+	path := []uint32{}
+	for len(value) >= 2 {
+		count := int(value[1])
+		value = value[2:]
+		for i := 0; i < count && len(value) >= asLength; i++ {
+			if fourOctetAS {
+				path = append(path, binary.BigEndian.Uint32(value))
+			} else {
+				path = append(path, uint32(binary.BigEndian.Uint16(value)))
+			}
+			value = value[asLength:]
+		}
+	}
+	return path
+}
+
+func marshalPrefixes(prefixes []*net.IPNet) []byte {
+	encoded := []byte{}
+	for _, prefix := range prefixes {
+		ones, _ := prefix.Mask.Size()
+		encoded = append(encoded, byte(ones))
+		encoded = append(encoded, prefix.IP.To4()[:(ones+7)/8]...)
+	}
+	return encoded
+}
+
+func parsePrefixes(encoded []byte) ([]*net.IPNet, error) {
+	prefixes := []*net.IPNet{}
+	for len(encoded) > 0 {
+		ones := int(encoded[0])
+		octets := (ones + 7) / 8
+		if ones > 32 || len(encoded) < 1+octets {
+			return nil, errors.New("invalid prefix")
+		}
+		ip := make(net.IP, 4)
+		copy(ip, encoded[1:1+octets])
+		prefixes = append(prefixes, &net.IPNet{IP: ip, Mask: net.CIDRMask(ones, 32)})
+		encoded = encoded[1+octets:]
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,225 @@
+package bgp_test
+
+import (
+	"bytes"
+	"net"
+
+	"code.cloudfoundry.org/silk/daemon/bgp"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Messages", func() {
+	mustParseCIDR := func(cidr string) *net.IPNet {
+		_, network, err := net.ParseCIDR(cidr)
+		Expect(err).NotTo(HaveOccurred())
+		return network
+	}
+
+	It("writes and reads a message with its header", func() {
+		buffer := &bytes.Buffer{}
+		Expect(bgp.WriteMessage(buffer, bgp.MsgKeepalive, nil)).To(Succeed())
+		Expect(buffer.Len()).To(Equal(19))
+
+		message, err := bgp.ReadMessage(buffer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(Equal(bgp.Message{Type: bgp.MsgKeepalive, Body: []byte{}}))
+	})
+
+	It("rejects a message with an invalid marker", func() {
+		_, err := bgp.ReadMessage(bytes.NewReader(make([]byte, 19)))
+		Expect(err).To(MatchError("invalid marker"))
+	})
+
+	It("marshals and parses an OPEN message with a four-octet AS", func() {
+		open := bgp.OpenMessage{AS: 4200000001, HoldTime: 90, RouterID: net.ParseIP("10.0.0.1").To4(), FourOctetAS: true}
+
+		parsed, err := bgp.ParseOpen(open.Marshal())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(open))
+	})
+
+	It("parses an OPEN message without capabilities", func() {
+		open := []byte{4, 0xfd, 0xe8, 0, 90, 10, 0, 0, 2, 0}
+
+		parsed, err := bgp.ParseOpen(open)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.AS).To(Equal(uint32(65000)))
+		Expect(parsed.FourOctetAS).To(BeFalse())
+	})
+
+	It("marshals and parses an UPDATE message", func() {
+		update := bgp.UpdateMessage{
+			Withdrawn: []*net.IPNet{mustParseCIDR("10.255.7.0/24")},
+			ASPath:    []uint32{65001},
+			NextHop:   net.ParseIP("10.0.0.1").To4(),
+			NLRI:      []*net.IPNet{mustParseCIDR("10.255.8.0/24"), mustParseCIDR("10.128.0.0/9")},
+		}
+
+		for _, fourOctetAS := range []bool{true, false} {
+			parsed, err := bgp.ParseUpdate(update.Marshal(fourOctetAS), fourOctetAS)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(update))
+		}
+	})
+
+	It("rejects a message longer than the maximum length", func() {
+		header := append(bytes.Repeat([]byte{0xff}, 16), 0x10, 0x01, 2)
+		_, err := bgp.ReadMessage(bytes.NewReader(header))
+		Expect(err).To(MatchError("invalid length 4097"))
+	})
+
+	It("reads messages that arrive back to back", func() {
+		buffer := &bytes.Buffer{}
+		Expect(bgp.WriteMessage(buffer, bgp.MsgKeepalive, nil)).To(Succeed())
+		Expect(bgp.WriteMessage(buffer, bgp.MsgNotification, []byte{6, 2})).To(Succeed())
+
+		message, err := bgp.ReadMessage(buffer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message.Type).To(Equal(bgp.MsgKeepalive))
+		message, err = bgp.ReadMessage(buffer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(Equal(bgp.Message{Type: bgp.MsgNotification, Body: []byte{6, 2}}))
+	})
+
+	Describe("the encoding on the wire", func() {
+		marker := bytes.Repeat([]byte{0xff}, 16)
+
+		It("writes an OPEN message with the multiprotocol and four-octet AS capabilities", func() {
+			open := bgp.OpenMessage{AS: 65001, HoldTime: 90, RouterID: net.ParseIP("10.0.0.1").To4(), FourOctetAS: true}
+			buffer := &bytes.Buffer{}
+			Expect(bgp.WriteMessage(buffer, bgp.MsgOpen, open.Marshal())).To(Succeed())
+
+			Expect(buffer.Bytes()).To(Equal(concat(
+				marker, []byte{0x00, 0x2b, 0x01},
+				[]byte{0x04, 0xfd, 0xe9, 0x00, 0x5a, 0x0a, 0x00, 0x00, 0x01},
+				[]byte{0x0e, 0x02, 0x0c},
+				[]byte{0x01, 0x04, 0x00, 0x01, 0x00, 0x01},
+				[]byte{0x41, 0x04, 0x00, 0x00, 0xfd, 0xe9},
+			)))
+		})
+
+		It("writes AS_TRANS in the OPEN message for a four-octet AS", func() {
+			open := bgp.OpenMessage{AS: 4200000001, HoldTime: 90, RouterID: net.ParseIP("10.0.0.1").To4(), FourOctetAS: true}
+
+			Expect(open.Marshal()).To(Equal(concat(
+				[]byte{0x04, 0x5b, 0xa0, 0x00, 0x5a, 0x0a, 0x00, 0x00, 0x01},
+				[]byte{0x0e, 0x02, 0x0c},
+				[]byte{0x01, 0x04, 0x00, 0x01, 0x00, 0x01},
+				[]byte{0x41, 0x04, 0xfa, 0x56, 0xea, 0x01},
+			)))
+		})
+
+		It("parses an OPEN message with each capability in its own optional parameter", func() {
+			open := concat(
+				[]byte{0x04, 0x5b, 0xa0, 0x00, 0xb4, 0x0a, 0x00, 0x00, 0xfe, 0x2a},
+				[]byte{0x02, 0x06, 0x01, 0x04, 0x00, 0x01, 0x00, 0x01},
+				[]byte{0x02, 0x02, 0x80, 0x00},
+				[]byte{0x02, 0x02, 0x02, 0x00},
+				[]byte{0x02, 0x06, 0x41, 0x04, 0xfa, 0x56, 0xea, 0x00},
+				[]byte{0x02, 0x06, 0x45, 0x04, 0x00, 0x01, 0x01, 0x01},
+				[]byte{0x02, 0x02, 0x46, 0x00},
+				[]byte{0x02, 0x04, 0x40, 0x02, 0x00, 0x78},
+			)
+
+			parsed, err := bgp.ParseOpen(open)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(bgp.OpenMessage{
+				AS:          4200000000,
+				HoldTime:    180,
+				RouterID:    net.ParseIP("10.0.0.254").To4(),
+				FourOctetAS: true,
+			}))
+		})
+
+		It("rejects an OPEN message with a truncated capability", func() {
+			open := []byte{0x04, 0xfd, 0xe8, 0x00, 0xb4, 0x0a, 0x00, 0x00, 0xfe, 0x04, 0x02, 0x02, 0x41, 0x04}
+
+			_, err := bgp.ParseOpen(open)
+			Expect(err).To(MatchError("invalid capability"))
+		})
+
+		It("writes an UPDATE message for an external peer", func() {
+			update := bgp.UpdateMessage{
+				ASPath:  []uint32{65001},
+				NextHop: net.ParseIP("10.0.0.1").To4(),
+				NLRI:    []*net.IPNet{mustParseCIDR("10.255.8.0/24")},
+			}
+
+			Expect(update.Marshal(true)).To(Equal(concat(
+				[]byte{0x00, 0x00, 0x00, 0x14},
+				[]byte{0x40, 0x01, 0x01, 0x00},
+				[]byte{0x40, 0x02, 0x06, 0x02, 0x01, 0x00, 0x00, 0xfd, 0xe9},
+				[]byte{0x40, 0x03, 0x04, 0x0a, 0x00, 0x00, 0x01},
+				[]byte{0x18, 0x0a, 0xff, 0x08},
+			)))
+			Expect(update.Marshal(false)).To(Equal(concat(
+				[]byte{0x00, 0x00, 0x00, 0x12},
+				[]byte{0x40, 0x01, 0x01, 0x00},
+				[]byte{0x40, 0x02, 0x04, 0x02, 0x01, 0xfd, 0xe9},
+				[]byte{0x40, 0x03, 0x04, 0x0a, 0x00, 0x00, 0x01},
+				[]byte{0x18, 0x0a, 0xff, 0x08},
+			)))
+		})
+
+		It("writes an UPDATE message for an internal peer", func() {
+			update := bgp.UpdateMessage{
+				NextHop:   net.ParseIP("10.0.0.1").To4(),
+				LocalPref: 100,
+				NLRI:      []*net.IPNet{mustParseCIDR("10.255.8.0/24")},
+			}
+
+			Expect(update.Marshal(true)).To(Equal(concat(
+				[]byte{0x00, 0x00, 0x00, 0x15},
+				[]byte{0x40, 0x01, 0x01, 0x00},
+				[]byte{0x40, 0x02, 0x00},
+				[]byte{0x40, 0x03, 0x04, 0x0a, 0x00, 0x00, 0x01},
+				[]byte{0x40, 0x05, 0x04, 0x00, 0x00, 0x00, 0x64},
+				[]byte{0x18, 0x0a, 0xff, 0x08},
+			)))
+		})
+
+		It("writes an UPDATE message that only withdraws routes", func() {
+			update := bgp.UpdateMessage{Withdrawn: []*net.IPNet{mustParseCIDR("10.255.8.0/24")}}
+
+			Expect(update.Marshal(true)).To(Equal([]byte{0x00, 0x04, 0x18, 0x0a, 0xff, 0x08, 0x00, 0x00}))
+		})
+
+		It("parses an UPDATE message with attributes it does not use", func() {
+			update := concat(
+				[]byte{0x00, 0x00, 0x00, 0x3c},
+				[]byte{0x40, 0x01, 0x01, 0x00},
+				[]byte{0x40, 0x02, 0x10, 0x02, 0x02, 0x00, 0x00, 0xfd, 0xe8, 0x00, 0x00, 0xfe, 0x4c, 0x01, 0x01, 0x00, 0x00, 0xff, 0x00},
+				[]byte{0x40, 0x03, 0x04, 0x0a, 0x00, 0x10, 0x01},
+				[]byte{0x80, 0x04, 0x04, 0x00, 0x00, 0x00, 0x00},
+				[]byte{0xc0, 0x08, 0x04, 0xfd, 0xe8, 0x00, 0x64},
+				[]byte{0xd0, 0x20, 0x00, 0x0c, 0x00, 0x00, 0xfd, 0xe8, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02},
+				[]byte{0x18, 0x0a, 0xff, 0x08, 0x09, 0x0a, 0x80},
+			)
+
+			parsed, err := bgp.ParseUpdate(update, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.ASPath).To(Equal([]uint32{65000, 65100, 65280}))
+			Expect(parsed.NextHop.Equal(net.ParseIP("10.0.16.1"))).To(BeTrue())
+			Expect(parsed.NLRI).To(Equal([]*net.IPNet{mustParseCIDR("10.255.8.0/24"), mustParseCIDR("10.128.0.0/9")}))
+		})
+
+		It("rejects an UPDATE message with a prefix longer than 32 bits", func() {
+			_, err := bgp.ParseUpdate([]byte{0x00, 0x00, 0x00, 0x00, 0x21, 0x0a, 0xff, 0x08, 0x00, 0x00}, true)
+			Expect(err).To(MatchError("invalid prefix"))
+		})
+	})
+
+	It("marshals and parses a NOTIFICATION message", func() {
+		notification := bgp.NotificationMessage{Code: 6, Subcode: 2, Data: []byte{}}
+
+		parsed, err := bgp.ParseNotification(notification.Marshal())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(notification))
+		Expect(parsed.Error()).To(Equal("notification code 6 subcode 2"))
+	})
+})
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
@@ -0,0 +1,289 @@
+package bgp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+//go:generate counterfeiter -o fakes/healthChecker.go --fake-name HealthChecker . healthChecker
+type healthChecker interface {
+	Healthy() bool
+}
+
+const (
+	DefaultPort         = 179
+	healthCheckInterval = time.Second
+	writeTimeout        = 10 * time.Second
+	localPref           = 100
+)
+
+var errStopped = errors.New("stopped")
+
+// Peer is a router the speaker opens a session with, e.g. a top of rack
+// switch.
+type Peer struct {
+	Address string
+	Port    int
+	AS      uint32
+}
+
+func (p Peer) external(localAS uint32) bool {
+	return p.AS != localAS
+}
+
+// Speaker announces the Prefixes of the cell to its BGP peers, with the
+// NextHop as the next hop, while the Health is healthy, and withdraws them
+// when it is not. It does not install the routes the peers announce, since
+// the cells reach each other over the default route of the underlay.
+type Speaker struct {
+	Logger       lager.Logger
+	LocalAS      uint32
+	RouterID     net.IP
+	NextHop      net.IP
+	Prefixes     []*net.IPNet
+	Peers        []Peer
+	HoldTime     time.Duration
+	ConnectRetry time.Duration
+	Health       healthChecker
+}
+
+func (s *Speaker) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, peer := range s.Peers {
+		wg.Add(1)
+		go func(peer Peer) {
+			defer wg.Done()
+			s.runPeer(peer, stop)
+		}(peer)
+	}
+
+	<-signals
+	close(stop)
+	wg.Wait()
+	return nil
+}
+
+// runPeer opens the session with a peer again after it fails, until the
+// speaker stops.
+func (s *Speaker) runPeer(peer Peer, stop <-chan struct{}) {
+	logger := s.Logger.Session("bgp-peer", lager.Data{"address": peer.Address, "as": peer.AS})
+	for {
+		err := s.runSession(logger, peer, stop)
+		if err == errStopped {
+			return
+		}
+		logger.Error("session", err)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(s.ConnectRetry):
+		}
+	}
+}
+
+func (s *Speaker) runSession(logger lager.Logger, peer Peer, stop <-chan struct{}) error {
+	address := net.JoinHostPort(peer.Address, strconv.Itoa(peer.Port))
+	conn, err := net.DialTimeout("tcp", address, s.ConnectRetry)
+	if err != nil {
+		return fmt.Errorf("connect: %s", err)
+	}
+	defer conn.Close()
+
+	holdTime, fourOctetAS, err := s.openSession(conn, peer)
+	if err != nil {
+		return err
+	}
+	logger.Info("established", lager.Data{"hold-time": holdTime.String()})
+
+	messages := make(chan Message)
+	readErrors := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			if holdTime > 0 {
+				conn.SetReadDeadline(time.Now().Add(holdTime))
+			}
+			message, err := ReadMessage(conn)
+			if err != nil {
+				readErrors <- err
+				return
+			}
+			select {
+			case messages <- message:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var keepalives <-chan time.Time
+	if holdTime > 0 {
+		keepaliveTicker := time.NewTicker(holdTime / 3)
+		defer keepaliveTicker.Stop()
+		keepalives = keepaliveTicker.C
+	}
+	healthTicker := time.NewTicker(healthCheckInterval)
+	defer healthTicker.Stop()
+
+	announced := false
+	announce := func() error {
+		healthy := s.Health.Healthy()
+		if healthy == announced {
+			return nil
+		}
+		update := UpdateMessage{Withdrawn: s.Prefixes}
+		if healthy {
+			update = s.announcement(peer)
+		}
+		err := write(conn, MsgUpdate, update.Marshal(fourOctetAS))
+		if err != nil {
+			return fmt.Errorf("update: %s", err)
+		}
+		announced = healthy
+		logger.Info("updated", lager.Data{"announced": announced})
+		return nil
+	}
+
+	err = announce()
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stop:
+			cease := NotificationMessage{Code: notificationCease, Subcode: subcodeAdminShutdown}
+			write(conn, MsgNotification, cease.Marshal())
+			return errStopped
+		case message := <-messages:
+			if message.Type == MsgNotification {
+				notification, err := ParseNotification(message.Body)
+				if err != nil {
+					return err
+				}
+				return fmt.Errorf("peer closed session: %s", notification)
+			}
+		case err := <-readErrors:
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				hold := NotificationMessage{Code: notificationHoldTimer}
+				write(conn, MsgNotification, hold.Marshal())
+				return errors.New("hold timer expired")
+			}
+			return fmt.Errorf("read: %s", err)
+		case <-keepalives:
+			err := write(conn, MsgKeepalive, nil)
+			if err != nil {
+				return fmt.Errorf("keepalive: %s", err)
+			}
+		case <-healthTicker.C:
+			err := announce()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// openSession exchanges the OPEN messages and the first KEEPALIVE messages
+// with the peer, and returns the hold time both sides agreed on and whether
+// the peer understands four-octet AS numbers.
+func (s *Speaker) openSession(conn net.Conn, peer Peer) (time.Duration, bool, error) {
+	open := OpenMessage{
+		AS:          s.LocalAS,
+		HoldTime:    uint16(s.HoldTime / time.Second),
+		RouterID:    s.RouterID,
+		FourOctetAS: true,
+	}
+	err := write(conn, MsgOpen, open.Marshal())
+	if err != nil {
+		return 0, false, fmt.Errorf("open: %s", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(s.HoldTime + writeTimeout))
+	message, err := readExpected(conn, MsgOpen)
+	if err != nil {
+		return 0, false, err
+	}
+	peerOpen, err := ParseOpen(message.Body)
+	if err != nil {
+		return 0, false, fmt.Errorf("parse open: %s", err)
+	}
+	if peerOpen.AS != peer.AS {
+		badPeerAS := NotificationMessage{Code: notificationOpenError, Subcode: subcodeBadPeerAS}
+		write(conn, MsgNotification, badPeerAS.Marshal())
+		return 0, false, fmt.Errorf("peer has AS %d, expected %d", peerOpen.AS, peer.AS)
+	}
+	if !peerOpen.FourOctetAS && s.LocalAS > 0xffff {
+		unsupported := NotificationMessage{Code: notificationOpenError, Subcode: subcodeUnsupportedOption}
+		write(conn, MsgNotification, unsupported.Marshal())
+		return 0, false, fmt.Errorf("peer does not support the four-octet AS %d", s.LocalAS)
+	}
+
+	// RFC 4271 only allows a hold time of zero, which turns off the
+	// keepalives, or of at least three seconds
+	if peerOpen.HoldTime == 1 || peerOpen.HoldTime == 2 {
+		unacceptable := NotificationMessage{Code: notificationOpenError, Subcode: subcodeUnacceptableHoldTime}
+		write(conn, MsgNotification, unacceptable.Marshal())
+		return 0, false, fmt.Errorf("peer has unacceptable hold time %ds", peerOpen.HoldTime)
+	}
+
+	holdTime := s.HoldTime
+	if peerHoldTime := time.Duration(peerOpen.HoldTime) * time.Second; peerHoldTime < holdTime {
+		holdTime = peerHoldTime
+	}
+	err = write(conn, MsgKeepalive, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("keepalive: %s", err)
+	}
+	_, err = readExpected(conn, MsgKeepalive)
+	if err != nil {
+		return 0, false, err
+	}
+	return holdTime, peerOpen.FourOctetAS, nil
+}
+
+// announcement is the UPDATE message with the prefixes of the cell. External
+// peers get the local AS in the AS path, internal ones the local preference.
+func (s *Speaker) announcement(peer Peer) UpdateMessage {
+	update := UpdateMessage{NextHop: s.NextHop, NLRI: s.Prefixes}
+	if peer.external(s.LocalAS) {
+		update.ASPath = []uint32{s.LocalAS}
+	} else {
+		update.LocalPref = localPref
+	}
+	return update
+}
+
+func readExpected(conn net.Conn, msgType MessageType) (Message, error) {
+	message, err := ReadMessage(conn)
+	if err != nil {
+		return Message{}, fmt.Errorf("read: %s", err)
+	}
+	if message.Type == MsgNotification {
+		notification, err := ParseNotification(message.Body)
+		if err != nil {
+			return Message{}, err
+		}
+		return Message{}, fmt.Errorf("peer closed session: %s", notification)
+	}
+	if message.Type != msgType {
+		return Message{}, fmt.Errorf("expected message type %d, got %d", msgType, message.Type)
+	}
+	return message, nil
+}
+
+func write(conn net.Conn, msgType MessageType, body []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return WriteMessage(conn, msgType, body)
+}
@@ -0,0 +1,248 @@
+package bgp_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/daemon/bgp"
+	"code.cloudfoundry.org/silk/daemon/bgp/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Speaker", func() {
+	var (
+		listener net.Listener
+		health   *fakes.HealthChecker
+		logger   *lagertest.TestLogger
+		speaker  *bgp.Speaker
+		process  ifrit.Process
+		prefix   *net.IPNet
+	)
+
+	readMessage := func(conn net.Conn) bgp.Message {
+		for {
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			message, err := bgp.ReadMessage(conn)
+			Expect(err).NotTo(HaveOccurred())
+			if message.Type != bgp.MsgKeepalive {
+				return message
+			}
+		}
+	}
+
+	acceptSessionWithHoldTime := func(peerAS uint32, holdTime uint16) net.Conn {
+		conn, err := listener.Accept()
+		Expect(err).NotTo(HaveOccurred())
+
+		message := readMessage(conn)
+		Expect(message.Type).To(Equal(bgp.MsgOpen))
+		open, err := bgp.ParseOpen(message.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(open).To(Equal(bgp.OpenMessage{AS: 65001, HoldTime: 90, RouterID: net.ParseIP("10.0.0.1").To4(), FourOctetAS: true}))
+
+		peerOpen := bgp.OpenMessage{AS: peerAS, HoldTime: holdTime, RouterID: net.ParseIP("10.0.0.254").To4(), FourOctetAS: true}
+		Expect(bgp.WriteMessage(conn, bgp.MsgOpen, peerOpen.Marshal())).To(Succeed())
+		Expect(bgp.WriteMessage(conn, bgp.MsgKeepalive, nil)).To(Succeed())
+		return conn
+	}
+
+	acceptSession := func(peerAS uint32) net.Conn {
+		return acceptSessionWithHoldTime(peerAS, 90)
+	}
+
+	readUpdate := func(conn net.Conn) bgp.UpdateMessage {
+		message := readMessage(conn)
+		Expect(message.Type).To(Equal(bgp.MsgUpdate))
+		update, err := bgp.ParseUpdate(message.Body, true)
+		Expect(err).NotTo(HaveOccurred())
+		return update
+	}
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		port := listener.Addr().(*net.TCPAddr).Port
+
+		_, prefix, err = net.ParseCIDR("10.255.8.0/24")
+		Expect(err).NotTo(HaveOccurred())
+		health = &fakes.HealthChecker{}
+		health.HealthyReturns(true)
+		logger = lagertest.NewTestLogger("test")
+		speaker = &bgp.Speaker{
+			Logger:       logger,
+			LocalAS:      65001,
+			RouterID:     net.ParseIP("10.0.0.1"),
+			NextHop:      net.ParseIP("10.0.0.1"),
+			Prefixes:     []*net.IPNet{prefix},
+			Peers:        []bgp.Peer{{Address: "127.0.0.1", Port: port, AS: 65000}},
+			HoldTime:     90 * time.Second,
+			ConnectRetry: 100 * time.Millisecond,
+			Health:       health,
+		}
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(speaker)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		listener.Close()
+	})
+
+	It("announces the prefixes to an external peer with the local AS in the path", func() {
+		conn := acceptSession(65000)
+		defer conn.Close()
+
+		update := readUpdate(conn)
+		Expect(update.NLRI).To(Equal([]*net.IPNet{prefix}))
+		Expect(update.ASPath).To(Equal([]uint32{65001}))
+		Expect(update.NextHop.Equal(net.ParseIP("10.0.0.1"))).To(BeTrue())
+		Expect(update.LocalPref).To(BeZero())
+		Eventually(logger).Should(gbytes.Say("established"))
+	})
+
+	It("withdraws the prefixes when the cell becomes unhealthy and announces them again when it recovers", func() {
+		conn := acceptSession(65000)
+		defer conn.Close()
+		Expect(readUpdate(conn).NLRI).To(HaveLen(1))
+
+		health.HealthyReturns(false)
+		update := readUpdate(conn)
+		Expect(update.Withdrawn).To(Equal([]*net.IPNet{prefix}))
+		Expect(update.NLRI).To(BeEmpty())
+
+		health.HealthyReturns(true)
+		Expect(readUpdate(conn).NLRI).To(Equal([]*net.IPNet{prefix}))
+	})
+
+	Context("when the cell is not healthy when the session opens", func() {
+		BeforeEach(func() {
+			health.HealthyReturns(false)
+		})
+
+		It("does not announce the prefixes", func() {
+			conn := acceptSession(65000)
+			defer conn.Close()
+
+			Consistently(func() int { return health.HealthyCallCount() }, 1500*time.Millisecond).Should(BeNumerically("<", 3))
+			health.HealthyReturns(true)
+			Expect(readUpdate(conn).NLRI).To(HaveLen(1))
+		})
+	})
+
+	Context("when the peer is internal", func() {
+		BeforeEach(func() {
+			speaker.Peers[0].AS = 65001
+		})
+
+		It("announces the prefixes with the local preference and an empty path", func() {
+			conn := acceptSession(65001)
+			defer conn.Close()
+
+			update := readUpdate(conn)
+			Expect(update.ASPath).To(BeEmpty())
+			Expect(update.LocalPref).To(Equal(uint32(100)))
+		})
+	})
+
+	It("sends a cease notification when it stops", func() {
+		conn := acceptSession(65000)
+		defer conn.Close()
+		readUpdate(conn)
+
+		process.Signal(os.Interrupt)
+		message := readMessage(conn)
+		Expect(message.Type).To(Equal(bgp.MsgNotification))
+		notification, err := bgp.ParseNotification(message.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notification.Code).To(Equal(uint8(6)))
+		Expect(notification.Subcode).To(Equal(uint8(2)))
+	})
+
+	Context("when the peer has another AS than configured", func() {
+		It("closes the session and connects again", func() {
+			conn := acceptSession(65099)
+			defer conn.Close()
+
+			message := readMessage(conn)
+			Expect(message.Type).To(Equal(bgp.MsgNotification))
+			Expect(message.Body[:2]).To(Equal([]byte{2, 2}))
+			Eventually(logger).Should(gbytes.Say("peer has AS 65099, expected 65000"))
+
+			retried := acceptSession(65000)
+			defer retried.Close()
+			Expect(readUpdate(retried).NLRI).To(HaveLen(1))
+		})
+	})
+
+	Context("when the peer has a shorter hold time", func() {
+		It("sends keepalives every third of the peer's hold time", func() {
+			conn := acceptSessionWithHoldTime(65000, 3)
+			defer conn.Close()
+			readUpdate(conn)
+			Eventually(logger).Should(gbytes.Say(`"hold-time":"3s"`))
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			message, err := bgp.ReadMessage(conn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message.Type).To(Equal(bgp.MsgKeepalive))
+		})
+	})
+
+	Context("when the peer turns off the hold timer", func() {
+		It("does not send keepalives", func() {
+			conn := acceptSessionWithHoldTime(65000, 0)
+			defer conn.Close()
+			readUpdate(conn)
+			Eventually(logger).Should(gbytes.Say(`"hold-time":"0s"`))
+
+			conn.SetReadDeadline(time.Now().Add(1500 * time.Millisecond))
+			_, err := bgp.ReadMessage(conn)
+			Expect(err).To(BeAssignableToTypeOf(&net.OpError{}))
+			Expect(err.(net.Error).Timeout()).To(BeTrue())
+		})
+	})
+
+	for _, holdTime := range []uint16{1, 2} {
+		holdTime := holdTime
+		Context(fmt.Sprintf("when the peer has a hold time of %ds", holdTime), func() {
+			It("closes the session with an unacceptable hold time notification", func() {
+				conn := acceptSessionWithHoldTime(65000, holdTime)
+				defer conn.Close()
+
+				message := readMessage(conn)
+				Expect(message.Type).To(Equal(bgp.MsgNotification))
+				Expect(message.Body[:2]).To(Equal([]byte{2, 6}))
+				Eventually(logger).Should(gbytes.Say(fmt.Sprintf("peer has unacceptable hold time %ds", holdTime)))
+
+				retried := acceptSession(65000)
+				defer retried.Close()
+				Expect(readUpdate(retried).NLRI).To(HaveLen(1))
+			})
+		})
+	}
+
+	Context("when the peer closes the session", func() {
+		It("connects again", func() {
+			conn := acceptSession(65000)
+			readUpdate(conn)
+			cease := bgp.NotificationMessage{Code: 6, Subcode: 2}
+			Expect(bgp.WriteMessage(conn, bgp.MsgNotification, cease.Marshal())).To(Succeed())
+			conn.Close()
+
+			retried := acceptSession(65000)
+			defer retried.Close()
+			Expect(readUpdate(retried).NLRI).To(HaveLen(1))
+			Expect(logger).To(gbytes.Say("peer closed session: notification code 6 subcode 2"))
+		})
+	})
+})
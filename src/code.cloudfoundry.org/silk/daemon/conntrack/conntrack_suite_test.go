@@ -0,0 +1,13 @@
+package conntrack_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestConntrack(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Conntrack Suite")
+}
@@ -0,0 +1,27 @@
+package conntrack_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/silk/daemon/conntrack"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MemTotal", func() {
+	It("reads the memory of the host", func() {
+		memTotal, err := conntrack.MemTotal(strings.NewReader("MemTotal:       16384000 kB\nMemFree:         8192000 kB\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(memTotal).To(Equal(uint64(16384000 * 1024)))
+	})
+
+	It("errors when there is no MemTotal", func() {
+		_, err := conntrack.MemTotal(strings.NewReader("MemFree:         8192000 kB\n"))
+		Expect(err).To(MatchError("no MemTotal in meminfo"))
+	})
+
+	It("errors when the MemTotal is not a number", func() {
+		_, err := conntrack.MemTotal(strings.NewReader("MemTotal:       lots kB\n"))
+		Expect(err).To(MatchError(ContainSubstring("parse MemTotal: ")))
+	})
+})
@@ -0,0 +1,86 @@
+package conntrack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+//go:generate counterfeiter -o fakes/sysctlAdapter.go --fake-name SysctlAdapter . sysctlAdapter
+type sysctlAdapter interface {
+	Sysctl(name string, params ...string) (string, error)
+}
+
+//go:generate counterfeiter -o fakes/metricSender.go --fake-name MetricSender . metricSender
+type metricSender interface {
+	SendValue(name string, value float64, units string)
+	IncrementCounter(name string)
+}
+
+// Monitor reports the utilization of the table. A table that is nearly full
+// is too small for the cell, and a high number of entries per bucket means
+// that the lookups walk long chains because the table has too few buckets.
+// A HighUtilizationPercent of 0 leaves out the alarm on a nearly full table.
+type Monitor struct {
+	SysctlAdapter          sysctlAdapter
+	MetricSender           metricSender
+	Logger                 lager.Logger
+	HighUtilizationPercent float64
+}
+
+func (m *Monitor) Check() error {
+	count, err := m.read(CountSysctl)
+	if os.IsNotExist(err) {
+		// the nf_conntrack module is not loaded, so nothing is tracked
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %s", CountSysctl, err)
+	}
+	max, err := m.read(MaxSysctl)
+	if err != nil {
+		return fmt.Errorf("read %s: %s", MaxSysctl, err)
+	}
+	buckets, err := m.read(BucketsSysctl)
+	if err != nil {
+		return fmt.Errorf("read %s: %s", BucketsSysctl, err)
+	}
+
+	utilization := 0.0
+	if max > 0 {
+		utilization = 100 * float64(count) / float64(max)
+	}
+	entriesPerBucket := 0.0
+	if buckets > 0 {
+		entriesPerBucket = float64(count) / float64(buckets)
+	}
+
+	m.MetricSender.SendValue("conntrackEntries", float64(count), "")
+	m.MetricSender.SendValue("conntrackMax", float64(max), "")
+	m.MetricSender.SendValue("conntrackUtilization", utilization, "")
+	m.MetricSender.SendValue("conntrackEntriesPerBucket", entriesPerBucket, "")
+
+	if m.HighUtilizationPercent > 0 && utilization >= m.HighUtilizationPercent {
+		m.Logger.Info("conntrack-table-nearly-full", lager.Data{
+			"entries":     count,
+			"max":         max,
+			"utilization": utilization,
+		})
+		m.MetricSender.IncrementCounter("conntrackTableNearlyFull")
+	}
+	return nil
+}
+
+func (m *Monitor) read(name string) (int, error) {
+	value, err := m.SysctlAdapter.Sysctl(name)
+	if err != nil {
+		return 0, err
+	}
+	integer, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("parse: %s", err)
+	}
+	return integer, nil
+}
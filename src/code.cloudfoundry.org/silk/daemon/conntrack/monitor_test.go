@@ -0,0 +1,131 @@
+package conntrack_test
+
+import (
+	"errors"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/daemon/conntrack"
+	"code.cloudfoundry.org/silk/daemon/conntrack/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Monitor", func() {
+	var (
+		fakeSysctlAdapter *fakes.SysctlAdapter
+		fakeMetricSender  *fakes.MetricSender
+		logger            *lagertest.TestLogger
+		values            map[string]string
+		monitor           *conntrack.Monitor
+	)
+
+	BeforeEach(func() {
+		values = map[string]string{
+			"net.netfilter.nf_conntrack_count":   "5000",
+			"net.netfilter.nf_conntrack_max":     "20000",
+			"net.netfilter.nf_conntrack_buckets": "5000",
+		}
+		fakeSysctlAdapter = &fakes.SysctlAdapter{}
+		fakeSysctlAdapter.SysctlStub = func(name string, params ...string) (string, error) {
+			value, ok := values[name]
+			if !ok {
+				return "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+			}
+			return value, nil
+		}
+		fakeMetricSender = &fakes.MetricSender{}
+		logger = lagertest.NewTestLogger("test")
+
+		monitor = &conntrack.Monitor{
+			SysctlAdapter:          fakeSysctlAdapter,
+			MetricSender:           fakeMetricSender,
+			Logger:                 logger,
+			HighUtilizationPercent: 90,
+		}
+	})
+
+	metrics := func() map[string]float64 {
+		sent := map[string]float64{}
+		for i := 0; i < fakeMetricSender.SendValueCallCount(); i++ {
+			name, value, _ := fakeMetricSender.SendValueArgsForCall(i)
+			sent[name] = value
+		}
+		return sent
+	}
+
+	It("reports the utilization of the table", func() {
+		Expect(monitor.Check()).To(Succeed())
+
+		Expect(metrics()).To(Equal(map[string]float64{
+			"conntrackEntries":          5000,
+			"conntrackMax":              20000,
+			"conntrackUtilization":      25,
+			"conntrackEntriesPerBucket": 1,
+		}))
+		Expect(fakeMetricSender.IncrementCounterCallCount()).To(Equal(0))
+	})
+
+	Context("when the table is nearly full", func() {
+		BeforeEach(func() {
+			values["net.netfilter.nf_conntrack_count"] = "19000"
+		})
+
+		It("logs and counts it", func() {
+			Expect(monitor.Check()).To(Succeed())
+
+			Expect(logger).To(gbytes.Say(`conntrack-table-nearly-full.*"entries":19000,"max":20000,"utilization":95`))
+			Expect(fakeMetricSender.IncrementCounterCallCount()).To(Equal(1))
+			Expect(fakeMetricSender.IncrementCounterArgsForCall(0)).To(Equal("conntrackTableNearlyFull"))
+		})
+	})
+
+	Context("when there is no high utilization", func() {
+		BeforeEach(func() {
+			values["net.netfilter.nf_conntrack_count"] = "20000"
+			monitor.HighUtilizationPercent = 0
+		})
+
+		It("does not alarm", func() {
+			Expect(monitor.Check()).To(Succeed())
+			Expect(fakeMetricSender.IncrementCounterCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the nf_conntrack module is not loaded", func() {
+		BeforeEach(func() {
+			values = map[string]string{}
+		})
+
+		It("reports nothing", func() {
+			Expect(monitor.Check()).To(Succeed())
+			Expect(fakeMetricSender.SendValueCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a sysctl cannot be read", func() {
+		BeforeEach(func() {
+			fakeSysctlAdapter.SysctlStub = func(name string, params ...string) (string, error) {
+				if name == "net.netfilter.nf_conntrack_max" {
+					return "", errors.New("banana")
+				}
+				return values[name], nil
+			}
+		})
+
+		It("returns an error", func() {
+			Expect(monitor.Check()).To(MatchError("read net.netfilter.nf_conntrack_max: banana"))
+		})
+	})
+
+	Context("when a sysctl is not a number", func() {
+		BeforeEach(func() {
+			values["net.netfilter.nf_conntrack_buckets"] = "many"
+		})
+
+		It("returns an error", func() {
+			Expect(monitor.Check()).To(MatchError(ContainSubstring("read net.netfilter.nf_conntrack_buckets: parse: ")))
+		})
+	})
+})
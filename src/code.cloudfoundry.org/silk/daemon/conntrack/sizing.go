@@ -0,0 +1,55 @@
+// Package conntrack sizes the connection tracking table of the cell and
+// reports how full it is. The kernel sizes the table from the memory of the
+// host alone, which is too small for cells that run many containers with
+// many connections each, and the packets of new connections are dropped
+// once the table is full.
+package conntrack
+
+import (
+	"strconv"
+
+	"code.cloudfoundry.org/silk/daemon/sysctls"
+)
+
+const (
+	MaxSysctl     = "net.netfilter.nf_conntrack_max"
+	BucketsSysctl = "net.netfilter.nf_conntrack_buckets"
+	CountSysctl   = "net.netfilter.nf_conntrack_count"
+
+	gib = 1 << 30
+)
+
+// Sizing is the formula the table is sized with: the larger of
+// EntriesPerGiB for every GiB of memory and EntriesPerContainer for every
+// container the cell is expected to run, with a hash bucket for every
+// EntriesPerBucket entries.
+type Sizing struct {
+	EntriesPerGiB       int
+	EntriesPerContainer int
+	Containers          int
+	EntriesPerBucket    int
+}
+
+func (s Sizing) Max(memoryBytes uint64) int {
+	byMemory := s.EntriesPerGiB * int(memoryBytes/gib)
+	byContainers := s.EntriesPerContainer * s.Containers
+	if byMemory > byContainers {
+		return byMemory
+	}
+	return byContainers
+}
+
+func (s Sizing) Buckets(max int) int {
+	return (max + s.EntriesPerBucket - 1) / s.EntriesPerBucket
+}
+
+// Settings are the minimums of the table size and its buckets, so that a
+// table the operator made larger is kept. They are optional, since the
+// sysctls only exist once the nf_conntrack module is loaded.
+func (s Sizing) Settings(memoryBytes uint64) []sysctls.Setting {
+	max := s.Max(memoryBytes)
+	return []sysctls.Setting{
+		{Name: MaxSysctl, Value: strconv.Itoa(max), Minimum: true, Optional: true},
+		{Name: BucketsSysctl, Value: strconv.Itoa(s.Buckets(max)), Minimum: true, Optional: true},
+	}
+}
@@ -0,0 +1,52 @@
+package conntrack_test
+
+import (
+	"code.cloudfoundry.org/silk/daemon/conntrack"
+	"code.cloudfoundry.org/silk/daemon/sysctls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sizing", func() {
+	var sizing conntrack.Sizing
+
+	BeforeEach(func() {
+		sizing = conntrack.Sizing{
+			EntriesPerGiB:       16384,
+			EntriesPerContainer: 1024,
+			Containers:          254,
+			EntriesPerBucket:    4,
+		}
+	})
+
+	Describe("Max", func() {
+		It("sizes the table by the memory when that is larger", func() {
+			Expect(sizing.Max(64 << 30)).To(Equal(1048576))
+		})
+
+		It("sizes the table by the containers when that is larger", func() {
+			Expect(sizing.Max(8 << 30)).To(Equal(260096))
+		})
+
+		It("counts whole GiB of memory", func() {
+			sizing.Containers = 0
+			Expect(sizing.Max(3<<30 - 1)).To(Equal(32768))
+		})
+	})
+
+	Describe("Buckets", func() {
+		It("rounds up", func() {
+			Expect(sizing.Buckets(260096)).To(Equal(65024))
+			Expect(sizing.Buckets(10)).To(Equal(3))
+		})
+	})
+
+	Describe("Settings", func() {
+		It("sets the minimums of the table size and its buckets", func() {
+			Expect(sizing.Settings(8 << 30)).To(Equal([]sysctls.Setting{
+				{Name: "net.netfilter.nf_conntrack_max", Value: "260096", Minimum: true, Optional: true},
+				{Name: "net.netfilter.nf_conntrack_buckets", Value: "65024", Minimum: true, Optional: true},
+			}))
+		})
+	})
+})
@@ -0,0 +1,341 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	ChainName  = "silk-egress"
+	ChainOwner = "silk-daemon"
+)
+
+//go:generate counterfeiter -o fakes/netlink_adapter.go --fake-name NetlinkAdapter . netlinkAdapter
+type netlinkAdapter interface {
+	RuleAdd(*netlink.Rule) error
+	RuleDel(*netlink.Rule) error
+	RuleList(int) ([]netlink.Rule, error)
+	RouteReplace(*netlink.Route) error
+}
+
+//go:generate counterfeiter -o fakes/chain_owners.go --fake-name ChainOwners . chainOwners
+type chainOwners interface {
+	Claim(owner string, chains ...datastore.OwnedChain) error
+}
+
+//go:generate counterfeiter -o fakes/netout_chain_namer.go --fake-name NetOutChainNamer . netOutChainNamer
+type netOutChainNamer interface {
+	Name(containerHandle string) string
+}
+
+var parentChains = []struct{ table, chain string }{
+	{"mangle", "PREROUTING"},
+	{"nat", "POSTROUTING"},
+	{"filter", "FORWARD"},
+}
+
+// Converger applies egress gateway State to the cell. The gateway with ID i
+// owns fwmark MarkBase+i and routing table TableBase+i, so adding or removing
+// another gateway does not move its traffic to a different mark or table.
+type Converger struct {
+	Logger         lager.Logger
+	IPTables       rules.IPTablesAdapter
+	NetlinkAdapter netlinkAdapter
+	NetOutChains   netOutChainNamer
+	ChainOwners    chainOwners
+	OverlayNetwork *net.IPNet
+	VTEP           net.Interface
+	MarkBase       int
+	TableBase      int
+	RulePriority   int
+
+	applied      *State
+	appliedRules map[string][]rules.IPTablesRule
+}
+
+func (c *Converger) Converge(state State) error {
+	err := c.ensureChains()
+	if err != nil {
+		return err
+	}
+
+	tableRules, err := c.iptablesRules(state)
+	if err != nil {
+		return err
+	}
+
+	if c.applied != nil && reflect.DeepEqual(*c.applied, state) && reflect.DeepEqual(c.appliedRules, tableRules) {
+		return nil
+	}
+
+	err = c.convergeRouting(state)
+	if err != nil {
+		return err
+	}
+
+	// the chains are replaced with a single iptables-restore, so that no
+	// packet sees a chain that is flushed or only partly written
+	var specs []rules.ChainSpec
+	for _, parent := range parentChains {
+		specs = append(specs, rules.ChainSpec{
+			Table: parent.table,
+			Chain: ChainName,
+			Rules: tableRules[parent.table],
+		})
+	}
+	err = c.IPTables.ReplaceChains(specs...)
+	if err != nil {
+		return fmt.Errorf("replace chains %s: %s", ChainName, err)
+	}
+
+	c.Logger.Info("egress-gateways-converged", lager.Data{"routes": len(state.Routes), "gateway": state.Gateway != nil})
+	c.applied = &state
+	c.appliedRules = tableRules
+	return nil
+}
+
+// ensureChains keeps the jump to ChainName first in each parent chain so
+// that no other FORWARD rule can accept gateway-bound traffic before the
+// container's ASGs are applied.
+//
+// When ChainOwners is set, the head of each parent chain is claimed first.
+// If another component owns the head, the jump is only added below it.
+func (c *Converger) ensureChains() error {
+	jump := rules.IPTablesRule{"-j", ChainName}
+	ownsHead, err := c.claimChains()
+	if err != nil {
+		return err
+	}
+
+	for _, parent := range parentChains {
+		chains, err := c.IPTables.ListChains(parent.table)
+		if err != nil {
+			return fmt.Errorf("list chains %s: %s", parent.table, err)
+		}
+		if !contains(chains, ChainName) {
+			err = c.IPTables.NewChain(parent.table, ChainName)
+			if err != nil {
+				return fmt.Errorf("create chain %s/%s: %s", parent.table, ChainName, err)
+			}
+		}
+
+		parentRules, err := c.IPTables.List(parent.table, parent.chain)
+		if err != nil {
+			return fmt.Errorf("list %s/%s: %s", parent.table, parent.chain, err)
+		}
+
+		expectedFirstRule := fmt.Sprintf("-A %s -j %s", parent.chain, ChainName)
+		if firstAppendedRule(parentRules) == expectedFirstRule {
+			continue
+		}
+
+		if !ownsHead {
+			if contains(parentRules, expectedFirstRule) {
+				continue
+			}
+			pos := 1
+			if firstAppendedRule(parentRules) != "" {
+				pos = 2
+			}
+			err = c.IPTables.BulkInsert(parent.table, parent.chain, pos, jump)
+			if err != nil {
+				return fmt.Errorf("insert jump %s/%s: %s", parent.table, parent.chain, err)
+			}
+			continue
+		}
+
+		if contains(parentRules, expectedFirstRule) {
+			err = c.IPTables.Delete(parent.table, parent.chain, jump)
+			if err != nil {
+				return fmt.Errorf("delete jump %s/%s: %s", parent.table, parent.chain, err)
+			}
+		}
+		err = c.IPTables.BulkInsert(parent.table, parent.chain, 1, jump)
+		if err != nil {
+			return fmt.Errorf("insert jump %s/%s: %s", parent.table, parent.chain, err)
+		}
+	}
+	return nil
+}
+
+func (c *Converger) claimChains() (bool, error) {
+	if c.ChainOwners == nil {
+		return true, nil
+	}
+
+	var owned []datastore.OwnedChain
+	for _, parent := range parentChains {
+		owned = append(owned, datastore.OwnedChain{Table: parent.table, Chain: ChainName})
+	}
+	err := c.ChainOwners.Claim(ChainOwner, owned...)
+	if err != nil {
+		return false, fmt.Errorf("claim chains: %s", err)
+	}
+
+	var heads []datastore.OwnedChain
+	for _, parent := range parentChains {
+		heads = append(heads, datastore.OwnedChain{Table: parent.table, Chain: parent.chain, Jump: ChainName})
+	}
+	err = c.ChainOwners.Claim(ChainOwner, heads...)
+	if err != nil {
+		if _, ok := err.(*datastore.ChainOwnedError); ok {
+			c.Logger.Info("parent-chains-owned-by-other", lager.Data{"error": err.Error()})
+			return false, nil
+		}
+		return false, fmt.Errorf("claim parent chains: %s", err)
+	}
+	return true, nil
+}
+
+// convergeRouting does not remove routes from tables that are no longer
+// referenced; without an ip rule pointing at them they carry no traffic.
+func (c *Converger) convergeRouting(state State) error {
+	desired := map[int]bool{}
+	for _, route := range state.Routes {
+		table := c.TableBase + route.ID
+		desired[table] = true
+
+		r := &netlink.Route{Table: table}
+		if route.GatewayOverlayIP == "" {
+			r.Type = syscall.RTN_BLACKHOLE
+		} else {
+			r.LinkIndex = c.VTEP.Index
+			r.Gw = net.ParseIP(route.GatewayOverlayIP)
+			r.Flags = int(netlink.FLAG_ONLINK)
+		}
+		err := c.NetlinkAdapter.RouteReplace(r)
+		if err != nil {
+			return fmt.Errorf("replace route in table %d: %s", table, err)
+		}
+	}
+
+	existing, err := c.NetlinkAdapter.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		return fmt.Errorf("list ip rules: %s", err)
+	}
+
+	present := map[int]bool{}
+	for _, rule := range existing {
+		if rule.Priority != c.RulePriority {
+			continue
+		}
+		if desired[rule.Table] && rule.Mark == c.MarkBase+(rule.Table-c.TableBase) {
+			present[rule.Table] = true
+			continue
+		}
+		rule := rule
+		err = c.NetlinkAdapter.RuleDel(&rule)
+		if err != nil {
+			return fmt.Errorf("delete ip rule: %s", err)
+		}
+	}
+
+	for _, route := range state.Routes {
+		table := c.TableBase + route.ID
+		if present[table] {
+			continue
+		}
+		rule := netlink.NewRule()
+		rule.Priority = c.RulePriority
+		rule.Table = table
+		rule.Mark = c.MarkBase + route.ID
+		err = c.NetlinkAdapter.RuleAdd(rule)
+		if err != nil {
+			return fmt.Errorf("add ip rule: %s", err)
+		}
+	}
+	return nil
+}
+
+func (c *Converger) iptablesRules(state State) (map[string][]rules.IPTablesRule, error) {
+	overlay := c.OverlayNetwork.String()
+	tableRules := map[string][]rules.IPTablesRule{}
+
+	if len(state.Routes) > 0 {
+		filterChains, err := c.IPTables.ListChains("filter")
+		if err != nil {
+			return nil, fmt.Errorf("list chains filter: %s", err)
+		}
+
+		for _, route := range state.Routes {
+			mark := fmt.Sprintf("0x%x/0xffffffff", c.MarkBase+route.ID)
+			for _, source := range route.Sources {
+				tableRules["mangle"] = append(tableRules["mangle"], rules.IPTablesRule{
+					"-s", source.IP, "!", "-d", overlay,
+					"-j", "MARK", "--set-xmark", mark,
+				})
+
+				netOutChain := c.NetOutChains.Name(source.Handle)
+				if !contains(filterChains, netOutChain) {
+					c.Logger.Info("missing-netout-chain", lager.Data{"handle": source.Handle, "chain": netOutChain})
+					tableRules["filter"] = append(tableRules["filter"], rules.IPTablesRule{
+						"-s", source.IP, "-o", c.VTEP.Name, "!", "-d", overlay,
+						"-j", "REJECT", "--reject-with", "icmp-port-unreachable",
+					})
+					continue
+				}
+				tableRules["filter"] = append(tableRules["filter"], rules.IPTablesRule{
+					"-s", source.IP, "-o", c.VTEP.Name, "!", "-d", overlay,
+					"-j", netOutChain,
+				})
+			}
+		}
+	}
+
+	if state.Gateway != nil {
+		mark := fmt.Sprintf("0x%x", c.MarkBase+state.Gateway.ID)
+		tableRules["mangle"] = append(tableRules["mangle"], rules.IPTablesRule{
+			"-i", c.VTEP.Name, "!", "-d", overlay,
+			"-j", "MARK", "--set-xmark", mark + "/0xffffffff",
+		})
+		tableRules["nat"] = append(tableRules["nat"], rules.IPTablesRule{
+			"!", "-d", overlay, "-m", "mark", "--mark", mark,
+			"-j", "SNAT", "--to-source", state.Gateway.EgressIP,
+		})
+		for _, source := range state.Gateway.LocalSources {
+			tableRules["nat"] = append(tableRules["nat"], rules.IPTablesRule{
+				"-s", source.IP, "!", "-d", overlay,
+				"-j", "SNAT", "--to-source", state.Gateway.EgressIP,
+			})
+		}
+		tableRules["filter"] = append(tableRules["filter"],
+			rules.IPTablesRule{
+				"-i", c.VTEP.Name, "!", "-d", overlay, "-m", "mark", "--mark", mark,
+				"-j", "ACCEPT",
+			},
+			rules.IPTablesRule{
+				"-o", c.VTEP.Name, "!", "-s", overlay,
+				"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED",
+				"-j", "ACCEPT",
+			},
+		)
+	}
+
+	return tableRules, nil
+}
+
+func firstAppendedRule(chainRules []string) string {
+	for _, rule := range chainRules {
+		if strings.HasPrefix(rule, "-A ") {
+			return rule
+		}
+	}
+	return ""
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,383 @@
+package egress_test
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/silk/daemon/egress"
+	"code.cloudfoundry.org/silk/daemon/egress/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Converger", func() {
+	var (
+		iptables     *libfakes.IPTablesAdapter
+		netlinkFake  *fakes.NetlinkAdapter
+		chainNamer   *fakes.NetOutChainNamer
+		converger    *egress.Converger
+		state        egress.State
+		filterChains []string
+	)
+
+	BeforeEach(func() {
+		iptables = &libfakes.IPTablesAdapter{}
+		netlinkFake = &fakes.NetlinkAdapter{}
+		chainNamer = &fakes.NetOutChainNamer{}
+		chainNamer.NameStub = func(handle string) string { return "netout--" + handle }
+		_, overlay, _ := net.ParseCIDR("10.255.0.0/16")
+
+		converger = &egress.Converger{
+			Logger:         lagertest.NewTestLogger("test"),
+			IPTables:       iptables,
+			NetlinkAdapter: netlinkFake,
+			NetOutChains:   chainNamer,
+			OverlayNetwork: overlay,
+			VTEP:           net.Interface{Index: 42, Name: "silk-vtep"},
+			MarkBase:       0x10000,
+			TableBase:      200,
+			RulePriority:   1000,
+		}
+
+		filterChains = []string{"INPUT", "FORWARD", "netout--handle-1"}
+		iptables.ListChainsStub = func(table string) ([]string, error) {
+			if table == "filter" {
+				return filterChains, nil
+			}
+			return []string{"PREROUTING", "POSTROUTING"}, nil
+		}
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			return []string{"-P " + chain + " ACCEPT"}, nil
+		}
+
+		state = egress.State{
+			Routes: []egress.Route{
+				{
+					ID:               0,
+					GatewayName:      "gw-a",
+					GatewayOverlayIP: "10.255.2.0",
+					Sources: []egress.Source{
+						{Handle: "handle-1", IP: "10.255.1.10"},
+						{Handle: "handle-2", IP: "10.255.1.11"},
+					},
+				},
+				{
+					ID:          1,
+					GatewayName: "gw-b",
+					Sources:     []egress.Source{{Handle: "handle-1", IP: "10.255.1.12"}},
+				},
+			},
+			Gateway: &egress.Gateway{
+				ID:           2,
+				Name:         "gw-c",
+				EgressIP:     "10.0.100.1",
+				LocalSources: []egress.Source{{Handle: "handle-3", IP: "10.255.1.13"}},
+			},
+		}
+	})
+
+	replacedRules := func(table string) []rules.IPTablesRule {
+		Expect(iptables.ReplaceChainsCallCount()).To(Equal(1))
+		for _, spec := range iptables.ReplaceChainsArgsForCall(0) {
+			if spec.Table == table {
+				Expect(spec.Chain).To(Equal("silk-egress"))
+				return spec.Rules
+			}
+		}
+		Fail("no chain replaced in table " + table)
+		return nil
+	}
+
+	It("creates the chains and jumps to them first from each parent chain", func() {
+		Expect(converger.Converge(state)).To(Succeed())
+
+		Expect(iptables.NewChainCallCount()).To(Equal(3))
+		Expect(iptables.BulkInsertCallCount()).To(Equal(3))
+		for i, parent := range [][]string{{"mangle", "PREROUTING"}, {"nat", "POSTROUTING"}, {"filter", "FORWARD"}} {
+			table, chain := iptables.NewChainArgsForCall(i)
+			Expect([]string{table, chain}).To(Equal([]string{parent[0], "silk-egress"}))
+
+			table, chain, pos, rulespec := iptables.BulkInsertArgsForCall(i)
+			Expect([]string{table, chain}).To(Equal(parent))
+			Expect(pos).To(Equal(1))
+			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"-j", "silk-egress"}}))
+		}
+	})
+
+	Context("when the jump exists but is not first", func() {
+		BeforeEach(func() {
+			iptables.ListStub = func(table, chain string) ([]string, error) {
+				return []string{"-P " + chain + " ACCEPT", "-A " + chain + " -j other", "-A " + chain + " -j silk-egress"}, nil
+			}
+		})
+
+		It("moves it to the top", func() {
+			Expect(converger.Converge(state)).To(Succeed())
+			Expect(iptables.DeleteCallCount()).To(Equal(3))
+			Expect(iptables.BulkInsertCallCount()).To(Equal(3))
+		})
+	})
+
+	Context("when chain ownership is coordinated", func() {
+		var chainOwners *fakes.ChainOwners
+
+		BeforeEach(func() {
+			chainOwners = &fakes.ChainOwners{}
+			converger.ChainOwners = chainOwners
+		})
+
+		It("claims its chains and the head of each parent chain", func() {
+			Expect(converger.Converge(state)).To(Succeed())
+
+			Expect(chainOwners.ClaimCallCount()).To(Equal(2))
+			owner, chains := chainOwners.ClaimArgsForCall(0)
+			Expect(owner).To(Equal("silk-daemon"))
+			Expect(chains).To(Equal([]datastore.OwnedChain{
+				{Table: "mangle", Chain: "silk-egress"},
+				{Table: "nat", Chain: "silk-egress"},
+				{Table: "filter", Chain: "silk-egress"},
+			}))
+			owner, chains = chainOwners.ClaimArgsForCall(1)
+			Expect(owner).To(Equal("silk-daemon"))
+			Expect(chains).To(Equal([]datastore.OwnedChain{
+				{Table: "mangle", Chain: "PREROUTING", Jump: "silk-egress"},
+				{Table: "nat", Chain: "POSTROUTING", Jump: "silk-egress"},
+				{Table: "filter", Chain: "FORWARD", Jump: "silk-egress"},
+			}))
+
+			_, _, pos, _ := iptables.BulkInsertArgsForCall(2)
+			Expect(pos).To(Equal(1))
+		})
+
+		Context("when the egress chains are owned by another component", func() {
+			BeforeEach(func() {
+				chainOwners.ClaimReturnsOnCall(0, &datastore.ChainOwnedError{Table: "filter", Chain: "silk-egress", Owner: "other"})
+			})
+
+			It("does not touch them", func() {
+				err := converger.Converge(state)
+				Expect(err).To(MatchError("claim chains: chain filter/silk-egress is owned by other"))
+				Expect(iptables.NewChainCallCount()).To(Equal(0))
+				Expect(iptables.ReplaceChainsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the parent chain heads are owned by another component", func() {
+			BeforeEach(func() {
+				chainOwners.ClaimReturnsOnCall(1, &datastore.ChainOwnedError{Table: "filter", Chain: "FORWARD", Owner: "other"})
+				iptables.ListStub = func(table, chain string) ([]string, error) {
+					return []string{"-P " + chain + " ACCEPT", "-A " + chain + " -j other"}, nil
+				}
+			})
+
+			It("inserts the jumps below the owner's rule", func() {
+				Expect(converger.Converge(state)).To(Succeed())
+				Expect(iptables.BulkInsertCallCount()).To(Equal(3))
+				for i := 0; i < 3; i++ {
+					_, _, pos, _ := iptables.BulkInsertArgsForCall(i)
+					Expect(pos).To(Equal(2))
+				}
+			})
+
+			Context("when the jumps exist but are not first", func() {
+				BeforeEach(func() {
+					iptables.ListStub = func(table, chain string) ([]string, error) {
+						return []string{"-P " + chain + " ACCEPT", "-A " + chain + " -j other", "-A " + chain + " -j silk-egress"}, nil
+					}
+				})
+
+				It("does not move them", func() {
+					Expect(converger.Converge(state)).To(Succeed())
+					Expect(iptables.DeleteCallCount()).To(Equal(0))
+					Expect(iptables.BulkInsertCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when claiming the parent chains fails", func() {
+			BeforeEach(func() {
+				chainOwners.ClaimReturnsOnCall(1, errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				Expect(converger.Converge(state)).To(MatchError("claim parent chains: banana"))
+			})
+		})
+	})
+
+	Context("when the jump is already first", func() {
+		BeforeEach(func() {
+			iptables.ListStub = func(table, chain string) ([]string, error) {
+				return []string{"-P " + chain + " ACCEPT", "-A " + chain + " -j silk-egress", "-A " + chain + " -j other"}, nil
+			}
+		})
+
+		It("leaves it alone", func() {
+			Expect(converger.Converge(state)).To(Succeed())
+			Expect(iptables.DeleteCallCount()).To(Equal(0))
+			Expect(iptables.BulkInsertCallCount()).To(Equal(0))
+		})
+	})
+
+	It("routes each gateway's marked traffic through its own table", func() {
+		Expect(converger.Converge(state)).To(Succeed())
+
+		Expect(netlinkFake.RouteReplaceCallCount()).To(Equal(2))
+		route := netlinkFake.RouteReplaceArgsForCall(0)
+		Expect(route.Table).To(Equal(200))
+		Expect(route.LinkIndex).To(Equal(42))
+		Expect(route.Gw.String()).To(Equal("10.255.2.0"))
+		Expect(route.Flags).To(Equal(int(netlink.FLAG_ONLINK)))
+
+		By("blackholing traffic for gateways without a lease")
+		route = netlinkFake.RouteReplaceArgsForCall(1)
+		Expect(route.Table).To(Equal(201))
+		Expect(route.Type).To(Equal(syscall.RTN_BLACKHOLE))
+
+		Expect(netlinkFake.RuleAddCallCount()).To(Equal(2))
+		rule := netlinkFake.RuleAddArgsForCall(0)
+		Expect(rule.Table).To(Equal(200))
+		Expect(rule.Mark).To(Equal(0x10000))
+		Expect(rule.Priority).To(Equal(1000))
+		rule = netlinkFake.RuleAddArgsForCall(1)
+		Expect(rule.Table).To(Equal(201))
+		Expect(rule.Mark).To(Equal(0x10001))
+	})
+
+	Context("when ip rules already exist", func() {
+		BeforeEach(func() {
+			netlinkFake.RuleListReturns([]netlink.Rule{
+				{Priority: 1000, Table: 200, Mark: 0x10000},
+				{Priority: 1000, Table: 205, Mark: 0x10005},
+				{Priority: 32766, Table: 254},
+			}, nil)
+		})
+
+		It("keeps the desired rules and removes stale ones", func() {
+			Expect(converger.Converge(state)).To(Succeed())
+
+			Expect(netlinkFake.RuleDelCallCount()).To(Equal(1))
+			Expect(netlinkFake.RuleDelArgsForCall(0).Table).To(Equal(205))
+
+			Expect(netlinkFake.RuleAddCallCount()).To(Equal(1))
+			Expect(netlinkFake.RuleAddArgsForCall(0).Table).To(Equal(201))
+		})
+	})
+
+	It("writes the iptables rules of all tables at once", func() {
+		Expect(converger.Converge(state)).To(Succeed())
+
+		Expect(iptables.ClearChainCallCount()).To(Equal(0))
+		Expect(iptables.BulkAppendCallCount()).To(Equal(0))
+		Expect(iptables.ReplaceChainsCallCount()).To(Equal(1))
+		Expect(iptables.ReplaceChainsArgsForCall(0)).To(HaveLen(3))
+
+		Expect(replacedRules("mangle")).To(Equal([]rules.IPTablesRule{
+			{"-s", "10.255.1.10", "!", "-d", "10.255.0.0/16", "-j", "MARK", "--set-xmark", "0x10000/0xffffffff"},
+			{"-s", "10.255.1.11", "!", "-d", "10.255.0.0/16", "-j", "MARK", "--set-xmark", "0x10000/0xffffffff"},
+			{"-s", "10.255.1.12", "!", "-d", "10.255.0.0/16", "-j", "MARK", "--set-xmark", "0x10001/0xffffffff"},
+			{"-i", "silk-vtep", "!", "-d", "10.255.0.0/16", "-j", "MARK", "--set-xmark", "0x10002/0xffffffff"},
+		}))
+
+		Expect(replacedRules("nat")).To(Equal([]rules.IPTablesRule{
+			{"!", "-d", "10.255.0.0/16", "-m", "mark", "--mark", "0x10002", "-j", "SNAT", "--to-source", "10.0.100.1"},
+			{"-s", "10.255.1.13", "!", "-d", "10.255.0.0/16", "-j", "SNAT", "--to-source", "10.0.100.1"},
+		}))
+
+		By("enforcing ASGs on gateway-bound traffic and rejecting it when the netout chain is missing")
+		Expect(replacedRules("filter")).To(Equal([]rules.IPTablesRule{
+			{"-s", "10.255.1.10", "-o", "silk-vtep", "!", "-d", "10.255.0.0/16", "-j", "netout--handle-1"},
+			{"-s", "10.255.1.11", "-o", "silk-vtep", "!", "-d", "10.255.0.0/16", "-j", "REJECT", "--reject-with", "icmp-port-unreachable"},
+			{"-s", "10.255.1.12", "-o", "silk-vtep", "!", "-d", "10.255.0.0/16", "-j", "netout--handle-1"},
+			{"-i", "silk-vtep", "!", "-d", "10.255.0.0/16", "-m", "mark", "--mark", "0x10002", "-j", "ACCEPT"},
+			{"-o", "silk-vtep", "!", "-s", "10.255.0.0/16", "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+		}))
+	})
+
+	Context("when the state has not changed since the last converge", func() {
+		BeforeEach(func() {
+			Expect(converger.Converge(state)).To(Succeed())
+		})
+
+		It("does not rewrite the rules", func() {
+			Expect(converger.Converge(state)).To(Succeed())
+			Expect(iptables.ReplaceChainsCallCount()).To(Equal(1))
+			Expect(netlinkFake.RouteReplaceCallCount()).To(Equal(2))
+		})
+
+		Context("when a missing netout chain appears", func() {
+			BeforeEach(func() {
+				filterChains = append(filterChains, "netout--handle-2")
+			})
+
+			It("rewrites the rules", func() {
+				Expect(converger.Converge(state)).To(Succeed())
+				Expect(iptables.ReplaceChainsCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Context("when the state is empty", func() {
+		It("replaces the chains with empty ones", func() {
+			Expect(converger.Converge(egress.State{})).To(Succeed())
+			Expect(replacedRules("mangle")).To(BeEmpty())
+			Expect(replacedRules("nat")).To(BeEmpty())
+			Expect(replacedRules("filter")).To(BeEmpty())
+			Expect(netlinkFake.RuleAddCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when replacing a route fails", func() {
+		BeforeEach(func() {
+			netlinkFake.RouteReplaceReturns(errors.New("banana"))
+		})
+
+		It("returns the error and retries on the next converge", func() {
+			Expect(converger.Converge(state)).To(MatchError("replace route in table 200: banana"))
+
+			netlinkFake.RouteReplaceReturns(nil)
+			Expect(converger.Converge(state)).To(Succeed())
+			Expect(iptables.ReplaceChainsCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when listing ip rules fails", func() {
+		BeforeEach(func() {
+			netlinkFake.RuleListReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(converger.Converge(state)).To(MatchError("list ip rules: banana"))
+		})
+	})
+
+	Context("when creating a chain fails", func() {
+		BeforeEach(func() {
+			iptables.NewChainReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(converger.Converge(state)).To(MatchError("create chain mangle/silk-egress: banana"))
+		})
+	})
+
+	Context("when replacing the chains fails", func() {
+		BeforeEach(func() {
+			iptables.ReplaceChainsReturns(errors.New("banana"))
+		})
+
+		It("returns the error and rewrites them on the next converge", func() {
+			Expect(converger.Converge(state)).To(MatchError("replace chains silk-egress: banana"))
+
+			iptables.ReplaceChainsReturns(nil)
+			Expect(converger.Converge(state)).To(Succeed())
+			Expect(iptables.ReplaceChainsCallCount()).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package egress_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEgress(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Egress Suite")
+}
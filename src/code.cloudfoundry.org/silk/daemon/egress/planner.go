@@ -0,0 +1,163 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/controller_client.go --fake-name ControllerClient . controllerClient
+type controllerClient interface {
+	GetEgressGateways() ([]controller.EgressGateway, error)
+	GetActiveLeases() ([]controller.Lease, error)
+}
+
+//go:generate counterfeiter -o fakes/converger.go --fake-name Converger . converger
+type converger interface {
+	Converge(State) error
+}
+
+type Source struct {
+	Handle string
+	IP     string
+}
+
+// Route sends egress traffic from Sources over the overlay to a remote
+// gateway. GatewayOverlayIP is empty when the gateway holds no lease, in
+// which case the traffic is dropped rather than leaked through the local NAT.
+type Route struct {
+	ID               int
+	GatewayName      string
+	GatewayOverlayIP string
+	Sources          []Source
+}
+
+// Gateway is set when this cell is itself an egress gateway.
+type Gateway struct {
+	ID           int
+	Name         string
+	EgressIP     string
+	LocalSources []Source
+}
+
+type State struct {
+	Routes  []Route
+	Gateway *Gateway
+}
+
+type Planner struct {
+	Logger           lager.Logger
+	ControllerClient controllerClient
+	Datastore        datastore.Datastore
+	LocalUnderlayIP  string
+	Converger        converger
+}
+
+func (p *Planner) DoCycle() error {
+	gateways, err := p.ControllerClient.GetEgressGateways()
+	if err != nil {
+		return fmt.Errorf("get egress gateways: %s", err)
+	}
+	sort.Slice(gateways, func(i, j int) bool { return gateways[i].Name < gateways[j].Name })
+
+	state := State{}
+	if len(gateways) > 0 {
+		state, err = p.buildState(gateways)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = p.Converger.Converge(state)
+	if err != nil {
+		return fmt.Errorf("converge egress gateways: %s", err)
+	}
+
+	p.Logger.Debug("converge-egress-gateways", lager.Data{"state": state})
+	return nil
+}
+
+func (p *Planner) buildState(gateways []controller.EgressGateway) (State, error) {
+	leases, err := p.ControllerClient.GetActiveLeases()
+	if err != nil {
+		return State{}, fmt.Errorf("get active leases: %s", err)
+	}
+
+	overlayIPs := map[string]string{}
+	for _, lease := range leases {
+		overlayIP, _, err := net.ParseCIDR(lease.OverlaySubnet)
+		if err != nil {
+			return State{}, fmt.Errorf("parse lease: %s", err)
+		}
+		overlayIPs[lease.UnderlayIP] = overlayIP.String()
+	}
+
+	containers, err := p.Datastore.ReadAll()
+	if err != nil {
+		return State{}, fmt.Errorf("read datastore: %s", err)
+	}
+
+	spaceGateways := map[string]int{}
+	for i, gateway := range gateways {
+		for _, space := range gateway.SpaceGUIDs {
+			if _, ok := spaceGateways[space]; !ok {
+				spaceGateways[space] = i
+			}
+		}
+	}
+
+	state := State{}
+	for _, gateway := range gateways {
+		if gateway.UnderlayIP == p.LocalUnderlayIP {
+			state.Gateway = &Gateway{ID: gateway.ID, Name: gateway.Name, EgressIP: gateway.EgressIP}
+		}
+	}
+
+	handles := []string{}
+	for handle := range containers {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+
+	routes := map[int]*Route{}
+	for _, handle := range handles {
+		container := containers[handle]
+		metadata, _ := datastore.ParseContainerMetadata(container.Metadata)
+		i, ok := spaceGateways[metadata.SpaceID]
+		if !ok {
+			continue
+		}
+
+		source := Source{Handle: handle, IP: container.IP}
+		if gateways[i].UnderlayIP == p.LocalUnderlayIP {
+			state.Gateway.LocalSources = append(state.Gateway.LocalSources, source)
+			continue
+		}
+
+		route, ok := routes[i]
+		if !ok {
+			route = &Route{
+				ID:               gateways[i].ID,
+				GatewayName:      gateways[i].Name,
+				GatewayOverlayIP: overlayIPs[gateways[i].UnderlayIP],
+			}
+			if route.GatewayOverlayIP == "" {
+				p.Logger.Info("egress-gateway-has-no-lease", lager.Data{"gateway": gateways[i].Name})
+			}
+			routes[i] = route
+		}
+		route.Sources = append(route.Sources, source)
+	}
+
+	for i := range gateways {
+		if route, ok := routes[i]; ok {
+			state.Routes = append(state.Routes, *route)
+		}
+	}
+
+	return state, nil
+}
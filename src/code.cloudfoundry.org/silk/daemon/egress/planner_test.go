@@ -0,0 +1,145 @@
+package egress_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/daemon/egress"
+	"code.cloudfoundry.org/silk/daemon/egress/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Planner", func() {
+	var (
+		controllerClient *fakes.ControllerClient
+		store            *libfakes.Datastore
+		converger        *fakes.Converger
+		planner          *egress.Planner
+	)
+
+	BeforeEach(func() {
+		controllerClient = &fakes.ControllerClient{}
+		store = &libfakes.Datastore{}
+		converger = &fakes.Converger{}
+		planner = &egress.Planner{
+			Logger:           lagertest.NewTestLogger("test"),
+			ControllerClient: controllerClient,
+			Datastore:        store,
+			LocalUnderlayIP:  "10.0.0.1",
+			Converger:        converger,
+		}
+
+		controllerClient.GetEgressGatewaysReturns([]controller.EgressGateway{
+			{ID: 7, Name: "gw-b", UnderlayIP: "10.0.0.3", EgressIP: "10.0.100.3", SpaceGUIDs: []string{"space-2", "space-1"}},
+			{ID: 3, Name: "gw-a", UnderlayIP: "10.0.0.2", EgressIP: "10.0.100.2", SpaceGUIDs: []string{"space-1"}},
+			{ID: 5, Name: "gw-c", UnderlayIP: "10.0.0.1", EgressIP: "10.0.100.1", SpaceGUIDs: []string{"space-3"}},
+		}, nil)
+		controllerClient.GetActiveLeasesReturns([]controller.Lease{
+			{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+			{UnderlayIP: "10.0.0.2", OverlaySubnet: "10.255.2.0/24"},
+		}, nil)
+		store.ReadAllReturns(map[string]datastore.Container{
+			"handle-1": {Handle: "handle-1", IP: "10.255.1.10", Metadata: map[string]interface{}{"space_id": "space-1"}},
+			"handle-2": {Handle: "handle-2", IP: "10.255.1.11", Metadata: map[string]interface{}{"space_id": "space-2"}},
+			"handle-3": {Handle: "handle-3", IP: "10.255.1.12", Metadata: map[string]interface{}{"space_id": "space-3"}},
+			"handle-4": {Handle: "handle-4", IP: "10.255.1.13", Metadata: map[string]interface{}{"space_id": "other"}},
+			"handle-5": {Handle: "handle-5", IP: "10.255.1.14"},
+		}, nil)
+	})
+
+	It("converges containers in gateway spaces onto their gateways", func() {
+		Expect(planner.DoCycle()).To(Succeed())
+
+		Expect(converger.ConvergeCallCount()).To(Equal(1))
+		Expect(converger.ConvergeArgsForCall(0)).To(Equal(egress.State{
+			Routes: []egress.Route{
+				{
+					ID:               3,
+					GatewayName:      "gw-a",
+					GatewayOverlayIP: "10.255.2.0",
+					Sources:          []egress.Source{{Handle: "handle-1", IP: "10.255.1.10"}},
+				},
+				{
+					ID:               7,
+					GatewayName:      "gw-b",
+					GatewayOverlayIP: "",
+					Sources:          []egress.Source{{Handle: "handle-2", IP: "10.255.1.11"}},
+				},
+			},
+			Gateway: &egress.Gateway{
+				ID:           5,
+				Name:         "gw-c",
+				EgressIP:     "10.0.100.1",
+				LocalSources: []egress.Source{{Handle: "handle-3", IP: "10.255.1.12"}},
+			},
+		}))
+	})
+
+	Context("when no gateways are configured", func() {
+		BeforeEach(func() {
+			controllerClient.GetEgressGatewaysReturns(nil, nil)
+		})
+
+		It("converges an empty state without reading leases or containers", func() {
+			Expect(planner.DoCycle()).To(Succeed())
+			Expect(controllerClient.GetActiveLeasesCallCount()).To(Equal(0))
+			Expect(store.ReadAllCallCount()).To(Equal(0))
+			Expect(converger.ConvergeArgsForCall(0)).To(Equal(egress.State{}))
+		})
+	})
+
+	Context("when getting the gateways fails", func() {
+		BeforeEach(func() {
+			controllerClient.GetEgressGatewaysReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(planner.DoCycle()).To(MatchError("get egress gateways: banana"))
+			Expect(converger.ConvergeCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when getting the leases fails", func() {
+		BeforeEach(func() {
+			controllerClient.GetActiveLeasesReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(planner.DoCycle()).To(MatchError("get active leases: banana"))
+		})
+	})
+
+	Context("when a lease is malformed", func() {
+		BeforeEach(func() {
+			controllerClient.GetActiveLeasesReturns([]controller.Lease{{UnderlayIP: "10.0.0.2", OverlaySubnet: "banana"}}, nil)
+		})
+
+		It("returns the error", func() {
+			Expect(planner.DoCycle()).To(MatchError(ContainSubstring("parse lease:")))
+		})
+	})
+
+	Context("when reading the datastore fails", func() {
+		BeforeEach(func() {
+			store.ReadAllReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(planner.DoCycle()).To(MatchError("read datastore: banana"))
+		})
+	})
+
+	Context("when converging fails", func() {
+		BeforeEach(func() {
+			converger.ConvergeReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(planner.DoCycle()).To(MatchError("converge egress gateways: banana"))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package daemon
 
 type NetworkInfo struct {
-	OverlaySubnet string `json:"overlay_subnet"`
-	MTU           int    `json:"mtu"`
+	OverlaySubnet     string `json:"overlay_subnet"`
+	OverlayIPv6Subnet string `json:"overlay_ipv6_subnet,omitempty"`
+	MTU               int    `json:"mtu"`
 }
@@ -25,6 +25,16 @@ type metricSender interface {
 	IncrementCounter(name string)
 }
 
+//go:generate counterfeiter -o fakes/underlay_probe.go --fake-name UnderlayProbe . underlayProbe
+type underlayProbe interface {
+	Check() error
+}
+
+//go:generate counterfeiter -o fakes/underlay_failover.go --fake-name UnderlayFailover . underlayFailover
+type underlayFailover interface {
+	FailoverUnderlayIP() (string, error)
+}
+
 type VXLANPlanner struct {
 	Logger           lager.Logger
 	ControllerClient controllerClient
@@ -32,21 +42,21 @@ type VXLANPlanner struct {
 	Lease            controller.Lease
 	ErrorDetector    FatalErrorDetector
 	MetricSender     metricSender
+	// UnderlayProbe, when set, gates the renewal of the lease: while it
+	// fails, the lease is not renewed, so that the other cells stop routing
+	// to this cell once the lease expires, while this cell still converges
+	// the leases of the others.
+	UnderlayProbe underlayProbe
+	// UnderlayFailover, when set, picks the failover underlay ip that the
+	// lease is renewed with. While neither underlay of the cell is healthy,
+	// the renewal is skipped as with UnderlayProbe.
+	UnderlayFailover underlayFailover
 }
 
 func (v *VXLANPlanner) DoCycle() error {
-	err := v.ControllerClient.RenewSubnetLease(v.Lease)
-	if err != nil {
-		v.MetricSender.IncrementCounter("renewFailure")
-		if v.ErrorDetector.IsFatal(err) {
-			return daemon.FatalError(fmt.Sprintf("renew lease: %s", err))
-		}
-		return fmt.Errorf("renew lease: %s", err)
+	if err := v.renewLease(); err != nil {
+		return err
 	}
-	v.ErrorDetector.GotSuccess()
-	v.Logger.Debug("renew-lease", lager.Data{"lease": v.Lease})
-
-	v.MetricSender.IncrementCounter("renewSuccess")
 
 	leases, err := v.ControllerClient.GetActiveLeases()
 	if err != nil {
@@ -65,3 +75,36 @@ func (v *VXLANPlanner) DoCycle() error {
 	v.Logger.Debug("converge-leases", lager.Data{"leases": leases})
 	return nil
 }
+
+func (v *VXLANPlanner) renewLease() error {
+	if v.UnderlayProbe != nil {
+		if err := v.UnderlayProbe.Check(); err != nil {
+			v.Logger.Error("underlay-unhealthy", err, lager.Data{"lease": v.Lease})
+			v.MetricSender.IncrementCounter("renewSkipped")
+			return nil
+		}
+	}
+	if v.UnderlayFailover != nil {
+		failoverUnderlayIP, err := v.UnderlayFailover.FailoverUnderlayIP()
+		if err != nil {
+			v.Logger.Error("underlay-unhealthy", err, lager.Data{"lease": v.Lease})
+			v.MetricSender.IncrementCounter("renewSkipped")
+			return nil
+		}
+		v.Lease.FailoverUnderlayIP = failoverUnderlayIP
+	}
+
+	err := v.ControllerClient.RenewSubnetLease(v.Lease)
+	if err != nil {
+		v.MetricSender.IncrementCounter("renewFailure")
+		if v.ErrorDetector.IsFatal(err) {
+			return daemon.FatalError(fmt.Sprintf("renew lease: %s", err))
+		}
+		return fmt.Errorf("renew lease: %s", err)
+	}
+	v.ErrorDetector.GotSuccess()
+	v.Logger.Debug("renew-lease", lager.Data{"lease": v.Lease})
+
+	v.MetricSender.IncrementCounter("renewSuccess")
+	return nil
+}
@@ -205,6 +205,102 @@ var _ = Describe("VxlanPlanner", func() {
 			})
 		})
 
+		Context("when the renewal is gated on an underlay probe", func() {
+			var underlayProbe *fakes.UnderlayProbe
+
+			BeforeEach(func() {
+				underlayProbe = &fakes.UnderlayProbe{}
+				vxlanPlanner.UnderlayProbe = underlayProbe
+			})
+
+			It("renews the lease while the probe passes", func() {
+				err := vxlanPlanner.DoCycle()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(underlayProbe.CheckCallCount()).To(Equal(1))
+				Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(1))
+				Expect(errorDetector.GotSuccessCallCount()).To(Equal(1))
+			})
+
+			Context("when the probe fails", func() {
+				BeforeEach(func() {
+					underlayProbe.CheckReturns(errors.New("no default gateway"))
+				})
+
+				It("skips the renewal and still converges the leases", func() {
+					err := vxlanPlanner.DoCycle()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(0))
+					Expect(errorDetector.GotSuccessCallCount()).To(Equal(0))
+					Expect(errorDetector.IsFatalCallCount()).To(Equal(0))
+
+					Expect(converger.ConvergeCallCount()).To(Equal(1))
+					Expect(converger.ConvergeArgsForCall(0)).To(Equal(leases))
+
+					Expect(metricSender.IncrementCounterCallCount()).To(Equal(2))
+					Expect(metricSender.IncrementCounterArgsForCall(0)).To(Equal("renewSkipped"))
+					Expect(metricSender.IncrementCounterArgsForCall(1)).To(Equal("convergeSuccess"))
+				})
+
+				It("logs why the renewal was skipped", func() {
+					err := vxlanPlanner.DoCycle()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(logger.Logs()).To(ContainElement(SatisfyAll(
+						LogsWith(lager.ERROR, "test.underlay-unhealthy"),
+						HaveLogData(HaveKeyWithValue("error", "no default gateway")),
+					)))
+				})
+			})
+		})
+
+		Context("when the cell can fail over to a secondary underlay", func() {
+			var underlayFailover *fakes.UnderlayFailover
+
+			BeforeEach(func() {
+				underlayFailover = &fakes.UnderlayFailover{}
+				vxlanPlanner.UnderlayFailover = underlayFailover
+			})
+
+			It("renews the lease with the failover underlay ip", func() {
+				underlayFailover.FailoverUnderlayIPReturns("172.245.17.0", nil)
+
+				err := vxlanPlanner.DoCycle()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(1))
+				Expect(controllerClient.RenewSubnetLeaseArgsForCall(0).FailoverUnderlayIP).To(Equal("172.245.17.0"))
+			})
+
+			It("clears it once the cell is back on its primary underlay", func() {
+				underlayFailover.FailoverUnderlayIPReturns("172.245.17.0", nil)
+				Expect(vxlanPlanner.DoCycle()).To(Succeed())
+
+				underlayFailover.FailoverUnderlayIPReturns("", nil)
+				Expect(vxlanPlanner.DoCycle()).To(Succeed())
+
+				Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(2))
+				Expect(controllerClient.RenewSubnetLeaseArgsForCall(1).FailoverUnderlayIP).To(BeEmpty())
+			})
+
+			Context("when neither underlay is healthy", func() {
+				BeforeEach(func() {
+					underlayFailover.FailoverUnderlayIPReturns("", errors.New("primary underlay: down, secondary underlay: down"))
+				})
+
+				It("skips the renewal and still converges the leases", func() {
+					err := vxlanPlanner.DoCycle()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(controllerClient.RenewSubnetLeaseCallCount()).To(Equal(0))
+					Expect(converger.ConvergeCallCount()).To(Equal(1))
+					Expect(metricSender.IncrementCounterArgsForCall(0)).To(Equal("renewSkipped"))
+					Expect(logger.Logs()).To(ContainElement(LogsWith(lager.ERROR, "test.underlay-unhealthy")))
+				})
+			})
+		})
+
 		Context("when getting the routable releases fails", func() {
 			BeforeEach(func() {
 				controllerClient.GetActiveLeasesReturns(nil, errors.New("guava"))
@@ -0,0 +1,75 @@
+package sysctls
+
+import (
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+//go:generate counterfeiter -o fakes/sysctlAdapter.go --fake-name SysctlAdapter . sysctlAdapter
+type sysctlAdapter interface {
+	Sysctl(name string, params ...string) (string, error)
+}
+
+//go:generate counterfeiter -o fakes/metricSender.go --fake-name MetricSender . metricSender
+type metricSender interface {
+	SendValue(name string, value float64, units string)
+}
+
+type Enforcer struct {
+	Settings      []Setting
+	SysctlAdapter sysctlAdapter
+	MetricSender  metricSender
+	Logger        lager.Logger
+}
+
+// Enforce sets every sysctl that drifted from its setting. It goes on past a
+// sysctl that cannot be read or set, and the first error is returned.
+func (e *Enforcer) Enforce() error {
+	drifted, failed := 0, 0
+	var firstErr error
+	for _, setting := range e.Settings {
+		changed, err := e.enforce(setting)
+		if changed {
+			drifted++
+		}
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	e.MetricSender.SendValue("sysctlDrift", float64(drifted), "")
+	e.MetricSender.SendValue("sysctlFailures", float64(failed), "")
+	return firstErr
+}
+
+func (e *Enforcer) enforce(setting Setting) (bool, error) {
+	found, err := e.SysctlAdapter.Sysctl(setting.Name)
+	if os.IsNotExist(err) && setting.Optional {
+		e.Logger.Debug("sysctl-not-present", lager.Data{"name": setting.Name})
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read sysctl %s: %s", setting.Name, err)
+	}
+
+	if setting.SatisfiedBy(found) {
+		return false, nil
+	}
+
+	e.Logger.Info("sysctl-drift", lager.Data{
+		"name":     setting.Name,
+		"expected": setting.Value,
+		"minimum":  setting.Minimum,
+		"found":    found,
+	})
+	_, err = e.SysctlAdapter.Sysctl(setting.Name, setting.Value)
+	if err != nil {
+		return true, fmt.Errorf("set sysctl %s: %s", setting.Name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,146 @@
+package sysctls_test
+
+import (
+	"errors"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/daemon/sysctls"
+	"code.cloudfoundry.org/silk/daemon/sysctls/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Enforcer", func() {
+	var (
+		fakeSysctlAdapter *fakes.SysctlAdapter
+		fakeMetricSender  *fakes.MetricSender
+		logger            *lagertest.TestLogger
+		values            map[string]string
+		enforcer          *sysctls.Enforcer
+	)
+
+	BeforeEach(func() {
+		values = map[string]string{
+			"net.ipv4.ip_forward":            "1",
+			"net.netfilter.nf_conntrack_max": "262144",
+		}
+		fakeSysctlAdapter = &fakes.SysctlAdapter{}
+		fakeSysctlAdapter.SysctlStub = func(name string, params ...string) (string, error) {
+			if len(params) == 1 {
+				values[name] = params[0]
+			}
+			value, ok := values[name]
+			if !ok {
+				return "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+			}
+			return value, nil
+		}
+		fakeMetricSender = &fakes.MetricSender{}
+		logger = lagertest.NewTestLogger("test")
+
+		enforcer = &sysctls.Enforcer{
+			Settings: []sysctls.Setting{
+				sysctls.IPForward,
+				{Name: "net.netfilter.nf_conntrack_max", Value: "131072", Minimum: true},
+			},
+			SysctlAdapter: fakeSysctlAdapter,
+			MetricSender:  fakeMetricSender,
+			Logger:        logger,
+		}
+	})
+
+	It("leaves the sysctls that are at their settings", func() {
+		Expect(enforcer.Enforce()).To(Succeed())
+
+		Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(2))
+		Expect(values["net.netfilter.nf_conntrack_max"]).To(Equal("262144"))
+	})
+
+	It("sets the sysctls that drifted", func() {
+		values["net.ipv4.ip_forward"] = "0"
+		values["net.netfilter.nf_conntrack_max"] = "65536"
+
+		Expect(enforcer.Enforce()).To(Succeed())
+
+		Expect(values["net.ipv4.ip_forward"]).To(Equal("1"))
+		Expect(values["net.netfilter.nf_conntrack_max"]).To(Equal("131072"))
+		Expect(logger).To(gbytes.Say(`sysctl-drift.*"expected":"1","found":"0","minimum":false,"name":"net.ipv4.ip_forward"`))
+	})
+
+	It("reports the drift", func() {
+		values["net.ipv4.ip_forward"] = "0"
+
+		Expect(enforcer.Enforce()).To(Succeed())
+
+		Expect(fakeMetricSender.SendValueCallCount()).To(Equal(2))
+		name, value, _ := fakeMetricSender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("sysctlDrift"))
+		Expect(value).To(Equal(1.0))
+		name, value, _ = fakeMetricSender.SendValueArgsForCall(1)
+		Expect(name).To(Equal("sysctlFailures"))
+		Expect(value).To(Equal(0.0))
+	})
+
+	Context("when an optional sysctl does not exist", func() {
+		BeforeEach(func() {
+			enforcer.Settings = append(enforcer.Settings, sysctls.Setting{
+				Name:     "net.bridge.bridge-nf-call-iptables",
+				Value:    "1",
+				Optional: true,
+			})
+		})
+
+		It("skips it", func() {
+			Expect(enforcer.Enforce()).To(Succeed())
+
+			Expect(fakeSysctlAdapter.SysctlCallCount()).To(Equal(3))
+			Expect(values).NotTo(HaveKey("net.bridge.bridge-nf-call-iptables"))
+		})
+	})
+
+	Context("when a required sysctl does not exist", func() {
+		BeforeEach(func() {
+			enforcer.Settings = append(enforcer.Settings, sysctls.Setting{
+				Name:  "net.bridge.bridge-nf-call-iptables",
+				Value: "1",
+			})
+		})
+
+		It("returns an error", func() {
+			err := enforcer.Enforce()
+			Expect(err).To(MatchError(ContainSubstring("read sysctl net.bridge.bridge-nf-call-iptables")))
+
+			_, value, _ := fakeMetricSender.SendValueArgsForCall(1)
+			Expect(value).To(Equal(1.0))
+		})
+	})
+
+	Context("when a sysctl cannot be set", func() {
+		BeforeEach(func() {
+			values["net.ipv4.ip_forward"] = "0"
+			values["net.netfilter.nf_conntrack_max"] = "65536"
+			fakeSysctlAdapter.SysctlStub = func(name string, params ...string) (string, error) {
+				if len(params) == 1 {
+					if name == "net.ipv4.ip_forward" {
+						return "", errors.New("banana")
+					}
+					values[name] = params[0]
+				}
+				return values[name], nil
+			}
+		})
+
+		It("sets the others and returns the first error", func() {
+			err := enforcer.Enforce()
+			Expect(err).To(MatchError("set sysctl net.ipv4.ip_forward: banana"))
+
+			Expect(values["net.netfilter.nf_conntrack_max"]).To(Equal("131072"))
+			_, value, _ := fakeMetricSender.SendValueArgsForCall(0)
+			Expect(value).To(Equal(2.0))
+			_, value, _ = fakeMetricSender.SendValueArgsForCall(1)
+			Expect(value).To(Equal(1.0))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+// Package sysctls keeps the host sysctls that the silk components depend on
+// at the values they need. The settings are asserted when the silk daemon
+// starts and re-asserted on every poll, so that a value changed by another
+// agent on the host, e.g. a sysctl.d file applied late, is put back.
+package sysctls
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IPForward is required for the cell to route between the containers, the
+// VTEP and the underlay.
+var IPForward = Setting{Name: "net.ipv4.ip_forward", Value: "1"}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+([./][A-Za-z0-9_-]+)+$`)
+
+// Setting is the value that a sysctl must have. A Minimum setting is
+// satisfied by larger values too, which leaves the tuning of e.g. the
+// conntrack table sizes to the operator. An Optional setting is skipped when
+// the sysctl does not exist on the host, e.g. because its kernel module is
+// not loaded.
+type Setting struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Minimum  bool   `json:"minimum"`
+	Optional bool   `json:"optional"`
+}
+
+func (s Setting) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid sysctl name %q", s.Name)
+	}
+	if len(strings.Fields(s.Value)) == 0 {
+		return fmt.Errorf("sysctl %s must have a value", s.Name)
+	}
+	if s.Minimum {
+		if _, err := parseIntegers(s.Value); err != nil {
+			return fmt.Errorf("sysctl %s has a minimum that is not a number: %s", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// SatisfiedBy reports whether the sysctl is at the value of the setting. The
+// fields of a value with several of them, e.g. net.ipv4.tcp_rmem, are
+// compared one by one.
+func (s Setting) SatisfiedBy(found string) bool {
+	if !s.Minimum {
+		return strings.Join(strings.Fields(found), " ") == strings.Join(strings.Fields(s.Value), " ")
+	}
+
+	minimums, err := parseIntegers(s.Value)
+	if err != nil {
+		return false
+	}
+	values, err := parseIntegers(found)
+	if err != nil || len(values) != len(minimums) {
+		return false
+	}
+	for i := range minimums {
+		if values[i] < minimums[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func parseIntegers(value string) ([]int64, error) {
+	var integers []int64
+	for _, field := range strings.Fields(value) {
+		integer, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		integers = append(integers, integer)
+	}
+	return integers, nil
+}
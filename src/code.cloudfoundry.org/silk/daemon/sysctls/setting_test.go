@@ -0,0 +1,69 @@
+package sysctls_test
+
+import (
+	"code.cloudfoundry.org/silk/daemon/sysctls"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Setting", func() {
+	Describe("Validate", func() {
+		It("accepts dotted and slashed names", func() {
+			Expect(sysctls.Setting{Name: "net.ipv4.ip_forward", Value: "1"}.Validate()).To(Succeed())
+			Expect(sysctls.Setting{Name: "net/ipv4/conf/silk-vtep/rp_filter", Value: "2"}.Validate()).To(Succeed())
+		})
+
+		It("rejects names that leave /proc/sys", func() {
+			Expect(sysctls.Setting{Name: "../../etc/passwd", Value: "1"}.Validate()).To(MatchError(`invalid sysctl name "../../etc/passwd"`))
+			Expect(sysctls.Setting{Name: "/net/ipv4/ip_forward", Value: "1"}.Validate()).To(HaveOccurred())
+			Expect(sysctls.Setting{Name: "ip_forward", Value: "1"}.Validate()).To(HaveOccurred())
+		})
+
+		It("requires a value", func() {
+			Expect(sysctls.Setting{Name: "net.ipv4.ip_forward", Value: " "}.Validate()).To(MatchError("sysctl net.ipv4.ip_forward must have a value"))
+		})
+
+		It("requires a minimum to be a number", func() {
+			err := sysctls.Setting{Name: "net.netfilter.nf_conntrack_max", Value: "many", Minimum: true}.Validate()
+			Expect(err).To(MatchError(ContainSubstring("sysctl net.netfilter.nf_conntrack_max has a minimum that is not a number")))
+		})
+	})
+
+	Describe("SatisfiedBy", func() {
+		It("compares the value", func() {
+			setting := sysctls.Setting{Name: "net.ipv4.ip_forward", Value: "1"}
+			Expect(setting.SatisfiedBy("1")).To(BeTrue())
+			Expect(setting.SatisfiedBy("0")).To(BeFalse())
+		})
+
+		It("ignores the whitespace between the fields of a value", func() {
+			setting := sysctls.Setting{Name: "net.ipv4.ip_local_port_range", Value: "32768 60999"}
+			Expect(setting.SatisfiedBy("32768\t60999")).To(BeTrue())
+		})
+
+		Context("when the value is a minimum", func() {
+			var setting sysctls.Setting
+
+			BeforeEach(func() {
+				setting = sysctls.Setting{Name: "net.netfilter.nf_conntrack_max", Value: "262144", Minimum: true}
+			})
+
+			It("is satisfied by the same or a larger value", func() {
+				Expect(setting.SatisfiedBy("262144")).To(BeTrue())
+				Expect(setting.SatisfiedBy("1048576")).To(BeTrue())
+				Expect(setting.SatisfiedBy("65536")).To(BeFalse())
+			})
+
+			It("compares the fields one by one", func() {
+				setting = sysctls.Setting{Name: "net.ipv4.tcp_rmem", Value: "4096 87380 6291456", Minimum: true}
+				Expect(setting.SatisfiedBy("4096\t131072\t6291456")).To(BeTrue())
+				Expect(setting.SatisfiedBy("4096\t65536\t6291456")).To(BeFalse())
+				Expect(setting.SatisfiedBy("4096")).To(BeFalse())
+			})
+
+			It("is not satisfied by a value that is not a number", func() {
+				Expect(setting.SatisfiedBy("")).To(BeFalse())
+			})
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package sysctls_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSysctls(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sysctls Suite")
+}
@@ -0,0 +1,126 @@
+package ttl
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"code.cloudfoundry.org/lib/rules"
+)
+
+const (
+	ChainName   = "silk-ttl"
+	table       = "mangle"
+	parentChain = "POSTROUTING"
+)
+
+// Setter sets the TTL of the packets that leave the cell over the underlay.
+// EncapsulatedTTL is set on the VXLAN packets of the overlay, and
+// ContainerEgressTTL on the packets that containers send to destinations
+// outside of the overlay. A TTL of 1 keeps the packets from being routed
+// beyond the first underlay hop. A TTL of 0 leaves the packets unchanged.
+// The VXLAN packets are sent from SecondaryUnderlayIP as well when it is set.
+type Setter struct {
+	IPTables            rules.IPTablesAdapter
+	UnderlayIP          string
+	SecondaryUnderlayIP string
+	VTEPName            string
+	VTEPPort            int
+	OverlayNetwork      *net.IPNet
+	EncapsulatedTTL     int
+	ContainerEgressTTL  int
+}
+
+// Apply writes the rules to ChainName and jumps to it from POSTROUTING. When
+// neither TTL is set, the chain of an earlier configuration is removed.
+func (s *Setter) Apply() error {
+	ttlRules := s.rules()
+	if len(ttlRules) == 0 {
+		return s.remove()
+	}
+
+	err := s.IPTables.ReplaceChain(table, ChainName, ttlRules...)
+	if err != nil {
+		return fmt.Errorf("replace chain %s/%s: %s", table, ChainName, err)
+	}
+	err = s.IPTables.EnsureRules(table, parentChain, jump())
+	if err != nil {
+		return fmt.Errorf("ensure jump %s/%s: %s", table, parentChain, err)
+	}
+	return nil
+}
+
+func (s *Setter) rules() []rules.IPTablesRule {
+	ttlRules := []rules.IPTablesRule{}
+	if s.EncapsulatedTTL != 0 {
+		underlayIPs := []string{s.UnderlayIP}
+		if s.SecondaryUnderlayIP != "" {
+			underlayIPs = append(underlayIPs, s.SecondaryUnderlayIP)
+		}
+		for _, underlayIP := range underlayIPs {
+			ttlRules = append(ttlRules, rules.IPTablesRule{
+				"-s", underlayIP,
+				"-p", "udp",
+				"-m", "udp", "--dport", strconv.Itoa(s.VTEPPort),
+				"-m", "comment", "--comment", "overlay",
+				"-j", "TTL", "--ttl-set", strconv.Itoa(s.EncapsulatedTTL),
+			})
+		}
+	}
+	if s.ContainerEgressTTL != 0 {
+		// packets to egress gateways leave through the VTEP and are routed
+		// again on the gateway cell, so only the packets that leave the cell
+		// directly are set
+		ttlRules = append(ttlRules, rules.IPTablesRule{
+			"-s", s.OverlayNetwork.String(),
+			"!", "-d", s.OverlayNetwork.String(),
+			"!", "-o", s.VTEPName,
+			"-m", "comment", "--comment", "container-egress",
+			"-j", "TTL", "--ttl-set", strconv.Itoa(s.ContainerEgressTTL),
+		})
+	}
+	return ttlRules
+}
+
+func (s *Setter) remove() error {
+	chains, err := s.IPTables.ListChains(table)
+	if err != nil {
+		return fmt.Errorf("list chains %s: %s", table, err)
+	}
+	if !contains(chains, ChainName) {
+		return nil
+	}
+
+	exists, err := s.IPTables.Exists(table, parentChain, jump())
+	if err != nil {
+		return fmt.Errorf("check jump %s/%s: %s", table, parentChain, err)
+	}
+	if exists {
+		err = s.IPTables.Delete(table, parentChain, jump())
+		if err != nil {
+			return fmt.Errorf("delete jump %s/%s: %s", table, parentChain, err)
+		}
+	}
+	err = s.IPTables.ClearChain(table, ChainName)
+	if err != nil {
+		return fmt.Errorf("clear chain %s/%s: %s", table, ChainName, err)
+	}
+	err = s.IPTables.DeleteChain(table, ChainName)
+	if err != nil {
+		return fmt.Errorf("delete chain %s/%s: %s", table, ChainName, err)
+	}
+	return nil
+}
+
+func jump() rules.IPTablesRule {
+	return rules.IPTablesRule{"-j", ChainName}
+}
+
+func contains(list []string, item string) bool {
+	for _, e := range list {
+		if e == item {
+			return true
+		}
+	}
+	return false
+}
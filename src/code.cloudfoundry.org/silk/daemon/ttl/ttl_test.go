@@ -0,0 +1,153 @@
+package ttl_test
+
+import (
+	"errors"
+	"net"
+
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/silk/daemon/ttl"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Setter", func() {
+	var (
+		iptables *libfakes.IPTablesAdapter
+		setter   *ttl.Setter
+	)
+
+	BeforeEach(func() {
+		iptables = &libfakes.IPTablesAdapter{}
+		_, overlay, _ := net.ParseCIDR("10.255.0.0/16")
+		setter = &ttl.Setter{
+			IPTables:           iptables,
+			UnderlayIP:         "10.0.16.4",
+			VTEPName:           "silk-vtep",
+			VTEPPort:           4789,
+			OverlayNetwork:     overlay,
+			EncapsulatedTTL:    1,
+			ContainerEgressTTL: 64,
+		}
+	})
+
+	It("sets the TTL of the overlay and container egress packets", func() {
+		Expect(setter.Apply()).To(Succeed())
+
+		Expect(iptables.ReplaceChainCallCount()).To(Equal(1))
+		table, chain, ttlRules := iptables.ReplaceChainArgsForCall(0)
+		Expect(table).To(Equal("mangle"))
+		Expect(chain).To(Equal("silk-ttl"))
+		Expect(ttlRules).To(Equal([]rules.IPTablesRule{
+			{"-s", "10.0.16.4", "-p", "udp", "-m", "udp", "--dport", "4789",
+				"-m", "comment", "--comment", "overlay", "-j", "TTL", "--ttl-set", "1"},
+			{"-s", "10.255.0.0/16", "!", "-d", "10.255.0.0/16", "!", "-o", "silk-vtep",
+				"-m", "comment", "--comment", "container-egress", "-j", "TTL", "--ttl-set", "64"},
+		}))
+
+		Expect(iptables.EnsureRulesCallCount()).To(Equal(1))
+		table, chain, jumps := iptables.EnsureRulesArgsForCall(0)
+		Expect(table).To(Equal("mangle"))
+		Expect(chain).To(Equal("POSTROUTING"))
+		Expect(jumps).To(Equal([]rules.IPTablesRule{{"-j", "silk-ttl"}}))
+	})
+
+	Context("when the cell has a secondary underlay", func() {
+		BeforeEach(func() {
+			setter.SecondaryUnderlayIP = "10.1.16.4"
+		})
+
+		It("sets the TTL of the overlay packets sent from either underlay", func() {
+			Expect(setter.Apply()).To(Succeed())
+
+			_, _, ttlRules := iptables.ReplaceChainArgsForCall(0)
+			Expect(ttlRules).To(HaveLen(3))
+			Expect(ttlRules[0][:2]).To(Equal(rules.IPTablesRule{"-s", "10.0.16.4"}))
+			Expect(ttlRules[1]).To(Equal(rules.IPTablesRule{"-s", "10.1.16.4", "-p", "udp", "-m", "udp", "--dport", "4789",
+				"-m", "comment", "--comment", "overlay", "-j", "TTL", "--ttl-set", "1"}))
+		})
+	})
+
+	Context("when only one of the TTLs is set", func() {
+		BeforeEach(func() {
+			setter.ContainerEgressTTL = 0
+		})
+
+		It("only writes its rule", func() {
+			Expect(setter.Apply()).To(Succeed())
+
+			_, _, ttlRules := iptables.ReplaceChainArgsForCall(0)
+			Expect(ttlRules).To(HaveLen(1))
+			Expect(ttlRules[0]).To(ContainElement("overlay"))
+		})
+	})
+
+	Context("when neither TTL is set", func() {
+		BeforeEach(func() {
+			setter.EncapsulatedTTL = 0
+			setter.ContainerEgressTTL = 0
+			iptables.ListChainsReturns([]string{"PREROUTING", "POSTROUTING", "silk-ttl"}, nil)
+			iptables.ExistsReturns(true, nil)
+		})
+
+		It("removes the chain of an earlier configuration", func() {
+			Expect(setter.Apply()).To(Succeed())
+
+			Expect(iptables.ReplaceChainCallCount()).To(Equal(0))
+			Expect(iptables.DeleteCallCount()).To(Equal(1))
+			table, chain, jump := iptables.DeleteArgsForCall(0)
+			Expect(table).To(Equal("mangle"))
+			Expect(chain).To(Equal("POSTROUTING"))
+			Expect(jump).To(Equal(rules.IPTablesRule{"-j", "silk-ttl"}))
+
+			Expect(iptables.ClearChainCallCount()).To(Equal(1))
+			Expect(iptables.DeleteChainCallCount()).To(Equal(1))
+			table, chain = iptables.DeleteChainArgsForCall(0)
+			Expect(table).To(Equal("mangle"))
+			Expect(chain).To(Equal("silk-ttl"))
+		})
+
+		Context("when there is no chain", func() {
+			BeforeEach(func() {
+				iptables.ListChainsReturns([]string{"PREROUTING", "POSTROUTING"}, nil)
+			})
+
+			It("does nothing", func() {
+				Expect(setter.Apply()).To(Succeed())
+
+				Expect(iptables.DeleteCallCount()).To(Equal(0))
+				Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when listing the chains fails", func() {
+			BeforeEach(func() {
+				iptables.ListChainsReturns(nil, errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				Expect(setter.Apply()).To(MatchError("list chains mangle: banana"))
+			})
+		})
+	})
+
+	Context("when replacing the chain fails", func() {
+		BeforeEach(func() {
+			iptables.ReplaceChainReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(setter.Apply()).To(MatchError("replace chain mangle/silk-ttl: banana"))
+		})
+	})
+
+	Context("when adding the jump fails", func() {
+		BeforeEach(func() {
+			iptables.EnsureRulesReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(setter.Apply()).To(MatchError("ensure jump mangle/POSTROUTING: banana"))
+		})
+	})
+})
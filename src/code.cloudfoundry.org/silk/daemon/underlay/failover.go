@@ -0,0 +1,49 @@
+package underlay
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+//go:generate counterfeiter -o fakes/checker.go --fake-name Checker . checker
+type checker interface {
+	Check() error
+}
+
+// Failover picks the underlay that the other cells send the overlay traffic
+// of the cell to: the primary underlay while its probe passes, and the
+// secondary one while only the probe of the secondary underlay passes. The
+// VTEP of the cell is not bound to either underlay, so that it sends over the
+// one that routes to each cell.
+type Failover struct {
+	SecondaryUnderlayIP string
+	PrimaryProbe        checker
+	SecondaryProbe      checker
+	Logger              lager.Logger
+
+	failedOver bool
+}
+
+// FailoverUnderlayIP is the underlay ip that the lease of the cell announces
+// besides its own: the secondary underlay ip while the cell is failed over,
+// and empty otherwise. It fails when neither underlay passes its probe.
+func (f *Failover) FailoverUnderlayIP() (string, error) {
+	primaryErr := f.PrimaryProbe.Check()
+	if primaryErr == nil {
+		if f.failedOver {
+			f.Logger.Info("underlay-failed-back")
+		}
+		f.failedOver = false
+		return "", nil
+	}
+
+	if err := f.SecondaryProbe.Check(); err != nil {
+		return "", fmt.Errorf("primary underlay: %s, secondary underlay: %s", primaryErr, err)
+	}
+	if !f.failedOver {
+		f.Logger.Error("underlay-failed-over", primaryErr, lager.Data{"failover_underlay_ip": f.SecondaryUnderlayIP})
+	}
+	f.failedOver = true
+	return f.SecondaryUnderlayIP, nil
+}
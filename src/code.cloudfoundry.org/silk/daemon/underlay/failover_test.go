@@ -0,0 +1,83 @@
+package underlay_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/daemon/underlay"
+	"code.cloudfoundry.org/silk/daemon/underlay/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Failover", func() {
+	var (
+		primaryProbe   *fakes.Checker
+		secondaryProbe *fakes.Checker
+		logger         *lagertest.TestLogger
+		failover       *underlay.Failover
+	)
+
+	BeforeEach(func() {
+		primaryProbe = &fakes.Checker{}
+		secondaryProbe = &fakes.Checker{}
+		logger = lagertest.NewTestLogger("test")
+		failover = &underlay.Failover{
+			SecondaryUnderlayIP: "10.1.16.4",
+			PrimaryProbe:        primaryProbe,
+			SecondaryProbe:      secondaryProbe,
+			Logger:              logger,
+		}
+	})
+
+	It("stays on the primary underlay while it is healthy", func() {
+		ip, err := failover.FailoverUnderlayIP()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ip).To(BeEmpty())
+		Expect(secondaryProbe.CheckCallCount()).To(Equal(0))
+	})
+
+	Context("when the primary underlay is unhealthy", func() {
+		BeforeEach(func() {
+			primaryProbe.CheckReturns(errors.New("underlay device eth0 has no carrier"))
+		})
+
+		It("fails over to the secondary underlay", func() {
+			ip, err := failover.FailoverUnderlayIP()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ip).To(Equal("10.1.16.4"))
+			Expect(logger).To(gbytes.Say("underlay-failed-over.*eth0 has no carrier.*10.1.16.4"))
+		})
+
+		It("logs the failover once", func() {
+			_, err := failover.FailoverUnderlayIP()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = failover.FailoverUnderlayIP()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logger.LogMessages()).To(Equal([]string{"test.underlay-failed-over"}))
+		})
+
+		It("fails back once the primary underlay is healthy again", func() {
+			_, err := failover.FailoverUnderlayIP()
+			Expect(err).NotTo(HaveOccurred())
+
+			primaryProbe.CheckReturns(nil)
+			ip, err := failover.FailoverUnderlayIP()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ip).To(BeEmpty())
+			Expect(logger).To(gbytes.Say("underlay-failed-back"))
+		})
+
+		Context("when the secondary underlay is unhealthy as well", func() {
+			BeforeEach(func() {
+				secondaryProbe.CheckReturns(errors.New("underlay device eth1 is down"))
+			})
+
+			It("returns an error", func() {
+				_, err := failover.FailoverUnderlayIP()
+				Expect(err).To(MatchError("primary underlay: underlay device eth0 has no carrier, secondary underlay: underlay device eth1 is down"))
+			})
+		})
+	})
+})
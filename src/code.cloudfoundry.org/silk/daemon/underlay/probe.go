@@ -0,0 +1,125 @@
+package underlay
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+//go:generate counterfeiter -o fakes/netlink_adapter.go --fake-name NetlinkAdapter . netlinkAdapter
+type netlinkAdapter interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkByIndex(index int) (netlink.Link, error)
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+}
+
+//go:generate counterfeiter -o fakes/pinger.go --fake-name Pinger . pinger
+type pinger interface {
+	Ping(destination net.IP, timeout time.Duration) (time.Duration, error)
+}
+
+// Probe checks the underlay that the cell sends the overlay traffic over.
+// With CheckCarrier, the VTEP has to be up and the device it sends through has
+// to have a carrier; Device names that device when the VTEP is not bound to
+// one. With CheckGateway, the default gateway of the cell has to answer a ping
+// within PingTimeout.
+type Probe struct {
+	VTEPName       string
+	Device         string
+	CheckCarrier   bool
+	CheckGateway   bool
+	PingTimeout    time.Duration
+	NetlinkAdapter netlinkAdapter
+	Pinger         pinger
+}
+
+func (p *Probe) Check() error {
+	if p.CheckCarrier {
+		if err := p.checkCarrier(); err != nil {
+			return err
+		}
+	}
+	if p.CheckGateway {
+		if err := p.checkGateway(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Probe) checkCarrier() error {
+	vtep, err := p.NetlinkAdapter.LinkByName(p.VTEPName)
+	if err != nil {
+		return fmt.Errorf("find vtep %s: %s", p.VTEPName, err)
+	}
+	if vtep.Attrs().Flags&net.FlagUp == 0 {
+		return fmt.Errorf("vtep %s is down", p.VTEPName)
+	}
+
+	device, err := p.underlayDevice(vtep)
+	if err != nil || device == nil {
+		return err
+	}
+	attrs := device.Attrs()
+	if attrs.Flags&net.FlagUp == 0 {
+		return fmt.Errorf("underlay device %s is down", attrs.Name)
+	}
+	switch attrs.OperState {
+	case netlink.OperDown, netlink.OperLowerLayerDown, netlink.OperNotPresent:
+		return fmt.Errorf("underlay device %s has no carrier", attrs.Name)
+	}
+	return nil
+}
+
+func (p *Probe) underlayDevice(vtep netlink.Link) (netlink.Link, error) {
+	if p.Device != "" {
+		device, err := p.NetlinkAdapter.LinkByName(p.Device)
+		if err != nil {
+			return nil, fmt.Errorf("find underlay device %s: %s", p.Device, err)
+		}
+		return device, nil
+	}
+
+	vxlan, ok := vtep.(*netlink.Vxlan)
+	if !ok || vxlan.VtepDevIndex == 0 {
+		return nil, nil
+	}
+	device, err := p.NetlinkAdapter.LinkByIndex(vxlan.VtepDevIndex)
+	if err != nil {
+		return nil, fmt.Errorf("find underlay device of vtep %s: %s", p.VTEPName, err)
+	}
+	return device, nil
+}
+
+func (p *Probe) checkGateway() error {
+	gateway, err := p.defaultGateway()
+	if err != nil {
+		return err
+	}
+	if _, err := p.Pinger.Ping(gateway, p.PingTimeout); err != nil {
+		return fmt.Errorf("ping default gateway %s: %s", gateway, err)
+	}
+	return nil
+}
+
+func (p *Probe) defaultGateway() (net.IP, error) {
+	routes, err := p.NetlinkAdapter.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("list routes: %s", err)
+	}
+	for _, route := range routes {
+		if route.Gw == nil {
+			continue
+		}
+		if route.Dst == nil {
+			return route.Gw, nil
+		}
+		if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+			return route.Gw, nil
+		}
+	}
+	return nil, errors.New("no default gateway")
+}
@@ -0,0 +1,167 @@
+package underlay_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/silk/daemon/underlay"
+	"code.cloudfoundry.org/silk/daemon/underlay/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Probe", func() {
+	var (
+		netlinkAdapter *fakes.NetlinkAdapter
+		pinger         *fakes.Pinger
+		vtep           *netlink.Vxlan
+		device         *netlink.Device
+		probe          *underlay.Probe
+	)
+
+	BeforeEach(func() {
+		netlinkAdapter = &fakes.NetlinkAdapter{}
+		pinger = &fakes.Pinger{}
+
+		vtep = &netlink.Vxlan{
+			LinkAttrs:    netlink.LinkAttrs{Name: "silk-vtep", Flags: net.FlagUp, OperState: netlink.OperUnknown},
+			VtepDevIndex: 2,
+		}
+		device = &netlink.Device{
+			LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2, Flags: net.FlagUp, OperState: netlink.OperUp},
+		}
+		netlinkAdapter.LinkByNameReturns(vtep, nil)
+		netlinkAdapter.LinkByIndexReturns(device, nil)
+
+		_, local, _ := net.ParseCIDR("10.0.16.0/24")
+		_, anywhere, _ := net.ParseCIDR("0.0.0.0/0")
+		netlinkAdapter.RouteListReturns([]netlink.Route{
+			{Dst: local},
+			{Dst: anywhere, Gw: net.ParseIP("10.0.16.1")},
+		}, nil)
+
+		probe = &underlay.Probe{
+			VTEPName:       "silk-vtep",
+			CheckCarrier:   true,
+			CheckGateway:   true,
+			PingTimeout:    time.Second,
+			NetlinkAdapter: netlinkAdapter,
+			Pinger:         pinger,
+		}
+	})
+
+	It("passes when the underlay is healthy", func() {
+		Expect(probe.Check()).To(Succeed())
+
+		Expect(netlinkAdapter.LinkByNameArgsForCall(0)).To(Equal("silk-vtep"))
+		Expect(netlinkAdapter.LinkByIndexArgsForCall(0)).To(Equal(2))
+
+		_, family := netlinkAdapter.RouteListArgsForCall(0)
+		Expect(family).To(Equal(netlink.FAMILY_V4))
+		Expect(pinger.PingCallCount()).To(Equal(1))
+		gateway, timeout := pinger.PingArgsForCall(0)
+		Expect(gateway.String()).To(Equal("10.0.16.1"))
+		Expect(timeout).To(Equal(time.Second))
+	})
+
+	It("fails when the vtep is down", func() {
+		vtep.Flags = 0
+		Expect(probe.Check()).To(MatchError("vtep silk-vtep is down"))
+	})
+
+	It("fails when the underlay device has no carrier", func() {
+		device.OperState = netlink.OperDown
+		Expect(probe.Check()).To(MatchError("underlay device eth0 has no carrier"))
+		Expect(pinger.PingCallCount()).To(Equal(0))
+	})
+
+	It("fails when the underlay device is down", func() {
+		device.Flags = 0
+		Expect(probe.Check()).To(MatchError("underlay device eth0 is down"))
+	})
+
+	It("fails when the vtep cannot be found", func() {
+		netlinkAdapter.LinkByNameReturns(nil, errors.New("banana"))
+		Expect(probe.Check()).To(MatchError("find vtep silk-vtep: banana"))
+	})
+
+	It("fails when the default gateway does not answer", func() {
+		pinger.PingReturns(0, errors.New("timeout"))
+		Expect(probe.Check()).To(MatchError("ping default gateway 10.0.16.1: timeout"))
+	})
+
+	It("finds the default gateway of a route without destination", func() {
+		netlinkAdapter.RouteListReturns([]netlink.Route{{Gw: net.ParseIP("10.0.16.254")}}, nil)
+		Expect(probe.Check()).To(Succeed())
+		gateway, _ := pinger.PingArgsForCall(0)
+		Expect(gateway.String()).To(Equal("10.0.16.254"))
+	})
+
+	It("fails when there is no default gateway", func() {
+		netlinkAdapter.RouteListReturns(nil, nil)
+		Expect(probe.Check()).To(MatchError("no default gateway"))
+	})
+
+	It("fails when the routes cannot be listed", func() {
+		netlinkAdapter.RouteListReturns(nil, errors.New("banana"))
+		Expect(probe.Check()).To(MatchError("list routes: banana"))
+	})
+
+	Context("when only the carrier is checked", func() {
+		BeforeEach(func() {
+			probe.CheckGateway = false
+		})
+
+		It("does not ping the gateway", func() {
+			Expect(probe.Check()).To(Succeed())
+			Expect(netlinkAdapter.RouteListCallCount()).To(Equal(0))
+			Expect(pinger.PingCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the underlay device is named", func() {
+		BeforeEach(func() {
+			probe.Device = "eth1"
+			vtep.VtepDevIndex = 0
+			netlinkAdapter.LinkByNameStub = func(name string) (netlink.Link, error) {
+				if name == "eth1" {
+					return device, nil
+				}
+				return vtep, nil
+			}
+		})
+
+		It("checks the carrier of that device", func() {
+			Expect(probe.Check()).To(Succeed())
+			Expect(netlinkAdapter.LinkByNameArgsForCall(1)).To(Equal("eth1"))
+			Expect(netlinkAdapter.LinkByIndexCallCount()).To(Equal(0))
+
+			device.OperState = netlink.OperDown
+			Expect(probe.Check()).To(MatchError("underlay device eth0 has no carrier"))
+		})
+
+		It("fails when the device cannot be found", func() {
+			netlinkAdapter.LinkByNameStub = func(name string) (netlink.Link, error) {
+				if name == "eth1" {
+					return nil, errors.New("banana")
+				}
+				return vtep, nil
+			}
+			Expect(probe.Check()).To(MatchError("find underlay device eth1: banana"))
+		})
+	})
+
+	Context("when only the gateway is checked", func() {
+		BeforeEach(func() {
+			probe.CheckCarrier = false
+		})
+
+		It("does not look at the vtep", func() {
+			vtep.Flags = 0
+			Expect(probe.Check()).To(Succeed())
+			Expect(netlinkAdapter.LinkByNameCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package underlay_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestUnderlay(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Underlay Suite")
+}
@@ -28,6 +28,10 @@ type Config struct {
 	VNI                        int
 	OverlayNetworkPrefixLength int
 	VTEPPort                   int
+	// SecondaryUnderlayIP is set when the cell has a second underlay to fail
+	// over to, on SecondaryUnderlayInterface.
+	SecondaryUnderlayIP        net.IP
+	SecondaryUnderlayInterface net.Interface
 }
 
 func (c *ConfigCreator) Create(clientConf clientConfig.Config, lease controller.Lease) (*Config, error) {
@@ -60,6 +64,19 @@ func (c *ConfigCreator) Create(clientConf clientConfig.Config, lease controller.
 		}
 	}
 
+	var secondaryUnderlayIP net.IP
+	var secondaryUnderlayInterface net.Interface
+	if clientConf.SecondaryUnderlayIP != "" {
+		secondaryUnderlayIP = net.ParseIP(clientConf.SecondaryUnderlayIP)
+		if secondaryUnderlayIP == nil {
+			return nil, fmt.Errorf("parse secondary underlay ip: %s", clientConf.SecondaryUnderlayIP)
+		}
+		secondaryUnderlayInterface, err = c.locateInterface(secondaryUnderlayIP)
+		if err != nil {
+			return nil, fmt.Errorf("find device from ip %s: %s", secondaryUnderlayIP, err)
+		}
+	}
+
 	overlayIP, _, err := net.ParseCIDR(lease.OverlaySubnet)
 	if err != nil {
 		return nil, fmt.Errorf("determine vtep overlay ip: %s", err)
@@ -91,6 +108,8 @@ func (c *ConfigCreator) Create(clientConf clientConfig.Config, lease controller.
 		VNI:                        clientConf.VNI,
 		OverlayNetworkPrefixLength: overlayNetworkPrefixLength,
 		VTEPPort:                   clientConf.VTEPPort,
+		SecondaryUnderlayIP:        secondaryUnderlayIP,
+		SecondaryUnderlayInterface: secondaryUnderlayInterface,
 	}, nil
 }
 
@@ -95,6 +95,38 @@ var _ = Describe("ConfigCreator", func() {
 			})
 		})
 
+		Context("when a secondary underlay ip is set", func() {
+			BeforeEach(func() {
+				clientConf.SecondaryUnderlayIP = "172.254.30.2"
+				fakeNetAdapter.InterfacesReturns([]net.Interface{{Index: 42}, {Index: 43}}, nil)
+				fakeNetAdapter.InterfaceAddrsStub = func(iface net.Interface) ([]net.Addr, error) {
+					if iface.Index == 43 {
+						return []net.Addr{&net.IPNet{IP: net.IP{172, 254, 30, 2}, Mask: net.IPMask{255, 255, 255, 0}}}, nil
+					}
+					return []net.Addr{&net.IPNet{IP: net.IP{172, 255, 30, 2}, Mask: net.IPMask{255, 255, 255, 0}}}, nil
+				}
+			})
+
+			It("finds the device of the secondary underlay", func() {
+				conf, err := creator.Create(clientConf, lease)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conf.UnderlayInterface).To(Equal(net.Interface{Index: 42}))
+				Expect(conf.SecondaryUnderlayIP.String()).To(Equal("172.254.30.2"))
+				Expect(conf.SecondaryUnderlayInterface).To(Equal(net.Interface{Index: 43}))
+			})
+
+			Context("when no device has the secondary underlay ip", func() {
+				BeforeEach(func() {
+					clientConf.SecondaryUnderlayIP = "172.253.30.2"
+				})
+
+				It("returns an error", func() {
+					_, err := creator.Create(clientConf, lease)
+					Expect(err).To(MatchError("find device from ip 172.253.30.2: no interface with address 172.253.30.2"))
+				})
+			})
+		})
+
 		Context("when the overlay network prefix length is greater than or equal to the subnet prefix length", func() {
 			BeforeEach(func() {
 				clientConf.OverlayNetwork = "10.255.0.0/30"
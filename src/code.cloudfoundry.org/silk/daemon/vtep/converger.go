@@ -1,6 +1,7 @@
 package vtep
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"syscall"
@@ -10,12 +11,32 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
+//go:generate counterfeiter -o fakes/metricSender.go --fake-name MetricSender . metricSender
+type metricSender interface {
+	SendValue(name string, value float64, units string)
+	IncrementCounter(name string)
+}
+
 type Converger struct {
 	OverlayNetwork *net.IPNet
 	LocalSubnet    *net.IPNet
 	LocalVTEP      net.Interface
 	NetlinkAdapter netlinkAdapter
+	MetricSender   metricSender
 	Logger         lager.Logger
+	// NoOverlay leaves the traffic to the other cells to the underlay, which
+	// routes their subnets in no-overlay mode. The routes and neighbor
+	// entries of the other cells are removed from the VTEP, as is the route
+	// to the overlay network of its address.
+	NoOverlay bool
+	// RouteTable is the routing table the routes to the subnets of the other
+	// cells are installed in, with RouteMetric as their metric. Unless it is
+	// 0, for the main table, an ip rule with RulePriority looks the overlay
+	// network up in it, so that the rules and routes of the site do not
+	// take over the traffic to the other cells.
+	RouteTable   int
+	RouteMetric  int
+	RulePriority int
 }
 
 func (c *Converger) Converge(leases []controller.Lease) error {
@@ -33,7 +54,7 @@ func (c *Converger) Converge(leases []controller.Lease) error {
 			return fmt.Errorf("parse lease: %s", err)
 		}
 
-		if c.isLocal(destNet) {
+		if c.isLocal(destNet) || c.NoOverlay {
 			continue
 		}
 
@@ -48,9 +69,9 @@ func (c *Converger) Converge(leases []controller.Lease) error {
 		}
 		currentRoutes = append(currentRoutes, route)
 
-		underlayIP := net.ParseIP(lease.UnderlayIP)
+		underlayIP := net.ParseIP(lease.VTEPUnderlayIP())
 		if underlayIP == nil {
-			return fmt.Errorf("invalid underlay ip: %s", lease.UnderlayIP)
+			return fmt.Errorf("invalid underlay ip: %s", lease.VTEPUnderlayIP())
 		}
 
 		remoteMac, err := net.ParseMAC(lease.OverlayHardwareAddr)
@@ -65,6 +86,11 @@ func (c *Converger) Converge(leases []controller.Lease) error {
 		currentNeighs = append(currentNeighs, neighs...)
 	}
 
+	err = c.convergeRule()
+	if err != nil {
+		return err
+	}
+
 	routesForDeletion := getDeletedRoutes(previousRoutes, currentRoutes)
 	for _, route := range routesForDeletion {
 		if route.LinkIndex == c.LocalVTEP.Index && c.OverlayNetwork.Contains(route.Gw) {
@@ -75,16 +101,19 @@ func (c *Converger) Converge(leases []controller.Lease) error {
 		}
 	}
 
-	neighsForDeletion := getDeletedNeighs(previousNeighs, currentNeighs)
-	for _, neigh := range neighsForDeletion {
-		if neigh.LinkIndex == c.LocalVTEP.Index {
-			err = c.NetlinkAdapter.NeighDel(&neigh)
-			if err != nil {
-				return fmt.Errorf("del neigh with ip/hwaddr %s: %s", &neigh, err)
-			}
+	if c.NoOverlay {
+		err = c.deleteOverlayNetworkRoute(previousRoutes)
+		if err != nil {
+			return err
 		}
 	}
 
+	pruned, err := c.pruneNeighs(getDeletedNeighs(previousNeighs, currentNeighs))
+	c.sendNeighMetrics(currentNeighs, pruned)
+	if err != nil {
+		return err
+	}
+
 	if nonRoutableLeaseCount > 0 {
 		c.Logger.Info("converger", lager.Data{"non-routable-lease-count": nonRoutableLeaseCount})
 	}
@@ -92,6 +121,107 @@ func (c *Converger) Converge(leases []controller.Lease) error {
 	return nil
 }
 
+// convergeRule keeps the ip rule that looks the overlay network up in
+// RouteTable. The other rules for the overlay network are left over from an
+// earlier table or priority and are deleted; the routes of an earlier table
+// are not, as without a rule they carry no traffic.
+func (c *Converger) convergeRule() error {
+	existing, err := c.NetlinkAdapter.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		return fmt.Errorf("list ip rules: %s", err)
+	}
+
+	desired := c.RouteTable != 0 && !c.NoOverlay
+	present := false
+	for _, rule := range existing {
+		if rule.Dst == nil || rule.Dst.String() != c.OverlayNetwork.String() {
+			continue
+		}
+		if desired && rule.Table == c.RouteTable && rule.Priority == c.RulePriority {
+			present = true
+			continue
+		}
+		rule := rule
+		err = c.NetlinkAdapter.RuleDel(&rule)
+		if err != nil {
+			return fmt.Errorf("delete ip rule: %s", err)
+		}
+	}
+
+	if !desired || present {
+		return nil
+	}
+	rule := netlink.NewRule()
+	rule.Dst = c.OverlayNetwork
+	rule.Table = c.RouteTable
+	rule.Priority = c.RulePriority
+	err = c.NetlinkAdapter.RuleAdd(rule)
+	if err != nil {
+		return fmt.Errorf("add ip rule: %s", err)
+	}
+	return nil
+}
+
+// deleteOverlayNetworkRoute deletes the route the kernel adds for the address
+// of the VTEP, which would send the traffic to the other cells into the VTEP.
+func (c *Converger) deleteOverlayNetworkRoute(previousRoutes []netlink.Route) error {
+	for _, route := range previousRoutes {
+		route := route
+		if route.LinkIndex == c.LocalVTEP.Index && route.Gw == nil && route.Dst.String() == c.OverlayNetwork.String() {
+			err := c.NetlinkAdapter.RouteDel(&route)
+			if err != nil {
+				return fmt.Errorf("del overlay network route: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// pruneNeighs deletes the ARP and FDB entries of the cells that are no longer
+// in the lease table, so that the neighbor tables of the VTEP do not grow
+// with every cell that ever left the foundation. The pruning goes on past an
+// entry that cannot be deleted, and the first error is returned.
+func (c *Converger) pruneNeighs(neighs []netlink.Neigh) (int, error) {
+	pruned := 0
+	var firstErr error
+	for _, neigh := range neighs {
+		if neigh.LinkIndex != c.LocalVTEP.Index {
+			continue
+		}
+		err := c.NetlinkAdapter.NeighDel(&neigh)
+		if errors.Is(err, syscall.ENOENT) {
+			continue
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("del neigh with ip/hwaddr %s: %s", &neigh, err)
+			}
+			continue
+		}
+		pruned++
+	}
+	if pruned > 0 {
+		c.Logger.Info("pruned-neighbor-entries", lager.Data{"count": pruned})
+	}
+	return pruned, firstErr
+}
+
+// sendNeighMetrics reports the entries the VTEP keeps for the cells in the
+// lease table, which is all it keeps once the others are pruned.
+func (c *Converger) sendNeighMetrics(currentNeighs []netlink.Neigh, pruned int) {
+	fdbEntries, arpEntries := 0, 0
+	for _, neigh := range currentNeighs {
+		if neigh.Family == syscall.AF_BRIDGE {
+			fdbEntries++
+		} else {
+			arpEntries++
+		}
+	}
+	c.MetricSender.SendValue("numberFDBEntries", float64(fdbEntries), "")
+	c.MetricSender.SendValue("numberNeighborEntries", float64(arpEntries), "")
+	c.MetricSender.SendValue("prunedNeighborEntries", float64(pruned), "")
+}
+
 func (c *Converger) isLocal(destNet *net.IPNet) bool {
 	return destNet.String() == c.LocalSubnet.String()
 }
@@ -140,6 +270,17 @@ func (c *Converger) getPreviousState(index int) ([]netlink.Route, []netlink.Neig
 		return nil, nil, fmt.Errorf("list routes: %s", err)
 	}
 
+	if c.RouteTable != 0 {
+		tableRoutes, err := c.NetlinkAdapter.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+			LinkIndex: c.LocalVTEP.Index,
+			Table:     c.RouteTable,
+		}, netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list routes in table %d: %s", c.RouteTable, err)
+		}
+		previousRoutes = append(previousRoutes, tableRoutes...)
+	}
+
 	previousFDBNeighs, err := c.NetlinkAdapter.FDBList(c.LocalVTEP.Index)
 	if err != nil {
 		return nil, nil, fmt.Errorf("list fdb: %s", err)
@@ -162,6 +303,8 @@ func (c *Converger) addRoute(destNet *net.IPNet, destAddr net.IP) (netlink.Route
 		Dst:       destNet,
 		Gw:        destAddr,
 		Src:       c.LocalSubnet.IP,
+		Table:     c.RouteTable,
+		Priority:  c.RouteMetric,
 	}
 
 	err := c.NetlinkAdapter.RouteReplace(&route)
@@ -209,7 +352,18 @@ func routeEqual(r1, r2 netlink.Route) bool {
 		r1.Scope == r2.Scope &&
 		r1.Dst.String() == r2.Dst.String() &&
 		r1.Gw.String() == r2.Gw.String() &&
-		r1.Src.String() == r2.Src.String()
+		r1.Src.String() == r2.Src.String() &&
+		routeTable(r1) == routeTable(r2) &&
+		r1.Priority == r2.Priority
+}
+
+// routeTable is the table of a route, which the kernel lists as the main
+// table when it was installed without one.
+func routeTable(route netlink.Route) int {
+	if route.Table == 0 {
+		return syscall.RT_TABLE_MAIN
+	}
+	return route.Table
 }
 
 func neighEqual(n1, n2 netlink.Neigh) bool {
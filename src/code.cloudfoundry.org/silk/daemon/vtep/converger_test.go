@@ -17,18 +17,20 @@ import (
 
 var _ = Describe("Converger", func() {
 	var (
-		fakeNetlink *fakes.NetlinkAdapter
-		converger   *vtep.Converger
-		leases      []controller.Lease
-		overlayNet  *net.IPNet
-		logger      *lagertest.TestLogger
-		localMac    net.HardwareAddr
-		remoteMac   net.HardwareAddr
+		fakeNetlink      *fakes.NetlinkAdapter
+		fakeMetricSender *fakes.MetricSender
+		converger        *vtep.Converger
+		leases           []controller.Lease
+		overlayNet       *net.IPNet
+		logger           *lagertest.TestLogger
+		localMac         net.HardwareAddr
+		remoteMac        net.HardwareAddr
 	)
 
 	Describe("Converge", func() {
 		BeforeEach(func() {
 			fakeNetlink = &fakes.NetlinkAdapter{}
+			fakeMetricSender = &fakes.MetricSender{}
 			_, localSubnet, _ := net.ParseCIDR("10.255.32.0/24")
 			_, overlayNet, _ = net.ParseCIDR("10.255.0.0/16")
 			logger = lagertest.NewTestLogger("test")
@@ -41,6 +43,7 @@ var _ = Describe("Converger", func() {
 				LocalSubnet:    localSubnet,
 				LocalVTEP:      localVTEP,
 				NetlinkAdapter: fakeNetlink,
+				MetricSender:   fakeMetricSender,
 				Logger:         logger,
 			}
 			localMac, _ = net.ParseMAC("ee:ee:aa:bb:cc:dd")
@@ -103,6 +106,23 @@ var _ = Describe("Converger", func() {
 			))
 		})
 
+		Context("when a remote cell failed over to its secondary underlay", func() {
+			BeforeEach(func() {
+				leases[1].FailoverUnderlayIP = "10.11.0.5"
+			})
+
+			It("sends its overlay traffic to the failover underlay ip", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.NeighSetCallCount()).To(Equal(2))
+				fdb := fakeNetlink.NeighSetArgsForCall(1)
+				Expect(fdb.Family).To(Equal(syscall.AF_BRIDGE))
+				Expect(fdb.IP.String()).To(Equal("10.11.0.5"))
+				Expect(fdb.HardwareAddr).To(Equal(remoteMac))
+			})
+		})
+
 		It("does not log anything about non-routable leases", func() {
 			err := converger.Converge(leases)
 			Expect(err).NotTo(HaveOccurred())
@@ -228,6 +248,55 @@ var _ = Describe("Converger", func() {
 				))
 			})
 
+			It("reports the entries that are kept and the ones that are pruned", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeMetricSender.SendValueCallCount()).To(Equal(3))
+				metrics := map[string]float64{}
+				for i := 0; i < fakeMetricSender.SendValueCallCount(); i++ {
+					name, value, _ := fakeMetricSender.SendValueArgsForCall(i)
+					metrics[name] = value
+				}
+				Expect(metrics).To(Equal(map[string]float64{
+					"numberFDBEntries":      1,
+					"numberNeighborEntries": 1,
+					"prunedNeighborEntries": 2,
+				}))
+			})
+
+			Context("when an entry is already gone", func() {
+				BeforeEach(func() {
+					fakeNetlink.NeighDelReturnsOnCall(0, syscall.ENOENT)
+				})
+
+				It("does not count it as pruned", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.NeighDelCallCount()).To(Equal(2))
+					name, value, _ := fakeMetricSender.SendValueArgsForCall(2)
+					Expect(name).To(Equal("prunedNeighborEntries"))
+					Expect(value).To(Equal(1.0))
+				})
+			})
+
+			Context("when deleting an entry fails", func() {
+				BeforeEach(func() {
+					fakeNetlink.NeighDelReturnsOnCall(0, errors.New("mango"))
+				})
+
+				It("prunes the other entries and returns the error", func() {
+					err := converger.Converge(leases)
+					Expect(err).To(MatchError(HavePrefix("del neigh with ip/hwaddr")))
+
+					Expect(fakeNetlink.NeighDelCallCount()).To(Equal(2))
+					name, value, _ := fakeMetricSender.SendValueArgsForCall(2)
+					Expect(name).To(Equal("prunedNeighborEntries"))
+					Expect(value).To(Equal(1.0))
+				})
+			})
+
 		})
 
 		Context("when there are other routing rules", func() {
@@ -251,6 +320,231 @@ var _ = Describe("Converger", func() {
 			})
 		})
 
+		Context("when the VTEP is in no-overlay mode", func() {
+			var overlayNetworkRoute netlink.Route
+
+			BeforeEach(func() {
+				converger.NoOverlay = true
+				destGW, destNet, _ := net.ParseCIDR("10.255.19.0/24")
+				overlayNetworkRoute = netlink.Route{
+					LinkIndex: 42,
+					Scope:     netlink.SCOPE_LINK,
+					Dst:       overlayNet,
+					Src:       net.ParseIP("10.255.32.0").To4(),
+				}
+				fakeNetlink.RouteListReturns([]netlink.Route{
+					overlayNetworkRoute,
+					{
+						LinkIndex: 42,
+						Scope:     netlink.SCOPE_UNIVERSE,
+						Dst:       destNet,
+						Gw:        destGW,
+						Src:       net.ParseIP("10.255.32.0").To4(),
+					},
+				}, nil)
+			})
+
+			It("removes the routes to the other cells and to the overlay network", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(0))
+				Expect(fakeNetlink.NeighSetCallCount()).To(Equal(0))
+				Expect(fakeNetlink.RouteDelCallCount()).To(Equal(2))
+				Expect(fakeNetlink.RouteDelArgsForCall(0).Dst.String()).To(Equal("10.255.19.0/24"))
+				Expect(fakeNetlink.RouteDelArgsForCall(1)).To(Equal(&overlayNetworkRoute))
+			})
+
+			Context("when deleting the route to the overlay network fails", func() {
+				BeforeEach(func() {
+					fakeNetlink.RouteDelReturnsOnCall(1, errors.New("lime"))
+				})
+
+				It("returns a meaningful error", func() {
+					err := converger.Converge(leases)
+					Expect(err).To(MatchError("del overlay network route: lime"))
+				})
+			})
+		})
+
+		Context("when the routes are installed in a dedicated table", func() {
+			var destGW net.IP
+			var destNet *net.IPNet
+
+			BeforeEach(func() {
+				converger.RouteTable = 300
+				converger.RouteMetric = 50
+				converger.RulePriority = 900
+				destGW, destNet, _ = net.ParseCIDR("10.255.19.0/24")
+			})
+
+			It("installs the routes in the table with the metric", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RouteReplaceCallCount()).To(Equal(1))
+				Expect(fakeNetlink.RouteReplaceArgsForCall(0)).To(Equal(&netlink.Route{
+					LinkIndex: 42,
+					Scope:     netlink.SCOPE_UNIVERSE,
+					Dst:       destNet,
+					Gw:        destGW,
+					Src:       net.ParseIP("10.255.32.0").To4(),
+					Table:     300,
+					Priority:  50,
+				}))
+			})
+
+			It("lists the routes of the table", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RouteListFilteredCallCount()).To(Equal(1))
+				family, filter, mask := fakeNetlink.RouteListFilteredArgsForCall(0)
+				Expect(family).To(Equal(netlink.FAMILY_V4))
+				Expect(filter).To(Equal(&netlink.Route{LinkIndex: 42, Table: 300}))
+				Expect(mask).To(Equal(netlink.RT_FILTER_OIF | netlink.RT_FILTER_TABLE))
+			})
+
+			It("adds an ip rule that looks the overlay network up in the table", func() {
+				err := converger.Converge(leases)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlink.RuleAddCallCount()).To(Equal(1))
+				rule := fakeNetlink.RuleAddArgsForCall(0)
+				Expect(rule.Dst).To(Equal(overlayNet))
+				Expect(rule.Table).To(Equal(300))
+				Expect(rule.Priority).To(Equal(900))
+			})
+
+			Context("when the routes were installed in the main table", func() {
+				BeforeEach(func() {
+					fakeNetlink.RouteListReturns([]netlink.Route{
+						{
+							LinkIndex: 42,
+							Scope:     netlink.SCOPE_UNIVERSE,
+							Dst:       destNet,
+							Gw:        destGW,
+							Src:       net.ParseIP("10.255.32.0").To4(),
+							Table:     syscall.RT_TABLE_MAIN,
+						},
+					}, nil)
+					fakeNetlink.RouteListFilteredReturns([]netlink.Route{
+						{
+							LinkIndex: 42,
+							Scope:     netlink.SCOPE_UNIVERSE,
+							Dst:       destNet,
+							Gw:        destGW,
+							Src:       net.ParseIP("10.255.32.0").To4(),
+							Table:     300,
+							Priority:  50,
+						},
+					}, nil)
+				})
+
+				It("deletes them from the main table only", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.RouteDelCallCount()).To(Equal(1))
+					Expect(fakeNetlink.RouteDelArgsForCall(0).Table).To(Equal(syscall.RT_TABLE_MAIN))
+				})
+			})
+
+			Context("when the ip rule is present", func() {
+				BeforeEach(func() {
+					rule := netlink.NewRule()
+					rule.Dst = overlayNet
+					rule.Table = 300
+					rule.Priority = 900
+					fakeNetlink.RuleListReturns([]netlink.Rule{*rule}, nil)
+				})
+
+				It("keeps it", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.RuleAddCallCount()).To(Equal(0))
+					Expect(fakeNetlink.RuleDelCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when there are ip rules of an earlier configuration", func() {
+				BeforeEach(func() {
+					_, siteNet, _ := net.ParseCIDR("10.0.0.0/8")
+					site := netlink.NewRule()
+					site.Dst = siteNet
+					site.Table = 100
+					site.Priority = 500
+					earlier := netlink.NewRule()
+					earlier.Dst = overlayNet
+					earlier.Table = 299
+					earlier.Priority = 900
+					fakeNetlink.RuleListReturns([]netlink.Rule{*site, *earlier}, nil)
+				})
+
+				It("deletes the rules for the overlay network only", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.RuleDelCallCount()).To(Equal(1))
+					Expect(fakeNetlink.RuleDelArgsForCall(0).Table).To(Equal(299))
+					Expect(fakeNetlink.RuleAddCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when the VTEP is in no-overlay mode", func() {
+				BeforeEach(func() {
+					converger.NoOverlay = true
+					rule := netlink.NewRule()
+					rule.Dst = overlayNet
+					rule.Table = 300
+					rule.Priority = 900
+					fakeNetlink.RuleListReturns([]netlink.Rule{*rule}, nil)
+				})
+
+				It("deletes the ip rule", func() {
+					err := converger.Converge(leases)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeNetlink.RuleDelCallCount()).To(Equal(1))
+					Expect(fakeNetlink.RuleAddCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the routes of the table cannot be listed", func() {
+				BeforeEach(func() {
+					fakeNetlink.RouteListFilteredReturns(nil, errors.New("plum"))
+				})
+
+				It("returns a meaningful error", func() {
+					err := converger.Converge(leases)
+					Expect(err).To(MatchError("list routes in table 300: plum"))
+				})
+			})
+
+			Context("when the ip rules cannot be listed", func() {
+				BeforeEach(func() {
+					fakeNetlink.RuleListReturns(nil, errors.New("quince"))
+				})
+
+				It("returns a meaningful error", func() {
+					err := converger.Converge(leases)
+					Expect(err).To(MatchError("list ip rules: quince"))
+				})
+			})
+
+			Context("when the ip rule cannot be added", func() {
+				BeforeEach(func() {
+					fakeNetlink.RuleAddReturns(errors.New("fig"))
+				})
+
+				It("returns a meaningful error", func() {
+					err := converger.Converge(leases)
+					Expect(err).To(MatchError("add ip rule: fig"))
+				})
+			})
+		})
+
 		Context("when the link cannot be found", func() {
 			BeforeEach(func() {
 				fakeNetlink.LinkByIndexReturns(nil, errors.New("passionfruit"))
@@ -21,7 +21,11 @@ type netlinkAdapter interface {
 	RouteAdd(*netlink.Route) error
 	RouteReplace(*netlink.Route) error
 	RouteList(netlink.Link, int) ([]netlink.Route, error)
+	RouteListFiltered(int, *netlink.Route, uint64) ([]netlink.Route, error)
 	RouteDel(*netlink.Route) error
+	RuleAdd(*netlink.Rule) error
+	RuleDel(*netlink.Rule) error
+	RuleList(int) ([]netlink.Rule, error)
 	LinkDel(netlink.Link) error
 	NeighSet(*netlink.Neigh) error
 	ARPList(index int) ([]netlink.Neigh, error)
@@ -29,6 +33,11 @@ type netlinkAdapter interface {
 	NeighDel(*netlink.Neigh) error
 }
 
+// vxlanOverhead is the size of the headers that the VTEP adds to the packets
+// it sends over IPv4, which the kernel subtracts from the MTU of the underlay
+// device of a VTEP.
+const vxlanOverhead = 50
+
 type Factory struct {
 	NetlinkAdapter netlinkAdapter
 	Logger         lager.Logger
@@ -59,6 +68,18 @@ func (f *Factory) CreateVTEP(cfg *Config) error {
 		VtepDevIndex: cfg.UnderlayInterface.Index,
 		GBP:          true,
 	}
+	if cfg.SecondaryUnderlayIP != nil {
+		// with a secondary underlay, the VTEP is not bound to either one:
+		// the kernel sends the overlay traffic to each cell from the address
+		// of the underlay that routes to it
+		vxlan.SrcAddr = nil
+		vxlan.VtepDevIndex = 0
+		mtu := cfg.UnderlayInterface.MTU
+		if cfg.SecondaryUnderlayInterface.MTU < mtu {
+			mtu = cfg.SecondaryUnderlayInterface.MTU
+		}
+		vxlan.MTU = mtu - vxlanOverhead
+	}
 	err := f.NetlinkAdapter.LinkAdd(vxlan)
 	if err != nil {
 		return fmt.Errorf("create link %s: %s", cfg.VTEPName, err)
@@ -95,6 +116,20 @@ func (f *Factory) DeleteVTEP(deviceName string) error {
 	return nil
 }
 
+// IsBoundToUnderlay tells whether the VTEP sends through a single underlay
+// device, which it does unless it was created with a secondary underlay.
+func (f *Factory) IsBoundToUnderlay(vtepName string) (bool, error) {
+	link, err := f.NetlinkAdapter.LinkByName(vtepName)
+	if err != nil {
+		return false, fmt.Errorf("find link: %s", err)
+	}
+	vxlan, ok := link.(*netlink.Vxlan)
+	if !ok {
+		return false, fmt.Errorf("link %s is not a vxlan device", vtepName)
+	}
+	return vxlan.VtepDevIndex != 0, nil
+}
+
 func (f *Factory) GetVTEPState(vtepName string) (net.HardwareAddr, net.IP, int, error) {
 	link, err := f.NetlinkAdapter.LinkByName(vtepName)
 	if err != nil {
@@ -87,6 +87,34 @@ var _ = Describe("Factory", func() {
 			}))
 		})
 
+		Context("when the cell has a secondary underlay", func() {
+			BeforeEach(func() {
+				vtepConfig.SecondaryUnderlayIP = net.IP{172, 254, 0, 0}
+				vtepConfig.SecondaryUnderlayInterface = net.Interface{
+					Index: 5,
+					MTU:   1400,
+					Name:  "eth5",
+				}
+			})
+
+			It("creates the link without binding it to either underlay", func() {
+				err := factory.CreateVTEP(vtepConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeNetlinkAdapter.LinkAddCallCount()).To(Equal(1))
+				Expect(fakeNetlinkAdapter.LinkAddArgsForCall(0)).To(Equal(&netlink.Vxlan{
+					LinkAttrs: netlink.LinkAttrs{
+						Name:         "some-device",
+						HardwareAddr: overlayMAC,
+						MTU:          1350,
+					},
+					VxlanId: 99,
+					GBP:     true,
+					Port:    4913,
+				}))
+			})
+		})
+
 		Context("when adding the link fails", func() {
 			BeforeEach(func() {
 				fakeNetlinkAdapter.LinkAddReturns(errors.New("potato"))
@@ -189,6 +217,41 @@ var _ = Describe("Factory", func() {
 		})
 	})
 
+	Describe("IsBoundToUnderlay", func() {
+		It("tells whether the vtep has an underlay device", func() {
+			fakeNetlinkAdapter.LinkByNameReturns(&netlink.Vxlan{VtepDevIndex: 4}, nil)
+			bound, err := factory.IsBoundToUnderlay("some-device")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bound).To(BeTrue())
+			Expect(fakeNetlinkAdapter.LinkByNameArgsForCall(0)).To(Equal("some-device"))
+
+			fakeNetlinkAdapter.LinkByNameReturns(&netlink.Vxlan{}, nil)
+			bound, err = factory.IsBoundToUnderlay("some-device")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bound).To(BeFalse())
+		})
+
+		Context("when the link cannot be found", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(nil, errors.New("banana"))
+			})
+			It("returns an error", func() {
+				_, err := factory.IsBoundToUnderlay("some-device")
+				Expect(err).To(MatchError("find link: banana"))
+			})
+		})
+
+		Context("when the link is not a vxlan device", func() {
+			BeforeEach(func() {
+				fakeNetlinkAdapter.LinkByNameReturns(&netlink.Dummy{}, nil)
+			})
+			It("returns an error", func() {
+				_, err := factory.IsBoundToUnderlay("some-device")
+				Expect(err).To(MatchError("link some-device is not a vxlan device"))
+			})
+		})
+	})
+
 	Describe("DeleteVTEP", func() {
 		BeforeEach(func() {
 			fakeNetlinkAdapter.LinkByNameReturns(&netlink.Vxlan{
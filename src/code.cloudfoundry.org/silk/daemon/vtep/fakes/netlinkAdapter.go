@@ -131,6 +131,21 @@ type NetlinkAdapter struct {
 		result1 []netlink.Route
 		result2 error
 	}
+	RouteListFilteredStub        func(int, *netlink.Route, uint64) ([]netlink.Route, error)
+	routeListFilteredMutex       sync.RWMutex
+	routeListFilteredArgsForCall []struct {
+		arg1 int
+		arg2 *netlink.Route
+		arg3 uint64
+	}
+	routeListFilteredReturns struct {
+		result1 []netlink.Route
+		result2 error
+	}
+	routeListFilteredReturnsOnCall map[int]struct {
+		result1 []netlink.Route
+		result2 error
+	}
 	RouteDelStub        func(*netlink.Route) error
 	routeDelMutex       sync.RWMutex
 	routeDelArgsForCall []struct {
@@ -142,6 +157,41 @@ type NetlinkAdapter struct {
 	routeDelReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RuleAddStub        func(*netlink.Rule) error
+	ruleAddMutex       sync.RWMutex
+	ruleAddArgsForCall []struct {
+		arg1 *netlink.Rule
+	}
+	ruleAddReturns struct {
+		result1 error
+	}
+	ruleAddReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RuleDelStub        func(*netlink.Rule) error
+	ruleDelMutex       sync.RWMutex
+	ruleDelArgsForCall []struct {
+		arg1 *netlink.Rule
+	}
+	ruleDelReturns struct {
+		result1 error
+	}
+	ruleDelReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RuleListStub        func(int) ([]netlink.Rule, error)
+	ruleListMutex       sync.RWMutex
+	ruleListArgsForCall []struct {
+		arg1 int
+	}
+	ruleListReturns struct {
+		result1 []netlink.Rule
+		result2 error
+	}
+	ruleListReturnsOnCall map[int]struct {
+		result1 []netlink.Rule
+		result2 error
+	}
 	LinkDelStub        func(netlink.Link) error
 	linkDelMutex       sync.RWMutex
 	linkDelArgsForCall []struct {
@@ -701,6 +751,59 @@ func (fake *NetlinkAdapter) RouteListReturnsOnCall(i int, result1 []netlink.Rout
 	}{result1, result2}
 }
 
+func (fake *NetlinkAdapter) RouteListFiltered(arg1 int, arg2 *netlink.Route, arg3 uint64) ([]netlink.Route, error) {
+	fake.routeListFilteredMutex.Lock()
+	ret, specificReturn := fake.routeListFilteredReturnsOnCall[len(fake.routeListFilteredArgsForCall)]
+	fake.routeListFilteredArgsForCall = append(fake.routeListFilteredArgsForCall, struct {
+		arg1 int
+		arg2 *netlink.Route
+		arg3 uint64
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("RouteListFiltered", []interface{}{arg1, arg2, arg3})
+	fake.routeListFilteredMutex.Unlock()
+	if fake.RouteListFilteredStub != nil {
+		return fake.RouteListFilteredStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.routeListFilteredReturns.result1, fake.routeListFilteredReturns.result2
+}
+
+func (fake *NetlinkAdapter) RouteListFilteredCallCount() int {
+	fake.routeListFilteredMutex.RLock()
+	defer fake.routeListFilteredMutex.RUnlock()
+	return len(fake.routeListFilteredArgsForCall)
+}
+
+func (fake *NetlinkAdapter) RouteListFilteredArgsForCall(i int) (int, *netlink.Route, uint64) {
+	fake.routeListFilteredMutex.RLock()
+	defer fake.routeListFilteredMutex.RUnlock()
+	return fake.routeListFilteredArgsForCall[i].arg1, fake.routeListFilteredArgsForCall[i].arg2, fake.routeListFilteredArgsForCall[i].arg3
+}
+
+func (fake *NetlinkAdapter) RouteListFilteredReturns(result1 []netlink.Route, result2 error) {
+	fake.RouteListFilteredStub = nil
+	fake.routeListFilteredReturns = struct {
+		result1 []netlink.Route
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *NetlinkAdapter) RouteListFilteredReturnsOnCall(i int, result1 []netlink.Route, result2 error) {
+	fake.RouteListFilteredStub = nil
+	if fake.routeListFilteredReturnsOnCall == nil {
+		fake.routeListFilteredReturnsOnCall = make(map[int]struct {
+			result1 []netlink.Route
+			result2 error
+		})
+	}
+	fake.routeListFilteredReturnsOnCall[i] = struct {
+		result1 []netlink.Route
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *NetlinkAdapter) RouteDel(arg1 *netlink.Route) error {
 	fake.routeDelMutex.Lock()
 	ret, specificReturn := fake.routeDelReturnsOnCall[len(fake.routeDelArgsForCall)]
@@ -749,6 +852,153 @@ func (fake *NetlinkAdapter) RouteDelReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *NetlinkAdapter) RuleAdd(arg1 *netlink.Rule) error {
+	fake.ruleAddMutex.Lock()
+	ret, specificReturn := fake.ruleAddReturnsOnCall[len(fake.ruleAddArgsForCall)]
+	fake.ruleAddArgsForCall = append(fake.ruleAddArgsForCall, struct {
+		arg1 *netlink.Rule
+	}{arg1})
+	fake.recordInvocation("RuleAdd", []interface{}{arg1})
+	fake.ruleAddMutex.Unlock()
+	if fake.RuleAddStub != nil {
+		return fake.RuleAddStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.ruleAddReturns.result1
+}
+
+func (fake *NetlinkAdapter) RuleAddCallCount() int {
+	fake.ruleAddMutex.RLock()
+	defer fake.ruleAddMutex.RUnlock()
+	return len(fake.ruleAddArgsForCall)
+}
+
+func (fake *NetlinkAdapter) RuleAddArgsForCall(i int) *netlink.Rule {
+	fake.ruleAddMutex.RLock()
+	defer fake.ruleAddMutex.RUnlock()
+	return fake.ruleAddArgsForCall[i].arg1
+}
+
+func (fake *NetlinkAdapter) RuleAddReturns(result1 error) {
+	fake.RuleAddStub = nil
+	fake.ruleAddReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkAdapter) RuleAddReturnsOnCall(i int, result1 error) {
+	fake.RuleAddStub = nil
+	if fake.ruleAddReturnsOnCall == nil {
+		fake.ruleAddReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.ruleAddReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkAdapter) RuleDel(arg1 *netlink.Rule) error {
+	fake.ruleDelMutex.Lock()
+	ret, specificReturn := fake.ruleDelReturnsOnCall[len(fake.ruleDelArgsForCall)]
+	fake.ruleDelArgsForCall = append(fake.ruleDelArgsForCall, struct {
+		arg1 *netlink.Rule
+	}{arg1})
+	fake.recordInvocation("RuleDel", []interface{}{arg1})
+	fake.ruleDelMutex.Unlock()
+	if fake.RuleDelStub != nil {
+		return fake.RuleDelStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.ruleDelReturns.result1
+}
+
+func (fake *NetlinkAdapter) RuleDelCallCount() int {
+	fake.ruleDelMutex.RLock()
+	defer fake.ruleDelMutex.RUnlock()
+	return len(fake.ruleDelArgsForCall)
+}
+
+func (fake *NetlinkAdapter) RuleDelArgsForCall(i int) *netlink.Rule {
+	fake.ruleDelMutex.RLock()
+	defer fake.ruleDelMutex.RUnlock()
+	return fake.ruleDelArgsForCall[i].arg1
+}
+
+func (fake *NetlinkAdapter) RuleDelReturns(result1 error) {
+	fake.RuleDelStub = nil
+	fake.ruleDelReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkAdapter) RuleDelReturnsOnCall(i int, result1 error) {
+	fake.RuleDelStub = nil
+	if fake.ruleDelReturnsOnCall == nil {
+		fake.ruleDelReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.ruleDelReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NetlinkAdapter) RuleList(arg1 int) ([]netlink.Rule, error) {
+	fake.ruleListMutex.Lock()
+	ret, specificReturn := fake.ruleListReturnsOnCall[len(fake.ruleListArgsForCall)]
+	fake.ruleListArgsForCall = append(fake.ruleListArgsForCall, struct {
+		arg1 int
+	}{arg1})
+	fake.recordInvocation("RuleList", []interface{}{arg1})
+	fake.ruleListMutex.Unlock()
+	if fake.RuleListStub != nil {
+		return fake.RuleListStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.ruleListReturns.result1, fake.ruleListReturns.result2
+}
+
+func (fake *NetlinkAdapter) RuleListCallCount() int {
+	fake.ruleListMutex.RLock()
+	defer fake.ruleListMutex.RUnlock()
+	return len(fake.ruleListArgsForCall)
+}
+
+func (fake *NetlinkAdapter) RuleListArgsForCall(i int) int {
+	fake.ruleListMutex.RLock()
+	defer fake.ruleListMutex.RUnlock()
+	return fake.ruleListArgsForCall[i].arg1
+}
+
+func (fake *NetlinkAdapter) RuleListReturns(result1 []netlink.Rule, result2 error) {
+	fake.RuleListStub = nil
+	fake.ruleListReturns = struct {
+		result1 []netlink.Rule
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *NetlinkAdapter) RuleListReturnsOnCall(i int, result1 []netlink.Rule, result2 error) {
+	fake.RuleListStub = nil
+	if fake.ruleListReturnsOnCall == nil {
+		fake.ruleListReturnsOnCall = make(map[int]struct {
+			result1 []netlink.Rule
+			result2 error
+		})
+	}
+	fake.ruleListReturnsOnCall[i] = struct {
+		result1 []netlink.Rule
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *NetlinkAdapter) LinkDel(arg1 netlink.Link) error {
 	fake.linkDelMutex.Lock()
 	ret, specificReturn := fake.linkDelReturnsOnCall[len(fake.linkDelArgsForCall)]
@@ -1018,8 +1268,16 @@ func (fake *NetlinkAdapter) Invocations() map[string][][]interface{} {
 	defer fake.routeReplaceMutex.RUnlock()
 	fake.routeListMutex.RLock()
 	defer fake.routeListMutex.RUnlock()
+	fake.routeListFilteredMutex.RLock()
+	defer fake.routeListFilteredMutex.RUnlock()
 	fake.routeDelMutex.RLock()
 	defer fake.routeDelMutex.RUnlock()
+	fake.ruleAddMutex.RLock()
+	defer fake.ruleAddMutex.RUnlock()
+	fake.ruleDelMutex.RLock()
+	defer fake.ruleDelMutex.RUnlock()
+	fake.ruleListMutex.RLock()
+	defer fake.ruleListMutex.RUnlock()
 	fake.linkDelMutex.RLock()
 	defer fake.linkDelMutex.RUnlock()
 	fake.neighSetMutex.RLock()
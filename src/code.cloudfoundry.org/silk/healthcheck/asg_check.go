@@ -0,0 +1,57 @@
+package healthcheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+)
+
+const asgDefaultDenyCheckName = "asg-default-deny"
+
+//go:generate counterfeiter -o fakes/netout_chain_namer.go --fake-name NetOutChainNamer . netOutChainNamer
+type netOutChainNamer interface {
+	Name(containerHandle string) string
+}
+
+type ASGDefaultDenyCheck struct {
+	IPTables     rules.IPTablesAdapter
+	Datastore    datastore.Datastore
+	NetOutChains netOutChainNamer
+}
+
+func (c *ASGDefaultDenyCheck) Check() Result {
+	containers, err := c.Datastore.ReadAll()
+	if err != nil {
+		return Result{Name: asgDefaultDenyCheckName, Healthy: false, Message: fmt.Sprintf("read datastore: %s", err)}
+	}
+
+	handles := []string{}
+	for handle := range containers {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+
+	problems := []string{}
+	for _, handle := range handles {
+		chain := c.NetOutChains.Name(handle)
+		chainRules, err := c.IPTables.List("filter", chain)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("list %s: %s", chain, err))
+			continue
+		}
+
+		if len(chainRules) == 0 || !strings.Contains(chainRules[len(chainRules)-1], "-j REJECT") {
+			problems = append(problems, fmt.Sprintf("%s does not end in a default REJECT rule", chain))
+		}
+	}
+
+	details := map[string]interface{}{"containers_checked": len(handles)}
+	if len(problems) > 0 {
+		return Result{Name: asgDefaultDenyCheckName, Healthy: false, Message: strings.Join(problems, "; "), Details: details}
+	}
+
+	return Result{Name: asgDefaultDenyCheckName, Healthy: true, Details: details}
+}
@@ -0,0 +1,80 @@
+package healthcheck_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/silk/healthcheck"
+	"code.cloudfoundry.org/silk/healthcheck/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ASGDefaultDenyCheck", func() {
+	var (
+		iptables   *libfakes.IPTablesAdapter
+		store      *libfakes.Datastore
+		chainNamer *fakes.NetOutChainNamer
+		check      *healthcheck.ASGDefaultDenyCheck
+	)
+
+	BeforeEach(func() {
+		iptables = &libfakes.IPTablesAdapter{}
+		store = &libfakes.Datastore{}
+		chainNamer = &fakes.NetOutChainNamer{}
+		chainNamer.NameStub = func(handle string) string {
+			return "netout--" + handle
+		}
+		store.ReadAllReturns(map[string]datastore.Container{
+			"handle-1": {Handle: "handle-1", IP: "10.255.1.2"},
+		}, nil)
+		iptables.ListReturns([]string{
+			"-N netout--handle-1",
+			"-A netout--handle-1 -m state --state RELATED,ESTABLISHED -j ACCEPT",
+			"-A netout--handle-1 -j REJECT --reject-with icmp-port-unreachable",
+		}, nil)
+
+		check = &healthcheck.ASGDefaultDenyCheck{
+			IPTables:     iptables,
+			Datastore:    store,
+			NetOutChains: chainNamer,
+		}
+	})
+
+	It("reports healthy when every netout chain ends in a reject", func() {
+		result := check.Check()
+		Expect(result.Healthy).To(BeTrue())
+		table, chain := iptables.ListArgsForCall(0)
+		Expect(table).To(Equal("filter"))
+		Expect(chain).To(Equal("netout--handle-1"))
+	})
+
+	Context("when a netout chain does not end in a reject", func() {
+		BeforeEach(func() {
+			iptables.ListReturns([]string{
+				"-N netout--handle-1",
+				"-A netout--handle-1 -j ACCEPT",
+			}, nil)
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("netout--handle-1 does not end in a default REJECT rule"))
+		})
+	})
+
+	Context("when listing a netout chain fails", func() {
+		BeforeEach(func() {
+			iptables.ListReturns(nil, errors.New("banana"))
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("list netout--handle-1: banana"))
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package healthcheck
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+//go:generate counterfeiter -o fakes/lease_lister.go --fake-name LeaseLister . leaseLister
+type leaseLister interface {
+	GetActiveLeases() ([]controller.Lease, error)
+}
+
+type DaemonCheckerBuilder struct {
+	VTEPName         string
+	OverlayNetwork   *net.IPNet
+	Lease            controller.Lease
+	VTEPStateGetter  vtepStateGetter
+	LeaseLister      leaseLister
+	Pinger           pinger
+	ProbeTimeout     time.Duration
+	ProbeConcurrency int
+	ProbeDeadline    time.Duration
+}
+
+func (b *DaemonCheckerBuilder) Build(peerCount int) ([]Checker, error) {
+	leases, err := b.LeaseLister.GetActiveLeases()
+	if err != nil {
+		return nil, fmt.Errorf("get active leases: %s", err)
+	}
+
+	lease := b.Lease
+	return []Checker{
+		&VTEPCheck{
+			VTEPName:        b.VTEPName,
+			OverlayNetwork:  b.OverlayNetwork,
+			Lease:           &lease,
+			VTEPStateGetter: b.VTEPStateGetter,
+		},
+		&PeerProbe{
+			LocalUnderlayIP: b.Lease.UnderlayIP,
+			Leases:          leases,
+			Count:           peerCount,
+			Timeout:         b.ProbeTimeout,
+			Concurrency:     b.ProbeConcurrency,
+			Deadline:        b.ProbeDeadline,
+			Pinger:          b.Pinger,
+			Rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		},
+	}, nil
+}
@@ -0,0 +1,71 @@
+package healthcheck_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/healthcheck"
+	"code.cloudfoundry.org/silk/healthcheck/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DaemonCheckerBuilder", func() {
+	var (
+		leaseLister *fakes.LeaseLister
+		builder     *healthcheck.DaemonCheckerBuilder
+		leases      []controller.Lease
+	)
+
+	BeforeEach(func() {
+		leaseLister = &fakes.LeaseLister{}
+		leases = []controller.Lease{
+			{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+			{UnderlayIP: "10.0.0.2", OverlaySubnet: "10.255.2.0/24"},
+		}
+		leaseLister.GetActiveLeasesReturns(leases, nil)
+
+		_, overlayNetwork, _ := net.ParseCIDR("10.255.0.0/16")
+		builder = &healthcheck.DaemonCheckerBuilder{
+			VTEPName:        "silk-vtep",
+			OverlayNetwork:  overlayNetwork,
+			Lease:           leases[0],
+			VTEPStateGetter: &fakes.VTEPStateGetter{},
+			LeaseLister:     leaseLister,
+			Pinger:          &fakes.Pinger{},
+			ProbeTimeout:    time.Second,
+		}
+	})
+
+	It("builds a vtep check and a peer probe over the active leases", func() {
+		checkers, err := builder.Build(5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkers).To(HaveLen(2))
+
+		vtepCheck, ok := checkers[0].(*healthcheck.VTEPCheck)
+		Expect(ok).To(BeTrue())
+		Expect(vtepCheck.VTEPName).To(Equal("silk-vtep"))
+		Expect(*vtepCheck.Lease).To(Equal(leases[0]))
+
+		peerProbe, ok := checkers[1].(*healthcheck.PeerProbe)
+		Expect(ok).To(BeTrue())
+		Expect(peerProbe.LocalUnderlayIP).To(Equal("10.0.0.1"))
+		Expect(peerProbe.Leases).To(Equal(leases))
+		Expect(peerProbe.Count).To(Equal(5))
+		Expect(peerProbe.Timeout).To(Equal(time.Second))
+	})
+
+	Context("when getting the active leases fails", func() {
+		BeforeEach(func() {
+			leaseLister.GetActiveLeasesReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			_, err := builder.Build(5)
+			Expect(err).To(MatchError("get active leases: banana"))
+		})
+	})
+})
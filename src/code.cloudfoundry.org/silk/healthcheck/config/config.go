@@ -0,0 +1,42 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/validator.v2"
+)
+
+type Config struct {
+	ConnectivityServerURL string   `json:"connectivity_server_url" validate:"nonzero"`
+	ServerCACertFile      string   `json:"ca_cert_file" validate:"nonzero"`
+	ClientCertFile        string   `json:"client_cert_file" validate:"nonzero"`
+	ClientKeyFile         string   `json:"client_key_file" validate:"nonzero"`
+	ControllerSPIFFEIDs   []string `json:"controller_spiffe_ids"`
+	SelfTestPort          int      `json:"self_test_port" validate:"min=1"`
+	SelfTestCACertFile    string   `json:"self_test_ca_cert_file" validate:"nonzero"`
+	SelfTestServerName    string   `json:"self_test_server_name" validate:"nonzero"`
+	ClientTimeoutSeconds  int      `json:"client_timeout_seconds" validate:"nonzero"`
+	PeerCount             int      `json:"peer_count"`
+	Concurrency           int      `json:"concurrency" validate:"min=1"`
+	LogPrefix             string   `json:"log_prefix" validate:"nonzero"`
+}
+
+func LoadConfig(filePath string) (Config, error) {
+	var cfg Config
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return cfg, fmt.Errorf("reading file %s: %s", filePath, err)
+	}
+
+	err = json.Unmarshal(contents, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("unmarshaling contents: %s", err)
+	}
+
+	if err := validator.Validate(cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config: %s", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,82 @@
+package config_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/silk/healthcheck/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config.LoadConfig", func() {
+	var requiredFields map[string]interface{}
+
+	writeConfig := func(cfg map[string]interface{}) string {
+		file, err := os.CreateTemp(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(json.NewEncoder(file).Encode(cfg)).To(Succeed())
+		return file.Name()
+	}
+
+	BeforeEach(func() {
+		requiredFields = map[string]interface{}{
+			"connectivity_server_url": "https://silk-controller.something",
+			"ca_cert_file":            "/some/cert/file.pem",
+			"client_cert_file":        "/some/client-cert/file.pem",
+			"client_key_file":         "/some/client-key/file.pem",
+			"self_test_port":          4104,
+			"self_test_ca_cert_file":  "/some/self-test/ca.pem",
+			"self_test_server_name":   "silk-daemon-self-test",
+			"client_timeout_seconds":  5,
+			"concurrency":             10,
+			"log_prefix":              "some-prefix",
+		}
+	})
+
+	It("loads a valid config", func() {
+		cfg := map[string]interface{}{}
+		for k, v := range requiredFields {
+			cfg[k] = v
+		}
+		cfg["peer_count"] = -1
+
+		loadedConfig, err := config.LoadConfig(writeConfig(cfg))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loadedConfig).To(Equal(config.Config{
+			ConnectivityServerURL: "https://silk-controller.something",
+			ServerCACertFile:      "/some/cert/file.pem",
+			ClientCertFile:        "/some/client-cert/file.pem",
+			ClientKeyFile:         "/some/client-key/file.pem",
+			SelfTestPort:          4104,
+			SelfTestCACertFile:    "/some/self-test/ca.pem",
+			SelfTestServerName:    "silk-daemon-self-test",
+			ClientTimeoutSeconds:  5,
+			PeerCount:             -1,
+			Concurrency:           10,
+			LogPrefix:             "some-prefix",
+		}))
+	})
+
+	It("errors if a required field is not set", func() {
+		for fieldName := range requiredFields {
+			cfg := map[string]interface{}{}
+			for k, v := range requiredFields {
+				if k != fieldName {
+					cfg[k] = v
+				}
+			}
+
+			By(fmt.Sprintf("checking that %s is required", fieldName))
+			_, err := config.LoadConfig(writeConfig(cfg))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("invalid config:"))
+		}
+	})
+
+	It("errors if the file does not exist", func() {
+		_, err := config.LoadConfig("/does/not/exist")
+		Expect(err).To(MatchError(ContainSubstring("reading file /does/not/exist")))
+	})
+})
@@ -0,0 +1,148 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+const (
+	ProbeOK      = "ok"
+	ProbeFailed  = "failed"
+	ProbeUnknown = "unknown"
+)
+
+//go:generate counterfeiter -o fakes/report_fetcher.go --fake-name ReportFetcher . reportFetcher
+type reportFetcher interface {
+	FetchReport(underlayIP string) (Report, error)
+}
+
+type CellResult struct {
+	UnderlayIP string `json:"underlay_ip"`
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error,omitempty"`
+	Report     Report `json:"report"`
+}
+
+type MatrixReport struct {
+	Healthy  bool                         `json:"healthy"`
+	Cells    []CellResult                 `json:"cells"`
+	Matrix   map[string]map[string]string `json:"matrix"`
+	Failures []string                     `json:"failures"`
+}
+
+type Fanout struct {
+	Fetcher     reportFetcher
+	Concurrency int
+}
+
+func (f *Fanout) Run(leases []controller.Lease) MatrixReport {
+	cells := make([]CellResult, len(leases))
+
+	concurrency := f.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, lease := range leases {
+		wg.Add(1)
+		go func(i int, underlayIP string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			cells[i] = f.fetch(underlayIP)
+		}(i, lease.UnderlayIP)
+	}
+	wg.Wait()
+
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].UnderlayIP < cells[j].UnderlayIP
+	})
+
+	return buildMatrixReport(leases, cells)
+}
+
+func (f *Fanout) fetch(underlayIP string) CellResult {
+	report, err := f.Fetcher.FetchReport(underlayIP)
+	if err != nil {
+		return CellResult{UnderlayIP: underlayIP, Healthy: false, Error: err.Error()}
+	}
+	return CellResult{UnderlayIP: underlayIP, Healthy: report.Healthy, Report: report}
+}
+
+func buildMatrixReport(leases []controller.Lease, cells []CellResult) MatrixReport {
+	matrix := map[string]map[string]string{}
+	for _, src := range leases {
+		matrix[src.UnderlayIP] = map[string]string{}
+		for _, dst := range leases {
+			if src.UnderlayIP != dst.UnderlayIP {
+				matrix[src.UnderlayIP][dst.UnderlayIP] = ProbeUnknown
+			}
+		}
+	}
+
+	healthy := true
+	failures := []string{}
+	for _, cell := range cells {
+		if cell.Error != "" {
+			healthy = false
+			failures = append(failures, fmt.Sprintf("%s: self-test unreachable: %s", cell.UnderlayIP, cell.Error))
+			continue
+		}
+
+		for _, check := range cell.Report.Checks {
+			if check.Name == peerProbeCheckName {
+				for _, probe := range peerProbeResults(check.Details) {
+					if probe.Error != "" {
+						matrix[cell.UnderlayIP][probe.UnderlayIP] = ProbeFailed
+						failures = append(failures, fmt.Sprintf("%s -> %s: %s", cell.UnderlayIP, probe.UnderlayIP, probe.Error))
+					} else {
+						matrix[cell.UnderlayIP][probe.UnderlayIP] = ProbeOK
+					}
+				}
+				if !check.Healthy {
+					healthy = false
+				}
+				continue
+			}
+
+			if !check.Healthy {
+				healthy = false
+				failures = append(failures, fmt.Sprintf("%s: %s: %s", cell.UnderlayIP, check.Name, check.Message))
+			}
+		}
+
+		if !cell.Healthy {
+			healthy = false
+		}
+	}
+
+	return MatrixReport{
+		Healthy:  healthy,
+		Cells:    cells,
+		Matrix:   matrix,
+		Failures: failures,
+	}
+}
+
+func peerProbeResults(details interface{}) []PeerProbeResult {
+	if results, ok := details.([]PeerProbeResult); ok {
+		return results
+	}
+
+	// reports decoded from json carry generic details
+	bytes, err := json.Marshal(details)
+	if err != nil {
+		return nil
+	}
+	var results []PeerProbeResult
+	if err := json.Unmarshal(bytes, &results); err != nil {
+		return nil
+	}
+	return results
+}
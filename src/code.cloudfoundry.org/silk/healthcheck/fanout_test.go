@@ -0,0 +1,126 @@
+package healthcheck_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/healthcheck"
+	"code.cloudfoundry.org/silk/healthcheck/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fanout", func() {
+	var (
+		fetcher *fakes.ReportFetcher
+		fanout  *healthcheck.Fanout
+		leases  []controller.Lease
+		reports map[string]healthcheck.Report
+	)
+
+	peerProbe := func(healthy bool, results ...healthcheck.PeerProbeResult) healthcheck.Result {
+		return healthcheck.Result{Name: "peer-probe", Healthy: healthy, Details: results}
+	}
+
+	BeforeEach(func() {
+		leases = []controller.Lease{
+			{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+			{UnderlayIP: "10.0.0.2", OverlaySubnet: "10.255.2.0/24"},
+		}
+		reports = map[string]healthcheck.Report{
+			"10.0.0.1": {
+				UnderlayIP: "10.0.0.1",
+				Healthy:    true,
+				Checks: []healthcheck.Result{
+					{Name: "vtep-config", Healthy: true},
+					peerProbe(true, healthcheck.PeerProbeResult{UnderlayIP: "10.0.0.2", OverlayIP: "10.255.2.0"}),
+				},
+			},
+			"10.0.0.2": {
+				UnderlayIP: "10.0.0.2",
+				Healthy:    true,
+				Checks: []healthcheck.Result{
+					{Name: "vtep-config", Healthy: true},
+					peerProbe(true, healthcheck.PeerProbeResult{UnderlayIP: "10.0.0.1", OverlayIP: "10.255.1.0"}),
+				},
+			},
+		}
+
+		fetcher = &fakes.ReportFetcher{}
+		fetcher.FetchReportStub = func(underlayIP string) (healthcheck.Report, error) {
+			report, ok := reports[underlayIP]
+			if !ok {
+				return healthcheck.Report{}, errors.New("connection refused")
+			}
+			return report, nil
+		}
+
+		fanout = &healthcheck.Fanout{
+			Fetcher:     fetcher,
+			Concurrency: 2,
+		}
+	})
+
+	It("fetches every cell's report and builds a connectivity matrix", func() {
+		matrixReport := fanout.Run(leases)
+		Expect(fetcher.FetchReportCallCount()).To(Equal(2))
+		Expect(matrixReport.Healthy).To(BeTrue())
+		Expect(matrixReport.Failures).To(BeEmpty())
+		Expect(matrixReport.Matrix).To(Equal(map[string]map[string]string{
+			"10.0.0.1": {"10.0.0.2": "ok"},
+			"10.0.0.2": {"10.0.0.1": "ok"},
+		}))
+		Expect(matrixReport.Cells).To(HaveLen(2))
+		Expect(matrixReport.Cells[0].UnderlayIP).To(Equal("10.0.0.1"))
+		Expect(matrixReport.Cells[1].UnderlayIP).To(Equal("10.0.0.2"))
+	})
+
+	Context("when a peer probe fails", func() {
+		BeforeEach(func() {
+			report := reports["10.0.0.2"]
+			report.Healthy = false
+			report.Checks[1] = peerProbe(false, healthcheck.PeerProbeResult{UnderlayIP: "10.0.0.1", OverlayIP: "10.255.1.0", Error: "i/o timeout"})
+			reports["10.0.0.2"] = report
+		})
+
+		It("marks the pair as failed", func() {
+			matrixReport := fanout.Run(leases)
+			Expect(matrixReport.Healthy).To(BeFalse())
+			Expect(matrixReport.Matrix["10.0.0.2"]["10.0.0.1"]).To(Equal("failed"))
+			Expect(matrixReport.Failures).To(ConsistOf("10.0.0.2 -> 10.0.0.1: i/o timeout"))
+		})
+	})
+
+	Context("when a non-probe check fails", func() {
+		BeforeEach(func() {
+			report := reports["10.0.0.1"]
+			report.Healthy = false
+			report.Checks[0] = healthcheck.Result{Name: "vtep-config", Healthy: false, Message: "bad mtu"}
+			reports["10.0.0.1"] = report
+		})
+
+		It("reports the failure", func() {
+			matrixReport := fanout.Run(leases)
+			Expect(matrixReport.Healthy).To(BeFalse())
+			Expect(matrixReport.Failures).To(ConsistOf("10.0.0.1: vtep-config: bad mtu"))
+		})
+	})
+
+	Context("when a cell cannot be reached", func() {
+		BeforeEach(func() {
+			leases = append(leases, controller.Lease{UnderlayIP: "10.0.0.3", OverlaySubnet: "10.255.3.0/24"})
+		})
+
+		It("reports the cell as unreachable and its row as unknown", func() {
+			matrixReport := fanout.Run(leases)
+			Expect(matrixReport.Healthy).To(BeFalse())
+			Expect(matrixReport.Failures).To(ConsistOf("10.0.0.3: self-test unreachable: connection refused"))
+			Expect(matrixReport.Matrix["10.0.0.3"]).To(Equal(map[string]string{
+				"10.0.0.1": "unknown",
+				"10.0.0.2": "unknown",
+			}))
+			Expect(matrixReport.Matrix["10.0.0.1"]["10.0.0.3"]).To(Equal("unknown"))
+		})
+	})
+})
@@ -0,0 +1,59 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const allPeers = -1
+
+//go:generate counterfeiter -o fakes/checker_builder.go --fake-name CheckerBuilder . checkerBuilder
+type checkerBuilder interface {
+	Build(peerCount int) ([]Checker, error)
+}
+
+type SelfTestHandler struct {
+	Logger         lager.Logger
+	UnderlayIP     string
+	CheckerBuilder checkerBuilder
+}
+
+func (h *SelfTestHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := h.Logger.Session("self-test")
+
+	peerCount := allPeers
+	if value := req.URL.Query().Get("peer_count"); value != "" {
+		var err error
+		peerCount, err = strconv.Atoi(value)
+		if err != nil {
+			logger.Error("parse-peer-count", err)
+			http.Error(w, "invalid peer_count", http.StatusBadRequest)
+			return
+		}
+	}
+
+	checkers, err := h.CheckerBuilder.Build(peerCount)
+	if err != nil {
+		logger.Error("build-checkers", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := BuildReport(h.UnderlayIP, checkers...)
+	if !report.Healthy {
+		logger.Info("unhealthy", lager.Data{"report": report})
+	}
+
+	bytes, err := json.Marshal(report)
+	if err != nil {
+		logger.Error("marshal-report", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes)
+}
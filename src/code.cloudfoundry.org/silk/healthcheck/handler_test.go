@@ -0,0 +1,86 @@
+package healthcheck_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/silk/healthcheck"
+	"code.cloudfoundry.org/silk/healthcheck/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelfTestHandler", func() {
+	var (
+		checkerBuilder *fakes.CheckerBuilder
+		checker        *fakes.Checker
+		handler        *healthcheck.SelfTestHandler
+		resp           *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		checker = &fakes.Checker{}
+		checker.CheckReturns(healthcheck.Result{Name: "some-check", Healthy: true})
+		checkerBuilder = &fakes.CheckerBuilder{}
+		checkerBuilder.BuildReturns([]healthcheck.Checker{checker}, nil)
+
+		handler = &healthcheck.SelfTestHandler{
+			Logger:         lagertest.NewTestLogger("test"),
+			UnderlayIP:     "10.0.0.1",
+			CheckerBuilder: checkerBuilder,
+		}
+		resp = httptest.NewRecorder()
+	})
+
+	It("runs the checks against all peers and returns the report", func() {
+		req := httptest.NewRequest("GET", "/self-test", nil)
+		handler.ServeHTTP(resp, req)
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(checkerBuilder.BuildArgsForCall(0)).To(Equal(-1))
+
+		var report healthcheck.Report
+		Expect(json.Unmarshal(resp.Body.Bytes(), &report)).To(Succeed())
+		Expect(report).To(Equal(healthcheck.Report{
+			UnderlayIP: "10.0.0.1",
+			Healthy:    true,
+			Checks:     []healthcheck.Result{{Name: "some-check", Healthy: true}},
+		}))
+	})
+
+	It("passes the requested peer count to the builder", func() {
+		req := httptest.NewRequest("GET", "/self-test?peer_count=2", nil)
+		handler.ServeHTTP(resp, req)
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(checkerBuilder.BuildArgsForCall(0)).To(Equal(2))
+	})
+
+	Context("when the peer count is invalid", func() {
+		It("returns a bad request", func() {
+			req := httptest.NewRequest("GET", "/self-test?peer_count=banana", nil)
+			handler.ServeHTTP(resp, req)
+
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(checkerBuilder.BuildCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when building the checkers fails", func() {
+		BeforeEach(func() {
+			checkerBuilder.BuildReturns(nil, errors.New("banana"))
+		})
+
+		It("returns an internal server error", func() {
+			req := httptest.NewRequest("GET", "/self-test", nil)
+			handler.ServeHTTP(resp, req)
+
+			Expect(resp.Code).To(Equal(http.StatusInternalServerError))
+			Expect(resp.Body.String()).To(ContainSubstring("banana"))
+		})
+	})
+})
@@ -0,0 +1,150 @@
+package healthcheck
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+const (
+	peerProbeCheckName          = "peer-probe"
+	defaultPeerProbeConcurrency = 16
+)
+
+//go:generate counterfeiter -o fakes/pinger.go --fake-name Pinger . pinger
+type pinger interface {
+	Ping(destination net.IP, timeout time.Duration) (time.Duration, error)
+}
+
+type PeerProbeResult struct {
+	UnderlayIP    string `json:"underlay_ip"`
+	OverlayIP     string `json:"overlay_ip"`
+	RoundTripTime string `json:"round_trip_time,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// PeerProbe pings the overlay address of peer cells. Up to Concurrency peers
+// are probed at once, and peers not probed within Deadline are reported as
+// failed, so that probing every peer of a large foundation stays bounded.
+type PeerProbe struct {
+	LocalUnderlayIP string
+	Leases          []controller.Lease
+	Count           int
+	Timeout         time.Duration
+	Concurrency     int
+	Deadline        time.Duration
+	Pinger          pinger
+	Rand            *rand.Rand
+}
+
+func (p *PeerProbe) Check() Result {
+	peers := p.selectPeers()
+	if len(peers) == 0 {
+		return Result{Name: peerProbeCheckName, Healthy: true, Message: "no peer cells to probe", Details: []PeerProbeResult{}}
+	}
+
+	healthy := true
+	var failures int
+	results := p.probeAll(peers)
+	for _, result := range results {
+		if result.Error != "" {
+			healthy = false
+			failures++
+		}
+	}
+
+	message := ""
+	if !healthy {
+		message = fmt.Sprintf("%d of %d peer probes failed", failures, len(peers))
+	}
+
+	return Result{Name: peerProbeCheckName, Healthy: healthy, Message: message, Details: results}
+}
+
+func (p *PeerProbe) probeAll(peers []controller.Lease) []PeerProbeResult {
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPeerProbeConcurrency
+	}
+	var deadline time.Time
+	if p.Deadline > 0 {
+		deadline = time.Now().Add(p.Deadline)
+	}
+
+	results := make([]PeerProbeResult, len(peers))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(peers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = p.probe(peers[i], deadline)
+			}
+		}()
+	}
+	for i := range peers {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func (p *PeerProbe) probe(peer controller.Lease, deadline time.Time) PeerProbeResult {
+	result := PeerProbeResult{UnderlayIP: peer.UnderlayIP}
+
+	overlayIP, _, err := net.ParseCIDR(peer.OverlaySubnet)
+	if err != nil {
+		result.Error = fmt.Sprintf("parse overlay subnet: %s", err)
+		return result
+	}
+	result.OverlayIP = overlayIP.String()
+
+	timeout := p.Timeout
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			result.Error = "not probed: deadline exceeded"
+			return result
+		}
+		if remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	rtt, err := p.Pinger.Ping(overlayIP, timeout)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.RoundTripTime = rtt.String()
+
+	return result
+}
+
+func (p *PeerProbe) selectPeers() []controller.Lease {
+	var peers []controller.Lease
+	for _, lease := range p.Leases {
+		if lease.UnderlayIP != p.LocalUnderlayIP {
+			peers = append(peers, lease)
+		}
+	}
+
+	if p.Rand != nil {
+		p.Rand.Shuffle(len(peers), func(i, j int) {
+			peers[i], peers[j] = peers[j], peers[i]
+		})
+	}
+
+	if p.Count >= 0 && len(peers) > p.Count {
+		peers = peers[:p.Count]
+	}
+
+	return peers
+}
@@ -0,0 +1,167 @@
+package healthcheck_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/healthcheck"
+	"code.cloudfoundry.org/silk/healthcheck/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PeerProbe", func() {
+	var (
+		pinger *fakes.Pinger
+		probe  *healthcheck.PeerProbe
+	)
+
+	BeforeEach(func() {
+		pinger = &fakes.Pinger{}
+		pinger.PingReturns(2*time.Millisecond, nil)
+
+		probe = &healthcheck.PeerProbe{
+			LocalUnderlayIP: "10.0.0.1",
+			Leases: []controller.Lease{
+				{UnderlayIP: "10.0.0.1", OverlaySubnet: "10.255.1.0/24"},
+				{UnderlayIP: "10.0.0.2", OverlaySubnet: "10.255.2.0/24"},
+				{UnderlayIP: "10.0.0.3", OverlaySubnet: "10.255.3.0/24"},
+			},
+			Count:   5,
+			Timeout: time.Second,
+			Pinger:  pinger,
+		}
+	})
+
+	It("pings the overlay vtep ip of every peer except itself", func() {
+		result := probe.Check()
+		Expect(result.Healthy).To(BeTrue())
+		Expect(pinger.PingCallCount()).To(Equal(2))
+
+		var pinged []string
+		for i := 0; i < pinger.PingCallCount(); i++ {
+			ip, timeout := pinger.PingArgsForCall(i)
+			Expect(timeout).To(Equal(time.Second))
+			pinged = append(pinged, ip.String())
+		}
+		Expect(pinged).To(ConsistOf("10.255.2.0", "10.255.3.0"))
+
+		Expect(result.Details).To(Equal([]healthcheck.PeerProbeResult{
+			{UnderlayIP: "10.0.0.2", OverlayIP: "10.255.2.0", RoundTripTime: "2ms"},
+			{UnderlayIP: "10.0.0.3", OverlayIP: "10.255.3.0", RoundTripTime: "2ms"},
+		}))
+	})
+
+	Context("when count is smaller than the number of peers", func() {
+		BeforeEach(func() {
+			probe.Count = 1
+			probe.Rand = rand.New(rand.NewSource(1))
+		})
+
+		It("only probes that many peers", func() {
+			probe.Check()
+			Expect(pinger.PingCallCount()).To(Equal(1))
+			ip, _ := pinger.PingArgsForCall(0)
+			Expect(ip.String()).NotTo(Equal("10.255.1.0"))
+		})
+	})
+
+	Context("when there are no peers", func() {
+		BeforeEach(func() {
+			probe.Leases = probe.Leases[:1]
+		})
+
+		It("reports healthy without probing", func() {
+			result := probe.Check()
+			Expect(result.Healthy).To(BeTrue())
+			Expect(result.Message).To(Equal("no peer cells to probe"))
+			Expect(pinger.PingCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a ping fails", func() {
+		BeforeEach(func() {
+			pinger.PingStub = func(ip net.IP, _ time.Duration) (time.Duration, error) {
+				if ip.String() == "10.255.3.0" {
+					return 0, errors.New("i/o timeout")
+				}
+				return time.Millisecond, nil
+			}
+		})
+
+		It("reports unhealthy with the failing peer", func() {
+			result := probe.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("1 of 2 peer probes failed"))
+			Expect(result.Details).To(ContainElement(healthcheck.PeerProbeResult{
+				UnderlayIP: "10.0.0.3",
+				OverlayIP:  "10.255.3.0",
+				Error:      "i/o timeout",
+			}))
+		})
+	})
+
+	Context("when probing many peers", func() {
+		BeforeEach(func() {
+			probe.Count = -1
+			probe.Leases = nil
+			for i := 0; i < 20; i++ {
+				probe.Leases = append(probe.Leases, controller.Lease{
+					UnderlayIP:    fmt.Sprintf("10.0.1.%d", i),
+					OverlaySubnet: fmt.Sprintf("10.255.%d.0/24", 100+i),
+				})
+			}
+			probe.Concurrency = 4
+		})
+
+		It("probes at most Concurrency peers at once", func() {
+			var inFlight, maxInFlight int32
+			pinger.PingStub = func(net.IP, time.Duration) (time.Duration, error) {
+				n := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					m := atomic.LoadInt32(&maxInFlight)
+					if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				return time.Millisecond, nil
+			}
+
+			result := probe.Check()
+			Expect(result.Healthy).To(BeTrue())
+			Expect(pinger.PingCallCount()).To(Equal(20))
+			Expect(atomic.LoadInt32(&maxInFlight)).To(BeNumerically("<=", 4))
+			Expect(result.Details).To(HaveLen(20))
+		})
+
+		Context("when the deadline passes", func() {
+			BeforeEach(func() {
+				probe.Concurrency = 1
+				probe.Deadline = 50 * time.Millisecond
+				pinger.PingStub = func(net.IP, time.Duration) (time.Duration, error) {
+					time.Sleep(20 * time.Millisecond)
+					return time.Millisecond, nil
+				}
+			})
+
+			It("reports the peers it did not get to as failed", func() {
+				result := probe.Check()
+				Expect(result.Healthy).To(BeFalse())
+				Expect(pinger.PingCallCount()).To(BeNumerically("<", 20))
+				Expect(result.Details).To(ContainElement(HaveField("Error", "not probed: deadline exceeded")))
+				for i := 0; i < pinger.PingCallCount(); i++ {
+					_, timeout := pinger.PingArgsForCall(i)
+					Expect(timeout).To(BeNumerically("<=", 50*time.Millisecond))
+				}
+			})
+		})
+	})
+})
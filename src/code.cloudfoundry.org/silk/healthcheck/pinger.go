@@ -0,0 +1,89 @@
+package healthcheck
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	icmpEchoRequest = 8
+	icmpEchoReply   = 0
+	ipv4HeaderLen   = 20
+)
+
+type ICMPPinger struct{}
+
+func (ICMPPinger) Ping(destination net.IP, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return 0, fmt.Errorf("listen icmp: %s", err)
+	}
+	defer conn.Close()
+
+	id := os.Getpid() & 0xffff
+	request := echoRequest(id, 1)
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, fmt.Errorf("set deadline: %s", err)
+	}
+
+	if _, err := conn.WriteTo(request, &net.IPAddr{IP: destination}); err != nil {
+		return 0, fmt.Errorf("send echo request: %s", err)
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(reply)
+		if err != nil {
+			return 0, fmt.Errorf("read echo reply: %s", err)
+		}
+
+		peerAddr, ok := peer.(*net.IPAddr)
+		if !ok || !peerAddr.IP.Equal(destination) {
+			continue
+		}
+
+		message := reply[:n]
+		// raw ip4 sockets may deliver the ip header along with the icmp message
+		if len(message) >= ipv4HeaderLen && message[0]>>4 == 4 {
+			message = message[int(message[0]&0x0f)*4:]
+		}
+
+		if len(message) < 8 || message[0] != icmpEchoReply {
+			continue
+		}
+		if int(binary.BigEndian.Uint16(message[4:6])) != id {
+			continue
+		}
+
+		return time.Since(start), nil
+	}
+}
+
+func echoRequest(id, seq int) []byte {
+	message := make([]byte, 16)
+	message[0] = icmpEchoRequest
+	binary.BigEndian.PutUint16(message[4:6], uint16(id))
+	binary.BigEndian.PutUint16(message[6:8], uint16(seq))
+	copy(message[8:], []byte("silk-hc!"))
+	binary.BigEndian.PutUint16(message[2:4], checksum(message))
+	return message
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,96 @@
+package healthcheck
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+)
+
+const policyMarkCheckName = "policy-marks"
+
+var (
+	jumpRegex        = regexp.MustCompile(`-j (\S+)`)
+	policyChainRegex = rules.ManagedChainRegexp("vpa--")
+	sourceRegex      = regexp.MustCompile(`-s ([0-9.]+)(/32)?`)
+	setMarkRegex     = regexp.MustCompile(`--set-xmark (0x[0-9a-fA-F]+)`)
+)
+
+type PolicyMarkCheck struct {
+	IPTables  rules.IPTablesAdapter
+	Datastore datastore.Datastore
+}
+
+func (c *PolicyMarkCheck) Check() Result {
+	containers, err := c.Datastore.ReadAll()
+	if err != nil {
+		return c.unhealthy(fmt.Sprintf("read datastore: %s", err), nil)
+	}
+
+	localIPs := map[string]string{}
+	for handle, container := range containers {
+		localIPs[container.IP] = handle
+	}
+
+	forwardRules, err := c.IPTables.List("filter", "FORWARD")
+	if err != nil {
+		return c.unhealthy(fmt.Sprintf("list FORWARD chain: %s", err), nil)
+	}
+
+	policyChain := ""
+	for _, rule := range forwardRules {
+		if matches := jumpRegex.FindStringSubmatch(rule); matches != nil && policyChainRegex.MatchString(matches[1]) {
+			policyChain = matches[1]
+			break
+		}
+	}
+	if policyChain == "" {
+		return c.unhealthy("no jump to a vpa policy chain found in FORWARD", nil)
+	}
+
+	policyRules, err := c.IPTables.List("filter", policyChain)
+	if err != nil {
+		return c.unhealthy(fmt.Sprintf("list %s chain: %s", policyChain, err), nil)
+	}
+
+	marks := map[string]string{}
+	problems := []string{}
+	for _, rule := range policyRules {
+		markMatches := setMarkRegex.FindStringSubmatch(rule)
+		if markMatches == nil {
+			continue
+		}
+		sourceMatches := sourceRegex.FindStringSubmatch(rule)
+		if sourceMatches == nil {
+			continue
+		}
+
+		sourceIP, mark := sourceMatches[1], markMatches[1]
+		if _, ok := localIPs[sourceIP]; !ok {
+			problems = append(problems, fmt.Sprintf("mark %s set for %s which is not a local container", mark, sourceIP))
+			continue
+		}
+		if existing, ok := marks[sourceIP]; ok && existing != mark {
+			problems = append(problems, fmt.Sprintf("conflicting marks %s and %s set for %s", existing, mark, sourceIP))
+			continue
+		}
+		marks[sourceIP] = mark
+	}
+
+	details := map[string]interface{}{
+		"policy_chain": policyChain,
+		"marks":        marks,
+	}
+
+	if len(problems) > 0 {
+		return c.unhealthy(strings.Join(problems, "; "), details)
+	}
+
+	return Result{Name: policyMarkCheckName, Healthy: true, Details: details}
+}
+
+func (c *PolicyMarkCheck) unhealthy(message string, details interface{}) Result {
+	return Result{Name: policyMarkCheckName, Healthy: false, Message: message, Details: details}
+}
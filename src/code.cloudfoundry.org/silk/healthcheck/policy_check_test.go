@@ -0,0 +1,156 @@
+package healthcheck_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/silk/healthcheck"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PolicyMarkCheck", func() {
+	var (
+		iptables    *libfakes.IPTablesAdapter
+		store       *libfakes.Datastore
+		check       *healthcheck.PolicyMarkCheck
+		policyRules []string
+	)
+
+	BeforeEach(func() {
+		iptables = &libfakes.IPTablesAdapter{}
+		store = &libfakes.Datastore{}
+		store.ReadAllReturns(map[string]datastore.Container{
+			"handle-1": {Handle: "handle-1", IP: "10.255.1.2"},
+			"handle-2": {Handle: "handle-2", IP: "10.255.1.3"},
+		}, nil)
+
+		policyRules = []string{
+			"-N vpa--1234567890",
+			"-A vpa--1234567890 -s 10.255.1.2/32 -m comment --comment \"src:app-1\" -j MARK --set-xmark 0x1/0xffffffff",
+			"-A vpa--1234567890 -s 10.255.1.3/32 -m comment --comment \"src:app-2\" -j MARK --set-xmark 0x2/0xffffffff",
+		}
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			if chain == "FORWARD" {
+				return []string{
+					"-P FORWARD ACCEPT",
+					"-A FORWARD -j vpa--1234567890",
+					"-A FORWARD -i silk-vtep -j istio-ingress",
+				}, nil
+			}
+			return policyRules, nil
+		}
+
+		check = &healthcheck.PolicyMarkCheck{
+			IPTables:  iptables,
+			Datastore: store,
+		}
+	})
+
+	It("reports healthy when marks are only set for local containers", func() {
+		result := check.Check()
+		Expect(result.Healthy).To(BeTrue())
+		Expect(result.Details).To(Equal(map[string]interface{}{
+			"policy_chain": "vpa--1234567890",
+			"marks": map[string]string{
+				"10.255.1.2": "0x1",
+				"10.255.1.3": "0x2",
+			},
+		}))
+
+		table, chain := iptables.ListArgsForCall(1)
+		Expect(table).To(Equal("filter"))
+		Expect(chain).To(Equal("vpa--1234567890"))
+	})
+
+	Context("when the policy chain has a version 1 name", func() {
+		BeforeEach(func() {
+			policyRules = []string{
+				"-N vpa--v1-g7cs183k3a",
+				"-A vpa--v1-g7cs183k3a -s 10.255.1.2/32 -m comment --comment \"src:app-1\" -j MARK --set-xmark 0x1/0xffffffff",
+			}
+			iptables.ListStub = func(table, chain string) ([]string, error) {
+				if chain == "FORWARD" {
+					return []string{
+						"-P FORWARD ACCEPT",
+						"-A FORWARD -j vpa--v1-g7cs183k3a",
+					}, nil
+				}
+				return policyRules, nil
+			}
+		})
+
+		It("checks the marks of that chain", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeTrue())
+			Expect(result.Details).To(HaveKeyWithValue("policy_chain", "vpa--v1-g7cs183k3a"))
+
+			_, chain := iptables.ListArgsForCall(1)
+			Expect(chain).To(Equal("vpa--v1-g7cs183k3a"))
+		})
+	})
+
+	Context("when a mark is set for a non-local ip", func() {
+		BeforeEach(func() {
+			policyRules = append(policyRules, "-A vpa--1234567890 -s 10.255.9.9/32 -j MARK --set-xmark 0x3/0xffffffff")
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("mark 0x3 set for 10.255.9.9 which is not a local container"))
+		})
+	})
+
+	Context("when conflicting marks are set for the same ip", func() {
+		BeforeEach(func() {
+			policyRules = append(policyRules, "-A vpa--1234567890 -s 10.255.1.2/32 -j MARK --set-xmark 0x4/0xffffffff")
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("conflicting marks 0x1 and 0x4 set for 10.255.1.2"))
+		})
+	})
+
+	Context("when there is no jump to a policy chain", func() {
+		BeforeEach(func() {
+			iptables.ListStub = nil
+			iptables.ListReturns([]string{"-P FORWARD ACCEPT"}, nil)
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("no jump to a vpa policy chain found in FORWARD"))
+		})
+	})
+
+	Context("when reading the datastore fails", func() {
+		BeforeEach(func() {
+			store.ReadAllReturns(nil, errors.New("banana"))
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("read datastore: banana"))
+		})
+	})
+
+	Context("when listing iptables fails", func() {
+		BeforeEach(func() {
+			iptables.ListStub = nil
+			iptables.ListReturns(nil, errors.New("banana"))
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("list FORWARD chain: banana"))
+		})
+	})
+})
@@ -0,0 +1,37 @@
+package healthcheck
+
+//go:generate counterfeiter -o fakes/checker.go --fake-name Checker . Checker
+type Checker interface {
+	Check() Result
+}
+
+type Result struct {
+	Name    string      `json:"name"`
+	Healthy bool        `json:"healthy"`
+	Message string      `json:"message,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type Report struct {
+	UnderlayIP string   `json:"underlay_ip"`
+	Healthy    bool     `json:"healthy"`
+	Checks     []Result `json:"checks"`
+}
+
+func BuildReport(underlayIP string, checkers ...Checker) Report {
+	report := Report{
+		UnderlayIP: underlayIP,
+		Healthy:    true,
+		Checks:     []Result{},
+	}
+
+	for _, checker := range checkers {
+		result := checker.Check()
+		if !result.Healthy {
+			report.Healthy = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report
+}
@@ -0,0 +1,46 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+type HTTPReportFetcher struct {
+	Client    *http.Client
+	Scheme    string
+	Port      int
+	PeerCount int
+}
+
+func (f *HTTPReportFetcher) FetchReport(underlayIP string) (Report, error) {
+	scheme := f.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s/self-test?peer_count=%d", scheme, net.JoinHostPort(underlayIP, strconv.Itoa(f.Port)), f.PeerCount)
+
+	resp, err := f.Client.Get(url)
+	if err != nil {
+		return Report{}, fmt.Errorf("get self-test: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Report{}, fmt.Errorf("read body: %s", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Report{}, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+
+	var report Report
+	if err := json.Unmarshal(body, &report); err != nil {
+		return Report{}, fmt.Errorf("unmarshal report: %s", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,86 @@
+package healthcheck_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+
+	"code.cloudfoundry.org/silk/healthcheck"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPReportFetcher", func() {
+	var (
+		server     *httptest.Server
+		statusCode int
+		requests   []*http.Request
+		fetcher    *healthcheck.HTTPReportFetcher
+		host       string
+	)
+
+	BeforeEach(func() {
+		statusCode = http.StatusOK
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			w.WriteHeader(statusCode)
+			json.NewEncoder(w).Encode(healthcheck.Report{UnderlayIP: "127.0.0.1", Healthy: true})
+		}))
+
+		serverURL, err := url.Parse(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		var port string
+		host, port, err = net.SplitHostPort(serverURL.Host)
+		Expect(err).NotTo(HaveOccurred())
+		portInt, err := strconv.Atoi(port)
+		Expect(err).NotTo(HaveOccurred())
+
+		fetcher = &healthcheck.HTTPReportFetcher{
+			Client:    server.Client(),
+			Scheme:    "http",
+			Port:      portInt,
+			PeerCount: -1,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fetches the self-test report from the cell", func() {
+		report, err := fetcher.FetchReport(host)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Healthy).To(BeTrue())
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.Path).To(Equal("/self-test"))
+		Expect(requests[0].URL.Query().Get("peer_count")).To(Equal("-1"))
+	})
+
+	Context("when the cell responds with an error", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusInternalServerError
+		})
+
+		It("returns an error", func() {
+			_, err := fetcher.FetchReport(host)
+			Expect(err).To(MatchError(ContainSubstring("unexpected status code 500")))
+		})
+	})
+
+	Context("when the cell cannot be reached", func() {
+		BeforeEach(func() {
+			server.Close()
+		})
+
+		It("returns an error", func() {
+			_, err := fetcher.FetchReport(host)
+			Expect(err).To(MatchError(ContainSubstring("get self-test")))
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package healthcheck_test
+
+import (
+	"code.cloudfoundry.org/silk/healthcheck"
+	"code.cloudfoundry.org/silk/healthcheck/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BuildReport", func() {
+	var (
+		healthyChecker   *fakes.Checker
+		unhealthyChecker *fakes.Checker
+	)
+
+	BeforeEach(func() {
+		healthyChecker = &fakes.Checker{}
+		healthyChecker.CheckReturns(healthcheck.Result{Name: "good", Healthy: true})
+		unhealthyChecker = &fakes.Checker{}
+		unhealthyChecker.CheckReturns(healthcheck.Result{Name: "bad", Healthy: false, Message: "banana"})
+	})
+
+	It("runs every checker and reports healthy when all pass", func() {
+		report := healthcheck.BuildReport("10.0.0.1", healthyChecker, healthyChecker)
+		Expect(healthyChecker.CheckCallCount()).To(Equal(2))
+		Expect(report.UnderlayIP).To(Equal("10.0.0.1"))
+		Expect(report.Healthy).To(BeTrue())
+		Expect(report.Checks).To(HaveLen(2))
+	})
+
+	It("reports unhealthy when any check fails", func() {
+		report := healthcheck.BuildReport("10.0.0.1", healthyChecker, unhealthyChecker)
+		Expect(report.Healthy).To(BeFalse())
+		Expect(report.Checks).To(Equal([]healthcheck.Result{
+			{Name: "good", Healthy: true},
+			{Name: "bad", Healthy: false, Message: "banana"},
+		}))
+	})
+})
@@ -0,0 +1,68 @@
+package healthcheck
+
+import (
+	"fmt"
+	"net"
+
+	"code.cloudfoundry.org/silk/controller"
+)
+
+const vtepCheckName = "vtep-config"
+
+//go:generate counterfeiter -o fakes/vtep_state_getter.go --fake-name VTEPStateGetter . vtepStateGetter
+type vtepStateGetter interface {
+	GetVTEPState(vtepName string) (net.HardwareAddr, net.IP, int, error)
+}
+
+type VTEPCheck struct {
+	VTEPName        string
+	OverlayNetwork  *net.IPNet
+	Lease           *controller.Lease
+	VTEPStateGetter vtepStateGetter
+}
+
+func (c *VTEPCheck) Check() Result {
+	if c.Lease == nil {
+		return c.unhealthy("no active lease found for this cell", nil)
+	}
+
+	hardwareAddr, overlayIP, mtu, err := c.VTEPStateGetter.GetVTEPState(c.VTEPName)
+	if err != nil {
+		return c.unhealthy(fmt.Sprintf("get vtep state: %s", err), nil)
+	}
+
+	details := map[string]interface{}{
+		"vtep_name":      c.VTEPName,
+		"overlay_ip":     overlayIP.String(),
+		"hardware_addr":  hardwareAddr.String(),
+		"mtu":            mtu,
+		"overlay_subnet": c.Lease.OverlaySubnet,
+	}
+
+	leaseIP, leaseSubnet, err := net.ParseCIDR(c.Lease.OverlaySubnet)
+	if err != nil {
+		return c.unhealthy(fmt.Sprintf("parse lease subnet: %s", err), details)
+	}
+
+	if !c.OverlayNetwork.Contains(leaseSubnet.IP) {
+		return c.unhealthy(fmt.Sprintf("lease subnet %s is not in overlay network %s", leaseSubnet, c.OverlayNetwork), details)
+	}
+
+	if !overlayIP.Equal(leaseIP) {
+		return c.unhealthy(fmt.Sprintf("vtep overlay ip %s does not match lease %s", overlayIP, leaseIP), details)
+	}
+
+	if hardwareAddr.String() != c.Lease.OverlayHardwareAddr {
+		return c.unhealthy(fmt.Sprintf("vtep hardware addr %s does not match lease %s", hardwareAddr, c.Lease.OverlayHardwareAddr), details)
+	}
+
+	if mtu <= 0 {
+		return c.unhealthy(fmt.Sprintf("invalid vtep mtu: %d", mtu), details)
+	}
+
+	return Result{Name: vtepCheckName, Healthy: true, Details: details}
+}
+
+func (c *VTEPCheck) unhealthy(message string, details interface{}) Result {
+	return Result{Name: vtepCheckName, Healthy: false, Message: message, Details: details}
+}
@@ -0,0 +1,105 @@
+package healthcheck_test
+
+import (
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/silk/controller"
+	"code.cloudfoundry.org/silk/healthcheck"
+	"code.cloudfoundry.org/silk/healthcheck/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VTEPCheck", func() {
+	var (
+		vtepStateGetter *fakes.VTEPStateGetter
+		check           *healthcheck.VTEPCheck
+	)
+
+	BeforeEach(func() {
+		vtepStateGetter = &fakes.VTEPStateGetter{}
+		_, overlayNetwork, _ := net.ParseCIDR("10.255.0.0/16")
+		hwAddr, _ := net.ParseMAC("ee:ee:0a:ff:1e:00")
+		vtepStateGetter.GetVTEPStateReturns(hwAddr, net.ParseIP("10.255.30.0"), 1410, nil)
+
+		check = &healthcheck.VTEPCheck{
+			VTEPName:       "silk-vtep",
+			OverlayNetwork: overlayNetwork,
+			Lease: &controller.Lease{
+				UnderlayIP:          "10.0.0.1",
+				OverlaySubnet:       "10.255.30.0/24",
+				OverlayHardwareAddr: "ee:ee:0a:ff:1e:00",
+			},
+			VTEPStateGetter: vtepStateGetter,
+		}
+	})
+
+	It("reports healthy when the vtep matches the lease", func() {
+		result := check.Check()
+		Expect(result.Healthy).To(BeTrue())
+		Expect(result.Name).To(Equal("vtep-config"))
+		Expect(vtepStateGetter.GetVTEPStateArgsForCall(0)).To(Equal("silk-vtep"))
+	})
+
+	Context("when there is no lease", func() {
+		BeforeEach(func() {
+			check.Lease = nil
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("no active lease found for this cell"))
+		})
+	})
+
+	Context("when getting the vtep state fails", func() {
+		BeforeEach(func() {
+			vtepStateGetter.GetVTEPStateReturns(nil, nil, 0, errors.New("banana"))
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("get vtep state: banana"))
+		})
+	})
+
+	Context("when the lease is outside the overlay network", func() {
+		BeforeEach(func() {
+			check.Lease.OverlaySubnet = "10.254.30.0/24"
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(ContainSubstring("is not in overlay network"))
+		})
+	})
+
+	Context("when the vtep ip does not match the lease", func() {
+		BeforeEach(func() {
+			check.Lease.OverlaySubnet = "10.255.31.0/24"
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(Equal("vtep overlay ip 10.255.30.0 does not match lease 10.255.31.0"))
+		})
+	})
+
+	Context("when the vtep hardware addr does not match the lease", func() {
+		BeforeEach(func() {
+			check.Lease.OverlayHardwareAddr = "ee:ee:0a:ff:1f:00"
+		})
+
+		It("reports unhealthy", func() {
+			result := check.Check()
+			Expect(result.Healthy).To(BeFalse())
+			Expect(result.Message).To(ContainSubstring("does not match lease ee:ee:0a:ff:1f:00"))
+		})
+	})
+})
@@ -21,6 +21,10 @@ func (*NetlinkAdapter) ParseAddr(addr string) (*netlink.Addr, error) {
 	return netlink.ParseAddr(addr)
 }
 
+func (*NetlinkAdapter) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	return netlink.AddrAdd(link, addr)
+}
+
 func (*NetlinkAdapter) AddrAddScopeLink(link netlink.Link, addr *netlink.Addr) error {
 	addr.Scope = int(netlink.SCOPE_LINK)
 	return netlink.AddrAdd(link, addr)
@@ -60,6 +64,10 @@ func (*NetlinkAdapter) NeighDel(neigh *netlink.Neigh) error {
 	return netlink.NeighDel(neigh)
 }
 
+func (*NetlinkAdapter) NeighProxyList(linkIndex, family int) ([]netlink.Neigh, error) {
+	return netlink.NeighProxyList(linkIndex, family)
+}
+
 func (*NetlinkAdapter) LinkSetARPOff(link netlink.Link) error {
 	return netlink.LinkSetARPOff(link)
 }
@@ -100,10 +108,26 @@ func (*NetlinkAdapter) RouteList(link netlink.Link, family int) ([]netlink.Route
 	return netlink.RouteList(link, family)
 }
 
+func (*NetlinkAdapter) RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+	return netlink.RouteListFiltered(family, filter, filterMask)
+}
+
 func (*NetlinkAdapter) RouteDel(route *netlink.Route) error {
 	return netlink.RouteDel(route)
 }
 
+func (*NetlinkAdapter) RuleAdd(rule *netlink.Rule) error {
+	return netlink.RuleAdd(rule)
+}
+
+func (*NetlinkAdapter) RuleDel(rule *netlink.Rule) error {
+	return netlink.RuleDel(rule)
+}
+
+func (*NetlinkAdapter) RuleList(family int) ([]netlink.Rule, error) {
+	return netlink.RuleList(family)
+}
+
 func (*NetlinkAdapter) QdiscAdd(qdisc netlink.Qdisc) error {
 	return netlink.QdiscAdd(qdisc)
 }
@@ -0,0 +1,131 @@
+// Package announce tells the neighbors on a link the hardware address of an
+// address of the link, with a gratuitous ARP for IPv4 and an unsolicited
+// neighbor advertisement for IPv6. The neighbors update the entries they
+// already have for the address, so that its first packets are not sent to a
+// stale hardware address or held back while it is resolved again.
+package announce
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	etherTypeIPv4             = 0x0800
+	arpHardwareEthernet       = 1
+	arpRequest                = 1
+	icmpv6NeighborAdvert      = 136
+	naFlagOverride            = 0x20
+	optTargetLinkLayerAddr    = 2
+	hopLimitNeighborDiscovery = 255
+)
+
+var (
+	ethernetBroadcast = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	allNodes          = net.ParseIP("ff02::1")
+)
+
+// ARPFrame is the Ethernet frame of a gratuitous ARP request for ip: the
+// sender and target addresses are both ip, and it is sent to the broadcast
+// address.
+func ARPFrame(mac net.HardwareAddr, ip net.IP) []byte {
+	frame := append([]byte{}, ethernetBroadcast...)
+	frame = append(frame, mac...)
+	frame = binary.BigEndian.AppendUint16(frame, unix.ETH_P_ARP)
+
+	frame = binary.BigEndian.AppendUint16(frame, arpHardwareEthernet)
+	frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv4)
+	frame = append(frame, byte(len(mac)), net.IPv4len)
+	frame = binary.BigEndian.AppendUint16(frame, arpRequest)
+	frame = append(frame, mac...)
+	frame = append(frame, ip.To4()...)
+	frame = append(frame, make([]byte, len(mac))...)
+	return append(frame, ip.To4()...)
+}
+
+// NAMessage is the ICMPv6 message of an unsolicited neighbor advertisement
+// for ip, as in RFC 4861 7.2.6. The override flag is set so that the
+// neighbors replace the hardware address they have. The checksum is left to
+// the kernel.
+func NAMessage(mac net.HardwareAddr, ip net.IP) []byte {
+	message := []byte{icmpv6NeighborAdvert, 0, 0, 0, naFlagOverride, 0, 0, 0}
+	message = append(message, ip.To16()...)
+	message = append(message, optTargetLinkLayerAddr, byte((2+len(mac)+7)/8))
+	return append(message, mac...)
+}
+
+type Announcer struct{}
+
+// GratuitousARP broadcasts a gratuitous ARP for ip on the link.
+func (*Announcer) GratuitousARP(ifindex int, ip net.IP, mac net.HardwareAddr) error {
+	if ip.To4() == nil {
+		return fmt.Errorf("not an IPv4 address: %s", ip)
+	}
+	if len(mac) != 6 {
+		return errors.New("not an Ethernet hardware address")
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(unix.ETH_P_ARP)))
+	if err != nil {
+		return fmt.Errorf("open packet socket: %s", err)
+	}
+	defer unix.Close(fd)
+
+	addr := &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ARP),
+		Ifindex:  ifindex,
+		Halen:    uint8(len(ethernetBroadcast)),
+	}
+	copy(addr.Addr[:], ethernetBroadcast)
+	err = unix.Sendto(fd, ARPFrame(mac, ip), 0, addr)
+	if err != nil {
+		return fmt.Errorf("send: %s", err)
+	}
+	return nil
+}
+
+// UnsolicitedNA sends an unsolicited neighbor advertisement for ip to all
+// the nodes on the link. ip has to be an address of the link, which it is
+// sent from.
+func (*Announcer) UnsolicitedNA(ifindex int, ip net.IP, mac net.HardwareAddr) error {
+	if ip.To4() != nil || ip.To16() == nil {
+		return fmt.Errorf("not an IPv6 address: %s", ip)
+	}
+
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_RAW, unix.IPPROTO_ICMPV6)
+	if err != nil {
+		return fmt.Errorf("open icmpv6 socket: %s", err)
+	}
+	defer unix.Close(fd)
+
+	// the neighbors drop neighbor discovery messages that may have been
+	// forwarded, i.e. whose hop limit is not 255
+	err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS, hopLimitNeighborDiscovery)
+	if err != nil {
+		return fmt.Errorf("set hop limit: %s", err)
+	}
+
+	source := &unix.SockaddrInet6{ZoneId: uint32(ifindex)}
+	copy(source.Addr[:], ip.To16())
+	err = unix.Bind(fd, source)
+	if err != nil {
+		return fmt.Errorf("bind to %s: %s", ip, err)
+	}
+
+	destination := &unix.SockaddrInet6{ZoneId: uint32(ifindex)}
+	copy(destination.Addr[:], allNodes)
+	err = unix.Sendto(fd, NAMessage(mac, ip), 0, destination)
+	if err != nil {
+		return fmt.Errorf("send: %s", err)
+	}
+	return nil
+}
+
+func htons(v uint16) uint16 {
+	b := binary.BigEndian.AppendUint16(nil, v)
+	return binary.NativeEndian.Uint16(b)
+}
@@ -0,0 +1,13 @@
+package announce_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAnnounce(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Announce Suite")
+}
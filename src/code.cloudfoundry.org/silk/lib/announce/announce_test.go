@@ -0,0 +1,66 @@
+package announce_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/silk/lib/announce"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Announce", func() {
+	var mac net.HardwareAddr
+
+	BeforeEach(func() {
+		var err error
+		mac, err = net.ParseMAC("ee:ee:0a:ff:1e:04")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("ARPFrame", func() {
+		It("is a broadcast ARP request with the address as sender and target", func() {
+			Expect(announce.ARPFrame(mac, net.ParseIP("10.255.30.4"))).To(Equal([]byte{
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xee, 0xee, 0x0a, 0xff, 0x1e, 0x04,
+				0x08, 0x06,
+				0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01,
+				0xee, 0xee, 0x0a, 0xff, 0x1e, 0x04, 10, 255, 30, 4,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 10, 255, 30, 4,
+			}))
+		})
+	})
+
+	Describe("NAMessage", func() {
+		It("is a neighbor advertisement that overrides the cached hardware address", func() {
+			Expect(announce.NAMessage(mac, net.ParseIP("fd00::a:4"))).To(Equal([]byte{
+				136, 0, 0x00, 0x00,
+				0x20, 0x00, 0x00, 0x00,
+				0xfd, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x0a, 0, 0x04,
+				2, 1, 0xee, 0xee, 0x0a, 0xff, 0x1e, 0x04,
+			}))
+		})
+	})
+
+	Describe("Announcer", func() {
+		var announcer *announce.Announcer
+
+		BeforeEach(func() {
+			announcer = &announce.Announcer{}
+		})
+
+		It("only sends a gratuitous ARP for an IPv4 address", func() {
+			err := announcer.GratuitousARP(1, net.ParseIP("fd00::a:4"), mac)
+			Expect(err).To(MatchError("not an IPv4 address: fd00::a:4"))
+		})
+
+		It("only sends a gratuitous ARP with an Ethernet hardware address", func() {
+			err := announcer.GratuitousARP(1, net.ParseIP("10.255.30.4"), net.HardwareAddr{1, 2})
+			Expect(err).To(MatchError("not an Ethernet hardware address"))
+		})
+
+		It("only sends a neighbor advertisement for an IPv6 address", func() {
+			err := announcer.UnsolicitedNA(1, net.ParseIP("10.255.30.4"), mac)
+			Expect(err).To(MatchError("not an IPv6 address: 10.255.30.4"))
+		})
+	})
+})
@@ -0,0 +1,58 @@
+// Package rpfilter holds the reverse path filtering policy of the silk
+// interfaces. The host and container ends of the veth pairs only ever carry
+// the packets of one container, so they filter strictly, which drops
+// packets a container sends from an address that is not its own. The VTEP
+// receives the replies to packets that egress gateways sent on behalf of the
+// containers of the cell, whose sources are routed over the underlay, so it
+// filters loosely. Distributions that default to strict filtering would
+// otherwise silently drop that asymmetric overlay traffic.
+package rpfilter
+
+import "fmt"
+
+// Mode is a reverse path filtering mode of net.ipv4.conf.<device>.rp_filter.
+type Mode string
+
+const (
+	Off    Mode = "off"
+	Strict Mode = "strict"
+	Loose  Mode = "loose"
+)
+
+const (
+	DefaultVeth = Strict
+	DefaultVTEP = Loose
+)
+
+var values = map[Mode]string{
+	Off:    "0",
+	Strict: "1",
+	Loose:  "2",
+}
+
+// Validate accepts the modes and the empty mode, which stands for the
+// default of the interface.
+func (m Mode) Validate() error {
+	if _, ok := values[m]; !ok && m != "" {
+		return fmt.Errorf("invalid reverse path filter %q: must be one of off, strict or loose", m)
+	}
+	return nil
+}
+
+// Or returns the mode, or the given default when the mode is empty.
+func (m Mode) Or(defaultMode Mode) Mode {
+	if m == "" {
+		return defaultMode
+	}
+	return m
+}
+
+// SysctlValue is the value of the rp_filter sysctl for the mode.
+func (m Mode) SysctlValue() string {
+	return values[m]
+}
+
+// SysctlName is the rp_filter sysctl of the device.
+func SysctlName(deviceName string) string {
+	return fmt.Sprintf("net.ipv4.conf.%s.rp_filter", deviceName)
+}
@@ -0,0 +1,13 @@
+package rpfilter_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRPFilter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RPFilter Suite")
+}
@@ -0,0 +1,33 @@
+package rpfilter_test
+
+import (
+	"code.cloudfoundry.org/silk/lib/rpfilter"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mode", func() {
+	DescribeTable("the sysctl values",
+		func(mode rpfilter.Mode, value string) {
+			Expect(mode.Validate()).To(Succeed())
+			Expect(mode.SysctlValue()).To(Equal(value))
+		},
+		Entry("off", rpfilter.Off, "0"),
+		Entry("strict", rpfilter.Strict, "1"),
+		Entry("loose", rpfilter.Loose, "2"),
+	)
+
+	It("accepts the empty mode, which falls back to the default", func() {
+		Expect(rpfilter.Mode("").Validate()).To(Succeed())
+		Expect(rpfilter.Mode("").Or(rpfilter.DefaultVTEP)).To(Equal(rpfilter.Loose))
+		Expect(rpfilter.Off.Or(rpfilter.DefaultVTEP)).To(Equal(rpfilter.Off))
+	})
+
+	It("rejects other modes", func() {
+		Expect(rpfilter.Mode("1").Validate()).To(MatchError(`invalid reverse path filter "1": must be one of off, strict or loose`))
+	})
+
+	It("names the sysctl of a device", func() {
+		Expect(rpfilter.SysctlName("silk-vtep")).To(Equal("net.ipv4.conf.silk-vtep.rp_filter"))
+	})
+})
@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package netsandbox_test
+
+import (
+	"os/exec"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestNetsandbox(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Netsandbox Suite")
+}
+
+func iptablesRulesOfHost() string {
+	output, err := exec.Command("iptables", "-w", "-S", "-t", "filter").CombinedOutput()
+	Expect(err).NotTo(HaveOccurred())
+	return string(output)
+}
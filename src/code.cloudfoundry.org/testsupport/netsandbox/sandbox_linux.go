@@ -0,0 +1,236 @@
+// Package netsandbox runs datapath tests against the kernel instead of fakes.
+// A sandbox is a throwaway network namespace that plays a cell, with its own
+// iptables, and containers are network namespaces connected to it with veth
+// pairs. Traffic between containers is routed through the cell, so the rules
+// the tests enforce in the cell apply to it, e.g. in the FORWARD chain.
+//
+// The sandbox needs root and the iptables binaries.
+package netsandbox
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ip"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/containernetworking/plugins/pkg/testutils"
+	"github.com/vishvananda/netlink"
+)
+
+// DialTimeout is how long Dial waits for a connection, so that rejected and
+// dropped connections fail the same way.
+const DialTimeout = time.Second
+
+// Sandbox is a cell with containers.
+type Sandbox struct {
+	Host       ns.NetNS
+	Containers []*Container
+}
+
+// Container is a network namespace whose eth0 is connected to the cell, with
+// a route through the cell to everywhere.
+type Container struct {
+	Name string
+	NS   ns.NetNS
+	IP   net.IP
+	// HostVeth is the name of the veth of the container in the cell, which
+	// has the gateway address of the container.
+	HostVeth  string
+	GatewayIP net.IP
+}
+
+// New creates a cell that forwards traffic between its containers.
+func New() (*Sandbox, error) {
+	if _, err := exec.LookPath("iptables"); err != nil {
+		return nil, fmt.Errorf("iptables is required: %s", err)
+	}
+
+	host, err := testutils.NewNS()
+	if err != nil {
+		return nil, fmt.Errorf("create host namespace: %s", err)
+	}
+	s := &Sandbox{Host: host}
+
+	err = host.Do(func(ns.NetNS) error {
+		if err := setLoopbackUp(); err != nil {
+			return err
+		}
+		return ip.EnableIP4Forward()
+	})
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("set up host namespace: %s", err)
+	}
+	return s, nil
+}
+
+// AddContainer creates a container with an address like 10.255.1.2/24. The
+// first address of the network, here 10.255.1.1, is the gateway address of
+// the container in the cell, so every container needs a network of its own.
+func (s *Sandbox) AddContainer(name, cidr string) (*Container, error) {
+	containerIP, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	gatewayIP := ip.NextIP(network.IP)
+	if gatewayIP.Equal(containerIP) {
+		return nil, fmt.Errorf("container address %s is the gateway address", cidr)
+	}
+
+	containerNS, err := testutils.NewNS()
+	if err != nil {
+		return nil, fmt.Errorf("create container namespace: %s", err)
+	}
+	c := &Container{
+		Name:      name,
+		NS:        containerNS,
+		IP:        containerIP,
+		GatewayIP: gatewayIP,
+	}
+	s.Containers = append(s.Containers, c)
+
+	err = containerNS.Do(func(ns.NetNS) error {
+		if err := setLoopbackUp(); err != nil {
+			return err
+		}
+		hostVeth, containerVeth, err := ip.SetupVeth("eth0", 1500, "", s.Host)
+		if err != nil {
+			return err
+		}
+		c.HostVeth = hostVeth.Name
+
+		link, err := netlink.LinkByName(containerVeth.Name)
+		if err != nil {
+			return err
+		}
+		err = netlink.AddrAdd(link, &netlink.Addr{IPNet: &net.IPNet{IP: containerIP, Mask: network.Mask}})
+		if err != nil {
+			return fmt.Errorf("add address to %s: %s", containerVeth.Name, err)
+		}
+		if err := netlink.LinkSetUp(link); err != nil {
+			return err
+		}
+		return netlink.RouteAdd(&netlink.Route{LinkIndex: link.Attrs().Index, Gw: gatewayIP})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("set up container %s: %s", name, err)
+	}
+
+	err = s.Host.Do(func(ns.NetNS) error {
+		link, err := netlink.LinkByName(c.HostVeth)
+		if err != nil {
+			return err
+		}
+		return netlink.AddrAdd(link, &netlink.Addr{IPNet: &net.IPNet{IP: gatewayIP, Mask: network.Mask}})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("set up gateway of container %s: %s", name, err)
+	}
+	return c, nil
+}
+
+// Do runs f in the cell, e.g. to enforce rules with a rules.LockedIPTables.
+// Goroutines that f starts do not run in the cell.
+func (s *Sandbox) Do(f func() error) error {
+	return s.Host.Do(func(ns.NetNS) error {
+		return f()
+	})
+}
+
+// Exec runs a command in the cell and returns its combined output.
+func (s *Sandbox) Exec(name string, args ...string) ([]byte, error) {
+	return execIn(s.Host, name, args...)
+}
+
+// IPTablesRules lists the rules of a table of the cell like iptables -S.
+func (s *Sandbox) IPTablesRules(table string) ([]byte, error) {
+	return s.Exec("iptables", "-w", "-S", "-t", table)
+}
+
+// Close removes the containers and the cell with their veths.
+func (s *Sandbox) Close() error {
+	var firstErr error
+	for _, c := range s.Containers {
+		if err := closeNS(c.NS); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.Containers = nil
+	if err := closeNS(s.Host); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// Do runs f in the container.
+func (c *Container) Do(f func() error) error {
+	return c.NS.Do(func(ns.NetNS) error {
+		return f()
+	})
+}
+
+// Exec runs a command in the container and returns its combined output.
+func (c *Container) Exec(name string, args ...string) ([]byte, error) {
+	return execIn(c.NS, name, args...)
+}
+
+// Listen listens in the container, e.g. on tcp :8080. The listener can be
+// served from any goroutine.
+func (c *Container) Listen(network, address string) (net.Listener, error) {
+	var listener net.Listener
+	err := c.Do(func() error {
+		var err error
+		listener, err = net.Listen(network, address)
+		return err
+	})
+	return listener, err
+}
+
+// ListenPacket listens for packets in the container, e.g. on udp :53.
+func (c *Container) ListenPacket(network, address string) (net.PacketConn, error) {
+	var conn net.PacketConn
+	err := c.Do(func() error {
+		var err error
+		conn, err = net.ListenPacket(network, address)
+		return err
+	})
+	return conn, err
+}
+
+// Dial connects from the container within DialTimeout.
+func (c *Container) Dial(network, address string) (net.Conn, error) {
+	var conn net.Conn
+	err := c.Do(func() error {
+		var err error
+		conn, err = net.DialTimeout(network, address, DialTimeout)
+		return err
+	})
+	return conn, err
+}
+
+func execIn(netNS ns.NetNS, name string, args ...string) ([]byte, error) {
+	var output []byte
+	err := netNS.Do(func(ns.NetNS) error {
+		var err error
+		output, err = exec.Command(name, args...).CombinedOutput()
+		return err
+	})
+	return output, err
+}
+
+func setLoopbackUp() error {
+	lo, err := netlink.LinkByName("lo")
+	if err != nil {
+		return err
+	}
+	return netlink.LinkSetUp(lo)
+}
+
+func closeNS(netNS ns.NetNS) error {
+	if err := netNS.Close(); err != nil {
+		return err
+	}
+	return testutils.UnmountNS(netNS)
+}
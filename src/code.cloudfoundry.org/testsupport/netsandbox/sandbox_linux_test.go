@@ -0,0 +1,93 @@
+package netsandbox_test
+
+import (
+	"io"
+	"net"
+
+	"code.cloudfoundry.org/testsupport/netsandbox"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sandbox", func() {
+	var (
+		sandbox    *netsandbox.Sandbox
+		client     *netsandbox.Container
+		server     *netsandbox.Container
+		listener   net.Listener
+		serverAddr string
+	)
+
+	BeforeEach(func() {
+		listener = nil
+		var err error
+		sandbox, err = netsandbox.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		client, err = sandbox.AddContainer("client", "10.255.1.2/24")
+		Expect(err).NotTo(HaveOccurred())
+		server, err = sandbox.AddContainer("server", "10.255.2.2/24")
+		Expect(err).NotTo(HaveOccurred())
+
+		listener, err = server.Listen("tcp", ":8080")
+		Expect(err).NotTo(HaveOccurred())
+		serverAddr = "10.255.2.2:8080"
+		go func() {
+			defer GinkgoRecover()
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Write([]byte("hello"))
+				conn.Close()
+			}
+		}()
+	})
+
+	AfterEach(func() {
+		// the sandbox needs a network namespace, so BeforeEach may fail
+		// before creating it
+		if listener != nil {
+			listener.Close()
+		}
+		if sandbox != nil {
+			Expect(sandbox.Close()).To(Succeed())
+		}
+	})
+
+	It("connects the containers through the cell", func() {
+		Expect(client.HostVeth).NotTo(BeEmpty())
+		Expect(client.GatewayIP.String()).To(Equal("10.255.1.1"))
+
+		conn, err := client.Dial("tcp", serverAddr)
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		Expect(io.ReadAll(conn)).To(Equal([]byte("hello")))
+	})
+
+	It("applies the iptables rules of the cell to the traffic", func() {
+		_, err := sandbox.Exec("iptables", "-w", "-I", "FORWARD", "-d", "10.255.2.2/32", "-p", "tcp", "--dport", "8080", "-j", "REJECT")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sandbox.IPTablesRules("filter")).To(ContainSubstring("-A FORWARD -d 10.255.2.2/32 -p tcp -m tcp --dport 8080 -j REJECT"))
+
+		_, err = client.Dial("tcp", serverAddr)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("does not change the iptables rules of the host", func() {
+		_, err := sandbox.Exec("iptables", "-w", "-N", "netsandbox-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		output, err := sandbox.IPTablesRules("filter")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(output)).To(ContainSubstring("-N netsandbox-test"))
+		Expect(iptablesRulesOfHost()).NotTo(ContainSubstring("netsandbox-test"))
+	})
+
+	It("rejects a container address that is the gateway address", func() {
+		_, err := sandbox.AddContainer("gateway", "10.255.3.1/24")
+		Expect(err).To(MatchError("container address 10.255.3.1/24 is the gateway address"))
+	})
+})
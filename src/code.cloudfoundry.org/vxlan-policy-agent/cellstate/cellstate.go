@@ -0,0 +1,375 @@
+// Package cellstate captures the state that silk manages on a cell into an
+// archive, to look at it after the cell was recreated or to reproduce a bug
+// on another machine, and verifies and restores such archives.
+//
+// An archive is a gzipped tar with a manifest.json, followed by the captured
+// files and command outputs under their names. The manifest records where
+// every entry came from and its checksum.
+package cellstate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/filelock"
+)
+
+const manifestName = "manifest.json"
+
+// File is a file that is captured as is. With a LockPath, the file lock that
+// its writers take is held while the file is read or restored.
+type File struct {
+	Name     string
+	Path     string
+	LockPath string
+}
+
+// Command is a command whose output is captured, e.g. iptables-save. With
+// RestoreArgs, the output can be restored by passing it to the stdin of that
+// command, while the file lock at LockPath is held.
+type Command struct {
+	Name        string
+	Args        []string
+	RestoreArgs []string
+	LockPath    string
+}
+
+// Manifest describes the entries of an archive.
+type Manifest struct {
+	CapturedAt time.Time `json:"captured_at"`
+	Hostname   string    `json:"hostname"`
+	Entries    []Entry   `json:"entries"`
+}
+
+// Entry is a captured file or command output. An entry that could not be
+// captured has no content, but says why, so that the rest of the state is
+// still captured on a broken cell.
+type Entry struct {
+	Name    string   `json:"name"`
+	Path    string   `json:"path,omitempty"`
+	Command []string `json:"command,omitempty"`
+	Size    int      `json:"size"`
+	SHA256  string   `json:"sha256,omitempty"`
+	Missing bool     `json:"missing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func (e Entry) captured() bool {
+	return !e.Missing && e.Error == ""
+}
+
+// Snapshotter writes the Files and the output of the Commands to an archive.
+type Snapshotter struct {
+	Files    []File
+	Commands []Command
+}
+
+func (s *Snapshotter) Snapshot(out io.Writer) (Manifest, error) {
+	hostname, _ := os.Hostname()
+	manifest := Manifest{
+		CapturedAt: time.Now().UTC(),
+		Hostname:   hostname,
+		Entries:    []Entry{},
+	}
+	contents := map[string][]byte{}
+
+	for _, file := range s.Files {
+		entry := Entry{Name: file.Name, Path: file.Path}
+		content, err := readFile(file)
+		switch {
+		case os.IsNotExist(err):
+			entry.Missing = true
+		case err != nil:
+			entry.Error = err.Error()
+		default:
+			entry.setContent(content)
+			contents[entry.Name] = content
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	for _, command := range s.Commands {
+		entry := Entry{Name: command.Name, Command: command.Args}
+		content, err := runCommand(command)
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.setContent(content)
+			contents[entry.Name] = content
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	if err := writeArchive(out, manifest, contents); err != nil {
+		return Manifest{}, fmt.Errorf("write archive: %s", err)
+	}
+	return manifest, nil
+}
+
+func (e *Entry) setContent(content []byte) {
+	sum := sha256.Sum256(content)
+	e.Size = len(content)
+	e.SHA256 = hex.EncodeToString(sum[:])
+}
+
+func readFile(file File) ([]byte, error) {
+	// the lock would create a file that is not there
+	if _, err := os.Stat(file.Path); err != nil {
+		return nil, err
+	}
+	unlock, err := lock(file.LockPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return os.ReadFile(file.Path)
+}
+
+func runCommand(command Command) ([]byte, error) {
+	if len(command.Args) == 0 {
+		return nil, errors.New("no command")
+	}
+	unlock, err := lock(command.LockPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(command.Args[0], command.Args[1:]...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func lock(lockPath string) (func(), error) {
+	if lockPath == "" {
+		return func() {}, nil
+	}
+	lockedFile, err := filelock.NewLocker(lockPath).Open()
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %s", lockPath, err)
+	}
+	return func() { lockedFile.Close() }, nil
+}
+
+func writeArchive(out io.Writer, manifest Manifest, contents map[string][]byte) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err // not tested
+	}
+	if err := writeEntry(tw, manifestName, manifestJSON, manifest.CapturedAt); err != nil {
+		return err
+	}
+	for _, entry := range manifest.Entries {
+		if !entry.captured() {
+			continue
+		}
+		if err := writeEntry(tw, entry.Name, contents[entry.Name], manifest.CapturedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(content)
+	return err
+}
+
+// Read reads an archive and checks that it holds every captured entry of
+// its manifest, with the recorded checksum, and nothing else.
+func Read(in io.Reader) (Manifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return Manifest{}, nil, fmt.Errorf("read archive: %s", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *Manifest
+	contents := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Manifest{}, nil, fmt.Errorf("read archive: %s", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return Manifest{}, nil, fmt.Errorf("read %s: %s", header.Name, err)
+		}
+		if header.Name == manifestName {
+			manifest = &Manifest{}
+			if err := json.Unmarshal(content, manifest); err != nil {
+				return Manifest{}, nil, fmt.Errorf("parse manifest: %s", err)
+			}
+			continue
+		}
+		contents[header.Name] = content
+	}
+	if manifest == nil {
+		return Manifest{}, nil, errors.New("archive has no manifest")
+	}
+
+	problems := []string{}
+	for _, entry := range manifest.Entries {
+		if !entry.captured() {
+			continue
+		}
+		content, ok := contents[entry.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is missing", entry.Name))
+			continue
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != entry.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s does not match its checksum", entry.Name))
+		}
+	}
+	for name := range contents {
+		if !manifest.has(name) {
+			problems = append(problems, fmt.Sprintf("%s is not in the manifest", name))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return Manifest{}, nil, fmt.Errorf("verify archive: %s", strings.Join(problems, ", "))
+	}
+	return *manifest, contents, nil
+}
+
+func (m Manifest) has(name string) bool {
+	for _, entry := range m.Entries {
+		if entry.Name == name && entry.captured() {
+			return true
+		}
+	}
+	return false
+}
+
+// Restorer writes the captured Files of an archive back to their paths below
+// Root, and passes the captured output of the Commands to their RestoreArgs,
+// e.g. to restore the iptables rules, which replaces the state of the machine
+// it runs on. The paths and commands are those of the Restorer, never those of
+// the manifest, so that an archive cannot write or run anything else.
+type Restorer struct {
+	Root     string
+	Files    []File
+	Commands []Command
+}
+
+// Restore returns the names of the restored entries. The archive is verified
+// before anything is restored.
+func (r *Restorer) Restore(in io.Reader) ([]string, error) {
+	_, contents, err := Read(in)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := []string{}
+	for _, file := range r.Files {
+		content, ok := contents[file.Name]
+		if !ok {
+			continue
+		}
+		if err := r.restoreFile(file, content); err != nil {
+			return restored, fmt.Errorf("restore %s: %s", file.Name, err)
+		}
+		restored = append(restored, file.Name)
+	}
+	for _, command := range r.Commands {
+		content, ok := contents[command.Name]
+		if !ok || len(command.RestoreArgs) == 0 {
+			continue
+		}
+		if err := runRestoreCommand(command, content); err != nil {
+			return restored, fmt.Errorf("restore %s: %s", command.Name, err)
+		}
+		restored = append(restored, command.Name)
+	}
+	return restored, nil
+}
+
+func (r *Restorer) path(p string) string {
+	if p == "" {
+		return ""
+	}
+	return filepath.Join(r.Root, p)
+}
+
+func (r *Restorer) restoreFile(file File, content []byte) error {
+	filePath := r.path(file.Path)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	unlock, err := lock(r.path(file.LockPath))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// the lock of some stores is the file itself, so it is rewritten in place
+	// instead of being replaced
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runRestoreCommand(command Command, content []byte) error {
+	unlock, err := lock(command.LockPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cmd := exec.Command(command.RestoreArgs[0], command.RestoreArgs[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package cellstate_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCellState(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CellState Suite")
+}
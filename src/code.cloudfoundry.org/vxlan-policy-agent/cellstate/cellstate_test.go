@@ -0,0 +1,270 @@
+package cellstate_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/cellstate"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CellState", func() {
+	var (
+		dir         string
+		archive     *bytes.Buffer
+		snapshotter *cellstate.Snapshotter
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		archive = &bytes.Buffer{}
+		Expect(os.WriteFile(filepath.Join(dir, "store.json"), []byte(`{"some-handle":{}}`), 0600)).To(Succeed())
+		snapshotter = &cellstate.Snapshotter{
+			Files: []cellstate.File{
+				{Name: "container-metadata/store.json", Path: filepath.Join(dir, "store.json"), LockPath: filepath.Join(dir, "store.json_lock")},
+				{Name: "silk/store.json", Path: filepath.Join(dir, "silk", "store.json")},
+			},
+			Commands: []cellstate.Command{
+				{Name: "iptables/rules", Args: []string{"echo", "-A some-chain -j ACCEPT"}},
+				{Name: "vtep/link", Args: []string{"sh", "-c", "echo 'no such device' >&2; exit 1"}},
+			},
+		}
+	})
+
+	Describe("Snapshot", func() {
+		It("records every entry in the manifest", func() {
+			manifest, err := snapshotter.Snapshot(archive)
+			Expect(err).NotTo(HaveOccurred())
+
+			hostname, _ := os.Hostname()
+			Expect(manifest.Hostname).To(Equal(hostname))
+			Expect(manifest.CapturedAt).NotTo(BeZero())
+			Expect(manifest.Entries).To(Equal([]cellstate.Entry{
+				{
+					Name:   "container-metadata/store.json",
+					Path:   filepath.Join(dir, "store.json"),
+					Size:   18,
+					SHA256: "7d95c8a3fab1454cfad026ea9790a7fd7c5b43b621a425b876e7b3792b97e5e8",
+				},
+				{
+					Name:    "silk/store.json",
+					Path:    filepath.Join(dir, "silk", "store.json"),
+					Missing: true,
+				},
+				{
+					Name:    "iptables/rules",
+					Command: []string{"echo", "-A some-chain -j ACCEPT"},
+					Size:    24,
+					SHA256:  "0d35beba15240849486a43fb2d4c4aaa8007a313a91c5ac99dee7b7fdc0b0f7a",
+				},
+				{
+					Name:    "vtep/link",
+					Command: []string{"sh", "-c", "echo 'no such device' >&2; exit 1"},
+					Error:   "exit status 1: no such device",
+				},
+			}))
+		})
+
+		It("writes the manifest and the captured entries to the archive", func() {
+			_, err := snapshotter.Snapshot(archive)
+			Expect(err).NotTo(HaveOccurred())
+
+			entries := archiveEntries(archive.Bytes())
+			Expect(entries).To(HaveLen(3))
+			Expect(entries["manifest.json"]).To(ContainSubstring(`"name": "vtep/link"`))
+			Expect(entries["container-metadata/store.json"]).To(Equal(`{"some-handle":{}}`))
+			Expect(entries["iptables/rules"]).To(Equal("-A some-chain -j ACCEPT\n"))
+		})
+
+		It("does not create files that are missing", func() {
+			_, err := snapshotter.Snapshot(archive)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filepath.Join(dir, "silk")).NotTo(BeADirectory())
+		})
+	})
+
+	Describe("Read", func() {
+		BeforeEach(func() {
+			_, err := snapshotter.Snapshot(archive)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns the manifest and the captured entries", func() {
+			manifest, contents, err := cellstate.Read(archive)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest.Entries).To(HaveLen(4))
+			Expect(contents).To(Equal(map[string][]byte{
+				"container-metadata/store.json": []byte(`{"some-handle":{}}`),
+				"iptables/rules":                []byte("-A some-chain -j ACCEPT\n"),
+			}))
+		})
+
+		Context("when an entry was changed", func() {
+			It("returns an error", func() {
+				tampered := rewriteArchive(archive.Bytes(), func(name string, content []byte) []byte {
+					if name == "iptables/rules" {
+						return []byte("-A some-chain -j DROP\n")
+					}
+					return content
+				})
+				_, _, err := cellstate.Read(bytes.NewReader(tampered))
+				Expect(err).To(MatchError("verify archive: iptables/rules does not match its checksum"))
+			})
+		})
+
+		Context("when an entry is missing or was added", func() {
+			It("returns an error", func() {
+				tampered := rewriteArchive(archive.Bytes(), func(name string, content []byte) []byte {
+					if name == "iptables/rules" {
+						return nil
+					}
+					return content
+				}, "extra")
+				_, _, err := cellstate.Read(bytes.NewReader(tampered))
+				Expect(err).To(MatchError("verify archive: extra is not in the manifest, iptables/rules is missing"))
+			})
+		})
+
+		Context("when the archive has no manifest", func() {
+			It("returns an error", func() {
+				tampered := rewriteArchive(archive.Bytes(), func(name string, content []byte) []byte {
+					if name == "manifest.json" {
+						return nil
+					}
+					return content
+				})
+				_, _, err := cellstate.Read(bytes.NewReader(tampered))
+				Expect(err).To(MatchError("archive has no manifest"))
+			})
+		})
+
+		Context("when it is not an archive", func() {
+			It("returns an error", func() {
+				_, _, err := cellstate.Read(bytes.NewBufferString("not an archive"))
+				Expect(err).To(MatchError(ContainSubstring("read archive:")))
+			})
+		})
+	})
+
+	Describe("Restore", func() {
+		var (
+			root     string
+			restorer *cellstate.Restorer
+		)
+
+		BeforeEach(func() {
+			_, err := snapshotter.Snapshot(archive)
+			Expect(err).NotTo(HaveOccurred())
+
+			root = GinkgoT().TempDir()
+			restorer = &cellstate.Restorer{
+				Root:  root,
+				Files: snapshotter.Files,
+			}
+		})
+
+		It("writes the captured files below the root", func() {
+			restored, err := restorer.Restore(archive)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(Equal([]string{"container-metadata/store.json"}))
+
+			content, err := os.ReadFile(filepath.Join(root, dir, "store.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(`{"some-handle":{}}`))
+			Expect(filepath.Join(root, dir, "silk", "store.json")).NotTo(BeAnExistingFile())
+		})
+
+		It("passes the captured output of commands to their restore command", func() {
+			restoredRules := filepath.Join(root, "restored-rules")
+			restorer.Files = nil
+			restorer.Commands = []cellstate.Command{
+				{Name: "iptables/rules", RestoreArgs: []string{"sh", "-c", "cat > " + restoredRules}},
+				{Name: "vtep/link", RestoreArgs: []string{"false"}},
+			}
+
+			restored, err := restorer.Restore(archive)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(Equal([]string{"iptables/rules"}))
+
+			content, err := os.ReadFile(restoredRules)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("-A some-chain -j ACCEPT\n"))
+		})
+
+		Context("when a restore command fails", func() {
+			It("returns an error", func() {
+				restorer.Commands = []cellstate.Command{
+					{Name: "iptables/rules", RestoreArgs: []string{"sh", "-c", "echo 'bad rule' >&2; exit 1"}},
+				}
+
+				restored, err := restorer.Restore(archive)
+				Expect(err).To(MatchError("restore iptables/rules: exit status 1: bad rule"))
+				Expect(restored).To(Equal([]string{"container-metadata/store.json"}))
+			})
+		})
+
+		Context("when the archive does not verify", func() {
+			It("restores nothing", func() {
+				tampered := rewriteArchive(archive.Bytes(), func(name string, content []byte) []byte {
+					if name == "container-metadata/store.json" {
+						return []byte("{}")
+					}
+					return content
+				})
+
+				_, err := restorer.Restore(bytes.NewReader(tampered))
+				Expect(err).To(MatchError(ContainSubstring("does not match its checksum")))
+				Expect(filepath.Join(root, dir)).NotTo(BeADirectory())
+			})
+		})
+	})
+})
+
+func archiveEntries(archive []byte) map[string]string {
+	entries := map[string]string{}
+	rewriteArchive(archive, func(name string, content []byte) []byte {
+		entries[name] = string(content)
+		return content
+	})
+	return entries
+}
+
+// rewriteArchive passes every entry of the archive to rewrite and drops the
+// entries it returns nil for. The extra entries are appended.
+func rewriteArchive(archive []byte, rewrite func(name string, content []byte) []byte, extra ...string) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	Expect(err).NotTo(HaveOccurred())
+	tr := tar.NewReader(gz)
+
+	out := &bytes.Buffer{}
+	gzOut := gzip.NewWriter(out)
+	tw := tar.NewWriter(gzOut)
+	write := func(name string, content []byte) {
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))})).To(Succeed())
+		_, err := tw.Write(content)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		Expect(err).NotTo(HaveOccurred())
+		content, err := io.ReadAll(tr)
+		Expect(err).NotTo(HaveOccurred())
+		if content = rewrite(header.Name, content); content != nil {
+			write(header.Name, content)
+		}
+	}
+	for _, name := range extra {
+		write(name, []byte("extra"))
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gzOut.Close()).To(Succeed())
+	return out.Bytes()
+}
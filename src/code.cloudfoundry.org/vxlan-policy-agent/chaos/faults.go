@@ -0,0 +1,149 @@
+// Package chaos injects faults into the datapath of a cell for game days: it
+// drops the overlay traffic with peer cells, blackholes CIDRs and delays the
+// enforcement of changed rules. The faults are read from a file on the cell
+// and expire on their own, so that a forgotten drill does not outlive the day.
+package chaos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// The faults are enforced in global chains of the raw table, which sees the
+// packets before the chains of the filter table can accept them.
+const (
+	Table               = "raw"
+	PreroutingChainName = "chaospre"
+	OutputChainName     = "chaosout"
+)
+
+// MaxFaultDuration is how far ahead faults may expire. Faults that expire
+// later are ignored, so that every drill has to be set up again the next day.
+const MaxFaultDuration = 24 * time.Hour
+
+// MaxEnforcementDelay is the longest enforcement delay a drill may inject.
+const MaxEnforcementDelay = 5 * time.Minute
+
+// Faults are the faults of a drill.
+type Faults struct {
+	// DropPeerCells are the underlay IPs of the cells whose VXLAN traffic
+	// with this cell is dropped in both directions.
+	DropPeerCells []string `json:"drop_peer_cells"`
+	// BlackholeCIDRs are dropped for the containers and the cell.
+	BlackholeCIDRs []string `json:"blackhole_cidrs"`
+	// EnforcementDelayMs delays every poll cycle that enforces changed
+	// rules.
+	EnforcementDelayMs int       `json:"enforcement_delay_ms"`
+	ExpiresAt          time.Time `json:"expires_at"`
+}
+
+func (f Faults) Validate(now time.Time) error {
+	if f.ExpiresAt.IsZero() {
+		return errors.New("missing expires_at")
+	}
+	if f.ExpiresAt.Sub(now) > MaxFaultDuration {
+		return fmt.Errorf("expires_at is more than %s ahead", MaxFaultDuration)
+	}
+	for _, peer := range f.DropPeerCells {
+		if ip := net.ParseIP(peer); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid peer cell %q", peer)
+		}
+	}
+	for _, cidr := range f.BlackholeCIDRs {
+		if ip, _, err := net.ParseCIDR(cidr); err != nil || ip.To4() == nil {
+			return fmt.Errorf("invalid blackhole cidr %q", cidr)
+		}
+	}
+	if f.EnforcementDelayMs < 0 || f.EnforcementDelay() > MaxEnforcementDelay {
+		return fmt.Errorf("enforcement delay must be between 0 and %s", MaxEnforcementDelay)
+	}
+	return nil
+}
+
+func (f Faults) EnforcementDelay() time.Duration {
+	return time.Duration(f.EnforcementDelayMs) * time.Millisecond
+}
+
+func (f Faults) None() bool {
+	return len(f.DropPeerCells) == 0 && len(f.BlackholeCIDRs) == 0 && f.EnforcementDelayMs == 0
+}
+
+func Read(path string) (Faults, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Faults{}, err
+	}
+	var faults Faults
+	if err := json.Unmarshal(contents, &faults); err != nil {
+		return Faults{}, fmt.Errorf("parsing faults: %s", err)
+	}
+	return faults, nil
+}
+
+// Source reads the faults from Path every time they are asked for, so that a
+// drill starts and stops with the next poll cycle. A missing file means no
+// faults. Faults that are invalid or expired are ignored.
+type Source struct {
+	Path   string
+	Logger lager.Logger
+	Now    func() time.Time
+
+	mutex     sync.Mutex
+	lastState string
+}
+
+func (s *Source) Faults() Faults {
+	faults, err := Read(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.transition("none", func() { s.Logger.Info("faults-cleared") })
+		return Faults{}
+	}
+	if err == nil {
+		err = faults.Validate(s.Now())
+	}
+	if err != nil {
+		s.transition("invalid: "+err.Error(), func() { s.Logger.Error("faults-ignored", err, lager.Data{"path": s.Path}) })
+		return Faults{}
+	}
+	if !s.Now().Before(faults.ExpiresAt) {
+		s.transition("expired: "+faults.ExpiresAt.String(), func() {
+			s.Logger.Info("faults-expired", lager.Data{"expires_at": faults.ExpiresAt})
+		})
+		return Faults{}
+	}
+
+	state, _ := json.Marshal(faults)
+	s.transition(string(state), func() {
+		s.Logger.Info("faults-active", lager.Data{
+			"drop_peer_cells":      faults.DropPeerCells,
+			"blackhole_cidrs":      faults.BlackholeCIDRs,
+			"enforcement_delay_ms": faults.EnforcementDelayMs,
+			"expires_at":           faults.ExpiresAt,
+		})
+	})
+	return faults
+}
+
+func (s *Source) EnforcementDelay() time.Duration {
+	return s.Faults().EnforcementDelay()
+}
+
+// transition logs a change of the faults once instead of on every cycle.
+func (s *Source) transition(state string, log func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state == s.lastState {
+		return
+	}
+	if s.lastState != "" || state != "none" {
+		log()
+	}
+	s.lastState = state
+}
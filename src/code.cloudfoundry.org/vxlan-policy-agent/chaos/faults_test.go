@@ -0,0 +1,105 @@
+package chaos_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/vxlan-policy-agent/chaos"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Source", func() {
+	var (
+		path   string
+		now    time.Time
+		logger *lagertest.TestLogger
+		source *chaos.Source
+	)
+
+	writeFaults := func(contents string) {
+		Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "faults.json")
+		now = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		logger = lagertest.NewTestLogger("test")
+		source = &chaos.Source{
+			Path:   path,
+			Logger: logger,
+			Now:    func() time.Time { return now },
+		}
+	})
+
+	It("returns the faults of the file", func() {
+		writeFaults(`{
+			"drop_peer_cells": ["10.0.16.5"],
+			"blackhole_cidrs": ["192.0.2.0/24"],
+			"enforcement_delay_ms": 2500,
+			"expires_at": "2026-01-02T05:00:00Z"
+		}`)
+
+		faults := source.Faults()
+		Expect(faults.DropPeerCells).To(Equal([]string{"10.0.16.5"}))
+		Expect(faults.BlackholeCIDRs).To(Equal([]string{"192.0.2.0/24"}))
+		Expect(faults.ExpiresAt).To(Equal(time.Date(2026, 1, 2, 5, 0, 0, 0, time.UTC)))
+		Expect(source.EnforcementDelay()).To(Equal(2500 * time.Millisecond))
+		Expect(logger).To(gbytes.Say("faults-active"))
+	})
+
+	It("logs the faults once while they do not change", func() {
+		writeFaults(`{"drop_peer_cells": ["10.0.16.5"], "expires_at": "2026-01-02T05:00:00Z"}`)
+		source.Faults()
+		source.Faults()
+
+		Expect(logger.LogMessages()).To(Equal([]string{"test.faults-active"}))
+	})
+
+	Context("when there is no file", func() {
+		It("returns no faults without logging", func() {
+			Expect(source.Faults().None()).To(BeTrue())
+			Expect(logger.LogMessages()).To(BeEmpty())
+		})
+
+		It("logs that the faults were cleared after a drill", func() {
+			writeFaults(`{"drop_peer_cells": ["10.0.16.5"], "expires_at": "2026-01-02T05:00:00Z"}`)
+			source.Faults()
+			Expect(os.Remove(path)).To(Succeed())
+
+			Expect(source.Faults().None()).To(BeTrue())
+			Expect(logger.LogMessages()).To(Equal([]string{"test.faults-active", "test.faults-cleared"}))
+		})
+	})
+
+	Context("when the faults expired", func() {
+		It("returns no faults", func() {
+			writeFaults(`{"drop_peer_cells": ["10.0.16.5"], "expires_at": "2026-01-02T03:04:05Z"}`)
+
+			Expect(source.Faults().None()).To(BeTrue())
+			Expect(logger.LogMessages()).To(Equal([]string{"test.faults-expired"}))
+		})
+	})
+
+	DescribeTable("when the faults are invalid",
+		func(contents, errorMsg string) {
+			writeFaults(contents)
+
+			Expect(source.Faults().None()).To(BeTrue())
+			Expect(logger.LogMessages()).To(Equal([]string{"test.faults-ignored"}))
+			Expect(logger.Logs()[0].Data["error"]).To(Equal(errorMsg))
+		},
+		Entry("unparsable", `{`, "parsing faults: unexpected end of JSON input"),
+		Entry("without expiry", `{"drop_peer_cells": ["10.0.16.5"]}`, "missing expires_at"),
+		Entry("expiring too late", `{"drop_peer_cells": ["10.0.16.5"], "expires_at": "2026-01-03T03:04:06Z"}`, "expires_at is more than 24h0m0s ahead"),
+		Entry("invalid peer cell", `{"drop_peer_cells": ["banana"], "expires_at": "2026-01-02T05:00:00Z"}`, `invalid peer cell "banana"`),
+		Entry("ipv6 peer cell", `{"drop_peer_cells": ["fd00::5"], "expires_at": "2026-01-02T05:00:00Z"}`, `invalid peer cell "fd00::5"`),
+		Entry("invalid cidr", `{"blackhole_cidrs": ["192.0.2.0"], "expires_at": "2026-01-02T05:00:00Z"}`, `invalid blackhole cidr "192.0.2.0"`),
+		Entry("delay too long", `{"enforcement_delay_ms": 300001, "expires_at": "2026-01-02T05:00:00Z"}`, "enforcement delay must be between 0 and 5m0s"),
+		Entry("negative delay", `{"enforcement_delay_ms": -1, "expires_at": "2026-01-02T05:00:00Z"}`, "enforcement delay must be between 0 and 5m0s"),
+	)
+})
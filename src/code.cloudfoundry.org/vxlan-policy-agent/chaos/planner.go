@@ -0,0 +1,63 @@
+package chaos
+
+import (
+	"net"
+	"strconv"
+
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+//go:generate counterfeiter -o fakes/fault_source.go --fake-name FaultSource . faultSource
+type faultSource interface {
+	Faults() Faults
+}
+
+// Planner plans the rules of the faults in one chain of the raw table: in
+// PREROUTING for the packets the cell receives and forwards, in OUTPUT for
+// the packets the cell sends. Without faults the chain is empty.
+type Planner struct {
+	Faults   faultSource
+	Chain    enforcer.Chain
+	VTEPPort int
+}
+
+// Chains are the chains of the faults.
+func Chains() []enforcer.Chain {
+	return []enforcer.Chain{
+		{Table: Table, ParentChain: "PREROUTING", Prefix: PreroutingChainName + "--"},
+		{Table: Table, ParentChain: "OUTPUT", Prefix: OutputChainName + "--"},
+	}
+}
+
+func (p *Planner) GetPolicyRulesAndChain() (enforcer.RulesWithChain, error) {
+	faults := p.Faults.Faults()
+
+	peerMatch := "-s"
+	if p.Chain.ParentChain == "OUTPUT" {
+		peerMatch = "-d"
+	}
+
+	ruleset := []rules.IPTablesRule{}
+	for _, peer := range faults.DropPeerCells {
+		ruleset = append(ruleset, rules.IPTablesRule{
+			peerMatch, peer + "/32",
+			"-p", "udp", "-m", "udp", "--dport", strconv.Itoa(p.VTEPPort),
+			"-j", "DROP",
+		})
+	}
+	for _, cidr := range faults.BlackholeCIDRs {
+		// written as iptables lists it, so that the chain matches its plan
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		ruleset = append(ruleset, rules.IPTablesRule{"-d", network.String(), "-j", "DROP"})
+	}
+
+	return enforcer.RulesWithChain{Chain: p.Chain, Rules: ruleset}, nil
+}
+
+func (p *Planner) GetASGRulesAndChains(containers ...string) ([]enforcer.RulesWithChain, error) {
+	return nil, nil
+}
@@ -0,0 +1,75 @@
+package chaos_test
+
+import (
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/chaos"
+	"code.cloudfoundry.org/vxlan-policy-agent/chaos/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Planner", func() {
+	var (
+		faultSource *fakes.FaultSource
+		planner     *chaos.Planner
+	)
+
+	BeforeEach(func() {
+		faultSource = &fakes.FaultSource{}
+		faultSource.FaultsReturns(chaos.Faults{
+			DropPeerCells:  []string{"10.0.16.5"},
+			BlackholeCIDRs: []string{"192.0.2.7/24"},
+		})
+		planner = &chaos.Planner{
+			Faults:   faultSource,
+			Chain:    chaos.Chains()[0],
+			VTEPPort: 4789,
+		}
+	})
+
+	It("drops the VXLAN packets from the peer cells and the packets to the blackholed CIDRs", func() {
+		rulesWithChain, err := planner.GetPolicyRulesAndChain()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rulesWithChain.Chain).To(Equal(enforcer.Chain{Table: "raw", ParentChain: "PREROUTING", Prefix: "chaospre--"}))
+		Expect(rulesWithChain.Rules).To(Equal([]rules.IPTablesRule{
+			{"-s", "10.0.16.5/32", "-p", "udp", "-m", "udp", "--dport", "4789", "-j", "DROP"},
+			{"-d", "192.0.2.0/24", "-j", "DROP"},
+		}))
+	})
+
+	Context("in OUTPUT", func() {
+		BeforeEach(func() {
+			planner.Chain = chaos.Chains()[1]
+		})
+
+		It("drops the VXLAN packets to the peer cells", func() {
+			rulesWithChain, err := planner.GetPolicyRulesAndChain()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rulesWithChain.Chain).To(Equal(enforcer.Chain{Table: "raw", ParentChain: "OUTPUT", Prefix: "chaosout--"}))
+			Expect(rulesWithChain.Rules).To(Equal([]rules.IPTablesRule{
+				{"-d", "10.0.16.5/32", "-p", "udp", "-m", "udp", "--dport", "4789", "-j", "DROP"},
+				{"-d", "192.0.2.0/24", "-j", "DROP"},
+			}))
+		})
+	})
+
+	Context("without faults", func() {
+		BeforeEach(func() {
+			faultSource.FaultsReturns(chaos.Faults{})
+		})
+
+		It("plans an empty chain", func() {
+			rulesWithChain, err := planner.GetPolicyRulesAndChain()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rulesWithChain.Rules).To(BeEmpty())
+		})
+	})
+
+	It("plans no ASG rules", func() {
+		asgRules, err := planner.GetASGRulesAndChains()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(asgRules).To(BeNil())
+	})
+})
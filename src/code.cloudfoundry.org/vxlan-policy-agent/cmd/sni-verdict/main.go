@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagerflags"
+	"code.cloudfoundry.org/lib/common"
+	"code.cloudfoundry.org/vxlan-policy-agent/config"
+	"code.cloudfoundry.org/vxlan-policy-agent/sni"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/sigmon"
+)
+
+const jobPrefix = "sni-verdict"
+
+var logPrefix = "cfnetworking"
+
+func main() {
+	configFilePath := flag.String("config-file", "", "path to config file")
+	flag.Parse()
+
+	conf, err := config.NewSNIVerdict(*configFilePath)
+	if err != nil {
+		log.Fatalf("%s.%s: reading config: %s", logPrefix, jobPrefix, err)
+	}
+
+	if conf.LogPrefix != "" {
+		logPrefix = conf.LogPrefix
+	}
+
+	loggerConfig := common.GetLagerConfig()
+	if conf.LogLevel != "" {
+		loggerConfig.LogLevel = conf.LogLevel
+	}
+	logger, _ := lagerflags.NewFromConfig(fmt.Sprintf("%s.%s", logPrefix, jobPrefix), loggerConfig)
+	logger.Info("parsed-config", lager.Data{"config": conf})
+
+	// every queue has a verdicter of its own, since each is only called by
+	// the goroutine of its queue
+	members := grouper.Members{}
+	for _, allowlist := range conf.SNIInspection.Allowlists {
+		queueLogger := logger.Session("queue", lager.Data{"queue": allowlist.QueueNum})
+		members = append(members, grouper.Member{
+			Name: "queue-" + strconv.Itoa(allowlist.QueueNum),
+			Runner: &sni.Queue{
+				Num:      uint16(allowlist.QueueNum),
+				FailOpen: conf.SNIInspection.FailOpen,
+				Verdicter: &sni.Verdicter{
+					Allowlist:    sni.NewAllowlist(allowlist.ServerNames),
+					Destinations: destinations(allowlist.Rules),
+					FailOpen:     conf.SNIInspection.FailOpen,
+					FlowTimeout:  time.Duration(conf.FlowTimeoutSeconds) * time.Second,
+					Logger:       queueLogger,
+					Now:          time.Now,
+				},
+				Logger: queueLogger,
+			},
+		})
+	}
+
+	monitor := ifrit.Invoke(sigmon.New(grouper.NewParallel(os.Interrupt, members)))
+	logger.Info("starting")
+	err = <-monitor.Wait()
+	if err != nil {
+		logger.Error("ifrit-monitor", err)
+		os.Exit(1)
+	}
+}
+
+// destinations converts the rules of an allowlist, which the config validated,
+// to a destination per network and port range.
+func destinations(sniRules []cnilib.SNIRuleConfig) []sni.Destination {
+	destinations := []sni.Destination{}
+	for _, sniRule := range sniRules {
+		rule, err := netrules.NewRuleFromSecurityGroupRule(sniRule.SecurityGroupRule())
+		if err != nil {
+			continue
+		}
+		allowlist := sni.NewAllowlist(sniRule.ServerNames)
+		for _, network := range rule.Networks() {
+			for _, ports := range rule.Ports() {
+				destinations = append(destinations, sni.Destination{
+					Start:     network.Start,
+					End:       network.End,
+					StartPort: ports.Start,
+					EndPort:   ports.End,
+					Allowlist: allowlist,
+				})
+			}
+		}
+	}
+	return destinations
+}
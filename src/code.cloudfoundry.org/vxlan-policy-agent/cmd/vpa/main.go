@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"text/tabwriter"
+	"time"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/lib/serial"
+	"code.cloudfoundry.org/vxlan-policy-agent/cellstate"
+	"code.cloudfoundry.org/vxlan-policy-agent/config"
+	"code.cloudfoundry.org/vxlan-policy-agent/egress"
+	"code.cloudfoundry.org/vxlan-policy-agent/plandump"
+	"code.cloudfoundry.org/vxlan-policy-agent/simulation"
+	"github.com/coreos/go-iptables/iptables"
+)
+
+const jobPrefix = "vpa"
+
+const usage = `usage: vpa [-datastore <path>] chains lookup <container-handle>
+       vpa [-datastore <path>] chains export <container-handle>
+       vpa [-datastore <path>] chains analyze [<container-handle>...]
+       vpa simulate [-config-file <path>] [-containers <n>] [-instances-per-app <n>] [-apps-per-space <n>] [-policies-per-app <n>] [-asg-rules <n>]
+       vpa [-datastore <path>] state snapshot <archive>
+       vpa state verify <archive>
+       vpa [-datastore <path>] state restore [-root <dir>] [-iptables] <archive>
+       vpa plans diff <old-plan> <new-plan>`
+
+const (
+	silkDatastorePath    = "/var/vcap/data/silk/store.json"
+	silkDaemonConfigPath = "/var/vcap/jobs/silk-daemon/config/client-config.json"
+	iptablesLockFile     = "/var/vcap/data/garden-cni/iptables.lock"
+	vtepName             = "silk-vtep"
+)
+
+func main() {
+	err := mainWithError(os.Stdout)
+	if err != nil {
+		log.Fatalf("%s error: %s", jobPrefix, err)
+	}
+}
+
+func mainWithError(out io.Writer) error {
+	datastorePath := flag.String("datastore", "/var/vcap/data/container-metadata/store.json", "path to container metadata datastore")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 3 && args[0] == "chains" && args[1] == "lookup" {
+		return LookupChains(out, *datastorePath, args[2])
+	}
+	if len(args) == 3 && args[0] == "chains" && args[1] == "export" {
+		ipt, err := iptables.New()
+		if err != nil {
+			return fmt.Errorf("iptables: %s", err)
+		}
+		return ExportRules(out, *datastorePath, args[2], &egress.Exporter{IPTables: ipt})
+	}
+	if len(args) >= 2 && args[0] == "chains" && args[1] == "analyze" {
+		ipt, err := iptables.New()
+		if err != nil {
+			return fmt.Errorf("iptables: %s", err)
+		}
+		return AnalyzeRules(out, *datastorePath, args[2:], &egress.Analyzer{IPTables: ipt})
+	}
+	if len(args) > 0 && args[0] == "simulate" {
+		return simulate(out, args[1:])
+	}
+	if len(args) == 3 && args[0] == "state" && args[1] == "snapshot" {
+		return SnapshotState(out, args[2], &cellstate.Snapshotter{
+			Files:    append(StateFiles(*datastorePath), cellstate.File{Name: "silk-daemon/client-config.json", Path: silkDaemonConfigPath}),
+			Commands: StateCommands(),
+		})
+	}
+	if len(args) == 3 && args[0] == "state" && args[1] == "verify" {
+		return VerifyState(out, args[2])
+	}
+	if len(args) >= 2 && args[0] == "state" && args[1] == "restore" {
+		return restoreState(out, *datastorePath, args[2:])
+	}
+	if len(args) == 4 && args[0] == "plans" && args[1] == "diff" {
+		return DiffPlans(out, args[2], args[3])
+	}
+	return errors.New(usage)
+}
+
+// ExportRules prints the egress rules iptables enforces for a container as
+// the JSON rules of an ASG.
+func ExportRules(out io.Writer, datastorePath, containerHandle string, exporter *egress.Exporter) error {
+	chains, err := netrules.ContainerChainNames(&netrules.ChainNamer{MaxLength: 28}, containerHandle)
+	if err != nil {
+		return err
+	}
+
+	containers, err := readContainers(datastorePath)
+	if err != nil {
+		return err
+	}
+	container, ok := containers[containerHandle]
+	if !ok {
+		return fmt.Errorf("container %s not found in datastore", containerHandle)
+	}
+
+	rules, err := exporter.Export(egress.Container{NetOutChain: chains.NetOut, IP: container.IP})
+	if err != nil {
+		return fmt.Errorf("export rules: %s", err)
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rules)
+}
+
+// AnalyzeRules prints the egress rules iptables enforces for containers that
+// are shadowed, redundant or conflicting. Without handles, every container in
+// the datastore is analyzed.
+func AnalyzeRules(out io.Writer, datastorePath string, containerHandles []string, analyzer *egress.Analyzer) error {
+	containers, err := readContainers(datastorePath)
+	if err != nil {
+		return err
+	}
+	if len(containerHandles) == 0 {
+		for handle := range containers {
+			containerHandles = append(containerHandles, handle)
+		}
+		sort.Strings(containerHandles)
+	}
+
+	for _, handle := range containerHandles {
+		container, ok := containers[handle]
+		if !ok {
+			return fmt.Errorf("container %s not found in datastore", handle)
+		}
+		chains, err := netrules.ContainerChainNames(&netrules.ChainNamer{MaxLength: 28}, handle)
+		if err != nil {
+			return err
+		}
+		findings, err := analyzer.Analyze(egress.Container{NetOutChain: chains.NetOut, IP: container.IP})
+		if err != nil {
+			return fmt.Errorf("analyze rules of %s: %s", handle, err)
+		}
+
+		if len(findings) == 0 {
+			fmt.Fprintf(out, "%s: no findings\n", handle)
+			continue
+		}
+		fmt.Fprintf(out, "%s:\n", handle)
+		for _, finding := range findings {
+			fmt.Fprintf(out, "  %s: %s\n", finding.Kind, finding.Rule)
+			for _, by := range finding.By {
+				fmt.Fprintf(out, "    by: %s\n", by)
+			}
+			if finding.Overlap != "" {
+				fmt.Fprintf(out, "    overlap: %s\n", finding.Overlap)
+			}
+		}
+	}
+	return nil
+}
+
+// StateFiles are the stores of the cell that a state snapshot captures and
+// that can be restored.
+func StateFiles(datastorePath string) []cellstate.File {
+	asgChainsFile := datastore.ASGChainsFilePath(datastorePath)
+	chainOwnersFile := datastore.ChainOwnersFilePath(datastorePath)
+	return []cellstate.File{
+		{Name: "container-metadata/store.json", Path: datastorePath, LockPath: datastorePath + "_lock"},
+		{Name: "container-metadata/store.json_version", Path: datastorePath + "_version", LockPath: datastorePath + "_lock"},
+		{Name: "container-metadata/asg-chains.json", Path: asgChainsFile, LockPath: asgChainsFile + "_lock"},
+		{Name: "container-metadata/chain-owners.json", Path: chainOwnersFile, LockPath: chainOwnersFile + "_lock"},
+		// the silk store is locked by the file itself
+		{Name: "silk/store.json", Path: silkDatastorePath, LockPath: silkDatastorePath},
+	}
+}
+
+// StateCommands capture the iptables rules, which can be restored, and the
+// VTEP, whose address and hardware address are the lease of the cell. The
+// VTEP is not restored: the silk daemon creates it from the lease that the
+// silk controller hands out.
+func StateCommands() []cellstate.Command {
+	return []cellstate.Command{
+		{Name: "iptables/rules", Args: []string{"iptables-save", "-c"}, RestoreArgs: []string{"iptables-restore", "-c"}, LockPath: iptablesLockFile},
+		{Name: "ip6tables/rules", Args: []string{"ip6tables-save", "-c"}, RestoreArgs: []string{"ip6tables-restore", "-c"}, LockPath: iptablesLockFile},
+		{Name: "vtep/link.json", Args: []string{"ip", "-details", "-json", "link", "show", "dev", vtepName}},
+		{Name: "vtep/addresses.json", Args: []string{"ip", "-json", "address", "show", "dev", vtepName}},
+		{Name: "vtep/neighbors.json", Args: []string{"ip", "-json", "neighbor", "show", "dev", vtepName}},
+		{Name: "vtep/fdb.json", Args: []string{"bridge", "-json", "fdb", "show", "dev", vtepName}},
+		{Name: "vtep/routes.json", Args: []string{"ip", "-json", "route", "show", "dev", vtepName}},
+	}
+}
+
+// SnapshotState writes the state of the cell to a new archive and prints its
+// entries, including those that could not be captured.
+func SnapshotState(out io.Writer, archivePath string, snapshotter *cellstate.Snapshotter) error {
+	archive, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("create archive: %s", err)
+	}
+	manifest, err := snapshotter.Snapshot(archive)
+	if err != nil {
+		archive.Close()
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("close archive: %s", err)
+	}
+
+	printEntries(out, manifest)
+	fmt.Fprintf(out, "wrote %s\n", archivePath)
+	return nil
+}
+
+// VerifyState checks the checksums of an archive and prints its entries.
+func VerifyState(out io.Writer, archivePath string) error {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("open archive: %s", err)
+	}
+	defer archive.Close()
+
+	manifest, _, err := cellstate.Read(archive)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "captured on %s at %s\n", manifest.Hostname, manifest.CapturedAt.Format(time.RFC3339))
+	printEntries(out, manifest)
+	fmt.Fprintf(out, "%s is intact\n", archivePath)
+	return nil
+}
+
+func printEntries(out io.Writer, manifest cellstate.Manifest) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, entry := range manifest.Entries {
+		switch {
+		case entry.Missing:
+			fmt.Fprintf(w, "%s\tmissing\n", entry.Name)
+		case entry.Error != "":
+			fmt.Fprintf(w, "%s\tfailed: %s\n", entry.Name, entry.Error)
+		default:
+			fmt.Fprintf(w, "%s\t%d bytes\n", entry.Name, entry.Size)
+		}
+	}
+	w.Flush()
+}
+
+func restoreState(out io.Writer, datastorePath string, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	root := flags.String("root", "/", "directory to restore the files below")
+	restoreIPTables := flags.Bool("iptables", false, "replace the iptables rules of this machine with those of the archive")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 1 {
+		return errors.New(usage)
+	}
+
+	restorer := &cellstate.Restorer{
+		Root:  *root,
+		Files: StateFiles(datastorePath),
+	}
+	if *restoreIPTables {
+		restorer.Commands = StateCommands()
+	}
+	return RestoreState(out, flags.Arg(0), restorer)
+}
+
+// RestoreState restores an archive and prints the restored entries.
+func RestoreState(out io.Writer, archivePath string, restorer *cellstate.Restorer) error {
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("open archive: %s", err)
+	}
+	defer archive.Close()
+
+	restored, err := restorer.Restore(archive)
+	for _, name := range restored {
+		fmt.Fprintf(out, "restored %s\n", name)
+	}
+	return err
+}
+
+// DiffPlans prints the rule sets that were added, removed or changed between
+// two plans that the agent dumped, with examples of the rules that changed.
+func DiffPlans(out io.Writer, oldPath, newPath string) error {
+	oldPlan, err := plandump.Read(oldPath)
+	if err != nil {
+		return err
+	}
+	newPlan, err := plandump.Read(newPath)
+	if err != nil {
+		return err
+	}
+
+	changes := plandump.Diff(oldPlan, newPlan)
+	fmt.Fprintf(out, "%s plan of %s to %s plan of %s: %d rule sets changed\n",
+		oldPlan.Cycle, oldPlan.PlannedAt.Format(time.RFC3339),
+		newPlan.Cycle, newPlan.PlannedAt.Format(time.RFC3339), len(changes))
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, change := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d to %d rules (+%d -%d)\n",
+			change.Change, change.Chain.Table, change.Chain.ParentChain, change.Chain.Prefix, change.Handle,
+			change.Diff.OldRules, change.Diff.NewRules, change.Diff.Added, change.Diff.Removed)
+		for _, rule := range change.Diff.AddedExamples {
+			fmt.Fprintf(w, "\t+ %s\n", rule)
+		}
+		for _, rule := range change.Diff.RemovedExamples {
+			fmt.Fprintf(w, "\t- %s\n", rule)
+		}
+	}
+	return w.Flush()
+}
+
+func readContainers(datastorePath string) (map[string]datastore.Container, error) {
+	store := &datastore.Store{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(datastorePath + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath:    datastorePath,
+		VersionFilePath: datastorePath + "_version",
+		LockedFilePath:  datastorePath + "_lock",
+		CacheMutex:      new(sync.RWMutex),
+	}
+	containers, err := store.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read datastore: %s", err)
+	}
+	return containers, nil
+}
+
+func simulate(out io.Writer, args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFilePath := flags.String("config-file", "/var/vcap/jobs/vxlan-policy-agent/config/vxlan-policy-agent.json", "path to the config file of the vxlan policy agent")
+	load := simulation.Load{}
+	flags.IntVar(&load.Containers, "containers", 250, "number of synthetic containers")
+	flags.IntVar(&load.InstancesPerApp, "instances-per-app", 2, "number of containers of every app")
+	flags.IntVar(&load.AppsPerSpace, "apps-per-space", 10, "number of apps in every space")
+	flags.IntVar(&load.PoliciesPerApp, "policies-per-app", 5, "number of container to container policies from every app")
+	flags.IntVar(&load.ASGRules, "asg-rules", 100, "number of ASG rules of every space")
+	err := flags.Parse(args)
+	if err != nil || flags.NArg() != 0 {
+		return errors.New(usage)
+	}
+
+	conf, err := config.New(*configFilePath)
+	if err != nil {
+		return fmt.Errorf("read config: %s", err)
+	}
+	ipt, err := iptables.New()
+	if err != nil {
+		return fmt.Errorf("iptables: %s", err)
+	}
+
+	simulator := &simulation.Simulator{
+		Load: load,
+		IPTables: &rules.LockedIPTables{
+			IPTables: ipt,
+			Locker: &filelock.Locker{
+				FileLocker: filelock.NewLocker(conf.IPTablesLockFile),
+				Mutex:      new(sync.Mutex),
+			},
+			Restorer: &rules.Restorer{},
+		},
+		ChainNameVersion: conf.ManagedChainNameVersion,
+		SubChainMinRules: conf.IPTablesSubChainMinRules,
+		Logger:           lager.NewLogger(jobPrefix),
+	}
+	cycles, err := simulator.Run()
+	if err != nil {
+		return fmt.Errorf("simulate: %s", err)
+	}
+	PrintCycles(out, load, cycles)
+	return nil
+}
+
+// PrintCycles prints how long the simulated cycles of a load took, e.g.
+//
+//	cycle      policy plan  policy enforce  asg plan  asg enforce  total
+//	initial    12ms         40ms            95ms      3.2s         3.347s
+func PrintCycles(out io.Writer, load simulation.Load, cycles []simulation.Cycle) {
+	fmt.Fprintf(out, "%d containers, %d instances per app, %d apps per space, %d policies per app, %d asg rules\n\n",
+		load.Containers, load.InstancesPerApp, load.AppsPerSpace, load.PoliciesPerApp, load.ASGRules)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "cycle\tpolicy plan\tpolicy enforce\tasg plan\tasg enforce\ttotal")
+	for _, cycle := range cycles {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", cycle.Name,
+			cycle.PolicyPlan.Round(time.Millisecond), cycle.PolicyEnforce.Round(time.Millisecond),
+			cycle.ASGPlan.Round(time.Millisecond), cycle.ASGEnforce.Round(time.Millisecond),
+			cycle.Total().Round(time.Millisecond))
+	}
+	w.Flush()
+}
+
+func LookupChains(out io.Writer, datastorePath, containerHandle string) error {
+	chains, err := netrules.ContainerChainNames(&netrules.ChainNamer{MaxLength: 28}, containerHandle)
+	if err != nil {
+		return err
+	}
+
+	asgChainsFile := datastore.ASGChainsFilePath(datastorePath)
+	asgChains := &datastore.ASGChains{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(asgChainsFile + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath: asgChainsFile,
+	}
+
+	asgChain, ok, err := asgChains.Lookup("filter", chains.NetOut)
+	if err != nil {
+		return fmt.Errorf("lookup asg chain: %s", err)
+	}
+	if !ok {
+		asgChain = "(none)"
+	}
+
+	fmt.Fprintf(out, "input:      %s\n", chains.Input)
+	fmt.Fprintf(out, "netout:     %s\n", chains.NetOut)
+	fmt.Fprintf(out, "netout log: %s\n", chains.NetOutLog)
+	fmt.Fprintf(out, "asg:        %s\n", asgChain)
+	fmt.Fprintf(out, "overlay:    %s\n", chains.Overlay)
+	fmt.Fprintf(out, "netin:      %s\n", chains.NetIn)
+	return nil
+}
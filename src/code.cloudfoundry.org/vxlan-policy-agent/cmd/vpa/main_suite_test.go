@@ -0,0 +1,13 @@
+package main_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestVPA(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "vpa Suite")
+}
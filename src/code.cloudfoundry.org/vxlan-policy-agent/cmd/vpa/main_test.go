@@ -0,0 +1,344 @@
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	main "code.cloudfoundry.org/vxlan-policy-agent/cmd/vpa"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/lib/serial"
+	"code.cloudfoundry.org/vxlan-policy-agent/cellstate"
+	"code.cloudfoundry.org/vxlan-policy-agent/egress"
+	"code.cloudfoundry.org/vxlan-policy-agent/egress/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/plandump"
+	"code.cloudfoundry.org/vxlan-policy-agent/simulation"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("vpa chains lookup", func() {
+	var (
+		datastorePath string
+		out           *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		datastorePath = filepath.Join(GinkgoT().TempDir(), "store.json")
+		out = &bytes.Buffer{}
+	})
+
+	It("prints the chain names of the container", func() {
+		asgChainsFile := datastore.ASGChainsFilePath(datastorePath)
+		asgChains := &datastore.ASGChains{
+			Serializer: &serial.Serial{},
+			Locker: &filelock.Locker{
+				FileLocker: filelock.NewLocker(asgChainsFile + "_lock"),
+				Mutex:      new(sync.Mutex),
+			},
+			DataFilePath: asgChainsFile,
+		}
+		Expect(asgChains.Save([]datastore.ASGChain{
+			{Table: "filter", ParentChain: "netout--some-handle", Chain: "asg-a1b2c3v1-g7cs183k3a"},
+		})).To(Succeed())
+
+		Expect(main.LookupChains(out, datastorePath, "some-handle")).To(Succeed())
+		Expect(out.String()).To(Equal(
+			"input:      input--some-handle\n" +
+				"netout:     netout--some-handle\n" +
+				"netout log: netout--some-handle--log\n" +
+				"asg:        asg-a1b2c3v1-g7cs183k3a\n" +
+				"overlay:    overlay--some-handle\n" +
+				"netin:      netin--some-handle\n"))
+	})
+
+	Context("when no asg chain has been recorded for the container", func() {
+		It("prints none", func() {
+			Expect(main.LookupChains(out, datastorePath, "some-handle")).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("asg:        (none)\n"))
+		})
+	})
+})
+
+var _ = Describe("vpa chains export", func() {
+	var (
+		datastorePath string
+		out           *bytes.Buffer
+		iptables      *fakes.Lister
+		exporter      *egress.Exporter
+	)
+
+	BeforeEach(func() {
+		datastorePath = filepath.Join(GinkgoT().TempDir(), "store.json")
+		out = &bytes.Buffer{}
+		iptables = &fakes.Lister{}
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			switch chain {
+			case "netout--some-handle":
+				return []string{
+					"-A netout--some-handle -j asg-a1b2c3v1-g7cs183k3a",
+					"-A netout--some-handle -j REJECT --reject-with icmp-port-unreachable",
+				}, nil
+			case "asg-a1b2c3v1-g7cs183k3a":
+				return []string{
+					"-A asg-a1b2c3v1-g7cs183k3a -p tcp -m iprange --dst-range 10.0.0.1-10.0.0.1 -m tcp --dport 443 -j ACCEPT",
+					"-A asg-a1b2c3v1-g7cs183k3a -j REJECT --reject-with icmp-port-unreachable",
+				}, nil
+			}
+			return nil, nil
+		}
+		exporter = &egress.Exporter{IPTables: iptables}
+
+		store := &datastore.Store{
+			Serializer: &serial.Serial{},
+			Locker: &filelock.Locker{
+				FileLocker: filelock.NewLocker(datastorePath + "_lock"),
+				Mutex:      new(sync.Mutex),
+			},
+			DataFilePath:    datastorePath,
+			VersionFilePath: datastorePath + "_version",
+			LockedFilePath:  datastorePath + "_lock",
+			CacheMutex:      new(sync.RWMutex),
+		}
+		Expect(store.Add("some-handle", "10.255.1.2", map[string]interface{}{})).To(Succeed())
+	})
+
+	It("prints the enforced egress rules of the container as ASG rules", func() {
+		Expect(main.ExportRules(out, datastorePath, "some-handle", exporter)).To(Succeed())
+		Expect(out.String()).To(MatchJSON(`[{"protocol": "tcp", "destination": "10.0.0.1", "ports": "443"}]`))
+
+		_, chain := iptables.ListArgsForCall(2)
+		Expect(chain).To(Equal("FORWARD"))
+	})
+
+	Context("when the container is not in the datastore", func() {
+		It("returns an error", func() {
+			err := main.ExportRules(out, datastorePath, "other-handle", exporter)
+			Expect(err).To(MatchError("container other-handle not found in datastore"))
+		})
+	})
+})
+
+var _ = Describe("vpa chains analyze", func() {
+	var (
+		datastorePath string
+		out           *bytes.Buffer
+		iptables      *fakes.Lister
+		analyzer      *egress.Analyzer
+	)
+
+	BeforeEach(func() {
+		datastorePath = filepath.Join(GinkgoT().TempDir(), "store.json")
+		out = &bytes.Buffer{}
+		iptables = &fakes.Lister{}
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			switch chain {
+			case "netout--some-handle":
+				return []string{
+					"-A netout--some-handle -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable",
+					"-A netout--some-handle -p tcp -m iprange --dst-range 0.0.0.0-255.255.255.255 -m tcp --dport 443 -j ACCEPT",
+					"-A netout--some-handle -p tcp -m iprange --dst-range 10.0.0.1-10.0.0.1 -m tcp --dport 443 -j ACCEPT",
+					"-A netout--some-handle -j REJECT --reject-with icmp-port-unreachable",
+				}, nil
+			case "netout--other-handle":
+				return []string{
+					"-A netout--other-handle -j REJECT --reject-with icmp-port-unreachable",
+				}, nil
+			}
+			return nil, nil
+		}
+		analyzer = &egress.Analyzer{IPTables: iptables}
+
+		store := &datastore.Store{
+			Serializer: &serial.Serial{},
+			Locker: &filelock.Locker{
+				FileLocker: filelock.NewLocker(datastorePath + "_lock"),
+				Mutex:      new(sync.Mutex),
+			},
+			DataFilePath:    datastorePath,
+			VersionFilePath: datastorePath + "_version",
+			LockedFilePath:  datastorePath + "_lock",
+			CacheMutex:      new(sync.RWMutex),
+		}
+		Expect(store.Add("some-handle", "10.255.1.2", map[string]interface{}{})).To(Succeed())
+		Expect(store.Add("other-handle", "10.255.1.3", map[string]interface{}{})).To(Succeed())
+	})
+
+	It("prints the findings of every container in the datastore", func() {
+		Expect(main.AnalyzeRules(out, datastorePath, nil, analyzer)).To(Succeed())
+		Expect(out.String()).To(Equal(`other-handle: no findings
+some-handle:
+  conflicting: -p tcp -m iprange --dst-range 0.0.0.0-255.255.255.255 -m tcp --dport 443 -j ACCEPT
+    by: -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable
+    overlap: 10.0.0.0/8
+  shadowed: -p tcp -m iprange --dst-range 10.0.0.1-10.0.0.1 -m tcp --dport 443 -j ACCEPT
+    by: -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable
+    by: -p tcp -m iprange --dst-range 0.0.0.0-255.255.255.255 -m tcp --dport 443 -j ACCEPT
+`))
+	})
+
+	Context("when containers are given", func() {
+		It("prints only their findings", func() {
+			Expect(main.AnalyzeRules(out, datastorePath, []string{"other-handle"}, analyzer)).To(Succeed())
+			Expect(out.String()).To(Equal("other-handle: no findings\n"))
+		})
+	})
+
+	Context("when a container is not in the datastore", func() {
+		It("returns an error", func() {
+			err := main.AnalyzeRules(out, datastorePath, []string{"missing-handle"}, analyzer)
+			Expect(err).To(MatchError("container missing-handle not found in datastore"))
+		})
+	})
+})
+
+var _ = Describe("vpa simulate", func() {
+	It("prints the durations of the simulated cycles", func() {
+		out := &bytes.Buffer{}
+		main.PrintCycles(out, simulation.Load{
+			Containers:      250,
+			InstancesPerApp: 2,
+			AppsPerSpace:    10,
+			PoliciesPerApp:  5,
+			ASGRules:        100,
+		}, []simulation.Cycle{
+			{Name: "initial", PolicyPlan: 12 * time.Millisecond, PolicyEnforce: 40 * time.Millisecond, ASGPlan: 95 * time.Millisecond, ASGEnforce: 3200 * time.Millisecond},
+			{Name: "unchanged", PolicyPlan: 11 * time.Millisecond, PolicyEnforce: 1400 * time.Microsecond, ASGPlan: 90 * time.Millisecond, ASGEnforce: 8 * time.Millisecond},
+		})
+
+		Expect(out.String()).To(Equal(
+			"250 containers, 2 instances per app, 10 apps per space, 5 policies per app, 100 asg rules\n\n" +
+				"cycle      policy plan  policy enforce  asg plan  asg enforce  total\n" +
+				"initial    12ms         40ms            95ms      3.2s         3.347s\n" +
+				"unchanged  11ms         1ms             90ms      8ms          110ms\n"))
+	})
+})
+
+var _ = Describe("vpa state", func() {
+	var (
+		dir           string
+		datastorePath string
+		archivePath   string
+		out           *bytes.Buffer
+		snapshotter   *cellstate.Snapshotter
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		datastorePath = filepath.Join(dir, "container-metadata", "store.json")
+		archivePath = filepath.Join(dir, "state.tgz")
+		out = &bytes.Buffer{}
+
+		Expect(os.MkdirAll(filepath.Dir(datastorePath), 0755)).To(Succeed())
+		Expect(os.WriteFile(datastorePath, []byte(`{"some-handle":{}}`), 0600)).To(Succeed())
+		Expect(os.WriteFile(datastorePath+"_version", []byte(`{"version":2}`), 0600)).To(Succeed())
+		snapshotter = &cellstate.Snapshotter{
+			Files: main.StateFiles(datastorePath)[:2],
+			Commands: []cellstate.Command{
+				{Name: "iptables/rules", Args: []string{"echo", "-A some-chain -j ACCEPT"}},
+				{Name: "vtep/link.json", Args: []string{"false"}},
+			},
+		}
+	})
+
+	It("captures the stores next to the datastore", func() {
+		Expect(main.StateFiles(datastorePath)[:4]).To(Equal([]cellstate.File{
+			{Name: "container-metadata/store.json", Path: datastorePath, LockPath: datastorePath + "_lock"},
+			{Name: "container-metadata/store.json_version", Path: datastorePath + "_version", LockPath: datastorePath + "_lock"},
+			{Name: "container-metadata/asg-chains.json", Path: filepath.Join(dir, "container-metadata", "asg-chains.json"), LockPath: filepath.Join(dir, "container-metadata", "asg-chains.json_lock")},
+			{Name: "container-metadata/chain-owners.json", Path: filepath.Join(dir, "container-metadata", "chain-owners.json"), LockPath: filepath.Join(dir, "container-metadata", "chain-owners.json_lock")},
+		}))
+	})
+
+	It("snapshots, verifies and restores the state", func() {
+		Expect(main.SnapshotState(out, archivePath, snapshotter)).To(Succeed())
+		Expect(out.String()).To(Equal(
+			"container-metadata/store.json          18 bytes\n" +
+				"container-metadata/store.json_version  13 bytes\n" +
+				"iptables/rules                         24 bytes\n" +
+				"vtep/link.json                         failed: exit status 1\n" +
+				"wrote " + archivePath + "\n"))
+
+		out.Reset()
+		Expect(main.VerifyState(out, archivePath)).To(Succeed())
+		Expect(out.String()).To(HavePrefix("captured on "))
+		Expect(out.String()).To(HaveSuffix(archivePath + " is intact\n"))
+
+		out.Reset()
+		root := filepath.Join(dir, "root")
+		Expect(main.RestoreState(out, archivePath, &cellstate.Restorer{Root: root, Files: snapshotter.Files})).To(Succeed())
+		Expect(out.String()).To(Equal(
+			"restored container-metadata/store.json\n" +
+				"restored container-metadata/store.json_version\n"))
+		Expect(filepath.Join(root, datastorePath)).To(BeAnExistingFile())
+	})
+
+	Context("when the archive exists", func() {
+		It("does not overwrite it", func() {
+			Expect(os.WriteFile(archivePath, []byte("some-archive"), 0600)).To(Succeed())
+			err := main.SnapshotState(out, archivePath, snapshotter)
+			Expect(err).To(MatchError(ContainSubstring("create archive:")))
+		})
+	})
+
+	Context("when the archive is missing", func() {
+		It("returns an error", func() {
+			Expect(main.VerifyState(out, archivePath)).To(MatchError(ContainSubstring("open archive:")))
+			Expect(main.RestoreState(out, archivePath, &cellstate.Restorer{})).To(MatchError(ContainSubstring("open archive:")))
+		})
+	})
+})
+
+var _ = Describe("vpa plans diff", func() {
+	var (
+		dir    string
+		out    *bytes.Buffer
+		writer *plandump.Writer
+		chain  enforcer.Chain
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		out = &bytes.Buffer{}
+		now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		writer = &plandump.Writer{
+			Dir: dir,
+			Now: func() time.Time {
+				now = now.Add(time.Minute)
+				return now
+			},
+		}
+		chain = enforcer.Chain{Table: "filter", ParentChain: "netout-1", Prefix: "asg-1"}
+	})
+
+	It("prints the rule sets that changed between the plans", func() {
+		Expect(writer.Write(plandump.CycleASG, []enforcer.RulesWithChain{
+			{Chain: chain, Handle: "container-1", Rules: []rules.IPTablesRule{{"-d", "10.0.0.1", "-j", "ACCEPT"}}},
+		})).To(Succeed())
+		Expect(writer.Write(plandump.CycleASG, []enforcer.RulesWithChain{
+			{Chain: chain, Handle: "container-1", Rules: []rules.IPTablesRule{{"-d", "10.0.0.2", "-j", "ACCEPT"}}},
+		})).To(Succeed())
+
+		names, err := plandump.List(dir, plandump.CycleASG)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(main.DiffPlans(out, filepath.Join(dir, names[0]), filepath.Join(dir, names[1]))).To(Succeed())
+		Expect(out.String()).To(Equal(
+			"asg plan of 2026-01-02T03:05:05Z to asg plan of 2026-01-02T03:06:05Z: 1 rule sets changed\n" +
+				"changed  filter  netout-1  asg-1  container-1  1 to 1 rules (+1 -1)\n" +
+				"         + -d 10.0.0.2 -j ACCEPT\n" +
+				"         - -d 10.0.0.1 -j ACCEPT\n"))
+	})
+
+	Context("when a plan is missing", func() {
+		It("returns an error", func() {
+			err := main.DiffPlans(out, filepath.Join(dir, "missing.json"), filepath.Join(dir, "missing.json"))
+			Expect(err).To(MatchError(ContainSubstring("reading plan:")))
+		})
+	})
+})
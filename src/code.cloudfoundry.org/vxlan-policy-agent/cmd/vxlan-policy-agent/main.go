@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -15,22 +16,34 @@ import (
 	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/lib/common"
+	"code.cloudfoundry.org/lib/containerevents"
 	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/featureflags"
 	"code.cloudfoundry.org/lib/interfacelookup"
+	"code.cloudfoundry.org/lib/iptablesrecord"
 	"code.cloudfoundry.org/lib/poller"
 	"code.cloudfoundry.org/lib/rules"
 	"code.cloudfoundry.org/lib/serial"
+	"code.cloudfoundry.org/lib/tlsreload"
 	"code.cloudfoundry.org/policy_client"
+	"code.cloudfoundry.org/silk/cni/netinfo"
+	"code.cloudfoundry.org/vxlan-policy-agent/chaos"
 	"code.cloudfoundry.org/vxlan-policy-agent/config"
 	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/destinations"
 	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
 	"code.cloudfoundry.org/vxlan-policy-agent/handlers"
+	"code.cloudfoundry.org/vxlan-policy-agent/plandump"
 	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+	"code.cloudfoundry.org/vxlan-policy-agent/policysource"
 
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
-	"code.cloudfoundry.org/cf-networking-helpers/mutualtls"
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
 	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/filelock"
+	gardenclient "code.cloudfoundry.org/garden/client"
+	"code.cloudfoundry.org/garden/client/connection"
 	"code.cloudfoundry.org/go-loggregator/v8/runtimeemitter"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
@@ -59,6 +72,7 @@ func die(logger lager.Logger, action string, err error) {
 
 func main() {
 	configFilePath := flag.String("config-file", "", "path to config file")
+	shardIndex := flag.Int("shard-index", 0, "shard of the containers that this worker enforces the ASGs of")
 	flag.Parse()
 
 	conf, err := config.New(*configFilePath)
@@ -80,6 +94,14 @@ func main() {
 
 	logger.Info("parsed-config", lager.Data{"config": conf})
 
+	shard := planner.Shard{Index: *shardIndex, Count: conf.Sharding.Workers}
+	if shard.Index < 0 || (shard.Index > 0 && shard.Index >= shard.Count) {
+		die(logger, "shard-index", fmt.Errorf("shard %d out of %d workers", shard.Index, shard.Count))
+	}
+	if shard.Sharded() {
+		logger = logger.WithData(lager.Data{"shard": shard.Index})
+	}
+
 	_, err = os.Stat(filepath.Dir(conf.Datastore))
 	if err != nil {
 		die(logger, "datastore-directory-stat", err)
@@ -101,21 +123,35 @@ func main() {
 
 	asgPollInterval := time.Duration(conf.ASGPollInterval) * time.Second
 
-	clientTLSConfig, err := mutualtls.NewClientTLSConfig(conf.ClientCertFile, conf.ClientKeyFile, conf.ServerCACertFile)
+	clientCredentials, err := tlsreload.New(logger.Session("client-credentials"), conf.ClientCertFile, conf.ClientKeyFile, conf.ServerCACertFile)
 	if err != nil {
 		die(logger, "mutual tls config", err)
 	}
+	if err := clientCredentials.AuthorizeSPIFFEIDs(conf.PolicyServerSPIFFEIDs); err != nil {
+		die(logger, "mutual tls config", err)
+	}
 
 	httpClient := &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: clientTLSConfig,
+			DialTLSContext: clientCredentials.DialTLSContext,
 		},
 		Timeout: time.Duration(conf.ClientTimeoutSeconds) * time.Second,
 	}
 
+	metricsSender := &metrics.MetricsSender{
+		Logger: logger.Session("time-metric-emitter"),
+	}
+
+	meteredHTTPClient := &planner.MeteredHTTPClient{
+		HTTPClient:    httpClient,
+		MetricsSender: metricsSender,
+	}
+	policyServerCache := &planner.ConditionalHTTPClient{
+		HTTPClient: meteredHTTPClient,
+	}
 	policyClient := policy_client.NewInternal(
 		logger.Session("policy-client"),
-		httpClient,
+		policyServerCache,
 		conf.PolicyServerURL,
 		policy_client.DefaultConfig,
 	)
@@ -138,6 +174,16 @@ func main() {
 		CacheMutex:      new(sync.RWMutex),
 	}
 
+	chainOwnersFile := datastore.ChainOwnersFilePath(conf.Datastore)
+	chainOwners := &datastore.ChainOwners{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(chainOwnersFile + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath: chainOwnersFile,
+	}
+
 	ipt, err := iptables.New()
 	if err != nil {
 		die(logger, "iptables-new", err)
@@ -153,9 +199,27 @@ func main() {
 		Locker:   iptLocker,
 		Restorer: restorer,
 	}
-
-	metricsSender := &metrics.MetricsSender{
-		Logger: logger.Session("time-metric-emitter"),
+	var enforcerIPTables rules.IPTablesAdapter = lockedIPTables
+	logLimit := rules.LogLimit{PerSecond: conf.IPTablesCellLogsPerSec, Burst: conf.IPTablesCellLogsBurst}
+	if logLimit.Enabled() {
+		enforcerIPTables = &rules.LogLimitedIPTables{IPTablesAdapter: lockedIPTables, Limit: logLimit}
+	}
+	if conf.IPTablesRecordFile != "" {
+		recordFile := conf.IPTablesRecordFile
+		if shard.Index > 0 {
+			recordFile = fmt.Sprintf("%s.%d", recordFile, shard.Index)
+		}
+		recording, err := os.OpenFile(recordFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			die(logger, "open-iptables-record-file", err)
+		}
+		defer recording.Close()
+		enforcerIPTables = &iptablesrecord.Recorder{
+			IPTables: enforcerIPTables,
+			Writer:   recording,
+			Logger:   logger.Session("iptables-recorder"),
+		}
+		logger.Info("recording-iptables-calls", lager.Data{"file": recordFile})
 	}
 
 	iptablesLoggingState := &planner.LoggingState{}
@@ -174,15 +238,50 @@ func main() {
 
 	netOutChain := &netrules.NetOutChain{
 		ChainNamer: chainNamer,
-		Converter:  &netrules.RuleConverter{Logger: logger},
+		Converter:  &netrules.RuleConverter{Logger: logger, MetricsSender: metricsSender},
 		ASGLogging: conf.IPTablesASGLogging,
 		DenyNetworks: netrules.DenyNetworks{
 			Always:  conf.DenyNetworks.Always,
 			Running: conf.DenyNetworks.Running,
 			Staging: conf.DenyNetworks.Staging,
 		},
-		DeniedLogsPerSec: conf.IPTablesDeniedLogsPerSec,
-		Conn:             outConn,
+		DeniedLogsPerSec:         conf.IPTablesDeniedLogsPerSec,
+		Conn:                     outConn,
+		RejectTCPWithReset:       conf.RejectTCPWithReset,
+		DeniedLogsPerDestination: conf.IPTablesDeniedLogsPerDest,
+		RelatedEstablished:       conf.RelatedEstablished.NetRules(),
+		SNIInspection:            conf.SNIInspection.NetRules(),
+	}
+
+	var featureFlags *featureflags.Set
+	var loggingState loggingStateGetter = iptablesLoggingState
+	if conf.FeatureFlags.File != "" {
+		featureFlags, err = featureflags.New(logger.Session("feature-flags"), conf.FeatureFlags.File, conf.FeatureFlags.CellID)
+		if err != nil {
+			logger.Error("load-feature-flags", err)
+		}
+		featureFlags.MetricsSender = metricsSender
+		netOutChain.FeatureFlags = featureFlags
+		loggingState = &flaggedLoggingState{LoggingState: iptablesLoggingState, flags: featureFlags}
+	}
+
+	policySources := []planner.PolicySource{}
+	policySourceHTTPClient := &http.Client{
+		Timeout: time.Duration(conf.ClientTimeoutSeconds) * time.Second,
+	}
+	for _, source := range conf.PolicySources {
+		policySources = append(policySources, &policysource.Webhook{
+			Client: json_client.New(logger.Session("policy-source"), policySourceHTTPClient, source.URL),
+		})
+	}
+
+	qosClasses := []planner.QoSClass{}
+	for _, class := range conf.QoSClasses {
+		qosClasses = append(qosClasses, planner.QoSClass{
+			Name:              class.Name,
+			DSCP:              class.DSCP,
+			RateLimitKBPerSec: class.RateLimitKBPerSec,
+		})
 	}
 
 	dynamicPlanner := &planner.VxlanPolicyPlanner{
@@ -196,31 +295,162 @@ func main() {
 			ParentChain: "FORWARD",
 			Prefix:      "vpa--",
 		},
-		LoggingState:                  iptablesLoggingState,
+		LoggingState:                  loggingState,
 		IPTablesAcceptedUDPLogsPerSec: conf.IPTablesAcceptedUDPLogsPerSec,
 		EnableOverlayIngressRules:     conf.EnableOverlayIngressRules,
 		HostInterfaceNames:            interfaceNames,
 		NetOutChain:                   netOutChain,
+		EgressProxySpaceGUIDs:         conf.EgressProxy.SpaceGUIDs,
+		EgressProxyRules:              conf.EgressProxy.SecurityGroupRules(),
+		SNIAllowlistRules:             conf.SNIInspection.SecurityGroupRules(),
+		PolicySources:                 policySources,
+		PolicyServerCache:             policyServerCache,
+		PayloadMeter:                  meteredHTTPClient,
+		SubChainMinRules:              conf.IPTablesSubChainMinRules,
+		Shard:                         shard,
+		QoSClasses:                    qosClasses,
+		AcceptICMPEcho:                conf.ContainerICMPEcho == config.ContainerICMPEchoAccept,
+		HealthCheckSources:            conf.HealthCheckSources,
+	}
+
+	if conf.EnableDestinations {
+		dynamicPlanner.DestinationChains = &destinations.Syncer{
+			Logger: logger.Session("destinations"),
+			Destinations: &destinations.Client{
+				JSONClient: json_client.New(logger.Session("destinations-client"), httpClient, conf.PolicyServerURL),
+			},
+			IPTables:  enforcerIPTables,
+			Converter: &netrules.RuleConverter{Logger: logger, MetricsSender: metricsSender},
+		}
+	}
+
+	planners := []converger.Planner{dynamicPlanner}
+	for _, globalChain := range conf.GlobalChains {
+		globalChainPlanner := &planner.GlobalChainPlanner{
+			Logger: logger.Session("global-chain-planner"),
+			Chain: enforcer.Chain{
+				Table:       globalChain.Table,
+				ParentChain: globalChain.ParentChain,
+				Prefix:      globalChain.Name + "--",
+			},
+			OverlayNetwork: conf.OverlayNetwork,
+		}
+		if len(conf.UnderlayIPs) > 0 {
+			globalChainPlanner.CellIP = conf.UnderlayIPs[0]
+		}
+		if conf.SilkDaemonPort != 0 {
+			globalChainPlanner.NetworkInfo = &netinfo.Daemon{
+				JSONClient: json_client.New(logger.Session("silk-daemon-client"), http.DefaultClient, fmt.Sprintf("http://127.0.0.1:%d", conf.SilkDaemonPort)),
+			}
+		}
+		err = globalChainPlanner.SetRules(globalChain.Rules)
+		if err != nil {
+			die(logger, "global-chain-rules", fmt.Errorf("%s: %s", globalChain.Name, err))
+		}
+		for _, table := range globalChain.ExtraTables {
+			err = globalChainPlanner.AddTable(enforcer.Chain{
+				Table:       table.Table,
+				ParentChain: table.ParentChain,
+				Prefix:      globalChain.Name + "--",
+			}, table.Rules)
+			if err != nil {
+				die(logger, "global-chain-rules", fmt.Errorf("%s in %s: %s", globalChain.Name, table.Table, err))
+			}
+		}
+		planners = append(planners, globalChainPlanner)
+	}
+
+	var chaosFaults *chaos.Source
+	if conf.Chaos.Enabled() {
+		logger.Info("chaos-enabled", lager.Data{"faults_file": conf.Chaos.FaultsFile})
+		chaosFaults = &chaos.Source{Path: conf.Chaos.FaultsFile, Logger: logger.Session("chaos"), Now: time.Now}
+		for _, chain := range chaos.Chains() {
+			planners = append(planners, &chaos.Planner{Faults: chaosFaults, Chain: chain, VTEPPort: conf.Chaos.VTEPPort})
+		}
+	}
+
+	var intentLog enforcer.IntentLog
+	if conf.IntentLogFile != "" {
+		intentLogFile := conf.IntentLogFile
+		if shard.Index > 0 {
+			intentLogFile = fmt.Sprintf("%s.%d", intentLogFile, shard.Index)
+		}
+		intentLog = &enforcer.FileIntentLog{Path: intentLogFile}
 	}
 
 	timestamper := &enforcer.Timestamper{}
 	ruleEnforcer := enforcer.NewEnforcer(
 		logger.Session("rules-enforcer"),
 		timestamper,
-		lockedIPTables,
+		enforcerIPTables,
 		enforcer.EnforcerConfig{
 			DisableContainerNetworkPolicy: conf.DisableContainerNetworkPolicy,
 			OverlayNetwork:                conf.OverlayNetwork,
+			ChainOwners:                   chainOwners,
+			ChainNameVersion:              conf.ManagedChainNameVersion,
+			OwnsChain:                     shard.OwnsChain,
+			IntentLog:                     intentLog,
 		},
 	)
 
+	// changes that a crash interrupted are repaired before any other
+	repaired, err := ruleEnforcer.RecoverIntents()
+	if err != nil {
+		die(logger, "recover-intents", err)
+	}
+	if repaired > 0 {
+		logger.Info("repaired-interrupted-changes", lager.Data{"changes": repaired})
+	}
+
+	// chains of global chains that were removed from the config are not
+	// planned anymore, so they are only cleaned up here
+	globalChainPrefixes := map[string][]string{enforcer.FilterTable: {"vpa--"}}
+	if len(qosClasses) > 0 {
+		globalChainPrefixes[planner.QoSTable] = []string{"vpa--"}
+	}
+	for _, globalChain := range conf.GlobalChains {
+		globalChainPrefixes[globalChain.Table] = append(globalChainPrefixes[globalChain.Table], globalChain.Name+"--")
+		for _, table := range globalChain.ExtraTables {
+			globalChainPrefixes[table.Table] = append(globalChainPrefixes[table.Table], globalChain.Name+"--")
+		}
+	}
+	if conf.Chaos.Enabled() {
+		for _, chain := range chaos.Chains() {
+			globalChainPrefixes[chain.Table] = append(globalChainPrefixes[chain.Table], chain.Prefix)
+		}
+	}
+	for _, table := range config.GlobalChainTables {
+		if !shard.Coordinator() {
+			break
+		}
+		removed, err := ruleEnforcer.CleanChainsWithoutPrefix(table, regexp.MustCompile(planner.GlobalChainsRegex), globalChainPrefixes[table])
+		if err != nil {
+			logger.Error("clean-removed-global-chains", err, lager.Data{"table": table})
+		} else if len(removed) > 0 {
+			logger.Info("cleaned-removed-global-chains", lager.Data{"table": table, "chains": removed})
+		}
+	}
+
 	err = dropsonde.Initialize(conf.MetronAddress, dropsondeOrigin)
 	if err != nil {
 		log.Fatalf("%s: initializing dropsonde: %s", logPrefix, err)
 	}
 
-	uptimeSource := metrics.NewUptimeSource()
-	metricsEmitter := metrics.NewMetricsEmitter(logger, emitInterval, uptimeSource)
+	iptablesRunner, err := runner.NewCommandRunner("iptables", true)
+	if err != nil {
+		die(logger, "iptables-runner", err)
+	}
+
+	metricSources := []metrics.MetricSource{metrics.NewUptimeSource()}
+	if logLimit.Enabled() {
+		suppressedLogsCounter := &rules.SuppressedLogsCounter{IPTablesRunner: iptablesRunner}
+		metricSources = append(metricSources, metrics.MetricSource{
+			Name:   "iptablesSuppressedLogs",
+			Unit:   "",
+			Getter: suppressedLogsCounter.SuppressedLogs,
+		})
+	}
+	metricsEmitter := metrics.NewMetricsEmitter(logger, emitInterval, metricSources...)
 
 	metronClient, err := loggingclient.NewIngressClient(conf.LoggregatorConfig)
 	if err != nil {
@@ -232,28 +462,133 @@ func main() {
 		go emitter.Run()
 	}
 
+	stuckProcesses := &enforcer.StuckProcesses{
+		ProcDir:   "/proc",
+		Commands:  []string{"iptables", "iptables-restore"},
+		LockFiles: []string{conf.IPTablesLockFile, "/run/xtables.lock"},
+	}
+	if dmesgRunner, err := runner.NewCommandRunner("dmesg", true); err == nil {
+		stuckProcesses.DmesgRunner = dmesgRunner
+	} else {
+		logger.Error("dmesg-not-found", err)
+	}
+	enforcementWatchdog := &converger.EnforcementWatchdog{
+		Enforcer:       ruleEnforcer,
+		Timeout:        time.Duration(conf.EnforcementTimeout) * time.Second,
+		StuckProcesses: stuckProcesses,
+		MetricsSender:  metricsSender,
+		Logger:         logger.Session("enforcement-watchdog"),
+	}
+
 	singlePollCycle := converger.NewSinglePollCycle(
-		[]converger.Planner{dynamicPlanner},
-		ruleEnforcer,
+		planners,
+		enforcementWatchdog,
 		policyClient,
 		metricsSender,
 		metronClient,
 		logger,
 	)
 
+	asgChainsFile := datastore.ASGChainsFilePath(conf.Datastore)
+	singlePollCycle.ASGChainStore = &datastore.ASGChains{
+		Serializer: &serial.Serial{},
+		Locker: &filelock.Locker{
+			FileLocker: filelock.NewLocker(asgChainsFile + "_lock"),
+			Mutex:      new(sync.Mutex),
+		},
+		DataFilePath: asgChainsFile,
+		OwnsChain:    shard.OwnsChain,
+	}
+	singlePollCycle.ASGSyncBatchSize = conf.ASGSyncBatchSize
+	singlePollCycle.EnforcementStatusStore = store
+	if chaosFaults != nil {
+		singlePollCycle.EnforcementDelay = chaosFaults
+	}
+
+	if conf.PlanDumpDir != "" && conf.PlanDumpsToKeep > 0 {
+		planDumpDir := conf.PlanDumpDir
+		if shard.Index > 0 {
+			planDumpDir = fmt.Sprintf("%s.%d", planDumpDir, shard.Index)
+		}
+		singlePollCycle.PlanDumper = &plandump.Writer{Dir: planDumpDir, Keep: conf.PlanDumpsToKeep, Now: time.Now}
+	}
+
+	if conf.ASGSyncingPauseFile != "" {
+		if _, err := os.Stat(conf.ASGSyncingPauseFile); err == nil {
+			singlePollCycle.PauseASGSyncing()
+		}
+	}
+
+	backendDetector := &rules.BackendDetector{IPTablesRunner: iptablesRunner}
+	iptablesBackend, err := backendDetector.Detect()
+	if err != nil {
+		die(logger, "detect-iptables-backend", err)
+	}
+	logger.Info("iptables-backend", lager.Data{"backend": iptablesBackend})
+	backendWatchdog := &converger.BackendWatchdog{
+		Detector: backendDetector,
+		Backend:  iptablesBackend,
+		Adapt:    conf.IPTablesBackendChange == config.IPTablesBackendChangeAdapt,
+		AdaptFunc: func() error {
+			singlePollCycle.ResetCaches()
+			if shard.Coordinator() {
+				err := singlePollCycle.DoPolicyCycle()
+				if err != nil {
+					return err
+				}
+			}
+			if !conf.EnableASGSyncing {
+				return nil
+			}
+			return singlePollCycle.DoASGCycle()
+		},
+		MetricsSender: metricsSender,
+		Logger:        logger.Session("iptables-backend-watchdog"),
+	}
+
 	policyPoller := &poller.Poller{
 		Logger:          logger,
 		PollInterval:    pollInterval,
-		SingleCycleFunc: singlePollCycle.DoPolicyCycleWithLastUpdatedCheck,
+		SingleCycleFunc: backendWatchdog.Guard(singlePollCycle.DoPolicyCycleWithLastUpdatedCheck),
+	}
+	if len(policySources) > 0 || conf.Chaos.Enabled() {
+		// policies of the policy sources and the faults of drills change
+		// without the policy server noticing, so every cycle has to plan
+		policyPoller.SingleCycleFunc = backendWatchdog.Guard(singlePollCycle.DoPolicyCycle)
 	}
 
 	asgPoller := &poller.Poller{
 		Logger:          logger,
 		PollInterval:    asgPollInterval,
-		SingleCycleFunc: singlePollCycle.DoASGCycle,
+		SingleCycleFunc: backendWatchdog.Guard(singlePollCycle.DoASGCycle),
 	}
 
-	forcePolicyPollCycleServerAddress := fmt.Sprintf("%s:%d", conf.ForcePolicyPollCycleHost, conf.ForcePolicyPollCyclePort)
+	forcePolicyPollCycleServerAddress := fmt.Sprintf("%s:%d", conf.ForcePolicyPollCycleHost, conf.ForcePolicyPollCyclePortOf(shard.Index))
+
+	wrapperChainCleaner := &converger.WrapperChainCleaner{
+		Enforcer:    ruleEnforcer,
+		ChainOwners: chainOwners,
+	}
+	containerDrainer := &converger.ContainerDrainer{
+		Store:                   store,
+		WrapperChainCleanupFunc: wrapperChainCleaner.CleanupContainerChains,
+		PolicyCycleFunc:         singlePollCycle.DoPolicyCycle,
+		Logger:                  logger,
+	}
+	if conf.EnableASGSyncing {
+		// the ASG chains of the containers of other workers are cleaned up
+		// by their owners when they enforce the ASGs next
+		containerDrainer.ASGCleanupFunc = func(handle string) ([]enforcer.LiveChain, error) {
+			if !shard.Owns(handle) {
+				return nil, nil
+			}
+			return singlePollCycle.CleanupASGsChainsForContainer(handle)
+		}
+	}
+	asgCleanupQueue := &converger.ASGCleanupQueue{
+		ASGCleanupFunc: singlePollCycle.CleanupASGsChainsForContainer,
+		Logger:         logger.Session("asg-cleanup-queue"),
+	}
 
 	forceHandlers := map[string]http.Handler{
 		"/force-policy-poll-cycle": &handlers.ForcePolicyPollCycle{
@@ -264,24 +599,133 @@ func main() {
 			EnableASGSyncing: conf.EnableASGSyncing,
 		},
 		"/force-orphaned-asgs-cleanup": &handlers.ForceOrphanedASGsCleanup{
-			ASGCleanupFunc:   singlePollCycle.CleanupOrphanedASGsChains,
+			ASGCleanupFunc:   asgCleanupQueue.Cleanup,
+			EnableASGSyncing: conf.EnableASGSyncing,
+		},
+		"/drain-container": &handlers.DrainContainer{
+			DrainFunc: containerDrainer.DrainContainer,
+		},
+		"/asg-syncing": &handlers.ASGSyncing{
+			State:            singlePollCycle,
+			PauseFile:        conf.ASGSyncingPauseFile,
+			EnableASGSyncing: conf.EnableASGSyncing,
+		},
+		"/health": &handlers.Health{
+			ASGSyncingState:  singlePollCycle,
 			EnableASGSyncing: conf.EnableASGSyncing,
 		},
 	}
 
+	if shard.Sharded() {
+		if shard.Coordinator() {
+			workerAddresses := make([]string, shard.Count)
+			for i := range workerAddresses {
+				workerAddresses[i] = fmt.Sprintf("127.0.0.1:%d", conf.ForcePolicyPollCyclePortOf(i))
+			}
+			for _, path := range []string{"/force-asgs-for-container", "/force-orphaned-asgs-cleanup"} {
+				forceHandlers[path] = &handlers.ForwardToShard{
+					ShardOf:         shard.Of,
+					Index:           shard.Index,
+					WorkerAddresses: workerAddresses,
+					Handler:         forceHandlers[path],
+				}
+			}
+		} else {
+			delete(forceHandlers, "/force-policy-poll-cycle")
+			delete(forceHandlers, "/drain-container")
+		}
+	}
+
 	forcePolicyPollCycleServer := createForceUpdateServer(forcePolicyPollCycleServerAddress, forceHandlers)
 
-	debugServerAddress := fmt.Sprintf("%s:%d", conf.DebugServerHost, conf.DebugServerPort)
-	debugServer := createCustomDebugServer(debugServerAddress, reconfigurableSink, iptablesLoggingState)
+	debugServerAddress := fmt.Sprintf("%s:%d", conf.DebugServerHost, conf.DebugServerPortOf(shard.Index))
+	var selfMetrics http.Handler
+	if conf.EnableSelfMetrics {
+		selfMetrics = &handlers.SelfMetrics{Caches: singlePollCycle}
+	}
+	debugServer := createCustomDebugServer(debugServerAddress, reconfigurableSink, iptablesLoggingState, selfMetrics)
 	members := grouper.Members{
 		{Name: "metrics_emitter", Runner: metricsEmitter},
-		{Name: "policy_poller", Runner: policyPoller},
-		{Name: "debug-server", Runner: debugServer},
-		{Name: "force-policy-poll-cycle-server", Runner: forcePolicyPollCycleServer},
+	}
+	if shard.Coordinator() {
+		members = append(members, grouper.Member{Name: "policy_poller", Runner: policyPoller})
+
+		if conf.ContainerEventsSocket != "" {
+			// the cni-wrapper-plugin forces a cycle for added containers, so
+			// the rules of deleted ones are removed right away as well
+			trigger, pollPolicies := poller.NewTrigger()
+			policyPoller.Trigger = trigger
+			members = append(members, grouper.Member{Name: "container_events", Runner: &containerevents.Subscriber{
+				Path: conf.ContainerEventsSocket,
+				Handler: func(event containerevents.Event) {
+					if event.Type == containerevents.TypeDel {
+						pollPolicies()
+					}
+				},
+				Logger: logger.Session("container-events"),
+			}})
+		}
+	}
+	members = append(members,
+		grouper.Member{Name: "debug-server", Runner: debugServer},
+		grouper.Member{Name: "force-policy-poll-cycle-server", Runner: forcePolicyPollCycleServer},
+		grouper.Member{Name: "client_credentials", Runner: clientCredentials},
+	)
+	if featureFlags != nil {
+		members = append(members, grouper.Member{Name: "feature_flags", Runner: featureFlags})
 	}
 
 	if conf.EnableASGSyncing {
 		members = append(members, grouper.Member{Name: "asg_poller", Runner: asgPoller})
+
+		if conf.ASGCleanupRetryInterval > 0 {
+			members = append(members, grouper.Member{Name: "asg_cleanup_retrier", Runner: &poller.Poller{
+				Logger:          logger,
+				PollInterval:    time.Duration(conf.ASGCleanupRetryInterval) * time.Second,
+				SingleCycleFunc: asgCleanupQueue.Retry,
+			}})
+		}
+	}
+
+	if conf.RuntimeReconcileInterval > 0 && shard.Coordinator() {
+		runtimeReconciler := &converger.RuntimeReconciler{
+			Store: store,
+			Runtime: &converger.GardenRuntime{
+				Client: gardenclient.New(connection.New(conf.GardenNetwork, conf.GardenAddress)),
+			},
+			DrainFunc: containerDrainer.DrainContainer,
+			Logger:    logger.Session("runtime-reconciler"),
+		}
+		members = append(members, grouper.Member{Name: "runtime_reconciler", Runner: &poller.Poller{
+			Logger:          logger,
+			PollInterval:    time.Duration(conf.RuntimeReconcileInterval) * time.Second,
+			SingleCycleFunc: runtimeReconciler.Reconcile,
+		}})
+	}
+
+	if conf.ConsistencyCheckInterval > 0 {
+		// every worker checks the ASG chains of its own containers, and the
+		// coordinator the chains of the cni-wrapper-plugin
+		consistencyChecker := &converger.ConsistencyChecker{
+			Store:              store,
+			IPTables:           enforcerIPTables,
+			Enforcer:           ruleEnforcer,
+			ChainOwners:        chainOwners,
+			DefaultRules:       netOutChain.DefaultRules,
+			SyncASGs:           singlePollCycle.SyncASGsForContainers,
+			CheckWrapperChains: shard.Coordinator(),
+			CheckASGChains:     conf.EnableASGSyncing,
+			Owns:               shard.Owns,
+			OwnsChain:          shard.OwnsChain,
+			Repair:             conf.RepairInconsistencies,
+			MetricsSender:      metricsSender,
+			Logger:             logger.Session("consistency-checker"),
+		}
+		members = append(members, grouper.Member{Name: "consistency_checker", Runner: &poller.Poller{
+			Logger:          logger,
+			PollInterval:    time.Duration(conf.ConsistencyCheckInterval) * time.Second,
+			SingleCycleFunc: consistencyChecker.Check,
+		}})
 	}
 
 	monitor := ifrit.Invoke(sigmon.New(grouper.NewOrdered(os.Interrupt, members)))
@@ -317,11 +761,29 @@ func initLoggerSink(logger lager.Logger, level string) *lager.ReconfigurableSink
 	return lager.NewReconfigurableSink(w, logLevel)
 }
 
-func createCustomDebugServer(listenAddress string, sink *lager.ReconfigurableSink, iptablesLoggingState *planner.LoggingState) ifrit.Runner {
+type loggingStateGetter interface {
+	IsEnabled() bool
+}
+
+// flaggedLoggingState lets the c2c logging flag override the logging state
+// of the debug server.
+type flaggedLoggingState struct {
+	*planner.LoggingState
+	flags *featureflags.Set
+}
+
+func (l *flaggedLoggingState) IsEnabled() bool {
+	return l.flags.Enabled(featureflags.IPTablesC2CLogging, l.LoggingState.IsEnabled())
+}
+
+func createCustomDebugServer(listenAddress string, sink *lager.ReconfigurableSink, iptablesLoggingState *planner.LoggingState, selfMetrics http.Handler) ifrit.Runner {
 	mux := debugserver.Handler(sink).(*http.ServeMux)
 	mux.Handle("/iptables-c2c-logging", &handlers.IPTablesLogging{
 		LoggingState: iptablesLoggingState,
 	})
+	if selfMetrics != nil {
+		mux.Handle("/self-metrics", selfMetrics)
+	}
 	return http_server.New(listenAddress, mux)
 }
 
@@ -1,71 +1,339 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"net"
+	"net/url"
+	"regexp"
 
 	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	libconfig "code.cloudfoundry.org/lib/config"
+	"code.cloudfoundry.org/vxlan-policy-agent/chaos"
 	validator "gopkg.in/validator.v2"
 )
 
 type VxlanPolicyAgent struct {
-	PollInterval                  int                       `json:"poll_interval" validate:"nonzero"`
-	EnableASGSyncing              bool                      `json:"enable_asg_syncing"`
-	ASGPollInterval               int                       `json:"asg_poll_interval" validate:"min=1"`
-	Datastore                     string                    `json:"cni_datastore_path" validate:"nonzero"`
-	PolicyServerURL               string                    `json:"policy_server_url" validate:"min=1"`
-	VNI                           int                       `json:"vni" validate:"nonzero"`
-	MetronAddress                 string                    `json:"metron_address" validate:"nonzero"`
-	ServerCACertFile              string                    `json:"ca_cert_file" validate:"nonzero"`
-	ClientCertFile                string                    `json:"client_cert_file" validate:"nonzero"`
-	ClientKeyFile                 string                    `json:"client_key_file" validate:"nonzero"`
-	ClientTimeoutSeconds          int                       `json:"client_timeout_seconds" validate:"nonzero"`
-	IPTablesLockFile              string                    `json:"iptables_lock_file" validate:"nonzero"`
-	DebugServerHost               string                    `json:"debug_server_host" validate:"nonzero"`
-	DebugServerPort               int                       `json:"debug_server_port" validate:"nonzero"`
-	LogLevel                      string                    `json:"log_level"`
-	LogPrefix                     string                    `json:"log_prefix" validate:"nonzero"`
-	IPTablesLogging               bool                      `json:"iptables_c2c_logging"`
-	IPTablesAcceptedUDPLogsPerSec int                       `json:"iptables_accepted_udp_logs_per_sec" validate:"min=1"`
-	EnableOverlayIngressRules     bool                      `json:"enable_overlay_ingress_rules"`
-	ForcePolicyPollCyclePort      int                       `json:"force_policy_poll_cycle_port" validate:"nonzero"`
-	ForcePolicyPollCycleHost      string                    `json:"force_policy_poll_cycle_host" validate:"nonzero"`
-	DisableContainerNetworkPolicy bool                      `json:"disable_container_network_policy"`
-	OverlayNetwork                string                    `json:"overlay_network"`
-	UnderlayIPs                   []string                  `json:"underlay_ips"`
-	IPTablesASGLogging            bool                      `json:"iptables_asg_logging"`
-	IPTablesDeniedLogsPerSec      int                       `json:"iptables_denied_logs_per_sec"`
-	DenyNetworks                  cnilib.DenyNetworksConfig `json:"deny_networks"`
-	OutConn                       cnilib.OutConnConfig      `json:"outbound_connections"`
-	LoggregatorConfig             loggingclient.Config      `json:"loggregator"`
+	PollInterval                  int                             `json:"poll_interval" validate:"nonzero"`
+	EnableASGSyncing              bool                            `json:"enable_asg_syncing"`
+	ASGPollInterval               int                             `json:"asg_poll_interval" validate:"min=1"`
+	ASGSyncingPauseFile           string                          `json:"asg_syncing_pause_file"`
+	ContainerEventsSocket         string                          `json:"container_events_socket"`
+	IPTablesRecordFile            string                          `json:"iptables_record_file"`
+	IntentLogFile                 string                          `json:"intent_log_file"`
+	PlanDumpDir                   string                          `json:"plan_dump_dir"`
+	PlanDumpsToKeep               int                             `json:"plan_dumps_to_keep" validate:"min=0"`
+	ASGSyncBatchSize              int                             `json:"asg_sync_batch_size" validate:"min=0"`
+	ASGCleanupRetryInterval       int                             `json:"asg_cleanup_retry_interval"`
+	RuntimeReconcileInterval      int                             `json:"runtime_reconcile_interval"`
+	ConsistencyCheckInterval      int                             `json:"consistency_check_interval"`
+	RepairInconsistencies         bool                            `json:"repair_inconsistencies"`
+	GardenNetwork                 string                          `json:"garden_network"`
+	GardenAddress                 string                          `json:"garden_address"`
+	Datastore                     string                          `json:"cni_datastore_path" validate:"nonzero"`
+	PolicyServerURL               string                          `json:"policy_server_url" validate:"min=1"`
+	VNI                           int                             `json:"vni" validate:"nonzero"`
+	MetronAddress                 string                          `json:"metron_address" validate:"nonzero"`
+	ServerCACertFile              string                          `json:"ca_cert_file" validate:"nonzero"`
+	ClientCertFile                string                          `json:"client_cert_file" validate:"nonzero"`
+	ClientKeyFile                 string                          `json:"client_key_file" validate:"nonzero"`
+	PolicyServerSPIFFEIDs         []string                        `json:"policy_server_spiffe_ids"`
+	ClientTimeoutSeconds          int                             `json:"client_timeout_seconds" validate:"nonzero"`
+	IPTablesLockFile              string                          `json:"iptables_lock_file" validate:"nonzero"`
+	EnforcementTimeout            int                             `json:"enforcement_timeout" validate:"min=0"`
+	IPTablesBackendChange         string                          `json:"iptables_backend_change"`
+	ContainerICMPEcho             string                          `json:"container_icmp_echo"`
+	HealthCheckSources            []string                        `json:"health_check_sources"`
+	DebugServerHost               string                          `json:"debug_server_host" validate:"nonzero"`
+	DebugServerPort               int                             `json:"debug_server_port" validate:"nonzero"`
+	EnableSelfMetrics             bool                            `json:"enable_self_metrics"`
+	ManagedChainNameVersion       int                             `json:"managed_chain_name_version" validate:"max=1"`
+	LogLevel                      string                          `json:"log_level"`
+	LogPrefix                     string                          `json:"log_prefix" validate:"nonzero"`
+	IPTablesLogging               bool                            `json:"iptables_c2c_logging"`
+	IPTablesAcceptedUDPLogsPerSec int                             `json:"iptables_accepted_udp_logs_per_sec" validate:"min=1"`
+	IPTablesSubChainMinRules      int                             `json:"iptables_sub_chain_min_rules" validate:"min=0"`
+	EnableOverlayIngressRules     bool                            `json:"enable_overlay_ingress_rules"`
+	EnableDestinations            bool                            `json:"enable_destinations"`
+	ForcePolicyPollCyclePort      int                             `json:"force_policy_poll_cycle_port" validate:"nonzero"`
+	ForcePolicyPollCycleHost      string                          `json:"force_policy_poll_cycle_host" validate:"nonzero"`
+	DisableContainerNetworkPolicy bool                            `json:"disable_container_network_policy"`
+	OverlayNetwork                string                          `json:"overlay_network"`
+	UnderlayIPs                   []string                        `json:"underlay_ips"`
+	IPTablesASGLogging            bool                            `json:"iptables_asg_logging"`
+	IPTablesDeniedLogsPerSec      int                             `json:"iptables_denied_logs_per_sec"`
+	IPTablesDeniedLogsPerDest     bool                            `json:"iptables_denied_logs_per_destination"`
+	IPTablesCellLogsPerSec        int                             `json:"iptables_cell_logs_per_sec" validate:"min=0,max=5000"`
+	IPTablesCellLogsBurst         int                             `json:"iptables_cell_logs_burst" validate:"min=0,max=5000"`
+	DenyNetworks                  cnilib.DenyNetworksConfig       `json:"deny_networks"`
+	RejectTCPWithReset            bool                            `json:"reject_tcp_with_reset"`
+	RelatedEstablished            cnilib.RelatedEstablishedConfig `json:"related_established"`
+	SNIInspection                 cnilib.SNIInspectionConfig      `json:"sni_inspection"`
+	OutConn                       cnilib.OutConnConfig            `json:"outbound_connections"`
+	LoggregatorConfig             loggingclient.Config            `json:"loggregator"`
+	EgressProxy                   cnilib.EgressProxyConfig        `json:"egress_proxy"`
+	GlobalChains                  []GlobalChainConfig             `json:"global_chains"`
+	SilkDaemonPort                int                             `json:"silk_daemon_port"`
+	PolicySources                 []PolicySourceConfig            `json:"policy_sources"`
+	QoSClasses                    []QoSClassConfig                `json:"qos_classes"`
+	Sharding                      ShardingConfig                  `json:"sharding"`
+	Chaos                         ChaosConfig                     `json:"chaos"`
+	FeatureFlags                  cnilib.FeatureFlagsConfig       `json:"feature_flags"`
 }
 
-func (c *VxlanPolicyAgent) Validate() error {
-	return validator.Validate(c)
+// ShardingConfig runs the agent as several workers, each enforcing the ASGs
+// of a share of the containers. The worker of shard 0 listens on the
+// configured ports; worker i listens with its debug server on
+// PortBase+2(i-1) and with its force server on the port after.
+type ShardingConfig struct {
+	Workers  int `json:"workers" validate:"min=0"`
+	PortBase int `json:"port_base"`
 }
 
-func New(configFilePath string) (*VxlanPolicyAgent, error) {
-	cfg := &VxlanPolicyAgent{}
-	if _, err := os.Stat(configFilePath); err != nil {
-		return cfg, fmt.Errorf("file does not exist: %s", err)
+// ChaosConfig enables the faults of game-day drills, which are read from
+// FaultsFile. It is not meant for production cells. VTEPPort is the UDP port
+// of the VXLAN traffic between the cells.
+type ChaosConfig struct {
+	FaultsFile string `json:"faults_file"`
+	VTEPPort   int    `json:"vtep_port"`
+}
+
+func (c ChaosConfig) Enabled() bool {
+	return c.FaultsFile != ""
+}
+
+// DebugServerPortOf is the port of the debug server of a worker.
+func (c *VxlanPolicyAgent) DebugServerPortOf(shard int) int {
+	if shard == 0 {
+		return c.DebugServerPort
+	}
+	return c.Sharding.PortBase + 2*(shard-1)
+}
+
+// ForcePolicyPollCyclePortOf is the port of the force server of a worker.
+func (c *VxlanPolicyAgent) ForcePolicyPollCyclePortOf(shard int) int {
+	if shard == 0 {
+		return c.ForcePolicyPollCyclePort
+	}
+	return c.Sharding.PortBase + 2*(shard-1) + 1
+}
+
+// What the agent does when the iptables backend changes between legacy and
+// nf_tables while it runs: alarm stops enforcing, adapt enforces all rules
+// again in the new backend.
+const (
+	IPTablesBackendChangeAlarm = "alarm"
+	IPTablesBackendChangeAdapt = "adapt"
+)
+
+// Whether containers answer pings from other containers on their overlay
+// IPs: deny leaves echo requests to the overlay chains of the containers,
+// which reject them without a policy, accept lets them through.
+const (
+	ContainerICMPEchoDeny   = "deny"
+	ContainerICMPEchoAccept = "accept"
+)
+
+type PolicySourceConfig struct {
+	URL string `json:"url"`
+}
+
+// QoSClassConfig is a network service level that policy sources can assign
+// to apps: their packets are marked with DSCP, and their containers may send
+// at most RateLimitKBPerSec kilobytes per second through the cell when it is
+// not 0.
+type QoSClassConfig struct {
+	Name              string `json:"name"`
+	DSCP              int    `json:"dscp"`
+	RateLimitKBPerSec int    `json:"rate_limit_kb_per_sec"`
+}
+
+type GlobalChainConfig struct {
+	Name        string                   `json:"name"`
+	Table       string                   `json:"table"`
+	ParentChain string                   `json:"parent_chain"`
+	Rules       []string                 `json:"rules"`
+	ExtraTables []GlobalChainTableConfig `json:"extra_tables"`
+}
+
+// GlobalChainTableConfig are the rules of a global chain in another table,
+// which are enforced along with the rules of the global chain.
+type GlobalChainTableConfig struct {
+	Table       string   `json:"table"`
+	ParentChain string   `json:"parent_chain"`
+	Rules       []string `json:"rules"`
+}
+
+func validateEgressProxy(e cnilib.EgressProxyConfig) error {
+	if len(e.SpaceGUIDs) == 0 {
+		return nil
 	}
 
-	configBytes, err := ioutil.ReadFile(configFilePath)
-	if err != nil {
-		return cfg, fmt.Errorf("reading config file: %s", err)
+	if len(e.Endpoints) == 0 {
+		return errors.New("egress proxy: space guids configured without endpoints")
 	}
 
-	err = json.Unmarshal(configBytes, &cfg)
-	if err != nil {
-		return cfg, fmt.Errorf("parsing config (%s): %s", configFilePath, err)
+	for _, endpoint := range e.Endpoints {
+		if endpoint.Protocol != "tcp" && endpoint.Protocol != "udp" {
+			return fmt.Errorf("egress proxy: invalid protocol %q for %s", endpoint.Protocol, endpoint.Destination)
+		}
+		if endpoint.Ports == "" {
+			return fmt.Errorf("egress proxy: missing ports for %s", endpoint.Destination)
+		}
 	}
 
-	if err := cfg.Validate(); err != nil {
-		return cfg, fmt.Errorf("invalid config: %s", err)
+	if _, err := netrules.NewRulesFromSecurityGroupRules(e.SecurityGroupRules()); err != nil {
+		return fmt.Errorf("egress proxy: %s", err)
 	}
 
-	return cfg, nil
+	return nil
+}
+
+// Global chain names become the chain prefix <name>--, so they are kept short
+// enough for the timestamp and free of the dashes used by the other managed
+// chains.
+var globalChainName = regexp.MustCompile(`^[a-z][a-z0-9]{0,9}$`)
+
+// reservedGlobalChainNames are the names of the global chains of the agent.
+var reservedGlobalChainNames = map[string]bool{
+	"vpa":                     true,
+	chaos.PreroutingChainName: true,
+	chaos.OutputChainName:     true,
+}
+
+// GlobalChainTables are the tables global chains can be configured in.
+var GlobalChainTables = []string{"filter", "nat", "mangle", "raw"}
+
+func isGlobalChainTable(table string) bool {
+	for _, t := range GlobalChainTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+func validateGlobalChains(globalChains []GlobalChainConfig) error {
+	names := map[string]bool{}
+	for _, g := range globalChains {
+		if !globalChainName.MatchString(g.Name) || reservedGlobalChainNames[g.Name] {
+			return fmt.Errorf("global chains: invalid name %q", g.Name)
+		}
+		if names[g.Name] {
+			return fmt.Errorf("global chains: duplicate name %q", g.Name)
+		}
+		names[g.Name] = true
+
+		if !isGlobalChainTable(g.Table) {
+			return fmt.Errorf("global chains: invalid table %q for %s", g.Table, g.Name)
+		}
+		if g.ParentChain == "" {
+			return fmt.Errorf("global chains: missing parent chain for %s", g.Name)
+		}
+
+		tables := map[string]bool{g.Table: true}
+		for _, t := range g.ExtraTables {
+			if !isGlobalChainTable(t.Table) || tables[t.Table] {
+				return fmt.Errorf("global chains: invalid extra table %q for %s", t.Table, g.Name)
+			}
+			tables[t.Table] = true
+			if t.ParentChain == "" {
+				return fmt.Errorf("global chains: missing parent chain in %s for %s", t.Table, g.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func validateHealthCheckSources(sources []string) error {
+	for _, source := range sources {
+		if _, _, err := net.ParseCIDR(source); err != nil {
+			return fmt.Errorf("health check sources: invalid cidr %q", source)
+		}
+	}
+	return nil
+}
+
+func validatePolicySources(policySources []PolicySourceConfig) error {
+	for _, source := range policySources {
+		u, err := url.Parse(source.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("policy sources: invalid url %q", source.URL)
+		}
+	}
+	return nil
+}
+
+func validateQoSClasses(qosClasses []QoSClassConfig) error {
+	names := map[string]bool{}
+	for _, class := range qosClasses {
+		if class.Name == "" {
+			return errors.New("qos classes: missing name")
+		}
+		if names[class.Name] {
+			return fmt.Errorf("qos classes: duplicate name %q", class.Name)
+		}
+		names[class.Name] = true
+
+		if class.DSCP < 0 || class.DSCP > 63 {
+			return fmt.Errorf("qos classes: invalid dscp %d for %s", class.DSCP, class.Name)
+		}
+		if class.RateLimitKBPerSec < 0 {
+			return fmt.Errorf("qos classes: invalid rate limit %d for %s", class.RateLimitKBPerSec, class.Name)
+		}
+	}
+	return nil
+}
+
+func (c *VxlanPolicyAgent) Validate() error {
+	if err := validator.Validate(c); err != nil {
+		return err
+	}
+	if c.RuntimeReconcileInterval > 0 && (c.GardenNetwork == "" || c.GardenAddress == "") {
+		return errors.New("runtime reconcile: missing garden network or address")
+	}
+	if c.IPTablesBackendChange != "" && c.IPTablesBackendChange != IPTablesBackendChangeAlarm && c.IPTablesBackendChange != IPTablesBackendChangeAdapt {
+		return fmt.Errorf("iptables backend change: invalid action %q", c.IPTablesBackendChange)
+	}
+	if c.ContainerICMPEcho != "" && c.ContainerICMPEcho != ContainerICMPEchoDeny && c.ContainerICMPEcho != ContainerICMPEchoAccept {
+		return fmt.Errorf("container icmp echo: invalid policy %q", c.ContainerICMPEcho)
+	}
+	if err := c.RelatedEstablished.Validate(); err != nil {
+		return err
+	}
+	if err := c.SNIInspection.Validate(); err != nil {
+		return err
+	}
+	// the containers of egress proxy spaces may only reach the proxies
+	for spaceGUID := range c.SNIInspection.SecurityGroupRules() {
+		if c.EgressProxy.IncludesSpace(spaceGUID) {
+			return fmt.Errorf("sni inspection: space %s has rules but is an egress proxy space", spaceGUID)
+		}
+	}
+	if err := validateGlobalChains(c.GlobalChains); err != nil {
+		return err
+	}
+	if err := validateHealthCheckSources(c.HealthCheckSources); err != nil {
+		return err
+	}
+	if err := validatePolicySources(c.PolicySources); err != nil {
+		return err
+	}
+	if err := validateQoSClasses(c.QoSClasses); err != nil {
+		return err
+	}
+	if c.Sharding.Workers > 1 && c.Sharding.PortBase == 0 {
+		return errors.New("sharding: missing port base")
+	}
+	if c.Chaos.Enabled() && (c.Chaos.VTEPPort < 1 || c.Chaos.VTEPPort > 65535) {
+		return fmt.Errorf("chaos: invalid vtep port %d", c.Chaos.VTEPPort)
+	}
+	return validateEgressProxy(c.EgressProxy)
+}
+
+func New(configFilePath string) (*VxlanPolicyAgent, error) {
+	cfg := &VxlanPolicyAgent{}
+	return cfg, libconfig.Load(configFilePath, cfg)
 }
@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"os"
 
+	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	"code.cloudfoundry.org/policy_client"
 	"code.cloudfoundry.org/vxlan-policy-agent/config"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -29,6 +31,19 @@ var _ = Describe("Config", func() {
 				file.WriteString(`{
 					"poll_interval": 1234,
 					"asg_poll_interval": 5678,
+					"asg_cleanup_retry_interval": 3,
+					"runtime_reconcile_interval": 30,
+					"consistency_check_interval": 300,
+					"repair_inconsistencies": true,
+					"garden_network": "unix",
+					"garden_address": "/some/garden.sock",
+					"asg_syncing_pause_file": "/some/pause/file",
+					"container_events_socket": "/some/events/vxlan-policy-agent.sock",
+					"iptables_record_file": "/some/record/file",
+					"intent_log_file": "/some/intent/log",
+					"plan_dump_dir": "/some/plan/dir",
+					"plan_dumps_to_keep": 10,
+					"asg_sync_batch_size": 50,
 					"cni_datastore_path": "/some/datastore/path",
 					"policy_server_url": "https://some-url:1234",
 					"vni": 42,
@@ -37,20 +52,32 @@ var _ = Describe("Config", func() {
 					"client_cert_file": "/some/client/cert/file",
 					"client_key_file": "/some/client/key/file",
 					"iptables_lock_file":  "/var/vcap/data/lock",
+					"enforcement_timeout": 120,
+					"iptables_backend_change": "adapt",
+					"container_icmp_echo": "accept",
+					"health_check_sources": ["169.254.0.5/32"],
 					"debug_server_host": "http://5.6.7.8",
 					"debug_server_port": 5678,
+					"enable_self_metrics": true,
+					"managed_chain_name_version": 1,
 					"log_level": "debug",
 					"log_prefix": "cfnetworking",
 					"iptables_c2c_logging": true,
 					"client_timeout_seconds":5,
 					"iptables_accepted_udp_logs_per_sec":4,
+					"iptables_sub_chain_min_rules":500,
 					"enable_overlay_ingress_rules": true,
+					"enable_destinations": true,
 					"force_policy_poll_cycle_port": 6789,
 					"force_policy_poll_cycle_host": "http://6.7.8.9",
 					"disable_container_network_policy": false,
 					"underlay_ips": ["123.1.2.3"],
 					"iptables_asg_logging": true,
 					"iptables_denied_logs_per_sec": 2,
+					"iptables_denied_logs_per_destination": true,
+					"iptables_cell_logs_per_sec": 100,
+					"iptables_cell_logs_burst": 200,
+					"reject_tcp_with_reset": true,
 					"deny_networks": {
 						"always": ["10.0.0.0/24"],
 						"running": ["10.0.1.0/24"],
@@ -61,12 +88,44 @@ var _ = Describe("Config", func() {
 						"logging": true,
 						"burst": 900,
 						"rate_per_sec": 100
-					}
+					},
+					"egress_proxy": {
+						"space_guids": ["some-space-guid"],
+						"endpoints": [{"destination": "10.0.5.5", "protocol": "tcp", "ports": "3128"}]
+					},
+					"global_chains": [{
+						"name": "metadata",
+						"table": "filter",
+						"parent_chain": "FORWARD",
+						"rules": ["-s {{.ContainerNetwork}} -d 169.254.169.254/32 -j REJECT"],
+						"extra_tables": [{
+							"table": "mangle",
+							"parent_chain": "POSTROUTING",
+							"rules": ["-d 169.254.169.254/32 -j DSCP --set-dscp 10"]
+						}]
+					}],
+					"silk_daemon_port": 23954,
+					"policy_sources": [{"url": "http://127.0.0.1:8181/v1/data/cf/egress"}],
+					"qos_classes": [{"name": "gold", "dscp": 34, "rate_limit_kb_per_sec": 1024}],
+					"chaos": {"faults_file": "/some/faults.json", "vtep_port": 4789}
 				}`)
 				c, err := config.New(file.Name())
 				Expect(err).NotTo(HaveOccurred())
 				Expect(c.PollInterval).To(Equal(1234))
 				Expect(c.ASGPollInterval).To(Equal(5678))
+				Expect(c.ASGSyncingPauseFile).To(Equal("/some/pause/file"))
+				Expect(c.ContainerEventsSocket).To(Equal("/some/events/vxlan-policy-agent.sock"))
+				Expect(c.IPTablesRecordFile).To(Equal("/some/record/file"))
+				Expect(c.IntentLogFile).To(Equal("/some/intent/log"))
+				Expect(c.PlanDumpDir).To(Equal("/some/plan/dir"))
+				Expect(c.PlanDumpsToKeep).To(Equal(10))
+				Expect(c.ASGSyncBatchSize).To(Equal(50))
+				Expect(c.ASGCleanupRetryInterval).To(Equal(3))
+				Expect(c.RuntimeReconcileInterval).To(Equal(30))
+				Expect(c.ConsistencyCheckInterval).To(Equal(300))
+				Expect(c.RepairInconsistencies).To(BeTrue())
+				Expect(c.GardenNetwork).To(Equal("unix"))
+				Expect(c.GardenAddress).To(Equal("/some/garden.sock"))
 				Expect(c.Datastore).To(Equal("/some/datastore/path"))
 				Expect(c.PolicyServerURL).To(Equal("https://some-url:1234"))
 				Expect(c.VNI).To(Equal(42))
@@ -75,20 +134,32 @@ var _ = Describe("Config", func() {
 				Expect(c.ClientCertFile).To(Equal("/some/client/cert/file"))
 				Expect(c.ClientKeyFile).To(Equal("/some/client/key/file"))
 				Expect(c.IPTablesLockFile).To(Equal("/var/vcap/data/lock"))
+				Expect(c.EnforcementTimeout).To(Equal(120))
+				Expect(c.IPTablesBackendChange).To(Equal("adapt"))
+				Expect(c.ContainerICMPEcho).To(Equal("accept"))
+				Expect(c.HealthCheckSources).To(Equal([]string{"169.254.0.5/32"}))
 				Expect(c.DebugServerHost).To(Equal("http://5.6.7.8"))
 				Expect(c.DebugServerPort).To(Equal(5678))
+				Expect(c.EnableSelfMetrics).To(BeTrue())
+				Expect(c.ManagedChainNameVersion).To(Equal(1))
 				Expect(c.LogLevel).To(Equal("debug"))
 				Expect(c.LogPrefix).To(Equal("cfnetworking"))
 				Expect(c.IPTablesLogging).To(Equal(true))
 				Expect(c.ClientTimeoutSeconds).To(Equal(5))
 				Expect(c.IPTablesAcceptedUDPLogsPerSec).To(Equal(4))
+				Expect(c.IPTablesSubChainMinRules).To(Equal(500))
 				Expect(c.EnableOverlayIngressRules).To(Equal(true))
+				Expect(c.EnableDestinations).To(BeTrue())
 				Expect(c.ForcePolicyPollCyclePort).To(Equal(6789))
 				Expect(c.ForcePolicyPollCycleHost).To(Equal("http://6.7.8.9"))
 				Expect(c.DisableContainerNetworkPolicy).To(BeFalse())
 				Expect(c.UnderlayIPs).To(Equal([]string{"123.1.2.3"}))
 				Expect(c.IPTablesASGLogging).To(BeTrue())
 				Expect(c.IPTablesDeniedLogsPerSec).To(Equal(2))
+				Expect(c.IPTablesCellLogsPerSec).To(Equal(100))
+				Expect(c.IPTablesCellLogsBurst).To(Equal(200))
+				Expect(c.RejectTCPWithReset).To(BeTrue())
+				Expect(c.IPTablesDeniedLogsPerDest).To(BeTrue())
 				Expect(c.DenyNetworks.Always).To(Equal([]string{"10.0.0.0/24"}))
 				Expect(c.DenyNetworks.Running).To(Equal([]string{"10.0.1.0/24"}))
 				Expect(c.DenyNetworks.Staging).To(Equal([]string{"10.0.2.0/24"}))
@@ -96,6 +167,29 @@ var _ = Describe("Config", func() {
 				Expect(c.OutConn.Logging).To(BeTrue())
 				Expect(c.OutConn.Burst).To(Equal(900))
 				Expect(c.OutConn.RatePerSec).To(Equal(100))
+				Expect(c.EgressProxy.SpaceGUIDs).To(Equal([]string{"some-space-guid"}))
+				Expect(c.EgressProxy.Endpoints).To(Equal([]cnilib.EgressProxyEndpoint{
+					{Destination: "10.0.5.5", Protocol: "tcp", Ports: "3128"},
+				}))
+				Expect(c.EgressProxy.SecurityGroupRules()).To(Equal([]policy_client.SecurityGroupRule{
+					{Destination: "10.0.5.5", Protocol: "tcp", Ports: "3128"},
+				}))
+				Expect(c.GlobalChains).To(Equal([]config.GlobalChainConfig{{
+					Name:        "metadata",
+					Table:       "filter",
+					ParentChain: "FORWARD",
+					Rules:       []string{"-s {{.ContainerNetwork}} -d 169.254.169.254/32 -j REJECT"},
+					ExtraTables: []config.GlobalChainTableConfig{{
+						Table:       "mangle",
+						ParentChain: "POSTROUTING",
+						Rules:       []string{"-d 169.254.169.254/32 -j DSCP --set-dscp 10"},
+					}},
+				}}))
+				Expect(c.SilkDaemonPort).To(Equal(23954))
+				Expect(c.PolicySources).To(Equal([]config.PolicySourceConfig{{URL: "http://127.0.0.1:8181/v1/data/cf/egress"}}))
+				Expect(c.QoSClasses).To(Equal([]config.QoSClassConfig{{Name: "gold", DSCP: 34, RateLimitKBPerSec: 1024}}))
+				Expect(c.Chaos).To(Equal(config.ChaosConfig{FaultsFile: "/some/faults.json", VTEPPort: 4789}))
+				Expect(c.Chaos.Enabled()).To(BeTrue())
 			})
 		})
 
@@ -121,6 +215,14 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when config file has a key the config does not have", func() {
+			It("returns the error", func() {
+				file.WriteString(`{"poll_intreval": 5}`)
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(ContainSubstring(`parsing config (%s): json: unknown field "poll_intreval"`, file.Name())))
+			})
+		})
+
 		DescribeTable("when config file is missing a member",
 			func(missingFlag, errorMsg string) {
 				allData := map[string]interface{}{
@@ -179,5 +281,606 @@ var _ = Describe("Config", func() {
 			Entry("missing force policy poll cycle host", "force_policy_poll_cycle_host", "ForcePolicyPollCycleHost: zero value"),
 			Entry("missing force policy poll cycle port", "force_policy_poll_cycle_port", "ForcePolicyPollCyclePort: zero value"),
 		)
+
+		Context("when the managed chain name version is unknown", func() {
+			It("returns the error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"managed_chain_name_version":         2,
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: ManagedChainNameVersion: greater than max"))
+			})
+		})
+
+		Context("when the cell logs per sec are above the hashlimit maximum", func() {
+			It("returns the error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"iptables_cell_logs_per_sec":         5001,
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: IPTablesCellLogsPerSec: greater than max"))
+			})
+		})
+
+		DescribeTable("when the egress proxy config is invalid",
+			func(egressProxy map[string]interface{}, errorMsg string) {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"egress_proxy": egressProxy,
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorMsg)))
+			},
+			Entry("spaces without endpoints", map[string]interface{}{
+				"space_guids": []string{"some-space-guid"},
+			}, "egress proxy: space guids configured without endpoints"),
+			Entry("invalid protocol", map[string]interface{}{
+				"space_guids": []string{"some-space-guid"},
+				"endpoints":   []map[string]string{{"destination": "10.0.5.5", "protocol": "icmp", "ports": "3128"}},
+			}, `egress proxy: invalid protocol "icmp" for 10.0.5.5`),
+			Entry("missing ports", map[string]interface{}{
+				"space_guids": []string{"some-space-guid"},
+				"endpoints":   []map[string]string{{"destination": "10.0.5.5", "protocol": "tcp"}},
+			}, "egress proxy: missing ports for 10.0.5.5"),
+			Entry("invalid destination", map[string]interface{}{
+				"space_guids": []string{"some-space-guid"},
+				"endpoints":   []map[string]string{{"destination": "banana", "protocol": "tcp", "ports": "3128"}},
+			}, "egress proxy: failed to convert destination to ip range"),
+		)
+
+		Context("when the runtime is reconciled without a garden address", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"runtime_reconcile_interval": 30,
+					"garden_network":             "unix",
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: runtime reconcile: missing garden network or address"))
+			})
+		})
+
+		Context("when the iptables backend change action is invalid", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"iptables_backend_change": "ignore",
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(`invalid config: iptables backend change: invalid action "ignore"`))
+			})
+		})
+
+		Context("when the container icmp echo policy is invalid", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"container_icmp_echo": "drop",
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(`invalid config: container icmp echo: invalid policy "drop"`))
+			})
+		})
+
+		Context("when the related established scope is invalid", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"related_established": map[string]interface{}{
+						"scope": "interface",
+					},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: related established: missing interfaces"))
+			})
+		})
+
+		Context("when an sni allowlist is invalid", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"sni_inspection": map[string]interface{}{
+						"allowlists": []map[string]interface{}{{
+							"queue_num":   100,
+							"space_guids": []string{"some-space"},
+						}},
+					},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: sni inspection: queue 100: missing server names"))
+			})
+		})
+
+		Context("when an sni allowlist has rules for an egress proxy space", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"sni_inspection": map[string]interface{}{
+						"allowlists": []map[string]interface{}{{
+							"queue_num":   100,
+							"space_guids": []string{"some-space"},
+							"rules": []map[string]interface{}{{
+								"destination":  "10.0.0.0/24",
+								"server_names": []string{"api.example.com"},
+							}},
+						}},
+					},
+					"egress_proxy": map[string]interface{}{
+						"space_guids": []string{"some-space"},
+						"endpoints":   []map[string]string{{"destination": "10.0.5.5", "protocol": "tcp", "ports": "3128"}},
+					},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: sni inspection: space some-space has rules but is an egress proxy space"))
+			})
+		})
+
+		Context("when a health check source is not a cidr", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"health_check_sources": []string{"169.254.0.5"},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(`invalid config: health check sources: invalid cidr "169.254.0.5"`))
+			})
+		})
+
+		DescribeTable("when the global chains config is invalid",
+			func(globalChains []map[string]interface{}, errorMsg string) {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"global_chains": globalChains,
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(fmt.Sprintf("invalid config: %s", errorMsg)))
+			},
+			Entry("name with dashes", []map[string]interface{}{
+				{"name": "site-rules", "table": "filter", "parent_chain": "FORWARD"},
+			}, `global chains: invalid name "site-rules"`),
+			Entry("name too long", []map[string]interface{}{
+				{"name": "averylongname", "table": "filter", "parent_chain": "FORWARD"},
+			}, `global chains: invalid name "averylongname"`),
+			Entry("name of the policy chain", []map[string]interface{}{
+				{"name": "vpa", "table": "filter", "parent_chain": "FORWARD"},
+			}, `global chains: invalid name "vpa"`),
+			Entry("name of a chaos chain", []map[string]interface{}{
+				{"name": "chaosout", "table": "raw", "parent_chain": "OUTPUT"},
+			}, `global chains: invalid name "chaosout"`),
+			Entry("duplicate name", []map[string]interface{}{
+				{"name": "site", "table": "filter", "parent_chain": "FORWARD"},
+				{"name": "site", "table": "nat", "parent_chain": "PREROUTING"},
+			}, `global chains: duplicate name "site"`),
+			Entry("invalid table", []map[string]interface{}{
+				{"name": "site", "table": "banana", "parent_chain": "FORWARD"},
+			}, `global chains: invalid table "banana" for site`),
+			Entry("missing parent chain", []map[string]interface{}{
+				{"name": "site", "table": "filter"},
+			}, "global chains: missing parent chain for site"),
+			Entry("invalid extra table", []map[string]interface{}{
+				{"name": "site", "table": "filter", "parent_chain": "FORWARD", "extra_tables": []map[string]interface{}{
+					{"table": "banana", "parent_chain": "POSTROUTING"},
+				}},
+			}, `global chains: invalid extra table "banana" for site`),
+			Entry("extra table of the chain itself", []map[string]interface{}{
+				{"name": "site", "table": "filter", "parent_chain": "FORWARD", "extra_tables": []map[string]interface{}{
+					{"table": "filter", "parent_chain": "INPUT"},
+				}},
+			}, `global chains: invalid extra table "filter" for site`),
+			Entry("missing parent chain in extra table", []map[string]interface{}{
+				{"name": "site", "table": "filter", "parent_chain": "FORWARD", "extra_tables": []map[string]interface{}{
+					{"table": "mangle"},
+				}},
+			}, "global chains: missing parent chain in mangle for site"),
+		)
+
+		DescribeTable("when the policy sources config is invalid",
+			func(sourceURL string) {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"policy_sources": []map[string]string{{"url": sourceURL}},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(fmt.Sprintf("invalid config: policy sources: invalid url %q", sourceURL)))
+			},
+			Entry("missing url", ""),
+			Entry("unsupported scheme", "ftp://127.0.0.1/rules"),
+			Entry("missing host", "http:///v1/data"),
+			Entry("unparsable url", "http://[::1"),
+		)
+
+		DescribeTable("when the qos classes config is invalid",
+			func(qosClasses []map[string]interface{}, expectedErr string) {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"qos_classes": qosClasses,
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: " + expectedErr))
+			},
+			Entry("missing name", []map[string]interface{}{
+				{"dscp": 10},
+			}, "qos classes: missing name"),
+			Entry("duplicate name", []map[string]interface{}{
+				{"name": "gold", "dscp": 34},
+				{"name": "gold", "dscp": 10},
+			}, `qos classes: duplicate name "gold"`),
+			Entry("dscp out of range", []map[string]interface{}{
+				{"name": "gold", "dscp": 64},
+			}, "qos classes: invalid dscp 64 for gold"),
+			Entry("negative rate limit", []map[string]interface{}{
+				{"name": "bronze", "rate_limit_kb_per_sec": -1},
+			}, "qos classes: invalid rate limit -1 for bronze"),
+		)
+
+		Context("when the agent is sharded without a port base", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"sharding": map[string]interface{}{"workers": 3},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: sharding: missing port base"))
+			})
+		})
+
+		Context("when chaos is enabled without a vtep port", func() {
+			It("returns an error", func() {
+				allData := map[string]interface{}{
+					"poll_interval":                      1234,
+					"asg_poll_interval":                  5678,
+					"cni_datastore_path":                 "/some/datastore/path",
+					"policy_server_url":                  "https://some-url:1234",
+					"vni":                                42,
+					"metron_address":                     "http://1.2.3.4:1234",
+					"ca_cert_file":                       "/some/ca/file",
+					"client_cert_file":                   "/some/client/cert/file",
+					"client_key_file":                    "/some/client/key/file",
+					"iptables_lock_file":                 "/var/vcap/data/lock",
+					"debug_server_host":                  "http://5.6.7.8",
+					"debug_server_port":                  5678,
+					"log_prefix":                         "cfnetworking",
+					"client_timeout_seconds":             5,
+					"iptables_accepted_udp_logs_per_sec": 4,
+					"force_policy_poll_cycle_port":       6789,
+					"force_policy_poll_cycle_host":       "http://6.7.8.9",
+					"outbound_connections": map[string]interface{}{
+						"burst":        900,
+						"rate_per_sec": 100,
+					},
+					"chaos": map[string]interface{}{"faults_file": "/some/faults.json"},
+				}
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError("invalid config: chaos: invalid vtep port 0"))
+			})
+		})
+	})
+
+	Describe("ports of the shards", func() {
+		It("uses the configured ports for shard 0 and the port base for the others", func() {
+			c := config.VxlanPolicyAgent{
+				DebugServerPort:          22222,
+				ForcePolicyPollCyclePort: 8081,
+				Sharding:                 config.ShardingConfig{Workers: 3, PortBase: 9100},
+			}
+			Expect(c.DebugServerPortOf(0)).To(Equal(22222))
+			Expect(c.ForcePolicyPollCyclePortOf(0)).To(Equal(8081))
+			Expect(c.DebugServerPortOf(1)).To(Equal(9100))
+			Expect(c.ForcePolicyPollCyclePortOf(1)).To(Equal(9101))
+			Expect(c.DebugServerPortOf(2)).To(Equal(9102))
+			Expect(c.ForcePolicyPollCyclePortOf(2)).To(Equal(9103))
+		})
 	})
 })
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"errors"
+
+	cnilib "code.cloudfoundry.org/cni-wrapper-plugin/lib"
+	libconfig "code.cloudfoundry.org/lib/config"
+	validator "gopkg.in/validator.v2"
+)
+
+// SNIVerdict is the config of the SNI verdict daemon, which decides on the
+// TLS connections that the netout chains queue to it by their server names.
+type SNIVerdict struct {
+	LogLevel           string                     `json:"log_level"`
+	LogPrefix          string                     `json:"log_prefix" validate:"nonzero"`
+	FlowTimeoutSeconds int                        `json:"flow_timeout_seconds" validate:"min=0"`
+	SNIInspection      cnilib.SNIInspectionConfig `json:"sni_inspection"`
+}
+
+func (c *SNIVerdict) Validate() error {
+	if err := validator.Validate(c); err != nil {
+		return err
+	}
+	if len(c.SNIInspection.Allowlists) == 0 {
+		return errors.New("sni inspection: missing allowlists")
+	}
+	return c.SNIInspection.Validate()
+}
+
+func NewSNIVerdict(configFilePath string) (*SNIVerdict, error) {
+	cfg := &SNIVerdict{}
+	return cfg, libconfig.Load(configFilePath, cfg)
+}
@@ -0,0 +1,73 @@
+package config_test
+
+import (
+	"encoding/json"
+	"os"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SNIVerdict", func() {
+	var (
+		file    *os.File
+		allData map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		file, err = os.CreateTemp(os.TempDir(), "config-")
+		Expect(err).NotTo(HaveOccurred())
+		allData = map[string]interface{}{
+			"log_prefix":           "cfnetworking",
+			"flow_timeout_seconds": 300,
+			"sni_inspection": map[string]interface{}{
+				"fail_open": true,
+				"allowlists": []map[string]interface{}{{
+					"queue_num":    100,
+					"space_guids":  []string{"some-space"},
+					"server_names": []string{"api.example.com", "*.example.org"},
+				}},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.Remove(file.Name())
+	})
+
+	It("loads the config", func() {
+		Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+		c, err := config.NewSNIVerdict(file.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.FlowTimeoutSeconds).To(Equal(300))
+		Expect(c.SNIInspection.FailOpen).To(BeTrue())
+		Expect(c.SNIInspection.Allowlists).To(HaveLen(1))
+		Expect(c.SNIInspection.Allowlists[0].QueueNum).To(Equal(100))
+		Expect(c.SNIInspection.Allowlists[0].ServerNames).To(Equal([]string{"api.example.com", "*.example.org"}))
+	})
+
+	It("fails without allowlists", func() {
+		allData["sni_inspection"] = map[string]interface{}{"fail_open": true}
+		Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+		_, err := config.NewSNIVerdict(file.Name())
+		Expect(err).To(MatchError("invalid config: sni inspection: missing allowlists"))
+	})
+
+	It("fails on an invalid allowlist", func() {
+		allData["sni_inspection"] = map[string]interface{}{
+			"allowlists": []map[string]interface{}{{
+				"queue_num":    100,
+				"space_guids":  []string{"some-space"},
+				"server_names": []string{"api.example.com:443"},
+			}},
+		}
+		Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+		_, err := config.NewSNIVerdict(file.Name())
+		Expect(err).To(MatchError(`invalid config: sni inspection: queue 100: invalid server name "api.example.com:443"`))
+	})
+})
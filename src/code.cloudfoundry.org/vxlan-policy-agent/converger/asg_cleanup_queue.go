@@ -0,0 +1,87 @@
+package converger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"github.com/hashicorp/go-multierror"
+)
+
+// ASGCleanupQueue removes the ASG chains of containers that the CNI wrapper
+// plugin deleted. The chains are removed while the plugin waits, and a
+// container whose cleanup fails, e.g. because iptables is locked, is queued
+// and retried on every Retry instead of being left to the next ASG cycle.
+type ASGCleanupQueue struct {
+	ASGCleanupFunc func(string) ([]enforcer.LiveChain, error)
+	Logger         lager.Logger
+
+	mutex   sync.Mutex
+	pending map[string]struct{}
+}
+
+// Cleanup removes the ASG chains of a container, or queues the container when
+// that fails. It does not return the error, so that the plugin does not fail
+// the deletion of a container for a temporary one.
+func (q *ASGCleanupQueue) Cleanup(containerHandle string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if err := q.cleanup(containerHandle); err != nil {
+		q.Logger.Error("queued-asg-cleanup", err, lager.Data{"container": containerHandle})
+		if q.pending == nil {
+			q.pending = map[string]struct{}{}
+		}
+		q.pending[containerHandle] = struct{}{}
+	}
+	return nil
+}
+
+// Retry cleans up the queued containers again and keeps those that still
+// fail.
+func (q *ASGCleanupQueue) Retry() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	handles := []string{}
+	for handle := range q.pending {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+
+	var errors error
+	for _, handle := range handles {
+		if err := q.cleanup(handle); err != nil {
+			errors = multierror.Append(errors, err)
+			continue
+		}
+		delete(q.pending, handle)
+	}
+	return errors
+}
+
+// Pending returns the queued containers.
+func (q *ASGCleanupQueue) Pending() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	handles := []string{}
+	for handle := range q.pending {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+	return handles
+}
+
+func (q *ASGCleanupQueue) cleanup(containerHandle string) error {
+	deletedChains, err := q.ASGCleanupFunc(containerHandle)
+	if err != nil {
+		return fmt.Errorf("cleanup asg chains for %s: %s", containerHandle, err)
+	}
+	if len(deletedChains) > 0 {
+		q.Logger.Info("removed-container-asg-chains", lager.Data{"container": containerHandle, "chains": deletedChains})
+	}
+	return nil
+}
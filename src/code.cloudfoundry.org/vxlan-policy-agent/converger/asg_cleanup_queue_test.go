@@ -0,0 +1,72 @@
+package converger_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ASGCleanupQueue", func() {
+	var (
+		queue             *converger.ASGCleanupQueue
+		logger            *lagertest.TestLogger
+		cleanedContainers []string
+		cleanupErr        error
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		cleanedContainers = nil
+		cleanupErr = nil
+
+		queue = &converger.ASGCleanupQueue{
+			ASGCleanupFunc: func(containerHandle string) ([]enforcer.LiveChain, error) {
+				cleanedContainers = append(cleanedContainers, containerHandle)
+				if cleanupErr != nil {
+					return nil, cleanupErr
+				}
+				return []enforcer.LiveChain{{Table: "filter", Name: "asg-a1b2c3v1-g7cs183k3a"}}, nil
+			},
+			Logger: logger,
+		}
+	})
+
+	It("cleans up the ASG chains of the container right away", func() {
+		Expect(queue.Cleanup("container-a")).To(Succeed())
+		Expect(cleanedContainers).To(Equal([]string{"container-a"}))
+		Expect(logger).To(gbytes.Say("removed-container-asg-chains.*asg-a1b2c3v1-g7cs183k3a.*container-a"))
+		Expect(queue.Pending()).To(BeEmpty())
+
+		Expect(queue.Retry()).To(Succeed())
+		Expect(cleanedContainers).To(HaveLen(1))
+	})
+
+	Context("when cleaning up the chains fails", func() {
+		BeforeEach(func() {
+			cleanupErr = errors.New("banana")
+		})
+
+		It("queues the container and retries it until it succeeds", func() {
+			Expect(queue.Cleanup("container-b")).To(Succeed())
+			Expect(queue.Cleanup("container-a")).To(Succeed())
+			Expect(logger).To(gbytes.Say("queued-asg-cleanup.*banana"))
+			Expect(queue.Pending()).To(Equal([]string{"container-a", "container-b"}))
+
+			err := queue.Retry()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cleanup asg chains for container-a: banana"))
+			Expect(queue.Pending()).To(HaveLen(2))
+
+			cleanupErr = nil
+			Expect(queue.Retry()).To(Succeed())
+			Expect(cleanedContainers).To(Equal([]string{"container-b", "container-a", "container-a", "container-b", "container-a", "container-b"}))
+			Expect(queue.Pending()).To(BeEmpty())
+		})
+	})
+})
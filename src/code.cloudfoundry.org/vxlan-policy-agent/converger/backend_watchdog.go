@@ -0,0 +1,88 @@
+package converger
+
+import (
+	"fmt"
+	"sync"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+)
+
+const metricIPTablesBackendMismatches = "iptablesBackendMismatches"
+
+//go:generate counterfeiter -o fakes/backend_detector.go --fake-name BackendDetector . backendDetector
+type backendDetector interface {
+	Detect() (rules.Backend, error)
+}
+
+// BackendChangedError is returned by the cycles when the iptables binary
+// writes to another backend than the one the rules were enforced in.
+type BackendChangedError struct {
+	Expected rules.Backend
+	Found    rules.Backend
+}
+
+func (e *BackendChangedError) Error() string {
+	return fmt.Sprintf("iptables backend changed from %s to %s", e.Expected, e.Found)
+}
+
+// BackendWatchdog checks before every cycle that iptables still writes to the
+// backend the rules were enforced in. When the iptables alternative flips
+// between legacy and nf_tables, e.g. after the OS was patched, the rules
+// enforced before are in tables that iptables no longer lists, so the cycles
+// would enforce the other half of the rules next to nothing. Without Adapt,
+// the cycles fail until the backend is switched back. With Adapt, AdaptFunc
+// enforces all rules again in the new backend, which the watchdog then
+// follows.
+type BackendWatchdog struct {
+	Detector      backendDetector
+	Backend       rules.Backend
+	Adapt         bool
+	AdaptFunc     func() error
+	MetricsSender metricsSender
+	Logger        lager.Logger
+
+	mutex sync.Mutex
+}
+
+// Guard returns the cycle checked by the watchdog.
+func (w *BackendWatchdog) Guard(cycle func() error) func() error {
+	return func() error {
+		err := w.Check()
+		if err != nil {
+			return err
+		}
+		return cycle()
+	}
+}
+
+func (w *BackendWatchdog) Check() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	backend, err := w.Detector.Detect()
+	if err != nil {
+		// the enforcer fails on its own when iptables is broken
+		w.Logger.Error("detect-iptables-backend", err)
+		return nil
+	}
+	if backend == w.Backend {
+		return nil
+	}
+
+	changed := &BackendChangedError{Expected: w.Backend, Found: backend}
+	w.MetricsSender.IncrementCounter(metricIPTablesBackendMismatches)
+	if !w.Adapt {
+		w.Logger.Error("iptables-backend-changed", changed, lager.Data{"expected": w.Backend, "found": backend, "action": "stop-enforcing"})
+		return changed
+	}
+
+	w.Logger.Error("iptables-backend-changed", changed, lager.Data{"expected": w.Backend, "found": backend, "action": "adapt"})
+	err = w.AdaptFunc()
+	if err != nil {
+		return fmt.Errorf("adapt to iptables backend %s: %s", backend, err)
+	}
+	w.Backend = backend
+	w.Logger.Info("adapted-to-iptables-backend", lager.Data{"backend": backend})
+	return nil
+}
@@ -0,0 +1,129 @@
+package converger_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("BackendWatchdog", func() {
+	var (
+		watchdog      *converger.BackendWatchdog
+		detector      *fakes.BackendDetector
+		metricsSender *fakes.MetricsSender
+		logger        *lagertest.TestLogger
+		adaptCalls    int
+		adaptErr      error
+		cycleCalls    int
+		cycle         func() error
+	)
+
+	BeforeEach(func() {
+		detector = &fakes.BackendDetector{}
+		detector.DetectReturns(rules.BackendLegacy, nil)
+		metricsSender = &fakes.MetricsSender{}
+		logger = lagertest.NewTestLogger("test")
+		adaptCalls, cycleCalls = 0, 0
+		adaptErr = nil
+
+		watchdog = &converger.BackendWatchdog{
+			Detector: detector,
+			Backend:  rules.BackendLegacy,
+			AdaptFunc: func() error {
+				adaptCalls++
+				return adaptErr
+			},
+			MetricsSender: metricsSender,
+			Logger:        logger,
+		}
+		cycle = watchdog.Guard(func() error {
+			cycleCalls++
+			return nil
+		})
+	})
+
+	It("runs the cycle while the backend is unchanged", func() {
+		Expect(cycle()).To(Succeed())
+		Expect(cycleCalls).To(Equal(1))
+		Expect(metricsSender.IncrementCounterCallCount()).To(Equal(0))
+	})
+
+	Context("when the backend changed", func() {
+		BeforeEach(func() {
+			detector.DetectReturns(rules.BackendNFTables, nil)
+		})
+
+		It("fails the cycle with a structured error and alarms", func() {
+			err := cycle()
+			Expect(err).To(MatchError("iptables backend changed from legacy to nf_tables"))
+			var changed *converger.BackendChangedError
+			Expect(errors.As(err, &changed)).To(BeTrue())
+			Expect(changed.Found).To(Equal(rules.BackendNFTables))
+
+			Expect(cycleCalls).To(Equal(0))
+			Expect(adaptCalls).To(Equal(0))
+			Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("iptablesBackendMismatches"))
+			Expect(logger).To(gbytes.Say(`iptables-backend-changed.*"action":"stop-enforcing","error":"iptables backend changed from legacy to nf_tables","expected":"legacy","found":"nf_tables"`))
+		})
+
+		It("runs the cycles again once the backend is switched back", func() {
+			Expect(cycle()).NotTo(Succeed())
+
+			detector.DetectReturns(rules.BackendLegacy, nil)
+			Expect(cycle()).To(Succeed())
+			Expect(cycleCalls).To(Equal(1))
+		})
+
+		Context("when the watchdog adapts", func() {
+			BeforeEach(func() {
+				watchdog.Adapt = true
+			})
+
+			It("enforces the rules again and follows the new backend", func() {
+				Expect(cycle()).To(Succeed())
+				Expect(adaptCalls).To(Equal(1))
+				Expect(cycleCalls).To(Equal(1))
+				Expect(logger).To(gbytes.Say(`iptables-backend-changed.*"action":"adapt"`))
+				Expect(logger).To(gbytes.Say("adapted-to-iptables-backend"))
+
+				Expect(cycle()).To(Succeed())
+				Expect(adaptCalls).To(Equal(1))
+				Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+			})
+
+			Context("when enforcing the rules again fails", func() {
+				BeforeEach(func() {
+					adaptErr = errors.New("banana")
+				})
+
+				It("fails the cycle and adapts again in the next one", func() {
+					Expect(cycle()).To(MatchError("adapt to iptables backend nf_tables: banana"))
+					Expect(cycleCalls).To(Equal(0))
+
+					adaptErr = nil
+					Expect(cycle()).To(Succeed())
+					Expect(adaptCalls).To(Equal(2))
+				})
+			})
+		})
+	})
+
+	Context("when the backend cannot be detected", func() {
+		BeforeEach(func() {
+			detector.DetectReturns("", errors.New("no iptables"))
+		})
+
+		It("logs the error and runs the cycle", func() {
+			Expect(cycle()).To(Succeed())
+			Expect(cycleCalls).To(Equal(1))
+			Expect(logger).To(gbytes.Say("detect-iptables-backend.*no iptables"))
+		})
+	})
+})
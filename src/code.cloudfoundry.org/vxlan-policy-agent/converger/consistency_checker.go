@@ -0,0 +1,367 @@
+package converger
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+	"github.com/hashicorp/go-multierror"
+)
+
+//go:generate counterfeiter -o fakes/chain_lister.go --fake-name ChainLister . chainLister
+type chainLister interface {
+	ListChains(table string) ([]string, error)
+	List(table, chain string) ([]string, error)
+}
+
+//go:generate counterfeiter -o fakes/gauge_sender.go --fake-name GaugeSender . gaugeSender
+type gaugeSender interface {
+	SendValue(name string, value float64, unit string)
+}
+
+const (
+	metricChainsWithoutContainer = "consistencyChainsWithoutContainer"
+	metricContainersWithoutChain = "consistencyContainersWithoutChain"
+	metricWrongLogConfig         = "consistencyWrongLogConfig"
+)
+
+// wrapperChainPrefixes start the names of the per-container chains of the
+// cni-wrapper-plugin that the ConsistencyChecker checks.
+var wrapperChainPrefixes = []string{"netout--", "netin--"}
+
+// wrapperChainTables are the tables the checked chains of the cni-wrapper-plugin
+// are in.
+var wrapperChainTables = []string{enforcer.FilterTable, "nat", "mangle"}
+
+var denyLogPrefixRegexp = regexp.MustCompile(`--log-prefix "?(DENY_[^"]*)"?`)
+
+// ConsistencyChecker cross-references the containers in the datastore with
+// the chains the cni-wrapper-plugin and the agent created for them, and
+// reports the discrepancies it finds on two checks in a row, since the plugin
+// and the agent create and delete the chains after and before the datastore
+// changes:
+//
+//   - chains without container: netout, netin and ASG chains of containers
+//     that are not in the datastore. Repair deletes them.
+//   - containers without chain: containers without netout chain, or without
+//     ASG chain when CheckASGChains is set. Repair syncs the ASGs of the
+//     containers without ASG chain; the netout chain is only created along
+//     with the container.
+//   - wrong log config: ASG chains whose denied packets are not logged with
+//     the log config of the container in the datastore. Repair syncs the ASGs
+//     of the container.
+//
+// Owns and OwnsChain restrict the checks to the containers and ASG chains of
+// a worker, and the chains of the cni-wrapper-plugin are only checked with
+// CheckWrapperChains.
+type ConsistencyChecker struct {
+	Store       datastore.Datastore
+	IPTables    chainLister
+	Enforcer    chainDeleter
+	ChainOwners chainReleaser
+	// DefaultRules are the rules that end the ASG chain of a container, which
+	// log its denied packets with its log config.
+	DefaultRules func(handle, instanceIndex string) []rules.IPTablesRule
+	SyncASGs     func(handles ...string) error
+
+	CheckWrapperChains bool
+	CheckASGChains     bool
+	Owns               func(handle string) bool
+	OwnsChain          func(name string) bool
+	Repair             bool
+
+	MetricsSender gaugeSender
+	Logger        lager.Logger
+
+	seen map[string]struct{}
+}
+
+type inconsistencies struct {
+	chainsWithoutContainer []enforcer.LiveChain
+	// containersWithoutChain are the containers without netout chain, and
+	// containersWithoutASGChain the ones without ASG chain.
+	containersWithoutChain    []string
+	containersWithoutASGChain []string
+	wrongLogConfig            []string
+}
+
+func (c *ConsistencyChecker) Check() error {
+	found, err := c.find()
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]struct{}{}
+	confirmed := inconsistencies{}
+	for _, chain := range found.chainsWithoutContainer {
+		if c.confirm(seen, "chain", chain.Table, chain.Name) {
+			c.Logger.Info("chain-without-container", lager.Data{"table": chain.Table, "chain": chain.Name})
+			confirmed.chainsWithoutContainer = append(confirmed.chainsWithoutContainer, chain)
+		}
+	}
+	for _, handle := range found.containersWithoutChain {
+		if c.confirm(seen, "netout", handle) {
+			c.Logger.Info("container-without-chain", lager.Data{"container": handle, "chain": "netout"})
+			confirmed.containersWithoutChain = append(confirmed.containersWithoutChain, handle)
+		}
+	}
+	for _, handle := range found.containersWithoutASGChain {
+		if c.confirm(seen, "asg", handle) {
+			c.Logger.Info("container-without-chain", lager.Data{"container": handle, "chain": "asg"})
+			confirmed.containersWithoutASGChain = append(confirmed.containersWithoutASGChain, handle)
+		}
+	}
+	for _, handle := range found.wrongLogConfig {
+		if c.confirm(seen, "log-config", handle) {
+			c.Logger.Info("wrong-log-config", lager.Data{"container": handle})
+			confirmed.wrongLogConfig = append(confirmed.wrongLogConfig, handle)
+		}
+	}
+	c.seen = seen
+
+	c.MetricsSender.SendValue(metricChainsWithoutContainer, float64(len(confirmed.chainsWithoutContainer)), "chains")
+	c.MetricsSender.SendValue(metricContainersWithoutChain, float64(len(confirmed.containersWithoutChain)+len(confirmed.containersWithoutASGChain)), "containers")
+	c.MetricsSender.SendValue(metricWrongLogConfig, float64(len(confirmed.wrongLogConfig)), "containers")
+
+	if !c.Repair {
+		return nil
+	}
+	return c.repair(confirmed)
+}
+
+// confirm records a discrepancy and reports whether the previous check found
+// it too.
+func (c *ConsistencyChecker) confirm(seen map[string]struct{}, key ...string) bool {
+	k := strings.Join(key, "/")
+	seen[k] = struct{}{}
+	_, ok := c.seen[k]
+	return ok
+}
+
+func (c *ConsistencyChecker) find() (inconsistencies, error) {
+	containers, err := c.Store.ReadAll()
+	if err != nil {
+		return inconsistencies{}, fmt.Errorf("read datastore: %s", err)
+	}
+
+	handles := []string{}
+	for handle := range containers {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+
+	found := inconsistencies{}
+	if c.CheckWrapperChains {
+		err := c.findWrapperChains(handles, &found)
+		if err != nil {
+			return inconsistencies{}, err
+		}
+	}
+	if c.CheckASGChains {
+		err := c.findASGChains(containers, handles, &found)
+		if err != nil {
+			return inconsistencies{}, err
+		}
+	}
+	return found, nil
+}
+
+func (c *ConsistencyChecker) findWrapperChains(handles []string, found *inconsistencies) error {
+	expected := map[enforcer.LiveChain]struct{}{}
+	netOutChains := map[string]string{}
+	for _, handle := range handles {
+		names, err := netrules.ContainerChainNames(&netrules.ChainNamer{MaxLength: 28}, handle)
+		if err != nil {
+			return fmt.Errorf("chain names of %s: %s", handle, err)
+		}
+		for _, chain := range names.Tables() {
+			expected[enforcer.LiveChain{Table: chain.Table, Name: chain.Chain}] = struct{}{}
+		}
+		netOutChains[handle] = names.NetOut
+	}
+
+	live := map[enforcer.LiveChain]struct{}{}
+	for _, table := range wrapperChainTables {
+		chains, err := c.IPTables.ListChains(table)
+		if err != nil {
+			return fmt.Errorf("list chains in %s: %s", table, err)
+		}
+		for _, name := range chains {
+			if !hasAnyPrefix(name, wrapperChainPrefixes) {
+				continue
+			}
+			chain := enforcer.LiveChain{Table: table, Name: name}
+			live[chain] = struct{}{}
+			if _, ok := expected[chain]; !ok {
+				found.chainsWithoutContainer = append(found.chainsWithoutContainer, chain)
+			}
+		}
+	}
+
+	for _, handle := range handles {
+		if _, ok := live[enforcer.LiveChain{Table: enforcer.FilterTable, Name: netOutChains[handle]}]; !ok {
+			found.containersWithoutChain = append(found.containersWithoutChain, handle)
+		}
+	}
+	return nil
+}
+
+func (c *ConsistencyChecker) findASGChains(containers map[string]datastore.Container, handles []string, found *inconsistencies) error {
+	chains, err := c.IPTables.ListChains(enforcer.FilterTable)
+	if err != nil {
+		return fmt.Errorf("list chains in %s: %s", enforcer.FilterTable, err)
+	}
+
+	prefixes := map[string]string{}
+	for _, handle := range handles {
+		prefixes[planner.ASGChainPrefix(handle)] = handle
+	}
+
+	reManagedChain := rules.ManagedChainRegexp(planner.ASGManagedChainsRegex)
+	containerChains := map[string][]string{}
+	for _, name := range chains {
+		if !strings.HasPrefix(name, "asg-") || !c.ownsChain(name) {
+			continue
+		}
+		prefix := name
+		if len(prefix) > len("asg-")+6 {
+			prefix = prefix[:len("asg-")+6]
+		}
+		handle, ok := prefixes[prefix]
+		if !ok {
+			found.chainsWithoutContainer = append(found.chainsWithoutContainer, enforcer.LiveChain{Table: enforcer.FilterTable, Name: name})
+			continue
+		}
+		if reManagedChain.MatchString(name) {
+			containerChains[handle] = append(containerChains[handle], name)
+		}
+	}
+
+	for _, handle := range handles {
+		if !c.owns(handle) {
+			continue
+		}
+		metadata, err := datastore.ParseContainerMetadata(containers[handle].Metadata)
+		if err != nil || metadata.SpaceID == "" || metadata.PolicyGroupID == "" {
+			// the agent enforces no ASGs for the container
+			continue
+		}
+		switch len(containerChains[handle]) {
+		case 0:
+			found.containersWithoutASGChain = append(found.containersWithoutASGChain, handle)
+		case 1:
+			matches, err := c.logConfigMatches(handle, metadata.InstanceIndex(), containerChains[handle][0])
+			if err != nil {
+				return err
+			}
+			if !matches {
+				found.wrongLogConfig = append(found.wrongLogConfig, handle)
+			}
+		default:
+			// the agent is replacing the chain of the container
+		}
+	}
+	return nil
+}
+
+// logConfigMatches reports whether the ASG chain of a container logs its
+// denied packets with the log prefix of its default rules.
+func (c *ConsistencyChecker) logConfigMatches(handle, instanceIndex, chain string) (bool, error) {
+	expected := map[string]struct{}{}
+	for _, rule := range c.DefaultRules(handle, instanceIndex) {
+		if prefix, ok := denyLogPrefix(strings.Join(rule, " ")); ok {
+			expected[strings.TrimSpace(prefix)] = struct{}{}
+		}
+	}
+
+	listing, err := c.IPTables.List(enforcer.FilterTable, chain)
+	if err != nil {
+		return false, fmt.Errorf("list %s: %s", chain, err)
+	}
+	live := map[string]struct{}{}
+	for _, rule := range listing {
+		if prefix, ok := denyLogPrefix(rule); ok {
+			live[strings.TrimSpace(prefix)] = struct{}{}
+		}
+	}
+
+	if len(live) != len(expected) {
+		return false, nil
+	}
+	for prefix := range expected {
+		if _, ok := live[prefix]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// denyLogPrefix is the log prefix of a rule that logs the packets the
+// default rules of an ASG chain deny, e.g. DENY_0_some-handle.
+func denyLogPrefix(rule string) (string, bool) {
+	matches := denyLogPrefixRegexp.FindStringSubmatch(rule)
+	if matches == nil || strings.HasPrefix(matches[1], "DENY_ORL") {
+		return "", false
+	}
+	return matches[1], true
+}
+
+func (c *ConsistencyChecker) repair(confirmed inconsistencies) error {
+	var errors error
+	if len(confirmed.chainsWithoutContainer) > 0 {
+		c.Logger.Info("deleting-chains-without-container", lager.Data{"chains": confirmed.chainsWithoutContainer})
+		deleted, err := c.Enforcer.DeleteChains(confirmed.chainsWithoutContainer)
+		if err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("delete chains: %s", err))
+		}
+
+		released := []datastore.OwnedChain{}
+		for _, chain := range deleted {
+			if hasAnyPrefix(chain.Name, wrapperChainPrefixes) {
+				released = append(released, datastore.OwnedChain{Table: chain.Table, Chain: chain.Name})
+			}
+		}
+		if len(released) > 0 {
+			err = c.ChainOwners.Release(netrules.ChainOwner, released...)
+			if err != nil {
+				errors = multierror.Append(errors, fmt.Errorf("release chains: %s", err))
+			}
+		}
+	}
+
+	if c.CheckASGChains && c.SyncASGs != nil {
+		resync := append(append([]string{}, confirmed.containersWithoutASGChain...), confirmed.wrongLogConfig...)
+		if len(resync) > 0 {
+			c.Logger.Info("syncing-asgs-of-containers", lager.Data{"containers": resync})
+			err := c.SyncASGs(resync...)
+			if err != nil {
+				errors = multierror.Append(errors, fmt.Errorf("sync asgs: %s", err))
+			}
+		}
+	}
+	return errors
+}
+
+func (c *ConsistencyChecker) owns(handle string) bool {
+	return c.Owns == nil || c.Owns(handle)
+}
+
+func (c *ConsistencyChecker) ownsChain(name string) bool {
+	return c.OwnsChain == nil || c.OwnsChain(name)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
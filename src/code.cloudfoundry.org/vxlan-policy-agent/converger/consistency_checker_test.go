@@ -0,0 +1,350 @@
+package converger_test
+
+import (
+	"errors"
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsistencyChecker", func() {
+	var (
+		checker       *converger.ConsistencyChecker
+		store         *libfakes.Datastore
+		iptables      *fakes.ChainLister
+		chainDeleter  *fakes.ChainDeleter
+		chainReleaser *fakes.ChainReleaser
+		metricsSender *fakes.GaugeSender
+		logger        *lagertest.TestLogger
+		synced        [][]string
+
+		liveChains map[string][]string
+		asgChainA  string
+		asgChainB  string
+		asgOrphan  string
+		logPrefixA string
+	)
+
+	gauges := func() map[string]float64 {
+		values := map[string]float64{}
+		for i := 0; i < metricsSender.SendValueCallCount(); i++ {
+			name, value, _ := metricsSender.SendValueArgsForCall(i)
+			values[name] = value
+		}
+		return values
+	}
+
+	removeChain := func(table, name string) {
+		kept := []string{}
+		for _, chain := range liveChains[table] {
+			if chain != name {
+				kept = append(kept, chain)
+			}
+		}
+		liveChains[table] = kept
+	}
+
+	BeforeEach(func() {
+		store = &libfakes.Datastore{}
+		store.ReadAllReturns(map[string]datastore.Container{
+			"container-a": {Handle: "container-a", Metadata: map[string]interface{}{
+				"space_id":        "some-space",
+				"policy_group_id": "some-app",
+				"log_config":      `{"guid":"some-app","index":2}`,
+			}},
+			"container-b": {Handle: "container-b", Metadata: map[string]interface{}{
+				"space_id":        "some-space",
+				"policy_group_id": "some-app",
+			}},
+			"container-c": {Handle: "container-c", Metadata: map[string]interface{}{}},
+		}, nil)
+
+		asgChainA = rules.ChainName(planner.ASGChainPrefix("container-a"), 1, 1000)
+		asgChainB = rules.ChainName(planner.ASGChainPrefix("container-b"), 1, 1000)
+		asgOrphan = rules.ChainName(planner.ASGChainPrefix("container-gone"), 1, 1000)
+		liveChains = map[string][]string{
+			"filter": {
+				"INPUT", "FORWARD",
+				"netout--container-a", "netout--container-a--log",
+				"netout--container-b",
+				"netout--container-c",
+				"netout--container-gone",
+				asgChainA, enforcer.SubChainName(asgChainA, 0),
+				asgChainB,
+				asgOrphan, enforcer.SubChainName(asgOrphan, 0),
+			},
+			"nat":    {"POSTROUTING", "netin--container-a", "netin--container-gone"},
+			"mangle": {"netin--container-a"},
+		}
+		logPrefixA = "DENY_2_container-a "
+
+		iptables = &fakes.ChainLister{}
+		iptables.ListChainsStub = func(table string) ([]string, error) {
+			return liveChains[table], nil
+		}
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			switch chain {
+			case asgChainA:
+				return []string{
+					fmt.Sprintf("-N %s", chain),
+					fmt.Sprintf(`-A %s -m limit --limit 2/s --limit-burst 2 -j LOG --log-prefix "DENY_ORL_container-a "`, chain),
+					fmt.Sprintf(`-A %s -m limit --limit 2/s --limit-burst 2 -j LOG --log-prefix "%s"`, chain, logPrefixA),
+					fmt.Sprintf("-A %s -j REJECT --reject-with icmp-port-unreachable", chain),
+				}, nil
+			case asgChainB:
+				return []string{
+					fmt.Sprintf("-N %s", chain),
+					fmt.Sprintf(`-A %s -m limit --limit 2/s --limit-burst 2 -j LOG --log-prefix "DENY_container-b "`, chain),
+					fmt.Sprintf("-A %s -j REJECT --reject-with icmp-port-unreachable", chain),
+				}, nil
+			}
+			return nil, nil
+		}
+
+		chainDeleter = &fakes.ChainDeleter{}
+		chainDeleter.DeleteChainsStub = func(chains []enforcer.LiveChain) ([]enforcer.LiveChain, error) {
+			return chains, nil
+		}
+		chainReleaser = &fakes.ChainReleaser{}
+		metricsSender = &fakes.GaugeSender{}
+		logger = lagertest.NewTestLogger("test")
+		synced = nil
+
+		checker = &converger.ConsistencyChecker{
+			Store:       store,
+			IPTables:    iptables,
+			Enforcer:    chainDeleter,
+			ChainOwners: chainReleaser,
+			DefaultRules: func(handle, instanceIndex string) []rules.IPTablesRule {
+				logID := handle
+				if instanceIndex != "" {
+					logID = instanceIndex + "_" + handle
+				}
+				return []rules.IPTablesRule{
+					{"-m", "limit", "--limit", "2/s", "--limit-burst", "2", "--jump", "LOG", "--log-prefix", "DENY_" + logID + " "},
+					{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+				}
+			},
+			SyncASGs: func(handles ...string) error {
+				synced = append(synced, handles)
+				return nil
+			},
+			CheckWrapperChains: true,
+			CheckASGChains:     true,
+			MetricsSender:      metricsSender,
+			Logger:             logger,
+		}
+	})
+
+	It("reports the chains without container found on two checks in a row", func() {
+		Expect(checker.Check()).To(Succeed())
+		Expect(gauges()).To(Equal(map[string]float64{
+			"consistencyChainsWithoutContainer": 0,
+			"consistencyContainersWithoutChain": 0,
+			"consistencyWrongLogConfig":         0,
+		}))
+
+		Expect(checker.Check()).To(Succeed())
+		Expect(gauges()).To(HaveKeyWithValue("consistencyChainsWithoutContainer", float64(4)))
+		Expect(logger.LogMessages()).To(ContainElement("test.chain-without-container"))
+		Expect(logger.Logs()).To(ContainElement(WithTransform(func(log lager.LogFormat) interface{} {
+			return log.Data["chain"]
+		}, Equal("netin--container-gone"))))
+	})
+
+	It("does not report chains that are gone by the second check", func() {
+		Expect(checker.Check()).To(Succeed())
+		removeChain("filter", "netout--container-gone")
+		removeChain("nat", "netin--container-gone")
+		removeChain("filter", asgOrphan)
+		removeChain("filter", enforcer.SubChainName(asgOrphan, 0))
+
+		Expect(checker.Check()).To(Succeed())
+		Expect(gauges()).To(HaveKeyWithValue("consistencyChainsWithoutContainer", float64(0)))
+	})
+
+	It("does not repair without Repair", func() {
+		Expect(checker.Check()).To(Succeed())
+		Expect(checker.Check()).To(Succeed())
+
+		Expect(chainDeleter.DeleteChainsCallCount()).To(Equal(0))
+		Expect(synced).To(BeEmpty())
+	})
+
+	Context("when a container has no netout chain", func() {
+		BeforeEach(func() {
+			removeChain("filter", "netout--container-c")
+		})
+
+		It("reports the container", func() {
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(Succeed())
+
+			Expect(gauges()).To(HaveKeyWithValue("consistencyContainersWithoutChain", float64(1)))
+			Expect(logger.LogMessages()).To(ContainElement("test.container-without-chain"))
+		})
+
+		It("does not sync its ASGs to repair it", func() {
+			checker.Repair = true
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(Succeed())
+
+			Expect(synced).To(BeEmpty())
+		})
+	})
+
+	Context("when a container with ASGs has no ASG chain", func() {
+		BeforeEach(func() {
+			removeChain("filter", asgChainB)
+		})
+
+		It("reports the container", func() {
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(Succeed())
+
+			Expect(gauges()).To(HaveKeyWithValue("consistencyContainersWithoutChain", float64(1)))
+		})
+
+		It("syncs its ASGs to repair it", func() {
+			checker.Repair = true
+			Expect(checker.Check()).To(Succeed())
+			Expect(synced).To(BeEmpty())
+
+			Expect(checker.Check()).To(Succeed())
+			Expect(synced).To(Equal([][]string{{"container-b"}}))
+		})
+	})
+
+	Context("when the ASG chain of a container logs with another log config", func() {
+		BeforeEach(func() {
+			logPrefixA = "DENY_1_container-a "
+		})
+
+		It("reports the container", func() {
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(Succeed())
+
+			Expect(gauges()).To(HaveKeyWithValue("consistencyWrongLogConfig", float64(1)))
+			Expect(logger.LogMessages()).To(ContainElement("test.wrong-log-config"))
+		})
+
+		It("syncs its ASGs to repair it", func() {
+			checker.Repair = true
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(Succeed())
+
+			Expect(synced).To(Equal([][]string{{"container-a"}}))
+		})
+	})
+
+	Context("when the ASG chain of a container logs although ASG logging is disabled", func() {
+		BeforeEach(func() {
+			checker.DefaultRules = func(handle, instanceIndex string) []rules.IPTablesRule {
+				return []rules.IPTablesRule{{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"}}
+			}
+		})
+
+		It("reports the containers", func() {
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(Succeed())
+
+			Expect(gauges()).To(HaveKeyWithValue("consistencyWrongLogConfig", float64(2)))
+		})
+	})
+
+	Context("when repairing", func() {
+		BeforeEach(func() {
+			checker.Repair = true
+		})
+
+		It("deletes the chains without container and releases the ones of the wrapper plugin", func() {
+			Expect(checker.Check()).To(Succeed())
+			Expect(chainDeleter.DeleteChainsCallCount()).To(Equal(0))
+
+			Expect(checker.Check()).To(Succeed())
+			Expect(chainDeleter.DeleteChainsCallCount()).To(Equal(1))
+			Expect(chainDeleter.DeleteChainsArgsForCall(0)).To(ConsistOf(
+				enforcer.LiveChain{Table: "filter", Name: "netout--container-gone"},
+				enforcer.LiveChain{Table: "nat", Name: "netin--container-gone"},
+				enforcer.LiveChain{Table: "filter", Name: asgOrphan},
+				enforcer.LiveChain{Table: "filter", Name: enforcer.SubChainName(asgOrphan, 0)},
+			))
+
+			Expect(chainReleaser.ReleaseCallCount()).To(Equal(1))
+			owner, released := chainReleaser.ReleaseArgsForCall(0)
+			Expect(owner).To(Equal("cni-wrapper-plugin"))
+			Expect(released).To(ConsistOf(
+				datastore.OwnedChain{Table: "filter", Chain: "netout--container-gone"},
+				datastore.OwnedChain{Table: "nat", Chain: "netin--container-gone"},
+			))
+		})
+
+		It("returns the errors of the repair", func() {
+			chainDeleter.DeleteChainsStub = nil
+			chainDeleter.DeleteChainsReturns(nil, errors.New("banana"))
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(MatchError(ContainSubstring("delete chains: banana")))
+		})
+	})
+
+	Context("when the checker is a worker of a sharded agent", func() {
+		BeforeEach(func() {
+			checker.CheckWrapperChains = false
+			checker.Owns = func(handle string) bool {
+				return handle == "container-a"
+			}
+			checker.OwnsChain = func(name string) bool {
+				return name == asgChainA || name == enforcer.SubChainName(asgChainA, 0)
+			}
+			removeChain("filter", asgChainB)
+		})
+
+		It("only checks its own containers and ASG chains", func() {
+			Expect(checker.Check()).To(Succeed())
+			Expect(checker.Check()).To(Succeed())
+
+			Expect(gauges()).To(Equal(map[string]float64{
+				"consistencyChainsWithoutContainer": 0,
+				"consistencyContainersWithoutChain": 0,
+				"consistencyWrongLogConfig":         0,
+			}))
+			for _, table := range []string{"nat", "mangle"} {
+				for i := 0; i < iptables.ListChainsCallCount(); i++ {
+					Expect(iptables.ListChainsArgsForCall(i)).NotTo(Equal(table))
+				}
+			}
+		})
+	})
+
+	Context("when reading the datastore fails", func() {
+		BeforeEach(func() {
+			store.ReadAllReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(checker.Check()).To(MatchError("read datastore: banana"))
+		})
+	})
+
+	Context("when listing the chains fails", func() {
+		BeforeEach(func() {
+			iptables.ListChainsStub = nil
+			iptables.ListChainsReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			Expect(checker.Check()).To(MatchError("list chains in filter: banana"))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package converger
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"github.com/hashicorp/go-multierror"
+)
+
+type DrainResult struct {
+	Container            string   `json:"container"`
+	IP                   string   `json:"ip,omitempty"`
+	DatastoreRemoved     bool     `json:"datastore_entry_removed"`
+	ASGChainsRemoved     []string `json:"asg_chains_removed"`
+	WrapperChainsRemoved []string `json:"wrapper_chains_removed"`
+	PoliciesUpdated      bool     `json:"policies_updated"`
+}
+
+// ContainerDrainer removes the rules of a single container right away, e.g.
+// after a container was deleted without garden calling the CNI plugin.
+// ASGCleanupFunc is nil when ASG syncing is disabled. WrapperChainCleanupFunc
+// removes the chains the CNI wrapper plugin created for the container.
+type ContainerDrainer struct {
+	Store                   datastore.Datastore
+	ASGCleanupFunc          func(string) ([]enforcer.LiveChain, error)
+	WrapperChainCleanupFunc func(string) ([]enforcer.LiveChain, error)
+	PolicyCycleFunc         func() error
+	Logger                  lager.Logger
+}
+
+func (d *ContainerDrainer) DrainContainer(containerHandle string) (DrainResult, error) {
+	logger := d.Logger.Session("drain-container", lager.Data{"container": containerHandle})
+	result := DrainResult{
+		Container:            containerHandle,
+		ASGChainsRemoved:     []string{},
+		WrapperChainsRemoved: []string{},
+	}
+
+	var errors error
+
+	container, err := d.Store.Delete(containerHandle)
+	if err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("delete from datastore: %s", err))
+	} else if container.Handle != "" {
+		result.IP = container.IP
+		result.DatastoreRemoved = true
+	}
+
+	if d.ASGCleanupFunc != nil {
+		deletedChains, err := d.ASGCleanupFunc(containerHandle)
+		if err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("cleanup asg chains: %s", err))
+		}
+		for _, chain := range deletedChains {
+			result.ASGChainsRemoved = append(result.ASGChainsRemoved, chain.Name)
+		}
+	}
+
+	// the ASG chains jump to the chains of the wrapper plugin, so those go
+	// after them
+	deletedChains, err := d.WrapperChainCleanupFunc(containerHandle)
+	if err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("cleanup wrapper chains: %s", err))
+	}
+	for _, chain := range deletedChains {
+		result.WrapperChainsRemoved = append(result.WrapperChainsRemoved, chain.Table+"/"+chain.Name)
+	}
+
+	// policy rules are planned from the datastore, so they only change when
+	// the container was removed from it
+	if result.DatastoreRemoved {
+		err = d.PolicyCycleFunc()
+		if err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("policy cycle: %s", err))
+		} else {
+			result.PoliciesUpdated = true
+		}
+	}
+
+	if errors != nil {
+		logger.Error("failed", errors, lager.Data{"result": result})
+	} else {
+		logger.Info("drained", lager.Data{"result": result})
+	}
+	return result, errors
+}
@@ -0,0 +1,148 @@
+package converger_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ContainerDrainer", func() {
+	var (
+		drainer           *converger.ContainerDrainer
+		store             *libfakes.Datastore
+		logger            *lagertest.TestLogger
+		cleanedContainers []string
+		cleanupErr        error
+		chainCleanups     []string
+		steps             []string
+		chainCleanupErr   error
+		policyCycles      int
+		policyCycleErr    error
+	)
+
+	BeforeEach(func() {
+		store = &libfakes.Datastore{}
+		store.DeleteReturns(datastore.Container{Handle: "some-handle", IP: "10.255.1.2"}, nil)
+		logger = lagertest.NewTestLogger("test")
+		cleanedContainers = nil
+		cleanupErr = nil
+		chainCleanups = nil
+		steps = nil
+		chainCleanupErr = nil
+		policyCycles = 0
+		policyCycleErr = nil
+
+		drainer = &converger.ContainerDrainer{
+			Store: store,
+			ASGCleanupFunc: func(containerHandle string) ([]enforcer.LiveChain, error) {
+				cleanedContainers = append(cleanedContainers, containerHandle)
+				steps = append(steps, "asg")
+				return []enforcer.LiveChain{{Table: "filter", Name: "asg-a1b2c3v1-g7cs183k3a"}}, cleanupErr
+			},
+			WrapperChainCleanupFunc: func(containerHandle string) ([]enforcer.LiveChain, error) {
+				chainCleanups = append(chainCleanups, containerHandle)
+				steps = append(steps, "wrapper")
+				return []enforcer.LiveChain{
+					{Table: "filter", Name: "netout--some-handle"},
+					{Table: "nat", Name: "netin--some-handle"},
+				}, chainCleanupErr
+			},
+			PolicyCycleFunc: func() error {
+				policyCycles++
+				return policyCycleErr
+			},
+			Logger: logger,
+		}
+	})
+
+	It("removes the container from the datastore, cleans up its chains and updates policies", func() {
+		result, err := drainer.DrainContainer("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(store.DeleteCallCount()).To(Equal(1))
+		Expect(store.DeleteArgsForCall(0)).To(Equal("some-handle"))
+		Expect(cleanedContainers).To(Equal([]string{"some-handle"}))
+		Expect(chainCleanups).To(Equal([]string{"some-handle"}))
+		Expect(policyCycles).To(Equal(1))
+		Expect(steps).To(Equal([]string{"asg", "wrapper"}), "the ASG chains jump to the wrapper chains")
+
+		Expect(result).To(Equal(converger.DrainResult{
+			Container:        "some-handle",
+			IP:               "10.255.1.2",
+			DatastoreRemoved: true,
+			ASGChainsRemoved: []string{"asg-a1b2c3v1-g7cs183k3a"},
+			WrapperChainsRemoved: []string{
+				"filter/netout--some-handle",
+				"nat/netin--some-handle",
+			},
+			PoliciesUpdated: true,
+		}))
+		Expect(logger).To(gbytes.Say("drain-container.drained"))
+	})
+
+	Context("when the container is not in the datastore", func() {
+		BeforeEach(func() {
+			store.DeleteReturns(datastore.Container{}, nil)
+		})
+
+		It("still cleans up ASG chains but does not run a policy cycle", func() {
+			result, err := drainer.DrainContainer("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.DatastoreRemoved).To(BeFalse())
+			Expect(result.ASGChainsRemoved).To(HaveLen(1))
+			Expect(policyCycles).To(Equal(0))
+		})
+	})
+
+	Context("when ASG syncing is disabled", func() {
+		BeforeEach(func() {
+			drainer.ASGCleanupFunc = nil
+		})
+
+		It("skips the ASG cleanup", func() {
+			result, err := drainer.DrainContainer("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ASGChainsRemoved).To(BeEmpty())
+			Expect(result.WrapperChainsRemoved).To(HaveLen(2))
+			Expect(result.PoliciesUpdated).To(BeTrue())
+		})
+	})
+
+	Context("when steps fail", func() {
+		BeforeEach(func() {
+			store.DeleteReturns(datastore.Container{}, errors.New("banana"))
+			cleanupErr = errors.New("zucchini")
+			chainCleanupErr = errors.New("cucumber")
+		})
+
+		It("runs the remaining steps and returns all errors", func() {
+			_, err := drainer.DrainContainer("some-handle")
+			Expect(err).To(MatchError(ContainSubstring("delete from datastore: banana")))
+			Expect(err).To(MatchError(ContainSubstring("cleanup asg chains: zucchini")))
+			Expect(err).To(MatchError(ContainSubstring("cleanup wrapper chains: cucumber")))
+			Expect(cleanedContainers).To(HaveLen(1))
+			Expect(chainCleanups).To(HaveLen(1))
+			Expect(logger).To(gbytes.Say("drain-container.failed"))
+		})
+	})
+
+	Context("when the policy cycle fails", func() {
+		BeforeEach(func() {
+			policyCycleErr = errors.New("eggplant")
+		})
+
+		It("returns the error", func() {
+			result, err := drainer.DrainContainer("some-handle")
+			Expect(err).To(MatchError(ContainSubstring("policy cycle: eggplant")))
+			Expect(result.PoliciesUpdated).To(BeFalse())
+		})
+	})
+})
@@ -2,7 +2,9 @@ package converger
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -10,7 +12,9 @@ import (
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
 	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/plandump"
 	"code.cloudfoundry.org/vxlan-policy-agent/planner"
 	"github.com/hashicorp/go-multierror"
 )
@@ -30,14 +34,54 @@ type Planner interface {
 type ruleEnforcer interface {
 	EnforceRulesAndChain(enforcer.RulesWithChain) (string, error)
 	CleanChainsMatching(regex *regexp.Regexp, desiredChains []enforcer.LiveChain) ([]enforcer.LiveChain, error)
+	RepairDuplicateJumps(enforcer.Chain) (int, error)
 }
 
 //go:generate counterfeiter -o fakes/metrics_sender.go --fake-name MetricsSender . metricsSender
 type metricsSender interface {
 	SendDuration(string, time.Duration)
+	IncrementCounter(string)
+}
+
+//go:generate counterfeiter -o fakes/asg_chain_store.go --fake-name ASGChainStore . asgChainStore
+type asgChainStore interface {
+	Save([]datastore.ASGChain) error
+}
+
+//go:generate counterfeiter -o fakes/enforcement_status_store.go --fake-name EnforcementStatusStore . enforcementStatusStore
+type enforcementStatusStore interface {
+	SaveEnforcementStatuses(map[string]datastore.EnforcementStatus) error
+}
+
+//go:generate counterfeiter -o fakes/plan_dumper.go --fake-name PlanDumper . planDumper
+type planDumper interface {
+	Write(cycle string, ruleSets []enforcer.RulesWithChain) error
+}
+
+//go:generate counterfeiter -o fakes/enforcement_delay.go --fake-name EnforcementDelay . enforcementDelay
+type enforcementDelay interface {
+	EnforcementDelay() time.Duration
 }
 
 type SinglePollCycle struct {
+	ASGChainStore asgChainStore
+	// EnforcementStatusStore records the result of the last enforcement of
+	// the ASG rules of each container in its datastore entry.
+	EnforcementStatusStore enforcementStatusStore
+	// ASGSyncBatchSize limits how many containers get changed ASG rules
+	// enforced in one ASG cycle. The rules of the others are enforced in the
+	// next cycles, which continue after the last container of this one. The
+	// syncs that are forced for single containers are not limited. 0 means
+	// no limit.
+	ASGSyncBatchSize int
+	// PlanDumper writes the rule sets that each policy cycle and each ASG
+	// polling cycle planned, whether they were enforced or not, for offline
+	// analysis.
+	PlanDumper planDumper
+	// EnforcementDelay holds back the first enforcement of every cycle that
+	// changes rules, to drill how the platform copes with a slow agent.
+	EnforcementDelay enforcementDelay
+
 	planners            []Planner
 	enforcer            ruleEnforcer
 	metricsSender       metricsSender
@@ -47,9 +91,14 @@ type SinglePollCycle struct {
 	policyRuleSets      map[enforcer.Chain]enforcer.RulesWithChain
 	asgRuleSets         map[enforcer.LiveChain]enforcer.RulesWithChain
 	containerToASGChain map[enforcer.LiveChain]string
+	persistedASGChains  map[enforcer.LiveChain]string
 	metronClient        loggingclient.IngressClient
 	policyMutex         sync.Locker
 	asgMutex            sync.Locker
+	asgPauseMutex       sync.RWMutex
+	asgPausedSince      time.Time
+	iptablesScheduler   *IPTablesScheduler
+	asgBatchCursor      enforcer.LiveChain
 }
 
 func NewSinglePollCycle(planners []Planner, re ruleEnforcer, p policyClient, ms metricsSender, metronClient loggingclient.IngressClient, logger lager.Logger) *SinglePollCycle {
@@ -63,6 +112,8 @@ func NewSinglePollCycle(planners []Planner, re ruleEnforcer, p policyClient, ms
 		metronClient:  metronClient,
 		policyMutex:   new(sync.Mutex),
 		asgMutex:      new(sync.Mutex),
+
+		iptablesScheduler: &IPTablesScheduler{},
 	}
 }
 
@@ -73,6 +124,13 @@ const metricASGEnforceDuration = "asgIptablesEnforceTime"
 const metricASGCleanupDuration = "asgIptablesCleanupTime"
 const metricASGPollDuration = "asgTotalPollTime"
 
+const metricDuplicateJumpsRepaired = "iptablesDuplicateJumpsRepaired"
+
+const metricPolicyCyclePrefix = "policyCycle"
+const metricASGCyclePrefix = "asgCycle"
+
+const metricIPTablesCycleOverlaps = "iptablesCycleOverlaps"
+
 func (m *SinglePollCycle) DoPolicyCycleWithLastUpdatedCheck() error {
 	lastUpdated, err := m.policyClient.GetPoliciesLastUpdated()
 	if err != nil {
@@ -99,29 +157,47 @@ func (m *SinglePollCycle) DoPolicyCycle() error {
 
 	pollStartTime := time.Now()
 	var enforceDuration time.Duration
+	var phases cyclePhases
+	var allRuleSets []enforcer.RulesWithChain
+	delayed := false
 	for _, p := range m.planners {
+		phaseStart := time.Now()
 		ruleSet, err := p.GetPolicyRulesAndChain()
 		if err != nil {
 			m.policyMutex.Unlock()
 			return fmt.Errorf("get-rules: %s", err)
 		}
-		enforceStartTime := time.Now()
+		allRuleSets = append(allRuleSets, ruleSet)
+		enforceStartTime := since(&phases.plan, phaseStart)
 
 		oldRuleSet := m.policyRuleSets[ruleSet.Chain]
 		if !ruleSet.Equals(oldRuleSet) {
-			m.logger.Debug("poll-cycle", lager.Data{
-				"message":       "updating iptables rules",
-				"num old rules": len(oldRuleSet.Rules),
-				"num new rules": len(ruleSet.Rules),
-				"old rules":     oldRuleSet,
-				"new rules":     ruleSet,
-			})
+			diff := RuleDiff(oldRuleSet, ruleSet)
+			phaseStart = since(&phases.diff, enforceStartTime)
+			m.logger.Info("poll-cycle", diff)
+			delayed = m.delayEnforcement(delayed)
+			m.waitForIPTables()
+			phaseStart = since(&phases.wait, phaseStart)
 			_, err = m.enforcer.EnforceRulesAndChain(ruleSet)
 			if err != nil {
+				m.iptablesScheduler.Release()
 				m.policyMutex.Unlock()
 				return fmt.Errorf("enforce: %s", err)
 			}
 			m.policyRuleSets[ruleSet.Chain] = ruleSet
+			phaseStart = since(&phases.apply, phaseStart)
+
+			repaired, err := m.repairDuplicateJumps(ruleSet.Chain)
+			if err != nil {
+				m.logger.Error("repair-duplicate-jumps", err)
+			}
+			if repaired {
+				delete(m.policyRuleSets, ruleSet.Chain)
+			}
+			m.iptablesScheduler.Release()
+			since(&phases.cleanup, phaseStart)
+		} else {
+			since(&phases.diff, enforceStartTime)
 		}
 
 		enforceDuration += time.Now().Sub(enforceStartTime)
@@ -132,6 +208,8 @@ func (m *SinglePollCycle) DoPolicyCycle() error {
 	pollDuration := time.Now().Sub(pollStartTime)
 	m.metricsSender.SendDuration(metricEnforceDuration, enforceDuration)
 	m.metricsSender.SendDuration(metricPollDuration, pollDuration)
+	phases.send(m.metricsSender, metricPolicyCyclePrefix)
+	m.dumpPlan(plandump.CyclePolicy, allRuleSets)
 
 	return nil
 }
@@ -140,9 +218,82 @@ func (m *SinglePollCycle) DoASGCycle() error {
 	return m.SyncASGsForContainers() // syncs for all containers when arguments are empty
 }
 
+// PauseASGSyncing freezes the ASG chains of existing containers. It waits
+// for any in-flight ASG sync so that containers keep their last applied
+// chains. Containers without applied chains are still synced when forced.
+func (m *SinglePollCycle) PauseASGSyncing() {
+	m.asgMutex.Lock()
+	defer m.asgMutex.Unlock()
+
+	m.asgPauseMutex.Lock()
+	defer m.asgPauseMutex.Unlock()
+	if m.asgPausedSince.IsZero() {
+		m.asgPausedSince = time.Now()
+		m.logger.Info("asg-syncing-paused")
+	}
+}
+
+func (m *SinglePollCycle) ResumeASGSyncing() {
+	m.asgPauseMutex.Lock()
+	defer m.asgPauseMutex.Unlock()
+	if !m.asgPausedSince.IsZero() {
+		m.asgPausedSince = time.Time{}
+		m.logger.Info("asg-syncing-resumed")
+	}
+}
+
+func (m *SinglePollCycle) ASGSyncingPausedSince() (time.Time, bool) {
+	m.asgPauseMutex.RLock()
+	defer m.asgPauseMutex.RUnlock()
+	return m.asgPausedSince, !m.asgPausedSince.IsZero()
+}
+
+// CacheSizes reports how many plans and chains the poll cycle holds on to
+// between cycles.
+func (m *SinglePollCycle) CacheSizes() map[string]int {
+	sizes := map[string]int{"policy_rules": 0, "asg_rules": 0}
+
+	m.policyMutex.Lock()
+	sizes["policy_rule_sets"] = len(m.policyRuleSets)
+	for _, ruleSet := range m.policyRuleSets {
+		sizes["policy_rules"] += len(ruleSet.Rules)
+	}
+	m.policyMutex.Unlock()
+
+	m.asgMutex.Lock()
+	sizes["asg_rule_sets"] = len(m.asgRuleSets)
+	for _, ruleSet := range m.asgRuleSets {
+		sizes["asg_rules"] += len(ruleSet.Rules)
+	}
+	sizes["container_asg_chains"] = len(m.containerToASGChain)
+	m.asgMutex.Unlock()
+
+	return sizes
+}
+
+// ResetCaches forgets the rule sets that were enforced, so that the next
+// cycles enforce all of them again, e.g. after the iptables backend changed
+// and the enforced rules are no longer in the tables iptables writes to.
+func (m *SinglePollCycle) ResetCaches() {
+	m.policyMutex.Lock()
+	m.policyRuleSets = nil
+	m.policyMutex.Unlock()
+
+	m.asgMutex.Lock()
+	m.asgRuleSets = nil
+	m.asgMutex.Unlock()
+}
+
 func (m *SinglePollCycle) SyncASGsForContainers(containers ...string) error {
 	m.asgMutex.Lock()
 
+	_, paused := m.ASGSyncingPausedSince()
+	if paused && len(containers) == 0 {
+		m.asgMutex.Unlock()
+		m.logger.Debug("poll-cycle-asg", lager.Data{"message": "skipping: asg syncing is paused"})
+		return nil
+	}
+
 	if m.asgRuleSets == nil {
 		m.asgRuleSets = make(map[enforcer.LiveChain]enforcer.RulesWithChain)
 	}
@@ -152,60 +303,117 @@ func (m *SinglePollCycle) SyncASGsForContainers(containers ...string) error {
 
 	pollStartTime := time.Now()
 	var enforceDuration time.Duration
+	var phases cyclePhases
 
 	var allRuleSets []enforcer.RulesWithChain
 	var desiredChains []enforcer.LiveChain
 
 	var errors error
+	statuses := map[string]datastore.EnforcementStatus{}
+
+	pollingLoop := len(containers) == 0
+	batching := pollingLoop && m.ASGSyncBatchSize > 0
+	var batched, deferred int
+	delayed := false
 
 	for _, p := range m.planners {
+		phaseStart := time.Now()
 		asgrulesets, err := p.GetASGRulesAndChains(containers...)
 		if err != nil {
 			m.asgMutex.Unlock()
 			return fmt.Errorf("get-asg-rules: %s", err)
 		}
 
-		enforceStartTime := time.Now()
+		enforceStartTime := since(&phases.plan, phaseStart)
+		phaseStart = enforceStartTime
 
+		if batching {
+			asgrulesets = m.continueASGBatch(asgrulesets)
+		}
 		allRuleSets = append(allRuleSets, asgrulesets...)
 		for _, ruleset := range asgrulesets {
 			chainKey := enforcer.LiveChain{Table: ruleset.Chain.Table, Name: ruleset.Chain.ParentChain}
-			oldRuleSet := m.asgRuleSets[chainKey]
-			if !ruleset.Equals(oldRuleSet) {
-				m.logger.Debug("poll-cycle-asg", lager.Data{
-					"message":       "updating iptables rules",
-					"num old rules": len(oldRuleSet.Rules),
-					"num new rules": len(ruleset.Rules),
-					"old rules":     oldRuleSet,
-					"new rules":     ruleset,
-				})
+			oldRuleSet, applied := m.asgRuleSets[chainKey]
+			if paused && applied {
+				m.logger.Debug("poll-cycle-asg", lager.Data{"message": "skipping: asg syncing is paused", "chain": chainKey.Name})
+				continue
+			}
+			changed := !ruleset.Equals(oldRuleSet)
+			phaseStart = since(&phases.diff, phaseStart)
+			if changed && batching {
+				if batched == m.ASGSyncBatchSize {
+					deferred++
+					changed = false
+				} else {
+					batched++
+					m.asgBatchCursor = chainKey
+				}
+			}
+			if changed {
+				diff := RuleDiff(oldRuleSet, ruleset)
+				phaseStart = since(&phases.diff, phaseStart)
+				m.logger.Info("poll-cycle-asg", diff)
+				delayed = m.delayEnforcement(delayed)
+				m.waitForIPTables()
+				phaseStart = since(&phases.wait, phaseStart)
 				chain, err := m.enforcer.EnforceRulesAndChain(ruleset)
+				phaseStart = since(&phases.apply, phaseStart)
 				if err != nil {
+					status := datastore.EnforcementStatus{EnforcedAt: time.Now(), RulesHash: ruleset.Hash()}
 					if _, ok := err.(*enforcer.CleanupErr); ok {
 						m.updateRuleSet(chainKey, chain, ruleset)
+					} else {
+						status.Error = err.Error()
+					}
+					status.Chain = m.containerToASGChain[chainKey]
+					if ruleset.Handle != "" {
+						statuses[ruleset.Handle] = status
 					}
 
 					errors = multierror.Append(errors, fmt.Errorf("enforce-asg: %s", err))
 				} else {
+					if ruleset.Handle != "" {
+						statuses[ruleset.Handle] = datastore.EnforcementStatus{Chain: chain, EnforcedAt: time.Now(), RulesHash: ruleset.Hash()}
+					}
 					m.updateRuleSet(chainKey, chain, ruleset)
+
+					if pollingLoop {
+						repaired, err := m.repairDuplicateJumps(ruleset.Chain)
+						if err != nil {
+							errors = multierror.Append(errors, err)
+						}
+						if repaired {
+							delete(m.asgRuleSets, chainKey)
+						}
+						phaseStart = since(&phases.cleanup, phaseStart)
+					}
 				}
+				m.iptablesScheduler.Release()
 			}
 			desiredChains = append(desiredChains, enforcer.LiveChain{Table: ruleset.Chain.Table, Name: m.containerToASGChain[chainKey]})
 		}
 		enforceDuration += time.Now().Sub(enforceStartTime)
 	}
 
-	pollingLoop := len(containers) == 0
+	if deferred > 0 {
+		m.logger.Info("asg-sync-batch-full", lager.Data{"batch_size": m.ASGSyncBatchSize, "deferred": deferred})
+	}
 
 	var cleanupDuration time.Duration
 	if pollingLoop {
-		cleanupStart := time.Now()
-		err := m.cleanupASGsChains(planner.ASGManagedChainsRegex, desiredChains)
+		waitStart := time.Now()
+		m.waitForIPTables()
+		cleanupStart := since(&phases.wait, waitStart)
+		_, err := m.cleanupASGsChains(planner.ASGManagedChainsRegex, desiredChains)
+		m.iptablesScheduler.Release()
 		if err != nil {
 			errors = multierror.Append(errors, err)
 		}
 		cleanupDuration = time.Now().Sub(cleanupStart)
+		phases.cleanup += cleanupDuration
 	}
+	m.persistASGChains()
+	m.persistEnforcementStatuses(statuses)
 	m.asgMutex.Unlock()
 
 	if pollingLoop {
@@ -213,16 +421,143 @@ func (m *SinglePollCycle) SyncASGsForContainers(containers ...string) error {
 		m.metricsSender.SendDuration(metricASGCleanupDuration, cleanupDuration)
 		pollDuration := time.Now().Sub(pollStartTime)
 		m.metricsSender.SendDuration(metricASGPollDuration, pollDuration)
+		phases.send(m.metricsSender, metricASGCyclePrefix)
+		m.dumpPlan(plandump.CycleASG, allRuleSets)
 	}
 
 	return errors
 }
 
+// continueASGBatch orders the rule sets by their chains, starting with the
+// chain after the last one that was enforced, so that the batch of a cycle
+// continues where the batch of the previous cycle stopped.
+func (m *SinglePollCycle) continueASGBatch(ruleSets []enforcer.RulesWithChain) []enforcer.RulesWithChain {
+	chainKey := func(ruleSet enforcer.RulesWithChain) enforcer.LiveChain {
+		return enforcer.LiveChain{Table: ruleSet.Chain.Table, Name: ruleSet.Chain.ParentChain}
+	}
+	after := func(a, b enforcer.LiveChain) bool {
+		return a.Name > b.Name || (a.Name == b.Name && a.Table > b.Table)
+	}
+
+	sorted := append([]enforcer.RulesWithChain{}, ruleSets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return after(chainKey(sorted[j]), chainKey(sorted[i]))
+	})
+	next := sort.Search(len(sorted), func(i int) bool {
+		return after(chainKey(sorted[i]), m.asgBatchCursor)
+	})
+	return append(append([]enforcer.RulesWithChain{}, sorted[next:]...), sorted[:next]...)
+}
+
+// waitForIPTables waits for the turn of a cycle to change iptables, and counts
+// the turns for which the policy and the ASG cycle would have overlapped.
+// Callers release the turn as soon as they are done with iptables.
+// delayEnforcement sleeps for the enforcement delay unless the cycle was
+// delayed already, and returns that it was.
+func (m *SinglePollCycle) delayEnforcement(delayed bool) bool {
+	if delayed || m.EnforcementDelay == nil {
+		return true
+	}
+	delay := m.EnforcementDelay.EnforcementDelay()
+	if delay > 0 {
+		m.logger.Info("delaying-enforcement", lager.Data{"delay": delay.String()})
+		time.Sleep(delay)
+	}
+	return true
+}
+
+func (m *SinglePollCycle) waitForIPTables() {
+	if m.iptablesScheduler.Acquire() {
+		m.metricsSender.IncrementCounter(metricIPTablesCycleOverlaps)
+	}
+}
+
+// repairDuplicateJumps checks a parent chain right after it was enforced,
+// which covers the first cycle after startup, for stale jumps to managed
+// chains. Unchanged chains are not listed again on every cycle. When jumps
+// were removed, callers drop the cached rule set so that the next cycle
+// enforces the chain again.
+func (m *SinglePollCycle) repairDuplicateJumps(chain enforcer.Chain) (bool, error) {
+	removed, err := m.enforcer.RepairDuplicateJumps(chain)
+	if removed > 0 {
+		m.logger.Info("repaired-duplicate-jumps", lager.Data{"table": chain.Table, "chain": chain.ParentChain, "removed": removed})
+		m.metricsSender.IncrementCounter(metricDuplicateJumpsRepaired)
+	}
+	if err != nil {
+		return removed > 0, fmt.Errorf("repair-duplicate-jumps: %s", err)
+	}
+	return removed > 0, nil
+}
+
 func (m *SinglePollCycle) CleanupOrphanedASGsChains(containerHandle string) error {
+	_, err := m.CleanupASGsChainsForContainer(containerHandle)
+	return err
+}
+
+// CleanupASGsChainsForContainer removes all ASG chains of a container and
+// returns the chains that were deleted.
+func (m *SinglePollCycle) CleanupASGsChainsForContainer(containerHandle string) ([]enforcer.LiveChain, error) {
 	m.asgMutex.Lock()
 	defer m.asgMutex.Unlock()
 
-	return m.cleanupASGsChains(planner.ASGChainPrefix(containerHandle), []enforcer.LiveChain{})
+	m.waitForIPTables()
+	deletedChains, err := m.cleanupASGsChains(planner.ASGChainPrefix(containerHandle), []enforcer.LiveChain{})
+	m.iptablesScheduler.Release()
+	m.persistASGChains()
+	return deletedChains, err
+}
+
+// persistASGChains records the applied ASG chains for tools that run outside
+// of the agent. Failures are logged, since the chains are already enforced.
+func (m *SinglePollCycle) persistASGChains() {
+	if m.ASGChainStore == nil || reflect.DeepEqual(m.persistedASGChains, m.containerToASGChain) {
+		return
+	}
+
+	chains := []datastore.ASGChain{}
+	persisted := make(map[enforcer.LiveChain]string)
+	for chainKey, chainName := range m.containerToASGChain {
+		chains = append(chains, datastore.ASGChain{Table: chainKey.Table, ParentChain: chainKey.Name, Chain: chainName})
+		persisted[chainKey] = chainName
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].ParentChain < chains[j].ParentChain
+	})
+
+	err := m.ASGChainStore.Save(chains)
+	if err != nil {
+		m.logger.Error("persist-asg-chains", err)
+		return
+	}
+	m.persistedASGChains = persisted
+}
+
+// persistEnforcementStatuses records the results of the enforcements of a
+// cycle in the datastore, so that whether the ASGs of a container are current
+// can be seen without asking the agent. Failures are logged, since the rules
+// are already enforced.
+func (m *SinglePollCycle) persistEnforcementStatuses(statuses map[string]datastore.EnforcementStatus) {
+	if m.EnforcementStatusStore == nil || len(statuses) == 0 {
+		return
+	}
+
+	err := m.EnforcementStatusStore.SaveEnforcementStatuses(statuses)
+	if err != nil {
+		m.logger.Error("persist-enforcement-statuses", err)
+	}
+}
+
+// dumpPlan writes the rule sets that a cycle planned. Failures are logged,
+// since the dumps are only used for analysis.
+func (m *SinglePollCycle) dumpPlan(cycle string, ruleSets []enforcer.RulesWithChain) {
+	if m.PlanDumper == nil {
+		return
+	}
+
+	err := m.PlanDumper.Write(cycle, ruleSets)
+	if err != nil {
+		m.logger.Error("dump-plan", err, lager.Data{"cycle": cycle})
+	}
 }
 
 func (m *SinglePollCycle) updateRuleSet(chainKey enforcer.LiveChain, chain string, ruleset enforcer.RulesWithChain) {
@@ -231,10 +566,10 @@ func (m *SinglePollCycle) updateRuleSet(chainKey enforcer.LiveChain, chain strin
 	m.sendAppLog(ruleset.LogConfig)
 }
 
-func (m *SinglePollCycle) cleanupASGsChains(prefix string, desiredChains []enforcer.LiveChain) error {
+func (m *SinglePollCycle) cleanupASGsChains(prefix string, desiredChains []enforcer.LiveChain) ([]enforcer.LiveChain, error) {
 	deletedChains, err := m.enforcer.CleanChainsMatching(regexp.MustCompile(prefix), desiredChains)
 	if err != nil {
-		return fmt.Errorf("clean-up-orphaned-asg-chains: %s", err)
+		return nil, fmt.Errorf("clean-up-orphaned-asg-chains: %s", err)
 	} else {
 		m.logger.Debug("policy-cycle-asg", lager.Data{
 			"message": "deleted-orphaned-chains",
@@ -251,7 +586,7 @@ func (m *SinglePollCycle) cleanupASGsChains(prefix string, desiredChains []enfor
 		}
 	}
 
-	return nil
+	return deletedChains, nil
 }
 
 // used to test that we're deleting the right chains and nothing else
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"time"
 
 	diegologgingclientfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lib/datastore"
 	"code.cloudfoundry.org/lib/rules"
 	"code.cloudfoundry.org/vxlan-policy-agent/converger"
 	"code.cloudfoundry.org/vxlan-policy-agent/converger/fakes"
@@ -165,13 +167,139 @@ var _ = Describe("Single Poll Cycle", func() {
 			It("emits time metrics", func() {
 				err := p.DoPolicyCycle()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(metricsSender.SendDurationCallCount()).To(Equal(2))
+				Expect(metricsSender.SendDurationCallCount()).To(Equal(7))
 				name, _ := metricsSender.SendDurationArgsForCall(0)
 				Expect(name).To(Equal("iptablesEnforceTime"))
 				name, _ = metricsSender.SendDurationArgsForCall(1)
 				Expect(name).To(Equal("totalPollTime"))
 			})
 
+			It("emits the time spent in every phase of the cycle", func() {
+				err := p.DoPolicyCycle()
+				Expect(err).NotTo(HaveOccurred())
+				names := []string{}
+				for i := 2; i < metricsSender.SendDurationCallCount(); i++ {
+					name, _ := metricsSender.SendDurationArgsForCall(i)
+					names = append(names, name)
+				}
+				Expect(names).To(Equal([]string{
+					"policyCyclePlanTime",
+					"policyCycleDiffTime",
+					"policyCycleApplyTime",
+					"policyCycleCleanupTime",
+					"policyCycleIptablesWaitTime",
+				}))
+			})
+
+			It("checks the parent chains for duplicate jumps after enforcing them", func() {
+				err := p.DoPolicyCycle()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeEnforcer.RepairDuplicateJumpsCallCount()).To(Equal(3))
+				Expect(fakeEnforcer.RepairDuplicateJumpsArgsForCall(0)).To(Equal(localRulesWithChain.Chain))
+				Expect(metricsSender.IncrementCounterCallCount()).To(Equal(0))
+			})
+
+			Context("when a plan dumper is set", func() {
+				var planDumper *fakes.PlanDumper
+
+				BeforeEach(func() {
+					planDumper = &fakes.PlanDumper{}
+					p.PlanDumper = planDumper
+				})
+
+				It("dumps the rule sets of every planner", func() {
+					Expect(p.DoPolicyCycle()).To(Succeed())
+					Expect(planDumper.WriteCallCount()).To(Equal(1))
+					cycle, ruleSets := planDumper.WriteArgsForCall(0)
+					Expect(cycle).To(Equal("policy"))
+					Expect(ruleSets).To(Equal([]enforcer.RulesWithChain{localRulesWithChain, remoteRulesWithChain, policyRulesWithChain}))
+				})
+
+				It("dumps the rule sets that did not change as well", func() {
+					Expect(p.DoPolicyCycle()).To(Succeed())
+					Expect(p.DoPolicyCycle()).To(Succeed())
+					Expect(planDumper.WriteCallCount()).To(Equal(2))
+					_, ruleSets := planDumper.WriteArgsForCall(1)
+					Expect(ruleSets).To(HaveLen(3))
+				})
+
+				Context("when dumping fails", func() {
+					BeforeEach(func() {
+						planDumper.WriteReturns(errors.New("banana"))
+					})
+
+					It("logs the error", func() {
+						Expect(p.DoPolicyCycle()).To(Succeed())
+						Expect(logger).To(gbytes.Say("dump-plan.*banana"))
+					})
+				})
+			})
+
+			Context("when an enforcement delay is set", func() {
+				var enforcementDelay *fakes.EnforcementDelay
+
+				BeforeEach(func() {
+					enforcementDelay = &fakes.EnforcementDelay{}
+					enforcementDelay.EnforcementDelayReturns(20 * time.Millisecond)
+					p.EnforcementDelay = enforcementDelay
+				})
+
+				It("delays the cycle once before enforcing", func() {
+					start := time.Now()
+					Expect(p.DoPolicyCycle()).To(Succeed())
+					Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+					Expect(enforcementDelay.EnforcementDelayCallCount()).To(Equal(1))
+					Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
+					Expect(logger).To(gbytes.Say("delaying-enforcement.*20ms"))
+				})
+
+				It("does not delay cycles without changes", func() {
+					Expect(p.DoPolicyCycle()).To(Succeed())
+					Expect(p.DoPolicyCycle()).To(Succeed())
+					Expect(enforcementDelay.EnforcementDelayCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when duplicate jumps are repaired", func() {
+				BeforeEach(func() {
+					fakeEnforcer.RepairDuplicateJumpsStub = func(chain enforcer.Chain) (int, error) {
+						if chain == localRulesWithChain.Chain {
+							return 1, nil
+						}
+						return 0, nil
+					}
+				})
+
+				It("emits a metric and enforces the chain again on the next cycle", func() {
+					err := p.DoPolicyCycle()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+					Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("iptablesDuplicateJumpsRepaired"))
+					Expect(logger).To(gbytes.Say("repaired-duplicate-jumps"))
+					Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
+
+					fakeEnforcer.RepairDuplicateJumpsStub = nil
+					err = p.DoPolicyCycle()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(4))
+					Expect(fakeEnforcer.EnforceRulesAndChainArgsForCall(3)).To(Equal(localRulesWithChain))
+				})
+			})
+
+			Context("when repairing duplicate jumps fails", func() {
+				BeforeEach(func() {
+					fakeEnforcer.RepairDuplicateJumpsReturns(0, errors.New("banana"))
+				})
+
+				It("logs the error and continues", func() {
+					err := p.DoPolicyCycle()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(logger).To(gbytes.Say("repair-duplicate-jumps.*banana"))
+					Expect(fakeEnforcer.RepairDuplicateJumpsCallCount()).To(Equal(3))
+				})
+			})
+
 			Context("when a ruleset has not changed since the last poll cycle", func() {
 				BeforeEach(func() {
 					err := p.DoPolicyCycle()
@@ -188,6 +316,12 @@ var _ = Describe("Single Poll Cycle", func() {
 
 					Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
 				})
+
+				It("does not check the parent chains for duplicate jumps again", func() {
+					err := p.DoPolicyCycle()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakeEnforcer.RepairDuplicateJumpsCallCount()).To(Equal(3))
+				})
 			})
 
 			Context("when a ruleset has changed since the last poll cycle", func() {
@@ -207,12 +341,15 @@ var _ = Describe("Single Poll Cycle", func() {
 					Expect(fakePolicyPlanner.GetPolicyRulesAndChainCallCount()).To(Equal(2))
 
 					Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(4))
+
+					Expect(fakeEnforcer.RepairDuplicateJumpsCallCount()).To(Equal(4))
+					Expect(fakeEnforcer.RepairDuplicateJumpsArgsForCall(3)).To(Equal(localRulesWithChain.Chain))
 				})
 
 				It("logs a message about writing ip tables rules", func() {
 					err := p.DoPolicyCycle()
 					Expect(err).NotTo(HaveOccurred())
-					Expect(logger).To(gbytes.Say("poll-cycle.*updating iptables rules.*new rules.*new-rule.*num new rules.*1.*num old rules.*1.*old rules.*local-rule"))
+					Expect(logger).To(gbytes.Say(`poll-cycle.*"log_level":1.*"added":1,"added examples":\["new-rule"\],"chain":"[^"]*","message":"updating iptables rules","num new rules":1,"num old rules":1,"removed":1,"removed examples":\["local-rule"\]`))
 				})
 			})
 
@@ -247,7 +384,7 @@ var _ = Describe("Single Poll Cycle", func() {
 					Expect(err).NotTo(HaveOccurred())
 
 					Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
-					Expect(metricsSender.SendDurationCallCount()).To(Equal(2))
+					Expect(metricsSender.SendDurationCallCount()).To(Equal(7))
 				})
 			})
 
@@ -386,6 +523,101 @@ var _ = Describe("Single Poll Cycle", func() {
 			fakeASGPlanner.GetASGRulesAndChainsReturns(ASGRulesWithChain, nil)
 		})
 
+		Context("when the batch size is limited", func() {
+			enforcedChains := func() []string {
+				chains := []string{}
+				for i := 0; i < fakeEnforcer.EnforceRulesAndChainCallCount(); i++ {
+					chains = append(chains, fakeEnforcer.EnforceRulesAndChainArgsForCall(i).Chain.ParentChain)
+				}
+				return chains
+			}
+
+			BeforeEach(func() {
+				p.ASGSyncBatchSize = 2
+				fakeASGPlanner.GetASGRulesAndChainsReturns([]enforcer.RulesWithChain{
+					ASGRulesWithChain[2], ASGRulesWithChain[0], ASGRulesWithChain[1],
+				}, nil)
+			})
+
+			It("enforces the rules of the other containers in the next cycle", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(enforcedChains()).To(Equal([]string{"netout-1", "netout-2"}))
+				Expect(logger).To(gbytes.Say("asg-sync-batch-full.*batch_size.*2.*deferred.*1"))
+
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(enforcedChains()).To(Equal([]string{"netout-1", "netout-2", "netout-3"}))
+
+				_, desiredChains := fakeEnforcer.CleanChainsMatchingArgsForCall(0)
+				Expect(desiredChains).To(ConsistOf(
+					enforcer.LiveChain{Table: "filter", Name: "asg-1234-with-suffix"},
+					enforcer.LiveChain{Table: "filter", Name: "asg-2345-with-suffix"},
+					enforcer.LiveChain{Table: "filter", Name: ""},
+				))
+			})
+
+			It("continues after the last container of the previous cycle", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+
+				changed := []enforcer.RulesWithChain{}
+				for _, ruleSet := range ASGRulesWithChain {
+					ruleSet.Rules = []rules.IPTablesRule{[]string{"changed-rule"}}
+					changed = append(changed, ruleSet)
+				}
+				fakeASGPlanner.GetASGRulesAndChainsReturns(changed, nil)
+
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(enforcedChains()[2:]).To(Equal([]string{"netout-3", "netout-1"}))
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(enforcedChains()[4:]).To(Equal([]string{"netout-2"}))
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(enforcedChains()).To(HaveLen(5))
+			})
+
+			It("does not limit syncs forced for containers", func() {
+				Expect(p.SyncASGsForContainers("container-1", "container-2", "container-3")).To(Succeed())
+				Expect(enforcedChains()).To(HaveLen(3))
+			})
+		})
+
+		Context("when the policy cycle changes iptables at the same time", func() {
+			var unblockPolicies chan struct{}
+
+			BeforeEach(func() {
+				unblockPolicies = make(chan struct{})
+				fakeASGPlanner.GetPolicyRulesAndChainReturns(enforcer.RulesWithChain{
+					Rules: []rules.IPTablesRule{[]string{"policy-rule"}},
+					Chain: enforcer.Chain{
+						Table:       "filter",
+						ParentChain: "vpa--1",
+						Prefix:      "c2c",
+					},
+				}, nil)
+				fakeEnforcer.EnforceRulesAndChainStub = func(chain enforcer.RulesWithChain) (string, error) {
+					if chain.Chain.Prefix == "c2c" {
+						<-unblockPolicies
+					}
+					return fmt.Sprintf("%s-with-suffix", chain.Chain.Prefix), nil
+				}
+			})
+
+			It("waits for its turn and counts the overlap", func() {
+				policyErrs := make(chan error)
+				go func() { policyErrs <- p.DoPolicyCycle() }()
+				Eventually(fakeEnforcer.EnforceRulesAndChainCallCount).Should(Equal(1))
+
+				asgErrs := make(chan error)
+				go func() { asgErrs <- p.DoASGCycle() }()
+				Consistently(fakeEnforcer.EnforceRulesAndChainCallCount).Should(Equal(1))
+
+				close(unblockPolicies)
+				Eventually(policyErrs).Should(Receive(BeNil()))
+				Eventually(asgErrs).Should(Receive(BeNil()))
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(4))
+				Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+				Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("iptablesCycleOverlaps"))
+			})
+		})
+
 		It("enforces ASG rules on configured interval", func() {
 			err := p.DoASGCycle()
 			Expect(err).NotTo(HaveOccurred())
@@ -419,13 +651,190 @@ var _ = Describe("Single Poll Cycle", func() {
 		It("emits time metrics", func() {
 			err := p.DoASGCycle()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(metricsSender.SendDurationCallCount()).To(Equal(3))
+			Expect(metricsSender.SendDurationCallCount()).To(Equal(8))
 			name, _ := metricsSender.SendDurationArgsForCall(0)
 			Expect(name).To(Equal("asgIptablesEnforceTime"))
 			name, _ = metricsSender.SendDurationArgsForCall(1)
 			Expect(name).To(Equal("asgIptablesCleanupTime"))
 			name, _ = metricsSender.SendDurationArgsForCall(2)
 			Expect(name).To(Equal("asgTotalPollTime"))
+			name, _ = metricsSender.SendDurationArgsForCall(3)
+			Expect(name).To(Equal("asgCyclePlanTime"))
+			name, _ = metricsSender.SendDurationArgsForCall(4)
+			Expect(name).To(Equal("asgCycleDiffTime"))
+			name, _ = metricsSender.SendDurationArgsForCall(5)
+			Expect(name).To(Equal("asgCycleApplyTime"))
+			name, _ = metricsSender.SendDurationArgsForCall(6)
+			Expect(name).To(Equal("asgCycleCleanupTime"))
+			name, _ = metricsSender.SendDurationArgsForCall(7)
+			Expect(name).To(Equal("asgCycleIptablesWaitTime"))
+		})
+
+		Context("when a plan dumper is set", func() {
+			var planDumper *fakes.PlanDumper
+
+			BeforeEach(func() {
+				planDumper = &fakes.PlanDumper{}
+				p.PlanDumper = planDumper
+			})
+
+			It("dumps the rule sets of the polling cycle", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(planDumper.WriteCallCount()).To(Equal(1))
+				cycle, ruleSets := planDumper.WriteArgsForCall(0)
+				Expect(cycle).To(Equal("asg"))
+				Expect(ruleSets).To(Equal(ASGRulesWithChain))
+			})
+
+			It("does not dump the syncs of single containers", func() {
+				Expect(p.SyncASGsForContainers("container-1")).To(Succeed())
+				Expect(planDumper.WriteCallCount()).To(Equal(0))
+			})
+
+			Context("when dumping fails", func() {
+				BeforeEach(func() {
+					planDumper.WriteReturns(errors.New("banana"))
+				})
+
+				It("logs the error", func() {
+					Expect(p.DoASGCycle()).To(Succeed())
+					Expect(logger).To(gbytes.Say("dump-plan.*banana"))
+				})
+			})
+		})
+
+		Context("when an enforcement delay is set", func() {
+			var enforcementDelay *fakes.EnforcementDelay
+
+			BeforeEach(func() {
+				enforcementDelay = &fakes.EnforcementDelay{}
+				enforcementDelay.EnforcementDelayReturns(20 * time.Millisecond)
+				p.EnforcementDelay = enforcementDelay
+			})
+
+			It("delays the cycle once before enforcing", func() {
+				start := time.Now()
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+				Expect(enforcementDelay.EnforcementDelayCallCount()).To(Equal(1))
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(len(ASGRulesWithChain)))
+			})
+		})
+
+		Context("when an ASG chain store is set", func() {
+			var asgChainStore *fakes.ASGChainStore
+
+			BeforeEach(func() {
+				asgChainStore = &fakes.ASGChainStore{}
+				p.ASGChainStore = asgChainStore
+			})
+
+			It("saves the applied chains", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(asgChainStore.SaveCallCount()).To(Equal(1))
+				Expect(asgChainStore.SaveArgsForCall(0)).To(Equal([]datastore.ASGChain{
+					{Table: "filter", ParentChain: "netout-1", Chain: "asg-1234-with-suffix"},
+					{Table: "filter", ParentChain: "netout-2", Chain: "asg-2345-with-suffix"},
+					{Table: "filter", ParentChain: "netout-3", Chain: "asg-3456-with-suffix"},
+				}))
+			})
+
+			It("does not save the chains again when they have not changed", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(asgChainStore.SaveCallCount()).To(Equal(1))
+			})
+
+			It("saves the chains after orphaned chains of a container are cleaned up", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				fakeEnforcer.CleanChainsMatchingReturns([]enforcer.LiveChain{{Table: "filter", Name: "asg-2345-with-suffix"}}, nil)
+
+				Expect(p.CleanupOrphanedASGsChains("some-container-handle")).To(Succeed())
+				Expect(asgChainStore.SaveCallCount()).To(Equal(2))
+				Expect(asgChainStore.SaveArgsForCall(1)).To(Equal([]datastore.ASGChain{
+					{Table: "filter", ParentChain: "netout-1", Chain: "asg-1234-with-suffix"},
+					{Table: "filter", ParentChain: "netout-3", Chain: "asg-3456-with-suffix"},
+				}))
+			})
+
+			Context("when saving fails", func() {
+				BeforeEach(func() {
+					asgChainStore.SaveReturns(errors.New("banana"))
+				})
+
+				It("logs the error and retries on the next cycle", func() {
+					Expect(p.DoASGCycle()).To(Succeed())
+					Expect(logger).To(gbytes.Say("persist-asg-chains.*banana"))
+
+					Expect(p.DoASGCycle()).To(Succeed())
+					Expect(asgChainStore.SaveCallCount()).To(Equal(2))
+				})
+			})
+		})
+
+		Context("when an enforcement status store is set", func() {
+			var statusStore *fakes.EnforcementStatusStore
+
+			BeforeEach(func() {
+				statusStore = &fakes.EnforcementStatusStore{}
+				p.EnforcementStatusStore = statusStore
+
+				for i := range ASGRulesWithChain {
+					ASGRulesWithChain[i].Handle = fmt.Sprintf("container-%d", i+1)
+				}
+				fakeASGPlanner.GetASGRulesAndChainsReturns(ASGRulesWithChain, nil)
+			})
+
+			It("saves the results of the enforcements in the datastore", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(statusStore.SaveEnforcementStatusesCallCount()).To(Equal(1))
+
+				statuses := statusStore.SaveEnforcementStatusesArgsForCall(0)
+				Expect(statuses).To(HaveLen(3))
+				Expect(statuses["container-1"].Chain).To(Equal("asg-1234-with-suffix"))
+				Expect(statuses["container-1"].RulesHash).To(Equal(ASGRulesWithChain[0].Hash()))
+				Expect(statuses["container-1"].EnforcedAt).To(BeTemporally("~", time.Now(), time.Minute))
+				Expect(statuses["container-1"].Error).To(BeEmpty())
+				Expect(statuses["container-3"].Chain).To(Equal("asg-3456-with-suffix"))
+			})
+
+			It("does not save the statuses again when nothing was enforced", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(statusStore.SaveEnforcementStatusesCallCount()).To(Equal(1))
+			})
+
+			Context("when an enforcement fails", func() {
+				BeforeEach(func() {
+					fakeEnforcer.EnforceRulesAndChainStub = func(chain enforcer.RulesWithChain) (string, error) {
+						if chain.Handle == "container-2" {
+							return "", errors.New("banana")
+						}
+						return fmt.Sprintf("%s-with-suffix", chain.Chain.Prefix), nil
+					}
+				})
+
+				It("saves the error with the container", func() {
+					Expect(p.DoASGCycle()).To(MatchError(ContainSubstring("banana")))
+
+					statuses := statusStore.SaveEnforcementStatusesArgsForCall(0)
+					Expect(statuses["container-2"].Error).To(Equal("banana"))
+					Expect(statuses["container-2"].Chain).To(BeEmpty())
+					Expect(statuses["container-2"].RulesHash).To(Equal(ASGRulesWithChain[1].Hash()))
+					Expect(statuses["container-1"].Error).To(BeEmpty())
+				})
+			})
+
+			Context("when saving fails", func() {
+				BeforeEach(func() {
+					statusStore.SaveEnforcementStatusesReturns(errors.New("banana"))
+				})
+
+				It("logs the error", func() {
+					Expect(p.DoASGCycle()).To(Succeed())
+					Expect(logger).To(gbytes.Say("persist-enforcement-statuses.*banana"))
+				})
+			})
 		})
 
 		Context("when a ruleset has not changed since the last poll cycle", func() {
@@ -448,6 +857,63 @@ var _ = Describe("Single Poll Cycle", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(fakeMetronClient.SendAppLogCallCount()).To(Equal(3))
 			})
+
+			It("does not check the parent chains for duplicate jumps again", func() {
+				err := p.DoASGCycle()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeEnforcer.RepairDuplicateJumpsCallCount()).To(Equal(3))
+			})
+		})
+
+		It("checks the parent chains for duplicate jumps after enforcing them", func() {
+			err := p.DoASGCycle()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeEnforcer.RepairDuplicateJumpsCallCount()).To(Equal(3))
+			Expect(fakeEnforcer.RepairDuplicateJumpsArgsForCall(1)).To(Equal(ASGRulesWithChain[1].Chain))
+		})
+
+		It("does not check for duplicate jumps when syncing specific containers", func() {
+			err := p.SyncASGsForContainers("container-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
+			Expect(fakeEnforcer.RepairDuplicateJumpsCallCount()).To(Equal(0))
+		})
+
+		Context("when duplicate jumps are repaired", func() {
+			BeforeEach(func() {
+				fakeEnforcer.RepairDuplicateJumpsStub = func(chain enforcer.Chain) (int, error) {
+					if chain == ASGRulesWithChain[1].Chain {
+						return 2, nil
+					}
+					return 0, nil
+				}
+			})
+
+			It("emits a metric and enforces the chain again on the next cycle", func() {
+				err := p.DoASGCycle()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+				Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("iptablesDuplicateJumpsRepaired"))
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
+
+				fakeEnforcer.RepairDuplicateJumpsStub = nil
+				err = p.DoASGCycle()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(4))
+				Expect(fakeEnforcer.EnforceRulesAndChainArgsForCall(3)).To(Equal(ASGRulesWithChain[1]))
+			})
+		})
+
+		Context("when repairing duplicate jumps fails", func() {
+			BeforeEach(func() {
+				fakeEnforcer.RepairDuplicateJumpsReturns(0, errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				err := p.DoASGCycle()
+				Expect(err).To(MatchError(ContainSubstring("repair-duplicate-jumps: banana")))
+				Expect(fakeEnforcer.CleanChainsMatchingCallCount()).To(Equal(1))
+			})
 		})
 
 		Context("when a ruleset has changed since the last poll cycle", func() {
@@ -470,7 +936,7 @@ var _ = Describe("Single Poll Cycle", func() {
 			It("logs a message about writing ip tables rules", func() {
 				err := p.DoASGCycle()
 				Expect(err).NotTo(HaveOccurred())
-				Expect(logger).To(gbytes.Say("poll-cycle.*updating iptables rules.*new rules.*new-rule.*num new rules.*1.*num old rules.*1.*old rules.*asg-rule"))
+				Expect(logger).To(gbytes.Say(`poll-cycle-asg.*"log_level":1.*"added":1,"added examples":\["new-rule"\],"chain":"[^"]*","message":"updating iptables rules","num new rules":1,"num old rules":1,"removed":1,"removed examples":\["asg-rule1"\]`))
 			})
 
 			It("sends app logs", func() {
@@ -573,7 +1039,7 @@ var _ = Describe("Single Poll Cycle", func() {
 					errors := multiErr.WrappedErrors()
 					Expect(errors).To(HaveLen(1))
 					Expect(errors[0]).To(MatchError("clean-up-orphaned-asg-chains: eggplant"))
-					Expect(metricsSender.SendDurationCallCount()).To(Equal(metricsCount + 3))
+					Expect(metricsSender.SendDurationCallCount()).To(Equal(metricsCount + 8))
 				})
 			})
 		})
@@ -609,7 +1075,7 @@ var _ = Describe("Single Poll Cycle", func() {
 
 				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
 				Expect(fakeEnforcer.CleanChainsMatchingCallCount()).To(Equal(1))
-				Expect(metricsSender.SendDurationCallCount()).To(Equal(3))
+				Expect(metricsSender.SendDurationCallCount()).To(Equal(8))
 			})
 		})
 
@@ -691,7 +1157,7 @@ var _ = Describe("Single Poll Cycle", func() {
 					Expect(errors[0]).To(MatchError("enforce-asg: cleaning up: zucchini"))
 					Expect(errors[1]).To(MatchError("enforce-asg: cleaning up: zucchini"))
 
-					Expect(metricsSender.SendDurationCallCount()).To(Equal(6))
+					Expect(metricsSender.SendDurationCallCount()).To(Equal(16))
 				})
 
 				It("does not try to update the rules again", func() {
@@ -754,7 +1220,7 @@ var _ = Describe("Single Poll Cycle", func() {
 					Expect(errors[0]).To(MatchError("enforce-asg: eggplant"))
 					Expect(errors[1]).To(MatchError("enforce-asg: eggplant"))
 
-					Expect(metricsSender.SendDurationCallCount()).To(Equal(6))
+					Expect(metricsSender.SendDurationCallCount()).To(Equal(16))
 				})
 
 				It("tries to update the rules again", func() {
@@ -891,6 +1357,91 @@ var _ = Describe("Single Poll Cycle", func() {
 			})
 		})
 
+		Describe("CacheSizes", func() {
+			It("reports empty caches before the first cycle", func() {
+				Expect(p.CacheSizes()).To(Equal(map[string]int{
+					"policy_rule_sets":     0,
+					"policy_rules":         0,
+					"asg_rule_sets":        0,
+					"asg_rules":            0,
+					"container_asg_chains": 0,
+				}))
+			})
+
+			It("reports the applied ASG plans and chains", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				sizes := p.CacheSizes()
+				Expect(sizes["asg_rule_sets"]).To(Equal(3))
+				Expect(sizes["asg_rules"]).To(Equal(3))
+				Expect(sizes["container_asg_chains"]).To(Equal(3))
+			})
+		})
+
+		Describe("ResetCaches", func() {
+			It("enforces the unchanged rule sets again in the next cycles", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
+
+				p.ResetCaches()
+				Expect(p.CacheSizes()["asg_rule_sets"]).To(Equal(0))
+
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(6))
+			})
+		})
+
+		Describe("pausing ASG syncing", func() {
+			BeforeEach(func() {
+				fakeASGPlanner.GetASGRulesAndChainsReturnsOnCall(0, ASGRulesWithChain[:2], nil)
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(2))
+
+				p.PauseASGSyncing()
+				ASGRulesWithChain[0].Rules = []rules.IPTablesRule{[]string{"changed-rule"}}
+			})
+
+			It("reports the paused state", func() {
+				since, paused := p.ASGSyncingPausedSince()
+				Expect(paused).To(BeTrue())
+				Expect(since).To(BeTemporally("~", time.Now(), time.Minute))
+				Expect(logger).To(gbytes.Say("asg-syncing-paused"))
+			})
+
+			It("skips the polling cycle entirely", func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				Expect(fakeASGPlanner.GetASGRulesAndChainsCallCount()).To(Equal(1))
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(2))
+				Expect(fakeEnforcer.CleanChainsMatchingCallCount()).To(Equal(1))
+			})
+
+			It("keeps the last applied chains of existing containers but syncs new containers", func() {
+				Expect(p.SyncASGsForContainers("container-1", "container-3")).To(Succeed())
+				Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(3))
+				Expect(fakeEnforcer.EnforceRulesAndChainArgsForCall(2)).To(Equal(ASGRulesWithChain[2]))
+			})
+
+			It("still cleans up chains of deleted containers", func() {
+				Expect(p.CleanupOrphanedASGsChains("some-container-handle")).To(Succeed())
+				Expect(fakeEnforcer.CleanChainsMatchingCallCount()).To(Equal(2))
+			})
+
+			Context("when resumed", func() {
+				BeforeEach(func() {
+					p.ResumeASGSyncing()
+				})
+
+				It("syncs changed rules again", func() {
+					_, paused := p.ASGSyncingPausedSince()
+					Expect(paused).To(BeFalse())
+
+					Expect(p.DoASGCycle()).To(Succeed())
+					Expect(fakeEnforcer.EnforceRulesAndChainCallCount()).To(Equal(4))
+					Expect(fakeEnforcer.EnforceRulesAndChainArgsForCall(2)).To(Equal(ASGRulesWithChain[0]))
+					Expect(logger).To(gbytes.Say("asg-syncing-resumed"))
+				})
+			})
+		})
+
 		Describe("CleanupOrphanedASGsChains", func() {
 			It("cleans up asg chains with no desired chains", func() {
 				err := p.CleanupOrphanedASGsChains("some-container-handle")
@@ -912,6 +1463,20 @@ var _ = Describe("Single Poll Cycle", func() {
 				})
 			})
 		})
+
+		Describe("CleanupASGsChainsForContainer", func() {
+			BeforeEach(func() {
+				Expect(p.DoASGCycle()).To(Succeed())
+				fakeEnforcer.CleanChainsMatchingReturns([]enforcer.LiveChain{{Table: "filter", Name: "asg-2345-with-suffix"}}, nil)
+			})
+
+			It("returns the deleted chains", func() {
+				deletedChains, err := p.CleanupASGsChainsForContainer("some-container-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(deletedChains).To(Equal([]enforcer.LiveChain{{Table: "filter", Name: "asg-2345-with-suffix"}}))
+				Expect(p.CurrentlyAppliedChainNames()).To(ConsistOf("asg-1234-with-suffix", "asg-3456-with-suffix"))
+			})
+		})
 	})
 })
 
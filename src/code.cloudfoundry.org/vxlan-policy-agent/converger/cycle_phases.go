@@ -0,0 +1,38 @@
+package converger
+
+import "time"
+
+// cyclePhases adds up how long a poll cycle spends in each of its phases, so
+// that a slow cycle can be told apart as slow planning, which includes the
+// requests to the policy server, or as slow iptables:
+//
+//   - plan: getting the rules from the planners
+//   - diff: comparing the rules with the rules that were enforced last
+//   - apply: enforcing changed rules in iptables
+//   - cleanup: repairing jumps and removing chains that are no longer needed
+//   - wait: waiting for the other cycle to finish changing iptables
+type cyclePhases struct {
+	plan    time.Duration
+	diff    time.Duration
+	apply   time.Duration
+	cleanup time.Duration
+	wait    time.Duration
+}
+
+// since adds the time since start to a phase and returns the current time,
+// which starts the next phase.
+func since(phase *time.Duration, start time.Time) time.Time {
+	now := time.Now()
+	*phase += now.Sub(start)
+	return now
+}
+
+// send emits the duration of every phase as a metric named after the prefix
+// and the phase, e.g. policyCyclePlanTime.
+func (p cyclePhases) send(sender metricsSender, prefix string) {
+	sender.SendDuration(prefix+"PlanTime", p.plan)
+	sender.SendDuration(prefix+"DiffTime", p.diff)
+	sender.SendDuration(prefix+"ApplyTime", p.apply)
+	sender.SendDuration(prefix+"CleanupTime", p.cleanup)
+	sender.SendDuration(prefix+"IptablesWaitTime", p.wait)
+}
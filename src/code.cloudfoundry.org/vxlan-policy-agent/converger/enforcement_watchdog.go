@@ -0,0 +1,115 @@
+package converger
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+const metricEnforcementTimeouts = "iptablesEnforcementTimeouts"
+
+// enforcementGracePeriod is how long the watchdog waits for a timed out
+// enforcement to return after its processes were killed.
+const enforcementGracePeriod = 5 * time.Second
+
+//go:generate counterfeiter -o fakes/context_rule_enforcer.go --fake-name ContextRuleEnforcer . contextRuleEnforcer
+type contextRuleEnforcer interface {
+	EnforceRulesAndChainContext(context.Context, enforcer.RulesWithChain) (string, error)
+	CleanChainsMatchingContext(ctx context.Context, regex *regexp.Regexp, desiredChains []enforcer.LiveChain) ([]enforcer.LiveChain, error)
+	RepairDuplicateJumpsContext(context.Context, enforcer.Chain) (int, error)
+}
+
+//go:generate counterfeiter -o fakes/stuck_processes.go --fake-name StuckProcesses . stuckProcesses
+type stuckProcesses interface {
+	Diagnose() lager.Data
+	Kill() ([]int, error)
+}
+
+// EnforcementWatchdog fails the calls to the enforcer that take longer than
+// the timeout, e.g. because an iptables process is wedged, instead of letting
+// them hang the poll cycles forever. The calls are made with a context that
+// is done after the timeout and names the operation of the iptables calls,
+// so that the enforcer starts no more iptables calls once a call timed out.
+// It logs who holds the iptables locks and the tail of the kernel log, and
+// kills the stuck iptables processes, so that the enforcer returns and
+// releases the iptables lock for the next cycle. A timeout of 0 disables the
+// watchdog.
+type EnforcementWatchdog struct {
+	Enforcer       contextRuleEnforcer
+	Timeout        time.Duration
+	StuckProcesses stuckProcesses
+	MetricsSender  metricsSender
+	Logger         lager.Logger
+}
+
+func (w *EnforcementWatchdog) EnforceRulesAndChain(rulesAndChain enforcer.RulesWithChain) (string, error) {
+	return watch(w, "enforce-rules-and-chain", func(ctx context.Context) (string, error) {
+		return w.Enforcer.EnforceRulesAndChainContext(ctx, rulesAndChain)
+	})
+}
+
+func (w *EnforcementWatchdog) CleanChainsMatching(regex *regexp.Regexp, desiredChains []enforcer.LiveChain) ([]enforcer.LiveChain, error) {
+	return watch(w, "clean-chains-matching", func(ctx context.Context) ([]enforcer.LiveChain, error) {
+		return w.Enforcer.CleanChainsMatchingContext(ctx, regex, desiredChains)
+	})
+}
+
+func (w *EnforcementWatchdog) RepairDuplicateJumps(chain enforcer.Chain) (int, error) {
+	return watch(w, "repair-duplicate-jumps", func(ctx context.Context) (int, error) {
+		return w.Enforcer.RepairDuplicateJumpsContext(ctx, chain)
+	})
+}
+
+type watchedResult[T any] struct {
+	value T
+	err   error
+}
+
+func watch[T any](w *EnforcementWatchdog, operation string, call func(ctx context.Context) (T, error)) (T, error) {
+	ctx := rules.WithOperation(context.Background(), operation)
+	if w.Timeout <= 0 {
+		return call(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+	done := make(chan watchedResult[T], 1)
+	go func() {
+		value, err := call(ctx)
+		done <- watchedResult[T]{value: value, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-ctx.Done():
+	}
+
+	data := w.StuckProcesses.Diagnose()
+	if data == nil {
+		data = lager.Data{}
+	}
+	data["operation"] = operation
+	data["timeout"] = w.Timeout.String()
+	killed, err := w.StuckProcesses.Kill()
+	data["killed_pids"] = killed
+	if err != nil {
+		data["kill_error"] = err.Error()
+	}
+	w.Logger.Error("enforcement-timed-out", fmt.Errorf("%s timed out", operation), data)
+	w.MetricsSender.IncrementCounter(metricEnforcementTimeouts)
+
+	select {
+	case <-done:
+	case <-time.After(enforcementGracePeriod):
+		w.Logger.Info("enforcement-still-running", lager.Data{"operation": operation})
+	}
+
+	var zero T
+	return zero, fmt.Errorf("%s: timed out after %s", operation, w.Timeout)
+}
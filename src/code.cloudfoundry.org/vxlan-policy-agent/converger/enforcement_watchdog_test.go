@@ -0,0 +1,150 @@
+package converger_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("EnforcementWatchdog", func() {
+	var (
+		watchdog       *converger.EnforcementWatchdog
+		fakeEnforcer   *fakes.ContextRuleEnforcer
+		stuckProcesses *fakes.StuckProcesses
+		metricsSender  *fakes.MetricsSender
+		logger         *lagertest.TestLogger
+		rulesWithChain enforcer.RulesWithChain
+	)
+
+	BeforeEach(func() {
+		fakeEnforcer = &fakes.ContextRuleEnforcer{}
+		stuckProcesses = &fakes.StuckProcesses{}
+		metricsSender = &fakes.MetricsSender{}
+		logger = lagertest.NewTestLogger("test")
+		rulesWithChain = enforcer.RulesWithChain{
+			Chain: enforcer.Chain{Table: "filter", ParentChain: "netout-1", Prefix: "asg-1234"},
+		}
+
+		watchdog = &converger.EnforcementWatchdog{
+			Enforcer:       fakeEnforcer,
+			Timeout:        50 * time.Millisecond,
+			StuckProcesses: stuckProcesses,
+			MetricsSender:  metricsSender,
+			Logger:         logger,
+		}
+	})
+
+	Context("when the enforcer returns in time", func() {
+		BeforeEach(func() {
+			fakeEnforcer.EnforceRulesAndChainContextReturns("asg-1234-chain", errors.New("banana"))
+			fakeEnforcer.RepairDuplicateJumpsContextReturns(2, nil)
+		})
+
+		It("returns what the enforcer returned", func() {
+			chain, err := watchdog.EnforceRulesAndChain(rulesWithChain)
+			Expect(err).To(MatchError("banana"))
+			Expect(chain).To(Equal("asg-1234-chain"))
+			ctx, enforced := fakeEnforcer.EnforceRulesAndChainContextArgsForCall(0)
+			Expect(enforced).To(Equal(rulesWithChain))
+			Expect(rules.Operation(ctx)).To(Equal("enforce-rules-and-chain"))
+			_, hasDeadline := ctx.Deadline()
+			Expect(hasDeadline).To(BeTrue())
+
+			removed, err := watchdog.RepairDuplicateJumps(rulesWithChain.Chain)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(2))
+
+			Expect(stuckProcesses.DiagnoseCallCount()).To(Equal(0))
+			Expect(stuckProcesses.KillCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the enforcer does not return in time", func() {
+		var unblock chan struct{}
+
+		BeforeEach(func() {
+			unblock = make(chan struct{})
+			fakeEnforcer.EnforceRulesAndChainContextStub = func(ctx context.Context, _ enforcer.RulesWithChain) (string, error) {
+				<-unblock
+				return "", ctx.Err()
+			}
+			stuckProcesses.DiagnoseReturns(lager.Data{"lock_holders": []string{"/var/vcap/data/lock: iptables -L (pid 42)"}})
+			stuckProcesses.KillStub = func() ([]int, error) {
+				close(unblock)
+				return []int{1234}, nil
+			}
+		})
+
+		It("kills the stuck processes and fails the call", func() {
+			_, err := watchdog.EnforceRulesAndChain(rulesWithChain)
+			Expect(err).To(MatchError("enforce-rules-and-chain: timed out after 50ms"))
+			Expect(stuckProcesses.KillCallCount()).To(Equal(1))
+		})
+
+		It("ends the context of the call, so that the enforcer starts no more iptables calls", func() {
+			watchdog.EnforceRulesAndChain(rulesWithChain)
+			ctx, _ := fakeEnforcer.EnforceRulesAndChainContextArgsForCall(0)
+			Expect(ctx.Err()).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("logs the diagnostics", func() {
+			watchdog.EnforceRulesAndChain(rulesWithChain)
+			Expect(logger).To(gbytes.Say(`enforcement-timed-out.*enforce-rules-and-chain timed out.*"killed_pids":\[1234\].*"lock_holders":\["/var/vcap/data/lock: iptables -L \(pid 42\)"\].*"operation":"enforce-rules-and-chain".*"timeout":"50ms"`))
+		})
+
+		It("counts the timeout", func() {
+			watchdog.EnforceRulesAndChain(rulesWithChain)
+			Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+			Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("iptablesEnforcementTimeouts"))
+		})
+
+		Context("when killing the processes fails", func() {
+			BeforeEach(func() {
+				stuckProcesses.KillStub = func() ([]int, error) {
+					close(unblock)
+					return []int{}, errors.New("operation not permitted")
+				}
+			})
+
+			It("logs the error and still fails the call", func() {
+				_, err := watchdog.EnforceRulesAndChain(rulesWithChain)
+				Expect(err).To(MatchError("enforce-rules-and-chain: timed out after 50ms"))
+				Expect(logger).To(gbytes.Say(`"kill_error":"operation not permitted"`))
+			})
+		})
+	})
+
+	Context("when the timeout is 0", func() {
+		BeforeEach(func() {
+			watchdog.Timeout = 0
+			fakeEnforcer.CleanChainsMatchingContextStub = func(context.Context, *regexp.Regexp, []enforcer.LiveChain) ([]enforcer.LiveChain, error) {
+				time.Sleep(10 * time.Millisecond)
+				return []enforcer.LiveChain{{Table: "filter", Name: "asg-1234"}}, nil
+			}
+		})
+
+		It("waits for the enforcer", func() {
+			deleted, err := watchdog.CleanChainsMatching(regexp.MustCompile("asg-"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted).To(Equal([]enforcer.LiveChain{{Table: "filter", Name: "asg-1234"}}))
+			Expect(stuckProcesses.KillCallCount()).To(Equal(0))
+
+			ctx, _, _ := fakeEnforcer.CleanChainsMatchingContextArgsForCall(0)
+			Expect(rules.Operation(ctx)).To(Equal("clean-chains-matching"))
+			_, hasDeadline := ctx.Deadline()
+			Expect(hasDeadline).To(BeFalse())
+		})
+	})
+})
@@ -7,6 +7,11 @@ import (
 )
 
 type MetricsSender struct {
+	IncrementCounterStub        func(string)
+	incrementCounterMutex       sync.RWMutex
+	incrementCounterArgsForCall []struct {
+		arg1 string
+	}
 	SendDurationStub        func(string, time.Duration)
 	sendDurationMutex       sync.RWMutex
 	sendDurationArgsForCall []struct {
@@ -17,6 +22,38 @@ type MetricsSender struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *MetricsSender) IncrementCounter(arg1 string) {
+	fake.incrementCounterMutex.Lock()
+	fake.incrementCounterArgsForCall = append(fake.incrementCounterArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.IncrementCounterStub
+	fake.recordInvocation("IncrementCounter", []interface{}{arg1})
+	fake.incrementCounterMutex.Unlock()
+	if stub != nil {
+		fake.IncrementCounterStub(arg1)
+	}
+}
+
+func (fake *MetricsSender) IncrementCounterCallCount() int {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	return len(fake.incrementCounterArgsForCall)
+}
+
+func (fake *MetricsSender) IncrementCounterCalls(stub func(string)) {
+	fake.incrementCounterMutex.Lock()
+	defer fake.incrementCounterMutex.Unlock()
+	fake.IncrementCounterStub = stub
+}
+
+func (fake *MetricsSender) IncrementCounterArgsForCall(i int) string {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	argsForCall := fake.incrementCounterArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *MetricsSender) SendDuration(arg1 string, arg2 time.Duration) {
 	fake.sendDurationMutex.Lock()
 	fake.sendDurationArgsForCall = append(fake.sendDurationArgsForCall, struct {
@@ -53,6 +90,8 @@ func (fake *MetricsSender) SendDurationArgsForCall(i int) (string, time.Duration
 func (fake *MetricsSender) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
 	fake.sendDurationMutex.RLock()
 	defer fake.sendDurationMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
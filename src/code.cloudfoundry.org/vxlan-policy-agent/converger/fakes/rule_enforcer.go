@@ -36,6 +36,19 @@ type RuleEnforcer struct {
 		result1 string
 		result2 error
 	}
+	RepairDuplicateJumpsStub        func(enforcer.Chain) (int, error)
+	repairDuplicateJumpsMutex       sync.RWMutex
+	repairDuplicateJumpsArgsForCall []struct {
+		arg1 enforcer.Chain
+	}
+	repairDuplicateJumpsReturns struct {
+		result1 int
+		result2 error
+	}
+	repairDuplicateJumpsReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -174,6 +187,70 @@ func (fake *RuleEnforcer) EnforceRulesAndChainReturnsOnCall(i int, result1 strin
 	}{result1, result2}
 }
 
+func (fake *RuleEnforcer) RepairDuplicateJumps(arg1 enforcer.Chain) (int, error) {
+	fake.repairDuplicateJumpsMutex.Lock()
+	ret, specificReturn := fake.repairDuplicateJumpsReturnsOnCall[len(fake.repairDuplicateJumpsArgsForCall)]
+	fake.repairDuplicateJumpsArgsForCall = append(fake.repairDuplicateJumpsArgsForCall, struct {
+		arg1 enforcer.Chain
+	}{arg1})
+	stub := fake.RepairDuplicateJumpsStub
+	fakeReturns := fake.repairDuplicateJumpsReturns
+	fake.recordInvocation("RepairDuplicateJumps", []interface{}{arg1})
+	fake.repairDuplicateJumpsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *RuleEnforcer) RepairDuplicateJumpsCallCount() int {
+	fake.repairDuplicateJumpsMutex.RLock()
+	defer fake.repairDuplicateJumpsMutex.RUnlock()
+	return len(fake.repairDuplicateJumpsArgsForCall)
+}
+
+func (fake *RuleEnforcer) RepairDuplicateJumpsCalls(stub func(enforcer.Chain) (int, error)) {
+	fake.repairDuplicateJumpsMutex.Lock()
+	defer fake.repairDuplicateJumpsMutex.Unlock()
+	fake.RepairDuplicateJumpsStub = stub
+}
+
+func (fake *RuleEnforcer) RepairDuplicateJumpsArgsForCall(i int) enforcer.Chain {
+	fake.repairDuplicateJumpsMutex.RLock()
+	defer fake.repairDuplicateJumpsMutex.RUnlock()
+	argsForCall := fake.repairDuplicateJumpsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *RuleEnforcer) RepairDuplicateJumpsReturns(result1 int, result2 error) {
+	fake.repairDuplicateJumpsMutex.Lock()
+	defer fake.repairDuplicateJumpsMutex.Unlock()
+	fake.RepairDuplicateJumpsStub = nil
+	fake.repairDuplicateJumpsReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *RuleEnforcer) RepairDuplicateJumpsReturnsOnCall(i int, result1 int, result2 error) {
+	fake.repairDuplicateJumpsMutex.Lock()
+	defer fake.repairDuplicateJumpsMutex.Unlock()
+	fake.RepairDuplicateJumpsStub = nil
+	if fake.repairDuplicateJumpsReturnsOnCall == nil {
+		fake.repairDuplicateJumpsReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.repairDuplicateJumpsReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *RuleEnforcer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -181,6 +258,8 @@ func (fake *RuleEnforcer) Invocations() map[string][][]interface{} {
 	defer fake.cleanChainsMatchingMutex.RUnlock()
 	fake.enforceRulesAndChainMutex.RLock()
 	defer fake.enforceRulesAndChainMutex.RUnlock()
+	fake.repairDuplicateJumpsMutex.RLock()
+	defer fake.repairDuplicateJumpsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,54 @@
+package converger
+
+import "sync"
+
+// IPTablesScheduler takes turns between the policy and the ASG cycle in their
+// phases that change iptables, so that the cycles do not contend for the
+// xtables lock at the same time. Turns are handed out in the order they were
+// asked for, so that a long ASG cycle cannot starve the policy cycle and the
+// other way round: a cycle waits for at most the turns that were already
+// queued.
+type IPTablesScheduler struct {
+	mutex   sync.Mutex
+	busy    bool
+	waiting []chan struct{}
+}
+
+// Acquire waits for the turn of the caller, and reports whether it had to wait
+// for another cycle.
+func (s *IPTablesScheduler) Acquire() bool {
+	s.mutex.Lock()
+	if !s.busy {
+		s.busy = true
+		s.mutex.Unlock()
+		return false
+	}
+	turn := make(chan struct{})
+	s.waiting = append(s.waiting, turn)
+	s.mutex.Unlock()
+
+	<-turn
+	return true
+}
+
+// Release ends the turn of the caller and hands it to the longest waiting
+// cycle.
+func (s *IPTablesScheduler) Release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.waiting) == 0 {
+		s.busy = false
+		return
+	}
+	next := s.waiting[0]
+	s.waiting = s.waiting[1:]
+	close(next)
+}
+
+// Waiting returns how many cycles wait for their turn.
+func (s *IPTablesScheduler) Waiting() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.waiting)
+}
@@ -0,0 +1,56 @@
+package converger_test
+
+import (
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IPTablesScheduler", func() {
+	var scheduler *converger.IPTablesScheduler
+
+	BeforeEach(func() {
+		scheduler = &converger.IPTablesScheduler{}
+	})
+
+	It("gives a free turn right away", func() {
+		Expect(scheduler.Acquire()).To(BeFalse())
+		scheduler.Release()
+		Expect(scheduler.Acquire()).To(BeFalse())
+	})
+
+	It("makes a cycle wait until the turn is released", func() {
+		Expect(scheduler.Acquire()).To(BeFalse())
+
+		waited := make(chan bool)
+		go func() { waited <- scheduler.Acquire() }()
+		Eventually(scheduler.Waiting).Should(Equal(1))
+		Consistently(waited).ShouldNot(Receive())
+
+		scheduler.Release()
+		Eventually(waited).Should(Receive(BeTrue()))
+		Expect(scheduler.Waiting()).To(Equal(0))
+	})
+
+	It("hands out the turns in the order they were asked for", func() {
+		Expect(scheduler.Acquire()).To(BeFalse())
+
+		turns := make(chan string, 2)
+		for _, cycle := range []string{"asg", "policy"} {
+			waiting := scheduler.Waiting()
+			go func(cycle string) {
+				scheduler.Acquire()
+				turns <- cycle
+			}(cycle)
+			Eventually(scheduler.Waiting).Should(Equal(waiting + 1))
+		}
+
+		scheduler.Release()
+		Eventually(turns).Should(Receive(Equal("asg")))
+		Consistently(turns).ShouldNot(Receive())
+
+		scheduler.Release()
+		Eventually(turns).Should(Receive(Equal("policy")))
+	})
+})
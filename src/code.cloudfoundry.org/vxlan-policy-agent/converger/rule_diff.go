@@ -0,0 +1,22 @@
+package converger
+
+import (
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+// RuleDiff describes the change between two rule sets for the log line of a
+// cycle that enforces the new rule set.
+func RuleDiff(oldRuleSet, newRuleSet enforcer.RulesWithChain) lager.Data {
+	diff := enforcer.DiffRuleSets(oldRuleSet, newRuleSet)
+	return lager.Data{
+		"message":          "updating iptables rules",
+		"chain":            newRuleSet.Chain.ParentChain,
+		"num old rules":    diff.OldRules,
+		"num new rules":    diff.NewRules,
+		"added":            diff.Added,
+		"removed":          diff.Removed,
+		"added examples":   diff.AddedExamples,
+		"removed examples": diff.RemovedExamples,
+	}
+}
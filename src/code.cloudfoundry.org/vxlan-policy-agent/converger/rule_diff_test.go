@@ -0,0 +1,63 @@
+package converger_test
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RuleDiff", func() {
+	var oldRuleSet, newRuleSet enforcer.RulesWithChain
+
+	BeforeEach(func() {
+		oldRuleSet = enforcer.RulesWithChain{
+			Chain: enforcer.Chain{ParentChain: "some-chain"},
+			Rules: []rules.IPTablesRule{{"-s", "1.1.1.1", "-j", "ACCEPT"}, {"rule2"}, {"rule2"}},
+		}
+		newRuleSet = enforcer.RulesWithChain{
+			Chain: enforcer.Chain{ParentChain: "some-chain"},
+			Rules: []rules.IPTablesRule{{"rule2"}, {"rule3"}},
+			SubChains: []enforcer.SubChain{
+				{Rules: []rules.IPTablesRule{{"sub-rule"}}},
+			},
+			ExtraTables: []enforcer.TableRules{
+				{Chain: enforcer.Chain{Table: "mangle"}, Rules: []rules.IPTablesRule{{"mangle-rule"}}},
+			},
+		}
+	})
+
+	It("counts the added and removed rules", func() {
+		Expect(converger.RuleDiff(oldRuleSet, newRuleSet)).To(Equal(lager.Data{
+			"message":          "updating iptables rules",
+			"chain":            "some-chain",
+			"num old rules":    3,
+			"num new rules":    4,
+			"added":            3,
+			"removed":          2,
+			"added examples":   []string{"rule3", "sub-rule", "-t mangle mangle-rule"},
+			"removed examples": []string{"-s 1.1.1.1 -j ACCEPT", "rule2"},
+		}))
+	})
+
+	It("only includes the first few examples", func() {
+		oldRuleSet.Rules = nil
+		newRuleSet.SubChains = nil
+		newRuleSet.ExtraTables = nil
+		newRuleSet.Rules = nil
+		for i := 0; i < 10; i++ {
+			newRuleSet.Rules = append(newRuleSet.Rules, rules.IPTablesRule{fmt.Sprintf("rule%d", i)})
+		}
+
+		diff := converger.RuleDiff(oldRuleSet, newRuleSet)
+		Expect(diff["added"]).To(Equal(10))
+		Expect(diff["added examples"]).To(Equal([]string{"rule0", "rule1", "rule2", "rule3", "rule4"}))
+		Expect(diff["removed"]).To(Equal(0))
+		Expect(diff["removed examples"]).To(BeEmpty())
+	})
+})
@@ -0,0 +1,90 @@
+package converger
+
+import (
+	"fmt"
+	"sort"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/datastore"
+	"github.com/hashicorp/go-multierror"
+)
+
+//go:generate counterfeiter -o fakes/container_runtime.go --fake-name ContainerRuntime . containerRuntime
+type containerRuntime interface {
+	Handles() ([]string, error)
+}
+
+// GardenRuntime lists the containers that garden runs.
+type GardenRuntime struct {
+	Client garden.Client
+}
+
+func (g *GardenRuntime) Handles() ([]string, error) {
+	containers, err := g.Client.Containers(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	handles := []string{}
+	for _, container := range containers {
+		handles = append(handles, container.Handle())
+	}
+	return handles, nil
+}
+
+// RuntimeReconciler drains the containers that are still in the datastore
+// although the container runtime no longer runs them, e.g. because garden
+// never called the CNI plugin to delete them. A container is only drained
+// when it was missing from the runtime on two checks in a row, since the
+// plugin adds it to the datastore before the runtime lists it.
+type RuntimeReconciler struct {
+	Store     datastore.Datastore
+	Runtime   containerRuntime
+	DrainFunc func(string) (DrainResult, error)
+	Logger    lager.Logger
+
+	missing map[string]struct{}
+}
+
+func (r *RuntimeReconciler) Reconcile() error {
+	// the runtime is listed first, so that a container that is added in
+	// between is not missing from it
+	handles, err := r.Runtime.Handles()
+	if err != nil {
+		return fmt.Errorf("list runtime containers: %s", err)
+	}
+	running := map[string]struct{}{}
+	for _, handle := range handles {
+		running[handle] = struct{}{}
+	}
+
+	containers, err := r.Store.ReadAll()
+	if err != nil {
+		return fmt.Errorf("read datastore: %s", err)
+	}
+
+	missing := map[string]struct{}{}
+	stale := []string{}
+	for handle := range containers {
+		if _, ok := running[handle]; ok {
+			continue
+		}
+		missing[handle] = struct{}{}
+		if _, ok := r.missing[handle]; ok {
+			stale = append(stale, handle)
+		}
+	}
+	sort.Strings(stale)
+
+	var errors error
+	for _, handle := range stale {
+		r.Logger.Info("draining-stale-container", lager.Data{"container": handle})
+		if _, err := r.DrainFunc(handle); err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("drain %s: %s", handle, err))
+		}
+	}
+	r.missing = missing
+
+	return errors
+}
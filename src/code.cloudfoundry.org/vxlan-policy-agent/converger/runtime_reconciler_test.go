@@ -0,0 +1,104 @@
+package converger_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/datastore"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RuntimeReconciler", func() {
+	var (
+		reconciler        *converger.RuntimeReconciler
+		store             *libfakes.Datastore
+		runtime           *fakes.ContainerRuntime
+		drainedContainers []string
+		drainErr          error
+	)
+
+	containers := func(handles ...string) map[string]datastore.Container {
+		result := map[string]datastore.Container{}
+		for _, handle := range handles {
+			result[handle] = datastore.Container{Handle: handle}
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		store = &libfakes.Datastore{}
+		store.ReadAllReturns(containers("container-a", "container-b", "container-c"), nil)
+		runtime = &fakes.ContainerRuntime{}
+		runtime.HandlesReturns([]string{"container-a", "container-c", "container-d"}, nil)
+		drainedContainers = nil
+		drainErr = nil
+
+		reconciler = &converger.RuntimeReconciler{
+			Store:   store,
+			Runtime: runtime,
+			DrainFunc: func(containerHandle string) (converger.DrainResult, error) {
+				drainedContainers = append(drainedContainers, containerHandle)
+				return converger.DrainResult{Container: containerHandle}, drainErr
+			},
+			Logger: lagertest.NewTestLogger("test"),
+		}
+	})
+
+	It("drains containers that the runtime did not run on two checks in a row", func() {
+		Expect(reconciler.Reconcile()).To(Succeed())
+		Expect(drainedContainers).To(BeEmpty())
+
+		Expect(reconciler.Reconcile()).To(Succeed())
+		Expect(drainedContainers).To(Equal([]string{"container-b"}))
+	})
+
+	It("does not drain containers that the runtime runs again", func() {
+		Expect(reconciler.Reconcile()).To(Succeed())
+
+		runtime.HandlesReturns([]string{"container-a", "container-b", "container-c"}, nil)
+		Expect(reconciler.Reconcile()).To(Succeed())
+		Expect(drainedContainers).To(BeEmpty())
+	})
+
+	Context("when draining fails", func() {
+		BeforeEach(func() {
+			drainErr = errors.New("banana")
+		})
+
+		It("returns an error and drains the container again on the next check", func() {
+			Expect(reconciler.Reconcile()).To(Succeed())
+
+			err := reconciler.Reconcile()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("drain container-b: banana"))
+
+			Expect(reconciler.Reconcile()).NotTo(Succeed())
+			Expect(drainedContainers).To(Equal([]string{"container-b", "container-b"}))
+		})
+	})
+
+	Context("when listing the runtime containers fails", func() {
+		It("returns an error without draining anything", func() {
+			Expect(reconciler.Reconcile()).To(Succeed())
+
+			runtime.HandlesReturns(nil, errors.New("banana"))
+			Expect(reconciler.Reconcile()).To(MatchError("list runtime containers: banana"))
+			Expect(drainedContainers).To(BeEmpty())
+		})
+	})
+
+	Context("when reading the datastore fails", func() {
+		It("returns an error without draining anything", func() {
+			Expect(reconciler.Reconcile()).To(Succeed())
+
+			store.ReadAllReturns(nil, errors.New("banana"))
+			Expect(reconciler.Reconcile()).To(MatchError("read datastore: banana"))
+			Expect(drainedContainers).To(BeEmpty())
+		})
+	})
+})
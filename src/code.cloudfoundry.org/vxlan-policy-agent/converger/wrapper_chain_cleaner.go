@@ -0,0 +1,59 @@
+package converger
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"github.com/hashicorp/go-multierror"
+)
+
+//go:generate counterfeiter -o fakes/chain_deleter.go --fake-name ChainDeleter . chainDeleter
+type chainDeleter interface {
+	DeleteChains([]enforcer.LiveChain) ([]enforcer.LiveChain, error)
+}
+
+//go:generate counterfeiter -o fakes/chain_releaser.go --fake-name ChainReleaser . chainReleaser
+type chainReleaser interface {
+	Release(owner string, chains ...datastore.OwnedChain) error
+}
+
+// WrapperChainCleaner removes the chains the cni-wrapper-plugin created for a
+// container, for containers that were deleted without the plugin being
+// called, and releases the plugin's claim on them.
+type WrapperChainCleaner struct {
+	Enforcer    chainDeleter
+	ChainOwners chainReleaser
+}
+
+func (c *WrapperChainCleaner) CleanupContainerChains(containerHandle string) ([]enforcer.LiveChain, error) {
+	names, err := netrules.ContainerChainNames(&netrules.ChainNamer{MaxLength: 28}, containerHandle)
+	if err != nil {
+		return []enforcer.LiveChain{}, err
+	}
+
+	chains := []enforcer.LiveChain{}
+	for _, chain := range names.Tables() {
+		chains = append(chains, enforcer.LiveChain{Table: chain.Table, Name: chain.Chain})
+	}
+
+	var errors error
+	deleted, err := c.Enforcer.DeleteChains(chains)
+	if err != nil {
+		errors = multierror.Append(errors, fmt.Errorf("delete chains: %s", err))
+	}
+
+	released := []datastore.OwnedChain{}
+	for _, chain := range deleted {
+		released = append(released, datastore.OwnedChain{Table: chain.Table, Chain: chain.Name})
+	}
+	if len(released) > 0 {
+		err = c.ChainOwners.Release(netrules.ChainOwner, released...)
+		if err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("release chains: %s", err))
+		}
+	}
+
+	return deleted, errors
+}
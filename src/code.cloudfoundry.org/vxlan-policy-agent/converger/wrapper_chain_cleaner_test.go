@@ -0,0 +1,100 @@
+package converger_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WrapperChainCleaner", func() {
+	var (
+		chainDeleter  *fakes.ChainDeleter
+		chainReleaser *fakes.ChainReleaser
+		cleaner       *converger.WrapperChainCleaner
+		deleted       []enforcer.LiveChain
+	)
+
+	BeforeEach(func() {
+		chainDeleter = &fakes.ChainDeleter{}
+		chainReleaser = &fakes.ChainReleaser{}
+		deleted = []enforcer.LiveChain{
+			{Table: "filter", Name: "netout--some-handle"},
+			{Table: "nat", Name: "netin--some-handle"},
+		}
+		chainDeleter.DeleteChainsReturns(deleted, nil)
+
+		cleaner = &converger.WrapperChainCleaner{
+			Enforcer:    chainDeleter,
+			ChainOwners: chainReleaser,
+		}
+	})
+
+	It("deletes the chains the wrapper plugin creates for the container and releases them", func() {
+		chains, err := cleaner.CleanupContainerChains("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chains).To(Equal(deleted))
+
+		Expect(chainDeleter.DeleteChainsCallCount()).To(Equal(1))
+		Expect(chainDeleter.DeleteChainsArgsForCall(0)).To(Equal([]enforcer.LiveChain{
+			{Table: "filter", Name: "input--some-handle"},
+			{Table: "filter", Name: "netout--some-handle"},
+			{Table: "filter", Name: "netout--some-handle--log"},
+			{Table: "filter", Name: "netout--some-handle--rl-log"},
+			{Table: "filter", Name: "netout--some-handle--uid"},
+			{Table: "filter", Name: "overlay--some-handle"},
+			{Table: "nat", Name: "netin--some-handle"},
+			{Table: "mangle", Name: "netin--some-handle"},
+		}))
+
+		Expect(chainReleaser.ReleaseCallCount()).To(Equal(1))
+		owner, released := chainReleaser.ReleaseArgsForCall(0)
+		Expect(owner).To(Equal("cni-wrapper-plugin"))
+		Expect(released).To(Equal([]datastore.OwnedChain{
+			{Table: "filter", Chain: "netout--some-handle"},
+			{Table: "nat", Chain: "netin--some-handle"},
+		}))
+	})
+
+	Context("when no chain was left", func() {
+		BeforeEach(func() {
+			chainDeleter.DeleteChainsReturns([]enforcer.LiveChain{}, nil)
+		})
+
+		It("releases nothing", func() {
+			_, err := cleaner.CleanupContainerChains("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chainReleaser.ReleaseCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when deleting a chain fails", func() {
+		BeforeEach(func() {
+			chainDeleter.DeleteChainsReturns(deleted[:1], errors.New("banana"))
+		})
+
+		It("releases the chains deleted so far and returns the error", func() {
+			_, err := cleaner.CleanupContainerChains("some-handle")
+			Expect(err).To(MatchError(ContainSubstring("delete chains: banana")))
+
+			_, released := chainReleaser.ReleaseArgsForCall(0)
+			Expect(released).To(HaveLen(1))
+		})
+	})
+
+	Context("when releasing the chains fails", func() {
+		BeforeEach(func() {
+			chainReleaser.ReleaseReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			_, err := cleaner.CleanupContainerChains("some-handle")
+			Expect(err).To(MatchError(ContainSubstring("release chains: banana")))
+		})
+	})
+})
@@ -0,0 +1,142 @@
+package destinations
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+// ChainPrefix starts the names of the shared chains of the destinations.
+const ChainPrefix = "dest-"
+
+//go:generate counterfeiter -o fakes/destination_getter.go --fake-name DestinationGetter . destinationGetter
+type destinationGetter interface {
+	GetDestinations() (Response, error)
+}
+
+// ChainName is the name of the shared chain of a destination. It stays the
+// same for as long as the destination exists, so that the ASG chains can
+// jump to it while its rules change.
+func ChainName(id string) string {
+	h := sha1.New()
+	h.Write([]byte(id))
+	return fmt.Sprintf("%s%x", ChainPrefix, h.Sum(nil)[0:6])
+}
+
+// Syncer writes the shared chains of the destinations that apps have egress
+// policies to. A chain accepts the packets to its destination and returns the
+// others to the ASG chain that jumped to it. The chains of destinations
+// without policies are emptied, so that the ASG chains that still jump to
+// them until they are enforced again no longer accept the traffic, and are
+// deleted once no chain jumps to them.
+type Syncer struct {
+	Logger       lager.Logger
+	Destinations destinationGetter
+	IPTables     rules.IPTablesAdapter
+	Converter    *netrules.RuleConverter
+
+	lastChains map[string][]string
+}
+
+// Sync writes the chains and returns the names of the chains each app guid
+// may jump to. When the destinations cannot be got or written, it returns
+// the chains of the last sync with the error.
+func (s *Syncer) Sync() (map[string][]string, error) {
+	resp, err := s.Destinations.GetDestinations()
+	if err != nil {
+		return s.lastChains, fmt.Errorf("get destinations: %s", err)
+	}
+
+	destinations := map[string]Destination{}
+	for _, destination := range resp.Destinations {
+		destinations[destination.ID] = destination
+	}
+
+	appChains := map[string][]string{}
+	desired := map[string]Destination{}
+	for _, policy := range resp.EgressPolicies {
+		destination, ok := destinations[policy.Destination.ID]
+		if !ok {
+			continue
+		}
+		name := ChainName(destination.ID)
+		if _, ok := desired[name]; !ok {
+			desired[name] = destination
+		}
+		if !containsString(appChains[policy.Source.ID], name) {
+			appChains[policy.Source.ID] = append(appChains[policy.Source.ID], name)
+		}
+	}
+	for _, chains := range appChains {
+		sort.Strings(chains)
+	}
+
+	liveChains, err := s.IPTables.ListChains(enforcer.FilterTable)
+	if err != nil {
+		return s.lastChains, fmt.Errorf("list chains: %s", err)
+	}
+	stale := []string{}
+	for _, chain := range liveChains {
+		if _, ok := desired[chain]; strings.HasPrefix(chain, ChainPrefix) && !ok {
+			stale = append(stale, chain)
+		}
+	}
+
+	names := []string{}
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	specs := []rules.ChainSpec{}
+	for _, name := range names {
+		specs = append(specs, rules.ChainSpec{Table: enforcer.FilterTable, Chain: name, Rules: s.chainRules(desired[name])})
+	}
+	for _, name := range stale {
+		specs = append(specs, rules.ChainSpec{Table: enforcer.FilterTable, Chain: name, Rules: []rules.IPTablesRule{}})
+	}
+	if len(specs) > 0 {
+		if err := s.IPTables.ReplaceChains(specs...); err != nil {
+			return s.lastChains, fmt.Errorf("replace chains: %s", err)
+		}
+	}
+
+	for _, name := range stale {
+		// fails while an ASG chain still jumps to it, until a later sync
+		if err := s.IPTables.DeleteChain(enforcer.FilterTable, name); err != nil {
+			s.Logger.Debug("delete-stale-chain", lager.Data{"chain": name, "error": err.Error()})
+			continue
+		}
+		s.Logger.Info("deleted-stale-chain", lager.Data{"chain": name})
+	}
+
+	s.lastChains = appChains
+	return appChains, nil
+}
+
+// chainRules are the rules of a destination in the order of its security
+// group rules. They are not logged, since the chain is shared by the
+// containers of several apps.
+func (s *Syncer) chainRules(destination Destination) []rules.IPTablesRule {
+	ruleSpec, err := netrules.NewRulesFromSecurityGroupRules(destination.Rules)
+	if err != nil {
+		s.Logger.Error("rules-from-destination", err, lager.Data{"destination": destination.ID, "name": destination.Name})
+		return []rules.IPTablesRule{}
+	}
+	return s.Converter.DeduplicateRules(s.Converter.BulkConvert(ruleSpec, "", false))
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package destinations_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/policy_client"
+	"code.cloudfoundry.org/vxlan-policy-agent/destinations"
+	"code.cloudfoundry.org/vxlan-policy-agent/destinations/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Syncer", func() {
+	var (
+		getter   *fakes.DestinationGetter
+		iptables *libfakes.IPTablesAdapter
+		logger   *lagertest.TestLogger
+		syncer   *destinations.Syncer
+		dbChain  string
+		mqChain  string
+	)
+
+	policy := func(appGUID, destinationID string) destinations.EgressPolicy {
+		var p destinations.EgressPolicy
+		p.Source.ID = appGUID
+		p.Destination.ID = destinationID
+		return p
+	}
+
+	BeforeEach(func() {
+		getter = &fakes.DestinationGetter{}
+		iptables = &libfakes.IPTablesAdapter{}
+		logger = lagertest.NewTestLogger("test")
+		syncer = &destinations.Syncer{
+			Logger:       logger,
+			Destinations: getter,
+			IPTables:     iptables,
+			Converter:    &netrules.RuleConverter{Logger: logger},
+		}
+		dbChain = destinations.ChainName("db-id")
+		mqChain = destinations.ChainName("mq-id")
+
+		getter.GetDestinationsReturns(destinations.Response{
+			Destinations: []destinations.Destination{
+				{ID: "db-id", Name: "db", Rules: []policy_client.SecurityGroupRule{{Protocol: "tcp", Destination: "10.1.0.0/16", Ports: "5432"}}},
+				{ID: "mq-id", Name: "mq", Rules: []policy_client.SecurityGroupRule{{Protocol: "all", Destination: "10.2.0.1"}}},
+				{ID: "unused-id", Name: "unused", Rules: []policy_client.SecurityGroupRule{{Protocol: "all", Destination: "10.3.0.1"}}},
+			},
+			EgressPolicies: []destinations.EgressPolicy{
+				policy("app-a", "db-id"),
+				policy("app-b", "db-id"),
+				policy("app-b", "mq-id"),
+				policy("app-b", "mq-id"),
+				policy("app-c", "missing-id"),
+			},
+		}, nil)
+	})
+
+	It("names the chains of the destinations after their ids", func() {
+		Expect(dbChain).To(HavePrefix("dest-"))
+		Expect(dbChain).To(HaveLen(17))
+		Expect(destinations.ChainName("db-id")).To(Equal(dbChain))
+		Expect(mqChain).NotTo(Equal(dbChain))
+	})
+
+	It("writes one chain per destination with policies and returns the chains of each app", func() {
+		appChains, err := syncer.Sync()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(appChains).To(HaveLen(2))
+		Expect(appChains["app-a"]).To(Equal([]string{dbChain}))
+		Expect(appChains["app-b"]).To(ConsistOf(dbChain, mqChain))
+
+		Expect(iptables.ReplaceChainsCallCount()).To(Equal(1))
+		Expect(iptables.ReplaceChainsArgsForCall(0)).To(ConsistOf(
+			rules.ChainSpec{Table: "filter", Chain: dbChain, Rules: []rules.IPTablesRule{
+				{"-m", "iprange", "-p", "tcp", "--dst-range", "10.1.0.0-10.1.255.255", "-m", "tcp", "--destination-port", "5432:5432", "--jump", "ACCEPT"},
+			}},
+			rules.ChainSpec{Table: "filter", Chain: mqChain, Rules: []rules.IPTablesRule{
+				{"-m", "iprange", "--dst-range", "10.2.0.1-10.2.0.1", "--jump", "ACCEPT"},
+			}},
+		))
+		Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+	})
+
+	Context("when chains of destinations without policies are left", func() {
+		BeforeEach(func() {
+			iptables.ListChainsReturns([]string{"INPUT", "FORWARD", dbChain, "dest-0123456789ab", "asg-012345"}, nil)
+		})
+
+		It("empties and deletes them", func() {
+			_, err := syncer.Sync()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(iptables.ListChainsArgsForCall(0)).To(Equal("filter"))
+			Expect(iptables.ReplaceChainsArgsForCall(0)).To(ContainElement(
+				rules.ChainSpec{Table: "filter", Chain: "dest-0123456789ab", Rules: []rules.IPTablesRule{}},
+			))
+			Expect(iptables.DeleteChainCallCount()).To(Equal(1))
+			table, chain := iptables.DeleteChainArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("dest-0123456789ab"))
+		})
+
+		Context("when a chain still has jumps to it", func() {
+			BeforeEach(func() {
+				iptables.DeleteChainReturns(errors.New("Too many links"))
+			})
+
+			It("leaves it to a later sync", func() {
+				_, err := syncer.Sync()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logger).To(gbytes.Say("delete-stale-chain.*dest-0123456789ab"))
+			})
+		})
+	})
+
+	Context("when there are no destinations", func() {
+		BeforeEach(func() {
+			getter.GetDestinationsReturns(destinations.Response{}, nil)
+		})
+
+		It("writes no chains", func() {
+			appChains, err := syncer.Sync()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(appChains).To(BeEmpty())
+			Expect(iptables.ReplaceChainsCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the rules of a destination are invalid", func() {
+		BeforeEach(func() {
+			getter.GetDestinationsReturns(destinations.Response{
+				Destinations:   []destinations.Destination{{ID: "db-id", Rules: []policy_client.SecurityGroupRule{{Protocol: "tcp", Destination: "not-an-ip", Ports: "5432"}}}},
+				EgressPolicies: []destinations.EgressPolicy{policy("app-a", "db-id")},
+			}, nil)
+		})
+
+		It("writes an empty chain", func() {
+			appChains, err := syncer.Sync()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(appChains["app-a"]).To(Equal([]string{dbChain}))
+			Expect(iptables.ReplaceChainsArgsForCall(0)).To(Equal([]rules.ChainSpec{
+				{Table: "filter", Chain: dbChain, Rules: []rules.IPTablesRule{}},
+			}))
+			Expect(logger).To(gbytes.Say("rules-from-destination"))
+		})
+	})
+
+	Context("when the destinations cannot be got", func() {
+		It("returns the chains of the last sync", func() {
+			_, err := syncer.Sync()
+			Expect(err).NotTo(HaveOccurred())
+
+			getter.GetDestinationsReturns(destinations.Response{}, errors.New("banana"))
+			appChains, err := syncer.Sync()
+			Expect(err).To(MatchError("get destinations: banana"))
+			Expect(appChains["app-a"]).To(Equal([]string{dbChain}))
+			Expect(iptables.ReplaceChainsCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when the chains cannot be written", func() {
+		BeforeEach(func() {
+			iptables.ReplaceChainsReturns(errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			appChains, err := syncer.Sync()
+			Expect(err).To(MatchError("replace chains: banana"))
+			Expect(appChains).To(BeNil())
+		})
+	})
+
+	Context("when the chains cannot be listed", func() {
+		BeforeEach(func() {
+			iptables.ListChainsReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			_, err := syncer.Sync()
+			Expect(err).To(MatchError("list chains: banana"))
+			Expect(iptables.ReplaceChainsCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,63 @@
+// Package destinations enforces the egress policies of apps to destination
+// objects: named sets of CIDRs and ports that the policy server serves from
+// its destinations API, e.g. the service CIDRs that users provide. The rules
+// of each destination are written once into a shared chain, which the ASG
+// chains of all apps with a policy to the destination jump to, instead of
+// being copied into the ASG chain of every container of those apps.
+package destinations
+
+import (
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
+	"code.cloudfoundry.org/policy_client"
+)
+
+const Route = "/networking/v1/internal/destinations"
+
+// Destination is a named set of CIDRs and ports, written as security group
+// rules.
+type Destination struct {
+	ID    string                            `json:"id"`
+	Name  string                            `json:"name"`
+	Rules []policy_client.SecurityGroupRule `json:"rules"`
+}
+
+// EgressPolicy lets the containers of the source app reach the destination.
+type EgressPolicy struct {
+	Source struct {
+		ID string `json:"id"`
+	} `json:"source"`
+	Destination struct {
+		ID string `json:"id"`
+	} `json:"destination"`
+}
+
+// Response is the answer of the destinations API:
+//
+//	{"destinations": [{"id": ..., "name": ..., "rules": [{"protocol": ..., "destination": ..., "ports": ...}]}],
+//	 "egress_policies": [{"source": {"id": <app guid>}, "destination": {"id": <destination id>}}]}
+type Response struct {
+	Destinations   []Destination  `json:"destinations"`
+	EgressPolicies []EgressPolicy `json:"egress_policies"`
+}
+
+// Client gets the destinations and the egress policies to them from the
+// policy server. A policy server without the destinations API has none.
+type Client struct {
+	JSONClient json_client.JsonClient
+}
+
+func (c *Client) GetDestinations() (Response, error) {
+	var resp Response
+	err := c.JSONClient.Do("GET", Route, nil, &resp, "")
+	var codeErr *json_client.HttpResponseCodeError
+	if errors.As(err, &codeErr) && codeErr.StatusCode == http.StatusNotFound {
+		return Response{}, nil
+	}
+	if err != nil {
+		return Response{}, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,13 @@
+package destinations_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDestinations(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Destinations Suite")
+}
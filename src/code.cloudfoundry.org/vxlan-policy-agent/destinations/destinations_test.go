@@ -0,0 +1,82 @@
+package destinations_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/policy_client"
+	"code.cloudfoundry.org/vxlan-policy-agent/destinations"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server       *httptest.Server
+		requestPath  string
+		responseCode int
+		responseBody string
+		client       *destinations.Client
+	)
+
+	BeforeEach(func() {
+		responseCode = http.StatusOK
+		responseBody = `{
+			"destinations": [{"id": "some-destination", "name": "db", "rules": [{"protocol": "tcp", "destination": "10.1.0.0/16", "ports": "5432"}]}],
+			"egress_policies": [{"source": {"id": "some-app-guid"}, "destination": {"id": "some-destination"}}]
+		}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestPath = r.URL.Path
+			w.WriteHeader(responseCode)
+			w.Write([]byte(responseBody))
+		}))
+		client = &destinations.Client{
+			JSONClient: json_client.New(lagertest.NewTestLogger("test"), http.DefaultClient, server.URL),
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("gets the destinations and the egress policies to them", func() {
+		resp, err := client.GetDestinations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requestPath).To(Equal("/networking/v1/internal/destinations"))
+
+		Expect(resp.Destinations).To(Equal([]destinations.Destination{{
+			ID:    "some-destination",
+			Name:  "db",
+			Rules: []policy_client.SecurityGroupRule{{Protocol: "tcp", Destination: "10.1.0.0/16", Ports: "5432"}},
+		}}))
+		Expect(resp.EgressPolicies).To(HaveLen(1))
+		Expect(resp.EgressPolicies[0].Source.ID).To(Equal("some-app-guid"))
+		Expect(resp.EgressPolicies[0].Destination.ID).To(Equal("some-destination"))
+	})
+
+	Context("when the policy server has no destinations API", func() {
+		BeforeEach(func() {
+			responseCode = http.StatusNotFound
+			responseBody = `{"error": "not found"}`
+		})
+
+		It("returns no destinations", func() {
+			resp, err := client.GetDestinations()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp).To(Equal(destinations.Response{}))
+		})
+	})
+
+	Context("when the policy server fails", func() {
+		BeforeEach(func() {
+			responseCode = http.StatusInternalServerError
+		})
+
+		It("returns the error", func() {
+			_, err := client.GetDestinations()
+			Expect(err).To(MatchError(ContainSubstring("http status 500")))
+		})
+	})
+})
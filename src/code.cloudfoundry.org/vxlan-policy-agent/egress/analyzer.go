@@ -0,0 +1,168 @@
+package egress
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FindingKind tells how a rule is affected by the rules before it.
+type FindingKind string
+
+const (
+	// Shadowed rules never match a packet, since the rules before them
+	// decide all of their packets, and some of them the other way, e.g. an
+	// ASG rule to a deny network.
+	Shadowed FindingKind = "shadowed"
+	// Redundant rules never match a packet either, but the rules before them
+	// decide all of their packets the same way.
+	Redundant FindingKind = "redundant"
+	// Conflicting rules match some packets that a rule before them decides
+	// the other way.
+	Conflicting FindingKind = "conflicting"
+)
+
+// Finding is a rule, as iptables lists it, that the rules before it hide in
+// part or in whole.
+type Finding struct {
+	Kind FindingKind `json:"kind"`
+	Rule string      `json:"rule"`
+	// By are the rules before the rule that decide its packets.
+	By []string `json:"by"`
+	// Overlap are the destinations that a conflicting rule shares with the
+	// rule before it.
+	Overlap string `json:"overlap,omitempty"`
+}
+
+// Analyzer finds the rules that iptables enforces for a container which are
+// hidden by the rules before them. The ASGs, deny networks, policy sources
+// and egress proxies of a container are composed into one chain, so an ASG
+// rule that a deny network rejects, or a deny that an earlier rule accepts,
+// cannot be seen in any one of them. The container to container policies
+// from the app of the container only accept packets, so they can only be
+// redundant.
+type Analyzer struct {
+	IPTables lister
+}
+
+func (a *Analyzer) Analyze(container Container) ([]Finding, error) {
+	findings := []Finding{}
+	_, err := walkChain(a.IPTables, container.NetOutChain, "all", nil, func(d decision, earlier []decision) error {
+		findings = append(findings, analyzeDecision(d, earlier)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if container.IP != "" {
+		policies, err := containerPolicies(a.IPTables, container.IP)
+		if err != nil {
+			return nil, err
+		}
+		earlier := []decision{}
+		for _, rule := range policies {
+			d := decision{rule: rule, protocol: rule.protocol, accept: true}
+			d.destination, err = parseDestination(rule)
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: %s", rule.comment, err)
+			}
+			findings = append(findings, analyzeDecision(d, earlier)...)
+			earlier = append(earlier, d)
+		}
+	}
+	return deduplicateFindings(findings), nil
+}
+
+// analyzeDecision compares a rule with the rules before it on the way of its
+// packets.
+func analyzeDecision(d decision, earlier []decision) []Finding {
+	covering := []decision{}
+	covered := []ipRange{}
+	for _, e := range earlier {
+		if e.destination.overlaps(d.destination) && matchesAllOf(e, d) {
+			covering = append(covering, e)
+			covered = append(covered, e.destination)
+		}
+	}
+	if len(covering) > 0 && len(d.destination.subtract(covered)) == 0 {
+		finding := Finding{Kind: Redundant, Rule: d.rule.spec, By: []string{}}
+		for _, e := range covering {
+			if e.accept != d.accept {
+				finding.Kind = Shadowed
+			}
+			finding.By = append(finding.By, e.rule.spec)
+		}
+		return []Finding{finding}
+	}
+
+	findings := []Finding{}
+	for _, e := range earlier {
+		if e.accept == d.accept || !e.destination.overlaps(d.destination) || !matchesSomeOf(e, d) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Kind:    Conflicting,
+			Rule:    d.rule.spec,
+			By:      []string{e.rule.spec},
+			Overlap: e.destination.intersect(d.destination).String(),
+		})
+	}
+	return findings
+}
+
+// matchesAllOf reports whether an earlier rule matches every packet of a
+// later rule, leaving their destinations aside.
+func matchesAllOf(e, d decision) bool {
+	if e.protocol != "all" && e.protocol != d.protocol {
+		return false
+	}
+	if e.rule.icmpType != "" && e.rule.icmpType != "any" && e.rule.icmpType != d.rule.icmpType {
+		return false
+	}
+	earlierStart, earlierEnd, ok := portRange(e.rule.dport)
+	if !ok {
+		return false
+	}
+	start, end, ok := portRange(d.rule.dport)
+	if !ok {
+		return false
+	}
+	return earlierStart <= start && end <= earlierEnd
+}
+
+// matchesSomeOf reports whether an earlier rule matches some packets of a
+// later rule, leaving their destinations aside.
+func matchesSomeOf(e, d decision) bool {
+	if e.protocol != "all" && d.protocol != "all" && e.protocol != d.protocol {
+		return false
+	}
+	if e.rule.icmpType != "" && d.rule.icmpType != "" && e.rule.icmpType != "any" && d.rule.icmpType != "any" &&
+		e.rule.icmpType != d.rule.icmpType {
+		return false
+	}
+	earlierStart, earlierEnd, ok := portRange(e.rule.dport)
+	if !ok {
+		return true
+	}
+	start, end, ok := portRange(d.rule.dport)
+	if !ok {
+		return true
+	}
+	return earlierStart <= end && start <= earlierEnd
+}
+
+// deduplicateFindings drops the findings that were made before, e.g. for the
+// rules for all protocols that every protocol sub-chain of an ASG chain has.
+func deduplicateFindings(findings []Finding) []Finding {
+	seen := map[string]bool{}
+	unique := []Finding{}
+	for _, finding := range findings {
+		key := strings.Join(append([]string{string(finding.Kind), finding.Rule, finding.Overlap}, finding.By...), "\n")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, finding)
+	}
+	return unique
+}
@@ -0,0 +1,136 @@
+package egress_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/egress"
+	"code.cloudfoundry.org/vxlan-policy-agent/egress/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Analyzer", func() {
+	var (
+		iptables  *fakes.Lister
+		chains    map[string][]string
+		analyzer  *egress.Analyzer
+		container egress.Container
+	)
+
+	const (
+		denyNetwork   = "-d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable"
+		deniedAllow   = "-p tcp -m iprange --dst-range 10.1.0.0-10.1.255.255 -m tcp --dport 443 -j ACCEPT"
+		broadAllow    = "-p tcp -m iprange --dst-range 0.0.0.0-255.255.255.255 -m tcp --dport 443 -j ACCEPT"
+		narrowAllow   = "-p tcp -m iprange --dst-range 8.8.8.8-8.8.8.8 -m tcp --dport 443 -j ACCEPT"
+		dnsAllow      = "-p udp -m iprange --dst-range 8.8.8.8-8.8.8.8 -m udp --dport 53 -j ACCEPT"
+		rangePolicy   = "-d 10.255.1.3/32 -p tcp -m tcp --dport 8080:8090 -m mark --mark 0x1 -m comment --comment src:app-a_dst:app-b -j ACCEPT"
+		portPolicy    = "-d 10.255.1.3/32 -p tcp -m tcp --dport 8080 -m mark --mark 0x1 -m comment --comment src:app-a_dst:app-b -j ACCEPT"
+		asgChain      = "asg-a1b2c3v1-g7cs183k3a"
+		policyChain   = "vpa--v1-g7cs183k3a"
+		netOutChain   = "netout--some-handle"
+		chainRejected = "-j REJECT --reject-with icmp-port-unreachable"
+	)
+
+	BeforeEach(func() {
+		chains = map[string][]string{
+			netOutChain: {
+				"-N " + netOutChain,
+				"-A " + netOutChain + " -j " + asgChain,
+				"-A " + netOutChain + " " + chainRejected,
+			},
+			asgChain: {
+				"-N " + asgChain,
+				"-A " + asgChain + " -m state --state RELATED,ESTABLISHED -j ACCEPT",
+				"-A " + asgChain + " " + denyNetwork,
+				"-A " + asgChain + " " + deniedAllow,
+				"-A " + asgChain + " " + broadAllow,
+				"-A " + asgChain + " " + narrowAllow,
+				"-A " + asgChain + " " + dnsAllow,
+				"-A " + asgChain + " " + chainRejected,
+			},
+		}
+		iptables = &fakes.Lister{}
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			listing, ok := chains[chain]
+			if !ok {
+				return nil, errors.New("No chain/target/match by that name.")
+			}
+			return listing, nil
+		}
+		analyzer = &egress.Analyzer{IPTables: iptables}
+		container = egress.Container{NetOutChain: netOutChain}
+	})
+
+	It("finds the shadowed, redundant and conflicting rules", func() {
+		findings, err := analyzer.Analyze(container)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(findings).To(Equal([]egress.Finding{
+			{Kind: egress.Shadowed, Rule: deniedAllow, By: []string{denyNetwork}},
+			{Kind: egress.Conflicting, Rule: broadAllow, By: []string{denyNetwork}, Overlap: "10.0.0.0/8"},
+			{Kind: egress.Redundant, Rule: narrowAllow, By: []string{broadAllow}},
+		}))
+	})
+
+	Context("when the rules of the ASG chain are split into sub-chains", func() {
+		BeforeEach(func() {
+			chains[asgChain] = []string{
+				"-A " + asgChain + " -p tcp -g " + asgChain + "-0",
+				"-A " + asgChain + " -g " + asgChain + "-1",
+			}
+			chains[asgChain+"-0"] = []string{
+				"-A " + asgChain + "-0 " + denyNetwork,
+				"-A " + asgChain + "-0 " + deniedAllow,
+				"-A " + asgChain + "-0 " + chainRejected,
+			}
+			chains[asgChain+"-1"] = []string{
+				"-A " + asgChain + "-1 " + denyNetwork,
+				"-A " + asgChain + "-1 " + chainRejected,
+			}
+		})
+
+		It("reports every finding once", func() {
+			findings, err := analyzer.Analyze(container)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(findings).To(Equal([]egress.Finding{
+				{Kind: egress.Shadowed, Rule: deniedAllow, By: []string{denyNetwork}},
+			}))
+		})
+	})
+
+	Context("when the container IP is known", func() {
+		BeforeEach(func() {
+			container.IP = "10.255.1.2"
+			chains["FORWARD"] = []string{
+				"-P FORWARD ACCEPT",
+				"-A FORWARD -j " + policyChain,
+			}
+			chains[policyChain] = []string{
+				"-N " + policyChain,
+				"-A " + policyChain + " -s 10.255.1.2/32 -m comment --comment src:app-a -j MARK --set-xmark 0x1/0xffffffff",
+				"-A " + policyChain + " " + rangePolicy,
+				"-A " + policyChain + " " + portPolicy,
+			}
+		})
+
+		It("finds the redundant policies from the app of the container", func() {
+			findings, err := analyzer.Analyze(container)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(findings).To(HaveLen(4))
+			Expect(findings[3]).To(Equal(egress.Finding{Kind: egress.Redundant, Rule: portPolicy, By: []string{rangePolicy}}))
+		})
+	})
+
+	Context("when listing a chain fails", func() {
+		BeforeEach(func() {
+			delete(chains, asgChain)
+		})
+
+		It("returns the error", func() {
+			_, err := analyzer.Analyze(container)
+			Expect(err).To(MatchError("list asg-a1b2c3v1-g7cs183k3a: No chain/target/match by that name."))
+		})
+	})
+})
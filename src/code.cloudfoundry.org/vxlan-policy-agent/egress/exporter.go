@@ -0,0 +1,223 @@
+package egress
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+// The policy chains of the agent are jumped to from the FORWARD chain.
+const (
+	policyParentChain = "FORWARD"
+	policyChainPrefix = "vpa--"
+)
+
+//go:generate counterfeiter -o fakes/lister.go --fake-name Lister . lister
+type lister interface {
+	List(table, chain string) ([]string, error)
+}
+
+// Container is what the exporter needs to know about a container to find its
+// rules.
+type Container struct {
+	// NetOutChain is the chain the packets the container sends out of the
+	// cell go through, e.g. netout--some-handle.
+	NetOutChain string
+	// IP is the overlay address of the container. Without it, the container
+	// to container policies are not exported.
+	IP string
+}
+
+// Exporter reads the egress rules that iptables enforces for a container and
+// turns them back into ASG rules, so that security reviewers can compare what
+// is enforced with what Cloud Controller says should be. The rules of the ASG
+// chain merge the ASGs, the rules of policy sources and of egress proxies,
+// less the deny networks that are rejected before them. The policies from
+// the container follow as rules to the addresses of their destinations.
+type Exporter struct {
+	IPTables lister
+}
+
+func (e *Exporter) Export(container Container) ([]Rule, error) {
+	exported := []Rule{}
+	_, err := walkChain(e.IPTables, container.NetOutChain, "all", nil, func(d decision, earlier []decision) error {
+		if !d.accept {
+			return nil
+		}
+		rules, err := exportDecision(d, earlier)
+		exported = append(exported, rules...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if container.IP != "" {
+		policyRules, err := e.policyRules(container.IP)
+		if err != nil {
+			return nil, err
+		}
+		exported = append(exported, policyRules...)
+	}
+	return deduplicate(exported), nil
+}
+
+// policyRules returns the policies from the app of the container as ASG
+// rules.
+func (e *Exporter) policyRules(containerIP string) ([]Rule, error) {
+	policies, err := containerPolicies(e.IPTables, containerIP)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := []Rule{}
+	for _, rule := range policies {
+		exported = append(exported, Rule{
+			Protocol:    rule.protocol,
+			Destination: strings.TrimSuffix(rule.destination, "/32"),
+			Ports:       formatPorts(rule.dport),
+			Description: "container to container policy " + rule.comment,
+		})
+	}
+	return exported, nil
+}
+
+// containerPolicies returns the rules of the policy chain that accept the
+// packets from the app of the container, which are marked with its tag.
+func containerPolicies(iptables lister, containerIP string) ([]listedRule, error) {
+	listing, err := iptables.List(enforcer.FilterTable, policyParentChain)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %s", policyParentChain, err)
+	}
+	forwardRules, err := parseListedRules(policyParentChain, listing)
+	if err != nil {
+		return nil, err
+	}
+
+	reManagedChain := rules.ManagedChainRegexp(policyChainPrefix)
+	policyChain := ""
+	for _, rule := range forwardRules {
+		if reManagedChain.MatchString(rule.target()) {
+			policyChain = rule.target()
+			break
+		}
+	}
+	if policyChain == "" {
+		return nil, nil
+	}
+
+	policyRules, err := chainRules(iptables, policyChain)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := ""
+	for _, rule := range policyRules {
+		if rule.setMark != "" && strings.TrimSuffix(rule.source, "/32") == containerIP {
+			tag = normalizeMark(rule.setMark)
+			break
+		}
+	}
+	if tag == "" {
+		return nil, nil
+	}
+
+	policies := []listedRule{}
+	for _, rule := range policyRules {
+		if rule.jump == "ACCEPT" && rule.mark != "" && normalizeMark(rule.mark) == tag {
+			policies = append(policies, rule)
+		}
+	}
+	return policies, nil
+}
+
+// chainRules returns the rules of a chain with the rules of the sub-chains it
+// goes to in their place.
+func chainRules(iptables lister, chain string) ([]listedRule, error) {
+	listing, err := iptables.List(enforcer.FilterTable, chain)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %s", chain, err)
+	}
+	parsed, err := parseListedRules(chain, listing)
+	if err != nil {
+		return nil, err
+	}
+
+	all := []listedRule{}
+	for _, rule := range parsed {
+		if rule.goTo != "" && enforcer.IsSubChainOf(rule.goTo, chain) {
+			subChainRules, err := chainRules(iptables, rule.goTo)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, subChainRules...)
+			continue
+		}
+		all = append(all, rule)
+	}
+	return all, nil
+}
+
+// exportDecision turns a rule that accepts packets into ASG rules for its
+// destinations, less those that are rejected before.
+func exportDecision(d decision, earlier []decision) ([]Rule, error) {
+	exported := Rule{Protocol: d.rule.protocol, Ports: formatPorts(d.rule.dport), Log: d.log}
+	if exported.Protocol == "" {
+		exported.Protocol = "all"
+	}
+	if d.rule.icmpType != "" {
+		t, c, err := parseICMPType(d.rule.icmpType)
+		if err != nil {
+			return nil, err
+		}
+		exported.Type, exported.Code = &t, &c
+	}
+
+	rejected := []ipRange{}
+	for _, e := range earlier {
+		if e.accept {
+			continue
+		}
+		if e.rule.protocol == "" || e.rule.protocol == "all" || e.rule.protocol == exported.Protocol {
+			rejected = append(rejected, e.destination)
+		}
+	}
+
+	rules := []Rule{}
+	for _, part := range d.destination.subtract(rejected) {
+		exported.Destination = part.String()
+		rules = append(rules, exported)
+	}
+	return rules, nil
+}
+
+// normalizeMark turns a mark as iptables lists it, e.g. 0x1/0xffffffff, and
+// as the planner writes it, e.g. 0x0001, into the same form.
+func normalizeMark(mark string) string {
+	value, _, _ := strings.Cut(mark, "/")
+	n, err := strconv.ParseUint(value, 0, 32)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatUint(n, 16)
+}
+
+// deduplicate drops the rules that were exported before, e.g. the rules for
+// all protocols that every protocol sub-chain of an ASG chain has.
+func deduplicate(exported []Rule) []Rule {
+	seen := map[string]bool{}
+	unique := []Rule{}
+	for _, rule := range exported {
+		key, _ := json.Marshal(rule)
+		if seen[string(key)] {
+			continue
+		}
+		seen[string(key)] = true
+		unique = append(unique, rule)
+	}
+	return unique
+}
@@ -0,0 +1,223 @@
+package egress_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/egress"
+	"code.cloudfoundry.org/vxlan-policy-agent/egress/fakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exporter", func() {
+	var (
+		iptables  *fakes.Lister
+		chains    map[string][]string
+		exporter  *egress.Exporter
+		container egress.Container
+	)
+
+	intPointer := func(i int) *int { return &i }
+
+	BeforeEach(func() {
+		chains = map[string][]string{
+			"netout--some-handle": {
+				"-N netout--some-handle",
+				"-A netout--some-handle -j asg-a1b2c3v1-g7cs183k3a",
+				"-A netout--some-handle -j REJECT --reject-with icmp-port-unreachable",
+			},
+			"asg-a1b2c3v1-g7cs183k3a": {
+				"-N asg-a1b2c3v1-g7cs183k3a",
+				"-A asg-a1b2c3v1-g7cs183k3a -m state --state RELATED,ESTABLISHED -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a -p tcp -m state --state INVALID -j DROP",
+				"-A asg-a1b2c3v1-g7cs183k3a -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable",
+				"-A asg-a1b2c3v1-g7cs183k3a -p tcp -m iprange --dst-range 0.0.0.0-255.255.255.255 -m tcp --dport 443 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a -p udp -m iprange --dst-range 8.8.8.8-8.8.8.8 -m udp --dport 53 -g netout--some-handle--log",
+				"-A asg-a1b2c3v1-g7cs183k3a -p icmp -m iprange --dst-range 10.1.0.0-10.1.255.255 -m icmp --icmp-type 8/0 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a -p icmp -m iprange --dst-range 192.168.2.1-192.168.2.5 -m icmp --icmp-type 255/255 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a -m iprange --dst-range 192.168.1.0-192.168.1.255 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a -p tcp -m tcp --dport 8080:8090 -m iprange --dst-range 172.16.0.0-172.16.0.255 -j ACCEPT",
+				`-A asg-a1b2c3v1-g7cs183k3a -m limit --limit 2/sec --limit-burst 2 -j LOG --log-prefix "DENY_some-handle "`,
+				"-A asg-a1b2c3v1-g7cs183k3a -j REJECT --reject-with icmp-port-unreachable",
+				"-A asg-a1b2c3v1-g7cs183k3a -m iprange --dst-range 1.1.1.1-1.1.1.1 -j ACCEPT",
+			},
+			"netout--some-handle--log": {
+				"-N netout--some-handle--log",
+				`-A netout--some-handle--log -p udp -m limit --limit 100/sec -j LOG --log-prefix "OK_some-handle "`,
+				"-A netout--some-handle--log -j ACCEPT",
+			},
+		}
+		iptables = &fakes.Lister{}
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			listing, ok := chains[chain]
+			if !ok {
+				return nil, errors.New("No chain/target/match by that name.")
+			}
+			return listing, nil
+		}
+		exporter = &egress.Exporter{IPTables: iptables}
+		container = egress.Container{NetOutChain: "netout--some-handle"}
+	})
+
+	It("exports the accepted destinations of the ASG chain less the rejected ones", func() {
+		rules, err := exporter.Export(container)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(rules).To(Equal([]egress.Rule{
+			{Protocol: "tcp", Destination: "0.0.0.0-9.255.255.255", Ports: "443"},
+			{Protocol: "tcp", Destination: "11.0.0.0-255.255.255.255", Ports: "443"},
+			{Protocol: "udp", Destination: "8.8.8.8", Ports: "53", Log: true},
+			{Protocol: "icmp", Destination: "192.168.2.1-192.168.2.5", Type: intPointer(-1), Code: intPointer(-1)},
+			{Protocol: "all", Destination: "192.168.1.0/24"},
+			{Protocol: "tcp", Destination: "172.16.0.0/24", Ports: "8080-8090"},
+		}))
+		Expect(iptables.ListCallCount()).To(Equal(2))
+		for i, chain := range []string{"netout--some-handle", "asg-a1b2c3v1-g7cs183k3a"} {
+			table, listed := iptables.ListArgsForCall(i)
+			Expect(table).To(Equal("filter"))
+			Expect(listed).To(Equal(chain))
+		}
+	})
+
+	Context("when the deny networks reset tcp connections", func() {
+		BeforeEach(func() {
+			chains["asg-a1b2c3v1-g7cs183k3a"] = []string{
+				"-A asg-a1b2c3v1-g7cs183k3a -d 10.0.0.0/8 -p tcp -j REJECT --reject-with tcp-reset",
+				"-A asg-a1b2c3v1-g7cs183k3a -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable",
+				"-A asg-a1b2c3v1-g7cs183k3a -m iprange --dst-range 10.0.0.0-10.1.255.255 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a -j REJECT --reject-with icmp-port-unreachable",
+			}
+		})
+
+		It("subtracts them from the rules of every protocol", func() {
+			rules, err := exporter.Export(container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(BeEmpty())
+		})
+	})
+
+	Context("when the rules of the ASG chain are split into sub-chains", func() {
+		BeforeEach(func() {
+			chains["asg-a1b2c3v1-g7cs183k3a"] = []string{
+				"-N asg-a1b2c3v1-g7cs183k3a",
+				"-A asg-a1b2c3v1-g7cs183k3a -p tcp -g asg-a1b2c3v1-g7cs183k3a-0",
+				"-A asg-a1b2c3v1-g7cs183k3a -p udp -g asg-a1b2c3v1-g7cs183k3a-1",
+				"-A asg-a1b2c3v1-g7cs183k3a -g asg-a1b2c3v1-g7cs183k3a-2",
+			}
+			chains["asg-a1b2c3v1-g7cs183k3a-0"] = []string{
+				"-A asg-a1b2c3v1-g7cs183k3a-0 -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable",
+				"-A asg-a1b2c3v1-g7cs183k3a-0 -p tcp -m iprange --dst-range 0.0.0.0-9.255.255.255 -m tcp --dport 443 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a-0 -m iprange --dst-range 192.168.1.0-192.168.1.255 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a-0 -j REJECT --reject-with icmp-port-unreachable",
+			}
+			chains["asg-a1b2c3v1-g7cs183k3a-1"] = []string{
+				"-A asg-a1b2c3v1-g7cs183k3a-1 -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable",
+				"-A asg-a1b2c3v1-g7cs183k3a-1 -p udp -m iprange --dst-range 8.8.8.8-8.8.8.8 -m udp --dport 53 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a-1 -m iprange --dst-range 192.168.1.0-192.168.1.255 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a-1 -j REJECT --reject-with icmp-port-unreachable",
+			}
+			chains["asg-a1b2c3v1-g7cs183k3a-2"] = []string{
+				"-A asg-a1b2c3v1-g7cs183k3a-2 -d 10.0.0.0/8 -j REJECT --reject-with icmp-port-unreachable",
+				"-A asg-a1b2c3v1-g7cs183k3a-2 -m iprange --dst-range 192.168.1.0-192.168.1.255 -j ACCEPT",
+				"-A asg-a1b2c3v1-g7cs183k3a-2 -j REJECT --reject-with icmp-port-unreachable",
+			}
+		})
+
+		It("exports the rules of every sub-chain once", func() {
+			rules, err := exporter.Export(container)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(rules).To(Equal([]egress.Rule{
+				{Protocol: "tcp", Destination: "0.0.0.0-9.255.255.255", Ports: "443"},
+				{Protocol: "all", Destination: "192.168.1.0/24"},
+				{Protocol: "udp", Destination: "8.8.8.8", Ports: "53"},
+			}))
+		})
+	})
+
+	Context("when the container has not had an ASG sync yet", func() {
+		BeforeEach(func() {
+			chains["netout--some-handle"] = []string{
+				"-N netout--some-handle",
+				"-A netout--some-handle -m state --state RELATED,ESTABLISHED -j ACCEPT",
+				"-A netout--some-handle -p tcp -m iprange --dst-range 10.0.0.1-10.0.0.1 -m tcp --dport 80 -j ACCEPT",
+				"-A netout--some-handle -j REJECT --reject-with icmp-port-unreachable",
+			}
+		})
+
+		It("exports the rules garden-cni wrote to the netout chain", func() {
+			rules, err := exporter.Export(container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(Equal([]egress.Rule{
+				{Protocol: "tcp", Destination: "10.0.0.1", Ports: "80"},
+			}))
+		})
+	})
+
+	Context("when the container IP is known", func() {
+		BeforeEach(func() {
+			container.IP = "10.255.1.2"
+			chains["FORWARD"] = []string{
+				"-P FORWARD ACCEPT",
+				"-A FORWARD -j vpa--v1-g7cs183k3a",
+				"-A FORWARD -i silk-vtep -j overlay--fwd",
+			}
+			chains["vpa--v1-g7cs183k3a"] = []string{
+				"-N vpa--v1-g7cs183k3a",
+				"-A vpa--v1-g7cs183k3a -s 10.255.1.2/32 -m comment --comment src:app-a -j MARK --set-xmark 0x1/0xffffffff",
+				"-A vpa--v1-g7cs183k3a -s 10.255.1.3/32 -m comment --comment src:app-b -j MARK --set-xmark 0x2/0xffffffff",
+				"-A vpa--v1-g7cs183k3a -d 10.255.1.3/32 -p tcp -m tcp --dport 8080 -m mark --mark 0x1 -m conntrack --ctstate INVALID,NEW,UNTRACKED -j LOG --log-prefix OK_0001_app-a_app-b",
+				"-A vpa--v1-g7cs183k3a -d 10.255.1.3/32 -p tcp -m tcp --dport 8080 -m mark --mark 0x1 -m comment --comment src:app-a_dst:app-b -j ACCEPT",
+				"-A vpa--v1-g7cs183k3a -d 10.255.1.4/32 -p udp -m udp --dport 9000:9010 -m mark --mark 0x1 -m comment --comment src:app-a_dst:app-c -j ACCEPT",
+				"-A vpa--v1-g7cs183k3a -d 10.255.1.2/32 -p tcp -m tcp --dport 8080 -m mark --mark 0x2 -m comment --comment src:app-b_dst:app-a -j ACCEPT",
+			}
+		})
+
+		It("exports the policies from the app of the container after the ASG rules", func() {
+			rules, err := exporter.Export(container)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(rules[len(rules)-2:]).To(Equal([]egress.Rule{
+				{Protocol: "tcp", Destination: "10.255.1.3", Ports: "8080", Description: "container to container policy src:app-a_dst:app-b"},
+				{Protocol: "udp", Destination: "10.255.1.4", Ports: "9000-9010", Description: "container to container policy src:app-a_dst:app-c"},
+			}))
+			Expect(rules).To(HaveLen(8))
+		})
+
+		Context("when the app of the container has no policies", func() {
+			BeforeEach(func() {
+				container.IP = "10.255.1.9"
+			})
+
+			It("exports only the ASG rules", func() {
+				rules, err := exporter.Export(container)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rules).To(HaveLen(6))
+			})
+		})
+	})
+
+	Context("when listing a chain fails", func() {
+		BeforeEach(func() {
+			delete(chains, "asg-a1b2c3v1-g7cs183k3a")
+		})
+
+		It("returns the error", func() {
+			_, err := exporter.Export(container)
+			Expect(err).To(MatchError("list asg-a1b2c3v1-g7cs183k3a: No chain/target/match by that name."))
+		})
+	})
+
+	Context("when a destination cannot be parsed", func() {
+		BeforeEach(func() {
+			chains["netout--some-handle"] = []string{
+				"-A netout--some-handle -m iprange --dst-range banana -j ACCEPT",
+			}
+		})
+
+		It("returns the error", func() {
+			_, err := exporter.Export(container)
+			Expect(err).To(MatchError("chain netout--some-handle: invalid range banana"))
+		})
+	})
+})
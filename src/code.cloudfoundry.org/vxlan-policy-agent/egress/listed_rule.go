@@ -0,0 +1,110 @@
+package egress
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/shlex"
+)
+
+// listedRule holds the parts of a rule, as iptables lists it, that decide
+// where a container may send packets, e.g.
+// -A asg-a1b2c3v1-g7cs183k3a -p tcp -m iprange --dst-range 10.0.0.1-10.0.0.9 -m tcp --dport 443 -j ACCEPT
+type listedRule struct {
+	// spec is the rule as listed, without the chain it is in.
+	spec        string
+	source      string
+	destination string
+	dstRange    string
+	protocol    string
+	dport       string
+	icmpType    string
+	mark        string
+	setMark     string
+	jump        string
+	goTo        string
+	comment     string
+	// matchesState is set for rules that match on the connection state or
+	// rate, which are not about the destinations of new connections.
+	matchesState bool
+	// negated is set for rules that negate a match.
+	negated bool
+}
+
+// parseListedRules parses the rules of a chain and skips the other lines of
+// the listing, e.g. -N for the chain itself.
+func parseListedRules(chain string, listing []string) ([]listedRule, error) {
+	prefix := fmt.Sprintf("-A %s ", chain)
+	parsed := []listedRule{}
+	for _, line := range listing {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		args, err := shlex.Split(strings.TrimPrefix(line, prefix))
+		if err != nil {
+			return nil, fmt.Errorf("parse rule %q: %s", line, err)
+		}
+		rule := parseArgs(args)
+		rule.spec = strings.TrimPrefix(line, prefix)
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+func parseArgs(args []string) listedRule {
+	rule := listedRule{}
+	for i := 0; i < len(args); i++ {
+		value := ""
+		if i+1 < len(args) {
+			value = args[i+1]
+		}
+		switch args[i] {
+		case "!":
+			rule.negated = true
+			continue
+		case "-s", "--source":
+			rule.source = value
+		case "-d", "--destination":
+			rule.destination = value
+		case "--dst-range":
+			rule.dstRange = value
+		case "-p", "--protocol":
+			rule.protocol = value
+		case "--dport", "--destination-port":
+			rule.dport = value
+		case "--icmp-type":
+			rule.icmpType = value
+		case "--mark":
+			rule.mark = value
+		case "--set-xmark", "--set-mark":
+			rule.setMark = value
+		case "-j", "--jump":
+			rule.jump = value
+		case "-g", "--goto":
+			rule.goTo = value
+		case "--comment":
+			rule.comment = value
+		case "--state", "--ctstate", "--hashlimit-above", "--limit":
+			rule.matchesState = true
+		default:
+			continue
+		}
+		i++
+	}
+	return rule
+}
+
+// target returns the chain or target the rule jumps or goes to.
+func (r listedRule) target() string {
+	if r.goTo != "" {
+		return r.goTo
+	}
+	return r.jump
+}
+
+// unconditional reports whether the rule matches every packet.
+func (r listedRule) unconditional() bool {
+	return r.source == "" && r.destination == "" && r.dstRange == "" &&
+		(r.protocol == "" || r.protocol == "all") && r.dport == "" && r.icmpType == "" &&
+		r.mark == "" && !r.matchesState && !r.negated
+}
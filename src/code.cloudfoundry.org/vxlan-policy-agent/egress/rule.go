@@ -0,0 +1,195 @@
+package egress
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Rule is an egress rule in the format of the rules of Cloud Foundry
+// application security groups, as given to cf create-security-group.
+type Rule struct {
+	Protocol    string `json:"protocol"`
+	Destination string `json:"destination"`
+	Ports       string `json:"ports,omitempty"`
+	Type        *int   `json:"type,omitempty"`
+	Code        *int   `json:"code,omitempty"`
+	Log         bool   `json:"log,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ipRange is an inclusive range of IPv4 addresses.
+type ipRange struct {
+	start uint32
+	end   uint32
+}
+
+var allAddresses = ipRange{start: 0, end: 1<<32 - 1}
+
+// parseDestination reads the destination of a rule, which iptables lists as
+// an address range, e.g. 10.0.0.1-10.0.0.9, or as a network, e.g.
+// 10.0.0.0/8. Rules without a destination match all addresses.
+func parseDestination(rule listedRule) (ipRange, error) {
+	if rule.dstRange != "" {
+		start, end, ok := strings.Cut(rule.dstRange, "-")
+		if !ok {
+			return ipRange{}, fmt.Errorf("invalid range %s", rule.dstRange)
+		}
+		startIP, err := parseIPv4(start)
+		if err != nil {
+			return ipRange{}, err
+		}
+		endIP, err := parseIPv4(end)
+		if err != nil {
+			return ipRange{}, err
+		}
+		return ipRange{start: startIP, end: endIP}, nil
+	}
+	if rule.destination == "" {
+		return allAddresses, nil
+	}
+	if !strings.Contains(rule.destination, "/") {
+		ip, err := parseIPv4(rule.destination)
+		return ipRange{start: ip, end: ip}, err
+	}
+	_, network, err := net.ParseCIDR(rule.destination)
+	if err != nil {
+		return ipRange{}, err
+	}
+	ip := network.IP.To4()
+	if ip == nil {
+		return ipRange{}, fmt.Errorf("%s is not an IPv4 network", rule.destination)
+	}
+	start := binary.BigEndian.Uint32(ip)
+	ones, _ := network.Mask.Size()
+	return ipRange{start: start, end: start | uint32(1<<(32-ones)-1)}, nil
+}
+
+func parseIPv4(s string) (uint32, error) {
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("%q is not an IPv4 address", s)
+	}
+	return binary.BigEndian.Uint32(ip), nil
+}
+
+// subtract returns the parts of the range that none of the other ranges
+// cover, in order.
+func (r ipRange) subtract(others []ipRange) []ipRange {
+	remaining := []ipRange{r}
+	for _, other := range others {
+		next := []ipRange{}
+		for _, part := range remaining {
+			if other.end < part.start || other.start > part.end {
+				next = append(next, part)
+				continue
+			}
+			if other.start > part.start {
+				next = append(next, ipRange{start: part.start, end: other.start - 1})
+			}
+			if other.end < part.end {
+				next = append(next, ipRange{start: other.end + 1, end: part.end})
+			}
+		}
+		remaining = next
+	}
+	return remaining
+}
+
+// String formats the range as an ASG destination: a single address, a
+// network when the range is one, or else a range.
+func (r ipRange) String() string {
+	start, end := formatIPv4(r.start), formatIPv4(r.end)
+	if r.start == r.end {
+		return start
+	}
+	size := uint64(r.end) - uint64(r.start) + 1
+	if size&(size-1) == 0 && uint64(r.start)%size == 0 {
+		return fmt.Sprintf("%s/%d", start, 32-bits.TrailingZeros64(size))
+	}
+	return fmt.Sprintf("%s-%s", start, end)
+}
+
+func formatIPv4(ip uint32) string {
+	b := make(net.IP, 4)
+	binary.BigEndian.PutUint32(b, ip)
+	return b.String()
+}
+
+// formatPorts turns the ports of a rule, e.g. 8080:8090, into those of an
+// ASG rule, e.g. 8080-8090.
+func formatPorts(dport string) string {
+	start, end, ok := strings.Cut(dport, ":")
+	if !ok || start == end {
+		return start
+	}
+	return start + "-" + end
+}
+
+// parseICMPType reads the type and code of an ICMP rule, e.g. 8/0. A type or
+// code of 255, or a missing code, match any, which ASGs write as -1.
+func parseICMPType(icmpType string) (int, int, error) {
+	if icmpType == "any" {
+		return -1, -1, nil
+	}
+	typeValue, codeValue, hasCode := strings.Cut(icmpType, "/")
+	t, err := strconv.Atoi(typeValue)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid icmp type %s", icmpType)
+	}
+	c := -1
+	if hasCode {
+		c, err = strconv.Atoi(codeValue)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid icmp type %s", icmpType)
+		}
+	}
+	if t == 255 {
+		t = -1
+	}
+	if c == 255 {
+		c = -1
+	}
+	return t, c, nil
+}
+
+func (r ipRange) overlaps(other ipRange) bool {
+	return r.start <= other.end && other.start <= r.end
+}
+
+// intersect returns the addresses both ranges have, which they must overlap
+// for.
+func (r ipRange) intersect(other ipRange) ipRange {
+	intersection := r
+	if other.start > intersection.start {
+		intersection.start = other.start
+	}
+	if other.end < intersection.end {
+		intersection.end = other.end
+	}
+	return intersection
+}
+
+// portRange reads the ports of a rule, e.g. 8080:8090. Rules without ports
+// match all of them.
+func portRange(dport string) (int, int, bool) {
+	if dport == "" {
+		return 0, 65535, true
+	}
+	start, end, ok := strings.Cut(dport, ":")
+	if !ok {
+		end = start
+	}
+	startPort, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, false
+	}
+	endPort, err := strconv.Atoi(end)
+	if err != nil {
+		return 0, 0, false
+	}
+	return startPort, endPort, true
+}
@@ -0,0 +1,98 @@
+package egress
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+// logChainSuffix ends the chain that logs and accepts the packets of ASG
+// rules with logging, e.g. netout--some-handle--log.
+const logChainSuffix = "--log"
+
+// decision is a rule that accepts or rejects the packets it matches.
+type decision struct {
+	rule listedRule
+	// protocol is the protocol of the packets the rule sees, which is
+	// narrowed by the rules that go to the chain of the rule, e.g. the
+	// protocol sub-chains of an ASG chain. It is all for every protocol.
+	protocol    string
+	destination ipRange
+	accept      bool
+	log         bool
+}
+
+// visitFunc is called for every decision, with the decisions that come
+// before it on the way of its packets.
+type visitFunc func(d decision, earlier []decision) error
+
+// walkChain follows a chain through the chains it jumps or goes to, in the
+// order iptables does, and visits the rules that accept or reject packets.
+// It reports whether the chain ends with a rule that matches every packet,
+// after which the chains that jumped to it are not walked any further.
+func walkChain(iptables lister, chain, protocol string, earlier []decision, visit visitFunc) (bool, error) {
+	listing, err := iptables.List(enforcer.FilterTable, chain)
+	if err != nil {
+		return false, fmt.Errorf("list %s: %s", chain, err)
+	}
+	chainRules, err := parseListedRules(chain, listing)
+	if err != nil {
+		return false, err
+	}
+
+	for _, rule := range chainRules {
+		if rule.negated || rule.matchesState {
+			continue
+		}
+		ruleProtocol := protocol
+		if rule.protocol != "" && rule.protocol != "all" {
+			ruleProtocol = rule.protocol
+		}
+
+		target := rule.target()
+		d := decision{rule: rule, protocol: ruleProtocol}
+		switch {
+		case target == "ACCEPT":
+			d.accept = true
+		case strings.HasSuffix(target, logChainSuffix):
+			d.accept, d.log = true, true
+		case target == "REJECT" || target == "DROP":
+			if rule.unconditional() {
+				return true, nil
+			}
+		case target == "RETURN":
+			if rule.unconditional() {
+				return false, nil
+			}
+			continue
+		case target == "" || strings.ToUpper(target) == target:
+			// other targets, e.g. LOG and MARK, do not decide the fate of
+			// the packet
+			continue
+		default:
+			ended, err := walkChain(iptables, target, ruleProtocol, append([]decision{}, earlier...), visit)
+			if err != nil {
+				return false, err
+			}
+			if ended && rule.unconditional() {
+				return true, nil
+			}
+			continue
+		}
+
+		d.destination, err = parseDestination(rule)
+		if err != nil {
+			return false, fmt.Errorf("chain %s: %s", chain, err)
+		}
+		err = visit(d, earlier)
+		if err != nil {
+			return false, fmt.Errorf("chain %s: %s", chain, err)
+		}
+		if rule.unconditional() {
+			return true, nil
+		}
+		earlier = append(earlier, d)
+	}
+	return false, nil
+}
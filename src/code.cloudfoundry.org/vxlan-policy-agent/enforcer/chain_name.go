@@ -0,0 +1,36 @@
+package enforcer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// MaxSubChains is the number of sub-chains a managed chain can have, since the
+// index of a sub-chain is a single base 36 digit.
+const MaxSubChains = 36
+
+// SubChainName returns the name of the index-th sub-chain of a managed chain.
+func SubChainName(chain string, index int) string {
+	return fmt.Sprintf("%s-%s", chain, strconv.FormatInt(int64(index), 36))
+}
+
+// IsSubChainOf reports whether name is a sub-chain of the managed chain.
+func IsSubChainOf(name, chain string) bool {
+	index, ok := strings.CutPrefix(name, chain+"-")
+	if !ok || len(index) != 1 {
+		return false
+	}
+	_, err := strconv.ParseInt(index, 36, 64)
+	return err == nil
+}
+
+// SubChainRegexp matches the full name of a sub-chain of a managed chain whose
+// prefix matches managedChainsRegex. The name of the managed chain is captured
+// by the first group.
+func SubChainRegexp(managedChainsRegex string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`^((?:%s)%s)-[0-9a-z]$`, managedChainsRegex, rules.ChainNameSuffixPattern))
+}
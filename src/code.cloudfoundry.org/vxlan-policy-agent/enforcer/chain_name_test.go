@@ -0,0 +1,62 @@
+package enforcer_test
+
+import (
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChainName", func() {
+	It("fits ASG chains within the iptables chain name limit", func() {
+		name := rules.ChainName(planner.ASGChainPrefix("some-handle"), rules.ChainNameVersion1, 9999999999999999)
+		Expect(len(name)).To(BeNumerically("<=", rules.MaxChainNameLength))
+	})
+})
+
+var _ = Describe("ManagedChainRegexp", func() {
+	It("only matches complete managed chain names", func() {
+		re := rules.ManagedChainRegexp(planner.ASGManagedChainsRegex)
+		Expect(re.MatchString("asg-abcdef1645708469990518")).To(BeTrue())
+		Expect(re.MatchString("asg-abcdefv1-g7cs183k3a")).To(BeTrue())
+		Expect(re.MatchString("asg-abcdef")).To(BeFalse())
+		Expect(re.MatchString("asg-abcdef1645708469990518-log")).To(BeFalse())
+		Expect(re.MatchString("my-asg-abcdef1645708469990518")).To(BeFalse())
+		Expect(re.MatchString("asg-abcdefv2-g7cs183k3a")).To(BeFalse())
+	})
+})
+
+var _ = Describe("SubChainName", func() {
+	It("names sub-chains after the managed chain and their base 36 index", func() {
+		Expect(enforcer.SubChainName("asg-abcdef1645708469990518", 0)).To(Equal("asg-abcdef1645708469990518-0"))
+		Expect(enforcer.SubChainName("asg-abcdef1645708469990518", 35)).To(Equal("asg-abcdef1645708469990518-z"))
+	})
+
+	It("fits the sub-chains of ASG chains within the iptables chain name limit", func() {
+		name := rules.ChainName(planner.ASGChainPrefix("some-handle"), rules.ChainNameVersionLegacy, 9999999999999999)
+		Expect(len(enforcer.SubChainName(name, 35))).To(BeNumerically("<=", rules.MaxChainNameLength))
+	})
+})
+
+var _ = Describe("IsSubChainOf", func() {
+	It("only matches sub-chains of the managed chain", func() {
+		Expect(enforcer.IsSubChainOf("asg-abcdef1645708469990518-0", "asg-abcdef1645708469990518")).To(BeTrue())
+		Expect(enforcer.IsSubChainOf("asg-abcdef1645708469990518-z", "asg-abcdef1645708469990518")).To(BeTrue())
+		Expect(enforcer.IsSubChainOf("asg-abcdef1645708469990518-", "asg-abcdef1645708469990518")).To(BeFalse())
+		Expect(enforcer.IsSubChainOf("asg-abcdef1645708469990518-log", "asg-abcdef1645708469990518")).To(BeFalse())
+		Expect(enforcer.IsSubChainOf("asg-abcdef1645708469990999-0", "asg-abcdef1645708469990518")).To(BeFalse())
+	})
+})
+
+var _ = Describe("SubChainRegexp", func() {
+	It("matches sub-chains of managed chains and captures the managed chain", func() {
+		re := enforcer.SubChainRegexp(planner.ASGManagedChainsRegex)
+		Expect(re.FindStringSubmatch("asg-abcdef1645708469990518-0")).To(ContainElement("asg-abcdef1645708469990518"))
+		Expect(re.FindStringSubmatch("asg-abcdefv1-g7cs183k3a-1")).To(ContainElement("asg-abcdefv1-g7cs183k3a"))
+		Expect(re.MatchString("asg-abcdef1645708469990518")).To(BeFalse())
+		Expect(re.MatchString("asg-abcdef1645708469990518-log")).To(BeFalse())
+		Expect(re.MatchString("netout-abcdef-0")).To(BeFalse())
+	})
+})
@@ -0,0 +1,98 @@
+package enforcer
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// maxDiffExamples is the number of added and removed rules a RuleSetDiff
+// keeps as examples.
+const maxDiffExamples = 5
+
+// RuleSetDiff is the change between the rule set that was enforced and the
+// planned one, compact enough to be logged on every change: how many rules
+// were added and removed, and the first few of each. Rules of sub-chains and
+// of other tables are compared along with the rules of the chain.
+type RuleSetDiff struct {
+	OldRules        int
+	NewRules        int
+	Added           int
+	Removed         int
+	AddedExamples   []string
+	RemovedExamples []string
+}
+
+// DiffRuleSets compares two rule sets, taking repeated rules into account.
+func DiffRuleSets(enforced, planned RulesWithChain) RuleSetDiff {
+	oldRules := ruleSetLines(enforced)
+	newRules := ruleSetLines(planned)
+
+	added, addedExamples := countMissing(newRules, oldRules)
+	removed, removedExamples := countMissing(oldRules, newRules)
+
+	return RuleSetDiff{
+		OldRules:        len(oldRules),
+		NewRules:        len(newRules),
+		Added:           added,
+		Removed:         removed,
+		AddedExamples:   addedExamples,
+		RemovedExamples: removedExamples,
+	}
+}
+
+// RuleSetDiffer is the Differ of the agent: a rule set changed when it is not
+// equal to the enforced one, including the order of its rules.
+type RuleSetDiffer struct{}
+
+func (RuleSetDiffer) Diff(enforced, planned RulesWithChain) (RuleSetDiff, bool) {
+	if planned.Equals(enforced) {
+		return RuleSetDiff{}, false
+	}
+	return DiffRuleSets(enforced, planned), true
+}
+
+// countMissing counts the rules that are in rulesList but not in otherRules,
+// taking repeated rules into account.
+func countMissing(rulesList, otherRules []string) (int, []string) {
+	remaining := map[string]int{}
+	for _, rule := range otherRules {
+		remaining[rule]++
+	}
+
+	count := 0
+	examples := []string{}
+	for _, rule := range rulesList {
+		if remaining[rule] > 0 {
+			remaining[rule]--
+			continue
+		}
+		count++
+		if len(examples) < maxDiffExamples {
+			examples = append(examples, rule)
+		}
+	}
+	return count, examples
+}
+
+func ruleSetLines(ruleSet RulesWithChain) []string {
+	lines := ruleLines(ruleSet.Rules)
+	for _, subChain := range ruleSet.SubChains {
+		lines = append(lines, ruleLines(subChain.Rules)...)
+	}
+	for _, tableRules := range ruleSet.ExtraTables {
+		for _, line := range ruleLines(tableRules.Rules) {
+			lines = append(lines, fmt.Sprintf("-t %s %s", tableRules.Chain.Table, line))
+		}
+	}
+	return lines
+}
+
+func ruleLines(rulesList []rules.IPTablesRule) []string {
+	lines := make([]string, 0, len(rulesList))
+	for _, rule := range rulesList {
+		lines = append(lines, strings.Join(rule, " "))
+	}
+	return lines
+}
@@ -1,15 +1,31 @@
+// Package enforcer manages the lifecycle of the iptables chains of an agent:
+// a rule set is enforced in a new chain named after a prefix and a timestamp,
+// the jump from its parent chain is swapped to the new chain, and chains that
+// are not desired anymore are deleted along with their sub-chains.
+//
+// Components that manage chains of their own build on the Planner, Differ,
+// Applier and Cleaner interfaces and run them with a Lifecycle; Enforcer is
+// the Applier and Cleaner of iptables. These interfaces, Lifecycle and the
+// rule set types are the stable API of the package. The converger of the
+// VXLAN policy agent runs the same phases with the batching, pausing and
+// metrics of its poll cycles.
 package enforcer
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"regexp"
-	"strconv"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lib/datastore"
 	"code.cloudfoundry.org/lib/rules"
 
 	"code.cloudfoundry.org/lager/v3"
+	"github.com/google/shlex"
 )
 
 type Timestamper struct{}
@@ -23,6 +39,11 @@ type TimeStamper interface {
 	CurrentTime() int64
 }
 
+//go:generate counterfeiter -o fakes/chain_owners.go --fake-name ChainOwners . chainOwners
+type chainOwners interface {
+	Owner(table, chain string) (datastore.ChainOwner, bool, error)
+}
+
 type Enforcer struct {
 	Logger      lager.Logger
 	timestamper TimeStamper
@@ -39,13 +60,32 @@ func NewEnforcer(logger lager.Logger, timestamper TimeStamper, ipt rules.IPTable
 	}
 }
 
+// withContext is a copy of the enforcer whose iptables calls stop when the
+// context is done.
+func (e *Enforcer) withContext(ctx context.Context) *Enforcer {
+	withContext := *e
+	withContext.iptables = e.iptables.WithContext(ctx)
+	return &withContext
+}
+
 type EnforcerConfig struct {
 	DisableContainerNetworkPolicy bool
 	OverlayNetwork                string
+	ChainOwners                   chainOwners
+	ChainNameVersion              int
+	// OwnsChain tells the chains that CleanChainsMatching may delete, when
+	// other agents manage chains matching the same pattern. Nil owns all.
+	OwnsChain func(name string) bool
+	// IntentLog records the changes before they are made, so that the ones a
+	// crash interrupts are repaired by RecoverIntents. Nil records none.
+	IntentLog IntentLog
 }
 
 const FilterTable = "filter"
 
+// ManagedTables are the tables the agent can create managed chains in.
+var ManagedTables = []string{FilterTable, "nat", "mangle", "raw"}
+
 type Chain struct {
 	Table              string
 	ParentChain        string
@@ -60,9 +100,23 @@ type LiveChain struct {
 }
 
 type RulesWithChain struct {
-	Chain     Chain
-	Rules     []rules.IPTablesRule
-	LogConfig executor.LogConfig
+	Chain       Chain
+	Rules       []rules.IPTablesRule
+	SubChains   []SubChain
+	ExtraTables []TableRules
+	LogConfig   executor.LogConfig
+	// Handle is the container the rules are for, if they are for one.
+	Handle string
+}
+
+// SubChain is a chain that the managed chain hands packets matching
+// Conditions to with -g. A packet that falls off the end of a sub-chain
+// returns to the parent chain of the managed chain, skipping the rest of the
+// managed chain. Sub-chains are named after the managed chain and are created
+// and deleted along with it.
+type SubChain struct {
+	Conditions rules.IPTablesRule
+	Rules      []rules.IPTablesRule
 }
 
 type CleanupErr struct {
@@ -78,12 +132,71 @@ func (r *RulesWithChain) Equals(other RulesWithChain) bool {
 		return false
 	}
 
-	if len(r.Rules) != len(other.Rules) {
+	if !rulesEqual(r.Rules, other.Rules) {
+		return false
+	}
+
+	if len(r.SubChains) != len(other.SubChains) {
+		return false
+	}
+
+	for i, subChain := range r.SubChains {
+		otherSubChain := other.SubChains[i]
+		if !rulesEqual([]rules.IPTablesRule{subChain.Conditions}, []rules.IPTablesRule{otherSubChain.Conditions}) {
+			return false
+		}
+		if !rulesEqual(subChain.Rules, otherSubChain.Rules) {
+			return false
+		}
+	}
+
+	if len(r.ExtraTables) != len(other.ExtraTables) {
 		return false
 	}
 
-	for i, rule := range r.Rules {
-		otherRule := other.Rules[i]
+	for i, tableRules := range r.ExtraTables {
+		otherTableRules := other.ExtraTables[i]
+		if tableRules.Chain != otherTableRules.Chain {
+			return false
+		}
+		if !rulesEqual(tableRules.Rules, otherTableRules.Rules) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash is a hash of the rules of the rule set, its sub-chains and extra
+// tables. Rule sets that are Equal have the same hash.
+func (r *RulesWithChain) Hash() string {
+	hash := sha256.New()
+	writeRules := func(section string, rulesList []rules.IPTablesRule) {
+		fmt.Fprintf(hash, "%s %d\n", section, len(rulesList))
+		for _, rule := range rulesList {
+			fmt.Fprintf(hash, "%q\n", []string(rule))
+		}
+	}
+
+	fmt.Fprintf(hash, "%q\n", []string{r.Chain.Table, r.Chain.ParentChain, r.Chain.Prefix})
+	writeRules("rules", r.Rules)
+	for _, subChain := range r.SubChains {
+		writeRules("sub-chain", []rules.IPTablesRule{subChain.Conditions})
+		writeRules("sub-chain-rules", subChain.Rules)
+	}
+	for _, tableRules := range r.ExtraTables {
+		fmt.Fprintf(hash, "%q\n", []string{tableRules.Chain.Table, tableRules.Chain.ParentChain, tableRules.Chain.Prefix})
+		writeRules("table-rules", tableRules.Rules)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func rulesEqual(rulesList, otherRulesList []rules.IPTablesRule) bool {
+	if len(rulesList) != len(otherRulesList) {
+		return false
+	}
+
+	for i, rule := range rulesList {
+		otherRule := otherRulesList[i]
 		if len(rule) != len(otherRule) {
 			return false
 		}
@@ -96,6 +209,16 @@ func (r *RulesWithChain) Equals(other RulesWithChain) bool {
 	return true
 }
 
+// CleanChainsMatching deletes the managed chains matching regex in every
+// table that are not desired, along with their sub-chains. The chains a rule
+// set has in other tables carry the name of its chain in its own table, so
+// they are kept along with it.
+// CleanChainsMatchingContext is CleanChainsMatching with iptables calls that
+// stop when the context is done.
+func (e *Enforcer) CleanChainsMatchingContext(ctx context.Context, regex *regexp.Regexp, desiredChains []LiveChain) ([]LiveChain, error) {
+	return e.withContext(ctx).CleanChainsMatching(regex, desiredChains)
+}
+
 func (e *Enforcer) CleanChainsMatching(regex *regexp.Regexp, desiredChains []LiveChain) ([]LiveChain, error) {
 	desiredMap := make(map[string]struct{})
 	for _, chain := range desiredChains {
@@ -105,20 +228,40 @@ func (e *Enforcer) CleanChainsMatching(regex *regexp.Regexp, desiredChains []Liv
 	}
 
 	var chainsToDelete []LiveChain
+	reManagedChain := rules.ManagedChainRegexp(regex.String())
+	reSubChain := SubChainRegexp(regex.String())
 
-	allChains, err := e.iptables.ListChains(FilterTable)
-	if err != nil {
-		e.Logger.Error(fmt.Sprintf("list-chains-%s", FilterTable), err)
-		return []LiveChain{}, fmt.Errorf("listing chains in %s: %s", FilterTable, err)
-	}
-	e.Logger.Debug("allchains", lager.Data{"chains": allChains})
+	for _, table := range ManagedTables {
+		allChains, err := e.iptables.ListChains(table)
+		if err != nil {
+			e.Logger.Error(fmt.Sprintf("list-chains-%s", table), err)
+			return []LiveChain{}, fmt.Errorf("listing chains in %s: %s", table, err)
+		}
+		e.Logger.Debug("allchains", lager.Data{"table": table, "chains": allChains})
 
-	for _, chainName := range allChains {
-		if regex.MatchString(chainName) {
-			if _, ok := desiredMap[chainName]; !ok {
-				chainsToDelete = append(chainsToDelete, LiveChain{Table: FilterTable, Name: chainName})
+		for _, chainName := range allChains {
+			if reManagedChain.MatchString(chainName) && e.ownsChain(chainName) {
+				if _, ok := desiredMap[chainName]; !ok {
+					chainsToDelete = append(chainsToDelete, LiveChain{Table: table, Name: chainName})
+				}
 			}
 		}
+
+		// sub-chains are deleted with the managed chain that goes to them, so
+		// only those left behind without one are deleted on their own
+		for _, chainName := range allChains {
+			matches := reSubChain.FindStringSubmatch(chainName)
+			if len(matches) < 2 {
+				continue
+			}
+			if _, ok := desiredMap[matches[1]]; ok {
+				continue
+			}
+			if containsString(allChains, matches[1]) || !e.ownsChain(matches[1]) {
+				continue
+			}
+			chainsToDelete = append(chainsToDelete, LiveChain{Table: table, Name: chainName})
+		}
 	}
 
 	for _, chain := range chainsToDelete {
@@ -132,8 +275,159 @@ func (e *Enforcer) CleanChainsMatching(regex *regexp.Regexp, desiredChains []Liv
 	return chainsToDelete, nil
 }
 
+func (e *Enforcer) ownsChain(name string) bool {
+	return e.conf.OwnsChain == nil || e.conf.OwnsChain(name)
+}
+
+// CleanChainsWithoutPrefix deletes the managed chains of a table whose name
+// starts with none of the desired prefixes, along with the jumps to them from
+// the other chains of the table. It is meant for chains that are no longer
+// configured, whose parent chain is not known anymore.
+func (e *Enforcer) CleanChainsWithoutPrefix(table string, regex *regexp.Regexp, desiredPrefixes []string) ([]LiveChain, error) {
+	logger := e.Logger.Session("clean-chains-without-prefix", lager.Data{"table": table})
+	reManagedChain := rules.ManagedChainRegexp(regex.String())
+
+	allChains, err := e.iptables.ListChains(table)
+	if err != nil {
+		return []LiveChain{}, fmt.Errorf("listing chains in %s: %s", table, err)
+	}
+
+	staleChains := []string{}
+	for _, chainName := range allChains {
+		if !reManagedChain.MatchString(chainName) || hasAnyPrefix(chainName, desiredPrefixes) {
+			continue
+		}
+		staleChains = append(staleChains, chainName)
+	}
+	if len(staleChains) == 0 {
+		return []LiveChain{}, nil
+	}
+
+	err = e.deleteJumpsTo(logger, table, allChains, staleChains)
+	if err != nil {
+		return []LiveChain{}, err
+	}
+
+	deleted := []LiveChain{}
+	for _, chainName := range staleChains {
+		chain := LiveChain{Table: table, Name: chainName}
+		logger.Info("delete-chain", lager.Data{"chain": chainName})
+		err := e.deleteChain(logger, chain)
+		if err != nil {
+			return deleted, fmt.Errorf("deleting chain %s from table %s: %s", chainName, table, err)
+		}
+		deleted = append(deleted, chain)
+	}
+	return deleted, nil
+}
+
+// DeleteChains deletes the given chains along with the jumps to them from the
+// other chains of their tables. Chains that do not exist are skipped. All of
+// them are flushed before any is deleted, as they may jump to each other.
+func (e *Enforcer) DeleteChains(chains []LiveChain) ([]LiveChain, error) {
+	logger := e.Logger.Session("delete-chains")
+
+	tables := []string{}
+	chainsByTable := map[string][]string{}
+	for _, chain := range chains {
+		if _, ok := chainsByTable[chain.Table]; !ok {
+			tables = append(tables, chain.Table)
+		}
+		chainsByTable[chain.Table] = append(chainsByTable[chain.Table], chain.Name)
+	}
+
+	deleted := []LiveChain{}
+	for _, table := range tables {
+		allChains, err := e.iptables.ListChains(table)
+		if err != nil {
+			return deleted, fmt.Errorf("listing chains in %s: %s", table, err)
+		}
+
+		existing := []string{}
+		for _, chainName := range chainsByTable[table] {
+			if containsString(allChains, chainName) && !containsString(existing, chainName) {
+				existing = append(existing, chainName)
+			}
+		}
+		if len(existing) == 0 {
+			continue
+		}
+
+		err = e.deleteJumpsTo(logger, table, allChains, existing)
+		if err != nil {
+			return deleted, err
+		}
+
+		for _, chainName := range existing {
+			err := e.iptables.ClearChain(table, chainName)
+			if err != nil {
+				return deleted, fmt.Errorf("flushing chain %s in table %s: %s", chainName, table, err)
+			}
+		}
+		for _, chainName := range existing {
+			logger.Info("delete-chain", lager.Data{"table": table, "chain": chainName})
+			err := e.iptables.DeleteChain(table, chainName)
+			if err != nil {
+				return deleted, fmt.Errorf("deleting chain %s from table %s: %s", chainName, table, err)
+			}
+			deleted = append(deleted, LiveChain{Table: table, Name: chainName})
+		}
+	}
+	return deleted, nil
+}
+
+// deleteJumpsTo deletes the rules jumping or going to the stale chains from
+// the other chains of the table, by their listed rule spec.
+func (e *Enforcer) deleteJumpsTo(logger lager.Logger, table string, allChains, staleChains []string) error {
+	for _, chainName := range allChains {
+		if containsString(staleChains, chainName) {
+			continue
+		}
+		chainRules, err := e.iptables.List(table, chainName)
+		if err != nil {
+			return fmt.Errorf("listing chain %s: %s", chainName, err)
+		}
+		rulePrefix := fmt.Sprintf("-A %s ", chainName)
+		for _, r := range chainRules {
+			if !strings.HasPrefix(r, rulePrefix) || !containsString(staleChains, jumpOrGotoTarget(r)) {
+				continue
+			}
+			rulespec, err := shlex.Split(strings.TrimPrefix(r, rulePrefix))
+			if err != nil {
+				return fmt.Errorf("parsing jump %q: %s", r, err)
+			}
+			logger.Info("delete-jump", lager.Data{"chain": chainName, "rule": r})
+			err = e.iptables.Delete(table, chainName, rulespec)
+			if err != nil {
+				return fmt.Errorf("deleting jump from %s: %s", chainName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// EnforceRulesAndChainContext is EnforceRulesAndChain with iptables calls
+// that stop when the context is done.
+func (e *Enforcer) EnforceRulesAndChainContext(ctx context.Context, rulesAndChain RulesWithChain) (string, error) {
+	return e.withContext(ctx).EnforceRulesAndChain(rulesAndChain)
+}
+
 func (e *Enforcer) EnforceRulesAndChain(rulesAndChain RulesWithChain) (string, error) {
-	return e.EnforceOnChain(rulesAndChain.Chain, rulesAndChain.Rules)
+	if len(rulesAndChain.ExtraTables) > 0 {
+		return e.enforceTables(rulesAndChain)
+	}
+
+	if len(rulesAndChain.SubChains) == 0 {
+		return e.EnforceOnChain(rulesAndChain.Chain, rulesAndChain.Rules)
+	}
+
+	c := rulesAndChain.Chain
+	managedChainsRegex := c.ManagedChainsRegex
+	if managedChainsRegex == "" {
+		managedChainsRegex = c.Prefix
+	}
+	return e.enforce(c.Table, c.ParentChain, c.Prefix, managedChainsRegex, c.CleanUpParentChain, rulesAndChain.SubChains, rulesAndChain.Rules)
 }
 
 func (e *Enforcer) EnforceOnChain(c Chain, rules []rules.IPTablesRule) (string, error) {
@@ -147,28 +441,62 @@ func (e *Enforcer) EnforceOnChain(c Chain, rules []rules.IPTablesRule) (string,
 }
 
 func (e *Enforcer) Enforce(table, parentChain, chainPrefix, managedChainsRegex string, cleanupParentChain bool, rulespec ...rules.IPTablesRule) (string, error) {
+	return e.enforce(table, parentChain, chainPrefix, managedChainsRegex, cleanupParentChain, nil, rulespec)
+}
+
+func (e *Enforcer) enforce(table, parentChain, chainPrefix, managedChainsRegex string, cleanupParentChain bool, subChains []SubChain, rulespec []rules.IPTablesRule) (string, error) {
 	newTime := e.timestamper.CurrentTime()
-	chain := fmt.Sprintf("%s%d", chainPrefix, newTime)
+	chain := rules.ChainName(chainPrefix, e.conf.ChainNameVersion, newTime)
 	logger := e.Logger.Session(chain)
 
+	ruleCount := len(subChains) + len(rulespec)
+	if e.conf.DisableContainerNetworkPolicy {
+		ruleCount++
+	}
+	rulesWithChain := RulesWithChain{Chain: Chain{Table: table, ParentChain: parentChain, Prefix: chainPrefix}, Rules: rulespec, SubChains: subChains}
+	intent := newIntent(OperationEnforce, rulesWithChain.Hash(), []IntentChain{{Table: table, ParentChain: parentChain, Name: chain, Rules: ruleCount}})
+	err := e.beginIntent(logger, intent)
+	if err != nil {
+		return "", err
+	}
+
 	logger.Debug("create-chain", lager.Data{"chain": chain, "table": table})
-	err := e.iptables.NewChain(table, chain)
+	err = e.iptables.NewChain(table, chain)
 	if err != nil {
 		logger.Error("create-chain", err)
+		e.completeIntent(logger, intent)
 		return "", fmt.Errorf("creating chain: %s", err)
 	}
 
+	if len(subChains) > 0 {
+		gotoRules, err := e.createSubChains(logger, table, chain, subChains)
+		if err != nil {
+			logger.Error("create-sub-chains", err)
+			delErr := e.deleteChain(logger, LiveChain{Table: table, Name: chain})
+			if delErr != nil {
+				logger.Error("cleanup-failed-create-sub-chains", delErr)
+			} else {
+				e.completeIntent(logger, intent)
+			}
+			return "", fmt.Errorf("creating sub-chains: %s", err)
+		}
+		rulespec = append(gotoRules, rulespec...)
+	}
+
 	if e.conf.DisableContainerNetworkPolicy {
 		rulespec = append([]rules.IPTablesRule{rules.NewAcceptEverythingRule(e.conf.OverlayNetwork)}, rulespec...)
 	}
 
-	logger.Debug("insert-chain", lager.Data{"chain": parentChain, "table": table, "index": 1, "rule": rules.IPTablesRule{"-j", chain}})
-	err = e.iptables.BulkInsert(table, parentChain, 1, rules.IPTablesRule{"-j", chain})
+	pos := e.insertPosition(logger, table, parentChain)
+	logger.Debug("insert-chain", lager.Data{"chain": parentChain, "table": table, "index": pos, "rule": rules.IPTablesRule{"-j", chain}})
+	err = e.iptables.BulkInsert(table, parentChain, pos, rules.IPTablesRule{"-j", chain})
 	if err != nil {
 		logger.Error("insert-chain", err)
 		delErr := e.deleteChain(logger, LiveChain{Table: table, Name: chain})
 		if delErr != nil {
 			logger.Error("cleanup-failed-insert", delErr)
+		} else {
+			e.completeIntent(logger, intent)
 		}
 		return "", fmt.Errorf("inserting chain: %s", err)
 	}
@@ -180,9 +508,12 @@ func (e *Enforcer) Enforce(table, parentChain, chainPrefix, managedChainsRegex s
 		cleanErr := e.cleanupOldChain(logger, LiveChain{Table: table, Name: chain}, parentChain)
 		if cleanErr != nil {
 			logger.Error("cleanup-failed-append", cleanErr)
+		} else {
+			e.completeIntent(logger, intent)
 		}
 		return "", fmt.Errorf("bulk appending: %s", err)
 	}
+	e.completeIntent(logger, intent)
 
 	logger.Debug("cleaning-up-old-rules", lager.Data{"chain": chain, "table": table, "rules": rulespec})
 	err = e.cleanupOldRules(logger, table, parentChain, managedChainsRegex, cleanupParentChain, newTime)
@@ -194,19 +525,96 @@ func (e *Enforcer) Enforce(table, parentChain, chainPrefix, managedChainsRegex s
 	return chain, nil
 }
 
+// createSubChains creates the sub-chains of a new managed chain and returns
+// the rules that go to them. Sub-chains that were created are deleted again
+// when one fails.
+func (e *Enforcer) createSubChains(logger lager.Logger, table, chain string, subChains []SubChain) ([]rules.IPTablesRule, error) {
+	if len(subChains) > MaxSubChains {
+		return nil, fmt.Errorf("%d sub-chains is more than %d", len(subChains), MaxSubChains)
+	}
+
+	var created []string
+	var gotoRules []rules.IPTablesRule
+	for i, subChain := range subChains {
+		name := SubChainName(chain, i)
+		if len(name) > rules.MaxChainNameLength {
+			e.deleteSubChains(logger, table, created)
+			return nil, fmt.Errorf("sub-chain name %s is longer than %d characters", name, rules.MaxChainNameLength)
+		}
+
+		logger.Debug("create-sub-chain", lager.Data{"chain": name, "table": table})
+		err := e.iptables.NewChain(table, name)
+		if err != nil {
+			e.deleteSubChains(logger, table, created)
+			return nil, fmt.Errorf("creating sub-chain %s: %s", name, err)
+		}
+		created = append(created, name)
+
+		err = e.iptables.BulkAppend(table, name, subChain.Rules...)
+		if err != nil {
+			e.deleteSubChains(logger, table, created)
+			return nil, fmt.Errorf("bulk appending to sub-chain %s: %s", name, err)
+		}
+
+		gotoRules = append(gotoRules, rules.NewGotoRule(subChain.Conditions, name))
+	}
+	return gotoRules, nil
+}
+
+func (e *Enforcer) deleteSubChains(logger lager.Logger, table string, subChains []string) {
+	for _, name := range subChains {
+		err := e.deleteChain(logger, LiveChain{Table: table, Name: name})
+		if err != nil {
+			logger.Error("cleanup-sub-chain", err, lager.Data{"chain": name})
+		}
+	}
+}
+
+// insertPosition keeps the jump below the head of the parent chain when the
+// head has been claimed by another component.
+func (e *Enforcer) insertPosition(logger lager.Logger, table, parentChain string) int {
+	if e.conf.ChainOwners == nil {
+		return 1
+	}
+
+	owner, ok, err := e.conf.ChainOwners.Owner(table, parentChain)
+	if err != nil {
+		logger.Error("lookup-chain-owner", err)
+		return 1
+	}
+	if !ok || owner.Jump == "" {
+		return 1
+	}
+
+	rulesList, err := e.iptables.List(table, parentChain)
+	if err != nil {
+		logger.Error("list-parent-chain", err)
+		return 1
+	}
+	for _, r := range rulesList {
+		if r == fmt.Sprintf("-A %s -j %s", parentChain, owner.Jump) {
+			return 2
+		}
+		if strings.HasPrefix(r, "-A ") {
+			break
+		}
+	}
+	return 1
+}
+
 func (e *Enforcer) cleanupOldRules(logger lager.Logger, table, parentChain, managedChainsRegex string, cleanupParentChain bool, newTime int64) error {
 	rulesList, err := e.iptables.List(table, parentChain)
 	if err != nil {
 		return fmt.Errorf("listing forward rules: %s", err)
 	}
 
-	reManagedChain := regexp.MustCompile(managedChainsRegex + "([0-9]{10,16})")
+	reManagedChain := rules.ManagedChainRegexp(managedChainsRegex)
 
 	for _, r := range rulesList {
-		matches := reManagedChain.FindStringSubmatch(r)
+		matches := reManagedChain.FindStringSubmatch(jumpTarget(r))
 
 		if len(matches) > 1 {
-			oldTime, err := strconv.ParseInt(matches[1], 10, 64)
+			_, oldTime, err := rules.ParseChainNameSuffix(matches[1])
 			if err != nil {
 				return err // not tested
 			}
@@ -234,6 +642,94 @@ func (e *Enforcer) cleanupOldRules(logger lager.Logger, table, parentChain, mana
 	return nil
 }
 
+// RepairDuplicateJumpsContext is RepairDuplicateJumps with iptables calls
+// that stop when the context is done.
+func (e *Enforcer) RepairDuplicateJumpsContext(ctx context.Context, c Chain) (int, error) {
+	return e.withContext(ctx).RepairDuplicateJumps(c)
+}
+
+// RepairDuplicateJumps removes all but the newest jump to a managed chain
+// from the parent chain. Duplicates are left behind when the agent stops in
+// the middle of Enforce. It returns the number of jumps removed.
+func (e *Enforcer) RepairDuplicateJumps(c Chain) (int, error) {
+	managedChainsRegex := c.ManagedChainsRegex
+	if managedChainsRegex == "" {
+		managedChainsRegex = c.Prefix
+	}
+	logger := e.Logger.Session("repair-duplicate-jumps", lager.Data{"table": c.Table, "chain": c.ParentChain})
+
+	rulesList, err := e.iptables.List(c.Table, c.ParentChain)
+	if err != nil {
+		return 0, fmt.Errorf("listing parent chain: %s", err)
+	}
+
+	reManagedChain := rules.ManagedChainRegexp(managedChainsRegex)
+	rulePrefix := fmt.Sprintf("-A %s ", c.ParentChain)
+	var jumps []string
+	var jumpRules []rules.IPTablesRule
+	newest := ""
+	var newestTime int64
+	for _, r := range rulesList {
+		if !strings.HasPrefix(r, rulePrefix) {
+			continue
+		}
+		matches := reManagedChain.FindStringSubmatch(jumpTarget(r))
+		if len(matches) < 2 {
+			continue
+		}
+		// the jump may carry matches, so it is deleted by its full spec as
+		// listed rather than by its target alone
+		rulespec, err := shlex.Split(strings.TrimPrefix(r, rulePrefix))
+		if err != nil {
+			return 0, fmt.Errorf("parsing jump %q: %s", r, err)
+		}
+		jumps = append(jumps, matches[0])
+		jumpRules = append(jumpRules, rulespec)
+
+		_, chainTime, err := rules.ParseChainNameSuffix(matches[1])
+		if err != nil {
+			return 0, err // not tested
+		}
+		if newest == "" || chainTime > newestTime {
+			newest, newestTime = matches[0], chainTime
+		}
+	}
+	if len(jumps) < 2 {
+		return 0, nil
+	}
+
+	logger.Info("found-duplicate-jumps", lager.Data{"jumps": jumps, "keep": newest})
+
+	removed := 0
+	keptNewest := false
+	staleChains := []string{}
+	for i, jump := range jumps {
+		if jump == newest && !keptNewest {
+			keptNewest = true
+			continue
+		}
+
+		err := e.iptables.Delete(c.Table, c.ParentChain, jumpRules[i])
+		if err != nil {
+			return removed, fmt.Errorf("remove duplicate jump to %s: %s", jump, err)
+		}
+		removed++
+
+		if jump != newest && !containsString(staleChains, jump) {
+			staleChains = append(staleChains, jump)
+		}
+	}
+
+	for _, chain := range staleChains {
+		err := e.deleteChain(logger, LiveChain{Table: c.Table, Name: chain})
+		if err != nil {
+			return removed, fmt.Errorf("delete stale chain %s: %s", chain, err)
+		}
+	}
+
+	return removed, nil
+}
+
 func (e *Enforcer) cleanupOldChain(logger lager.Logger, chain LiveChain, parentChain string) error {
 	logger.Debug("delete-parent-chain-jump-rule", lager.Data{"table": chain.Table, "chain": parentChain, "rule": rules.IPTablesRule{"-j", chain.Name}})
 	err := e.iptables.Delete(chain.Table, parentChain, rules.IPTablesRule{"-j", chain.Name})
@@ -249,18 +745,20 @@ func (e *Enforcer) cleanupOldChain(logger lager.Logger, chain LiveChain, parentC
 func (e *Enforcer) deleteChain(logger lager.Logger, chain LiveChain) error {
 	// find gotos and delete those chains as well (since we may have log tables that we reference that need deleting)
 	logger.Debug("list-chain", lager.Data{"table": chain.Table, "chain": chain.Name})
-	rules, err := e.iptables.List(chain.Table, chain.Name)
+	listing, err := e.iptables.List(chain.Table, chain.Name)
+	if err != nil {
+		return fmt.Errorf("list rules for chain: %s", err)
+	}
+	parsed, err := rules.ParseRules(listing)
 	if err != nil {
 		return fmt.Errorf("list rules for chain: %s", err)
 	}
 
-	reJumpRule := regexp.MustCompile(fmt.Sprintf(`-A\s+%s\s+.*-g\s+([^\s]+)`, chain.Name))
 	jumpTargets := map[string]struct{}{}
-	for _, rule := range rules {
-		matches := reJumpRule.FindStringSubmatch(rule)
-		if len(matches) > 1 {
-			logger.Debug("found-target-chain-to-recurse", lager.Data{"table": chain.Table, "chain": chain.Name, "target-chain": matches[1]})
-			jumpTargets[matches[1]] = struct{}{}
+	for _, rule := range parsed {
+		if rule.Chain == chain.Name && rule.Goto {
+			logger.Debug("found-target-chain-to-recurse", lager.Data{"table": chain.Table, "chain": chain.Name, "target-chain": rule.Target})
+			jumpTargets[rule.Target] = struct{}{}
 		}
 	}
 
@@ -277,6 +775,14 @@ func (e *Enforcer) deleteChain(logger lager.Logger, chain LiveChain) error {
 	}
 
 	for target, _ := range jumpTargets {
+		if IsSubChainOf(target, chain.Name) {
+			logger.Debug("deleting-sub-chain", lager.Data{"table": chain.Table, "sub-chain": target})
+			if err := e.deleteChain(logger, LiveChain{Table: chain.Table, Name: target}); err != nil {
+				return fmt.Errorf("cleanup sub-chain %s: %s", target, err)
+			}
+			continue
+		}
+
 		logger.Debug("deleting-target-chain", lager.Data{"table": chain.Table, "target-chain": target})
 		if err := e.iptables.DeleteChain(chain.Table, target); err != nil {
 			return fmt.Errorf("cleanup jump target %s: %s", target, err)
@@ -285,3 +791,41 @@ func (e *Enforcer) deleteChain(logger lager.Logger, chain LiveChain) error {
 
 	return nil
 }
+
+var reJumpTarget = regexp.MustCompile(`\s-j\s+(\S+)`)
+
+func jumpTarget(rule string) string {
+	matches := reJumpTarget.FindStringSubmatch(rule)
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
+
+var reJumpOrGotoTarget = regexp.MustCompile(`\s-[jg]\s+(\S+)`)
+
+func jumpOrGotoTarget(rule string) string {
+	matches := reJumpOrGotoTarget.FindStringSubmatch(rule)
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,13 @@
 package enforcer_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 
+	"code.cloudfoundry.org/lib/datastore"
 	libfakes "code.cloudfoundry.org/lib/fakes"
 	"code.cloudfoundry.org/lib/rules"
 	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
@@ -96,6 +99,54 @@ var _ = Describe("Enforcer", func() {
 			Expect(ruleSpec).To(Equal([]rules.IPTablesRule{{"-j", "foo42"}}))
 		})
 
+		Context("when the head of the parent chain is owned by another component", func() {
+			var chainOwners *fakes.ChainOwners
+
+			BeforeEach(func() {
+				chainOwners = &fakes.ChainOwners{}
+				chainOwners.OwnerReturns(datastore.ChainOwner{Owner: "silk-daemon", Jump: "silk-egress"}, true, nil)
+				ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{ChainOwners: chainOwners})
+				iptables.ListReturns([]string{"-P some-chain ACCEPT", "-A some-chain -j silk-egress", "-A some-chain -j other"}, nil)
+			})
+
+			It("inserts the new chain below the owner's jump", func() {
+				_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, fakeRule)
+				Expect(err).NotTo(HaveOccurred())
+
+				table, chain := chainOwners.OwnerArgsForCall(0)
+				Expect([]string{table, chain}).To(Equal([]string{"some-table", "some-chain"}))
+				_, _, pos, _ := iptables.BulkInsertArgsForCall(0)
+				Expect(pos).To(Equal(2))
+			})
+
+			Context("when the owner's jump is not first", func() {
+				BeforeEach(func() {
+					iptables.ListReturns([]string{"-P some-chain ACCEPT", "-A some-chain -j other", "-A some-chain -j silk-egress"}, nil)
+				})
+
+				It("inserts the new chain first", func() {
+					_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, fakeRule)
+					Expect(err).NotTo(HaveOccurred())
+					_, _, pos, _ := iptables.BulkInsertArgsForCall(0)
+					Expect(pos).To(Equal(1))
+				})
+			})
+
+			Context("when looking up the owner fails", func() {
+				BeforeEach(func() {
+					chainOwners.OwnerReturns(datastore.ChainOwner{}, false, errors.New("banana"))
+				})
+
+				It("logs the error and inserts the new chain first", func() {
+					_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, fakeRule)
+					Expect(err).NotTo(HaveOccurred())
+					_, _, pos, _ := iptables.BulkInsertArgsForCall(0)
+					Expect(pos).To(Equal(1))
+					Expect(logger).To(gbytes.Say("lookup-chain-owner.*banana"))
+				})
+			})
+		})
+
 		Context("when there is an older timestamped chain", func() {
 			BeforeEach(func() {
 				timestamper.CurrentTimeReturns(9999999999111111)
@@ -125,6 +176,57 @@ var _ = Describe("Enforcer", func() {
 			})
 		})
 
+		Context("when the chain name version is 1", func() {
+			BeforeEach(func() {
+				ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{OverlayNetwork: "10.10.0.0/16", ChainNameVersion: rules.ChainNameVersion1})
+			})
+
+			It("names the chain with the version and the base 36 time", func() {
+				chain, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, fakeRule)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(chain).To(Equal("foov1-16"))
+			})
+
+			Context("when there are older chains of both versions", func() {
+				BeforeEach(func() {
+					timestamper.CurrentTimeReturns(9999999999111111)
+					iptables.ListReturns([]string{
+						"-A some-chain -j foov1-2qgpckv4e6e",
+						"-A some-chain -j foo9999999999111109",
+						"-A some-chain -j foov1-2qgpckv4e6g",
+					}, nil)
+				})
+
+				It("deletes the older chains", func() {
+					_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, fakeRule)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(iptables.DeleteCallCount()).To(Equal(2))
+					_, _, ruleSpec := iptables.DeleteArgsForCall(0)
+					Expect(ruleSpec).To(Equal(rules.IPTablesRule{"-j", "foov1-2qgpckv4e6e"}))
+					_, _, ruleSpec = iptables.DeleteArgsForCall(1)
+					Expect(ruleSpec).To(Equal(rules.IPTablesRule{"-j", "foo9999999999111109"}))
+				})
+			})
+		})
+
+		Context("when a chain only partly matches the managed chain names", func() {
+			BeforeEach(func() {
+				timestamper.CurrentTimeReturns(9999999999111111)
+				iptables.ListReturns([]string{
+					"-A some-chain -j foo9999999999111110-custom",
+					"-A some-chain -j xfoo9999999999111110",
+					"-A some-chain -j foov2-abc",
+				}, nil)
+			})
+
+			It("leaves it alone", func() {
+				_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, fakeRule)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(iptables.DeleteCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when there is an older timestamped chain with a different prefix", func() {
 			BeforeEach(func() {
 				timestamper.CurrentTimeReturns(9999999999111111)
@@ -336,14 +438,179 @@ var _ = Describe("Enforcer", func() {
 			})
 		})
 	})
+	Describe("EnforceRulesAndChain", func() {
+		var (
+			iptables      *libfakes.IPTablesAdapter
+			timestamper   *fakes.TimeStamper
+			logger        *lagertest.TestLogger
+			ruleEnforcer  *enforcer.Enforcer
+			rulesAndChain enforcer.RulesWithChain
+		)
+
+		BeforeEach(func() {
+			timestamper = &fakes.TimeStamper{}
+			logger = lagertest.NewTestLogger("test")
+			iptables = &libfakes.IPTablesAdapter{}
+
+			timestamper.CurrentTimeReturns(42)
+			ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{})
+
+			rulesAndChain = enforcer.RulesWithChain{
+				Chain: enforcer.Chain{
+					Table:       "some-table",
+					ParentChain: "some-chain",
+					Prefix:      "foo",
+				},
+				Rules: []rules.IPTablesRule{{"rule1"}},
+				SubChains: []enforcer.SubChain{
+					{Conditions: rules.IPTablesRule{"-d", "10.0.0.0/8"}, Rules: []rules.IPTablesRule{{"sub-rule1"}}},
+					{Conditions: rules.IPTablesRule{"-p", "udp"}, Rules: []rules.IPTablesRule{{"sub-rule2"}, {"sub-rule3"}}},
+				},
+			}
+		})
+
+		It("creates the sub-chains and goes to them from the managed chain", func() {
+			chain, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chain).To(Equal("foo42"))
+
+			Expect(iptables.NewChainCallCount()).To(Equal(3))
+			_, chainName := iptables.NewChainArgsForCall(0)
+			Expect(chainName).To(Equal("foo42"))
+			_, chainName = iptables.NewChainArgsForCall(1)
+			Expect(chainName).To(Equal("foo42-0"))
+			_, chainName = iptables.NewChainArgsForCall(2)
+			Expect(chainName).To(Equal("foo42-1"))
+
+			Expect(iptables.BulkAppendCallCount()).To(Equal(3))
+			table, chainName, rulespec := iptables.BulkAppendArgsForCall(0)
+			Expect(table).To(Equal("some-table"))
+			Expect(chainName).To(Equal("foo42-0"))
+			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"sub-rule1"}}))
+
+			_, chainName, rulespec = iptables.BulkAppendArgsForCall(1)
+			Expect(chainName).To(Equal("foo42-1"))
+			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"sub-rule2"}, {"sub-rule3"}}))
+
+			_, chainName, rulespec = iptables.BulkAppendArgsForCall(2)
+			Expect(chainName).To(Equal("foo42"))
+			Expect(rulespec).To(Equal([]rules.IPTablesRule{
+				{"-d", "10.0.0.0/8", "-g", "foo42-0"},
+				{"-p", "udp", "-g", "foo42-1"},
+				{"rule1"},
+			}))
+		})
+
+		Context("when the sub-chain names are too long", func() {
+			BeforeEach(func() {
+				rulesAndChain.Chain.Prefix = "some-very-long-chain-prefix"
+			})
+
+			It("cleans up the managed chain and returns an error", func() {
+				_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+				Expect(err).To(MatchError("creating sub-chains: sub-chain name some-very-long-chain-prefix42-0 is longer than 28 characters"))
+
+				Expect(iptables.NewChainCallCount()).To(Equal(1))
+				_, chainName := iptables.DeleteChainArgsForCall(0)
+				Expect(chainName).To(Equal("some-very-long-chain-prefix42"))
+				Expect(iptables.BulkInsertCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when there are too many sub-chains", func() {
+			BeforeEach(func() {
+				rulesAndChain.SubChains = make([]enforcer.SubChain, enforcer.MaxSubChains+1)
+			})
+
+			It("cleans up the managed chain and returns an error", func() {
+				_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+				Expect(err).To(MatchError("creating sub-chains: 37 sub-chains is more than 36"))
+
+				Expect(iptables.NewChainCallCount()).To(Equal(1))
+				Expect(iptables.DeleteChainCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when creating a sub-chain fails", func() {
+			BeforeEach(func() {
+				iptables.NewChainReturnsOnCall(2, errors.New("banana"))
+			})
+
+			It("cleans up the chains it created and returns an error", func() {
+				_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+				Expect(err).To(MatchError("creating sub-chains: creating sub-chain foo42-1: banana"))
+
+				Expect(iptables.DeleteChainCallCount()).To(Equal(2))
+				_, chainName := iptables.DeleteChainArgsForCall(0)
+				Expect(chainName).To(Equal("foo42-0"))
+				_, chainName = iptables.DeleteChainArgsForCall(1)
+				Expect(chainName).To(Equal("foo42"))
+				Expect(iptables.BulkInsertCallCount()).To(Equal(0))
+
+				Expect(logger).To(gbytes.Say("create-sub-chains.*banana"))
+			})
+		})
+
+		Context("when appending to a sub-chain fails", func() {
+			BeforeEach(func() {
+				iptables.BulkAppendReturnsOnCall(0, errors.New("banana"))
+			})
+
+			It("cleans up the chains it created and returns an error", func() {
+				_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+				Expect(err).To(MatchError("creating sub-chains: bulk appending to sub-chain foo42-0: banana"))
+
+				Expect(iptables.DeleteChainCallCount()).To(Equal(2))
+				_, chainName := iptables.DeleteChainArgsForCall(0)
+				Expect(chainName).To(Equal("foo42-0"))
+				_, chainName = iptables.DeleteChainArgsForCall(1)
+				Expect(chainName).To(Equal("foo42"))
+			})
+		})
+
+		Context("when an old chain with sub-chains is replaced", func() {
+			BeforeEach(func() {
+				iptables.ListStub = func(table, chain string) ([]string, error) {
+					switch chain {
+					case "some-chain":
+						return []string{"-A some-chain -j foo42", "-A some-chain -j foo0000000001"}, nil
+					case "foo0000000001":
+						return []string{"-A foo0000000001 -d 10.0.0.0/8 -g foo0000000001-0", "-A foo0000000001 -j ACCEPT"}, nil
+					case "foo0000000001-0":
+						return []string{"-A foo0000000001-0 -g some-log-chain"}, nil
+					}
+					return nil, nil
+				}
+			})
+
+			It("flushes and deletes the old sub-chains", func() {
+				_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+				Expect(err).NotTo(HaveOccurred())
+
+				var cleared, deleted []string
+				for i := 0; i < iptables.ClearChainCallCount(); i++ {
+					_, chainName := iptables.ClearChainArgsForCall(i)
+					cleared = append(cleared, chainName)
+				}
+				for i := 0; i < iptables.DeleteChainCallCount(); i++ {
+					_, chainName := iptables.DeleteChainArgsForCall(i)
+					deleted = append(deleted, chainName)
+				}
+				Expect(cleared).To(Equal([]string{"foo0000000001", "foo0000000001-0"}))
+				Expect(deleted).To(Equal([]string{"foo0000000001", "foo0000000001-0", "some-log-chain"}))
+			})
+		})
+	})
+
 	Describe("EnforceChainMatching", func() {
 
 		var (
-			iptables     *libfakes.IPTablesAdapter
-			timestamper  *fakes.TimeStamper
-			logger       *lagertest.TestLogger
-			ruleEnforcer *enforcer.Enforcer
-			fakeChain    []enforcer.LiveChain
+			iptables       *libfakes.IPTablesAdapter
+			timestamper    *fakes.TimeStamper
+			logger         *lagertest.TestLogger
+			ruleEnforcer   *enforcer.Enforcer
+			fakeChain      []enforcer.LiveChain
+			chainsForTable map[string][]string
 		)
 		BeforeEach(func() {
 
@@ -365,12 +632,12 @@ var _ = Describe("Enforcer", func() {
 				},
 			}
 
-			chainsForTable := map[string][]string{
-				"filter": []string{"asg-bbbbb01645708469990518", "asg-ccccc01645708469990518", "donttouchme"},
+			chainsForTable = map[string][]string{
+				"filter": []string{"asg-bbbbb01645708469990518", "asg-ccccc01645708469990518", "donttouchme", "asg-dddddd-custom", "xasg-eeeee01645708469990518"},
 				"mangle": []string{"reallydonttouchme", "asg-aaaaa01645708469990518"},
 			}
 			rulesForChain := map[string][]string{
-				"asg-ccccc01645708469990518": []string{"-A asg-ccccc01645708469990518 somefilter -g log-chain"},
+				"asg-ccccc01645708469990518": []string{"-A asg-ccccc01645708469990518 -d 10.0.0.0/8 -g log-chain"},
 			}
 
 			iptables.ListChainsStub = func(table string) ([]string, error) {
@@ -381,13 +648,15 @@ var _ = Describe("Enforcer", func() {
 			}
 		})
 
-		It("deletes orphaned chains on filter table", func() {
+		It("deletes orphaned chains", func() {
 			deletedChains, err := ruleEnforcer.CleanChainsMatching(regexp.MustCompile(planner.ASGManagedChainsRegex), fakeChain)
 
 			Expect(err).ToNot(HaveOccurred())
-			Expect(iptables.ListChainsCallCount()).To(Equal(1))
+			Expect(iptables.ListChainsCallCount()).To(Equal(4))
 
-			Expect(iptables.ListChainsArgsForCall(0)).To(Equal("filter"))
+			for i, table := range []string{"filter", "nat", "mangle", "raw"} {
+				Expect(iptables.ListChainsArgsForCall(i)).To(Equal(table))
+			}
 
 			Expect(iptables.DeleteChainCallCount()).To(Equal(2)) // once for the main chain, once for the log-chain it jumps to
 			table, chain := iptables.DeleteChainArgsForCall(0)
@@ -419,12 +688,85 @@ var _ = Describe("Enforcer", func() {
 		})
 
 		Context("when there are no desired chains", func() {
-			It("deletes alls chains on filter table matching pattern", func() {
+			It("deletes all chains matching pattern in every table", func() {
 				deletedChains, err := ruleEnforcer.CleanChainsMatching(regexp.MustCompile(planner.ASGManagedChainsRegex), []enforcer.LiveChain{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(deletedChains).To(ConsistOf([]enforcer.LiveChain{
 					{Table: "filter", Name: "asg-bbbbb01645708469990518"},
 					{Table: "filter", Name: "asg-ccccc01645708469990518"},
+					{Table: "mangle", Name: "asg-aaaaa01645708469990518"},
+				}))
+			})
+		})
+
+		Context("when other agents own some of the chains", func() {
+			BeforeEach(func() {
+				chainsForTable["filter"] = append(chainsForTable["filter"], "asg-ccccc01645708469990519-0")
+				ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{
+					OwnsChain: func(name string) bool {
+						return !strings.HasPrefix(name, "asg-ccccc0")
+					},
+				})
+			})
+
+			It("leaves their chains and sub-chains alone", func() {
+				deletedChains, err := ruleEnforcer.CleanChainsMatching(regexp.MustCompile(planner.ASGManagedChainsRegex), []enforcer.LiveChain{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deletedChains).To(ConsistOf([]enforcer.LiveChain{
+					{Table: "filter", Name: "asg-bbbbb01645708469990518"},
+					{Table: "mangle", Name: "asg-aaaaa01645708469990518"},
+				}))
+			})
+		})
+
+		Context("when a desired chain has chains in other tables", func() {
+			BeforeEach(func() {
+				chainsForTable["nat"] = []string{"asg-bbbbb01645708469990518", "asg-ggggg01645708469990518"}
+			})
+
+			It("keeps the chains of the same name and deletes the others", func() {
+				deletedChains, err := ruleEnforcer.CleanChainsMatching(regexp.MustCompile(planner.ASGManagedChainsRegex), fakeChain[0:1])
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deletedChains).To(ConsistOf([]enforcer.LiveChain{
+					{Table: "filter", Name: "asg-ccccc01645708469990518"},
+					{Table: "nat", Name: "asg-ggggg01645708469990518"},
+					{Table: "mangle", Name: "asg-aaaaa01645708469990518"},
+				}))
+			})
+		})
+
+		Context("when listing the chains of another table fails", func() {
+			BeforeEach(func() {
+				iptables.ListChainsStub = func(table string) ([]string, error) {
+					if table == "mangle" {
+						return nil, fmt.Errorf("iptables list error")
+					}
+					return chainsForTable[table], nil
+				}
+			})
+
+			It("returns an error without deleting anything", func() {
+				_, err := ruleEnforcer.CleanChainsMatching(regexp.MustCompile(planner.ASGManagedChainsRegex), fakeChain)
+				Expect(err).To(MatchError("listing chains in mangle: iptables list error"))
+				Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when sub-chains are left behind without their managed chain", func() {
+			BeforeEach(func() {
+				chainsForTable["filter"] = []string{
+					"asg-bbbbb01645708469990518", "asg-bbbbb01645708469990518-0",
+					"asg-ccccc01645708469990518", "asg-ccccc01645708469990518-0",
+					"asg-fffff01645708469990518-0",
+				}
+			})
+
+			It("deletes the orphaned sub-chains and leaves the others to their managed chain", func() {
+				deletedChains, err := ruleEnforcer.CleanChainsMatching(regexp.MustCompile(planner.ASGManagedChainsRegex), fakeChain)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deletedChains).To(Equal([]enforcer.LiveChain{
+					{Table: "filter", Name: "asg-ccccc01645708469990518"},
+					{Table: "filter", Name: "asg-fffff01645708469990518-0"},
 				}))
 			})
 		})
@@ -471,6 +813,392 @@ var _ = Describe("Enforcer", func() {
 		})
 
 	})
+	Describe("RepairDuplicateJumps", func() {
+		var (
+			iptables     *libfakes.IPTablesAdapter
+			logger       *lagertest.TestLogger
+			ruleEnforcer *enforcer.Enforcer
+			chain        enforcer.Chain
+			parentRules  []string
+		)
+
+		BeforeEach(func() {
+			logger = lagertest.NewTestLogger("test")
+			iptables = &libfakes.IPTablesAdapter{}
+			ruleEnforcer = enforcer.NewEnforcer(logger, &fakes.TimeStamper{}, iptables, enforcer.EnforcerConfig{})
+
+			chain = enforcer.Chain{
+				Table:              "filter",
+				ParentChain:        "netout-some-handle",
+				Prefix:             "asg-abcdef",
+				ManagedChainsRegex: planner.ASGManagedChainsRegex,
+			}
+			parentRules = []string{
+				"-N netout-some-handle",
+				"-A netout-some-handle -j asg-abcdef1645708469990518",
+				"-A netout-some-handle -j asg-abcdef1645708469990999",
+				"-A netout-some-handle -j REJECT --reject-with icmp-port-unreachable",
+			}
+			iptables.ListStub = func(table, chain string) ([]string, error) {
+				if chain == "netout-some-handle" {
+					return parentRules, nil
+				}
+				return []string{"-N " + chain}, nil
+			}
+		})
+
+		It("keeps the jump to the newest chain and removes the stale chain", func() {
+			removed, err := ruleEnforcer.RepairDuplicateJumps(chain)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(Equal(1))
+
+			Expect(iptables.DeleteCallCount()).To(Equal(1))
+			table, parentChain, rule := iptables.DeleteArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(parentChain).To(Equal("netout-some-handle"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-j", "asg-abcdef1645708469990518"}))
+
+			Expect(iptables.ClearChainCallCount()).To(Equal(1))
+			_, clearedChain := iptables.ClearChainArgsForCall(0)
+			Expect(clearedChain).To(Equal("asg-abcdef1645708469990518"))
+			Expect(iptables.DeleteChainCallCount()).To(Equal(1))
+			_, deletedChain := iptables.DeleteChainArgsForCall(0)
+			Expect(deletedChain).To(Equal("asg-abcdef1645708469990518"))
+		})
+
+		Context("with a context", func() {
+			It("makes the iptables calls with the context", func() {
+				ctx := rules.WithOperation(context.Background(), "repair-duplicate-jumps")
+				iptables.WithContextStub = func(context.Context) rules.IPTablesAdapter {
+					return iptables
+				}
+
+				removed, err := ruleEnforcer.RepairDuplicateJumpsContext(ctx, chain)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(removed).To(Equal(1))
+
+				Expect(iptables.WithContextCallCount()).To(Equal(1))
+				Expect(iptables.WithContextArgsForCall(0)).To(Equal(ctx))
+			})
+		})
+
+		Context("when the jumps carry matches", func() {
+			BeforeEach(func() {
+				parentRules = []string{
+					"-N netout-some-handle",
+					"-A netout-some-handle -m comment --comment \"asg sync\" -j asg-abcdef1645708469990518",
+					"-A netout-some-handle -m comment --comment \"asg sync\" -j asg-abcdef1645708469990999",
+				}
+			})
+
+			It("removes the jump by its full rule spec", func() {
+				removed, err := ruleEnforcer.RepairDuplicateJumps(chain)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(removed).To(Equal(1))
+
+				_, _, rule := iptables.DeleteArgsForCall(0)
+				Expect(rule).To(Equal(rules.IPTablesRule{"-m", "comment", "--comment", "asg sync", "-j", "asg-abcdef1645708469990518"}))
+			})
+		})
+
+		Context("when the same chain is jumped to more than once", func() {
+			BeforeEach(func() {
+				parentRules = []string{
+					"-N netout-some-handle",
+					"-A netout-some-handle -j asg-abcdef1645708469990999",
+					"-A netout-some-handle -j asg-abcdef1645708469990999",
+				}
+			})
+
+			It("removes the extra jump but keeps the chain", func() {
+				removed, err := ruleEnforcer.RepairDuplicateJumps(chain)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(removed).To(Equal(1))
+
+				Expect(iptables.DeleteCallCount()).To(Equal(1))
+				_, _, rule := iptables.DeleteArgsForCall(0)
+				Expect(rule).To(Equal(rules.IPTablesRule{"-j", "asg-abcdef1645708469990999"}))
+				Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when there is a single jump", func() {
+			BeforeEach(func() {
+				parentRules = []string{
+					"-N netout-some-handle",
+					"-A netout-some-handle -j asg-abcdef1645708469990999",
+					"-A netout-some-handle -j REJECT --reject-with icmp-port-unreachable",
+				}
+			})
+
+			It("does nothing", func() {
+				removed, err := ruleEnforcer.RepairDuplicateJumps(chain)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(removed).To(Equal(0))
+				Expect(iptables.DeleteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the chain has no managed chains regex", func() {
+			BeforeEach(func() {
+				chain = enforcer.Chain{Table: "filter", ParentChain: "FORWARD", Prefix: "vpa--"}
+				parentRules = []string{
+					"-P FORWARD ACCEPT",
+					"-A FORWARD -j vpa--1645708469990999",
+					"-A FORWARD -j silk-egress",
+					"-A FORWARD -j vpa--1645708469990518",
+				}
+				iptables.ListStub = func(table, chain string) ([]string, error) {
+					if chain == "FORWARD" {
+						return parentRules, nil
+					}
+					return []string{"-N " + chain}, nil
+				}
+			})
+
+			It("matches jumps by the prefix", func() {
+				removed, err := ruleEnforcer.RepairDuplicateJumps(chain)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(removed).To(Equal(1))
+
+				_, parentChain, rule := iptables.DeleteArgsForCall(0)
+				Expect(parentChain).To(Equal("FORWARD"))
+				Expect(rule).To(Equal(rules.IPTablesRule{"-j", "vpa--1645708469990518"}))
+			})
+		})
+
+		Context("when listing the parent chain fails", func() {
+			BeforeEach(func() {
+				iptables.ListStub = nil
+				iptables.ListReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := ruleEnforcer.RepairDuplicateJumps(chain)
+				Expect(err).To(MatchError("listing parent chain: banana"))
+			})
+		})
+
+		Context("when removing a jump fails", func() {
+			BeforeEach(func() {
+				iptables.DeleteReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := ruleEnforcer.RepairDuplicateJumps(chain)
+				Expect(err).To(MatchError("remove duplicate jump to asg-abcdef1645708469990518: banana"))
+			})
+		})
+
+		Context("when deleting the stale chain fails", func() {
+			BeforeEach(func() {
+				iptables.DeleteChainReturns(errors.New("banana"))
+			})
+
+			It("returns an error with the number of removed jumps", func() {
+				removed, err := ruleEnforcer.RepairDuplicateJumps(chain)
+				Expect(err).To(MatchError("delete stale chain asg-abcdef1645708469990518: delete old chain: banana"))
+				Expect(removed).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("CleanChainsWithoutPrefix", func() {
+		var (
+			iptables      *libfakes.IPTablesAdapter
+			logger        *lagertest.TestLogger
+			ruleEnforcer  *enforcer.Enforcer
+			rulesForChain map[string][]string
+		)
+
+		BeforeEach(func() {
+			logger = lagertest.NewTestLogger("test")
+			iptables = &libfakes.IPTablesAdapter{}
+			ruleEnforcer = enforcer.NewEnforcer(logger, &fakes.TimeStamper{}, iptables, enforcer.EnforcerConfig{})
+
+			iptables.ListChainsReturns([]string{
+				"PREROUTING",
+				"POSTROUTING",
+				"vpa--1645708469990518",
+				"keep--1645708469990518",
+				"gone--1645708469990518",
+				"gone--1645708469990999",
+				"donttouch--me",
+			}, nil)
+			rulesForChain = map[string][]string{
+				"PREROUTING": {
+					"-P PREROUTING ACCEPT",
+					"-A PREROUTING -j keep--1645708469990518",
+					"-A PREROUTING -m comment --comment \"site rules\" -j gone--1645708469990518",
+				},
+				"POSTROUTING": {
+					"-P POSTROUTING ACCEPT",
+					"-A POSTROUTING -j gone--1645708469990999",
+				},
+			}
+			iptables.ListStub = func(table, chain string) ([]string, error) {
+				return rulesForChain[chain], nil
+			}
+		})
+
+		It("deletes the jumps to and the chains without a desired prefix", func() {
+			deleted, err := ruleEnforcer.CleanChainsWithoutPrefix("nat", regexp.MustCompile("[a-z][a-z0-9]{0,9}--"), []string{"vpa--", "keep--"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted).To(Equal([]enforcer.LiveChain{
+				{Table: "nat", Name: "gone--1645708469990518"},
+				{Table: "nat", Name: "gone--1645708469990999"},
+			}))
+
+			Expect(iptables.ListChainsArgsForCall(0)).To(Equal("nat"))
+
+			Expect(iptables.DeleteCallCount()).To(Equal(2))
+			table, chain, rule := iptables.DeleteArgsForCall(0)
+			Expect(table).To(Equal("nat"))
+			Expect(chain).To(Equal("PREROUTING"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-m", "comment", "--comment", "site rules", "-j", "gone--1645708469990518"}))
+			_, chain, rule = iptables.DeleteArgsForCall(1)
+			Expect(chain).To(Equal("POSTROUTING"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-j", "gone--1645708469990999"}))
+
+			Expect(iptables.DeleteChainCallCount()).To(Equal(2))
+			table, chain = iptables.DeleteChainArgsForCall(0)
+			Expect(table).To(Equal("nat"))
+			Expect(chain).To(Equal("gone--1645708469990518"))
+			_, chain = iptables.DeleteChainArgsForCall(1)
+			Expect(chain).To(Equal("gone--1645708469990999"))
+		})
+
+		Context("when every managed chain has a desired prefix", func() {
+			It("does not list the chains", func() {
+				deleted, err := ruleEnforcer.CleanChainsWithoutPrefix("nat", regexp.MustCompile("[a-z][a-z0-9]{0,9}--"), []string{"vpa--", "keep--", "gone--"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(deleted).To(BeEmpty())
+				Expect(iptables.ListCallCount()).To(Equal(0))
+				Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when listing the chains fails", func() {
+			BeforeEach(func() {
+				iptables.ListChainsReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := ruleEnforcer.CleanChainsWithoutPrefix("nat", regexp.MustCompile("[a-z][a-z0-9]{0,9}--"), []string{"vpa--"})
+				Expect(err).To(MatchError("listing chains in nat: banana"))
+			})
+		})
+
+		Context("when deleting a jump fails", func() {
+			BeforeEach(func() {
+				iptables.DeleteReturns(errors.New("banana"))
+			})
+
+			It("returns an error without deleting the chains", func() {
+				_, err := ruleEnforcer.CleanChainsWithoutPrefix("nat", regexp.MustCompile("[a-z][a-z0-9]{0,9}--"), []string{"vpa--", "keep--"})
+				Expect(err).To(MatchError("deleting jump from PREROUTING: banana"))
+				Expect(iptables.DeleteChainCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("DeleteChains", func() {
+		var (
+			iptables     *libfakes.IPTablesAdapter
+			ruleEnforcer *enforcer.Enforcer
+		)
+
+		BeforeEach(func() {
+			iptables = &libfakes.IPTablesAdapter{}
+			ruleEnforcer = enforcer.NewEnforcer(lagertest.NewTestLogger("test"), &fakes.TimeStamper{}, iptables, enforcer.EnforcerConfig{})
+
+			iptables.ListChainsStub = func(table string) ([]string, error) {
+				if table == "nat" {
+					return []string{"PREROUTING", "netin--some-handle"}, nil
+				}
+				return []string{"FORWARD", "netout--some-handle", "netout--some-handle--log", "asg-123456"}, nil
+			}
+			rulesForChain := map[string][]string{
+				"PREROUTING": {
+					"-P PREROUTING ACCEPT",
+					"-A PREROUTING -j netin--some-handle",
+				},
+				"FORWARD": {
+					"-P FORWARD ACCEPT",
+					"-A FORWARD -s 10.255.0.1/32 -o eth0 -j netout--some-handle",
+				},
+				"asg-123456": {
+					"-N asg-123456",
+					"-A asg-123456 -g netout--some-handle--log",
+				},
+			}
+			iptables.ListStub = func(table, chain string) ([]string, error) {
+				return rulesForChain[chain], nil
+			}
+		})
+
+		It("deletes the jumps to the chains, then flushes and deletes the chains that exist", func() {
+			deleted, err := ruleEnforcer.DeleteChains([]enforcer.LiveChain{
+				{Table: "filter", Name: "netout--some-handle"},
+				{Table: "filter", Name: "netout--some-handle--log"},
+				{Table: "filter", Name: "overlay--some-handle"},
+				{Table: "nat", Name: "netin--some-handle"},
+				{Table: "mangle", Name: "netin--some-handle"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted).To(Equal([]enforcer.LiveChain{
+				{Table: "filter", Name: "netout--some-handle"},
+				{Table: "filter", Name: "netout--some-handle--log"},
+				{Table: "nat", Name: "netin--some-handle"},
+			}))
+
+			Expect(iptables.DeleteCallCount()).To(Equal(3))
+			table, chain, rule := iptables.DeleteArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("FORWARD"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-s", "10.255.0.1/32", "-o", "eth0", "-j", "netout--some-handle"}))
+			_, chain, rule = iptables.DeleteArgsForCall(1)
+			Expect(chain).To(Equal("asg-123456"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-g", "netout--some-handle--log"}))
+			table, chain, rule = iptables.DeleteArgsForCall(2)
+			Expect(table).To(Equal("nat"))
+			Expect(chain).To(Equal("PREROUTING"))
+			Expect(rule).To(Equal(rules.IPTablesRule{"-j", "netin--some-handle"}))
+
+			Expect(iptables.ClearChainCallCount()).To(Equal(3))
+			Expect(iptables.DeleteChainCallCount()).To(Equal(3))
+			_, chain = iptables.ClearChainArgsForCall(1)
+			Expect(chain).To(Equal("netout--some-handle--log"))
+			_, chain = iptables.DeleteChainArgsForCall(0)
+			Expect(chain).To(Equal("netout--some-handle"))
+		})
+
+		Context("when none of the chains exist", func() {
+			It("does not list the rules of the table", func() {
+				deleted, err := ruleEnforcer.DeleteChains([]enforcer.LiveChain{{Table: "mangle", Name: "netin--some-handle"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(deleted).To(BeEmpty())
+				Expect(iptables.ListCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when deleting a chain fails", func() {
+			BeforeEach(func() {
+				iptables.DeleteChainReturnsOnCall(1, errors.New("banana"))
+			})
+
+			It("returns the chains deleted so far with the error", func() {
+				deleted, err := ruleEnforcer.DeleteChains([]enforcer.LiveChain{
+					{Table: "filter", Name: "netout--some-handle"},
+					{Table: "filter", Name: "netout--some-handle--log"},
+					{Table: "nat", Name: "netin--some-handle"},
+				})
+				Expect(err).To(MatchError("deleting chain netout--some-handle--log from table filter: banana"))
+				Expect(deleted).To(Equal([]enforcer.LiveChain{{Table: "filter", Name: "netout--some-handle"}}))
+			})
+		})
+	})
+
 	Describe("RulesWithChain", func() {
 		Describe("Equals", func() {
 			var ruleSet, otherRuleSet enforcer.RulesWithChain
@@ -529,6 +1257,44 @@ var _ = Describe("Enforcer", func() {
 				})
 			})
 
+			Context("when the sub-chains are different", func() {
+				BeforeEach(func() {
+					ruleSet.SubChains = []enforcer.SubChain{{Conditions: rules.IPTablesRule{"-p", "tcp"}, Rules: []rules.IPTablesRule{{"rule2"}}}}
+					otherRuleSet.SubChains = []enforcer.SubChain{{Conditions: rules.IPTablesRule{"-p", "tcp"}, Rules: []rules.IPTablesRule{{"rule2"}}}}
+				})
+				It("compares their conditions and rules", func() {
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeTrue())
+
+					otherRuleSet.SubChains[0].Conditions = rules.IPTablesRule{"-p", "udp"}
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeFalse())
+
+					otherRuleSet.SubChains = []enforcer.SubChain{{Conditions: rules.IPTablesRule{"-p", "tcp"}, Rules: []rules.IPTablesRule{{"rule3"}}}}
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeFalse())
+
+					otherRuleSet.SubChains = nil
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeFalse())
+				})
+			})
+
+			Context("when the rules of other tables are different", func() {
+				BeforeEach(func() {
+					ruleSet.ExtraTables = []enforcer.TableRules{{Chain: enforcer.Chain{Table: "mangle"}, Rules: []rules.IPTablesRule{{"rule2"}}}}
+					otherRuleSet.ExtraTables = []enforcer.TableRules{{Chain: enforcer.Chain{Table: "mangle"}, Rules: []rules.IPTablesRule{{"rule2"}}}}
+				})
+				It("compares their chains and rules", func() {
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeTrue())
+
+					otherRuleSet.ExtraTables[0].Chain.Table = "nat"
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeFalse())
+
+					otherRuleSet.ExtraTables = []enforcer.TableRules{{Chain: enforcer.Chain{Table: "mangle"}, Rules: []rules.IPTablesRule{{"rule3"}}}}
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeFalse())
+
+					otherRuleSet.ExtraTables = nil
+					Expect(ruleSet.Equals(otherRuleSet)).To(BeFalse())
+				})
+			})
+
 			Context("when the rule sets are different lengths", func() {
 				BeforeEach(func() {
 					otherRuleSet.Rules = []rules.IPTablesRule{[]string{"rule1", "other-rule"}}
@@ -538,5 +1304,47 @@ var _ = Describe("Enforcer", func() {
 				})
 			})
 		})
+
+		Describe("Hash", func() {
+			var ruleSet, otherRuleSet enforcer.RulesWithChain
+
+			BeforeEach(func() {
+				ruleSet = enforcer.RulesWithChain{
+					Chain: enforcer.Chain{
+						Table:       "table",
+						ParentChain: "parent",
+						Prefix:      "prefix",
+					},
+					Rules:       []rules.IPTablesRule{{"rule1"}},
+					SubChains:   []enforcer.SubChain{{Conditions: rules.IPTablesRule{"-p", "tcp"}, Rules: []rules.IPTablesRule{{"rule2"}}}},
+					ExtraTables: []enforcer.TableRules{{Chain: enforcer.Chain{Table: "mangle"}, Rules: []rules.IPTablesRule{{"rule3"}}}},
+					Handle:      "some-handle",
+				}
+				otherRuleSet = ruleSet
+				otherRuleSet.Handle = "other-handle"
+			})
+
+			It("is the same for equal rule sets", func() {
+				Expect(ruleSet.Hash()).To(HaveLen(64))
+				Expect(ruleSet.Hash()).To(Equal(otherRuleSet.Hash()))
+			})
+
+			It("differs when the rules differ", func() {
+				otherRuleSet.Rules = []rules.IPTablesRule{{"rule1", "other-rule"}}
+				Expect(ruleSet.Hash()).NotTo(Equal(otherRuleSet.Hash()))
+
+				otherRuleSet.Rules = []rules.IPTablesRule{{"rule1"}, {"other-rule"}}
+				Expect(ruleSet.Hash()).NotTo(Equal(otherRuleSet.Hash()))
+			})
+
+			It("differs when the sub-chains or the rules of other tables differ", func() {
+				otherRuleSet.SubChains = nil
+				Expect(ruleSet.Hash()).NotTo(Equal(otherRuleSet.Hash()))
+
+				otherRuleSet = ruleSet
+				otherRuleSet.ExtraTables = []enforcer.TableRules{{Chain: enforcer.Chain{Table: "nat"}, Rules: []rules.IPTablesRule{{"rule3"}}}}
+				Expect(ruleSet.Hash()).NotTo(Equal(otherRuleSet.Hash()))
+			})
+		})
 	})
 })
@@ -0,0 +1,149 @@
+package enforcer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const OperationEnforce = "enforce"
+
+// Intent is a change of iptables that the enforcer records before it makes
+// it, and clears once the change is complete or rolled back. An intent that
+// is still pending when the agent starts was interrupted, e.g. by a crash
+// between inserting the jump to a new chain and appending its rules, and is
+// repaired by RecoverIntents.
+type Intent struct {
+	ID        string        `json:"id"`
+	Operation string        `json:"operation"`
+	Chains    []IntentChain `json:"chains"`
+	RulesHash string        `json:"rules_hash"`
+}
+
+// IntentChain is a new chain of an intent. Rules is the number of rules the
+// chain has once it is complete.
+type IntentChain struct {
+	Table       string `json:"table"`
+	ParentChain string `json:"parent_chain"`
+	Name        string `json:"name"`
+	Rules       int    `json:"rules"`
+}
+
+func newIntent(operation, rulesHash string, chains []IntentChain) Intent {
+	return Intent{
+		ID:        chains[0].Table + "/" + chains[0].Name,
+		Operation: operation,
+		Chains:    chains,
+		RulesHash: rulesHash,
+	}
+}
+
+//go:generate counterfeiter -o fakes/intent_log.go --fake-name IntentLog . IntentLog
+type IntentLog interface {
+	Begin(Intent) error
+	Complete(id string) error
+	Pending() ([]Intent, error)
+}
+
+// FileIntentLog keeps the pending intents in a JSON file. Every change is
+// synced to disk and renamed into place, so that the file holds either the
+// intents before or after the change when the agent crashes.
+type FileIntentLog struct {
+	Path string
+
+	lock sync.Mutex
+}
+
+func (l *FileIntentLog) Begin(intent Intent) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	intents, err := l.read()
+	if err != nil {
+		return err
+	}
+	intents[intent.ID] = intent
+	return l.write(intents)
+}
+
+func (l *FileIntentLog) Complete(id string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	intents, err := l.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := intents[id]; !ok {
+		return nil
+	}
+	delete(intents, id)
+	return l.write(intents)
+}
+
+// Pending returns the pending intents ordered by their ids.
+func (l *FileIntentLog) Pending() ([]Intent, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	intents, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	pending := []Intent{}
+	for _, intent := range intents {
+		pending = append(pending, intent)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	return pending, nil
+}
+
+func (l *FileIntentLog) read() (map[string]Intent, error) {
+	intents := map[string]Intent{}
+	contents, err := os.ReadFile(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return intents, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading intent log: %s", err)
+	}
+	if len(contents) == 0 {
+		return intents, nil
+	}
+	if err := json.Unmarshal(contents, &intents); err != nil {
+		return nil, fmt.Errorf("reading intent log: %s", err)
+	}
+	return intents, nil
+}
+
+func (l *FileIntentLog) write(intents map[string]Intent) error {
+	contents, err := json.Marshal(intents)
+	if err != nil {
+		return fmt.Errorf("writing intent log: %s", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.Path), filepath.Base(l.Path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("writing intent log: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(contents)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("writing intent log: %s", err)
+	}
+	return nil
+}
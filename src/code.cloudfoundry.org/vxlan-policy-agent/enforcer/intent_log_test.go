@@ -0,0 +1,89 @@
+package enforcer_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FileIntentLog", func() {
+	var (
+		path      string
+		intentLog *enforcer.FileIntentLog
+		intent    enforcer.Intent
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "iptables-intents.json")
+		intentLog = &enforcer.FileIntentLog{Path: path}
+		intent = enforcer.Intent{
+			ID:        "filter/foo42",
+			Operation: enforcer.OperationEnforce,
+			Chains:    []enforcer.IntentChain{{Table: "filter", ParentChain: "FORWARD", Name: "foo42", Rules: 2}},
+			RulesHash: "some-hash",
+		}
+	})
+
+	It("has no pending intents without a file", func() {
+		Expect(intentLog.Pending()).To(BeEmpty())
+	})
+
+	It("keeps the begun intents pending until they are complete", func() {
+		other := intent
+		other.ID = "filter/bar42"
+		Expect(intentLog.Begin(intent)).To(Succeed())
+		Expect(intentLog.Begin(other)).To(Succeed())
+		Expect(intentLog.Pending()).To(Equal([]enforcer.Intent{other, intent}))
+
+		Expect(intentLog.Complete(other.ID)).To(Succeed())
+		Expect(intentLog.Pending()).To(Equal([]enforcer.Intent{intent}))
+	})
+
+	It("keeps the pending intents across restarts", func() {
+		Expect(intentLog.Begin(intent)).To(Succeed())
+
+		restarted := &enforcer.FileIntentLog{Path: path}
+		Expect(restarted.Pending()).To(Equal([]enforcer.Intent{intent}))
+	})
+
+	It("ignores completing an intent that is not pending", func() {
+		Expect(intentLog.Complete("filter/unknown")).To(Succeed())
+		Expect(intentLog.Pending()).To(BeEmpty())
+	})
+
+	It("leaves no temporary files behind", func() {
+		Expect(intentLog.Begin(intent)).To(Succeed())
+		Expect(intentLog.Complete(intent.ID)).To(Succeed())
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Name()).To(Equal("iptables-intents.json"))
+	})
+
+	Context("when the file cannot be parsed", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(path, []byte("{"), 0600)).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			_, err := intentLog.Pending()
+			Expect(err).To(MatchError(ContainSubstring("reading intent log")))
+			Expect(intentLog.Begin(intent)).To(MatchError(ContainSubstring("reading intent log")))
+		})
+	})
+
+	Context("when the file cannot be written", func() {
+		BeforeEach(func() {
+			intentLog.Path = filepath.Join(filepath.Dir(path), "missing-dir", "iptables-intents.json")
+		})
+
+		It("returns an error", func() {
+			Expect(intentLog.Begin(intent)).To(MatchError(ContainSubstring("writing intent log")))
+		})
+	})
+})
@@ -0,0 +1,143 @@
+package enforcer
+
+import (
+	"fmt"
+	"regexp"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/hashicorp/go-multierror"
+)
+
+//go:generate counterfeiter -o fakes/planner.go --fake-name Planner . Planner
+//go:generate counterfeiter -o fakes/applier.go --fake-name Applier . Applier
+//go:generate counterfeiter -o fakes/cleaner.go --fake-name Cleaner . Cleaner
+
+// Planner plans the rule sets that should be enforced, one per managed chain.
+type Planner interface {
+	Plan() ([]RulesWithChain, error)
+}
+
+// Differ compares the rule set that was enforced last for a chain with the
+// planned one, and reports whether the planned one has to be applied.
+type Differ interface {
+	Diff(enforced, planned RulesWithChain) (RuleSetDiff, bool)
+}
+
+// Applier enforces a rule set in a new managed chain, swaps the jump from the
+// parent chain to it and returns its name.
+type Applier interface {
+	Apply(RulesWithChain) (string, error)
+}
+
+// Cleaner deletes the managed chains matching regex that are not desired,
+// and returns the deleted chains.
+type Cleaner interface {
+	Cleanup(regex *regexp.Regexp, desiredChains []LiveChain) ([]LiveChain, error)
+}
+
+var _ Applier = &Enforcer{}
+var _ Cleaner = &Enforcer{}
+var _ Differ = RuleSetDiffer{}
+
+// Apply is EnforceRulesAndChain.
+func (e *Enforcer) Apply(rulesAndChain RulesWithChain) (string, error) {
+	return e.EnforceRulesAndChain(rulesAndChain)
+}
+
+// Cleanup is CleanChainsMatching.
+func (e *Enforcer) Cleanup(regex *regexp.Regexp, desiredChains []LiveChain) ([]LiveChain, error) {
+	return e.CleanChainsMatching(regex, desiredChains)
+}
+
+// CycleResult lists the chains a cycle of a Lifecycle created and deleted.
+type CycleResult struct {
+	Applied []LiveChain
+	Deleted []LiveChain
+}
+
+// Lifecycle runs the plan, diff, apply and cleanup phases of the managed
+// chains of an agent. It remembers the rule sets it applied, so that a cycle
+// only applies the rule sets that changed since. A rule set that fails to
+// apply is applied again in the next cycle, while the other rule sets of the
+// cycle are still applied.
+type Lifecycle struct {
+	Planner Planner
+	Applier Applier
+	Cleaner Cleaner
+	// Differ defaults to RuleSetDiffer.
+	Differ Differ
+	// ManagedChainsRegex matches the chains that the cleanup phase deletes
+	// when they are not planned anymore. Without it, no chain is deleted.
+	ManagedChainsRegex *regexp.Regexp
+	Logger             lager.Logger
+
+	enforced map[LiveChain]RulesWithChain
+	chains   map[LiveChain]string
+}
+
+// Cycle runs the phases once. Lifecycle is not safe to be cycled
+// concurrently.
+func (l *Lifecycle) Cycle() (CycleResult, error) {
+	logger := l.Logger.Session("cycle")
+	if l.enforced == nil {
+		l.enforced = map[LiveChain]RulesWithChain{}
+		l.chains = map[LiveChain]string{}
+	}
+	differ := l.Differ
+	if differ == nil {
+		differ = RuleSetDiffer{}
+	}
+
+	ruleSets, err := l.Planner.Plan()
+	if err != nil {
+		return CycleResult{}, fmt.Errorf("plan: %s", err)
+	}
+
+	var result CycleResult
+	var errs error
+	planned := map[LiveChain]struct{}{}
+	var desiredChains []LiveChain
+	for _, ruleSet := range ruleSets {
+		key := LiveChain{Table: ruleSet.Chain.Table, Name: ruleSet.Chain.ParentChain}
+		planned[key] = struct{}{}
+
+		diff, changed := differ.Diff(l.enforced[key], ruleSet)
+		if changed {
+			logger.Info("apply", lager.Data{
+				"chain":   ruleSet.Chain.ParentChain,
+				"table":   ruleSet.Chain.Table,
+				"added":   diff.Added,
+				"removed": diff.Removed,
+			})
+			chain, err := l.Applier.Apply(ruleSet)
+			if _, ok := err.(*CleanupErr); err == nil || ok {
+				l.enforced[key] = ruleSet
+				l.chains[key] = chain
+				result.Applied = append(result.Applied, LiveChain{Table: ruleSet.Chain.Table, Name: chain})
+			}
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("apply %s: %s", ruleSet.Chain.ParentChain, err))
+			}
+		}
+		if chain, ok := l.chains[key]; ok {
+			desiredChains = append(desiredChains, LiveChain{Table: ruleSet.Chain.Table, Name: chain})
+		}
+	}
+
+	for key := range l.enforced {
+		if _, ok := planned[key]; !ok {
+			delete(l.enforced, key)
+			delete(l.chains, key)
+		}
+	}
+
+	if l.ManagedChainsRegex != nil {
+		deleted, err := l.Cleaner.Cleanup(l.ManagedChainsRegex, desiredChains)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("cleanup: %s", err))
+		}
+		result.Deleted = deleted
+	}
+
+	return result, errs
+}
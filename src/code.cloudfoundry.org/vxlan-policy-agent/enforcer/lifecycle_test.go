@@ -0,0 +1,199 @@
+package enforcer_test
+
+import (
+	"errors"
+	"regexp"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lifecycle", func() {
+	var (
+		planner   *fakes.Planner
+		applier   *fakes.Applier
+		cleaner   *fakes.Cleaner
+		lifecycle *enforcer.Lifecycle
+
+		ruleSetA enforcer.RulesWithChain
+		ruleSetB enforcer.RulesWithChain
+	)
+
+	BeforeEach(func() {
+		planner = &fakes.Planner{}
+		applier = &fakes.Applier{}
+		cleaner = &fakes.Cleaner{}
+		lifecycle = &enforcer.Lifecycle{
+			Planner:            planner,
+			Applier:            applier,
+			Cleaner:            cleaner,
+			ManagedChainsRegex: regexp.MustCompile("^some-"),
+			Logger:             lagertest.NewTestLogger("test"),
+		}
+
+		ruleSetA = enforcer.RulesWithChain{
+			Chain: enforcer.Chain{Table: "filter", ParentChain: "chain-a", Prefix: "some-"},
+			Rules: []rules.IPTablesRule{{"-j", "ACCEPT"}},
+		}
+		ruleSetB = enforcer.RulesWithChain{
+			Chain: enforcer.Chain{Table: "filter", ParentChain: "chain-b", Prefix: "some-"},
+			Rules: []rules.IPTablesRule{{"-j", "REJECT"}},
+		}
+		planner.PlanReturns([]enforcer.RulesWithChain{ruleSetA, ruleSetB}, nil)
+		applier.ApplyStub = func(ruleSet enforcer.RulesWithChain) (string, error) {
+			return "some-" + ruleSet.Chain.ParentChain + "-1", nil
+		}
+	})
+
+	It("applies the planned rule sets and cleans up the chains that are not desired", func() {
+		result, err := lifecycle.Cycle()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(applier.ApplyCallCount()).To(Equal(2))
+		Expect(applier.ApplyArgsForCall(0)).To(Equal(ruleSetA))
+		Expect(applier.ApplyArgsForCall(1)).To(Equal(ruleSetB))
+		Expect(result.Applied).To(Equal([]enforcer.LiveChain{
+			{Table: "filter", Name: "some-chain-a-1"},
+			{Table: "filter", Name: "some-chain-b-1"},
+		}))
+
+		Expect(cleaner.CleanupCallCount()).To(Equal(1))
+		regex, desired := cleaner.CleanupArgsForCall(0)
+		Expect(regex.String()).To(Equal("^some-"))
+		Expect(desired).To(ConsistOf(
+			enforcer.LiveChain{Table: "filter", Name: "some-chain-a-1"},
+			enforcer.LiveChain{Table: "filter", Name: "some-chain-b-1"},
+		))
+	})
+
+	It("only applies the rule sets that changed since the last cycle", func() {
+		_, err := lifecycle.Cycle()
+		Expect(err).NotTo(HaveOccurred())
+
+		changed := ruleSetB
+		changed.Rules = []rules.IPTablesRule{{"-j", "DROP"}}
+		planner.PlanReturns([]enforcer.RulesWithChain{ruleSetA, changed}, nil)
+
+		result, err := lifecycle.Cycle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applier.ApplyCallCount()).To(Equal(3))
+		Expect(applier.ApplyArgsForCall(2)).To(Equal(changed))
+		Expect(result.Applied).To(Equal([]enforcer.LiveChain{{Table: "filter", Name: "some-chain-b-1"}}))
+
+		_, desired := cleaner.CleanupArgsForCall(1)
+		Expect(desired).To(HaveLen(2))
+	})
+
+	It("forgets the rule sets that are not planned anymore", func() {
+		_, err := lifecycle.Cycle()
+		Expect(err).NotTo(HaveOccurred())
+
+		planner.PlanReturns([]enforcer.RulesWithChain{ruleSetA}, nil)
+		_, err = lifecycle.Cycle()
+		Expect(err).NotTo(HaveOccurred())
+		_, desired := cleaner.CleanupArgsForCall(1)
+		Expect(desired).To(Equal([]enforcer.LiveChain{{Table: "filter", Name: "some-chain-a-1"}}))
+
+		planner.PlanReturns([]enforcer.RulesWithChain{ruleSetA, ruleSetB}, nil)
+		_, err = lifecycle.Cycle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applier.ApplyCallCount()).To(Equal(3))
+		Expect(applier.ApplyArgsForCall(2)).To(Equal(ruleSetB))
+	})
+
+	Context("when planning fails", func() {
+		BeforeEach(func() {
+			planner.PlanReturns(nil, errors.New("potato"))
+		})
+
+		It("returns the error without changing iptables", func() {
+			_, err := lifecycle.Cycle()
+			Expect(err).To(MatchError("plan: potato"))
+			Expect(applier.ApplyCallCount()).To(Equal(0))
+			Expect(cleaner.CleanupCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when applying a rule set fails", func() {
+		BeforeEach(func() {
+			applier.ApplyStub = func(ruleSet enforcer.RulesWithChain) (string, error) {
+				if ruleSet.Chain.ParentChain == "chain-a" {
+					return "", errors.New("potato")
+				}
+				return "some-" + ruleSet.Chain.ParentChain + "-1", nil
+			}
+		})
+
+		It("applies the other rule sets and retries it in the next cycle", func() {
+			result, err := lifecycle.Cycle()
+			Expect(err).To(MatchError(ContainSubstring("apply chain-a: potato")))
+			Expect(result.Applied).To(Equal([]enforcer.LiveChain{{Table: "filter", Name: "some-chain-b-1"}}))
+			_, desired := cleaner.CleanupArgsForCall(0)
+			Expect(desired).To(Equal([]enforcer.LiveChain{{Table: "filter", Name: "some-chain-b-1"}}))
+
+			_, _ = lifecycle.Cycle()
+			Expect(applier.ApplyCallCount()).To(Equal(3))
+			Expect(applier.ApplyArgsForCall(2)).To(Equal(ruleSetA))
+		})
+	})
+
+	Context("when no managed chains regex is set", func() {
+		BeforeEach(func() {
+			lifecycle.ManagedChainsRegex = nil
+		})
+
+		It("does not clean up", func() {
+			_, err := lifecycle.Cycle()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cleaner.CleanupCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when cleaning up fails", func() {
+		BeforeEach(func() {
+			cleaner.CleanupReturns(nil, errors.New("potato"))
+		})
+
+		It("returns the error", func() {
+			_, err := lifecycle.Cycle()
+			Expect(err).To(MatchError(ContainSubstring("cleanup: potato")))
+		})
+	})
+})
+
+var _ = Describe("RuleSetDiffer", func() {
+	It("reports the rule sets that are equal as unchanged", func() {
+		ruleSet := enforcer.RulesWithChain{
+			Chain: enforcer.Chain{ParentChain: "some-chain"},
+			Rules: []rules.IPTablesRule{{"rule1"}},
+		}
+		_, changed := enforcer.RuleSetDiffer{}.Diff(ruleSet, ruleSet)
+		Expect(changed).To(BeFalse())
+	})
+
+	It("counts the rules of a changed rule set", func() {
+		enforced := enforcer.RulesWithChain{
+			Chain: enforcer.Chain{ParentChain: "some-chain"},
+			Rules: []rules.IPTablesRule{{"rule1"}, {"rule2"}},
+		}
+		planned := enforcer.RulesWithChain{
+			Chain: enforcer.Chain{ParentChain: "some-chain"},
+			Rules: []rules.IPTablesRule{{"rule2"}, {"rule3"}},
+		}
+		diff, changed := enforcer.RuleSetDiffer{}.Diff(enforced, planned)
+		Expect(changed).To(BeTrue())
+		Expect(diff).To(Equal(enforcer.RuleSetDiff{
+			OldRules:        2,
+			NewRules:        2,
+			Added:           1,
+			Removed:         1,
+			AddedExamples:   []string{"rule3"},
+			RemovedExamples: []string{"rule1"},
+		}))
+	})
+})
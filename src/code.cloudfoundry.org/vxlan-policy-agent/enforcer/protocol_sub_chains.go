@@ -0,0 +1,76 @@
+package enforcer
+
+import "code.cloudfoundry.org/lib/rules"
+
+// splitProtocols are the protocols that get a sub-chain of their own. Rules
+// for other protocols, e.g. numbered ones, could match the packets of these
+// under another name, so rule sets with them are not split.
+var splitProtocols = map[string]bool{
+	"tcp":  true,
+	"udp":  true,
+	"icmp": true,
+}
+
+// SplitByProtocol moves the rules of a managed chain into one sub-chain per
+// protocol they match, followed by a sub-chain for all other packets. Every
+// sub-chain keeps, in their original order, the rules that apply to its
+// packets, so a packet is treated as it would be by the unsplit rules, but
+// only walks the rules of its protocol. It reports false when the rules
+// cannot be split.
+func SplitByProtocol(ruleset []rules.IPTablesRule) ([]SubChain, bool) {
+	var protocols []string
+	ruleProtocols := make([]string, len(ruleset))
+	for i, rule := range ruleset {
+		protocol, ok := ruleProtocol(rule)
+		if !ok {
+			return nil, false
+		}
+		if protocol != "" && !containsString(protocols, protocol) {
+			protocols = append(protocols, protocol)
+		}
+		ruleProtocols[i] = protocol
+	}
+
+	if len(protocols) == 0 || len(protocols)+1 > MaxSubChains {
+		return nil, false
+	}
+
+	subChains := []SubChain{}
+	for _, protocol := range append(protocols, "") {
+		subChain := SubChain{Conditions: rules.IPTablesRule{}, Rules: []rules.IPTablesRule{}}
+		if protocol != "" {
+			subChain.Conditions = rules.IPTablesRule{"-p", protocol}
+		}
+		for i, rule := range ruleset {
+			if ruleProtocols[i] == "" || ruleProtocols[i] == protocol {
+				subChain.Rules = append(subChain.Rules, rule)
+			}
+		}
+		subChains = append(subChains, subChain)
+	}
+	return subChains, true
+}
+
+// ruleProtocol returns the protocol a rule is limited to, or "" when it
+// applies to every protocol. It reports false for negated and unknown
+// protocols.
+func ruleProtocol(rule rules.IPTablesRule) (string, bool) {
+	protocol := ""
+	for i, arg := range rule {
+		if arg != "-p" && arg != "--protocol" {
+			continue
+		}
+		if (i > 0 && rule[i-1] == "!") || i+1 == len(rule) {
+			return "", false
+		}
+		value := rule[i+1]
+		if value == "all" {
+			continue
+		}
+		if !splitProtocols[value] || (protocol != "" && protocol != value) {
+			return "", false
+		}
+		protocol = value
+	}
+	return protocol, true
+}
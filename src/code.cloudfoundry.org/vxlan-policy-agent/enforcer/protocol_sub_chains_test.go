@@ -0,0 +1,77 @@
+package enforcer_test
+
+import (
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SplitByProtocol", func() {
+	It("gives every protocol a sub-chain with the rules that apply to it", func() {
+		subChains, ok := enforcer.SplitByProtocol([]rules.IPTablesRule{
+			{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+			{"-p", "tcp", "-d", "10.0.0.1", "-j", "ACCEPT"},
+			{"-d", "10.0.0.2", "-j", "ACCEPT"},
+			{"-p", "udp", "-d", "10.0.0.3", "-j", "ACCEPT"},
+			{"-p", "all", "-d", "10.0.0.4", "-j", "ACCEPT"},
+			{"-p", "tcp", "-d", "10.0.0.5", "-j", "ACCEPT"},
+			{"-j", "REJECT"},
+		})
+		Expect(ok).To(BeTrue())
+		Expect(subChains).To(Equal([]enforcer.SubChain{
+			{
+				Conditions: rules.IPTablesRule{"-p", "tcp"},
+				Rules: []rules.IPTablesRule{
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+					{"-p", "tcp", "-d", "10.0.0.1", "-j", "ACCEPT"},
+					{"-d", "10.0.0.2", "-j", "ACCEPT"},
+					{"-p", "all", "-d", "10.0.0.4", "-j", "ACCEPT"},
+					{"-p", "tcp", "-d", "10.0.0.5", "-j", "ACCEPT"},
+					{"-j", "REJECT"},
+				},
+			},
+			{
+				Conditions: rules.IPTablesRule{"-p", "udp"},
+				Rules: []rules.IPTablesRule{
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+					{"-d", "10.0.0.2", "-j", "ACCEPT"},
+					{"-p", "udp", "-d", "10.0.0.3", "-j", "ACCEPT"},
+					{"-p", "all", "-d", "10.0.0.4", "-j", "ACCEPT"},
+					{"-j", "REJECT"},
+				},
+			},
+			{
+				Conditions: rules.IPTablesRule{},
+				Rules: []rules.IPTablesRule{
+					{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+					{"-d", "10.0.0.2", "-j", "ACCEPT"},
+					{"-p", "all", "-d", "10.0.0.4", "-j", "ACCEPT"},
+					{"-j", "REJECT"},
+				},
+			},
+		}))
+	})
+
+	DescribeTable("does not split rules that cannot be split",
+		func(rule rules.IPTablesRule) {
+			_, ok := enforcer.SplitByProtocol([]rules.IPTablesRule{
+				{"-p", "tcp", "-j", "ACCEPT"},
+				rule,
+			})
+			Expect(ok).To(BeFalse())
+		},
+		Entry("negated protocol", rules.IPTablesRule{"!", "-p", "tcp", "-j", "ACCEPT"}),
+		Entry("numbered protocol", rules.IPTablesRule{"-p", "6", "-j", "ACCEPT"}),
+		Entry("two protocols", rules.IPTablesRule{"-p", "tcp", "-p", "udp", "-j", "ACCEPT"}),
+		Entry("missing protocol", rules.IPTablesRule{"-j", "ACCEPT", "-p"}),
+	)
+
+	Context("when no rule is limited to a protocol", func() {
+		It("does not split the rules", func() {
+			_, ok := enforcer.SplitByProtocol([]rules.IPTablesRule{{"-j", "ACCEPT"}})
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
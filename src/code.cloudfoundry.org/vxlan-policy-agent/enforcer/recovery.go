@@ -0,0 +1,178 @@
+package enforcer
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// beginIntent records the intent before its change is made. The change is
+// not made if it cannot be recorded, since a crash could leave it undetected.
+func (e *Enforcer) beginIntent(logger lager.Logger, intent Intent) error {
+	if e.conf.IntentLog == nil {
+		return nil
+	}
+	err := e.conf.IntentLog.Begin(intent)
+	if err != nil {
+		logger.Error("begin-intent", err)
+		return fmt.Errorf("recording intent: %s", err)
+	}
+	return nil
+}
+
+// completeIntent clears the intent of a change that is complete or rolled
+// back. An intent that cannot be cleared is verified by RecoverIntents.
+func (e *Enforcer) completeIntent(logger lager.Logger, intent Intent) {
+	if e.conf.IntentLog == nil {
+		return
+	}
+	err := e.conf.IntentLog.Complete(intent.ID)
+	if err != nil {
+		logger.Error("complete-intent", err, lager.Data{"intent": intent.ID})
+	}
+}
+
+// RecoverIntents verifies the changes of the pending intents, which were
+// interrupted, and repairs them. A change whose new chains all have their
+// rules and are jumped to is kept. The new chains of any other change are
+// deleted, along with the jumps to them and their sub-chains, so that the
+// chains they were going to replace stay in effect until the rule set is
+// enforced again. It returns the number of changes that were repaired.
+func (e *Enforcer) RecoverIntents() (int, error) {
+	if e.conf.IntentLog == nil {
+		return 0, nil
+	}
+	logger := e.Logger.Session("recover-intents")
+
+	pending, err := e.conf.IntentLog.Pending()
+	if err != nil {
+		return 0, fmt.Errorf("reading pending intents: %s", err)
+	}
+
+	repaired := 0
+	for _, intent := range pending {
+		complete, err := e.intentComplete(intent)
+		if err != nil {
+			return repaired, fmt.Errorf("verifying intent %s: %s", intent.ID, err)
+		}
+
+		if complete {
+			logger.Info("kept-complete-intent", lager.Data{"intent": intent.ID, "operation": intent.Operation})
+		} else {
+			for _, chain := range intent.Chains {
+				if err := e.removeIntentChain(logger, chain); err != nil {
+					return repaired, fmt.Errorf("repairing intent %s: %s", intent.ID, err)
+				}
+			}
+			logger.Info("repaired-incomplete-intent", lager.Data{"intent": intent.ID, "operation": intent.Operation, "rules_hash": intent.RulesHash})
+			repaired++
+		}
+
+		if err := e.conf.IntentLog.Complete(intent.ID); err != nil {
+			return repaired, fmt.Errorf("clearing intent %s: %s", intent.ID, err)
+		}
+	}
+	return repaired, nil
+}
+
+// intentComplete tells whether the new chains of the intent have all their
+// rules and are jumped to. A rule that iptables splits, e.g. one with several
+// source addresses, makes a complete chain look incomplete, which only costs
+// enforcing its rule set again.
+func (e *Enforcer) intentComplete(intent Intent) (bool, error) {
+	for _, chain := range intent.Chains {
+		exists, err := e.chainExists(chain.Table, chain.Name)
+		if err != nil || !exists {
+			return false, err
+		}
+
+		listing, err := e.iptables.List(chain.Table, chain.Name)
+		if err != nil {
+			return false, fmt.Errorf("list rules for chain: %s", err)
+		}
+		parsed, err := rules.ParseRules(listing)
+		if err != nil {
+			return false, fmt.Errorf("list rules for chain: %s", err)
+		}
+		if len(parsed) != chain.Rules {
+			return false, nil
+		}
+
+		jumped, err := e.jumpsTo(chain.Table, chain.ParentChain, chain.Name)
+		if err != nil || !jumped {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// removeIntentChain deletes a new chain of an interrupted change and the jump
+// to it, if they were created, and the sub-chains that were created for it.
+func (e *Enforcer) removeIntentChain(logger lager.Logger, chain IntentChain) error {
+	live := LiveChain{Table: chain.Table, Name: chain.Name}
+
+	jumped, err := e.jumpsTo(chain.Table, chain.ParentChain, chain.Name)
+	if err != nil {
+		return err
+	}
+	if jumped {
+		logger.Debug("delete-parent-chain-jump-rule", lager.Data{"table": chain.Table, "chain": chain.ParentChain, "rule": rules.IPTablesRule{"-j", chain.Name}})
+		if err := e.iptables.Delete(chain.Table, chain.ParentChain, rules.IPTablesRule{"-j", chain.Name}); err != nil {
+			return fmt.Errorf("remove reference to chain %s: %s", chain.Name, err)
+		}
+	}
+
+	exists, err := e.chainExists(chain.Table, chain.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := e.deleteChain(logger, live); err != nil {
+			return err
+		}
+	}
+
+	// sub-chains that were created before their gotos were appended
+	chains, err := e.iptables.ListChains(chain.Table)
+	if err != nil {
+		return fmt.Errorf("list chains: %s", err)
+	}
+	for _, name := range chains {
+		if IsSubChainOf(name, chain.Name) {
+			if err := e.deleteChain(logger, LiveChain{Table: chain.Table, Name: name}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Enforcer) chainExists(table, chain string) (bool, error) {
+	chains, err := e.iptables.ListChains(table)
+	if err != nil {
+		return false, fmt.Errorf("list chains: %s", err)
+	}
+	return containsString(chains, chain), nil
+}
+
+func (e *Enforcer) jumpsTo(table, parentChain, chain string) (bool, error) {
+	exists, err := e.chainExists(table, parentChain)
+	if err != nil || !exists {
+		return false, err
+	}
+	listing, err := e.iptables.List(table, parentChain)
+	if err != nil {
+		return false, fmt.Errorf("list rules for chain: %s", err)
+	}
+	parsed, err := rules.ParseRules(listing)
+	if err != nil {
+		return false, fmt.Errorf("list rules for chain: %s", err)
+	}
+	for _, rule := range parsed {
+		if rule.Chain == parentChain && rule.Target == chain && !rule.Goto {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,301 @@
+package enforcer_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer/fakes"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Intents", func() {
+	var (
+		iptables     *libfakes.IPTablesAdapter
+		timestamper  *fakes.TimeStamper
+		intentLog    *fakes.IntentLog
+		logger       *lagertest.TestLogger
+		ruleEnforcer *enforcer.Enforcer
+	)
+
+	BeforeEach(func() {
+		timestamper = &fakes.TimeStamper{}
+		intentLog = &fakes.IntentLog{}
+		logger = lagertest.NewTestLogger("test")
+		iptables = &libfakes.IPTablesAdapter{}
+
+		timestamper.CurrentTimeReturns(42)
+		ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{IntentLog: intentLog})
+	})
+
+	Describe("Enforce", func() {
+		It("records the intent before it changes iptables and clears it afterwards", func() {
+			iptables.NewChainStub = func(string, string) error {
+				Expect(intentLog.BeginCallCount()).To(Equal(1))
+				Expect(intentLog.CompleteCallCount()).To(Equal(0))
+				return nil
+			}
+
+			_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, rules.IPTablesRule{"rule1"}, rules.IPTablesRule{"rule2"})
+			Expect(err).NotTo(HaveOccurred())
+
+			intent := intentLog.BeginArgsForCall(0)
+			Expect(intent.ID).To(Equal("some-table/foo42"))
+			Expect(intent.Operation).To(Equal(enforcer.OperationEnforce))
+			Expect(intent.Chains).To(Equal([]enforcer.IntentChain{{Table: "some-table", ParentChain: "some-chain", Name: "foo42", Rules: 2}}))
+			Expect(intent.RulesHash).NotTo(BeEmpty())
+
+			Expect(intentLog.CompleteCallCount()).To(Equal(1))
+			Expect(intentLog.CompleteArgsForCall(0)).To(Equal("some-table/foo42"))
+		})
+
+		It("counts the rules that go to the sub-chains", func() {
+			_, err := ruleEnforcer.EnforceRulesAndChain(enforcer.RulesWithChain{
+				Chain:     enforcer.Chain{Table: "some-table", ParentChain: "some-chain", Prefix: "foo"},
+				Rules:     []rules.IPTablesRule{{"rule1"}},
+				SubChains: []enforcer.SubChain{{Conditions: rules.IPTablesRule{"-p", "tcp"}, Rules: []rules.IPTablesRule{{"rule2"}}}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(intentLog.BeginArgsForCall(0).Chains[0].Rules).To(Equal(2))
+		})
+
+		It("records the chains of every table of a rule set", func() {
+			_, err := ruleEnforcer.EnforceRulesAndChain(enforcer.RulesWithChain{
+				Chain: enforcer.Chain{Table: "filter", ParentChain: "FORWARD", Prefix: "foo"},
+				Rules: []rules.IPTablesRule{{"filter-rule"}},
+				ExtraTables: []enforcer.TableRules{{
+					Chain: enforcer.Chain{Table: "mangle", ParentChain: "POSTROUTING", Prefix: "bar"},
+					Rules: []rules.IPTablesRule{{"mangle-rule"}, {"other-mangle-rule"}},
+				}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			intent := intentLog.BeginArgsForCall(0)
+			Expect(intent.ID).To(Equal("filter/foo42"))
+			Expect(intent.Chains).To(Equal([]enforcer.IntentChain{
+				{Table: "filter", ParentChain: "FORWARD", Name: "foo42", Rules: 1},
+				{Table: "mangle", ParentChain: "POSTROUTING", Name: "bar42", Rules: 2},
+			}))
+			Expect(intentLog.CompleteCallCount()).To(Equal(1))
+		})
+
+		Context("when the intent cannot be recorded", func() {
+			BeforeEach(func() {
+				intentLog.BeginReturns(errors.New("banana"))
+			})
+
+			It("does not change iptables", func() {
+				_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, rules.IPTablesRule{"rule1"})
+				Expect(err).To(MatchError("recording intent: banana"))
+				Expect(iptables.NewChainCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the change fails and is rolled back", func() {
+			BeforeEach(func() {
+				iptables.BulkAppendReturns(errors.New("banana"))
+			})
+
+			It("clears the intent", func() {
+				_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, rules.IPTablesRule{"rule1"})
+				Expect(err).To(MatchError("bulk appending: banana"))
+				Expect(intentLog.CompleteCallCount()).To(Equal(1))
+			})
+
+			Context("when the rollback fails", func() {
+				BeforeEach(func() {
+					iptables.DeleteReturns(errors.New("kiwi"))
+				})
+
+				It("keeps the intent pending", func() {
+					_, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, rules.IPTablesRule{"rule1"})
+					Expect(err).To(MatchError("bulk appending: banana"))
+					Expect(intentLog.CompleteCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the intent cannot be cleared", func() {
+			BeforeEach(func() {
+				intentLog.CompleteReturns(errors.New("banana"))
+			})
+
+			It("logs the error and keeps the change", func() {
+				chain, err := ruleEnforcer.Enforce("some-table", "some-chain", "foo", "foo", false, rules.IPTablesRule{"rule1"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(chain).To(Equal("foo42"))
+				Expect(logger.Logs()).To(ContainElement(HaveField("Message", "test.foo42.complete-intent")))
+			})
+		})
+	})
+
+	Describe("RecoverIntents", func() {
+		var (
+			live   map[string][]string
+			intent enforcer.Intent
+		)
+
+		BeforeEach(func() {
+			live = map[string][]string{
+				"FORWARD": {"-A FORWARD -j foo42", "-A FORWARD -j foo41"},
+				"foo42":   {"-A foo42 -j ACCEPT", "-A foo42 -j DROP"},
+				"foo41":   {"-A foo41 -j ACCEPT"},
+			}
+			iptables.ListChainsStub = func(string) ([]string, error) {
+				chains := []string{}
+				for chain := range live {
+					chains = append(chains, chain)
+				}
+				return chains, nil
+			}
+			iptables.ListStub = func(_, chain string) ([]string, error) {
+				if _, ok := live[chain]; !ok {
+					return nil, fmt.Errorf("no chain %s", chain)
+				}
+				return append([]string{"-N " + chain}, live[chain]...), nil
+			}
+			iptables.DeleteStub = func(_, chain string, rule rules.IPTablesRule) error {
+				line := fmt.Sprintf("-A %s %s", chain, strings.Join(rule, " "))
+				for i, l := range live[chain] {
+					if l == line {
+						live[chain] = append(live[chain][:i], live[chain][i+1:]...)
+						return nil
+					}
+				}
+				return fmt.Errorf("no rule %s", line)
+			}
+			iptables.ClearChainStub = func(_, chain string) error {
+				live[chain] = []string{}
+				return nil
+			}
+			iptables.DeleteChainStub = func(_, chain string) error {
+				delete(live, chain)
+				return nil
+			}
+
+			intent = enforcer.Intent{
+				ID:        "filter/foo42",
+				Operation: enforcer.OperationEnforce,
+				Chains:    []enforcer.IntentChain{{Table: "filter", ParentChain: "FORWARD", Name: "foo42", Rules: 2}},
+			}
+			intentLog.PendingReturns([]enforcer.Intent{intent}, nil)
+		})
+
+		Context("when the change is complete", func() {
+			It("keeps it and clears the intent", func() {
+				repaired, err := ruleEnforcer.RecoverIntents()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(Equal(0))
+
+				Expect(live).To(HaveKey("foo42"))
+				Expect(live["FORWARD"]).To(ContainElement("-A FORWARD -j foo42"))
+				Expect(intentLog.CompleteCallCount()).To(Equal(1))
+				Expect(intentLog.CompleteArgsForCall(0)).To(Equal("filter/foo42"))
+			})
+		})
+
+		Context("when the new chain is jumped to but misses rules", func() {
+			BeforeEach(func() {
+				live["foo42"] = []string{"-A foo42 -j ACCEPT"}
+			})
+
+			It("removes the jump and the chain, keeping the old chain", func() {
+				repaired, err := ruleEnforcer.RecoverIntents()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(Equal(1))
+
+				Expect(live).NotTo(HaveKey("foo42"))
+				Expect(live["FORWARD"]).To(Equal([]string{"-A FORWARD -j foo41"}))
+				Expect(live).To(HaveKey("foo41"))
+				Expect(intentLog.CompleteCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the new chain has its rules but is not jumped to", func() {
+			BeforeEach(func() {
+				live["FORWARD"] = []string{"-A FORWARD -j foo41"}
+			})
+
+			It("removes the chain", func() {
+				repaired, err := ruleEnforcer.RecoverIntents()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(Equal(1))
+				Expect(live).NotTo(HaveKey("foo42"))
+				Expect(iptables.DeleteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when only sub-chains of the new chain were created", func() {
+			BeforeEach(func() {
+				delete(live, "foo42")
+				live["FORWARD"] = []string{"-A FORWARD -j foo41"}
+				live["foo42-0"] = []string{"-A foo42-0 -j ACCEPT"}
+			})
+
+			It("removes the sub-chains", func() {
+				repaired, err := ruleEnforcer.RecoverIntents()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(Equal(1))
+				Expect(live).NotTo(HaveKey("foo42-0"))
+				Expect(live).To(HaveKey("foo41"))
+			})
+		})
+
+		Context("when a chain of another table of the change is missing", func() {
+			BeforeEach(func() {
+				intent.Chains = append(intent.Chains, enforcer.IntentChain{Table: "mangle", ParentChain: "POSTROUTING", Name: "bar42", Rules: 1})
+				intentLog.PendingReturns([]enforcer.Intent{intent}, nil)
+			})
+
+			It("removes the chains of every table", func() {
+				repaired, err := ruleEnforcer.RecoverIntents()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(Equal(1))
+				Expect(live).NotTo(HaveKey("foo42"))
+			})
+		})
+
+		Context("when reading the pending intents fails", func() {
+			BeforeEach(func() {
+				intentLog.PendingReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := ruleEnforcer.RecoverIntents()
+				Expect(err).To(MatchError("reading pending intents: banana"))
+			})
+		})
+
+		Context("when listing the chains fails", func() {
+			BeforeEach(func() {
+				iptables.ListChainsStub = nil
+				iptables.ListChainsReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error and keeps the intent", func() {
+				_, err := ruleEnforcer.RecoverIntents()
+				Expect(err).To(MatchError("verifying intent filter/foo42: list chains: banana"))
+				Expect(intentLog.CompleteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("without an intent log", func() {
+			BeforeEach(func() {
+				ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{})
+			})
+
+			It("recovers nothing", func() {
+				repaired, err := ruleEnforcer.RecoverIntents()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(repaired).To(Equal(0))
+				Expect(iptables.ListChainsCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package enforcer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"code.cloudfoundry.org/cf-networking-helpers/runner"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const dmesgTailLines = 20
+
+// commLength is the length the kernel cuts the command names of processes to.
+const commLength = 15
+
+type commandRunner interface {
+	CombinedOutput(command runner.Command) ([]byte, error)
+}
+
+// StuckProcesses finds the iptables processes the agent started when an
+// enforcement does not finish in time. It describes who holds the iptables
+// locks and what the kernel logged, and kills the processes, so that the
+// enforcement fails instead of waiting for them forever.
+type StuckProcesses struct {
+	// ProcDir is where the proc filesystem is mounted, usually /proc.
+	ProcDir string
+	// Commands are the names of the child processes that are killed, e.g.
+	// iptables-restore.
+	Commands []string
+	// LockFiles are the files iptables is locked with, e.g. the iptables lock
+	// file of garden-cni and /run/xtables.lock.
+	LockFiles []string
+	// DmesgRunner runs dmesg for the tail of the kernel log. Without it the
+	// kernel log is not read.
+	DmesgRunner commandRunner
+}
+
+// Diagnose describes the processes that hold the lock files and the tail of
+// the kernel log.
+func (s *StuckProcesses) Diagnose() lager.Data {
+	data := lager.Data{"lock_holders": s.lockHolders()}
+	if s.DmesgRunner != nil {
+		output, err := s.DmesgRunner.CombinedOutput(runner.Command{})
+		if err != nil {
+			data["dmesg_error"] = err.Error()
+		}
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		if len(lines) > dmesgTailLines {
+			lines = lines[len(lines)-dmesgTailLines:]
+		}
+		data["dmesg"] = lines
+	}
+	return data
+}
+
+// Kill kills the child processes of the agent that run one of the commands,
+// and returns their pids.
+func (s *StuckProcesses) Kill() ([]int, error) {
+	statFiles, err := filepath.Glob(filepath.Join(s.ProcDir, "[0-9]*", "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	killed := []int{}
+	for _, statFile := range statFiles {
+		pid, command, ppid, err := parseStat(statFile)
+		if err != nil || ppid != os.Getpid() || !s.isStuckCommand(command) {
+			continue
+		}
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+			return killed, fmt.Errorf("kill %s %d: %s", command, pid, err)
+		}
+		killed = append(killed, pid)
+	}
+	return killed, nil
+}
+
+func (s *StuckProcesses) isStuckCommand(command string) bool {
+	for _, c := range s.Commands {
+		if len(c) > commLength {
+			c = c[:commLength]
+		}
+		if command == c {
+			return true
+		}
+	}
+	return false
+}
+
+// lockHolders matches the inodes of the lock files with the locks the
+// kernel lists in /proc/locks, e.g.
+// 1: FLOCK  ADVISORY  WRITE 1234 08:01:5678 0 EOF
+func (s *StuckProcesses) lockHolders() []string {
+	inodes := map[string]string{}
+	for _, lockFile := range s.LockFiles {
+		info, err := os.Stat(lockFile)
+		if err != nil {
+			continue
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			inodes[strconv.FormatUint(stat.Ino, 10)] = lockFile
+		}
+	}
+
+	locks, err := os.ReadFile(filepath.Join(s.ProcDir, "locks"))
+	if err != nil {
+		return []string{fmt.Sprintf("read locks: %s", err)}
+	}
+
+	holders := []string{}
+	for _, line := range strings.Split(string(locks), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[1] == "->" {
+			continue
+		}
+		device := strings.Split(fields[5], ":")
+		lockFile, ok := inodes[device[len(device)-1]]
+		if !ok {
+			continue
+		}
+		pid := fields[4]
+		cmdline, _ := os.ReadFile(filepath.Join(s.ProcDir, pid, "cmdline"))
+		command := strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		holders = append(holders, fmt.Sprintf("%s: %s (pid %s)", lockFile, command, pid))
+	}
+	return holders
+}
+
+// parseStat reads the pid, command and parent pid from a stat file, e.g.
+// 1234 (iptables-restor) S 1000 ...
+// The command may contain spaces and parentheses, so it ends at the last
+// closing parenthesis.
+func parseStat(statFile string) (int, string, int, error) {
+	contents, err := os.ReadFile(statFile)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	stat := string(contents)
+	open := strings.Index(stat, "(")
+	closing := strings.LastIndex(stat, ")")
+	if open < 0 || closing < open {
+		return 0, "", 0, fmt.Errorf("malformed stat file %s", statFile)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:open]))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	fields := strings.Fields(stat[closing+1:])
+	if len(fields) < 2 {
+		return 0, "", 0, fmt.Errorf("malformed stat file %s", statFile)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, "", 0, err
+	}
+	return pid, stat[open+1 : closing], ppid, nil
+}
@@ -0,0 +1,135 @@
+package enforcer_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StuckProcesses", func() {
+	var stuckProcesses *enforcer.StuckProcesses
+
+	Describe("Kill", func() {
+		var stuck, other *exec.Cmd
+
+		BeforeEach(func() {
+			stuck = exec.Command("sleep", "60")
+			Expect(stuck.Start()).To(Succeed())
+			other = exec.Command("tail", "-f", "/dev/null")
+			Expect(other.Start()).To(Succeed())
+
+			stuckProcesses = &enforcer.StuckProcesses{
+				ProcDir:  "/proc",
+				Commands: []string{"sleep"},
+			}
+		})
+
+		AfterEach(func() {
+			other.Process.Kill()
+			other.Wait()
+		})
+
+		It("kills the child processes that run one of the commands", func() {
+			killed, err := stuckProcesses.Kill()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(killed).To(Equal([]int{stuck.Process.Pid}))
+
+			err = stuck.Wait()
+			Expect(err).To(MatchError("signal: killed"))
+			Expect(other.Process.Signal(syscall.Signal(0))).To(Succeed())
+		})
+	})
+
+	Describe("Diagnose", func() {
+		var (
+			procDir     string
+			lockFile    string
+			dmesgRunner *libfakes.CommandRunner
+		)
+
+		BeforeEach(func() {
+			var err error
+			procDir, err = os.MkdirTemp("", "proc-")
+			Expect(err).NotTo(HaveOccurred())
+			lockFile = filepath.Join(procDir, "iptables.lock")
+			Expect(os.WriteFile(lockFile, nil, 0600)).To(Succeed())
+			info, err := os.Stat(lockFile)
+			Expect(err).NotTo(HaveOccurred())
+			inode := info.Sys().(*syscall.Stat_t).Ino
+
+			Expect(os.WriteFile(filepath.Join(procDir, "locks"), []byte(fmt.Sprintf(
+				"1: FLOCK  ADVISORY  WRITE 42 00:2e:%d 0 EOF\n"+
+					"1: -> FLOCK  ADVISORY  WRITE 43 00:2e:%d 0 EOF\n"+
+					"2: POSIX  ADVISORY  WRITE 44 00:2e:1 0 EOF\n", inode, inode)), 0600)).To(Succeed())
+			Expect(os.Mkdir(filepath.Join(procDir, "42"), 0700)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(procDir, "42", "cmdline"), []byte("iptables\x00-w\x00-L\x00"), 0600)).To(Succeed())
+
+			lines := []string{}
+			for i := 1; i <= 30; i++ {
+				lines = append(lines, fmt.Sprintf("kernel line %d", i))
+			}
+			dmesgRunner = &libfakes.CommandRunner{}
+			dmesgRunner.CombinedOutputReturns([]byte(strings.Join(lines, "\n")+"\n"), nil)
+
+			stuckProcesses = &enforcer.StuckProcesses{
+				ProcDir:     procDir,
+				LockFiles:   []string{lockFile, filepath.Join(procDir, "missing.lock")},
+				DmesgRunner: dmesgRunner,
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(procDir)
+		})
+
+		It("describes the processes holding the lock files", func() {
+			data := stuckProcesses.Diagnose()
+			Expect(data["lock_holders"]).To(Equal([]string{
+				fmt.Sprintf("%s: iptables -w -L (pid 42)", lockFile),
+			}))
+		})
+
+		It("returns the tail of the kernel log", func() {
+			data := stuckProcesses.Diagnose()
+			tail := []string{}
+			for i := 11; i <= 30; i++ {
+				tail = append(tail, fmt.Sprintf("kernel line %d", i))
+			}
+			Expect(data["dmesg"]).To(Equal(tail))
+		})
+
+		Context("when dmesg fails", func() {
+			BeforeEach(func() {
+				dmesgRunner.CombinedOutputReturns([]byte("dmesg: read kernel buffer failed: Operation not permitted\n"), errors.New("exit status 1"))
+			})
+
+			It("records the error and the output", func() {
+				data := stuckProcesses.Diagnose()
+				Expect(data["dmesg_error"]).To(Equal("exit status 1"))
+				Expect(data["dmesg"]).To(Equal([]string{"dmesg: read kernel buffer failed: Operation not permitted"}))
+			})
+		})
+
+		Context("when there is no dmesg runner", func() {
+			BeforeEach(func() {
+				stuckProcesses.DmesgRunner = nil
+			})
+
+			It("only describes the lock holders", func() {
+				data := stuckProcesses.Diagnose()
+				Expect(data).To(HaveKey("lock_holders"))
+				Expect(data).NotTo(HaveKey("dmesg"))
+			})
+		})
+	})
+})
@@ -0,0 +1,162 @@
+package enforcer
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+)
+
+// TableRules are rules of a rule set that belong in a chain of another table,
+// e.g. mangle or nat, so that they stay in sync with the filter rules.
+type TableRules struct {
+	Chain Chain
+	Rules []rules.IPTablesRule
+}
+
+type tableChain struct {
+	chain              Chain
+	live               LiveChain
+	managedChainsRegex string
+	rules              []rules.IPTablesRule
+	subChains          []SubChain
+	inserted           bool
+}
+
+// enforceTables enforces a rule set that has rules in more than one table. The
+// new chains of every table are created and filled before any of them is
+// jumped to, and the jumps are only kept if all of them could be inserted.
+// The jumps are inserted one table after the other, so for a moment traffic
+// can see the new rules of one table with the old rules of another. The new
+// chains of all tables carry the same name when their prefixes match. It
+// returns the name of the new chain of the rule set's own table.
+func (e *Enforcer) enforceTables(rulesAndChain RulesWithChain) (string, error) {
+	newTime := e.timestamper.CurrentTime()
+
+	tableChains := []*tableChain{{
+		chain:     rulesAndChain.Chain,
+		rules:     rulesAndChain.Rules,
+		subChains: rulesAndChain.SubChains,
+	}}
+	for _, tableRules := range rulesAndChain.ExtraTables {
+		tableChains = append(tableChains, &tableChain{chain: tableRules.Chain, rules: tableRules.Rules})
+	}
+
+	for _, tc := range tableChains {
+		tc.live = LiveChain{Table: tc.chain.Table, Name: rules.ChainName(tc.chain.Prefix, e.conf.ChainNameVersion, newTime)}
+		tc.managedChainsRegex = tc.chain.ManagedChainsRegex
+		if tc.managedChainsRegex == "" {
+			tc.managedChainsRegex = tc.chain.Prefix
+		}
+	}
+
+	mainChain := tableChains[0].live
+	logger := e.Logger.Session(mainChain.Name)
+
+	intentChains := []IntentChain{}
+	for i, tc := range tableChains {
+		ruleCount := len(tc.subChains) + len(tc.rules)
+		if i == 0 && e.conf.DisableContainerNetworkPolicy {
+			ruleCount++
+		}
+		intentChains = append(intentChains, IntentChain{Table: tc.live.Table, ParentChain: tc.chain.ParentChain, Name: tc.live.Name, Rules: ruleCount})
+	}
+	intent := newIntent(OperationEnforce, rulesAndChain.Hash(), intentChains)
+	if err := e.beginIntent(logger, intent); err != nil {
+		return "", err
+	}
+
+	var created []*tableChain
+	for i, tc := range tableChains {
+		logger.Debug("create-chain", lager.Data{"chain": tc.live.Name, "table": tc.live.Table})
+		err := e.iptables.NewChain(tc.live.Table, tc.live.Name)
+		if err != nil {
+			logger.Error("create-chain", err)
+			e.rollbackTables(logger, intent, created)
+			return "", fmt.Errorf("creating chain in %s: %s", tc.live.Table, err)
+		}
+		created = append(created, tc)
+
+		rulespec := tc.rules
+		if len(tc.subChains) > 0 {
+			gotoRules, err := e.createSubChains(logger, tc.live.Table, tc.live.Name, tc.subChains)
+			if err != nil {
+				logger.Error("create-sub-chains", err)
+				e.rollbackTables(logger, intent, created)
+				return "", fmt.Errorf("creating sub-chains: %s", err)
+			}
+			rulespec = append(gotoRules, rulespec...)
+		}
+		if i == 0 && e.conf.DisableContainerNetworkPolicy {
+			rulespec = append([]rules.IPTablesRule{rules.NewAcceptEverythingRule(e.conf.OverlayNetwork)}, rulespec...)
+		}
+
+		logger.Debug("bulk-append", lager.Data{"chain": tc.live.Name, "table": tc.live.Table, "rules": rulespec})
+		err = e.iptables.BulkAppend(tc.live.Table, tc.live.Name, rulespec...)
+		if err != nil {
+			logger.Error("bulk-append", err)
+			if len(tc.subChains) > 0 {
+				e.deleteSubChains(logger, tc.live.Table, subChainNames(tc.live.Name, len(tc.subChains)))
+			}
+			e.rollbackTables(logger, intent, created)
+			return "", fmt.Errorf("bulk appending in %s: %s", tc.live.Table, err)
+		}
+	}
+
+	for _, tc := range tableChains {
+		pos := e.insertPosition(logger, tc.live.Table, tc.chain.ParentChain)
+		logger.Debug("insert-chain", lager.Data{"chain": tc.chain.ParentChain, "table": tc.live.Table, "index": pos, "rule": rules.IPTablesRule{"-j", tc.live.Name}})
+		err := e.iptables.BulkInsert(tc.live.Table, tc.chain.ParentChain, pos, rules.IPTablesRule{"-j", tc.live.Name})
+		if err != nil {
+			logger.Error("insert-chain", err)
+			e.rollbackTables(logger, intent, tableChains)
+			return "", fmt.Errorf("inserting chain in %s: %s", tc.live.Table, err)
+		}
+		tc.inserted = true
+	}
+	e.completeIntent(logger, intent)
+
+	var cleanupErr error
+	for _, tc := range tableChains {
+		logger.Debug("cleaning-up-old-rules", lager.Data{"chain": tc.live.Name, "table": tc.live.Table})
+		err := e.cleanupOldRules(logger, tc.live.Table, tc.chain.ParentChain, tc.managedChainsRegex, tc.chain.CleanUpParentChain, newTime)
+		if err != nil {
+			logger.Error("cleanup-rules", err)
+			if cleanupErr == nil {
+				cleanupErr = &CleanupErr{err}
+			}
+		}
+	}
+
+	return mainChain.Name, cleanupErr
+}
+
+// rollbackTables removes the new chains of a rule set, and the jumps to them
+// that were already inserted. The intent of the rule set stays pending if a
+// chain cannot be removed.
+func (e *Enforcer) rollbackTables(logger lager.Logger, intent Intent, tableChains []*tableChain) {
+	rolledBack := true
+	for _, tc := range tableChains {
+		var err error
+		if tc.inserted {
+			err = e.cleanupOldChain(logger, tc.live, tc.chain.ParentChain)
+		} else {
+			err = e.deleteChain(logger, tc.live)
+		}
+		if err != nil {
+			logger.Error("rollback-chain", err, lager.Data{"chain": tc.live.Name, "table": tc.live.Table})
+			rolledBack = false
+		}
+	}
+	if rolledBack {
+		e.completeIntent(logger, intent)
+	}
+}
+
+func subChainNames(chain string, count int) []string {
+	names := []string{}
+	for i := 0; i < count; i++ {
+		names = append(names, SubChainName(chain, i))
+	}
+	return names
+}
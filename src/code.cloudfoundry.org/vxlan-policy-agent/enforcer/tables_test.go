@@ -0,0 +1,204 @@
+package enforcer_test
+
+import (
+	"errors"
+
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer/fakes"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Enforcing rules in more than one table", func() {
+	var (
+		iptables      *libfakes.IPTablesAdapter
+		timestamper   *fakes.TimeStamper
+		logger        *lagertest.TestLogger
+		ruleEnforcer  *enforcer.Enforcer
+		rulesAndChain enforcer.RulesWithChain
+	)
+
+	BeforeEach(func() {
+		timestamper = &fakes.TimeStamper{}
+		logger = lagertest.NewTestLogger("test")
+		iptables = &libfakes.IPTablesAdapter{}
+
+		timestamper.CurrentTimeReturns(42)
+		ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{})
+
+		rulesAndChain = enforcer.RulesWithChain{
+			Chain: enforcer.Chain{
+				Table:       "filter",
+				ParentChain: "FORWARD",
+				Prefix:      "foo",
+			},
+			Rules: []rules.IPTablesRule{{"filter-rule"}},
+			ExtraTables: []enforcer.TableRules{
+				{
+					Chain: enforcer.Chain{
+						Table:       "mangle",
+						ParentChain: "POSTROUTING",
+						Prefix:      "bar",
+					},
+					Rules: []rules.IPTablesRule{{"mangle-rule"}},
+				},
+			},
+		}
+	})
+
+	It("fills the chains of every table before jumping to them", func() {
+		chain, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chain).To(Equal("foo42"))
+
+		Expect(iptables.NewChainCallCount()).To(Equal(2))
+		table, chainName := iptables.NewChainArgsForCall(0)
+		Expect([]string{table, chainName}).To(Equal([]string{"filter", "foo42"}))
+		table, chainName = iptables.NewChainArgsForCall(1)
+		Expect([]string{table, chainName}).To(Equal([]string{"mangle", "bar42"}))
+
+		Expect(iptables.BulkAppendCallCount()).To(Equal(2))
+		table, chainName, rulespec := iptables.BulkAppendArgsForCall(0)
+		Expect([]string{table, chainName}).To(Equal([]string{"filter", "foo42"}))
+		Expect(rulespec).To(Equal([]rules.IPTablesRule{{"filter-rule"}}))
+		table, chainName, rulespec = iptables.BulkAppendArgsForCall(1)
+		Expect([]string{table, chainName}).To(Equal([]string{"mangle", "bar42"}))
+		Expect(rulespec).To(Equal([]rules.IPTablesRule{{"mangle-rule"}}))
+
+		Expect(iptables.BulkInsertCallCount()).To(Equal(2))
+		table, chainName, pos, rulespec := iptables.BulkInsertArgsForCall(0)
+		Expect([]string{table, chainName}).To(Equal([]string{"filter", "FORWARD"}))
+		Expect(pos).To(Equal(1))
+		Expect(rulespec).To(Equal([]rules.IPTablesRule{{"-j", "foo42"}}))
+		table, chainName, pos, rulespec = iptables.BulkInsertArgsForCall(1)
+		Expect([]string{table, chainName}).To(Equal([]string{"mangle", "POSTROUTING"}))
+		Expect(pos).To(Equal(1))
+		Expect(rulespec).To(Equal([]rules.IPTablesRule{{"-j", "bar42"}}))
+	})
+
+	It("cleans up the old chains of every table", func() {
+		iptables.ListStub = func(table, chain string) ([]string, error) {
+			switch chain {
+			case "FORWARD":
+				return []string{"-A FORWARD -j foo42", "-A FORWARD -j foo0000000001"}, nil
+			case "POSTROUTING":
+				return []string{"-A POSTROUTING -j bar42", "-A POSTROUTING -j bar0000000001"}, nil
+			}
+			return nil, nil
+		}
+
+		_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(iptables.DeleteChainCallCount()).To(Equal(2))
+		table, chainName := iptables.DeleteChainArgsForCall(0)
+		Expect([]string{table, chainName}).To(Equal([]string{"filter", "foo0000000001"}))
+		table, chainName = iptables.DeleteChainArgsForCall(1)
+		Expect([]string{table, chainName}).To(Equal([]string{"mangle", "bar0000000001"}))
+	})
+
+	Context("when network policy is disabled", func() {
+		BeforeEach(func() {
+			ruleEnforcer = enforcer.NewEnforcer(logger, timestamper, iptables, enforcer.EnforcerConfig{
+				DisableContainerNetworkPolicy: true,
+				OverlayNetwork:                "10.10.0.0/16",
+			})
+		})
+
+		It("only allows all container connections in the rule set's own table", func() {
+			_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, rulespec := iptables.BulkAppendArgsForCall(0)
+			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"-s", "10.10.0.0/16", "-d", "10.10.0.0/16", "-j", "ACCEPT"}, {"filter-rule"}}))
+			_, _, rulespec = iptables.BulkAppendArgsForCall(1)
+			Expect(rulespec).To(Equal([]rules.IPTablesRule{{"mangle-rule"}}))
+		})
+	})
+
+	Context("when creating the chain of another table fails", func() {
+		BeforeEach(func() {
+			iptables.NewChainReturnsOnCall(1, errors.New("banana"))
+		})
+
+		It("deletes the chains it created and returns an error", func() {
+			_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+			Expect(err).To(MatchError("creating chain in mangle: banana"))
+
+			Expect(iptables.DeleteChainCallCount()).To(Equal(1))
+			table, chainName := iptables.DeleteChainArgsForCall(0)
+			Expect([]string{table, chainName}).To(Equal([]string{"filter", "foo42"}))
+			Expect(iptables.BulkInsertCallCount()).To(Equal(0))
+
+			Expect(logger).To(gbytes.Say("create-chain.*banana"))
+		})
+	})
+
+	Context("when appending to the chain of another table fails", func() {
+		BeforeEach(func() {
+			iptables.BulkAppendReturnsOnCall(1, errors.New("banana"))
+		})
+
+		It("deletes the chains it created without jumping to any of them", func() {
+			_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+			Expect(err).To(MatchError("bulk appending in mangle: banana"))
+
+			Expect(iptables.DeleteChainCallCount()).To(Equal(2))
+			table, chainName := iptables.DeleteChainArgsForCall(0)
+			Expect([]string{table, chainName}).To(Equal([]string{"filter", "foo42"}))
+			table, chainName = iptables.DeleteChainArgsForCall(1)
+			Expect([]string{table, chainName}).To(Equal([]string{"mangle", "bar42"}))
+			Expect(iptables.BulkInsertCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when jumping to the chain of another table fails", func() {
+		BeforeEach(func() {
+			iptables.BulkInsertReturnsOnCall(1, errors.New("banana"))
+		})
+
+		It("removes the jumps it inserted and deletes the chains it created", func() {
+			_, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+			Expect(err).To(MatchError("inserting chain in mangle: banana"))
+
+			Expect(iptables.DeleteCallCount()).To(Equal(1))
+			table, chainName, rulespec := iptables.DeleteArgsForCall(0)
+			Expect([]string{table, chainName}).To(Equal([]string{"filter", "FORWARD"}))
+			Expect(rulespec).To(Equal(rules.IPTablesRule{"-j", "foo42"}))
+
+			Expect(iptables.DeleteChainCallCount()).To(Equal(2))
+			table, chainName = iptables.DeleteChainArgsForCall(0)
+			Expect([]string{table, chainName}).To(Equal([]string{"filter", "foo42"}))
+			table, chainName = iptables.DeleteChainArgsForCall(1)
+			Expect([]string{table, chainName}).To(Equal([]string{"mangle", "bar42"}))
+		})
+	})
+
+	Context("when cleaning up the old chains of a table fails", func() {
+		BeforeEach(func() {
+			iptables.ListStub = func(table, chain string) ([]string, error) {
+				if chain == "FORWARD" {
+					return nil, errors.New("banana")
+				}
+				return nil, nil
+			}
+		})
+
+		It("cleans up the other tables and returns a CleanupErr with the chain name", func() {
+			chain, err := ruleEnforcer.EnforceRulesAndChain(rulesAndChain)
+			Expect(err).To(MatchError("cleaning up: listing forward rules: banana"))
+			_, isCleanupErr := err.(*enforcer.CleanupErr)
+			Expect(isCleanupErr).To(BeTrue())
+			Expect(chain).To(Equal("foo42"))
+
+			Expect(iptables.ListCallCount()).To(Equal(2))
+			_, chainName := iptables.ListArgsForCall(1)
+			Expect(chainName).To(Equal("POSTROUTING"))
+		})
+	})
+})
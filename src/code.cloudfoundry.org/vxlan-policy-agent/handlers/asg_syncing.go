@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+//go:generate counterfeiter -o fakes/asgSyncingState.go --fake-name ASGSyncingState . asgSyncingState
+type asgSyncingState interface {
+	PauseASGSyncing()
+	ResumeASGSyncing()
+	ASGSyncingPausedSince() (time.Time, bool)
+}
+
+// ASGSyncing pauses and resumes ASG syncing. When PauseFile is set, the
+// paused state is recorded there so that it survives agent restarts.
+type ASGSyncing struct {
+	State            asgSyncingState
+	PauseFile        string
+	EnableASGSyncing bool
+}
+
+func (h *ASGSyncing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.EnableASGSyncing {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`{ "error": "ASG syncing has been disabled administratively" }`))
+		return
+	}
+
+	if r.Method == "PUT" {
+		var bodyStruct = struct {
+			Paused *bool `json:"paused"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&bodyStruct)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{ "error": "decoding request body as json" }`))
+			return
+		}
+		if bodyStruct.Paused == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{ "error": "missing required key 'paused'" }`))
+			return
+		}
+
+		if *bodyStruct.Paused {
+			h.State.PauseASGSyncing()
+			err = h.writePauseFile()
+		} else {
+			h.State.ResumeASGSyncing()
+			err = h.removePauseFile()
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf(`{ "error": %q }`, err.Error())))
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(asgSyncingStatus(h.EnableASGSyncing, h.State))
+}
+
+func (h *ASGSyncing) writePauseFile() error {
+	if h.PauseFile == "" {
+		return nil
+	}
+	err := os.WriteFile(h.PauseFile, []byte{}, 0600)
+	if err != nil {
+		return fmt.Errorf("persisting paused state: %s", err)
+	}
+	return nil
+}
+
+func (h *ASGSyncing) removePauseFile() error {
+	if h.PauseFile == "" {
+		return nil
+	}
+	err := os.Remove(h.PauseFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing paused state: %s", err)
+	}
+	return nil
+}
+
+type asgSyncingStatusResponse struct {
+	Paused      bool       `json:"paused"`
+	PausedSince *time.Time `json:"paused_since,omitempty"`
+}
+
+func asgSyncingStatus(enabled bool, state asgSyncingState) asgSyncingStatusResponse {
+	if !enabled {
+		return asgSyncingStatusResponse{}
+	}
+	since, paused := state.ASGSyncingPausedSince()
+	if !paused {
+		return asgSyncingStatusResponse{}
+	}
+	return asgSyncingStatusResponse{Paused: true, PausedSince: &since}
+}
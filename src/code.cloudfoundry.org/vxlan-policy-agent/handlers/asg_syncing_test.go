@@ -0,0 +1,162 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers"
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ASGSyncing", func() {
+	var (
+		handler   *handlers.ASGSyncing
+		recorder  *httptest.ResponseRecorder
+		state     *fakes.ASGSyncingState
+		pauseFile string
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		state = &fakes.ASGSyncingState{}
+		pauseFile = filepath.Join(GinkgoT().TempDir(), "asg-syncing-paused")
+		handler = &handlers.ASGSyncing{
+			State:            state,
+			PauseFile:        pauseFile,
+			EnableASGSyncing: true,
+		}
+	})
+
+	Describe("getting the state", func() {
+		It("returns not paused", func() {
+			req, err := http.NewRequest("GET", "/", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"paused": false}`))
+		})
+
+		Context("when syncing is paused", func() {
+			BeforeEach(func() {
+				state.ASGSyncingPausedSinceReturns(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), true)
+			})
+
+			It("returns paused and since when", func() {
+				req, err := http.NewRequest("GET", "/", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"paused": true, "paused_since": "2020-01-02T03:04:05Z"}`))
+			})
+		})
+	})
+
+	Describe("setting the state", func() {
+		Context("when called with paused: true", func() {
+			It("pauses syncing and records it in the pause file", func() {
+				req, err := http.NewRequest("PUT", "/", strings.NewReader(`{"paused":true}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(state.PauseASGSyncingCallCount()).To(Equal(1))
+				Expect(pauseFile).To(BeAnExistingFile())
+			})
+
+			Context("when the pause file cannot be written", func() {
+				BeforeEach(func() {
+					handler.PauseFile = "/some/non-existent/dir/file"
+				})
+
+				It("returns an error", func() {
+					req, err := http.NewRequest("PUT", "/", strings.NewReader(`{"paused":true}`))
+					Expect(err).NotTo(HaveOccurred())
+
+					handler.ServeHTTP(recorder, req)
+					Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+					Expect(recorder.Body.String()).To(ContainSubstring("persisting paused state"))
+				})
+			})
+		})
+
+		Context("when called with paused: false", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(pauseFile, []byte{}, 0600)).To(Succeed())
+			})
+
+			It("resumes syncing and removes the pause file", func() {
+				req, err := http.NewRequest("PUT", "/", strings.NewReader(`{"paused":false}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(state.ResumeASGSyncingCallCount()).To(Equal(1))
+				Expect(pauseFile).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when no pause file is configured", func() {
+			BeforeEach(func() {
+				handler.PauseFile = ""
+			})
+
+			It("only changes the in-memory state", func() {
+				req, err := http.NewRequest("PUT", "/", strings.NewReader(`{"paused":true}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(state.PauseASGSyncingCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the body is not valid json", func() {
+			It("returns a bad request", func() {
+				req, err := http.NewRequest("PUT", "/", strings.NewReader(`{`))
+				Expect(err).NotTo(HaveOccurred())
+
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"error": "decoding request body as json"}`))
+			})
+		})
+
+		Context("when the paused key is missing", func() {
+			It("returns a bad request", func() {
+				req, err := http.NewRequest("PUT", "/", strings.NewReader(`{}`))
+				Expect(err).NotTo(HaveOccurred())
+
+				handler.ServeHTTP(recorder, req)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"error": "missing required key 'paused'"}`))
+				Expect(state.PauseASGSyncingCallCount()).To(Equal(0))
+				Expect(state.ResumeASGSyncingCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("when ASG syncing is disabled", func() {
+		BeforeEach(func() {
+			handler.EnableASGSyncing = false
+		})
+
+		It("returns a 405", func() {
+			req, err := http.NewRequest("PUT", "/", strings.NewReader(`{"paused":true}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(recorder.Body.String()).To(MatchJSON(`{"error": "ASG syncing has been disabled administratively"}`))
+			Expect(state.PauseASGSyncingCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+)
+
+type DrainContainer struct {
+	DrainFunc func(container string) (converger.DrainResult, error)
+}
+
+func (h *DrainContainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`{ "error": "draining a container requires a POST" }`))
+		return
+	}
+
+	container := r.URL.Query().Get("container")
+	if container == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{ "error": "no container specified" }`))
+		return
+	}
+
+	result, err := h.DrainFunc(container)
+	response := struct {
+		converger.DrainResult
+		Error string `json:"error,omitempty"`
+	}{DrainResult: result}
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
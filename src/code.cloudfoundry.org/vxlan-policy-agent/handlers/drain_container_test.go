@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Drain Container", func() {
+	var (
+		response         *httptest.ResponseRecorder
+		request          *http.Request
+		drainedContainer string
+		drainErr         error
+		handler          *handlers.DrainContainer
+	)
+
+	BeforeEach(func() {
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("POST", "/drain-container?container=some-handle", nil)
+		drainedContainer = ""
+		drainErr = nil
+
+		handler = &handlers.DrainContainer{
+			DrainFunc: func(container string) (converger.DrainResult, error) {
+				drainedContainer = container
+				return converger.DrainResult{
+					Container:        container,
+					IP:               "10.255.1.2",
+					DatastoreRemoved: true,
+					ASGChainsRemoved: []string{"asg-a1b2c3v1-g7cs183k3a"},
+					WrapperChainsRemoved: []string{
+						"filter/netout--some-handle",
+						"nat/netin--some-handle",
+					},
+					PoliciesUpdated: true,
+				}, drainErr
+			},
+		}
+	})
+
+	It("drains the container and reports the result", func() {
+		handler.ServeHTTP(response, request)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(drainedContainer).To(Equal("some-handle"))
+		Expect(response.Body.String()).To(MatchJSON(`{
+			"container": "some-handle",
+			"ip": "10.255.1.2",
+			"datastore_entry_removed": true,
+			"asg_chains_removed": ["asg-a1b2c3v1-g7cs183k3a"],
+			"wrapper_chains_removed": ["filter/netout--some-handle", "nat/netin--some-handle"],
+			"policies_updated": true
+		}`))
+	})
+
+	Context("when the request is not a POST", func() {
+		BeforeEach(func() {
+			request = httptest.NewRequest("GET", "/drain-container?container=some-handle", nil)
+		})
+
+		It("returns 405 without draining", func() {
+			handler.ServeHTTP(response, request)
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(response.Header().Get("Allow")).To(Equal("POST"))
+			Expect(drainedContainer).To(BeEmpty())
+		})
+	})
+
+	Context("when no container is specified", func() {
+		BeforeEach(func() {
+			request = httptest.NewRequest("POST", "/drain-container", nil)
+		})
+
+		It("returns 400", func() {
+			handler.ServeHTTP(response, request)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(drainedContainer).To(BeEmpty())
+		})
+	})
+
+	Context("when draining fails", func() {
+		BeforeEach(func() {
+			drainErr = errors.New("banana")
+		})
+
+		It("returns 500 with the partial result and the error", func() {
+			handler.ServeHTTP(response, request)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{
+				"container": "some-handle",
+				"ip": "10.255.1.2",
+				"datastore_entry_removed": true,
+				"asg_chains_removed": ["asg-a1b2c3v1-g7cs183k3a"],
+				"wrapper_chains_removed": ["filter/netout--some-handle", "nat/netin--some-handle"],
+				"policies_updated": true,
+				"error": "banana"
+			}`))
+		})
+	})
+})
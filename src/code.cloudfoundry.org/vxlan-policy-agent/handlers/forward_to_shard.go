@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ForwardToShard serves the requests about a container on the worker of a
+// sharded agent that owns the container. Requests for the containers of the
+// worker, or without a container, are served by Handler.
+type ForwardToShard struct {
+	ShardOf func(handle string) int
+	Index   int
+	// WorkerAddresses are the host:port of the force servers of the workers,
+	// by shard.
+	WorkerAddresses []string
+	Handler         http.Handler
+}
+
+func (h *ForwardToShard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	container := r.URL.Query().Get("container")
+	if container == "" {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	shard := h.ShardOf(container)
+	if shard == h.Index || shard >= len(h.WorkerAddresses) {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: h.WorkerAddresses[shard]})
+	proxy.ServeHTTP(w, r)
+}
@@ -0,0 +1,76 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ForwardToShard", func() {
+	var (
+		worker   *httptest.Server
+		response *httptest.ResponseRecorder
+		handler  *handlers.ForwardToShard
+		served   []string
+	)
+
+	BeforeEach(func() {
+		served = nil
+		worker = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("worker 1: " + r.URL.RequestURI()))
+		}))
+		response = httptest.NewRecorder()
+		handler = &handlers.ForwardToShard{
+			ShardOf: func(handle string) int {
+				if strings.HasPrefix(handle, "other-") {
+					return 1
+				}
+				return 0
+			},
+			Index:           0,
+			WorkerAddresses: []string{"127.0.0.1:1", strings.TrimPrefix(worker.URL, "http://")},
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = append(served, r.URL.RequestURI())
+				w.Write([]byte("coordinator"))
+			}),
+		}
+	})
+
+	AfterEach(func() {
+		worker.Close()
+	})
+
+	It("serves the containers of its own shard", func() {
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/force-asgs-for-container?container=some-handle", nil))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(served).To(Equal([]string{"/force-asgs-for-container?container=some-handle"}))
+	})
+
+	It("forwards the containers of other shards to their worker", func() {
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/force-asgs-for-container?container=other-handle", nil))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(io.ReadAll(response.Body)).To(Equal([]byte("worker 1: /force-asgs-for-container?container=other-handle")))
+		Expect(served).To(BeEmpty())
+	})
+
+	It("serves the requests without a container", func() {
+		handler.ServeHTTP(response, httptest.NewRequest("GET", "/force-asgs-for-container", nil))
+		Expect(served).To(HaveLen(1))
+	})
+
+	Context("when the worker cannot be reached", func() {
+		BeforeEach(func() {
+			worker.Close()
+		})
+
+		It("returns a bad gateway", func() {
+			handler.ServeHTTP(response, httptest.NewRequest("GET", "/force-asgs-for-container?container=other-handle", nil))
+			Expect(response.Code).To(Equal(http.StatusBadGateway))
+		})
+	})
+})
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type Health struct {
+	ASGSyncingState  asgSyncingState
+	EnableASGSyncing bool
+}
+
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := struct {
+		Healthy    bool `json:"healthy"`
+		ASGSyncing struct {
+			Enabled     bool       `json:"enabled"`
+			Paused      bool       `json:"paused"`
+			PausedSince *time.Time `json:"paused_since,omitempty"`
+		} `json:"asg_syncing"`
+	}{Healthy: true}
+
+	asgStatus := asgSyncingStatus(h.EnableASGSyncing, h.ASGSyncingState)
+	status.ASGSyncing.Enabled = h.EnableASGSyncing
+	status.ASGSyncing.Paused = asgStatus.Paused
+	status.ASGSyncing.PausedSince = asgStatus.PausedSince
+
+	json.NewEncoder(w).Encode(status)
+}
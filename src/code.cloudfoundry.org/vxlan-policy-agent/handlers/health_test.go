@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers"
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		handler  *handlers.Health
+		recorder *httptest.ResponseRecorder
+		state    *fakes.ASGSyncingState
+		request  *http.Request
+	)
+
+	BeforeEach(func() {
+		recorder = httptest.NewRecorder()
+		state = &fakes.ASGSyncingState{}
+		handler = &handlers.Health{
+			ASGSyncingState:  state,
+			EnableASGSyncing: true,
+		}
+
+		var err error
+		request, err = http.NewRequest("GET", "/health", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports that ASG syncing is active", func() {
+		handler.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"healthy": true,
+			"asg_syncing": {"enabled": true, "paused": false}
+		}`))
+	})
+
+	Context("when ASG syncing is paused", func() {
+		BeforeEach(func() {
+			state.ASGSyncingPausedSinceReturns(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), true)
+		})
+
+		It("reports the paused state", func() {
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"healthy": true,
+				"asg_syncing": {"enabled": true, "paused": true, "paused_since": "2020-01-02T03:04:05Z"}
+			}`))
+		})
+	})
+
+	Context("when ASG syncing is disabled", func() {
+		BeforeEach(func() {
+			handler.EnableASGSyncing = false
+		})
+
+		It("reports that syncing is disabled", func() {
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Body.String()).To(MatchJSON(`{
+				"healthy": true,
+				"asg_syncing": {"enabled": false, "paused": false}
+			}`))
+			Expect(state.ASGSyncingPausedSinceCallCount()).To(Equal(0))
+		})
+	})
+})
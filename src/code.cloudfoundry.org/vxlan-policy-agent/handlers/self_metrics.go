@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+)
+
+//go:generate counterfeiter -o fakes/cacheSizer.go --fake-name CacheSizer . cacheSizer
+type cacheSizer interface {
+	CacheSizes() map[string]int
+}
+
+// SelfMetrics reports the agent's own memory use and cache sizes, so that
+// memory growth can be investigated without restarting the agent.
+type SelfMetrics struct {
+	Caches cacheSizer
+}
+
+type memoryStats struct {
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes uint64 `json:"heap_inuse_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+func (h *SelfMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	json.NewEncoder(w).Encode(struct {
+		Goroutines int            `json:"goroutines"`
+		Memory     memoryStats    `json:"memory"`
+		Caches     map[string]int `json:"caches"`
+	}{
+		Goroutines: runtime.NumGoroutine(),
+		Memory: memoryStats{
+			HeapAllocBytes: memStats.HeapAlloc,
+			HeapInuseBytes: memStats.HeapInuse,
+			HeapObjects:    memStats.HeapObjects,
+			SysBytes:       memStats.Sys,
+			NumGC:          memStats.NumGC,
+		},
+		Caches: h.Caches.CacheSizes(),
+	})
+}
@@ -0,0 +1,51 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers"
+	"code.cloudfoundry.org/vxlan-policy-agent/handlers/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelfMetrics", func() {
+	var (
+		handler  *handlers.SelfMetrics
+		caches   *fakes.CacheSizer
+		recorder *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		caches = &fakes.CacheSizer{}
+		caches.CacheSizesReturns(map[string]int{"asg_rule_sets": 3})
+		handler = &handlers.SelfMetrics{Caches: caches}
+		recorder = httptest.NewRecorder()
+	})
+
+	It("reports goroutines, memory and cache sizes", func() {
+		req, err := http.NewRequest("GET", "/self-metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		handler.ServeHTTP(recorder, req)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var body struct {
+			Goroutines int               `json:"goroutines"`
+			Memory     map[string]uint64 `json:"memory"`
+			Caches     map[string]int    `json:"caches"`
+		}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Goroutines).To(BeNumerically(">", 0))
+		Expect(body.Memory).To(HaveKey("heap_alloc_bytes"))
+		Expect(body.Memory).To(HaveKey("heap_inuse_bytes"))
+		Expect(body.Memory).To(HaveKey("heap_objects"))
+		Expect(body.Memory).To(HaveKey("sys_bytes"))
+		Expect(body.Memory).To(HaveKey("num_gc"))
+		Expect(body.Memory["heap_alloc_bytes"]).To(BeNumerically(">", 0))
+		Expect(body.Caches).To(Equal(map[string]int{"asg_rule_sets": 3}))
+	})
+})
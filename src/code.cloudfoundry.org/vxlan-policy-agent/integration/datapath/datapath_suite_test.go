@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package datapath_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDatapath(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Datapath Suite")
+}
@@ -0,0 +1,157 @@
+//go:build linux
+// +build linux
+
+package datapath_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/testsupport/netsandbox"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer/fakes"
+	goiptables "github.com/coreos/go-iptables/iptables"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Datapath", func() {
+	var (
+		sandbox      *netsandbox.Sandbox
+		client       *netsandbox.Container
+		listeners    []net.Listener
+		lockDir      string
+		iptables     *rules.LockedIPTables
+		ruleEnforcer *enforcer.Enforcer
+	)
+
+	// enforce enforces the rules in a chain that the FORWARD chain of the
+	// cell jumps to, replacing the chain enforced before.
+	enforce := func(ruleSpec ...rules.IPTablesRule) {
+		err := sandbox.Do(func() error {
+			_, err := ruleEnforcer.EnforceRulesAndChain(enforcer.RulesWithChain{
+				Chain: enforcer.Chain{Table: "filter", ParentChain: "FORWARD", Prefix: "datapath-"},
+				Rules: ruleSpec,
+			})
+			return err
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	connects := func(address string) bool {
+		conn, err := client.Dial("tcp", address)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	BeforeEach(func() {
+		listeners = nil
+		lockDir = ""
+		var err error
+		sandbox, err = netsandbox.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		client, err = sandbox.AddContainer("client", "10.255.1.2/24")
+		Expect(err).NotTo(HaveOccurred())
+		server, err := sandbox.AddContainer("server", "10.255.2.2/24")
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, address := range []string{":8080", ":9090"} {
+			listener, err := server.Listen("tcp", address)
+			Expect(err).NotTo(HaveOccurred())
+			listeners = append(listeners, listener)
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					conn.Close()
+				}
+			}()
+		}
+
+		lockDir, err = os.MkdirTemp("", "datapath-")
+		Expect(err).NotTo(HaveOccurred())
+		ipt, err := goiptables.New()
+		Expect(err).NotTo(HaveOccurred())
+		iptables = &rules.LockedIPTables{
+			IPTables: ipt,
+			Locker: &filelock.Locker{
+				FileLocker: filelock.NewLocker(filepath.Join(lockDir, "iptables.lock")),
+				Mutex:      &sync.Mutex{},
+			},
+			Restorer: &rules.Restorer{},
+		}
+
+		var now int64 = 1000
+		timestamper := &fakes.TimeStamper{}
+		timestamper.CurrentTimeStub = func() int64 {
+			now++
+			return now
+		}
+		ruleEnforcer = enforcer.NewEnforcer(lagertest.NewTestLogger("test"), timestamper, iptables, enforcer.EnforcerConfig{})
+	})
+
+	AfterEach(func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		// the sandbox needs a network namespace, so BeforeEach may fail
+		// before creating it
+		if sandbox != nil {
+			Expect(sandbox.Close()).To(Succeed())
+		}
+		if lockDir != "" {
+			os.RemoveAll(lockDir)
+		}
+	})
+
+	It("lets the first matching rule decide", func() {
+		enforce(
+			rules.IPTablesRule{"-d", "10.255.2.2/32", "-p", "tcp", "--dport", "8080", "-j", "REJECT"},
+			rules.IPTablesRule{"-d", "10.255.2.2/32", "-j", "ACCEPT"},
+		)
+		Expect(connects("10.255.2.2:8080")).To(BeFalse())
+		Expect(connects("10.255.2.2:9090")).To(BeTrue())
+
+		enforce(
+			rules.IPTablesRule{"-d", "10.255.2.2/32", "-j", "ACCEPT"},
+			rules.IPTablesRule{"-d", "10.255.2.2/32", "-p", "tcp", "--dport", "8080", "-j", "REJECT"},
+		)
+		Expect(connects("10.255.2.2:8080")).To(BeTrue())
+
+		output, err := sandbox.IPTablesRules("filter")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(output)).To(ContainSubstring("-A FORWARD -j datapath-1002"))
+		Expect(string(output)).NotTo(ContainSubstring("datapath-1001"))
+	})
+
+	It("limits the rate of new connections with hashlimit", func() {
+		err := sandbox.Do(func() error {
+			if err := iptables.NewChain("filter", "rate-limit-reject"); err != nil {
+				return err
+			}
+			return iptables.BulkAppend("filter", "rate-limit-reject", rules.IPTablesRule{"-j", "REJECT"})
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		enforce(
+			rules.NewNetOutConnRateLimitRule("1/minute", "2", "client-handle", "60000", "rate-limit-reject"),
+			rules.IPTablesRule{"-j", "ACCEPT"},
+		)
+		Expect(connects("10.255.2.2:8080")).To(BeTrue())
+		Expect(connects("10.255.2.2:8080")).To(BeTrue())
+		Expect(connects("10.255.2.2:8080")).To(BeFalse())
+		Expect(connects("10.255.2.2:9090")).To(BeTrue())
+	})
+})
@@ -0,0 +1,158 @@
+// Package plandump writes the plans of the enforcement cycles of the agent,
+// all the rule sets of a cycle, to a ring of files, so that a change of the
+// rules that is only reported after the fact can be found by comparing the
+// plans of two cycles.
+package plandump
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+const (
+	CyclePolicy = "policy"
+	CycleASG    = "asg"
+)
+
+// Dump is the plan of one cycle.
+type Dump struct {
+	Cycle     string                    `json:"cycle"`
+	PlannedAt time.Time                 `json:"planned_at"`
+	RuleSets  []enforcer.RulesWithChain `json:"rule_sets"`
+}
+
+// Writer writes every plan to a file in Dir named after its cycle and the
+// time it was planned, and keeps the last Keep plans of each cycle, or all of
+// them if Keep is 0.
+type Writer struct {
+	Dir  string
+	Keep int
+	Now  func() time.Time
+}
+
+func (w *Writer) Write(cycle string, ruleSets []enforcer.RulesWithChain) error {
+	dump := Dump{Cycle: cycle, PlannedAt: w.Now().UTC(), RuleSets: ruleSets}
+
+	contents, err := json.Marshal(dump)
+	if err != nil {
+		return fmt.Errorf("marshaling plan: %s", err)
+	}
+	if err := os.MkdirAll(w.Dir, 0700); err != nil {
+		return fmt.Errorf("creating plan dir: %s", err)
+	}
+
+	// the name of a plan sorts by the time it was planned
+	name := fmt.Sprintf("%s-%s.json", cycle, dump.PlannedAt.Format("20060102T150405.000000000Z"))
+	tmp := filepath.Join(w.Dir, "."+name)
+	if err := os.WriteFile(tmp, contents, 0600); err != nil {
+		return fmt.Errorf("writing plan: %s", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(w.Dir, name)); err != nil {
+		return fmt.Errorf("writing plan: %s", err)
+	}
+
+	return w.prune(cycle)
+}
+
+func (w *Writer) prune(cycle string) error {
+	if w.Keep <= 0 {
+		return nil
+	}
+	names, err := List(w.Dir, cycle)
+	if err != nil {
+		return err
+	}
+	for len(names) > w.Keep {
+		if err := os.Remove(filepath.Join(w.Dir, names[0])); err != nil {
+			return fmt.Errorf("removing old plan: %s", err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// List returns the names of the plans of a cycle in dir, oldest first.
+func List(dir, cycle string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing plans: %s", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, cycle+"-") && strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func Read(path string) (Dump, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Dump{}, fmt.Errorf("reading plan: %s", err)
+	}
+	var dump Dump
+	if err := json.Unmarshal(contents, &dump); err != nil {
+		return Dump{}, fmt.Errorf("reading plan %s: %s", path, err)
+	}
+	return dump, nil
+}
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// RuleSetChange is a rule set that was added, removed or changed between two
+// plans.
+type RuleSetChange struct {
+	Chain  enforcer.Chain
+	Handle string
+	Change string
+	Diff   enforcer.RuleSetDiff
+}
+
+// Diff compares the rule sets of two plans by their chains, ordered by
+// their tables, parent chains and prefixes.
+func Diff(old, new Dump) []RuleSetChange {
+	oldRuleSets := map[enforcer.Chain]enforcer.RulesWithChain{}
+	for _, ruleSet := range old.RuleSets {
+		oldRuleSets[ruleSet.Chain] = ruleSet
+	}
+
+	changes := []RuleSetChange{}
+	for _, ruleSet := range new.RuleSets {
+		oldRuleSet, ok := oldRuleSets[ruleSet.Chain]
+		delete(oldRuleSets, ruleSet.Chain)
+		switch {
+		case !ok:
+			changes = append(changes, RuleSetChange{Chain: ruleSet.Chain, Handle: ruleSet.Handle, Change: ChangeAdded, Diff: enforcer.DiffRuleSets(enforcer.RulesWithChain{}, ruleSet)})
+		case !ruleSet.Equals(oldRuleSet):
+			changes = append(changes, RuleSetChange{Chain: ruleSet.Chain, Handle: ruleSet.Handle, Change: ChangeChanged, Diff: enforcer.DiffRuleSets(oldRuleSet, ruleSet)})
+		}
+	}
+	for _, ruleSet := range oldRuleSets {
+		changes = append(changes, RuleSetChange{Chain: ruleSet.Chain, Handle: ruleSet.Handle, Change: ChangeRemoved, Diff: enforcer.DiffRuleSets(ruleSet, enforcer.RulesWithChain{})})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Chain.Table != changes[j].Chain.Table {
+			return changes[i].Chain.Table < changes[j].Chain.Table
+		}
+		if changes[i].Chain.ParentChain != changes[j].Chain.ParentChain {
+			return changes[i].Chain.ParentChain < changes[j].Chain.ParentChain
+		}
+		return changes[i].Chain.Prefix < changes[j].Chain.Prefix
+	})
+	return changes
+}
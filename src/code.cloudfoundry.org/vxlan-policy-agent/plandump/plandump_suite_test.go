@@ -0,0 +1,13 @@
+package plandump_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPlanDump(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "PlanDump Suite")
+}
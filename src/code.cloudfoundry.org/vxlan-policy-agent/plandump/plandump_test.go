@@ -0,0 +1,163 @@
+package plandump_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/plandump"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Writer", func() {
+	var (
+		dir      string
+		now      time.Time
+		writer   *plandump.Writer
+		ruleSets []enforcer.RulesWithChain
+	)
+
+	BeforeEach(func() {
+		dir = filepath.Join(GinkgoT().TempDir(), "plans")
+		now = time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+		writer = &plandump.Writer{
+			Dir:  dir,
+			Keep: 2,
+			Now: func() time.Time {
+				now = now.Add(time.Second)
+				return now
+			},
+		}
+		ruleSets = []enforcer.RulesWithChain{{
+			Chain: enforcer.Chain{Table: "filter", ParentChain: "FORWARD", Prefix: "vpa--"},
+			Rules: []rules.IPTablesRule{{"-j", "ACCEPT"}},
+		}}
+	})
+
+	It("writes the plan of a cycle to a file named after the cycle and the time", func() {
+		Expect(writer.Write(plandump.CyclePolicy, ruleSets)).To(Succeed())
+
+		names, err := plandump.List(dir, plandump.CyclePolicy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"policy-20260102T030406.000000006Z.json"}))
+
+		dump, err := plandump.Read(filepath.Join(dir, names[0]))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dump.Cycle).To(Equal("policy"))
+		Expect(dump.PlannedAt).To(Equal(time.Date(2026, 1, 2, 3, 4, 6, 6, time.UTC)))
+		Expect(dump.RuleSets).To(Equal(ruleSets))
+	})
+
+	It("keeps the last plans of each cycle", func() {
+		for i := 0; i < 3; i++ {
+			Expect(writer.Write(plandump.CyclePolicy, ruleSets)).To(Succeed())
+		}
+		Expect(writer.Write(plandump.CycleASG, ruleSets)).To(Succeed())
+
+		names, err := plandump.List(dir, plandump.CyclePolicy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{
+			"policy-20260102T030407.000000006Z.json",
+			"policy-20260102T030408.000000006Z.json",
+		}))
+		Expect(plandump.List(dir, plandump.CycleASG)).To(HaveLen(1))
+	})
+
+	It("keeps all plans when Keep is 0", func() {
+		writer.Keep = 0
+		for i := 0; i < 3; i++ {
+			Expect(writer.Write(plandump.CyclePolicy, ruleSets)).To(Succeed())
+		}
+		Expect(plandump.List(dir, plandump.CyclePolicy)).To(HaveLen(3))
+	})
+
+	It("leaves no temporary files behind", func() {
+		Expect(writer.Write(plandump.CyclePolicy, ruleSets)).To(Succeed())
+		entries, err := os.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	Context("when the dir cannot be created", func() {
+		BeforeEach(func() {
+			file := filepath.Join(GinkgoT().TempDir(), "file")
+			Expect(os.WriteFile(file, nil, 0600)).To(Succeed())
+			writer.Dir = filepath.Join(file, "plans")
+		})
+
+		It("returns an error", func() {
+			Expect(writer.Write(plandump.CyclePolicy, ruleSets)).To(MatchError(ContainSubstring("creating plan dir")))
+		})
+	})
+})
+
+var _ = Describe("Read", func() {
+	It("returns an error when the plan cannot be parsed", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "policy.json")
+		Expect(os.WriteFile(path, []byte("{"), 0600)).To(Succeed())
+		_, err := plandump.Read(path)
+		Expect(err).To(MatchError(ContainSubstring("reading plan " + path)))
+	})
+})
+
+var _ = Describe("Diff", func() {
+	var (
+		unchanged, changed, removed, added enforcer.RulesWithChain
+	)
+
+	BeforeEach(func() {
+		unchanged = enforcer.RulesWithChain{
+			Chain: enforcer.Chain{Table: "filter", ParentChain: "FORWARD", Prefix: "vpa--"},
+			Rules: []rules.IPTablesRule{{"-j", "ACCEPT"}},
+		}
+		changed = enforcer.RulesWithChain{
+			Chain:  enforcer.Chain{Table: "filter", ParentChain: "netout-1", Prefix: "asg-1"},
+			Handle: "container-1",
+			Rules:  []rules.IPTablesRule{{"-d", "10.0.0.1", "-j", "ACCEPT"}},
+		}
+		removed = enforcer.RulesWithChain{
+			Chain:  enforcer.Chain{Table: "filter", ParentChain: "netout-2", Prefix: "asg-2"},
+			Handle: "container-2",
+			Rules:  []rules.IPTablesRule{{"-j", "REJECT"}},
+		}
+		added = enforcer.RulesWithChain{
+			Chain:  enforcer.Chain{Table: "filter", ParentChain: "netout-3", Prefix: "asg-3"},
+			Handle: "container-3",
+			Rules:  []rules.IPTablesRule{{"-j", "ACCEPT"}},
+		}
+	})
+
+	It("reports the rule sets that were added, removed or changed", func() {
+		newChanged := changed
+		newChanged.Rules = []rules.IPTablesRule{{"-d", "10.0.0.2", "-j", "ACCEPT"}}
+
+		changes := plandump.Diff(
+			plandump.Dump{RuleSets: []enforcer.RulesWithChain{unchanged, changed, removed}},
+			plandump.Dump{RuleSets: []enforcer.RulesWithChain{added, newChanged, unchanged}},
+		)
+
+		Expect(changes).To(HaveLen(3))
+		Expect(changes[0].Chain).To(Equal(changed.Chain))
+		Expect(changes[0].Handle).To(Equal("container-1"))
+		Expect(changes[0].Change).To(Equal(plandump.ChangeChanged))
+		Expect(changes[0].Diff.Added).To(Equal(1))
+		Expect(changes[0].Diff.Removed).To(Equal(1))
+
+		Expect(changes[1].Chain).To(Equal(removed.Chain))
+		Expect(changes[1].Change).To(Equal(plandump.ChangeRemoved))
+		Expect(changes[1].Diff.Removed).To(Equal(1))
+
+		Expect(changes[2].Chain).To(Equal(added.Chain))
+		Expect(changes[2].Change).To(Equal(plandump.ChangeAdded))
+		Expect(changes[2].Diff.Added).To(Equal(1))
+	})
+
+	It("reports nothing for equal plans", func() {
+		dump := plandump.Dump{RuleSets: []enforcer.RulesWithChain{unchanged, changed}}
+		Expect(plandump.Diff(dump, dump)).To(BeEmpty())
+	})
+})
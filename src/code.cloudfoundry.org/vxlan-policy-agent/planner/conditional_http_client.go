@@ -0,0 +1,179 @@
+package planner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
+)
+
+// maxCachedResponses bounds the cache, as the policy IDs in the query change
+// with the containers on the cell.
+const maxCachedResponses = 64
+
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+	lastUsed     uint64
+}
+
+// ConditionalHTTPClient sends conditional GET requests to the policy server.
+// When the server answers 304 Not Modified, the body of the last response to
+// the same request is returned instead, so callers do not need to know about
+// it. Responses are kept per full URL and request body, so that the pages of
+// the security groups do not replace each other.
+type ConditionalHTTPClient struct {
+	HTTPClient json_client.HttpClient
+
+	mutex       sync.Mutex
+	cache       map[string]*cachedResponse
+	notModified map[string]bool
+	uses        uint64
+}
+
+func (c *ConditionalHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if request.Method != "GET" {
+		return c.HTTPClient.Do(request)
+	}
+
+	route := request.URL.Path
+	key, err := cacheKey(request)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, ok := c.lookup(key)
+	if ok {
+		if cached.etag != "" {
+			request.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			request.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return response, err
+	}
+
+	if response.StatusCode == http.StatusNotModified && ok {
+		response.Body.Close()
+		c.record(route, key, nil, true)
+		return &http.Response{
+			Status:     "200 OK",
+			StatusCode: http.StatusOK,
+			Proto:      response.Proto,
+			ProtoMajor: response.ProtoMajor,
+			ProtoMinor: response.ProtoMinor,
+			Header:     cached.header.Clone(),
+			Body:       io.NopCloser(bytes.NewReader(cached.body)),
+			Request:    request,
+		}, nil
+	}
+
+	etag := response.Header.Get("ETag")
+	lastModified := response.Header.Get("Last-Modified")
+	if response.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		c.record(route, key, nil, false)
+		return response, nil
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		c.record(route, key, nil, false)
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	c.record(route, key, &cachedResponse{
+		etag:         etag,
+		lastModified: lastModified,
+		header:       response.Header.Clone(),
+		body:         body,
+	}, false)
+	return response, nil
+}
+
+func (c *ConditionalHTTPClient) CloseIdleConnections() {
+	c.HTTPClient.CloseIdleConnections()
+}
+
+// NotModified reports whether the last request to the route was answered
+// from the cache.
+func (c *ConditionalHTTPClient) NotModified(route string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.notModified[route]
+}
+
+// cacheKey identifies a request by its full URL and a hash of its body. The
+// body is read and put back.
+func cacheKey(request *http.Request) (string, error) {
+	var body []byte
+	if request.Body != nil && request.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	bodyHash := sha256.Sum256(body)
+	return request.URL.String() + " " + hex.EncodeToString(bodyHash[:]), nil
+}
+
+func (c *ConditionalHTTPClient) lookup(key string) (cachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, ok := c.cache[key]
+	if !ok {
+		return cachedResponse{}, false
+	}
+	c.uses++
+	cached.lastUsed = c.uses
+	return *cached, true
+}
+
+func (c *ConditionalHTTPClient) record(route, key string, response *cachedResponse, notModified bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.cache == nil {
+		c.cache = make(map[string]*cachedResponse)
+		c.notModified = make(map[string]bool)
+	}
+	if response != nil {
+		c.uses++
+		response.lastUsed = c.uses
+		c.cache[key] = response
+		c.evict()
+	} else if !notModified {
+		delete(c.cache, key)
+	}
+	c.notModified[route] = notModified
+}
+
+// evict drops the least recently used responses above maxCachedResponses.
+func (c *ConditionalHTTPClient) evict() {
+	for len(c.cache) > maxCachedResponses {
+		oldestKey := ""
+		var oldest uint64
+		for key, cached := range c.cache {
+			if oldestKey == "" || cached.lastUsed < oldest {
+				oldestKey = key
+				oldest = cached.lastUsed
+			}
+		}
+		delete(c.cache, oldestKey)
+	}
+}
@@ -0,0 +1,148 @@
+package planner_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConditionalHTTPClient", func() {
+	var (
+		server          *httptest.Server
+		client          *planner.ConditionalHTTPClient
+		etag            string
+		body            string
+		receivedHeaders []http.Header
+	)
+
+	BeforeEach(func() {
+		etag = `"v1"`
+		body = `{"policies": [{"source": {"id": "some-app-guid"}}]}`
+		receivedHeaders = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedHeaders = append(receivedHeaders, r.Header.Clone())
+			if etag != "" {
+				if r.Header.Get("If-None-Match") == etag {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", etag)
+			}
+			w.Write([]byte(body))
+		}))
+		client = &planner.ConditionalHTTPClient{HTTPClient: server.Client()}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(route string) (int, string) {
+		request, err := http.NewRequest("GET", server.URL+route, nil)
+		Expect(err).NotTo(HaveOccurred())
+		response, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		respBody, err := io.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return response.StatusCode, string(respBody)
+	}
+
+	It("returns the cached body when the server answers not modified", func() {
+		code, respBody := get("/networking/v1/internal/policies?id=a,b")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(respBody).To(Equal(body))
+		Expect(client.NotModified("/networking/v1/internal/policies")).To(BeFalse())
+
+		code, respBody = get("/networking/v1/internal/policies?id=a,b")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(respBody).To(Equal(body))
+		Expect(receivedHeaders[1].Get("If-None-Match")).To(Equal(`"v1"`))
+		Expect(client.NotModified("/networking/v1/internal/policies")).To(BeTrue())
+	})
+
+	It("returns the new body when the version changed", func() {
+		get("/networking/v1/internal/policies?id=a,b")
+		etag = `"v2"`
+		body = `{"policies": []}`
+
+		_, respBody := get("/networking/v1/internal/policies?id=a,b")
+		Expect(respBody).To(Equal(`{"policies": []}`))
+		Expect(client.NotModified("/networking/v1/internal/policies")).To(BeFalse())
+	})
+
+	It("does not send a version for a different query", func() {
+		get("/networking/v1/internal/policies?id=a,b")
+		get("/networking/v1/internal/policies?id=a")
+		Expect(receivedHeaders[1].Get("If-None-Match")).To(BeEmpty())
+	})
+
+	It("keeps the responses of each page of a route", func() {
+		get("/networking/v1/internal/security_groups?per_page=2")
+		get("/networking/v1/internal/security_groups?per_page=2&from=some-token")
+		get("/networking/v1/internal/security_groups?per_page=2")
+		get("/networking/v1/internal/security_groups?per_page=2&from=some-token")
+
+		Expect(receivedHeaders[2].Get("If-None-Match")).To(Equal(`"v1"`))
+		Expect(receivedHeaders[3].Get("If-None-Match")).To(Equal(`"v1"`))
+		Expect(client.NotModified("/networking/v1/internal/security_groups")).To(BeTrue())
+	})
+
+	It("does not send a version for a request with a different body", func() {
+		send := func(requestBody string) {
+			request, err := http.NewRequest("GET", server.URL+"/networking/v1/internal/policies", strings.NewReader(requestBody))
+			Expect(err).NotTo(HaveOccurred())
+			response, err := client.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			response.Body.Close()
+		}
+
+		send(`{"ids": ["a"]}`)
+		send(`{"ids": ["b"]}`)
+		send(`{"ids": ["a"]}`)
+		Expect(receivedHeaders[1].Get("If-None-Match")).To(BeEmpty())
+		Expect(receivedHeaders[2].Get("If-None-Match")).To(Equal(`"v1"`))
+	})
+
+	It("drops the least recently used responses when the cache is full", func() {
+		get("/networking/v1/internal/policies?id=first")
+		for i := 0; i < 64; i++ {
+			get(fmt.Sprintf("/networking/v1/internal/policies?id=%d", i))
+		}
+		get("/networking/v1/internal/policies?id=first")
+		Expect(receivedHeaders[65].Get("If-None-Match")).To(BeEmpty())
+
+		get("/networking/v1/internal/policies?id=63")
+		Expect(receivedHeaders[66].Get("If-None-Match")).To(Equal(`"v1"`))
+	})
+
+	Context("when the server does not return a version", func() {
+		BeforeEach(func() {
+			etag = ""
+		})
+
+		It("does not send conditional requests", func() {
+			get("/networking/v1/internal/policies?id=a,b")
+			get("/networking/v1/internal/policies?id=a,b")
+			Expect(receivedHeaders[1].Get("If-None-Match")).To(BeEmpty())
+			Expect(receivedHeaders[1].Get("If-Modified-Since")).To(BeEmpty())
+			Expect(client.NotModified("/networking/v1/internal/policies")).To(BeFalse())
+		})
+	})
+
+	It("passes other methods through", func() {
+		request, err := http.NewRequest("PUT", server.URL+"/networking/v1/internal/tags", nil)
+		Expect(err).NotTo(HaveOccurred())
+		response, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		response.Body.Close()
+		Expect(client.NotModified("/networking/v1/internal/tags")).To(BeFalse())
+	})
+})
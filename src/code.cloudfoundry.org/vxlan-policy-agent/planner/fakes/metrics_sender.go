@@ -7,26 +7,61 @@ import (
 )
 
 type MetricsSender struct {
+	IncrementCounterStub        func(string)
+	incrementCounterMutex       sync.RWMutex
+	incrementCounterArgsForCall []struct {
+		arg1 string
+	}
 	SendDurationStub        func(string, time.Duration)
 	sendDurationMutex       sync.RWMutex
 	sendDurationArgsForCall []struct {
 		arg1 string
 		arg2 time.Duration
 	}
+	SendValueStub        func(string, float64, string)
+	sendValueMutex       sync.RWMutex
+	sendValueArgsForCall []struct {
+		arg1 string
+		arg2 float64
+		arg3 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *MetricsSender) IncrementCounter(arg1 string) {
+	fake.incrementCounterMutex.Lock()
+	fake.incrementCounterArgsForCall = append(fake.incrementCounterArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("IncrementCounter", []interface{}{arg1})
+	fake.incrementCounterMutex.Unlock()
+	if fake.IncrementCounterStub != nil {
+		fake.IncrementCounterStub(arg1)
+	}
+}
+
+func (fake *MetricsSender) IncrementCounterCallCount() int {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	return len(fake.incrementCounterArgsForCall)
+}
+
+func (fake *MetricsSender) IncrementCounterArgsForCall(i int) string {
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
+	return fake.incrementCounterArgsForCall[i].arg1
+}
+
 func (fake *MetricsSender) SendDuration(arg1 string, arg2 time.Duration) {
 	fake.sendDurationMutex.Lock()
 	fake.sendDurationArgsForCall = append(fake.sendDurationArgsForCall, struct {
 		arg1 string
 		arg2 time.Duration
 	}{arg1, arg2})
-	stub := fake.SendDurationStub
 	fake.recordInvocation("SendDuration", []interface{}{arg1, arg2})
 	fake.sendDurationMutex.Unlock()
-	if stub != nil {
+	if fake.SendDurationStub != nil {
 		fake.SendDurationStub(arg1, arg2)
 	}
 }
@@ -37,24 +72,47 @@ func (fake *MetricsSender) SendDurationCallCount() int {
 	return len(fake.sendDurationArgsForCall)
 }
 
-func (fake *MetricsSender) SendDurationCalls(stub func(string, time.Duration)) {
-	fake.sendDurationMutex.Lock()
-	defer fake.sendDurationMutex.Unlock()
-	fake.SendDurationStub = stub
-}
-
 func (fake *MetricsSender) SendDurationArgsForCall(i int) (string, time.Duration) {
 	fake.sendDurationMutex.RLock()
 	defer fake.sendDurationMutex.RUnlock()
-	argsForCall := fake.sendDurationArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return fake.sendDurationArgsForCall[i].arg1, fake.sendDurationArgsForCall[i].arg2
+}
+
+func (fake *MetricsSender) SendValue(arg1 string, arg2 float64, arg3 string) {
+	fake.sendValueMutex.Lock()
+	fake.sendValueArgsForCall = append(fake.sendValueArgsForCall, struct {
+		arg1 string
+		arg2 float64
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("SendValue", []interface{}{arg1, arg2, arg3})
+	fake.sendValueMutex.Unlock()
+	if fake.SendValueStub != nil {
+		fake.SendValueStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *MetricsSender) SendValueCallCount() int {
+	fake.sendValueMutex.RLock()
+	defer fake.sendValueMutex.RUnlock()
+	return len(fake.sendValueArgsForCall)
+}
+
+func (fake *MetricsSender) SendValueArgsForCall(i int) (string, float64, string) {
+	fake.sendValueMutex.RLock()
+	defer fake.sendValueMutex.RUnlock()
+	return fake.sendValueArgsForCall[i].arg1, fake.sendValueArgsForCall[i].arg2, fake.sendValueArgsForCall[i].arg3
 }
 
 func (fake *MetricsSender) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.incrementCounterMutex.RLock()
+	defer fake.incrementCounterMutex.RUnlock()
 	fake.sendDurationMutex.RLock()
 	defer fake.sendDurationMutex.RUnlock()
+	fake.sendValueMutex.RLock()
+	defer fake.sendValueMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -9,10 +9,11 @@ import (
 )
 
 type NetOutChain struct {
-	DefaultRulesStub        func(string) []rules.IPTablesRule
+	DefaultRulesStub        func(string, string) []rules.IPTablesRule
 	defaultRulesMutex       sync.RWMutex
 	defaultRulesArgsForCall []struct {
 		arg1 string
+		arg2 string
 	}
 	defaultRulesReturns struct {
 		result1 []rules.IPTablesRule
@@ -46,22 +47,34 @@ type NetOutChain struct {
 	nameReturnsOnCall map[int]struct {
 		result1 string
 	}
+	SNIRulesStub        func(string) []rules.IPTablesRule
+	sNIRulesMutex       sync.RWMutex
+	sNIRulesArgsForCall []struct {
+		arg1 string
+	}
+	sNIRulesReturns struct {
+		result1 []rules.IPTablesRule
+	}
+	sNIRulesReturnsOnCall map[int]struct {
+		result1 []rules.IPTablesRule
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *NetOutChain) DefaultRules(arg1 string) []rules.IPTablesRule {
+func (fake *NetOutChain) DefaultRules(arg1 string, arg2 string) []rules.IPTablesRule {
 	fake.defaultRulesMutex.Lock()
 	ret, specificReturn := fake.defaultRulesReturnsOnCall[len(fake.defaultRulesArgsForCall)]
 	fake.defaultRulesArgsForCall = append(fake.defaultRulesArgsForCall, struct {
 		arg1 string
-	}{arg1})
+		arg2 string
+	}{arg1, arg2})
 	stub := fake.DefaultRulesStub
 	fakeReturns := fake.defaultRulesReturns
-	fake.recordInvocation("DefaultRules", []interface{}{arg1})
+	fake.recordInvocation("DefaultRules", []interface{}{arg1, arg2})
 	fake.defaultRulesMutex.Unlock()
 	if stub != nil {
-		return stub(arg1)
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
@@ -75,17 +88,17 @@ func (fake *NetOutChain) DefaultRulesCallCount() int {
 	return len(fake.defaultRulesArgsForCall)
 }
 
-func (fake *NetOutChain) DefaultRulesCalls(stub func(string) []rules.IPTablesRule) {
+func (fake *NetOutChain) DefaultRulesCalls(stub func(string, string) []rules.IPTablesRule) {
 	fake.defaultRulesMutex.Lock()
 	defer fake.defaultRulesMutex.Unlock()
 	fake.DefaultRulesStub = stub
 }
 
-func (fake *NetOutChain) DefaultRulesArgsForCall(i int) string {
+func (fake *NetOutChain) DefaultRulesArgsForCall(i int) (string, string) {
 	fake.defaultRulesMutex.RLock()
 	defer fake.defaultRulesMutex.RUnlock()
 	argsForCall := fake.defaultRulesArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *NetOutChain) DefaultRulesReturns(result1 []rules.IPTablesRule) {
@@ -243,6 +256,67 @@ func (fake *NetOutChain) NameReturnsOnCall(i int, result1 string) {
 	}{result1}
 }
 
+func (fake *NetOutChain) SNIRules(arg1 string) []rules.IPTablesRule {
+	fake.sNIRulesMutex.Lock()
+	ret, specificReturn := fake.sNIRulesReturnsOnCall[len(fake.sNIRulesArgsForCall)]
+	fake.sNIRulesArgsForCall = append(fake.sNIRulesArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.SNIRulesStub
+	fakeReturns := fake.sNIRulesReturns
+	fake.recordInvocation("SNIRules", []interface{}{arg1})
+	fake.sNIRulesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *NetOutChain) SNIRulesCallCount() int {
+	fake.sNIRulesMutex.RLock()
+	defer fake.sNIRulesMutex.RUnlock()
+	return len(fake.sNIRulesArgsForCall)
+}
+
+func (fake *NetOutChain) SNIRulesCalls(stub func(string) []rules.IPTablesRule) {
+	fake.sNIRulesMutex.Lock()
+	defer fake.sNIRulesMutex.Unlock()
+	fake.SNIRulesStub = stub
+}
+
+func (fake *NetOutChain) SNIRulesArgsForCall(i int) string {
+	fake.sNIRulesMutex.RLock()
+	defer fake.sNIRulesMutex.RUnlock()
+	argsForCall := fake.sNIRulesArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *NetOutChain) SNIRulesReturns(result1 []rules.IPTablesRule) {
+	fake.sNIRulesMutex.Lock()
+	defer fake.sNIRulesMutex.Unlock()
+	fake.SNIRulesStub = nil
+	fake.sNIRulesReturns = struct {
+		result1 []rules.IPTablesRule
+	}{result1}
+}
+
+func (fake *NetOutChain) SNIRulesReturnsOnCall(i int, result1 []rules.IPTablesRule) {
+	fake.sNIRulesMutex.Lock()
+	defer fake.sNIRulesMutex.Unlock()
+	fake.SNIRulesStub = nil
+	if fake.sNIRulesReturnsOnCall == nil {
+		fake.sNIRulesReturnsOnCall = make(map[int]struct {
+			result1 []rules.IPTablesRule
+		})
+	}
+	fake.sNIRulesReturnsOnCall[i] = struct {
+		result1 []rules.IPTablesRule
+	}{result1}
+}
+
 func (fake *NetOutChain) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -252,6 +326,8 @@ func (fake *NetOutChain) Invocations() map[string][][]interface{} {
 	defer fake.iPTablesRulesMutex.RUnlock()
 	fake.nameMutex.RLock()
 	defer fake.nameMutex.RUnlock()
+	fake.sNIRulesMutex.RLock()
+	defer fake.sNIRulesMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
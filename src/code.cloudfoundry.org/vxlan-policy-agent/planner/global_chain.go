@@ -0,0 +1,182 @@
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/template"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/silk/daemon"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"github.com/google/shlex"
+)
+
+// GlobalChainsRegex matches the chains of all operator-defined global chains,
+// whose prefix is the configured name followed by "--".
+const GlobalChainsRegex = `[a-z][a-z0-9]{0,9}--`
+
+//go:generate counterfeiter -o fakes/network_info.go --fake-name NetworkInfo . networkInfo
+type networkInfo interface {
+	Get() (daemon.NetworkInfo, error)
+}
+
+// GlobalChainPlanner plans an operator-defined chain that is not tied to any
+// container. Its rules are text/template strings that may refer to
+// {{.OverlayNetwork}}, {{.CellIP}} and {{.ContainerNetwork}}.
+type GlobalChainPlanner struct {
+	Logger         lager.Logger
+	Chain          enforcer.Chain
+	OverlayNetwork string
+	CellIP         string
+	NetworkInfo    networkInfo
+	templates      []*template.Template
+	tables         []tableTemplates
+
+	containerNetworkMutex sync.Mutex
+	containerNetwork      string
+}
+
+// tableTemplates are the rule templates of a global chain in another table.
+type tableTemplates struct {
+	chain     enforcer.Chain
+	templates []*template.Template
+}
+
+type ruleVariables struct {
+	OverlayNetwork   string
+	CellIP           string
+	containerNetwork func() (string, error)
+}
+
+// ContainerNetwork is only looked up when a rule uses it.
+func (v ruleVariables) ContainerNetwork() (string, error) {
+	return v.containerNetwork()
+}
+
+// lookupContainerNetwork asks the silk daemon for the subnet of the cell once
+// and reuses it for every later cycle, since the lease of a cell does not
+// change while the agent runs. Failed lookups are retried on the next cycle.
+func (p *GlobalChainPlanner) lookupContainerNetwork() (string, error) {
+	p.containerNetworkMutex.Lock()
+	defer p.containerNetworkMutex.Unlock()
+
+	if p.containerNetwork != "" {
+		return p.containerNetwork, nil
+	}
+	if p.NetworkInfo == nil {
+		return "", fmt.Errorf("no silk daemon configured")
+	}
+	info, err := p.NetworkInfo.Get()
+	if err != nil {
+		return "", fmt.Errorf("get network info: %s", err)
+	}
+	p.containerNetwork = info.OverlaySubnet
+	return p.containerNetwork, nil
+}
+
+// SetRules parses the rule templates and renders them once with placeholder
+// values, so that typos in variable names are reported at startup.
+func (p *GlobalChainPlanner) SetRules(ruleTemplates []string) error {
+	templates, err := parseRuleTemplates(p.Chain.Prefix, ruleTemplates)
+	if err != nil {
+		return err
+	}
+
+	p.templates = templates
+	return nil
+}
+
+// AddTable adds rules in a chain of another table, which are enforced along
+// with the rules of the global chain. The rule templates are checked like
+// those of SetRules.
+func (p *GlobalChainPlanner) AddTable(chain enforcer.Chain, ruleTemplates []string) error {
+	templates, err := parseRuleTemplates(fmt.Sprintf("%s%s", chain.Prefix, chain.Table), ruleTemplates)
+	if err != nil {
+		return err
+	}
+
+	p.tables = append(p.tables, tableTemplates{chain: chain, templates: templates})
+	return nil
+}
+
+func parseRuleTemplates(name string, ruleTemplates []string) ([]*template.Template, error) {
+	templates := make([]*template.Template, 0, len(ruleTemplates))
+	for i, ruleTemplate := range ruleTemplates {
+		t, err := template.New(fmt.Sprintf("%s-%d", name, i)).Option("missingkey=error").Parse(ruleTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("parse rule %q: %s", ruleTemplate, err)
+		}
+		templates = append(templates, t)
+	}
+
+	_, err := renderRules(templates, ruleVariables{
+		OverlayNetwork: "10.255.0.0/16",
+		CellIP:         "10.0.0.1",
+		containerNetwork: func() (string, error) {
+			return "10.255.1.0/24", nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (p *GlobalChainPlanner) GetPolicyRulesAndChain() (enforcer.RulesWithChain, error) {
+	vars := ruleVariables{
+		OverlayNetwork:   p.OverlayNetwork,
+		CellIP:           p.CellIP,
+		containerNetwork: p.lookupContainerNetwork,
+	}
+	ruleset, err := renderRules(p.templates, vars)
+	if err != nil {
+		p.Logger.Error("render-global-chain-rules", err, lager.Data{"chain": p.Chain.Prefix})
+		return enforcer.RulesWithChain{}, err
+	}
+
+	rulesWithChain := enforcer.RulesWithChain{
+		Chain: p.Chain,
+		Rules: ruleset,
+	}
+	for _, table := range p.tables {
+		tableRules, err := renderRules(table.templates, vars)
+		if err != nil {
+			p.Logger.Error("render-global-chain-rules", err, lager.Data{"chain": table.chain.Prefix, "table": table.chain.Table})
+			return enforcer.RulesWithChain{}, err
+		}
+		rulesWithChain.ExtraTables = append(rulesWithChain.ExtraTables, enforcer.TableRules{
+			Chain: table.chain,
+			Rules: tableRules,
+		})
+	}
+
+	p.Logger.Debug("generated-rules", lager.Data{"chain": p.Chain.Prefix, "rules": ruleset, "extra_tables": rulesWithChain.ExtraTables})
+	return rulesWithChain, nil
+}
+
+func (p *GlobalChainPlanner) GetASGRulesAndChains(containers ...string) ([]enforcer.RulesWithChain, error) {
+	return nil, nil
+}
+
+func renderRules(templates []*template.Template, vars ruleVariables) ([]rules.IPTablesRule, error) {
+	ruleset := []rules.IPTablesRule{}
+	for _, t := range templates {
+		var buf bytes.Buffer
+		err := t.Execute(&buf, vars)
+		if err != nil {
+			return nil, fmt.Errorf("render rule: %s", err)
+		}
+
+		rule, err := shlex.Split(buf.String())
+		if err != nil {
+			return nil, fmt.Errorf("split rule %q: %s", buf.String(), err)
+		}
+		if len(rule) == 0 {
+			return nil, fmt.Errorf("rule %q is empty", t.Root.String())
+		}
+		ruleset = append(ruleset, rules.IPTablesRule(rule))
+	}
+	return ruleset, nil
+}
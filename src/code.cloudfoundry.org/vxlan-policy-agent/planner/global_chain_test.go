@@ -0,0 +1,190 @@
+package planner_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/silk/daemon"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GlobalChainPlanner", func() {
+	var (
+		networkInfo        *fakes.NetworkInfo
+		globalChainPlanner *planner.GlobalChainPlanner
+		chain              enforcer.Chain
+	)
+
+	BeforeEach(func() {
+		networkInfo = &fakes.NetworkInfo{}
+		networkInfo.GetReturns(daemon.NetworkInfo{OverlaySubnet: "10.255.7.0/24", MTU: 1410}, nil)
+		chain = enforcer.Chain{
+			Table:       "filter",
+			ParentChain: "FORWARD",
+			Prefix:      "site--",
+		}
+		globalChainPlanner = &planner.GlobalChainPlanner{
+			Logger:         lagertest.NewTestLogger("test"),
+			Chain:          chain,
+			OverlayNetwork: "10.255.0.0/16",
+			CellIP:         "10.0.16.4",
+			NetworkInfo:    networkInfo,
+		}
+	})
+
+	Describe("GetPolicyRulesAndChain", func() {
+		It("renders the rule templates", func() {
+			Expect(globalChainPlanner.SetRules([]string{
+				"-s {{.ContainerNetwork}} -d 169.254.169.254/32 -j REJECT",
+				"-s {{.OverlayNetwork}} -d {{.CellIP}} -p tcp --dport 8080 -j ACCEPT",
+				`-m comment --comment "site rule" -j RETURN`,
+			})).To(Succeed())
+
+			rulesWithChain, err := globalChainPlanner.GetPolicyRulesAndChain()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rulesWithChain).To(Equal(enforcer.RulesWithChain{
+				Chain: chain,
+				Rules: []rules.IPTablesRule{
+					{"-s", "10.255.7.0/24", "-d", "169.254.169.254/32", "-j", "REJECT"},
+					{"-s", "10.255.0.0/16", "-d", "10.0.16.4", "-p", "tcp", "--dport", "8080", "-j", "ACCEPT"},
+					{"-m", "comment", "--comment", "site rule", "-j", "RETURN"},
+				},
+			}))
+		})
+
+		It("only asks the silk daemon for the container network when a rule uses it", func() {
+			Expect(globalChainPlanner.SetRules([]string{"-d {{.CellIP}} -j ACCEPT"})).To(Succeed())
+
+			_, err := globalChainPlanner.GetPolicyRulesAndChain()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(networkInfo.GetCallCount()).To(Equal(0))
+		})
+
+		It("asks the silk daemon for the container network only once", func() {
+			Expect(globalChainPlanner.SetRules([]string{
+				"-s {{.ContainerNetwork}} -j ACCEPT",
+				"-d {{.ContainerNetwork}} -j ACCEPT",
+			})).To(Succeed())
+
+			for i := 0; i < 3; i++ {
+				_, err := globalChainPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(networkInfo.GetCallCount()).To(Equal(1))
+		})
+
+		Context("when the silk daemon cannot be reached", func() {
+			BeforeEach(func() {
+				networkInfo.GetReturnsOnCall(0, daemon.NetworkInfo{}, errors.New("banana"))
+			})
+
+			It("returns an error and asks again on the next cycle", func() {
+				Expect(globalChainPlanner.SetRules([]string{"-s {{.ContainerNetwork}} -j ACCEPT"})).To(Succeed())
+
+				_, err := globalChainPlanner.GetPolicyRulesAndChain()
+				Expect(err).To(MatchError(ContainSubstring("get network info: banana")))
+
+				rulesWithChain, err := globalChainPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.Rules).To(Equal([]rules.IPTablesRule{{"-s", "10.255.7.0/24", "-j", "ACCEPT"}}))
+				Expect(networkInfo.GetCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when no silk daemon is configured", func() {
+			BeforeEach(func() {
+				globalChainPlanner.NetworkInfo = nil
+			})
+
+			It("returns an error for rules using the container network", func() {
+				Expect(globalChainPlanner.SetRules([]string{"-s {{.ContainerNetwork}} -j ACCEPT"})).To(Succeed())
+
+				_, err := globalChainPlanner.GetPolicyRulesAndChain()
+				Expect(err).To(MatchError(ContainSubstring("no silk daemon configured")))
+			})
+		})
+	})
+
+	Describe("AddTable", func() {
+		var mangleChain enforcer.Chain
+
+		BeforeEach(func() {
+			mangleChain = enforcer.Chain{
+				Table:       "mangle",
+				ParentChain: "POSTROUTING",
+				Prefix:      "site--",
+			}
+			Expect(globalChainPlanner.SetRules([]string{"-s {{.OverlayNetwork}} -j ACCEPT"})).To(Succeed())
+		})
+
+		It("plans the rules of the other table along with those of the chain", func() {
+			Expect(globalChainPlanner.AddTable(mangleChain, []string{
+				"-s {{.ContainerNetwork}} -j DSCP --set-dscp 10",
+			})).To(Succeed())
+
+			rulesWithChain, err := globalChainPlanner.GetPolicyRulesAndChain()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rulesWithChain).To(Equal(enforcer.RulesWithChain{
+				Chain: chain,
+				Rules: []rules.IPTablesRule{{"-s", "10.255.0.0/16", "-j", "ACCEPT"}},
+				ExtraTables: []enforcer.TableRules{{
+					Chain: mangleChain,
+					Rules: []rules.IPTablesRule{{"-s", "10.255.7.0/24", "-j", "DSCP", "--set-dscp", "10"}},
+				}},
+			}))
+		})
+
+		It("rejects templates that do not parse", func() {
+			err := globalChainPlanner.AddTable(mangleChain, []string{"-s {{.CellIP -j ACCEPT"})
+			Expect(err).To(MatchError(ContainSubstring(`parse rule "-s {{.CellIP -j ACCEPT"`)))
+		})
+
+		Context("when the rules of the other table cannot be rendered", func() {
+			BeforeEach(func() {
+				globalChainPlanner.NetworkInfo = nil
+				Expect(globalChainPlanner.AddTable(mangleChain, []string{"-s {{.ContainerNetwork}} -j ACCEPT"})).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				_, err := globalChainPlanner.GetPolicyRulesAndChain()
+				Expect(err).To(MatchError(ContainSubstring("no silk daemon configured")))
+			})
+		})
+	})
+
+	Describe("GetASGRulesAndChains", func() {
+		It("plans no ASG chains", func() {
+			rulesWithChains, err := globalChainPlanner.GetASGRulesAndChains()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rulesWithChains).To(BeEmpty())
+		})
+	})
+
+	Describe("SetRules", func() {
+		It("rejects templates that do not parse", func() {
+			err := globalChainPlanner.SetRules([]string{"-s {{.OverlayNetwork -j ACCEPT"})
+			Expect(err).To(MatchError(ContainSubstring(`parse rule "-s {{.OverlayNetwork -j ACCEPT"`)))
+		})
+
+		It("rejects unknown variables", func() {
+			err := globalChainPlanner.SetRules([]string{"-s {{.OverlayCIDR}} -j ACCEPT"})
+			Expect(err).To(MatchError(ContainSubstring("render rule")))
+		})
+
+		It("rejects empty rules", func() {
+			err := globalChainPlanner.SetRules([]string{"  "})
+			Expect(err).To(MatchError(`rule "  " is empty`))
+		})
+
+		It("does not contact the silk daemon", func() {
+			Expect(globalChainPlanner.SetRules([]string{"-s {{.ContainerNetwork}} -j ACCEPT"})).To(Succeed())
+			Expect(networkInfo.GetCallCount()).To(Equal(0))
+		})
+	})
+})
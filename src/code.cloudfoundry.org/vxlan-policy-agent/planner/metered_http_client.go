@@ -0,0 +1,121 @@
+package planner
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
+)
+
+const metricPolicyServerPoliciesPayload = "policyServerPoliciesPayloadSize"
+const metricPolicyServerASGPayload = "policyServerASGPayloadSize"
+const metricPolicyServerNotModified = "policyServerPollNotModified"
+const metricPolicyServerASGNotModified = "policyServerASGPollNotModified"
+
+// MeteredHTTPClient measures the policy and security group payloads that the
+// policy client reads from the policy server, and counts the 304 Not Modified
+// answers to them. The security groups are read in pages, so the sizes are
+// summed until SendPayloadSize is called once per cycle.
+type MeteredHTTPClient struct {
+	HTTPClient    json_client.HttpClient
+	MetricsSender metricsSender
+
+	mutex        sync.Mutex
+	payloadSizes map[string]int
+}
+
+func (c *MeteredHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return response, err
+	}
+
+	route := meteredRoute(request.URL.Path)
+	if route == "" {
+		return response, nil
+	}
+
+	if response.StatusCode == http.StatusNotModified {
+		c.MetricsSender.IncrementCounter(notModifiedMetric(route))
+	}
+	response.Body = &countingBody{
+		ReadCloser: response.Body,
+		onClose: func(size int) {
+			c.addPayloadSize(route, size)
+		},
+	}
+	return response, nil
+}
+
+func (c *MeteredHTTPClient) CloseIdleConnections() {
+	c.HTTPClient.CloseIdleConnections()
+}
+
+// SendPayloadSize emits the size of the payloads read from the route since the
+// last call, if any were read.
+func (c *MeteredHTTPClient) SendPayloadSize(route string) {
+	c.mutex.Lock()
+	size, ok := c.payloadSizes[route]
+	delete(c.payloadSizes, route)
+	c.mutex.Unlock()
+
+	if ok {
+		c.MetricsSender.SendValue(payloadMetric(route), float64(size), "bytes")
+	}
+}
+
+func (c *MeteredHTTPClient) addPayloadSize(route string, size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.payloadSizes == nil {
+		c.payloadSizes = make(map[string]int)
+	}
+	c.payloadSizes[route] += size
+}
+
+func meteredRoute(path string) string {
+	for _, route := range []string{policiesRoute, securityGroupsRoute} {
+		if strings.HasSuffix(path, route) {
+			return route
+		}
+	}
+	return ""
+}
+
+func payloadMetric(route string) string {
+	if route == securityGroupsRoute {
+		return metricPolicyServerASGPayload
+	}
+	return metricPolicyServerPoliciesPayload
+}
+
+func notModifiedMetric(route string) string {
+	if route == securityGroupsRoute {
+		return metricPolicyServerASGNotModified
+	}
+	return metricPolicyServerNotModified
+}
+
+type countingBody struct {
+	io.ReadCloser
+	size    int
+	closed  bool
+	onClose func(int)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.onClose(b.size)
+	}
+	return b.ReadCloser.Close()
+}
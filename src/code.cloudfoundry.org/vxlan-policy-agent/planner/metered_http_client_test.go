@@ -0,0 +1,111 @@
+package planner_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner/fakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MeteredHTTPClient", func() {
+	var (
+		server        *httptest.Server
+		metricsSender *fakes.MetricsSender
+		client        *planner.MeteredHTTPClient
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"policies": []}`))
+		}))
+		metricsSender = &fakes.MetricsSender{}
+		client = &planner.MeteredHTTPClient{
+			HTTPClient:    server.Client(),
+			MetricsSender: metricsSender,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(route string) {
+		request, err := http.NewRequest("GET", server.URL+route, nil)
+		Expect(err).NotTo(HaveOccurred())
+		response, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = io.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.Body.Close()).To(Succeed())
+	}
+
+	It("reports the size of the policies payload once it is sent", func() {
+		get("/networking/v1/internal/policies?id=some-app-guid")
+		Expect(metricsSender.SendValueCallCount()).To(Equal(0))
+
+		client.SendPayloadSize("/networking/v1/internal/policies")
+		Expect(metricsSender.SendValueCallCount()).To(Equal(1))
+		name, value, unit := metricsSender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("policyServerPoliciesPayloadSize"))
+		Expect(value).To(Equal(float64(len(`{"policies": []}`))))
+		Expect(unit).To(Equal("bytes"))
+	})
+
+	It("sums the pages of the security groups payload", func() {
+		get("/networking/v1/internal/security_groups?per_page=5000")
+		get("/networking/v1/internal/security_groups?per_page=5000&from=some-token")
+
+		client.SendPayloadSize("/networking/v1/internal/security_groups")
+		Expect(metricsSender.SendValueCallCount()).To(Equal(1))
+		name, value, _ := metricsSender.SendValueArgsForCall(0)
+		Expect(name).To(Equal("policyServerASGPayloadSize"))
+		Expect(value).To(Equal(float64(2 * len(`{"policies": []}`))))
+	})
+
+	It("starts over after the size is sent", func() {
+		get("/networking/v1/internal/policies")
+		client.SendPayloadSize("/networking/v1/internal/policies")
+		client.SendPayloadSize("/networking/v1/internal/policies")
+		Expect(metricsSender.SendValueCallCount()).To(Equal(1))
+
+		get("/networking/v1/internal/policies")
+		client.SendPayloadSize("/networking/v1/internal/policies")
+		Expect(metricsSender.SendValueCallCount()).To(Equal(2))
+		_, value, _ := metricsSender.SendValueArgsForCall(1)
+		Expect(value).To(Equal(float64(len(`{"policies": []}`))))
+	})
+
+	It("does not report other requests", func() {
+		get("/networking/v1/internal/policies_last_updated")
+		get("/networking/v1/internal/tags")
+		client.SendPayloadSize("/networking/v1/internal/policies")
+		client.SendPayloadSize("/networking/v1/internal/security_groups")
+		Expect(metricsSender.SendValueCallCount()).To(Equal(0))
+		Expect(metricsSender.IncrementCounterCallCount()).To(Equal(0))
+	})
+
+	Context("when the policy server answers 304 Not Modified", func() {
+		BeforeEach(func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotModified)
+			})
+		})
+
+		It("counts the answer per route", func() {
+			get("/networking/v1/internal/policies")
+			get("/networking/v1/internal/security_groups")
+			Expect(metricsSender.IncrementCounterCallCount()).To(Equal(2))
+			Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("policyServerPollNotModified"))
+			Expect(metricsSender.IncrementCounterArgsForCall(1)).To(Equal("policyServerASGPollNotModified"))
+
+			client.SendPayloadSize("/networking/v1/internal/policies")
+			_, value, _ := metricsSender.SendValueArgsForCall(0)
+			Expect(value).To(BeZero())
+		})
+	})
+})
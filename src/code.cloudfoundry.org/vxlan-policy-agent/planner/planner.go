@@ -2,7 +2,6 @@ package planner
 
 import (
 	"crypto/sha1"
-	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
@@ -17,13 +16,14 @@ import (
 )
 
 type container struct {
-	Handle    string
-	AppID     string
-	SpaceID   string
-	Ports     string
-	IP        string
-	Purpose   string
-	LogConfig executor.LogConfig
+	Handle        string
+	InstanceIndex string
+	AppID         string
+	SpaceID       string
+	Ports         string
+	IP            string
+	Purpose       string
+	LogConfig     executor.LogConfig
 }
 
 type VxlanPolicyPlanner struct {
@@ -38,6 +38,80 @@ type VxlanPolicyPlanner struct {
 	EnableOverlayIngressRules     bool
 	HostInterfaceNames            []string
 	NetOutChain                   netOutChain
+	EgressProxySpaceGUIDs         []string
+	EgressProxyRules              []policy_client.SecurityGroupRule
+	// SNIAllowlistRules are the ASG-like rules of the sni allowlists by the
+	// spaces they apply to.
+	SNIAllowlistRules map[string][]policy_client.SecurityGroupRule
+	PolicySources     []PolicySource
+	PolicyServerCache policyServerCache
+	PayloadMeter      payloadMeter
+	// SubChainMinRules is the number of rules from which the rules of a
+	// chain are split into per-protocol sub-chains. Zero keeps every chain
+	// whole.
+	SubChainMinRules int
+	// Shard is the share of the containers whose ASGs are planned.
+	Shard Shard
+	// QoSClasses are the classes that policy sources can assign to apps.
+	// Without classes, no QoS chain is planned.
+	QoSClasses []QoSClass
+	// AcceptICMPEcho lets pings from other containers through to the
+	// overlay IPs of the containers, which their overlay chains reject
+	// otherwise.
+	AcceptICMPEcho bool
+	// HealthCheckSources are the CIDRs of the platform components on the
+	// cell that probe the app ports of the containers. Their probes are
+	// accepted before the overlay chains, which would reject and log them
+	// as denied container to container traffic.
+	HealthCheckSources []string
+	// DestinationChains writes the shared chains of the destinations that
+	// apps have egress policies to, which the ASG chains of the app and task
+	// containers of those apps jump to.
+	DestinationChains destinationChains
+	lastPolicyPlan    *policyPlan
+	// the last answers of each policy source, used while the source fails
+	policySourceRules      []map[string][]policy_client.SecurityGroupRule
+	policySourcePolicies   [][]policy_client.Policy
+	policySourceQoSClasses []map[string]string
+	policySourceTags       map[string]string
+}
+
+// policyPlan is the policy rule set planned from the containers and the
+// policy server response of a cycle.
+type policyPlan struct {
+	containers       []container
+	ingressTag       string
+	loggingEnabled   bool
+	externalPolicies []policy_client.Policy
+	qosClasses       map[string]string
+	rulesWithChain   enforcer.RulesWithChain
+}
+
+// PolicySourceContainer is what an external policy source learns about a
+// container when asked for its egress rules.
+type PolicySourceContainer struct {
+	Handle    string `json:"handle"`
+	AppGUID   string `json:"app_guid"`
+	SpaceGUID string `json:"space_guid"`
+	Purpose   string `json:"purpose"`
+	IP        string `json:"ip"`
+}
+
+// PolicySource contributes egress rules, keyed by container handle, that are
+// added to the ASG rules of those containers, container to container
+// policies that are added to those of the policy server, and the QoS classes
+// of apps, keyed by app guid, which the policy server does not know about.
+//
+//go:generate counterfeiter -o fakes/policy_source.go --fake-name PolicySource . PolicySource
+type PolicySource interface {
+	EgressRules(containers []PolicySourceContainer) (map[string][]policy_client.SecurityGroupRule, error)
+	Policies(containers []PolicySourceContainer) ([]policy_client.Policy, error)
+	QoSClasses(containers []PolicySourceContainer) (map[string]string, error)
+}
+
+//go:generate counterfeiter -o fakes/destination_chains.go --fake-name DestinationChains . destinationChains
+type destinationChains interface {
+	Sync() (map[string][]string, error)
 }
 
 //go:generate counterfeiter -o fakes/dstore.go --fake-name Dstore . dstore
@@ -52,9 +126,21 @@ type policyClient interface {
 	CreateOrGetTag(id, groupType string) (string, error)
 }
 
+//go:generate counterfeiter -o fakes/policy_server_cache.go --fake-name PolicyServerCache . policyServerCache
+type policyServerCache interface {
+	NotModified(route string) bool
+}
+
+//go:generate counterfeiter -o fakes/payload_meter.go --fake-name PayloadMeter . payloadMeter
+type payloadMeter interface {
+	SendPayloadSize(route string)
+}
+
 //go:generate counterfeiter -o fakes/metrics_sender.go --fake-name MetricsSender . metricsSender
 type metricsSender interface {
+	IncrementCounter(string)
 	SendDuration(string, time.Duration)
+	SendValue(string, float64, string)
 }
 
 //go:generate counterfeiter -o fakes/loggingStateGetter.go --fake-name LoggingStateGetter . loggingStateGetter
@@ -65,20 +151,49 @@ type loggingStateGetter interface {
 //go:generate counterfeiter -o fakes/netout_chain.go --fake-name NetOutChain . netOutChain
 type netOutChain interface {
 	Name(containerHandle string) string
-	DefaultRules(containerHandle string) []rules.IPTablesRule
+	DefaultRules(containerHandle, instanceIndex string) []rules.IPTablesRule
 	IPTablesRules(containerHandle string, containerWorkload string, ruleSpec []netrules.Rule) ([]rules.IPTablesRule, error)
+	SNIRules(spaceGUID string) []rules.IPTablesRule
 }
 
 const metricContainerMetadata = "containerMetadataTime"
 const metricPolicyServerPoll = "policyServerPollTime"
 const metricPolicyServerASGPoll = "policyServerASGPollTime"
+const metricPolicySourcePoll = "policySourcePollTime"
+const metricPolicyConvert = "policyConvertTime"
+const metricASGConvert = "asgConvertTime"
+const metricPolicySourceFailures = "policySourceFailures"
+const metricDestinationSyncFailures = "destinationSyncFailures"
+const metricPolicyServerPolicies = "policyServerPolicies"
+const metricPolicyServerSecurityGroups = "policyServerSecurityGroups"
+
+const policiesRoute = "/networking/v1/internal/policies"
+const securityGroupsRoute = "/networking/v1/internal/security_groups"
+
+// splitIntoSubChains moves the rules of a large rule set into per-protocol
+// sub-chains, so that a packet only walks the rules of its protocol.
+func (p *VxlanPolicyPlanner) splitIntoSubChains(rulesWithChain *enforcer.RulesWithChain) {
+	if p.SubChainMinRules == 0 || len(rulesWithChain.Rules) < p.SubChainMinRules {
+		return
+	}
+	subChains, ok := enforcer.SplitByProtocol(rulesWithChain.Rules)
+	if !ok {
+		return
+	}
+	rulesWithChain.Rules = []rules.IPTablesRule{}
+	rulesWithChain.SubChains = subChains
+}
 
 func ASGChainPrefix(handle string) string {
+	return fmt.Sprintf("asg-%x", handleHash(handle)) //only need 6 digits so we use 3.
+}
+
+// handleHash is the hash of a container handle in the names of its ASG
+// chains.
+func handleHash(handle string) []byte {
 	h := sha1.New()
 	h.Write([]byte(handle))
-	smallHash := h.Sum(nil)
-
-	return fmt.Sprintf("asg-%x", smallHash[0:3]) //only need 6 digits so we use 3.
+	return h.Sum(nil)[0:3]
 }
 
 func (p *VxlanPolicyPlanner) readFile(specifiedContainers ...string) ([]container, error) {
@@ -99,46 +214,31 @@ func (p *VxlanPolicyPlanner) readFile(specifiedContainers ...string) ([]containe
 
 	var allContainers []container
 	for handle, containerMeta := range specifiedContainerMetadata {
-		ports, ok := containerMeta.Metadata["ports"].(string)
-		if !ok || ports == "" {
+		metadata, err := datastore.ParseContainerMetadata(containerMeta.Metadata)
+		if metadata.Ports == "" {
 			message := "Container metadata is missing key ports. CloudController version may be out of date or apps may need to be restaged."
 			p.Logger.Debug("container-metadata-policy-group-id", lager.Data{"container_handle": handle, "message": message})
 		}
 
-		policyGroupID, ok := containerMeta.Metadata["policy_group_id"].(string)
-		if !ok || policyGroupID == "" {
+		if metadata.PolicyGroupID == "" {
 			message := "Container metadata is missing key policy_group_id. CloudController version may be out of date or apps may need to be restaged."
 			p.Logger.Debug("container-metadata-policy-group-id", lager.Data{"container_handle": handle, "message": message})
 			continue
 		}
 
-		spaceID, ok := containerMeta.Metadata["space_id"].(string)
-		if !ok {
-			spaceID = ""
-		}
-
-		purpose, ok := containerMeta.Metadata["container_workload"].(string)
-		if !ok {
-			purpose = ""
-		}
-
-		var logConfig executor.LogConfig
-		logConfigStr, ok := containerMeta.Metadata["log_config"].(string)
-		if ok {
-			err := json.Unmarshal([]byte(logConfigStr), &logConfig)
-			if err != nil {
-				return nil, err
-			}
+		if err != nil {
+			return nil, err
 		}
 
 		allContainers = append(allContainers, container{
-			Handle:    containerMeta.Handle,
-			AppID:     policyGroupID,
-			SpaceID:   spaceID,
-			Ports:     ports,
-			IP:        containerMeta.IP,
-			Purpose:   purpose,
-			LogConfig: logConfig,
+			Handle:        containerMeta.Handle,
+			InstanceIndex: metadata.InstanceIndex(),
+			AppID:         metadata.PolicyGroupID,
+			SpaceID:       metadata.SpaceID,
+			Ports:         metadata.Ports,
+			IP:            containerMeta.IP,
+			Purpose:       metadata.Workload,
+			LogConfig:     executor.LogConfig(metadata.LogConfig),
 		})
 	}
 	containerMetadataDuration := time.Now().Sub(containerMetadataStartTime)
@@ -169,9 +269,19 @@ func extractGUIDs(allContainers []container) []string {
 		guids[i] = key
 		i++
 	}
+	sort.Strings(guids)
 	return guids
 }
 
+func (p *VxlanPolicyPlanner) isEgressProxySpace(spaceGUID string) bool {
+	for _, egressProxySpaceGUID := range p.EgressProxySpaceGUIDs {
+		if spaceGUID == egressProxySpaceGUID {
+			return true
+		}
+	}
+	return false
+}
+
 func extractSpaceGUIDs(allContainers []container) []string {
 	allGUIDs := make(map[string]interface{})
 	for _, container := range allContainers {
@@ -186,5 +296,6 @@ func extractSpaceGUIDs(allContainers []container) []string {
 		guids[i] = key
 		i++
 	}
+	sort.Strings(guids)
 	return guids
 }
@@ -6,6 +6,7 @@ package planner
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -22,6 +23,7 @@ type containerPolicySet struct {
 	Source      sourceSlice
 	Destination destinationSlice
 	Ingress     ingressSlice
+	HealthCheck healthCheckSlice
 }
 
 type source struct {
@@ -118,6 +120,36 @@ func (s ingressSlice) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 
+type healthCheck struct {
+	Source string
+	IP     string
+	Port   int
+}
+
+type healthCheckSlice []healthCheck
+
+func (s healthCheckSlice) Len() int {
+	return len(s)
+}
+
+func (s healthCheckSlice) Less(i, j int) bool {
+	a, err := json.Marshal(s[i])
+	if err != nil {
+		panic(err)
+	}
+
+	b, err := json.Marshal(s[j])
+	if err != nil {
+		panic(err)
+	}
+
+	return strings.Compare(string(a), string(b)) < 0
+}
+
+func (s healthCheckSlice) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
 const ASGManagedChainsRegex = `asg-[a-z0-9]{6}`
 
 func (p *VxlanPolicyPlanner) GetPolicyRulesAndChain() (enforcer.RulesWithChain, error) {
@@ -127,18 +159,66 @@ func (p *VxlanPolicyPlanner) GetPolicyRulesAndChain() (enforcer.RulesWithChain,
 		return enforcer.RulesWithChain{}, err
 	}
 
-	containerPolicySet, err := p.getContainerPolicies(allContainers)
+	policies, ingressTag, err := p.getPolicies(allContainers)
+	if err != nil {
+		p.Logger.Error("policy-client-get-container-policies", err)
+		return enforcer.RulesWithChain{}, err
+	}
+
+	plan := &policyPlan{
+		containers:       allContainers,
+		ingressTag:       ingressTag,
+		loggingEnabled:   p.LoggingState.IsEnabled(),
+		externalPolicies: p.getPolicySourcePolicies(allContainers),
+		qosClasses:       p.getPolicySourceQoSClasses(allContainers),
+	}
+	if p.policiesNotModified(plan) {
+		p.Logger.Debug("policies-not-modified")
+		return p.lastPolicyPlan.rulesWithChain, nil
+	}
+
+	convertStartTime := time.Now()
+	allPolicies := append(append([]policy_client.Policy{}, policies...), plan.externalPolicies...)
+	containerPolicySet, err := p.getContainerPolicies(allContainers, allPolicies, ingressTag)
 	if err != nil {
 		p.Logger.Error("policy-client-get-container-policies", err)
 		return enforcer.RulesWithChain{}, err
 	}
 	ruleset := p.planIPTableRules(containerPolicySet)
+	if p.AcceptICMPEcho {
+		ruleset = append(ruleset, planICMPEchoRules(allContainers)...)
+	}
 
 	p.Logger.Debug("generated-rules", lager.Data{"rules": ruleset})
-	return enforcer.RulesWithChain{
+	plan.rulesWithChain = enforcer.RulesWithChain{
 		Chain: p.Chain,
 		Rules: ruleset,
-	}, nil
+	}
+	p.splitIntoSubChains(&plan.rulesWithChain)
+	if len(p.QoSClasses) > 0 {
+		// the QoS chain is planned even without containers with a class, so
+		// that the chain of the last plan is replaced
+		plan.rulesWithChain.ExtraTables = []enforcer.TableRules{p.planQoSRules(allContainers, plan.qosClasses)}
+	}
+	p.MetricsSender.SendDuration(metricPolicyConvert, time.Now().Sub(convertStartTime))
+	p.lastPolicyPlan = plan
+	return plan.rulesWithChain, nil
+}
+
+// policiesNotModified reports whether the policy server answered with the
+// policies of the last plan and nothing else that goes into the plan changed.
+func (p *VxlanPolicyPlanner) policiesNotModified(plan *policyPlan) bool {
+	if p.PolicyServerCache == nil || p.lastPolicyPlan == nil {
+		return false
+	}
+	if len(plan.containers) > 0 && !p.PolicyServerCache.NotModified(policiesRoute) {
+		return false
+	}
+	return plan.ingressTag == p.lastPolicyPlan.ingressTag &&
+		plan.loggingEnabled == p.lastPolicyPlan.loggingEnabled &&
+		reflect.DeepEqual(plan.externalPolicies, p.lastPolicyPlan.externalPolicies) &&
+		reflect.DeepEqual(plan.qosClasses, p.lastPolicyPlan.qosClasses) &&
+		reflect.DeepEqual(plan.containers, p.lastPolicyPlan.containers)
 }
 
 func (p *VxlanPolicyPlanner) GetASGRulesAndChains(specifiedContainers ...string) ([]enforcer.RulesWithChain, error) {
@@ -148,12 +228,34 @@ func (p *VxlanPolicyPlanner) GetASGRulesAndChains(specifiedContainers ...string)
 		return nil, err
 	}
 
-	securityGroups, err := p.getContainerSecurityGroups(allContainers)
+	if p.Shard.Sharded() {
+		shardContainers := []container{}
+		for _, container := range allContainers {
+			if p.Shard.Owns(container.Handle) {
+				shardContainers = append(shardContainers, container)
+			}
+		}
+		allContainers = shardContainers
+	}
+
+	asgContainers := []container{}
+	for _, container := range allContainers {
+		if !p.isEgressProxySpace(container.SpaceID) {
+			asgContainers = append(asgContainers, container)
+		}
+	}
+
+	securityGroups, err := p.getContainerSecurityGroups(asgContainers)
 	if err != nil {
 		p.Logger.Error("policy-client-get-security-group-rules", err)
 		return nil, err
 	}
 
+	externalRules := p.getPolicySourceRules(asgContainers, len(specifiedContainers) == 0)
+	destinationChains := p.getDestinationChains()
+	sortSecurityGroups(securityGroups)
+
+	convertStartTime := time.Now()
 	rulesWithChains := []enforcer.RulesWithChain{}
 	stagingRulesForSpace := map[string][]policy_client.SecurityGroupRule{}
 	runningRulesForSpace := map[string][]policy_client.SecurityGroupRule{}
@@ -186,26 +288,39 @@ func (p *VxlanPolicyPlanner) GetASGRulesAndChains(specifiedContainers ...string)
 
 		parentChainName := p.NetOutChain.Name(container.Handle)
 		var sgRules []policy_client.SecurityGroupRule
-		if container.Purpose == "staging" {
+		if p.isEgressProxySpace(container.SpaceID) {
+			// containers in egress proxy spaces may only reach the proxies, regardless of their ASGs
+			sgRules = p.EgressProxyRules
+		} else if container.Purpose == "staging" {
 			sgRules = append(defaultStagingRules, stagingRulesForSpace[container.SpaceID]...)
 		} else if container.Purpose == "app" || container.Purpose == "task" {
 			sgRules = append(defaultRunningRules, runningRulesForSpace[container.SpaceID]...)
 		}
+		if extraRules := externalRules[container.Handle]; len(extraRules) > 0 {
+			sgRules = append(append([]policy_client.SecurityGroupRule{}, sgRules...), extraRules...)
+		}
+		if sniRules := p.SNIAllowlistRules[container.SpaceID]; len(sniRules) > 0 {
+			sgRules = append(append([]policy_client.SecurityGroupRule{}, sgRules...), sniRules...)
+		}
 		ruleSpec, err := netrules.NewRulesFromSecurityGroupRules(sgRules)
 		if err != nil {
 			p.Logger.Error("rules-from-security-group-rules", err)
 			continue
 		}
 
-		defaultRules := p.NetOutChain.DefaultRules(container.Handle)
+		defaultRules := p.NetOutChain.DefaultRules(container.Handle, container.InstanceIndex)
 
 		iptablesRules, err := p.NetOutChain.IPTablesRules(container.Handle, container.Purpose, ruleSpec)
 		if err != nil {
 			p.Logger.Error("converting-to-iptables-rules", err)
 			continue
 		}
+		iptablesRules = append(iptablesRules, p.NetOutChain.SNIRules(container.SpaceID)...)
+		if !p.isEgressProxySpace(container.SpaceID) && (container.Purpose == "app" || container.Purpose == "task") {
+			iptablesRules = append(destinationJumps(destinationChains[container.AppID]), iptablesRules...)
+		}
 
-		rulesWithChains = append(rulesWithChains, enforcer.RulesWithChain{
+		rulesWithChain := enforcer.RulesWithChain{
 			Chain: enforcer.Chain{
 				Table:              enforcer.FilterTable,
 				ParentChain:        parentChainName,
@@ -215,8 +330,12 @@ func (p *VxlanPolicyPlanner) GetASGRulesAndChains(specifiedContainers ...string)
 			},
 			Rules:     reverseOrderIptablesRules(iptablesRules, defaultRules),
 			LogConfig: container.LogConfig,
-		})
+			Handle:    container.Handle,
+		}
+		p.splitIntoSubChains(&rulesWithChain)
+		rulesWithChains = append(rulesWithChains, rulesWithChain)
 	}
+	p.MetricsSender.SendDuration(metricASGConvert, time.Now().Sub(convertStartTime))
 
 	return rulesWithChains, nil
 }
@@ -233,6 +352,19 @@ func reverseOrderIptablesRules(iptablesRules, defaultRules []rules.IPTablesRule)
 	return allRules
 }
 
+// sortSecurityGroups puts the security groups in a canonical order, so that
+// the rules of a container are planned in the same order whatever the order
+// the policy server returns the groups in. Planning them in another order
+// would enforce the chain of every container again.
+func sortSecurityGroups(securityGroups []policy_client.SecurityGroup) {
+	sort.SliceStable(securityGroups, func(i, j int) bool {
+		if securityGroups[i].Guid != securityGroups[j].Guid {
+			return securityGroups[i].Guid < securityGroups[j].Guid
+		}
+		return securityGroups[i].Name < securityGroups[j].Name
+	})
+}
+
 func (p *VxlanPolicyPlanner) getContainerSecurityGroups(allContainers []container) ([]policy_client.SecurityGroup, error) {
 	policyServerStartRequestTime := time.Now()
 	spaceGuids := extractSpaceGUIDs(allContainers)
@@ -244,10 +376,184 @@ func (p *VxlanPolicyPlanner) getContainerSecurityGroups(allContainers []containe
 
 	policyServerPollDuration := time.Now().Sub(policyServerStartRequestTime)
 	p.MetricsSender.SendDuration(metricPolicyServerASGPoll, policyServerPollDuration)
+	p.MetricsSender.SendValue(metricPolicyServerSecurityGroups, float64(len(securityGroups)), "security groups")
+	p.sendPayloadSize(securityGroupsRoute)
 	return securityGroups, nil
 }
 
-func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (containerPolicySet, error) {
+func (p *VxlanPolicyPlanner) sendPayloadSize(route string) {
+	if p.PayloadMeter != nil {
+		p.PayloadMeter.SendPayloadSize(route)
+	}
+}
+
+// getPolicySourceRules asks every policy source for the egress rules of the
+// containers. A failing source is logged and counted, and the rules it last
+// returned for the containers are used instead, so that one source being down
+// neither stops ASG syncing nor drops the rules it contributed.
+func (p *VxlanPolicyPlanner) getPolicySourceRules(asgContainers []container, fullSync bool) map[string][]policy_client.SecurityGroupRule {
+	if len(p.PolicySources) == 0 {
+		return nil
+	}
+
+	spaceContainers := []container{}
+	for _, container := range asgContainers {
+		if container.SpaceID != "" {
+			spaceContainers = append(spaceContainers, container)
+		}
+	}
+	sourceContainers := policySourceContainers(spaceContainers)
+	if len(sourceContainers) == 0 {
+		return nil
+	}
+
+	if p.policySourceRules == nil {
+		p.policySourceRules = make([]map[string][]policy_client.SecurityGroupRule, len(p.PolicySources))
+	}
+
+	policySourceStartRequestTime := time.Now()
+	allRules := map[string][]policy_client.SecurityGroupRule{}
+	for i, source := range p.PolicySources {
+		rulesForContainer, err := source.EgressRules(sourceContainers)
+		if err != nil {
+			p.Logger.Error("policy-source-get-egress-rules", err, lager.Data{"policy_source": i})
+			p.MetricsSender.IncrementCounter(metricPolicySourceFailures)
+		} else {
+			p.policySourceRules[i] = updatePolicySourceRules(p.policySourceRules[i], rulesForContainer, sourceContainers, fullSync)
+		}
+
+		for _, container := range sourceContainers {
+			allRules[container.Handle] = append(allRules[container.Handle], p.policySourceRules[i][container.Handle]...)
+		}
+	}
+
+	policySourcePollDuration := time.Now().Sub(policySourceStartRequestTime)
+	p.MetricsSender.SendDuration(metricPolicySourcePoll, policySourcePollDuration)
+	return allRules
+}
+
+// getDestinationChains writes the shared chains of the destinations and
+// returns the chains that the ASG chains of each app jump to. While the
+// destinations cannot be synced, the chains of the last sync are used.
+func (p *VxlanPolicyPlanner) getDestinationChains() map[string][]string {
+	if p.DestinationChains == nil {
+		return nil
+	}
+	chains, err := p.DestinationChains.Sync()
+	if err != nil {
+		p.Logger.Error("destination-chains-sync", err)
+		p.MetricsSender.IncrementCounter(metricDestinationSyncFailures)
+	}
+	return chains
+}
+
+// destinationJumps hand the packets of a container to the chains of the
+// destinations of its app, which accept them like the rules of its ASGs.
+func destinationJumps(chains []string) []rules.IPTablesRule {
+	jumps := []rules.IPTablesRule{}
+	for _, chain := range chains {
+		jumps = append(jumps, rules.IPTablesRule{"-j", chain})
+	}
+	return jumps
+}
+
+// updatePolicySourceRules records the answer of a policy source about the
+// containers it was asked about. A sync of all containers also forgets the
+// containers that are gone.
+func updatePolicySourceRules(last, answer map[string][]policy_client.SecurityGroupRule, containers []PolicySourceContainer, fullSync bool) map[string][]policy_client.SecurityGroupRule {
+	if fullSync || last == nil {
+		last = map[string][]policy_client.SecurityGroupRule{}
+	}
+	for _, container := range containers {
+		if sgRules, ok := answer[container.Handle]; ok {
+			last[container.Handle] = sgRules
+		} else {
+			delete(last, container.Handle)
+		}
+	}
+	return last
+}
+
+// getPolicySourcePolicies asks every policy source for container to container
+// policies between the apps of the containers. Like for egress rules, a
+// failing source is logged and counted and its last policies are kept.
+func (p *VxlanPolicyPlanner) getPolicySourcePolicies(allContainers []container) []policy_client.Policy {
+	if len(p.PolicySources) == 0 {
+		return nil
+	}
+
+	sourceContainers := policySourceContainers(allContainers)
+	if len(sourceContainers) == 0 {
+		return nil
+	}
+
+	if p.policySourcePolicies == nil {
+		p.policySourcePolicies = make([][]policy_client.Policy, len(p.PolicySources))
+	}
+
+	policySourceStartRequestTime := time.Now()
+	allPolicies := []policy_client.Policy{}
+	for i, source := range p.PolicySources {
+		policies, err := source.Policies(sourceContainers)
+		if err == nil {
+			policies, err = p.tagPolicies(policies)
+		}
+		if err != nil {
+			p.Logger.Error("policy-source-get-policies", err, lager.Data{"policy_source": i})
+			p.MetricsSender.IncrementCounter(metricPolicySourceFailures)
+		} else {
+			p.policySourcePolicies[i] = policies
+		}
+		allPolicies = append(allPolicies, p.policySourcePolicies[i]...)
+	}
+
+	policySourcePollDuration := time.Now().Sub(policySourceStartRequestTime)
+	p.MetricsSender.SendDuration(metricPolicySourcePoll, policySourcePollDuration)
+	return allPolicies
+}
+
+// tagPolicies fills in the tags of the source apps, which the policy server
+// assigns, so that the policies of a source mark packets like those of the
+// policy server do on every cell.
+func (p *VxlanPolicyPlanner) tagPolicies(policies []policy_client.Policy) ([]policy_client.Policy, error) {
+	if p.policySourceTags == nil {
+		p.policySourceTags = map[string]string{}
+	}
+
+	tagged := make([]policy_client.Policy, 0, len(policies))
+	for _, policy := range policies {
+		if policy.Source.Tag == "" {
+			tag, ok := p.policySourceTags[policy.Source.ID]
+			if !ok {
+				var err error
+				tag, err = p.PolicyClient.CreateOrGetTag(policy.Source.ID, "app")
+				if err != nil {
+					return nil, fmt.Errorf("get tag for %s: %s", policy.Source.ID, err)
+				}
+				p.policySourceTags[policy.Source.ID] = tag
+			}
+			policy.Source.Tag = tag
+		}
+		tagged = append(tagged, policy)
+	}
+	return tagged, nil
+}
+
+func policySourceContainers(containers []container) []PolicySourceContainer {
+	sourceContainers := []PolicySourceContainer{}
+	for _, container := range containers {
+		sourceContainers = append(sourceContainers, PolicySourceContainer{
+			Handle:    container.Handle,
+			AppGUID:   container.AppID,
+			SpaceGUID: container.SpaceID,
+			Purpose:   container.Purpose,
+			IP:        container.IP,
+		})
+	}
+	return sourceContainers
+}
+
+func (p *VxlanPolicyPlanner) getPolicies(allContainers []container) ([]policy_client.Policy, string, error) {
 	policyServerStartRequestTime := time.Now()
 	guids := extractGUIDs(allContainers)
 
@@ -256,8 +562,7 @@ func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (co
 		var err error
 		policies, err = p.PolicyClient.GetPoliciesByID(guids...)
 		if err != nil {
-			err = fmt.Errorf("failed to get policies: %s", err)
-			return containerPolicySet{}, err
+			return nil, "", fmt.Errorf("failed to get policies: %s", err)
 		}
 	}
 
@@ -266,14 +571,18 @@ func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (co
 		var err error
 		ingressTag, err = p.PolicyClient.CreateOrGetTag("INGRESS_ROUTER", "router")
 		if err != nil {
-			err = fmt.Errorf("failed to get ingress tags: %s", err)
-			return containerPolicySet{}, err
+			return nil, "", fmt.Errorf("failed to get ingress tags: %s", err)
 		}
 	}
 
 	policyServerPollDuration := time.Now().Sub(policyServerStartRequestTime)
 	p.MetricsSender.SendDuration(metricPolicyServerPoll, policyServerPollDuration)
+	p.MetricsSender.SendValue(metricPolicyServerPolicies, float64(len(policies)), "policies")
+	p.sendPayloadSize(policiesRoute)
+	return policies, ingressTag, nil
+}
 
+func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container, policies []policy_client.Policy, ingressTag string) (containerPolicySet, error) {
 	visited := make(map[string]bool)
 	var containerPolicySet containerPolicySet
 	for _, container := range allContainers {
@@ -304,20 +613,28 @@ func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (co
 			}
 		}
 
-		if p.EnableOverlayIngressRules {
-			if container.Ports != "" {
-				for _, port := range strings.Split(container.Ports, ",") {
-					convPort, err := strconv.Atoi(strings.TrimSpace(port))
-					if err != nil {
-						return containerPolicySet, fmt.Errorf("converting container metadata port to int: %s", err)
-					}
-					containerPolicySet.Ingress = append(containerPolicySet.Ingress, ingress{
-						IngressTag: ingressTag,
-						IP:         container.IP,
-						Protocol:   "tcp",
-						Port:       convPort,
-					})
-				}
+		if !p.EnableOverlayIngressRules && len(p.HealthCheckSources) == 0 {
+			continue
+		}
+		ports, err := appPorts(container)
+		if err != nil {
+			return containerPolicySet, err
+		}
+		for _, port := range ports {
+			if p.EnableOverlayIngressRules {
+				containerPolicySet.Ingress = append(containerPolicySet.Ingress, ingress{
+					IngressTag: ingressTag,
+					IP:         container.IP,
+					Protocol:   "tcp",
+					Port:       port,
+				})
+			}
+			for _, source := range p.HealthCheckSources {
+				containerPolicySet.HealthCheck = append(containerPolicySet.HealthCheck, healthCheck{
+					Source: source,
+					IP:     container.IP,
+					Port:   port,
+				})
 			}
 		}
 	}
@@ -325,6 +642,7 @@ func (p *VxlanPolicyPlanner) getContainerPolicies(allContainers []container) (co
 	sort.Sort(containerPolicySet.Source)
 	sort.Sort(containerPolicySet.Destination)
 	sort.Sort(containerPolicySet.Ingress)
+	sort.Sort(containerPolicySet.HealthCheck)
 
 	return containerPolicySet, nil
 }
@@ -346,6 +664,7 @@ func (p *VxlanPolicyPlanner) planIPTableRules(containerPolicySet containerPolicy
 				c2cDestination.StartPort,
 				c2cDestination.EndPort,
 				c2cDestination.SourceTag,
+				c2cDestination.SourceGUID,
 				c2cDestination.GUID,
 				p.IPTablesAcceptedUDPLogsPerSec,
 			))
@@ -370,6 +689,44 @@ func (p *VxlanPolicyPlanner) planIPTableRules(containerPolicySet containerPolicy
 		))
 	}
 
+	for _, healthCheck := range containerPolicySet.HealthCheck {
+		ruleset = append(ruleset, rules.NewHealthCheckAcceptRule(
+			healthCheck.Source,
+			healthCheck.IP,
+			healthCheck.Port,
+		))
+	}
+
+	return ruleset
+}
+
+// appPorts are the ports of the container metadata, which the app listens
+// on.
+func appPorts(container container) ([]int, error) {
+	ports := []int{}
+	if container.Ports == "" {
+		return ports, nil
+	}
+	for _, port := range strings.Split(container.Ports, ",") {
+		convPort, err := strconv.Atoi(strings.TrimSpace(port))
+		if err != nil {
+			return nil, fmt.Errorf("converting container metadata port to int: %s", err)
+		}
+		ports = append(ports, convPort)
+	}
+	return ports, nil
+}
+
+// planICMPEchoRules accepts echo requests to every container, which only
+// come from the overlay since pings from the cell do not pass FORWARD.
+func planICMPEchoRules(allContainers []container) []rules.IPTablesRule {
+	ruleset := []rules.IPTablesRule{}
+	for _, container := range allContainers {
+		if container.IP == "" {
+			continue
+		}
+		ruleset = append(ruleset, rules.NewICMPEchoAcceptRule(container.IP))
+	}
 	return ruleset
 }
 
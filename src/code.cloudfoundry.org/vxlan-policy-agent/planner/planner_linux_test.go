@@ -198,6 +198,60 @@ var _ = Describe("Planner", func() {
 			Expect(policyClient.GetPoliciesByIDArgsForCall(0)).To(ConsistOf([]interface{}{"some-app-guid", "some-other-app-guid", "some-space-guid", "some-other-space-guid"}))
 		})
 
+		Context("when a policy server cache is set", func() {
+			var (
+				policyServerCache *fakes.PolicyServerCache
+				firstRules        enforcer.RulesWithChain
+			)
+
+			BeforeEach(func() {
+				policyServerCache = &fakes.PolicyServerCache{}
+				policyPlanner.PolicyServerCache = policyServerCache
+
+				var err error
+				firstRules, err = policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				policyServerCache.NotModifiedReturns(true)
+			})
+
+			It("returns the last rules without planning when the policies were not modified", func() {
+				policyClient.GetPoliciesByIDReturns(nil, nil)
+
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain).To(Equal(firstRules))
+				Expect(policyClient.GetPoliciesByIDCallCount()).To(Equal(2))
+				Expect(policyServerCache.NotModifiedArgsForCall(0)).To(Equal("/networking/v1/internal/policies"))
+				Expect(logger).To(gbytes.Say("policies-not-modified"))
+			})
+
+			It("plans again when the policies were modified", func() {
+				policyServerCache.NotModifiedReturns(false)
+				policyClient.GetPoliciesByIDReturns(nil, nil)
+
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain).NotTo(Equal(firstRules))
+			})
+
+			It("plans again when the containers changed", func() {
+				delete(data, "container-id-1")
+				store.ReadAllReturns(data, nil)
+
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain).NotTo(Equal(firstRules))
+			})
+
+			It("plans again when iptables logging was toggled", func() {
+				loggingStateGetter.IsEnabledReturns(true)
+
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain).NotTo(Equal(firstRules))
+			})
+		})
+
 		Context("when iptables logging is disabled", func() {
 			BeforeEach(func() {
 				loggingStateGetter.IsEnabledReturns(false)
@@ -309,7 +363,7 @@ var _ = Describe("Planner", func() {
 						"-m", "limit",
 						"--limit", "3/s",
 						"--limit-burst", "3",
-						"--jump", "LOG", "--log-prefix", `"OK_BB_some-other-app-guid "`,
+						"--jump", "LOG", "--log-prefix", `"OK_BB_another-_some-oth "`,
 					},
 					// allow bb based on mark
 					{
@@ -329,7 +383,7 @@ var _ = Describe("Planner", func() {
 						"--dport", "1234:1234",
 						"-m", "mark", "--mark", "0xAA",
 						"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
-						"--jump", "LOG", "--log-prefix", `"OK_AA_some-other-app-guid "`,
+						"--jump", "LOG", "--log-prefix", `"OK_AA_some-app_some-oth "`,
 					},
 					// allow aa based on mark
 					{
@@ -364,14 +418,136 @@ var _ = Describe("Planner", func() {
 			Expect(rulesWithChain.Rules[3]).To(ContainElement("ACCEPT"))
 		})
 
+		Context("when the rules reach the sub-chain threshold", func() {
+			BeforeEach(func() {
+				policyPlanner.SubChainMinRules = 2
+			})
+
+			It("moves the rules into per-protocol sub-chains", func() {
+				unsplit := *policyPlanner
+				unsplit.SubChainMinRules = 0
+				unsplitRules, err := unsplit.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+
+				expectedSubChains, ok := enforcer.SplitByProtocol(unsplitRules.Rules)
+				Expect(ok).To(BeTrue())
+				Expect(rulesWithChain.Rules).To(BeEmpty())
+				Expect(rulesWithChain.SubChains).To(Equal(expectedSubChains))
+				Expect(rulesWithChain.SubChains[0].Conditions).To(Equal(rules.IPTablesRule{"-p", "tcp"}))
+				Expect(rulesWithChain.SubChains[0].Rules[0]).To(ContainElement("--set-xmark"))
+			})
+		})
+
+		Context("when the rules are below the sub-chain threshold", func() {
+			BeforeEach(func() {
+				policyPlanner.SubChainMinRules = 1000
+			})
+
+			It("keeps the rules in one chain", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.Rules).NotTo(BeEmpty())
+				Expect(rulesWithChain.SubChains).To(BeEmpty())
+			})
+		})
+
+		Context("when health check sources are configured", func() {
+			BeforeEach(func() {
+				policyPlanner.HealthCheckSources = []string{"169.254.0.5/32"}
+			})
+
+			It("accepts their probes to the app ports of every container", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+
+				healthCheckRules := []rules.IPTablesRule{}
+				for _, rule := range rulesWithChain.Rules {
+					if rule[0] == "-s" && rule[1] == "169.254.0.5/32" {
+						healthCheckRules = append(healthCheckRules, rule)
+					}
+				}
+				Expect(healthCheckRules).To(Equal([]rules.IPTablesRule{
+					{"-s", "169.254.0.5/32", "-d", "10.255.1.2", "-p", "tcp", "-m", "tcp", "--dport", "8080", "--jump", "ACCEPT", "-m", "comment", "--comment", "health-check"},
+					{"-s", "169.254.0.5/32", "-d", "10.255.1.3", "-p", "tcp", "-m", "tcp", "--dport", "8181", "--jump", "ACCEPT", "-m", "comment", "--comment", "health-check"},
+					{"-s", "169.254.0.5/32", "-d", "10.255.1.3", "-p", "tcp", "-m", "tcp", "--dport", "9090", "--jump", "ACCEPT", "-m", "comment", "--comment", "health-check"},
+				}))
+			})
+
+			Context("when overlay ingress rules are disabled", func() {
+				BeforeEach(func() {
+					policyPlanner.EnableOverlayIngressRules = false
+				})
+
+				It("still accepts the probes", func() {
+					rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.Rules).To(ContainElement(ContainElement("health-check")))
+				})
+			})
+		})
+
+		Context("when icmp echo is accepted", func() {
+			BeforeEach(func() {
+				policyPlanner.AcceptICMPEcho = true
+			})
+
+			It("accepts echo requests to every container after the policy rules", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.Rules[len(rulesWithChain.Rules)-2:]).To(Equal([]rules.IPTablesRule{
+					{"-d", "10.255.1.3", "-p", "icmp", "-m", "icmp", "--icmp-type", "echo-request", "--jump", "ACCEPT"},
+					{"-d", "10.255.1.2", "-p", "icmp", "-m", "icmp", "--icmp-type", "echo-request", "--jump", "ACCEPT"},
+				}))
+			})
+		})
+
+		Context("when icmp echo is not accepted", func() {
+			It("leaves echo requests to the overlay chains", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.Rules).NotTo(ContainElement(ContainElement("echo-request")))
+			})
+		})
+
 		It("emits time metrics", func() {
 			_, err := policyPlanner.GetPolicyRulesAndChain()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(metricsSender.SendDurationCallCount()).To(Equal(2))
+			Expect(metricsSender.SendDurationCallCount()).To(Equal(3))
 			name, _ := metricsSender.SendDurationArgsForCall(0)
 			Expect(name).To(Equal("containerMetadataTime"))
 			name, _ = metricsSender.SendDurationArgsForCall(1)
 			Expect(name).To(Equal("policyServerPollTime"))
+			name, _ = metricsSender.SendDurationArgsForCall(2)
+			Expect(name).To(Equal("policyConvertTime"))
+		})
+
+		It("emits the number of policies", func() {
+			_, err := policyPlanner.GetPolicyRulesAndChain()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metricsSender.SendValueCallCount()).To(Equal(1))
+			name, value, unit := metricsSender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("policyServerPolicies"))
+			Expect(value).To(Equal(float64(len(policyServerResponse))))
+			Expect(unit).To(Equal("policies"))
+		})
+
+		Context("when the payload is metered", func() {
+			var payloadMeter *fakes.PayloadMeter
+
+			BeforeEach(func() {
+				payloadMeter = &fakes.PayloadMeter{}
+				policyPlanner.PayloadMeter = payloadMeter
+			})
+
+			It("sends the size of the policies payload once per cycle", func() {
+				_, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(payloadMeter.SendPayloadSizeCallCount()).To(Equal(1))
+				Expect(payloadMeter.SendPayloadSizeArgsForCall(0)).To(Equal("/networking/v1/internal/policies"))
+			})
 		})
 
 		Context("when the policies are returned from the server in a different order", func() {
@@ -769,6 +945,225 @@ var _ = Describe("Planner", func() {
 				Expect(logger).To(gbytes.Say(`policy-client-get-container-policies.*converting container metadata port to int*`))
 			})
 		})
+		Context("when policy sources are configured", func() {
+			var (
+				policySource      *fakes.PolicySource
+				otherPolicySource *fakes.PolicySource
+				externalAllowRule rules.IPTablesRule
+			)
+
+			BeforeEach(func() {
+				policyClient.CreateOrGetTagStub = func(id, groupType string) (string, error) {
+					if id == "external-app-guid" && groupType == "app" {
+						return "DD", nil
+					}
+					return "5476", nil
+				}
+
+				policySource = &fakes.PolicySource{}
+				policySource.PoliciesReturns([]policy_client.Policy{{
+					Source: policy_client.Source{ID: "external-app-guid"},
+					Destination: policy_client.Destination{
+						ID:       "some-other-app-guid",
+						Protocol: "tcp",
+						Ports:    policy_client.Ports{Start: 7000, End: 7000},
+					},
+				}}, nil)
+				otherPolicySource = &fakes.PolicySource{}
+				policyPlanner.PolicySources = []planner.PolicySource{policySource, otherPolicySource}
+
+				externalAllowRule = rules.NewMarkAllowRule("10.255.1.3", "tcp", 7000, 7000, "DD", "external-app-guid", "some-other-app-guid")
+			})
+
+			It("asks each policy source about the containers", func() {
+				_, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(policySource.PoliciesCallCount()).To(Equal(1))
+				Expect(policySource.PoliciesArgsForCall(0)).To(ConsistOf(
+					planner.PolicySourceContainer{Handle: "container-id-1", AppGUID: "some-app-guid", SpaceGUID: "some-space-guid", Purpose: "task", IP: "10.255.1.2"},
+					planner.PolicySourceContainer{Handle: "container-id-2", AppGUID: "some-other-app-guid", SpaceGUID: "some-other-space-guid", Purpose: "staging", IP: "10.255.1.3"},
+				))
+				Expect(otherPolicySource.PoliciesCallCount()).To(Equal(1))
+			})
+
+			It("adds the policies of the policy sources to those of the policy server", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.Rules).To(ContainElement(externalAllowRule))
+				Expect(rulesWithChain.Rules).To(ContainElement(rules.NewMarkAllowRule("10.255.1.3", "tcp", 1234, 1234, "AA", "some-app-guid", "some-other-app-guid")))
+			})
+
+			It("looks up the tag of a source app once", func() {
+				_, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				_, err = policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+
+				lookups := 0
+				for i := 0; i < policyClient.CreateOrGetTagCallCount(); i++ {
+					if id, _ := policyClient.CreateOrGetTagArgsForCall(i); id == "external-app-guid" {
+						lookups++
+					}
+				}
+				Expect(lookups).To(Equal(1))
+			})
+
+			Context("when the policy server has not modified the policies", func() {
+				BeforeEach(func() {
+					policyServerCache := &fakes.PolicyServerCache{}
+					policyServerCache.NotModifiedReturns(true)
+					policyPlanner.PolicyServerCache = policyServerCache
+				})
+
+				It("plans again when the policies of a source changed", func() {
+					rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.Rules).To(ContainElement(externalAllowRule))
+
+					policySource.PoliciesReturns(nil, nil)
+					rulesWithChain, err = policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.Rules).NotTo(ContainElement(externalAllowRule))
+				})
+			})
+
+			Context("when a policy source fails", func() {
+				BeforeEach(func() {
+					_, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					policySource.PoliciesReturns(nil, errors.New("banana"))
+				})
+
+				It("logs, counts the failure and keeps the last policies of the source", func() {
+					rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.Rules).To(ContainElement(externalAllowRule))
+
+					Expect(logger).To(gbytes.Say("policy-source-get-policies.*banana"))
+					Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+					Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("policySourceFailures"))
+					Expect(otherPolicySource.PoliciesCallCount()).To(Equal(2))
+				})
+			})
+
+			Context("when the tag of a source app cannot be looked up", func() {
+				BeforeEach(func() {
+					policyClient.CreateOrGetTagStub = func(id, groupType string) (string, error) {
+						if id == "external-app-guid" {
+							return "", errors.New("banana")
+						}
+						return "5476", nil
+					}
+				})
+
+				It("treats it as a failure of the source", func() {
+					rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.Rules).NotTo(ContainElement(externalAllowRule))
+					Expect(logger).To(gbytes.Say("policy-source-get-policies.*get tag for external-app-guid: banana"))
+				})
+			})
+		})
+
+		Context("when qos classes are configured", func() {
+			var (
+				policySource      *fakes.PolicySource
+				otherPolicySource *fakes.PolicySource
+			)
+
+			BeforeEach(func() {
+				policySource = &fakes.PolicySource{}
+				policySource.QoSClassesReturns(map[string]string{"some-app-guid": "gold"}, nil)
+				otherPolicySource = &fakes.PolicySource{}
+				otherPolicySource.QoSClassesReturns(map[string]string{
+					"some-app-guid":       "bronze",
+					"some-other-app-guid": "bronze",
+				}, nil)
+				policyPlanner.PolicySources = []planner.PolicySource{policySource, otherPolicySource}
+				policyPlanner.QoSClasses = []planner.QoSClass{
+					{Name: "gold", DSCP: 34},
+					{Name: "bronze", DSCP: 10, RateLimitKBPerSec: 512},
+				}
+			})
+
+			It("marks and caps the packets of the containers of apps with a class in the mangle table", func() {
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(rulesWithChain.ExtraTables).To(Equal([]enforcer.TableRules{{
+					Chain: enforcer.Chain{Table: "mangle", ParentChain: "INPUT", Prefix: "some-prefix"},
+					Rules: []rules.IPTablesRule{
+						{"-s", "10.255.1.3", "-m", "hashlimit", "--hashlimit-above", "512kb/s", "--hashlimit-mode", "srcip", "--hashlimit-name", "vpa-qos-0", "-m", "comment", "--comment", "qos:bronze", "-j", "DROP"},
+						{"-s", "10.255.1.3", "-m", "comment", "--comment", "qos:bronze", "-j", "DSCP", "--set-dscp", "10"},
+						{"-s", "10.255.1.2", "-m", "comment", "--comment", "qos:gold", "-j", "DSCP", "--set-dscp", "34"},
+					},
+				}}))
+			})
+
+			It("ignores the classes that are not configured", func() {
+				policySource.QoSClassesReturns(map[string]string{"some-app-guid": "platinum"}, nil)
+				otherPolicySource.QoSClassesReturns(nil, nil)
+
+				rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChain.ExtraTables).To(HaveLen(1))
+				Expect(rulesWithChain.ExtraTables[0].Rules).To(BeEmpty())
+				Expect(logger).To(gbytes.Say("unknown-qos-class.*platinum"))
+			})
+
+			Context("when the policy server has not modified the policies", func() {
+				BeforeEach(func() {
+					policyServerCache := &fakes.PolicyServerCache{}
+					policyServerCache.NotModifiedReturns(true)
+					policyPlanner.PolicyServerCache = policyServerCache
+				})
+
+				It("plans again when the class of an app changed", func() {
+					_, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+
+					policySource.QoSClassesReturns(map[string]string{"some-app-guid": "bronze"}, nil)
+					rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.ExtraTables[0].Rules).To(ContainElement(
+						rules.IPTablesRule{"-s", "10.255.1.2", "-m", "comment", "--comment", "qos:bronze", "-j", "DSCP", "--set-dscp", "10"},
+					))
+				})
+			})
+
+			Context("when a policy source fails", func() {
+				BeforeEach(func() {
+					_, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					policySource.QoSClassesReturns(nil, errors.New("banana"))
+				})
+
+				It("logs, counts the failure and keeps the last classes of the source", func() {
+					rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.ExtraTables[0].Rules).To(ContainElement(
+						rules.IPTablesRule{"-s", "10.255.1.2", "-m", "comment", "--comment", "qos:gold", "-j", "DSCP", "--set-dscp", "34"},
+					))
+
+					Expect(logger).To(gbytes.Say("policy-source-get-qos-classes.*banana"))
+					Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("policySourceFailures"))
+				})
+			})
+
+			Context("when no qos classes are configured", func() {
+				BeforeEach(func() {
+					policyPlanner.QoSClasses = nil
+				})
+
+				It("does not ask the policy sources and plans no qos chain", func() {
+					rulesWithChain, err := policyPlanner.GetPolicyRulesAndChain()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain.ExtraTables).To(BeEmpty())
+					Expect(policySource.QoSClassesCallCount()).To(Equal(0))
+				})
+			})
+		})
 	})
 
 	Describe("GetASGRulesAndChains", func() {
@@ -782,11 +1177,33 @@ var _ = Describe("Planner", func() {
 		It("emits time metrics", func() {
 			_, err := policyPlanner.GetASGRulesAndChains()
 			Expect(err).NotTo(HaveOccurred())
-			Expect(metricsSender.SendDurationCallCount()).To(Equal(2))
+			Expect(metricsSender.SendDurationCallCount()).To(Equal(3))
 			name, _ := metricsSender.SendDurationArgsForCall(0)
 			Expect(name).To(Equal("containerMetadataTime"))
 			name, _ = metricsSender.SendDurationArgsForCall(1)
 			Expect(name).To(Equal("policyServerASGPollTime"))
+			name, _ = metricsSender.SendDurationArgsForCall(2)
+			Expect(name).To(Equal("asgConvertTime"))
+		})
+
+		It("emits the number of security groups", func() {
+			policyClient.GetSecurityGroupsForSpaceReturns([]policy_client.SecurityGroup{{Name: "sg-1"}, {Name: "sg-2"}}, nil)
+			_, err := policyPlanner.GetASGRulesAndChains()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metricsSender.SendValueCallCount()).To(Equal(1))
+			name, value, unit := metricsSender.SendValueArgsForCall(0)
+			Expect(name).To(Equal("policyServerSecurityGroups"))
+			Expect(value).To(Equal(float64(2)))
+			Expect(unit).To(Equal("security groups"))
+		})
+
+		It("sends the size of the security groups payload once per cycle", func() {
+			payloadMeter := &fakes.PayloadMeter{}
+			policyPlanner.PayloadMeter = payloadMeter
+			_, err := policyPlanner.GetASGRulesAndChains()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(payloadMeter.SendPayloadSizeCallCount()).To(Equal(1))
+			Expect(payloadMeter.SendPayloadSizeArgsForCall(0)).To(Equal("/networking/v1/internal/security_groups"))
 		})
 
 		Context("when there are no containers in the datastore", func() {
@@ -914,6 +1331,81 @@ var _ = Describe("Planner", func() {
 					Expect(receivedStagingContainerWorkload).To(Equal("staging"))
 				})
 
+				Context("when the security groups are returned from the server in a different order", func() {
+					BeforeEach(func() {
+						securityGroups = append(securityGroups, policy_client.SecurityGroup{
+							Guid:              "another-running-security-group-guid",
+							Name:              "another-running-security-group",
+							RunningSpaceGuids: []string{"some-space-guid"},
+							Rules:             policy_client.SecurityGroupRules{{Protocol: "all", Destination: "50.0.0.5"}},
+						})
+						securityGroups[1].Guid = "running-security-group-guid"
+						policyClient.GetSecurityGroupsForSpaceReturns(securityGroups, nil)
+					})
+
+					It("the order of the rules is not affected", func() {
+						_, err := policyPlanner.GetASGRulesAndChains("container-id-1")
+						Expect(err).NotTo(HaveOccurred())
+
+						reversed := []policy_client.SecurityGroup{}
+						for i := range securityGroups {
+							reversed = append(reversed, securityGroups[len(securityGroups)-i-1])
+						}
+						policyClient.GetSecurityGroupsForSpaceReturns(reversed, nil)
+						_, err = policyPlanner.GetASGRulesAndChains("container-id-1")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(netOutChain.IPTablesRulesCallCount()).To(Equal(2))
+						_, _, ruleSpec := netOutChain.IPTablesRulesArgsForCall(0)
+						_, _, reversedRuleSpec := netOutChain.IPTablesRulesArgsForCall(1)
+						expectedRules, err := netrules.NewRulesFromSecurityGroupRules(policy_client.SecurityGroupRules{
+							{Protocol: "all", Destination: "50.0.0.5"},
+							{Protocol: "all", Destination: "20.0.0.2"},
+						})
+						Expect(err).NotTo(HaveOccurred())
+						Expect(ruleSpec).To(Equal(expectedRules))
+						Expect(reversedRuleSpec).To(Equal(expectedRules))
+					})
+				})
+
+				Context("when the rules reach the sub-chain threshold", func() {
+					BeforeEach(func() {
+						policyPlanner.SubChainMinRules = 3
+						netOutChain.IPTablesRulesReturns([]rules.IPTablesRule{
+							{"-p", "tcp", "-d", "10.0.0.1", "-j", "ACCEPT"},
+							{"-d", "10.0.0.2", "-j", "ACCEPT"},
+						}, nil)
+						netOutChain.DefaultRulesReturns([]rules.IPTablesRule{{"-j", "REJECT"}})
+					})
+
+					It("moves the rules of each container into per-protocol sub-chains", func() {
+						rulesWithChains, err := policyPlanner.GetASGRulesAndChains()
+						Expect(err).NotTo(HaveOccurred())
+						Expect(rulesWithChains).To(HaveLen(2))
+
+						for _, rulesWithChain := range rulesWithChains {
+							Expect(rulesWithChain.Rules).To(BeEmpty())
+							Expect(rulesWithChain.SubChains).To(Equal([]enforcer.SubChain{
+								{
+									Conditions: rules.IPTablesRule{"-p", "tcp"},
+									Rules: []rules.IPTablesRule{
+										{"-d", "10.0.0.2", "-j", "ACCEPT"},
+										{"-p", "tcp", "-d", "10.0.0.1", "-j", "ACCEPT"},
+										{"-j", "REJECT"},
+									},
+								},
+								{
+									Conditions: rules.IPTablesRule{},
+									Rules: []rules.IPTablesRule{
+										{"-d", "10.0.0.2", "-j", "ACCEPT"},
+										{"-j", "REJECT"},
+									},
+								},
+							}))
+						}
+					})
+				})
+
 				Context("and there are also global security groups for staging and running", func() {
 					var (
 						expectedGlobalRunningRules policy_client.SecurityGroupRules
@@ -997,6 +1489,13 @@ var _ = Describe("Planner", func() {
 					})
 				})
 
+				It("names the container of the rules", func() {
+					rulesWithChain, err := policyPlanner.GetASGRulesAndChains("container-id-2")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChain).To(HaveLen(1))
+					Expect(rulesWithChain[0].Handle).To(Equal("container-id-2"))
+				})
+
 				Describe("log config", func() {
 					Context("when container metadata does not contain log_config", func() {
 						It("returns empty log config", func() {
@@ -1054,6 +1553,314 @@ var _ = Describe("Planner", func() {
 				Expect(netOutChain.IPTablesRulesCallCount()).To(Equal(2))
 			})
 
+			It("passes the instance index from the log config to the default rules", func() {
+				data["container-id-1"].Metadata["log_config"] = `{"guid":"some-app-guid","index":3}`
+
+				_, err := policyPlanner.GetASGRulesAndChains()
+				Expect(err).NotTo(HaveOccurred())
+
+				instanceIndexes := map[string]string{}
+				for i := 0; i < netOutChain.DefaultRulesCallCount(); i++ {
+					handle, instanceIndex := netOutChain.DefaultRulesArgsForCall(i)
+					instanceIndexes[handle] = instanceIndex
+				}
+				Expect(instanceIndexes).To(Equal(map[string]string{
+					"container-id-1": "3",
+					"container-id-2": "",
+				}))
+			})
+
+			Context("when the agent is sharded", func() {
+				BeforeEach(func() {
+					policyPlanner.Shard = planner.Shard{Index: planner.Shard{Count: 5}.Of("container-id-2"), Count: 5}
+					Expect(policyPlanner.Shard.Owns("container-id-1")).To(BeFalse())
+				})
+
+				It("only plans the containers of its shard", func() {
+					rulesWithChains, err := policyPlanner.GetASGRulesAndChains()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChains).To(HaveLen(1))
+					Expect(rulesWithChains[0].Chain.ParentChain).To(Equal("netout-container-id-2"))
+				})
+			})
+
+		})
+
+		Context("when a container is in an egress proxy space", func() {
+			var proxyRules policy_client.SecurityGroupRules
+
+			BeforeEach(func() {
+				proxyRules = policy_client.SecurityGroupRules{{Protocol: "tcp", Destination: "10.0.5.5", Ports: "3128"}}
+				policyPlanner.EgressProxySpaceGUIDs = []string{"some-other-space-guid"}
+				policyPlanner.EgressProxyRules = proxyRules
+				policyClient.GetSecurityGroupsForSpaceReturns([]policy_client.SecurityGroup{
+					{
+						Name:              "staging-security-group",
+						StagingSpaceGuids: []string{"some-other-space-guid"},
+						Rules:             policy_client.SecurityGroupRules{{Protocol: "all", Destination: "0.0.0.0-255.255.255.255"}},
+						StagingDefault:    true,
+					},
+				}, nil)
+			})
+
+			It("does not get security groups for the egress proxy space", func() {
+				_, err := policyPlanner.GetASGRulesAndChains()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policyClient.GetSecurityGroupsForSpaceArgsForCall(0)).To(ConsistOf("some-space-guid"))
+			})
+
+			It("only allows traffic to the egress proxies, ignoring security groups", func() {
+				rulesWithChains, err := policyPlanner.GetASGRulesAndChains("container-id-2")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChains).To(HaveLen(1))
+				Expect(rulesWithChains[0].Chain.ParentChain).To(Equal("netout-container-id-2"))
+				Expect(rulesWithChains[0].Chain.Prefix).To(Equal(planner.ASGChainPrefix("container-id-2")))
+
+				handle, containerWorkload, ruleSpec := netOutChain.IPTablesRulesArgsForCall(0)
+				Expect(handle).To(Equal("container-id-2"))
+				Expect(containerWorkload).To(Equal("staging"))
+				expectedRules, err := netrules.NewRulesFromSecurityGroupRules(proxyRules)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ruleSpec).To(Equal(expectedRules))
+			})
+		})
+
+		Context("when a container is in an sni inspected space", func() {
+			BeforeEach(func() {
+				netOutChain.SNIRulesStub = func(spaceGUID string) []rules.IPTablesRule {
+					if spaceGUID == "some-other-space-guid" {
+						return []rules.IPTablesRule{{"sni-queue"}, {"sni-denied"}}
+					}
+					return nil
+				}
+			})
+
+			It("puts the sni inspection rules on top of the others", func() {
+				rulesWithChains, err := policyPlanner.GetASGRulesAndChains("container-id-1", "container-id-2")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rulesWithChains).To(HaveLen(2))
+				for _, rulesWithChain := range rulesWithChains {
+					if rulesWithChain.Handle == "container-id-2" {
+						Expect(rulesWithChain.Rules).To(Equal([]rules.IPTablesRule{{"sni-denied"}, {"sni-queue"}, {"rule-4"}, {"rule-3"}}))
+					} else {
+						Expect(rulesWithChain.Rules).To(Equal([]rules.IPTablesRule{{"rule-2"}, {"rule-1"}}))
+					}
+				}
+			})
+
+			Context("when its allowlist has rules", func() {
+				var sniRules []policy_client.SecurityGroupRule
+
+				BeforeEach(func() {
+					sniRules = []policy_client.SecurityGroupRule{{Protocol: "tcp", Destination: "10.0.0.0/24", Ports: "443"}}
+					policyPlanner.SNIAllowlistRules = map[string][]policy_client.SecurityGroupRule{
+						"some-other-space-guid": sniRules,
+					}
+				})
+
+				It("allows the connections to their destinations after the ASGs", func() {
+					_, err := policyPlanner.GetASGRulesAndChains("container-id-2")
+					Expect(err).NotTo(HaveOccurred())
+
+					_, _, ruleSpec := netOutChain.IPTablesRulesArgsForCall(0)
+					expectedRules, err := netrules.NewRulesFromSecurityGroupRules(sniRules)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(len(ruleSpec)).To(BeNumerically(">=", len(expectedRules)))
+					Expect(ruleSpec[len(ruleSpec)-len(expectedRules):]).To(Equal(expectedRules))
+				})
+
+				It("does not add them to the containers of other spaces", func() {
+					_, err := policyPlanner.GetASGRulesAndChains("container-id-1")
+					Expect(err).NotTo(HaveOccurred())
+
+					_, _, ruleSpec := netOutChain.IPTablesRulesArgsForCall(0)
+					expectedRules, err := netrules.NewRulesFromSecurityGroupRules(sniRules)
+					Expect(err).NotTo(HaveOccurred())
+					for _, rule := range expectedRules {
+						Expect(ruleSpec).NotTo(ContainElement(rule))
+					}
+				})
+			})
+		})
+
+		Context("when policy sources are configured", func() {
+			var (
+				policySource      *fakes.PolicySource
+				otherPolicySource *fakes.PolicySource
+				runningRules      policy_client.SecurityGroupRules
+			)
+
+			BeforeEach(func() {
+				runningRules = policy_client.SecurityGroupRules{{Protocol: "tcp", Destination: "10.0.0.0/8", Ports: "443"}}
+				policyClient.GetSecurityGroupsForSpaceReturns([]policy_client.SecurityGroup{
+					{
+						Name:           "running-security-group",
+						Rules:          runningRules,
+						RunningDefault: true,
+					},
+				}, nil)
+
+				policySource = &fakes.PolicySource{}
+				policySource.EgressRulesReturns(map[string][]policy_client.SecurityGroupRule{
+					"container-id-1": {{Protocol: "tcp", Destination: "10.1.1.1", Ports: "5432"}},
+				}, nil)
+				otherPolicySource = &fakes.PolicySource{}
+				otherPolicySource.EgressRulesReturns(map[string][]policy_client.SecurityGroupRule{
+					"container-id-1": {{Protocol: "udp", Destination: "10.2.2.2", Ports: "53"}},
+				}, nil)
+				policyPlanner.PolicySources = []planner.PolicySource{policySource, otherPolicySource}
+			})
+
+			It("asks each policy source about the containers", func() {
+				_, err := policyPlanner.GetASGRulesAndChains("container-id-1")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(policySource.EgressRulesCallCount()).To(Equal(1))
+				Expect(policySource.EgressRulesArgsForCall(0)).To(Equal([]planner.PolicySourceContainer{{
+					Handle:    "container-id-1",
+					AppGUID:   "some-app-guid",
+					SpaceGUID: "some-space-guid",
+					Purpose:   "task",
+					IP:        "10.255.1.2",
+				}}))
+				Expect(otherPolicySource.EgressRulesCallCount()).To(Equal(1))
+			})
+
+			It("adds the rules of every policy source to the security group rules", func() {
+				_, err := policyPlanner.GetASGRulesAndChains("container-id-1")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, ruleSpec := netOutChain.IPTablesRulesArgsForCall(0)
+				expectedRules, err := netrules.NewRulesFromSecurityGroupRules([]policy_client.SecurityGroupRule{
+					{Protocol: "tcp", Destination: "10.0.0.0/8", Ports: "443"},
+					{Protocol: "tcp", Destination: "10.1.1.1", Ports: "5432"},
+					{Protocol: "udp", Destination: "10.2.2.2", Ports: "53"},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ruleSpec).To(Equal(expectedRules))
+			})
+
+			It("emits a metric for the policy sources", func() {
+				_, err := policyPlanner.GetASGRulesAndChains()
+				Expect(err).NotTo(HaveOccurred())
+
+				found := false
+				for i := 0; i < metricsSender.SendDurationCallCount(); i++ {
+					name, _ := metricsSender.SendDurationArgsForCall(i)
+					if name == "policySourcePollTime" {
+						found = true
+					}
+				}
+				Expect(found).To(BeTrue())
+			})
+
+			Context("when a container is in an egress proxy space", func() {
+				BeforeEach(func() {
+					policyPlanner.EgressProxySpaceGUIDs = []string{"some-space-guid"}
+					policyPlanner.EgressProxyRules = policy_client.SecurityGroupRules{{Protocol: "tcp", Destination: "10.0.5.5", Ports: "3128"}}
+				})
+
+				It("does not ask the policy sources about it", func() {
+					_, err := policyPlanner.GetASGRulesAndChains("container-id-1")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(policySource.EgressRulesCallCount()).To(Equal(0))
+
+					_, _, ruleSpec := netOutChain.IPTablesRulesArgsForCall(0)
+					expectedRules, err := netrules.NewRulesFromSecurityGroupRules(policyPlanner.EgressProxyRules)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ruleSpec).To(Equal(expectedRules))
+				})
+			})
+
+			Context("when a policy source fails", func() {
+				BeforeEach(func() {
+					_, err := policyPlanner.GetASGRulesAndChains()
+					Expect(err).NotTo(HaveOccurred())
+					otherPolicySource.EgressRulesReturns(nil, errors.New("banana"))
+				})
+
+				It("logs, counts the failure and keeps the last rules of the source", func() {
+					_, err := policyPlanner.GetASGRulesAndChains()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(logger).To(gbytes.Say("policy-source-get-egress-rules.*banana"))
+					Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+					Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("policySourceFailures"))
+
+					Expect(netOutChain.IPTablesRulesCallCount()).To(Equal(4))
+					handle, _, ruleSpec := netOutChain.IPTablesRulesArgsForCall(3)
+					Expect(handle).To(Equal("container-id-1"))
+					expectedRules, err := netrules.NewRulesFromSecurityGroupRules([]policy_client.SecurityGroupRule{
+						{Protocol: "tcp", Destination: "10.0.0.0/8", Ports: "443"},
+						{Protocol: "tcp", Destination: "10.1.1.1", Ports: "5432"},
+						{Protocol: "udp", Destination: "10.2.2.2", Ports: "53"},
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ruleSpec).To(Equal(expectedRules))
+				})
+			})
+		})
+
+		Context("when destination chains are configured", func() {
+			var destinationChains *fakes.DestinationChains
+
+			BeforeEach(func() {
+				data["container-id-2"].Metadata["policy_group_id"] = "some-app-guid"
+				destinationChains = &fakes.DestinationChains{}
+				destinationChains.SyncReturns(map[string][]string{
+					"some-app-guid": {"dest-aaaaaaaaaaaa", "dest-bbbbbbbbbbbb"},
+				}, nil)
+				policyPlanner.DestinationChains = destinationChains
+			})
+
+			It("jumps from the ASG chains of the app and task containers to the chains of their destinations", func() {
+				rulesWithChains, err := policyPlanner.GetASGRulesAndChains()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(destinationChains.SyncCallCount()).To(Equal(1))
+
+				rulesByHandle := map[string][]rules.IPTablesRule{}
+				for _, rulesWithChain := range rulesWithChains {
+					rulesByHandle[rulesWithChain.Handle] = rulesWithChain.Rules
+				}
+				Expect(rulesByHandle["container-id-1"]).To(Equal([]rules.IPTablesRule{
+					{"rule-2"},
+					{"rule-1"},
+					{"-j", "dest-bbbbbbbbbbbb"},
+					{"-j", "dest-aaaaaaaaaaaa"},
+				}))
+
+				By("not jumping from the chains of staging containers")
+				Expect(rulesByHandle["container-id-2"]).To(Equal([]rules.IPTablesRule{{"rule-4"}, {"rule-3"}}))
+			})
+
+			Context("when a container is in an egress proxy space", func() {
+				BeforeEach(func() {
+					policyPlanner.EgressProxySpaceGUIDs = []string{"some-space-guid"}
+				})
+
+				It("does not jump to the chains of its destinations", func() {
+					rulesWithChains, err := policyPlanner.GetASGRulesAndChains("container-id-1")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(rulesWithChains).To(HaveLen(1))
+					Expect(rulesWithChains[0].Rules).To(Equal([]rules.IPTablesRule{{"rule-2"}, {"rule-1"}}))
+				})
+			})
+
+			Context("when the destinations cannot be synced", func() {
+				BeforeEach(func() {
+					destinationChains.SyncReturns(map[string][]string{
+						"some-app-guid": {"dest-aaaaaaaaaaaa"},
+					}, errors.New("banana"))
+				})
+
+				It("logs, counts the failure and jumps to the chains of the last sync", func() {
+					rulesWithChains, err := policyPlanner.GetASGRulesAndChains("container-id-1")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(logger).To(gbytes.Say("destination-chains-sync.*banana"))
+					Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+					Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("destinationSyncFailures"))
+					Expect(rulesWithChains[0].Rules).To(Equal([]rules.IPTablesRule{{"rule-2"}, {"rule-1"}, {"-j", "dest-aaaaaaaaaaaa"}}))
+				})
+			})
 		})
 
 		Context("when getting containers from datastore fails", func() {
@@ -0,0 +1,128 @@
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+)
+
+// QoSTable is the table of the chain that marks and caps the packets of the
+// containers with a QoS class. It sits next to the policy chain, before the
+// filter table sees the packets.
+const QoSTable = "mangle"
+
+// QoSClass is a network service level of apps: the packets their containers
+// send through the cell are marked with DSCP, and beyond RateLimitKBPerSec
+// kilobytes per second per container they are dropped, unless it is 0.
+type QoSClass struct {
+	Name              string
+	DSCP              int
+	RateLimitKBPerSec int
+}
+
+// getPolicySourceQoSClasses asks every policy source for the QoS classes of
+// the apps, keyed by app guid. The first source that names a class for an app
+// wins. A source that fails keeps the classes of its last answer.
+func (p *VxlanPolicyPlanner) getPolicySourceQoSClasses(allContainers []container) map[string]string {
+	if len(p.QoSClasses) == 0 || len(p.PolicySources) == 0 {
+		return nil
+	}
+
+	sourceContainers := policySourceContainers(allContainers)
+	if len(sourceContainers) == 0 {
+		return nil
+	}
+
+	if p.policySourceQoSClasses == nil {
+		p.policySourceQoSClasses = make([]map[string]string, len(p.PolicySources))
+	}
+
+	policySourceStartRequestTime := time.Now()
+	appClasses := map[string]string{}
+	for i, source := range p.PolicySources {
+		classes, err := source.QoSClasses(sourceContainers)
+		if err != nil {
+			p.Logger.Error("policy-source-get-qos-classes", err, lager.Data{"policy_source": i})
+			p.MetricsSender.IncrementCounter(metricPolicySourceFailures)
+		} else {
+			p.policySourceQoSClasses[i] = classes
+		}
+		for app, class := range p.policySourceQoSClasses[i] {
+			if _, ok := appClasses[app]; !ok {
+				appClasses[app] = class
+			}
+		}
+	}
+
+	policySourcePollDuration := time.Now().Sub(policySourceStartRequestTime)
+	p.MetricsSender.SendDuration(metricPolicySourcePoll, policySourcePollDuration)
+	return appClasses
+}
+
+// planQoSRules plans the rules of the QoS chain: for every container of an
+// app with a known class, a rule that drops its packets beyond the rate limit
+// of the class, and a rule that marks the rest with the DSCP of the class.
+// Classes that are not configured are ignored.
+func (p *VxlanPolicyPlanner) planQoSRules(allContainers []container, appClasses map[string]string) enforcer.TableRules {
+	classes := map[string]QoSClass{}
+	hashlimitNames := map[string]string{}
+	names := []string{}
+	for _, class := range p.QoSClasses {
+		classes[class.Name] = class
+		names = append(names, class.Name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		// hashlimit names are limited to 15 characters
+		hashlimitNames[name] = fmt.Sprintf("vpa-qos-%d", i)
+	}
+
+	qosRules := []rules.IPTablesRule{}
+	unknown := map[string]bool{}
+	for _, container := range allContainers {
+		className, ok := appClasses[container.AppID]
+		if !ok {
+			continue
+		}
+		class, ok := classes[className]
+		if !ok {
+			unknown[className] = true
+			continue
+		}
+
+		if class.RateLimitKBPerSec > 0 {
+			qosRules = append(qosRules, rules.IPTablesRule{
+				"-s", container.IP,
+				"-m", "hashlimit",
+				"--hashlimit-above", fmt.Sprintf("%dkb/s", class.RateLimitKBPerSec),
+				"--hashlimit-mode", "srcip",
+				"--hashlimit-name", hashlimitNames[class.Name],
+				"-m", "comment", "--comment", fmt.Sprintf("qos:%s", class.Name),
+				"-j", "DROP",
+			})
+		}
+		qosRules = append(qosRules, rules.IPTablesRule{
+			"-s", container.IP,
+			"-m", "comment", "--comment", fmt.Sprintf("qos:%s", class.Name),
+			"-j", "DSCP", "--set-dscp", strconv.Itoa(class.DSCP),
+		})
+	}
+
+	for className := range unknown {
+		p.Logger.Info("unknown-qos-class", lager.Data{"class": className})
+	}
+
+	return enforcer.TableRules{
+		Chain: enforcer.Chain{
+			Table:       QoSTable,
+			ParentChain: p.Chain.ParentChain,
+			Prefix:      p.Chain.Prefix,
+		},
+		Rules: qosRules,
+	}
+}
@@ -0,0 +1,65 @@
+package planner
+
+import (
+	"encoding/hex"
+	"regexp"
+)
+
+// Shard is the share of the containers of a cell that one policy agent worker
+// enforces the ASGs of, when the agent runs as several workers. A container
+// belongs to the shard of the hash of its handle that is in the names of its
+// ASG chains, so that the owner of a chain is known from its name alone.
+// The worker of shard 0 is the coordinator: it alone enforces the chains that
+// are global to the cell. The zero Shard is a single worker that owns all
+// containers.
+type Shard struct {
+	Index int
+	Count int
+}
+
+var asgChainHashRegexp = regexp.MustCompile(`^asg-([0-9a-f]{6})`)
+
+// Sharded reports whether the containers are split between workers.
+func (s Shard) Sharded() bool {
+	return s.Count > 1
+}
+
+// Coordinator reports whether the worker owns the global chains of the cell.
+func (s Shard) Coordinator() bool {
+	return s.Index == 0
+}
+
+// Of is the shard of a container.
+func (s Shard) Of(handle string) int {
+	return s.of(handleHash(handle))
+}
+
+// Owns reports whether the worker enforces the ASGs of a container.
+func (s Shard) Owns(handle string) bool {
+	return !s.Sharded() || s.Of(handle) == s.Index
+}
+
+// OwnsChain reports whether the worker manages an ASG chain, or one of its
+// sub-chains. Chains that are not ASG chains are left to the callers.
+func (s Shard) OwnsChain(name string) bool {
+	if !s.Sharded() {
+		return true
+	}
+	matches := asgChainHashRegexp.FindStringSubmatch(name)
+	if matches == nil {
+		return true
+	}
+	hash, err := hex.DecodeString(matches[1])
+	if err != nil {
+		return true
+	}
+	return s.of(hash) == s.Index
+}
+
+func (s Shard) of(hash []byte) int {
+	if !s.Sharded() {
+		return 0
+	}
+	value := int(hash[0])<<16 | int(hash[1])<<8 | int(hash[2])
+	return value % s.Count
+}
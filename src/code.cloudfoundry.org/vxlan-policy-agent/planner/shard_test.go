@@ -0,0 +1,59 @@
+package planner_test
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shard", func() {
+	It("gives every container to a single worker", func() {
+		shards := []planner.Shard{{Index: 0, Count: 3}, {Index: 1, Count: 3}, {Index: 2, Count: 3}}
+		owned := map[int]int{}
+		for i := 0; i < 300; i++ {
+			handle := fmt.Sprintf("container-%d", i)
+			owners := 0
+			for _, shard := range shards {
+				if shard.Owns(handle) {
+					owners++
+					owned[shard.Index]++
+					Expect(shard.Of(handle)).To(Equal(shard.Index))
+				}
+			}
+			Expect(owners).To(Equal(1))
+		}
+		for _, shard := range shards {
+			Expect(owned[shard.Index]).To(BeNumerically(">", 50))
+		}
+	})
+
+	It("finds the owner of an ASG chain from its name", func() {
+		for i := 0; i < 30; i++ {
+			handle := fmt.Sprintf("container-%d", i)
+			shard := planner.Shard{Index: planner.Shard{Count: 4}.Of(handle), Count: 4}
+			other := planner.Shard{Index: (shard.Index + 1) % 4, Count: 4}
+			chain := planner.ASGChainPrefix(handle) + "v1-abc"
+
+			Expect(shard.OwnsChain(chain)).To(BeTrue())
+			Expect(shard.OwnsChain(chain + "-1")).To(BeTrue())
+			Expect(other.OwnsChain(chain)).To(BeFalse())
+		}
+	})
+
+	It("leaves the chains that are not ASG chains to the callers", func() {
+		shard := planner.Shard{Index: 1, Count: 4}
+		Expect(shard.OwnsChain("vpa--1234567890")).To(BeTrue())
+	})
+
+	Context("when the agent is not sharded", func() {
+		It("owns every container and chain and coordinates", func() {
+			shard := planner.Shard{}
+			Expect(shard.Sharded()).To(BeFalse())
+			Expect(shard.Coordinator()).To(BeTrue())
+			Expect(shard.Owns("some-handle")).To(BeTrue())
+			Expect(shard.OwnsChain(planner.ASGChainPrefix("some-handle") + "1234567890")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package policysource_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPolicySource(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "PolicySource Suite")
+}
@@ -0,0 +1,75 @@
+package policysource
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
+	"code.cloudfoundry.org/policy_client"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+)
+
+// Webhook asks an HTTP endpoint for egress rules, container to container
+// policies and the QoS classes of apps. The request and response follow the OPA data API, so the URL can
+// point at an OPA rule such as http://127.0.0.1:8181/v1/data/cf/network, or at
+// any service speaking the same JSON:
+//
+//	request:  {"input": {"containers": [{"handle": ..., "app_guid": ..., ...}]}}
+//	response: {"result": {
+//	            "egress": {"<container handle>": [{"protocol": ..., "destination": ..., "ports": ...}]},
+//	            "policies": [{"source": {"id": ...}, "destination": {"id": ..., "protocol": ..., "ports": {"start": ..., "end": ...}}}],
+//	            "qos": {"<app guid>": "<qos class>"}
+//	          }}
+type Webhook struct {
+	Client json_client.JsonClient
+}
+
+type webhookInput struct {
+	Containers []planner.PolicySourceContainer `json:"containers"`
+}
+
+type webhookRequest struct {
+	Input webhookInput `json:"input"`
+}
+
+type webhookResult struct {
+	Egress   map[string][]policy_client.SecurityGroupRule `json:"egress"`
+	Policies []policy_client.Policy                       `json:"policies"`
+	QoS      map[string]string                            `json:"qos"`
+}
+
+type webhookResponse struct {
+	Result webhookResult `json:"result"`
+}
+
+func (w *Webhook) EgressRules(containers []planner.PolicySourceContainer) (map[string][]policy_client.SecurityGroupRule, error) {
+	result, err := w.query(containers)
+	if err != nil {
+		return nil, err
+	}
+	return result.Egress, nil
+}
+
+func (w *Webhook) Policies(containers []planner.PolicySourceContainer) ([]policy_client.Policy, error) {
+	result, err := w.query(containers)
+	if err != nil {
+		return nil, err
+	}
+	return result.Policies, nil
+}
+
+func (w *Webhook) QoSClasses(containers []planner.PolicySourceContainer) (map[string]string, error) {
+	result, err := w.query(containers)
+	if err != nil {
+		return nil, err
+	}
+	return result.QoS, nil
+}
+
+func (w *Webhook) query(containers []planner.PolicySourceContainer) (webhookResult, error) {
+	var resp webhookResponse
+	err := w.Client.Do("POST", "", webhookRequest{Input: webhookInput{Containers: containers}}, &resp, "")
+	if err != nil {
+		return webhookResult{}, fmt.Errorf("webhook: %s", err)
+	}
+	return resp.Result, nil
+}
@@ -0,0 +1,149 @@
+package policysource_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/policy_client"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+	"code.cloudfoundry.org/vxlan-policy-agent/policysource"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Webhook", func() {
+	var (
+		server       *httptest.Server
+		requestBody  string
+		requestPath  string
+		responseCode int
+		responseBody string
+		webhook      *policysource.Webhook
+		containers   []planner.PolicySourceContainer
+	)
+
+	BeforeEach(func() {
+		responseCode = http.StatusOK
+		responseBody = `{"result": {
+			"egress": {"some-handle": [{"protocol": "tcp", "destination": "10.1.1.1", "ports": "5432"}]},
+			"policies": [{"source": {"id": "some-app-guid"}, "destination": {"id": "other-app-guid", "protocol": "tcp", "ports": {"start": 8080, "end": 8081}}}],
+			"qos": {"some-app-guid": "gold"}
+		}}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			requestBody = string(body)
+			requestPath = r.URL.Path
+			w.WriteHeader(responseCode)
+			w.Write([]byte(responseBody))
+		}))
+
+		webhook = &policysource.Webhook{
+			Client: json_client.New(lagertest.NewTestLogger("test"), http.DefaultClient, server.URL+"/v1/data/cf/network"),
+		}
+		containers = []planner.PolicySourceContainer{{
+			Handle:    "some-handle",
+			AppGUID:   "some-app-guid",
+			SpaceGUID: "some-space-guid",
+			Purpose:   "app",
+			IP:        "10.255.1.2",
+		}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("posts the containers as input", func() {
+		_, err := webhook.EgressRules(containers)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requestPath).To(Equal("/v1/data/cf/network"))
+		Expect(requestBody).To(MatchJSON(`{"input": {"containers": [{
+			"handle": "some-handle",
+			"app_guid": "some-app-guid",
+			"space_guid": "some-space-guid",
+			"purpose": "app",
+			"ip": "10.255.1.2"
+		}]}}`))
+	})
+
+	It("returns the rules from the result", func() {
+		rules, err := webhook.EgressRules(containers)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules).To(Equal(map[string][]policy_client.SecurityGroupRule{
+			"some-handle": {{Protocol: "tcp", Destination: "10.1.1.1", Ports: "5432"}},
+		}))
+	})
+
+	It("returns the policies from the result", func() {
+		policies, err := webhook.Policies(containers)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(Equal([]policy_client.Policy{{
+			Source: policy_client.Source{ID: "some-app-guid"},
+			Destination: policy_client.Destination{
+				ID:       "other-app-guid",
+				Protocol: "tcp",
+				Ports:    policy_client.Ports{Start: 8080, End: 8081},
+			},
+		}}))
+	})
+
+	It("returns the qos classes from the result", func() {
+		classes, err := webhook.QoSClasses(containers)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(classes).To(Equal(map[string]string{"some-app-guid": "gold"}))
+	})
+
+	Context("when the result is undefined", func() {
+		BeforeEach(func() {
+			responseBody = `{}`
+		})
+
+		It("returns no rules", func() {
+			rules, err := webhook.EgressRules(containers)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rules).To(BeEmpty())
+		})
+
+		It("returns no policies", func() {
+			policies, err := webhook.Policies(containers)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(BeEmpty())
+		})
+
+		It("returns no qos classes", func() {
+			classes, err := webhook.QoSClasses(containers)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(classes).To(BeEmpty())
+		})
+	})
+
+	Context("when the webhook fails", func() {
+		BeforeEach(func() {
+			responseCode = http.StatusInternalServerError
+			responseBody = `{"error": "banana"}`
+		})
+
+		It("returns an error", func() {
+			_, err := webhook.EgressRules(containers)
+			Expect(err).To(MatchError("webhook: http status 500: banana"))
+
+			_, err = webhook.Policies(containers)
+			Expect(err).To(MatchError("webhook: http status 500: banana"))
+		})
+	})
+
+	Context("when the response is not json", func() {
+		BeforeEach(func() {
+			responseBody = `banana`
+		})
+
+		It("returns an error", func() {
+			_, err := webhook.EgressRules(containers)
+			Expect(err).To(MatchError(ContainSubstring("webhook: json unmarshal")))
+		})
+	})
+})
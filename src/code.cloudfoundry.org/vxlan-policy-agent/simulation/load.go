@@ -0,0 +1,168 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"code.cloudfoundry.org/lib/datastore"
+	"code.cloudfoundry.org/policy_client"
+)
+
+// maxContainers is the number of container addresses in 198.18.0.0/16, the
+// first half of the benchmarking network the synthetic containers get their
+// addresses from. The ASG rules go to the second half, 198.19.0.0/16.
+const maxContainers = 65534
+
+const maxASGRules = 65536
+
+// Load describes the synthetic containers a simulation plans and enforces
+// the rules of.
+type Load struct {
+	// Containers is the number of synthetic app containers.
+	Containers int
+	// InstancesPerApp is how many of the containers are instances of the
+	// same app.
+	InstancesPerApp int
+	// AppsPerSpace is how many apps share a space, and so its ASG.
+	AppsPerSpace int
+	// PoliciesPerApp is the number of container to container policies from
+	// every app to the apps after it.
+	PoliciesPerApp int
+	// ASGRules is the number of rules of the ASG that is bound to every
+	// space.
+	ASGRules int
+}
+
+func (l Load) Validate() error {
+	if l.Containers < 1 || l.Containers > maxContainers {
+		return fmt.Errorf("containers must be between 1 and %d", maxContainers)
+	}
+	if l.InstancesPerApp < 1 {
+		return errors.New("instances per app must be at least 1")
+	}
+	if l.AppsPerSpace < 1 {
+		return errors.New("apps per space must be at least 1")
+	}
+	if l.PoliciesPerApp < 0 {
+		return errors.New("policies per app must not be negative")
+	}
+	if l.ASGRules < 0 || l.ASGRules > maxASGRules {
+		return fmt.Errorf("asg rules must be between 0 and %d", maxASGRules)
+	}
+	return nil
+}
+
+func (l Load) apps() int {
+	return (l.Containers + l.InstancesPerApp - 1) / l.InstancesPerApp
+}
+
+// syntheticSource serves the containers, policies and ASGs of a load to the
+// planner in place of the datastore and the policy server. Bumping the
+// revision changes the port of every policy and ASG rule, so that every rule
+// set changes.
+type syntheticSource struct {
+	load     Load
+	revision int
+}
+
+func (s *syntheticSource) handles() []string {
+	handles := make([]string, s.load.Containers)
+	for i := range handles {
+		handles[i] = containerHandle(i)
+	}
+	return handles
+}
+
+func (s *syntheticSource) ReadAll() (map[string]datastore.Container, error) {
+	containers := map[string]datastore.Container{}
+	for i := 0; i < s.load.Containers; i++ {
+		app := i / s.load.InstancesPerApp
+		containers[containerHandle(i)] = datastore.Container{
+			Handle: containerHandle(i),
+			IP:     fmt.Sprintf("198.18.%d.%d", (i+1)/256, (i+1)%256),
+			Metadata: map[string]interface{}{
+				datastore.MetadataKeyAppID:         appGUID(app),
+				datastore.MetadataKeyPolicyGroupID: appGUID(app),
+				datastore.MetadataKeySpaceID:       spaceGUID(app / s.load.AppsPerSpace),
+				datastore.MetadataKeyPorts:         "8080",
+				datastore.MetadataKeyWorkload:      "app",
+			},
+		}
+	}
+	return containers, nil
+}
+
+func (s *syntheticSource) GetPoliciesByID(ids ...string) ([]policy_client.Policy, error) {
+	requested := map[string]bool{}
+	for _, id := range ids {
+		requested[id] = true
+	}
+
+	apps := s.load.apps()
+	policies := []policy_client.Policy{}
+	for app := 0; app < apps; app++ {
+		for k := 1; k <= s.load.PoliciesPerApp && k < apps; k++ {
+			destination := (app + k) % apps
+			if !requested[appGUID(app)] && !requested[appGUID(destination)] {
+				continue
+			}
+			policies = append(policies, policy_client.Policy{
+				Source: policy_client.Source{ID: appGUID(app), Tag: appTag(app)},
+				Destination: policy_client.Destination{
+					ID:       appGUID(destination),
+					Tag:      appTag(destination),
+					Protocol: "tcp",
+					Ports:    policy_client.Ports{Start: 8080 + s.revision, End: 8080 + s.revision},
+				},
+			})
+		}
+	}
+	return policies, nil
+}
+
+func (s *syntheticSource) GetSecurityGroupsForSpace(spaceGuids ...string) ([]policy_client.SecurityGroup, error) {
+	rules := policy_client.SecurityGroupRules{}
+	for j := 0; j < s.load.ASGRules; j++ {
+		rules = append(rules, policy_client.SecurityGroupRule{
+			Protocol:    "tcp",
+			Destination: fmt.Sprintf("198.19.%d.%d", j/256, j%256),
+			Ports:       strconv.Itoa(443 + s.revision),
+		})
+	}
+
+	securityGroups := []policy_client.SecurityGroup{}
+	for _, spaceGuid := range spaceGuids {
+		securityGroups = append(securityGroups, policy_client.SecurityGroup{
+			Guid:              spaceGuid + "-asg",
+			Name:              spaceGuid + "-asg",
+			Rules:             rules,
+			RunningSpaceGuids: []string{spaceGuid},
+		})
+	}
+	return securityGroups, nil
+}
+
+// CreateOrGetTag is only asked for the tags of policy sources and of the
+// ingress router, which a simulation does not have.
+func (s *syntheticSource) CreateOrGetTag(id, groupType string) (string, error) {
+	return "", fmt.Errorf("no tag for %s %s in a simulation", groupType, id)
+}
+
+func containerHandle(i int) string {
+	return fmt.Sprintf("%s%d", HandlePrefix, i)
+}
+
+func appGUID(app int) string {
+	return fmt.Sprintf("%sapp-%d", HandlePrefix, app)
+}
+
+func spaceGUID(space int) string {
+	return fmt.Sprintf("%sspace-%d", HandlePrefix, space)
+}
+
+// appTag is the policy tag of an app. Tags are 16 bit marks, and there are
+// fewer apps than containers.
+func appTag(app int) string {
+	return fmt.Sprintf("%04X", app+1)
+}
@@ -0,0 +1,38 @@
+package simulation_test
+
+import (
+	"code.cloudfoundry.org/vxlan-policy-agent/simulation"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Load", func() {
+	var load simulation.Load
+
+	BeforeEach(func() {
+		load = simulation.Load{
+			Containers:      100,
+			InstancesPerApp: 2,
+			AppsPerSpace:    5,
+			PoliciesPerApp:  3,
+			ASGRules:        50,
+		}
+	})
+
+	It("is valid", func() {
+		Expect(load.Validate()).To(Succeed())
+	})
+
+	DescribeTable("invalid loads",
+		func(change func(*simulation.Load), message string) {
+			change(&load)
+			Expect(load.Validate()).To(MatchError(message))
+		},
+		Entry("no containers", func(l *simulation.Load) { l.Containers = 0 }, "containers must be between 1 and 65534"),
+		Entry("more containers than addresses", func(l *simulation.Load) { l.Containers = 65535 }, "containers must be between 1 and 65534"),
+		Entry("no instances per app", func(l *simulation.Load) { l.InstancesPerApp = 0 }, "instances per app must be at least 1"),
+		Entry("no apps per space", func(l *simulation.Load) { l.AppsPerSpace = 0 }, "apps per space must be at least 1"),
+		Entry("negative policies per app", func(l *simulation.Load) { l.PoliciesPerApp = -1 }, "policies per app must not be negative"),
+		Entry("more asg rules than destinations", func(l *simulation.Load) { l.ASGRules = 65537 }, "asg rules must be between 0 and 65536"),
+	)
+})
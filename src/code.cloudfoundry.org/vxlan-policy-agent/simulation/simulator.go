@@ -0,0 +1,215 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cni-wrapper-plugin/netrules"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/converger"
+	"code.cloudfoundry.org/vxlan-policy-agent/enforcer"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+)
+
+// The chains of a simulation all start with vsim, so that they are told
+// apart from the chains of the agent and of garden-cni, and cannot be cleaned
+// up by them while a simulation runs.
+const (
+	// HandlePrefix starts the handles of the synthetic containers, and so the
+	// names of their netout chains, e.g. netout--vsim-0.
+	HandlePrefix = "vsim-"
+	// ForwardChain stands in for the FORWARD chain. Nothing jumps to it, so
+	// the rules of a simulation never match any traffic.
+	ForwardChain = "vsim-forward"
+	// PolicyChainPrefix starts the names of the policy chains.
+	PolicyChainPrefix = "vsim--"
+	// ASGChainPrefix replaces the asg- of the names of the ASG chains, e.g.
+	// vsim1a2b3cv1-g7cs183k3a, which keeps them as long as those of the agent.
+	ASGChainPrefix = "vsim"
+
+	asgManagedChainsRegex = ASGChainPrefix + `[a-z0-9]{6}`
+)
+
+// Simulator measures how long the agent takes to plan and enforce the rules
+// of a synthetic load, so that the capacity of a cell can be planned before
+// a large change to a foundation without running any workloads. It uses the
+// planner, converger and enforcer of the agent with the real iptables of the
+// cell, and deletes its chains when it is done.
+type Simulator struct {
+	Load             Load
+	IPTables         rules.IPTablesAdapter
+	ChainNameVersion int
+	SubChainMinRules int
+	Logger           lager.Logger
+}
+
+// Cycle is how long a simulated poll cycle took to plan the policy and ASG
+// rules and to enforce them. Enforcing includes comparing the rules with the
+// ones that were enforced last, which is all an unchanged cycle does.
+type Cycle struct {
+	Name          string
+	PolicyPlan    time.Duration
+	PolicyEnforce time.Duration
+	ASGPlan       time.Duration
+	ASGEnforce    time.Duration
+}
+
+func (c Cycle) Total() time.Duration {
+	return c.PolicyPlan + c.PolicyEnforce + c.ASGPlan + c.ASGEnforce
+}
+
+// Run simulates a first cycle that enforces all rules, a cycle in which
+// nothing changed, and a cycle in which every policy and ASG rule changed.
+// Chains left behind by an interrupted simulation are deleted first.
+func (s *Simulator) Run() (cycles []Cycle, err error) {
+	err = s.Load.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ruleEnforcer := enforcer.NewEnforcer(
+		s.Logger.Session("rules-enforcer"),
+		&enforcer.Timestamper{},
+		s.IPTables,
+		enforcer.EnforcerConfig{ChainNameVersion: s.ChainNameVersion},
+	)
+	netOutChain := &netrules.NetOutChain{
+		ChainNamer: &netrules.ChainNamer{MaxLength: 28},
+		Converter:  &netrules.RuleConverter{Logger: s.Logger, MetricsSender: discardMetrics{}},
+	}
+
+	err = s.deleteChains(ruleEnforcer)
+	if err != nil {
+		return nil, fmt.Errorf("delete chains of previous simulation: %s", err)
+	}
+	defer func() {
+		deleteErr := s.deleteChains(ruleEnforcer)
+		if deleteErr != nil && err == nil {
+			err = fmt.Errorf("delete chains: %s", deleteErr)
+		}
+	}()
+
+	source := &syntheticSource{load: s.Load}
+	parentChains := []string{ForwardChain}
+	for _, handle := range source.handles() {
+		parentChains = append(parentChains, netOutChain.Name(handle))
+	}
+	for _, chain := range parentChains {
+		err = s.IPTables.NewChain(enforcer.FilterTable, chain)
+		if err != nil {
+			return nil, fmt.Errorf("create chain %s: %s", chain, err)
+		}
+	}
+
+	timedPlanner := &timedPlanner{planner: &planner.VxlanPolicyPlanner{
+		Logger:        s.Logger.Session("planner"),
+		Datastore:     source,
+		PolicyClient:  source,
+		MetricsSender: discardMetrics{},
+		Chain: enforcer.Chain{
+			Table:       enforcer.FilterTable,
+			ParentChain: ForwardChain,
+			Prefix:      PolicyChainPrefix,
+		},
+		LoggingState:     &planner.LoggingState{},
+		NetOutChain:      netOutChain,
+		SubChainMinRules: s.SubChainMinRules,
+	}}
+	pollCycle := converger.NewSinglePollCycle(
+		[]converger.Planner{timedPlanner},
+		ruleEnforcer,
+		nil,
+		discardMetrics{},
+		nil,
+		s.Logger.Session("converger"),
+	)
+
+	steps := []struct {
+		name     string
+		revision int
+	}{
+		{name: "initial", revision: 0},
+		{name: "unchanged", revision: 0},
+		{name: "changed", revision: 1},
+	}
+	for _, step := range steps {
+		source.revision = step.revision
+		cycle := Cycle{Name: step.name}
+
+		timedPlanner.planned = 0
+		start := time.Now()
+		err = pollCycle.DoPolicyCycle()
+		if err != nil {
+			return cycles, fmt.Errorf("%s policy cycle: %s", step.name, err)
+		}
+		cycle.PolicyPlan = timedPlanner.planned
+		cycle.PolicyEnforce = time.Since(start) - timedPlanner.planned
+
+		// syncing the containers by handle skips the cleanup of the polling
+		// loop, which would look for the ASG chains of the agent
+		timedPlanner.planned = 0
+		start = time.Now()
+		err = pollCycle.SyncASGsForContainers(source.handles()...)
+		if err != nil {
+			return cycles, fmt.Errorf("%s asg cycle: %s", step.name, err)
+		}
+		cycle.ASGPlan = timedPlanner.planned
+		cycle.ASGEnforce = time.Since(start) - timedPlanner.planned
+
+		s.Logger.Info("simulated-cycle", lager.Data{"cycle": cycle.Name, "total": cycle.Total().String()})
+		cycles = append(cycles, cycle)
+	}
+	return cycles, nil
+}
+
+// deleteChains deletes the chains of the filter table that belong to a
+// simulation, along with their sub-chains.
+func (s *Simulator) deleteChains(ruleEnforcer *enforcer.Enforcer) error {
+	chains, err := s.IPTables.ListChains(enforcer.FilterTable)
+	if err != nil {
+		return err
+	}
+
+	simulationChains := []enforcer.LiveChain{}
+	for _, chain := range chains {
+		if strings.HasPrefix(chain, ASGChainPrefix) || strings.HasPrefix(chain, "netout--"+HandlePrefix) {
+			simulationChains = append(simulationChains, enforcer.LiveChain{Table: enforcer.FilterTable, Name: chain})
+		}
+	}
+	_, err = ruleEnforcer.DeleteChains(simulationChains)
+	return err
+}
+
+// timedPlanner adds up how long the planner takes, and renames the ASG chains
+// of the synthetic containers, so that the agent does not clean them up as
+// stale ASG chains while the simulation runs.
+type timedPlanner struct {
+	planner converger.Planner
+	planned time.Duration
+}
+
+func (p *timedPlanner) GetPolicyRulesAndChain() (enforcer.RulesWithChain, error) {
+	start := time.Now()
+	defer func() { p.planned += time.Since(start) }()
+	return p.planner.GetPolicyRulesAndChain()
+}
+
+func (p *timedPlanner) GetASGRulesAndChains(containers ...string) ([]enforcer.RulesWithChain, error) {
+	start := time.Now()
+	defer func() { p.planned += time.Since(start) }()
+
+	ruleSets, err := p.planner.GetASGRulesAndChains(containers...)
+	for i := range ruleSets {
+		ruleSets[i].Chain.Prefix = ASGChainPrefix + strings.TrimPrefix(ruleSets[i].Chain.Prefix, "asg-")
+		ruleSets[i].Chain.ManagedChainsRegex = asgManagedChainsRegex
+	}
+	return ruleSets, err
+}
+
+type discardMetrics struct{}
+
+func (discardMetrics) IncrementCounter(string)            {}
+func (discardMetrics) SendDuration(string, time.Duration) {}
+func (discardMetrics) SendValue(string, float64, string)  {}
@@ -0,0 +1,235 @@
+package simulation_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	libfakes "code.cloudfoundry.org/lib/fakes"
+	"code.cloudfoundry.org/lib/rules"
+	"code.cloudfoundry.org/vxlan-policy-agent/planner"
+	"code.cloudfoundry.org/vxlan-policy-agent/simulation"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// memoryTable keeps the chains of the filter table that the fake iptables
+// changes, so that the enforcer sees the chains it created.
+type memoryTable struct {
+	order  []string
+	chains map[string][]rules.IPTablesRule
+}
+
+func newMemoryTable(iptables *libfakes.IPTablesAdapter) *memoryTable {
+	t := &memoryTable{chains: map[string][]rules.IPTablesRule{}}
+	iptables.NewChainStub = func(table, chain string) error {
+		if _, ok := t.chains[chain]; ok {
+			return fmt.Errorf("chain %s exists", chain)
+		}
+		t.order = append(t.order, chain)
+		t.chains[chain] = nil
+		return nil
+	}
+	iptables.ListChainsStub = func(table string) ([]string, error) {
+		return append([]string{}, t.order...), nil
+	}
+	iptables.ListStub = func(table, chain string) ([]string, error) {
+		list := []string{"-N " + chain}
+		for _, rule := range t.chains[chain] {
+			list = append(list, fmt.Sprintf("-A %s %s", chain, strings.Join(rule, " ")))
+		}
+		return list, nil
+	}
+	iptables.BulkInsertStub = func(table, chain string, pos int, rulespec ...rules.IPTablesRule) error {
+		existing := t.chains[chain]
+		t.chains[chain] = append(append(append([]rules.IPTablesRule{}, existing[:pos-1]...), rulespec...), existing[pos-1:]...)
+		return nil
+	}
+	iptables.BulkAppendStub = func(table, chain string, rulespec ...rules.IPTablesRule) error {
+		t.chains[chain] = append(t.chains[chain], rulespec...)
+		return nil
+	}
+	iptables.DeleteStub = func(table, chain string, rulespec rules.IPTablesRule) error {
+		for i, rule := range t.chains[chain] {
+			if strings.Join(rule, " ") == strings.Join(rulespec, " ") {
+				t.chains[chain] = append(t.chains[chain][:i], t.chains[chain][i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("no rule %v in %s", rulespec, chain)
+	}
+	iptables.DeleteAfterRuleNumKeepRejectStub = func(table, chain string, ruleNum int) error {
+		if len(t.chains[chain]) >= ruleNum {
+			t.chains[chain] = t.chains[chain][:ruleNum-1]
+		}
+		t.chains[chain] = append(t.chains[chain], rules.IPTablesRule{"-j", "REJECT"})
+		return nil
+	}
+	iptables.ClearChainStub = func(table, chain string) error {
+		t.chains[chain] = nil
+		return nil
+	}
+	iptables.DeleteChainStub = func(table, chain string) error {
+		delete(t.chains, chain)
+		for i, name := range t.order {
+			if name == chain {
+				t.order = append(t.order[:i], t.order[i+1:]...)
+				break
+			}
+		}
+		return nil
+	}
+	return t
+}
+
+var _ = Describe("Simulator", func() {
+	var (
+		iptables  *libfakes.IPTablesAdapter
+		table     *memoryTable
+		simulator *simulation.Simulator
+	)
+
+	BeforeEach(func() {
+		iptables = &libfakes.IPTablesAdapter{}
+		table = newMemoryTable(iptables)
+		Expect(iptables.NewChain("filter", "netout--real-handle")).To(Succeed())
+		Expect(iptables.NewChain("filter", "asg-a1b2c3v1-g7cs183k3a")).To(Succeed())
+		Expect(iptables.BulkAppend("filter", "netout--real-handle", rules.IPTablesRule{"-j", "asg-a1b2c3v1-g7cs183k3a"})).To(Succeed())
+
+		simulator = &simulation.Simulator{
+			Load: simulation.Load{
+				Containers:      4,
+				InstancesPerApp: 2,
+				AppsPerSpace:    1,
+				PoliciesPerApp:  1,
+				ASGRules:        3,
+			},
+			IPTables:         iptables,
+			ChainNameVersion: rules.ChainNameVersion1,
+			Logger:           lagertest.NewTestLogger("test"),
+		}
+	})
+
+	// createdChains returns the chains the simulation created with the
+	// prefix, in order.
+	createdChains := func(prefix string) []string {
+		chains := []string{}
+		for i := 0; i < iptables.NewChainCallCount(); i++ {
+			_, chain := iptables.NewChainArgsForCall(i)
+			if strings.HasPrefix(chain, prefix) {
+				chains = append(chains, chain)
+			}
+		}
+		return chains
+	}
+
+	// appendedRules returns the rules the simulation appended to the chain.
+	appendedRules := func(chain string) []rules.IPTablesRule {
+		appended := []rules.IPTablesRule{}
+		for i := 0; i < iptables.BulkAppendCallCount(); i++ {
+			_, name, rulespec := iptables.BulkAppendArgsForCall(i)
+			if name == chain {
+				appended = append(appended, rulespec...)
+			}
+		}
+		return appended
+	}
+
+	It("measures an initial, an unchanged and a changed cycle", func() {
+		cycles, err := simulator.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cycles).To(HaveLen(3))
+		for i, name := range []string{"initial", "unchanged", "changed"} {
+			Expect(cycles[i].Name).To(Equal(name))
+			Expect(cycles[i].PolicyPlan).To(BeNumerically(">", 0))
+			Expect(cycles[i].ASGPlan).To(BeNumerically(">", 0))
+			Expect(cycles[i].Total()).To(Equal(cycles[i].PolicyPlan + cycles[i].PolicyEnforce + cycles[i].ASGPlan + cycles[i].ASGEnforce))
+		}
+	})
+
+	It("enforces the policies of the synthetic apps", func() {
+		_, err := simulator.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		policyChains := createdChains(simulation.PolicyChainPrefix)
+		Expect(policyChains).To(HaveLen(2))
+		Expect(appendedRules(policyChains[0])).To(ConsistOf(
+			rules.NewMarkSetRule("198.18.0.1", "0001", "vsim-app-0"),
+			rules.NewMarkSetRule("198.18.0.2", "0001", "vsim-app-0"),
+			rules.NewMarkSetRule("198.18.0.3", "0002", "vsim-app-1"),
+			rules.NewMarkSetRule("198.18.0.4", "0002", "vsim-app-1"),
+			rules.NewMarkAllowRule("198.18.0.1", "tcp", 8080, 8080, "0002", "vsim-app-1", "vsim-app-0"),
+			rules.NewMarkAllowRule("198.18.0.2", "tcp", 8080, 8080, "0002", "vsim-app-1", "vsim-app-0"),
+			rules.NewMarkAllowRule("198.18.0.3", "tcp", 8080, 8080, "0001", "vsim-app-0", "vsim-app-1"),
+			rules.NewMarkAllowRule("198.18.0.4", "tcp", 8080, 8080, "0001", "vsim-app-0", "vsim-app-1"),
+		))
+		Expect(appendedRules(policyChains[1])).To(ContainElement(
+			rules.NewMarkAllowRule("198.18.0.1", "tcp", 8081, 8081, "0002", "vsim-app-1", "vsim-app-0"),
+		))
+	})
+
+	It("enforces the ASG rules of every synthetic container in chains apart from those of the agent", func() {
+		_, err := simulator.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(createdChains("asg-")).To(Equal([]string{"asg-a1b2c3v1-g7cs183k3a"}))
+		for i := 0; i < 4; i++ {
+			handle := fmt.Sprintf("vsim-%d", i)
+			prefix := simulation.ASGChainPrefix + strings.TrimPrefix(planner.ASGChainPrefix(handle), "asg-")
+			asgChains := createdChains(prefix)
+			Expect(asgChains).To(HaveLen(2), handle)
+			Expect(asgChains[0]).To(MatchRegexp(`^vsim[0-9a-f]{6}v1-[0-9a-z]+$`))
+			Expect(len(asgChains[0])).To(BeNumerically("<=", rules.MaxChainNameLength))
+
+			asgRules := appendedRules(asgChains[0])
+			Expect(asgRules).To(ContainElement(ContainElements("198.19.0.2-198.19.0.2", "443:443")))
+			Expect(appendedRules(asgChains[1])).To(ContainElement(ContainElements("198.19.0.2-198.19.0.2", "444:444")))
+		}
+	})
+
+	It("deletes its chains and leaves the chains of the agent alone", func() {
+		_, err := simulator.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(table.order).To(Equal([]string{"netout--real-handle", "asg-a1b2c3v1-g7cs183k3a"}))
+		Expect(table.chains["netout--real-handle"]).To(Equal([]rules.IPTablesRule{{"-j", "asg-a1b2c3v1-g7cs183k3a"}}))
+	})
+
+	It("deletes the chains of an interrupted simulation first", func() {
+		Expect(iptables.NewChain("filter", simulation.ForwardChain)).To(Succeed())
+		Expect(iptables.NewChain("filter", "vsim--v1-g7cs183k3a")).To(Succeed())
+		Expect(iptables.BulkAppend("filter", simulation.ForwardChain, rules.IPTablesRule{"-j", "vsim--v1-g7cs183k3a"})).To(Succeed())
+
+		_, err := simulator.Run()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(table.order).To(Equal([]string{"netout--real-handle", "asg-a1b2c3v1-g7cs183k3a"}))
+	})
+
+	Context("when the load is invalid", func() {
+		BeforeEach(func() {
+			simulator.Load.Containers = 0
+		})
+
+		It("does not touch iptables", func() {
+			_, err := simulator.Run()
+			Expect(err).To(MatchError("containers must be between 1 and 65534"))
+			Expect(iptables.ListChainsCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when enforcing fails", func() {
+		BeforeEach(func() {
+			iptables.BulkInsertStub = nil
+			iptables.BulkInsertReturns(errors.New("potato"))
+		})
+
+		It("returns the error and still deletes its chains", func() {
+			_, err := simulator.Run()
+			Expect(err).To(MatchError(ContainSubstring("initial policy cycle: enforce: inserting chain: potato")))
+			Expect(table.order).To(Equal([]string{"netout--real-handle", "asg-a1b2c3v1-g7cs183k3a"}))
+		})
+	})
+})
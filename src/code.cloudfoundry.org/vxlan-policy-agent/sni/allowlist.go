@@ -0,0 +1,61 @@
+package sni
+
+import (
+	"bytes"
+	"net"
+	"strings"
+)
+
+// Allowlist holds the server names the containers of a space may connect to.
+// A name with a leading *. label allows all of its subdomains, but not the
+// name itself. Names are compared case insensitively.
+type Allowlist struct {
+	names    map[string]bool
+	suffixes []string
+}
+
+func NewAllowlist(serverNames []string) *Allowlist {
+	a := &Allowlist{names: map[string]bool{}}
+	for _, name := range serverNames {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "*.") {
+			a.suffixes = append(a.suffixes, name[1:])
+			continue
+		}
+		a.names[name] = true
+	}
+	return a
+}
+
+func (a *Allowlist) Allows(serverName string) bool {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if serverName == "" {
+		return false
+	}
+	if a.names[serverName] {
+		return true
+	}
+	for _, suffix := range a.suffixes {
+		if len(serverName) > len(suffix) && strings.HasSuffix(serverName, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Destination allows the connections to its addresses, from Start to End,
+// and ports, from StartPort to EndPort, to the server names of its Allowlist.
+type Destination struct {
+	Start, End         net.IP
+	StartPort, EndPort uint16
+	Allowlist          *Allowlist
+}
+
+func (d Destination) includes(ip net.IP, port uint16) bool {
+	ip, start, end := ip.To4(), d.Start.To4(), d.End.To4()
+	if ip == nil || start == nil || end == nil {
+		return false
+	}
+	return bytes.Compare(ip, start) >= 0 && bytes.Compare(ip, end) <= 0 &&
+		port >= d.StartPort && port <= d.EndPort
+}
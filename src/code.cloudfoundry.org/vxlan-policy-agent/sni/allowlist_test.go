@@ -0,0 +1,32 @@
+package sni_test
+
+import (
+	"code.cloudfoundry.org/vxlan-policy-agent/sni"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Allowlist", func() {
+	var allowlist *sni.Allowlist
+
+	BeforeEach(func() {
+		allowlist = sni.NewAllowlist([]string{"api.example.com", "*.Example.org"})
+	})
+
+	It("allows the server names it lists, case insensitively", func() {
+		Expect(allowlist.Allows("api.example.com")).To(BeTrue())
+		Expect(allowlist.Allows("API.example.com.")).To(BeTrue())
+		Expect(allowlist.Allows("www.example.com")).To(BeFalse())
+	})
+
+	It("allows the subdomains of a wildcard name, but not the name itself", func() {
+		Expect(allowlist.Allows("www.example.org")).To(BeTrue())
+		Expect(allowlist.Allows("a.b.example.org")).To(BeTrue())
+		Expect(allowlist.Allows("example.org")).To(BeFalse())
+		Expect(allowlist.Allows("badexample.org")).To(BeFalse())
+	})
+
+	It("does not allow a missing server name", func() {
+		Expect(allowlist.Allows("")).To(BeFalse())
+	})
+})
@@ -0,0 +1,163 @@
+package sni
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrIncomplete is returned for the beginning of a ClientHello that ends
+	// before its server name.
+	ErrIncomplete = errors.New("incomplete client hello")
+	// ErrNotClientHello is returned for data that does not start with a TLS
+	// ClientHello.
+	ErrNotClientHello = errors.New("not a tls client hello")
+)
+
+const (
+	recordTypeHandshake      = 0x16
+	handshakeTypeClientHello = 0x01
+	extensionServerName      = 0x0000
+	serverNameTypeHostName   = 0x00
+	recordHeaderLength       = 5
+)
+
+// ServerName returns the server name of the TLS ClientHello that data starts
+// with, or "" if it has none. The ClientHello may span several records, and
+// data may end after the server name, as long as it contains all of it.
+func ServerName(data []byte) (string, error) {
+	handshake, complete, err := handshakeData(data)
+	if err != nil {
+		return "", err
+	}
+
+	h := reader{data: handshake, complete: complete}
+	if msgType := h.uint8(); h.err == nil && msgType != handshakeTypeClientHello {
+		return "", ErrNotClientHello
+	}
+	h.skip(3)  // length
+	h.skip(2)  // legacy version
+	h.skip(32) // random
+	h.skip(int(h.uint8()))
+	h.skip(int(h.uint16()))
+	h.skip(int(h.uint8()))
+	if h.err != nil {
+		return "", h.err
+	}
+	if h.remaining() == 0 && complete {
+		return "", nil
+	}
+
+	extensionsLength := int(h.uint16())
+	for end := h.offset + extensionsLength; h.err == nil && h.offset < end; {
+		extensionType := h.uint16()
+		extension := h.bytes(int(h.uint16()))
+		if h.err == nil && extensionType == extensionServerName {
+			return hostName(extension)
+		}
+	}
+	if h.err != nil {
+		return "", h.err
+	}
+	return "", nil
+}
+
+// handshakeData concatenates the fragments of the handshake records that
+// data starts with. It is complete if data contains all of the first
+// handshake message.
+func handshakeData(data []byte) ([]byte, bool, error) {
+	handshake := []byte{}
+	for len(data) > 0 {
+		if len(data) < recordHeaderLength {
+			if len(handshake) == 0 && data[0] != recordTypeHandshake {
+				return nil, false, ErrNotClientHello
+			}
+			break
+		}
+		if data[0] != recordTypeHandshake || data[1] != 0x03 {
+			if len(handshake) == 0 {
+				return nil, false, ErrNotClientHello
+			}
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		fragment := data[recordHeaderLength:]
+		if len(fragment) > length {
+			fragment = fragment[:length]
+		}
+		handshake = append(handshake, fragment...)
+		if len(handshake) >= 4 && len(handshake) >= 4+int(uint32(handshake[1])<<16|uint32(handshake[2])<<8|uint32(handshake[3])) {
+			return handshake, true, nil
+		}
+		data = data[recordHeaderLength+len(fragment):]
+	}
+	if len(handshake) == 0 {
+		return nil, false, ErrIncomplete
+	}
+	return handshake, false, nil
+}
+
+func hostName(extension []byte) (string, error) {
+	e := reader{data: extension, complete: true}
+	for end := 2 + int(e.uint16()); e.err == nil && e.offset < end; {
+		nameType := e.uint8()
+		name := e.bytes(int(e.uint16()))
+		if e.err == nil && nameType == serverNameTypeHostName {
+			return string(name), nil
+		}
+	}
+	if e.err != nil {
+		return "", ErrNotClientHello
+	}
+	return "", nil
+}
+
+// reader reads the fields of a handshake message. Reading past the end of
+// the data fails with ErrIncomplete, or with ErrNotClientHello if the data
+// is all of the message.
+type reader struct {
+	data     []byte
+	offset   int
+	complete bool
+	err      error
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.offset
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.remaining() < n {
+		r.err = ErrIncomplete
+		if r.complete {
+			r.err = ErrNotClientHello
+		}
+		return nil
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b
+}
+
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *reader) uint8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
@@ -0,0 +1,68 @@
+package sni_test
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"code.cloudfoundry.org/vxlan-policy-agent/sni"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ServerName", func() {
+	It("returns the server name of the client hello", func() {
+		serverName, err := sni.ServerName(clientHello("api.example.com"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serverName).To(Equal("api.example.com"))
+	})
+
+	It("returns no server name for a client hello without one", func() {
+		serverName, err := sni.ServerName(clientHello(""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serverName).To(BeEmpty())
+	})
+
+	It("returns the server name of a client hello that is cut off after it", func() {
+		hello := clientHello("api.example.com")
+		end := bytes.Index(hello, []byte("api.example.com")) + len("api.example.com")
+
+		serverName, err := sni.ServerName(hello[:end])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serverName).To(Equal("api.example.com"))
+	})
+
+	It("fails with ErrIncomplete for a client hello that is cut off before the server name", func() {
+		hello := clientHello("api.example.com")
+		end := bytes.Index(hello, []byte("api.example.com")) + 3
+
+		_, err := sni.ServerName(hello[:end])
+		Expect(err).To(MatchError(sni.ErrIncomplete))
+		_, err = sni.ServerName(hello[:3])
+		Expect(err).To(MatchError(sni.ErrIncomplete))
+	})
+
+	It("returns the server name of a client hello that spans several records", func() {
+		hello := clientHello("api.example.com")
+		handshake := hello[5:]
+		split := bytes.Index(handshake, []byte("api.example.com")) + 3
+
+		fragmented := []byte{}
+		for _, fragment := range [][]byte{handshake[:split], handshake[split:]} {
+			header := []byte{0x16, 0x03, 0x01, 0, 0}
+			binary.BigEndian.PutUint16(header[3:5], uint16(len(fragment)))
+			fragmented = append(append(fragmented, header...), fragment...)
+		}
+
+		serverName, err := sni.ServerName(fragmented)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serverName).To(Equal("api.example.com"))
+	})
+
+	It("fails with ErrNotClientHello for other data", func() {
+		_, err := sni.ServerName([]byte("GET / HTTP/1.1\r\nHost: api.example.com\r\n\r\n"))
+		Expect(err).To(MatchError(sni.ErrNotClientHello))
+
+		_, err = sni.ServerName([]byte{0x16, 0x03, 0x01, 0x00, 0x04, 0x02, 0x00, 0x00, 0x00})
+		Expect(err).To(MatchError(sni.ErrNotClientHello))
+	})
+})
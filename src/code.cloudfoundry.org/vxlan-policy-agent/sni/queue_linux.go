@@ -0,0 +1,269 @@
+package sni
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lib/rules"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// The constants of nfnetlink_queue, see
+// include/uapi/linux/netfilter/nfnetlink_queue.h.
+const (
+	nfnlSubsysQueue = 3
+
+	nfqnlMsgPacket  = 0
+	nfqnlMsgVerdict = 1
+	nfqnlMsgConfig  = 2
+
+	nfqaPacketHdr  = 1
+	nfqaVerdictHdr = 2
+	nfqaPayload    = 10
+	nfqaCT         = 11
+
+	nfqaCfgCmd    = 1
+	nfqaCfgParams = 2
+	nfqaCfgMask   = 6
+	nfqaCfgFlags  = 5
+
+	nfqnlCfgCmdBind   = 1
+	nfqnlCfgCmdUnbind = 2
+	nfqnlCopyPacket   = 2
+	nfqaCfgFFailOpen  = 1
+
+	ctaMark     = 8
+	ctaMarkMask = 21
+
+	nfDrop   = 0
+	nfAccept = 1
+
+	// copyRange is the most bytes of a packet the kernel copies to the
+	// queue, so that a packet fits into a netlink message.
+	copyRange = 0xffff - 256
+)
+
+type verdicter interface {
+	Verdict(packet []byte) Decision
+}
+
+// Queue binds to an NFQUEUE and returns the verdicts of its Verdicter on the
+// queued packets. The decided connections are marked with
+// rules.SNIAllowedConnMark or rules.SNIDeniedConnMark, so that they are no
+// longer queued. With FailOpen, the kernel accepts the packets the queue has
+// no room for.
+type Queue struct {
+	Num       uint16
+	FailOpen  bool
+	Verdicter verdicter
+	Logger    lager.Logger
+}
+
+// Run binds to the queue and handles its packets until it is signaled.
+func (q *Queue) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	socket, err := nl.Subscribe(unix.NETLINK_NETFILTER)
+	if err != nil {
+		return fmt.Errorf("netlink socket: %s", err)
+	}
+	defer socket.Close()
+
+	if err := q.configure(socket); err != nil {
+		return fmt.Errorf("binding queue %d: %s", q.Num, err)
+	}
+	defer func() {
+		if err := q.request(socket, q.configRequest(nl.NewRtAttr(nfqaCfgCmd, configCmd(nfqnlCfgCmdUnbind)))); err != nil {
+			q.Logger.Error("unbind-queue", err)
+		}
+	}()
+	// the receive times out, so that the signals are checked
+	if err := socket.SetReceiveTimeout(&unix.Timeval{Sec: 1}); err != nil {
+		return fmt.Errorf("setting receive timeout: %s", err)
+	}
+
+	q.Logger.Info("bound-queue", lager.Data{"queue": q.Num, "fail_open": q.FailOpen})
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		default:
+		}
+
+		msgs, _, err := socket.Receive()
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if errors.Is(err, unix.ENOBUFS) {
+				// the kernel dropped packets the socket had no room for
+				q.Logger.Error("queue-overrun", err, lager.Data{"queue": q.Num})
+				continue
+			}
+			return fmt.Errorf("receiving from queue %d: %s", q.Num, err)
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Type != nfnlSubsysQueue<<8|nfqnlMsgPacket {
+				continue
+			}
+			if err := q.handle(socket, msg.Data); err != nil {
+				q.Logger.Error("handle-packet", err, lager.Data{"queue": q.Num})
+			}
+		}
+	}
+}
+
+// configure binds to the queue, copies the whole packets to it and sets
+// its fail open flag, all in one message, so that no packet arrives before
+// the queue copies it.
+func (q *Queue) configure(socket *nl.NetlinkSocket) error {
+	params := make([]byte, 5)
+	binary.BigEndian.PutUint32(params, copyRange)
+	params[4] = nfqnlCopyPacket
+
+	flags := uint32(0)
+	if q.FailOpen {
+		flags = nfqaCfgFFailOpen
+	}
+	return q.request(socket, q.configRequest(
+		nl.NewRtAttr(nfqaCfgCmd, configCmd(nfqnlCfgCmdBind)),
+		nl.NewRtAttr(nfqaCfgParams, params),
+		nl.NewRtAttr(nfqaCfgFlags, bigEndianUint32(flags)),
+		nl.NewRtAttr(nfqaCfgMask, bigEndianUint32(nfqaCfgFFailOpen)),
+	))
+}
+
+func (q *Queue) handle(socket *nl.NetlinkSocket, data []byte) error {
+	if len(data) < nl.SizeofNfgenmsg {
+		return errors.New("short packet message")
+	}
+	attrs, err := nl.ParseRouteAttr(data[nl.SizeofNfgenmsg:])
+	if err != nil {
+		return fmt.Errorf("parsing packet message: %s", err)
+	}
+
+	var id uint32
+	var hasID bool
+	var payload []byte
+	for _, attr := range attrs {
+		switch attr.Attr.Type & ^uint16(unix.NLA_F_NESTED|unix.NLA_F_NET_BYTEORDER) {
+		case nfqaPacketHdr:
+			if len(attr.Value) < 4 {
+				return errors.New("short packet header")
+			}
+			id = binary.BigEndian.Uint32(attr.Value[0:4])
+			hasID = true
+		case nfqaPayload:
+			payload = attr.Value
+		}
+	}
+	if !hasID {
+		return errors.New("packet without id")
+	}
+
+	decision := q.Verdicter.Verdict(payload)
+	return socket.Send(q.verdictRequest(id, decision))
+}
+
+func (q *Queue) verdictRequest(id uint32, decision Decision) *nl.NetlinkRequest {
+	verdict := uint32(nfAccept)
+	if decision == Hold || decision == Deny {
+		verdict = nfDrop
+	}
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], verdict)
+	binary.BigEndian.PutUint32(header[4:8], id)
+
+	req := q.newRequest(nfqnlMsgVerdict, 0)
+	req.AddData(nl.NewRtAttr(nfqaVerdictHdr, header))
+
+	mark := uint32(0)
+	switch decision {
+	case Allow:
+		mark = rules.SNIAllowedConnMark
+	case Deny:
+		mark = rules.SNIDeniedConnMark
+	}
+	if mark != 0 {
+		ct := nl.NewRtAttr(nfqaCT|unix.NLA_F_NESTED, nil)
+		ct.AddRtAttr(ctaMark, bigEndianUint32(mark))
+		ct.AddRtAttr(ctaMarkMask, bigEndianUint32(rules.SNIConnMarkMask))
+		req.AddData(ct)
+	}
+	return req
+}
+
+func (q *Queue) configRequest(attrs ...*nl.RtAttr) *nl.NetlinkRequest {
+	req := q.newRequest(nfqnlMsgConfig, unix.NLM_F_ACK)
+	for _, attr := range attrs {
+		req.AddData(attr)
+	}
+	return req
+}
+
+func (q *Queue) newRequest(msgType, flags int) *nl.NetlinkRequest {
+	req := nl.NewNetlinkRequest(nfnlSubsysQueue<<8|msgType, flags)
+	req.AddData(&nfgenmsg{family: unix.AF_UNSPEC, resID: q.Num})
+	return req
+}
+
+// request sends req and waits for its acknowledgement.
+func (q *Queue) request(socket *nl.NetlinkSocket, req *nl.NetlinkRequest) error {
+	if err := socket.Send(req); err != nil {
+		return err
+	}
+	for {
+		msgs, _, err := socket.Receive()
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if msg.Header.Seq != req.Seq || msg.Header.Type != unix.NLMSG_ERROR {
+				continue
+			}
+			if len(msg.Data) < 4 {
+				return errors.New("short netlink acknowledgement")
+			}
+			if errno := int32(nl.NativeEndian().Uint32(msg.Data[0:4])); errno != 0 {
+				return unix.Errno(-errno)
+			}
+			return nil
+		}
+	}
+}
+
+func configCmd(cmd uint8) []byte {
+	// struct nfqnl_msg_config_cmd: the command, padding and a protocol
+	// family that the kernel ignores
+	return []byte{cmd, 0, 0, 0}
+}
+
+func bigEndianUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// nfgenmsg is the header of the nfnetlink messages. Its resource id, the
+// queue number, is big endian.
+type nfgenmsg struct {
+	family uint8
+	resID  uint16
+}
+
+func (m *nfgenmsg) Len() int {
+	return nl.SizeofNfgenmsg
+}
+
+func (m *nfgenmsg) Serialize() []byte {
+	b := make([]byte, nl.SizeofNfgenmsg)
+	b[0] = m.family
+	b[1] = 0 // NFNETLINK_V0
+	binary.BigEndian.PutUint16(b[2:4], m.resID)
+	return b
+}
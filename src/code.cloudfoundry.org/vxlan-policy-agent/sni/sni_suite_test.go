@@ -0,0 +1,53 @@
+package sni_test
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSNI(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SNI Suite")
+}
+
+// clientHello returns the first TLS record of a handshake to serverName.
+func clientHello(serverName string) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		defer GinkgoRecover()
+		conn := tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+		_ = conn.Handshake()
+	}()
+	defer client.Close()
+
+	header := make([]byte, 5)
+	_, err := io.ReadFull(server, header)
+	Expect(err).NotTo(HaveOccurred())
+	body := make([]byte, binary.BigEndian.Uint16(header[3:5]))
+	_, err = io.ReadFull(server, body)
+	Expect(err).NotTo(HaveOccurred())
+	return append(header, body...)
+}
+
+// tcpPacket returns an IPv4 packet of a TCP segment from 10.255.0.2:50000 to
+// 1.2.3.4:443.
+func tcpPacket(seq uint32, payload []byte) []byte {
+	packet := make([]byte, 40, 40+len(payload))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(40+len(payload)))
+	packet[9] = 6
+	copy(packet[12:16], []byte{10, 255, 0, 2})
+	copy(packet[16:20], []byte{1, 2, 3, 4})
+	binary.BigEndian.PutUint16(packet[20:22], 50000)
+	binary.BigEndian.PutUint16(packet[22:24], 443)
+	binary.BigEndian.PutUint32(packet[24:28], seq)
+	packet[32] = 5 << 4
+	return append(packet, payload...)
+}
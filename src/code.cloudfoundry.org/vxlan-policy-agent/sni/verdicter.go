@@ -0,0 +1,239 @@
+package sni
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// Decision is the verdict on a queued packet and on its connection.
+type Decision int
+
+const (
+	// Pass accepts the packet and keeps queueing its connection.
+	Pass Decision = iota
+	// Hold drops the packet, e.g. a segment out of order, so that it is sent
+	// again, and keeps queueing its connection.
+	Hold
+	// Allow accepts the packet and marks its connection as allowed.
+	Allow
+	// Deny drops the packet and marks its connection as denied.
+	Deny
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Pass:
+		return "pass"
+	case Hold:
+		return "hold"
+	case Allow:
+		return "allow"
+	case Deny:
+		return "deny"
+	}
+	return fmt.Sprintf("decision %d", int(d))
+}
+
+// DefaultFlowTimeout is how long the verdicter remembers an idle connection.
+const DefaultFlowTimeout = 5 * time.Minute
+
+// maxClientHelloLength is the most data of a connection that is buffered to
+// find its server name. A connection that sends more without one is
+// uninspectable.
+const maxClientHelloLength = 32 * 1024
+
+// Verdicter decides on the TCP connections of the packets of a queue by the
+// server names of their ClientHellos. It buffers the data of the undecided
+// connections, and remembers the decided ones until they are idle for the
+// FlowTimeout, in case their conntrack mark could not be set. A connection
+// whose data is not a ClientHello is uninspectable: it is allowed with
+// FailOpen, and denied otherwise.
+type Verdicter struct {
+	Allowlist *Allowlist
+	// Destinations allow the connections to them to more server names.
+	Destinations []Destination
+	FailOpen     bool
+	FlowTimeout  time.Duration
+	Logger       lager.Logger
+	Now          func() time.Time
+
+	flows     map[flowKey]*flow
+	lastSweep time.Time
+}
+
+type flowKey struct {
+	src, dst         [4]byte
+	srcPort, dstPort uint16
+}
+
+func (k flowKey) data() lager.Data {
+	return lager.Data{
+		"source":      fmt.Sprintf("%s:%d", net.IP(k.src[:]), k.srcPort),
+		"destination": fmt.Sprintf("%s:%d", net.IP(k.dst[:]), k.dstPort),
+	}
+}
+
+type flow struct {
+	decision  Decision
+	started   bool
+	nextSeq   uint32
+	data      []byte
+	truncated bool
+	lastSeen  time.Time
+}
+
+func (v *Verdicter) Verdict(packet []byte) Decision {
+	now := v.Now()
+	v.sweep(now)
+
+	segment, err := parseSegment(packet)
+	if err != nil {
+		v.Logger.Error("uninspectable-packet", err)
+		return v.failDecision()
+	}
+
+	f, ok := v.flows[segment.key]
+	if !ok {
+		f = &flow{decision: Pass}
+		v.flows[segment.key] = f
+	}
+	f.lastSeen = now
+	if f.decision != Pass || segment.length == 0 {
+		return f.decision
+	}
+
+	if !f.started {
+		f.started = true
+		f.nextSeq = segment.seq
+	}
+	if segment.seq != f.nextSeq {
+		if int32(segment.seq-f.nextSeq) < 0 {
+			// a retransmission of data that was passed already
+			return Pass
+		}
+		return Hold
+	}
+	f.nextSeq += uint32(segment.length)
+	f.data = append(f.data, segment.payload...)
+	f.truncated = f.truncated || len(segment.payload) < segment.length
+
+	f.decision = v.decide(segment.key, f)
+	if f.decision != Pass {
+		f.data = nil
+	}
+	return f.decision
+}
+
+func (v *Verdicter) decide(key flowKey, f *flow) Decision {
+	serverName, err := ServerName(f.data)
+	if err == ErrIncomplete && !f.truncated && len(f.data) < maxClientHelloLength {
+		return Pass
+	}
+	if err == ErrIncomplete {
+		err = fmt.Errorf("no server name in the first %d bytes", len(f.data))
+	}
+
+	data := key.data()
+	if err != nil {
+		data["error"] = err.Error()
+		data["fail_open"] = v.FailOpen
+		v.Logger.Info("uninspectable-connection", data)
+		return v.failDecision()
+	}
+
+	data["server_name"] = serverName
+	if !v.allows(key, serverName) {
+		v.Logger.Info("denied-connection", data)
+		return Deny
+	}
+	v.Logger.Debug("allowed-connection", data)
+	return Allow
+}
+
+func (v *Verdicter) allows(key flowKey, serverName string) bool {
+	if v.Allowlist != nil && v.Allowlist.Allows(serverName) {
+		return true
+	}
+	for _, destination := range v.Destinations {
+		if destination.includes(net.IP(key.dst[:]), key.dstPort) && destination.Allowlist.Allows(serverName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verdicter) failDecision() Decision {
+	if v.FailOpen {
+		return Allow
+	}
+	return Deny
+}
+
+func (v *Verdicter) sweep(now time.Time) {
+	if v.flows == nil {
+		v.flows = map[flowKey]*flow{}
+	}
+	timeout := v.FlowTimeout
+	if timeout <= 0 {
+		timeout = DefaultFlowTimeout
+	}
+	if now.Sub(v.lastSweep) < timeout {
+		return
+	}
+	for key, f := range v.flows {
+		if now.Sub(f.lastSeen) >= timeout {
+			delete(v.flows, key)
+		}
+	}
+	v.lastSweep = now
+}
+
+type segment struct {
+	key     flowKey
+	seq     uint32
+	payload []byte
+	// length is the length of the payload of the segment, which is longer
+	// than payload if the packet was truncated by the queue.
+	length int
+}
+
+func parseSegment(packet []byte) (segment, error) {
+	if len(packet) < 20 || packet[0]>>4 != 4 {
+		return segment{}, errors.New("not an ipv4 packet")
+	}
+	ipHeaderLength := int(packet[0]&0x0f) * 4
+	totalLength := int(binary.BigEndian.Uint16(packet[2:4]))
+	if packet[9] != 6 {
+		return segment{}, errors.New("not a tcp packet")
+	}
+	if ipHeaderLength < 20 || len(packet) < ipHeaderLength+20 {
+		return segment{}, errors.New("truncated packet headers")
+	}
+
+	tcp := packet[ipHeaderLength:]
+	tcpHeaderLength := int(tcp[12]>>4) * 4
+	if tcpHeaderLength < 20 || len(tcp) < tcpHeaderLength || totalLength < ipHeaderLength+tcpHeaderLength {
+		return segment{}, errors.New("truncated packet headers")
+	}
+
+	s := segment{
+		seq:    binary.BigEndian.Uint32(tcp[4:8]),
+		length: totalLength - ipHeaderLength - tcpHeaderLength,
+	}
+	copy(s.key.src[:], packet[12:16])
+	copy(s.key.dst[:], packet[16:20])
+	s.key.srcPort = binary.BigEndian.Uint16(tcp[0:2])
+	s.key.dstPort = binary.BigEndian.Uint16(tcp[2:4])
+
+	payload := tcp[tcpHeaderLength:]
+	if len(payload) > s.length {
+		payload = payload[:s.length]
+	}
+	s.payload = payload
+	return s, nil
+}
@@ -0,0 +1,134 @@
+package sni_test
+
+import (
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/vxlan-policy-agent/sni"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Verdicter", func() {
+	var (
+		logger    *lagertest.TestLogger
+		now       time.Time
+		verdicter *sni.Verdicter
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		now = time.Unix(1700000000, 0)
+		verdicter = &sni.Verdicter{
+			Allowlist:   sni.NewAllowlist([]string{"api.example.com"}),
+			FlowTimeout: time.Minute,
+			Logger:      logger,
+			Now:         func() time.Time { return now },
+		}
+	})
+
+	It("passes the packets without data", func() {
+		Expect(verdicter.Verdict(tcpPacket(1, nil))).To(Equal(sni.Pass))
+	})
+
+	It("allows the connections to allowed server names", func() {
+		Expect(verdicter.Verdict(tcpPacket(1, clientHello("api.example.com")))).To(Equal(sni.Allow))
+	})
+
+	It("denies the connections to other server names and logs them", func() {
+		Expect(verdicter.Verdict(tcpPacket(1, clientHello("www.example.com")))).To(Equal(sni.Deny))
+		Expect(logger).To(Say(`denied-connection.*"destination":"1.2.3.4:443".*"server_name":"www.example.com","source":"10.255.0.2:50000"`))
+	})
+
+	Context("when it has destinations", func() {
+		BeforeEach(func() {
+			verdicter.Destinations = []sni.Destination{
+				{Start: net.ParseIP("1.2.3.0"), End: net.ParseIP("1.2.3.255"), StartPort: 443, EndPort: 443, Allowlist: sni.NewAllowlist([]string{"*.example.org"})},
+				{Start: net.ParseIP("5.6.7.8"), End: net.ParseIP("5.6.7.8"), StartPort: 443, EndPort: 443, Allowlist: sni.NewAllowlist([]string{"www.example.com"})},
+			}
+		})
+
+		It("allows the connections to a destination to its server names", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, clientHello("cdn.example.org")))).To(Equal(sni.Allow))
+		})
+
+		It("still allows the server names of its allowlist", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, clientHello("api.example.com")))).To(Equal(sni.Allow))
+		})
+
+		It("denies the server names of other destinations", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, clientHello("www.example.com")))).To(Equal(sni.Deny))
+		})
+
+		It("denies the server names of a destination on other ports", func() {
+			verdicter.Destinations[0].StartPort, verdicter.Destinations[0].EndPort = 8000, 8443
+			Expect(verdicter.Verdict(tcpPacket(1, clientHello("cdn.example.org")))).To(Equal(sni.Deny))
+		})
+	})
+
+	It("keeps its decision on the later packets of a connection", func() {
+		hello := clientHello("www.example.com")
+		Expect(verdicter.Verdict(tcpPacket(1, hello))).To(Equal(sni.Deny))
+		Expect(verdicter.Verdict(tcpPacket(1, hello))).To(Equal(sni.Deny))
+		Expect(verdicter.Verdict(tcpPacket(1+uint32(len(hello)), nil))).To(Equal(sni.Deny))
+	})
+
+	Context("when the client hello spans several segments", func() {
+		var first, second []byte
+
+		BeforeEach(func() {
+			hello := clientHello("api.example.com")
+			first, second = hello[:20], hello[20:]
+		})
+
+		It("passes the segments until it has the server name", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, first))).To(Equal(sni.Pass))
+			Expect(verdicter.Verdict(tcpPacket(21, second))).To(Equal(sni.Allow))
+		})
+
+		It("passes retransmitted segments", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, first))).To(Equal(sni.Pass))
+			Expect(verdicter.Verdict(tcpPacket(1, first))).To(Equal(sni.Pass))
+			Expect(verdicter.Verdict(tcpPacket(21, second))).To(Equal(sni.Allow))
+		})
+
+		It("holds segments out of order until the missing ones arrive", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, first[:10]))).To(Equal(sni.Pass))
+			Expect(verdicter.Verdict(tcpPacket(21, second))).To(Equal(sni.Hold))
+			Expect(verdicter.Verdict(tcpPacket(11, first[10:]))).To(Equal(sni.Pass))
+			Expect(verdicter.Verdict(tcpPacket(21, second))).To(Equal(sni.Allow))
+		})
+
+		It("forgets the connections that are idle for the flow timeout", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, first))).To(Equal(sni.Pass))
+			now = now.Add(time.Minute)
+			Expect(verdicter.Verdict(tcpPacket(21, second))).To(Equal(sni.Deny))
+		})
+	})
+
+	Context("when a connection cannot be inspected", func() {
+		It("denies it and logs it", func() {
+			Expect(verdicter.Verdict(tcpPacket(1, []byte("GET / HTTP/1.1\r\n")))).To(Equal(sni.Deny))
+			Expect(logger).To(Say(`uninspectable-connection.*"error":"not a tls client hello","fail_open":false`))
+		})
+
+		It("denies a connection whose data was cut off before the server name", func() {
+			hello := clientHello("api.example.com")
+			packet := tcpPacket(1, hello)
+			Expect(verdicter.Verdict(packet[:60])).To(Equal(sni.Deny))
+		})
+
+		Context("when it fails open", func() {
+			BeforeEach(func() {
+				verdicter.FailOpen = true
+			})
+
+			It("allows it", func() {
+				Expect(verdicter.Verdict(tcpPacket(1, []byte("GET / HTTP/1.1\r\n")))).To(Equal(sni.Allow))
+				Expect(verdicter.Verdict([]byte{0x60})).To(Equal(sni.Allow))
+			})
+		})
+	})
+})